@@ -3,6 +3,7 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/pockode/server/session"
 )
@@ -16,6 +17,33 @@ const (
 	PermissionAlwaysAllow                         // Allow and persist for future requests
 )
 
+// String returns the wire name: "deny", "allow" or "always_allow".
+func (c PermissionChoice) String() string {
+	switch c {
+	case PermissionAllow:
+		return "allow"
+	case PermissionAlwaysAllow:
+		return "always_allow"
+	default:
+		return "deny"
+	}
+}
+
+// readOnlyTools are tools that only read the workspace, so allowing them
+// without a user decision cannot change anything.
+var readOnlyTools = map[string]bool{
+	"Read":         true,
+	"Glob":         true,
+	"Grep":         true,
+	"LS":           true,
+	"NotebookRead": true,
+}
+
+// IsReadOnlyTool reports whether toolName only reads the workspace.
+func IsReadOnlyTool(toolName string) bool {
+	return readOnlyTools[toolName]
+}
+
 // PermissionRequestData contains the data needed to send a permission response.
 type PermissionRequestData struct {
 	RequestID             string
@@ -34,10 +62,60 @@ type QuestionRequestData struct {
 type StartOptions struct {
 	WorkDir    string
 	DataDir    string // data directory for MCP config
+	MCPDataDir string // data directory the built-in MCP server runs with; empty = DataDir
 	SessionID  string
 	Resume     bool
-	Mode       session.Mode
-	DisableMCP bool // skip MCP config (for testing)
+	Mode       session.Mode // always a built-in mode; custom modes are resolved by the caller
+	DisableMCP bool         // skip MCP config (for testing)
+	Env        []string     // extra KEY=VALUE entries appended to the inherited environment
+	// MCPServers are started alongside the built-in pockode MCP server
+	// (merged worktree and agent role configuration).
+	MCPServers []MCPServer
+	// From a custom session mode: text appended to the system prompt, and
+	// when non-empty, the only tools the agent may use (see ToolAllowed).
+	SystemPromptSuffix string
+	AllowedTools       []string
+}
+
+// MCPDir returns the data directory the built-in MCP server is run with.
+func (o StartOptions) MCPDir() string {
+	if o.MCPDataDir != "" {
+		return o.MCPDataDir
+	}
+	return o.DataDir
+}
+
+// ToolAllowed reports whether toolName is permitted by an allowed-tools
+// list. An empty list allows everything; entries ending in "*" match by
+// prefix.
+func ToolAllowed(allowed []string, toolName string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if prefix, ok := strings.CutSuffix(a, "*"); ok {
+			if strings.HasPrefix(toolName, prefix) {
+				return true
+			}
+		} else if a == toolName {
+			return true
+		}
+	}
+	return false
+}
+
+// OnlyReadOnlyTools reports whether an allowed-tools list restricts the
+// agent to read-only tools, so a sandbox can enforce it too.
+func OnlyReadOnlyTools(allowed []string) bool {
+	if len(allowed) == 0 {
+		return false
+	}
+	for _, a := range allowed {
+		if !IsReadOnlyTool(a) {
+			return false
+		}
+	}
+	return true
 }
 
 // Agent defines the interface for an AI agent.
@@ -37,20 +37,18 @@ func New() *Agent {
 }
 
 // ensureMCPConfig writes the MCP config file and returns its path.
-// The config points to the current binary with the "mcp" subcommand.
-func ensureMCPConfig(dataDir string) (string, error) {
+// The config points to the current binary with the "mcp" subcommand, plus any
+// extra servers. The built-in server's args name the session, so each session
+// gets its own file (0600, since extra server env often holds credentials);
+// only a session-less config without extras uses the shared file.
+func ensureMCPConfig(dataDir, sessionID string, extra []agent.MCPServer) (string, error) {
 	exe, err := os.Executable()
 	if err != nil {
 		return "", fmt.Errorf("resolve executable path: %w", err)
 	}
 
 	config := map[string]interface{}{
-		"mcpServers": map[string]interface{}{
-			"pockode": map[string]interface{}{
-				"command": exe,
-				"args":    []string{"mcp", "--data-dir", dataDir},
-			},
-		},
+		"mcpServers": agent.MCPServersConfig(exe, dataDir, sessionID, extra),
 	}
 
 	data, err := json.MarshalIndent(config, "", "  ")
@@ -58,14 +56,46 @@ func ensureMCPConfig(dataDir string) (string, error) {
 		return "", err
 	}
 
-	configPath := filepath.Join(dataDir, "mcp-config.json")
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return "", err
+	if sessionID == "" && len(extra) == 0 {
+		configPath := filepath.Join(dataDir, "mcp-config.json")
+		if err := os.WriteFile(configPath, data, 0644); err != nil {
+			return "", err
+		}
+		return configPath, nil
 	}
 
+	configDir := filepath.Join(dataDir, "mcp-configs")
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return "", err
+	}
+	configPath := filepath.Join(configDir, sessionID+".json")
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		return "", err
+	}
 	return configPath, nil
 }
 
+// modeArgs returns the permission and prompt flags for the session mode.
+func modeArgs(opts agent.StartOptions) []string {
+	// Always use permission-prompt-tool so we receive control_request events
+	// (including AskUserQuestion) regardless of mode.
+	args := []string{"--permission-prompt-tool", "stdio"}
+	switch opts.Mode {
+	case session.ModeYolo:
+		// bypassPermissions would skip the prompts the server uses to deny
+		// tools outside AllowedTools; the server auto-allows the rest instead.
+		if len(opts.AllowedTools) == 0 {
+			args = append(args, "--permission-mode", "bypassPermissions")
+		}
+	case session.ModePlan:
+		args = append(args, "--permission-mode", "plan")
+	}
+	if opts.SystemPromptSuffix != "" {
+		args = append(args, "--append-system-prompt", opts.SystemPromptSuffix)
+	}
+	return args
+}
+
 // Start launches a persistent Claude CLI process.
 func (a *Agent) Start(ctx context.Context, opts agent.StartOptions) (agent.Session, error) {
 	procCtx, cancel := context.WithCancel(ctx)
@@ -76,12 +106,7 @@ func (a *Agent) Start(ctx context.Context, opts agent.StartOptions) (agent.Sessi
 		"--verbose",
 	}
 
-	// Always use permission-prompt-tool so we receive control_request events
-	// (including AskUserQuestion) regardless of mode.
-	claudeArgs = append(claudeArgs, "--permission-prompt-tool", "stdio")
-	if opts.Mode == session.ModeYolo {
-		claudeArgs = append(claudeArgs, "--permission-mode", "bypassPermissions")
-	}
+	claudeArgs = append(claudeArgs, modeArgs(opts)...)
 
 	resumeState := newClaudeResumeStateManager(opts, slog.With("sessionId", opts.SessionID))
 	providerSessionID, shouldResume := resumeState.resolve()
@@ -95,7 +120,7 @@ func (a *Agent) Start(ctx context.Context, opts agent.StartOptions) (agent.Sessi
 
 	// Add MCP config for work management tools (unless disabled for testing)
 	if !opts.DisableMCP {
-		mcpConfigPath, err := ensureMCPConfig(opts.DataDir)
+		mcpConfigPath, err := ensureMCPConfig(opts.MCPDir(), opts.SessionID, opts.MCPServers)
 		if err != nil {
 			cancel()
 			return nil, fmt.Errorf("failed to create MCP config: %w", err)
@@ -105,6 +130,9 @@ func (a *Agent) Start(ctx context.Context, opts agent.StartOptions) (agent.Sessi
 
 	cmd := exec.CommandContext(procCtx, Binary, claudeArgs...)
 	cmd.Dir = opts.WorkDir
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
+	}
 
 	// stdin ownership is transferred to session; closed by session.Close()
 	stdin, err := cmd.StdinPipe()
@@ -650,11 +678,13 @@ type cliMessageString struct {
 type cliContentBlock struct {
 	Type      string          `json:"type"`
 	Text      string          `json:"text,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
 	ID        string          `json:"id,omitempty"`
 	Name      string          `json:"name,omitempty"`
 	Input     json.RawMessage `json:"input,omitempty"`
 	ToolUseID string          `json:"tool_use_id,omitempty"`
 	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
 }
 
 func parseLine(log *slog.Logger, line []byte, pendingRequests *sync.Map) []agent.AgentEvent {
@@ -692,11 +722,21 @@ func parseLine(log *slog.Logger, line []byte, pendingRequests *sync.Map) []agent
 		// Other CLI wrappers also ignore it.
 		return nil
 	default:
-		log.Debug("unhandled event type from CLI", "type", event.Type)
-		return nil
+		// Likely added by a newer CLI: keep it visible rather than drop it.
+		if _, seen := warnedEventTypes.LoadOrStore(event.Type, true); seen {
+			log.Debug("unknown event type from CLI", "kind", event.Type, "subtype", event.Subtype)
+		} else {
+			log.Warn("unknown event type from CLI, passing through", "kind", event.Type, "subtype", event.Subtype, "lineLength", len(line))
+		}
+		return []agent.AgentEvent{agent.RawEvent{Content: string(line), Kind: event.Type, Unknown: true}}
 	}
 }
 
+// warnedEventTypes holds the unknown CLI event types already warned about,
+// so a chatty new kind logs one warning per server run instead of one per
+// line.
+var warnedEventTypes sync.Map
+
 func parseControlRequest(log *slog.Logger, line []byte, pendingRequests *sync.Map) []agent.AgentEvent {
 	var req controlRequest
 	if err := json.Unmarshal(line, &req); err != nil {
@@ -815,18 +855,30 @@ func parseAssistantEvent(log *slog.Logger, event cliEvent) []agent.AgentEvent {
 	var events []agent.AgentEvent
 	var textParts []string
 
-	// TODO: Handle thinking/redacted_thinking blocks and other missing fields.
+	flushText := func() {
+		if len(textParts) > 0 {
+			events = append(events, agent.TextEvent{Content: strings.Join(textParts, "")})
+			textParts = nil
+		}
+	}
+
 	for _, block := range msg.Content {
 		switch block.Type {
 		case "text":
 			if block.Text != "" {
 				textParts = append(textParts, block.Text)
 			}
-		case "tool_use", "server_tool_use":
-			if len(textParts) > 0 {
-				events = append(events, agent.TextEvent{Content: strings.Join(textParts, "")})
-				textParts = nil
+		case "thinking":
+			if block.Thinking != "" {
+				flushText()
+				events = append(events, agent.ThinkingEvent{Content: block.Thinking})
 			}
+		case "redacted_thinking":
+			// The payload is encrypted for the API only; keep just a marker.
+			flushText()
+			events = append(events, agent.ThinkingEvent{Redacted: true})
+		case "tool_use", "server_tool_use":
+			flushText()
 			events = append(events, agent.ToolCallEvent{
 				ToolUseID: block.ID,
 				ToolName:  block.Name,
@@ -835,9 +887,7 @@ func parseAssistantEvent(log *slog.Logger, event cliEvent) []agent.AgentEvent {
 		}
 	}
 
-	if len(textParts) > 0 {
-		events = append(events, agent.TextEvent{Content: strings.Join(textParts, "")})
-	}
+	flushText()
 
 	return events
 }
@@ -883,6 +933,7 @@ func parseUserEvent(log *slog.Logger, event cliEvent) []agent.AgentEvent {
 			events = append(events, agent.ToolResultEvent{
 				ToolUseID:  block.ToolUseID,
 				ToolResult: content,
+				IsError:    block.IsError,
 			})
 
 		default:
@@ -977,7 +1028,14 @@ func hasImageContent(content json.RawMessage) bool {
 type resultEvent struct {
 	Subtype   string   `json:"subtype"`
 	SessionID string   `json:"session_id"`
+	IsError   bool     `json:"is_error"`
+	Result    string   `json:"result"`
 	Errors    []string `json:"errors"`
+	Usage     struct {
+		InputTokens              int64 `json:"input_tokens"`
+		OutputTokens             int64 `json:"output_tokens"`
+		CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	} `json:"usage"`
 }
 
 func parseResultEvent(line []byte) agent.AgentEvent {
@@ -995,5 +1053,18 @@ func parseResultEvent(line []byte) agent.AgentEvent {
 		}
 	}
 
-	return agent.DoneEvent{}
+	// API failures (expired login, rate limit, unreachable API) end the turn
+	// with is_error set; surface the classified ones as errors so work can
+	// back off instead of retrying at once.
+	if result.IsError {
+		msg := strings.Join(append([]string{result.Result}, result.Errors...), "\n")
+		if code := agent.ClassifyError(msg); code != "" {
+			return agent.ErrorEvent{Error: strings.TrimSpace(msg), Code: code}
+		}
+	}
+
+	// Cache reads are left out: they are billed at a fraction of the rate
+	// and would dominate the count on long sessions.
+	u := result.Usage
+	return agent.DoneEvent{Tokens: u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens}
 }
@@ -7,12 +7,42 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"testing"
 
 	"github.com/pockode/server/agent"
+	"github.com/pockode/server/session"
 )
 
+func TestModeArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts agent.StartOptions
+		want []string
+	}{
+		{"default", agent.StartOptions{Mode: session.ModeDefault}, []string{"--permission-prompt-tool", "stdio"}},
+		{"yolo", agent.StartOptions{Mode: session.ModeYolo}, []string{"--permission-prompt-tool", "stdio", "--permission-mode", "bypassPermissions"}},
+		{
+			"yolo with allowed tools keeps prompts",
+			agent.StartOptions{Mode: session.ModeYolo, AllowedTools: []string{"Read"}},
+			[]string{"--permission-prompt-tool", "stdio"},
+		},
+		{
+			"plan with prompt suffix",
+			agent.StartOptions{Mode: session.ModePlan, SystemPromptSuffix: "Review only."},
+			[]string{"--permission-prompt-tool", "stdio", "--permission-mode", "plan", "--append-system-prompt", "Review only."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := modeArgs(tt.opts); !slices.Equal(got, tt.want) {
+				t.Errorf("modeArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseLine(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -39,11 +69,31 @@ func TestParseLine(t *testing.T) {
 			input:    `{"type":"result","subtype":"success","result":"Hello"}`,
 			expected: []agent.AgentEvent{agent.DoneEvent{}},
 		},
+		{
+			name:     "result event with usage",
+			input:    `{"type":"result","subtype":"success","usage":{"input_tokens":10,"output_tokens":20,"cache_creation_input_tokens":5,"cache_read_input_tokens":1000}}`,
+			expected: []agent.AgentEvent{agent.DoneEvent{Tokens: 35}},
+		},
 		{
 			name:     "result event interrupted",
 			input:    `{"type":"result","subtype":"error_during_execution","errors":["Error: Request was aborted."]}`,
 			expected: []agent.AgentEvent{agent.InterruptedEvent{}},
 		},
+		{
+			name:     "result event rate limited",
+			input:    `{"type":"result","subtype":"success","is_error":true,"result":"API Error: 429 {\"type\":\"rate_limit_error\"}"}`,
+			expected: []agent.AgentEvent{agent.ErrorEvent{Error: `API Error: 429 {"type":"rate_limit_error"}`, Code: agent.ErrorCodeRateLimit}},
+		},
+		{
+			name:     "result event login expired",
+			input:    `{"type":"result","subtype":"success","is_error":true,"result":"Invalid API key · Please run /login"}`,
+			expected: []agent.AgentEvent{agent.ErrorEvent{Error: "Invalid API key · Please run /login", Code: agent.ErrorCodeAuth}},
+		},
+		{
+			name:     "result event unclassified error",
+			input:    `{"type":"result","subtype":"error_max_turns","is_error":true}`,
+			expected: []agent.AgentEvent{agent.DoneEvent{}},
+		},
 		{
 			name:     "assistant text message",
 			input:    `{"type":"assistant","message":{"content":[{"type":"text","text":"Hello World"}]}}`,
@@ -59,6 +109,28 @@ func TestParseLine(t *testing.T) {
 			input:    `{"type":"assistant","message":{"content":[]}}`,
 			expected: nil,
 		},
+		{
+			name:  "assistant thinking before text",
+			input: `{"type":"assistant","message":{"content":[{"type":"thinking","thinking":"Check the tests first","signature":"sig"},{"type":"text","text":"Done"}]}}`,
+			expected: []agent.AgentEvent{
+				agent.ThinkingEvent{Content: "Check the tests first"},
+				agent.TextEvent{Content: "Done"},
+			},
+		},
+		{
+			name:  "assistant redacted thinking between text",
+			input: `{"type":"assistant","message":{"content":[{"type":"text","text":"Hmm"},{"type":"redacted_thinking","data":"EncryptedBlob"},{"type":"text","text":"OK"}]}}`,
+			expected: []agent.AgentEvent{
+				agent.TextEvent{Content: "Hmm"},
+				agent.ThinkingEvent{Redacted: true},
+				agent.TextEvent{Content: "OK"},
+			},
+		},
+		{
+			name:     "assistant empty thinking block is skipped",
+			input:    `{"type":"assistant","message":{"content":[{"type":"thinking","thinking":""}]}}`,
+			expected: nil,
+		},
 		{
 			name:  "assistant tool_use message",
 			input: `{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_123","name":"Read","input":{"file":"test.go"}}]}}`,
@@ -113,6 +185,15 @@ func TestParseLine(t *testing.T) {
 				ToolResult: "file contents here",
 			}},
 		},
+		{
+			name:  "user tool_result flagged as error",
+			input: `{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_err","content":"Exit code 1","is_error":true}]}}`,
+			expected: []agent.AgentEvent{agent.ToolResultEvent{
+				ToolUseID:  "toolu_err",
+				ToolResult: "Exit code 1",
+				IsError:    true,
+			}},
+		},
 		{
 			name:  "user tool_result with image content returns warning",
 			input: `{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_img","content":[{"type":"image","source":{"type":"base64","data":"..."}},{"type":"text","text":"description"}]}]}}`,
@@ -154,9 +235,13 @@ func TestParseLine(t *testing.T) {
 			expected: nil,
 		},
 		{
-			name:     "unknown event type is ignored",
-			input:    `{"type":"unknown_event"}`,
-			expected: nil,
+			name:  "unknown event type is passed through",
+			input: `{"type":"unknown_event","subtype":"x","data":1}`,
+			expected: []agent.AgentEvent{agent.RawEvent{
+				Content: `{"type":"unknown_event","subtype":"x","data":1}`,
+				Kind:    "unknown_event",
+				Unknown: true,
+			}},
 		},
 		{
 			name:  "control_request permission request",
@@ -252,6 +337,9 @@ func agentEventEqual(a, b agent.AgentEvent) bool {
 	case agent.TextEvent:
 		bv, ok := b.(agent.TextEvent)
 		return ok && av.Content == bv.Content
+	case agent.ThinkingEvent:
+		bv, ok := b.(agent.ThinkingEvent)
+		return ok && av.Content == bv.Content && av.Redacted == bv.Redacted
 	case agent.ToolCallEvent:
 		bv, ok := b.(agent.ToolCallEvent)
 		return ok && av.ToolUseID == bv.ToolUseID && av.ToolName == bv.ToolName &&
@@ -264,7 +352,7 @@ func agentEventEqual(a, b agent.AgentEvent) bool {
 		return ok && av.Message == bv.Message && av.Code == bv.Code
 	case agent.ErrorEvent:
 		bv, ok := b.(agent.ErrorEvent)
-		return ok && av.Error == bv.Error
+		return ok && av.Error == bv.Error && av.Code == bv.Code
 	case agent.DoneEvent:
 		_, ok := b.(agent.DoneEvent)
 		return ok
@@ -988,3 +1076,65 @@ func TestExtractEventsFromText(t *testing.T) {
 		})
 	}
 }
+
+func TestEnsureMCPConfig(t *testing.T) {
+	dataDir := t.TempDir()
+
+	readServers := func(path string) map[string]map[string]any {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var config struct {
+			MCPServers map[string]map[string]any `json:"mcpServers"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			t.Fatal(err)
+		}
+		return config.MCPServers
+	}
+
+	shared, err := ensureMCPConfig(dataDir, "", nil)
+	if err != nil {
+		t.Fatalf("ensureMCPConfig: %v", err)
+	}
+	if shared != filepath.Join(dataDir, "mcp-config.json") {
+		t.Errorf("path = %s, want shared mcp-config.json", shared)
+	}
+	if servers := readServers(shared); len(servers) != 1 || servers["pockode"] == nil {
+		t.Errorf("servers = %v, want only pockode", servers)
+	}
+
+	own, err := ensureMCPConfig(dataDir, "sess-0", nil)
+	if err != nil {
+		t.Fatalf("ensureMCPConfig: %v", err)
+	}
+	if own == shared {
+		t.Fatal("a session's config must not overwrite the shared config")
+	}
+	args, _ := readServers(own)["pockode"]["args"].([]any)
+	if len(args) < 2 || args[len(args)-2] != "--session-id" || args[len(args)-1] != "sess-0" {
+		t.Errorf("pockode args = %v, want --session-id sess-0", args)
+	}
+
+	extra := []agent.MCPServer{{Name: "db", Command: "db-mcp", Env: map[string]string{"TOKEN": "t"}}}
+	perSession, err := ensureMCPConfig(dataDir, "sess-1", extra)
+	if err != nil {
+		t.Fatalf("ensureMCPConfig: %v", err)
+	}
+	if perSession == shared {
+		t.Fatal("a session with extra servers must not overwrite the shared config")
+	}
+	info, err := os.Stat(perSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	servers := readServers(perSession)
+	if servers["pockode"] == nil || servers["db"]["command"] != "db-mcp" {
+		t.Errorf("servers = %v, want pockode and db", servers)
+	}
+}
@@ -0,0 +1,114 @@
+package claude
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/pockode/server/agent"
+)
+
+const listCommandsTimeout = 15 * time.Second
+
+type initializeRequest struct {
+	Type      string                `json:"type"`
+	RequestID string                `json:"request_id"`
+	Request   initializeRequestData `json:"request"`
+}
+
+type initializeRequestData struct {
+	Subtype string `json:"subtype"`
+}
+
+type initializeResponse struct {
+	Type     string `json:"type"`
+	Response struct {
+		Subtype   string `json:"subtype"`
+		RequestID string `json:"request_id"`
+		Error     string `json:"error,omitempty"`
+		Response  struct {
+			Commands []agent.SlashCommand `json:"commands"`
+		} `json:"response"`
+	} `json:"response"`
+}
+
+// ListCommands starts the CLI in workDir just long enough to answer the
+// initialize control request, whose reply lists the slash commands
+// available there (including custom ones from .claude/commands). No
+// prompt is sent, so it costs nothing.
+func (a *Agent) ListCommands(ctx context.Context, workDir string) ([]agent.SlashCommand, error) {
+	ctx, cancel := context.WithTimeout(ctx, listCommandsTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, Binary,
+		"--output-format", "stream-json",
+		"--input-format", "stream-json",
+		"--verbose",
+	)
+	cmd.Dir = workDir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start claude: %w", err)
+	}
+	defer func() {
+		// The process would otherwise wait for a prompt.
+		stdin.Close()
+		cancel()
+		_ = cmd.Wait()
+	}()
+
+	commands, err := requestCommands(stdin, stdout)
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("listing commands: %w", ctx.Err())
+	}
+	return commands, err
+}
+
+// requestCommands sends the initialize control request and reads output
+// until its response.
+func requestCommands(stdin io.Writer, stdout io.Reader) ([]agent.SlashCommand, error) {
+	requestID := generateRequestID()
+	data, err := json.Marshal(initializeRequest{
+		Type:      "control_request",
+		RequestID: requestID,
+		Request:   initializeRequestData{Subtype: "initialize"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal initialize request: %w", err)
+	}
+	if _, err := stdin.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to send initialize request: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var resp initializeResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			continue
+		}
+		if resp.Type != "control_response" || resp.Response.RequestID != requestID {
+			continue
+		}
+		if resp.Response.Subtype != "success" {
+			return nil, fmt.Errorf("initialize request failed: %s", resp.Response.Error)
+		}
+		return resp.Response.Response.Commands, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read claude output: %w", err)
+	}
+	return nil, errors.New("claude exited before listing its commands")
+}
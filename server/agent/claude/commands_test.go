@@ -0,0 +1,61 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeInitialize answers the initialize request written to stdin with reply,
+// after some unrelated output.
+func fakeInitialize(t *testing.T, reply func(requestID string) string) (io.Writer, io.Reader) {
+	t.Helper()
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	go func() {
+		defer stdoutW.Close()
+		line, err := bufio.NewReader(stdinR).ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req initializeRequest
+		if err := json.Unmarshal(line, &req); err != nil || req.Request.Subtype != "initialize" {
+			t.Errorf("unexpected request: %s", line)
+			return
+		}
+		fmt.Fprintln(stdoutW, `{"type":"system","subtype":"init"}`)
+		fmt.Fprintln(stdoutW, `{"type":"control_response","response":{"subtype":"success","request_id":"other"}}`)
+		fmt.Fprintln(stdoutW, reply(req.RequestID))
+	}()
+	return stdinW, stdoutR
+}
+
+func TestRequestCommands(t *testing.T) {
+	stdin, stdout := fakeInitialize(t, func(id string) string {
+		return `{"type":"control_response","response":{"subtype":"success","request_id":"` + id + `","response":{"commands":[` +
+			`{"name":"compact","description":"Clear history but keep a summary","argumentHint":"<optional instructions>"},` +
+			`{"name":"fix-issue","description":"Fix a GitHub issue (project)","argumentHint":"<issue>"}]}}}`
+	})
+
+	commands, err := requestCommands(stdin, stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 2 || commands[1].Name != "fix-issue" || commands[1].ArgumentHint != "<issue>" {
+		t.Errorf("commands = %+v", commands)
+	}
+}
+
+func TestRequestCommands_Error(t *testing.T) {
+	stdin, stdout := fakeInitialize(t, func(id string) string {
+		return `{"type":"control_response","response":{"subtype":"error","request_id":"` + id + `","error":"Already initialized"}}`
+	})
+
+	_, err := requestCommands(stdin, stdout)
+	if err == nil || !strings.Contains(err.Error(), "Already initialized") {
+		t.Errorf("err = %v, want the CLI's error", err)
+	}
+}
@@ -0,0 +1,174 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pockode/server/agent"
+)
+
+const (
+	healthCommandTimeout = 10 * time.Second
+	healthHTTPTimeout    = 5 * time.Second
+	defaultAPIBaseURL    = "https://api.anthropic.com"
+)
+
+// Environment variables the CLI authenticates with instead of a login.
+var credentialEnvVars = []string{"ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN", "CLAUDE_CODE_OAUTH_TOKEN"}
+
+// Third-party providers authenticate through their own SDK configuration,
+// which the CLI resolves itself.
+var providerEnvVars = []string{"CLAUDE_CODE_USE_BEDROCK", "CLAUDE_CODE_USE_VERTEX"}
+
+// healthProbe holds the system access a health check needs, so tests can
+// replace it.
+type healthProbe struct {
+	lookPath func(file string) (string, error)
+	output   func(ctx context.Context, name string, args ...string) ([]byte, error)
+	getenv   func(key string) string
+	homeDir  func() (string, error)
+	stat     func(name string) (os.FileInfo, error)
+	reach    func(ctx context.Context, url string) error
+	goos     string
+}
+
+func systemHealthProbe() healthProbe {
+	return healthProbe{
+		lookPath: exec.LookPath,
+		output: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return exec.CommandContext(ctx, name, args...).Output()
+		},
+		getenv:  os.Getenv,
+		homeDir: os.UserHomeDir,
+		stat:    os.Stat,
+		reach:   reachURL,
+		goos:    runtime.GOOS,
+	}
+}
+
+// reachURL succeeds on any HTTP response: an unauthenticated request is
+// rejected by the API, but getting that answer proves DNS, proxy and TLS
+// work.
+func reachURL(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Timeout: healthHTTPTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// HealthCheck verifies the claude CLI is installed, has credentials and
+// can reach the API. It never sends a prompt, so it costs nothing.
+func (a *Agent) HealthCheck(ctx context.Context) agent.HealthReport {
+	return systemHealthProbe().check(ctx)
+}
+
+func (p healthProbe) check(ctx context.Context) agent.HealthReport {
+	report := agent.HealthReport{Checks: []agent.HealthCheck{}}
+	p.checkCLI(ctx, &report)
+	provider := p.checkAuth(&report)
+	p.checkAPI(ctx, &report, provider)
+	return report
+}
+
+func (p healthProbe) checkCLI(ctx context.Context, report *agent.HealthReport) {
+	path, err := p.lookPath(Binary)
+	if err != nil {
+		report.Add("cli", agent.HealthFail, fmt.Sprintf("%s CLI not found in PATH; install it with `npm install -g @anthropic-ai/claude-code`", Binary))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCommandTimeout)
+	defer cancel()
+	out, err := p.output(ctx, path, "--version")
+	if err != nil {
+		report.Add("cli", agent.HealthFail, fmt.Sprintf("%s --version failed: %v", path, err))
+		return
+	}
+	report.Version = strings.TrimSpace(string(out))
+	report.Add("cli", agent.HealthOK, path)
+}
+
+// checkAuth reports where credentials come from and whether a third-party
+// provider (which has its own endpoint) is in use.
+func (p healthProbe) checkAuth(report *agent.HealthReport) (provider string) {
+	for _, key := range providerEnvVars {
+		if isTruthy(p.getenv(key)) {
+			report.Add("auth", agent.HealthOK, "using "+key)
+			return key
+		}
+	}
+	for _, key := range credentialEnvVars {
+		if p.getenv(key) != "" {
+			report.Add("auth", agent.HealthOK, "using "+key)
+			return ""
+		}
+	}
+
+	if path, ok := p.credentialsFile(); ok {
+		report.Add("auth", agent.HealthOK, "logged in ("+path+")")
+		return ""
+	}
+	if p.goos == "darwin" {
+		// The login is kept in the keychain, which cannot be read without
+		// prompting the user.
+		report.Add("auth", agent.HealthWarn, "no credentials file or API key; a login stored in the macOS keychain cannot be verified")
+		return ""
+	}
+	report.Add("auth", agent.HealthFail, fmt.Sprintf("not logged in: run `%s` and /login, or set ANTHROPIC_API_KEY", Binary))
+	return ""
+}
+
+func (p healthProbe) credentialsFile() (string, bool) {
+	dir := p.getenv("CLAUDE_CONFIG_DIR")
+	if dir == "" {
+		home, err := p.homeDir()
+		if err != nil {
+			return "", false
+		}
+		dir = filepath.Join(home, ".claude")
+	}
+	path := filepath.Join(dir, ".credentials.json")
+	if info, err := p.stat(path); err != nil || info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
+
+func (p healthProbe) checkAPI(ctx context.Context, report *agent.HealthReport, provider string) {
+	if provider != "" {
+		report.Add("api", agent.HealthWarn, "not checked: requests go through "+provider)
+		return
+	}
+	url := p.getenv("ANTHROPIC_BASE_URL")
+	if url == "" {
+		url = defaultAPIBaseURL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthHTTPTimeout)
+	defer cancel()
+	if err := p.reach(ctx, url); err != nil {
+		report.Add("api", agent.HealthFail, fmt.Sprintf("cannot reach %s: %v", url, err))
+		return
+	}
+	report.Add("api", agent.HealthOK, url)
+}
+
+func isTruthy(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/pockode/server/agent"
+)
+
+type fakeFileInfo struct{ os.FileInfo }
+
+func (fakeFileInfo) IsDir() bool { return false }
+
+func fakeHealthProbe(env map[string]string, files map[string]bool) healthProbe {
+	return healthProbe{
+		lookPath: func(string) (string, error) { return "/usr/local/bin/claude", nil },
+		output: func(context.Context, string, ...string) ([]byte, error) {
+			return []byte("2.0.1 (Claude Code)\n"), nil
+		},
+		getenv:  func(key string) string { return env[key] },
+		homeDir: func() (string, error) { return "/home/u", nil },
+		stat: func(name string) (os.FileInfo, error) {
+			if files[name] {
+				return fakeFileInfo{}, nil
+			}
+			return nil, os.ErrNotExist
+		},
+		reach: func(context.Context, string) error { return nil },
+		goos:  "linux",
+	}
+}
+
+func checkStatuses(report agent.HealthReport) map[string]agent.HealthStatus {
+	got := make(map[string]agent.HealthStatus)
+	for _, c := range report.Checks {
+		got[c.Name] = c.Status
+	}
+	return got
+}
+
+func TestHealthProbe(t *testing.T) {
+	t.Run("healthy with credentials file", func(t *testing.T) {
+		p := fakeHealthProbe(nil, map[string]bool{"/home/u/.claude/.credentials.json": true})
+		report := p.check(context.Background())
+
+		if !report.Healthy {
+			t.Errorf("expected healthy, got %+v", report)
+		}
+		if report.Version != "2.0.1 (Claude Code)" {
+			t.Errorf("Version = %q", report.Version)
+		}
+		want := map[string]agent.HealthStatus{"cli": agent.HealthOK, "auth": agent.HealthOK, "api": agent.HealthOK}
+		for name, status := range want {
+			if got := checkStatuses(report)[name]; got != status {
+				t.Errorf("%s = %q, want %q", name, got, status)
+			}
+		}
+	})
+
+	t.Run("CLI missing", func(t *testing.T) {
+		p := fakeHealthProbe(map[string]string{"ANTHROPIC_API_KEY": "k"}, nil)
+		p.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+		report := p.check(context.Background())
+
+		if report.Healthy || checkStatuses(report)["cli"] != agent.HealthFail {
+			t.Errorf("expected cli failure, got %+v", report)
+		}
+	})
+
+	t.Run("not logged in", func(t *testing.T) {
+		report := fakeHealthProbe(nil, nil).check(context.Background())
+		if report.Healthy || checkStatuses(report)["auth"] != agent.HealthFail {
+			t.Errorf("expected auth failure, got %+v", report)
+		}
+	})
+
+	t.Run("keychain on macOS is a warning", func(t *testing.T) {
+		p := fakeHealthProbe(nil, nil)
+		p.goos = "darwin"
+		report := p.check(context.Background())
+		if !report.Healthy || checkStatuses(report)["auth"] != agent.HealthWarn {
+			t.Errorf("expected auth warning, got %+v", report)
+		}
+	})
+
+	t.Run("CLAUDE_CONFIG_DIR", func(t *testing.T) {
+		p := fakeHealthProbe(map[string]string{"CLAUDE_CONFIG_DIR": "/cfg"}, map[string]bool{"/cfg/.credentials.json": true})
+		if got := checkStatuses(p.check(context.Background()))["auth"]; got != agent.HealthOK {
+			t.Errorf("auth = %q, want ok", got)
+		}
+	})
+
+	t.Run("API unreachable uses base URL", func(t *testing.T) {
+		p := fakeHealthProbe(map[string]string{"ANTHROPIC_API_KEY": "k", "ANTHROPIC_BASE_URL": "https://proxy.example"}, nil)
+		var gotURL string
+		p.reach = func(_ context.Context, url string) error {
+			gotURL = url
+			return errors.New("dial tcp: timeout")
+		}
+		report := p.check(context.Background())
+		if gotURL != "https://proxy.example" {
+			t.Errorf("reached %q", gotURL)
+		}
+		if report.Healthy || checkStatuses(report)["api"] != agent.HealthFail {
+			t.Errorf("expected api failure, got %+v", report)
+		}
+	})
+
+	t.Run("third-party provider skips API check", func(t *testing.T) {
+		p := fakeHealthProbe(map[string]string{"CLAUDE_CODE_USE_BEDROCK": "1"}, nil)
+		p.reach = func(context.Context, string) error {
+			t.Error("API should not be probed")
+			return nil
+		}
+		report := p.check(context.Background())
+		if !report.Healthy || checkStatuses(report)["api"] != agent.HealthWarn {
+			t.Errorf("expected api warning, got %+v", report)
+		}
+	})
+}
+
+func TestReachURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	url := srv.URL
+	ctx := context.Background()
+
+	if err := reachURL(ctx, url); err != nil {
+		t.Errorf("401 should count as reachable, got %v", err)
+	}
+	srv.Close()
+	if err := reachURL(ctx, url); err == nil {
+		t.Error("expected error after server closed")
+	}
+}
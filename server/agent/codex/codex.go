@@ -49,6 +49,9 @@ func (a *Agent) Start(ctx context.Context, opts agent.StartOptions) (agent.Sessi
 
 	cmd := exec.CommandContext(procCtx, Binary, mcpSubcommand)
 	cmd.Dir = opts.WorkDir
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -433,12 +436,7 @@ func (s *mcpSession) buildStartConfig(prompt string) map[string]interface{} {
 		"prompt": prompt,
 		"cwd":    s.opts.WorkDir,
 		"config": map[string]interface{}{
-			"mcp_servers": map[string]interface{}{
-				"pockode": map[string]interface{}{
-					"command": s.exe,
-					"args":    []string{"mcp", "--data-dir", s.opts.DataDir},
-				},
-			},
+			"mcp_servers": agent.MCPServersConfig(s.exe, s.opts.MCPDir(), s.opts.SessionID, s.opts.MCPServers),
 		},
 	}
 
@@ -446,10 +444,24 @@ func (s *mcpSession) buildStartConfig(prompt string) map[string]interface{} {
 	case session.ModeYolo:
 		config["approval-policy"] = "never"
 		config["sandbox"] = "danger-full-access"
+	case session.ModePlan:
+		config["approval-policy"] = "untrusted"
+		config["sandbox"] = "read-only"
 	default:
 		config["approval-policy"] = "untrusted"
 		config["sandbox"] = "workspace-write"
 	}
+	if len(s.opts.AllowedTools) > 0 {
+		// Tools outside the list are denied at the approval prompt, which
+		// "never" would skip.
+		config["approval-policy"] = "untrusted"
+		if agent.OnlyReadOnlyTools(s.opts.AllowedTools) {
+			config["sandbox"] = "read-only"
+		}
+	}
+	if s.opts.SystemPromptSuffix != "" {
+		config["developer-instructions"] = s.opts.SystemPromptSuffix
+	}
 
 	return config
 }
@@ -575,6 +587,18 @@ func (s *mcpSession) processCodexMsg(raw json.RawMessage) {
 			s.emitEvent(agent.TextEvent{Content: ev.Message})
 		}
 
+	case "agent_reasoning":
+		var ev struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			s.log.Warn("failed to parse agent_reasoning", "error", err)
+			return
+		}
+		if ev.Text != "" {
+			s.emitEvent(agent.ThinkingEvent{Content: ev.Text})
+		}
+
 	case "exec_command_begin", "exec_approval_request":
 		var ev struct {
 			CallID  string          `json:"call_id"`
@@ -599,9 +623,10 @@ func (s *mcpSession) processCodexMsg(raw json.RawMessage) {
 
 	case "exec_command_end":
 		var ev struct {
-			CallID string `json:"call_id"`
-			Output string `json:"output"`
-			Error  string `json:"error"`
+			CallID   string `json:"call_id"`
+			Output   string `json:"output"`
+			Error    string `json:"error"`
+			ExitCode *int   `json:"exit_code"`
 		}
 		if err := json.Unmarshal(raw, &ev); err != nil {
 			s.log.Warn("failed to parse exec_command_end", "error", err)
@@ -614,6 +639,8 @@ func (s *mcpSession) processCodexMsg(raw json.RawMessage) {
 		s.emitEvent(agent.ToolResultEvent{
 			ToolUseID:  ev.CallID,
 			ToolResult: result,
+			IsError:    ev.Error != "" || (ev.ExitCode != nil && *ev.ExitCode != 0),
+			ExitCode:   ev.ExitCode,
 		})
 
 	case "patch_apply_begin":
@@ -649,6 +676,7 @@ func (s *mcpSession) processCodexMsg(raw json.RawMessage) {
 		s.emitEvent(agent.ToolResultEvent{
 			ToolUseID:  ev.CallID,
 			ToolResult: result,
+			IsError:    !ev.Success,
 		})
 
 	case "mcp_tool_call_begin":
@@ -693,9 +721,11 @@ func (s *mcpSession) processCodexMsg(raw json.RawMessage) {
 			return
 		}
 		var result string
-		if ev.Result.Err != "" {
+		isError := ev.Result.Err != ""
+		if isError {
 			result = ev.Result.Err
 		} else if ev.Result.Ok != nil {
+			isError = ev.Result.Ok.IsError
 			var parts []string
 			for _, c := range ev.Result.Ok.Content {
 				if c.Text != "" {
@@ -707,6 +737,7 @@ func (s *mcpSession) processCodexMsg(raw json.RawMessage) {
 		s.emitEvent(agent.ToolResultEvent{
 			ToolUseID:  ev.CallID,
 			ToolResult: result,
+			IsError:    isError,
 		})
 
 	case "task_complete":
@@ -78,6 +78,39 @@ func TestBuildStartConfig_MCPServers(t *testing.T) {
 	}
 }
 
+func TestBuildStartConfig_CustomMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         session.Mode
+		allowed      []string
+		wantApproval string
+		wantSandbox  string
+	}{
+		{"read-only tools", session.ModeYolo, []string{"Read", "Grep"}, "untrusted", "read-only"},
+		{"editing tools", session.ModeYolo, []string{"Read", "Edit"}, "untrusted", "danger-full-access"},
+		{"no restriction", session.ModeYolo, nil, "never", "danger-full-access"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &mcpSession{opts: agent.StartOptions{
+				Mode:               tt.mode,
+				AllowedTools:       tt.allowed,
+				SystemPromptSuffix: "Review only.",
+			}}
+			config := sess.buildStartConfig("hello")
+			if config["approval-policy"] != tt.wantApproval {
+				t.Errorf("approval-policy = %v, want %s", config["approval-policy"], tt.wantApproval)
+			}
+			if config["sandbox"] != tt.wantSandbox {
+				t.Errorf("sandbox = %v, want %s", config["sandbox"], tt.wantSandbox)
+			}
+			if config["developer-instructions"] != "Review only." {
+				t.Errorf("developer-instructions = %v", config["developer-instructions"])
+			}
+		})
+	}
+}
+
 func TestNormalizeCommand(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,17 @@
+package agent
+
+import "context"
+
+// SlashCommand is a slash command an agent's CLI offers in a directory:
+// its builtins plus the user's and project's custom commands.
+type SlashCommand struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	ArgumentHint string `json:"argumentHint,omitempty"` // e.g. "<file> [message]"
+}
+
+// CommandLister is implemented by agents whose CLI can list its slash
+// commands without running a prompt.
+type CommandLister interface {
+	ListCommands(ctx context.Context, workDir string) ([]SlashCommand, error)
+}
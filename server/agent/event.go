@@ -1,12 +1,17 @@
 package agent
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/pockode/server/session"
+)
 
 // EventType defines the type of agent event.
 type EventType string
 
 const (
 	EventTypeText               EventType = "text"
+	EventTypeThinking           EventType = "thinking" // Model reasoning, rendered collapsed
 	EventTypeToolCall           EventType = "tool_call"
 	EventTypeToolResult         EventType = "tool_result"
 	EventTypeWarning            EventType = "warning"
@@ -139,6 +144,21 @@ func (e TextEvent) ToRecord() EventRecord {
 	return EventRecord{Type: e.EventType(), Content: e.Content}
 }
 
+// ThinkingEvent is a reasoning block the model produced before answering.
+// Redacted blocks carry encrypted content the client cannot show, so only
+// the fact that reasoning happened is kept.
+type ThinkingEvent struct {
+	Content  string
+	Redacted bool
+}
+
+func (ThinkingEvent) EventType() EventType { return EventTypeThinking }
+func (ThinkingEvent) isAgentEvent()        {}
+
+func (e ThinkingEvent) ToRecord() EventRecord {
+	return EventRecord{Type: e.EventType(), Content: e.Content, Redacted: e.Redacted}
+}
+
 type ToolCallEvent struct {
 	ToolName  string
 	ToolInput json.RawMessage
@@ -160,6 +180,13 @@ func (e ToolCallEvent) ToRecord() EventRecord {
 type ToolResultEvent struct {
 	ToolUseID  string
 	ToolResult string
+	// FullSize is the byte length of the complete output when ToolResult was
+	// truncated (0 otherwise); the full output is fetched by tool use ID.
+	FullSize int
+	// IsError reports that the tool failed, as the agent CLI flagged it.
+	IsError bool
+	// ExitCode is a command's exit status, when the agent CLI reports it.
+	ExitCode *int
 }
 
 func (ToolResultEvent) EventType() EventType { return EventTypeToolResult }
@@ -167,9 +194,12 @@ func (ToolResultEvent) isAgentEvent()        {}
 
 func (e ToolResultEvent) ToRecord() EventRecord {
 	return EventRecord{
-		Type:       e.EventType(),
-		ToolUseID:  e.ToolUseID,
-		ToolResult: e.ToolResult,
+		Type:           e.EventType(),
+		ToolUseID:      e.ToolUseID,
+		ToolResult:     e.ToolResult,
+		ToolResultSize: e.FullSize,
+		IsError:        e.IsError,
+		ExitCode:       e.ExitCode,
 	}
 }
 
@@ -193,16 +223,21 @@ func (e WarningEvent) ToRecord() EventRecord {
 
 type ErrorEvent struct {
 	Error string
+	Code  string // ErrorCode* when the failure was classified; see ClassifyError
 }
 
 func (ErrorEvent) EventType() EventType { return EventTypeError }
 func (ErrorEvent) isAgentEvent()        {}
 
 func (e ErrorEvent) ToRecord() EventRecord {
-	return EventRecord{Type: e.EventType(), Error: e.Error}
+	return EventRecord{Type: e.EventType(), Error: e.Error, ErrorCode: e.Code}
 }
 
-type DoneEvent struct{}
+type DoneEvent struct {
+	// Tokens the turn consumed (input, output and cache writes), used for
+	// budgets; 0 when the agent does not report usage. Not persisted.
+	Tokens int64
+}
 
 func (DoneEvent) EventType() EventType { return EventTypeDone }
 func (DoneEvent) isAgentEvent()        {}
@@ -226,6 +261,10 @@ type PermissionRequestEvent struct {
 	ToolInput             json.RawMessage
 	ToolUseID             string
 	PermissionSuggestions []PermissionUpdate
+	// Group is set by the server on requests likely to get the same answer
+	// (same tool on the same directory, command or host), so clients can
+	// show pending ones as a single prompt.
+	Group string
 }
 
 func (PermissionRequestEvent) EventType() EventType { return EventTypePermissionRequest }
@@ -239,6 +278,7 @@ func (e PermissionRequestEvent) ToRecord() EventRecord {
 		ToolInput:             e.ToolInput,
 		ToolUseID:             e.ToolUseID,
 		PermissionSuggestions: e.PermissionSuggestions,
+		Group:                 e.Group,
 	}
 }
 
@@ -294,21 +334,32 @@ func (e ProcessEndedEvent) ToRecord() EventRecord {
 // MessageEvent represents a user message. Used for:
 // - History replay: reconstructing past messages
 // - Broadcast: notifying other clients when a user sends a message
+//
+// System marks a message the server injected on behalf of a subsystem
+// (autorun, CI feedback, integrations) rather than one a user typed.
 type MessageEvent struct {
 	Content string
+	System  bool
 }
 
 func (MessageEvent) EventType() EventType { return EventTypeMessage }
 func (MessageEvent) isAgentEvent()        {}
 
 func (e MessageEvent) ToRecord() EventRecord {
-	return EventRecord{Type: e.EventType(), Content: e.Content}
+	return EventRecord{Type: e.EventType(), Content: e.Content, System: e.System}
 }
 
-// PermissionResponseEvent is for history replay only, not sent as RPC notification.
+// PermissionResponseEvent is for history replay only. It is sent as an RPC
+// notification only when the server answered the request itself: on timeout,
+// or by the session mode's tool policy.
 type PermissionResponseEvent struct {
 	RequestID string
-	Choice    string // "deny", "allow", "always_allow"
+	Choice    string       // "deny", "allow", "always_allow"
+	TimedOut  bool         // nobody responded in time; Choice is the default action
+	ByMode    session.Mode // answered by this custom mode's allowed-tools policy
+	// AppliedFrom is the request whose answer was applied to this one as
+	// part of its group.
+	AppliedFrom string
 }
 
 func (PermissionResponseEvent) EventType() EventType { return EventTypePermissionResponse }
@@ -316,9 +367,12 @@ func (PermissionResponseEvent) isAgentEvent()        {}
 
 func (e PermissionResponseEvent) ToRecord() EventRecord {
 	return EventRecord{
-		Type:      e.EventType(),
-		RequestID: e.RequestID,
-		Choice:    e.Choice,
+		Type:        e.EventType(),
+		RequestID:   e.RequestID,
+		Choice:      e.Choice,
+		TimedOut:    e.TimedOut,
+		ByMode:      e.ByMode,
+		AppliedFrom: e.AppliedFrom,
 	}
 }
 
@@ -339,15 +393,20 @@ func (e QuestionResponseEvent) ToRecord() EventRecord {
 	}
 }
 
+// RawEvent passes CLI output through unprocessed. Unknown marks output of a
+// kind the parser does not know (e.g. added by a newer CLI release), with
+// Kind naming it, so clients can show it generically instead of losing it.
 type RawEvent struct {
 	Content string
+	Kind    string
+	Unknown bool
 }
 
 func (RawEvent) EventType() EventType { return EventTypeRaw }
 func (RawEvent) isAgentEvent()        {}
 
 func (e RawEvent) ToRecord() EventRecord {
-	return EventRecord{Type: e.EventType(), Content: e.Content}
+	return EventRecord{Type: e.EventType(), Content: e.Content, Kind: e.Kind, Unknown: e.Unknown}
 }
 
 type CommandOutputEvent struct {
@@ -0,0 +1,47 @@
+package agent
+
+import "context"
+
+// HealthStatus is the outcome of one health check.
+type HealthStatus string
+
+const (
+	HealthOK   HealthStatus = "ok"
+	HealthWarn HealthStatus = "warn" // could not be verified; sessions may still work
+	HealthFail HealthStatus = "fail" // sessions will not start or will fail
+)
+
+// HealthCheck is one diagnostic step, e.g. "cli" or "auth".
+type HealthCheck struct {
+	Name   string       `json:"name"`
+	Status HealthStatus `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// HealthReport is the structured result of an agent health check.
+type HealthReport struct {
+	Healthy bool          `json:"healthy"` // no check failed
+	Version string        `json:"version,omitempty"`
+	Checks  []HealthCheck `json:"checks"`
+}
+
+// Add appends a check and keeps Healthy in sync.
+func (r *HealthReport) Add(name string, status HealthStatus, detail string) {
+	r.Checks = append(r.Checks, HealthCheck{Name: name, Status: status, Detail: detail})
+	r.Healthy = !r.failed()
+}
+
+func (r *HealthReport) failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == HealthFail {
+			return true
+		}
+	}
+	return false
+}
+
+// HealthChecker is implemented by agents that can verify their setup (CLI
+// installed, logged in, API reachable) without starting a session.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) HealthReport
+}
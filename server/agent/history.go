@@ -1,6 +1,11 @@
 package agent
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pockode/server/session"
+)
 
 // EventRecord is the serialized form of an AgentEvent.
 // Used for persistence (history storage) and notifications (WebSocket).
@@ -11,17 +16,93 @@ type EventRecord struct {
 	ToolInput             json.RawMessage    `json:"tool_input,omitempty"`
 	ToolUseID             string             `json:"tool_use_id,omitempty"`
 	ToolResult            string             `json:"tool_result,omitempty"`
+	ToolResultSize        int                `json:"tool_result_size,omitempty"` // set only when tool_result is truncated
+	IsError               bool               `json:"is_error,omitempty"`
+	ExitCode              *int               `json:"exit_code,omitempty"`
 	Error                 string             `json:"error,omitempty"`
+	ErrorCode             string             `json:"error_code,omitempty"`
 	Message               string             `json:"message,omitempty"`
 	Code                  string             `json:"code,omitempty"`
 	RequestID             string             `json:"request_id,omitempty"`
 	PermissionSuggestions []PermissionUpdate `json:"permission_suggestions,omitempty"`
+	Group                 string             `json:"group,omitempty"`
 	Questions             []AskUserQuestion  `json:"questions,omitempty"`
 	Choice                string             `json:"choice,omitempty"`
+	TimedOut              bool               `json:"timed_out,omitempty"`
+	ByMode                session.Mode       `json:"by_mode,omitempty"`
+	AppliedFrom           string             `json:"applied_from,omitempty"`
 	Answers               map[string]string  `json:"answers,omitempty"`
+	Redacted              bool               `json:"redacted,omitempty"`
+	Kind                  string             `json:"kind,omitempty"`
+	Unknown               bool               `json:"unknown,omitempty"`
+	System                bool               `json:"system,omitempty"`
 }
 
 // NewEventRecord creates an EventRecord from an AgentEvent.
 func NewEventRecord(event AgentEvent) EventRecord {
 	return event.ToRecord()
 }
+
+// EventFromRecord rebuilds the AgentEvent a record was made from, for
+// replaying recorded streams. Fields records do not keep (DoneEvent.Tokens)
+// come back zero.
+func EventFromRecord(r EventRecord) (AgentEvent, error) {
+	switch r.Type {
+	case EventTypeText:
+		return TextEvent{Content: r.Content}, nil
+	case EventTypeThinking:
+		return ThinkingEvent{Content: r.Content, Redacted: r.Redacted}, nil
+	case EventTypeToolCall:
+		return ToolCallEvent{ToolName: r.ToolName, ToolInput: r.ToolInput, ToolUseID: r.ToolUseID}, nil
+	case EventTypeToolResult:
+		return ToolResultEvent{
+			ToolUseID:  r.ToolUseID,
+			ToolResult: r.ToolResult,
+			FullSize:   r.ToolResultSize,
+			IsError:    r.IsError,
+			ExitCode:   r.ExitCode,
+		}, nil
+	case EventTypeWarning:
+		return WarningEvent{Message: r.Message, Code: r.Code}, nil
+	case EventTypeError:
+		return ErrorEvent{Error: r.Error, Code: r.ErrorCode}, nil
+	case EventTypeDone:
+		return DoneEvent{}, nil
+	case EventTypeInterrupted:
+		return InterruptedEvent{}, nil
+	case EventTypePermissionRequest:
+		return PermissionRequestEvent{
+			RequestID:             r.RequestID,
+			ToolName:              r.ToolName,
+			ToolInput:             r.ToolInput,
+			ToolUseID:             r.ToolUseID,
+			PermissionSuggestions: r.PermissionSuggestions,
+			Group:                 r.Group,
+		}, nil
+	case EventTypeRequestCancelled:
+		return RequestCancelledEvent{RequestID: r.RequestID}, nil
+	case EventTypeAskUserQuestion:
+		return AskUserQuestionEvent{RequestID: r.RequestID, ToolUseID: r.ToolUseID, Questions: r.Questions}, nil
+	case EventTypeSystem:
+		return SystemEvent{Content: r.Content}, nil
+	case EventTypeProcessEnded:
+		return ProcessEndedEvent{}, nil
+	case EventTypeMessage:
+		return MessageEvent{Content: r.Content, System: r.System}, nil
+	case EventTypePermissionResponse:
+		return PermissionResponseEvent{
+			RequestID:   r.RequestID,
+			Choice:      r.Choice,
+			TimedOut:    r.TimedOut,
+			ByMode:      r.ByMode,
+			AppliedFrom: r.AppliedFrom,
+		}, nil
+	case EventTypeQuestionResponse:
+		return QuestionResponseEvent{RequestID: r.RequestID, Answers: r.Answers}, nil
+	case EventTypeRaw:
+		return RawEvent{Content: r.Content, Kind: r.Kind, Unknown: r.Unknown}, nil
+	case EventTypeCommandOutput:
+		return CommandOutputEvent{Content: r.Content}, nil
+	}
+	return nil, fmt.Errorf("unknown event type %q", r.Type)
+}
@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// BuiltinMCPServerName is the name of pockode's own MCP server, which every
+// agent process gets; custom servers cannot use it.
+const BuiltinMCPServerName = "pockode"
+
+// MaxMCPServers bounds the custom servers per role or worktree.
+const MaxMCPServers = 16
+
+var ErrInvalidMCPServer = errors.New("invalid mcp server")
+
+var mcpServerName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// MCPServer is an extra stdio MCP server started alongside an agent process,
+// configured per agent role or per worktree.
+type MCPServer struct {
+	Name    string            `json:"name"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+func (s MCPServer) Equal(other MCPServer) bool {
+	return s.Name == other.Name &&
+		s.Command == other.Command &&
+		slices.Equal(s.Args, other.Args) &&
+		maps.Equal(s.Env, other.Env)
+}
+
+// ValidateMCPServers checks names (unique, not the built-in) and commands.
+// Errors wrap ErrInvalidMCPServer.
+func ValidateMCPServers(servers []MCPServer) error {
+	if len(servers) > MaxMCPServers {
+		return fmt.Errorf("%w: at most %d servers", ErrInvalidMCPServer, MaxMCPServers)
+	}
+	seen := make(map[string]bool, len(servers))
+	for _, s := range servers {
+		if !mcpServerName.MatchString(s.Name) {
+			return fmt.Errorf("%w: name %q must be 1-64 letters, digits, '-' or '_'", ErrInvalidMCPServer, s.Name)
+		}
+		if s.Name == BuiltinMCPServerName {
+			return fmt.Errorf("%w: name %q is reserved", ErrInvalidMCPServer, s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("%w: duplicate name %q", ErrInvalidMCPServer, s.Name)
+		}
+		seen[s.Name] = true
+		if s.Command == "" {
+			return fmt.Errorf("%w: %s: command is required", ErrInvalidMCPServer, s.Name)
+		}
+	}
+	return nil
+}
+
+// MergeMCPServers returns base with overrides applied by name: an override
+// replaces the base server of the same name, others are appended in order.
+func MergeMCPServers(base, overrides []MCPServer) []MCPServer {
+	if len(overrides) == 0 {
+		return base
+	}
+	merged := slices.Clone(base)
+	for _, o := range overrides {
+		if i := slices.IndexFunc(merged, func(s MCPServer) bool { return s.Name == o.Name }); i >= 0 {
+			merged[i] = o
+		} else {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+// MCPServersConfig builds the "mcpServers" map shared by the Claude and
+// Codex configs: the built-in pockode server (exe run as `mcp --data-dir`,
+// with `--session-id` when set so its calls are attributed to the session)
+// plus extra. The built-in entry is written last so it always wins, even
+// if an unvalidated extra uses its name.
+func MCPServersConfig(exe, dataDir, sessionID string, extra []MCPServer) map[string]interface{} {
+	servers := make(map[string]interface{}, len(extra)+1)
+	for _, s := range extra {
+		entry := map[string]interface{}{"command": s.Command}
+		if len(s.Args) > 0 {
+			entry["args"] = s.Args
+		}
+		if len(s.Env) > 0 {
+			entry["env"] = s.Env
+		}
+		servers[s.Name] = entry
+	}
+	args := []string{"mcp", "--data-dir", dataDir}
+	if sessionID != "" {
+		args = append(args, "--session-id", sessionID)
+	}
+	servers[BuiltinMCPServerName] = map[string]interface{}{
+		"command": exe,
+		"args":    args,
+	}
+	return servers
+}
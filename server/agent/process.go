@@ -7,6 +7,7 @@ import (
 	"io"
 	"log/slog"
 	"os/exec"
+	"regexp"
 	"strings"
 	"time"
 
@@ -15,8 +16,14 @@ import (
 
 const StderrReadTimeout = 5 * time.Second
 
-// ReadStderr collects all stderr output from a subprocess into a channel.
-// The returned channel receives the full stderr content when the reader is exhausted.
+// stderrTailBytes bounds the stderr kept for the error event: a CLI that
+// logs heavily must not grow the buffer for the life of the process, and
+// the failure is at the end.
+const stderrTailBytes = 16 << 10
+
+// ReadStderr collects stderr output from a subprocess into a channel. Lines
+// are logged at debug level as they arrive; the returned channel receives
+// the last stderrTailBytes of output when the reader is exhausted.
 func ReadStderr(stderr io.Reader, agentName string) <-chan string {
 	ch := make(chan string, 1)
 	go func() {
@@ -29,7 +36,18 @@ func ReadStderr(stderr io.Reader, agentName string) <-chan string {
 		}()
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
-			content.WriteString(scanner.Text())
+			line := scanner.Text()
+			slog.Debug("agent stderr", "agent", agentName, "line", line)
+			if drop := content.Len() + len(line) + 1 - stderrTailBytes; drop > 0 {
+				// Drop whole lines from the front.
+				tail := content.String()[min(drop, content.Len()):]
+				if i := strings.IndexByte(tail, '\n'); i >= 0 && drop < content.Len() {
+					tail = tail[i+1:]
+				}
+				content.Reset()
+				content.WriteString(tail)
+			}
+			content.WriteString(line)
 			content.WriteString("\n")
 		}
 		if err := scanner.Err(); err != nil {
@@ -39,6 +57,34 @@ func ReadStderr(stderr io.Reader, agentName string) <-chan string {
 	return ch
 }
 
+// Error codes classify agent failures so callers can react without parsing
+// CLI output (see ClassifyError).
+const (
+	ErrorCodeAuth      = "auth"       // credentials missing, invalid or expired
+	ErrorCodeRateLimit = "rate_limit" // rate or usage limit, or the API is overloaded
+	ErrorCodeNetwork   = "network"    // the API could not be reached
+)
+
+var errorPatterns = []struct {
+	code string
+	re   *regexp.Regexp
+}{
+	{ErrorCodeAuth, regexp.MustCompile(`(?i)\b401\b|authentication_error|unauthorized|invalid api key|oauth token (has )?expired|please run /login|not logged in`)},
+	{ErrorCodeRateLimit, regexp.MustCompile(`(?i)\b(429|529)\b|rate[ _]limit|usage limit|too many requests|overloaded`)},
+	{ErrorCodeNetwork, regexp.MustCompile(`(?i)econnrefused|econnreset|enotfound|etimedout|eai_again|getaddrinfo|socket hang up|fetch failed|network error|connection (refused|reset)`)},
+}
+
+// ClassifyError returns the error code matching an agent CLI's error
+// output, or "" when the failure is not one it recognizes.
+func ClassifyError(output string) string {
+	for _, p := range errorPatterns {
+		if p.re.MatchString(output) {
+			return p.code
+		}
+	}
+	return ""
+}
+
 // WaitForProcess waits for a subprocess to exit and emits an ErrorEvent if it
 // terminated unexpectedly (i.e. not due to context cancellation).
 func WaitForProcess(ctx context.Context, log *slog.Logger, cmd *exec.Cmd, stderrCh <-chan string, events chan<- AgentEvent) {
@@ -55,7 +101,7 @@ func WaitForProcess(ctx context.Context, log *slog.Logger, cmd *exec.Cmd, stderr
 				errMsg = err.Error()
 			}
 			select {
-			case events <- ErrorEvent{Error: errMsg}:
+			case events <- ErrorEvent{Error: errMsg, Code: ClassifyError(stderrContent)}:
 			case <-ctx.Done():
 			}
 		}
@@ -0,0 +1,259 @@
+package agentrole
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pockode/server/session"
+)
+
+// RolePackVersion is the rolepack format version written by BuildRolePack.
+// Import accepts packs up to this version.
+const RolePackVersion = 1
+
+// MaxPackRoles bounds one rolepack; curated sets are a handful of roles.
+const MaxPackRoles = 100
+
+// RolePack is the shareable file format for a set of roles. Role IDs are
+// project-local, so roles are carried without them and matched by name on
+// import.
+type RolePack struct {
+	Version    int        `json:"version"`
+	Name       string     `json:"name,omitempty"`
+	ExportedAt time.Time  `json:"exported_at"`
+	Roles      []PackRole `json:"roles"`
+}
+
+type PackRole struct {
+	Name               string            `json:"name"`
+	RolePrompt         string            `json:"role_prompt"`
+	Steps              []string          `json:"steps,omitempty"`
+	IdleTimeoutMinutes int               `json:"idle_timeout_minutes,omitempty"`
+	AgentType          session.AgentType `json:"agent_type,omitempty"`
+}
+
+// ConflictPolicy decides what importing a role does when a role with the
+// same name already exists.
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"      // keep the existing role
+	ConflictOverwrite ConflictPolicy = "overwrite" // replace its prompt, steps, and timeout
+	ConflictDuplicate ConflictPolicy = "duplicate" // create a copy named "<name> (2)"
+)
+
+func (p ConflictPolicy) IsValid() bool {
+	switch p {
+	case ConflictSkip, ConflictOverwrite, ConflictDuplicate:
+		return true
+	default:
+		return false
+	}
+}
+
+// ImportAction is what importing one pack role does (or would do, in a dry run).
+type ImportAction string
+
+const (
+	ImportCreate    ImportAction = "create"
+	ImportOverwrite ImportAction = "overwrite"
+	ImportDuplicate ImportAction = "duplicate"
+	ImportSkip      ImportAction = "skip"
+	ImportUnchanged ImportAction = "unchanged" // overwrite of an identical role
+)
+
+// ImportItem reports the outcome for one pack role. RoleID is the existing
+// role for skip/overwrite/unchanged and the new role otherwise; it is empty
+// for creations in a dry run. Name is the final name, which differs from
+// the pack name only for duplicates.
+type ImportItem struct {
+	PackName string       `json:"pack_name"`
+	Name     string       `json:"name"`
+	Action   ImportAction `json:"action"`
+	RoleID   string       `json:"role_id,omitempty"`
+}
+
+// BuildRolePack exports the roles with the given IDs, in that order, or all
+// roles when ids is empty. Returns ErrNotFound for an unknown ID.
+func BuildRolePack(roles []AgentRole, ids []string, name string) (RolePack, error) {
+	selected := roles
+	if len(ids) > 0 {
+		selected = make([]AgentRole, 0, len(ids))
+		for _, id := range ids {
+			idx := slices.IndexFunc(roles, func(r AgentRole) bool { return r.ID == id })
+			if idx < 0 {
+				return RolePack{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+			}
+			selected = append(selected, roles[idx])
+		}
+	}
+
+	pack := RolePack{
+		Version:    RolePackVersion,
+		Name:       name,
+		ExportedAt: time.Now().UTC(),
+		Roles:      make([]PackRole, len(selected)),
+	}
+	for i, r := range selected {
+		pack.Roles[i] = PackRole{
+			Name:               r.Name,
+			RolePrompt:         r.RolePrompt,
+			Steps:              r.Steps,
+			IdleTimeoutMinutes: r.IdleTimeoutMinutes,
+			AgentType:          r.AgentType,
+		}
+	}
+	return pack, nil
+}
+
+// Validate checks a pack before import. Names must be unique within the
+// pack, since conflicts are resolved by name.
+func (p RolePack) Validate() error {
+	if p.Version < 1 || p.Version > RolePackVersion {
+		return fmt.Errorf("%w: unsupported rolepack version %d", ErrInvalidRole, p.Version)
+	}
+	if len(p.Roles) == 0 {
+		return fmt.Errorf("%w: rolepack has no roles", ErrInvalidRole)
+	}
+	if len(p.Roles) > MaxPackRoles {
+		return fmt.Errorf("%w: rolepack has more than %d roles", ErrInvalidRole, MaxPackRoles)
+	}
+	seen := make(map[string]bool, len(p.Roles))
+	for _, r := range p.Roles {
+		name := strings.TrimSpace(r.Name)
+		if name == "" {
+			return fmt.Errorf("%w: rolepack role name is required", ErrInvalidRole)
+		}
+		if seen[name] {
+			return fmt.Errorf("%w: duplicate role %q in rolepack", ErrInvalidRole, name)
+		}
+		seen[name] = true
+		if err := validateIdleTimeout(r.IdleTimeoutMinutes); err != nil {
+			return err
+		}
+		if err := validateAgentType(r.AgentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matches reports whether importing r over existing would change nothing.
+func (r PackRole) matches(existing AgentRole) bool {
+	return r.RolePrompt == existing.RolePrompt &&
+		slices.Equal(r.Steps, existing.Steps) &&
+		r.IdleTimeoutMinutes == existing.IdleTimeoutMinutes &&
+		r.AgentType == existing.AgentType
+}
+
+// duplicateName returns the first "<name> (n)" not in taken.
+func duplicateName(name string, taken map[string]bool) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+func (s *FileStore) Import(_ context.Context, pack RolePack, policy ConflictPolicy, dryRun bool) ([]ImportItem, error) {
+	if !policy.IsValid() {
+		return nil, fmt.Errorf("%w: unknown conflict policy %q", ErrInvalidRole, policy)
+	}
+	if err := pack.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.rolesMu.Lock()
+
+	taken := make(map[string]bool, len(s.roles))
+	byName := make(map[string]int, len(s.roles))
+	for i, r := range s.roles {
+		taken[r.Name] = true
+		if _, ok := byName[r.Name]; !ok {
+			byName[r.Name] = i
+		}
+	}
+
+	prev := s.roles
+	next := slices.Clone(s.roles)
+	now := time.Now()
+	var events []ChangeEvent
+	items := make([]ImportItem, len(pack.Roles))
+	for i, pr := range pack.Roles {
+		name := strings.TrimSpace(pr.Name)
+		item := ImportItem{PackName: pr.Name, Name: name}
+		idx, exists := byName[name]
+		switch {
+		case !exists && !taken[name]:
+			item.Action = ImportCreate
+		case !exists:
+			// Collides with a duplicate created earlier in this import.
+			item.Action, item.Name = ImportDuplicate, duplicateName(name, taken)
+		case policy == ConflictSkip:
+			item.Action, item.RoleID = ImportSkip, next[idx].ID
+		case policy == ConflictOverwrite && pr.matches(next[idx]):
+			item.Action, item.RoleID = ImportUnchanged, next[idx].ID
+		case policy == ConflictOverwrite:
+			item.Action, item.RoleID = ImportOverwrite, next[idx].ID
+		default:
+			item.Action, item.Name = ImportDuplicate, duplicateName(name, taken)
+		}
+
+		switch item.Action {
+		case ImportCreate, ImportDuplicate:
+			taken[item.Name] = true
+			if dryRun {
+				break
+			}
+			role := AgentRole{
+				ID:                 uuid.Must(uuid.NewV7()).String(),
+				Name:               item.Name,
+				RolePrompt:         pr.RolePrompt,
+				Steps:              pr.Steps,
+				IdleTimeoutMinutes: pr.IdleTimeoutMinutes,
+				AgentType:          pr.AgentType,
+				CreatedAt:          now,
+				UpdatedAt:          now,
+			}
+			item.RoleID = role.ID
+			next = append(next, role)
+			events = append(events, ChangeEvent{Op: OperationCreate, Role: role})
+		case ImportOverwrite:
+			if dryRun {
+				break
+			}
+			r := &next[idx]
+			r.RolePrompt = pr.RolePrompt
+			r.Steps = pr.Steps
+			r.IdleTimeoutMinutes = pr.IdleTimeoutMinutes
+			r.AgentType = pr.AgentType
+			r.UpdatedAt = now
+			events = append(events, ChangeEvent{Op: OperationUpdate, Role: *r})
+		}
+		items[i] = item
+	}
+
+	if dryRun || len(events) == 0 {
+		s.rolesMu.Unlock()
+		return items, nil
+	}
+
+	s.roles = next
+	if err := s.persistIndex(); err != nil {
+		s.roles = prev
+		s.rolesMu.Unlock()
+		return nil, err
+	}
+	listeners := s.copyListeners()
+	s.rolesMu.Unlock()
+
+	for _, e := range events {
+		notify(listeners, e)
+	}
+	return items, nil
+}
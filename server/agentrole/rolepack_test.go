@@ -0,0 +1,164 @@
+package agentrole
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBuildRolePack(t *testing.T) {
+	roles := []AgentRole{
+		{ID: "a", Name: "Alpha", RolePrompt: "A", Steps: []string{"plan", "do"}},
+		{ID: "b", Name: "Beta", RolePrompt: "B", IdleTimeoutMinutes: 30},
+	}
+
+	pack, err := BuildRolePack(roles, []string{"b"}, "team")
+	if err != nil {
+		t.Fatalf("BuildRolePack: %v", err)
+	}
+	if pack.Version != RolePackVersion || pack.Name != "team" || len(pack.Roles) != 1 {
+		t.Fatalf("pack = %+v, want version %d with Beta only", pack, RolePackVersion)
+	}
+	if r := pack.Roles[0]; r.Name != "Beta" || r.RolePrompt != "B" || r.IdleTimeoutMinutes != 30 {
+		t.Errorf("role = %+v, want Beta", r)
+	}
+
+	all, err := BuildRolePack(roles, nil, "")
+	if err != nil || len(all.Roles) != 2 {
+		t.Errorf("all = %+v, %v; want both roles", all.Roles, err)
+	}
+
+	if _, err := BuildRolePack(roles, []string{"missing"}, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown ID err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRolePack_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		pack RolePack
+	}{
+		{"unsupported version", RolePack{Version: RolePackVersion + 1, Roles: []PackRole{{Name: "A"}}}},
+		{"missing version", RolePack{Roles: []PackRole{{Name: "A"}}}},
+		{"no roles", RolePack{Version: 1}},
+		{"empty name", RolePack{Version: 1, Roles: []PackRole{{Name: "  "}}}},
+		{"duplicate name", RolePack{Version: 1, Roles: []PackRole{{Name: "A"}, {Name: "A"}}}},
+		{"bad idle timeout", RolePack{Version: 1, Roles: []PackRole{{Name: "A", IdleTimeoutMinutes: -1}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.pack.Validate(); !errors.Is(err, ErrInvalidRole) {
+				t.Errorf("err = %v, want ErrInvalidRole", err)
+			}
+		})
+	}
+}
+
+func TestImport_ConflictPolicies(t *testing.T) {
+	pack := RolePack{Version: 1, Roles: []PackRole{
+		{Name: "Reviewer", RolePrompt: "Review strictly."},
+		{Name: "Writer", RolePrompt: "Write docs."},
+		{Name: "Same", RolePrompt: "Unchanged."},
+	}}
+
+	tests := []struct {
+		policy       ConflictPolicy
+		wantActions  []ImportAction
+		wantNames    []string
+		wantReviewer string
+	}{
+		{ConflictSkip, []ImportAction{ImportSkip, ImportCreate, ImportSkip}, []string{"Reviewer", "Writer", "Same"}, "Old review."},
+		{ConflictOverwrite, []ImportAction{ImportOverwrite, ImportCreate, ImportUnchanged}, []string{"Reviewer", "Writer", "Same"}, "Review strictly."},
+		{ConflictDuplicate, []ImportAction{ImportDuplicate, ImportCreate, ImportDuplicate}, []string{"Reviewer (3)", "Writer", "Same (2)"}, "Old review."},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s := newTestStore(t)
+			reviewer := createRole(t, s, "Reviewer", "Old review.")
+			createRole(t, s, "Reviewer (2)", "Taken suffix.")
+			createRole(t, s, "Same", "Unchanged.")
+			before, _ := s.List()
+
+			items, err := s.Import(context.Background(), pack, tt.policy, false)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			for i, item := range items {
+				if item.Action != tt.wantActions[i] || item.Name != tt.wantNames[i] {
+					t.Errorf("items[%d] = %+v, want %s as %q", i, item, tt.wantActions[i], tt.wantNames[i])
+				}
+				if item.RoleID == "" {
+					t.Errorf("items[%d] has no role ID", i)
+				}
+			}
+			if got := getRole(t, s, reviewer.ID).RolePrompt; got != tt.wantReviewer {
+				t.Errorf("existing Reviewer prompt = %q, want %q", got, tt.wantReviewer)
+			}
+
+			created := 0
+			for _, a := range tt.wantActions {
+				if a == ImportCreate || a == ImportDuplicate {
+					created++
+				}
+			}
+			after, _ := s.List()
+			if len(after) != len(before)+created {
+				t.Errorf("role count = %d, want %d", len(after), len(before)+created)
+			}
+		})
+	}
+}
+
+func TestImport_DryRunWritesNothing(t *testing.T) {
+	s := newTestStore(t)
+	createRole(t, s, "Reviewer", "Old review.")
+	before, _ := s.List()
+
+	var events []ChangeEvent
+	s.AddOnChangeListener(listenerFunc(func(e ChangeEvent) { events = append(events, e) }))
+
+	items, err := s.Import(context.Background(), RolePack{Version: 1, Roles: []PackRole{
+		{Name: "Reviewer", RolePrompt: "New review."},
+		{Name: "Writer", RolePrompt: "Write docs."},
+	}}, ConflictOverwrite, true)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if items[0].Action != ImportOverwrite || items[1].Action != ImportCreate || items[1].RoleID != "" {
+		t.Errorf("items = %+v, want overwrite then create without ID", items)
+	}
+
+	after, _ := s.List()
+	if len(after) != len(before) {
+		t.Errorf("dry run changed the role count: %d → %d", len(before), len(after))
+	}
+	if len(events) != 0 {
+		t.Errorf("dry run fired %d events", len(events))
+	}
+}
+
+func TestImport_DuplicateCollidesWithPackRole(t *testing.T) {
+	s := newTestStore(t)
+	createRole(t, s, "Reviewer", "Old review.")
+
+	// "Reviewer (2)" is created for the conflict first, so the pack's own
+	// "Reviewer (2)" must not land on the same name.
+	items, err := s.Import(context.Background(), RolePack{Version: 1, Roles: []PackRole{
+		{Name: "Reviewer", RolePrompt: "A"},
+		{Name: "Reviewer (2)", RolePrompt: "B"},
+	}}, ConflictDuplicate, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if items[0].Name != "Reviewer (2)" || items[1].Name != "Reviewer (2) (2)" {
+		t.Errorf("names = %q, %q; want distinct names", items[0].Name, items[1].Name)
+	}
+}
+
+func TestImport_InvalidPolicy(t *testing.T) {
+	s := newTestStore(t)
+	_, err := s.Import(context.Background(), RolePack{Version: 1, Roles: []PackRole{{Name: "A"}}}, "merge", false)
+	if !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("err = %v, want ErrInvalidRole", err)
+	}
+}
@@ -2,7 +2,6 @@ package agentrole
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"path/filepath"
@@ -10,7 +9,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pockode/server/agent"
 	"github.com/pockode/server/filestore"
+	"github.com/pockode/server/session"
 )
 
 // Store provides CRUD operations and change notifications for AgentRole items.
@@ -23,6 +24,10 @@ type Store interface {
 	Delete(ctx context.Context, id string) error
 	// ResetDefaults replaces all roles with built-in defaults and returns the PM role ID.
 	ResetDefaults(ctx context.Context) (string, error)
+	// Import adds the roles of a rolepack, resolving name conflicts with
+	// policy, in one persist. With dryRun nothing is written and the result
+	// lists what would change.
+	Import(ctx context.Context, pack RolePack, policy ConflictPolicy, dryRun bool) ([]ImportItem, error)
 
 	AddOnChangeListener(listener OnChangeListener)
 }
@@ -32,6 +37,14 @@ type UpdateFields struct {
 	Name       *string   `json:"name,omitempty"`
 	RolePrompt *string   `json:"role_prompt,omitempty"`
 	Steps      *[]string `json:"steps,omitempty"`
+	// IdleTimeoutMinutes: 0 resets to the server default.
+	IdleTimeoutMinutes *int `json:"idle_timeout_minutes,omitempty"`
+	// AgentType: "" resets to the default_agent_type setting.
+	AgentType *session.AgentType `json:"agent_type,omitempty"`
+	// MCPServers replaces the role's extra MCP servers; an empty slice clears them.
+	MCPServers *[]agent.MCPServer `json:"mcp_servers,omitempty"`
+	// MaxConcurrent: 0 removes the cap.
+	MaxConcurrent *int `json:"max_concurrent,omitempty"`
 }
 
 type indexData struct {
@@ -182,17 +195,33 @@ func (s *FileStore) Create(_ context.Context, r AgentRole) (AgentRole, error) {
 	if r.Name == "" {
 		return AgentRole{}, fmt.Errorf("%w: name is required", ErrInvalidRole)
 	}
+	if err := validateIdleTimeout(r.IdleTimeoutMinutes); err != nil {
+		return AgentRole{}, err
+	}
+	if err := validateAgentType(r.AgentType); err != nil {
+		return AgentRole{}, err
+	}
+	if err := validateMCPServers(r.MCPServers); err != nil {
+		return AgentRole{}, err
+	}
+	if err := validateMaxConcurrent(r.MaxConcurrent); err != nil {
+		return AgentRole{}, err
+	}
 
 	s.rolesMu.Lock()
 
 	now := time.Now()
 	role := AgentRole{
-		ID:         uuid.Must(uuid.NewV7()).String(),
-		Name:       r.Name,
-		RolePrompt: r.RolePrompt,
-		Steps:      r.Steps,
-		CreatedAt:  now,
-		UpdatedAt:  now,
+		ID:                 uuid.Must(uuid.NewV7()).String(),
+		Name:               r.Name,
+		RolePrompt:         r.RolePrompt,
+		Steps:              r.Steps,
+		IdleTimeoutMinutes: r.IdleTimeoutMinutes,
+		AgentType:          r.AgentType,
+		MCPServers:         r.MCPServers,
+		MaxConcurrent:      r.MaxConcurrent,
+		CreatedAt:          now,
+		UpdatedAt:          now,
 	}
 
 	s.roles = append(s.roles, role)
@@ -236,6 +265,38 @@ func (s *FileStore) Update(_ context.Context, id string, fields UpdateFields) er
 	if fields.Steps != nil {
 		r.Steps = *fields.Steps
 	}
+	if fields.IdleTimeoutMinutes != nil {
+		if err := validateIdleTimeout(*fields.IdleTimeoutMinutes); err != nil {
+			*r = prev
+			s.rolesMu.Unlock()
+			return err
+		}
+		r.IdleTimeoutMinutes = *fields.IdleTimeoutMinutes
+	}
+	if fields.AgentType != nil {
+		if err := validateAgentType(*fields.AgentType); err != nil {
+			*r = prev
+			s.rolesMu.Unlock()
+			return err
+		}
+		r.AgentType = *fields.AgentType
+	}
+	if fields.MCPServers != nil {
+		if err := validateMCPServers(*fields.MCPServers); err != nil {
+			*r = prev
+			s.rolesMu.Unlock()
+			return err
+		}
+		r.MCPServers = *fields.MCPServers
+	}
+	if fields.MaxConcurrent != nil {
+		if err := validateMaxConcurrent(*fields.MaxConcurrent); err != nil {
+			*r = prev
+			s.rolesMu.Unlock()
+			return err
+		}
+		r.MaxConcurrent = *fields.MaxConcurrent
+	}
 	r.UpdatedAt = now
 
 	if err := s.persistIndex(); err != nil {
@@ -331,18 +392,10 @@ func notify(listeners []OnChangeListener, event ChangeEvent) {
 // --- File I/O ---
 
 func (s *FileStore) readIndexFromDisk() (indexData, error) {
-	data, err := s.file.Read()
+	idx, err := filestore.Load(s.file, indexData{})
 	if err != nil {
 		return indexData{}, err
 	}
-	if data == nil {
-		return indexData{Roles: []AgentRole{}}, nil
-	}
-
-	var idx indexData
-	if err := json.Unmarshal(data, &idx); err != nil {
-		return indexData{}, err
-	}
 	if idx.Roles == nil {
 		idx.Roles = []AgentRole{}
 	}
@@ -350,11 +403,7 @@ func (s *FileStore) readIndexFromDisk() (indexData, error) {
 }
 
 func (s *FileStore) persistIndex() error {
-	data, err := filestore.MarshalIndex(indexData{Roles: s.roles})
-	if err != nil {
-		return err
-	}
-	return s.file.Write(data)
+	return s.file.Persist(indexData{Roles: s.roles})
 }
 
 // --- fsnotify ---
@@ -366,59 +415,31 @@ func (s *FileStore) StartWatching() error { return s.file.StartWatching() }
 func (s *FileStore) StopWatching()        { s.file.StopWatching() }
 
 func (s *FileStore) reloadFromDisk() {
-	genBefore := s.file.SnapshotGen()
+	var events []ChangeEvent
+	var listeners []OnChangeListener
 
-	idx, err := s.readIndexFromDisk()
+	err := filestore.Reload(s.file, &s.rolesMu, indexData{}, func(idx indexData) {
+		if idx.Roles == nil {
+			idx.Roles = []AgentRole{}
+		}
+		events = diffRoles(s.roles, idx.Roles)
+		s.roles = idx.Roles
+		listeners = s.copyListeners()
+	})
 	if err != nil {
 		slog.Error("failed to reload agent role index", "error", err)
 		return
 	}
 
-	s.rolesMu.Lock()
-
-	if s.file.IsStale(genBefore) {
-		s.rolesMu.Unlock()
-		return
-	}
-
-	old := s.roles
-	s.roles = idx.Roles
-	listeners := s.copyListeners()
-	s.rolesMu.Unlock()
-
-	events := diffRoles(old, idx.Roles)
 	for _, e := range events {
 		notify(listeners, e)
 	}
 }
 
 func diffRoles(old, updated []AgentRole) []ChangeEvent {
-	return filestore.Diff(old, updated,
-		func(r AgentRole) string { return r.ID },
-		roleChanged,
-		func(op filestore.Operation, r AgentRole) ChangeEvent {
-			return ChangeEvent{Op: Operation(op), Role: r}
-		},
-	)
-}
-
-func roleChanged(a, b AgentRole) bool {
-	return a.Name != b.Name ||
-		a.RolePrompt != b.RolePrompt ||
-		!stepsEqual(a.Steps, b.Steps) ||
-		!a.UpdatedAt.Equal(b.UpdatedAt)
-}
-
-func stepsEqual(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
-	}
-	return true
+	return filestore.Diff(old, updated, func(op filestore.Operation, r AgentRole) ChangeEvent {
+		return ChangeEvent{Op: Operation(op), Role: r}
+	})
 }
 
 // --- Helpers ---
@@ -3,12 +3,16 @@ package agentrole
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/session"
 )
 
 func newTestStore(t *testing.T) *FileStore {
@@ -170,6 +174,137 @@ func TestUpdate_EmptyRolePrompt(t *testing.T) {
 	}
 }
 
+func TestCreate_IdleTimeout(t *testing.T) {
+	s := newTestStore(t)
+
+	role, err := s.Create(context.Background(), AgentRole{Name: "Builder", IdleTimeoutMinutes: 120})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getRole(t, s, role.ID).IdleTimeout(); got != 2*time.Hour {
+		t.Errorf("idle timeout = %v, want 2h", got)
+	}
+
+	for _, minutes := range []int{-1, MaxIdleTimeoutMinutes + 1} {
+		_, err := s.Create(context.Background(), AgentRole{Name: "Bad", IdleTimeoutMinutes: minutes})
+		if !errors.Is(err, ErrInvalidRole) {
+			t.Errorf("minutes=%d: expected ErrInvalidRole, got %v", minutes, err)
+		}
+	}
+}
+
+func TestUpdate_IdleTimeout(t *testing.T) {
+	s := newTestStore(t)
+	role := createRole(t, s, "Test", "prompt")
+
+	minutes := 30
+	if err := s.Update(context.Background(), role.ID, UpdateFields{IdleTimeoutMinutes: &minutes}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getRole(t, s, role.ID).IdleTimeoutMinutes; got != 30 {
+		t.Errorf("idle_timeout_minutes = %d, want 30", got)
+	}
+
+	negative := -5
+	name := "Renamed"
+	err := s.Update(context.Background(), role.ID, UpdateFields{Name: &name, IdleTimeoutMinutes: &negative})
+	if !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+	// A rejected update leaves the role untouched.
+	got := getRole(t, s, role.ID)
+	if got.Name != "Test" || got.IdleTimeoutMinutes != 30 {
+		t.Errorf("role changed by rejected update: name=%q minutes=%d", got.Name, got.IdleTimeoutMinutes)
+	}
+}
+
+func TestAgentType(t *testing.T) {
+	s := newTestStore(t)
+
+	role, err := s.Create(context.Background(), AgentRole{Name: "Codex Dev", AgentType: session.AgentTypeCodex})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getRole(t, s, role.ID).AgentType; got != session.AgentTypeCodex {
+		t.Errorf("agent_type = %q, want codex", got)
+	}
+	if _, err := s.Create(context.Background(), AgentRole{Name: "Bad", AgentType: "gpt"}); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("expected ErrInvalidRole, got %v", err)
+	}
+
+	bad := session.AgentType("gpt")
+	if err := s.Update(context.Background(), role.ID, UpdateFields{AgentType: &bad}); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("expected ErrInvalidRole, got %v", err)
+	}
+	reset := session.AgentType("")
+	if err := s.Update(context.Background(), role.ID, UpdateFields{AgentType: &reset}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getRole(t, s, role.ID).AgentType; got != "" {
+		t.Errorf("agent_type = %q, want reset to default", got)
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	s := newTestStore(t)
+
+	role, err := s.Create(context.Background(), AgentRole{Name: "Architect", MaxConcurrent: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getRole(t, s, role.ID).MaxConcurrent; got != 1 {
+		t.Errorf("max_concurrent = %d, want 1", got)
+	}
+	if _, err := s.Create(context.Background(), AgentRole{Name: "Bad", MaxConcurrent: -1}); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("expected ErrInvalidRole, got %v", err)
+	}
+
+	negative := -1
+	if err := s.Update(context.Background(), role.ID, UpdateFields{MaxConcurrent: &negative}); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("expected ErrInvalidRole, got %v", err)
+	}
+	uncapped := 0
+	if err := s.Update(context.Background(), role.ID, UpdateFields{MaxConcurrent: &uncapped}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getRole(t, s, role.ID).MaxConcurrent; got != 0 {
+		t.Errorf("max_concurrent = %d, want 0", got)
+	}
+}
+
+func TestMCPServers(t *testing.T) {
+	s := newTestStore(t)
+
+	servers := []agent.MCPServer{{Name: "db", Command: "db-mcp", Args: []string{"--ro"}, Env: map[string]string{"DB_URL": "x"}}}
+	role, err := s.Create(context.Background(), AgentRole{Name: "Analyst", MCPServers: servers})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := getRole(t, s, role.ID).MCPServers
+	if len(got) != 1 || !got[0].Equal(servers[0]) {
+		t.Errorf("mcp_servers = %+v, want %+v", got, servers)
+	}
+
+	for _, bad := range [][]agent.MCPServer{
+		{{Name: "pockode", Command: "x"}},
+		{{Name: "db", Command: ""}},
+		{{Name: "has space", Command: "x"}},
+		{{Name: "db", Command: "x"}, {Name: "db", Command: "y"}},
+	} {
+		if _, err := s.Create(context.Background(), AgentRole{Name: "Bad", MCPServers: bad}); !errors.Is(err, ErrInvalidRole) {
+			t.Errorf("servers=%+v: expected ErrInvalidRole, got %v", bad, err)
+		}
+	}
+
+	cleared := []agent.MCPServer{}
+	if err := s.Update(context.Background(), role.ID, UpdateFields{MCPServers: &cleared}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getRole(t, s, role.ID).MCPServers; len(got) != 0 {
+		t.Errorf("mcp_servers = %+v, want cleared", got)
+	}
+}
+
 func TestUpdate_NotFound(t *testing.T) {
 	s := newTestStore(t)
 	name := "x"
@@ -2,7 +2,12 @@ package agentrole
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/session"
 )
 
 var (
@@ -11,12 +16,78 @@ var (
 )
 
 type AgentRole struct {
-	ID         string    `json:"id"`
-	Name       string    `json:"name"`
-	RolePrompt string    `json:"role_prompt"`
-	Steps      []string  `json:"steps,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	RolePrompt string   `json:"role_prompt"`
+	Steps      []string `json:"steps,omitempty"`
+	// IdleTimeoutMinutes overrides the server idle timeout for sessions run
+	// under this role; 0 uses the server default.
+	IdleTimeoutMinutes int `json:"idle_timeout_minutes,omitempty"`
+	// AgentType is the backend for new sessions run under this role, so
+	// Claude and Codex roles can work side by side; empty uses the
+	// default_agent_type setting.
+	AgentType session.AgentType `json:"agent_type,omitempty"`
+	// MCPServers are extra MCP servers for sessions run under this role,
+	// merged over the worktree's (see agent.MergeMCPServers).
+	MCPServers []agent.MCPServer `json:"mcp_servers,omitempty"`
+	// MaxConcurrent caps how many of this role's work sessions autorun
+	// keeps running at once (see work.AutorunSlots); 0 means no cap.
+	MaxConcurrent int       `json:"max_concurrent,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ItemID implements filestore.Item.
+func (r AgentRole) ItemID() string { return r.ID }
+
+// Changed implements filestore.Item.
+func (r AgentRole) Changed(other AgentRole) bool {
+	return r.Name != other.Name ||
+		r.RolePrompt != other.RolePrompt ||
+		!slices.Equal(r.Steps, other.Steps) ||
+		r.IdleTimeoutMinutes != other.IdleTimeoutMinutes ||
+		r.AgentType != other.AgentType ||
+		!slices.EqualFunc(r.MCPServers, other.MCPServers, agent.MCPServer.Equal) ||
+		r.MaxConcurrent != other.MaxConcurrent ||
+		!r.UpdatedAt.Equal(other.UpdatedAt)
+}
+
+// MaxIdleTimeoutMinutes bounds IdleTimeoutMinutes; keep-alive covers
+// sessions that must never be reaped.
+const MaxIdleTimeoutMinutes = 7 * 24 * 60
+
+// IdleTimeout returns the role's idle timeout override, or zero for the
+// server default.
+func (r AgentRole) IdleTimeout() time.Duration {
+	return time.Duration(r.IdleTimeoutMinutes) * time.Minute
+}
+
+func validateIdleTimeout(minutes int) error {
+	if minutes < 0 || minutes > MaxIdleTimeoutMinutes {
+		return fmt.Errorf("%w: idle_timeout_minutes must be between 0 and %d", ErrInvalidRole, MaxIdleTimeoutMinutes)
+	}
+	return nil
+}
+
+func validateMaxConcurrent(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%w: max_concurrent must not be negative", ErrInvalidRole)
+	}
+	return nil
+}
+
+func validateAgentType(t session.AgentType) error {
+	if t != "" && !t.IsValid() {
+		return fmt.Errorf("%w: unknown agent_type %q", ErrInvalidRole, t)
+	}
+	return nil
+}
+
+func validateMCPServers(servers []agent.MCPServer) error {
+	if err := agent.ValidateMCPServers(servers); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRole, err)
+	}
+	return nil
 }
 
 type Operation string
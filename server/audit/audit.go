@@ -0,0 +1,77 @@
+// Package audit appends security-relevant events (e.g. refused writes to
+// protected paths, work hook runs) to a JSON Lines file in the data
+// directory.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const Filename = "audit.log"
+
+// Event is one audit record. Action names the refused operation in RPC or
+// tool terms ("git.add", "file.write", "permission.timeout"), or the
+// recorded one ("work.hook").
+type Event struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Path      string    `json:"path,omitempty"`
+	Pattern   string    `json:"pattern,omitempty"` // protected path pattern that matched
+	Worktree  string    `json:"worktree,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	WorkID    string    `json:"work_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Output    string    `json:"output,omitempty"` // tail of a command's output
+}
+
+// Log is an append-only audit file. A nil *Log discards events, so callers
+// need no nil checks.
+type Log struct {
+	path    string
+	writeMu sync.Mutex
+}
+
+func NewLog(dataDir string) *Log {
+	return &Log{path: filepath.Join(dataDir, Filename)}
+}
+
+// Record appends e, stamping Time when unset. The event is also logged, so a
+// failed write still leaves a trace.
+func (l *Log) Record(e Event) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	slog.Warn("audit", "action", e.Action, "path", e.Path, "pattern", e.Pattern, "worktree", e.Worktree, "sessionId", e.SessionID)
+	if err := l.append(e); err != nil {
+		slog.Error("failed to write audit log", "path", l.path, "error", err)
+	}
+}
+
+func (l *Log) append(e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal audit event: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
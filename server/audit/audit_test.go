@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLog_Record(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLog(dir)
+
+	l.Record(Event{Action: "git.add", Path: ".env", Pattern: ".env"})
+	l.Record(Event{Action: "file.write", Path: "secrets/key", Pattern: "secrets/"})
+
+	f, err := os.Open(filepath.Join(dir, Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("bad line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 || events[0].Action != "git.add" || events[1].Path != "secrets/key" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if events[0].Time.IsZero() {
+		t.Error("expected Time to be stamped")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("mode = %o, want 0600", perm)
+	}
+}
+
+func TestLog_NilDiscards(t *testing.T) {
+	var l *Log
+	l.Record(Event{Action: "git.add"}) // must not panic
+}
@@ -13,13 +13,16 @@ import (
 
 var ErrSessionNotFound = errors.New("session not found")
 
-// MessageBroadcastFunc broadcasts a user message to all session subscribers,
+// MessageBroadcastFunc broadcasts a user or system message to all session subscribers,
 // optionally excluding one notifier. The exclude parameter is typed as any
 // to avoid importing the watch package; the wiring code casts it.
 type MessageBroadcastFunc func(sessionID string, event agent.MessageEvent, exclude any)
 
 // Client coordinates chat operations across session and process management.
-// It is the single entry point for programmatic chat interactions.
+// It is the single entry point for programmatic chat interactions: RPC
+// handlers send user messages through it, and server subsystems (autorun,
+// CI feedback, work kickoff, integrations) post system messages through
+// SendSystemMessage so every message is recorded and broadcast the same way.
 type Client struct {
 	store     session.Store
 	pm        *process.Manager
@@ -35,32 +38,54 @@ func (c *Client) SetBroadcaster(fn MessageBroadcastFunc) {
 	c.broadcast = fn
 }
 
-// SendMessage sends a user message to the agent process, persists it to
-// history, and broadcasts it to all session subscribers.
-func (c *Client) SendMessage(ctx context.Context, sessionID, content string) error {
-	return c.sendMessage(ctx, sessionID, content, nil)
+// SendUserMessage sends a message on behalf of the user: it is delivered to
+// the agent process (created if needed), persisted to history, and
+// broadcast to all session subscribers.
+func (c *Client) SendUserMessage(ctx context.Context, sessionID, content string) error {
+	return c.send(ctx, sessionID, agent.MessageEvent{Content: content})
 }
 
-// SendMessageExcluding is like SendMessage but excludes one notifier from
-// the broadcast. Used when the caller already notified itself (e.g. the
-// WebSocket client that sent the message).
-func (c *Client) SendMessageExcluding(ctx context.Context, sessionID, content string, exclude any) error {
-	return c.sendMessage(ctx, sessionID, content, exclude)
+// SendSystemMessage is like SendUserMessage for a message the server
+// composes itself, such as autorun prompts, CI feedback, or messages posted
+// by webhooks and schedulers. The history record is marked as system so
+// clients can tell it apart from what a user typed.
+func (c *Client) SendSystemMessage(ctx context.Context, sessionID, content string) error {
+	return c.send(ctx, sessionID, agent.MessageEvent{Content: content, System: true})
 }
 
-func (c *Client) sendMessage(ctx context.Context, sessionID, content string, exclude any) error {
+func (c *Client) send(ctx context.Context, sessionID string, event agent.MessageEvent) error {
 	proc, err := c.getOrCreateProcess(ctx, sessionID)
 	if err != nil {
 		return err
 	}
+	return c.deliverMessage(ctx, proc, sessionID, event, nil)
+}
 
-	// Persist user message to history
-	event := agent.MessageEvent{Content: content}
+// SendMessageExcluding is like SendUserMessage for a message from a client,
+// identified by its notifier: the notifier is excluded from the broadcast
+// (the client already shows its message), and the message waits if another
+// client's turn is running (see process.Process.SubmitInput). A queued
+// message is persisted and broadcast when it is delivered; queued reports
+// whether it was.
+func (c *Client) SendMessageExcluding(ctx context.Context, sessionID, content string, exclude any) (queued bool, err error) {
+	proc, err := c.getOrCreateProcess(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	// A queued message outlives the request that sent it.
+	deliverCtx := context.WithoutCancel(ctx)
+	return proc.SubmitInput(exclude, func() error {
+		return c.deliverMessage(deliverCtx, proc, sessionID, agent.MessageEvent{Content: content}, exclude)
+	})
+}
+
+func (c *Client) deliverMessage(ctx context.Context, proc *process.Process, sessionID string, event agent.MessageEvent, exclude any) error {
+	// Persist message to history
 	if err := c.store.AppendToHistory(ctx, sessionID, agent.NewEventRecord(event)); err != nil {
-		slog.Error("failed to persist user message", "sessionId", sessionID, "error", err)
+		slog.Error("failed to persist message", "sessionId", sessionID, "system", event.System, "error", err)
 	}
 
-	if err := proc.SendMessage(content); err != nil {
+	if err := proc.SendMessage(event.Content); err != nil {
 		return err
 	}
 
@@ -81,16 +106,36 @@ func (c *Client) SendPermissionResponse(ctx context.Context, sessionID string, d
 		return err
 	}
 
-	// Persist response to history
+	c.persistPermissionResponse(ctx, sessionID, data.RequestID, choice)
+	return nil
+}
+
+// SendPermissionGroupResponse answers a permission request and applies the
+// same choice to the other pending requests in its group, returning their
+// IDs.
+func (c *Client) SendPermissionGroupResponse(ctx context.Context, sessionID string, data agent.PermissionRequestData, choice agent.PermissionChoice) ([]string, error) {
+	proc, err := c.getOrCreateProcess(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	answered, err := proc.SendPermissionGroupResponse(data, choice)
+	if err != nil {
+		return nil, err
+	}
+
+	c.persistPermissionResponse(ctx, sessionID, data.RequestID, choice)
+	return answered, nil
+}
+
+func (c *Client) persistPermissionResponse(ctx context.Context, sessionID, requestID string, choice agent.PermissionChoice) {
 	event := agent.PermissionResponseEvent{
-		RequestID: data.RequestID,
-		Choice:    choiceToString(choice),
+		RequestID: requestID,
+		Choice:    choice.String(),
 	}
 	if err := c.store.AppendToHistory(ctx, sessionID, agent.NewEventRecord(event)); err != nil {
 		slog.Error("failed to persist permission response", "sessionId", sessionID, "error", err)
 	}
-
-	return nil
 }
 
 func (c *Client) SendQuestionResponse(ctx context.Context, sessionID string, data agent.QuestionRequestData, answers map[string]string) error {
@@ -123,6 +168,21 @@ func (c *Client) Interrupt(ctx context.Context, sessionID string) error {
 	return proc.SendInterrupt()
 }
 
+// Restart replaces the session's agent process with a fresh one that
+// resumes the conversation. The turn in flight is lost.
+func (c *Client) Restart(ctx context.Context, sessionID string) error {
+	if _, found, err := c.store.Get(sessionID); err != nil {
+		return fmt.Errorf("get session: %w", err)
+	} else if !found {
+		return ErrSessionNotFound
+	}
+	if err := c.pm.CloseAndWait(sessionID); err != nil {
+		return err
+	}
+	_, err := c.getOrCreateProcess(ctx, sessionID)
+	return err
+}
+
 // getOrCreateProcess handles session validation, process creation, and activation.
 func (c *Client) getOrCreateProcess(ctx context.Context, sessionID string) (*process.Process, error) {
 	meta, found, err := c.store.Get(sessionID)
@@ -145,17 +205,11 @@ func (c *Client) getOrCreateProcess(ctx context.Context, sessionID string) (*pro
 			slog.Error("failed to activate session", "sessionId", sessionID, "error", err)
 		}
 	}
+	if created && meta.Drained != "" {
+		if err := c.store.SetDrained(ctx, sessionID, ""); err != nil {
+			slog.Error("failed to clear drain marker", "sessionId", sessionID, "error", err)
+		}
+	}
 
 	return proc, nil
 }
-
-func choiceToString(choice agent.PermissionChoice) string {
-	switch choice {
-	case agent.PermissionAllow:
-		return "allow"
-	case agent.PermissionAlwaysAllow:
-		return "always_allow"
-	default:
-		return "deny"
-	}
-}
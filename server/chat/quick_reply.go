@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// MaxQuickReplies bounds the templates per worktree; they are shown as a
+// one-tap list, so more would not fit anyway.
+const MaxQuickReplies = 50
+
+// maxQuickReplyText keeps a template a chat message, not a document.
+const maxQuickReplyText = 4000
+
+var ErrInvalidQuickReply = errors.New("invalid quick reply")
+
+var quickReplyID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Quick reply kinds; an empty kind offers the template for both.
+const (
+	QuickReplyPermission = "permission"
+	QuickReplyQuestion   = "question"
+)
+
+// QuickReply is a canned answer attached to a permission or question
+// response: its text is sent as a follow-up user message once the response
+// is delivered, so "deny, and explain why" takes one tap.
+type QuickReply struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Text  string `json:"text"`
+	Kind  string `json:"kind,omitempty"` // QuickReplyPermission | QuickReplyQuestion | "" (both)
+}
+
+// AppliesTo reports whether the template is offered for kind.
+func (q QuickReply) AppliesTo(kind string) bool {
+	return q.Kind == "" || q.Kind == kind
+}
+
+// ValidateQuickReplies checks IDs (unique), labels, texts and kinds. Errors
+// wrap ErrInvalidQuickReply.
+func ValidateQuickReplies(replies []QuickReply) error {
+	if len(replies) > MaxQuickReplies {
+		return fmt.Errorf("%w: at most %d quick replies", ErrInvalidQuickReply, MaxQuickReplies)
+	}
+	seen := make(map[string]bool, len(replies))
+	for _, q := range replies {
+		if !quickReplyID.MatchString(q.ID) {
+			return fmt.Errorf("%w: id %q must be 1-64 letters, digits, '-' or '_'", ErrInvalidQuickReply, q.ID)
+		}
+		if seen[q.ID] {
+			return fmt.Errorf("%w: duplicate id %q", ErrInvalidQuickReply, q.ID)
+		}
+		seen[q.ID] = true
+		if q.Label == "" {
+			return fmt.Errorf("%w: %s: label is required", ErrInvalidQuickReply, q.ID)
+		}
+		if q.Text == "" {
+			return fmt.Errorf("%w: %s: text is required", ErrInvalidQuickReply, q.ID)
+		}
+		if utf8.RuneCountInString(q.Text) > maxQuickReplyText {
+			return fmt.Errorf("%w: %s: text exceeds %d characters", ErrInvalidQuickReply, q.ID, maxQuickReplyText)
+		}
+		switch q.Kind {
+		case "", QuickReplyPermission, QuickReplyQuestion:
+		default:
+			return fmt.Errorf("%w: %s: kind must be %q, %q or empty", ErrInvalidQuickReply, q.ID, QuickReplyPermission, QuickReplyQuestion)
+		}
+	}
+	return nil
+}
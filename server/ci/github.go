@@ -0,0 +1,108 @@
+package ci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGitHubAPI = "https://api.github.com"
+	requestTimeout   = 15 * time.Second
+)
+
+// Repo identifies a GitHub repository.
+type Repo struct {
+	Owner string
+	Name  string
+}
+
+// ParseGitHubRemote extracts the repository from a github.com remote URL in
+// SSH (git@github.com:o/r.git, ssh://git@github.com/o/r) or HTTPS form.
+func ParseGitHubRemote(remote string) (Repo, bool) {
+	remote = strings.TrimSpace(remote)
+	var path string
+	if rest, ok := strings.CutPrefix(remote, "git@github.com:"); ok {
+		path = rest
+	} else {
+		u, err := url.Parse(remote)
+		if err != nil || u.Hostname() != "github.com" {
+			return Repo{}, false
+		}
+		path = strings.TrimPrefix(u.Path, "/")
+	}
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	owner, name, ok := strings.Cut(path, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return Repo{}, false
+	}
+	return Repo{Owner: owner, Name: name}, true
+}
+
+// GitHubClient reads check runs from the GitHub REST API.
+type GitHubClient struct {
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+func NewGitHubClient(token string) *GitHubClient {
+	return &GitHubClient{
+		client:  &http.Client{Timeout: requestTimeout},
+		baseURL: defaultGitHubAPI,
+		token:   token,
+	}
+}
+
+type checkRunsResponse struct {
+	CheckRuns []struct {
+		Name       string `json:"name"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+		HTMLURL    string `json:"html_url"`
+	} `json:"check_runs"`
+}
+
+// CheckRuns returns the check runs for a commit. Only the first 100 are read;
+// repositories with more checks per commit are rare.
+func (c *GitHubClient) CheckRuns(ctx context.Context, repo Repo, sha string) ([]Check, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/commits/%s/check-runs?per_page=100",
+		c.baseURL, url.PathEscape(repo.Owner), url.PathEscape(repo.Name), url.PathEscape(sha))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch check runs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		// Commit not on GitHub (yet), or no access to the repository.
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch check runs: unexpected status %d", resp.StatusCode)
+	}
+
+	var body checkRunsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode check runs: %w", err)
+	}
+	checks := make([]Check, len(body.CheckRuns))
+	for i, r := range body.CheckRuns {
+		checks[i] = Check{Name: r.Name, Status: r.Status, Conclusion: r.Conclusion, URL: r.HTMLURL}
+	}
+	return checks, nil
+}
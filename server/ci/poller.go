@@ -0,0 +1,196 @@
+package ci
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultPollInterval keeps an authenticated poller far below GitHub's
+	// 5000 requests/hour even with dozens of worktrees.
+	DefaultPollInterval = time.Minute
+	gitTimeout          = 10 * time.Second
+)
+
+// Target is a worktree branch to track.
+type Target struct {
+	Worktree string
+	Branch   string
+	Dir      string
+}
+
+// CheckFetcher returns the check runs of a commit. Satisfied by *GitHubClient.
+type CheckFetcher interface {
+	CheckRuns(ctx context.Context, repo Repo, sha string) ([]Check, error)
+}
+
+// Poller periodically refreshes the CI status of every target branch.
+type Poller struct {
+	fetcher  CheckFetcher
+	targets  func() []Target
+	mainDir  string
+	interval time.Duration
+
+	statusesMu sync.RWMutex
+	statuses   map[string]Status // by branch
+	listeners  []OnChangeListener
+
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewPoller creates a poller for the GitHub repository behind mainDir's
+// origin remote. targets is called on every poll so new worktrees are picked
+// up without a restart.
+func NewPoller(fetcher CheckFetcher, mainDir string, targets func() []Target) *Poller {
+	return &Poller{
+		fetcher:  fetcher,
+		targets:  targets,
+		mainDir:  mainDir,
+		interval: DefaultPollInterval,
+		statuses: make(map[string]Status),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// AddOnChangeListener must be called before Start.
+func (p *Poller) AddOnChangeListener(listener OnChangeListener) {
+	p.statusesMu.Lock()
+	defer p.statusesMu.Unlock()
+	p.listeners = append(p.listeners, listener)
+}
+
+func (p *Poller) Start() {
+	p.started.Store(true)
+	go func() {
+		defer close(p.done)
+		p.Poll(context.Background())
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.Poll(context.Background())
+			}
+		}
+	}()
+	slog.Info("CI status poller started", "interval", p.interval)
+}
+
+// Stop stops polling. Safe to call when Start was never called.
+func (p *Poller) Stop() {
+	if !p.started.Load() {
+		return
+	}
+	close(p.stop)
+	<-p.done
+}
+
+// Get returns the last known status of branch.
+func (p *Poller) Get(branch string) (Status, bool) {
+	p.statusesMu.RLock()
+	defer p.statusesMu.RUnlock()
+	s, ok := p.statuses[branch]
+	return s, ok
+}
+
+// List returns the last known status of every tracked branch.
+func (p *Poller) List() []Status {
+	p.statusesMu.RLock()
+	defer p.statusesMu.RUnlock()
+	out := make([]Status, 0, len(p.statuses))
+	for _, s := range p.statuses {
+		out = append(out, s)
+	}
+	return out
+}
+
+// Poll refreshes all targets once. Exported for tests and manual refresh.
+func (p *Poller) Poll(ctx context.Context) {
+	remote, err := p.git(ctx, p.mainDir, "remote", "get-url", "origin")
+	if err != nil {
+		return // no origin: nothing can have CI
+	}
+	repo, ok := ParseGitHubRemote(remote)
+	if !ok {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range p.targets() {
+		if t.Branch == "" {
+			continue // detached HEAD
+		}
+		seen[t.Branch] = true
+		p.pollTarget(ctx, repo, t)
+	}
+
+	p.statusesMu.Lock()
+	for branch := range p.statuses {
+		if !seen[branch] {
+			delete(p.statuses, branch)
+		}
+	}
+	p.statusesMu.Unlock()
+}
+
+func (p *Poller) pollTarget(ctx context.Context, repo Repo, t Target) {
+	// The upstream ref is the last pushed commit; unpushed work has no CI.
+	sha, err := p.git(ctx, t.Dir, "rev-parse", "--verify", "--quiet", t.Branch+"@{upstream}")
+	if err != nil {
+		return
+	}
+
+	prev, hadPrev := p.Get(t.Branch)
+	if hadPrev && prev.SHA == sha && prev.State.final() {
+		return
+	}
+
+	checks, err := p.fetcher.CheckRuns(ctx, repo, sha)
+	if err != nil {
+		slog.Warn("failed to fetch CI status", "branch", t.Branch, "sha", sha, "error", err)
+		return
+	}
+	status := Status{
+		Worktree:  t.Worktree,
+		Branch:    t.Branch,
+		SHA:       sha,
+		State:     Aggregate(checks),
+		Checks:    checks,
+		UpdatedAt: time.Now(),
+	}
+	if hadPrev && !status.changed(prev) {
+		return
+	}
+
+	p.statusesMu.Lock()
+	p.statuses[t.Branch] = status
+	listeners := make([]OnChangeListener, len(p.listeners))
+	copy(listeners, p.listeners)
+	p.statusesMu.Unlock()
+
+	for _, l := range listeners {
+		l.OnCIStatusChange(prev, status)
+	}
+}
+
+func (p *Poller) git(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--no-optional-locks"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,87 @@
+// Package ci tracks CI status (GitHub check runs) for worktree branches.
+//
+// A Poller resolves each branch's pushed commit (its upstream ref) and asks
+// the GitHub checks API for the runs on that commit. Only pushed commits can
+// have CI, so unpushed branches simply have no status.
+package ci
+
+import (
+	"slices"
+	"time"
+)
+
+type State string
+
+const (
+	StateNone    State = "none" // pushed, but no check runs reported (yet)
+	StatePending State = "pending"
+	StateSuccess State = "success"
+	StateFailure State = "failure"
+)
+
+// final reports whether the state can no longer change for the same commit,
+// so polling that commit again is unnecessary. Re-runs on the same commit are
+// missed, which is acceptable for a status badge.
+func (s State) final() bool {
+	return s == StateSuccess || s == StateFailure
+}
+
+// Check is one GitHub check run.
+type Check struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`               // queued | in_progress | completed
+	Conclusion string `json:"conclusion,omitempty"` // success | failure | neutral | cancelled | skipped | timed_out | action_required
+	URL        string `json:"url,omitempty"`
+}
+
+// Failed reports whether the check completed with a failing conclusion.
+// Cancelled runs are usually superseded by a newer push, so they do not count.
+func (c Check) Failed() bool {
+	switch c.Conclusion {
+	case "failure", "timed_out", "action_required":
+		return true
+	}
+	return false
+}
+
+// Status is the CI status of a branch's pushed commit.
+type Status struct {
+	Worktree  string    `json:"worktree"`
+	Branch    string    `json:"branch"`
+	SHA       string    `json:"sha"`
+	State     State     `json:"state"`
+	Checks    []Check   `json:"checks,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Aggregate derives the overall state of a commit from its check runs. A
+// failed check fails the commit even while others are still running, so
+// feedback is not held back by slow jobs.
+func Aggregate(checks []Check) State {
+	if len(checks) == 0 {
+		return StateNone
+	}
+	pending := false
+	for _, c := range checks {
+		if c.Failed() {
+			return StateFailure
+		}
+		if c.Status != "completed" {
+			pending = true
+		}
+	}
+	if pending {
+		return StatePending
+	}
+	return StateSuccess
+}
+
+func (s Status) changed(other Status) bool {
+	return s.Worktree != other.Worktree || s.SHA != other.SHA || s.State != other.State || !slices.Equal(s.Checks, other.Checks)
+}
+
+// OnChangeListener receives branch statuses whose commit, state, or checks
+// changed. Called from the poller goroutine; must not block.
+type OnChangeListener interface {
+	OnCIStatusChange(prev, status Status)
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/fsck"
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/settings"
+	"github.com/pockode/server/work"
+	"github.com/pockode/server/workhook"
+)
+
+// Admin subcommands (work, role, session, fsck) open the data dir through the
+// same stores the server uses, so scripts need no WebSocket client. Writes
+// take the stores' file locks; a running server picks them up through its
+// file watchers.
+
+const cliUsage = `Usage:
+  pockode [serve] [flags]          run the server (see pockode serve -h)
+  pockode work list [flags]        list work items
+  pockode work create [flags]      create a work item
+  pockode work done [flags] <id>   close an in-progress work item
+  pockode role list [flags]        list agent roles
+  pockode session export [flags] <session-id>
+                                   write a session and its history as JSON
+  pockode fsck [flags]             check the data directory for inconsistencies
+  pockode replay [flags] <file>    play recorded agent events through the
+                                   process pipeline (see serve -record-events)
+  pockode mcp | cluster            internal and cluster modes
+`
+
+var errCLIUsage = errors.New("invalid command line")
+
+// cliHelp is returned for -h with the usage to print.
+type cliHelp string
+
+func (h cliHelp) Error() string { return string(h) }
+
+// runCLI runs an admin subcommand. args excludes the command name.
+func runCLI(command string, args []string, stdout io.Writer) error {
+	if command == "fsck" {
+		return cliFsck(args, stdout)
+	}
+	sub := ""
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+	switch command + " " + sub {
+	case "work list":
+		return cliWorkList(args, stdout)
+	case "work create":
+		return cliWorkCreate(args, stdout)
+	case "work done":
+		return cliWorkDone(args, stdout)
+	case "role list":
+		return cliRoleList(args, stdout)
+	case "session export":
+		return cliSessionExport(args, stdout)
+	}
+	return fmt.Errorf("%w: unknown command %q\n\n%s", errCLIUsage, strings.TrimSpace(command+" "+sub), cliUsage)
+}
+
+// cliDirs registers -work and -data with the serve defaults and resolves
+// them after parsing.
+type cliDirs struct {
+	work *string
+	data *string
+}
+
+func newCLIFlags(name string) (*flag.FlagSet, cliDirs) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	return fs, cliDirs{
+		work: fs.String("work", ".", "working directory"),
+		data: fs.String("data", "", "data directory (default: <work>/.pockode)"),
+	}
+}
+
+func (d cliDirs) resolve() (workDir, dataDir string, err error) {
+	workDir, err = filepath.Abs(*d.work)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve work directory: %w", err)
+	}
+	dataDir = *d.data
+	if dataDir == "" {
+		dataDir = filepath.Join(workDir, ".pockode")
+	}
+	dataDir, err = filepath.Abs(dataDir)
+	if err != nil {
+		return "", "", fmt.Errorf("resolve data directory: %w", err)
+	}
+	if _, err := os.Stat(dataDir); err != nil {
+		return "", "", fmt.Errorf("data directory: %w", err)
+	}
+	return workDir, dataDir, nil
+}
+
+// parseCLIFlags parses args and wants exactly nArgs positional arguments.
+func parseCLIFlags(fs *flag.FlagSet, args []string, nArgs int, argsUsage string) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return cliHelp(flagUsage(fs, argsUsage))
+	} else if err != nil {
+		return fmt.Errorf("%w: %v\n\n%s", errCLIUsage, err, flagUsage(fs, argsUsage))
+	}
+	if fs.NArg() != nArgs {
+		return fmt.Errorf("%w\n\n%s", errCLIUsage, flagUsage(fs, argsUsage))
+	}
+	return nil
+}
+
+func flagUsage(fs *flag.FlagSet, argsUsage string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage: pockode %s [flags] %s\n", fs.Name(), argsUsage)
+	fs.SetOutput(&b)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+	return b.String()
+}
+
+// openCLIStores opens the work, agent role, and settings stores of dataDir
+// wired as the server wires them: created work falls back to the default
+// agent role and lifecycle hooks apply. Non-blocking hooks run in the
+// background, so callers Wait on the returned runner before returning.
+func openCLIStores(workDir, dataDir string) (*stores, *settings.Store, *workhook.Runner, error) {
+	settingsStore, err := settings.NewStore(dataDir)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("open settings: %w", err)
+	}
+	s, err := initStores(dataDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	setupDefaultAgentRole(settingsStore, s)
+	hooks := workhook.NewRunner(workDir, audit.NewLog(dataDir))
+	hooks.SetHooks(func() []workhook.Hook { return settingsStore.Get().WorkHooks })
+	s.work.SetLifecycleHooks(hooks)
+	return s, settingsStore, hooks, nil
+}
+
+func cliWorkList(args []string, stdout io.Writer) error {
+	fs, dirs := newCLIFlags("work list")
+	status := fs.String("status", "", "comma-separated statuses to include")
+	typ := fs.String("type", "", "story or task")
+	parent := fs.String("parent", "", "only children of this work ID")
+	asJSON := fs.Bool("json", false, "print the items as JSON")
+	if err := parseCLIFlags(fs, args, 0, ""); err != nil {
+		return err
+	}
+	workDir, dataDir, err := dirs.resolve()
+	if err != nil {
+		return err
+	}
+	s, _, hooks, err := openCLIStores(workDir, dataDir)
+	if err != nil {
+		return err
+	}
+	defer hooks.Wait()
+
+	works, err := s.work.List()
+	if err != nil {
+		return err
+	}
+	q := work.ListQuery{Type: work.WorkType(*typ), ParentID: work.ResolveID(works, *parent)}
+	if *status != "" {
+		for _, st := range strings.Split(*status, ",") {
+			q.Statuses = append(q.Statuses, work.WorkStatus(strings.TrimSpace(st)))
+		}
+	}
+	page, err := work.Query(works, q)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(page.Items)
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tSTATUS\tTITLE")
+	for _, w := range page.Items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", displayID(w), w.Type, w.Status, w.Title)
+	}
+	return tw.Flush()
+}
+
+func cliWorkCreate(args []string, stdout io.Writer) error {
+	fs, dirs := newCLIFlags("work create")
+	typ := fs.String("type", "story", "story or task")
+	title := fs.String("title", "", "title (required)")
+	body := fs.String("body", "", "description or instructions")
+	parent := fs.String("parent", "", "parent story ID (required for tasks)")
+	role := fs.String("role", "", "agent role ID (default: the default agent role)")
+	due := fs.String("due", "", "due date as an RFC 3339 timestamp")
+	if err := parseCLIFlags(fs, args, 0, ""); err != nil {
+		return err
+	}
+	workDir, dataDir, err := dirs.resolve()
+	if err != nil {
+		return err
+	}
+	s, _, hooks, err := openCLIStores(workDir, dataDir)
+	if err != nil {
+		return err
+	}
+	defer hooks.Wait()
+
+	if *role != "" {
+		if _, found, err := s.agentRole.Get(*role); err != nil {
+			return fmt.Errorf("validate agent role: %w", err)
+		} else if !found {
+			return fmt.Errorf("agent role %q not found", *role)
+		}
+	}
+	dueAt, err := work.ParseDueAt(*due)
+	if err != nil {
+		return err
+	}
+	works, err := s.work.List()
+	if err != nil {
+		return err
+	}
+
+	w, err := s.work.Create(context.Background(), work.Work{
+		Type:        work.WorkType(*typ),
+		ParentID:    work.ResolveID(works, *parent),
+		AgentRoleID: *role,
+		Title:       *title,
+		Body:        *body,
+		DueAt:       dueAt,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s\t%s\n", displayID(w), w.ID)
+	return nil
+}
+
+func cliWorkDone(args []string, stdout io.Writer) error {
+	fs, dirs := newCLIFlags("work done")
+	if err := parseCLIFlags(fs, args, 1, "<id>"); err != nil {
+		return err
+	}
+	workDir, dataDir, err := dirs.resolve()
+	if err != nil {
+		return err
+	}
+	s, _, hooks, err := openCLIStores(workDir, dataDir)
+	if err != nil {
+		return err
+	}
+	defer hooks.Wait()
+
+	works, err := s.work.List()
+	if err != nil {
+		return err
+	}
+	id := work.ResolveID(works, fs.Arg(0))
+	// No step count: an admin closing the item skips any steps left. The
+	// agent session, if one is running, is not told.
+	if _, err := s.work.StepDone(context.Background(), id, 0); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "closed %s\n", fs.Arg(0))
+	return nil
+}
+
+func cliRoleList(args []string, stdout io.Writer) error {
+	fs, dirs := newCLIFlags("role list")
+	asJSON := fs.Bool("json", false, "print the roles as JSON")
+	if err := parseCLIFlags(fs, args, 0, ""); err != nil {
+		return err
+	}
+	workDir, dataDir, err := dirs.resolve()
+	if err != nil {
+		return err
+	}
+	s, settingsStore, hooks, err := openCLIStores(workDir, dataDir)
+	if err != nil {
+		return err
+	}
+	defer hooks.Wait()
+
+	roles, err := s.agentRole.List()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(roles)
+	}
+	defaultID := settingsStore.Get().DefaultAgentRoleID
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tDEFAULT")
+	for _, r := range roles {
+		mark := ""
+		if r.ID == defaultID {
+			mark = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.ID, r.Name, mark)
+	}
+	return tw.Flush()
+}
+
+// sessionExport is the file session export writes.
+type sessionExport struct {
+	Session    session.SessionMeta `json:"session"`
+	History    []json.RawMessage   `json:"history"`
+	ExportedAt time.Time           `json:"exported_at"`
+}
+
+func cliSessionExport(args []string, stdout io.Writer) error {
+	fs, dirs := newCLIFlags("session export")
+	worktreeName := fs.String("worktree", "", "worktree the session belongs to (default: the main one)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	if err := parseCLIFlags(fs, args, 1, "<session-id>"); err != nil {
+		return err
+	}
+	_, dataDir, err := dirs.resolve()
+	if err != nil {
+		return err
+	}
+
+	// Sessions live in the data dir of the worktree they ran in.
+	sessionDir := dataDir
+	if *worktreeName != "" {
+		sessionDir = filepath.Join(dataDir, "worktrees", *worktreeName)
+	}
+	store, err := session.NewFileStore(sessionDir)
+	if err != nil {
+		return fmt.Errorf("open sessions: %w", err)
+	}
+	meta, found, err := store.Get(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("session %q not found", fs.Arg(0))
+	}
+	history, err := store.GetHistory(context.Background(), meta.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(sessionExport{Session: meta, History: history, ExportedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*out, data, 0600)
+}
+
+func cliFsck(args []string, stdout io.Writer) error {
+	fs, dirs := newCLIFlags("fsck")
+	repair := fs.Bool("repair", false, "apply the safe fixes")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := parseCLIFlags(fs, args, 0, ""); err != nil {
+		return err
+	}
+	workDir, dataDir, err := dirs.resolve()
+	if err != nil {
+		return err
+	}
+	s, settingsStore, hooks, err := openCLIStores(workDir, dataDir)
+	if err != nil {
+		return err
+	}
+	defer hooks.Wait()
+
+	checker := fsck.NewChecker(dataDir, s.work, s.agentRole, func() string { return settingsStore.Get().DefaultAgentRoleID })
+	report, err := checker.Run(context.Background(), *repair)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	if len(report.Issues) == 0 {
+		fmt.Fprintln(stdout, "no issues found")
+		return nil
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tSUBJECT\tDETAIL\tFIX")
+	for _, issue := range report.Issues {
+		fix := issue.Fix
+		switch {
+		case issue.Error != "":
+			fix += " (failed: " + issue.Error + ")"
+		case issue.Repaired:
+			fix += " (done)"
+		case fix == "":
+			fix = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", issue.Kind, issue.Subject, issue.Detail, fix)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%d issues, %d repaired\n", len(report.Issues), report.Repaired)
+	return nil
+}
+
+// displayID is the short ID when the item has one.
+func displayID(w work.Work) string {
+	if w.ShortID != "" {
+		return w.ShortID
+	}
+	return w.ID
+}
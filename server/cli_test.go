@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/fsck"
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/settings"
+	"github.com/pockode/server/work"
+	"github.com/pockode/server/workhook"
+)
+
+func runCLIForTest(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := runCLI(args[0], args[1:], &out)
+	return out.String(), err
+}
+
+func TestCLI_Work(t *testing.T) {
+	dataDir := t.TempDir()
+
+	out, err := runCLIForTest(t, "work", "create", "-data", dataDir, "-title", "Scripted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortID := strings.Fields(out)[0]
+
+	// No -role: the seeded default role is used.
+	workStore, err := work.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	works, _ := workStore.List()
+	if len(works) != 1 || works[0].AgentRoleID == "" {
+		t.Fatalf("works = %+v, want one item with the default role", works)
+	}
+	if _, err := workStore.Start(context.Background(), works[0].ID, "sess-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = runCLIForTest(t, "work", "list", "-data", dataDir, "-status", "in_progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, shortID) || !strings.Contains(out, "Scripted") {
+		t.Errorf("list = %q, want the item", out)
+	}
+
+	if _, err := runCLIForTest(t, "work", "done", "-data", dataDir, shortID); err != nil {
+		t.Fatal(err)
+	}
+	out, _ = runCLIForTest(t, "work", "list", "-data", dataDir, "-json", "-status", "closed")
+	var listed []work.Work
+	if err := json.Unmarshal([]byte(out), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ID != works[0].ID {
+		t.Errorf("closed = %+v, want the item", listed)
+	}
+}
+
+func TestCLI_SessionExport(t *testing.T) {
+	dataDir := t.TempDir()
+	store, err := session.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := store.Create(context.Background(), "sess-1", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendToHistory(context.Background(), sess.ID, map[string]string{"type": "message", "content": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	outFile := filepath.Join(t.TempDir(), "export.json")
+	if _, err := runCLIForTest(t, "session", "export", "-data", dataDir, "-o", outFile, sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export sessionExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Session.ID != sess.ID || len(export.History) != 1 {
+		t.Errorf("export = %+v, want the session and its message", export)
+	}
+
+	if _, err := runCLIForTest(t, "session", "export", "-data", dataDir, "missing"); err == nil {
+		t.Error("expected an error for an unknown session")
+	}
+}
+
+func TestCLI_Fsck(t *testing.T) {
+	dataDir := t.TempDir()
+	out, err := runCLIForTest(t, "fsck", "-data", dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "no issues found") {
+		t.Errorf("fresh data dir = %q, want no issues", out)
+	}
+
+	roles, err := agentrole.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := roles.Create(context.Background(), agentrole.AgentRole{Name: "Temp", RolePrompt: "temp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCLIForTest(t, "work", "create", "-data", dataDir, "-title", "Orphaned role", "-role", role.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := roles.Delete(context.Background(), role.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = runCLIForTest(t, "fsck", "-data", dataDir, "-repair", "-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report fsck.Report
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != fsck.KindMissingRole || report.Repaired != 1 {
+		t.Errorf("report = %+v, want the missing role repaired", report)
+	}
+}
+
+func TestCLI_Usage(t *testing.T) {
+	if _, err := runCLIForTest(t, "work", "frob"); !errors.Is(err, errCLIUsage) {
+		t.Errorf("unknown subcommand err = %v, want errCLIUsage", err)
+	}
+	if _, err := runCLIForTest(t, "work", "done", "-data", t.TempDir()); !errors.Is(err, errCLIUsage) {
+		t.Errorf("missing id err = %v, want errCLIUsage", err)
+	}
+	var help cliHelp
+	if _, err := runCLIForTest(t, "role", "list", "-h"); !errors.As(err, &help) || !strings.Contains(string(help), "-json") {
+		t.Errorf("-h err = %v, want the usage", err)
+	}
+}
+
+func TestCLI_WorkWaitsForBackgroundHooks(t *testing.T) {
+	dataDir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "hook-ran")
+	settingsStore, err := settings.NewStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := settingsStore.Get()
+	cfg.WorkHooks = []workhook.Hook{{
+		Event:     work.LifecycleCreated,
+		Command:   "sleep 0.2 && touch " + marker,
+		OnFailure: workhook.FailureIgnore,
+	}}
+	if err := settingsStore.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runCLIForTest(t, "work", "create", "-data", dataDir, "-title", "Hooked"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("created hook had not finished when the CLI returned: %v", err)
+	}
+}
@@ -107,7 +107,7 @@ func Run(cfg Config) error {
 
 	// Print QR code if relay is enabled
 	if remoteURL != "" {
-		startup.PrintQRCode(remoteURL)
+		startup.PrintQRCode(remoteURL, "Scan to connect")
 		fmt.Println()
 	}
 
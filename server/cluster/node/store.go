@@ -1,7 +1,6 @@
 package node
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -228,18 +227,10 @@ func (s *FileStore) Delete(id string) error {
 // --- File I/O ---
 
 func (s *FileStore) readIndexFromDisk() (indexData, error) {
-	data, err := s.file.Read()
+	idx, err := filestore.Load(s.file, indexData{})
 	if err != nil {
 		return indexData{}, err
 	}
-	if data == nil {
-		return indexData{Nodes: []Node{}}, nil
-	}
-
-	var idx indexData
-	if err := json.Unmarshal(data, &idx); err != nil {
-		return indexData{}, err
-	}
 	if idx.Nodes == nil {
 		idx.Nodes = []Node{}
 	}
@@ -247,11 +238,7 @@ func (s *FileStore) readIndexFromDisk() (indexData, error) {
 }
 
 func (s *FileStore) persistIndex() error {
-	data, err := filestore.MarshalIndex(indexData{Nodes: s.nodes})
-	if err != nil {
-		return err
-	}
-	return s.file.Write(data)
+	return s.file.Persist(indexData{Nodes: s.nodes})
 }
 
 func (s *FileStore) snapshotNodes() []Node {
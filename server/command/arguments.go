@@ -0,0 +1,32 @@
+package command
+
+import "regexp"
+
+// Argument is one placeholder of a command's argument hint.
+type Argument struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required"`
+}
+
+var argumentPlaceholder = regexp.MustCompile(`<([^<>]+)>|\[([^\[\]]+)\]`)
+
+// ParseArgumentHint extracts the placeholders of an argument hint such as
+// "<file> [message]": angle brackets mark required arguments, square
+// brackets optional ones. Other text in the hint is ignored, and a name
+// repeated across alternatives is returned once.
+func ParseArgumentHint(hint string) []Argument {
+	var args []Argument
+	seen := make(map[string]bool)
+	for _, m := range argumentPlaceholder.FindAllStringSubmatch(hint, -1) {
+		arg := Argument{Name: m[1], Required: true}
+		if m[1] == "" {
+			arg = Argument{Name: m[2]}
+		}
+		if arg.Name == "" || seen[arg.Name] {
+			continue
+		}
+		seen[arg.Name] = true
+		args = append(args, arg)
+	}
+	return args
+}
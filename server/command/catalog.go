@@ -0,0 +1,48 @@
+package command
+
+import (
+	"sync"
+	"time"
+)
+
+// Catalog memoizes the commands agents list, keyed by agent and directory,
+// for ttl: listing starts the agent's CLI, too slow to repeat every time
+// the palette opens, while custom commands change rarely. Failed listings
+// are not cached. Safe for concurrent use.
+type Catalog struct {
+	ttl       time.Duration
+	entriesMu sync.Mutex
+	entries   map[string]catalogEntry
+}
+
+type catalogEntry struct {
+	fetchedAt time.Time
+	specs     []Spec
+}
+
+func NewCatalog(ttl time.Duration) *Catalog {
+	return &Catalog{
+		ttl:     ttl,
+		entries: make(map[string]catalogEntry),
+	}
+}
+
+// Get returns the cached specs for key, calling list when there are none
+// or they are older than the catalog's ttl.
+func (c *Catalog) Get(key string, list func() ([]Spec, error)) ([]Spec, error) {
+	c.entriesMu.Lock()
+	entry, ok := c.entries[key]
+	c.entriesMu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < c.ttl {
+		return entry.specs, nil
+	}
+
+	specs, err := list()
+	if err != nil {
+		return nil, err
+	}
+	c.entriesMu.Lock()
+	c.entries[key] = catalogEntry{fetchedAt: time.Now(), specs: specs}
+	c.entriesMu.Unlock()
+	return specs, nil
+}
@@ -3,6 +3,7 @@ package command
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -34,12 +35,32 @@ var BuiltinCommands = []string{
 type RecentCommand struct {
 	Name   string    `json:"name"`
 	UsedAt time.Time `json:"usedAt"`
+	Count  int       `json:"count,omitempty"` // uses so far; 0 in legacy data means 1
 }
 
-// Command is the API response type with builtin flag.
+// uses is Count with legacy entries counted once.
+func (rc RecentCommand) uses() int {
+	return max(rc.Count, 1)
+}
+
+// Spec describes a command an agent offers, as its CLI lists it.
+type Spec struct {
+	Name         string
+	Description  string
+	ArgumentHint string
+}
+
+// Command is the API response type with builtin flag. Description,
+// ArgumentHint and Arguments are known for commands the agent listed
+// (see List); UseCount and LastUsedAt for commands used before.
 type Command struct {
-	Name      string `json:"name"`
-	IsBuiltin bool   `json:"isBuiltin"`
+	Name         string     `json:"name"`
+	IsBuiltin    bool       `json:"isBuiltin"`
+	Description  string     `json:"description,omitempty"`
+	ArgumentHint string     `json:"argumentHint,omitempty"`
+	Arguments    []Argument `json:"arguments,omitempty"`
+	UseCount     int        `json:"useCount,omitempty"`
+	LastUsedAt   time.Time  `json:"lastUsedAt,omitzero"`
 }
 
 // Store manages slash command history.
@@ -87,9 +108,14 @@ func (s *Store) readFromDisk() ([]RecentCommand, error) {
 func deduplicateCommands(commands []RecentCommand) []RecentCommand {
 	latest := make(map[string]RecentCommand)
 	for _, cmd := range commands {
-		if existing, ok := latest[cmd.Name]; !ok || cmd.UsedAt.After(existing.UsedAt) {
-			latest[cmd.Name] = cmd
+		existing, ok := latest[cmd.Name]
+		if ok {
+			cmd.Count = existing.uses() + cmd.uses()
+			if existing.UsedAt.After(cmd.UsedAt) {
+				cmd.UsedAt = existing.UsedAt
+			}
 		}
+		latest[cmd.Name] = cmd
 	}
 
 	result := make([]RecentCommand, 0, len(latest))
@@ -107,35 +133,72 @@ func (s *Store) persist() error {
 	return os.WriteFile(s.filePath(), data, 0644)
 }
 
-// List returns commands sorted by most recently used, with unused builtins appended at the end.
-func (s *Store) List() []Command {
+// recencyHalfLife is how long it takes a use to count half as much when
+// ranking commands.
+const recencyHalfLife = 7 * 24 * time.Hour
+
+// frecency ranks a used command by how often and how recently it was used:
+// each use counts less the older the latest one is.
+func frecency(rc RecentCommand, now time.Time) float64 {
+	age := max(now.Sub(rc.UsedAt), 0)
+	return float64(rc.uses()) * math.Exp2(-float64(age)/float64(recencyHalfLife))
+}
+
+// List returns the used commands ranked by frecency, then the unused ones
+// among available (the agent's listing, in its order) and the builtins.
+// available also supplies descriptions and argument hints.
+func (s *Store) List(available ...Spec) []Command {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
+	sorted := slices.Clone(s.recent)
+	s.mu.RUnlock()
 
-	sorted := make([]RecentCommand, len(s.recent))
-	copy(sorted, s.recent)
-	sort.Slice(sorted, func(i, j int) bool {
+	now := time.Now()
+	sort.SliceStable(sorted, func(i, j int) bool {
+		si, sj := frecency(sorted[i], now), frecency(sorted[j], now)
+		if si != sj {
+			return si > sj
+		}
 		return sorted[i].UsedAt.After(sorted[j].UsedAt)
 	})
 
+	specs := make(map[string]Spec, len(available))
+	for _, spec := range available {
+		specs[spec.Name] = spec
+	}
+	describe := func(name string) Command {
+		spec := specs[name]
+		return Command{
+			Name:         name,
+			IsBuiltin:    slices.Contains(BuiltinCommands, name),
+			Description:  spec.Description,
+			ArgumentHint: spec.ArgumentHint,
+			Arguments:    ParseArgumentHint(spec.ArgumentHint),
+		}
+	}
+
 	seen := make(map[string]bool)
-	commands := make([]Command, 0, len(sorted)+len(BuiltinCommands))
+	commands := make([]Command, 0, len(sorted)+len(available)+len(BuiltinCommands))
 	for _, rc := range sorted {
 		seen[rc.Name] = true
-		commands = append(commands, Command{
-			Name:      rc.Name,
-			IsBuiltin: slices.Contains(BuiltinCommands, rc.Name),
-		})
+		cmd := describe(rc.Name)
+		cmd.UseCount = rc.uses()
+		cmd.LastUsedAt = rc.UsedAt
+		commands = append(commands, cmd)
+	}
+
+	for _, spec := range available {
+		if seen[spec.Name] {
+			continue
+		}
+		seen[spec.Name] = true
+		commands = append(commands, describe(spec.Name))
 	}
 
 	for _, name := range BuiltinCommands {
 		if seen[name] {
 			continue
 		}
-		commands = append(commands, Command{
-			Name:      name,
-			IsBuiltin: true,
-		})
+		commands = append(commands, describe(name))
 	}
 
 	return commands
@@ -161,10 +224,12 @@ func (s *Store) Use(name string) (bool, error) {
 
 	if idx >= 0 {
 		newRecent[idx].UsedAt = now
+		newRecent[idx].Count = newRecent[idx].uses() + 1
 	} else {
 		newRecent = append(newRecent, RecentCommand{
 			Name:   name,
 			UsedAt: now,
+			Count:  1,
 		})
 	}
 
@@ -290,3 +290,127 @@ func TestUse_InvalidName(t *testing.T) {
 		}
 	}
 }
+
+func TestList_RanksByFrecency(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+	data, _ := json.Marshal([]RecentCommand{
+		{Name: "stale", UsedAt: now.Add(-60 * 24 * time.Hour), Count: 50},
+		{Name: "frequent", UsedAt: now.Add(-24 * time.Hour), Count: 10},
+		{Name: "recent", UsedAt: now, Count: 1},
+	})
+	os.WriteFile(filepath.Join(dir, "commands.json"), data, 0644)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Use("frequent")
+
+	commands := store.List()
+	want := []string{"frequent", "recent", "stale"}
+	for i, name := range want {
+		if commands[i].Name != name {
+			t.Fatalf("expected %v first, got %s at index %d", want, commands[i].Name, i)
+		}
+	}
+	if commands[0].UseCount != 11 || commands[0].LastUsedAt.IsZero() {
+		t.Errorf("expected 'frequent' used 11 times with a last use, got %+v", commands[0])
+	}
+}
+
+func TestList_MergesAvailableCommands(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Use("deploy")
+
+	commands := store.List(
+		Spec{Name: "review", Description: "Review a pull request", ArgumentHint: "[pr-number]"},
+		Spec{Name: "deploy", Description: "Deploy", ArgumentHint: "<env> [tag]"},
+		Spec{Name: "fix-issue", ArgumentHint: "<issue>"},
+	)
+
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+	want := []string{"deploy", "review", "fix-issue", "compact", "context", "cost", "init"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+
+	deploy := commands[0]
+	if deploy.Description != "Deploy" || deploy.UseCount != 1 || deploy.IsBuiltin {
+		t.Errorf("unexpected deploy entry: %+v", deploy)
+	}
+	if fmt.Sprint(deploy.Arguments) != fmt.Sprint([]Argument{{Name: "env", Required: true}, {Name: "tag"}}) {
+		t.Errorf("unexpected deploy arguments: %+v", deploy.Arguments)
+	}
+	if review := commands[1]; !review.IsBuiltin || review.ArgumentHint != "[pr-number]" || review.UseCount != 0 {
+		t.Errorf("unexpected review entry: %+v", review)
+	}
+}
+
+func TestNewStore_LegacyEntriesCountOnce(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "commands.json"), []byte(`[
+		{"name": "help", "usedAt": "2024-01-01T00:00:00Z"},
+		{"name": "help", "usedAt": "2024-01-03T00:00:00Z"}
+	]`), 0644)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Use("help")
+
+	if cmd := store.List()[0]; cmd.Name != "help" || cmd.UseCount != 3 {
+		t.Errorf("expected 'help' used 3 times, got %+v", cmd)
+	}
+}
+
+func TestParseArgumentHint(t *testing.T) {
+	tests := []struct {
+		hint string
+		want []Argument
+	}{
+		{"", nil},
+		{"free text", nil},
+		{"<file>", []Argument{{Name: "file", Required: true}}},
+		{"<file> [message]", []Argument{{Name: "file", Required: true}, {Name: "message"}}},
+		{"add [tag] | remove [tag]", []Argument{{Name: "tag"}}},
+	}
+	for _, tt := range tests {
+		if got := ParseArgumentHint(tt.hint); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ParseArgumentHint(%q) = %+v, want %+v", tt.hint, got, tt.want)
+		}
+	}
+}
+
+func TestCatalog_CachesSuccessfulListings(t *testing.T) {
+	c := NewCatalog(time.Hour)
+	calls := 0
+	list := func() ([]Spec, error) {
+		calls++
+		if calls == 1 {
+			return nil, fmt.Errorf("claude not installed")
+		}
+		return []Spec{{Name: "review"}}, nil
+	}
+
+	if _, err := c.Get("claude", list); err == nil {
+		t.Fatal("expected the listing error")
+	}
+	for range 2 {
+		specs, err := c.Get("claude", list)
+		if err != nil || len(specs) != 1 {
+			t.Fatalf("Get = %v, %v", specs, err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 listings (failure not cached), got %d", calls)
+	}
+}
@@ -2,20 +2,31 @@
 package contents
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 )
 
 var (
 	ErrNotFound    = errors.New("not found")
 	ErrInvalidPath = errors.New("invalid path")
+	ErrConflict    = errors.New("file changed since it was read")
 )
 
+// writeMu queues writes made through this package, so a base-hash check
+// and the write that follows it cannot interleave with another client's.
+// Agents write directly and are caught by the check instead.
+var writeMu sync.Mutex
+
 // ValidatePath checks if path is safe and within workDir.
 // Returns ErrInvalidPath for path traversal attempts or absolute paths.
 func ValidatePath(workDir, path string) error {
@@ -62,6 +73,38 @@ type FileContent struct {
 	Path     string    `json:"path"`
 	Content  string    `json:"content"`
 	Encoding Encoding  `json:"encoding"`
+	Size     int64     `json:"size"`
+	MimeType string    `json:"mime_type"`
+	// Preview is set for PNG, JPEG and GIF images.
+	Preview *Preview `json:"preview,omitempty"`
+	// Unsupported marks binary content the file browser cannot preview;
+	// Content still holds it, base64-encoded.
+	Unsupported bool `json:"unsupported,omitempty"`
+	// Hash identifies the content read; pass it back as the base hash when
+	// writing to detect edits made in between.
+	Hash string `json:"hash"`
+}
+
+// HashContent returns the hash FileContent.Hash uses for content.
+func HashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ConflictError reports that a file no longer matches the base hash a write
+// was made against.
+type ConflictError struct {
+	Path string
+	// Current is the file as it is now, or nil if it has been deleted.
+	Current *FileContent
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrConflict, e.Path)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
 }
 
 // ContentsResult holds the result of GetContents.
@@ -78,7 +121,8 @@ func (r ContentsResult) IsDir() bool {
 
 // GetContents returns directory entries or file content.
 // Returns ErrNotFound if path doesn't exist, ErrInvalidPath for path traversal attempts.
-func GetContents(workDir, path string) (ContentsResult, error) {
+// A directory is read in batches, so cancelling ctx stops a huge listing.
+func GetContents(ctx context.Context, workDir, path string) (ContentsResult, error) {
 	if err := ValidatePath(workDir, path); err != nil {
 		return ContentsResult{}, err
 	}
@@ -93,7 +137,7 @@ func GetContents(workDir, path string) (ContentsResult, error) {
 	}
 
 	if info.IsDir() {
-		entries, err := listDir(path, fullPath)
+		entries, err := listDir(ctx, path, fullPath)
 		if err != nil {
 			return ContentsResult{}, err
 		}
@@ -107,11 +151,30 @@ func GetContents(workDir, path string) (ContentsResult, error) {
 	return ContentsResult{File: file}, nil
 }
 
-func listDir(relPath, fullPath string) ([]Entry, error) {
-	dirEntries, err := os.ReadDir(fullPath)
+// listDirBatch is how many entries listDir reads between checks of ctx.
+const listDirBatch = 1000
+
+func listDir(ctx context.Context, relPath, fullPath string) ([]Entry, error) {
+	dir, err := os.Open(fullPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
+	defer dir.Close()
+
+	var dirEntries []os.DirEntry
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		batch, err := dir.ReadDir(listDirBatch)
+		dirEntries = append(dirEntries, batch...)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+	}
 
 	entries := make([]Entry, 0, len(dirEntries))
 	for _, de := range dirEntries {
@@ -149,21 +212,27 @@ func readFile(relPath, fullPath string, info os.FileInfo) (*FileContent, error)
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	encoding := EncodingText
-	contentStr := string(content)
-
-	if isBinary(content) {
-		encoding = EncodingBase64
-		contentStr = base64.StdEncoding.EncodeToString(content)
-	}
-
-	return &FileContent{
+	file := &FileContent{
 		Name:     info.Name(),
 		Type:     TypeFile,
 		Path:     relPath,
-		Content:  contentStr,
-		Encoding: encoding,
-	}, nil
+		Content:  string(content),
+		Encoding: EncodingText,
+		Hash:     HashContent(content),
+		Size:     int64(len(content)),
+		MimeType: mimeType(info.Name(), content),
+	}
+
+	if isBinary(content) || strings.HasPrefix(file.MimeType, "image/") && file.MimeType != "image/svg+xml" {
+		file.Encoding = EncodingBase64
+		file.Content = base64.StdEncoding.EncodeToString(content)
+	}
+	if strings.HasPrefix(file.MimeType, "image/") {
+		file.Preview, _ = buildPreview(content)
+	}
+	file.Unsupported = file.Encoding == EncodingBase64 && file.Preview == nil
+
+	return file, nil
 }
 
 // isBinary detects binary content by checking for null bytes in the first 512 bytes.
@@ -181,6 +250,13 @@ func isBinary(content []byte) bool {
 // Creates the file and parent directories if they don't exist.
 // Returns ErrInvalidPath for path traversal attempts, absolute paths, or empty paths.
 func WriteFile(workDir, path, content string) error {
+	return WriteFileIfUnchanged(workDir, path, content, "")
+}
+
+// WriteFileIfUnchanged is WriteFile that first checks the file still hashes
+// to baseHash, returning a *ConflictError if it doesn't. An empty baseHash
+// writes unconditionally.
+func WriteFileIfUnchanged(workDir, path, content, baseHash string) error {
 	if path == "" {
 		return fmt.Errorf("%w: empty path", ErrInvalidPath)
 	}
@@ -191,6 +267,15 @@ func WriteFile(workDir, path, content string) error {
 
 	fullPath := filepath.Join(workDir, path)
 
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if baseHash != "" {
+		if err := checkUnchanged(path, fullPath, baseHash); err != nil {
+			return err
+		}
+	}
+
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create parent directories: %w", err)
@@ -199,6 +284,27 @@ func WriteFile(workDir, path, content string) error {
 	return os.WriteFile(fullPath, []byte(content), 0644)
 }
 
+func checkUnchanged(relPath, fullPath, baseHash string) error {
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return &ConflictError{Path: relPath}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%w: %s is a directory", ErrInvalidPath, relPath)
+	}
+	current, err := readFile(relPath, fullPath, info)
+	if err != nil {
+		return err
+	}
+	if current.Hash != baseHash {
+		return &ConflictError{Path: relPath, Current: current}
+	}
+	return nil
+}
+
 // Delete removes a file or directory within workDir.
 // For directories, it recursively removes all contents.
 // Returns ErrInvalidPath for path traversal attempts, absolute paths, or empty paths.
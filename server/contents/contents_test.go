@@ -1,6 +1,8 @@
 package contents
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -105,6 +107,56 @@ func TestWriteFile(t *testing.T) {
 	})
 }
 
+func TestWriteFileIfUnchanged(t *testing.T) {
+	workDir := t.TempDir()
+	path := "a.txt"
+	if err := os.WriteFile(filepath.Join(workDir, path), []byte("v1"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	result, err := GetContents(context.Background(), workDir, path)
+	if err != nil {
+		t.Fatalf("GetContents failed: %v", err)
+	}
+	base := result.File.Hash
+	if base != HashContent([]byte("v1")) {
+		t.Fatalf("Hash = %q, want hash of content", base)
+	}
+
+	t.Run("writes when unchanged", func(t *testing.T) {
+		if err := WriteFileIfUnchanged(workDir, path, "v2", base); err != nil {
+			t.Fatalf("WriteFileIfUnchanged failed: %v", err)
+		}
+	})
+
+	t.Run("conflicts after another write", func(t *testing.T) {
+		err := WriteFileIfUnchanged(workDir, path, "v3", base)
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) {
+			t.Fatalf("got %v, want ConflictError", err)
+		}
+		if conflict.Current == nil || conflict.Current.Content != "v2" || conflict.Current.Hash != HashContent([]byte("v2")) {
+			t.Errorf("Current = %+v, want latest content", conflict.Current)
+		}
+		data, _ := os.ReadFile(filepath.Join(workDir, path))
+		if string(data) != "v2" {
+			t.Errorf("file overwritten on conflict: %q", data)
+		}
+	})
+
+	t.Run("conflicts when deleted", func(t *testing.T) {
+		var conflict *ConflictError
+		if err := WriteFileIfUnchanged(workDir, "gone.txt", "x", base); !errors.As(err, &conflict) || conflict.Current != nil {
+			t.Errorf("got %v, want ConflictError without content", err)
+		}
+	})
+
+	t.Run("empty base hash writes unconditionally", func(t *testing.T) {
+		if err := WriteFileIfUnchanged(workDir, path, "v4", ""); err != nil {
+			t.Fatalf("WriteFileIfUnchanged failed: %v", err)
+		}
+	})
+}
+
 func TestDeleteFile(t *testing.T) {
 	t.Run("deletes existing file", func(t *testing.T) {
 		workDir := t.TempDir()
@@ -293,3 +345,16 @@ func TestDelete(t *testing.T) {
 		}
 	})
 }
+
+func TestGetContents_CancelledListing(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := GetContents(ctx, workDir, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
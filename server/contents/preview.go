@@ -0,0 +1,111 @@
+package contents
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	_ "image/gif" // register decoders for previews
+	_ "image/jpeg"
+	"image/png"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ThumbnailSize bounds the longer side of a preview thumbnail.
+	ThumbnailSize = 256
+	// maxPreviewPixels keeps a huge (or maliciously declared) image from
+	// being decoded just to build a thumbnail.
+	maxPreviewPixels = 40_000_000
+)
+
+// Preview describes an image file: its dimensions and a downscaled PNG
+// thumbnail, base64-encoded.
+type Preview struct {
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	Thumbnail       string `json:"thumbnail,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+}
+
+// mimeType detects content's MIME type from its bytes, falling back to the
+// file extension when sniffing finds nothing more specific (e.g. SVG).
+func mimeType(name string, content []byte) string {
+	sniffed := http.DetectContentType(content)
+	if sniffed != "application/octet-stream" && !strings.HasPrefix(sniffed, "text/plain") {
+		return sniffed
+	}
+	if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+		return byExt
+	}
+	return sniffed
+}
+
+// buildPreview decodes a PNG, JPEG or GIF image and returns its preview.
+// ok is false for anything else, including images too large to decode. An
+// image whose header decodes but whose data does not still gets its
+// dimensions.
+func buildPreview(content []byte) (*Preview, bool) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, false
+	}
+	p := &Preview{Width: cfg.Width, Height: cfg.Height}
+	if cfg.Width*cfg.Height > maxPreviewPixels {
+		return p, true
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return p, true
+	}
+	thumb := downscale(img, ThumbnailSize)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumb); err != nil {
+		return p, true
+	}
+	p.Thumbnail = base64.StdEncoding.EncodeToString(buf.Bytes())
+	p.ThumbnailWidth = thumb.Bounds().Dx()
+	p.ThumbnailHeight = thumb.Bounds().Dy()
+	return p, true
+}
+
+// downscale shrinks img so its longer side is at most size, averaging the
+// source pixels that fall into each thumbnail pixel. Smaller images are
+// copied at their own size.
+func downscale(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := sw, sh
+	if sw > size || sh > size {
+		if sw >= sh {
+			dw, dh = size, max(1, sh*size/sw)
+		} else {
+			dw, dh = max(1, sw*size/sh), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := b.Min.Y+y*sh/dh, b.Min.Y+max((y+1)*sh/dh, y*sh/dh+1)
+		for x := range dw {
+			x0, x1 := b.Min.X+x*sw/dw, b.Min.X+max((x+1)*sw/dw, x*sw/dw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,103 @@
+package contents
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetContents_ImagePreview(t *testing.T) {
+	workDir := t.TempDir()
+	img := image.NewNRGBA(image.Rect(0, 0, 600, 300))
+	for y := range 300 {
+		for x := range 600 {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "shot.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := GetContents(context.Background(), workDir, "shot.png")
+	if err != nil {
+		t.Fatalf("GetContents failed: %v", err)
+	}
+	f := result.File
+	if f.MimeType != "image/png" || f.Encoding != EncodingBase64 || f.Unsupported || f.Size != int64(buf.Len()) {
+		t.Fatalf("file = {mime %q, encoding %q, unsupported %v, size %d}", f.MimeType, f.Encoding, f.Unsupported, f.Size)
+	}
+	p := f.Preview
+	if p == nil || p.Width != 600 || p.Height != 300 || p.ThumbnailWidth != ThumbnailSize || p.ThumbnailHeight != ThumbnailSize/2 {
+		t.Fatalf("preview = %+v, want 600x300 with a 256x128 thumbnail", p)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(p.Thumbnail)
+	if err != nil {
+		t.Fatalf("thumbnail is not base64: %v", err)
+	}
+	thumb, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("thumbnail is not a PNG: %v", err)
+	}
+	if r, g, _, a := thumb.At(10, 10).RGBA(); r>>8 != 255 || g != 0 || a>>8 != 255 {
+		t.Errorf("thumbnail pixel = %v, want opaque red", thumb.At(10, 10))
+	}
+}
+
+func TestGetContents_BinaryAndText(t *testing.T) {
+	workDir := t.TempDir()
+	files := map[string][]byte{
+		"blob.bin":   {0x7f, 'E', 'L', 'F', 0, 0, 1, 2},
+		"notes.txt":  []byte("hello"),
+		"broken.png": append([]byte("\x89PNG\r\n\x1a\n"), 0, 0, 0),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(workDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path        string
+		encoding    Encoding
+		unsupported bool
+	}{
+		{"blob.bin", EncodingBase64, true},
+		{"notes.txt", EncodingText, false},
+		{"broken.png", EncodingBase64, true},
+	}
+	for _, tt := range tests {
+		result, err := GetContents(context.Background(), workDir, tt.path)
+		if err != nil {
+			t.Fatalf("GetContents(%s) failed: %v", tt.path, err)
+		}
+		f := result.File
+		if f.Encoding != tt.encoding || f.Unsupported != tt.unsupported || f.Preview != nil {
+			t.Errorf("%s: encoding %q, unsupported %v, preview %+v; want %q, %v, none",
+				tt.path, f.Encoding, f.Unsupported, f.Preview, tt.encoding, tt.unsupported)
+		}
+		if f.Size != int64(len(files[tt.path])) {
+			t.Errorf("%s: size = %d, want %d", tt.path, f.Size, len(files[tt.path]))
+		}
+	}
+}
+
+func TestDownscale_KeepsSmallImages(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 10, 400))
+	if got := downscale(img, ThumbnailSize).Bounds(); got.Dx() != 6 || got.Dy() != ThumbnailSize {
+		t.Errorf("tall image thumbnail = %v, want 6x256", got)
+	}
+	if got := downscale(img.SubImage(image.Rect(0, 0, 10, 20)), ThumbnailSize).Bounds(); got.Dx() != 10 || got.Dy() != 20 {
+		t.Errorf("small image thumbnail = %v, want 10x20", got)
+	}
+}
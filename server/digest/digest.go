@@ -0,0 +1,205 @@
+// Package digest builds a periodic summary of agent activity per worktree
+// (works closed, sessions run, permissions granted) and delivers it to a
+// configured webhook on a daily schedule.
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/work"
+	"github.com/pockode/server/worktree"
+)
+
+// Digest summarizes activity between Since and Until.
+type Digest struct {
+	Since     time.Time        `json:"since"`
+	Until     time.Time        `json:"until"`
+	Worktrees []WorktreeDigest `json:"worktrees"`
+}
+
+// WorktreeDigest is the activity of one worktree. The main worktree has an
+// empty Name.
+type WorktreeDigest struct {
+	Name        string   `json:"name"`
+	WorksClosed []string `json:"works_closed"` // titles
+	SessionsRun int      `json:"sessions_run"`
+	// PermissionsGranted counts allow/always_allow responses in sessions
+	// active during the period. History records carry no timestamps, so a
+	// session spanning the boundary contributes all of its responses.
+	PermissionsGranted int `json:"permissions_granted"`
+	// CostUSD is omitted because no agent backend reports cost yet.
+	CostUSD *float64 `json:"cost_usd,omitempty"`
+}
+
+// IsEmpty reports whether nothing happened during the period.
+func (d Digest) IsEmpty() bool {
+	for _, wt := range d.Worktrees {
+		if len(wt.WorksClosed) > 0 || wt.SessionsRun > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SessionSource yields the session store of every worktree.
+type SessionSource interface {
+	Names() []string
+	// Acquire returns the worktree's session store and a release func.
+	Acquire(name string) (session.Store, func(), error)
+}
+
+type worktreeSource struct {
+	manager *worktree.Manager
+}
+
+// NewWorktreeSource adapts a worktree.Manager into a SessionSource.
+func NewWorktreeSource(m *worktree.Manager) SessionSource {
+	return &worktreeSource{manager: m}
+}
+
+func (s *worktreeSource) Names() []string {
+	infos := s.manager.Registry().List()
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+	return names
+}
+
+// Acquire reads the session store without starting the worktree, so a
+// digest does not spin up watchers and processes for idle worktrees.
+func (s *worktreeSource) Acquire(name string) (session.Store, func(), error) {
+	store, err := s.manager.SessionStore(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, func() {}, nil
+}
+
+// Generator builds digests from the work store and per-worktree sessions.
+type Generator struct {
+	sessions    SessionSource
+	workStore   work.Store
+	watchedOnly func() bool
+}
+
+func NewGenerator(sessions SessionSource, workStore work.Store) *Generator {
+	return &Generator{sessions: sessions, workStore: workStore}
+}
+
+// SetWatchedOnly sets a provider of whether only watched works are listed
+// (see work.NotifyFilter). Muted works never are.
+func (g *Generator) SetWatchedOnly(fn func() bool) {
+	g.watchedOnly = fn
+}
+
+// Generate summarizes activity in [since, until).
+func (g *Generator) Generate(ctx context.Context, since, until time.Time) (Digest, error) {
+	all, err := g.workStore.List()
+	if err != nil {
+		return Digest{}, fmt.Errorf("list works: %w", err)
+	}
+	filter := work.NewNotifyFilter(all, g.watchedOnly != nil && g.watchedOnly())
+	works := make([]work.Work, 0, len(all))
+	for _, w := range all {
+		if filter.Allows(w) {
+			works = append(works, w)
+		}
+	}
+
+	names := g.sessions.Names()
+	sort.Strings(names)
+
+	d := Digest{Since: since, Until: until, Worktrees: make([]WorktreeDigest, 0, len(names))}
+	// Works live in a global store; attribute each closed work to the
+	// worktree that owns its session, falling back to main.
+	claimed := make(map[string]bool)
+	for _, name := range names {
+		wd, sessionIDs, err := g.worktreeDigest(ctx, name, since, until)
+		if err != nil {
+			return Digest{}, err
+		}
+		for _, w := range works {
+			if isClosedIn(w, since, until) && sessionIDs[w.SessionID] {
+				wd.WorksClosed = append(wd.WorksClosed, w.Title)
+				claimed[w.ID] = true
+			}
+		}
+		d.Worktrees = append(d.Worktrees, wd)
+	}
+
+	for _, w := range works {
+		if !isClosedIn(w, since, until) || claimed[w.ID] {
+			continue
+		}
+		for i := range d.Worktrees {
+			if d.Worktrees[i].Name == "" {
+				d.Worktrees[i].WorksClosed = append(d.Worktrees[i].WorksClosed, w.Title)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func isClosedIn(w work.Work, since, until time.Time) bool {
+	return w.Status == work.StatusClosed && !w.UpdatedAt.Before(since) && w.UpdatedAt.Before(until)
+}
+
+// worktreeDigest returns the session counts of one worktree plus the set of
+// all its session IDs (for work attribution).
+func (g *Generator) worktreeDigest(ctx context.Context, name string, since, until time.Time) (WorktreeDigest, map[string]bool, error) {
+	wd := WorktreeDigest{Name: name, WorksClosed: []string{}}
+
+	store, release, err := g.sessions.Acquire(name)
+	if err != nil {
+		return wd, nil, fmt.Errorf("acquire worktree %q: %w", name, err)
+	}
+	defer release()
+
+	metas, err := store.List()
+	if err != nil {
+		return wd, nil, fmt.Errorf("list sessions of %q: %w", name, err)
+	}
+
+	ids := make(map[string]bool, len(metas))
+	for _, m := range metas {
+		ids[m.ID] = true
+		if !m.Activated || m.UpdatedAt.Before(since) || !m.UpdatedAt.Before(until) {
+			continue
+		}
+		wd.SessionsRun++
+
+		granted, err := countGranted(ctx, store, m.ID)
+		if err != nil {
+			return wd, nil, err
+		}
+		wd.PermissionsGranted += granted
+	}
+	return wd, ids, nil
+}
+
+func countGranted(ctx context.Context, store session.Store, sessionID string) (int, error) {
+	records, err := store.GetHistory(ctx, sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("read history of %s: %w", sessionID, err)
+	}
+
+	n := 0
+	for _, raw := range records {
+		var rec agent.EventRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			continue // history may contain records written by older versions
+		}
+		if rec.Type == agent.EventTypePermissionResponse && (rec.Choice == "allow" || rec.Choice == "always_allow") {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,159 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/work"
+)
+
+type fakeSource struct {
+	stores map[string]session.Store
+}
+
+func (f *fakeSource) Names() []string {
+	names := make([]string, 0, len(f.stores))
+	for n := range f.stores {
+		names = append(names, n)
+	}
+	return names
+}
+
+func (f *fakeSource) Acquire(name string) (session.Store, func(), error) {
+	return f.stores[name], func() {}, nil
+}
+
+func newSessionStore(t *testing.T) *session.FileStore {
+	t.Helper()
+	s, err := session.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	return s
+}
+
+func TestGenerate_SummarizesPerWorktree(t *testing.T) {
+	ctx := context.Background()
+	mainSessions := newSessionStore(t)
+	featSessions := newSessionStore(t)
+
+	if _, err := featSessions.Create(ctx, "sess-feat", session.AgentTypeClaude, session.ModeDefault); err != nil {
+		t.Fatal(err)
+	}
+	if err := featSessions.Activate(ctx, "sess-feat"); err != nil {
+		t.Fatal(err)
+	}
+	for _, choice := range []string{"allow", "deny", "always_allow"} {
+		if err := featSessions.AppendToHistory(ctx, "sess-feat", agent.PermissionResponseEvent{RequestID: "r", Choice: choice}.ToRecord()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Never activated: created but no message sent, so not a run.
+	if _, err := mainSessions.Create(ctx, "sess-idle", session.AgentTypeClaude, session.ModeDefault); err != nil {
+		t.Fatal(err)
+	}
+
+	works, err := work.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	story, err := works.Create(ctx, work.Work{Type: work.WorkTypeStory, Title: "Ship feature", AgentRoleID: "role"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := works.Start(ctx, story.ID, "sess-feat"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := works.StepDone(ctx, story.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := works.Create(ctx, work.Work{Type: work.WorkTypeStory, Title: "Orphan", AgentRoleID: "role"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := works.Start(ctx, orphan.ID, "sess-gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := works.StepDone(ctx, orphan.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGenerator(&fakeSource{stores: map[string]session.Store{"": mainSessions, "feat": featSessions}}, works)
+	now := time.Now()
+	d, err := g.Generate(ctx, now.Add(-time.Hour), now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	if len(d.Worktrees) != 2 || d.Worktrees[0].Name != "" || d.Worktrees[1].Name != "feat" {
+		t.Fatalf("expected [main, feat], got %+v", d.Worktrees)
+	}
+	main, feat := d.Worktrees[0], d.Worktrees[1]
+	if main.SessionsRun != 0 || len(main.WorksClosed) != 1 || main.WorksClosed[0] != "Orphan" {
+		t.Errorf("unexpected main digest %+v", main)
+	}
+	if feat.SessionsRun != 1 || feat.PermissionsGranted != 2 || len(feat.WorksClosed) != 1 || feat.WorksClosed[0] != "Ship feature" {
+		t.Errorf("unexpected feat digest %+v", feat)
+	}
+	if d.IsEmpty() {
+		t.Error("expected non-empty digest")
+	}
+
+	old, err := g.Generate(ctx, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !old.IsEmpty() {
+		t.Errorf("expected empty digest outside the window, got %+v", old)
+	}
+
+	// Muted works are left out; with watched-only, so is everything unwatched.
+	if _, err := works.SetNotify(ctx, orphan.ID, work.NotifyMute); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := g.Generate(ctx, now.Add(-time.Hour), now.Add(time.Minute)); len(d.Worktrees[0].WorksClosed) != 0 {
+		t.Errorf("main works closed = %v, want the muted work left out", d.Worktrees[0].WorksClosed)
+	}
+	g.SetWatchedOnly(func() bool { return true })
+	if d, _ := g.Generate(ctx, now.Add(-time.Hour), now.Add(time.Minute)); len(d.Worktrees[1].WorksClosed) != 0 {
+		t.Errorf("feat works closed = %v, want none watched", d.Worktrees[1].WorksClosed)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var got Digest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected JSON content type, got %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	want := Digest{Worktrees: []WorktreeDigest{{Name: "feat", SessionsRun: 3}}}
+	if err := Deliver(context.Background(), srv.Client(), srv.URL, want); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if len(got.Worktrees) != 1 || got.Worktrees[0].SessionsRun != 3 {
+		t.Errorf("unexpected payload %+v", got)
+	}
+}
+
+func TestDeliver_NonSuccessStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if err := Deliver(context.Background(), srv.Client(), srv.URL, Digest{}); err == nil {
+		t.Error("expected error for 502 response")
+	}
+}
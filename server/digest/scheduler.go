@@ -0,0 +1,123 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/settings"
+)
+
+const (
+	checkInterval  = time.Minute
+	deliverTimeout = 10 * time.Second
+	period         = 24 * time.Hour
+)
+
+// Scheduler delivers a digest of the previous 24 hours to the configured
+// webhook once a day at settings.DigestHour (server local time). Settings
+// are re-read on every check so changes apply without a restart.
+type Scheduler struct {
+	generator *Generator
+	settings  *settings.Store
+	client    *http.Client
+
+	lastSentMu sync.Mutex
+	lastSent   time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewScheduler(generator *Generator, settingsStore *settings.Store) *Scheduler {
+	return &Scheduler{
+		generator: generator,
+		settings:  settingsStore,
+		client:    &http.Client{Timeout: deliverTimeout},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Start() {
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				s.tick(context.Background(), now)
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// tick sends the digest if the configured hour has arrived and today's
+// digest has not gone out yet.
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	cfg := s.settings.Get()
+	if cfg.DigestWebhookURL == "" || now.Hour() != cfg.DigestHour {
+		return
+	}
+
+	s.lastSentMu.Lock()
+	y, m, d := now.Date()
+	ly, lm, ld := s.lastSent.Date()
+	if y == ly && m == lm && d == ld {
+		s.lastSentMu.Unlock()
+		return
+	}
+	// Mark before delivering so a slow or failing webhook is not retried
+	// every minute for the rest of the hour.
+	s.lastSent = now
+	s.lastSentMu.Unlock()
+
+	digest, err := s.generator.Generate(ctx, now.Add(-period), now)
+	if err != nil {
+		slog.Error("failed to generate digest", "error", err)
+		return
+	}
+	if err := Deliver(ctx, s.client, cfg.DigestWebhookURL, digest); err != nil {
+		slog.Error("failed to deliver digest", "error", err)
+		return
+	}
+	slog.Info("digest delivered", "worktrees", len(digest.Worktrees))
+}
+
+// Deliver POSTs the digest as JSON to url.
+func Deliver(ctx context.Context, client *http.Client, url string, d Digest) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("marshal digest: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
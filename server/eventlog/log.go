@@ -0,0 +1,301 @@
+// Package eventlog persists the change events of the work, session and
+// settings stores with monotonically increasing sequence numbers, so a
+// client that was offline can fetch exactly what it missed (events.since)
+// instead of re-downloading full lists.
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/settings"
+	"github.com/pockode/server/work"
+)
+
+const (
+	// DefaultRetain is how many events are kept. Older ones are dropped;
+	// a client further behind gets Reset and reloads its lists.
+	DefaultRetain = 5000
+
+	// compactSlack is how far past the retention limit the file may grow
+	// before it is rewritten, so appends rarely pay for a rewrite.
+	compactSlack = 1000
+
+	DefaultSinceLimit = 500
+	MaxSinceLimit     = 1000
+
+	logFilename = "events.jsonl"
+)
+
+// Source names the store an event came from.
+type Source string
+
+const (
+	SourceWork     Source = "work"
+	SourceSession  Source = "session"
+	SourceSettings Source = "settings"
+)
+
+// Event is one persisted change. Data is the changed object as the
+// corresponding list subscription sends it (for delete, only its ID).
+type Event struct {
+	Seq      int64           `json:"seq"`
+	Time     time.Time       `json:"time"`
+	Source   Source          `json:"source"`
+	Op       string          `json:"op"`                 // create, update, delete
+	Worktree string          `json:"worktree,omitempty"` // session events; "" is main
+	ID       string          `json:"id,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// SinceResult is the answer to Since.
+type SinceResult struct {
+	Events []Event `json:"events"`
+	// LatestSeq is the newest sequence number; pass it to the next Since
+	// once HasMore is false.
+	LatestSeq int64 `json:"latest_seq"`
+	HasMore   bool  `json:"has_more"` // more events after the last one returned
+	// Reset means the requested position is no longer (or never was) in the
+	// log: reload full lists and continue from LatestSeq.
+	Reset bool `json:"reset"`
+}
+
+// Log is an append-only, size-bounded event log backed by a JSON Lines file.
+type Log struct {
+	path   string
+	retain int
+
+	eventsMu sync.Mutex
+	events   []Event // oldest first
+	lastSeq  int64
+	file     *os.File
+}
+
+// Open loads the log in dataDir, keeping the newest retain events (0 =
+// DefaultRetain). Unparseable lines (a torn final write) are dropped.
+func Open(dataDir string, retain int) (*Log, error) {
+	if retain <= 0 {
+		retain = DefaultRetain
+	}
+	l := &Log{path: filepath.Join(dataDir, logFilename), retain: retain}
+
+	skipped, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	// Rewriting also drops a torn last line, which the next append would
+	// otherwise run into.
+	if len(l.events) > l.retain || skipped > 0 {
+		l.events = l.events[max(0, len(l.events)-l.retain):]
+		if err := l.rewriteLocked(); err != nil {
+			return nil, err
+		}
+	}
+	if l.file == nil {
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("open event log: %w", err)
+		}
+		l.file = f
+	}
+	return l, nil
+}
+
+func (l *Log) load() (skipped int, err error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open event log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Seq <= l.lastSeq {
+			skipped++
+			continue
+		}
+		// Since indexes by seq, so a gap left by a dropped line cuts off
+		// everything before it; clients there get Reset.
+		if e.Seq != l.lastSeq+1 {
+			l.events = nil
+		}
+		l.events = append(l.events, e)
+		l.lastSeq = e.Seq
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("read event log: %w", err)
+	}
+	if skipped > 0 {
+		slog.Warn("event log: skipped unreadable entries", "count", skipped)
+	}
+	return skipped, nil
+}
+
+// Close closes the log file. Appends after Close are dropped.
+func (l *Log) Close() error {
+	l.eventsMu.Lock()
+	defer l.eventsMu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// LatestSeq returns the newest sequence number, 0 when empty.
+func (l *Log) LatestSeq() int64 {
+	l.eventsMu.Lock()
+	defer l.eventsMu.Unlock()
+	return l.lastSeq
+}
+
+// Append records an event and returns its sequence number. A write error
+// is logged, not returned: the event stays in memory, and the listeners
+// calling Append have no way to handle it.
+func (l *Log) Append(source Source, op, worktree, id string, data any) int64 {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("event log: failed to encode event", "source", source, "id", id, "error", err)
+		raw = nil
+	}
+
+	l.eventsMu.Lock()
+	defer l.eventsMu.Unlock()
+
+	l.lastSeq++
+	e := Event{Seq: l.lastSeq, Time: time.Now(), Source: source, Op: op, Worktree: worktree, ID: id, Data: raw}
+	l.events = append(l.events, e)
+
+	if len(l.events) > l.retain+compactSlack {
+		l.events = append([]Event(nil), l.events[len(l.events)-l.retain:]...)
+		if err := l.rewriteLocked(); err != nil {
+			slog.Error("event log: failed to compact", "error", err)
+		}
+		return e.Seq
+	}
+	if l.file != nil {
+		line, err := json.Marshal(e)
+		if err == nil {
+			_, err = l.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			slog.Error("event log: failed to append", "seq", e.Seq, "error", err)
+		}
+	}
+	return e.Seq
+}
+
+// rewriteLocked replaces the file with the in-memory events. Requires
+// eventsMu (or exclusive access during Open).
+func (l *Log) rewriteLocked() error {
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range l.events {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+
+	if l.file != nil {
+		if err := l.file.Close(); err != nil {
+			slog.Warn("event log: failed to close replaced file", "error", err)
+		}
+	}
+	l.file, err = os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+// Since returns up to limit events after seq (0 = DefaultSinceLimit,
+// capped at MaxSinceLimit).
+func (l *Log) Since(seq int64, limit int) SinceResult {
+	if limit <= 0 {
+		limit = DefaultSinceLimit
+	}
+	limit = min(limit, MaxSinceLimit)
+
+	l.eventsMu.Lock()
+	defer l.eventsMu.Unlock()
+
+	result := SinceResult{Events: []Event{}, LatestSeq: l.lastSeq}
+	oldest := l.lastSeq + 1
+	if len(l.events) > 0 {
+		oldest = l.events[0].Seq
+	}
+	// Behind the retained window, or ahead of a log that was lost.
+	if seq < oldest-1 || seq > l.lastSeq {
+		result.Reset = true
+		return result
+	}
+
+	start := int(seq - oldest + 1)
+	end := min(start+limit, len(l.events))
+	result.Events = append(result.Events, l.events[start:end]...)
+	result.HasMore = end < len(l.events)
+	return result
+}
+
+// OnWorkChange records work store changes.
+func (l *Log) OnWorkChange(e work.ChangeEvent) {
+	if e.Op == work.OperationDelete {
+		l.Append(SourceWork, string(e.Op), "", e.Work.ID, struct {
+			ID string `json:"id"`
+		}{e.Work.ID})
+		return
+	}
+	l.Append(SourceWork, string(e.Op), "", e.Work.ID, e.Work)
+}
+
+// OnSettingsChange records settings updates.
+func (l *Log) OnSettingsChange(s settings.Settings) {
+	l.Append(SourceSettings, "update", "", "", s)
+}
+
+// SessionListener returns a listener recording one worktree's session
+// store changes.
+func (l *Log) SessionListener(worktree string) session.OnChangeListener {
+	return sessionListener{log: l, worktree: worktree}
+}
+
+type sessionListener struct {
+	log      *Log
+	worktree string
+}
+
+func (s sessionListener) OnSessionChange(e session.SessionChangeEvent) {
+	if e.Op == session.OperationDelete {
+		s.log.Append(SourceSession, string(e.Op), s.worktree, e.Session.ID, struct {
+			ID string `json:"id"`
+		}{e.Session.ID})
+		return
+	}
+	s.log.Append(SourceSession, string(e.Op), s.worktree, e.Session.ID, e.Session)
+}
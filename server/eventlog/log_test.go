@@ -0,0 +1,104 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/settings"
+	"github.com/pockode/server/work"
+)
+
+func openLog(t *testing.T, dir string, retain int) *Log {
+	t.Helper()
+	l, err := Open(dir, retain)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestLog_RecordsStoreChanges(t *testing.T) {
+	l := openLog(t, t.TempDir(), 0)
+
+	l.OnWorkChange(work.ChangeEvent{Op: work.OperationCreate, Work: work.Work{ID: "w1", Title: "Build"}})
+	l.SessionListener("feature").OnSessionChange(session.SessionChangeEvent{Op: session.OperationDelete, Session: session.SessionMeta{ID: "s1"}})
+	l.OnSettingsChange(settings.Settings{DefaultMode: session.ModePlan})
+
+	got := l.Since(0, 0)
+	if got.Reset || got.HasMore || got.LatestSeq != 3 || len(got.Events) != 3 {
+		t.Fatalf("Since(0) = %+v, want 3 events", got)
+	}
+	if e := got.Events[0]; e.Seq != 1 || e.Source != SourceWork || e.Op != "create" || e.ID != "w1" {
+		t.Errorf("work event = %+v", e)
+	}
+	var w work.Work
+	if err := json.Unmarshal(got.Events[0].Data, &w); err != nil || w.Title != "Build" {
+		t.Errorf("work event data = %s, want the work", got.Events[0].Data)
+	}
+	if e := got.Events[1]; e.Source != SourceSession || e.Worktree != "feature" || e.Op != "delete" || string(e.Data) != `{"id":"s1"}` {
+		t.Errorf("session event = %+v, data %s", e, e.Data)
+	}
+	if e := got.Events[2]; e.Source != SourceSettings || e.Op != "update" {
+		t.Errorf("settings event = %+v", e)
+	}
+
+	if got := l.Since(2, 0); len(got.Events) != 1 || got.Events[0].Seq != 3 {
+		t.Errorf("Since(2) = %+v, want only seq 3", got)
+	}
+	if got := l.Since(3, 0); got.Reset || len(got.Events) != 0 {
+		t.Errorf("Since(latest) = %+v, want nothing new", got)
+	}
+}
+
+func TestLog_SincePagesAndResets(t *testing.T) {
+	l := openLog(t, t.TempDir(), 5)
+	for range 5 + compactSlack + 1 {
+		l.Append(SourceWork, "update", "", "w1", nil)
+	}
+	latest := int64(5 + compactSlack + 1)
+
+	got := l.Since(latest-5, 2)
+	if got.Reset || !got.HasMore || len(got.Events) != 2 || got.Events[0].Seq != latest-4 {
+		t.Errorf("paged Since = %+v, want 2 events from seq %d", got, latest-4)
+	}
+	if got := l.Since(latest-6, 0); !got.Reset || got.LatestSeq != latest {
+		t.Errorf("Since before the retained window = %+v, want reset", got)
+	}
+	if got := l.Since(latest+1, 0); !got.Reset {
+		t.Errorf("Since after the latest seq = %+v, want reset", got)
+	}
+}
+
+func TestLog_PersistsAcrossOpen(t *testing.T) {
+	dir := t.TempDir()
+	l := openLog(t, dir, 0)
+	l.Append(SourceWork, "create", "", "w1", nil)
+	l.Append(SourceWork, "update", "", "w1", nil)
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A torn final write is dropped, and appends continue the sequence.
+	f, err := os.OpenFile(filepath.Join(dir, logFilename), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":3,"sou`)
+	f.Close()
+
+	reopened := openLog(t, dir, 0)
+	if seq := reopened.Append(SourceWork, "delete", "", "w1", nil); seq != 3 {
+		t.Errorf("next seq = %d, want 3", seq)
+	}
+	reopened.Close()
+
+	again := openLog(t, dir, 0)
+	got := again.Since(0, 0)
+	if got.Reset || len(got.Events) != 3 || got.Events[2].Op != "delete" {
+		t.Errorf("events after reopen = %+v, want 3 ending in delete", got)
+	}
+}
@@ -1,20 +1,23 @@
 // Package filestore provides infrastructure for JSON-file-backed stores:
-// atomic file I/O (flock + write-temp-fsync-rename), fsnotify-based external
-// change detection with debounce, and writeGen-based stale reload prevention.
+// atomic file I/O (fslock + write-temp-fsync-rename), fsnotify-based external
+// change detection with debounce (polling on network filesystems), writeGen-based stale reload prevention, and
+// generic load/persist/reload/diff helpers so domain stores only declare their
+// index shape and item identity.
 package filestore
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/pockode/server/fslock"
 )
 
 const reloadDebounce = 100 * time.Millisecond
@@ -30,9 +33,13 @@ type File struct {
 	writeGen atomic.Int64
 
 	watcher    *fsnotify.Watcher
+	pollStop   chan struct{}
+	pollDone   chan struct{} // closed when the poll loop has returned
 	debounce   *time.Timer
 	debounceMu sync.Mutex
 
+	perm os.FileMode
+
 	// onReload is called after debounce when the index file changes on disk.
 	onReload func()
 }
@@ -47,6 +54,9 @@ type Config struct {
 	// The callee is responsible for reading from disk, checking
 	// ReloadGuard, updating in-memory state, and notifying listeners.
 	OnReload func()
+	// Perm is the index file's mode; 0 means 0644. Use 0600 for files
+	// holding secrets.
+	Perm os.FileMode
 }
 
 // New creates a File, ensuring the parent directory exists.
@@ -56,9 +66,14 @@ func New(cfg Config) (*File, error) {
 		return nil, err
 	}
 
+	perm := cfg.Perm
+	if perm == 0 {
+		perm = 0644
+	}
 	return &File{
 		path:     cfg.Path,
 		label:    cfg.Label,
+		perm:     perm,
 		onReload: cfg.OnReload,
 	}, nil
 }
@@ -69,19 +84,14 @@ func (f *File) lockPath() string {
 	return f.path + ".lock"
 }
 
-// Read reads the index file under a shared flock and returns the raw bytes.
+// Read reads the index file under a shared file lock and returns the raw bytes.
 // Returns nil, nil if the file does not exist.
 func (f *File) Read() ([]byte, error) {
-	lockF, err := os.OpenFile(f.lockPath(), os.O_CREATE|os.O_RDWR, 0644)
+	lock, err := fslock.Shared(f.lockPath())
 	if err != nil {
-		return nil, fmt.Errorf("open lock file: %w", err)
-	}
-	defer lockF.Close()
-
-	if err := syscall.Flock(int(lockF.Fd()), syscall.LOCK_SH); err != nil {
-		return nil, fmt.Errorf("flock shared: %w", err)
+		return nil, err
 	}
-	defer syscall.Flock(int(lockF.Fd()), syscall.LOCK_UN)
+	defer lock.Unlock()
 
 	data, err := os.ReadFile(f.path)
 	if os.IsNotExist(err) {
@@ -91,22 +101,61 @@ func (f *File) Read() ([]byte, error) {
 }
 
 // Write atomically writes data using write-temp-fsync-rename under an
-// exclusive flock. Increments writeGen on success.
+// exclusive file lock. Increments writeGen on success.
 func (f *File) Write(data []byte) error {
-	lockF, err := os.OpenFile(f.lockPath(), os.O_CREATE|os.O_RDWR, 0644)
+	lock, err := fslock.Exclusive(f.lockPath())
 	if err != nil {
-		return fmt.Errorf("open lock file: %w", err)
+		return err
 	}
-	defer lockF.Close()
+	defer lock.Unlock()
+
+	return f.writeLocked(data)
+}
 
-	if err := syscall.Flock(int(lockF.Fd()), syscall.LOCK_EX); err != nil {
-		return fmt.Errorf("flock exclusive: %w", err)
+// Update reads the file and writes what fn returns under one exclusive file
+// lock, so a value derived from the current contents (such as a counter)
+// cannot race with another process. current is nil if the file does not
+// exist. An error from fn aborts without writing.
+func (f *File) Update(fn func(current []byte) ([]byte, error)) error {
+	lock, err := fslock.Exclusive(f.lockPath())
+	if err != nil {
+		return err
 	}
-	defer syscall.Flock(int(lockF.Fd()), syscall.LOCK_UN)
+	defer lock.Unlock()
 
+	current, err := os.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	data, err := fn(current)
+	if err != nil {
+		return err
+	}
+	return f.writeLocked(data)
+}
+
+// Remove deletes the index file under an exclusive file lock. A missing file
+// is not an error.
+func (f *File) Remove() error {
+	lock, err := fslock.Exclusive(f.lockPath())
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f.writeGen.Add(1)
+	return nil
+}
+
+// writeLocked does the write for Write and Update. Caller must hold the
+// exclusive file lock.
+func (f *File) writeLocked(data []byte) error {
 	tmpPath := f.path + ".tmp"
 
-	tmpF, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	tmpF, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.perm)
 	if err != nil {
 		return fmt.Errorf("create temp file: %w", err)
 	}
@@ -152,26 +201,39 @@ func (f *File) IsStale(genBefore int64) bool {
 // --- fsnotify ---
 
 // StartWatching begins monitoring the index file's parent directory for
-// Write/Create events matching the index file name.
+// Write/Create events matching the index file name. On filesystems where
+// inotify misses remote writes (NFS, SMB, FUSE, Docker Desktop volumes), or
+// if fsnotify cannot be set up, it polls the file instead.
 func (f *File) StartWatching() error {
+	dir := filepath.Dir(f.path)
+	if poll, fsType := needsPolling(dir); poll {
+		slog.Info("store data dir is on a network filesystem, using polling", "label", f.label, "fsType", fsType)
+		f.startPolling()
+		return nil
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return err
+		slog.Warn("store fsnotify unavailable, using polling", "label", f.label, "error", err)
+		f.startPolling()
+		return nil
 	}
-	f.watcher = watcher
 
-	dir := filepath.Dir(f.path)
 	if err := watcher.Add(dir); err != nil {
 		watcher.Close()
-		return err
+		slog.Warn("store fsnotify watch failed, using polling", "label", f.label, "error", err)
+		f.startPolling()
+		return nil
 	}
+	f.watcher = watcher
 
 	go f.watchLoop()
 	slog.Info("store watching for external changes", "label", f.label, "path", f.path)
 	return nil
 }
 
-// StopWatching stops the fsnotify watcher and cancels any pending debounce.
+// StopWatching stops the fsnotify watcher or poller and cancels any pending
+// debounce.
 func (f *File) StopWatching() {
 	f.debounceMu.Lock()
 	if f.debounce != nil {
@@ -182,6 +244,11 @@ func (f *File) StopWatching() {
 	if f.watcher != nil {
 		f.watcher.Close()
 	}
+	if f.pollStop != nil {
+		close(f.pollStop)
+		<-f.pollDone // a poll in flight must not touch the file after Stop
+		f.pollStop = nil
+	}
 }
 
 func (f *File) watchLoop() {
@@ -218,6 +285,63 @@ func (f *File) scheduleReload() {
 	f.debounce = time.AfterFunc(reloadDebounce, f.onReload)
 }
 
+// --- Typed index helpers ---
+
+// ErrCorrupt wraps JSON decode failures from Load, letting callers that
+// tolerate a damaged file (e.g. settings) fall back to defaults while still
+// surfacing I/O errors.
+var ErrCorrupt = errors.New("corrupt index file")
+
+// Load reads and decodes the index file. Returns fallback if the file does
+// not exist yet.
+func Load[D any](f *File, fallback D) (D, error) {
+	data, err := f.Read()
+	if err != nil {
+		return fallback, err
+	}
+	if data == nil {
+		return fallback, nil
+	}
+
+	var out D
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fallback, fmt.Errorf("%w: %s: %v", ErrCorrupt, f.label, err)
+	}
+	return out, nil
+}
+
+// Persist marshals v as an indented index and writes it atomically.
+func (f *File) Persist(v any) error {
+	data, err := MarshalIndex(v)
+	if err != nil {
+		return err
+	}
+	return f.Write(data)
+}
+
+// Reload is the common body of an OnReload callback. It loads the index
+// without holding mu, then calls apply under mu unless an in-process Write
+// landed meanwhile (memory is then newer than what was read). apply should
+// swap in-memory state and capture listeners; notify after Reload returns so
+// listeners never run under the store lock.
+func Reload[D any](f *File, mu sync.Locker, fallback D, apply func(D)) error {
+	genBefore := f.SnapshotGen()
+
+	data, err := Load(f, fallback)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if f.IsStale(genBefore) {
+		return nil
+	}
+	apply(data)
+	return nil
+}
+
 // --- Diff helper ---
 
 // Operation represents a CRUD operation type, reusable across domains.
@@ -229,26 +353,28 @@ const (
 	OperationDelete Operation = "delete"
 )
 
+// Item is implemented by the element type of a diffable index. ItemID is
+// used instead of ID because domain structs already expose an ID field.
+type Item[T any] interface {
+	ItemID() string
+	// Changed reports whether other (same ItemID) differs in a way listeners
+	// must hear about.
+	Changed(other T) bool
+}
+
 // Diff computes create/update/delete changes between old and updated slices.
-// getID extracts each item's unique identifier. changed reports whether two
-// items with the same ID differ in a meaningful way. makeEvent constructs
-// a domain-specific event from an operation and item.
-func Diff[T any, E any](
-	old, updated []T,
-	getID func(T) string,
-	changed func(a, b T) bool,
-	makeEvent func(op Operation, item T) E,
-) []E {
+// makeEvent constructs a domain-specific event from an operation and item.
+func Diff[T Item[T], E any](old, updated []T, makeEvent func(op Operation, item T) E) []E {
 	var events []E
 
 	oldMap := make(map[string]T, len(old))
 	for _, item := range old {
-		oldMap[getID(item)] = item
+		oldMap[item.ItemID()] = item
 	}
 
 	newMap := make(map[string]T, len(updated))
 	for _, item := range updated {
-		newMap[getID(item)] = item
+		newMap[item.ItemID()] = item
 	}
 
 	for id, item := range oldMap {
@@ -261,7 +387,7 @@ func Diff[T any, E any](
 		oldItem, exists := oldMap[id]
 		if !exists {
 			events = append(events, makeEvent(OperationCreate, item))
-		} else if changed(oldItem, item) {
+		} else if oldItem.Changed(item) {
 			events = append(events, makeEvent(OperationUpdate, item))
 		}
 	}
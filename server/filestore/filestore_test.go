@@ -0,0 +1,239 @@
+package filestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testIndex struct {
+	Items []testItem `json:"items"`
+}
+
+type testItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (i testItem) ItemID() string              { return i.ID }
+func (i testItem) Changed(other testItem) bool { return i.Name != other.Name }
+
+func newTestFile(t *testing.T) *File {
+	t.Helper()
+	f, err := New(Config{
+		Path:     filepath.Join(t.TempDir(), "test", "index.json"),
+		Label:    "test",
+		OnReload: func() {},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return f
+}
+
+func TestLoad_ReturnsFallbackWhenMissing(t *testing.T) {
+	f := newTestFile(t)
+
+	got, err := Load(f, testIndex{Items: []testItem{{ID: "fallback"}}})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(got.Items) != 1 || got.Items[0].ID != "fallback" {
+		t.Errorf("expected fallback, got %+v", got)
+	}
+}
+
+func TestLoad_RoundTripsPersist(t *testing.T) {
+	f := newTestFile(t)
+
+	want := testIndex{Items: []testItem{{ID: "a", Name: "A"}}}
+	if err := f.Persist(want); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+
+	got, err := Load(f, testIndex{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(got.Items) != 1 || got.Items[0] != want.Items[0] {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestUpdate_SeesCurrentContents(t *testing.T) {
+	f := newTestFile(t)
+
+	var seen [][]byte
+	update := func(next string) error {
+		return f.Update(func(current []byte) ([]byte, error) {
+			seen = append(seen, current)
+			return []byte(next), nil
+		})
+	}
+	if err := update("one"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if err := update("two"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if seen[0] != nil || string(seen[1]) != "one" {
+		t.Errorf("seen = %q, want [nil one]", seen)
+	}
+
+	abort := errors.New("abort")
+	if err := f.Update(func([]byte) ([]byte, error) { return nil, abort }); !errors.Is(err, abort) {
+		t.Fatalf("Update error = %v, want abort", err)
+	}
+	if data, _ := f.Read(); string(data) != "two" {
+		t.Errorf("file = %q after aborted update, want two", data)
+	}
+}
+
+func TestLoad_WrapsDecodeErrorAsCorrupt(t *testing.T) {
+	f := newTestFile(t)
+
+	if err := os.WriteFile(f.path, []byte(`{invalid`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	_, err := Load(f, testIndex{})
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt, got %v", err)
+	}
+}
+
+func TestReload_AppliesFreshData(t *testing.T) {
+	f := newTestFile(t)
+	if err := os.WriteFile(f.path, []byte(`{"items":[{"id":"x","name":"X"}]}`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var applied testIndex
+	if err := Reload(f, &mu, testIndex{}, func(idx testIndex) { applied = idx }); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(applied.Items) != 1 || applied.Items[0].ID != "x" {
+		t.Errorf("expected reloaded item x, got %+v", applied)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := []testItem{{ID: "keep", Name: "same"}, {ID: "edit", Name: "before"}, {ID: "gone"}}
+	updated := []testItem{{ID: "keep", Name: "same"}, {ID: "edit", Name: "after"}, {ID: "new"}}
+
+	events := Diff(old, updated, func(op Operation, item testItem) string {
+		return string(op) + ":" + item.ID
+	})
+	sort.Strings(events)
+
+	want := []string{"create:new", "delete:gone", "update:edit"}
+	if len(events) != len(want) {
+		t.Fatalf("expected %v, got %v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events[%d] = %q, want %q", i, events[i], want[i])
+		}
+	}
+}
+
+func TestPolling_DetectsExternalWrite(t *testing.T) {
+	prev := pollInterval
+	pollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { pollInterval = prev })
+
+	reloaded := make(chan struct{}, 4)
+	f, err := New(Config{
+		Path:     filepath.Join(t.TempDir(), "index.json"),
+		Label:    "test",
+		OnReload: func() { reloaded <- struct{}{} },
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	f.startPolling()
+	t.Cleanup(f.StopWatching)
+
+	if err := os.WriteFile(f.path, []byte(`{"items":[]}`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected reload after external write")
+	}
+}
+
+func TestPolling_IgnoresTouchWithoutContentChange(t *testing.T) {
+	prev := pollInterval
+	pollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { pollInterval = prev })
+
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := os.WriteFile(path, []byte(`{"items":[]}`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	reloaded := make(chan struct{}, 4)
+	f, err := New(Config{Path: path, Label: "test", OnReload: func() { reloaded <- struct{}{} }})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	f.startPolling()
+	t.Cleanup(f.StopWatching)
+
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	select {
+	case <-reloaded:
+		t.Fatal("unexpected reload for mtime-only change")
+	case <-time.After(10*pollInterval + reloadDebounce):
+	}
+}
+
+func TestPolling_DetectsSameSizeRewriteWithSameMtime(t *testing.T) {
+	prev := pollInterval
+	pollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { pollInterval = prev })
+
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := os.WriteFile(path, []byte(`{"items":[1]}`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan struct{}, 4)
+	f, err := New(Config{Path: path, Label: "test", OnReload: func() { reloaded <- struct{}{} }})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	f.startPolling()
+	t.Cleanup(f.StopWatching)
+
+	// Same size, and the mtime put back as a coarse-grained filesystem
+	// would leave it.
+	if err := os.WriteFile(path, []byte(`{"items":[2]}`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected reload after a same-size rewrite with an unchanged mtime")
+	}
+}
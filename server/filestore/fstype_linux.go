@@ -0,0 +1,29 @@
+//go:build linux
+
+package filestore
+
+import "syscall"
+
+// Filesystem magic numbers (statfs f_type, truncated to 32 bits since its
+// width varies by arch) where inotify only reports changes made through the
+// local kernel, so edits from another host or from the Docker VM never arrive.
+var pollingFSTypes = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+	0x6a656a63: "virtiofs",
+}
+
+// needsPolling reports whether dir lives on a filesystem that needs the
+// polling watcher, along with the filesystem name for logging.
+func needsPolling(dir string) (bool, string) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return false, ""
+	}
+	name, ok := pollingFSTypes[uint32(st.Type)]
+	return ok, name
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package filestore
+
+// needsPolling always defers to fsnotify off Linux: kqueue and
+// ReadDirectoryChangesW have no cheap equivalent of the statfs magic check,
+// and StartWatching still falls back to polling if fsnotify setup fails.
+func needsPolling(dir string) (bool, string) {
+	return false, ""
+}
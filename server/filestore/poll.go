@@ -0,0 +1,72 @@
+package filestore
+
+import (
+	"crypto/sha256"
+	"log/slog"
+	"time"
+)
+
+// pollInterval is how often the polling watcher stats the index file.
+// A var so tests can shorten it.
+var pollInterval = time.Second
+
+// fileFingerprint identifies a version of the index file by its content
+// hash. mtime and size can't be trusted here: on NFS mtime often rounds to
+// whole seconds, so a same-size rewrite within that second leaves both
+// unchanged.
+type fileFingerprint struct {
+	exists bool
+	hash   [sha256.Size]byte
+}
+
+// startPolling watches the index file by periodic read+hash. Used when the
+// data dir is on a filesystem where inotify events are unreliable (NFS, SMB,
+// FUSE, some Docker volume drivers) or when fsnotify cannot be set up.
+func (f *File) startPolling() {
+	stop, done := make(chan struct{}), make(chan struct{})
+	f.pollStop, f.pollDone = stop, done
+
+	last := f.fingerprint(fileFingerprint{})
+	go func() {
+		defer close(done)
+		f.pollLoop(stop, last)
+	}()
+	slog.Info("store polling for external changes", "label", f.label, "path", f.path, "interval", pollInterval)
+}
+
+func (f *File) pollLoop(stop <-chan struct{}, last fileFingerprint) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cur := f.fingerprint(last)
+			// An mtime touch without a content change is not worth a reload.
+			changed := cur.exists != last.exists || cur.hash != last.hash
+			last = cur
+			// Deletion is not a reload trigger, matching the fsnotify path
+			// which only reacts to Write/Create.
+			if changed && cur.exists {
+				f.scheduleReload()
+			}
+		}
+	}
+}
+
+// fingerprint returns the current fingerprint of the index file. The file
+// is read and hashed on every poll; it is a small index, and only the
+// content says whether it changed.
+func (f *File) fingerprint(prev fileFingerprint) fileFingerprint {
+	data, err := f.Read()
+	if err != nil {
+		slog.Warn("store poll read failed", "label", f.label, "error", err)
+		return prev
+	}
+	if data == nil { // Read reports a missing file as no data
+		return fileFingerprint{}
+	}
+	return fileFingerprint{exists: true, hash: sha256.Sum256(data)}
+}
@@ -0,0 +1,320 @@
+// Package fsck checks a data directory for references and files the stores
+// left inconsistent (a crash mid-operation, a hand-edited index, a role
+// deleted while work still pointed at it) and optionally applies the fixes
+// that cannot lose data.
+package fsck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/fslock"
+	"github.com/pockode/server/health"
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/work"
+)
+
+// Kind identifies what an Issue is about.
+type Kind string
+
+const (
+	// KindOrphanTask is a task whose parent story no longer exists.
+	KindOrphanTask Kind = "orphan_task"
+	// KindMissingRole is a work item assigned to a deleted agent role.
+	KindMissingRole Kind = "missing_role"
+	// KindMissingSession is an active work item whose session is in no
+	// session index.
+	KindMissingSession Kind = "missing_session"
+	// KindStaleTemp is a temp file left by a write that never finished.
+	KindStaleTemp Kind = "stale_temp"
+	// KindDanglingLock is a lock file whose data file does not exist.
+	KindDanglingLock Kind = "dangling_lock"
+)
+
+// staleAfter is how old a temp or lock file must be before it is reported.
+// Writers hold them for milliseconds; the margin keeps a check running next
+// to a busy server from flagging a write in progress.
+const staleAfter = 10 * time.Minute
+
+// Issue is one problem found. Fix describes the repair, empty when there is
+// no safe automatic one.
+type Issue struct {
+	Kind     Kind   `json:"kind"`
+	Subject  string `json:"subject"`
+	Detail   string `json:"detail"`
+	Fix      string `json:"fix,omitempty"`
+	Repaired bool   `json:"repaired,omitempty"`
+	// Error is why a repair was attempted and failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the result of a check.
+type Report struct {
+	Issues   []Issue `json:"issues"`
+	Repaired int     `json:"repaired"`
+}
+
+// Checker checks one data directory through the stores that own it.
+type Checker struct {
+	dataDir     string
+	works       work.Store
+	roles       agentrole.Store
+	defaultRole func() string
+	now         func() time.Time
+}
+
+// NewChecker creates a Checker. defaultRole returns the role missing_role
+// repairs assign; nil or an empty result leaves those items alone.
+func NewChecker(dataDir string, works work.Store, roles agentrole.Store, defaultRole func() string) *Checker {
+	return &Checker{
+		dataDir:     dataDir,
+		works:       works,
+		roles:       roles,
+		defaultRole: defaultRole,
+		now:         time.Now,
+	}
+}
+
+// Run checks the data directory. With repair it also applies each issue's
+// Fix; a failed repair is recorded on the issue and does not stop the run.
+func (c *Checker) Run(ctx context.Context, repair bool) (Report, error) {
+	report := Report{Issues: []Issue{}}
+	add := func(issue Issue, fix func() error) {
+		if repair && fix != nil {
+			if err := fix(); err != nil {
+				issue.Error = err.Error()
+			} else {
+				issue.Repaired = true
+				report.Repaired++
+			}
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	if err := c.checkWorks(ctx, add); err != nil {
+		return Report{}, err
+	}
+	if err := c.checkFiles(add); err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+func (c *Checker) checkWorks(ctx context.Context, add func(Issue, func() error)) error {
+	works, err := c.works.List()
+	if err != nil {
+		return fmt.Errorf("list works: %w", err)
+	}
+	roles, err := c.roles.List()
+	if err != nil {
+		return fmt.Errorf("list agent roles: %w", err)
+	}
+	sessionIDs, err := session.IndexedIDs(c.dataDir)
+	if err != nil {
+		return err
+	}
+
+	workIDs := make(map[string]bool, len(works))
+	for _, w := range works {
+		workIDs[w.ID] = true
+	}
+	roleIDs := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		roleIDs[r.ID] = true
+	}
+	defaultRole := ""
+	if c.defaultRole != nil {
+		if id := c.defaultRole(); roleIDs[id] {
+			defaultRole = id
+		}
+	}
+
+	for _, w := range works {
+		subject := workSubject(w)
+
+		if w.Type == work.WorkTypeTask && !workIDs[w.ParentID] {
+			// Reparenting or deleting needs a decision about where the
+			// task belongs, so this is only reported.
+			add(Issue{
+				Kind:    KindOrphanTask,
+				Subject: subject,
+				Detail:  fmt.Sprintf("parent %s does not exist", w.ParentID),
+			}, nil)
+		}
+
+		if w.AgentRoleID != "" && !roleIDs[w.AgentRoleID] {
+			issue := Issue{
+				Kind:    KindMissingRole,
+				Subject: subject,
+				Detail:  fmt.Sprintf("agent role %s does not exist", w.AgentRoleID),
+			}
+			var fix func() error
+			if defaultRole != "" {
+				issue.Fix = "assign the default agent role"
+				fix = func() error {
+					return c.works.Update(ctx, w.ID, work.UpdateFields{AgentRoleID: &defaultRole})
+				}
+			}
+			add(issue, fix)
+		}
+
+		if w.SessionID != "" && !sessionIDs[w.SessionID] && sessionExpected(w.Status) {
+			issue := Issue{
+				Kind:    KindMissingSession,
+				Subject: subject,
+				Detail:  fmt.Sprintf("session %s is %s but in no session index", w.SessionID, w.Status),
+			}
+			var fix func() error
+			// No agent can be running without its session. Stopping lets
+			// a restart create the session afresh under the same ID.
+			if w.Status == work.StatusInProgress || w.Status == work.StatusNeedsInput {
+				issue.Fix = "stop the work"
+				fix = func() error { return c.works.Stop(ctx, w.ID) }
+			}
+			add(issue, fix)
+		}
+	}
+	return nil
+}
+
+// sessionExpected reports whether a work item in status relies on its
+// session existing. Stopped items recreate it on restart, and finished ones
+// may have had it deleted on purpose.
+func sessionExpected(status work.WorkStatus) bool {
+	switch status {
+	case work.StatusInProgress, work.StatusNeedsInput, work.StatusWaiting:
+		return true
+	}
+	return false
+}
+
+func workSubject(w work.Work) string {
+	if w.ShortID != "" {
+		return fmt.Sprintf("%s %s (%s)", w.Type, w.ShortID, w.Title)
+	}
+	return fmt.Sprintf("%s %s (%s)", w.Type, w.ID, w.Title)
+}
+
+func (c *Checker) checkFiles(add func(Issue, func() error)) error {
+	now := c.now()
+	err := filepath.WalkDir(c.dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Snapshot archives are written and pruned by the snapshot
+			// package alone.
+			if path == filepath.Join(c.dataDir, "snapshots") {
+				return filepath.SkipDir
+			}
+			// Attachments are user files; a Cargo.lock there is not a
+			// store lock.
+			if rel, err := filepath.Rel(c.dataDir, path); err == nil && isAttachmentDir(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if !strings.HasSuffix(name, ".tmp") && !strings.HasSuffix(name, ".lock") {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // removed since the directory was read
+		} else if err != nil {
+			return err
+		}
+		if now.Sub(info.ModTime()) < staleAfter {
+			return nil
+		}
+		rel, err := filepath.Rel(c.dataDir, path)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(name, ".tmp") {
+			add(Issue{
+				Kind:    KindStaleTemp,
+				Subject: rel,
+				Detail:  fmt.Sprintf("left by an unfinished write, last modified %s", info.ModTime().Format(time.RFC3339)),
+				Fix:     "remove the file",
+			}, func() error { return removeStaleTemp(path) })
+			return nil
+		}
+
+		if path == filepath.Join(c.dataDir, health.ProbeLockFile) {
+			return nil
+		}
+		dataPath := strings.TrimSuffix(path, ".lock")
+		if _, err := os.Stat(dataPath); err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		add(Issue{
+			Kind:    KindDanglingLock,
+			Subject: rel,
+			Detail:  fmt.Sprintf("%s does not exist", filepath.Base(dataPath)),
+			Fix:     "remove the file",
+		}, func() error { return removeDanglingLock(path, dataPath) })
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scan data directory: %w", err)
+	}
+	return nil
+}
+
+// attachmentDirPatterns match work attachment directories relative to the
+// data dir, in the main work store and in isolated worktree stores.
+var attachmentDirPatterns = []string{
+	filepath.Join("works", "*", "attachments"),
+	filepath.Join("worktrees", "*", "works", "*", "attachments"),
+}
+
+func isAttachmentDir(rel string) bool {
+	for _, pattern := range attachmentDirPatterns {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// removeStaleTemp removes a temp file under its data file's lock when it
+// has one, so a writer that is slow rather than dead is not cut short.
+func removeStaleTemp(path string) error {
+	lockPath := strings.TrimSuffix(path, ".tmp") + ".lock"
+	if _, err := os.Stat(lockPath); err == nil {
+		lock, err := fslock.Exclusive(lockPath)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// removeDanglingLock removes a lock file while holding it, after checking
+// that no writer created the data file in the meantime.
+func removeDanglingLock(lockPath, dataPath string) error {
+	lock, err := fslock.Exclusive(lockPath)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if _, err := os.Stat(dataPath); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Remove(lockPath)
+}
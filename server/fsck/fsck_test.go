@@ -0,0 +1,169 @@
+package fsck
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/work"
+)
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestChecker seeds dataDir with one of each work issue and returns a
+// Checker over it, the work store, and the surviving role's ID.
+func newTestChecker(t *testing.T, dataDir string) (*Checker, *work.FileStore, string) {
+	t.Helper()
+	roles, err := agentrole.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := roles.Create(context.Background(), agentrole.AgentRole{Name: "Dev", RolePrompt: "code"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeJSON(t, filepath.Join(dataDir, "works", "index.json"), map[string]any{"works": []work.Work{
+		{ID: "story", Type: work.WorkTypeStory, Title: "Story", Status: work.StatusOpen, AgentRoleID: role.ID},
+		{ID: "orphan", Type: work.WorkTypeTask, ParentID: "gone", Title: "Orphan", Status: work.StatusOpen, AgentRoleID: role.ID},
+		{ID: "no-role", Type: work.WorkTypeTask, ParentID: "story", Title: "No role", Status: work.StatusOpen, AgentRoleID: "deleted-role"},
+		{ID: "no-session", Type: work.WorkTypeTask, ParentID: "story", Title: "No session", Status: work.StatusInProgress, AgentRoleID: role.ID, SessionID: "sess-gone"},
+		{ID: "ok-session", Type: work.WorkTypeTask, ParentID: "story", Title: "Session", Status: work.StatusInProgress, AgentRoleID: role.ID, SessionID: "sess-wt"},
+		{ID: "closed", Type: work.WorkTypeTask, ParentID: "story", Title: "Closed", Status: work.StatusClosed, AgentRoleID: role.ID, SessionID: "sess-deleted"},
+	}})
+	writeJSON(t, filepath.Join(dataDir, "worktrees", "feat", "sessions", "index.json"), map[string]any{"sessions": []map[string]string{{"id": "sess-wt"}}})
+
+	works, err := work.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewChecker(dataDir, works, roles, func() string { return role.ID }), works, role.ID
+}
+
+func kinds(report Report) map[Kind][]Issue {
+	m := make(map[Kind][]Issue)
+	for _, issue := range report.Issues {
+		m[issue.Kind] = append(m[issue.Kind], issue)
+	}
+	return m
+}
+
+func TestChecker_Works(t *testing.T) {
+	dataDir := t.TempDir()
+	c, works, roleID := newTestChecker(t, dataDir)
+
+	report, err := c.Run(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := kinds(report)
+	if len(got[KindOrphanTask]) != 1 || len(got[KindMissingRole]) != 1 || len(got[KindMissingSession]) != 1 {
+		t.Fatalf("issues = %+v, want one orphan task, missing role, and missing session", report.Issues)
+	}
+	if report.Repaired != 0 {
+		t.Errorf("repaired = %d without repair", report.Repaired)
+	}
+
+	report, err = c.Run(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The orphan has no automatic fix.
+	if report.Repaired != 2 {
+		t.Errorf("repaired = %d, want 2: %+v", report.Repaired, report.Issues)
+	}
+	if w, _, _ := works.Get("no-role"); w.AgentRoleID != roleID {
+		t.Errorf("agent role = %q, want the default %q", w.AgentRoleID, roleID)
+	}
+	if w, _, _ := works.Get("no-session"); w.Status != work.StatusStopped {
+		t.Errorf("status = %q, want stopped", w.Status)
+	}
+
+	report, err = c.Run(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != KindOrphanTask {
+		t.Errorf("issues after repair = %+v, want only the orphan", report.Issues)
+	}
+}
+
+func TestChecker_Files(t *testing.T) {
+	dataDir := t.TempDir()
+	c, _, _ := newTestChecker(t, dataDir)
+	c.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	// The temp file has a data file and lock, so the repair takes the lock.
+	staleTemp := filepath.Join(dataDir, "commands", "index.json.tmp")
+	danglingLock := filepath.Join(dataDir, "settings.json.lock")
+	snapshotTemp := filepath.Join(dataDir, "snapshots", "x.tar.gz.tmp")
+	// Attachments are user files, whatever their names.
+	attachments := []string{
+		filepath.Join(dataDir, "works", "w1", "attachments", "Cargo.lock"),
+		filepath.Join(dataDir, "works", "w1", "attachments", "notes.tmp"),
+		filepath.Join(dataDir, "worktrees", "feature", "works", "w2", "attachments", "yarn.lock"),
+	}
+	for _, path := range append([]string{
+		staleTemp, filepath.Join(dataDir, "commands", "index.json"), filepath.Join(dataDir, "commands", "index.json.lock"),
+		danglingLock, snapshotTemp, filepath.Join(dataDir, ".readyz.lock"),
+	}, attachments...) {
+		writeJSON(t, path, nil)
+	}
+
+	report, err := c.Run(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := kinds(report)
+	if len(got[KindStaleTemp]) != 1 || got[KindStaleTemp][0].Subject != filepath.Join("commands", "index.json.tmp") {
+		t.Errorf("stale temp = %+v, want commands/index.json.tmp", got[KindStaleTemp])
+	}
+	if len(got[KindDanglingLock]) != 1 || got[KindDanglingLock][0].Subject != "settings.json.lock" {
+		t.Errorf("dangling lock = %+v, want settings.json.lock", got[KindDanglingLock])
+	}
+	for _, path := range []string{staleTemp, danglingLock} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after repair", path)
+		}
+	}
+	if _, err := os.Stat(snapshotTemp); err != nil {
+		t.Errorf("snapshot temp file touched: %v", err)
+	}
+	for _, path := range attachments {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("attachment %s touched: %v", path, err)
+		}
+	}
+}
+
+func TestChecker_FreshFilesIgnored(t *testing.T) {
+	dataDir := t.TempDir()
+	c, _, _ := newTestChecker(t, dataDir)
+	writeJSON(t, filepath.Join(dataDir, "works", "index.json.tmp"), nil)
+
+	report, err := c.Run(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range report.Issues {
+		if issue.Kind == KindStaleTemp || issue.Kind == KindDanglingLock {
+			t.Errorf("fresh file reported: %+v", issue)
+		}
+	}
+}
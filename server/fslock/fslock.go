@@ -0,0 +1,54 @@
+// Package fslock provides advisory inter-process file locks that work on
+// every platform the server ships for: flock(2) on Unix and LockFileEx on
+// Windows. Locks are held per open file handle, so two Lock values on the
+// same path exclude each other even within one process.
+package fslock
+
+import (
+	"fmt"
+	"os"
+)
+
+// Lock is a held lock on a lock file. Release it with Unlock.
+type Lock struct {
+	f *os.File
+}
+
+// Shared blocks until a shared (read) lock on path is acquired, creating
+// the lock file if needed.
+func Shared(path string) (*Lock, error) {
+	return acquire(path, false)
+}
+
+// Exclusive blocks until an exclusive (write) lock on path is acquired,
+// creating the lock file if needed.
+func Exclusive(path string) (*Lock, error) {
+	return acquire(path, true)
+}
+
+func acquire(path string, exclusive bool) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	if err := lock(f, exclusive); err != nil {
+		f.Close()
+		mode := "shared"
+		if exclusive {
+			mode = "exclusive"
+		}
+		return nil, fmt.Errorf("lock %s: %w", mode, err)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Unlock releases the lock and closes the lock file.
+func (l *Lock) Unlock() error {
+	unlockErr := unlock(l.f)
+	closeErr := l.f.Close()
+	if unlockErr != nil {
+		return fmt.Errorf("unlock: %w", unlockErr)
+	}
+	return closeErr
+}
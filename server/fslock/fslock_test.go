@@ -0,0 +1,74 @@
+package fslock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExclusive_BlocksUntilUnlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json.lock")
+
+	first, err := Exclusive(path)
+	if err != nil {
+		t.Fatalf("Exclusive failed: %v", err)
+	}
+
+	acquired := make(chan *Lock)
+	go func() {
+		second, err := Exclusive(path)
+		if err != nil {
+			t.Errorf("second Exclusive failed: %v", err)
+			close(acquired)
+			return
+		}
+		acquired <- second
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second exclusive lock acquired while first was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := first.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	select {
+	case second := <-acquired:
+		if second != nil {
+			second.Unlock()
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second exclusive lock not acquired after unlock")
+	}
+}
+
+func TestShared_AllowsConcurrentReaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json.lock")
+
+	a, err := Shared(path)
+	if err != nil {
+		t.Fatalf("Shared failed: %v", err)
+	}
+	defer a.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		b, err := Shared(path)
+		if err == nil {
+			err = b.Unlock()
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("second Shared failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shared lock blocked another shared lock")
+	}
+}
@@ -0,0 +1,169 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// FetchStaleAfter is how old the last fetch may be before ahead/behind
+	// counts are flagged as possibly outdated.
+	FetchStaleAfter = time.Hour
+
+	// DefaultFetchInterval is the minimum spacing between fetches started
+	// through a FetchLimiter. Fetches hit the remote host, so a client
+	// refreshing in a loop must not turn into a fetch loop.
+	DefaultFetchInterval = 30 * time.Second
+
+	fetchTimeout = 2 * time.Minute
+)
+
+// BranchStatus is the current branch's divergence from its upstream and
+// from the remote's default branch. Counts are only as fresh as the last
+// fetch; see LastFetch and FetchStale.
+type BranchStatus struct {
+	Branch   string `json:"branch,omitempty"`   // empty on a detached HEAD
+	Upstream string `json:"upstream,omitempty"` // e.g. "origin/feature"; empty when none is set
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+
+	// Base is the remote's default branch ("origin/main"), so branches
+	// without an upstream (new worktrees) still show how far they are
+	// behind. Empty when origin/HEAD is unknown.
+	Base       string `json:"base,omitempty"`
+	BaseAhead  int    `json:"base_ahead"`
+	BaseBehind int    `json:"base_behind"`
+
+	LastFetch  *time.Time `json:"last_fetch,omitempty"` // nil if never fetched
+	FetchStale bool       `json:"fetch_stale"`          // never fetched, or longer ago than FetchStaleAfter
+}
+
+// Branch returns the branch status of the repository at dir. Missing
+// upstream, base or fetch history leave the respective fields empty rather
+// than failing.
+func Branch(dir string) (*BranchStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := gitOutput(ctx, dir, "rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("not a git repository: %w", err)
+	}
+
+	s := &BranchStatus{}
+	s.Branch, _ = gitOutput(ctx, dir, "symbolic-ref", "--short", "-q", "HEAD")
+
+	if upstream, err := gitOutput(ctx, dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"); err == nil {
+		s.Upstream = upstream
+		s.Ahead, s.Behind = aheadBehind(ctx, dir, "@{upstream}")
+	}
+	if base, err := gitOutput(ctx, dir, "symbolic-ref", "--short", "-q", "refs/remotes/origin/HEAD"); err == nil {
+		s.Base = base
+		s.BaseAhead, s.BaseBehind = aheadBehind(ctx, dir, "refs/remotes/origin/HEAD")
+	}
+
+	if t, ok := lastFetch(ctx, dir); ok {
+		s.LastFetch = &t
+	}
+	s.FetchStale = s.LastFetch == nil || time.Since(*s.LastFetch) > FetchStaleAfter
+	return s, nil
+}
+
+// aheadBehind counts commits on HEAD but not ref, and on ref but not HEAD.
+func aheadBehind(ctx context.Context, dir, ref string) (int, int) {
+	out, err := gitOutput(ctx, dir, "rev-list", "--left-right", "--count", "HEAD..."+ref)
+	if err != nil {
+		return 0, 0
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return 0, 0
+	}
+	ahead, _ := strconv.Atoi(fields[0])
+	behind, _ := strconv.Atoi(fields[1])
+	return ahead, behind
+}
+
+// lastFetch returns the time of the latest fetch from FETCH_HEAD's mtime.
+// A linked worktree has its own FETCH_HEAD, and the remote-tracking refs
+// are shared, so a fetch from any worktree counts: the newest of the
+// worktree's and the main repository's is used.
+func lastFetch(ctx context.Context, dir string) (time.Time, bool) {
+	var paths []string
+	if p, err := gitOutput(ctx, dir, "rev-parse", "--git-path", "FETCH_HEAD"); err == nil {
+		paths = append(paths, p)
+	}
+	if common, err := gitOutput(ctx, dir, "rev-parse", "--git-common-dir"); err == nil {
+		paths = append(paths, filepath.Join(common, "FETCH_HEAD"))
+	}
+
+	var latest time.Time
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		if fi, err := os.Stat(p); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--no-optional-locks"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// FetchLimiter runs git fetch for one repository, at most once per
+// interval. Worktrees share their remote-tracking refs, so one limiter
+// covers all of them.
+type FetchLimiter struct {
+	interval time.Duration
+
+	fetchMu sync.Mutex
+	last    time.Time
+}
+
+func NewFetchLimiter(interval time.Duration) *FetchLimiter {
+	return &FetchLimiter{interval: interval}
+}
+
+// Fetch fetches all remotes of the repository at dir, pruning deleted
+// branches. When the previous fetch was less than the interval ago it does
+// nothing and returns the time to wait; concurrent callers wait for the
+// running fetch and then see it as the previous one.
+func (l *FetchLimiter) Fetch(ctx context.Context, dir string) (fetched bool, retryAfter time.Duration, err error) {
+	l.fetchMu.Lock()
+	defer l.fetchMu.Unlock()
+
+	if wait := l.interval - time.Since(l.last); !l.last.IsZero() && wait > 0 {
+		return false, wait, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "fetch", "--all", "--prune")
+	cmd.Dir = dir
+	// Never block on a credential prompt nobody can answer.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	// Failed fetches count too: retrying a failing remote in a loop is
+	// what the limit is for.
+	l.last = time.Now()
+	if err != nil {
+		return false, 0, fmt.Errorf("git fetch: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,172 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupClonedRepo creates a bare "origin" with one commit and a clone of it
+// tracking origin's default branch.
+func setupClonedRepo(t *testing.T) (origin, clone string) {
+	t.Helper()
+	root := t.TempDir()
+	origin = filepath.Join(root, "origin.git")
+	seed := filepath.Join(root, "seed")
+	clone = filepath.Join(root, "clone")
+
+	runGit(t, root, "init", "--bare", "-b", "main", origin)
+	runGit(t, root, "init", "-b", "main", seed)
+	commitFile(t, seed, "a.txt", "a")
+	runGit(t, seed, "remote", "add", "origin", origin)
+	runGit(t, seed, "push", "origin", "main")
+	runGit(t, root, "clone", origin, clone)
+	runGit(t, clone, "config", "user.email", "test@test.com")
+	runGit(t, clone, "config", "user.name", "Test")
+	return origin, clone
+}
+
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	runGit(t, dir, "config", "user.email", "test@test.com")
+	runGit(t, dir, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", name)
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "add "+name)
+}
+
+func TestBranch_AheadBehindUpstream(t *testing.T) {
+	origin, clone := setupClonedRepo(t)
+
+	// Someone else pushes two commits; we commit one locally and fetch.
+	other := filepath.Join(t.TempDir(), "other")
+	runGit(t, filepath.Dir(other), "clone", origin, other)
+	commitFile(t, other, "b.txt", "b")
+	commitFile(t, other, "c.txt", "c")
+	runGit(t, other, "push", "origin", "main")
+	commitFile(t, clone, "d.txt", "d")
+	runGit(t, clone, "fetch")
+
+	s, err := Branch(clone)
+	if err != nil {
+		t.Fatalf("Branch() error = %v", err)
+	}
+	if s.Branch != "main" || s.Upstream != "origin/main" {
+		t.Errorf("branch = %q, upstream = %q", s.Branch, s.Upstream)
+	}
+	if s.Ahead != 1 || s.Behind != 2 {
+		t.Errorf("ahead/behind = %d/%d, want 1/2", s.Ahead, s.Behind)
+	}
+	if s.Base != "origin/main" || s.BaseAhead != 1 || s.BaseBehind != 2 {
+		t.Errorf("base = %q %d/%d, want origin/main 1/2", s.Base, s.BaseAhead, s.BaseBehind)
+	}
+	if s.LastFetch == nil || s.FetchStale {
+		t.Errorf("last fetch = %v, stale = %v; want a fresh fetch", s.LastFetch, s.FetchStale)
+	}
+}
+
+func TestBranch_NoUpstreamComparesToBase(t *testing.T) {
+	_, clone := setupClonedRepo(t)
+	runGit(t, clone, "checkout", "-b", "feature")
+	commitFile(t, clone, "f.txt", "f")
+
+	s, err := Branch(clone)
+	if err != nil {
+		t.Fatalf("Branch() error = %v", err)
+	}
+	if s.Branch != "feature" || s.Upstream != "" || s.Ahead != 0 || s.Behind != 0 {
+		t.Errorf("status = %+v, want feature with no upstream", s)
+	}
+	if s.Base != "origin/main" || s.BaseAhead != 1 || s.BaseBehind != 0 {
+		t.Errorf("base = %q %d/%d, want origin/main 1/0", s.Base, s.BaseAhead, s.BaseBehind)
+	}
+	// A fresh clone has no FETCH_HEAD.
+	if s.LastFetch != nil || !s.FetchStale {
+		t.Errorf("last fetch = %v, stale = %v; want never fetched", s.LastFetch, s.FetchStale)
+	}
+}
+
+func TestBranch_StaleFetchAndWorktree(t *testing.T) {
+	_, clone := setupClonedRepo(t)
+	runGit(t, clone, "fetch")
+	old := time.Now().Add(-2 * FetchStaleAfter)
+	if err := os.Chtimes(filepath.Join(clone, ".git", "FETCH_HEAD"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	wt := filepath.Join(t.TempDir(), "wt")
+	runGit(t, clone, "worktree", "add", "-b", "feature", wt)
+
+	s, err := Branch(wt)
+	if err != nil {
+		t.Fatalf("Branch() error = %v", err)
+	}
+	if s.LastFetch == nil || !s.FetchStale {
+		t.Errorf("last fetch = %v, stale = %v; want the main repository's old fetch", s.LastFetch, s.FetchStale)
+	}
+}
+
+func TestBranch_NotARepository(t *testing.T) {
+	if _, err := Branch(t.TempDir()); err == nil {
+		t.Error("Branch() should fail outside a repository")
+	}
+}
+
+func TestStatus_IncludesBranch(t *testing.T) {
+	_, clone := setupClonedRepo(t)
+
+	s, err := Status(clone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Branch == nil || s.Branch.Upstream != "origin/main" {
+		t.Errorf("Branch = %+v, want upstream origin/main", s.Branch)
+	}
+}
+
+func TestFetchLimiter(t *testing.T) {
+	origin, clone := setupClonedRepo(t)
+	other := filepath.Join(t.TempDir(), "other")
+	runGit(t, filepath.Dir(other), "clone", origin, other)
+	commitFile(t, other, "b.txt", "b")
+	runGit(t, other, "push", "origin", "main")
+
+	l := NewFetchLimiter(time.Hour)
+	fetched, _, err := l.Fetch(context.Background(), clone)
+	if err != nil || !fetched {
+		t.Fatalf("first Fetch() = %v, %v; want fetched", fetched, err)
+	}
+	s, err := Branch(clone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Behind != 1 {
+		t.Errorf("behind = %d after fetch, want 1", s.Behind)
+	}
+
+	fetched, retryAfter, err := l.Fetch(context.Background(), clone)
+	if err != nil || fetched {
+		t.Fatalf("second Fetch() = %v, %v; want skipped", fetched, err)
+	}
+	if retryAfter <= 0 || retryAfter > time.Hour {
+		t.Errorf("retryAfter = %v, want within the interval", retryAfter)
+	}
+}
+
+func TestFetchLimiter_FailureCountsTowardLimit(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+	runGit(t, dir, "remote", "add", "origin", filepath.Join(t.TempDir(), "missing.git"))
+
+	l := NewFetchLimiter(time.Hour)
+	if _, _, err := l.Fetch(context.Background(), dir); err == nil {
+		t.Fatal("Fetch() should fail for a missing remote")
+	}
+	if fetched, _, err := l.Fetch(context.Background(), dir); err != nil || fetched {
+		t.Errorf("retry = %v, %v; want skipped by the limit", fetched, err)
+	}
+}
@@ -0,0 +1,303 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrInvalidBranchName   = errors.New("invalid branch name")
+	ErrBranchTemplateMatch = errors.New("branch does not match the branch template")
+	// ErrBranchTemplateVar means the template uses a placeholder there is
+	// no value for, such as {work-short-id} without a work item.
+	ErrBranchTemplateVar = errors.New("branch template placeholder has no value")
+)
+
+// Branch template placeholders.
+const (
+	BranchVarWorkShortID = "{work-short-id}"
+	BranchVarSlug        = "{slug}"
+	BranchVarName        = "{name}" // the worktree name
+)
+
+// branchVarPatterns are what each placeholder matches when a branch name
+// is checked against a template.
+var branchVarPatterns = map[string]string{
+	BranchVarWorkShortID: `[A-Za-z][A-Za-z0-9]{0,9}-[1-9][0-9]*`,
+	BranchVarSlug:        `[a-z0-9]+(?:-[a-z0-9]+)*`,
+	BranchVarName:        `[A-Za-z0-9._-]+`,
+}
+
+var branchVarRef = regexp.MustCompile(`\{[^{}]*\}`)
+
+// BranchTemplate is a branch naming policy: literal text with placeholders,
+// such as "feature/{work-short-id}-{slug}". Empty means no policy.
+type BranchTemplate string
+
+// BranchVars are the values substituted into a BranchTemplate.
+type BranchVars struct {
+	WorkShortID string
+	Slug        string
+	Name        string
+}
+
+func (v BranchVars) lookup(placeholder string) string {
+	switch placeholder {
+	case BranchVarWorkShortID:
+		return v.WorkShortID
+	case BranchVarSlug:
+		return v.Slug
+	case BranchVarName:
+		return v.Name
+	}
+	return ""
+}
+
+// Uses reports whether the template contains placeholder.
+func (t BranchTemplate) Uses(placeholder string) bool {
+	return strings.Contains(string(t), placeholder)
+}
+
+// Validate rejects unknown placeholders and templates that cannot produce
+// a valid branch name.
+func (t BranchTemplate) Validate() error {
+	if t == "" {
+		return nil
+	}
+	for _, ref := range branchVarRef.FindAllString(string(t), -1) {
+		if _, ok := branchVarPatterns[ref]; !ok {
+			return fmt.Errorf("unknown branch template placeholder %s", ref)
+		}
+	}
+	if strings.ContainsAny(branchVarRef.ReplaceAllString(string(t), ""), "{}") {
+		return errors.New("unbalanced braces in branch template")
+	}
+	sample := BranchVars{WorkShortID: "W-1", Slug: "slug", Name: "name"}
+	if _, err := t.Render(sample); err != nil {
+		return fmt.Errorf("branch template does not make a valid branch name: %w", err)
+	}
+	return nil
+}
+
+// Render fills in the template. It fails with ErrBranchTemplateVar if a
+// placeholder it uses has an empty value, and with ErrInvalidBranchName if
+// the result is not a valid branch name.
+func (t BranchTemplate) Render(v BranchVars) (string, error) {
+	var missing string
+	name := branchVarRef.ReplaceAllStringFunc(string(t), func(ref string) string {
+		value := v.lookup(ref)
+		if value == "" && missing == "" {
+			missing = ref
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("%w: %s", ErrBranchTemplateVar, missing)
+	}
+	if err := ValidateBranchName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Match checks that branch is one the template could have produced.
+func (t BranchTemplate) Match(branch string) error {
+	if t == "" {
+		return nil
+	}
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	s, last := string(t), 0
+	for _, loc := range branchVarRef.FindAllStringIndex(s, -1) {
+		pattern.WriteString(regexp.QuoteMeta(s[last:loc[0]]))
+		pattern.WriteString("(?:" + branchVarPatterns[s[loc[0]:loc[1]]] + ")")
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(s[last:]))
+	pattern.WriteString("$")
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return fmt.Errorf("compile branch template: %w", err)
+	}
+	if !re.MatchString(branch) {
+		return fmt.Errorf("%w %q: %q", ErrBranchTemplateMatch, t, branch)
+	}
+	return nil
+}
+
+// ValidateBranchName applies git's ref name rules (git check-ref-format)
+// to a branch name.
+func ValidateBranchName(name string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalidBranchName, name, reason)
+	}
+	switch {
+	case name == "" || name == "@":
+		return invalid("empty")
+	case strings.HasPrefix(name, "-"):
+		return invalid("starts with -")
+	case strings.Contains(name, ".."), strings.Contains(name, "@{"), strings.Contains(name, "//"):
+		return invalid(`contains "..", "@{" or "//"`)
+	case strings.HasPrefix(name, "/"), strings.HasSuffix(name, "/"), strings.HasSuffix(name, "."), strings.HasSuffix(name, ".lock"):
+		return invalid("bad start or end")
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return invalid(fmt.Sprintf("contains %q", r))
+		}
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return invalid("a component starts with .")
+		}
+	}
+	return nil
+}
+
+// maxSlugLen keeps generated branch names readable; titles are often
+// whole sentences.
+const maxSlugLen = 40
+
+// Slugify makes a lower-case, hyphen-separated ASCII slug from a title.
+// Kana is transliterated to Hepburn romaji, full-width and accented Latin
+// letters are folded to ASCII, and anything else (kanji included)
+// separates words. It returns "" when nothing is left.
+func Slugify(title string) string {
+	var b strings.Builder
+	sep := false
+	emit := func(s string) {
+		if sep && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		sep = false
+		b.WriteString(s)
+	}
+
+	runes := []rune(title)
+	for i := 0; i < len(runes); i++ {
+		r := unicode.ToLower(foldWidth(runes[i]))
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			emit(string(r))
+		case latinFold[r] != "":
+			emit(latinFold[r])
+		case isKana(r):
+			romaji, n := kanaToRomaji(runes[i:])
+			i += n - 1
+			if romaji != "" {
+				emit(romaji)
+			}
+		default:
+			sep = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLen {
+		slug = slug[:maxSlugLen]
+		if cut := strings.LastIndexByte(slug, '-'); cut > 0 {
+			slug = slug[:cut]
+		}
+	}
+	return strings.Trim(slug, "-")
+}
+
+// foldWidth maps full-width ASCII (common in Japanese text) to ASCII.
+func foldWidth(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		return r - 0xFEE0
+	}
+	return r
+}
+
+var latinFold = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ý': "y", 'ÿ': "y",
+	'ß': "ss", 'æ': "ae", 'œ': "oe",
+}
+
+func isKana(r rune) bool {
+	return (r >= 0x3041 && r <= 0x3096) || (r >= 0x30A1 && r <= 0x30FC)
+}
+
+// toHiragana maps katakana to hiragana, which kanaRomaji is keyed by.
+func toHiragana(r rune) rune {
+	if r >= 0x30A1 && r <= 0x30F6 {
+		return r - 0x60
+	}
+	return r
+}
+
+var kanaRomaji = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o", 'ゎ': "wa",
+}
+
+// smallKana combine with the kana before them (きゃ → kya, ふぁ → fa).
+var smallKana = map[rune]string{
+	'ゃ': "a", 'ゅ': "u", 'ょ': "o",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+}
+
+// kanaToRomaji transliterates the syllable at the start of runes and
+// returns it with the number of runes it used.
+func kanaToRomaji(runes []rune) (string, int) {
+	r := toHiragana(runes[0])
+	switch r {
+	case 'ー': // long vowel mark; simplified Hepburn drops it
+		return "", 1
+	case 'っ': // doubles the next consonant
+		if len(runes) < 2 || !isKana(runes[1]) {
+			return "", 1
+		}
+		next, n := kanaToRomaji(runes[1:])
+		if next == "" || strings.ContainsRune("aiueon", rune(next[0])) {
+			return next, 1 + n
+		}
+		if strings.HasPrefix(next, "ch") {
+			return "t" + next, 1 + n
+		}
+		return next[:1] + next, 1 + n
+	}
+
+	romaji := kanaRomaji[r]
+	if romaji == "" || len(runes) < 2 {
+		return romaji, 1
+	}
+	vowel, ok := smallKana[toHiragana(runes[1])]
+	if !ok || len(romaji) < 2 {
+		return romaji, 1
+	}
+	stem := romaji[:len(romaji)-1]
+	switch {
+	case strings.ContainsRune("ゃゅょ", toHiragana(runes[1])) && strings.HasSuffix(romaji, "i"):
+		// きゃ → kya, but しゃ → sha and じゃ → ja.
+		if stem == "j" || strings.HasSuffix(stem, "sh") || strings.HasSuffix(stem, "ch") {
+			return stem + vowel, 2
+		}
+		return stem + "y" + vowel, 2
+	case !strings.ContainsRune("ゃゅょ", toHiragana(runes[1])):
+		return stem + vowel, 2
+	}
+	return romaji, 1
+}
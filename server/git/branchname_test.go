@@ -0,0 +1,83 @@
+package git
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Fix login redirect", "fix-login-redirect"},
+		{"  Add OAuth2 (Google) support!! ", "add-oauth2-google-support"},
+		{"Café résumé", "cafe-resume"},
+		{"ＡＰＩ　ｖ２", "api-v2"},
+		{"ログイン画面", "roguin"},
+		{"チェックボックス", "chekkubokkusu"},
+		{"きゃっしゅ を しょうきょ", "kyasshu-o-shoukyo"},
+		{"マッチ", "matchi"},
+		{"ファイル一覧", "fairu"},
+		{"認証", ""},
+		{"Update the documentation for every public function in the server package", "update-the-documentation-for-every"},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.title); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestBranchTemplate(t *testing.T) {
+	tmpl := BranchTemplate("feature/{work-short-id}-{slug}")
+	if err := tmpl.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	got, err := tmpl.Render(BranchVars{WorkShortID: "PCK-12", Slug: "fix-login"})
+	if err != nil || got != "feature/PCK-12-fix-login" {
+		t.Errorf("Render = %q, %v", got, err)
+	}
+	if _, err := tmpl.Render(BranchVars{Slug: "fix-login"}); !errors.Is(err, ErrBranchTemplateVar) {
+		t.Errorf("Render without short ID: err = %v, want ErrBranchTemplateVar", err)
+	}
+
+	for _, branch := range []string{"feature/PCK-12-fix-login", "feature/W-3-x"} {
+		if err := tmpl.Match(branch); err != nil {
+			t.Errorf("Match(%q): %v", branch, err)
+		}
+	}
+	for _, branch := range []string{"fix-login", "feature/PCK-12", "feature/PCK-12-Fix", "feature/PCK-12-fix-login/x", "bugfix/PCK-12-a"} {
+		if err := tmpl.Match(branch); !errors.Is(err, ErrBranchTemplateMatch) {
+			t.Errorf("Match(%q) = %v, want ErrBranchTemplateMatch", branch, err)
+		}
+	}
+	if err := BranchTemplate("").Match("anything"); err != nil {
+		t.Errorf("empty template Match: %v", err)
+	}
+}
+
+func TestBranchTemplate_Validate(t *testing.T) {
+	for _, tmpl := range []BranchTemplate{"feature/{ticket}", "feature/{slug", "feature/{slug}.lock", "{name}..{slug}", "feat {slug}"} {
+		if err := tmpl.Validate(); err == nil {
+			t.Errorf("Validate(%q) = nil", tmpl)
+		}
+	}
+	for _, tmpl := range []BranchTemplate{"", "{name}", "users/alice/{slug}"} {
+		if err := tmpl.Validate(); err != nil {
+			t.Errorf("Validate(%q): %v", tmpl, err)
+		}
+	}
+}
+
+func TestValidateBranchName(t *testing.T) {
+	for _, name := range []string{"", "-x", "a..b", "a/", "a.lock", "a b", "a:b", "a/.b", "@", "a@{1}"} {
+		if err := ValidateBranchName(name); !errors.Is(err, ErrInvalidBranchName) {
+			t.Errorf("ValidateBranchName(%q) = %v", name, err)
+		}
+	}
+	if err := ValidateBranchName("feature/PCK-1-x.y"); err != nil {
+		t.Errorf("valid name: %v", err)
+	}
+}
@@ -2,13 +2,19 @@
 package git
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds configuration for git initialization.
@@ -199,6 +205,8 @@ type GitStatus struct {
 	Staged     []FileStatus          `json:"staged"`
 	Unstaged   []FileStatus          `json:"unstaged"`
 	Submodules map[string]*GitStatus `json:"submodules,omitempty"`
+	// Ahead/behind tracking for the top-level repository; nil in submodules.
+	Branch *BranchStatus `json:"branch,omitempty"`
 }
 
 // HasFile returns true if the file exists in staged or unstaged list.
@@ -255,6 +263,21 @@ func (s *GitStatus) IsUntracked(path string) bool {
 // Combined with ignoring CHMOD events in watcher.go, this prevents an infinite loop
 // when watching .git/index. If issues persist, consider switching to periodic polling.
 func Status(dir string) (*GitStatus, error) {
+	result, err := fileStatus(dir)
+	if err != nil {
+		return nil, err
+	}
+	branch, err := Branch(dir)
+	if err != nil {
+		return nil, err
+	}
+	result.Branch = branch
+	return result, nil
+}
+
+// fileStatus is Status without branch tracking, which submodules (usually
+// on a detached HEAD) don't need.
+func fileStatus(dir string) (*GitStatus, error) {
 	cmd := exec.Command("git", "--no-optional-locks", "status", "--porcelain=v1", "-uall", "--ignore-submodules=none")
 	cmd.Dir = dir
 	output, err := cmd.Output()
@@ -278,7 +301,7 @@ func Status(dir string) (*GitStatus, error) {
 				result.Submodules[sub] = &GitStatus{Staged: []FileStatus{}, Unstaged: []FileStatus{}}
 				continue
 			}
-			subStatus, err := Status(subDir)
+			subStatus, err := fileStatus(subDir)
 			if err != nil {
 				slog.Warn("failed to get submodule status", "submodule", sub, "error", err)
 				result.Submodules[sub] = &GitStatus{Staged: []FileStatus{}, Unstaged: []FileStatus{}}
@@ -407,6 +430,27 @@ func Diff(dir, path string, opts DiffOptions) (string, error) {
 	return string(output), nil
 }
 
+// DiffAll returns the unified diff of every tracked change in dir: staged
+// changes (index vs HEAD) with opts.Staged, unstaged (worktree vs index)
+// otherwise. Unlike Diff it leaves out untracked files and submodule
+// contents; Status lists those.
+func DiffAll(dir string, opts DiffOptions) (string, error) {
+	args := []string{"--no-optional-locks", "diff"}
+	if opts.Staged {
+		args = append(args, "--cached")
+	}
+	if opts.HideWhitespace {
+		args = append(args, "-w")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git diff failed: %w", err)
+	}
+	return string(output), nil
+}
+
 // resolveSubmodulePath resolves "submodule/path/to/file" to (dir/submodule, "path/to/file").
 func resolveSubmodulePath(dir, path string) (string, string) {
 	submodules := getSubmodulePaths(dir)
@@ -465,10 +509,25 @@ func showUntrackedFile(dir, path string) (string, error) {
 }
 
 // DiffResult contains diff output and file contents for syntax highlighting.
+// Hunks carries the same diff pre-parsed (line pairs, word-level changes) so
+// clients need not re-diff; it is empty for binary files.
 type DiffResult struct {
-	Diff       string `json:"diff"`
-	OldContent string `json:"old_content"`
-	NewContent string `json:"new_content"`
+	Diff       string     `json:"diff"`
+	OldContent string     `json:"old_content"`
+	NewContent string     `json:"new_content"`
+	Hunks      []DiffHunk `json:"hunks,omitempty"`
+	Binary     bool       `json:"binary,omitempty"`
+}
+
+func newDiffResult(diff, oldContent, newContent string) *DiffResult {
+	hunks, binary := ParseHunks(diff)
+	return &DiffResult{
+		Diff:       diff,
+		OldContent: oldContent,
+		NewContent: newContent,
+		Hunks:      hunks,
+		Binary:     binary,
+	}
 }
 
 // DiffWithContent returns the unified diff along with old and new file contents.
@@ -497,11 +556,7 @@ func DiffWithContent(dir, path string, opts DiffOptions) (*DiffResult, error) {
 		newContent, _ = getFileFromWorktree(actualDir, relativePath)
 	}
 
-	return &DiffResult{
-		Diff:       diff,
-		OldContent: oldContent,
-		NewContent: newContent,
-	}, nil
+	return newDiffResult(diff, oldContent, newContent), nil
 }
 
 // getFileFromRef gets file content from a git ref (e.g., HEAD).
@@ -580,6 +635,38 @@ func Add(dir, path string) error {
 	return nil
 }
 
+// AddCandidates lists the files `git add path` would stage: modified,
+// deleted and untracked (not ignored) files at or under path, relative to
+// dir. Lets callers vet a directory add before running it.
+func AddCandidates(dir, path string) ([]string, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+
+	actualDir, relativePath := resolveSubmodulePath(dir, path)
+	prefix := strings.TrimSuffix(path, relativePath)
+
+	cmd := exec.Command("git", "ls-files", "-z", "--modified", "--deleted", "--others", "--exclude-standard", "--", relativePath)
+	cmd.Dir = actualDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed: %w (output: %s)", err, stderr.String())
+	}
+
+	// A deleted file is listed as both modified and deleted.
+	var files []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(string(output), "\x00") {
+		if f != "" && !seen[f] {
+			seen[f] = true
+			files = append(files, prefix+f)
+		}
+	}
+	return files, nil
+}
+
 // Reset unstages a file from the git index.
 // For submodule paths (e.g., "submodule/path/to/file"), it runs git reset inside the submodule.
 // Uses "git restore --staged" which handles both existing and newly added files correctly.
@@ -654,26 +741,123 @@ type ShowResult struct {
 	Files []FileChange `json:"files"`
 }
 
-// Log returns the commit history for the repository.
-func Log(dir string, limit int) ([]Commit, error) {
+// ErrInvalidCursor is returned by Log for a cursor it did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// LogOptions filters and pages Log. Paths keeps commits touching any of them
+// (files or directories, matched literally); Author is a git --author
+// pattern; Since and Until bound the commit date. Cursor continues from a
+// previous page's NextCursor, with the same filters.
+type LogOptions struct {
+	Limit  int // default 50
+	Paths  []string
+	Author string
+	Since  time.Time
+	Until  time.Time
+	Cursor string
+}
+
+// LogPage is one page of Log. NextCursor is empty on the last page.
+type LogPage struct {
+	Commits    []Commit
+	NextCursor string
+}
+
+// Log returns the commit history for the repository. Cancelling ctx kills
+// git, which can take a while on a large history.
+//
+// A cursor pins the commit HEAD was at for the first page, so commits made
+// while paging don't shift later pages.
+func Log(ctx context.Context, dir string, opts LogOptions) (LogPage, error) {
+	limit := opts.Limit
 	if limit <= 0 {
 		limit = 50
 	}
 
+	start, skip, err := parseLogCursor(opts.Cursor)
+	if err != nil {
+		return LogPage{}, err
+	}
+	if start == "" {
+		var ok bool
+		start, ok = headCommit(ctx, dir)
+		if !ok {
+			return LogPage{Commits: []Commit{}}, nil // no commits yet
+		}
+	}
+
+	// One extra commit tells whether there is a next page.
 	args := []string{
 		"log",
-		fmt.Sprintf("-n%d", limit),
+		fmt.Sprintf("-n%d", limit+1),
+		fmt.Sprintf("--skip=%d", skip),
 		fmt.Sprintf("--format=%s", commitFormat),
 	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since="+opts.Since.Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until="+opts.Until.Format(time.RFC3339))
+	}
+	args = append(args, start, "--")
+	for _, p := range opts.Paths {
+		args = append(args, ":(literal)"+p)
+	}
 
-	cmd := exec.Command("git", args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = dir
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("git log failed: %w", err)
+		if ctx.Err() != nil {
+			return LogPage{}, ctx.Err()
+		}
+		return LogPage{}, fmt.Errorf("git log failed: %w", err)
 	}
 
-	return parseLogOutput(string(output)), nil
+	page := LogPage{Commits: parseLogOutput(string(output))}
+	if len(page.Commits) > limit {
+		page.Commits = page.Commits[:limit]
+		page.NextCursor = fmt.Sprintf("%s:%d", start, skip+limit)
+	}
+	return page, nil
+}
+
+// headCommit returns HEAD's hash, and false in a repository without
+// commits. Other failures fall back to "HEAD" and leave git log to report
+// them.
+func headCommit(ctx context.Context, dir string) (string, bool) {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if ctx.Err() == nil && errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", false
+		}
+		return "HEAD", true
+	}
+	return strings.TrimSpace(string(output)), true
+}
+
+var logCursorPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64}):([0-9]+)$`)
+
+// parseLogCursor splits a "<start hash>:<skip>" cursor; "" is the first page.
+func parseLogCursor(cursor string) (start string, skip int, err error) {
+	if cursor == "" {
+		return "", 0, nil
+	}
+	m := logCursorPattern.FindStringSubmatch(cursor)
+	if m == nil {
+		return "", 0, ErrInvalidCursor
+	}
+	skip, err = strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	return m[1], skip, nil
 }
 
 // parseLogOutput parses the output of git log with our custom format.
@@ -863,11 +1047,7 @@ func ShowFileDiff(dir, hash, path string, hideWhitespace bool) (*DiffResult, err
 	// Get new content (the commit itself)
 	newContent, _ := getFileFromRef(dir, hash, path)
 
-	return &DiffResult{
-		Diff:       diff,
-		OldContent: oldContent,
-		NewContent: newContent,
-	}, nil
+	return newDiffResult(diff, oldContent, newContent), nil
 }
 
 // validateCommitHash validates a git commit hash to prevent injection.
@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -323,6 +324,55 @@ func runGit(t *testing.T, dir string, args ...string) {
 	}
 }
 
+func TestAddCandidates(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("tracked.txt", "v1")
+	write("gone.txt", "x")
+	write(".gitignore", "ignored.txt\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "initial")
+
+	write("tracked.txt", "v2")
+	write("sub/new.txt", "new")
+	write("ignored.txt", "x")
+	if err := os.Remove(filepath.Join(dir, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := AddCandidates(dir, ".")
+	if err != nil {
+		t.Fatalf("AddCandidates() error: %v", err)
+	}
+	slices.Sort(files)
+	if want := []string{"gone.txt", "sub/new.txt", "tracked.txt"}; !slices.Equal(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	files, err = AddCandidates(dir, "sub")
+	if err != nil {
+		t.Fatalf("AddCandidates() error: %v", err)
+	}
+	if want := []string{"sub/new.txt"}; !slices.Equal(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	if _, err := AddCandidates(dir, "../outside"); err == nil {
+		t.Error("expected error for path traversal")
+	}
+}
+
 func TestDiff_WithSubmodule(t *testing.T) {
 	parentRepo, cleanup := setupTestRepoWithSubmodule(t)
 	defer cleanup()
@@ -0,0 +1,237 @@
+package git
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type DiffLineKind string
+
+const (
+	DiffLineContext DiffLineKind = "context"
+	DiffLineAdd     DiffLineKind = "add"
+	DiffLineDelete  DiffLineKind = "delete"
+)
+
+// DiffSegment is a run of a changed line; Changed marks the words that differ
+// from the paired line.
+type DiffSegment struct {
+	Text    string `json:"text"`
+	Changed bool   `json:"changed,omitempty"`
+}
+
+// DiffLine is one line of a hunk in unified order. A deleted line and the
+// added line that replaces it are paired via Pair (index into the hunk's
+// Lines), which lets clients align them side by side; both then carry
+// word-level Segments.
+type DiffLine struct {
+	Kind     DiffLineKind  `json:"kind"`
+	Content  string        `json:"content"`
+	OldLine  int           `json:"old_line,omitempty"` // 1-based; 0 for added lines
+	NewLine  int           `json:"new_line,omitempty"` // 1-based; 0 for deleted lines
+	Pair     *int          `json:"pair,omitempty"`
+	Segments []DiffSegment `json:"segments,omitempty"`
+}
+
+type DiffHunk struct {
+	Header   string     `json:"header"`
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseHunks parses a single-file unified diff into structured hunks. The
+// second result reports a binary diff, which has no hunks.
+func ParseHunks(diff string) ([]DiffHunk, bool) {
+	var hunks []DiffHunk
+	var cur *DiffHunk
+	oldLine, newLine := 0, 0
+
+	for _, line := range strings.Split(diff, "\n") {
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			if cur != nil {
+				pairChanges(cur)
+				hunks = append(hunks, *cur)
+			}
+			cur = &DiffHunk{
+				Header:   line,
+				OldStart: atoiDefault(m[1], 0),
+				OldLines: atoiDefault(m[2], 1),
+				NewStart: atoiDefault(m[3], 0),
+				NewLines: atoiDefault(m[4], 1),
+			}
+			oldLine, newLine = cur.OldStart, cur.NewStart
+			continue
+		}
+		if cur == nil {
+			if strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch" {
+				return nil, true
+			}
+			continue // file header
+		}
+		if line == "" {
+			continue
+		}
+
+		switch line[0] {
+		case ' ':
+			cur.Lines = append(cur.Lines, DiffLine{Kind: DiffLineContext, Content: line[1:], OldLine: oldLine, NewLine: newLine})
+			oldLine++
+			newLine++
+		case '-':
+			cur.Lines = append(cur.Lines, DiffLine{Kind: DiffLineDelete, Content: line[1:], OldLine: oldLine})
+			oldLine++
+		case '+':
+			cur.Lines = append(cur.Lines, DiffLine{Kind: DiffLineAdd, Content: line[1:], NewLine: newLine})
+			newLine++
+		case '\\':
+			// "\ No newline at end of file"
+		default:
+			// Start of another file's header in a multi-file diff.
+			pairChanges(cur)
+			hunks = append(hunks, *cur)
+			cur = nil
+		}
+	}
+	if cur != nil {
+		pairChanges(cur)
+		hunks = append(hunks, *cur)
+	}
+	return hunks, false
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// pairChanges pairs each run of deleted lines with the run of added lines
+// that follows it, position by position, and computes word diffs for pairs.
+func pairChanges(h *DiffHunk) {
+	lines := h.Lines
+	for i := 0; i < len(lines); {
+		if lines[i].Kind != DiffLineDelete {
+			i++
+			continue
+		}
+		delStart := i
+		for i < len(lines) && lines[i].Kind == DiffLineDelete {
+			i++
+		}
+		addStart := i
+		for i < len(lines) && lines[i].Kind == DiffLineAdd {
+			i++
+		}
+		n := min(addStart-delStart, i-addStart)
+		for k := 0; k < n; k++ {
+			d, a := delStart+k, addStart+k
+			lines[d].Pair, lines[a].Pair = &a, &d
+			lines[d].Segments, lines[a].Segments = wordDiff(lines[d].Content, lines[a].Content)
+		}
+	}
+}
+
+// maxWordDiffTokens bounds the O(n*m) word diff; longer lines (minified
+// code, data) are reported as changed whole.
+const maxWordDiffTokens = 400
+
+// wordDiff splits two lines into segments, marking the tokens that are not
+// part of their longest common subsequence.
+func wordDiff(oldText, newText string) ([]DiffSegment, []DiffSegment) {
+	a, b := tokenize(oldText), tokenize(newText)
+	if len(a) > maxWordDiffTokens || len(b) > maxWordDiffTokens {
+		return []DiffSegment{{Text: oldText, Changed: true}}, []DiffSegment{{Text: newText, Changed: true}}
+	}
+
+	// lcs[i][j] = LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var oldSegs, newSegs []DiffSegment
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			oldSegs = appendSegment(oldSegs, a[i], false)
+			newSegs = appendSegment(newSegs, b[j], false)
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			newSegs = appendSegment(newSegs, b[j], true)
+			j++
+		default:
+			oldSegs = appendSegment(oldSegs, a[i], true)
+			i++
+		}
+	}
+	return oldSegs, newSegs
+}
+
+// appendSegment extends the last segment when it has the same state, so the
+// result alternates between unchanged and changed runs.
+func appendSegment(segs []DiffSegment, text string, changed bool) []DiffSegment {
+	if n := len(segs); n > 0 && segs[n-1].Changed == changed {
+		segs[n-1].Text += text
+		return segs
+	}
+	return append(segs, DiffSegment{Text: text, Changed: changed})
+}
+
+// tokenize splits a line into words (letters, digits, underscore), runs of
+// whitespace, and single other characters.
+func tokenize(s string) []string {
+	var tokens []string
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		end := size
+		switch {
+		case isWordRune(r):
+			for end < len(s) {
+				r, n := utf8.DecodeRuneInString(s[end:])
+				if !isWordRune(r) {
+					break
+				}
+				end += n
+			}
+		case unicode.IsSpace(r):
+			for end < len(s) {
+				r, n := utf8.DecodeRuneInString(s[end:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				end += n
+			}
+		}
+		tokens = append(tokens, s[:end])
+		s = s[end:]
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,118 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseHunks(t *testing.T) {
+	diff := `diff --git a/f.go b/f.go
+index 1111111..2222222 100644
+--- a/f.go
++++ b/f.go
+@@ -1,4 +1,4 @@ package main
+ a
+-x := oldName(1)
++x := newName(1)
+ b
++added
+@@ -10 +10,0 @@
+-gone
+\ No newline at end of file
+`
+	hunks, binary := ParseHunks(diff)
+	if binary {
+		t.Fatal("text diff reported as binary")
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+
+	h := hunks[0]
+	if h.OldStart != 1 || h.OldLines != 4 || h.NewStart != 1 || h.NewLines != 4 {
+		t.Errorf("hunk range = -%d,%d +%d,%d, want -1,4 +1,4", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	}
+	wantKinds := []DiffLineKind{DiffLineContext, DiffLineDelete, DiffLineAdd, DiffLineContext, DiffLineAdd}
+	if len(h.Lines) != len(wantKinds) {
+		t.Fatalf("got %d lines, want %d", len(h.Lines), len(wantKinds))
+	}
+	for i, k := range wantKinds {
+		if h.Lines[i].Kind != k {
+			t.Errorf("line %d kind = %s, want %s", i, h.Lines[i].Kind, k)
+		}
+	}
+
+	del, add := h.Lines[1], h.Lines[2]
+	if del.OldLine != 2 || del.NewLine != 0 || add.NewLine != 2 || add.OldLine != 0 {
+		t.Errorf("line numbers: del old=%d new=%d, add old=%d new=%d", del.OldLine, del.NewLine, add.OldLine, add.NewLine)
+	}
+	if del.Pair == nil || *del.Pair != 2 || add.Pair == nil || *add.Pair != 1 {
+		t.Fatalf("changed lines not paired: %v %v", del.Pair, add.Pair)
+	}
+	wantDel := []DiffSegment{{Text: "x := "}, {Text: "oldName", Changed: true}, {Text: "(1)"}}
+	wantAdd := []DiffSegment{{Text: "x := "}, {Text: "newName", Changed: true}, {Text: "(1)"}}
+	if !reflect.DeepEqual(del.Segments, wantDel) {
+		t.Errorf("delete segments = %+v, want %+v", del.Segments, wantDel)
+	}
+	if !reflect.DeepEqual(add.Segments, wantAdd) {
+		t.Errorf("add segments = %+v, want %+v", add.Segments, wantAdd)
+	}
+	// An addition with no deleted counterpart stays unpaired.
+	if h.Lines[4].Pair != nil || h.Lines[4].Segments != nil || h.Lines[4].NewLine != 4 {
+		t.Errorf("unpaired add = %+v", h.Lines[4])
+	}
+
+	h = hunks[1]
+	if h.OldLines != 1 || h.NewLines != 0 || len(h.Lines) != 1 || h.Lines[0].OldLine != 10 {
+		t.Errorf("second hunk = %+v", h)
+	}
+}
+
+func TestParseHunks_Binary(t *testing.T) {
+	diff := "diff --git a/img.png b/img.png\nindex 1..2 100644\nBinary files a/img.png and b/img.png differ\n"
+	hunks, binary := ParseHunks(diff)
+	if !binary || hunks != nil {
+		t.Errorf("got hunks=%v binary=%v, want binary with no hunks", hunks, binary)
+	}
+}
+
+func TestWordDiff_LongLinesChangedWhole(t *testing.T) {
+	long := ""
+	for i := 0; i < maxWordDiffTokens; i++ {
+		long += "a "
+	}
+	oldSegs, newSegs := wordDiff(long, long+"b")
+	if len(oldSegs) != 1 || !oldSegs[0].Changed || len(newSegs) != 1 || !newSegs[0].Changed {
+		t.Errorf("expected whole-line segments, got %d/%d", len(oldSegs), len(newSegs))
+	}
+}
+
+func TestDiffWithContent_Hunks(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	testFile := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(testFile, []byte("hello world\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	runGit(t, dir, "add", "test.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "initial")
+	if err := os.WriteFile(testFile, []byte("hello there\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	result, err := DiffWithContent(dir, "test.txt", DiffOptions{})
+	if err != nil {
+		t.Fatalf("DiffWithContent() error: %v", err)
+	}
+	if len(result.Hunks) != 1 || len(result.Hunks[0].Lines) != 2 {
+		t.Fatalf("hunks = %+v, want one hunk with a changed pair", result.Hunks)
+	}
+	add := result.Hunks[0].Lines[1]
+	want := []DiffSegment{{Text: "hello "}, {Text: "there", Changed: true}}
+	if !reflect.DeepEqual(add.Segments, want) {
+		t.Errorf("segments = %+v, want %+v", add.Segments, want)
+	}
+}
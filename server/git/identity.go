@@ -0,0 +1,74 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Signing formats accepted for Identity.SigningFormat (git's gpg.format).
+const (
+	SigningFormatOpenPGP = "openpgp"
+	SigningFormatSSH     = "ssh"
+)
+
+// Identity is the author/committer identity and optional signing key that
+// commits made in worktrees should carry, regardless of the host git config.
+type Identity struct {
+	Name  string
+	Email string
+	// SigningKey is an SSH key path or GPG key ID (git's user.signingkey).
+	// Empty disables signing.
+	SigningKey    string
+	SigningFormat string
+}
+
+// Validate rejects values git would misparse.
+func (id Identity) Validate() error {
+	for field, v := range map[string]string{"name": id.Name, "email": id.Email, "signing key": id.SigningKey} {
+		if strings.ContainsAny(v, "\r\n\x00") {
+			return fmt.Errorf("git %s must be a single line", field)
+		}
+	}
+	switch id.SigningFormat {
+	case "", SigningFormatOpenPGP, SigningFormatSSH:
+	default:
+		return fmt.Errorf("invalid git signing format %q", id.SigningFormat)
+	}
+	if id.SigningFormat != "" && id.SigningKey == "" {
+		return fmt.Errorf("git signing format requires a signing key")
+	}
+	return nil
+}
+
+// Env returns environment entries that apply the identity to any git
+// process (including those an agent spawns). Author/committer use git's
+// dedicated variables; signing uses GIT_CONFIG_COUNT (git 2.31+) so it
+// overrides config without touching any file. Returns nil when empty.
+func (id Identity) Env() []string {
+	var env []string
+	if id.Name != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+id.Name, "GIT_COMMITTER_NAME="+id.Name)
+	}
+	if id.Email != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+id.Email, "GIT_COMMITTER_EMAIL="+id.Email)
+	}
+
+	if id.SigningKey == "" {
+		return env
+	}
+	config := [][2]string{
+		{"user.signingkey", id.SigningKey},
+		{"commit.gpgsign", "true"},
+		{"tag.gpgsign", "true"},
+	}
+	if id.SigningFormat != "" {
+		config = append(config, [2]string{"gpg.format", id.SigningFormat})
+	}
+	env = append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(len(config)))
+	for i, kv := range config {
+		n := strconv.Itoa(i)
+		env = append(env, "GIT_CONFIG_KEY_"+n+"="+kv[0], "GIT_CONFIG_VALUE_"+n+"="+kv[1])
+	}
+	return env
+}
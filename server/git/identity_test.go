@@ -0,0 +1,88 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIdentityEnv_Empty(t *testing.T) {
+	if env := (Identity{}).Env(); env != nil {
+		t.Errorf("expected nil env for empty identity, got %v", env)
+	}
+}
+
+func TestIdentityEnv_SigningConfig(t *testing.T) {
+	env := Identity{SigningKey: "~/.ssh/id_ed25519.pub", SigningFormat: SigningFormatSSH}.Env()
+
+	for _, want := range []string{
+		"GIT_CONFIG_COUNT=4",
+		"GIT_CONFIG_KEY_0=user.signingkey",
+		"GIT_CONFIG_VALUE_0=~/.ssh/id_ed25519.pub",
+		"GIT_CONFIG_KEY_1=commit.gpgsign",
+		"GIT_CONFIG_VALUE_1=true",
+		"GIT_CONFIG_KEY_3=gpg.format",
+		"GIT_CONFIG_VALUE_3=ssh",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("expected %q in env %v", want, env)
+		}
+	}
+}
+
+func TestIdentityEnv_AppliesToCommits(t *testing.T) {
+	dir := t.TempDir()
+	run := func(env []string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	run(nil, "init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(nil, "add", "a.txt")
+
+	env := Identity{Name: "Agent Smith", Email: "agent@example.com"}.Env()
+	run(env, "commit", "-q", "-m", "init")
+
+	got := run(nil, "log", "-1", "--format=%an <%ae> / %cn <%ce>")
+	want := "Agent Smith <agent@example.com> / Agent Smith <agent@example.com>"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestIdentityValidate(t *testing.T) {
+	valid := []Identity{
+		{},
+		{Name: "A", Email: "a@example.com"},
+		{SigningKey: "ABCDEF", SigningFormat: SigningFormatOpenPGP},
+	}
+	for _, id := range valid {
+		if err := id.Validate(); err != nil {
+			t.Errorf("Validate(%+v): unexpected error %v", id, err)
+		}
+	}
+
+	invalid := []Identity{
+		{Name: "A\nB"},
+		{SigningKey: "k", SigningFormat: "x509-ish"},
+		{SigningFormat: SigningFormatSSH},
+	}
+	for _, id := range invalid {
+		if err := id.Validate(); err == nil {
+			t.Errorf("Validate(%+v): expected error", id)
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package git
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseLogOutput(t *testing.T) {
@@ -211,3 +218,67 @@ func TestValidateCommitHash(t *testing.T) {
 		})
 	}
 }
+
+func TestLog_FiltersAndPages(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if page, err := Log(ctx, dir, LogOptions{}); err != nil || len(page.Commits) != 0 {
+		t.Fatalf("Log on empty repo = %+v, %v; want no commits", page, err)
+	}
+
+	commitFile(t, dir, "a.txt", "1")
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, dir, "src/b.txt", "1")
+	commitFile(t, dir, "src/c.txt", "1")
+	runGit(t, dir, "-c", "user.name=Other", "commit", "--no-gpg-sign", "--allow-empty", "-m", "by other")
+
+	subjects := func(page LogPage) []string {
+		var s []string
+		for _, c := range page.Commits {
+			s = append(s, c.Subject)
+		}
+		return s
+	}
+
+	page, err := Log(ctx, dir, LogOptions{Paths: []string{"src"}})
+	if err != nil {
+		t.Fatalf("Log(src): %v", err)
+	}
+	if got := subjects(page); !slices.Equal(got, []string{"add src/c.txt", "add src/b.txt"}) || page.NextCursor != "" {
+		t.Errorf("Log(src) = %v, cursor %q; want the two src commits, last page", got, page.NextCursor)
+	}
+
+	page, err = Log(ctx, dir, LogOptions{Author: "Other"})
+	if err != nil || !slices.Equal(subjects(page), []string{"by other"}) {
+		t.Errorf("Log(author Other) = %v, %v", subjects(page), err)
+	}
+
+	page, err = Log(ctx, dir, LogOptions{Until: time.Now().Add(-24 * time.Hour)})
+	if err != nil || len(page.Commits) != 0 {
+		t.Errorf("Log(until yesterday) = %v, %v; want none", subjects(page), err)
+	}
+
+	// Commits made while paging don't shift later pages.
+	first, err := Log(ctx, dir, LogOptions{Limit: 3})
+	if err != nil || len(first.Commits) != 3 || first.NextCursor == "" {
+		t.Fatalf("first page = %v, cursor %q, %v", subjects(first), first.NextCursor, err)
+	}
+	commitFile(t, dir, "d.txt", "1")
+	second, err := Log(ctx, dir, LogOptions{Limit: 3, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+	if got := subjects(second); !slices.Equal(got, []string{"add a.txt"}) || second.NextCursor != "" {
+		t.Errorf("second page = %v, cursor %q; want the oldest commit, last page", got, second.NextCursor)
+	}
+
+	for _, cursor := range []string{"HEAD:3", "abc:1", strings.Repeat("a", 40) + ":-1"} {
+		if _, err := Log(ctx, dir, LogOptions{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Log(cursor %q) error = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ErrHunkNotFound is returned by AddHunks when a requested hunk is not in
+// the file's current unstaged diff, typically because the file changed after
+// the client fetched the diff.
+var ErrHunkNotFound = errors.New("hunk not found in current diff")
+
+// HunkRange identifies a hunk by the range in its header, as reported in
+// DiffHunk.
+type HunkRange struct {
+	OldStart int `json:"old_start"`
+	OldLines int `json:"old_lines"`
+	NewStart int `json:"new_start"`
+	NewLines int `json:"new_lines"`
+}
+
+// Range returns the hunk's header range, the key AddHunks selects it by.
+func (h DiffHunk) Range() HunkRange {
+	return HunkRange{OldStart: h.OldStart, OldLines: h.OldLines, NewStart: h.NewStart, NewLines: h.NewLines}
+}
+
+// rawHunk is a hunk as text, kept verbatim (including "\ No newline" markers)
+// so it can be fed back to git apply.
+type rawHunk struct {
+	HunkRange
+	section string   // text after the closing "@@", e.g. the function name
+	body    []string // lines after the header
+}
+
+// AddHunks stages the given hunks of path's unstaged changes and leaves the
+// rest unstaged. The ranges must match hunks of the current diff without
+// hide_whitespace, since a whitespace-blind diff cannot be applied; if any
+// does not, nothing is staged and ErrHunkNotFound is returned.
+// For submodule paths (e.g., "submodule/path/to/file"), it stages inside the submodule.
+func AddHunks(dir, path string, ranges []HunkRange) error {
+	if err := validatePath(path); err != nil {
+		return err
+	}
+
+	diff, err := Diff(dir, path, DiffOptions{})
+	if err != nil {
+		return err
+	}
+	header, hunks := splitRawHunks(diff)
+
+	selected := make(map[HunkRange]bool, len(ranges))
+	for _, r := range ranges {
+		selected[r] = true
+	}
+	found := 0
+	for _, h := range hunks {
+		if selected[h.HunkRange] {
+			found++
+		}
+	}
+	if found != len(selected) {
+		return fmt.Errorf("%w: %s", ErrHunkNotFound, path)
+	}
+
+	actualDir, _ := resolveSubmodulePath(dir, path)
+	cmd := exec.Command("git", "apply", "--cached", "-")
+	cmd.Dir = actualDir
+	cmd.Stdin = strings.NewReader(buildPatch(header, hunks, selected))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git apply --cached failed: %w (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+// buildPatch joins the file header with the selected hunks. Each selected
+// hunk's new start is moved back by the lines the skipped hunks before it
+// would have added, so the patch describes the index after applying only
+// the selection.
+func buildPatch(header string, hunks []rawHunk, selected map[HunkRange]bool) string {
+	sort.SliceStable(hunks, func(i, j int) bool { return hunks[i].OldStart < hunks[j].OldStart })
+
+	var b strings.Builder
+	b.WriteString(header)
+	skipped := 0
+	for _, h := range hunks {
+		if !selected[h.HunkRange] {
+			skipped += h.NewLines - h.OldLines
+			continue
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@%s\n", h.OldStart, h.OldLines, h.NewStart-skipped, h.NewLines, h.section)
+		for _, line := range h.body {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// splitRawHunks splits a single-file unified diff into its file header
+// (everything before the first hunk, newline-terminated) and its hunks.
+func splitRawHunks(diff string) (string, []rawHunk) {
+	var header strings.Builder
+	var hunks []rawHunk
+	for _, line := range strings.Split(diff, "\n") {
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			hunks = append(hunks, rawHunk{
+				HunkRange: HunkRange{
+					OldStart: atoiDefault(m[1], 0),
+					OldLines: atoiDefault(m[2], 1),
+					NewStart: atoiDefault(m[3], 0),
+					NewLines: atoiDefault(m[4], 1),
+				},
+				section: strings.TrimPrefix(line, m[0]),
+			})
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if len(hunks) == 0 {
+			header.WriteString(line)
+			header.WriteByte('\n')
+			continue
+		}
+		last := &hunks[len(hunks)-1]
+		last.body = append(last.body, line)
+	}
+	return header.String(), hunks
+}
@@ -0,0 +1,115 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func numberedLines(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	return lines
+}
+
+func writeLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runGitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func TestAddHunks(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	file := filepath.Join(dir, "f.txt")
+	lines := numberedLines(30)
+	writeLines(t, file, lines)
+	runGit(t, dir, "add", "f.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "initial")
+
+	// The first hunk adds lines, so the second one's new start shifts.
+	modified := append([]string{}, lines[:2]...)
+	modified = append(modified, "inserted a", "inserted b")
+	modified = append(modified, lines[2:]...)
+	modified[26] = "changed 25"
+	writeLines(t, file, modified)
+
+	result, err := DiffWithContent(dir, "f.txt", DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(result.Hunks))
+	}
+
+	if err := AddHunks(dir, "f.txt", []HunkRange{result.Hunks[1].Range()}); err != nil {
+		t.Fatalf("AddHunks: %v", err)
+	}
+
+	staged := runGitOutput(t, dir, "diff", "--cached")
+	if !strings.Contains(staged, "+changed 25") || strings.Contains(staged, "inserted") {
+		t.Errorf("staged diff should hold only the second hunk:\n%s", staged)
+	}
+	unstaged := runGitOutput(t, dir, "diff")
+	if !strings.Contains(unstaged, "+inserted a") || strings.Contains(unstaged, "changed 25") {
+		t.Errorf("unstaged diff should hold only the first hunk:\n%s", unstaged)
+	}
+}
+
+func TestAddHunks_Stale(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	file := filepath.Join(dir, "f.txt")
+	writeLines(t, file, numberedLines(5))
+	runGit(t, dir, "add", "f.txt")
+	runGit(t, dir, "commit", "--no-gpg-sign", "-m", "initial")
+
+	writeLines(t, file, append(numberedLines(5), "added"))
+	stale := HunkRange{OldStart: 1, OldLines: 5, NewStart: 1, NewLines: 7}
+	if err := AddHunks(dir, "f.txt", []HunkRange{stale}); !errors.Is(err, ErrHunkNotFound) {
+		t.Errorf("AddHunks = %v, want ErrHunkNotFound", err)
+	}
+	if staged := runGitOutput(t, dir, "diff", "--cached"); staged != "" {
+		t.Errorf("a refused request staged changes:\n%s", staged)
+	}
+}
+
+func TestAddHunks_UntrackedFile(t *testing.T) {
+	dir, cleanup := setupTestRepo(t)
+	defer cleanup()
+
+	writeLines(t, filepath.Join(dir, "new.txt"), []string{"a", "b"})
+	result, err := DiffWithContent(dir, "new.txt", DiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hunks) != 1 {
+		t.Fatalf("got %d hunks, want 1", len(result.Hunks))
+	}
+	if err := AddHunks(dir, "new.txt", []HunkRange{result.Hunks[0].Range()}); err != nil {
+		t.Fatalf("AddHunks: %v", err)
+	}
+	if staged := runGitOutput(t, dir, "diff", "--cached", "--name-only"); strings.TrimSpace(staged) != "new.txt" {
+		t.Errorf("staged files = %q, want new.txt", staged)
+	}
+}
@@ -11,11 +11,9 @@ require (
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/mdp/qrterminal/v3 v3.2.1
 	github.com/sourcegraph/jsonrpc2 v0.2.1
+	golang.org/x/sys v0.29.0
 	golang.org/x/term v0.13.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	golang.org/x/sys v0.29.0 // indirect
-	rsc.io/qr v0.2.0 // indirect
-)
+require rsc.io/qr v0.2.0 // indirect
@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pockode/server/fslock"
+)
+
+var (
+	errTimedOut  = errors.New("timed out")
+	errPanicked  = errors.New("check panicked")
+	errNotInPath = errors.New("not found in PATH")
+)
+
+// probeFile prefixes the temporary file DataDirWritable writes and removes,
+// and names the lock file LockAcquirable takes (left in place, like the
+// stores' own lock files).
+const probeFile = ".readyz"
+
+// ProbeLockFile is the name of the lock file LockAcquirable leaves in the
+// data directory. It has no data file by design.
+const ProbeLockFile = probeFile + ".lock"
+
+// DataDirWritable checks that a file can be created, synced and removed in
+// dir, catching read-only mounts and full disks before a store write fails.
+func DataDirWritable(dir string) Check {
+	return Check{Name: "data_dir", Run: func(context.Context) error {
+		f, err := os.CreateTemp(dir, probeFile+"-*")
+		if err != nil {
+			return fmt.Errorf("not writable: %w", withoutPath(err))
+		}
+		_, err = f.Write([]byte("ok"))
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if removeErr := os.Remove(f.Name()); err == nil {
+			err = removeErr
+		}
+		if err != nil {
+			return fmt.Errorf("not writable: %w", withoutPath(err))
+		}
+		return nil
+	}}
+}
+
+// LockAcquirable checks that an exclusive file lock can be taken in dir.
+// The stores rely on flock for inter-process safety, which some network
+// filesystems do not support.
+func LockAcquirable(dir string) Check {
+	return Check{Name: "file_lock", Run: func(context.Context) error {
+		lock, err := fslock.Exclusive(filepath.Join(dir, ProbeLockFile))
+		if err != nil {
+			return fmt.Errorf("cannot lock: %w", withoutPath(err))
+		}
+		if err := lock.Unlock(); err != nil {
+			return fmt.Errorf("cannot unlock: %w", withoutPath(err))
+		}
+		return nil
+	}}
+}
+
+// Binary checks that the executable returned by name() is found in PATH.
+// name is evaluated per request, so it can follow a settings change.
+func Binary(checkName string, name func() string) Check {
+	return Check{Name: checkName, Run: func(context.Context) error {
+		bin := name()
+		if _, err := exec.LookPath(bin); err != nil {
+			return fmt.Errorf("%s %w", bin, errNotInPath)
+		}
+		return nil
+	}}
+}
+
+// withoutPath strips the file path from filesystem errors, keeping only the
+// cause ("permission denied"), since probe output is public.
+func withoutPath(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return linkErr.Err
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+// Package health serves the liveness (/healthz) and readiness (/readyz)
+// probes used by reverse proxies and uptime monitors.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// IsProbePath reports whether path is a probe, which monitors call without
+// the auth token.
+func IsProbePath(path string) bool {
+	return path == LivenessPath || path == ReadinessPath
+}
+
+// checkTimeout bounds each readiness check. A check that blocks longer (e.g.
+// a lock held by a stuck process) counts as failed; its goroutine is left to
+// finish on its own.
+const checkTimeout = 5 * time.Second
+
+// reportTTL is how long a readiness report is reused. /readyz is public and
+// relayed, and its checks write to the data dir and take a file lock, so a
+// caller polling it cannot turn it into a stream of disk writes.
+const reportTTL = 5 * time.Second
+
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+// Check is one readiness condition. Run returns nil when it holds. Errors
+// are shown to unauthenticated callers, so they should not include paths or
+// other local details.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name       string `json:"name"`
+	Status     Status `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report is the readiness response body. Status is ok only if every check
+// passed.
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Run runs the checks concurrently and reports them in the given order.
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: StatusOK, Checks: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range report.Checks {
+		if r.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("health check panicked", "check", c.Name, "panic", r)
+				done <- errPanicked
+			}
+		}()
+		done <- c.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errTimedOut
+	}
+	result := Result{Name: c.Name, Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler reports that the process is up and serving HTTP. It checks
+// nothing else, so a restart is only triggered when the server is wedged.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]Status{"status": StatusOK})
+	})
+}
+
+// ReadinessHandler runs the checks, at most once per reportTTL, and answers
+// 200 when all pass, 503 otherwise, with the per-check report either way.
+// Requests arriving while the checks run wait for and share that report.
+func ReadinessHandler(checks ...Check) http.Handler {
+	var (
+		mu    sync.Mutex
+		last  Report
+		ranAt time.Time
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if ranAt.IsZero() || time.Since(ranAt) >= reportTTL {
+			// The report is shared, so one caller hanging up must not
+			// fail it for the others.
+			last = Run(context.WithoutCancel(r.Context()), checks)
+			ranAt = time.Now()
+		}
+		report := last
+		mu.Unlock()
+
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+			for _, c := range report.Checks {
+				if c.Status != StatusOK {
+					slog.Warn("readiness check failed", "check", c.Name, "error", c.Error)
+				}
+			}
+		}
+		writeJSON(w, code, report)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("failed to write health response", "error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	dir := t.TempDir()
+	ok := ReadinessHandler(DataDirWritable(dir), LockAcquirable(dir), Binary("cli", func() string { return "go" }))
+
+	rec := httptest.NewRecorder()
+	ok.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusOK || len(report.Checks) != 3 || report.Checks[0].Name != "data_dir" {
+		t.Errorf("report = %+v", report)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != probeFile+".lock" {
+			t.Errorf("probe left %s behind", e.Name())
+		}
+	}
+
+	failing := ReadinessHandler(
+		Check{Name: "store", Run: func(context.Context) error { return errors.New("corrupt index") }},
+		Binary("cli", func() string { return "pockode-no-such-binary" }),
+	)
+	rec = httptest.NewRecorder()
+	failing.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	report = Report{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusFail || report.Checks[0].Error != "corrupt index" || report.Checks[1].Error != "pockode-no-such-binary not found in PATH" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestReadinessHandler_ReusesRecentReport(t *testing.T) {
+	var runs atomic.Int32
+	h := ReadinessHandler(Check{Name: "count", Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", rec.Code)
+		}
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("checks ran %d times for back-to-back requests, want 1", n)
+	}
+}
+
+func TestDataDirWritable_HidesPath(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	err := DataDirWritable(missing).Run(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+	if got := err.Error(); got != "not writable: no such file or directory" {
+		t.Errorf("error = %q, want it without the path", got)
+	}
+}
+
+func TestRun_TimesOut(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	report := Run(ctx, []Check{{Name: "slow", Run: func(context.Context) error {
+		<-block
+		return nil
+	}}})
+	if report.Status != StatusFail || report.Checks[0].Error != "timed out" {
+		t.Errorf("report = %+v", report)
+	}
+}
@@ -0,0 +1,77 @@
+// Package i18n provides the locales the server speaks and the catalog that
+// translates its user-facing messages.
+package i18n
+
+import (
+	_ "embed"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Locale string
+
+const (
+	English  Locale = "en"
+	Japanese Locale = "ja"
+)
+
+// Default is the source language of every server message.
+const Default = English
+
+// Supported lists the locales with a catalog, Default first.
+var Supported = []Locale{English, Japanese}
+
+func (l Locale) IsValid() bool {
+	for _, s := range Supported {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Or returns l, or fallback when l is empty.
+func (l Locale) Or(fallback Locale) Locale {
+	if l == "" {
+		return fallback
+	}
+	return l
+}
+
+// Match returns the supported locale for a language tag such as "ja",
+// "ja-JP" or "en_US", or "" when the language is not supported, so callers
+// can fall back to a configured default.
+func Match(tag string) Locale {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	if l := Locale(lang); l.IsValid() {
+		return l
+	}
+	return ""
+}
+
+//go:embed ja.yaml
+var jaYAML []byte
+
+// catalogs maps English source text to its translation, per locale.
+// English needs no catalog.
+var catalogs = map[Locale]map[string]string{}
+
+func init() {
+	var ja map[string]string
+	if err := yaml.Unmarshal(jaYAML, &ja); err != nil {
+		panic("failed to parse ja.yaml: " + err.Error())
+	}
+	catalogs[Japanese] = ja
+}
+
+// T translates msg, given in English, into l. Messages without a
+// translation are returned unchanged, so untranslated or dynamic text still
+// reaches the user in English.
+func T(l Locale, msg string) string {
+	if t, ok := catalogs[l][msg]; ok {
+		return t
+	}
+	return msg
+}
@@ -0,0 +1,58 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	for tag, want := range map[string]Locale{
+		"ja":     Japanese,
+		"ja-JP":  Japanese,
+		"JA_jp":  Japanese,
+		" en-US": English,
+		"fr":     "",
+		"":       "",
+	} {
+		if got := Match(tag); got != want {
+			t.Errorf("Match(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T(Japanese, "session not found"); got != "セッションが見つかりません" {
+		t.Errorf("T(ja) = %q", got)
+	}
+	if got := T(English, "session not found"); got != "session not found" {
+		t.Errorf("T(en) = %q", got)
+	}
+	if got := T(Japanese, "no such message"); got != "no such message" {
+		t.Errorf("untranslated = %q, want source text", got)
+	}
+	if got := T("", "session not found"); got != "session not found" {
+		t.Errorf("empty locale = %q, want source text", got)
+	}
+}
+
+func TestCatalogs(t *testing.T) {
+	for loc, catalog := range catalogs {
+		if !loc.IsValid() {
+			t.Errorf("catalog for unsupported locale %q", loc)
+		}
+		for src, msg := range catalog {
+			if strings.TrimSpace(msg) == "" {
+				t.Errorf("%s: empty translation for %q", loc, src)
+			}
+		}
+	}
+}
+
+func TestLocaleOr(t *testing.T) {
+	if got := Locale("").Or(Japanese); got != Japanese {
+		t.Errorf("empty Or = %q", got)
+	}
+	if got := English.Or(Japanese); got != English {
+		t.Errorf("set Or = %q", got)
+	}
+}
@@ -0,0 +1,16 @@
+package cmdoutput
+
+import "strings"
+
+// TailSize is how much of a command's output is kept once it finishes, for
+// setup step status and hook audit entries alike.
+const TailSize = 4 * 1024
+
+// Tail trims s and keeps its last TailSize bytes, marking a cut with "…".
+func Tail(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= TailSize {
+		return s
+	}
+	return "…" + s[len(s)-TailSize:]
+}
@@ -0,0 +1,21 @@
+package cmdoutput
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTail(t *testing.T) {
+	if got := Tail("  short\n"); got != "short" {
+		t.Errorf("Tail(short) = %q, want %q", got, "short")
+	}
+
+	long := strings.Repeat("a", TailSize) + "end"
+	got := Tail(long)
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "end") {
+		t.Errorf("Tail(long) = %q…, want a marked cut keeping the end", got[:16])
+	}
+	if len(got) != len("…")+TailSize {
+		t.Errorf("len(Tail(long)) = %d, want %d", len(got), len("…")+TailSize)
+	}
+}
@@ -0,0 +1,22 @@
+// Package procgroup makes cancelling a shell command stop everything it
+// started. exec.CommandContext alone kills only the shell, and Wait then
+// blocks until children such as the sleep in "sleep 30; echo done" exit and
+// close the output pipe.
+package procgroup
+
+import (
+	"os/exec"
+	"time"
+)
+
+// waitDelay bounds how long Wait keeps reading output once the command is
+// cancelled or has exited, for descendants that left the group but still
+// hold the pipe.
+const waitDelay = time.Second
+
+// Set makes cmd run in its own process group, killed as a whole when cmd's
+// context is done. Call before starting cmd.
+func Set(cmd *exec.Cmd) {
+	setGroup(cmd)
+	cmd.WaitDelay = waitDelay
+}
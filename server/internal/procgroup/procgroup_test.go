@@ -0,0 +1,26 @@
+//go:build !windows
+
+package procgroup
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestSet_CancelStopsCompoundCommand(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", "sleep 10; echo done")
+	Set(cmd)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("CombinedOutput() = %q, nil; want the command killed", output)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("cancelled command returned after %s, want well under the 10s sleep", elapsed)
+	}
+}
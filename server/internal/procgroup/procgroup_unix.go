@@ -0,0 +1,16 @@
+//go:build !windows
+
+package procgroup
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// The shell leads the group, so its PID is the group ID.
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package procgroup
+
+import "os/exec"
+
+// setGroup leaves the default Cancel, which kills only the process;
+// WaitDelay still keeps Wait from blocking on its children.
+func setGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,172 @@
+// Package issuesync keeps work items and issues in Jira or Linear in step:
+// each unfinished work item gets an issue, and edits on either side are
+// carried to the other on every poll, the more recent side winning a
+// conflict.
+package issuesync
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pockode/server/work"
+)
+
+// DefaultInterval polls often enough that a tracker edit shows up while
+// it is still relevant, and costs one request per 50 linked items.
+const DefaultInterval = time.Minute
+
+type ProviderName string
+
+const (
+	ProviderJira   ProviderName = "jira"
+	ProviderLinear ProviderName = "linear"
+)
+
+// IsValid reports whether p is a known provider; empty (sync off) is valid.
+func (p ProviderName) IsValid() bool {
+	return p == "" || p == ProviderJira || p == ProviderLinear
+}
+
+// Work fields that can be mapped to tracker fields.
+const (
+	FieldTitle = "title"
+	FieldBody  = "body"
+	FieldDueAt = "due_at"
+)
+
+// dueDateLayout is how both trackers write a due date.
+const dueDateLayout = "2006-01-02"
+
+var defaultFieldMaps = map[ProviderName]map[string]string{
+	ProviderJira:   {FieldTitle: "summary", FieldBody: "description", FieldDueAt: "duedate"},
+	ProviderLinear: {FieldTitle: "title", FieldBody: "description", FieldDueAt: "dueDate"},
+}
+
+// linearFields are the issue fields the Linear client reads and writes.
+var linearFields = []string{"title", "description", "dueDate"}
+
+var defaultStatusMaps = map[ProviderName]map[work.WorkStatus]string{
+	ProviderJira: {
+		work.StatusOpen:           "To Do",
+		work.StatusInProgress:     "In Progress",
+		work.StatusNeedsInput:     "In Progress",
+		work.StatusWaiting:        "In Progress",
+		work.StatusStopped:        "In Progress",
+		work.StatusNeedsAttention: "In Progress",
+		work.StatusClosed:         "Done",
+		// Jira's default workflow has no cancelled status.
+	},
+	ProviderLinear: {
+		work.StatusOpen:           "Todo",
+		work.StatusInProgress:     "In Progress",
+		work.StatusNeedsInput:     "In Progress",
+		work.StatusWaiting:        "In Progress",
+		work.StatusStopped:        "In Progress",
+		work.StatusNeedsAttention: "In Progress",
+		work.StatusClosed:         "Done",
+		work.StatusCancelled:      "Canceled",
+	},
+}
+
+// Config is the user-configured part of sync. Credentials are separate
+// (Credentials) so they never land in the settings file.
+type Config struct {
+	Provider ProviderName // empty disables sync
+	JiraURL  string       // site URL, e.g. https://example.atlassian.net
+	Project  string       // Jira project key or Linear team key
+	// Comma- or newline-separated work_field=tracker_field pairs
+	// ("title=summary,body=description"). Empty = the provider's default;
+	// otherwise it replaces the default and must map title.
+	FieldMap string
+	// Comma- or newline-separated work_status=tracker_status pairs
+	// ("open=Backlog,closed=Shipped"), overriding the provider's default
+	// per status.
+	StatusMap string
+	Interval  time.Duration // 0 = DefaultInterval
+}
+
+// Enabled reports whether a provider is chosen.
+func (c Config) Enabled() bool {
+	return c.Provider != ""
+}
+
+// ValidateJiraURL checks the site URL Jira sync needs.
+func ValidateJiraURL(p ProviderName, u string) error {
+	if p != ProviderJira {
+		return nil
+	}
+	if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		return errors.New("jira URL must be an http(s) URL")
+	}
+	return nil
+}
+
+// ValidateProject checks the project or team key every provider needs.
+func ValidateProject(p ProviderName, project string) error {
+	if p != "" && strings.TrimSpace(project) == "" {
+		return errors.New("sync project is required")
+	}
+	return nil
+}
+
+// ParseFieldMap returns the work field → tracker field map for provider p.
+func ParseFieldMap(p ProviderName, s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return defaultFieldMaps[p], nil
+	}
+	m := make(map[string]string)
+	for _, pair := range splitList(s) {
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || to == "" {
+			return nil, fmt.Errorf("invalid field mapping %q: use work_field=tracker_field", pair)
+		}
+		if from != FieldTitle && from != FieldBody && from != FieldDueAt {
+			return nil, fmt.Errorf("unknown work field %q: use title, body, or due_at", from)
+		}
+		if p == ProviderLinear && !slices.Contains(linearFields, to) {
+			return nil, fmt.Errorf("unsupported Linear field %q: use title, description, or dueDate", to)
+		}
+		m[from] = to
+	}
+	if m[FieldTitle] == "" {
+		return nil, errors.New("field mapping must map title")
+	}
+	return m, nil
+}
+
+// ParseStatusMap returns the work status → tracker status map for
+// provider p: the default with s applied over it. A status mapped to
+// nothing leaves the issue where it is.
+func ParseStatusMap(p ProviderName, s string) (map[work.WorkStatus]string, error) {
+	m := maps.Clone(defaultStatusMaps[p])
+	if m == nil {
+		m = make(map[work.WorkStatus]string)
+	}
+	for _, pair := range splitList(s) {
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || to == "" {
+			return nil, fmt.Errorf("invalid status mapping %q: use work_status=tracker_status", pair)
+		}
+		if !work.ValidateStatus(work.WorkStatus(from)) {
+			return nil, fmt.Errorf("unknown work status %q", from)
+		}
+		m[work.WorkStatus(from)] = to
+	}
+	return m, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
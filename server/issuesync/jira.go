@@ -0,0 +1,251 @@
+package issuesync
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pockode/server/work"
+)
+
+// jiraTimeLayout is how Jira writes timestamps ("2026-10-15T09:30:00.000+0000").
+const jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// jiraIssueTypes names the issue type each work type is filed as; both
+// exist in Jira Software's default schemes.
+var jiraIssueTypes = map[work.WorkType]string{
+	work.WorkTypeStory: "Story",
+	work.WorkTypeTask:  "Task",
+}
+
+// jiraClient talks to the Jira REST API v2, which takes descriptions as
+// plain text (v3 wants Atlassian Document Format).
+type jiraClient struct {
+	client     *http.Client
+	baseURL    string
+	project    string
+	auth       string
+	fieldNames []string
+}
+
+func newJiraClient(client *http.Client, baseURL, project, email, token string, fieldNames []string) *jiraClient {
+	auth := "Bearer " + token
+	if email != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(email+":"+token))
+	}
+	return &jiraClient{
+		client:     client,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		project:    project,
+		auth:       auth,
+		fieldNames: fieldNames,
+	}
+}
+
+type jiraIssue struct {
+	ID     string                     `json:"id"`
+	Key    string                     `json:"key"`
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+func (c *jiraClient) Create(ctx context.Context, workType work.WorkType, fields map[string]string, status string) (Issue, error) {
+	body := c.fieldValues(fields)
+	body["project"] = map[string]string{"key": c.project}
+	body["issuetype"] = map[string]string{"name": jiraIssueTypes[workType]}
+
+	var created jiraIssue
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]any{"fields": body}, &created); err != nil {
+		return Issue{}, fmt.Errorf("create issue: %w", err)
+	}
+	if err := c.transition(ctx, created.Key, status); err != nil {
+		return Issue{}, err
+	}
+	return c.get(ctx, created.Key)
+}
+
+func (c *jiraClient) Update(ctx context.Context, id string, fields map[string]string, status string) (Issue, error) {
+	if len(fields) > 0 {
+		path := "/rest/api/2/issue/" + url.PathEscape(id)
+		if err := c.do(ctx, http.MethodPut, path, map[string]any{"fields": c.fieldValues(fields)}, nil); err != nil {
+			return Issue{}, fmt.Errorf("update issue %s: %w", id, err)
+		}
+	}
+	if err := c.transition(ctx, id, status); err != nil {
+		return Issue{}, err
+	}
+	return c.get(ctx, id)
+}
+
+func (c *jiraClient) Issues(ctx context.Context, ids []string) ([]Issue, error) {
+	var out []Issue
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		quoted := make([]string, len(batch))
+		for i, id := range batch {
+			quoted[i] = strconv.Quote(id)
+		}
+		req := map[string]any{
+			"jql":        "key in (" + strings.Join(quoted, ",") + ")",
+			"fields":     c.readFields(),
+			"maxResults": batchSize,
+		}
+		var resp struct {
+			Issues []jiraIssue `json:"issues"`
+		}
+		if err := c.do(ctx, http.MethodPost, "/rest/api/2/search/jql", req, &resp); err != nil {
+			return nil, fmt.Errorf("search issues: %w", err)
+		}
+		for _, ji := range resp.Issues {
+			out = append(out, c.toIssue(ji))
+		}
+	}
+	return out, nil
+}
+
+// transition moves an issue to the status named status, through the
+// workflow transition that leads there. Jira has no direct status write.
+func (c *jiraClient) transition(ctx context.Context, id, status string) error {
+	if status == "" {
+		return nil
+	}
+	current, err := c.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(current.Status, status) {
+		return nil
+	}
+
+	path := "/rest/api/2/issue/" + url.PathEscape(id) + "/transitions"
+	var resp struct {
+		Transitions []struct {
+			ID string `json:"id"`
+			To struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return fmt.Errorf("list transitions of %s: %w", id, err)
+	}
+	for _, t := range resp.Transitions {
+		if strings.EqualFold(t.To.Name, status) {
+			req := map[string]any{"transition": map[string]string{"id": t.ID}}
+			if err := c.do(ctx, http.MethodPost, path, req, nil); err != nil {
+				return fmt.Errorf("move %s to %q: %w", id, status, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no transition of %s leads to %q", id, status)
+}
+
+func (c *jiraClient) get(ctx context.Context, id string) (Issue, error) {
+	path := "/rest/api/2/issue/" + url.PathEscape(id) + "?fields=" + url.QueryEscape(strings.Join(c.readFields(), ","))
+	var ji jiraIssue
+	if err := c.do(ctx, http.MethodGet, path, nil, &ji); err != nil {
+		return Issue{}, fmt.Errorf("get issue %s: %w", id, err)
+	}
+	return c.toIssue(ji), nil
+}
+
+func (c *jiraClient) readFields() []string {
+	return append([]string{"status", "updated"}, c.fieldNames...)
+}
+
+// fieldValues converts mapped text fields to a Jira fields object. Empty
+// text clears the field.
+func (c *jiraClient) fieldValues(fields map[string]string) map[string]any {
+	out := make(map[string]any, len(fields))
+	for name, v := range fields {
+		if v == "" {
+			out[name] = nil
+			continue
+		}
+		out[name] = v
+	}
+	return out
+}
+
+func (c *jiraClient) toIssue(ji jiraIssue) Issue {
+	issue := Issue{
+		ID:     ji.Key,
+		Key:    ji.Key,
+		URL:    c.baseURL + "/browse/" + ji.Key,
+		Fields: make(map[string]string, len(c.fieldNames)),
+	}
+	var status struct {
+		Name string `json:"name"`
+	}
+	if raw, ok := ji.Fields["status"]; ok && json.Unmarshal(raw, &status) == nil {
+		issue.Status = status.Name
+	}
+	var updated string
+	if raw, ok := ji.Fields["updated"]; ok && json.Unmarshal(raw, &updated) == nil {
+		issue.UpdatedAt, _ = time.Parse(jiraTimeLayout, updated)
+	}
+	for _, name := range c.fieldNames {
+		issue.Fields[name] = jiraText(ji.Fields[name])
+	}
+	return issue
+}
+
+// jiraText renders a field value as text. Fields that are neither text nor
+// numbers (users, options, documents) read as empty, so only text-like
+// fields can be mapped usefully.
+func jiraText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+	return ""
+}
+
+func (c *jiraClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.auth)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package issuesync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/work"
+)
+
+const defaultLinearAPI = "https://api.linear.app/graphql"
+
+const linearIssueFields = `id identifier url updatedAt state { name } title description dueDate`
+
+// linearClient talks to the Linear GraphQL API. Linear issues have no
+// story/task distinction, so the work type is not carried over.
+type linearClient struct {
+	client   *http.Client
+	endpoint string
+	teamKey  string
+	token    string
+
+	// The team's ID and workflow states, looked up once.
+	teamMu sync.Mutex
+	teamID string
+	states map[string]string // lowercased state name → state ID
+}
+
+func newLinearClient(client *http.Client, endpoint, teamKey, token string) *linearClient {
+	return &linearClient{client: client, endpoint: endpoint, teamKey: teamKey, token: token}
+}
+
+type linearIssue struct {
+	ID         string    `json:"id"`
+	Identifier string    `json:"identifier"`
+	URL        string    `json:"url"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	State      struct {
+		Name string `json:"name"`
+	} `json:"state"`
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+	DueDate     *string `json:"dueDate"`
+}
+
+func (i linearIssue) toIssue() Issue {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return Issue{
+		ID:     i.ID,
+		Key:    i.Identifier,
+		URL:    i.URL,
+		Status: i.State.Name,
+		Fields: map[string]string{
+			"title":       i.Title,
+			"description": deref(i.Description),
+			"dueDate":     deref(i.DueDate),
+		},
+		UpdatedAt: i.UpdatedAt,
+	}
+}
+
+func (c *linearClient) Create(ctx context.Context, _ work.WorkType, fields map[string]string, status string) (Issue, error) {
+	teamID, err := c.team(ctx)
+	if err != nil {
+		return Issue{}, err
+	}
+	input, err := c.input(ctx, fields, status)
+	if err != nil {
+		return Issue{}, err
+	}
+	input["teamId"] = teamID
+
+	var resp struct {
+		IssueCreate struct {
+			Issue linearIssue `json:"issue"`
+		} `json:"issueCreate"`
+	}
+	query := `mutation($input: IssueCreateInput!) { issueCreate(input: $input) { issue { ` + linearIssueFields + ` } } }`
+	if err := c.do(ctx, query, map[string]any{"input": input}, &resp); err != nil {
+		return Issue{}, fmt.Errorf("create issue: %w", err)
+	}
+	return resp.IssueCreate.Issue.toIssue(), nil
+}
+
+func (c *linearClient) Update(ctx context.Context, id string, fields map[string]string, status string) (Issue, error) {
+	input, err := c.input(ctx, fields, status)
+	if err != nil {
+		return Issue{}, err
+	}
+	var resp struct {
+		IssueUpdate struct {
+			Issue linearIssue `json:"issue"`
+		} `json:"issueUpdate"`
+	}
+	query := `mutation($id: String!, $input: IssueUpdateInput!) { issueUpdate(id: $id, input: $input) { issue { ` + linearIssueFields + ` } } }`
+	if err := c.do(ctx, query, map[string]any{"id": id, "input": input}, &resp); err != nil {
+		return Issue{}, fmt.Errorf("update issue %s: %w", id, err)
+	}
+	return resp.IssueUpdate.Issue.toIssue(), nil
+}
+
+func (c *linearClient) Issues(ctx context.Context, ids []string) ([]Issue, error) {
+	var out []Issue
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+		var resp struct {
+			Issues struct {
+				Nodes []linearIssue `json:"nodes"`
+			} `json:"issues"`
+		}
+		query := `query($ids: [ID!], $first: Int) { issues(filter: { id: { in: $ids } }, first: $first) { nodes { ` + linearIssueFields + ` } } }`
+		if err := c.do(ctx, query, map[string]any{"ids": batch, "first": batchSize}, &resp); err != nil {
+			return nil, fmt.Errorf("list issues: %w", err)
+		}
+		for _, i := range resp.Issues.Nodes {
+			out = append(out, i.toIssue())
+		}
+	}
+	return out, nil
+}
+
+// input builds an issue input from mapped fields and a state name. Empty
+// text clears the field.
+func (c *linearClient) input(ctx context.Context, fields map[string]string, status string) (map[string]any, error) {
+	input := make(map[string]any, len(fields)+1)
+	for name, v := range fields {
+		if v == "" && name != "title" {
+			input[name] = nil
+			continue
+		}
+		input[name] = v
+	}
+	if status != "" {
+		if _, err := c.team(ctx); err != nil {
+			return nil, err
+		}
+		stateID, ok := c.states[strings.ToLower(status)]
+		if !ok {
+			return nil, fmt.Errorf("team %s has no workflow state %q", c.teamKey, status)
+		}
+		input["stateId"] = stateID
+	}
+	return input, nil
+}
+
+// team looks up the team ID and its workflow states by team key.
+func (c *linearClient) team(ctx context.Context) (string, error) {
+	c.teamMu.Lock()
+	defer c.teamMu.Unlock()
+	if c.teamID != "" {
+		return c.teamID, nil
+	}
+
+	var resp struct {
+		Teams struct {
+			Nodes []struct {
+				ID     string `json:"id"`
+				States struct {
+					Nodes []struct {
+						ID   string `json:"id"`
+						Name string `json:"name"`
+					} `json:"nodes"`
+				} `json:"states"`
+			} `json:"nodes"`
+		} `json:"teams"`
+	}
+	query := `query($key: String!) { teams(filter: { key: { eq: $key } }) { nodes { id states { nodes { id name } } } } }`
+	if err := c.do(ctx, query, map[string]any{"key": c.teamKey}, &resp); err != nil {
+		return "", fmt.Errorf("look up team %s: %w", c.teamKey, err)
+	}
+	if len(resp.Teams.Nodes) == 0 {
+		return "", fmt.Errorf("no Linear team with key %q", c.teamKey)
+	}
+	team := resp.Teams.Nodes[0]
+	c.states = make(map[string]string, len(team.States.Nodes))
+	for _, s := range team.States.Nodes {
+		c.states[strings.ToLower(s.Name)] = s.ID
+	}
+	c.teamID = team.ID
+	return c.teamID, nil
+}
+
+func (c *linearClient) do(ctx context.Context, query string, variables map[string]any, out any) error {
+	data, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	// Personal API keys are sent bare; only OAuth tokens take "Bearer".
+	req.Header.Set("Authorization", c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var body struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if len(body.Errors) > 0 {
+		msgs := make([]string, len(body.Errors))
+		for i, e := range body.Errors {
+			msgs[i] = e.Message
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return json.Unmarshal(body.Data, out)
+}
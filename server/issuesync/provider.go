@@ -0,0 +1,69 @@
+package issuesync
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/pockode/server/work"
+)
+
+// requestTimeout bounds one tracker API call.
+const requestTimeout = 15 * time.Second
+
+// batchSize is how many issues one read asks for; both trackers allow it
+// in a single page.
+const batchSize = 50
+
+// ErrNoCredentials is returned when the chosen tracker has no API token.
+var ErrNoCredentials = errors.New("no API token for the sync provider")
+
+// Issue is the tracker side of a synced work item.
+type Issue struct {
+	ID     string // what the API addresses: Jira issue key, Linear issue UUID
+	Key    string // what people read: "PROJ-12", "ENG-12"
+	URL    string
+	Status string // tracker status name
+	// Mapped fields by tracker field name, as text; "" is unset.
+	Fields    map[string]string
+	UpdatedAt time.Time
+}
+
+// Provider is a tracker API. Status is a tracker status name; empty leaves
+// the status unchanged. Fields hold only the mapped tracker fields.
+type Provider interface {
+	Create(ctx context.Context, workType work.WorkType, fields map[string]string, status string) (Issue, error)
+	Update(ctx context.Context, id string, fields map[string]string, status string) (Issue, error)
+	// Issues returns the current state of the given issues; ones that no
+	// longer exist or are not visible are left out.
+	Issues(ctx context.Context, ids []string) ([]Issue, error)
+}
+
+// Credentials authenticate against the trackers. They come from flags or
+// the environment, never from settings.
+type Credentials struct {
+	JiraEmail   string // empty = JiraToken is a bearer token (Data Center)
+	JiraToken   string
+	LinearToken string
+}
+
+// newProvider builds the client for cfg. fieldNames are the tracker fields
+// to read.
+func newProvider(cfg Config, creds Credentials, fieldNames []string) (Provider, error) {
+	client := &http.Client{Timeout: requestTimeout}
+	switch cfg.Provider {
+	case ProviderJira:
+		if creds.JiraToken == "" {
+			return nil, ErrNoCredentials
+		}
+		return newJiraClient(client, cfg.JiraURL, cfg.Project, creds.JiraEmail, creds.JiraToken, fieldNames), nil
+	case ProviderLinear:
+		if creds.LinearToken == "" {
+			return nil, ErrNoCredentials
+		}
+		return newLinearClient(client, defaultLinearAPI, cfg.Project, creds.LinearToken), nil
+	default:
+		return nil, errors.New("no sync provider")
+	}
+}
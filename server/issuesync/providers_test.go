@@ -0,0 +1,181 @@
+package issuesync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pockode/server/work"
+)
+
+func TestJiraClient(t *testing.T) {
+	var mu sync.Mutex
+	status := "To Do"
+	summary := ""
+	var transitioned string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if got := r.Header.Get("Authorization"); got != "Basic dUBleC5jb206dG9r" {
+			t.Errorf("Authorization = %q", got)
+		}
+		issue := func() map[string]any {
+			return map[string]any{"id": "10001", "key": "PRJ-1", "fields": map[string]any{
+				"status":  map[string]string{"name": status},
+				"updated": "2026-10-15T09:30:00.000+0000",
+				"summary": summary,
+			}}
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			var body struct {
+				Fields map[string]any `json:"fields"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Fields["issuetype"].(map[string]any)["name"] != "Story" {
+				t.Errorf("issuetype = %v", body.Fields["issuetype"])
+			}
+			summary = body.Fields["summary"].(string)
+			json.NewEncoder(w).Encode(map[string]string{"id": "10001", "key": "PRJ-1"})
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/PRJ-1":
+			json.NewEncoder(w).Encode(issue())
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/PRJ-1/transitions":
+			json.NewEncoder(w).Encode(map[string]any{"transitions": []map[string]any{
+				{"id": "21", "to": map[string]string{"name": "In Progress"}},
+				{"id": "31", "to": map[string]string{"name": "Done"}},
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/PRJ-1/transitions":
+			var body struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			transitioned = body.Transition.ID
+			status = "In Progress"
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/search/jql":
+			var body struct {
+				JQL string `json:"jql"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.JQL != `key in ("PRJ-1")` {
+				t.Errorf("jql = %q", body.JQL)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"issues": []any{issue()}})
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := newJiraClient(srv.Client(), srv.URL+"/", "PRJ", "u@ex.com", "tok", []string{"summary"})
+	ctx := context.Background()
+
+	issue, err := c.Create(ctx, work.WorkTypeStory, map[string]string{"summary": "Login"}, "To Do")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Key != "PRJ-1" || issue.Fields["summary"] != "Login" || issue.URL != srv.URL+"/browse/PRJ-1" || issue.UpdatedAt.IsZero() {
+		t.Errorf("created = %+v", issue)
+	}
+	if transitioned != "" {
+		t.Errorf("transitioned to %s for an issue already in the status", transitioned)
+	}
+
+	issue, err = c.Update(ctx, "PRJ-1", nil, "in progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transitioned != "21" || issue.Status != "In Progress" {
+		t.Errorf("transition = %s, status = %q", transitioned, issue.Status)
+	}
+
+	if _, err := c.Update(ctx, "PRJ-1", nil, "Won't Do"); err == nil || !strings.Contains(err.Error(), "no transition") {
+		t.Errorf("Update to unreachable status = %v", err)
+	}
+
+	issues, err := c.Issues(ctx, []string{"PRJ-1"})
+	if err != nil || len(issues) != 1 || issues[0].Status != "In Progress" {
+		t.Errorf("Issues = %+v, %v", issues, err)
+	}
+}
+
+func TestLinearClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "lin_key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		issue := map[string]any{
+			"id": "uuid-1", "identifier": "ENG-7", "url": "https://linear.app/x/issue/ENG-7",
+			"updatedAt": "2026-10-15T09:30:00.000Z", "state": map[string]string{"name": "Todo"},
+			"title": "Login", "description": nil, "dueDate": "2026-11-01",
+		}
+		switch {
+		case strings.Contains(req.Query, "teams("):
+			if req.Variables["key"] != "ENG" {
+				t.Errorf("team key = %v", req.Variables["key"])
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"teams": map[string]any{"nodes": []any{
+				map[string]any{"id": "team-1", "states": map[string]any{"nodes": []any{
+					map[string]string{"id": "st-todo", "name": "Todo"},
+				}}},
+			}}}})
+		case strings.Contains(req.Query, "issueCreate"):
+			input := req.Variables["input"].(map[string]any)
+			if input["teamId"] != "team-1" || input["stateId"] != "st-todo" || input["title"] != "Login" {
+				t.Errorf("input = %v", input)
+			}
+			if v, ok := input["description"]; !ok || v != nil {
+				t.Errorf("empty description should clear the field, got %v", input["description"])
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"issueCreate": map[string]any{"issue": issue}}})
+		case strings.Contains(req.Query, "issueUpdate"):
+			json.NewEncoder(w).Encode(map[string]any{"errors": []map[string]string{{"message": "Entity not found"}}})
+		case strings.Contains(req.Query, "issues("):
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"issues": map[string]any{"nodes": []any{issue}}}})
+		}
+	}))
+	defer srv.Close()
+
+	c := newLinearClient(srv.Client(), srv.URL, "ENG", "lin_key")
+	ctx := context.Background()
+
+	issue, err := c.Create(ctx, work.WorkTypeTask, map[string]string{"title": "Login", "description": ""}, "todo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.ID != "uuid-1" || issue.Key != "ENG-7" || issue.Fields["dueDate"] != "2026-11-01" || issue.Fields["description"] != "" {
+		t.Errorf("created = %+v", issue)
+	}
+
+	if _, err := c.Update(ctx, "uuid-1", nil, "Blocked"); err == nil || !strings.Contains(err.Error(), "no workflow state") {
+		t.Errorf("Update to unknown state = %v", err)
+	}
+	if _, err := c.Update(ctx, "uuid-1", map[string]string{"title": "x"}, ""); err == nil || !strings.Contains(err.Error(), "Entity not found") {
+		t.Errorf("Update GraphQL error = %v", err)
+	}
+
+	issues, err := c.Issues(ctx, []string{"uuid-1"})
+	if err != nil || len(issues) != 1 || issues[0].Status != "Todo" {
+		t.Errorf("Issues = %+v, %v", issues, err)
+	}
+}
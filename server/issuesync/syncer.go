@@ -0,0 +1,544 @@
+package issuesync
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pockode/server/filestore"
+	"github.com/pockode/server/work"
+)
+
+const stateFilename = "issuesync.json"
+
+// WorkOps runs the transitions a tracker status change maps to, with the
+// session handling the user-facing paths have. Satisfied by *work.Operations.
+type WorkOps interface {
+	ReopenWork(ctx context.Context, id string) error
+	CancelWork(ctx context.Context, id string, reason string) (work.Work, error)
+}
+
+type ItemState string
+
+const (
+	ItemSynced  ItemState = "synced"
+	ItemPending ItemState = "pending" // no issue yet, or work changed since the last sync
+	ItemError   ItemState = "error"
+)
+
+type Action string
+
+const (
+	ActionCreated Action = "created" // issue filed for the work item
+	ActionPushed  Action = "pushed"  // work changes written to the issue
+	ActionPulled  Action = "pulled"  // issue changes applied to the work item
+)
+
+// Item is the sync state of one work item.
+type Item struct {
+	WorkID   string    `json:"work_id"`
+	IssueID  string    `json:"issue_id,omitempty"` // empty until an issue is filed
+	IssueKey string    `json:"issue_key,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	State    ItemState `json:"state,omitempty"` // derived in Status, not stored
+	// LastAction and SyncedAt describe the last sync that changed a side.
+	LastAction Action    `json:"last_action,omitempty"`
+	SyncedAt   time.Time `json:"synced_at,omitzero"`
+	// Each side's UpdatedAt as of the last sync. A side whose UpdatedAt is
+	// later has changed since.
+	WorkUpdatedAt  time.Time `json:"work_updated_at,omitzero"`
+	IssueUpdatedAt time.Time `json:"issue_updated_at,omitzero"`
+	// Error is why the last attempt failed; cleared by a successful one.
+	Error string `json:"error,omitempty"`
+}
+
+// Status is the state of sync, returned by sync.status.
+type Status struct {
+	Provider  ProviderName `json:"provider,omitempty"` // empty when sync is off
+	Project   string       `json:"project,omitempty"`
+	LastRunAt time.Time    `json:"last_run_at,omitzero"`
+	// LastError is why the last run failed as a whole (bad credentials,
+	// tracker unreachable); per-item failures are on the items.
+	LastError string `json:"last_error,omitempty"`
+	Items     []Item `json:"items"`
+}
+
+type stateIndex struct {
+	Provider ProviderName `json:"provider,omitempty"`
+	Project  string       `json:"project,omitempty"`
+	Items    []Item       `json:"items"`
+}
+
+// mapping is a Config's parsed field and status maps.
+type mapping struct {
+	fields map[string]string // work field → tracker field
+	status map[work.WorkStatus]string
+}
+
+// Syncer keeps work items and tracker issues in step. Each run files an
+// issue for every unfinished work item without one, then compares each
+// linked pair with its state at the last sync: a side that changed is
+// copied to the other, and when both changed the one with the later
+// UpdatedAt wins. Runs happen on a timer and on Trigger (the webhook).
+//
+// Work status is owned by Pockode: it is always pushed, and of tracker
+// status changes only those mapping to cancelled (cancel the work) or to
+// open / in_progress on closed work (reopen it) are applied.
+type Syncer struct {
+	store  work.Store
+	ops    WorkOps
+	config func() Config
+	creds  Credentials
+	file   *filestore.File
+
+	// newProvider is replaced in tests.
+	newProvider func(Config, Credentials, []string) (Provider, error)
+
+	// runMu serializes runs; the provider cache is only used under it.
+	runMu          sync.Mutex
+	providerConfig Config
+	provider       Provider
+
+	stateMu   sync.Mutex
+	index     stateIndex
+	items     map[string]Item // by work ID
+	lastRunAt time.Time
+	lastError string
+
+	trigger chan struct{}
+	started atomic.Bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewSyncer loads the sync state from dataDir. config is read on every
+// run, so settings changes apply without a restart.
+func NewSyncer(dataDir string, store work.Store, ops WorkOps, creds Credentials, config func() Config) (*Syncer, error) {
+	f, err := filestore.New(filestore.Config{
+		Path:  filepath.Join(dataDir, stateFilename),
+		Label: "issuesync",
+	})
+	if err != nil {
+		return nil, err
+	}
+	idx, err := filestore.Load(f, stateIndex{})
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]Item, len(idx.Items))
+	for _, item := range idx.Items {
+		items[item.WorkID] = item
+	}
+	return &Syncer{
+		store:       store,
+		ops:         ops,
+		config:      config,
+		creds:       creds,
+		file:        f,
+		newProvider: newProvider,
+		index:       stateIndex{Provider: idx.Provider, Project: idx.Project},
+		items:       items,
+		trigger:     make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}, nil
+}
+
+func (s *Syncer) Start() {
+	s.started.Store(true)
+	go func() {
+		defer close(s.done)
+		for {
+			s.Run(context.Background())
+			interval := s.config().Interval
+			if interval <= 0 {
+				interval = DefaultInterval
+			}
+			timer := time.NewTimer(interval)
+			select {
+			case <-s.stop:
+				timer.Stop()
+				return
+			case <-s.trigger:
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+	}()
+}
+
+// Stop stops syncing. Safe to call when Start was never called.
+func (s *Syncer) Stop() {
+	if !s.started.Load() {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// Trigger asks for a run now; requests during a run collapse into one.
+func (s *Syncer) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run syncs once. Exported for tests and manual refresh.
+func (s *Syncer) Run(ctx context.Context) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	cfg := s.config()
+	if !cfg.Enabled() {
+		return
+	}
+	err := s.run(ctx, cfg)
+	if err != nil {
+		slog.Warn("issue sync failed", "provider", cfg.Provider, "error", err)
+	}
+
+	s.stateMu.Lock()
+	s.lastRunAt = time.Now()
+	s.lastError = ""
+	if err != nil {
+		s.lastError = err.Error()
+	}
+	idx := stateIndex{Provider: s.index.Provider, Project: s.index.Project, Items: s.sortedItemsLocked()}
+	s.stateMu.Unlock()
+
+	if err := s.file.Persist(idx); err != nil {
+		slog.Error("failed to persist issue sync state", "error", err)
+	}
+}
+
+func (s *Syncer) run(ctx context.Context, cfg Config) error {
+	fields, err := ParseFieldMap(cfg.Provider, cfg.FieldMap)
+	if err != nil {
+		return err
+	}
+	status, err := ParseStatusMap(cfg.Provider, cfg.StatusMap)
+	if err != nil {
+		return err
+	}
+	m := mapping{fields: fields, status: status}
+
+	// Links point into one tracker project; a different one starts over.
+	s.stateMu.Lock()
+	if s.index.Provider != cfg.Provider || s.index.Project != cfg.Project {
+		s.index = stateIndex{Provider: cfg.Provider, Project: cfg.Project}
+		s.items = make(map[string]Item)
+	}
+	s.stateMu.Unlock()
+
+	p, err := s.providerFor(cfg, slices.Sorted(maps.Values(fields)))
+	if err != nil {
+		return err
+	}
+
+	works, err := s.store.List()
+	if err != nil {
+		return fmt.Errorf("list work: %w", err)
+	}
+	exists := make(map[string]bool, len(works))
+	for _, w := range works {
+		exists[w.ID] = true
+	}
+
+	s.stateMu.Lock()
+	var ids []string
+	for id, item := range s.items {
+		if !exists[id] {
+			delete(s.items, id) // work deleted; the issue is left alone
+			continue
+		}
+		if item.IssueID != "" {
+			ids = append(ids, item.IssueID)
+		}
+	}
+	s.stateMu.Unlock()
+	slices.Sort(ids)
+
+	issues, err := p.Issues(ctx, ids)
+	if err != nil {
+		return err
+	}
+	remote := make(map[string]Issue, len(issues))
+	for _, i := range issues {
+		remote[i.ID] = i
+	}
+
+	for _, w := range works {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.stateMu.Lock()
+		item, linked := s.items[w.ID]
+		s.stateMu.Unlock()
+
+		var next Item
+		switch {
+		case !linked || item.IssueID == "":
+			if w.Status == work.StatusClosed || w.Status == work.StatusCancelled {
+				// Finished before an issue was filed: nothing to track.
+				s.stateMu.Lock()
+				delete(s.items, w.ID)
+				s.stateMu.Unlock()
+				continue
+			}
+			next = s.fileIssue(ctx, p, m, w)
+		default:
+			issue, ok := remote[item.IssueID]
+			if !ok {
+				item.Error = "issue not found in " + string(cfg.Provider)
+				next = item
+				break
+			}
+			next = s.syncItem(ctx, cfg, p, m, w, item, issue)
+		}
+
+		s.stateMu.Lock()
+		s.items[w.ID] = next
+		s.stateMu.Unlock()
+	}
+	return nil
+}
+
+// providerFor returns the client for cfg, reusing the last one while the
+// config is unchanged so lookups it caches (Linear team states) survive.
+func (s *Syncer) providerFor(cfg Config, fieldNames []string) (Provider, error) {
+	if s.provider != nil && s.providerConfig == cfg {
+		return s.provider, nil
+	}
+	p, err := s.newProvider(cfg, s.creds, fieldNames)
+	if err != nil {
+		return nil, err
+	}
+	s.provider, s.providerConfig = p, cfg
+	return p, nil
+}
+
+// fileIssue files an issue for w.
+func (s *Syncer) fileIssue(ctx context.Context, p Provider, m mapping, w work.Work) Item {
+	item := Item{WorkID: w.ID}
+	issue, err := p.Create(ctx, w.Type, m.workFields(w), m.status[w.Status])
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+	item.IssueID, item.IssueKey, item.URL = issue.ID, issue.Key, issue.URL
+	return synced(item, ActionCreated, w.UpdatedAt, issue.UpdatedAt)
+}
+
+// syncItem carries changes between a linked work item and its issue.
+func (s *Syncer) syncItem(ctx context.Context, cfg Config, p Provider, m mapping, w work.Work, item Item, issue Issue) Item {
+	item.IssueKey, item.URL = issue.Key, issue.URL
+	workChanged := w.UpdatedAt.After(item.WorkUpdatedAt)
+	issueChanged := issue.UpdatedAt.After(item.IssueUpdatedAt)
+
+	switch {
+	case !workChanged && !issueChanged:
+		return item
+	case workChanged && !(issueChanged && issue.UpdatedAt.After(w.UpdatedAt)):
+		return s.push(ctx, p, m, w, item, issue)
+	default:
+		return s.pull(ctx, cfg, m, w, item, issue)
+	}
+}
+
+// push writes the work item's mapped fields and status to the issue,
+// skipping what already matches.
+func (s *Syncer) push(ctx context.Context, p Provider, m mapping, w work.Work, item Item, issue Issue) Item {
+	changed := make(map[string]string)
+	for name, v := range m.workFields(w) {
+		if issue.Fields[name] != v {
+			changed[name] = v
+		}
+	}
+	status := m.status[w.Status]
+	if strings.EqualFold(status, issue.Status) {
+		status = ""
+	}
+	if len(changed) == 0 && status == "" {
+		item.WorkUpdatedAt, item.IssueUpdatedAt, item.Error = w.UpdatedAt, issue.UpdatedAt, ""
+		return item
+	}
+
+	updated, err := p.Update(ctx, issue.ID, changed, status)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+	return synced(item, ActionPushed, w.UpdatedAt, updated.UpdatedAt)
+}
+
+// pull applies the issue's mapped fields, and the status changes Pockode
+// accepts from a tracker, to the work item.
+func (s *Syncer) pull(ctx context.Context, cfg Config, m mapping, w work.Work, item Item, issue Issue) Item {
+	fields, err := m.updateFields(w, issue)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+	if fields != (work.UpdateFields{}) {
+		if err := s.store.Update(ctx, w.ID, fields); err != nil {
+			item.Error = err.Error()
+			return item
+		}
+	}
+
+	switch {
+	case m.is(issue.Status, work.StatusCancelled) && w.Status != work.StatusCancelled:
+		reason := fmt.Sprintf("cancelled in %s (%s)", cfg.Provider, issue.Key)
+		if _, err := s.ops.CancelWork(ctx, w.ID, reason); err != nil {
+			item.Error = err.Error()
+			return item
+		}
+	case w.Status == work.StatusClosed && (m.is(issue.Status, work.StatusOpen) || m.is(issue.Status, work.StatusInProgress)):
+		if err := s.ops.ReopenWork(ctx, w.ID); err != nil {
+			item.Error = err.Error()
+			return item
+		}
+	}
+
+	// The work's own UpdatedAt moved with the changes above; record it so
+	// the next run does not push them back.
+	current, found, err := s.store.Get(w.ID)
+	if err != nil || !found {
+		current = w
+	}
+	return synced(item, ActionPulled, current.UpdatedAt, issue.UpdatedAt)
+}
+
+func synced(item Item, action Action, workUpdatedAt, issueUpdatedAt time.Time) Item {
+	item.LastAction = action
+	item.SyncedAt = time.Now()
+	item.WorkUpdatedAt = workUpdatedAt
+	item.IssueUpdatedAt = issueUpdatedAt
+	item.Error = ""
+	return item
+}
+
+// workFields renders the mapped work fields as tracker field text.
+func (m mapping) workFields(w work.Work) map[string]string {
+	out := make(map[string]string, len(m.fields))
+	for workField, trackerField := range m.fields {
+		out[trackerField] = workFieldText(w, workField)
+	}
+	return out
+}
+
+func workFieldText(w work.Work, field string) string {
+	switch field {
+	case FieldTitle:
+		return w.Title
+	case FieldBody:
+		return w.Body
+	case FieldDueAt:
+		if w.DueAt.IsZero() {
+			return ""
+		}
+		return w.DueAt.Local().Format(dueDateLayout)
+	}
+	return ""
+}
+
+// updateFields returns the work update that makes w's mapped fields match
+// issue. An empty tracker title is ignored, since work requires one.
+func (m mapping) updateFields(w work.Work, issue Issue) (work.UpdateFields, error) {
+	var f work.UpdateFields
+	for workField, trackerField := range m.fields {
+		v := issue.Fields[trackerField]
+		if v == workFieldText(w, workField) {
+			continue
+		}
+		switch workField {
+		case FieldTitle:
+			if v != "" {
+				f.Title = &v
+			}
+		case FieldBody:
+			f.Body = &v
+		case FieldDueAt:
+			var due time.Time
+			if v != "" {
+				t, err := time.ParseInLocation(dueDateLayout, v, time.Local)
+				if err != nil {
+					return work.UpdateFields{}, fmt.Errorf("invalid due date %q in %s", v, issue.Key)
+				}
+				due = t
+			}
+			f.DueAt = &due
+		}
+	}
+	return f, nil
+}
+
+// is reports whether the tracker status name maps from work status ws.
+func (m mapping) is(trackerStatus string, ws work.WorkStatus) bool {
+	mapped := m.status[ws]
+	return mapped != "" && strings.EqualFold(mapped, trackerStatus)
+}
+
+// Status returns the sync state of every work item sync covers, or of
+// workID alone when set. Unfinished work without an issue is pending.
+func (s *Syncer) Status(workID string) (Status, error) {
+	cfg := s.config()
+	if !cfg.Enabled() {
+		return Status{Items: []Item{}}, nil
+	}
+	works, err := s.store.List()
+	if err != nil {
+		return Status{}, err
+	}
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	st := Status{
+		Provider:  cfg.Provider,
+		Project:   cfg.Project,
+		LastRunAt: s.lastRunAt,
+		LastError: s.lastError,
+		Items:     []Item{},
+	}
+	stale := s.index.Provider != cfg.Provider || s.index.Project != cfg.Project
+	for _, w := range works {
+		if workID != "" && w.ID != workID {
+			continue
+		}
+		item, ok := s.items[w.ID]
+		if stale || !ok {
+			if w.Status == work.StatusClosed || w.Status == work.StatusCancelled {
+				continue
+			}
+			item = Item{WorkID: w.ID}
+		}
+		switch {
+		case item.Error != "":
+			item.State = ItemError
+		case item.IssueID == "" || w.UpdatedAt.After(item.WorkUpdatedAt):
+			item.State = ItemPending
+		default:
+			item.State = ItemSynced
+		}
+		st.Items = append(st.Items, item)
+	}
+	return st, nil
+}
+
+func (s *Syncer) sortedItemsLocked() []Item {
+	items := make([]Item, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	slices.SortFunc(items, func(a, b Item) int { return cmp.Compare(a.WorkID, b.WorkID) })
+	return items
+}
@@ -0,0 +1,382 @@
+package issuesync
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/work"
+)
+
+// fakeProvider is an in-memory tracker.
+type fakeProvider struct {
+	issuesMu  sync.Mutex
+	issues    map[string]Issue
+	created   int
+	updated   int
+	createErr error
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{issues: make(map[string]Issue)}
+}
+
+func (p *fakeProvider) Create(_ context.Context, _ work.WorkType, fields map[string]string, status string) (Issue, error) {
+	p.issuesMu.Lock()
+	defer p.issuesMu.Unlock()
+	if p.createErr != nil {
+		return Issue{}, p.createErr
+	}
+	p.created++
+	id := fmt.Sprintf("P-%d", p.created)
+	issue := Issue{ID: id, Key: id, URL: "https://tracker/" + id, Status: status, Fields: maps.Clone(fields), UpdatedAt: time.Now()}
+	p.issues[id] = issue
+	return issue, nil
+}
+
+func (p *fakeProvider) Update(_ context.Context, id string, fields map[string]string, status string) (Issue, error) {
+	p.issuesMu.Lock()
+	defer p.issuesMu.Unlock()
+	p.updated++
+	issue := p.issues[id]
+	maps.Copy(issue.Fields, fields)
+	if status != "" {
+		issue.Status = status
+	}
+	issue.UpdatedAt = time.Now()
+	p.issues[id] = issue
+	return issue, nil
+}
+
+func (p *fakeProvider) Issues(_ context.Context, ids []string) ([]Issue, error) {
+	p.issuesMu.Lock()
+	defer p.issuesMu.Unlock()
+	var out []Issue
+	for _, id := range ids {
+		if issue, ok := p.issues[id]; ok {
+			issue.Fields = maps.Clone(issue.Fields)
+			out = append(out, issue)
+		}
+	}
+	return out, nil
+}
+
+// edit changes an issue as a tracker user would.
+func (p *fakeProvider) edit(id string, fn func(*Issue)) {
+	p.issuesMu.Lock()
+	defer p.issuesMu.Unlock()
+	issue := p.issues[id]
+	fn(&issue)
+	issue.UpdatedAt = time.Now()
+	p.issues[id] = issue
+}
+
+func (p *fakeProvider) get(id string) Issue {
+	p.issuesMu.Lock()
+	defer p.issuesMu.Unlock()
+	return p.issues[id]
+}
+
+type syncEnv struct {
+	syncer   *Syncer
+	store    *work.FileStore
+	provider *fakeProvider
+	dataDir  string
+	config   Config
+}
+
+func newSyncEnv(t *testing.T, provider ProviderName) *syncEnv {
+	t.Helper()
+	store, err := work.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &syncEnv{store: store, provider: newFakeProvider(), dataDir: t.TempDir(), config: Config{Provider: provider, Project: "PRJ"}}
+	s, err := NewSyncer(env.dataDir, store, work.NewOperations(store, nil, nil), Credentials{}, func() Config { return env.config })
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.newProvider = func(Config, Credentials, []string) (Provider, error) { return env.provider, nil }
+	env.syncer = s
+	return env
+}
+
+func (e *syncEnv) createStory(t *testing.T, title string) work.Work {
+	t.Helper()
+	w, err := e.store.Create(context.Background(), work.Work{Type: work.WorkTypeStory, Title: title, AgentRoleID: "role"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func (e *syncEnv) item(t *testing.T, workID string) Item {
+	t.Helper()
+	st, err := e.syncer.Status(workID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Items) != 1 {
+		t.Fatalf("status items = %+v, want 1", st.Items)
+	}
+	return st.Items[0]
+}
+
+func (e *syncEnv) getWork(t *testing.T, id string) work.Work {
+	t.Helper()
+	w, _, err := e.store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestSyncer_FilesAndPushes(t *testing.T) {
+	env := newSyncEnv(t, ProviderJira)
+	ctx := context.Background()
+	w := env.createStory(t, "Login")
+	done := env.createStory(t, "Old")
+	if _, err := env.store.Start(ctx, done.ID, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.store.StepDone(ctx, done.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := env.item(t, w.ID).State; got != ItemPending {
+		t.Errorf("state before first run = %s, want pending", got)
+	}
+	env.syncer.Run(ctx)
+
+	if env.provider.created != 1 {
+		t.Fatalf("created = %d, want 1 (closed work is not filed)", env.provider.created)
+	}
+	item := env.item(t, w.ID)
+	if item.State != ItemSynced || item.IssueKey != "P-1" || item.LastAction != ActionCreated {
+		t.Errorf("item = %+v", item)
+	}
+	if issue := env.provider.get("P-1"); issue.Fields["summary"] != "Login" || issue.Status != "To Do" {
+		t.Errorf("issue = %+v", issue)
+	}
+
+	title := "Login with SSO"
+	if err := env.store.Update(ctx, w.ID, work.UpdateFields{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	if got := env.item(t, w.ID).State; got != ItemPending {
+		t.Errorf("state after edit = %s, want pending", got)
+	}
+	env.syncer.Run(ctx)
+	if issue := env.provider.get("P-1"); issue.Fields["summary"] != title {
+		t.Errorf("summary = %q, want %q", issue.Fields["summary"], title)
+	}
+	if item := env.item(t, w.ID); item.LastAction != ActionPushed || item.State != ItemSynced {
+		t.Errorf("item after push = %+v", item)
+	}
+
+	// Nothing changed: no further writes.
+	updates := env.provider.updated
+	env.syncer.Run(ctx)
+	if env.provider.updated != updates {
+		t.Errorf("updates = %d, want %d", env.provider.updated, updates)
+	}
+}
+
+func TestSyncer_Pulls(t *testing.T) {
+	env := newSyncEnv(t, ProviderJira)
+	ctx := context.Background()
+	w := env.createStory(t, "Login")
+	env.syncer.Run(ctx)
+
+	env.provider.edit("P-1", func(i *Issue) {
+		i.Fields["summary"] = "Login (renamed)"
+		i.Fields["duedate"] = "2026-11-01"
+	})
+	env.syncer.Run(ctx)
+
+	got := env.getWork(t, w.ID)
+	if got.Title != "Login (renamed)" || got.DueAt.Format(dueDateLayout) != "2026-11-01" {
+		t.Errorf("work = %q due %v", got.Title, got.DueAt)
+	}
+	if item := env.item(t, w.ID); item.LastAction != ActionPulled || item.State != ItemSynced {
+		t.Errorf("item = %+v", item)
+	}
+
+	// The pulled change is not pushed back.
+	updates := env.provider.updated
+	env.syncer.Run(ctx)
+	if env.provider.updated != updates {
+		t.Errorf("pulled change was pushed back")
+	}
+}
+
+func TestSyncer_ConflictPrefersNewer(t *testing.T) {
+	env := newSyncEnv(t, ProviderJira)
+	ctx := context.Background()
+	w := env.createStory(t, "Login")
+	env.syncer.Run(ctx)
+
+	// Issue edited first, work after: the work wins.
+	env.provider.edit("P-1", func(i *Issue) { i.Fields["summary"] = "from tracker" })
+	time.Sleep(5 * time.Millisecond)
+	title := "from pockode"
+	if err := env.store.Update(ctx, w.ID, work.UpdateFields{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	env.syncer.Run(ctx)
+	if got := env.provider.get("P-1").Fields["summary"]; got != "from pockode" {
+		t.Errorf("summary = %q, want the newer work title", got)
+	}
+
+	// Work edited first, issue after: the issue wins.
+	title = "older pockode"
+	if err := env.store.Update(ctx, w.ID, work.UpdateFields{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	env.provider.edit("P-1", func(i *Issue) { i.Fields["summary"] = "newer tracker" })
+	env.syncer.Run(ctx)
+	if got := env.getWork(t, w.ID).Title; got != "newer tracker" {
+		t.Errorf("title = %q, want the newer issue summary", got)
+	}
+}
+
+func TestSyncer_PullsCancel(t *testing.T) {
+	env := newSyncEnv(t, ProviderLinear)
+	ctx := context.Background()
+	w := env.createStory(t, "Login")
+	env.syncer.Run(ctx)
+
+	env.provider.edit("P-1", func(i *Issue) { i.Status = "Canceled" })
+	env.syncer.Run(ctx)
+
+	got := env.getWork(t, w.ID)
+	if got.Status != work.StatusCancelled || !strings.Contains(got.CancelReason, "P-1") {
+		t.Errorf("work = %s %q, want cancelled", got.Status, got.CancelReason)
+	}
+}
+
+func TestSyncer_Errors(t *testing.T) {
+	env := newSyncEnv(t, ProviderJira)
+	ctx := context.Background()
+	w := env.createStory(t, "Login")
+
+	env.provider.createErr = errors.New("project not found")
+	env.syncer.Run(ctx)
+	if item := env.item(t, w.ID); item.State != ItemError || item.Error != "project not found" {
+		t.Errorf("item = %+v", item)
+	}
+
+	env.provider.createErr = nil
+	env.syncer.Run(ctx)
+	if item := env.item(t, w.ID); item.State != ItemSynced {
+		t.Errorf("item after retry = %+v", item)
+	}
+
+	env.syncer.newProvider = func(Config, Credentials, []string) (Provider, error) { return nil, ErrNoCredentials }
+	env.syncer.provider = nil
+	env.syncer.Run(ctx)
+	if st, _ := env.syncer.Status(""); st.LastError != ErrNoCredentials.Error() {
+		t.Errorf("last error = %q", st.LastError)
+	}
+}
+
+func TestSyncer_StatePersists(t *testing.T) {
+	env := newSyncEnv(t, ProviderJira)
+	ctx := context.Background()
+	w := env.createStory(t, "Login")
+	env.syncer.Run(ctx)
+
+	reloaded, err := NewSyncer(env.dataDir, env.store, work.NewOperations(env.store, nil, nil), Credentials{}, func() Config { return env.config })
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.newProvider = env.syncer.newProvider
+	reloaded.Run(ctx)
+	if env.provider.created != 1 {
+		t.Errorf("created = %d after reload, want 1", env.provider.created)
+	}
+	if st, _ := reloaded.Status(w.ID); len(st.Items) != 1 || st.Items[0].IssueKey != "P-1" {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestParseMaps(t *testing.T) {
+	fields, err := ParseFieldMap(ProviderJira, "title=summary, due_at=customfield_10015")
+	if err != nil || fields[FieldTitle] != "summary" || fields[FieldDueAt] != "customfield_10015" || fields[FieldBody] != "" {
+		t.Errorf("ParseFieldMap = %v, %v", fields, err)
+	}
+	for _, bad := range []string{"body=description", "title", "estimate=points"} {
+		if _, err := ParseFieldMap(ProviderJira, bad); err == nil {
+			t.Errorf("ParseFieldMap(%q) succeeded", bad)
+		}
+	}
+	if _, err := ParseFieldMap(ProviderLinear, "title=summary"); err == nil {
+		t.Error("ParseFieldMap accepted an unknown Linear field")
+	}
+
+	status, err := ParseStatusMap(ProviderJira, "closed=Shipped")
+	if err != nil || status[work.StatusClosed] != "Shipped" || status[work.StatusOpen] != "To Do" {
+		t.Errorf("ParseStatusMap = %v, %v", status, err)
+	}
+	if _, err := ParseStatusMap(ProviderJira, "done=Shipped"); err == nil {
+		t.Error("ParseStatusMap accepted an unknown work status")
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	env := newSyncEnv(t, ProviderLinear)
+	const secret = "s3cret"
+	body := `{"type":"Issue"}`
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name       string
+		secret     string
+		query      string
+		signature  string
+		wantStatus int
+	}{
+		{"disabled", "", "?secret=" + secret, "", http.StatusNotFound},
+		{"query secret", secret, "?secret=" + secret, "", http.StatusAccepted},
+		{"wrong secret", secret, "?secret=nope", "", http.StatusUnauthorized},
+		{"linear signature", secret, "", signature, http.StatusAccepted},
+		{"bad signature", secret, "?secret=" + secret, strings.Repeat("0", 64), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewWebhookHandler(env.syncer, tt.secret)
+			req := httptest.NewRequest(http.MethodPost, WebhookPath+tt.query, strings.NewReader(body))
+			if tt.signature != "" {
+				req.Header.Set("Linear-Signature", tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			select {
+			case <-env.syncer.trigger:
+				if tt.wantStatus != http.StatusAccepted {
+					t.Error("refused webhook triggered a run")
+				}
+			default:
+				if tt.wantStatus == http.StatusAccepted {
+					t.Error("accepted webhook did not trigger a run")
+				}
+			}
+		})
+	}
+}
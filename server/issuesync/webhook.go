@@ -0,0 +1,63 @@
+package issuesync
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net/http"
+)
+
+// WebhookPath receives tracker webhooks. It bypasses the auth-token
+// middleware; the handler checks the webhook secret instead.
+const WebhookPath = "/api/sync/webhook"
+
+// maxWebhookBody bounds a webhook payload; issue events are a few KiB.
+const maxWebhookBody = 1 << 20
+
+// WebhookHandler starts a sync run when a tracker reports a change. The
+// payload only signals that something changed; the run reads the issues
+// through the API, so a forged payload cannot inject data.
+type WebhookHandler struct {
+	syncer *Syncer
+	secret string
+}
+
+// NewWebhookHandler serves WebhookPath. An empty secret disables it.
+func NewWebhookHandler(syncer *Syncer, secret string) *WebhookHandler {
+	return &WebhookHandler{syncer: syncer, secret: secret}
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.secret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if !h.authorized(r, body) {
+		http.Error(w, "invalid webhook secret", http.StatusUnauthorized)
+		return
+	}
+	h.syncer.Trigger()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// authorized accepts Linear's HMAC signature of the body, or the secret as
+// the "secret" query parameter (Jira webhooks cannot sign or set headers).
+func (h *WebhookHandler) authorized(r *http.Request, body []byte) bool {
+	if sig := r.Header.Get("Linear-Signature"); sig != "" {
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(h.secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+	return subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("secret")), []byte(h.secret)) == 1
+}
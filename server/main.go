@@ -5,8 +5,11 @@ import (
 	"crypto/rand"
 	"embed"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
@@ -22,20 +25,35 @@ import (
 	"github.com/pockode/server/agent/claude"
 	"github.com/pockode/server/agent/codex"
 	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/ci"
 	"github.com/pockode/server/cluster"
 	"github.com/pockode/server/command"
+	"github.com/pockode/server/digest"
+	"github.com/pockode/server/eventlog"
+	"github.com/pockode/server/fsck"
 	"github.com/pockode/server/git"
+	"github.com/pockode/server/health"
+	"github.com/pockode/server/i18n"
 	"github.com/pockode/server/internal/netutil"
+	"github.com/pockode/server/issuesync"
 	"github.com/pockode/server/logger"
 	"github.com/pockode/server/mcp"
 	"github.com/pockode/server/middleware"
+	"github.com/pockode/server/pairing"
+	"github.com/pockode/server/process"
 	"github.com/pockode/server/relay"
+	"github.com/pockode/server/replay"
 	"github.com/pockode/server/serverinfo"
 	"github.com/pockode/server/session"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/setup"
+	"github.com/pockode/server/snapshot"
 	"github.com/pockode/server/spa"
 	"github.com/pockode/server/startup"
+	"github.com/pockode/server/testrun"
 	"github.com/pockode/server/work"
+	"github.com/pockode/server/workhook"
 	"github.com/pockode/server/worktree"
 	"github.com/pockode/server/ws"
 )
@@ -45,13 +63,18 @@ var version = "dev"
 //go:embed static/*
 var staticFS embed.FS
 
-func newHandler(token string, devMode bool, wsHandler *ws.RPCHandler, mcpHandler http.Handler) http.Handler {
+func newHandler(devMode bool, wsHandler *ws.RPCHandler, mcpHandler, attachmentHandler, readinessHandler, syncWebhookHandler http.Handler) http.Handler {
 	mux := http.NewServeMux()
 
+	// Kept for existing monitors; /healthz is the structured equivalent.
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ok"))
 	})
+	mux.Handle("GET "+health.LivenessPath, health.LivenessHandler())
+	if readinessHandler != nil {
+		mux.Handle("GET "+health.ReadinessPath, readinessHandler)
+	}
 
 	mux.HandleFunc("GET /api/ping", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -60,12 +83,25 @@ func newHandler(token string, devMode bool, wsHandler *ws.RPCHandler, mcpHandler
 
 	mux.Handle("GET /ws", wsHandler)
 
-	// Local MCP API. middleware.Auth bypasses this exact route; mcpHandler
+	if attachmentHandler != nil {
+		mux.Handle("GET "+work.AttachmentPath, attachmentHandler)
+		mux.Handle("PUT "+work.AttachmentPath, attachmentHandler)
+	}
+
+	// Tracker webhooks. middleware.Auth bypasses this exact route; the
+	// handler checks the webhook secret.
+	if syncWebhookHandler != nil {
+		mux.Handle("POST "+issuesync.WebhookPath, syncWebhookHandler)
+	}
+
+	// Local MCP API. middleware.Auth bypasses these exact routes; mcpHandler
 	// self-auths with the locally-generated MCP token instead of the user
 	// --auth-token. The relay also refuses to forward it (loopback-only).
 	mux.Handle("POST "+mcp.APIPath, mcpHandler)
+	mux.Handle("GET "+mcp.EventsPath, mcpHandler)
 
-	authedMux := middleware.Auth(token)(mux)
+	// Accepts the auth token and the tokens of paired devices.
+	authedMux := middleware.AuthFunc(wsHandler.ValidToken)(mux)
 
 	if !devMode {
 		return newSPAHandler(authedMux)
@@ -85,7 +121,7 @@ func newSPAHandler(apiHandler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 
-		if strings.HasPrefix(path, "/api") || path == "/ws" || path == "/health" {
+		if strings.HasPrefix(path, "/api") || path == "/ws" || path == "/health" || health.IsProbePath(path) {
 			apiHandler.ServeHTTP(w, r)
 			return
 		}
@@ -106,6 +142,14 @@ func newSPAHandler(apiHandler http.Handler) http.Handler {
 
 const defaultPort = 9870
 
+// agentBinary returns the CLI executable an agent type runs.
+func agentBinary(agentType session.AgentType) string {
+	if agentType == session.AgentTypeCodex {
+		return codex.Binary
+	}
+	return claude.Binary
+}
+
 // generateToken returns a random 256-bit token as a hex string.
 func generateToken() (string, error) {
 	b := make([]byte, 32)
@@ -125,6 +169,27 @@ func main() {
 		case "cluster":
 			runCluster()
 			return
+		case "replay":
+			if err := runReplay(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "work", "role", "session", "fsck":
+			var help cliHelp
+			if err := runCLI(os.Args[1], os.Args[2:], os.Stdout); errors.As(err, &help) {
+				fmt.Print(help)
+				return
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "serve":
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case "help":
+			fmt.Print(cliUsage)
+			return
 		}
 	}
 
@@ -134,6 +199,7 @@ func main() {
 	dataDirFlag := flag.String("data", "", "data directory (default: <work>/.pockode)")
 	devModeFlag := flag.Bool("dev", false, "enable development mode")
 	idleTimeoutFlag := flag.Duration("idle-timeout", 8*time.Hour, "idle timeout before stopping")
+	drainTimeoutFlag := flag.Duration("drain-timeout", 2*time.Minute, "on SIGTERM, how long to wait for running agent turns before shutting down (0 = no drain)")
 	relayFlag := flag.Bool("relay", true, "relay for remote access (use -relay=false to disable)")
 	relayFrontendPortFlag := flag.Int("relay-frontend-port", 0, "relay frontend port (default: same as server port)")
 	cloudURLFlag := flag.String("cloud-url", "https://cloud.pockode.com", "cloud server URL")
@@ -142,9 +208,17 @@ func main() {
 	gitRepoTokenFlag := flag.String("git-repo-token", "", "git repository token")
 	gitUserNameFlag := flag.String("git-user-name", "", "git user name")
 	gitUserEmailFlag := flag.String("git-user-email", "", "git user email")
+	githubTokenFlag := flag.String("github-token", os.Getenv("GITHUB_TOKEN"), "GitHub token for CI status polling (default: $GITHUB_TOKEN, then -git-repo-token)")
+	jiraEmailFlag := flag.String("jira-email", os.Getenv("JIRA_EMAIL"), "Jira account email for issue sync; empty sends -jira-token as a bearer token (default: $JIRA_EMAIL)")
+	jiraTokenFlag := flag.String("jira-token", os.Getenv("JIRA_API_TOKEN"), "Jira API token for issue sync (default: $JIRA_API_TOKEN)")
+	linearTokenFlag := flag.String("linear-token", os.Getenv("LINEAR_API_KEY"), "Linear API key for issue sync (default: $LINEAR_API_KEY)")
+	syncWebhookSecretFlag := flag.String("sync-webhook-secret", os.Getenv("POCKODE_SYNC_WEBHOOK_SECRET"), "secret tracker webhooks must present to trigger an issue sync; empty disables the webhook (default: $POCKODE_SYNC_WEBHOOK_SECRET)")
 	logLevelFlag := flag.String("log-level", "", "log level: debug, info, warn, error (default info)")
 	logFormatFlag := flag.String("log-format", "", "log format: text, json (default text)")
 	logFileFlag := flag.String("log-file", "", "log file path (default: dataDir/server.log in production)")
+	recordEventsFlag := flag.Bool("record-events", false, "record every agent event into dataDir/replays for pockode replay")
+	snapshotFlag := flag.Bool("snapshot", false, "snapshot the data directory and exit")
+	restoreSnapshotFlag := flag.String("restore-snapshot", "", "restore the named snapshot into the data directory before starting")
 	versionFlag := flag.Bool("version", false, "print version and exit")
 	flag.Parse()
 
@@ -189,6 +263,36 @@ func main() {
 		LogFile:   *logFileFlag,
 	})
 
+	// Snapshots and restores run before any store opens: a restore replaces
+	// the files the stores are about to load.
+	snapshots := snapshot.NewManager(dataDir)
+	if *snapshotFlag {
+		info, err := snapshots.Create(snapshot.LabelCommandLine)
+		if err != nil {
+			slog.Error("failed to create snapshot", "error", err)
+			os.Exit(1)
+		}
+		fmt.Println(info.Name)
+		os.Exit(0)
+	}
+	restoreName := *restoreSnapshotFlag
+	if restoreName == "" {
+		pending, ok, err := snapshots.PendingRestore()
+		if err != nil {
+			slog.Error("failed to read pending snapshot restore", "error", err)
+			os.Exit(1)
+		}
+		if ok {
+			restoreName = pending
+		}
+	}
+	if restoreName != "" {
+		if err := snapshots.Restore(restoreName); err != nil {
+			slog.Error("failed to restore snapshot", "name", restoreName, "error", err)
+			os.Exit(1)
+		}
+	}
+
 	if *gitEnabledFlag {
 		gitCfg := git.Config{
 			RepoURL:   *gitRepoURLFlag,
@@ -225,9 +329,19 @@ func main() {
 	if err := settingsStore.StartWatching(); err != nil {
 		slog.Warn("failed to start settings store file watcher", "error", err)
 	}
+	clientPrefs, err := settings.NewClientPrefsStore(dataDir)
+	if err != nil {
+		slog.Error("failed to initialize client preferences store", "error", err)
+		os.Exit(1)
+	}
+	devices, err := pairing.NewManager(dataDir)
+	if err != nil {
+		slog.Error("failed to initialize device pairing", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize worktree setup hook
-	if err := worktree.InitSetupHook(dataDir); err != nil {
+	if err := setup.InitHook(dataDir); err != nil {
 		slog.Error("failed to initialize worktree setup hook", "error", err)
 		os.Exit(1)
 	}
@@ -244,39 +358,203 @@ func main() {
 		slog.Warn("failed to start agent role store file watcher", "error", err)
 	}
 
+	// Language of prompts sent to agents, read per send so a settings change
+	// applies to the next prompt.
+	serverLocale := func() i18n.Locale { return settingsStore.Get().EffectiveLocale() }
+
 	workAutoResumer := work.NewAutoResumer(workStore, 3)
-	workAutoResumer.StopOrphanedWork()
 	workAutoResumer.SetStepProvider(&agentRoleStepAdapter{store: agentRoleStore})
+	workAutoResumer.SetLocale(serverLocale)
 	session.ClearOrphanedNeedsInput(dataDir)
+	session.RemoveOrphanedEphemeral(dataDir)
 	workStore.AddOnChangeListener(workAutoResumer)
+	attachments := work.NewAttachmentStore(dataDir)
+	workStore.AddOnChangeListener(attachments)
 
-	// Set PM as default agent role on first launch
-	if pmID := agentRoleStore.SeededPMRoleID(); pmID != "" {
-		cfg := settingsStore.Get()
-		cfg.DefaultAgentRoleID = pmID
-		if err := settingsStore.Update(cfg); err != nil {
-			slog.Error("failed to set default agent role", "error", err)
-		}
+	autorunGate, err := work.NewAutorunGate(dataDir, func() work.AutorunLimits {
+		return settingsStore.Get().AutorunLimits()
+	})
+	if err != nil {
+		slog.Error("failed to load autorun usage", "error", err)
+		os.Exit(1)
 	}
+	workAutoResumer.SetAutorunGate(autorunGate)
+	// Per-role concurrency caps, read on every check so role edits apply
+	// to the next autorun turn.
+	autorunSlots := work.NewAutorunSlots(workStore, func(agentRoleID string) int {
+		role, found, err := agentRoleStore.Get(agentRoleID)
+		if err != nil {
+			slog.Warn("failed to get agent role", "agentRoleId", agentRoleID, "error", err)
+		}
+		if !found {
+			return 0
+		}
+		return role.MaxConcurrent
+	})
+	workAutoResumer.SetAutorunSlots(autorunSlots)
+
+	setupDefaultAgentRole(settingsStore, s)
 
 	// Initialize agent registry
+	var claudeAgent, codexAgent agent.Agent = claude.New(), codex.New()
+	if *recordEventsFlag {
+		replayDir := filepath.Join(dataDir, "replays")
+		claudeAgent = replay.NewRecordingAgent(claudeAgent, replayDir)
+		codexAgent = replay.NewRecordingAgent(codexAgent, replayDir)
+		slog.Info("recording agent events", "dir", replayDir)
+	}
 	agents := agent.NewRegistry()
-	agents.Register(session.AgentTypeClaude, claude.New())
-	agents.Register(session.AgentTypeCodex, codex.New())
+	agents.Register(session.AgentTypeClaude, claudeAgent)
+	agents.Register(session.AgentTypeCodex, codexAgent)
 
 	// Initialize worktree registry and manager
 	registry := worktree.NewRegistry(workDir, dataDir)
+	registry.Setup().SetConfig(func() setup.Config {
+		s := settingsStore.Get()
+		return setup.Config{
+			CopyFiles: s.WorktreeSetupCopyPaths(),
+			Commands:  s.WorktreeSetupCommandList(),
+		}
+	})
+	auditLog := audit.NewLog(dataDir)
+
+	workHooks := workhook.NewRunner(workDir, auditLog)
+	workHooks.SetHooks(func() []workhook.Hook { return settingsStore.Get().WorkHooks })
+	workStore.SetLifecycleHooks(workHooks)
+	reviewRole := reviewAgentRole(settingsStore, s)
+	workStore.SetReviewRole(reviewRole)
+
+	eventLog, err := eventlog.Open(dataDir, 0)
+	if err != nil {
+		slog.Error("failed to open event log", "error", err)
+		os.Exit(1)
+	}
+	workStore.AddOnChangeListener(eventLog)
+	settingsStore.AddOnChangeListener(eventLog)
+
+	// Worktrees created with isolated_work keep their work in their own
+	// store, configured like the main one, except for the review gate:
+	// reviews are started through Operations, which only runs on the main
+	// store.
+	workStores := worktree.NewWorkStores(workStore, dataDir)
+	workStores.SetConfigure(func(store *work.FileStore) {
+		store.SetDefaultAgentRole(defaultAgentRole(settingsStore, s))
+		store.SetLifecycleHooks(workHooks)
+		store.AddOnChangeListener(eventLog)
+	})
+
 	worktreeManager := worktree.NewManager(registry, agents, dataDir, idleTimeout)
+	worktreeManager.SetWorkStores(workStores)
+	worktreeManager.SetSessionListener(eventLog.SessionListener)
+	worktreeManager.SetAuditLog(auditLog)
 	worktreeManager.SetWorkAutoResumer(workAutoResumer)
+	workAutoResumer.SetProgressProbe(worktree.NewTurnProbe(worktreeManager))
 	worktreeManager.SetWorkNeedsInputSyncer(work.NewNeedsInputSyncer(workStore))
+	worktreeManager.SetAgentEnv(func() []string {
+		return settingsStore.Get().GitIdentity().Env()
+	})
+	worktreeManager.SetToolResultLimit(func() int {
+		return settingsStore.Get().ToolResultLimit()
+	})
+	worktreeManager.SetHideThinking(func() bool {
+		return settingsStore.Get().HideThinking
+	})
+	worktreeManager.SetStuckAfter(func() time.Duration {
+		return settingsStore.Get().StuckAfter()
+	})
+	worktreeManager.SetWarmPool(func() process.WarmPoolConfig {
+		s := settingsStore.Get()
+		return process.WarmPoolConfig{Size: s.WarmPoolSize, AgentType: s.DefaultAgentType, Mode: s.DefaultMode}
+	})
+	worktreeManager.SetPermissionTimeout(func() process.PermissionTimeout {
+		s := settingsStore.Get()
+		return process.PermissionTimeout{
+			After:         time.Duration(s.PermissionTimeoutSeconds) * time.Second,
+			AllowReadOnly: s.PermissionTimeoutAllowReadOnly,
+			Protected:     s.ProtectedPathPatterns(),
+		}
+	})
+	worktreeManager.SetModePolicy(func(mode session.Mode) process.ModePolicy {
+		m, ok := settingsStore.Get().ResolveMode(mode)
+		if !ok {
+			// A custom mode deleted from settings while sessions still use
+			// it: prompting for everything is the safe fallback.
+			slog.Warn("unknown session mode, using default", "mode", mode)
+			return process.ModePolicy{Base: session.ModeDefault}
+		}
+		return process.ModePolicy{
+			Base:               m.BaseMode(),
+			SystemPromptSuffix: m.PromptSuffix,
+			AllowedTools:       m.AllowedTools,
+			Protected:          settingsStore.Get().ProtectedPathPatterns(),
+		}
+	})
+	// Work sessions inherit the idle timeout and MCP servers of the role they
+	// run under.
+	sessionRole := func(sessionID string) (agentrole.AgentRole, bool) {
+		w, found, err := workStore.FindBySessionID(sessionID)
+		if err != nil {
+			slog.Warn("failed to find work for session", "sessionId", sessionID, "error", err)
+		}
+		if !found {
+			return agentrole.AgentRole{}, false
+		}
+		role, found, err := agentRoleStore.Get(w.EffectiveAgentRoleID())
+		if err != nil {
+			slog.Warn("failed to get agent role", "agentRoleId", w.EffectiveAgentRoleID(), "error", err)
+		}
+		return role, found
+	}
+	worktreeManager.SetIdleTimeoutOverride(func(sessionID string) time.Duration {
+		role, found := sessionRole(sessionID)
+		if !found {
+			return 0
+		}
+		return role.IdleTimeout()
+	})
+	worktreeManager.SetRoleMCPServers(func(sessionID string) []agent.MCPServer {
+		role, found := sessionRole(sessionID)
+		if !found {
+			return nil
+		}
+		return role.MCPServers
+	})
+	// Files a work session changed are also recorded on its work item, so
+	// reviewers see what each task touched.
+	worktreeManager.SetOnFilesModified(func(sessionID string, paths []string) {
+		w, found, err := workStore.FindBySessionID(sessionID)
+		if err != nil {
+			slog.Warn("failed to find work for session", "sessionId", sessionID, "error", err)
+		}
+		if !found {
+			return
+		}
+		if err := workStore.AddModifiedFiles(context.Background(), w.ID, paths); err != nil {
+			slog.Warn("failed to record modified files on work", "workId", w.ID, "error", err)
+		}
+	})
 	workStarter := worktree.NewWorkStarter(worktreeManager, agentRoleStore, settingsStore)
 	workStopper := worktree.NewWorkStopper(worktreeManager, workStore)
+	budgetGuard := work.NewBudgetGuard(workStore, workStopper)
+	worktreeManager.SetOnUsage(func(sessionID string, tokens int64) {
+		autorunGate.RecordTokens(sessionID, tokens)
+		budgetGuard.RecordTokens(sessionID, tokens)
+	})
 	// Single implementation of the start/reopen transitions, shared by both the
 	// WebSocket handler (user actions) and the MCP Executor (AI actions).
 	workOps := work.NewOperations(workStore, workStarter, workAutoResumer)
+	workOps.SetSessionCloser(workStopper)
+	workOps.SetLocale(serverLocale)
+	workOps.SetModeResolver(func(mode session.Mode) (session.Mode, bool) {
+		m, ok := settingsStore.Get().ResolveMode(mode)
+		return m.BaseMode(), ok
+	})
 	if err := worktreeManager.Start(); err != nil {
 		slog.Warn("failed to start worktree manager", "error", err)
 	}
+	// Runs before the HTTP server accepts clients, so no session can be
+	// started concurrently with the pass.
+	workAutoResumer.ReconcileOrphanedWork(settingsStore.Get().OrphanedWorkPolicy, workStopper, workOps)
 
 	// Local API token for the MCP subprocess. Randomly generated per startup and
 	// published to server.json, so it never outlives the process and is distinct
@@ -286,16 +564,119 @@ func main() {
 		slog.Error("failed to generate MCP token", "error", err)
 		os.Exit(1)
 	}
-	mcpHandler := mcp.NewAPIHandler(mcp.NewExecutor(workStore, agentRoleStore, workOps, workAutoResumer, settingsStore), mcpToken)
+	watchedOnly := func() bool { return settingsStore.Get().NotifyWatchedOnly }
+	digestGenerator := digest.NewGenerator(digest.NewWorktreeSource(worktreeManager), workStore)
+	digestGenerator.SetWatchedOnly(watchedOnly)
+	digestScheduler := digest.NewScheduler(digestGenerator, settingsStore)
+	digestScheduler.Start()
+
+	dueReminder := work.NewDueReminder(workStore, func() []time.Duration {
+		return settingsStore.Get().DueLeads()
+	})
+	dueReminder.SetWebhook(func() string {
+		return settingsStore.Get().WorkDueWebhookURL
+	})
+	dueReminder.SetWatchedOnly(watchedOnly)
+
+	mcpExecutor := mcp.NewExecutor(workStore, agentRoleStore, workOps, workAutoResumer, settingsStore)
+	mcpExecutor.SetTestRunStore(s.testRun)
+	mcpExecutor.SetAutorunGate(autorunGate)
+	mcpExecutor.SetAutorunSlots(autorunSlots)
+	mcpExecutor.SetAttachmentStore(attachments)
+	mcpExecutor.SetWorkStoreResolver(workStores)
+	mcpExecutor.SetWorkDirResolver(worktreeManager)
+	mcpExecutor.SetReviewRole(reviewRole)
+	mcpGuard := mcp.NewCallGuard(func() mcp.GuardConfig {
+		return mcp.GuardConfigFromSettings(settingsStore.Get())
+	})
+	mcpExecutor.SetCallGuard(mcpGuard)
+	mcpHandler := mcp.NewAPIHandler(mcpExecutor, mcpToken)
+	mcpEvents := mcp.NewEventHub()
+	workStore.AddOnChangeListener(mcpEvents)
+	mcpHandler.SetEvents(mcpEvents)
+
+	githubToken := *githubTokenFlag
+	if githubToken == "" {
+		githubToken = *gitRepoTokenFlag
+	}
+	ciPoller := ci.NewPoller(ci.NewGitHubClient(githubToken), workDir, func() []ci.Target {
+		infos := registry.List()
+		targets := make([]ci.Target, len(infos))
+		for i, info := range infos {
+			targets[i] = ci.Target{Worktree: info.Name, Branch: info.Branch, Dir: info.Path}
+		}
+		return targets
+	})
+	ciFailureNotifier := worktree.NewCIFailureNotifier(worktreeManager, workStore, func() bool {
+		return settingsStore.Get().CIFailureFeedback
+	})
+	ciFailureNotifier.SetLocale(serverLocale)
+	ciPoller.AddOnChangeListener(ciFailureNotifier)
+
+	issueSync, err := issuesync.NewSyncer(dataDir, workStore, workOps, issuesync.Credentials{
+		JiraEmail:   *jiraEmailFlag,
+		JiraToken:   *jiraTokenFlag,
+		LinearToken: *linearTokenFlag,
+	}, func() issuesync.Config { return settingsStore.Get().IssueSyncConfig() })
+	if err != nil {
+		slog.Error("failed to initialize issue sync", "error", err)
+		os.Exit(1)
+	}
 
-	wsHandler := ws.NewRPCHandler(token, version, devMode, commandStore, worktreeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore)
-	handler := newHandler(token, devMode, wsHandler, mcpHandler)
+	wsHandler := ws.NewRPCHandler(token, version, devMode, commandStore, worktreeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore, s.testRun, ciPoller)
+	wsHandler.SetSnapshots(snapshots)
+	wsHandler.SetAuditLog(auditLog)
+	wsHandler.SetEventLog(eventLog)
+	wsHandler.SetFsck(fsck.NewChecker(dataDir, workStore, agentRoleStore, func() string { return settingsStore.Get().DefaultAgentRoleID }))
+	wsHandler.SetClientPrefs(clientPrefs)
+	wsHandler.SetPairing(devices)
+	wsHandler.SetAutorunGate(autorunGate)
+	wsHandler.SetAutorunSlots(autorunSlots)
+	wsHandler.SetIssueSync(issueSync)
+	issueSync.Start()
+	wsHandler.SetMCPCallGuard(mcpGuard)
+	wsHandler.SetAttachmentStore(attachments)
+	autorunGate.Start()
+	wsHandler.SetDueReminder(dueReminder)
+	dueReminder.Start()
+	wsHandler.SetBudgetGuard(budgetGuard)
+	budgetGuard.Start()
+	// Buffered so server.drain never blocks; the shutdown goroutine reads it once.
+	drainCh := make(chan time.Duration, 1)
+	wsHandler.SetDrainer(func(timeout time.Duration) bool {
+		if timeout == 0 {
+			timeout = *drainTimeoutFlag
+		}
+		select {
+		case drainCh <- timeout:
+			return true
+		default:
+			return false
+		}
+	})
+	// Unauthenticated GitHub API access is limited to 60 requests/hour, too
+	// few to poll every branch each minute.
+	if githubToken != "" {
+		ciPoller.Start()
+	} else {
+		slog.Info("CI status polling disabled: no GitHub token")
+	}
+	readiness := health.ReadinessHandler(
+		health.DataDirWritable(dataDir),
+		health.LockAcquirable(dataDir),
+		health.Check{Name: "work_store", Run: func(context.Context) error { return workStore.Verify() }},
+		health.Binary("agent_cli", func() string { return agentBinary(settingsStore.Get().DefaultAgentType) }),
+	)
+	handler := newHandler(devMode, wsHandler, mcpHandler, work.NewAttachmentHandler(workStore, attachments), readiness, issuesync.NewWebhookHandler(issueSync, *syncWebhookSecretFlag))
 
 	portStr := strconv.Itoa(port)
 	srv := &http.Server{
 		Addr:    ":" + portStr,
 		Handler: handler,
 	}
+	// MCP event streams never go idle on their own; end them so Shutdown
+	// doesn't wait out its deadline.
+	srv.RegisterOnShutdown(mcpEvents.Close)
 
 	cloudURL := *cloudURLFlag
 
@@ -336,9 +717,25 @@ func main() {
 		}()
 	}
 
+	// Serve the local MCP API on a unix socket as well. Agents' stdio proxies
+	// prefer it to the TCP port; without it they fall back to the port.
+	var mcpSocket string
+	var mcpSrv *http.Server
+	if ln, err := mcp.ListenSocket(dataDir); err != nil {
+		slog.Warn("failed to listen on mcp socket", "error", err)
+	} else {
+		mcpSocket = ln.Addr().String()
+		mcpSrv = &http.Server{Handler: mcp.NewSocketMux(mcpHandler)}
+		go func() {
+			if err := mcpSrv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				slog.Error("mcp socket server error", "error", err)
+			}
+		}()
+	}
+
 	// Write server.json for orchestration programs to discover the running server
 	localURL := "http://localhost:" + portStr
-	if err := serverinfo.Write(dataDir, port, localURL, remoteURL, mcpToken); err != nil {
+	if err := serverinfo.Write(dataDir, port, localURL, remoteURL, mcpToken, mcpSocket); err != nil {
 		slog.Error("failed to write server.json", "error", err)
 		os.Exit(1)
 	}
@@ -348,22 +745,53 @@ func main() {
 	go func() {
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-		<-sigCh
+		// SIGTERM (service managers, upgrades) drains; SIGINT (Ctrl-C)
+		// stays immediate for interactive use.
+		var drainTimeout time.Duration
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGTERM {
+				drainTimeout = *drainTimeoutFlag
+			}
+		case drainTimeout = <-drainCh:
+		}
 		signal.Stop(sigCh)
 
+		if drainTimeout > 0 {
+			slog.Info("draining agent processes", "timeout", drainTimeout)
+			drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+			worktreeManager.Drain(drainCtx)
+			cancelDrain()
+		}
+
 		slog.Info("shutting down server")
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil {
 			slog.Error("server shutdown error", "error", err)
 		}
+		if mcpSrv != nil {
+			if err := mcpSrv.Shutdown(ctx); err != nil {
+				slog.Error("mcp socket server shutdown error", "error", err)
+			}
+		}
 		if relayManager != nil {
 			cancelRelayStreams()
 			relayManager.Stop()
 		}
 		wsHandler.Stop()
+		digestScheduler.Stop()
+		dueReminder.Stop()
+		budgetGuard.Stop()
+		autorunGate.Stop()
+		ciPoller.Stop()
+		issueSync.Stop()
 		workAutoResumer.Stop()
 		worktreeManager.Shutdown()
+		workHooks.Wait()
+		if err := eventLog.Close(); err != nil {
+			slog.Error("failed to close event log", "error", err)
+		}
 		settingsStore.StopWatching()
 		agentRoleStore.StopWatching()
 		if err := serverinfo.Delete(dataDir); err != nil {
@@ -383,10 +811,15 @@ func main() {
 		Announcement: announcement,
 	})
 
-	// Print QR code if relay is enabled
+	// Print a pairing QR code if relay is enabled, so a phone connects
+	// without the auth token
 	if remoteURL != "" {
-		startup.PrintQRCode(remoteURL)
-		fmt.Println()
+		if code, err := devices.NewCode(pairing.DefaultCodeTTL); err != nil {
+			slog.Warn("failed to mint pairing code", "error", err)
+		} else {
+			startup.PrintQRCode(pairing.URL(remoteURL, code.Code), fmt.Sprintf("Scan to pair (%d min)", int(pairing.DefaultCodeTTL.Minutes())))
+			fmt.Println()
+		}
 	}
 
 	startup.PrintFooter()
@@ -404,9 +837,10 @@ func main() {
 type stores struct {
 	work      *work.FileStore
 	agentRole *agentrole.FileStore
+	testRun   *testrun.FileStore
 }
 
-// initStores creates work and agent-role stores from the given data directory.
+// initStores creates work, agent-role, and test-run stores from the given data directory.
 func initStores(dataDir string) (*stores, error) {
 	workStore, err := work.NewFileStore(dataDir)
 	if err != nil {
@@ -418,7 +852,59 @@ func initStores(dataDir string) (*stores, error) {
 		return nil, fmt.Errorf("failed to initialize agent role store: %w", err)
 	}
 
-	return &stores{work: workStore, agentRole: agentRoleStore}, nil
+	testRunStore, err := testrun.NewFileStore(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize test run store: %w", err)
+	}
+
+	return &stores{work: workStore, agentRole: agentRoleStore, testRun: testRunStore}, nil
+}
+
+// setupDefaultAgentRole makes PM the default agent role on first launch and
+// has work created without a role get the default one. A default that
+// points at a deleted role is ignored rather than assigned.
+func setupDefaultAgentRole(settingsStore *settings.Store, s *stores) {
+	if pmID := s.agentRole.SeededPMRoleID(); pmID != "" {
+		cfg := settingsStore.Get()
+		cfg.DefaultAgentRoleID = pmID
+		if err := settingsStore.Update(cfg); err != nil {
+			slog.Error("failed to set default agent role", "error", err)
+		}
+	}
+	s.work.SetDefaultAgentRole(defaultAgentRole(settingsStore, s))
+}
+
+// defaultAgentRole returns the provider of the role work created without
+// one gets.
+func defaultAgentRole(settingsStore *settings.Store, s *stores) func() string {
+	return func() string {
+		id := settingsStore.Get().DefaultAgentRoleID
+		if id == "" {
+			return ""
+		}
+		if _, found, err := s.agentRole.Get(id); err != nil || !found {
+			slog.Warn("default agent role not found", "agentRoleId", id, "error", err)
+			return ""
+		}
+		return id
+	}
+}
+
+// reviewAgentRole returns the provider of the role stories are reviewed by
+// before closing. Like the default role, one pointing at a deleted role is
+// ignored, which turns the review off.
+func reviewAgentRole(settingsStore *settings.Store, s *stores) func() string {
+	return func() string {
+		id := settingsStore.Get().ReviewAgentRoleID
+		if id == "" {
+			return ""
+		}
+		if _, found, err := s.agentRole.Get(id); err != nil || !found {
+			slog.Warn("review agent role not found", "agentRoleId", id, "error", err)
+			return ""
+		}
+		return id
+	}
 }
 
 // agentRoleStepAdapter adapts agentrole.Store to work.StepProvider.
@@ -440,6 +926,7 @@ func (a *agentRoleStepAdapter) GetSteps(agentRoleID string) ([]string, error) {
 func runMCP() {
 	mcpFlags := flag.NewFlagSet("mcp", flag.ExitOnError)
 	dataDirFlag := mcpFlags.String("data-dir", "", "data directory (required)")
+	sessionIDFlag := mcpFlags.String("session-id", "", "agent session the tool calls belong to")
 	mcpFlags.Parse(os.Args[2:])
 
 	dataDir := *dataDirFlag
@@ -455,6 +942,7 @@ func runMCP() {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	client.SetSessionID(*sessionIDFlag)
 
 	server := mcp.NewServer(client, version)
 	if err := server.Run(context.Background()); err != nil {
@@ -510,3 +998,37 @@ func runCluster() {
 		os.Exit(1)
 	}
 }
+
+// runReplay plays a replay file recorded with -record-events through the
+// process pipeline and prints each step clients would observe as a JSON line.
+func runReplay(args []string, out io.Writer) error {
+	replayFlags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	speed := replayFlags.Float64("speed", 0, "play at this multiple of the recorded timing (0 = as fast as possible)")
+	if err := replayFlags.Parse(args); err != nil {
+		return err
+	}
+	if replayFlags.NArg() != 1 {
+		return errors.New("usage: pockode replay [-speed N] <file>")
+	}
+
+	entries, err := replay.ReadFile(replayFlags.Arg(0))
+	if err != nil {
+		return err
+	}
+	// Keep stderr to what went wrong; the steps are the output.
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	enc := json.NewEncoder(out)
+	var encErr error
+	err = replay.Run(ctx, entries, *speed, func(step replay.Step) {
+		if encErr == nil {
+			encErr = enc.Encode(step)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return encErr
+}
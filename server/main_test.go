@@ -10,10 +10,12 @@ import (
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/agent/claude"
 	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/ci"
 	"github.com/pockode/server/command"
 	"github.com/pockode/server/mcp"
 	"github.com/pockode/server/session"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/testrun"
 	"github.com/pockode/server/work"
 	"github.com/pockode/server/worktree"
 	"github.com/pockode/server/ws"
@@ -32,6 +34,7 @@ func TestHealthEndpoint(t *testing.T) {
 	settingsStore, _ := settings.NewStore(dataDir)
 	workStore, _ := work.NewFileStore(dataDir)
 	agentRoleStore, _ := agentrole.NewFileStore(dataDir)
+	testRunStore, _ := testrun.NewFileStore(dataDir)
 	registry := worktree.NewRegistry(workDir, dataDir)
 	scopeManager := worktree.NewManager(registry, newAgentRegistry(), dataDir, 10*time.Minute)
 	defer scopeManager.Shutdown()
@@ -39,9 +42,9 @@ func TestHealthEndpoint(t *testing.T) {
 	workStarter := worktree.NewWorkStarter(scopeManager, agentRoleStore, settingsStore)
 	workStopper := worktree.NewWorkStopper(scopeManager, workStore)
 	workOps := work.NewOperations(workStore, workStarter, nil)
-	wsHandler := ws.NewRPCHandler("test-token", "test", true, cmdStore, scopeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore)
+	wsHandler := ws.NewRPCHandler("test-token", "test", true, cmdStore, scopeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore, testRunStore, ci.NewPoller(nil, workDir, nil))
 	mcpHandler := mcp.NewAPIHandler(mcp.NewExecutor(workStore, agentRoleStore, workOps, nil, settingsStore), "mcp-token")
-	handler := newHandler("test-token", true, wsHandler, mcpHandler)
+	handler := newHandler(true, wsHandler, mcpHandler, nil, nil, nil)
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	rec := httptest.NewRecorder()
 
@@ -63,6 +66,7 @@ func TestPingEndpoint(t *testing.T) {
 	settingsStore, _ := settings.NewStore(dataDir)
 	workStore, _ := work.NewFileStore(dataDir)
 	agentRoleStore, _ := agentrole.NewFileStore(dataDir)
+	testRunStore, _ := testrun.NewFileStore(dataDir)
 	registry := worktree.NewRegistry(workDir, dataDir)
 	scopeManager := worktree.NewManager(registry, newAgentRegistry(), dataDir, 10*time.Minute)
 	defer scopeManager.Shutdown()
@@ -70,9 +74,9 @@ func TestPingEndpoint(t *testing.T) {
 	workStarter := worktree.NewWorkStarter(scopeManager, agentRoleStore, settingsStore)
 	workStopper := worktree.NewWorkStopper(scopeManager, workStore)
 	workOps := work.NewOperations(workStore, workStarter, nil)
-	wsHandler := ws.NewRPCHandler(token, "test", true, cmdStore, scopeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore)
+	wsHandler := ws.NewRPCHandler(token, "test", true, cmdStore, scopeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore, testRunStore, ci.NewPoller(nil, workDir, nil))
 	mcpHandler := mcp.NewAPIHandler(mcp.NewExecutor(workStore, agentRoleStore, workOps, nil, settingsStore), "mcp-token")
-	handler := newHandler(token, true, wsHandler, mcpHandler)
+	handler := newHandler(true, wsHandler, mcpHandler, nil, nil, nil)
 
 	t.Run("returns pong with valid token", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
@@ -117,6 +121,7 @@ func TestMCPEndpoint(t *testing.T) {
 	settingsStore, _ := settings.NewStore(dataDir)
 	workStore, _ := work.NewFileStore(dataDir)
 	agentRoleStore, _ := agentrole.NewFileStore(dataDir)
+	testRunStore, _ := testrun.NewFileStore(dataDir)
 	registry := worktree.NewRegistry(workDir, dataDir)
 	scopeManager := worktree.NewManager(registry, newAgentRegistry(), dataDir, 10*time.Minute)
 	defer scopeManager.Shutdown()
@@ -124,9 +129,9 @@ func TestMCPEndpoint(t *testing.T) {
 	workStarter := worktree.NewWorkStarter(scopeManager, agentRoleStore, settingsStore)
 	workStopper := worktree.NewWorkStopper(scopeManager, workStore)
 	workOps := work.NewOperations(workStore, workStarter, nil)
-	wsHandler := ws.NewRPCHandler(userToken, "test", true, cmdStore, scopeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore)
+	wsHandler := ws.NewRPCHandler(userToken, "test", true, cmdStore, scopeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore, testRunStore, ci.NewPoller(nil, workDir, nil))
 	mcpHandler := mcp.NewAPIHandler(mcp.NewExecutor(workStore, agentRoleStore, workOps, nil, settingsStore), mcpToken)
-	handler := newHandler(userToken, true, wsHandler, mcpHandler)
+	handler := newHandler(true, wsHandler, mcpHandler, nil, nil, nil)
 
 	const path = "/api/mcp/tools/call"
 	body := `{"name":"agent_role_list","arguments":{}}`
@@ -162,4 +167,14 @@ func TestMCPEndpoint(t *testing.T) {
 			t.Errorf("got status %d, want %d", rec.Code, http.StatusUnauthorized)
 		}
 	})
+
+	t.Run("event stream rejects user --auth-token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/mcp/events", nil)
+		req.Header.Set("Authorization", "Bearer "+userToken)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("got status %d, want %d", rec.Code, http.StatusUnauthorized)
+		}
+	})
 }
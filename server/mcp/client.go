@@ -1,11 +1,14 @@
 package mcp
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -13,11 +16,14 @@ import (
 )
 
 // Client forwards MCP tool calls from the stdio proxy to the running server's
-// local API. The base URL and token are discovered from server.json.
+// local API. The socket or base URL and the token are discovered from
+// server.json.
 type Client struct {
-	baseURL string
-	token   string
-	http    *http.Client
+	baseURL   string
+	token     string
+	sessionID string
+	storeDir  string // worktree data dir sent as WorkStoreHeader; "" = main store
+	http      *http.Client
 }
 
 // APIError is returned for non-2xx responses from the local MCP API. The status
@@ -33,11 +39,24 @@ func (e *APIError) Error() string { return e.Message }
 // NewClientFromServerInfo reads server.json from dataDir and builds a client
 // pointed at the running server's local API. The MCP subprocess is always
 // spawned by a running server, so a missing server.json is an error.
+//
+// A worktree with its own work store runs the subprocess with the worktree's
+// data dir (<dataDir>/worktrees/<name>). server.json is then read from the
+// enclosing data dir, and tool calls name the worktree's dir so the server
+// runs them against its store.
 func NewClientFromServerInfo(dataDir string) (*Client, error) {
 	info, err := serverinfo.Read(dataDir)
 	if err != nil {
 		return nil, fmt.Errorf("read server.json: %w", err)
 	}
+	var storeDir string
+	if parent := filepath.Dir(dataDir); info == nil && filepath.Base(parent) == "worktrees" {
+		info, err = serverinfo.Read(filepath.Dir(parent))
+		if err != nil {
+			return nil, fmt.Errorf("read server.json: %w", err)
+		}
+		storeDir = dataDir
+	}
 	if info == nil {
 		return nil, fmt.Errorf("server.json not found in %s: is the pockode server running?", dataDir)
 	}
@@ -49,17 +68,31 @@ func NewClientFromServerInfo(dataDir string) (*Client, error) {
 	if baseURL == "" {
 		baseURL = fmt.Sprintf("http://localhost:%d", info.Port)
 	}
+	// Bounded so a wedged server can't hang the tool call (and the AI) forever.
+	// Generous because work_start spawns an agent process server-side; normal
+	// calls finish in well under a second.
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	if info.MCPSocket != "" {
+		if _, err := os.Stat(info.MCPSocket); err == nil {
+			baseURL = socketBaseURL
+			httpClient.Transport = socketTransport(info.MCPSocket)
+		}
+	}
 
 	return &Client{
-		baseURL: strings.TrimRight(baseURL, "/"),
-		token:   info.Token,
-		// Bounded so a wedged server can't hang the tool call (and the AI) forever.
-		// Generous because work_start spawns an agent process server-side; normal
-		// calls finish in well under a second.
-		http: &http.Client{Timeout: 60 * time.Second},
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		token:    info.Token,
+		storeDir: storeDir,
+		http:     httpClient,
 	}, nil
 }
 
+// SetSessionID attributes tool calls to the agent session the proxy serves,
+// for per-session limits.
+func (c *Client) SetSessionID(id string) {
+	c.sessionID = id
+}
+
 // CallTool invokes a tool on the server and returns its result. A non-2xx
 // response is reported as *APIError; a tool whose handler failed comes back as
 // a normal response with IsError set.
@@ -75,6 +108,12 @@ func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage
 	}
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Content-Type", "application/json")
+	if c.sessionID != "" {
+		req.Header.Set(SessionHeader, c.sessionID)
+	}
+	if c.storeDir != "" {
+		req.Header.Set(WorkStoreHeader, c.storeDir)
+	}
 
 	resp, err := c.http.Do(req)
 	if err != nil {
@@ -100,3 +139,61 @@ func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage
 	}
 	return out, nil
 }
+
+// StreamEvents subscribes to the server's work change stream and calls fn for
+// each change until ctx is done or the stream ends. It returns nil only when
+// ctx is cancelled; any other end (server restart, network error) is an error
+// so the caller can reconnect.
+func (c *Client) StreamEvents(ctx context.Context, fn func(WorkChange)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+EventsPath, nil)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "text/event-stream")
+
+	// The stream is open-ended, so it must not inherit the per-call timeout.
+	streamClient := &http.Client{Transport: c.http.Transport}
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("open event stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxRequestBody)
+	var event, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if event == "work_changed" && data != "" {
+				var change WorkChange
+				if err := json.Unmarshal([]byte(data), &change); err != nil {
+					return fmt.Errorf("decode work change: %w", err)
+				}
+				fn(change)
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+		// Lines starting with ":" are keep-alive comments.
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read event stream: %w", err)
+	}
+	return fmt.Errorf("event stream closed by server")
+}
@@ -0,0 +1,164 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/work"
+)
+
+// EventsPath is the local HTTP endpoint the stdio proxy subscribes to for work
+// change notifications. It streams Server-Sent Events.
+const EventsPath = "/api/mcp/events"
+
+// eventBuffer bounds each subscriber's backlog. A proxy that falls this far
+// behind drops events rather than stalling the work store's notify path;
+// notifications are hints to re-read, so a gap costs at most one stale view.
+const eventBuffer = 64
+
+// eventKeepAlive is how often an idle stream sends an SSE comment, so a proxy
+// whose server vanished without closing the socket notices and reconnects.
+const eventKeepAlive = 30 * time.Second
+
+// WorkChange is the payload of a work-changed notification. It carries enough
+// to decide whether to call work_get without embedding the whole body.
+type WorkChange struct {
+	Op       work.Operation  `json:"op"`
+	WorkID   string          `json:"work_id"`
+	Type     work.WorkType   `json:"type"`
+	Title    string          `json:"title"`
+	Status   work.WorkStatus `json:"status"`
+	ParentID string          `json:"parent_id,omitempty"`
+}
+
+// EventHub fans work store changes out to connected stdio proxies. Register it
+// with work.Store.AddOnChangeListener and hand it to APIHandler.SetEvents.
+type EventHub struct {
+	subsMu sync.Mutex
+	subs   map[chan WorkChange]struct{}
+	closed bool
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subs: make(map[chan WorkChange]struct{})}
+}
+
+// OnWorkChange implements work.OnChangeListener. It never blocks: a full
+// subscriber buffer drops the event for that subscriber only.
+func (h *EventHub) OnWorkChange(event work.ChangeEvent) {
+	change := WorkChange{
+		Op:       event.Op,
+		WorkID:   event.Work.ID,
+		Type:     event.Work.Type,
+		Title:    event.Work.Title,
+		Status:   event.Work.Status,
+		ParentID: event.Work.ParentID,
+	}
+
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- change:
+		default:
+			slog.Debug("mcp event subscriber lagging, dropping work change", "workId", change.WorkID)
+		}
+	}
+}
+
+// subscribe registers a new stream. The returned channel is closed by the
+// unsubscribe func or by Close, whichever comes first. ok is false once the
+// hub is closed.
+func (h *EventHub) subscribe() (ch chan WorkChange, unsubscribe func(), ok bool) {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	if h.closed {
+		return nil, nil, false
+	}
+	ch = make(chan WorkChange, eventBuffer)
+	h.subs[ch] = struct{}{}
+	return ch, func() {
+		h.subsMu.Lock()
+		defer h.subsMu.Unlock()
+		if _, exists := h.subs[ch]; exists {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}, true
+}
+
+func (h *EventHub) subscriberCount() int {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	return len(h.subs)
+}
+
+// Close ends every open stream and rejects new ones. Streams are long-lived
+// requests, so the HTTP server's graceful shutdown would otherwise wait on
+// them until its deadline; register Close with http.Server.RegisterOnShutdown.
+func (h *EventHub) Close() {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// serveEvents streams work changes as SSE "work_changed" events until the
+// client disconnects or the hub closes.
+func (h *APIHandler) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		writeJSONError(w, http.StatusNotFound, "event stream not available")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	ch, unsubscribe, ok := h.events.subscribe()
+	if !ok {
+		writeJSONError(w, http.StatusServiceUnavailable, "server shutting down")
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case change, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				slog.Error("failed to marshal work change event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: work_changed\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
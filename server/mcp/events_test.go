@@ -0,0 +1,153 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/work"
+)
+
+// lineWriter delivers each line the proxy writes to stdout on a channel.
+type lineWriter struct{ lines chan string }
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.lines <- line
+	}
+	return len(p), nil
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEventHub_Close(t *testing.T) {
+	hub := NewEventHub()
+	ch, unsubscribe, ok := hub.subscribe()
+	if !ok {
+		t.Fatal("subscribe failed on open hub")
+	}
+	hub.Close()
+	if _, open := <-ch; open {
+		t.Error("channel should be closed by Close")
+	}
+	unsubscribe() // must not double-close
+	if _, _, ok := hub.subscribe(); ok {
+		t.Error("subscribe should fail after Close")
+	}
+}
+
+func TestEventHub_DropsWhenLagging(t *testing.T) {
+	hub := NewEventHub()
+	ch, unsubscribe, _ := hub.subscribe()
+	defer unsubscribe()
+
+	// Never blocks, even with nobody reading.
+	for i := 0; i < eventBuffer+10; i++ {
+		hub.OnWorkChange(work.ChangeEvent{Op: work.OperationUpdate, Work: work.Work{ID: fmt.Sprint(i)}})
+	}
+	if len(ch) != eventBuffer {
+		t.Errorf("buffered %d events, want %d", len(ch), eventBuffer)
+	}
+}
+
+func TestServeEvents_NotConfigured(t *testing.T) {
+	ts := newTestExec(t)
+	srv := httptest.NewServer(NewAPIHandler(ts.exec, "secret"))
+	defer srv.Close()
+
+	client := &Client{baseURL: srv.URL, token: "secret", http: srv.Client()}
+	err := client.StreamEvents(context.Background(), func(WorkChange) {})
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("err = %v, want 404 APIError", err)
+	}
+}
+
+func TestProxyNotifications(t *testing.T) {
+	ts := newTestExec(t)
+	hub := NewEventHub()
+	ts.store.AddOnChangeListener(hub)
+	handler := NewAPIHandler(ts.exec, "secret")
+	handler.SetEvents(hub)
+	httpSrv := httptest.NewServer(handler)
+	defer httpSrv.Close()
+	defer hub.Close()
+
+	client := &Client{baseURL: httpSrv.URL, token: "secret", http: httpSrv.Client()}
+	s := NewServer(client, "test")
+
+	in, stdin := io.Pipe()
+	out := &lineWriter{lines: make(chan string, 16)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := s.run(ctx, in, out); err != nil {
+			t.Errorf("run: %v", err)
+		}
+	}()
+	defer stdin.Close()
+
+	fmt.Fprintln(stdin, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	waitFor(t, func() bool { return hub.subscriberCount() == 1 })
+
+	created, err := ts.store.Create(context.Background(), work.Work{Type: work.WorkTypeStory, Title: "Pushed", AgentRoleID: ts.roleID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var methods []string
+	timeout := time.After(2 * time.Second)
+	for len(methods) < 2 {
+		select {
+		case line := <-out.lines:
+			var n struct {
+				Method string     `json:"method"`
+				Params WorkChange `json:"params"`
+			}
+			if err := json.Unmarshal([]byte(line), &n); err != nil {
+				t.Fatalf("unmarshal %q: %v", line, err)
+			}
+			if n.Method == WorkChangedMethod {
+				if n.Params.WorkID != created.ID || n.Params.Op != work.OperationCreate || n.Params.Title != "Pushed" {
+					t.Errorf("params = %+v, want create of %s", n.Params, created.ID)
+				}
+			}
+			methods = append(methods, n.Method)
+		case <-timeout:
+			t.Fatalf("got notifications %v, want work_changed then list_changed", methods)
+		}
+	}
+	if methods[0] != WorkChangedMethod || methods[1] != "notifications/tools/list_changed" {
+		t.Errorf("notifications = %v", methods)
+	}
+}
+
+func TestInitialize_AdvertisesNotifications(t *testing.T) {
+	resp := callMethod(t, NewServer(&Client{}, "test"), "initialize", nil)
+	b, _ := json.Marshal(resp.Result)
+	var result initializeResult
+	if err := json.Unmarshal(b, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Capabilities.Tools == nil || !result.Capabilities.Tools.ListChanged {
+		t.Error("tools.listChanged should be advertised")
+	}
+	if _, ok := result.Capabilities.Experimental["pockode/work_changed"]; !ok {
+		t.Error("pockode/work_changed should be advertised")
+	}
+}
@@ -1,15 +1,22 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/session"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/testrun"
 	"github.com/pockode/server/work"
 )
 
@@ -36,12 +43,20 @@ func userErrorf(format string, a ...any) error {
 // caller errors too, so they count even when they reach here unwrapped.
 func isUserError(err error) bool {
 	var ue *userError
-	if errors.As(err, &ue) {
+	var ae *argumentError
+	if errors.As(err, &ue) || errors.As(err, &ae) {
 		return true
 	}
 	return errors.Is(err, work.ErrWorkNotFound) ||
 		errors.Is(err, work.ErrInvalidWork) ||
-		errors.Is(err, work.ErrCommentNotFound)
+		errors.Is(err, work.ErrHookRefused) ||
+		errors.Is(err, work.ErrSessionTrashed) ||
+		errors.Is(err, work.ErrCommentNotFound) ||
+		errors.Is(err, work.ErrAttachmentNotFound) ||
+		errors.Is(err, work.ErrInvalidAttachment) ||
+		errors.Is(err, testrun.ErrInvalidRun) ||
+		errors.Is(err, agentrole.ErrNotFound) ||
+		errors.Is(err, agentrole.ErrInvalidRole)
 }
 
 // WorkNotifier delivers the next-step prompt that follows an in-process
@@ -60,6 +75,18 @@ type SettingsStore interface {
 	Update(settings.Settings) error
 }
 
+// WorkDirResolver finds the working directory of the worktree an agent
+// session runs in, for the git tools.
+type WorkDirResolver interface {
+	WorkDirForSession(sessionID string) (string, bool)
+}
+
+// WorkStoreResolver finds the work store of a worktree with its own store,
+// given the data dir its MCP proxy runs with (see WorkStoreHeader).
+type WorkStoreResolver interface {
+	ForDataDir(dataDir string) (work.Store, bool, error)
+}
+
 // Executor runs MCP tool calls against the live server stores. It is the
 // in-process counterpart to the stdio proxy: the proxy (running inside the AI
 // CLI subprocess) forwards each tool call over HTTP, and the Executor performs
@@ -71,39 +98,139 @@ type Executor struct {
 	ops            *work.Operations
 	notifier       WorkNotifier
 	settingsStore  SettingsStore
+	testRunStore   testrun.Store
+	autorunGate    *work.AutorunGate
+	autorunSlots   *work.AutorunSlots
+	guard          *CallGuard
+	attachments    *work.AttachmentStore
+	workStores     WorkStoreResolver
+	workDirs       WorkDirResolver
+	reviewRole     func() string
+	isolated       bool // running against a worktree's own store; see forWorktree
 }
 
 // NewExecutor creates an Executor. ops performs the start/reopen transitions and
 // their side effects (the same operations the WebSocket layer calls); it is
 // required whenever work_start or work_reopen is reachable. notifier delivers
 // the step-advance message on step_done; a nil notifier skips that follow-up.
-// settingsStore keeps the default agent role in sync on reset; a nil
-// settingsStore skips that update. Nils are tolerated only where the
+// settingsStore keeps the default agent role in sync on reset and marks it
+// in agent_role_list; a nil settingsStore skips both. Nils are tolerated only where the
 // corresponding tools are unreachable (e.g. narrow tests).
 func NewExecutor(store work.Store, agentRoleStore agentrole.Store, ops *work.Operations, notifier WorkNotifier, settingsStore SettingsStore) *Executor {
 	return &Executor{store: store, agentRoleStore: agentRoleStore, ops: ops, notifier: notifier, settingsStore: settingsStore}
 }
 
+// SetTestRunStore enables test_report. Without it the tool reports that test
+// reporting is unavailable.
+func (e *Executor) SetTestRunStore(store testrun.Store) {
+	e.testRunStore = store
+}
+
+// SetAutorunGate makes work_start fail while autorun is paused, since work
+// an agent starts runs unattended.
+func (e *Executor) SetAutorunGate(g *work.AutorunGate) {
+	e.autorunGate = g
+}
+
+// SetAutorunSlots makes work_start fail while the role it would run under
+// has no free slot.
+func (e *Executor) SetAutorunSlots(s *work.AutorunSlots) {
+	e.autorunSlots = s
+}
+
+// SetAttachmentStore enables attachment_read and lists attachments in
+// work_get. Without it attachment_read reports attachments are unavailable.
+func (e *Executor) SetAttachmentStore(s *work.AttachmentStore) {
+	e.attachments = s
+}
+
+// SetReviewRole sets the provider of the role step_done starts a story's
+// review under when the store asks for one (work.ErrReviewRequired). Use the
+// provider the work stores were given.
+func (e *Executor) SetReviewRole(fn func() string) {
+	e.reviewRole = fn
+}
+
+// SetWorkStoreResolver makes tool calls from a worktree with its own work
+// store run against that store.
+func (e *Executor) SetWorkStoreResolver(r WorkStoreResolver) {
+	e.workStores = r
+}
+
+// SetWorkDirResolver enables git_status and git_diff, scoped to the
+// worktree of the calling session.
+func (e *Executor) SetWorkDirResolver(r WorkDirResolver) {
+	e.workDirs = r
+}
+
+// SetCallGuard makes every known tool call pass g first, attributed to the
+// session from SessionIDFromContext.
+func (e *Executor) SetCallGuard(g *CallGuard) {
+	e.guard = g
+}
+
 // Execute runs the named tool and returns its text result. It returns a
 // wrapped ErrUnknownTool when the name is not recognized.
 func (e *Executor) Execute(ctx context.Context, name string, args json.RawMessage) (string, error) {
+	if e.guard != nil && isKnownTool(name) {
+		if err := e.guard.Check(SessionIDFromContext(ctx), name, args); err != nil {
+			return "", err
+		}
+	}
+	if def, ok := findTool(name); ok {
+		if err := validateArguments(name, def.InputSchema, args); err != nil {
+			return "", err
+		}
+	}
+	if dir := workStoreDirFromContext(ctx); dir != "" && e.workStores != nil {
+		store, found, err := e.workStores.ForDataDir(dir)
+		if err != nil {
+			return "", fmt.Errorf("open worktree work store: %w", err)
+		}
+		if found {
+			e = e.forWorktree(store)
+		}
+	}
+	if e.isolated && sharedOnlyTools[name] {
+		return "", userErrorf("%s is not available for work in a worktree-scoped store", name)
+	}
+	args, err := e.resolveShortIDs(args)
+	if err != nil {
+		return "", err
+	}
 	switch name {
 	case "work_list":
 		return e.workList(args)
+	case "work_search":
+		return e.workSearch(args)
+	case "work_next":
+		return e.workNext(args)
 	case "work_create":
 		return e.workCreate(ctx, args)
+	case "work_split":
+		return e.workSplit(ctx, args)
 	case "work_update":
 		return e.workUpdate(ctx, args)
 	case "work_get":
 		return e.workGet(args)
+	case "work_context":
+		return e.workContext(args)
 	case "work_delete":
 		return e.workDelete(ctx, args)
 	case "work_start":
 		return e.workStart(ctx, args)
 	case "work_needs_input":
 		return e.workNeedsInput(ctx, args)
+	case "work_plan_submit":
+		return e.workPlanSubmit(ctx, args)
 	case "work_reopen":
 		return e.workReopen(ctx, args)
+	case "work_cancel":
+		return e.workCancel(ctx, args)
+	case "work_bulk":
+		return e.workBulk(ctx, args)
+	case "work_log_time":
+		return e.workLogTime(ctx, args)
 	case "work_wait":
 		return e.workWait(ctx, args)
 	case "step_done":
@@ -114,10 +241,24 @@ func (e *Executor) Execute(ctx context.Context, name string, args json.RawMessag
 		return e.workCommentList(args)
 	case "work_comment_update":
 		return e.workCommentUpdate(ctx, args)
+	case "attachment_read":
+		return e.attachmentRead(args)
+	case "test_report":
+		return e.testReport(ctx, args)
+	case "git_status":
+		return e.gitStatus(ctx)
+	case "git_diff":
+		return e.gitDiff(ctx, args)
 	case "agent_role_list":
 		return e.agentRoleList()
 	case "agent_role_get":
 		return e.agentRoleGet(args)
+	case "agent_role_create":
+		return e.agentRoleCreate(ctx, args)
+	case "agent_role_update":
+		return e.agentRoleUpdate(ctx, args)
+	case "agent_role_delete":
+		return e.agentRoleDelete(ctx, args)
 	case "agent_role_reset_defaults":
 		return e.agentRoleResetDefaults(ctx)
 	default:
@@ -125,10 +266,30 @@ func (e *Executor) Execute(ctx context.Context, name string, args json.RawMessag
 	}
 }
 
+// sharedOnlyTools need the server-wide work operations (agent sessions,
+// autorun, the auto-resumer), which only act on the main store.
+var sharedOnlyTools = map[string]bool{
+	"work_start":  true,
+	"work_reopen": true,
+	"work_cancel": true,
+	"work_bulk":   true,
+}
+
+// forWorktree returns a copy of e that runs against a worktree's own store.
+// Collaborators bound to the main store are dropped: the step-advance
+// notifier, attachments, and the operations behind sharedOnlyTools.
+func (e *Executor) forWorktree(store work.Store) *Executor {
+	c := *e
+	c.store = store
+	c.ops = nil
+	c.notifier = nil
+	c.attachments = nil
+	c.isolated = true
+	return &c
+}
+
 func (e *Executor) workList(args json.RawMessage) (string, error) {
-	var params struct {
-		ParentID string `json:"parent_id"`
-	}
+	var params work.ListQuery
 	if len(args) > 0 {
 		if err := json.Unmarshal(args, &params); err != nil {
 			return "", userErrorf("invalid arguments: %w", err)
@@ -140,44 +301,152 @@ func (e *Executor) workList(args json.RawMessage) (string, error) {
 		return "", err
 	}
 
-	if params.ParentID != "" {
-		var filtered []work.Work
-		for _, w := range works {
-			if w.ParentID == params.ParentID {
-				filtered = append(filtered, w)
-			}
-		}
-		works = filtered
+	page, err := work.Query(works, params)
+	if err != nil {
+		return "", err
 	}
 
-	// Always return JSON array for consistent parsing by the AI agent.
+	// Always return JSON for consistent parsing by the AI agent.
 	// Formatted text would risk prompt injection via user-supplied titles.
 	type workItem struct {
-		ID          string `json:"id"`
-		Type        string `json:"type"`
-		ParentID    string `json:"parent_id,omitempty"`
-		AgentRoleID string `json:"agent_role_id,omitempty"`
-		Status      string `json:"status"`
-		Title       string `json:"title"`
-	}
-	items := make([]workItem, len(works))
-	for i, w := range works {
+		ID          string         `json:"id"`
+		ShortID     string         `json:"short_id,omitempty"`
+		Type        string         `json:"type"`
+		ParentID    string         `json:"parent_id,omitempty"`
+		AgentRoleID string         `json:"agent_role_id,omitempty"`
+		Status      string         `json:"status"`
+		Title       string         `json:"title"`
+		DueAt       time.Time      `json:"due_at,omitzero"`
+		Progress    *work.Progress `json:"progress,omitempty"`
+	}
+	items := make([]workItem, len(page.Items))
+	for i, w := range page.Items {
 		items[i] = workItem{
 			ID:          w.ID,
+			ShortID:     w.ShortID,
 			Type:        string(w.Type),
 			ParentID:    w.ParentID,
 			AgentRoleID: w.AgentRoleID,
 			Status:      string(w.Status),
 			Title:       w.Title,
+			DueAt:       w.DueAt,
+			Progress:    w.Progress,
 		}
 	}
-	b, err := json.Marshal(items)
+	b, err := json.Marshal(struct {
+		Items []workItem `json:"items"`
+		Total int        `json:"total"`
+	}{items, page.Total})
 	if err != nil {
 		return "", fmt.Errorf("marshal work list: %w", err)
 	}
 	return string(b), nil
 }
 
+func (e *Executor) workSearch(args json.RawMessage) (string, error) {
+	var params work.SearchQuery
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	works, err := e.store.List()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := work.Search(works, params)
+	if err != nil {
+		return "", err
+	}
+
+	// Matches are marked with ** in title and snippet: compact for the AI,
+	// unlike the highlight runs the WebSocket returns for rendering.
+	type hitItem struct {
+		ID       string `json:"id"`
+		ShortID  string `json:"short_id,omitempty"`
+		Type     string `json:"type"`
+		ParentID string `json:"parent_id,omitempty"`
+		Status   string `json:"status"`
+		Title    string `json:"title"`
+		Snippet  string `json:"snippet,omitempty"`
+		Score    int    `json:"score"`
+	}
+	hits := make([]hitItem, len(result.Hits))
+	for i, h := range result.Hits {
+		hits[i] = hitItem{
+			ID:       h.Work.ID,
+			ShortID:  h.Work.ShortID,
+			Type:     string(h.Work.Type),
+			ParentID: h.Work.ParentID,
+			Status:   string(h.Work.Status),
+			Title:    markHighlights(h.Title),
+			Snippet:  markHighlights(h.Snippet),
+			Score:    h.Score,
+		}
+	}
+	b, err := json.Marshal(struct {
+		Hits  []hitItem `json:"hits"`
+		Total int       `json:"total"`
+	}{hits, result.Total})
+	if err != nil {
+		return "", fmt.Errorf("marshal work search: %w", err)
+	}
+	return string(b), nil
+}
+
+func (e *Executor) workNext(args json.RawMessage) (string, error) {
+	var params work.NextQuery
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	works, err := e.store.List()
+	if err != nil {
+		return "", err
+	}
+
+	type nextItem struct {
+		ID          string    `json:"id"`
+		ShortID     string    `json:"short_id,omitempty"`
+		Type        string    `json:"type"`
+		ParentID    string    `json:"parent_id,omitempty"`
+		AgentRoleID string    `json:"agent_role_id"`
+		Title       string    `json:"title"`
+		DueAt       time.Time `json:"due_at,omitzero"`
+	}
+	var result struct {
+		Work *nextItem `json:"work"`
+	}
+	if w, found := work.Next(works, params, time.Now()); found {
+		result.Work = &nextItem{
+			ID:          w.ID,
+			ShortID:     w.ShortID,
+			Type:        string(w.Type),
+			ParentID:    w.ParentID,
+			AgentRoleID: w.AgentRoleID,
+			Title:       w.Title,
+			DueAt:       w.DueAt,
+		}
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("marshal work next: %w", err)
+	}
+	return string(b), nil
+}
+
+func markHighlights(hs []work.Highlight) string {
+	var b strings.Builder
+	for _, h := range hs {
+		if h.Match {
+			b.WriteString("**" + h.Text + "**")
+		} else {
+			b.WriteString(h.Text)
+		}
+	}
+	return b.String()
+}
+
 func (e *Executor) workCreate(ctx context.Context, args json.RawMessage) (string, error) {
 	var params struct {
 		Type        work.WorkType `json:"type"`
@@ -185,19 +454,25 @@ func (e *Executor) workCreate(ctx context.Context, args json.RawMessage) (string
 		Title       string        `json:"title"`
 		Body        string        `json:"body"`
 		AgentRoleID string        `json:"agent_role_id"`
+		DueAt       string        `json:"due_at"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
 		return "", userErrorf("invalid arguments: %w", err)
 	}
 
-	// Validate agent_role_id is provided and exists
-	if params.AgentRoleID == "" {
-		return "", userErrorf("agent_role_id is required")
+	// Validate agent_role_id exists if given; the store falls back to the
+	// default agent role otherwise.
+	if params.AgentRoleID != "" {
+		if _, found, err := e.agentRoleStore.Get(params.AgentRoleID); err != nil {
+			return "", fmt.Errorf("failed to validate agent role: %w", err)
+		} else if !found {
+			return "", userErrorf("agent role %q not found", params.AgentRoleID)
+		}
 	}
-	if _, found, err := e.agentRoleStore.Get(params.AgentRoleID); err != nil {
-		return "", fmt.Errorf("failed to validate agent role: %w", err)
-	} else if !found {
-		return "", userErrorf("agent role %q not found", params.AgentRoleID)
+
+	dueAt, err := work.ParseDueAt(params.DueAt)
+	if err != nil {
+		return "", err
 	}
 
 	created, err := e.store.Create(ctx, work.Work{
@@ -206,20 +481,77 @@ func (e *Executor) workCreate(ctx context.Context, args json.RawMessage) (string
 		Title:       params.Title,
 		Body:        params.Body,
 		AgentRoleID: params.AgentRoleID,
+		DueAt:       dueAt,
 	})
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("Created %s %q (ID: %s)", created.Type, created.Title, created.ID), nil
+	return fmt.Sprintf("Created %s %s %q (ID: %s)", created.Type, created.ShortID, created.Title, created.ID), nil
+}
+
+func (e *Executor) workSplit(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		StoryID string `json:"story_id"`
+		Tasks   []struct {
+			Title       string `json:"title"`
+			Body        string `json:"body"`
+			AgentRoleID string `json:"agent_role_id"`
+			DueAt       string `json:"due_at"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	tasks := make([]work.Work, len(params.Tasks))
+	for i, t := range params.Tasks {
+		if t.AgentRoleID != "" {
+			if _, found, err := e.agentRoleStore.Get(t.AgentRoleID); err != nil {
+				return "", fmt.Errorf("failed to validate agent role: %w", err)
+			} else if !found {
+				return "", userErrorf("task %d: agent role %q not found", i+1, t.AgentRoleID)
+			}
+		}
+		dueAt, err := work.ParseDueAt(t.DueAt)
+		if err != nil {
+			return "", fmt.Errorf("task %d: %w", i+1, err)
+		}
+		tasks[i] = work.Work{
+			Type:        work.WorkTypeTask,
+			ParentID:    params.StoryID,
+			Title:       t.Title,
+			Body:        t.Body,
+			AgentRoleID: t.AgentRoleID,
+			DueAt:       dueAt,
+		}
+	}
+
+	created, err := e.store.CreateAll(ctx, tasks)
+	if err != nil {
+		return "", err
+	}
+	links := make([]workLink, len(created))
+	for i, w := range created {
+		links[i] = workLink{ID: w.ID, ShortID: w.ShortID, Title: w.Title, Status: string(w.Status)}
+	}
+	b, err := json.Marshal(struct {
+		Created []workLink `json:"created"`
+	}{links})
+	if err != nil {
+		return "", fmt.Errorf("marshal created tasks: %w", err)
+	}
+	return string(b), nil
 }
 
 func (e *Executor) workUpdate(ctx context.Context, args json.RawMessage) (string, error) {
 	var params struct {
-		ID          string  `json:"id"`
-		Title       *string `json:"title"`
-		Body        *string `json:"body"`
-		AgentRoleID *string `json:"agent_role_id"`
+		ID              string  `json:"id"`
+		Title           *string `json:"title"`
+		Body            *string `json:"body"`
+		AgentRoleID     *string `json:"agent_role_id"`
+		EstimateMinutes *int    `json:"estimate_minutes"`
+		DueAt           *string `json:"due_at"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
 		return "", userErrorf("invalid arguments: %w", err)
@@ -235,9 +567,17 @@ func (e *Executor) workUpdate(ctx context.Context, args json.RawMessage) (string
 	}
 
 	fields := work.UpdateFields{
-		Title:       params.Title,
-		Body:        params.Body,
-		AgentRoleID: params.AgentRoleID,
+		Title:           params.Title,
+		Body:            params.Body,
+		AgentRoleID:     params.AgentRoleID,
+		EstimateMinutes: params.EstimateMinutes,
+	}
+	if params.DueAt != nil {
+		dueAt, err := work.ParseDueAt(*params.DueAt)
+		if err != nil {
+			return "", err
+		}
+		fields.DueAt = &dueAt
 	}
 	if err := e.store.Update(ctx, params.ID, fields); err != nil {
 		return "", err
@@ -253,12 +593,42 @@ func (e *Executor) workUpdate(ctx context.Context, args json.RawMessage) (string
 	if params.AgentRoleID != nil {
 		parts = append(parts, "agent_role_id")
 	}
+	if params.EstimateMinutes != nil {
+		parts = append(parts, "estimate_minutes")
+	}
+	if params.DueAt != nil {
+		parts = append(parts, "due_at")
+	}
 	if len(parts) == 0 {
 		return fmt.Sprintf("Updated work %s (no fields changed)", params.ID), nil
 	}
 	return fmt.Sprintf("Updated work %s %s", params.ID, strings.Join(parts, " and ")), nil
 }
 
+// workDetail is the full view of a work item that work_get returns.
+type workDetail struct {
+	ID          string `json:"id"`
+	ShortID     string `json:"short_id,omitempty"`
+	Type        string `json:"type"`
+	ParentID    string `json:"parent_id,omitempty"`
+	AgentRoleID string `json:"agent_role_id,omitempty"`
+	Status      string `json:"status"`
+	Title       string `json:"title"`
+	Body        string `json:"body,omitempty"`
+	// Set when the body uses the structured format.
+	StructuredBody   *work.StructuredBody `json:"structured_body,omitempty"`
+	EstimateMinutes  int                  `json:"estimate_minutes,omitempty"`
+	TimeSpentMinutes int                  `json:"time_spent_minutes,omitempty"`
+	DueAt            time.Time            `json:"due_at,omitzero"`
+	Rollup           *work.Effort         `json:"rollup,omitempty"`
+	Progress         *work.Progress       `json:"progress,omitempty"`
+	ModifiedFiles    []string             `json:"modified_files,omitempty"`
+	References       []workLink           `json:"references,omitempty"`
+	ReferencedBy     []workLink           `json:"referenced_by,omitempty"`
+	// Read with attachment_read.
+	Attachments []work.Attachment `json:"attachments,omitempty"`
+}
+
 func (e *Executor) workGet(args json.RawMessage) (string, error) {
 	var params struct {
 		ID string `json:"id"`
@@ -274,31 +644,195 @@ func (e *Executor) workGet(args json.RawMessage) (string, error) {
 	if !found {
 		return "", userErrorf("work %s not found", params.ID)
 	}
+	works, err := e.store.List()
+	if err != nil {
+		return "", err
+	}
+	detail, err := e.workDetail(w, works)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(detail)
+	if err != nil {
+		return "", fmt.Errorf("marshal work item: %w", err)
+	}
+	return string(b), nil
+}
+
+// workDetail builds w's work_get view; works is a List() snapshot for the
+// rollup and links.
+func (e *Executor) workDetail(w work.Work, works []work.Work) (workDetail, error) {
+	detail := workDetail{
+		ID:               w.ID,
+		ShortID:          w.ShortID,
+		Type:             string(w.Type),
+		ParentID:         w.ParentID,
+		AgentRoleID:      w.AgentRoleID,
+		Status:           string(w.Status),
+		Title:            w.Title,
+		Body:             w.Body,
+		EstimateMinutes:  w.EstimateMinutes,
+		TimeSpentMinutes: w.TimeSpentMinutes,
+		DueAt:            w.DueAt,
+		Progress:         w.Progress,
+		ModifiedFiles:    w.ModifiedFiles,
+	}
+	// A body stored before it was validated may not parse; it is still
+	// returned as text.
+	if sb, ok, err := work.ParseBody(w.Body); ok && err == nil {
+		detail.StructuredBody = &sb
+	}
+	// Only items with children get a rollup; for a leaf it equals its own fields.
+	if report, ok := work.BuildEffortReport(works, w.ID); ok && len(report.Children) > 0 {
+		detail.Rollup = &report.Total
+	}
+	detail.References = workLinks(works, w.References)
+	detail.ReferencedBy = workLinks(works, w.ReferencedBy)
+	if e.attachments != nil {
+		var err error
+		if detail.Attachments, err = e.attachments.List(w.ID); err != nil {
+			return workDetail{}, err
+		}
+	}
+	return detail, nil
+}
+
+// workContext bundles what an agent reads before starting on an item —
+// the item, its parent, the parent's other children, its own children and
+// its role — so kickoff takes one call instead of four.
+func (e *Executor) workContext(args json.RawMessage) (string, error) {
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	w, found, err := e.store.Get(params.ID)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", userErrorf("work %s not found", params.ID)
+	}
+	works, err := e.store.List()
+	if err != nil {
+		return "", err
+	}
 
-	type workDetail struct {
+	type parentSummary struct {
+		ID      string `json:"id"`
+		ShortID string `json:"short_id,omitempty"`
+		Type    string `json:"type"`
+		Status  string `json:"status"`
+		Title   string `json:"title"`
+		Body    string `json:"body,omitempty"`
+	}
+	type workSummary struct {
 		ID          string `json:"id"`
-		Type        string `json:"type"`
-		ParentID    string `json:"parent_id,omitempty"`
+		ShortID     string `json:"short_id,omitempty"`
 		AgentRoleID string `json:"agent_role_id,omitempty"`
 		Status      string `json:"status"`
 		Title       string `json:"title"`
-		Body        string `json:"body,omitempty"`
-	}
-	b, err := json.Marshal(workDetail{
-		ID:          w.ID,
-		Type:        string(w.Type),
-		ParentID:    w.ParentID,
-		AgentRoleID: w.AgentRoleID,
-		Status:      string(w.Status),
-		Title:       w.Title,
-		Body:        w.Body,
-	})
+	}
+	type roleContext struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name"`
+		RolePrompt  string   `json:"role_prompt"`
+		Steps       []string `json:"steps,omitempty"`
+		CurrentStep int      `json:"current_step"`
+	}
+	type workContext struct {
+		Work     workDetail     `json:"work"`
+		Parent   *parentSummary `json:"parent,omitempty"`
+		Siblings []workSummary  `json:"siblings,omitempty"`
+		Children []workSummary  `json:"children,omitempty"`
+		Role     *roleContext   `json:"role,omitempty"`
+	}
+
+	result := workContext{}
+	if result.Work, err = e.workDetail(w, works); err != nil {
+		return "", err
+	}
+	summarize := func(c work.Work) workSummary {
+		return workSummary{ID: c.ID, ShortID: c.ShortID, AgentRoleID: c.AgentRoleID, Status: string(c.Status), Title: c.Title}
+	}
+	for _, other := range works {
+		switch {
+		case other.ID == w.ParentID:
+			result.Parent = &parentSummary{
+				ID:      other.ID,
+				ShortID: other.ShortID,
+				Type:    string(other.Type),
+				Status:  string(other.Status),
+				Title:   other.Title,
+				Body:    other.Body,
+			}
+		case w.ParentID != "" && other.ParentID == w.ParentID && other.ID != w.ID:
+			result.Siblings = append(result.Siblings, summarize(other))
+		case other.ParentID == w.ID:
+			result.Children = append(result.Children, summarize(other))
+		}
+	}
+
+	// The role driving the session, as agent_role_get would return it. A
+	// deleted role is left out rather than failing the whole call.
+	if roleID := w.EffectiveAgentRoleID(); roleID != "" && e.agentRoleStore != nil {
+		role, found, err := e.agentRoleStore.Get(roleID)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			result.Role = &roleContext{
+				ID:         role.ID,
+				Name:       role.Name,
+				RolePrompt: role.RolePrompt,
+				Steps:      role.Steps,
+			}
+			if len(role.Steps) > 0 {
+				result.Role.CurrentStep = w.CurrentStep
+			}
+		}
+	}
+
+	b, err := json.Marshal(result)
 	if err != nil {
-		return "", fmt.Errorf("marshal work item: %w", err)
+		return "", fmt.Errorf("marshal work context: %w", err)
 	}
 	return string(b), nil
 }
 
+// resolveShortIDs lets every tool take a short ID ("PCK-12") wherever it
+// takes a work ID.
+func (e *Executor) resolveShortIDs(args json.RawMessage) (json.RawMessage, error) {
+	works, err := e.store.List()
+	if err != nil {
+		return nil, err
+	}
+	return work.ResolveShortIDs(args, works)
+}
+
+// workLink names a related work item so the agent needn't fetch each one.
+type workLink struct {
+	ID      string `json:"id"`
+	ShortID string `json:"short_id,omitempty"`
+	Title   string `json:"title"`
+	Status  string `json:"status"`
+}
+
+func workLinks(works []work.Work, ids []string) []workLink {
+	var links []workLink
+	for _, id := range ids {
+		for _, w := range works {
+			if w.ID == id {
+				links = append(links, workLink{ID: w.ID, ShortID: w.ShortID, Title: w.Title, Status: string(w.Status)})
+				break
+			}
+		}
+	}
+	return links
+}
+
 func (e *Executor) workDelete(ctx context.Context, args json.RawMessage) (string, error) {
 	var params struct {
 		ID string `json:"id"`
@@ -316,13 +850,48 @@ func (e *Executor) workDelete(ctx context.Context, args json.RawMessage) (string
 
 func (e *Executor) workStart(ctx context.Context, args json.RawMessage) (string, error) {
 	var params struct {
-		ID string `json:"id"`
+		ID          string       `json:"id"`
+		AgentRoleID string       `json:"agent_role_id"`
+		Mode        session.Mode `json:"mode"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
 		return "", userErrorf("invalid arguments: %w", err)
 	}
 
-	w, err := e.ops.StartWork(ctx, params.ID)
+	if params.AgentRoleID != "" {
+		_, found, err := e.agentRoleStore.Get(params.AgentRoleID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get agent role: %w", err)
+		}
+		if !found {
+			return "", userErrorf("agent role %s not found", params.AgentRoleID)
+		}
+	}
+	if e.autorunGate != nil {
+		if err := e.autorunGate.CheckStart(); err != nil {
+			return "", userErrorf("cannot start work: %w", err)
+		}
+	}
+	if e.autorunSlots != nil {
+		roleID := params.AgentRoleID
+		if roleID == "" {
+			w, found, err := e.store.Get(params.ID)
+			if err != nil {
+				return "", fmt.Errorf("failed to get work: %w", err)
+			}
+			if found {
+				roleID = w.EffectiveAgentRoleID()
+			}
+		}
+		if err := e.autorunSlots.CheckStart(roleID); err != nil {
+			return "", userErrorf("cannot start work: %w", err)
+		}
+	}
+
+	w, err := e.ops.StartWork(ctx, params.ID, work.StartOptions{
+		AgentRoleID: params.AgentRoleID,
+		Mode:        params.Mode,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -346,6 +915,22 @@ func (e *Executor) workNeedsInput(ctx context.Context, args json.RawMessage) (st
 	return fmt.Sprintf("Work %s is now waiting for user input: %s", params.ID, params.Reason), nil
 }
 
+func (e *Executor) workPlanSubmit(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		ID   string `json:"id"`
+		Plan string `json:"plan"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	if _, err := e.store.SubmitPlan(ctx, params.ID, params.Plan); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Plan submitted for work %s. Stop here and wait for the user's decision.", params.ID), nil
+}
+
 func (e *Executor) workReopen(ctx context.Context, args json.RawMessage) (string, error) {
 	var params struct {
 		ID string `json:"id"`
@@ -361,6 +946,68 @@ func (e *Executor) workReopen(ctx context.Context, args json.RawMessage) (string
 	return fmt.Sprintf("Reopened work %s", params.ID), nil
 }
 
+func (e *Executor) workCancel(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	if _, err := e.ops.CancelWork(ctx, params.ID, params.Reason); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Cancelled work %s", params.ID), nil
+}
+
+func (e *Executor) workBulk(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Operations []work.BulkOp `json:"operations"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	for _, id := range work.BulkAgentRoleIDs(params.Operations) {
+		if _, found, err := e.agentRoleStore.Get(id); err != nil {
+			return "", fmt.Errorf("failed to validate agent role: %w", err)
+		} else if !found {
+			return "", userErrorf("agent role %q not found", id)
+		}
+	}
+
+	results, err := e.ops.Bulk(ctx, params.Operations)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(struct {
+		Results []work.BulkResult `json:"results"`
+	}{results})
+	if err != nil {
+		return "", fmt.Errorf("marshal bulk results: %w", err)
+	}
+	return string(b), nil
+}
+
+func (e *Executor) workLogTime(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		ID      string `json:"id"`
+		Minutes int    `json:"minutes"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	w, err := e.store.LogTime(ctx, params.ID, params.Minutes)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Logged %d minutes on work %s (total %d)", params.Minutes, params.ID, w.TimeSpentMinutes), nil
+}
+
 func (e *Executor) workWait(ctx context.Context, args json.RawMessage) (string, error) {
 	var params struct {
 		ID string `json:"id"`
@@ -394,17 +1041,28 @@ func (e *Executor) stepDone(ctx context.Context, args json.RawMessage) (string,
 	}
 
 	// Get step count from agent role
-	role, found, err := e.agentRoleStore.Get(w.AgentRoleID)
+	roleID := w.EffectiveAgentRoleID()
+	role, found, err := e.agentRoleStore.Get(roleID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get agent role: %w", err)
 	}
 	if !found {
-		return "", userErrorf("agent role %s not found", w.AgentRoleID)
+		return "", userErrorf("agent role %s not found", roleID)
 	}
 
 	totalSteps := len(role.Steps)
 
 	hasMoreSteps, err := e.store.StepDone(ctx, params.ID, totalSteps)
+	if errors.Is(err, work.ErrReviewRequired) {
+		return e.requestReview(ctx, w)
+	}
+	if errors.Is(err, work.ErrCloseApprovalRequired) {
+		// The user approves the close with work.approve_close.
+		if err := e.store.MarkNeedsInput(ctx, params.ID); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Work %s is finished but needs the user's approval to close. It is now waiting for user input; stop here.", params.ID), nil
+	}
 	if err != nil {
 		return "", err
 	}
@@ -425,6 +1083,33 @@ func (e *Executor) stepDone(ctx context.Context, args json.RawMessage) (string,
 	return fmt.Sprintf("Step %d (final step) completed for work %s. Work is now closed.", w.CurrentStep+1, params.ID), nil
 }
 
+// requestReview starts the review a story's final step_done asked for.
+func (e *Executor) requestReview(ctx context.Context, story work.Work) (string, error) {
+	var roleID string
+	if e.reviewRole != nil {
+		roleID = e.reviewRole()
+	}
+	if e.ops == nil || roleID == "" {
+		return "", userErrorf("work %s needs a review before it can close, but no review can be started here", story.ID)
+	}
+	if e.autorunGate != nil {
+		if err := e.autorunGate.CheckStart(); err != nil {
+			return "", userErrorf("work %s needs a review before it can close, which cannot start now: %w", story.ID, err)
+		}
+	}
+	if e.autorunSlots != nil {
+		if err := e.autorunSlots.CheckStart(roleID); err != nil {
+			return "", userErrorf("work %s needs a review before it can close, which cannot start now: %w", story.ID, err)
+		}
+	}
+
+	review, err := e.ops.RequestReview(ctx, story.ID, roleID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Work %s needs a review before it can close. Started review task %s under agent role %s; work %s is now waiting and will be resumed when the review is finished.", story.ID, review.ID, roleID, story.ID), nil
+}
+
 func (e *Executor) workCommentAdd(ctx context.Context, args json.RawMessage) (string, error) {
 	var params struct {
 		WorkID string `json:"work_id"`
@@ -477,6 +1162,165 @@ func (e *Executor) workCommentList(args json.RawMessage) (string, error) {
 	return string(b), nil
 }
 
+const (
+	defaultGitDiffBytes = 64 << 10
+	maxGitDiffBytes     = 256 << 10
+)
+
+// sessionWorkDir returns the worktree directory of the calling session.
+func (e *Executor) sessionWorkDir(ctx context.Context) (string, error) {
+	if e.workDirs == nil {
+		return "", userErrorf("git tools are not available")
+	}
+	sessionID := SessionIDFromContext(ctx)
+	if sessionID == "" {
+		return "", userErrorf("git tools need an agent session")
+	}
+	dir, ok := e.workDirs.WorkDirForSession(sessionID)
+	if !ok {
+		return "", userErrorf("no running worktree for session %s", sessionID)
+	}
+	return dir, nil
+}
+
+func (e *Executor) gitStatus(ctx context.Context) (string, error) {
+	dir, err := e.sessionWorkDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	status, err := git.Status(dir)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(status)
+	if err != nil {
+		return "", fmt.Errorf("marshal git status: %w", err)
+	}
+	return string(b), nil
+}
+
+func (e *Executor) gitDiff(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Path           string `json:"path"`
+		Staged         bool   `json:"staged"`
+		HideWhitespace bool   `json:"hide_whitespace"`
+		MaxBytes       int    `json:"max_bytes"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+	if params.MaxBytes == 0 {
+		params.MaxBytes = defaultGitDiffBytes
+	}
+	if params.MaxBytes < 1 || params.MaxBytes > maxGitDiffBytes {
+		return "", userErrorf("max_bytes must be between 1 and %d", maxGitDiffBytes)
+	}
+	dir, err := e.sessionWorkDir(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := contents.ValidatePath(dir, params.Path); err != nil {
+		return "", userErrorf("invalid path: %s", params.Path)
+	}
+
+	opts := git.DiffOptions{Staged: params.Staged, HideWhitespace: params.HideWhitespace}
+	var diff string
+	if params.Path != "" {
+		diff, err = git.Diff(dir, params.Path, opts)
+	} else {
+		diff, err = git.DiffAll(dir, opts)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Hunks are only parsed from a complete diff; a cut one would end mid-hunk.
+	result := struct {
+		Diff      string         `json:"diff"`
+		Hunks     []git.DiffHunk `json:"hunks,omitempty"`
+		Binary    bool           `json:"binary,omitempty"`
+		Size      int            `json:"size"`
+		Truncated bool           `json:"truncated,omitempty"`
+	}{Diff: diff, Size: len(diff)}
+	if len(diff) > params.MaxBytes {
+		cut := diff[:params.MaxBytes]
+		if i := strings.LastIndexByte(cut, '\n'); i >= 0 {
+			cut = cut[:i+1]
+		}
+		result.Diff = cut
+		result.Truncated = true
+	} else if params.Path != "" {
+		result.Hunks, result.Binary = git.ParseHunks(diff)
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("marshal git diff: %w", err)
+	}
+	return string(b), nil
+}
+
+// maxAttachmentText bounds the text attachment_read returns inline; a
+// larger file is handed over by path like a binary one.
+const maxAttachmentText = 256 << 10
+
+func (e *Executor) attachmentRead(args json.RawMessage) (string, error) {
+	if e.attachments == nil {
+		return "", userErrorf("attachments are not available")
+	}
+	var params struct {
+		WorkID string `json:"work_id"`
+		Name   string `json:"name"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+	w, found, err := e.store.Get(params.WorkID)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", userErrorf("work %s not found", params.WorkID)
+	}
+
+	if params.Name == "" {
+		list, err := e.attachments.List(w.ID)
+		if err != nil {
+			return "", err
+		}
+		b, err := json.Marshal(list)
+		if err != nil {
+			return "", fmt.Errorf("marshal attachment list: %w", err)
+		}
+		return string(b), nil
+	}
+
+	a, data, err := e.attachments.Read(w.ID, params.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(data) <= maxAttachmentText && isText(data) {
+		return string(data), nil
+	}
+	path, err := e.attachments.Path(w.ID, a.Name)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(struct {
+		work.Attachment
+		Path string `json:"path"`
+		Note string `json:"note"`
+	}{a, path, "Not returned inline. Open path with your file reading tool; it can view images."})
+	if err != nil {
+		return "", fmt.Errorf("marshal attachment: %w", err)
+	}
+	return string(b), nil
+}
+
+// isText reports whether data looks like text rather than a binary file.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+}
+
 func (e *Executor) workCommentUpdate(ctx context.Context, args json.RawMessage) (string, error) {
 	var params struct {
 		ID   string `json:"id"`
@@ -515,15 +1359,22 @@ func (e *Executor) agentRoleList() (string, error) {
 		return "", err
 	}
 
+	var defaultID string
+	if e.settingsStore != nil {
+		defaultID = e.settingsStore.Get().DefaultAgentRoleID
+	}
+
 	type roleItem struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		Default bool   `json:"default,omitempty"`
 	}
 	items := make([]roleItem, len(roles))
 	for i, r := range roles {
 		items[i] = roleItem{
-			ID:   r.ID,
-			Name: r.Name,
+			ID:      r.ID,
+			Name:    r.Name,
+			Default: r.ID == defaultID,
 		}
 	}
 	b, err := json.Marshal(items)
@@ -550,14 +1401,16 @@ func (e *Executor) agentRoleGet(args json.RawMessage) (string, error) {
 	}
 
 	type roleDetail struct {
-		ID         string `json:"id"`
-		Name       string `json:"name"`
-		RolePrompt string `json:"role_prompt"`
+		ID         string            `json:"id"`
+		Name       string            `json:"name"`
+		RolePrompt string            `json:"role_prompt"`
+		AgentType  session.AgentType `json:"agent_type,omitempty"`
 	}
 	b, err := json.Marshal(roleDetail{
 		ID:         role.ID,
 		Name:       role.Name,
 		RolePrompt: role.RolePrompt,
+		AgentType:  role.AgentType,
 	})
 	if err != nil {
 		return "", fmt.Errorf("marshal agent role: %w", err)
@@ -565,6 +1418,133 @@ func (e *Executor) agentRoleGet(args json.RawMessage) (string, error) {
 	return string(b), nil
 }
 
+// agentRoleCreate and agentRoleUpdate leave out mcp_servers: those start
+// arbitrary commands in later sessions, so only the user may configure them.
+func (e *Executor) agentRoleCreate(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Name               string            `json:"name"`
+		RolePrompt         string            `json:"role_prompt"`
+		Steps              []string          `json:"steps"`
+		IdleTimeoutMinutes int               `json:"idle_timeout_minutes"`
+		AgentType          session.AgentType `json:"agent_type"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	role, err := e.agentRoleStore.Create(ctx, agentrole.AgentRole{
+		Name:               params.Name,
+		RolePrompt:         params.RolePrompt,
+		Steps:              params.Steps,
+		IdleTimeoutMinutes: params.IdleTimeoutMinutes,
+		AgentType:          params.AgentType,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Created agent role %q (ID: %s)", role.Name, role.ID), nil
+}
+
+func (e *Executor) agentRoleUpdate(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		ID                 string             `json:"id"`
+		Name               *string            `json:"name"`
+		RolePrompt         *string            `json:"role_prompt"`
+		Steps              *[]string          `json:"steps"`
+		IdleTimeoutMinutes *int               `json:"idle_timeout_minutes"`
+		AgentType          *session.AgentType `json:"agent_type"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	fields := agentrole.UpdateFields{
+		Name:               params.Name,
+		RolePrompt:         params.RolePrompt,
+		Steps:              params.Steps,
+		IdleTimeoutMinutes: params.IdleTimeoutMinutes,
+		AgentType:          params.AgentType,
+	}
+	if err := e.agentRoleStore.Update(ctx, params.ID, fields); err != nil {
+		return "", err
+	}
+
+	var parts []string
+	if params.Name != nil {
+		parts = append(parts, fmt.Sprintf("name to %q", *params.Name))
+	}
+	if params.RolePrompt != nil {
+		parts = append(parts, "role_prompt")
+	}
+	if params.Steps != nil {
+		parts = append(parts, "steps")
+	}
+	if params.IdleTimeoutMinutes != nil {
+		parts = append(parts, "idle_timeout_minutes")
+	}
+	if params.AgentType != nil {
+		parts = append(parts, "agent_type")
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("Updated agent role %s (no fields changed)", params.ID), nil
+	}
+	return fmt.Sprintf("Updated agent role %s %s", params.ID, strings.Join(parts, " and ")), nil
+}
+
+func (e *Executor) agentRoleDelete(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	if _, found, err := e.agentRoleStore.Get(params.ID); err != nil {
+		return "", err
+	} else if !found {
+		return "", userErrorf("agent role %q not found", params.ID)
+	}
+
+	// Same rule as the WebSocket handler: any referencing work item blocks
+	// the delete, so open work never loses the role it would run under and
+	// reopened work can still start.
+	works, err := e.store.List()
+	if err != nil {
+		return "", fmt.Errorf("failed to check role references: %w", err)
+	}
+	var refs, open int
+	for _, w := range works {
+		if w.AgentRoleID != params.ID {
+			continue
+		}
+		refs++
+		if w.Status != work.StatusClosed && w.Status != work.StatusCancelled {
+			open++
+		}
+	}
+	if refs > 0 {
+		return "", userErrorf("cannot delete: role is referenced by %d work item(s), %d of them not closed; reassign them with work_update first", refs, open)
+	}
+
+	if err := e.agentRoleStore.Delete(ctx, params.ID); err != nil {
+		return "", err
+	}
+
+	// Clear the default agent role if it pointed here, like the WebSocket
+	// handler.
+	if e.settingsStore != nil {
+		if s := e.settingsStore.Get(); s.DefaultAgentRoleID == params.ID {
+			s.DefaultAgentRoleID = ""
+			if err := e.settingsStore.Update(s); err != nil {
+				slog.Error("failed to clear default agent role after deletion", "error", err)
+			}
+		}
+	}
+
+	return fmt.Sprintf("Deleted agent role %s", params.ID), nil
+}
+
 func (e *Executor) agentRoleResetDefaults(ctx context.Context) (string, error) {
 	pmRoleID, err := e.agentRoleStore.ResetDefaults(ctx)
 	if err != nil {
@@ -584,3 +1564,46 @@ func (e *Executor) agentRoleResetDefaults(ctx context.Context) (string, error) {
 
 	return "Agent roles reset to defaults", nil
 }
+
+func (e *Executor) testReport(ctx context.Context, args json.RawMessage) (string, error) {
+	if e.testRunStore == nil {
+		return "", errors.New("test reporting is not available")
+	}
+
+	var params struct {
+		WorkID   string            `json:"work_id"`
+		Suite    string            `json:"suite"`
+		Passed   int               `json:"passed"`
+		Failed   int               `json:"failed"`
+		Skipped  int               `json:"skipped"`
+		Failures []testrun.Failure `json:"failures"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", userErrorf("invalid arguments: %w", err)
+	}
+
+	// The run is attributed to the work's current session, which is the
+	// reporting agent's own session when a work agent calls this.
+	w, found, err := e.store.Get(params.WorkID)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", userErrorf("work %s not found", params.WorkID)
+	}
+
+	run, err := e.testRunStore.Report(ctx, testrun.Run{
+		WorkID:    w.ID,
+		SessionID: w.SessionID,
+		Suite:     params.Suite,
+		Passed:    params.Passed,
+		Failed:    params.Failed,
+		Skipped:   params.Skipped,
+		Failures:  params.Failures,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Recorded %s run of %q for work %s (%d passed, %d failed)", run.Status, run.Suite, run.WorkID, run.Passed, run.Failed), nil
+}
@@ -5,11 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pockode/server/agentrole"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/testrun"
 	"github.com/pockode/server/work"
 )
 
@@ -18,14 +24,18 @@ import (
 // kickoff side effects belong to integration tests in the worktree package.
 type stubWorkStarter struct{}
 
-func (stubWorkStarter) HandleWorkStart(context.Context, work.Work) error { return nil }
+func (stubWorkStarter) HandleWorkStart(context.Context, work.Work, work.StartOptions) error {
+	return nil
+}
 
 var errStartFailed = errors.New("start handler failed")
 
 // failingWorkStarter always fails, to exercise the rollback path in work_start.
 type failingWorkStarter struct{ err error }
 
-func (f failingWorkStarter) HandleWorkStart(context.Context, work.Work) error { return f.err }
+func (f failingWorkStarter) HandleWorkStart(context.Context, work.Work, work.StartOptions) error {
+	return f.err
+}
 
 // stubNotifier satisfies WorkNotifier as a no-op.
 type stubNotifier struct{}
@@ -111,6 +121,75 @@ func callTool(t *testing.T, e *Executor, name string, args interface{}) result {
 
 func toolText(r result) string { return r.Text }
 
+// --- Tool: work_search ---
+
+func TestWorkSearch(t *testing.T) {
+	ts := newTestExec(t)
+
+	callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Login page", "body": "Email sign-in", "agent_role_id": ts.roleID,
+	})
+	callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Billing export", "agent_role_id": ts.roleID,
+	})
+
+	result := callTool(t, ts.exec, "work_search", map[string]string{"query": "email"})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.Text)
+	}
+	var out struct {
+		Hits []struct {
+			Title   string `json:"title"`
+			Snippet string `json:"snippet"`
+		} `json:"hits"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(result.Text), &out); err != nil {
+		t.Fatalf("unmarshal %q: %v", result.Text, err)
+	}
+	if out.Total != 1 || out.Hits[0].Title != "Login page" || out.Hits[0].Snippet != "**Email** sign-in" {
+		t.Errorf("unexpected result %s", result.Text)
+	}
+
+	if r := callTool(t, ts.exec, "work_search", map[string]string{"query": ""}); !r.IsError {
+		t.Error("expected error for empty query")
+	}
+}
+
+// --- Tool: work_next ---
+
+func TestWorkNext(t *testing.T) {
+	ts := newTestExec(t)
+	ctx := context.Background()
+	first, err := ts.store.Create(ctx, work.Work{Type: work.WorkTypeStory, Title: "Schema", AgentRoleID: ts.roleID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ts.store.Create(ctx, work.Work{Type: work.WorkTypeStory, Title: "API", AgentRoleID: ts.roleID,
+		Body: "---\ndepends_on: " + first.ShortID + "\n---\n", DueAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Work *struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"work"`
+	}
+	result := callTool(t, ts.exec, "work_next", map[string]string{"agent_role_id": ts.roleID})
+	if err := json.Unmarshal([]byte(result.Text), &out); err != nil {
+		t.Fatalf("unmarshal %q: %v", result.Text, err)
+	}
+	if out.Work == nil || out.Work.ID != first.ID {
+		t.Errorf("expected the unblocked story, got %s", result.Text)
+	}
+
+	result = callTool(t, ts.exec, "work_next", map[string]string{"agent_role_id": "other"})
+	if result.IsError || result.Text != `{"work":null}` {
+		t.Errorf("expected no work for another role, got %s", result.Text)
+	}
+}
+
 // --- Tool: work_create ---
 
 func TestWorkCreate(t *testing.T) {
@@ -147,6 +226,63 @@ func TestWorkCreate_InvalidType(t *testing.T) {
 	}
 }
 
+// --- Tool: work_split ---
+
+func TestWorkSplit(t *testing.T) {
+	ts := newTestExec(t)
+	story, err := ts.store.Create(context.Background(), work.Work{Type: work.WorkTypeStory, Title: "Login", AgentRoleID: ts.roleID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := callTool(t, ts.exec, "work_split", map[string]any{
+		"story_id": story.ShortID,
+		"tasks": []map[string]string{
+			{"title": "Form", "agent_role_id": ts.roleID},
+			{"title": "Session", "agent_role_id": ts.roleID},
+		},
+	})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", toolText(result))
+	}
+	var out struct {
+		Created []workLink `json:"created"`
+	}
+	if err := json.Unmarshal([]byte(toolText(result)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Created) != 2 || out.Created[0].Title != "Form" || out.Created[1].Title != "Session" {
+		t.Fatalf("created = %+v, want Form and Session", out.Created)
+	}
+	for _, c := range out.Created {
+		if w, _, _ := ts.store.Get(c.ID); w.ParentID != story.ID || w.Type != work.WorkTypeTask {
+			t.Errorf("%s: parent %q type %q, want a task under the story", c.Title, w.ParentID, w.Type)
+		}
+	}
+}
+
+func TestWorkSplit_AllOrNothing(t *testing.T) {
+	ts := newTestExec(t)
+	story, err := ts.store.Create(context.Background(), work.Work{Type: work.WorkTypeStory, Title: "Login", AgentRoleID: ts.roleID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := callTool(t, ts.exec, "work_split", map[string]any{
+		"story_id": story.ID,
+		"tasks": []map[string]string{
+			{"title": "Form", "agent_role_id": ts.roleID},
+			{"title": "Late", "agent_role_id": ts.roleID, "due_at": "tomorrow"},
+		},
+	})
+	if !result.IsError || !strings.Contains(toolText(result), "task 2") {
+		t.Fatalf("result = %q, want an error naming task 2", toolText(result))
+	}
+	if works, _ := ts.store.List(); len(works) != 1 {
+		t.Errorf("works = %d, want only the story", len(works))
+	}
+}
+
 // --- Tool: work_list ---
 
 func TestWorkList_Empty(t *testing.T) {
@@ -154,8 +290,8 @@ func TestWorkList_Empty(t *testing.T) {
 	result := callTool(t, ts.exec, "work_list", map[string]string{})
 
 	text := toolText(result)
-	if text != "[]" {
-		t.Errorf("expected empty JSON array, got %q", text)
+	if text != `{"items":[],"total":0}` {
+		t.Errorf("expected empty page, got %q", text)
 	}
 }
 
@@ -204,6 +340,48 @@ func TestWorkList_FilterByParentID(t *testing.T) {
 	}
 }
 
+func TestWorkList_FilterAndPaginate(t *testing.T) {
+	ts := newTestExec(t)
+
+	for _, title := range []string{"Story A", "Story B", "Story C"} {
+		callTool(t, ts.exec, "work_create", map[string]string{
+			"type": "story", "title": title, "agent_role_id": ts.roleID,
+		})
+	}
+
+	result := callTool(t, ts.exec, "work_list", map[string]any{
+		"status": []string{"open"}, "sort": "created_at", "order": "desc", "limit": 2,
+	})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", toolText(result))
+	}
+
+	var page struct {
+		Items []struct {
+			Title string `json:"title"`
+		} `json:"items"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(toolText(result)), &page); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if page.Total != 3 {
+		t.Errorf("expected total 3, got %d", page.Total)
+	}
+	if len(page.Items) != 2 || page.Items[0].Title != "Story C" || page.Items[1].Title != "Story B" {
+		t.Errorf("expected [Story C, Story B], got %+v", page.Items)
+	}
+}
+
+func TestWorkList_InvalidStatus(t *testing.T) {
+	ts := newTestExec(t)
+
+	result := callTool(t, ts.exec, "work_list", map[string]any{"status": []string{"done"}})
+	if !result.IsError {
+		t.Error("expected error for invalid status")
+	}
+}
+
 // --- Tool: work_update ---
 
 func TestWorkUpdate(t *testing.T) {
@@ -226,6 +404,32 @@ func TestWorkUpdate(t *testing.T) {
 	}
 }
 
+func TestWorkUpdate_DueAt(t *testing.T) {
+	ts := newTestExec(t)
+
+	createResult := callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Deadline", "agent_role_id": ts.roleID, "due_at": "2026-03-01T17:00:00Z",
+	})
+	id := extractID(t, toolText(createResult))
+
+	result := callTool(t, ts.exec, "work_get", map[string]string{"id": id})
+	if !strings.Contains(toolText(result), `"due_at":"2026-03-01T17:00:00Z"`) {
+		t.Errorf("work_get = %s, want due_at", toolText(result))
+	}
+
+	result = callTool(t, ts.exec, "work_update", map[string]string{"id": id, "due_at": "soon"})
+	if !result.IsError {
+		t.Error("expected error for a malformed due_at")
+	}
+	result = callTool(t, ts.exec, "work_update", map[string]string{"id": id, "due_at": ""})
+	if result.IsError {
+		t.Fatalf("clear due_at: %s", toolText(result))
+	}
+	if w, _, _ := ts.store.Get(id); !w.DueAt.IsZero() {
+		t.Errorf("due_at = %v after clearing", w.DueAt)
+	}
+}
+
 func TestWorkUpdate_NotFound(t *testing.T) {
 	ts := newTestExec(t)
 	result := callTool(t, ts.exec, "work_update", map[string]string{
@@ -259,6 +463,70 @@ func TestWorkGet(t *testing.T) {
 	if !strings.Contains(text, "Details here") {
 		t.Errorf("result = %q, want to contain body", text)
 	}
+
+	if err := ts.store.AddModifiedFiles(context.Background(), id, []string{"main.go"}); err != nil {
+		t.Fatal(err)
+	}
+	text = toolText(callTool(t, ts.exec, "work_get", map[string]string{"id": id}))
+	if !strings.Contains(text, `"modified_files":["main.go"]`) {
+		t.Errorf("result = %q, want to contain modified files", text)
+	}
+}
+
+func TestWorkGet_StructuredBody(t *testing.T) {
+	ts := newTestExec(t)
+
+	body := "---\npriority: high\n---\n## Context\nWhy\n## Acceptance Criteria\n- [ ] it works\n- [x] it is documented\n"
+	id := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Structured", "body": body, "agent_role_id": ts.roleID,
+	})))
+
+	text := toolText(callTool(t, ts.exec, "work_get", map[string]string{"id": id}))
+	want := `"structured_body":{"metadata":{"priority":"high"},"context":"Why","acceptance_criteria":[{"text":"it works"},{"text":"it is documented","done":true}]}`
+	if !strings.Contains(text, want) {
+		t.Errorf("result = %q, want %s", text, want)
+	}
+
+	result := callTool(t, ts.exec, "work_update", map[string]string{"id": id, "body": "---\nno end"})
+	if !result.IsError {
+		t.Errorf("work_update with malformed body = %q, want error", toolText(result))
+	}
+}
+
+func TestWorkGet_References(t *testing.T) {
+	ts := newTestExec(t)
+
+	target := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Target", "agent_role_id": ts.roleID,
+	})))
+	source := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Source", "body": "Blocked by #" + target, "agent_role_id": ts.roleID,
+	})))
+
+	text := toolText(callTool(t, ts.exec, "work_get", map[string]string{"id": source}))
+	if !strings.Contains(text, `"references":[{"id":"`+target+`","short_id":"W-1","title":"Target","status":"open"}]`) {
+		t.Errorf("source = %q, want reference to target", text)
+	}
+	text = toolText(callTool(t, ts.exec, "work_get", map[string]string{"id": target}))
+	if !strings.Contains(text, `"referenced_by":[{"id":"`+source+`","short_id":"W-2","title":"Source","status":"open"}]`) {
+		t.Errorf("target = %q, want backlink from source", text)
+	}
+}
+
+func TestWorkGet_ByShortID(t *testing.T) {
+	ts := newTestExec(t)
+	text := toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Short", "agent_role_id": ts.roleID,
+	}))
+	id := extractID(t, text)
+	if !strings.Contains(text, "W-1") {
+		t.Errorf("create result = %q, want short ID", text)
+	}
+
+	result := callTool(t, ts.exec, "work_get", map[string]string{"id": "W-1"})
+	if result.IsError || !strings.Contains(toolText(result), id) {
+		t.Errorf("work_get W-1 = %q, want %s", toolText(result), id)
+	}
 }
 
 func TestWorkGet_NotFound(t *testing.T) {
@@ -270,6 +538,76 @@ func TestWorkGet_NotFound(t *testing.T) {
 	}
 }
 
+// --- Tool: work_context ---
+
+func TestWorkContext(t *testing.T) {
+	ts := newTestExec(t)
+
+	story := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "body": "Story details", "agent_role_id": ts.roleID,
+	})))
+	task := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "task", "title": "Task", "body": "Task details", "parent_id": story, "agent_role_id": ts.roleID,
+	})))
+	sibling := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "task", "title": "Sibling", "body": "Sibling details", "parent_id": story, "agent_role_id": ts.roleID,
+	})))
+
+	result := callTool(t, ts.exec, "work_context", map[string]string{"id": task})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", toolText(result))
+	}
+	var got struct {
+		Work struct {
+			ID   string `json:"id"`
+			Body string `json:"body"`
+		} `json:"work"`
+		Parent *struct {
+			ID   string `json:"id"`
+			Body string `json:"body"`
+		} `json:"parent"`
+		Siblings []struct {
+			ID   string `json:"id"`
+			Body string `json:"body"`
+		} `json:"siblings"`
+		Children []json.RawMessage `json:"children"`
+		Role     *struct {
+			ID         string `json:"id"`
+			RolePrompt string `json:"role_prompt"`
+		} `json:"role"`
+	}
+	if err := json.Unmarshal([]byte(toolText(result)), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Work.ID != task || got.Work.Body != "Task details" {
+		t.Errorf("work = %+v, want the task with its body", got.Work)
+	}
+	if got.Parent == nil || got.Parent.ID != story || got.Parent.Body != "Story details" {
+		t.Errorf("parent = %+v, want the story with its body", got.Parent)
+	}
+	if len(got.Siblings) != 1 || got.Siblings[0].ID != sibling || got.Siblings[0].Body != "" {
+		t.Errorf("siblings = %+v, want only the sibling, without its body", got.Siblings)
+	}
+	if len(got.Children) != 0 {
+		t.Errorf("children = %s, want none for a task", got.Children)
+	}
+	if got.Role == nil || got.Role.ID != ts.roleID || got.Role.RolePrompt == "" {
+		t.Errorf("role = %+v, want the task's role with its prompt", got.Role)
+	}
+
+	text := toolText(callTool(t, ts.exec, "work_context", map[string]string{"id": story}))
+	if !strings.Contains(text, `"children":[`) || strings.Contains(text, `"parent"`) || strings.Contains(text, `"siblings"`) {
+		t.Errorf("story context = %q, want children and no parent or siblings", text)
+	}
+}
+
+func TestWorkContext_NotFound(t *testing.T) {
+	ts := newTestExec(t)
+	if result := callTool(t, ts.exec, "work_context", map[string]string{"id": "nonexistent"}); !result.IsError {
+		t.Error("expected error for nonexistent ID")
+	}
+}
+
 // --- Tool: work_delete ---
 
 func TestWorkDelete(t *testing.T) {
@@ -339,6 +677,53 @@ func TestWorkStart(t *testing.T) {
 	}
 }
 
+func TestWorkBulk(t *testing.T) {
+	ts := newTestExec(t)
+
+	ids := make([]string, 2)
+	for i, title := range []string{"One", "Two"} {
+		createResult := callTool(t, ts.exec, "work_create", map[string]string{
+			"type": "story", "title": title, "agent_role_id": ts.roleID,
+		})
+		ids[i] = extractID(t, toolText(createResult))
+	}
+
+	result := callTool(t, ts.exec, "work_bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"action": "update", "id": ids[0], "fields": map[string]interface{}{"title": "One renamed", "estimate_minutes": 30}},
+			{"action": "start", "id": ids[1]},
+			{"action": "cancel", "id": ids[0]},
+		},
+	})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", toolText(result))
+	}
+	var out struct {
+		Results []work.BulkResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(toolText(result)), &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(out.Results) != 3 || !out.Results[0].OK || !out.Results[1].OK || out.Results[2].OK {
+		t.Fatalf("results = %+v, want update and start ok, cancel without reason failed", out.Results)
+	}
+
+	w, _, _ := ts.store.Get(ids[0])
+	if w.Title != "One renamed" || w.EstimateMinutes != 30 || w.Status != work.StatusOpen {
+		t.Errorf("work = %q %d %s, want renamed with estimate and still open", w.Title, w.EstimateMinutes, w.Status)
+	}
+	if w, _, _ := ts.store.Get(ids[1]); w.Status != work.StatusInProgress {
+		t.Errorf("started status = %s, want in_progress", w.Status)
+	}
+
+	unknown := callTool(t, ts.exec, "work_bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{{"action": "start", "id": ids[0], "agent_role_id": "nope"}},
+	})
+	if !unknown.IsError {
+		t.Error("expected error for unknown agent role")
+	}
+}
+
 func TestWorkStart_NotFound(t *testing.T) {
 	ts := newTestExec(t)
 	result := callTool(t, ts.exec, "work_start", map[string]string{"id": "nonexistent"})
@@ -364,6 +749,31 @@ func TestWorkStart_AlreadyInProgress(t *testing.T) {
 	}
 }
 
+func TestWorkStart_AutorunPaused(t *testing.T) {
+	ts := newTestExec(t)
+	gate, err := work.NewAutorunGate(t.TempDir(), func() work.AutorunLimits {
+		return work.AutorunLimits{Start: time.Hour, End: time.Hour + time.Minute, Days: []time.Weekday{(time.Now().Weekday() + 3) % 7}}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.exec.SetAutorunGate(gate)
+
+	createResult := callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": ts.roleID,
+	})
+	id := extractID(t, toolText(createResult))
+
+	result := callTool(t, ts.exec, "work_start", map[string]string{"id": id})
+	if !result.IsError || !strings.Contains(toolText(result), "autorun paused") {
+		t.Errorf("result = %q, want autorun paused error", toolText(result))
+	}
+	w, _, _ := ts.store.Get(id)
+	if w.Status != work.StatusOpen {
+		t.Errorf("status = %q, want open", w.Status)
+	}
+}
+
 func TestWorkStart_NoAgentRole(t *testing.T) {
 	ts := newTestExec(t)
 
@@ -380,6 +790,108 @@ func TestWorkStart_NoAgentRole(t *testing.T) {
 	}
 }
 
+func TestWorkStart_RoleOverride(t *testing.T) {
+	store, arStore, settingsStore, roleID := newStoresWithRole(t, agentrole.AgentRole{Name: "Engineer", RolePrompt: "Build."})
+	planner, err := arStore.Create(context.Background(), agentrole.AgentRole{Name: "Planner", RolePrompt: "Plan.", Steps: []string{"outline"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := NewExecutor(store, arStore, work.NewOperations(store, stubWorkStarter{}, stubNotifier{}), stubNotifier{}, settingsStore)
+
+	id := extractID(t, toolText(callTool(t, exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": roleID,
+	})))
+
+	result := callTool(t, exec, "work_start", map[string]string{"id": id, "agent_role_id": planner.ID, "mode": "yolo"})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", toolText(result))
+	}
+
+	w, _, _ := store.Get(id)
+	if w.AgentRoleID != roleID {
+		t.Errorf("agent_role_id = %q, want work item role %q unchanged", w.AgentRoleID, roleID)
+	}
+	if w.SessionAgentRoleID != planner.ID {
+		t.Errorf("session_agent_role_id = %q, want %q", w.SessionAgentRoleID, planner.ID)
+	}
+}
+
+func TestWorkStart_UnknownRoleOverride(t *testing.T) {
+	ts := newTestExec(t)
+	id := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": ts.roleID,
+	})))
+
+	result := callTool(t, ts.exec, "work_start", map[string]string{"id": id, "agent_role_id": "missing"})
+	if !result.IsError || !strings.Contains(toolText(result), "not found") {
+		t.Errorf("result = %+v, want agent role not found error", result)
+	}
+	if w, _, _ := ts.store.Get(id); w.Status != work.StatusOpen {
+		t.Errorf("status = %q, want open", w.Status)
+	}
+}
+
+func TestWorkStart_InvalidMode(t *testing.T) {
+	ts := newTestExec(t)
+	id := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": ts.roleID,
+	})))
+
+	result := callTool(t, ts.exec, "work_start", map[string]string{"id": id, "mode": "bogus"})
+	if !result.IsError {
+		t.Error("expected error for invalid mode")
+	}
+}
+
+func TestWorkPlanSubmit(t *testing.T) {
+	ts := newTestExec(t)
+	id := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": ts.roleID,
+	})))
+
+	if r := callTool(t, ts.exec, "work_start", map[string]string{"id": id, "mode": "plan"}); r.IsError {
+		t.Fatalf("work_start: %s", toolText(r))
+	}
+	result := callTool(t, ts.exec, "work_plan_submit", map[string]string{"id": id, "plan": "1. refactor"})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", toolText(result))
+	}
+
+	w, _, _ := ts.store.Get(id)
+	if w.Status != work.StatusNeedsInput {
+		t.Errorf("status = %q, want needs_input", w.Status)
+	}
+	if w.Plan == nil || w.Plan.Status != work.PlanSubmitted || w.Plan.Body != "1. refactor" {
+		t.Errorf("plan = %+v, want submitted", w.Plan)
+	}
+}
+
+func TestWorkPlanSubmit_NotPlanMode(t *testing.T) {
+	ts := newTestExec(t)
+	id := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": ts.roleID,
+	})))
+	callTool(t, ts.exec, "work_start", map[string]string{"id": id})
+
+	result := callTool(t, ts.exec, "work_plan_submit", map[string]string{"id": id, "plan": "1. refactor"})
+	if !result.IsError {
+		t.Error("expected error for work not started in plan mode")
+	}
+}
+
+func TestStepDone_BlockedUntilPlanApproved(t *testing.T) {
+	ts := newTestExec(t)
+	id := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": ts.roleID,
+	})))
+	callTool(t, ts.exec, "work_start", map[string]string{"id": id, "mode": "plan"})
+
+	result := callTool(t, ts.exec, "step_done", map[string]string{"id": id})
+	if !result.IsError || !strings.Contains(toolText(result), "work_plan_submit") {
+		t.Errorf("result = %+v, want plan gate error", result)
+	}
+}
+
 // --- Tool: work_needs_input ---
 
 func TestWorkNeedsInput(t *testing.T) {
@@ -451,6 +963,24 @@ func TestAgentRoleList(t *testing.T) {
 	}
 }
 
+func TestAgentRoleList_MarksDefault(t *testing.T) {
+	store, arStore, settingsStore, roleID := newStoresWithRole(t, agentrole.AgentRole{Name: "Default"})
+	exec := NewExecutor(store, arStore, nil, nil, settingsStore)
+	if r := callTool(t, exec, "agent_role_list", map[string]string{}); strings.Contains(toolText(r), `"default"`) {
+		t.Errorf("list without a default = %q, want no default marked", toolText(r))
+	}
+
+	s := settingsStore.Get()
+	s.DefaultAgentRoleID = roleID
+	if err := settingsStore.Update(s); err != nil {
+		t.Fatal(err)
+	}
+	r := callTool(t, exec, "agent_role_list", map[string]string{})
+	if want := fmt.Sprintf(`"id":%q,"name":"Default","default":true`, roleID); !strings.Contains(toolText(r), want) {
+		t.Errorf("list = %q, want %s", toolText(r), want)
+	}
+}
+
 // --- Tool: agent_role_get ---
 
 func TestAgentRoleGet(t *testing.T) {
@@ -479,6 +1009,111 @@ func TestAgentRoleGet_NotFound(t *testing.T) {
 	}
 }
 
+// --- Tool: agent_role_create / agent_role_update / agent_role_delete ---
+
+func TestAgentRoleCreateUpdateDelete(t *testing.T) {
+	ts := newTestExec(t)
+
+	created := callTool(t, ts.exec, "agent_role_create", map[string]any{
+		"name": "DB Migrator", "role_prompt": "You write migrations.", "steps": []string{"plan", "migrate"},
+	})
+	if created.IsError {
+		t.Fatalf("create: %s", toolText(created))
+	}
+	id := extractID(t, toolText(created))
+
+	updated := callTool(t, ts.exec, "agent_role_update", map[string]any{
+		"id": id, "name": "Schema Migrator", "idle_timeout_minutes": 45,
+	})
+	if updated.IsError {
+		t.Fatalf("update: %s", toolText(updated))
+	}
+	if !strings.Contains(toolText(updated), `name to "Schema Migrator" and idle_timeout_minutes`) {
+		t.Errorf("update result = %q", toolText(updated))
+	}
+
+	var role struct {
+		Name       string `json:"name"`
+		RolePrompt string `json:"role_prompt"`
+	}
+	if err := json.Unmarshal([]byte(toolText(callTool(t, ts.exec, "agent_role_get", map[string]string{"id": id}))), &role); err != nil {
+		t.Fatal(err)
+	}
+	if role.Name != "Schema Migrator" || role.RolePrompt != "You write migrations." {
+		t.Errorf("role = %+v", role)
+	}
+
+	deleted := callTool(t, ts.exec, "agent_role_delete", map[string]string{"id": id})
+	if deleted.IsError {
+		t.Fatalf("delete: %s", toolText(deleted))
+	}
+	if r := callTool(t, ts.exec, "agent_role_get", map[string]string{"id": id}); !r.IsError {
+		t.Error("role should be gone after delete")
+	}
+}
+
+func TestAgentRoleCreate_Invalid(t *testing.T) {
+	ts := newTestExec(t)
+
+	for name, args := range map[string]map[string]any{
+		"no name":          {"role_prompt": "x"},
+		"negative timeout": {"name": "R", "idle_timeout_minutes": -1},
+	} {
+		if r := callTool(t, ts.exec, "agent_role_create", args); !r.IsError {
+			t.Errorf("%s: expected error, got %q", name, toolText(r))
+		}
+	}
+	if r := callTool(t, ts.exec, "agent_role_update", map[string]any{"id": "nonexistent", "name": "R"}); !r.IsError {
+		t.Error("update of nonexistent role should fail")
+	}
+}
+
+func TestAgentRoleDelete_RefusedWhileReferenced(t *testing.T) {
+	ts := newTestExec(t)
+
+	created := callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Uses role", "agent_role_id": ts.roleID,
+	})
+	workID := extractID(t, toolText(created))
+
+	r := callTool(t, ts.exec, "agent_role_delete", map[string]string{"id": ts.roleID})
+	if !r.IsError || !strings.Contains(toolText(r), "referenced by 1 work item(s), 1 of them not closed") {
+		t.Fatalf("delete = %q, want refusal", toolText(r))
+	}
+
+	if err := ts.store.Delete(context.Background(), workID); err != nil {
+		t.Fatal(err)
+	}
+	if r := callTool(t, ts.exec, "agent_role_delete", map[string]string{"id": ts.roleID}); r.IsError {
+		t.Errorf("delete after removing the work: %s", toolText(r))
+	}
+}
+
+func TestAgentRoleDelete_ClearsDefaultRole(t *testing.T) {
+	store, arStore, settingsStore, roleID := newStoresWithRole(t, agentrole.AgentRole{Name: "Default"})
+	exec := NewExecutor(store, arStore, nil, nil, settingsStore)
+	s := settingsStore.Get()
+	s.DefaultAgentRoleID = roleID
+	if err := settingsStore.Update(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := callTool(t, exec, "agent_role_delete", map[string]string{"id": roleID}); r.IsError {
+		t.Fatalf("delete: %s", toolText(r))
+	}
+	if got := settingsStore.Get().DefaultAgentRoleID; got != "" {
+		t.Errorf("DefaultAgentRoleID = %q, want cleared", got)
+	}
+}
+
+func TestAgentRoleDelete_NotFound(t *testing.T) {
+	ts := newTestExec(t)
+
+	if r := callTool(t, ts.exec, "agent_role_delete", map[string]string{"id": "nonexistent"}); !r.IsError {
+		t.Error("expected error for nonexistent ID")
+	}
+}
+
 // --- Tool: agent_role_reset_defaults ---
 
 func TestAgentRoleResetDefaults(t *testing.T) {
@@ -571,6 +1206,57 @@ func TestWorkCommentList_WithComments(t *testing.T) {
 	}
 }
 
+// --- Tool: attachment_read ---
+
+func TestAttachmentRead(t *testing.T) {
+	ts := newTestExec(t)
+	attachments := work.NewAttachmentStore(t.TempDir())
+	ts.exec.SetAttachmentStore(attachments)
+
+	createResult := callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": ts.roleID,
+	})
+	id := extractID(t, toolText(createResult))
+	if _, err := attachments.Save(id, "spec.md", []byte("# Spec\nUse library X.")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := attachments.Save(id, "mock.png", []byte("\x89PNG\x00\x00")); err != nil {
+		t.Fatal(err)
+	}
+
+	if text := toolText(callTool(t, ts.exec, "work_get", map[string]string{"id": id})); !strings.Contains(text, `"name":"spec.md"`) || !strings.Contains(text, `"name":"mock.png"`) {
+		t.Errorf("work_get = %s, want attachments listed", text)
+	}
+	if text := toolText(callTool(t, ts.exec, "attachment_read", map[string]string{"work_id": id})); !strings.Contains(text, `"name":"mock.png"`) {
+		t.Errorf("attachment_read without name = %s, want the list", text)
+	}
+
+	result := callTool(t, ts.exec, "attachment_read", map[string]string{"work_id": id, "name": "spec.md"})
+	if result.IsError || toolText(result) != "# Spec\nUse library X." {
+		t.Errorf("text attachment = %+v, want its content", result)
+	}
+
+	result = callTool(t, ts.exec, "attachment_read", map[string]string{"work_id": id, "name": "mock.png"})
+	path, _ := attachments.Path(id, "mock.png")
+	if result.IsError || !strings.Contains(toolText(result), `"path":`+strconv.Quote(path)) {
+		t.Errorf("binary attachment = %+v, want its path", result)
+	}
+
+	result = callTool(t, ts.exec, "attachment_read", map[string]string{"work_id": id, "name": "missing.md"})
+	if !result.IsError || !strings.Contains(toolText(result), "attachment not found") {
+		t.Errorf("missing attachment = %+v, want not found error", result)
+	}
+}
+
+func TestAttachmentRead_Unavailable(t *testing.T) {
+	ts := newTestExec(t)
+
+	result := callTool(t, ts.exec, "attachment_read", map[string]string{"work_id": "x"})
+	if !result.IsError || !strings.Contains(toolText(result), "not available") {
+		t.Errorf("result = %+v, want unavailable error", result)
+	}
+}
+
 // --- Tool: work_comment_update ---
 
 func TestWorkCommentUpdate(t *testing.T) {
@@ -724,6 +1410,74 @@ func TestStepDone_NoSteps(t *testing.T) {
 	}
 }
 
+// With a review role, a story's final step_done starts a review task and
+// waits for it instead of closing.
+func TestStepDone_StoryStartsReview(t *testing.T) {
+	store, arStore, settingsStore, roleID := newStoresWithRole(t, agentrole.AgentRole{Name: "PM", RolePrompt: "You coordinate."})
+	store.(*work.FileStore).SetReviewRole(func() string { return roleID })
+	exec := NewExecutor(store, arStore, work.NewOperations(store, stubWorkStarter{}, stubNotifier{}), stubNotifier{}, settingsStore)
+	exec.SetReviewRole(func() string { return roleID })
+
+	result := callTool(t, exec, "work_create", map[string]string{
+		"type": "story", "title": "Test Story", "agent_role_id": roleID,
+	})
+	storyID := extractID(t, toolText(result))
+	callTool(t, exec, "work_start", map[string]string{"id": storyID})
+
+	result = callTool(t, exec, "step_done", map[string]string{"id": storyID})
+	if result.IsError || !strings.Contains(toolText(result), "needs a review") {
+		t.Fatalf("step_done = %q, want review started", toolText(result))
+	}
+
+	story, _, _ := store.Get(storyID)
+	if story.Status != work.StatusWaiting {
+		t.Errorf("story status = %s, want waiting", story.Status)
+	}
+	works, _ := store.List()
+	var review *work.Work
+	for i := range works {
+		if works[i].Review {
+			review = &works[i]
+		}
+	}
+	if review == nil || review.ParentID != storyID || review.Status != work.StatusInProgress {
+		t.Fatalf("review = %+v, want an in_progress review task under the story", review)
+	}
+
+	callTool(t, exec, "step_done", map[string]string{"id": review.ID})
+	if err := store.ResumeFromWaiting(context.Background(), storyID); err != nil {
+		t.Fatal(err)
+	}
+	result = callTool(t, exec, "step_done", map[string]string{"id": storyID})
+	if result.IsError || !strings.Contains(toolText(result), "closed") {
+		t.Errorf("step_done after review = %q, want closed", toolText(result))
+	}
+}
+
+// Under require_explicit_close, the final step_done leaves the work waiting
+// for the user instead of closing it.
+func TestStepDone_ExplicitClosePolicyWaitsForUser(t *testing.T) {
+	ts := newTestExec(t)
+
+	result := callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Test Story", "agent_role_id": ts.roleID,
+	})
+	storyID := extractID(t, toolText(result))
+	policy := work.ClosePolicyExplicit
+	if err := ts.store.Update(context.Background(), storyID, work.UpdateFields{ClosePolicy: &policy}); err != nil {
+		t.Fatal(err)
+	}
+	callTool(t, ts.exec, "work_start", map[string]string{"id": storyID})
+
+	result = callTool(t, ts.exec, "step_done", map[string]string{"id": storyID})
+	if result.IsError || !strings.Contains(toolText(result), "approval") {
+		t.Fatalf("step_done = %q, want approval requested", toolText(result))
+	}
+	if w, _, _ := ts.store.Get(storyID); w.Status != work.StatusNeedsInput {
+		t.Errorf("status = %s, want needs_input", w.Status)
+	}
+}
+
 func TestWorkWait_StoryWithPendingChildWaits(t *testing.T) {
 	ts := newTestExec(t)
 
@@ -893,6 +1647,116 @@ func TestWorkReopen_NotifiesReopen(t *testing.T) {
 	}
 }
 
+func TestWorkCancel(t *testing.T) {
+	store, arStore, settingsStore, roleID := newStoresWithRole(t, agentrole.AgentRole{Name: "Eng", RolePrompt: "x"})
+	exec := NewExecutor(store, arStore, work.NewOperations(store, stubWorkStarter{}, stubNotifier{}), stubNotifier{}, settingsStore)
+
+	id := extractID(t, toolText(callTool(t, exec, "work_create", map[string]string{
+		"type": "story", "title": "S", "agent_role_id": roleID,
+	})))
+
+	if res := callTool(t, exec, "work_cancel", map[string]string{"id": id}); !res.IsError {
+		t.Error("expected error without a reason")
+	}
+
+	res := callTool(t, exec, "work_cancel", map[string]string{"id": id, "reason": "obsolete"})
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", toolText(res))
+	}
+	w, _, _ := store.Get(id)
+	if w.Status != work.StatusCancelled || w.CancelReason != "obsolete" {
+		t.Errorf("work = %s %q, want cancelled with reason", w.Status, w.CancelReason)
+	}
+}
+
+func TestWorkLogTime_RollsUpInWorkGet(t *testing.T) {
+	store, arStore, settingsStore, roleID := newStoresWithRole(t, agentrole.AgentRole{Name: "Eng", RolePrompt: "x"})
+	exec := NewExecutor(store, arStore, work.NewOperations(store, stubWorkStarter{}, stubNotifier{}), stubNotifier{}, settingsStore)
+
+	storyID := extractID(t, toolText(callTool(t, exec, "work_create", map[string]string{
+		"type": "story", "title": "S", "agent_role_id": roleID,
+	})))
+	taskID := extractID(t, toolText(callTool(t, exec, "work_create", map[string]string{
+		"type": "task", "parent_id": storyID, "title": "T", "agent_role_id": roleID,
+	})))
+
+	if res := callTool(t, exec, "work_update", map[string]any{"id": taskID, "estimate_minutes": 30}); res.IsError {
+		t.Fatalf("update: %s", toolText(res))
+	}
+	if res := callTool(t, exec, "work_log_time", map[string]any{"id": taskID, "minutes": 0}); !res.IsError {
+		t.Error("expected error for zero minutes")
+	}
+	if res := callTool(t, exec, "work_log_time", map[string]any{"id": taskID, "minutes": 40}); res.IsError {
+		t.Fatalf("log time: %s", toolText(res))
+	}
+
+	var detail struct {
+		EstimateMinutes  int            `json:"estimate_minutes"`
+		TimeSpentMinutes int            `json:"time_spent_minutes"`
+		Rollup           *work.Effort   `json:"rollup"`
+		Progress         *work.Progress `json:"progress"`
+	}
+	if err := json.Unmarshal([]byte(toolText(callTool(t, exec, "work_get", map[string]string{"id": storyID}))), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.Rollup == nil || *detail.Rollup != (work.Effort{EstimateMinutes: 30, TimeSpentMinutes: 40}) {
+		t.Errorf("story rollup = %+v, want 30/40", detail.Rollup)
+	}
+	if detail.Progress == nil || *detail.Progress != (work.Progress{Closed: 0, Total: 1, Percent: 0}) {
+		t.Errorf("story progress = %+v, want 0/1", detail.Progress)
+	}
+
+	detail.Rollup, detail.Progress = nil, nil
+	if err := json.Unmarshal([]byte(toolText(callTool(t, exec, "work_get", map[string]string{"id": taskID}))), &detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.EstimateMinutes != 30 || detail.TimeSpentMinutes != 40 || detail.Rollup != nil || detail.Progress != nil {
+		t.Errorf("task detail = %+v, want 30/40 without rollup", detail)
+	}
+}
+
+func TestTestReport(t *testing.T) {
+	store, arStore, settingsStore, roleID := newStoresWithRole(t, agentrole.AgentRole{Name: "Eng", RolePrompt: "x"})
+	exec := NewExecutor(store, arStore, work.NewOperations(store, stubWorkStarter{}, stubNotifier{}), stubNotifier{}, settingsStore)
+
+	args := map[string]any{"work_id": "w", "suite": "unit", "passed": 1, "failed": 0}
+	if res := callTool(t, exec, "test_report", args); !res.IsError {
+		t.Error("expected error without a test run store")
+	}
+
+	runs, err := testrun.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec.SetTestRunStore(runs)
+
+	id := extractID(t, toolText(callTool(t, exec, "work_create", map[string]string{
+		"type": "story", "title": "S", "agent_role_id": roleID,
+	})))
+
+	if res := callTool(t, exec, "test_report", args); !res.IsError {
+		t.Error("expected error for unknown work")
+	}
+	if res := callTool(t, exec, "test_report", map[string]any{"work_id": id, "passed": 1, "failed": 0}); !res.IsError {
+		t.Error("expected error without a suite")
+	}
+
+	res := callTool(t, exec, "test_report", map[string]any{
+		"work_id": id, "suite": "go test ./...", "passed": 4, "failed": 1,
+		"failures": []map[string]string{{"name": "TestX", "message": "want 1, got 2"}},
+	})
+	if res.IsError {
+		t.Fatalf("unexpected error: %s", toolText(res))
+	}
+	got, err := runs.List(testrun.ListQuery{WorkID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Status != testrun.StatusFailed || len(got[0].Failures) != 1 {
+		t.Errorf("runs = %+v, want one failed run with a failure", got)
+	}
+}
+
 // agent_role_reset_defaults must also repoint the default agent role in settings
 // (parity with the WebSocket handler), otherwise the default dangles at a
 // deleted role.
@@ -994,3 +1858,164 @@ func extractCommentID(t *testing.T, s string) string {
 	}
 	return s[start : start+end]
 }
+
+func TestWorkStart_NoAutorunSlot(t *testing.T) {
+	ts := newTestExec(t)
+	slots := work.NewAutorunSlots(ts.store, func(string) int { return 1 })
+	ts.exec.SetAutorunSlots(slots)
+
+	running := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Running", "agent_role_id": ts.roleID,
+	})))
+	if _, err := ts.store.Start(context.Background(), running, "sess-running"); err != nil {
+		t.Fatal(err)
+	}
+	slots.HandleProcessState("sess-running", "running", false)
+
+	id := extractID(t, toolText(callTool(t, ts.exec, "work_create", map[string]string{
+		"type": "story", "title": "Story", "agent_role_id": ts.roleID,
+	})))
+	result := callTool(t, ts.exec, "work_start", map[string]string{"id": id})
+	if !result.IsError || !strings.Contains(toolText(result), "no free autorun slot") {
+		t.Errorf("result = %q, want no free slot error", toolText(result))
+	}
+
+	slots.HandleProcessState("sess-running", "idle", false)
+	if result := callTool(t, ts.exec, "work_start", map[string]string{"id": id}); result.IsError {
+		t.Errorf("work_start after the slot freed: %s", toolText(result))
+	}
+}
+
+// --- Worktree-scoped work stores ---
+
+type stubWorkStores struct {
+	dir   string
+	store work.Store
+}
+
+func (s stubWorkStores) ForDataDir(dataDir string) (work.Store, bool, error) {
+	return s.store, dataDir == s.dir, nil
+}
+
+func TestExecute_WorktreeWorkStore(t *testing.T) {
+	ts := newTestExec(t)
+	isolated, err := work.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.exec.SetWorkStoreResolver(stubWorkStores{dir: "/data/worktrees/exp", store: isolated})
+
+	ctx := withWorkStoreDir(context.Background(), "/data/worktrees/exp")
+	args, _ := json.Marshal(map[string]string{"type": "story", "title": "Experiment", "agent_role_id": ts.roleID})
+	text, err := ts.exec.Execute(ctx, "work_create", args)
+	if err != nil {
+		t.Fatalf("work_create: %v", err)
+	}
+	id := extractID(t, text)
+	if _, found, _ := isolated.Get(id); !found {
+		t.Error("work was not created in the worktree's store")
+	}
+	if works, _ := ts.store.List(); len(works) != 0 {
+		t.Errorf("main store has %d works, want 0", len(works))
+	}
+
+	args, _ = json.Marshal(map[string]string{"id": id})
+	if _, err := ts.exec.Execute(ctx, "work_start", args); err == nil || !isUserError(err) {
+		t.Errorf("work_start on isolated work: err = %v, want a user error", err)
+	}
+}
+
+// --- Tools: git_status, git_diff ---
+
+type stubWorkDirs map[string]string
+
+func (s stubWorkDirs) WorkDirForSession(sessionID string) (string, bool) {
+	dir, ok := s[sessionID]
+	return dir, ok
+}
+
+func TestGitTools(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	git("init")
+	git("config", "user.email", "test@test.com")
+	git("config", "user.name", "Test")
+	git("config", "commit.gpgsign", "false")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "a.txt")
+	git("commit", "-m", "init")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(strings.Repeat("two\n", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestExec(t)
+	call := func(sessionID, name string, args any) result {
+		t.Helper()
+		raw, _ := json.Marshal(args)
+		text, err := ts.exec.Execute(WithSessionID(context.Background(), sessionID), name, raw)
+		if err != nil {
+			return result{Text: "Error: " + err.Error(), IsError: true}
+		}
+		return result{Text: text}
+	}
+
+	if r := call("s1", "git_status", map[string]any{}); !r.IsError {
+		t.Errorf("expected an error without a resolver, got %s", r.Text)
+	}
+	ts.exec.SetWorkDirResolver(stubWorkDirs{"s1": dir})
+	if r := call("other", "git_status", map[string]any{}); !r.IsError {
+		t.Errorf("expected an error for a session without a worktree, got %s", r.Text)
+	}
+
+	var status struct {
+		Unstaged []struct {
+			Path string `json:"path"`
+		} `json:"unstaged"`
+	}
+	r := call("s1", "git_status", map[string]any{})
+	if err := json.Unmarshal([]byte(r.Text), &status); err != nil {
+		t.Fatalf("unmarshal %q: %v", r.Text, err)
+	}
+	if len(status.Unstaged) != 1 || status.Unstaged[0].Path != "a.txt" {
+		t.Errorf("unexpected status %s", r.Text)
+	}
+
+	var diff struct {
+		Diff      string            `json:"diff"`
+		Hunks     []json.RawMessage `json:"hunks"`
+		Size      int               `json:"size"`
+		Truncated bool              `json:"truncated"`
+	}
+	r = call("s1", "git_diff", map[string]any{"path": "a.txt"})
+	if err := json.Unmarshal([]byte(r.Text), &diff); err != nil {
+		t.Fatalf("unmarshal %q: %v", r.Text, err)
+	}
+	if !strings.Contains(diff.Diff, "+two") || len(diff.Hunks) == 0 || diff.Truncated || diff.Size != len(diff.Diff) {
+		t.Errorf("unexpected diff %s", r.Text)
+	}
+
+	diff.Hunks = nil
+	r = call("s1", "git_diff", map[string]any{"max_bytes": 100})
+	if err := json.Unmarshal([]byte(r.Text), &diff); err != nil {
+		t.Fatalf("unmarshal %q: %v", r.Text, err)
+	}
+	if !diff.Truncated || len(diff.Diff) > 100 || !strings.HasSuffix(diff.Diff, "\n") || diff.Size <= 100 || diff.Hunks != nil {
+		t.Errorf("expected a diff cut at a line under 100 bytes, got %s", r.Text)
+	}
+
+	if r := call("s1", "git_diff", map[string]any{"path": "../etc/passwd"}); !r.IsError {
+		t.Errorf("expected an error for a path outside the worktree, got %s", r.Text)
+	}
+	if r := call("s1", "git_diff", map[string]any{"max_bytes": maxGitDiffBytes + 1}); !r.IsError {
+		t.Errorf("expected an error for max_bytes over the cap, got %s", r.Text)
+	}
+}
@@ -0,0 +1,310 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/settings"
+)
+
+// SessionHeader carries the calling agent session's ID from the stdio proxy,
+// so tool calls can be attributed (and limited) per session.
+const SessionHeader = "X-Pockode-Session"
+
+var (
+	// ErrRateLimited is returned when a session exceeds a tool's rate limit.
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrLoopDetected is returned when a session keeps repeating the same call.
+	ErrLoopDetected = errors.New("loop detected")
+)
+
+// DefaultRateLimits keeps a runaway agent from flooding the board while
+// leaving plenty of room for normal use.
+const DefaultRateLimits = "work_create=20/1m,*=120/1m"
+
+// RateLimitsOff disables rate limiting entirely.
+const RateLimitsOff = "off"
+
+const (
+	DefaultLoopRepeats = 5
+	DefaultLoopWindow  = time.Minute
+)
+
+// readOnlyTools only read the board or the workspace. Agents legitimately
+// poll them with identical arguments while waiting on other work, so they
+// are exempt from loop detection; rate limits still apply.
+var readOnlyTools = map[string]bool{
+	"work_list":         true,
+	"work_search":       true,
+	"work_next":         true,
+	"work_get":          true,
+	"work_context":      true,
+	"work_comment_list": true,
+	"attachment_read":   true,
+	"git_status":        true,
+	"git_diff":          true,
+	"agent_role_list":   true,
+	"agent_role_get":    true,
+}
+
+// RateLimit allows Calls calls per Per.
+type RateLimit struct {
+	Calls int
+	Per   time.Duration
+}
+
+// ParseRateLimits parses a comma-separated list of tool=calls/duration
+// entries ("work_create=10/1m,*=60/1m"). "*" applies to every tool without
+// its own entry. Empty returns DefaultRateLimits; RateLimitsOff returns nil.
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		s = DefaultRateLimits
+	}
+	if s == RateLimitsOff {
+		return nil, nil
+	}
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tool, spec, ok := strings.Cut(entry, "=")
+		calls, per, ok2 := strings.Cut(spec, "/")
+		tool = strings.TrimSpace(tool)
+		if !ok || !ok2 || tool == "" {
+			return nil, fmt.Errorf("invalid mcp rate limit %q: use tool=calls/duration", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(calls))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid mcp rate limit %q: calls must be a positive integer", entry)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(per))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid mcp rate limit %q: duration must be positive, like 30s or 1m", entry)
+		}
+		if _, dup := limits[tool]; dup {
+			return nil, fmt.Errorf("invalid mcp rate limit %q: duplicate tool", entry)
+		}
+		limits[tool] = RateLimit{Calls: n, Per: d}
+	}
+	return limits, nil
+}
+
+// GuardConfig is the effective CallGuard configuration.
+type GuardConfig struct {
+	RateLimits  map[string]RateLimit
+	LoopRepeats int // identical calls allowed per LoopWindow; 0 disables
+	LoopWindow  time.Duration
+}
+
+// GuardConfigFromSettings reads the MCP guard settings. Invalid rate limits
+// (rejected by settings.update, so only from a hand-edited file) fall back to
+// the defaults rather than disabling the guard.
+func GuardConfigFromSettings(s settings.Settings) GuardConfig {
+	limits, err := ParseRateLimits(s.MCPRateLimits)
+	if err != nil {
+		limits, _ = ParseRateLimits("")
+	}
+	config := GuardConfig{
+		RateLimits:  limits,
+		LoopRepeats: s.MCPLoopRepeats,
+		LoopWindow:  time.Duration(s.MCPLoopWindowSeconds) * time.Second,
+	}
+	switch {
+	case config.LoopRepeats < 0:
+		config.LoopRepeats = 0
+	case config.LoopRepeats == 0:
+		config.LoopRepeats = DefaultLoopRepeats
+	}
+	if config.LoopWindow <= 0 {
+		config.LoopWindow = DefaultLoopWindow
+	}
+	return config
+}
+
+const (
+	AlertRateLimit = "rate_limit"
+	AlertLoop      = "loop"
+)
+
+// Alert tells the user a session's tool calls were refused.
+type Alert struct {
+	Kind          string `json:"kind"` // AlertRateLimit | AlertLoop
+	SessionID     string `json:"session_id,omitempty"`
+	Tool          string `json:"tool"`
+	Calls         int    `json:"calls"`
+	WindowSeconds int    `json:"window_seconds"`
+}
+
+// OnAlertListener receives guard alerts. Called from the tool call's
+// goroutine; implementations must not block.
+type OnAlertListener interface {
+	OnMCPAlert(alert Alert)
+}
+
+type guardCall struct {
+	tool string
+	args string
+	at   time.Time
+}
+
+type guardAlertKey struct {
+	sessionID, tool, kind string
+}
+
+// CallGuard enforces per-session tool rate limits and refuses state-changing
+// calls an agent keeps repeating with identical arguments. Refusals are tool errors
+// telling the agent to stop, so a misbehaving agent is nudged rather than
+// killed; the user is alerted once per session, tool and kind per window.
+type CallGuard struct {
+	config func() GuardConfig
+	now    func() time.Time
+
+	callsMu sync.Mutex
+	calls   map[string][]guardCall // by session ID
+	alerted map[guardAlertKey]time.Time
+
+	listenersMu sync.RWMutex
+	listeners   []OnAlertListener
+}
+
+// NewCallGuard reads config on every call, so settings changes apply
+// without a restart.
+func NewCallGuard(config func() GuardConfig) *CallGuard {
+	return &CallGuard{
+		config:  config,
+		now:     time.Now,
+		calls:   make(map[string][]guardCall),
+		alerted: make(map[guardAlertKey]time.Time),
+	}
+}
+
+func (g *CallGuard) AddOnAlertListener(l OnAlertListener) {
+	g.listenersMu.Lock()
+	defer g.listenersMu.Unlock()
+	g.listeners = append(g.listeners, l)
+}
+
+// Check records a call by sessionID, or returns a user error wrapping
+// ErrRateLimited or ErrLoopDetected when it must be refused. Refused calls
+// are not recorded, so an agent that backs off is let through once the
+// window has passed.
+func (g *CallGuard) Check(sessionID, tool string, args json.RawMessage) error {
+	config := g.config()
+	now := g.now()
+	key := canonicalArgs(args)
+
+	g.callsMu.Lock()
+	g.prune(now, config)
+	calls := g.calls[sessionID]
+
+	var alert *Alert
+	var err error
+	limit, ok := config.RateLimits[tool]
+	if !ok {
+		limit, ok = config.RateLimits["*"]
+	}
+	if ok {
+		if n := countCalls(calls, now.Add(-limit.Per), func(c guardCall) bool { return c.tool == tool }); n >= limit.Calls {
+			alert = &Alert{Kind: AlertRateLimit, SessionID: sessionID, Tool: tool, Calls: n, WindowSeconds: int(limit.Per.Seconds())}
+			err = userErrorf("%w: %s was called %d times in the last %s. Stop calling it; continue without it or ask the user for help", ErrRateLimited, tool, n, limit.Per)
+		}
+	}
+	if err == nil && config.LoopRepeats > 0 && !readOnlyTools[tool] {
+		if n := countCalls(calls, now.Add(-config.LoopWindow), func(c guardCall) bool { return c.tool == tool && c.args == key }); n >= config.LoopRepeats {
+			alert = &Alert{Kind: AlertLoop, SessionID: sessionID, Tool: tool, Calls: n, WindowSeconds: int(config.LoopWindow.Seconds())}
+			err = userErrorf("%w: %s was called with identical arguments %d times in the last %s. Stop repeating this call; the earlier calls already ran, so move on or ask the user for help", ErrLoopDetected, tool, n, config.LoopWindow)
+		}
+	}
+	if err == nil {
+		g.calls[sessionID] = append(calls, guardCall{tool: tool, args: key, at: now})
+	} else {
+		ak := guardAlertKey{sessionID: sessionID, tool: tool, kind: alert.Kind}
+		if last, seen := g.alerted[ak]; seen && now.Sub(last) < time.Duration(alert.WindowSeconds)*time.Second {
+			alert = nil
+		} else {
+			g.alerted[ak] = now
+		}
+	}
+	g.callsMu.Unlock()
+
+	if alert != nil {
+		g.notify(*alert)
+	}
+	return err
+}
+
+// prune drops calls older than the longest window. Caller holds callsMu.
+func (g *CallGuard) prune(now time.Time, config GuardConfig) {
+	horizon := config.LoopWindow
+	for _, l := range config.RateLimits {
+		horizon = max(horizon, l.Per)
+	}
+	cutoff := now.Add(-horizon)
+	for id, calls := range g.calls {
+		i := 0
+		for i < len(calls) && !calls[i].at.After(cutoff) {
+			i++
+		}
+		if i == len(calls) {
+			delete(g.calls, id)
+		} else if i > 0 {
+			g.calls[id] = append([]guardCall(nil), calls[i:]...)
+		}
+	}
+	for k, at := range g.alerted {
+		if !at.After(cutoff) {
+			delete(g.alerted, k)
+		}
+	}
+}
+
+func (g *CallGuard) notify(alert Alert) {
+	g.listenersMu.RLock()
+	defer g.listenersMu.RUnlock()
+	for _, l := range g.listeners {
+		l.OnMCPAlert(alert)
+	}
+}
+
+func countCalls(calls []guardCall, since time.Time, match func(guardCall) bool) int {
+	n := 0
+	for _, c := range calls {
+		if c.at.After(since) && match(c) {
+			n++
+		}
+	}
+	return n
+}
+
+// canonicalArgs ignores whitespace differences so reformatted but identical
+// arguments still count as a repeat.
+func canonicalArgs(args json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, args); err != nil {
+		return string(args)
+	}
+	return buf.String()
+}
+
+type sessionContextKey struct{}
+
+// WithSessionID returns ctx carrying the calling session's ID.
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, sessionID)
+}
+
+// SessionIDFromContext returns the session ID set by WithSessionID, or "".
+func SessionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(sessionContextKey{}).(string)
+	return id
+}
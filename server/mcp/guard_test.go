@@ -0,0 +1,209 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/settings"
+)
+
+type alertRecorder struct {
+	alertsMu sync.Mutex
+	alerts   []Alert
+}
+
+func (r *alertRecorder) OnMCPAlert(a Alert) {
+	r.alertsMu.Lock()
+	defer r.alertsMu.Unlock()
+	r.alerts = append(r.alerts, a)
+}
+
+func (r *alertRecorder) list() []Alert {
+	r.alertsMu.Lock()
+	defer r.alertsMu.Unlock()
+	return append([]Alert(nil), r.alerts...)
+}
+
+func newTestGuard(config GuardConfig) (*CallGuard, *time.Time, *alertRecorder) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := NewCallGuard(func() GuardConfig { return config })
+	g.now = func() time.Time { return now }
+	rec := &alertRecorder{}
+	g.AddOnAlertListener(rec)
+	return g, &now, rec
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits(" work_create=10/1m , *=60/30s")
+	if err != nil {
+		t.Fatalf("ParseRateLimits: %v", err)
+	}
+	if limits["work_create"] != (RateLimit{Calls: 10, Per: time.Minute}) || limits["*"] != (RateLimit{Calls: 60, Per: 30 * time.Second}) {
+		t.Errorf("limits = %v", limits)
+	}
+
+	if limits, err := ParseRateLimits(""); err != nil || limits["work_create"].Calls == 0 {
+		t.Errorf("empty = %v, %v; want defaults", limits, err)
+	}
+	if limits, err := ParseRateLimits(RateLimitsOff); err != nil || limits != nil {
+		t.Errorf("off = %v, %v; want nil", limits, err)
+	}
+
+	for _, bad := range []string{"work_create", "work_create=10", "work_create=0/1m", "work_create=x/1m", "work_create=1/0s", "work_create=1/soon", "=1/1m", "a=1/1m,a=2/1m"} {
+		if _, err := ParseRateLimits(bad); err == nil {
+			t.Errorf("ParseRateLimits(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestGuardConfigFromSettings(t *testing.T) {
+	config := GuardConfigFromSettings(settings.Settings{MCPRateLimits: "bogus"})
+	if config.RateLimits["work_create"].Calls == 0 || config.LoopRepeats != DefaultLoopRepeats || config.LoopWindow != DefaultLoopWindow {
+		t.Errorf("config = %+v, want defaults", config)
+	}
+	config = GuardConfigFromSettings(settings.Settings{MCPRateLimits: RateLimitsOff, MCPLoopRepeats: -1, MCPLoopWindowSeconds: 10})
+	if config.RateLimits != nil || config.LoopRepeats != 0 || config.LoopWindow != 10*time.Second {
+		t.Errorf("config = %+v, want limits and loop detection off", config)
+	}
+}
+
+func TestCallGuard_RateLimit(t *testing.T) {
+	g, now, rec := newTestGuard(GuardConfig{RateLimits: map[string]RateLimit{
+		"work_create": {Calls: 2, Per: time.Minute},
+		"*":           {Calls: 100, Per: time.Minute},
+	}})
+
+	for i := range 2 {
+		if err := g.Check("s1", "work_create", json.RawMessage(`{"title":"`+string(rune('a'+i))+`"}`)); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	err := g.Check("s1", "work_create", json.RawMessage(`{"title":"c"}`))
+	if !errors.Is(err, ErrRateLimited) || !isUserError(err) {
+		t.Fatalf("got %v, want rate limit user error", err)
+	}
+	if !strings.Contains(err.Error(), "Stop calling it") {
+		t.Errorf("message %q should tell the agent to stop", err)
+	}
+	// Refusals repeat but the user is alerted once per window.
+	if err := g.Check("s1", "work_create", json.RawMessage(`{"title":"d"}`)); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want rate limit", err)
+	}
+	alerts := rec.list()
+	if len(alerts) != 1 || alerts[0] != (Alert{Kind: AlertRateLimit, SessionID: "s1", Tool: "work_create", Calls: 2, WindowSeconds: 60}) {
+		t.Errorf("alerts = %+v", alerts)
+	}
+
+	// Other sessions and tools have their own budgets.
+	if err := g.Check("s2", "work_create", nil); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+	if err := g.Check("s1", "work_list", nil); err != nil {
+		t.Errorf("other tool: %v", err)
+	}
+
+	*now = now.Add(61 * time.Second)
+	if err := g.Check("s1", "work_create", json.RawMessage(`{"title":"e"}`)); err != nil {
+		t.Errorf("after the window: %v", err)
+	}
+}
+
+func TestCallGuard_LoopDetection(t *testing.T) {
+	g, now, rec := newTestGuard(GuardConfig{LoopRepeats: 3, LoopWindow: time.Minute})
+
+	for i := range 3 {
+		if err := g.Check("s1", "work_update", json.RawMessage(`{"id": "w1", "title": "t"}`)); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	// Different arguments are not a repeat.
+	if err := g.Check("s1", "work_update", json.RawMessage(`{"id":"w2","title":"t"}`)); err != nil {
+		t.Fatalf("different args: %v", err)
+	}
+	// Whitespace does not make a call different.
+	err := g.Check("s1", "work_update", json.RawMessage(`{"id":"w1","title":"t"}`))
+	if !errors.Is(err, ErrLoopDetected) || !isUserError(err) {
+		t.Fatalf("got %v, want loop detected", err)
+	}
+	if alerts := rec.list(); len(alerts) != 1 || alerts[0].Kind != AlertLoop || alerts[0].Calls != 3 {
+		t.Errorf("alerts = %+v", alerts)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if err := g.Check("s1", "work_update", json.RawMessage(`{"id":"w1","title":"t"}`)); err != nil {
+		t.Errorf("after the window: %v", err)
+	}
+}
+
+func TestCallGuard_LoopDetectionSkipsReadOnlyTools(t *testing.T) {
+	g, _, rec := newTestGuard(GuardConfig{LoopRepeats: 3, LoopWindow: time.Minute})
+
+	// Polling a read-only tool is not a loop.
+	for i := range 10 {
+		if err := g.Check("s1", "work_get", json.RawMessage(`{"id":"w1"}`)); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if alerts := rec.list(); len(alerts) != 0 {
+		t.Errorf("alerts = %+v", alerts)
+	}
+
+	// Rate limits still apply to read-only tools.
+	g, _, _ = newTestGuard(GuardConfig{
+		RateLimits:  map[string]RateLimit{"*": {Calls: 4, Per: time.Minute}},
+		LoopRepeats: 3,
+		LoopWindow:  time.Minute,
+	})
+	for i := range 4 {
+		if err := g.Check("s1", "work_list", json.RawMessage(`{}`)); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if err := g.Check("s1", "work_list", json.RawMessage(`{}`)); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want rate limited", err)
+	}
+}
+
+func TestProxyToolCall_RateLimitedPerSession(t *testing.T) {
+	ts := newTestExec(t)
+	ts.exec.SetCallGuard(NewCallGuard(func() GuardConfig {
+		return GuardConfig{RateLimits: map[string]RateLimit{"work_create": {Calls: 1, Per: time.Minute}}}
+	}))
+	httpSrv := httptest.NewServer(NewAPIHandler(ts.exec, "secret"))
+	t.Cleanup(httpSrv.Close)
+
+	newProxy := func(sessionID string) *Server {
+		client := &Client{baseURL: httpSrv.URL, token: "secret", http: httpSrv.Client()}
+		client.SetSessionID(sessionID)
+		return NewServer(client, "test")
+	}
+	create := func(s *Server, title string) toolCallResult {
+		t.Helper()
+		resp := callToolViaProxy(t, s, "work_create", map[string]string{"type": "story", "title": title, "agent_role_id": ts.roleID})
+		if resp.Error != nil {
+			t.Fatalf("unexpected RPC error: %+v", resp.Error)
+		}
+		b, _ := json.Marshal(resp.Result)
+		var result toolCallResult
+		if err := json.Unmarshal(b, &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	s1 := newProxy("s1")
+	if r := create(s1, "One"); r.IsError {
+		t.Fatalf("first call: %s", r.Content[0].Text)
+	}
+	if r := create(s1, "Two"); !r.IsError || !strings.Contains(r.Content[0].Text, "rate limit exceeded") {
+		t.Fatalf("second call = %+v, want rate limit error", r)
+	}
+	if r := create(newProxy("s2"), "Three"); r.IsError {
+		t.Fatalf("other session: %s", r.Content[0].Text)
+	}
+}
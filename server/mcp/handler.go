@@ -1,6 +1,7 @@
 package mcp
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/json"
 	"errors"
@@ -12,6 +13,10 @@ import (
 // APIPath is the local HTTP endpoint the stdio proxy forwards tool calls to.
 const APIPath = "/api/mcp/tools/call"
 
+// WorkStoreHeader carries the data dir of a worktree with its own work store,
+// set by proxies running in that worktree so work tools use its store.
+const WorkStoreHeader = "X-Pockode-Data-Dir"
+
 // maxRequestBody caps the tool-call request body. 1MB matches the stdio
 // scanner buffer in server.go and is far above any real tool argument payload.
 const maxRequestBody = 1 << 20
@@ -36,18 +41,30 @@ type toolCallResponse struct {
 type APIHandler struct {
 	executor *Executor
 	token    string
+	events   *EventHub
 }
 
 func NewAPIHandler(executor *Executor, token string) *APIHandler {
 	return &APIHandler{executor: executor, token: token}
 }
 
+// SetEvents enables the work change stream at EventsPath. Without it the
+// endpoint answers 404 and proxies fall back to request/response only.
+func (h *APIHandler) SetEvents(hub *EventHub) {
+	h.events = hub
+}
+
 func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !h.authorized(r) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
+	if r.URL.Path == EventsPath {
+		h.serveEvents(w, r)
+		return
+	}
+
 	// Bound the body: tool arguments are small, so cap to avoid unbounded memory
 	// from a malformed or hostile request.
 	var req toolCallRequest
@@ -56,7 +73,9 @@ func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	text, err := h.executor.Execute(r.Context(), req.Name, req.Arguments)
+	ctx := WithSessionID(r.Context(), r.Header.Get(SessionHeader))
+	ctx = withWorkStoreDir(ctx, r.Header.Get(WorkStoreHeader))
+	text, err := h.executor.Execute(ctx, req.Name, req.Arguments)
 	if err != nil {
 		if errors.Is(err, ErrUnknownTool) {
 			writeJSONError(w, http.StatusBadRequest, err.Error())
@@ -99,3 +118,19 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 func writeJSONError(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, map[string]string{"error": msg})
 }
+
+type workStoreDirContextKey struct{}
+
+// withWorkStoreDir records the worktree data dir a tool call's store is
+// resolved from; "" leaves the main store.
+func withWorkStoreDir(ctx context.Context, dir string) context.Context {
+	if dir == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, workStoreDirContextKey{}, dir)
+}
+
+func workStoreDirFromContext(ctx context.Context) string {
+	dir, _ := ctx.Value(workStoreDirContextKey{}).(string)
+	return dir
+}
@@ -0,0 +1,127 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// argumentError lists every way a tool call's arguments fail the tool's
+// inputSchema. Handlers decode loosely, so without this check a missing
+// field reaches the store as a zero value and fails with an error that does
+// not name it.
+type argumentError struct {
+	tool     string
+	problems []string
+}
+
+func (e *argumentError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s:\n- %s", e.tool, strings.Join(e.problems, "\n- "))
+}
+
+// validateArguments checks args against schema: required fields, types,
+// enums and array items, recursing into nested objects. Fields the schema
+// does not declare are left to the handler, and null counts as absent.
+func validateArguments(tool string, schema inputSchema, args json.RawMessage) error {
+	var v any = map[string]any{}
+	if trimmed := bytes.TrimSpace(args); len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null")) {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return &argumentError{tool: tool, problems: []string{"arguments are not valid JSON"}}
+		}
+	}
+
+	var problems []string
+	root := propertySchema{Type: schema.Type, Properties: schema.Properties, Required: schema.Required}
+	checkValue("", root, v, &problems)
+	if len(problems) > 0 {
+		return &argumentError{tool: tool, problems: problems}
+	}
+	return nil
+}
+
+func checkValue(path string, s propertySchema, v any, problems *[]string) {
+	if got := jsonType(v); !typeMatches(s.Type, got) {
+		name := path
+		if name == "" {
+			name = "arguments"
+		}
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", name, s.Type, got))
+		return
+	}
+
+	switch v := v.(type) {
+	case string:
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+			*problems = append(*problems, fmt.Sprintf("%s: expected one of %s, got %q", path, quoteAll(s.Enum), v))
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				checkValue(fmt.Sprintf("%s[%d]", path, i), *s.Items, item, problems)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if v[name] == nil {
+				*problems = append(*problems, joinPath(path, name)+": required field is missing")
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok || v[name] == nil {
+				continue
+			}
+			checkValue(joinPath(path, name), prop, v[name], problems)
+		}
+	}
+}
+
+// jsonType names v's JSON type, telling integers from other numbers the
+// way a Go int field would accept them.
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func typeMatches(want, got string) bool {
+	return want == "" || want == got || (want == "number" && got == "integer")
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,107 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		tool string
+		args string
+		want []string // problems, in order; nil = valid
+	}{
+		{"valid", "work_create", `{"type":"story","title":"T","agent_role_id":"r"}`, nil},
+		{"missing required", "work_create", `{}`, []string{
+			"type: required field is missing",
+			"title: required field is missing",
+		}},
+		{"null is absent", "work_create", `{"type":"story","title":null,"agent_role_id":"r","parent_id":null}`, []string{
+			"title: required field is missing",
+		}},
+		{"empty arguments", "work_get", ``, []string{"id: required field is missing"}},
+		{"enum", "work_create", `{"type":"epic","title":"T","agent_role_id":"r"}`, []string{
+			`type: expected one of "story", "task", got "epic"`,
+		}},
+		{"mistyped", "work_list", `{"limit":"10","offset":1.5,"parent_id":3}`, []string{
+			"limit: expected integer, got string",
+			"offset: expected integer, got number",
+			"parent_id: expected string, got integer",
+		}},
+		{"array items", "work_list", `{"status":["open","done",1]}`, []string{
+			`status[1]: expected one of "open", "in_progress", "needs_input", "waiting", "stopped", "needs_attention", "closed", "cancelled", got "done"`,
+			"status[2]: expected string, got integer",
+		}},
+		{"nested objects", "work_bulk", `{"operations":[{"action":"stop","id":"a"},{"id":"b"}]}`, []string{
+			"operations[1].action: required field is missing",
+		}},
+		{"not an object", "work_get", `["id"]`, []string{"arguments: expected object, got array"}},
+		{"unknown fields pass", "work_get", `{"id":"a","verbose":true}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, ok := findTool(tt.tool)
+			if !ok {
+				t.Fatalf("unknown tool %s", tt.tool)
+			}
+			err := validateArguments(tt.tool, def.InputSchema, json.RawMessage(tt.args))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			var ae *argumentError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want *argumentError", err)
+			}
+			if strings.Join(ae.problems, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(ae.problems, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestExecute_RejectsInvalidArguments(t *testing.T) {
+	ts := newTestExec(t)
+
+	res := callTool(t, ts.exec, "work_create", map[string]any{"type": "story", "title": 5})
+	want := "Error: invalid arguments for work_create:\n- title: expected string, got integer"
+	if !res.IsError || res.Text != want {
+		t.Errorf("result = %q, want %q", res.Text, want)
+	}
+	works, err := ts.store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(works) != 0 {
+		t.Errorf("invalid call created %d work items", len(works))
+	}
+	if _, err := ts.exec.Execute(t.Context(), "work_get", nil); !isUserError(err) {
+		t.Errorf("argument errors should count as user errors, got %v", err)
+	}
+}
+
+// Every required field must be declared, or no call could satisfy it.
+func TestToolDefinitions_RequiredFieldsDeclared(t *testing.T) {
+	var check func(tool, path string, props map[string]propertySchema, required []string)
+	check = func(tool, path string, props map[string]propertySchema, required []string) {
+		for _, name := range required {
+			if _, ok := props[name]; !ok {
+				t.Errorf("%s: required field %s%s is not declared", tool, path, name)
+			}
+		}
+		for name, p := range props {
+			check(tool, path+name+".", p.Properties, p.Required)
+			if p.Items != nil {
+				check(tool, path+name+"[].", p.Items.Properties, p.Items.Required)
+			}
+		}
+	}
+	for _, def := range toolDefinitions {
+		check(def.Name, "", def.InputSchema.Properties, def.InputSchema.Required)
+	}
+}
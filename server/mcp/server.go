@@ -21,6 +21,20 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"sync"
+	"time"
+)
+
+// WorkChangedMethod is the custom notification the proxy emits for every work
+// change. Clients that don't know it ignore it, as JSON-RPC requires.
+const WorkChangedMethod = "notifications/pockode/work_changed"
+
+const (
+	// listChangedDelay coalesces a burst of work changes (a bulk create, a
+	// parent and its children closing) into one tools/list_changed.
+	listChangedDelay = 500 * time.Millisecond
+	// eventRetryDelay paces reconnects to the server's event stream.
+	eventRetryDelay = 2 * time.Second
 )
 
 // Server is the stdio MCP proxy. It answers protocol handshakes locally and
@@ -28,6 +42,9 @@ import (
 type Server struct {
 	client  *Client
 	version string
+
+	watchOnce        sync.Once
+	listChangedTimer *time.Timer // only touched by the watchEvents goroutine
 }
 
 func NewServer(client *Client, version string) *Server {
@@ -40,8 +57,22 @@ func NewServer(client *Client, version string) *Server {
 // round-trip to the local server, so head-of-line blocking is negligible;
 // processing concurrently would buy little and require serializing stdout
 // writes. Revisit only if a genuinely slow tool is added.
+//
+// Once the client sends notifications/initialized, a background goroutine
+// subscribes to the server's work change stream and pushes notifications to
+// stdout alongside the responses.
 func (s *Server) Run(ctx context.Context) error {
-	scanner := bufio.NewScanner(os.Stdin)
+	return s.run(ctx, os.Stdin, os.Stdout)
+}
+
+func (s *Server) run(ctx context.Context, in io.Reader, stdout io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Responses and notifications come from different goroutines; each message
+	// is a single Write, so serializing writes keeps lines intact.
+	out := &syncWriter{w: stdout}
+
+	scanner := bufio.NewScanner(in)
 	// 1MB buffer: the default 64KB is sufficient for current payloads, but MCP
 	// doesn't define a max message size. 1MB gives headroom (e.g. large tool
 	// results) with negligible cost since there's one scanner per process.
@@ -55,29 +86,85 @@ func (s *Server) Run(ctx context.Context) error {
 
 		var req jsonRPCRequest
 		if err := json.Unmarshal(line, &req); err != nil {
-			writeJSONRPCError(os.Stdout, nil, -32700, "Parse error")
+			writeJSONRPCError(out, nil, -32700, "Parse error")
 			continue
 		}
 
 		// Notifications (no id) don't need a response per JSON-RPC 2.0 spec
 		if req.ID == nil {
 			slog.Debug("received MCP notification", "method", req.Method)
+			// The spec forbids server notifications before the handshake completes.
+			if req.Method == "notifications/initialized" && s.client != nil {
+				s.watchOnce.Do(func() { go s.watchEvents(ctx, out) })
+			}
 			continue
 		}
 
-		s.handleRequest(ctx, os.Stdout, &req)
+		s.handleRequest(ctx, out, &req)
 	}
 
 	return scanner.Err()
 }
 
+// watchEvents relays the server's work changes as MCP notifications until ctx
+// is done, reconnecting when the stream drops (e.g. a server restart). A
+// server without the stream (404) ends the watch.
+func (s *Server) watchEvents(ctx context.Context, w io.Writer) {
+	defer func() {
+		if s.listChangedTimer != nil {
+			s.listChangedTimer.Stop()
+		}
+	}()
+	for {
+		err := s.client.StreamEvents(ctx, func(change WorkChange) {
+			writeJSONRPCNotification(w, WorkChangedMethod, change)
+			s.scheduleListChanged(w)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			slog.Debug("server has no MCP event stream, notifications disabled")
+			return
+		}
+		slog.Debug("MCP event stream disconnected, retrying", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventRetryDelay):
+		}
+	}
+}
+
+// scheduleListChanged sends notifications/tools/list_changed once a burst of
+// work changes settles. The tool set itself is static, but list_changed is the
+// only standard nudge most MCP clients act on, and re-listing is answered
+// locally so it costs nothing; clients that understand WorkChangedMethod get
+// the detail from it directly.
+func (s *Server) scheduleListChanged(w io.Writer) {
+	if s.listChangedTimer != nil {
+		s.listChangedTimer.Reset(listChangedDelay)
+		return
+	}
+	s.listChangedTimer = time.AfterFunc(listChangedDelay, func() {
+		writeJSONRPCNotification(w, "notifications/tools/list_changed", nil)
+	})
+}
+
 func (s *Server) handleRequest(ctx context.Context, w io.Writer, req *jsonRPCRequest) {
 	switch req.Method {
 	case "initialize":
 		writeJSONRPCResult(w, req.ID, initializeResult{
 			ProtocolVersion: "2024-11-05",
 			Capabilities: capabilities{
-				Tools: &toolsCap{},
+				Tools: &toolsCap{ListChanged: s.client != nil},
+				// Advertises the custom work change notification so clients can
+				// tell a quiet server from one that doesn't push.
+				Experimental: map[string]any{
+					"pockode/work_changed": map[string]any{},
+				},
 			},
 			ServerInfo: serverInfo{
 				Name:    "pockode",
@@ -139,6 +226,12 @@ type jsonRPCResponse struct {
 	Error   *rpcError   `json:"error,omitempty"`
 }
 
+type jsonRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 type rpcError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -159,6 +252,27 @@ func writeJSONRPCResult(w io.Writer, id json.RawMessage, result interface{}) {
 	fmt.Fprintf(w, "%s\n", data)
 }
 
+func writeJSONRPCNotification(w io.Writer, method string, params interface{}) {
+	data, err := json.Marshal(jsonRPCNotification{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		slog.Error("failed to marshal JSON-RPC notification", "method", method, "error", err)
+		return
+	}
+	fmt.Fprintf(w, "%s\n", data)
+}
+
+// syncWriter serializes writes from the request loop and the event watcher.
+type syncWriter struct {
+	writeMu sync.Mutex
+	w       io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.w.Write(p)
+}
+
 func writeJSONRPCError(w io.Writer, id json.RawMessage, code int, message string) {
 	resp := jsonRPCResponse{
 		JSONRPC: "2.0",
@@ -184,10 +298,13 @@ type initializeResult struct {
 }
 
 type capabilities struct {
-	Tools *toolsCap `json:"tools,omitempty"`
+	Tools        *toolsCap      `json:"tools,omitempty"`
+	Experimental map[string]any `json:"experimental,omitempty"`
 }
 
-type toolsCap struct{}
+type toolsCap struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
 
 type serverInfo struct {
 	Name    string `json:"name"`
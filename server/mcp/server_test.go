@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/pockode/server/serverinfo"
 )
 
 // callMethod sends a JSON-RPC request to the stdio proxy and returns the
@@ -71,7 +74,7 @@ func TestToolsList(t *testing.T) {
 		names[td.Name] = true
 	}
 
-	for _, want := range []string{"work_list", "work_create", "work_update", "work_get", "work_delete", "work_start", "work_needs_input", "step_done", "work_comment_add", "work_comment_list", "agent_role_list", "agent_role_get", "agent_role_reset_defaults"} {
+	for _, want := range []string{"work_list", "work_search", "work_create", "work_update", "work_get", "work_delete", "work_start", "work_needs_input", "step_done", "work_comment_add", "work_comment_list", "agent_role_list", "agent_role_get", "agent_role_create", "agent_role_update", "agent_role_delete", "agent_role_reset_defaults"} {
 		if !names[want] {
 			t.Errorf("missing tool %q", want)
 		}
@@ -133,6 +136,40 @@ func TestProxyToolCall_Success(t *testing.T) {
 	}
 }
 
+func TestProxyToolCall_OverSocket(t *testing.T) {
+	ts := newTestExec(t)
+	dataDir := t.TempDir()
+	ln, err := ListenSocket(dataDir)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := &http.Server{Handler: NewSocketMux(NewAPIHandler(ts.exec, "secret"))}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	// Port 1 refuses connections, so a passing call went over the socket.
+	if err := serverinfo.Write(dataDir, 1, "http://localhost:1", "", "secret", ln.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClientFromServerInfo(dataDir)
+	if err != nil {
+		t.Fatalf("NewClientFromServerInfo: %v", err)
+	}
+
+	resp := callToolViaProxy(t, NewServer(client, "test"), "work_create", map[string]string{
+		"type": "story", "title": "Socket Story", "agent_role_id": ts.roleID,
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected RPC error: %+v", resp.Error)
+	}
+	b, _ := json.Marshal(resp.Result)
+	var result toolCallResult
+	json.Unmarshal(b, &result)
+	if result.IsError || !strings.Contains(result.Content[0].Text, "Socket Story") {
+		t.Errorf("result = %+v, want the created story", result)
+	}
+}
+
 func TestProxyToolCall_ToolError(t *testing.T) {
 	s, _ := newProxyToAPI(t, "secret", "secret")
 
@@ -0,0 +1,60 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// SocketFilename is the unix socket in the data dir that serves the local MCP
+// API alongside the TCP port. Every agent's stdio proxy connects to it, so
+// all of them share the one server and its stores instead of each going
+// through the loopback port.
+const SocketFilename = "mcp.sock"
+
+// socketBaseURL is the URL the client uses over the socket; the host is
+// never resolved.
+const socketBaseURL = "http://pockode"
+
+// ListenSocket listens on the MCP socket in dataDir, replacing one left by a
+// server that did not shut down cleanly. The socket is only accessible to the
+// owner. It fails when the path is too long for a unix socket; the proxies
+// then use the TCP port.
+func ListenSocket(dataDir string) (net.Listener, error) {
+	path := filepath.Join(dataDir, SocketFilename)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale mcp socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("restrict mcp socket: %w", err)
+	}
+	return ln, nil
+}
+
+// NewSocketMux routes the local MCP API endpoints to h, for serving on the
+// MCP socket.
+func NewSocketMux(h *APIHandler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST "+APIPath, h)
+	mux.Handle("GET "+EventsPath, h)
+	return mux
+}
+
+// socketTransport dials path for every request.
+func socketTransport(path string) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}
+}
@@ -17,19 +17,58 @@ type inputSchema struct {
 }
 
 type propertySchema struct {
-	Type        string   `json:"type"`
-	Description string   `json:"description,omitempty"`
-	Enum        []string `json:"enum,omitempty"`
+	Type        string                    `json:"type"`
+	Description string                    `json:"description,omitempty"`
+	Enum        []string                  `json:"enum,omitempty"`
+	Items       *propertySchema           `json:"items,omitempty"`
+	Properties  map[string]propertySchema `json:"properties,omitempty"` // for Type "object"
+	Required    []string                  `json:"required,omitempty"`
 }
 
 var toolDefinitions = []toolDefinition{
 	{
 		Name:        "work_list",
-		Description: "List work items (stories and tasks). Returns all work items, optionally filtered by parent_id.",
+		Description: "List work items (stories and tasks) as {items, total}. Filter by parent, type, or status, and page with limit/offset; total counts all matches so you can tell whether more pages remain. Each item has a short_id (e.g. PCK-12) that every work tool accepts in place of its id.",
 		InputSchema: inputSchema{
 			Type: "object",
 			Properties: map[string]propertySchema{
 				"parent_id": {Type: "string", Description: "Filter by parent work ID"},
+				"type":      {Type: "string", Description: "Filter by work type", Enum: []string{"story", "task"}},
+				"status": {Type: "array", Description: "Only include work items in any of these statuses", Items: &propertySchema{
+					Type: "string", Enum: []string{"open", "in_progress", "needs_input", "waiting", "stopped", "needs_attention", "closed", "cancelled"},
+				}},
+				"due_before": {Type: "string", Description: "Only include work items due before this RFC 3339 time (pass the current time for overdue work)"},
+				"sort":       {Type: "string", Description: "Sort key (default: rank, the board order). due_at puts items without a due date last", Enum: []string{"rank", "created_at", "updated_at", "due_at"}},
+				"order":      {Type: "string", Description: "Sort direction (default: asc)", Enum: []string{"asc", "desc"}},
+				"limit":      {Type: "integer", Description: "Maximum items to return (1-200, default: all)"},
+				"offset":     {Type: "integer", Description: "Number of matching items to skip"},
+			},
+		},
+	},
+	{
+		Name:        "work_search",
+		Description: "Search work items by title and body, best matches first, as {hits, total}. Every word of the query must match; matching is case-insensitive and tolerates typos. Matched text is wrapped in ** in the title and body snippet. Prefer this over work_list when looking for specific work.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"query": {Type: "string", Description: "Words to search for"},
+				"type":  {Type: "string", Description: "Filter by work type", Enum: []string{"story", "task"}},
+				"status": {Type: "array", Description: "Only include work items in any of these statuses", Items: &propertySchema{
+					Type: "string", Enum: []string{"open", "in_progress", "needs_input", "waiting", "stopped", "needs_attention", "closed", "cancelled"},
+				}},
+				"limit": {Type: "integer", Description: "Maximum hits to return (1-100, default: 20)"},
+			},
+			Required: []string{"query"},
+		},
+	},
+	{
+		Name:        "work_next",
+		Description: "Get the work item to start next, as {work} (null when nothing is ready): the open item with an agent role that is not blocked, earliest due date first, then board order. An item is blocked while an item listed in its structured body's depends_on metadata is unfinished, while its parent is closed or cancelled, or while its budget is used up. Call this instead of picking from work_list yourself.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"agent_role_id": {Type: "string", Description: "Only consider work assigned to this agent role"},
+				"parent_id":     {Type: "string", Description: "Only consider children of this work item, e.g. your story's tasks"},
 			},
 		},
 	},
@@ -42,33 +81,68 @@ var toolDefinitions = []toolDefinition{
 				"type":          {Type: "string", Description: "Work type", Enum: []string{"story", "task"}},
 				"parent_id":     {Type: "string", Description: "Parent work ID (required for tasks)"},
 				"title":         {Type: "string", Description: "Title of the work item"},
-				"body":          {Type: "string", Description: "Detailed description or instructions for the work item"},
-				"agent_role_id": {Type: "string", Description: "Agent role ID (required)"},
+				"body":          {Type: "string", Description: "Detailed description or instructions for the work item. Mention other items as #<short_id> (e.g. #PCK-12) or #<id> to link them. Optionally structured: start with a ---/--- block of \"key: value\" metadata, then \"## Context\", \"## Acceptance Criteria\" (a list, \"- [ ] item\"), and \"## Notes\" sections; work_get then returns them as structured_body"},
+				"agent_role_id": {Type: "string", Description: "Agent role ID. Defaults to the default agent role (see agent_role_list)"},
+				"due_at":        {Type: "string", Description: "Due date as an RFC 3339 timestamp (e.g. 2026-03-01T17:00:00Z)"},
 			},
-			Required: []string{"type", "title", "agent_role_id"},
+			Required: []string{"type", "title"},
+		},
+	},
+	{
+		Name:        "work_split",
+		Description: "Break a story into tasks in one call. All tasks are created or, if any is invalid, none is. Returns the created tasks in order as {created: [{id, short_id, title, status}]}.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"story_id": {Type: "string", Description: "Story to create the tasks under"},
+				"tasks": {Type: "array", Description: "Tasks to create, in order (at most 200)", Items: &propertySchema{
+					Type: "object",
+					Properties: map[string]propertySchema{
+						"title":         {Type: "string", Description: "Title of the task"},
+						"body":          {Type: "string", Description: "Detailed description or instructions for the task"},
+						"agent_role_id": {Type: "string", Description: "Agent role ID. Defaults to the default agent role"},
+						"due_at":        {Type: "string", Description: "Due date as an RFC 3339 timestamp"},
+					},
+					Required: []string{"title"},
+				}},
+			},
+			Required: []string{"story_id", "tasks"},
 		},
 	},
 	{
 		Name:        "work_update",
-		Description: "Update a work item's title, body, or agent role.",
+		Description: "Update a work item's title, body, agent role, effort estimate, or due date.",
 		InputSchema: inputSchema{
 			Type: "object",
 			Properties: map[string]propertySchema{
-				"id":            {Type: "string", Description: "Work item ID"},
-				"title":         {Type: "string", Description: "New title"},
-				"body":          {Type: "string", Description: "New body content"},
-				"agent_role_id": {Type: "string", Description: "New agent role ID"},
+				"id":               {Type: "string", Description: "Work item ID"},
+				"title":            {Type: "string", Description: "New title"},
+				"body":             {Type: "string", Description: "New body content. A structured body (see work_create) is validated"},
+				"agent_role_id":    {Type: "string", Description: "New agent role ID"},
+				"estimate_minutes": {Type: "integer", Description: "Planned effort in minutes (0 clears the estimate)"},
+				"due_at":           {Type: "string", Description: "Due date as an RFC 3339 timestamp (empty string clears it)"},
 			},
 			Required: []string{"id"},
 		},
 	},
 	{
 		Name:        "work_get",
-		Description: "Get a single work item by ID with full details including body.",
+		Description: "Get a single work item by ID with full details including body (and, for a structured body, its metadata, context, acceptance criteria and notes as structured_body), effort, the files its sessions modified, the items it references (#<id> in its body or comments) and that reference it, and for stories the effort rolled up from their tasks.",
 		InputSchema: inputSchema{
 			Type: "object",
 			Properties: map[string]propertySchema{
-				"id": {Type: "string", Description: "Work item ID"},
+				"id": {Type: "string", Description: "Work item ID or short ID"},
+			},
+			Required: []string{"id"},
+		},
+	},
+	{
+		Name:        "work_context",
+		Description: "Get everything needed to start on a work item in one call: the item with the same details as work_get, its parent (with body), the parent's other children (siblings), the item's own children, and the role driving its session with role_prompt and steps. Call this first instead of separate work_get, work_list and agent_role_get calls.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"id": {Type: "string", Description: "Work item ID or short ID"},
 			},
 			Required: []string{"id"},
 		},
@@ -90,7 +164,9 @@ var toolDefinitions = []toolDefinition{
 		InputSchema: inputSchema{
 			Type: "object",
 			Properties: map[string]propertySchema{
-				"id": {Type: "string", Description: "Work item ID to start"},
+				"id":            {Type: "string", Description: "Work item ID to start"},
+				"agent_role_id": {Type: "string", Description: "Optional agent role ID to run this session under instead of the work item's own role. The work item itself is not modified."},
+				"mode":          {Type: "string", Description: "Optional permission mode for the session. Defaults to the configured default mode. \"plan\" starts a read-only planning session: the agent submits a plan with work_plan_submit and execution begins only after the user approves it.", Enum: []string{"default", "yolo", "plan"}},
 			},
 			Required: []string{"id"},
 		},
@@ -107,6 +183,18 @@ var toolDefinitions = []toolDefinition{
 			Required: []string{"id", "reason"},
 		},
 	},
+	{
+		Name:        "work_plan_submit",
+		Description: "Submit your plan for a work item started in plan mode. Transitions from in_progress to needs_input until the user approves (the session then switches to act mode and you receive the execution kickoff) or requests changes.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"id":   {Type: "string", Description: "Work item ID"},
+				"plan": {Type: "string", Description: "The full plan as markdown"},
+			},
+			Required: []string{"id", "plan"},
+		},
+	},
 	{
 		Name:        "work_reopen",
 		Description: "Reopen a closed work item. Transitions from closed to in_progress. Use when you need to add more child work items or continue working on a completed item.",
@@ -118,6 +206,57 @@ var toolDefinitions = []toolDefinition{
 			Required: []string{"id"},
 		},
 	},
+	{
+		Name:        "work_cancel",
+		Description: "Cancel a work item that will not be done (e.g. made obsolete or out of scope). Terminal; a reason is required. Unfinished child tasks are cancelled with it, and the parent is notified. Do not use for finished work — call step_done instead.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"id":     {Type: "string", Description: "Work item ID to cancel"},
+				"reason": {Type: "string", Description: "Why the work is not being done (shown to the user and the parent agent)"},
+			},
+			Required: []string{"id", "reason"},
+		},
+	},
+	{
+		Name:        "work_bulk",
+		Description: "Apply several work operations in one call. update, delete, stop, and cancel are applied in order as one batch; start operations follow. Each operation succeeds or fails on its own: the result lists {id, action, ok, error} per operation in request order.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"operations": {Type: "array", Description: "Operations to apply (at most 200)", Items: &propertySchema{
+					Type: "object",
+					Properties: map[string]propertySchema{
+						"action": {Type: "string", Description: "Operation to apply", Enum: []string{"update", "delete", "stop", "cancel", "start"}},
+						"id":     {Type: "string", Description: "Work item ID"},
+						"fields": {Type: "object", Description: "Fields to change (update only)", Properties: map[string]propertySchema{
+							"title":            {Type: "string", Description: "New title"},
+							"body":             {Type: "string", Description: "New body content"},
+							"agent_role_id":    {Type: "string", Description: "New agent role ID"},
+							"estimate_minutes": {Type: "integer", Description: "Planned effort in minutes"},
+						}},
+						"reason":        {Type: "string", Description: "Why the work is cancelled (cancel only, required)"},
+						"agent_role_id": {Type: "string", Description: "Agent role to run the session under (start only)"},
+						"mode":          {Type: "string", Description: "Permission mode for the session (start only)", Enum: []string{"default", "yolo", "plan"}},
+					},
+					Required: []string{"action", "id"},
+				}},
+			},
+			Required: []string{"operations"},
+		},
+	},
+	{
+		Name:        "work_log_time",
+		Description: "Log time spent on a work item. Call when finishing your work (before step_done) with the minutes you spent; repeated calls add up.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"id":      {Type: "string", Description: "Work item ID"},
+				"minutes": {Type: "integer", Description: "Minutes spent since the last log (must be positive)"},
+			},
+			Required: []string{"id", "minutes"},
+		},
+	},
 	{
 		Name:        "work_wait",
 		Description: "Pause a work item to wait for child work to complete. Transitions from in_progress to waiting. Use when the agent has started child tasks and needs to wait for them to finish before continuing.",
@@ -163,6 +302,18 @@ var toolDefinitions = []toolDefinition{
 			Required: []string{"work_id"},
 		},
 	},
+	{
+		Name:        "attachment_read",
+		Description: "Read a file attached to a work item (design docs, specs, screenshots). Without name, lists the attachments. Text files are returned as-is; for images and other binary files the result gives a path to open with your own file reading tool.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"work_id": {Type: "string", Description: "Work item ID"},
+				"name":    {Type: "string", Description: "Attachment file name, as listed by work_get"},
+			},
+			Required: []string{"work_id"},
+		},
+	},
 	{
 		Name:        "work_comment_update",
 		Description: "Update a comment's body text.",
@@ -175,9 +326,53 @@ var toolDefinitions = []toolDefinition{
 			Required: []string{"id", "body"},
 		},
 	},
+	{
+		Name:        "test_report",
+		Description: "Report structured test results for a work item after running a test suite. Call once per suite run, including when tests fail, so the user sees pass/fail status on the work board.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"work_id": {Type: "string", Description: "Work item ID the tests were run for"},
+				"suite":   {Type: "string", Description: "Suite name or command (e.g. \"go test ./...\", \"web unit\")"},
+				"passed":  {Type: "integer", Description: "Number of passed tests"},
+				"failed":  {Type: "integer", Description: "Number of failed tests"},
+				"skipped": {Type: "integer", Description: "Number of skipped tests"},
+				"failures": {Type: "array", Description: "Failed tests with a short failure message (at most 50 are kept)", Items: &propertySchema{
+					Type: "object",
+					Properties: map[string]propertySchema{
+						"name":    {Type: "string", Description: "Test name"},
+						"message": {Type: "string", Description: "Failure message or assertion output"},
+					},
+					Required: []string{"name"},
+				}},
+			},
+			Required: []string{"work_id", "suite", "passed", "failed"},
+		},
+	},
+	{
+		Name:        "git_status",
+		Description: "Get the git status of your worktree: staged and unstaged files, submodules, and branch ahead/behind. Read-only; use this instead of running git status.",
+		InputSchema: inputSchema{
+			Type:       "object",
+			Properties: map[string]propertySchema{},
+		},
+	},
+	{
+		Name:        "git_diff",
+		Description: "Get the unified diff of your worktree's changes, for one file or all tracked files. Read-only; use this instead of running git diff. A diff over max_bytes is cut at a line boundary and marked truncated; narrow it with path.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"path":            {Type: "string", Description: "File path relative to the worktree root. Omit for every tracked change (untracked files are listed by git_status)"},
+				"staged":          {Type: "boolean", Description: "Diff staged changes against HEAD instead of unstaged changes against the index"},
+				"hide_whitespace": {Type: "boolean", Description: "Ignore whitespace-only changes"},
+				"max_bytes":       {Type: "integer", Description: "Maximum diff size to return (1-262144, default: 65536)"},
+			},
+		},
+	},
 	{
 		Name:        "agent_role_list",
-		Description: "List all available agent roles. Use this to find which roles can be assigned to work items. Use agent_role_get for full details including role_prompt.",
+		Description: "List all available agent roles. Use this to find which roles can be assigned to work items; the default role (used when work_create gets no agent_role_id) is marked default. Use agent_role_get for full details including role_prompt.",
 		InputSchema: inputSchema{
 			Type:       "object",
 			Properties: map[string]propertySchema{},
@@ -194,6 +389,48 @@ var toolDefinitions = []toolDefinition{
 			Required: []string{"id"},
 		},
 	},
+	{
+		Name:        "agent_role_create",
+		Description: "Create an agent role, e.g. a specialist the project needs. Returns the new role's ID for work_create's agent_role_id. MCP servers for a role can only be configured by the user.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"name":                 {Type: "string", Description: "Role name (required)"},
+				"role_prompt":          {Type: "string", Description: "Instructions given to agents working under this role"},
+				"steps":                {Type: "array", Description: "Ordered workflow steps; each step_done advances to the next", Items: &propertySchema{Type: "string"}},
+				"idle_timeout_minutes": {Type: "integer", Description: "Idle timeout for sessions under this role; 0 uses the server default"},
+				"agent_type":           {Type: "string", Description: "Agent backend for sessions under this role; omit to use the default", Enum: []string{"claude", "codex"}},
+			},
+			Required: []string{"name"},
+		},
+	},
+	{
+		Name:        "agent_role_update",
+		Description: "Update an agent role. Only the given fields change; steps replaces the whole list.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"id":                   {Type: "string", Description: "Agent role ID"},
+				"name":                 {Type: "string", Description: "New name"},
+				"role_prompt":          {Type: "string", Description: "New role prompt"},
+				"steps":                {Type: "array", Description: "New workflow steps", Items: &propertySchema{Type: "string"}},
+				"idle_timeout_minutes": {Type: "integer", Description: "New idle timeout; 0 resets to the server default"},
+				"agent_type":           {Type: "string", Description: "New agent backend; empty resets to the default", Enum: []string{"", "claude", "codex"}},
+			},
+			Required: []string{"id"},
+		},
+	},
+	{
+		Name:        "agent_role_delete",
+		Description: "Delete an agent role. Refused while any work item uses the role; reassign those items with work_update first.",
+		InputSchema: inputSchema{
+			Type: "object",
+			Properties: map[string]propertySchema{
+				"id": {Type: "string", Description: "Agent role ID"},
+			},
+			Required: []string{"id"},
+		},
+	},
 	{
 		Name:        "agent_role_reset_defaults",
 		Description: "Reset all agent roles to their default values. This deletes all existing roles and recreates the defaults.",
@@ -203,3 +440,17 @@ var toolDefinitions = []toolDefinition{
 		},
 	},
 }
+
+func isKnownTool(name string) bool {
+	_, ok := findTool(name)
+	return ok
+}
+
+func findTool(name string) (toolDefinition, bool) {
+	for _, t := range toolDefinitions {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return toolDefinition{}, false
+}
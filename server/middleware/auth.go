@@ -6,15 +6,25 @@ import (
 	"strings"
 )
 
+// Auth requires the bearer token to equal token.
 func Auth(token string) func(http.Handler) http.Handler {
+	return AuthFunc(func(t string) bool {
+		return subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1
+	})
+}
+
+// AuthFunc requires a bearer token valid accepts, e.g. the auth token or a
+// paired device's token.
+func AuthFunc(valid func(token string) bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Health check, WebSocket, and the local MCP API bypass this middleware:
-			// WebSocket and the MCP API authenticate themselves (the MCP API uses a
-			// separate, locally-generated token, not the user-facing --auth-token).
-			// Match the MCP route exactly (not a prefix) so any future /api/mcp/*
+			// Health checks, WebSocket, the local MCP API, and the tracker webhook
+			// bypass this middleware: WebSocket and the MCP API authenticate
+			// themselves (the MCP API uses a separate, locally-generated token, not
+			// the user-facing --auth-token), and the webhook checks its secret.
+			// Match the MCP routes exactly (not a prefix) so any future /api/mcp/*
 			// route is auth-protected by default rather than silently exposed.
-			if r.URL.Path == "/health" || r.URL.Path == "/ws" || r.URL.Path == "/api/mcp/tools/call" {
+			if r.URL.Path == "/health" || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/ws" || r.URL.Path == "/api/mcp/tools/call" || r.URL.Path == "/api/mcp/events" || r.URL.Path == "/api/sync/webhook" {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -31,7 +41,7 @@ func Auth(token string) func(http.Handler) http.Handler {
 				return
 			}
 
-			if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(token)) != 1 {
+			if !valid(parts[1]) {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
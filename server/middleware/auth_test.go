@@ -26,12 +26,24 @@ func TestAuth(t *testing.T) {
 			authHeader: "",
 			wantStatus: http.StatusOK,
 		},
+		{
+			name:       "readiness probe bypasses auth",
+			path:       "/readyz",
+			authHeader: "",
+			wantStatus: http.StatusOK,
+		},
 		{
 			name:       "ws bypasses auth",
 			path:       "/ws",
 			authHeader: "",
 			wantStatus: http.StatusOK,
 		},
+		{
+			name:       "sync webhook bypasses auth",
+			path:       "/api/sync/webhook",
+			authHeader: "",
+			wantStatus: http.StatusOK,
+		},
 		{
 			name:       "missing auth header",
 			path:       "/api/ping",
@@ -0,0 +1,172 @@
+package outline
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// extractGo lists top-level declarations. go/parser returns a partial AST for
+// files with syntax errors, so a file mid-edit still gets an outline.
+func extractGo(src []byte) []Symbol {
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if file == nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			sym := Symbol{Name: d.Name.Name, Kind: KindFunction, Line: fset.Position(d.Pos()).Line}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				sym.Kind = KindMethod
+				sym.Container = receiverName(d.Recv.List[0].Type)
+			}
+			symbols = append(symbols, sym)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kind := KindType
+					if _, ok := s.Type.(*ast.InterfaceType); ok {
+						kind = KindInterface
+					}
+					symbols = append(symbols, Symbol{Name: s.Name.Name, Kind: kind, Line: fset.Position(s.Pos()).Line})
+				case *ast.ValueSpec:
+					kind := KindVar
+					if d.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, name := range s.Names {
+						if name.Name == "_" {
+							continue
+						}
+						symbols = append(symbols, Symbol{Name: name.Name, Kind: kind, Line: fset.Position(name.Pos()).Line})
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// receiverName strips pointers and type parameters from a method receiver.
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+var mdHeading = regexp.MustCompile(`^(#{1,6})[ \t]+(.+?)[ \t#]*$`)
+
+// extractMarkdown lists ATX headings, skipping fenced code blocks.
+func extractMarkdown(src []byte) []Symbol {
+	var symbols []Symbol
+	var fence string
+	forEachLine(src, func(n int, line string) {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			return
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			return
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			symbols = append(symbols, Symbol{Name: m[2], Kind: KindHeading, Line: n, Depth: len(m[1]) - 1})
+		}
+	})
+	return symbols
+}
+
+var pyDef = regexp.MustCompile(`^([ \t]*)(?:async[ \t]+)?(def|class)[ \t]+([A-Za-z_]\w*)`)
+
+// extractPython lists classes and functions, nesting by indentation.
+func extractPython(src []byte) []Symbol {
+	type scope struct {
+		indent int
+		name   string
+		class  bool
+	}
+	var symbols []Symbol
+	var stack []scope
+	forEachLine(src, func(n int, line string) {
+		m := pyDef.FindStringSubmatch(line)
+		if m == nil {
+			return
+		}
+		indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		sym := Symbol{Name: m[3], Kind: KindFunction, Line: n, Depth: len(stack)}
+		if m[2] == "class" {
+			sym.Kind = KindClass
+		} else if len(stack) > 0 && stack[len(stack)-1].class {
+			sym.Kind = KindMethod
+		}
+		if len(stack) > 0 {
+			sym.Container = stack[len(stack)-1].name
+		}
+		symbols = append(symbols, sym)
+		stack = append(stack, scope{indent: indent, name: m[3], class: m[2] == "class"})
+	})
+	return symbols
+}
+
+var jsDecls = []struct {
+	re   *regexp.Regexp
+	kind Kind
+}{
+	{regexp.MustCompile(`^(?:export[ \t]+)?(?:default[ \t]+)?(?:async[ \t]+)?function\*?[ \t]+([A-Za-z_$][\w$]*)`), KindFunction},
+	{regexp.MustCompile(`^(?:export[ \t]+)?(?:default[ \t]+)?(?:abstract[ \t]+)?class[ \t]+([A-Za-z_$][\w$]*)`), KindClass},
+	{regexp.MustCompile(`^(?:export[ \t]+)?interface[ \t]+([A-Za-z_$][\w$]*)`), KindInterface},
+	{regexp.MustCompile(`^(?:export[ \t]+)?type[ \t]+([A-Za-z_$][\w$]*)[^=]*=`), KindType},
+	{regexp.MustCompile(`^(?:export[ \t]+)?(?:const|let|var)[ \t]+([A-Za-z_$][\w$]*)[^=]*=[ \t]*(?:async[ \t]+)?(?:function\b|\([^)]*\)[^=]*=>|[A-Za-z_$][\w$]*[ \t]*=>)`), KindFunction},
+}
+
+// extractJS lists top-level declarations in JavaScript and TypeScript.
+// Only unindented lines are considered, which keeps local helpers and
+// control flow out of the outline without tracking braces.
+func extractJS(src []byte) []Symbol {
+	var symbols []Symbol
+	forEachLine(src, func(n int, line string) {
+		for _, d := range jsDecls {
+			if m := d.re.FindStringSubmatch(line); m != nil {
+				symbols = append(symbols, Symbol{Name: m[1], Kind: d.kind, Line: n})
+				return
+			}
+		}
+	})
+	return symbols
+}
+
+// forEachLine calls fn with each line and its 1-based number.
+func forEachLine(src []byte, fn func(n int, line string)) {
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxFileSize)
+	for n := 1; scanner.Scan(); n++ {
+		fn(n, strings.TrimRight(scanner.Text(), "\r"))
+	}
+}
@@ -0,0 +1,167 @@
+// Package outline extracts symbol outlines (functions, types, headings) from
+// source files for jump-to-symbol in the file viewer.
+//
+// Go is parsed with go/parser. Other languages use line-based extractors: they
+// are not full parsers, but need no external grammar and degrade to a shorter
+// outline rather than failing on unusual code.
+package outline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/contents"
+)
+
+var (
+	ErrUnsupported = errors.New("outline not supported for this file type")
+	ErrTooLarge    = errors.New("file too large for outline")
+)
+
+// MaxFileSize bounds the files we outline; larger files are almost always
+// generated code or data where an outline is not useful.
+const MaxFileSize = 2 * 1024 * 1024
+
+type Kind string
+
+const (
+	KindFunction  Kind = "function"
+	KindMethod    Kind = "method"
+	KindClass     Kind = "class"
+	KindType      Kind = "type"
+	KindInterface Kind = "interface"
+	KindConst     Kind = "const"
+	KindVar       Kind = "var"
+	KindHeading   Kind = "heading"
+)
+
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      Kind   `json:"kind"`
+	Line      int    `json:"line"`                // 1-based
+	Depth     int    `json:"depth,omitempty"`     // Nesting level (heading level - 1, methods in a class)
+	Container string `json:"container,omitempty"` // Receiver or enclosing class
+}
+
+// extractor returns the outline of one file's source.
+type extractor func(src []byte) []Symbol
+
+var extractors = map[string]extractor{
+	".go":       extractGo,
+	".md":       extractMarkdown,
+	".markdown": extractMarkdown,
+	".py":       extractPython,
+	".js":       extractJS,
+	".jsx":      extractJS,
+	".mjs":      extractJS,
+	".cjs":      extractJS,
+	".ts":       extractJS,
+	".tsx":      extractJS,
+	".mts":      extractJS,
+	".cts":      extractJS,
+}
+
+// Supported reports whether path has an outline extractor.
+func Supported(path string) bool {
+	_, ok := extractors[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Extract returns the outline of src, choosing the extractor by the file
+// extension of path.
+func Extract(path string, src []byte) ([]Symbol, error) {
+	extract, ok := extractors[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, path)
+	}
+	symbols := extract(src)
+	if symbols == nil {
+		symbols = []Symbol{}
+	}
+	return symbols, nil
+}
+
+// Cache memoizes outlines per file, keyed by path and invalidated when the
+// file's mtime or size changes. Safe for concurrent use.
+type Cache struct {
+	entriesMu  sync.Mutex
+	entries    map[string]cacheEntry
+	maxEntries int
+}
+
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	symbols []Symbol
+}
+
+func NewCache(maxEntries int) *Cache {
+	return &Cache{
+		entries:    make(map[string]cacheEntry),
+		maxEntries: maxEntries,
+	}
+}
+
+// Get returns the outline of path (relative to workDir). Returns
+// contents.ErrInvalidPath for paths escaping workDir, contents.ErrNotFound for
+// missing files, and ErrUnsupported / ErrTooLarge when no outline is produced.
+func (c *Cache) Get(workDir, path string) ([]Symbol, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: empty path", contents.ErrInvalidPath)
+	}
+	if err := contents.ValidatePath(workDir, path); err != nil {
+		return nil, err
+	}
+	if !Supported(path) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, path)
+	}
+
+	fullPath := filepath.Join(workDir, filepath.Clean(path))
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", contents.ErrNotFound, path)
+		}
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%w: %s is a directory", ErrUnsupported, path)
+	}
+	if info.Size() > MaxFileSize {
+		return nil, fmt.Errorf("%w: %s", ErrTooLarge, path)
+	}
+
+	c.entriesMu.Lock()
+	entry, ok := c.entries[fullPath]
+	c.entriesMu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.symbols, nil
+	}
+
+	src, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	symbols, err := Extract(path, src)
+	if err != nil {
+		return nil, err
+	}
+
+	c.entriesMu.Lock()
+	if _, exists := c.entries[fullPath]; !exists && len(c.entries) >= c.maxEntries {
+		// Evict an arbitrary entry: a miss only costs one re-parse.
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[fullPath] = cacheEntry{modTime: info.ModTime(), size: info.Size(), symbols: symbols}
+	c.entriesMu.Unlock()
+
+	return symbols, nil
+}
@@ -0,0 +1,205 @@
+package outline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/contents"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		src  string
+		want []Symbol
+	}{
+		{
+			name: "go declarations",
+			path: "main.go",
+			src: `package main
+
+const Version = "1"
+
+var (
+	a, _ = 1, 2
+)
+
+type Server struct{}
+
+type Store interface{ Get() }
+
+func New() *Server { return nil }
+
+func (s *Server) Start() {}
+
+func (l List[T]) Len() int { return 0 }
+`,
+			want: []Symbol{
+				{Name: "Version", Kind: KindConst, Line: 3},
+				{Name: "a", Kind: KindVar, Line: 6},
+				{Name: "Server", Kind: KindType, Line: 9},
+				{Name: "Store", Kind: KindInterface, Line: 11},
+				{Name: "New", Kind: KindFunction, Line: 13},
+				{Name: "Start", Kind: KindMethod, Line: 15, Container: "Server"},
+				{Name: "Len", Kind: KindMethod, Line: 17, Container: "List"},
+			},
+		},
+		{
+			name: "go with syntax error keeps earlier declarations",
+			path: "broken.go",
+			src: `package main
+
+func Good() {}
+
+func Bad( {
+`,
+			want: []Symbol{
+				{Name: "Good", Kind: KindFunction, Line: 3},
+				{Name: "Bad", Kind: KindFunction, Line: 5},
+			},
+		},
+		{
+			name: "markdown headings skip code fences",
+			path: "README.md",
+			src:  "# Title\n\nText\n\n## Usage ##\n\n```sh\n# not a heading\n```\n\n### Details\n",
+			want: []Symbol{
+				{Name: "Title", Kind: KindHeading, Line: 1},
+				{Name: "Usage", Kind: KindHeading, Line: 5, Depth: 1},
+				{Name: "Details", Kind: KindHeading, Line: 11, Depth: 2},
+			},
+		},
+		{
+			name: "python nesting",
+			path: "app.py",
+			src: `class Handler:
+    def get(self):
+        def inner():
+            pass
+
+async def main():
+    pass
+`,
+			want: []Symbol{
+				{Name: "Handler", Kind: KindClass, Line: 1},
+				{Name: "get", Kind: KindMethod, Line: 2, Depth: 1, Container: "Handler"},
+				{Name: "inner", Kind: KindFunction, Line: 3, Depth: 2, Container: "get"},
+				{Name: "main", Kind: KindFunction, Line: 6},
+			},
+		},
+		{
+			name: "typescript top-level declarations",
+			path: "store.ts",
+			src: `export interface Props { id: string }
+export type Mode = "a" | "b";
+export default function App() {}
+export const useStore = (x: number) => x;
+const LIMIT = 10;
+export abstract class Base {
+  method() {}
+}
+`,
+			want: []Symbol{
+				{Name: "Props", Kind: KindInterface, Line: 1},
+				{Name: "Mode", Kind: KindType, Line: 2},
+				{Name: "App", Kind: KindFunction, Line: 3},
+				{Name: "useStore", Kind: KindFunction, Line: 4},
+				{Name: "Base", Kind: KindClass, Line: 6},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.path, []byte(tt.src))
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtract_Unsupported(t *testing.T) {
+	if _, err := Extract("image.png", nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestCache_Get(t *testing.T) {
+	workDir := t.TempDir()
+	path := filepath.Join(workDir, "doc.md")
+	if err := os.WriteFile(path, []byte("# One\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache(8)
+
+	got, err := c.Get(workDir, "doc.md")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "One" {
+		t.Fatalf("got %+v, want heading One", got)
+	}
+
+	// A rewrite with a new mtime invalidates the cached outline.
+	if err := os.WriteFile(path, []byte("# One\n## Two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	got, err = c.Get(workDir, "doc.md")
+	if err != nil {
+		t.Fatalf("Get after change: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("got %+v, want 2 headings after change", got)
+	}
+}
+
+func TestCache_GetErrors(t *testing.T) {
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "data.bin"), []byte{0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache(8)
+
+	tests := []struct {
+		path string
+		want error
+	}{
+		{"", contents.ErrInvalidPath},
+		{"../outside.go", contents.ErrInvalidPath},
+		{"missing.go", contents.ErrNotFound},
+		{"data.bin", ErrUnsupported},
+	}
+	for _, tt := range tests {
+		if _, err := c.Get(workDir, tt.path); !errors.Is(err, tt.want) {
+			t.Errorf("Get(%q) err = %v, want %v", tt.path, err, tt.want)
+		}
+	}
+}
+
+func TestCache_Evicts(t *testing.T) {
+	workDir := t.TempDir()
+	c := NewCache(2)
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		if err := os.WriteFile(filepath.Join(workDir, name), []byte("# x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Get(workDir, name); err != nil {
+			t.Fatalf("Get(%s): %v", name, err)
+		}
+	}
+	if n := len(c.entries); n != 2 {
+		t.Errorf("cache holds %d entries, want 2", n)
+	}
+}
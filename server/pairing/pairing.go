@@ -0,0 +1,269 @@
+// Package pairing lets a phone connect without typing the permanent auth
+// token. The server mints short-lived signed pairing codes (shown as a QR
+// code); a client exchanges one for a device-scoped token, which it then
+// authenticates with like the permanent token. Devices can be listed and
+// revoked.
+package pairing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pockode/server/filestore"
+)
+
+var (
+	ErrInvalidCode    = errors.New("invalid or expired pairing code")
+	ErrDeviceNotFound = errors.New("device not found")
+)
+
+const (
+	// DefaultCodeTTL is how long a code minted without an explicit TTL,
+	// including the startup QR code, stays valid.
+	DefaultCodeTTL = 10 * time.Minute
+	MaxCodeTTL     = time.Hour
+
+	// URLParam is the query parameter carrying a pairing code in the URL a
+	// QR code encodes.
+	URLParam = "pair"
+
+	maxDeviceName = 64
+	// lastSeenInterval bounds how often Authenticate rewrites the device
+	// file just to move last_seen_at.
+	lastSeenInterval = time.Minute
+
+	nonceSize = 9
+	macSize   = 16
+)
+
+var codeEncoding = base64.RawURLEncoding
+
+// Device is a client paired with a code. Its token is never stored; the
+// device file keeps only its hash.
+type Device struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at,omitzero"`
+}
+
+type deviceRecord struct {
+	Device
+	TokenHash string `json:"token_hash"`
+}
+
+// Code is a minted pairing code.
+type Code struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Manager mints and redeems pairing codes and keeps the paired devices in
+// devices.json. Codes are signed with a key generated per process, so a
+// restart invalidates the outstanding ones; each code pairs one device.
+type Manager struct {
+	file *filestore.File
+	key  []byte
+	now  func() time.Time
+
+	mu      sync.Mutex
+	devices []deviceRecord
+	used    map[string]time.Time // redeemed code → its expiry
+}
+
+func NewManager(dataDir string) (*Manager, error) {
+	f, err := filestore.New(filestore.Config{
+		Path:  filepath.Join(dataDir, "devices.json"),
+		Label: "devices",
+	})
+	if err != nil {
+		return nil, err
+	}
+	devices, err := filestore.Load(f, []deviceRecord{})
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate pairing key: %w", err)
+	}
+	return &Manager{file: f, key: key, now: time.Now, devices: devices, used: make(map[string]time.Time)}, nil
+}
+
+// NewCode mints a pairing code valid for ttl; zero means DefaultCodeTTL.
+func (m *Manager) NewCode(ttl time.Duration) (Code, error) {
+	if ttl == 0 {
+		ttl = DefaultCodeTTL
+	}
+	if ttl < 0 || ttl > MaxCodeTTL {
+		return Code{}, fmt.Errorf("pairing code ttl must be between 0 and %s", MaxCodeTTL)
+	}
+	expires := m.now().Add(ttl).Truncate(time.Second)
+
+	payload := make([]byte, nonceSize+8)
+	if _, err := rand.Read(payload[:nonceSize]); err != nil {
+		return Code{}, fmt.Errorf("generate pairing code: %w", err)
+	}
+	binary.BigEndian.PutUint64(payload[nonceSize:], uint64(expires.Unix()))
+	code := codeEncoding.EncodeToString(payload) + "." + codeEncoding.EncodeToString(m.sign(payload))
+	return Code{Code: code, ExpiresAt: expires}, nil
+}
+
+func (m *Manager) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write(payload)
+	return mac.Sum(nil)[:macSize]
+}
+
+// verify returns the expiry of a correctly signed code.
+func (m *Manager) verify(code string) (time.Time, bool) {
+	p, s, ok := strings.Cut(code, ".")
+	if !ok {
+		return time.Time{}, false
+	}
+	payload, err1 := codeEncoding.DecodeString(p)
+	sig, err2 := codeEncoding.DecodeString(s)
+	if err1 != nil || err2 != nil || len(payload) != nonceSize+8 || !hmac.Equal(sig, m.sign(payload)) {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(payload[nonceSize:])), 0), true
+}
+
+// Pair redeems code for a new device named name and returns the device with
+// its token. The token is returned only here. A code that is malformed,
+// expired, or already redeemed fails with ErrInvalidCode.
+func (m *Manager) Pair(code, name string) (Device, string, error) {
+	expires, ok := m.verify(code)
+	now := m.now()
+	if !ok || !now.Before(expires) {
+		return Device{}, "", ErrInvalidCode
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Device"
+	}
+	if r := []rune(name); len(r) > maxDeviceName {
+		name = string(r[:maxDeviceName])
+	}
+	token, err := newToken()
+	if err != nil {
+		return Device{}, "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for c, exp := range m.used {
+		if !now.Before(exp) {
+			delete(m.used, c)
+		}
+	}
+	if _, redeemed := m.used[code]; redeemed {
+		return Device{}, "", ErrInvalidCode
+	}
+
+	rec := deviceRecord{
+		Device:    Device{ID: uuid.NewString(), Name: name, CreatedAt: now, LastSeenAt: now},
+		TokenHash: hashToken(token),
+	}
+	devices := append(slices.Clone(m.devices), rec)
+	if err := m.file.Persist(devices); err != nil {
+		return Device{}, "", err
+	}
+	m.devices = devices
+	m.used[code] = expires
+	return rec.Device, token, nil
+}
+
+// Authenticate returns the device a token was issued to.
+func (m *Manager) Authenticate(token string) (Device, bool) {
+	if token == "" {
+		return Device{}, false
+	}
+	hash := hashToken(token)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.devices, func(d deviceRecord) bool {
+		return hmac.Equal([]byte(d.TokenHash), []byte(hash))
+	})
+	if i < 0 {
+		return Device{}, false
+	}
+	now := m.now()
+	if now.Sub(m.devices[i].LastSeenAt) >= lastSeenInterval {
+		devices := slices.Clone(m.devices)
+		devices[i].LastSeenAt = now
+		// A failed write only leaves last_seen_at stale.
+		if err := m.file.Persist(devices); err == nil {
+			m.devices = devices
+		}
+	}
+	return m.devices[i].Device, true
+}
+
+// List returns the paired devices, oldest first.
+func (m *Manager) List() []Device {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	devices := make([]Device, len(m.devices))
+	for i, d := range m.devices {
+		devices[i] = d.Device
+	}
+	return devices
+}
+
+// Revoke removes a device; its token stops working immediately.
+func (m *Manager) Revoke(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.devices, func(d deviceRecord) bool { return d.ID == id })
+	if i < 0 {
+		return ErrDeviceNotFound
+	}
+	devices := slices.Delete(slices.Clone(m.devices), i, i+1)
+	if err := m.file.Persist(devices); err != nil {
+		return err
+	}
+	m.devices = devices
+	return nil
+}
+
+// URL returns base with code added as the pair query parameter, the form
+// a QR code encodes.
+func URL(base, code string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	q := u.Query()
+	q.Set(URLParam, code)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate device token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
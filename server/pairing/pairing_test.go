@@ -0,0 +1,101 @@
+package pairing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_PairAndAuthenticate(t *testing.T) {
+	dataDir := t.TempDir()
+	m, err := NewManager(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := m.NewCode(0)
+	if err != nil {
+		t.Fatalf("NewCode: %v", err)
+	}
+	device, token, err := m.Pair(code.Code, "  Phone ")
+	if err != nil {
+		t.Fatalf("Pair: %v", err)
+	}
+	if device.Name != "Phone" || token == "" {
+		t.Fatalf("Pair = %+v, %q", device, token)
+	}
+	if _, _, err := m.Pair(code.Code, "Again"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("second Pair with the same code: err = %v, want ErrInvalidCode", err)
+	}
+
+	if got, ok := m.Authenticate(token); !ok || got.ID != device.ID {
+		t.Errorf("Authenticate = %+v, %v; want the paired device", got, ok)
+	}
+	if _, ok := m.Authenticate("other"); ok {
+		t.Error("an unknown token authenticated")
+	}
+
+	// Devices survive a restart; the token itself is not stored.
+	reopened, err := NewManager(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Authenticate(token); !ok {
+		t.Error("device token rejected after reopening")
+	}
+
+	if err := reopened.Revoke(device.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, ok := reopened.Authenticate(token); ok {
+		t.Error("revoked token still authenticates")
+	}
+	if err := reopened.Revoke(device.ID); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("Revoke twice: err = %v, want ErrDeviceNotFound", err)
+	}
+}
+
+func TestManager_RejectsBadCodes(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	code, err := m.NewCode(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := code.Code[:len(code.Code)-1] + "A"
+	if strings.HasSuffix(code.Code, "A") {
+		tampered = code.Code[:len(code.Code)-1] + "B"
+	}
+	for _, c := range []string{"", "garbage", tampered} {
+		if _, _, err := m.Pair(c, "x"); !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("Pair(%q): err = %v, want ErrInvalidCode", c, err)
+		}
+	}
+
+	// Another server's key does not verify.
+	other, _ := NewManager(t.TempDir())
+	if _, _, err := other.Pair(code.Code, "x"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("code from another server: err = %v, want ErrInvalidCode", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, _, err := m.Pair(code.Code, "x"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expired code: err = %v, want ErrInvalidCode", err)
+	}
+
+	if _, err := m.NewCode(2 * time.Hour); err == nil {
+		t.Error("NewCode accepted a ttl over MaxCodeTTL")
+	}
+}
+
+func TestURL(t *testing.T) {
+	if got := URL("https://abc.example.com/?x=1", "c.d"); got != "https://abc.example.com/?pair=c.d&x=1" {
+		t.Errorf("URL = %q", got)
+	}
+}
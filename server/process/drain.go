@@ -0,0 +1,98 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/pockode/server/session"
+)
+
+// ErrDraining is returned for new processes once a drain has started.
+var ErrDraining = errors.New("server is draining for shutdown")
+
+// drainPollInterval is how often Drain checks for running turns. Turns last
+// seconds to minutes, so sub-second precision is plenty.
+const drainPollInterval = 100 * time.Millisecond
+
+// DrainResult lists the sessions that had a process when a drain finished,
+// by whether their turn completed in time.
+type DrainResult struct {
+	Idle    []string // turn finished (or none was running)
+	Running []string // still mid-turn when the drain timed out
+}
+
+// StartDrain stops the manager from creating processes. Existing processes
+// keep running so their turns can finish; warm ones have no turn and are
+// closed.
+func (m *Manager) StartDrain() {
+	if m.draining.CompareAndSwap(false, true) {
+		slog.Info("process manager draining", "workDir", m.workDir)
+		m.closeWarm()
+	}
+}
+
+// Draining reports whether StartDrain was called.
+func (m *Manager) Draining() bool {
+	return m.draining.Load()
+}
+
+// Drain stops new process creation and waits until no process is running a
+// turn, or ctx is done. Every session that still has a process is then
+// marked as drained in the session store, so clients can offer to resume it
+// after the restart. Processes are left open; Shutdown closes them.
+func (m *Manager) Drain(ctx context.Context) DrainResult {
+	m.StartDrain()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for m.runningCount() > 0 {
+		select {
+		case <-ctx.Done():
+			return m.markDrained()
+		case <-ticker.C:
+		}
+	}
+	return m.markDrained()
+}
+
+func (m *Manager) runningCount() int {
+	m.processesMu.Lock()
+	defer m.processesMu.Unlock()
+	n := 0
+	for _, p := range m.processes {
+		if p.State() == ProcessStateRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// markDrained records the drain marker for every live process.
+func (m *Manager) markDrained() DrainResult {
+	var result DrainResult
+	m.processesMu.Lock()
+	states := make(map[string]ProcessState, len(m.processes))
+	for id, p := range m.processes {
+		states[id] = p.State()
+	}
+	m.processesMu.Unlock()
+
+	// The marker is written with a fresh context: the drain context has
+	// usually expired by now, and the marker matters most in that case.
+	ctx := context.Background()
+	for id, state := range states {
+		marker := session.DrainedIdle
+		if state == ProcessStateRunning {
+			marker = session.DrainedMidTurn
+			result.Running = append(result.Running, id)
+		} else {
+			result.Idle = append(result.Idle, id)
+		}
+		if err := m.sessionStore.SetDrained(ctx, id, marker); err != nil {
+			slog.Warn("failed to mark session drained", "sessionId", id, "error", err)
+		}
+	}
+	return result
+}
@@ -0,0 +1,132 @@
+package process
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Input arbitration. Several clients can drive one session. The client
+// whose message started the current turn owns it until the agent goes idle:
+// its follow-ups go straight to the agent, but a message from another
+// client waits until the turn ends, so two people's prompts never
+// interleave. Messages from the server itself (from == nil: work kickoffs,
+// auto-resume) are never held and own no turn.
+//
+// A client is any comparable value; in practice the sending connection's
+// notifier.
+
+// PendingInput is a message waiting for the current turn to end.
+type PendingInput struct {
+	From     any
+	QueuedAt time.Time
+}
+
+// InputState is a session's arbitration state, reported through
+// SetOnInputChange whenever messages are queued or delivered.
+type InputState struct {
+	SessionID string
+	Owner     any // client whose turn is running; nil = none
+	Pending   []PendingInput
+}
+
+type queuedInput struct {
+	PendingInput
+	send func() error
+}
+
+// SetOnInputChange sets the callback invoked when a session's input queue
+// changes.
+func (m *Manager) SetOnInputChange(fn func(InputState)) {
+	m.onInputChange = fn
+}
+
+func (m *Manager) emitInputChange(s InputState) {
+	if m.onInputChange != nil {
+		m.onInputChange(s)
+	}
+}
+
+// SubmitInput delivers a message from a client by calling send, now or,
+// if another client's turn is running, once it ends. It reports whether
+// the message was queued; a queued message's send error is only logged.
+func (p *Process) SubmitInput(from any, send func() error) (bool, error) {
+	p.inputMu.Lock()
+	if from == nil || p.inputOwner == nil || p.inputOwner == from {
+		if from != nil {
+			p.inputOwner = from
+		}
+		p.inputMu.Unlock()
+		if err := send(); err != nil {
+			p.releaseFailedTurn(from)
+			return false, err
+		}
+		return false, nil
+	}
+	p.inputQueue = append(p.inputQueue, queuedInput{
+		PendingInput: PendingInput{From: from, QueuedAt: time.Now()},
+		send:         send,
+	})
+	state := p.inputStateLocked()
+	p.inputMu.Unlock()
+
+	p.manager.emitInputChange(state)
+	return true, nil
+}
+
+// releaseFailedTurn ends a turn whose first message never reached the
+// agent.
+func (p *Process) releaseFailedTurn(from any) {
+	if from == nil || p.State() == ProcessStateRunning {
+		return
+	}
+	p.endInputTurn()
+}
+
+// endInputTurn hands the turn to the first queued message, if any.
+func (p *Process) endInputTurn() {
+	p.inputMu.Lock()
+	p.inputOwner = nil
+	if len(p.inputQueue) == 0 {
+		p.inputMu.Unlock()
+		return
+	}
+	next := p.inputQueue[0]
+	p.inputQueue = p.inputQueue[1:]
+	p.inputOwner = next.From
+	state := p.inputStateLocked()
+	p.inputMu.Unlock()
+
+	p.manager.emitInputChange(state)
+	// Not on the event stream goroutine that ended the turn: send persists
+	// and broadcasts the message.
+	go func() {
+		if err := next.send(); err != nil {
+			slog.Warn("failed to deliver queued message", "sessionId", p.sessionID, "error", err)
+			p.releaseFailedTurn(next.From)
+		}
+	}()
+}
+
+// dropInput discards queued messages when the process ends; their senders
+// see the queue empty and can send them again.
+func (p *Process) dropInput() {
+	p.inputMu.Lock()
+	dropped := len(p.inputQueue)
+	p.inputOwner = nil
+	p.inputQueue = nil
+	state := p.inputStateLocked()
+	p.inputMu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("dropped queued messages of ended process", "sessionId", p.sessionID, "count", dropped)
+		p.manager.emitInputChange(state)
+	}
+}
+
+func (p *Process) inputStateLocked() InputState {
+	s := InputState{SessionID: p.sessionID, Owner: p.inputOwner}
+	for _, q := range p.inputQueue {
+		s.Pending = append(s.Pending, q.PendingInput)
+	}
+	return s
+}
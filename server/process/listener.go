@@ -12,3 +12,12 @@ type ChatMessage struct {
 type ChatMessageListener interface {
 	OnChatMessage(msg ChatMessage)
 }
+
+// ChatMessageListeners fans each message out to every listener in order.
+type ChatMessageListeners []ChatMessageListener
+
+func (ls ChatMessageListeners) OnChatMessage(msg ChatMessage) {
+	for _, l := range ls {
+		l.OnChatMessage(msg)
+	}
+}
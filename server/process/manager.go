@@ -6,8 +6,10 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/pockode/server/agent"
+	"github.com/pockode/server/audit"
 	"github.com/pockode/server/logger"
 	"github.com/pockode/server/session"
 )
@@ -26,6 +28,9 @@ type StateChangeEvent struct {
 	NeedsInput  bool
 	IsInitial   bool // true only for the initial idle emitted on process creation
 	Interrupted bool // true when idle is caused by user interrupt
+	// Set when idle is caused by a classified agent error
+	// (agent.ErrorCode*), e.g. a rate limit.
+	ErrorCode string
 }
 
 // Manager manages agent processes.
@@ -48,6 +53,57 @@ type Manager struct {
 	// Called when process running state changes
 	onStateChange func(StateChangeEvent)
 
+	// Returns extra environment for new agent processes (e.g. git identity)
+	agentEnv func() []string
+
+	// Returns a per-session idle timeout (e.g. from the agent role); zero
+	// falls back to idleTimeout.
+	idleTimeoutFor func(sessionID string) time.Duration
+
+	// Called once per idle period shortly before a process is reaped
+	onReapWarning func(sessionID string, reapAt time.Time)
+
+	// Returns how long a running process may go without output before it
+	// is reported as possibly stuck; nil or <= 0 disables the watchdog.
+	stuckAfter      func() time.Duration
+	onPossiblyStuck func(sessionID string, silentSince time.Time)
+
+	onInputChange func(InputState)
+
+	// Returns the byte size above which tool results are truncated in
+	// history and notifications; nil or <= 0 disables truncation.
+	toolResultLimit func() int
+
+	// Returns whether thinking events are dropped instead of persisted and
+	// emitted; nil keeps them.
+	hideThinking func() bool
+	onUsage      func(sessionID string, tokens int64)
+
+	// Returns how long a permission request may go unanswered before the
+	// default answer is sent; nil disables the timeout.
+	permissionTimeout func() PermissionTimeout
+
+	// Records auto-policy denials of protected paths; nil discards.
+	auditLog *audit.Log
+
+	// Returns the extra MCP servers for a new session's agent process.
+	mcpServersFor func(sessionID string) []agent.MCPServer
+
+	// Data directory the built-in MCP server runs with; empty = dataDir
+	mcpDataDir string
+
+	// Resolves custom session modes; nil treats every mode as built-in.
+	modePolicy func(session.Mode) ModePolicy
+
+	// Set by StartDrain; rejects new processes with ErrDraining.
+	draining atomic.Bool
+
+	// Pre-started processes waiting for a session (see warm_pool.go).
+	warmPool    func() WarmPoolConfig
+	warmMu      sync.Mutex
+	warm        []*warmProcess
+	warmFilling atomic.Bool
+
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -58,14 +114,33 @@ type Process struct {
 	agentSession agent.Session
 	sessionStore session.Store
 	manager      *Manager // back-reference for broadcasting to subscribers
-
-	mu         sync.Mutex
-	lastActive time.Time
-	state      ProcessState
+	mode         session.Mode
+	policy       ModePolicy // resolved from mode when the process started
+
+	mu           sync.Mutex
+	lastActive   time.Time
+	state        ProcessState
+	reapWarned   bool      // reap warning already sent for the current idle period
+	runningSince time.Time // start of the current turn
+	stuckWarned  bool      // possibly-stuck warning already sent for the current silence
 	// closed is set when the process is explicitly terminated (Close/Shutdown/reap).
 	// Prevents stale buffered events from emitting state changes (e.g. running/idle)
 	// that would incorrectly interact with the AutoResumer.
 	closed atomic.Bool
+	// done is closed once the event stream goroutine has finished, after the
+	// process was removed from the manager.
+	done chan struct{}
+
+	permissionTimersMu sync.Mutex
+	permissionTimers   map[string]*time.Timer // requestID -> pending timeout
+	timedOutRequests   map[string]bool        // requests answered by the timeout
+
+	pendingPermissionsMu sync.Mutex
+	pendingPermissions   []agent.PermissionRequestEvent // unanswered, in arrival order
+
+	inputMu    sync.Mutex
+	inputOwner any           // client whose turn is running (see SubmitInput)
+	inputQueue []queuedInput // other clients' messages, in arrival order
 }
 
 // NewManager creates a new manager with the given idle timeout.
@@ -94,6 +169,60 @@ func (m *Manager) SetOnStateChange(fn func(StateChangeEvent)) {
 	m.onStateChange = fn
 }
 
+// SetAgentEnv sets a provider evaluated at each process start, so settings
+// changes apply to the next process without restarting the server.
+func (m *Manager) SetAgentEnv(fn func() []string) {
+	m.agentEnv = fn
+}
+
+// SetMCPServers sets a provider evaluated at each process start, so worktree
+// and role MCP server changes apply to the next process.
+func (m *Manager) SetMCPServers(fn func(sessionID string) []agent.MCPServer) {
+	m.mcpServersFor = fn
+}
+
+// SetMCPDataDir points the built-in MCP server of new processes at dir
+// instead of the data dir, e.g. a worktree with its own work store.
+func (m *Manager) SetMCPDataDir(dir string) {
+	m.mcpDataDir = dir
+}
+
+// SetIdleTimeoutOverride sets a provider consulted on every reaper pass, so
+// long-running roles can outlive the global idle timeout. Returning zero keeps
+// the default.
+func (m *Manager) SetIdleTimeoutOverride(fn func(sessionID string) time.Duration) {
+	m.idleTimeoutFor = fn
+}
+
+// SetAuditLog sets where permission timeouts refused for protected paths
+// are recorded.
+func (m *Manager) SetAuditLog(l *audit.Log) {
+	m.auditLog = l
+}
+
+// SetOnReapWarning sets the callback invoked when an idle process is within
+// reapWarningLead of being reaped.
+func (m *Manager) SetOnReapWarning(fn func(sessionID string, reapAt time.Time)) {
+	m.onReapWarning = fn
+}
+
+// SetToolResultLimit sets the truncation threshold provider, evaluated per
+// tool result so settings changes apply immediately.
+func (m *Manager) SetToolResultLimit(fn func() int) {
+	m.toolResultLimit = fn
+}
+
+// SetHideThinking sets the provider deciding, per event, whether thinking
+// events are dropped.
+func (m *Manager) SetHideThinking(fn func() bool) {
+	m.hideThinking = fn
+}
+
+// SetOnUsage sets the callback told how many tokens each finished turn used.
+func (m *Manager) SetOnUsage(fn func(sessionID string, tokens int64)) {
+	m.onUsage = fn
+}
+
 func (m *Manager) emitStateChange(sessionID string, state ProcessState, needsInput bool) {
 	if m.onStateChange != nil {
 		m.onStateChange(StateChangeEvent{SessionID: sessionID, State: state, NeedsInput: needsInput})
@@ -126,24 +255,29 @@ func (m *Manager) GetOrCreateProcess(ctx context.Context, sessionID string, resu
 		return proc, false, nil
 	}
 
+	if m.draining.Load() {
+		m.processesMu.Unlock()
+		return nil, false, ErrDraining
+	}
+
 	ag, err := m.agents.Get(agentType)
 	if err != nil {
 		m.processesMu.Unlock()
 		return nil, false, err
 	}
 
-	// Use manager's context for process lifecycle, not request context
-	opts := agent.StartOptions{
-		WorkDir:   m.workDir,
-		DataDir:   m.dataDir,
-		SessionID: sessionID,
-		Resume:    resume,
-		Mode:      mode,
+	opts, policy := m.startOptions(sessionID, resume, mode)
+	var sess agent.Session
+	if !resume {
+		sess = m.bindWarm(sessionID, agentType, opts)
 	}
-	sess, err := ag.Start(m.ctx, opts)
-	if err != nil {
-		m.processesMu.Unlock()
-		return nil, false, err
+	if sess == nil {
+		// Use manager's context for process lifecycle, not request context
+		sess, err = ag.Start(m.ctx, opts)
+		if err != nil {
+			m.processesMu.Unlock()
+			return nil, false, err
+		}
 	}
 
 	proc := &Process{
@@ -151,8 +285,11 @@ func (m *Manager) GetOrCreateProcess(ctx context.Context, sessionID string, resu
 		agentSession: sess,
 		sessionStore: m.sessionStore,
 		manager:      m,
+		mode:         mode,
+		policy:       policy,
 		lastActive:   time.Now(),
 		state:        ProcessStateIdle,
+		done:         make(chan struct{}),
 	}
 	m.processes[sessionID] = proc
 
@@ -162,8 +299,10 @@ func (m *Manager) GetOrCreateProcess(ctx context.Context, sessionID string, resu
 				logger.LogPanic(r, "session crashed", "sessionId", sessionID)
 			}
 			m.remove(sessionID)
+			proc.dropInput()
 			m.emitStateChange(sessionID, ProcessStateEnded, false)
 			slog.Info("process ended", "sessionId", sessionID)
+			close(proc.done)
 		}()
 		proc.streamEvents(m.ctx)
 	}()
@@ -178,6 +317,30 @@ func (m *Manager) GetOrCreateProcess(ctx context.Context, sessionID string, resu
 	return proc, true, nil
 }
 
+// startOptions builds the options a process for sessionID is started with.
+// Providers are evaluated on every call, so the result reflects current
+// settings.
+func (m *Manager) startOptions(sessionID string, resume bool, mode session.Mode) (agent.StartOptions, ModePolicy) {
+	policy := m.resolveMode(mode)
+	opts := agent.StartOptions{
+		WorkDir:            m.workDir,
+		DataDir:            m.dataDir,
+		MCPDataDir:         m.mcpDataDir,
+		SessionID:          sessionID,
+		Resume:             resume,
+		Mode:               policy.Base,
+		SystemPromptSuffix: policy.SystemPromptSuffix,
+		AllowedTools:       policy.AllowedTools,
+	}
+	if m.agentEnv != nil {
+		opts.Env = m.agentEnv()
+	}
+	if m.mcpServersFor != nil {
+		opts.MCPServers = m.mcpServersFor(sessionID)
+	}
+	return opts, policy
+}
+
 // GetProcess returns an existing process or nil.
 // Use this to check if a process is running without creating one.
 func (m *Manager) GetProcess(sessionID string) *Process {
@@ -215,13 +378,16 @@ func (m *Manager) SetOnProcessEnd(callback func()) {
 	m.onProcessEnd = callback
 }
 
-// Touch updates the process's last active time.
-func (m *Manager) Touch(sessionID string) {
+// Touch updates the process's last active time, restarting its idle timer.
+// Reports whether a process was running.
+func (m *Manager) Touch(sessionID string) bool {
 	m.processesMu.Lock()
 	defer m.processesMu.Unlock()
-	if proc, exists := m.processes[sessionID]; exists {
+	proc, exists := m.processes[sessionID]
+	if exists {
 		proc.touch()
 	}
+	return exists
 }
 
 // remove removes a process from the manager and returns it.
@@ -266,6 +432,7 @@ func (m *Manager) Close(sessionID string) {
 // Shutdown closes all processes gracefully.
 func (m *Manager) Shutdown() {
 	m.cancel()
+	m.closeWarm()
 	procs := m.removeWhere(func(*Process) bool { return true })
 	for _, p := range procs {
 		p.closed.Store(true)
@@ -274,6 +441,10 @@ func (m *Manager) Shutdown() {
 	slog.Info("manager shutdown complete", "processesClosed", len(procs))
 }
 
+// reapWarningLead is how long before reaping a warning is sent. Capped at a
+// quarter of the timeout so short timeouts still leave an idle window.
+const reapWarningLead = time.Minute
+
 func (m *Manager) runIdleReaper() {
 	defer func() {
 		if r := recover(); r != nil {
@@ -281,23 +452,75 @@ func (m *Manager) runIdleReaper() {
 		}
 	}()
 
-	ticker := time.NewTicker(m.idleTimeout / 4)
+	// Tick at least once per warning lead so warnings for long timeouts are
+	// still sent before the deadline.
+	ticker := time.NewTicker(min(m.idleTimeout/4, reapWarningLead))
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case now := <-ticker.C:
 			m.reapIdle()
+			m.checkStuck(now)
+			m.refreshWarm(now)
 		case <-m.ctx.Done():
 			return
 		}
 	}
 }
 
+// timeoutFor returns the idle timeout for a session. Keep-alive sessions are
+// never reaped (ok=false).
+func (m *Manager) timeoutFor(sessionID string) (timeout time.Duration, ok bool) {
+	if meta, found, err := m.sessionStore.Get(sessionID); err == nil && found && meta.KeepAlive {
+		return 0, false
+	}
+	if m.idleTimeoutFor != nil {
+		if d := m.idleTimeoutFor(sessionID); d > 0 {
+			return d, true
+		}
+	}
+	return m.idleTimeout, true
+}
+
+type reapWarning struct {
+	sessionID string
+	reapAt    time.Time
+}
+
 func (m *Manager) reapIdle() {
 	now := time.Now()
+
+	// Resolve timeouts outside processesMu: the override and keep-alive lookup
+	// consult other stores, which must not be called under the process lock.
+	m.processesMu.Lock()
+	ids := make([]string, 0, len(m.processes))
+	for id := range m.processes {
+		ids = append(ids, id)
+	}
+	m.processesMu.Unlock()
+
+	timeouts := make(map[string]time.Duration, len(ids))
+	for _, id := range ids {
+		if d, ok := m.timeoutFor(id); ok {
+			timeouts[id] = d
+		}
+	}
+
+	var warnings []reapWarning
 	procs := m.removeWhere(func(p *Process) bool {
-		return now.Sub(p.getLastActive()) > m.idleTimeout
+		timeout, ok := timeouts[p.sessionID]
+		if !ok {
+			return false
+		}
+		idle := now.Sub(p.getLastActive())
+		if idle > timeout {
+			return true
+		}
+		if idle >= timeout-min(reapWarningLead, timeout/4) && p.markReapWarned() {
+			warnings = append(warnings, reapWarning{p.sessionID, p.getLastActive().Add(timeout)})
+		}
+		return false
 	})
 	for _, proc := range procs {
 		proc.closed.Store(true)
@@ -306,6 +529,11 @@ func (m *Manager) reapIdle() {
 		// when the events channel closes — no need to emit here.
 		slog.Info("idle process reaped", "sessionId", proc.sessionID)
 	}
+	if m.onReapWarning != nil {
+		for _, w := range warnings {
+			m.onReapWarning(w.sessionID, w.reapAt)
+		}
+	}
 }
 
 // SendMessage sends a message to the agent and sets running state.
@@ -315,7 +543,13 @@ func (p *Process) SendMessage(prompt string) error {
 }
 
 // SendPermissionResponse sends a permission response and sets running state.
+// Returns ErrPermissionTimedOut if the request was already answered by its
+// timeout.
 func (p *Process) SendPermissionResponse(data agent.PermissionRequestData, choice agent.PermissionChoice) error {
+	if err := p.resolvePermission(data.RequestID); err != nil {
+		return err
+	}
+	p.untrackPermission(data.RequestID)
 	p.SetRunning()
 	return p.agentSession.SendPermissionResponse(data, choice)
 }
@@ -334,9 +568,23 @@ func (p *Process) SendInterrupt() error {
 func (p *Process) touch() {
 	p.mu.Lock()
 	p.lastActive = time.Now()
+	p.reapWarned = false
+	p.stuckWarned = false
 	p.mu.Unlock()
 }
 
+// markReapWarned reports whether this call is the first warning of the
+// current idle period.
+func (p *Process) markReapWarned() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reapWarned {
+		return false
+	}
+	p.reapWarned = true
+	return true
+}
+
 func (p *Process) getLastActive() time.Time {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -357,26 +605,46 @@ func (p *Process) setState(state ProcessState) {
 
 // SetRunning transitions the process to running state and notifies subscribers.
 func (p *Process) SetRunning() {
-	if p.closed.Load() || p.State() == ProcessStateRunning {
+	p.mu.Lock()
+	if p.closed.Load() || p.state == ProcessStateRunning {
+		p.mu.Unlock()
 		return
 	}
-	p.setState(ProcessStateRunning)
+	p.state = ProcessStateRunning
+	p.runningSince = time.Now()
+	p.stuckWarned = false
+	p.mu.Unlock()
 	p.manager.emitStateChange(p.sessionID, ProcessStateRunning, false)
 }
 
 // SetIdle transitions the process to idle state and notifies subscribers.
 // needsInput indicates whether the AI is waiting for user input (permission/question).
 func (p *Process) SetIdle(needsInput bool) {
-	p.setIdle(needsInput, false)
+	p.setIdle(needsInput, false, "")
 }
 
 // SetIdleInterrupted transitions to idle due to a user interrupt.
 func (p *Process) SetIdleInterrupted() {
-	p.setIdle(false, true)
+	p.setIdle(false, true, "")
+}
+
+// setIdleAfterError transitions to idle after an agent error, carrying its
+// classification (empty when unclassified).
+func (p *Process) setIdleAfterError(errorCode string) {
+	p.setIdle(false, false, errorCode)
 }
 
-func (p *Process) setIdle(needsInput, interrupted bool) {
-	if p.closed.Load() || p.State() == ProcessStateIdle {
+func (p *Process) setIdle(needsInput, interrupted bool, errorCode string) {
+	if p.closed.Load() {
+		return
+	}
+	// Waiting on a permission or question answer is still the same turn.
+	// Otherwise it has ended, even when already idle: an interrupt while
+	// waiting on an answer ends it too.
+	if !needsInput {
+		defer p.endInputTurn()
+	}
+	if p.State() == ProcessStateIdle {
 		return
 	}
 	p.setState(ProcessStateIdle)
@@ -385,9 +653,36 @@ func (p *Process) setIdle(needsInput, interrupted bool) {
 		State:       ProcessStateIdle,
 		NeedsInput:  needsInput,
 		Interrupted: interrupted,
+		ErrorCode:   errorCode,
 	})
 }
 
+// truncateToolResult stores an oversized tool result in full and returns a
+// copy cut to the configured limit. If the full output cannot be stored the
+// event is passed through untouched rather than losing data.
+func (p *Process) truncateToolResult(ctx context.Context, e agent.ToolResultEvent) agent.ToolResultEvent {
+	if p.manager.toolResultLimit == nil || e.ToolUseID == "" {
+		return e
+	}
+	limit := p.manager.toolResultLimit()
+	if limit <= 0 || len(e.ToolResult) <= limit {
+		return e
+	}
+
+	if err := p.sessionStore.SaveToolResult(ctx, p.sessionID, e.ToolUseID, e.ToolResult); err != nil {
+		slog.Error("failed to save full tool result", "sessionId", p.sessionID, "toolUseId", e.ToolUseID, "error", err)
+		return e
+	}
+
+	// Cut on a rune boundary so the streamed JSON stays valid UTF-8.
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(e.ToolResult[cut]) {
+		cut--
+	}
+	e.ToolResult, e.FullSize = e.ToolResult[:cut], len(e.ToolResult)
+	return e
+}
+
 // streamEvents routes events to history and emits to the event listener.
 func (p *Process) streamEvents(ctx context.Context) {
 	log := slog.With("sessionId", p.sessionID)
@@ -398,8 +693,33 @@ func (p *Process) streamEvents(ctx context.Context) {
 		eventType := event.EventType()
 		log.Debug("streaming event", "type", eventType)
 
-		// Ensure running state on event (handles edge cases like resumed sessions)
-		p.SetRunning()
+		// Ensure running state on event (handles edge cases like resumed
+		// sessions). Unknown CLI output says nothing about whether a turn is
+		// in progress, so it must not leave an idle process stuck running.
+		if eventType != agent.EventTypeRaw {
+			p.SetRunning()
+		}
+
+		if e, ok := event.(agent.PermissionRequestEvent); ok {
+			if p.answerByMode(e) {
+				continue
+			}
+			e.Group = p.permissionGroup(e)
+			p.trackPermission(e)
+			event = e
+		}
+
+		if tr, ok := event.(agent.ToolResultEvent); ok {
+			event = p.truncateToolResult(ctx, tr)
+		}
+		if done, ok := event.(agent.DoneEvent); ok && done.Tokens > 0 && p.manager.onUsage != nil {
+			p.manager.onUsage(p.sessionID, done.Tokens)
+		}
+		// Still counts as output above, so hidden reasoning keeps the
+		// stuck watchdog and idle reaper quiet.
+		if eventType == agent.EventTypeThinking && p.manager.hideThinking != nil && p.manager.hideThinking() {
+			continue
+		}
 
 		// Persist to history
 		if err := p.sessionStore.AppendToHistory(ctx, p.sessionID, agent.NewEventRecord(event)); err != nil {
@@ -409,6 +729,8 @@ func (p *Process) streamEvents(ctx context.Context) {
 		if eventType.AwaitsUserInput() {
 			if eventType == agent.EventTypeInterrupted {
 				p.SetIdleInterrupted()
+			} else if e, ok := event.(agent.ErrorEvent); ok {
+				p.setIdleAfterError(e.Code)
 			} else {
 				needsInput := eventType == agent.EventTypePermissionRequest ||
 					eventType == agent.EventTypeAskUserQuestion
@@ -421,7 +743,17 @@ func (p *Process) streamEvents(ctx context.Context) {
 
 		// Emit to listener (ChatMessagesWatcher)
 		p.manager.EmitMessage(p.sessionID, event)
+
+		switch e := event.(type) {
+		case agent.PermissionRequestEvent:
+			p.armPermissionTimeout(e)
+		case agent.RequestCancelledEvent:
+			p.cancelPermissionTimeout(e.RequestID)
+			p.untrackPermission(e.RequestID)
+		}
 	}
+	p.stopPermissionTimers()
+	p.clearPendingPermissions()
 
 	log.Info("event stream ended")
 }
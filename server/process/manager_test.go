@@ -2,11 +2,19 @@ package process
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/pockode/server/agent"
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/protected"
 	"github.com/pockode/server/session"
 )
 
@@ -23,16 +31,18 @@ type mockAgent struct {
 }
 
 type startCall struct {
-	sessionID string
-	resume    bool
-	mode      session.Mode
+	sessionID  string
+	resume     bool
+	mode       session.Mode
+	mcpServers []agent.MCPServer
+	opts       agent.StartOptions
 }
 
 func (m *mockAgent) Start(ctx context.Context, opts agent.StartOptions) (agent.Session, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.startCalls = append(m.startCalls, startCall{opts.SessionID, opts.Resume, opts.Mode})
+	m.startCalls = append(m.startCalls, startCall{opts.SessionID, opts.Resume, opts.Mode, opts.MCPServers, opts})
 
 	if m.sessions == nil {
 		m.sessions = make(map[string]*mockSession)
@@ -49,13 +59,28 @@ type mockSession struct {
 	events   chan agent.AgentEvent
 	closed   bool
 	closedMu sync.Mutex
+
+	permissionsMu sync.Mutex
+	permissions   map[string]agent.PermissionChoice // requestID -> choice sent
 }
 
 func (s *mockSession) Events() <-chan agent.AgentEvent { return s.events }
 func (s *mockSession) SendMessage(prompt string) error { return nil }
 func (s *mockSession) SendPermissionResponse(data agent.PermissionRequestData, choice agent.PermissionChoice) error {
+	s.permissionsMu.Lock()
+	defer s.permissionsMu.Unlock()
+	if s.permissions == nil {
+		s.permissions = make(map[string]agent.PermissionChoice)
+	}
+	s.permissions[data.RequestID] = choice
 	return nil
 }
+func (s *mockSession) permissionChoice(requestID string) (agent.PermissionChoice, bool) {
+	s.permissionsMu.Lock()
+	defer s.permissionsMu.Unlock()
+	choice, ok := s.permissions[requestID]
+	return choice, ok
+}
 func (s *mockSession) SendQuestionResponse(data agent.QuestionRequestData, answers map[string]string) error {
 	return nil
 }
@@ -101,6 +126,24 @@ func TestManager_GetOrCreateProcess_NewSession(t *testing.T) {
 	}
 }
 
+func TestManager_MCPServers(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+	m.SetMCPServers(func(sessionID string) []agent.MCPServer {
+		return []agent.MCPServer{{Name: "db-" + sessionID, Command: "db-mcp"}}
+	})
+
+	if _, _, err := m.GetOrCreateProcess(context.Background(), "sess-1", false, session.AgentTypeClaude, session.ModeDefault); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := mock.startCalls[0].mcpServers
+	if len(got) != 1 || got[0].Name != "db-sess-1" {
+		t.Errorf("mcpServers = %+v, want db-sess-1", got)
+	}
+}
+
 func TestManager_GetOrCreateProcess_ExistingSession(t *testing.T) {
 	store, _ := session.NewFileStore(t.TempDir())
 	mock := &mockAgent{}
@@ -199,6 +242,272 @@ func TestManager_Touch_PreventsReaping(t *testing.T) {
 	}
 }
 
+func TestManager_KeepAlive_PreventsReaping(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	idleTimeout := 50 * time.Millisecond
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, idleTimeout)
+	defer m.Shutdown()
+
+	_, _ = store.Create(context.Background(), "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	if err := store.SetKeepAlive(context.Background(), "sess-1", true); err != nil {
+		t.Fatalf("SetKeepAlive: %v", err)
+	}
+	_, _, _ = m.GetOrCreateProcess(context.Background(), "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+
+	time.Sleep(idleTimeout * 3)
+
+	if m.GetProcess("sess-1") == nil {
+		t.Error("expected keep-alive process to survive the reaper")
+	}
+}
+
+func TestManager_IdleTimeoutOverride(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	idleTimeout := 50 * time.Millisecond
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, idleTimeout)
+	defer m.Shutdown()
+
+	m.SetIdleTimeoutOverride(func(sessionID string) time.Duration {
+		if sessionID == "long" {
+			return time.Hour
+		}
+		return 0
+	})
+
+	_, _, _ = m.GetOrCreateProcess(context.Background(), "long", false, session.AgentTypeClaude, session.ModeDefault)
+	_, _, _ = m.GetOrCreateProcess(context.Background(), "short", false, session.AgentTypeClaude, session.ModeDefault)
+
+	time.Sleep(idleTimeout * 3)
+
+	if m.GetProcess("long") == nil {
+		t.Error("expected process with extended timeout to survive")
+	}
+	if m.GetProcess("short") != nil {
+		t.Error("expected process with default timeout to be reaped")
+	}
+}
+
+func TestManager_ReapWarning(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	idleTimeout := 50 * time.Millisecond
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, idleTimeout)
+	defer m.Shutdown()
+
+	var mu sync.Mutex
+	var warned []string
+	var reapAt time.Time
+	m.SetOnReapWarning(func(sessionID string, at time.Time) {
+		mu.Lock()
+		warned = append(warned, sessionID)
+		reapAt = at
+		mu.Unlock()
+	})
+
+	start := time.Now()
+	_, _, _ = m.GetOrCreateProcess(context.Background(), "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+
+	time.Sleep(idleTimeout * 3)
+
+	if m.GetProcess("sess-1") != nil {
+		t.Error("expected process to be reaped after the warning")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(warned) != 1 || warned[0] != "sess-1" {
+		t.Fatalf("warnings = %v, want exactly one for sess-1", warned)
+	}
+	if reapAt.Before(start.Add(idleTimeout)) {
+		t.Errorf("reapAt = %v, want at least %v", reapAt, start.Add(idleTimeout))
+	}
+}
+
+func TestManager_Touch_ReportsRunning(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	if m.Touch("sess-1") {
+		t.Error("expected Touch to report false without a process")
+	}
+	_, _, _ = m.GetOrCreateProcess(context.Background(), "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+	if !m.Touch("sess-1") {
+		t.Error("expected Touch to report true for a running process")
+	}
+}
+
+func TestManager_TruncatesLargeToolResults(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+	m.SetToolResultLimit(func() int { return 8 })
+
+	var mu sync.Mutex
+	var emitted []agent.AgentEvent
+	m.SetMessageListener(chatMessageListenerFunc(func(msg ChatMessage) {
+		mu.Lock()
+		emitted = append(emitted, msg.Event)
+		mu.Unlock()
+	}))
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	_, _, _ = m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+
+	full := "abcdefgé-rest of a long log"
+	mock.sessions["sess-1"].events <- agent.ToolResultEvent{ToolUseID: "tu-1", ToolResult: full}
+	mock.sessions["sess-1"].events <- agent.ToolResultEvent{ToolUseID: "tu-2", ToolResult: "short"}
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(emitted) != 2 {
+		t.Fatalf("emitted %d events, want 2", len(emitted))
+	}
+	got := emitted[0].(agent.ToolResultEvent)
+	// "é" straddles the limit, so the cut backs off to the rune boundary.
+	if got.ToolResult != "abcdefg" || got.FullSize != len(full) {
+		t.Errorf("truncated = %q size = %d, want %q size %d", got.ToolResult, got.FullSize, "abcdefg", len(full))
+	}
+	if short := emitted[1].(agent.ToolResultEvent); short.ToolResult != "short" || short.FullSize != 0 {
+		t.Errorf("short result modified: %+v", short)
+	}
+
+	stored, err := store.GetToolResult(ctx, "sess-1", "tu-1")
+	if err != nil || stored != full {
+		t.Errorf("stored = %q, %v; want full output", stored, err)
+	}
+	if _, err := store.GetToolResult(ctx, "sess-1", "tu-2"); err != session.ErrToolResultNotFound {
+		t.Errorf("expected short result not to be stored, got %v", err)
+	}
+}
+
+func TestManager_OnUsage(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	var usageMu sync.Mutex
+	usage := map[string]int64{}
+	m.SetOnUsage(func(sessionID string, tokens int64) {
+		usageMu.Lock()
+		defer usageMu.Unlock()
+		usage[sessionID] += tokens
+	})
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	_, _, _ = m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+
+	mock.sessions["sess-1"].events <- agent.DoneEvent{Tokens: 120}
+	mock.sessions["sess-1"].events <- agent.DoneEvent{}
+	mock.sessions["sess-1"].events <- agent.DoneEvent{Tokens: 30}
+	time.Sleep(20 * time.Millisecond)
+
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	if usage["sess-1"] != 150 {
+		t.Errorf("usage = %d, want 150", usage["sess-1"])
+	}
+}
+
+func TestManager_HideThinking(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	var hiddenMu sync.Mutex
+	hidden := false
+	m.SetHideThinking(func() bool {
+		hiddenMu.Lock()
+		defer hiddenMu.Unlock()
+		return hidden
+	})
+
+	var emittedMu sync.Mutex
+	var emitted []agent.AgentEvent
+	m.SetMessageListener(chatMessageListenerFunc(func(msg ChatMessage) {
+		emittedMu.Lock()
+		emitted = append(emitted, msg.Event)
+		emittedMu.Unlock()
+	}))
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	_, _, _ = m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+
+	mock.sessions["sess-1"].events <- agent.ThinkingEvent{Content: "visible"}
+	time.Sleep(20 * time.Millisecond)
+	hiddenMu.Lock()
+	hidden = true
+	hiddenMu.Unlock()
+	mock.sessions["sess-1"].events <- agent.ThinkingEvent{Content: "hidden"}
+	mock.sessions["sess-1"].events <- agent.TextEvent{Content: "answer"}
+	time.Sleep(20 * time.Millisecond)
+
+	emittedMu.Lock()
+	defer emittedMu.Unlock()
+	if len(emitted) != 2 {
+		t.Fatalf("emitted %d events, want 2: %+v", len(emitted), emitted)
+	}
+	if th, ok := emitted[0].(agent.ThinkingEvent); !ok || th.Content != "visible" {
+		t.Errorf("emitted[0] = %+v, want visible thinking", emitted[0])
+	}
+
+	history, err := store.GetHistory(ctx, "sess-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || !strings.Contains(string(history[0]), `"type":"thinking"`) {
+		t.Errorf("history = %s, want visible thinking and text only", history)
+	}
+}
+
+func TestManager_UnknownEventPassthrough(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	var emittedMu sync.Mutex
+	var emitted []agent.AgentEvent
+	m.SetMessageListener(chatMessageListenerFunc(func(msg ChatMessage) {
+		emittedMu.Lock()
+		emitted = append(emitted, msg.Event)
+		emittedMu.Unlock()
+	}))
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	proc, _, _ := m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+
+	mock.sessions["sess-1"].events <- agent.RawEvent{Content: `{"type":"new_kind"}`, Kind: "new_kind", Unknown: true}
+	time.Sleep(20 * time.Millisecond)
+
+	if proc.State() != ProcessStateIdle {
+		t.Errorf("state = %s, want idle: unknown output must not start a turn", proc.State())
+	}
+	emittedMu.Lock()
+	if len(emitted) != 1 {
+		t.Errorf("emitted %d events, want 1", len(emitted))
+	}
+	emittedMu.Unlock()
+	history, err := store.GetHistory(ctx, "sess-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || !strings.Contains(string(history[0]), `"unknown":true`) || !strings.Contains(string(history[0]), `"kind":"new_kind"`) {
+		t.Errorf("history = %s, want the raw event flagged unknown", history)
+	}
+}
+
 func TestManager_Shutdown_ClosesAllProcesses(t *testing.T) {
 	store, _ := session.NewFileStore(t.TempDir())
 	mock := &mockAgent{}
@@ -416,3 +725,562 @@ func TestProcess_SendMessage_SetsRunning(t *testing.T) {
 		t.Errorf("expected running event after SendMessage, got %v", events)
 	}
 }
+
+func TestProcess_PermissionTimeout(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+	m.SetPermissionTimeout(func() PermissionTimeout {
+		return PermissionTimeout{After: 30 * time.Millisecond, AllowReadOnly: true}
+	})
+
+	var mu sync.Mutex
+	var responses []agent.PermissionResponseEvent
+	m.SetMessageListener(chatMessageListenerFunc(func(msg ChatMessage) {
+		if e, ok := msg.Event.(agent.PermissionResponseEvent); ok {
+			mu.Lock()
+			responses = append(responses, e)
+			mu.Unlock()
+		}
+	}))
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	proc, _, _ := m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+	sess := mock.sessions["sess-1"]
+
+	sess.events <- agent.PermissionRequestEvent{RequestID: "bash", ToolName: "Bash"}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "read", ToolName: "Read"}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "answered", ToolName: "Bash"}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "cancelled", ToolName: "Bash"}
+	sess.events <- agent.RequestCancelledEvent{RequestID: "cancelled"}
+	time.Sleep(10 * time.Millisecond)
+	if err := proc.SendPermissionResponse(agent.PermissionRequestData{RequestID: "answered"}, agent.PermissionAllow); err != nil {
+		t.Fatalf("answer before timeout: %v", err)
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	if choice, ok := sess.permissionChoice("bash"); !ok || choice != agent.PermissionDeny {
+		t.Errorf("bash choice = %v, %v; want deny", choice, ok)
+	}
+	if choice, ok := sess.permissionChoice("read"); !ok || choice != agent.PermissionAllow {
+		t.Errorf("read choice = %v, %v; want allow for a read-only tool", choice, ok)
+	}
+	if choice, _ := sess.permissionChoice("answered"); choice != agent.PermissionAllow {
+		t.Errorf("answered choice = %v, want the user's allow", choice)
+	}
+	if _, ok := sess.permissionChoice("cancelled"); ok {
+		t.Error("cancelled request was answered by the timeout")
+	}
+
+	mu.Lock()
+	if len(responses) != 2 {
+		t.Errorf("timed-out responses = %+v, want 2", responses)
+	}
+	for _, r := range responses {
+		if !r.TimedOut {
+			t.Errorf("response %+v lacks the timed-out marker", r)
+		}
+	}
+	mu.Unlock()
+
+	// A late answer is rejected rather than sent a second time.
+	err := proc.SendPermissionResponse(agent.PermissionRequestData{RequestID: "bash"}, agent.PermissionAllow)
+	if err != ErrPermissionTimedOut {
+		t.Errorf("late answer err = %v, want ErrPermissionTimedOut", err)
+	}
+
+	history, err := store.GetHistory(ctx, "sess-1")
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	timedOut := 0
+	for _, raw := range history {
+		var rec agent.EventRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			t.Fatalf("unmarshal history: %v", err)
+		}
+		if rec.Type == agent.EventTypePermissionResponse && rec.TimedOut {
+			timedOut++
+		}
+	}
+	if timedOut != 2 {
+		t.Errorf("history has %d timed-out responses, want 2", timedOut)
+	}
+}
+
+func TestProcess_PermissionGroup(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/work", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	var requestsMu sync.Mutex
+	groups := make(map[string]string)
+	var responses []agent.PermissionResponseEvent
+	m.SetMessageListener(chatMessageListenerFunc(func(msg ChatMessage) {
+		requestsMu.Lock()
+		defer requestsMu.Unlock()
+		switch e := msg.Event.(type) {
+		case agent.PermissionRequestEvent:
+			groups[e.RequestID] = e.Group
+		case agent.PermissionResponseEvent:
+			responses = append(responses, e)
+		}
+	}))
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	proc, _, _ := m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+	sess := mock.sessions["sess-1"]
+
+	sess.events <- agent.PermissionRequestEvent{RequestID: "edit-a", ToolName: "Edit", ToolInput: json.RawMessage(`{"file_path":"/work/src/a.go"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "edit-b", ToolName: "Edit", ToolInput: json.RawMessage(`{"file_path":"src/b.go"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "edit-other", ToolName: "Edit", ToolInput: json.RawMessage(`{"file_path":"/work/docs/c.md"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "write-a", ToolName: "Write", ToolInput: json.RawMessage(`{"file_path":"/work/src/d.go"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "npm-1", ToolName: "Bash", ToolInput: json.RawMessage(`{"command":"npm install"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "npm-2", ToolName: "Bash", ToolInput: json.RawMessage(`{"command":"npm test"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "edit-c", ToolName: "Edit", ToolInput: json.RawMessage(`{"file_path":"/work/src/e.go"}`)}
+	sess.events <- agent.RequestCancelledEvent{RequestID: "edit-c"}
+	time.Sleep(20 * time.Millisecond)
+
+	requestsMu.Lock()
+	if groups["edit-a"] != "Edit:src" || groups["edit-b"] != "Edit:src" {
+		t.Errorf("edit groups = %q, %q; want Edit:src", groups["edit-a"], groups["edit-b"])
+	}
+	if groups["edit-other"] == groups["edit-a"] || groups["write-a"] == groups["edit-a"] {
+		t.Errorf("different directory or tool grouped together: %v", groups)
+	}
+	if groups["npm-1"] != "Bash:npm" || groups["npm-2"] != "Bash:npm" {
+		t.Errorf("bash groups = %q, %q; want Bash:npm", groups["npm-1"], groups["npm-2"])
+	}
+	requestsMu.Unlock()
+
+	answered, err := proc.SendPermissionGroupResponse(agent.PermissionRequestData{RequestID: "edit-a"}, agent.PermissionAllow)
+	if err != nil {
+		t.Fatalf("SendPermissionGroupResponse: %v", err)
+	}
+	if len(answered) != 1 || answered[0] != "edit-b" {
+		t.Errorf("answered = %v, want [edit-b]", answered)
+	}
+	for _, id := range []string{"edit-a", "edit-b"} {
+		if choice, ok := sess.permissionChoice(id); !ok || choice != agent.PermissionAllow {
+			t.Errorf("%s choice = %v, %v; want allow", id, choice, ok)
+		}
+	}
+	for _, id := range []string{"edit-other", "write-a", "npm-1", "edit-c"} {
+		if _, ok := sess.permissionChoice(id); ok {
+			t.Errorf("%s answered outside its group", id)
+		}
+	}
+
+	requestsMu.Lock()
+	if len(responses) != 1 || responses[0].RequestID != "edit-b" || responses[0].AppliedFrom != "edit-a" || responses[0].Choice != "allow" {
+		t.Errorf("responses = %+v, want edit-b applied from edit-a", responses)
+	}
+	requestsMu.Unlock()
+
+	answered, err = proc.SendPermissionGroupResponse(agent.PermissionRequestData{RequestID: "npm-1"}, agent.PermissionDeny)
+	if err != nil || len(answered) != 1 || answered[0] != "npm-2" {
+		t.Errorf("npm group answered = %v, %v", answered, err)
+	}
+}
+
+func TestProcess_ModePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		base         session.Mode
+		wantEdit     agent.PermissionChoice
+		readAnswered bool // Read answered by the server rather than left to the user
+		readChoice   agent.PermissionChoice
+	}{
+		{name: "default base asks for allowed tools", base: session.ModeDefault, wantEdit: agent.PermissionDeny},
+		{name: "yolo base allows allowed tools", base: session.ModeYolo, wantEdit: agent.PermissionDeny, readAnswered: true, readChoice: agent.PermissionAllow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := session.NewFileStore(t.TempDir())
+			mock := &mockAgent{}
+			m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+			defer m.Shutdown()
+			m.SetModePolicy(func(mode session.Mode) ModePolicy {
+				if mode != "review" {
+					return ModePolicy{Base: mode}
+				}
+				return ModePolicy{Base: tt.base, SystemPromptSuffix: "Review only.", AllowedTools: []string{"Read", "mcp__pockode__*"}}
+			})
+
+			var responsesMu sync.Mutex
+			var responses []agent.PermissionResponseEvent
+			m.SetMessageListener(chatMessageListenerFunc(func(msg ChatMessage) {
+				if e, ok := msg.Event.(agent.PermissionResponseEvent); ok {
+					responsesMu.Lock()
+					responses = append(responses, e)
+					responsesMu.Unlock()
+				}
+			}))
+
+			ctx := context.Background()
+			_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, "review")
+			if _, _, err := m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, "review"); err != nil {
+				t.Fatalf("GetOrCreateProcess: %v", err)
+			}
+			opts := mock.startCalls[0].opts
+			if opts.Mode != tt.base || opts.SystemPromptSuffix != "Review only." || len(opts.AllowedTools) != 2 {
+				t.Errorf("start options = %+v, want the resolved policy", opts)
+			}
+
+			sess := mock.sessions["sess-1"]
+			sess.events <- agent.PermissionRequestEvent{RequestID: "edit", ToolName: "Edit"}
+			sess.events <- agent.PermissionRequestEvent{RequestID: "read", ToolName: "Read"}
+			sess.events <- agent.PermissionRequestEvent{RequestID: "work", ToolName: "mcp__pockode__work_list"}
+			time.Sleep(20 * time.Millisecond)
+
+			if choice, ok := sess.permissionChoice("edit"); !ok || choice != tt.wantEdit {
+				t.Errorf("edit choice = %v, %v; want %v", choice, ok, tt.wantEdit)
+			}
+			choice, ok := sess.permissionChoice("read")
+			if ok != tt.readAnswered || (ok && choice != tt.readChoice) {
+				t.Errorf("read choice = %v, %v; want answered=%v", choice, ok, tt.readAnswered)
+			}
+
+			responsesMu.Lock()
+			defer responsesMu.Unlock()
+			for _, r := range responses {
+				if r.ByMode != "review" {
+					t.Errorf("response %+v lacks the mode marker", r)
+				}
+			}
+			if len(responses) == 0 || responses[0].RequestID != "edit" {
+				t.Errorf("responses = %+v, want the edit denial first", responses)
+			}
+		})
+	}
+}
+
+func TestProcess_ModePolicyYoloDeniesProtectedPaths(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/work", "", store, 10*time.Minute)
+	defer m.Shutdown()
+	auditDir := t.TempDir()
+	m.SetAuditLog(audit.NewLog(auditDir))
+	m.SetModePolicy(func(mode session.Mode) ModePolicy {
+		return ModePolicy{Base: session.ModeYolo, AllowedTools: []string{"Write", "Edit"}, Protected: protected.Patterns{".env"}}
+	})
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, "builder")
+	_, _, _ = m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, "builder")
+	sess := mock.sessions["sess-1"]
+
+	sess.events <- agent.PermissionRequestEvent{RequestID: "env", ToolName: "Edit", ToolInput: json.RawMessage(`{"file_path":"/work/app/.env"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "plain", ToolName: "Write", ToolInput: json.RawMessage(`{"file_path":"/work/main.go"}`)}
+	time.Sleep(30 * time.Millisecond)
+
+	want := map[string]agent.PermissionChoice{"env": agent.PermissionDeny, "plain": agent.PermissionAllow}
+	for id, choice := range want {
+		if got, ok := sess.permissionChoice(id); !ok || got != choice {
+			t.Errorf("%s choice = %v, %v; want %v", id, got, ok, choice)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(auditDir, audit.Filename))
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 || !strings.Contains(string(data), `"action":"permission.mode"`) || !strings.Contains(string(data), `"path":"app/.env"`) {
+		t.Errorf("audit log = %s, want one permission.mode event for app/.env", data)
+	}
+}
+
+func TestProcess_PermissionTimeoutProtectedPath(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/work", "", store, 10*time.Minute)
+	defer m.Shutdown()
+	auditDir := t.TempDir()
+	m.SetAuditLog(audit.NewLog(auditDir))
+	m.SetPermissionTimeout(func() PermissionTimeout {
+		return PermissionTimeout{
+			After:         20 * time.Millisecond,
+			AllowReadOnly: true,
+			Protected:     protected.Patterns{".env", "secrets/**"},
+		}
+	})
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	_, _, _ = m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+	sess := mock.sessions["sess-1"]
+
+	sess.events <- agent.PermissionRequestEvent{RequestID: "env", ToolName: "Read", ToolInput: json.RawMessage(`{"file_path":"/work/app/.env"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "secrets", ToolName: "Grep", ToolInput: json.RawMessage(`{"pattern":"key","path":"secrets"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "outside", ToolName: "Read", ToolInput: json.RawMessage(`{"file_path":"/other/.env"}`)}
+	sess.events <- agent.PermissionRequestEvent{RequestID: "plain", ToolName: "Read", ToolInput: json.RawMessage(`{"file_path":"/work/main.go"}`)}
+	time.Sleep(80 * time.Millisecond)
+
+	want := map[string]agent.PermissionChoice{
+		"env":     agent.PermissionDeny,
+		"secrets": agent.PermissionDeny,
+		"outside": agent.PermissionAllow,
+		"plain":   agent.PermissionAllow,
+	}
+	for id, choice := range want {
+		if got, ok := sess.permissionChoice(id); !ok || got != choice {
+			t.Errorf("%s choice = %v, %v; want %v", id, got, ok, choice)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(auditDir, audit.Filename))
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("audit log has %d events, want 2:\n%s", lines, data)
+	}
+	if !strings.Contains(string(data), `"path":"app/.env"`) || !strings.Contains(string(data), `"session_id":"sess-1"`) {
+		t.Errorf("audit log = %s", data)
+	}
+}
+
+func TestProcess_PermissionTimeoutDisabled(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+	m.SetPermissionTimeout(func() PermissionTimeout { return PermissionTimeout{} })
+
+	ctx := context.Background()
+	_, _ = store.Create(ctx, "sess-1", session.AgentTypeClaude, session.ModeDefault)
+	_, _, _ = m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+	sess := mock.sessions["sess-1"]
+
+	sess.events <- agent.PermissionRequestEvent{RequestID: "bash", ToolName: "Bash"}
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := sess.permissionChoice("bash"); ok {
+		t.Error("request answered with the timeout disabled")
+	}
+}
+
+type chatMessageListenerFunc func(ChatMessage)
+
+func (f chatMessageListenerFunc) OnChatMessage(msg ChatMessage) { f(msg) }
+
+func TestManager_Drain(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	store.Create(context.Background(), "busy", "", "")
+	store.Create(context.Background(), "quiet", "", "")
+	m := NewManager(mockRegistry(&mockAgent{}), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	busy, _, _ := m.GetOrCreateProcess(context.Background(), "busy", false, session.AgentTypeClaude, session.ModeDefault)
+	m.GetOrCreateProcess(context.Background(), "quiet", false, session.AgentTypeClaude, session.ModeDefault)
+	busy.SetRunning()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	result := m.Drain(ctx)
+
+	if len(result.Running) != 1 || result.Running[0] != "busy" {
+		t.Errorf("running = %v, want [busy]", result.Running)
+	}
+	if len(result.Idle) != 1 || result.Idle[0] != "quiet" {
+		t.Errorf("idle = %v, want [quiet]", result.Idle)
+	}
+	if meta, _, _ := store.Get("busy"); meta.Drained != session.DrainedMidTurn {
+		t.Errorf("busy marker = %q, want %q", meta.Drained, session.DrainedMidTurn)
+	}
+	if meta, _, _ := store.Get("quiet"); meta.Drained != session.DrainedIdle {
+		t.Errorf("quiet marker = %q, want %q", meta.Drained, session.DrainedIdle)
+	}
+
+	// Existing processes stay reachable; new ones are refused.
+	if _, _, err := m.GetOrCreateProcess(context.Background(), "busy", true, session.AgentTypeClaude, session.ModeDefault); err != nil {
+		t.Errorf("existing process during drain: %v", err)
+	}
+	if _, _, err := m.GetOrCreateProcess(context.Background(), "new", false, session.AgentTypeClaude, session.ModeDefault); !errors.Is(err, ErrDraining) {
+		t.Errorf("new process during drain: err = %v, want ErrDraining", err)
+	}
+}
+
+func TestManager_Drain_WaitsForTurn(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	store.Create(context.Background(), "sess-1", "", "")
+	m := NewManager(mockRegistry(&mockAgent{}), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	proc, _, _ := m.GetOrCreateProcess(context.Background(), "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+	proc.SetRunning()
+	time.AfterFunc(50*time.Millisecond, func() { proc.SetIdle(false) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	result := m.Drain(ctx)
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("drain took %v, want it to return once the turn finished", elapsed)
+	}
+	if len(result.Idle) != 1 || len(result.Running) != 0 {
+		t.Errorf("result = %+v, want sess-1 idle", result)
+	}
+}
+
+func TestManager_CheckStuck(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	var stuck []string
+	m.SetStuckAfter(func() time.Duration { return 5 * time.Minute })
+	m.SetOnPossiblyStuck(func(sessionID string, _ time.Time) { stuck = append(stuck, sessionID) })
+
+	ctx := context.Background()
+	running, _, _ := m.GetOrCreateProcess(ctx, "running", false, session.AgentTypeClaude, session.ModeDefault)
+	_, _, _ = m.GetOrCreateProcess(ctx, "idle", false, session.AgentTypeClaude, session.ModeDefault)
+	running.SetRunning()
+
+	now := time.Now()
+	m.checkStuck(now.Add(time.Minute))
+	if len(stuck) != 0 {
+		t.Fatalf("reported before the threshold: %v", stuck)
+	}
+	m.checkStuck(now.Add(6 * time.Minute))
+	m.checkStuck(now.Add(7 * time.Minute))
+	if len(stuck) != 1 || stuck[0] != "running" {
+		t.Fatalf("stuck = %v, want one report for the running process", stuck)
+	}
+
+	// Output ends the silence and re-arms the watchdog.
+	running.touch()
+	m.checkStuck(now.Add(2 * time.Minute))
+	if len(stuck) != 1 {
+		t.Errorf("reported again right after output: %v", stuck)
+	}
+	m.checkStuck(time.Now().Add(6 * time.Minute))
+	if len(stuck) != 2 {
+		t.Errorf("stuck = %v, want a second report after another silence", stuck)
+	}
+}
+
+func TestManager_CloseAndWait(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	mock := &mockAgent{}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	var mu sync.Mutex
+	var states []ProcessState
+	m.SetOnStateChange(func(e StateChangeEvent) {
+		mu.Lock()
+		states = append(states, e.State)
+		mu.Unlock()
+	})
+
+	ctx := context.Background()
+	_, _, _ = m.GetOrCreateProcess(ctx, "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+	if err := m.CloseAndWait("sess-1"); err != nil {
+		t.Fatalf("CloseAndWait: %v", err)
+	}
+	// The old stream has fully ended, so it can't remove the replacement.
+	_, created, _ := m.GetOrCreateProcess(ctx, "sess-1", true, session.AgentTypeClaude, session.ModeDefault)
+	if !created || !m.HasProcess("sess-1") {
+		t.Fatal("replacement process missing")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []ProcessState{ProcessStateIdle, ProcessStateEnded, ProcessStateIdle}; !slices.Equal(states, want) {
+		t.Errorf("states = %v, want %v", states, want)
+	}
+
+	if err := m.CloseAndWait("missing"); err != nil {
+		t.Errorf("CloseAndWait without a process: %v", err)
+	}
+}
+
+func TestProcess_InputArbitration(t *testing.T) {
+	store, _ := session.NewFileStore(t.TempDir())
+	m := NewManager(mockRegistry(&mockAgent{}), "/tmp", "", store, 10*time.Minute)
+	defer m.Shutdown()
+
+	var mu sync.Mutex
+	var states []InputState
+	var sent []string
+	m.SetOnInputChange(func(s InputState) {
+		mu.Lock()
+		defer mu.Unlock()
+		states = append(states, s)
+	})
+	proc, _, _ := m.GetOrCreateProcess(context.Background(), "sess-1", false, session.AgentTypeClaude, session.ModeDefault)
+	submit := func(from any, content string) bool {
+		t.Helper()
+		queued, err := proc.SubmitInput(from, func() error {
+			mu.Lock()
+			sent = append(sent, content)
+			mu.Unlock()
+			return proc.SendMessage(content)
+		})
+		if err != nil {
+			t.Fatalf("SubmitInput(%s): %v", content, err)
+		}
+		return queued
+	}
+	lastState := func() InputState {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(states) == 0 {
+			return InputState{}
+		}
+		return states[len(states)-1]
+	}
+
+	// A's message starts a turn; A's follow-up and the server's messages go
+	// straight through, B's waits.
+	if submit("A", "a1") || submit("A", "a2") || submit(nil, "kickoff") {
+		t.Fatal("owner or server message was queued")
+	}
+	if !submit("B", "b1") {
+		t.Fatal("other client's message was not queued")
+	}
+	if s := lastState(); s.Owner != "A" || len(s.Pending) != 1 || s.Pending[0].From != "B" {
+		t.Errorf("state = %+v, want A's turn with B pending", s)
+	}
+
+	// Waiting on a permission answer is still A's turn.
+	proc.SetIdle(true)
+	proc.SetRunning()
+	proc.SetIdle(false)
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		mu.Lock()
+		n := len(sent)
+		mu.Unlock()
+		if n == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queued message not delivered; sent = %v", sent)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := lastState(); s.Owner != "B" || len(s.Pending) != 0 {
+		t.Errorf("state = %+v, want B's turn and nothing pending", s)
+	}
+	if sent[3] != "b1" {
+		t.Errorf("sent = %v, want b1 last", sent)
+	}
+
+	// Queued messages are dropped with the process.
+	if !submit("A", "a3") {
+		t.Fatal("A's message during B's turn was not queued")
+	}
+	m.Close("sess-1")
+	<-proc.done
+	if s := lastState(); len(s.Pending) != 0 {
+		t.Errorf("state = %+v, want the queue emptied", s)
+	}
+}
@@ -0,0 +1,85 @@
+package process
+
+import (
+	"log/slog"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/protected"
+	"github.com/pockode/server/session"
+)
+
+// ModePolicy is what a session mode means for its agent process. Built-in
+// modes have no suffix or tool restriction.
+type ModePolicy struct {
+	Base               session.Mode // built-in mode passed to the agent
+	SystemPromptSuffix string
+	AllowedTools       []string // empty = no restriction
+	// Protected paths are never allowed by a yolo base: a request whose
+	// target matches is denied and recorded in the audit log.
+	Protected protected.Patterns
+}
+
+// SetModePolicy sets the resolver for custom session modes, evaluated when
+// a process starts. Without one, every mode is passed through as built-in.
+func (m *Manager) SetModePolicy(fn func(session.Mode) ModePolicy) {
+	m.modePolicy = fn
+}
+
+func (m *Manager) resolveMode(mode session.Mode) ModePolicy {
+	if m.modePolicy == nil {
+		return ModePolicy{Base: mode}
+	}
+	return m.modePolicy(mode)
+}
+
+// answerByMode answers a permission request from the mode's allowed-tools
+// list, so the restriction holds whoever is watching: tools outside the list
+// are denied, and with a yolo base the rest are allowed since the agent was
+// started with prompts on, except for protected paths. Reports false when
+// the user should decide.
+func (p *Process) answerByMode(e agent.PermissionRequestEvent) bool {
+	if len(p.policy.AllowedTools) == 0 {
+		return false
+	}
+	choice, choiceName := agent.PermissionDeny, "deny"
+	if agent.ToolAllowed(p.policy.AllowedTools, e.ToolName) {
+		if p.policy.Base != session.ModeYolo {
+			return false
+		}
+		if rel, pattern, ok := p.protectedToolPath(e.ToolInput, p.policy.Protected); ok {
+			p.manager.auditLog.Record(audit.Event{
+				Action:    "permission.mode",
+				Path:      rel,
+				Pattern:   pattern,
+				SessionID: p.sessionID,
+				Detail:    "denied " + e.ToolName + " in mode " + string(p.mode),
+			})
+		} else {
+			choice, choiceName = agent.PermissionAllow, "allow"
+		}
+	}
+
+	log := slog.With("sessionId", p.sessionID, "requestId", e.RequestID, "tool", e.ToolName, "mode", p.mode, "choice", choiceName)
+	data := agent.PermissionRequestData{
+		RequestID:             e.RequestID,
+		ToolInput:             e.ToolInput,
+		ToolUseID:             e.ToolUseID,
+		PermissionSuggestions: e.PermissionSuggestions,
+	}
+	if err := p.agentSession.SendPermissionResponse(data, choice); err != nil {
+		log.Error("failed to send mode policy permission response", "error", err)
+		// Leave it to the user rather than stalling the agent.
+		return false
+	}
+	log.Info("permission request answered by mode policy")
+
+	// Recorded in full so history shows what the agent tried.
+	for _, event := range []agent.AgentEvent{e, agent.PermissionResponseEvent{RequestID: e.RequestID, Choice: choiceName, ByMode: p.mode}} {
+		if err := p.sessionStore.AppendToHistory(p.manager.ctx, p.sessionID, agent.NewEventRecord(event)); err != nil {
+			log.Error("failed to append to history", "error", err)
+		}
+		p.manager.EmitMessage(p.sessionID, event)
+	}
+	return true
+}
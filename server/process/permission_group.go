@@ -0,0 +1,152 @@
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/pockode/server/agent"
+)
+
+// permissionGroup returns the key shared by requests a user would likely
+// answer the same way: the same tool on the same directory, the same
+// command for shells, the same host for fetches. Other tools only group
+// with exact duplicates.
+func (p *Process) permissionGroup(e agent.PermissionRequestEvent) string {
+	var input struct {
+		FilePath     string `json:"file_path"`
+		Path         string `json:"path"`
+		NotebookPath string `json:"notebook_path"`
+		Command      string `json:"command"`
+		URL          string `json:"url"`
+	}
+	if len(e.ToolInput) > 0 {
+		// Unknown shapes fall through to the exact-duplicate key.
+		_ = json.Unmarshal(e.ToolInput, &input)
+	}
+
+	for _, path := range []string{input.FilePath, input.NotebookPath, input.Path} {
+		if path != "" {
+			return e.ToolName + ":" + p.groupDir(path)
+		}
+	}
+	if fields := strings.Fields(input.Command); len(fields) > 0 {
+		return e.ToolName + ":" + fields[0]
+	}
+	if u, err := url.Parse(input.URL); err == nil && u.Host != "" {
+		return e.ToolName + ":" + u.Host
+	}
+	sum := sha256.Sum256(e.ToolInput)
+	return e.ToolName + ":" + hex.EncodeToString(sum[:6])
+}
+
+// groupDir is path's directory, relative to the work directory when inside
+// it so the key reads the way the user sees the tree.
+func (p *Process) groupDir(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.manager.workDir, path)
+	}
+	dir := filepath.Dir(path)
+	if rel, err := filepath.Rel(p.manager.workDir, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(dir)
+}
+
+// trackPermission records a request the user has yet to answer.
+func (p *Process) trackPermission(e agent.PermissionRequestEvent) {
+	p.pendingPermissionsMu.Lock()
+	defer p.pendingPermissionsMu.Unlock()
+	p.pendingPermissions = append(p.pendingPermissions, e)
+}
+
+// untrackPermission drops a request once it is answered or cancelled.
+func (p *Process) untrackPermission(requestID string) {
+	p.pendingPermissionsMu.Lock()
+	defer p.pendingPermissionsMu.Unlock()
+	for i, e := range p.pendingPermissions {
+		if e.RequestID == requestID {
+			p.pendingPermissions = append(p.pendingPermissions[:i], p.pendingPermissions[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *Process) clearPendingPermissions() {
+	p.pendingPermissionsMu.Lock()
+	defer p.pendingPermissionsMu.Unlock()
+	p.pendingPermissions = nil
+}
+
+// takePermissionGroup removes and returns the other pending requests in
+// requestID's group, in arrival order.
+func (p *Process) takePermissionGroup(requestID string) []agent.PermissionRequestEvent {
+	p.pendingPermissionsMu.Lock()
+	defer p.pendingPermissionsMu.Unlock()
+
+	var group string
+	for _, e := range p.pendingPermissions {
+		if e.RequestID == requestID {
+			group = e.Group
+			break
+		}
+	}
+	if group == "" {
+		return nil
+	}
+
+	var peers []agent.PermissionRequestEvent
+	kept := p.pendingPermissions[:0]
+	for _, e := range p.pendingPermissions {
+		if e.Group == group && e.RequestID != requestID {
+			peers = append(peers, e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	p.pendingPermissions = kept
+	return peers
+}
+
+// SendPermissionGroupResponse answers a permission request like
+// SendPermissionResponse, then gives the same answer to every other pending
+// request in its group. Each of those is persisted and emitted as a
+// permission_response with AppliedFrom set, so clients drop their prompts.
+// Returns the IDs of the other requests answered; a request that timed out
+// meanwhile keeps its default answer and is left out.
+func (p *Process) SendPermissionGroupResponse(data agent.PermissionRequestData, choice agent.PermissionChoice) ([]string, error) {
+	peers := p.takePermissionGroup(data.RequestID)
+	if err := p.SendPermissionResponse(data, choice); err != nil {
+		return nil, err
+	}
+
+	answered := []string{}
+	for _, e := range peers {
+		log := slog.With("sessionId", p.sessionID, "requestId", e.RequestID, "tool", e.ToolName, "choice", choice.String())
+		if err := p.resolvePermission(e.RequestID); err != nil {
+			continue
+		}
+		peer := agent.PermissionRequestData{
+			RequestID:             e.RequestID,
+			ToolInput:             e.ToolInput,
+			ToolUseID:             e.ToolUseID,
+			PermissionSuggestions: e.PermissionSuggestions,
+		}
+		if err := p.agentSession.SendPermissionResponse(peer, choice); err != nil {
+			log.Error("failed to send grouped permission response", "error", err)
+			continue
+		}
+		answered = append(answered, e.RequestID)
+
+		event := agent.PermissionResponseEvent{RequestID: e.RequestID, Choice: choice.String(), AppliedFrom: data.RequestID}
+		if err := p.sessionStore.AppendToHistory(p.manager.ctx, p.sessionID, agent.NewEventRecord(event)); err != nil {
+			log.Error("failed to persist grouped permission response", "error", err)
+		}
+		p.manager.EmitMessage(p.sessionID, event)
+	}
+	return answered, nil
+}
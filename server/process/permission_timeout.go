@@ -0,0 +1,184 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/protected"
+)
+
+// ErrPermissionTimedOut is returned for a response to a permission request
+// the server already answered because nobody responded in time.
+var ErrPermissionTimedOut = errors.New("permission request already timed out")
+
+// PermissionTimeout configures the automatic answer to permission requests
+// nobody responds to. A zero After waits forever.
+type PermissionTimeout struct {
+	After time.Duration
+	// AllowReadOnly allows read-only tools (agent.IsReadOnlyTool) on timeout
+	// instead of denying them.
+	AllowReadOnly bool
+	// Protected paths are never allowed unattended: a read-only tool whose
+	// target matches is denied and the attempt recorded in the audit log.
+	Protected protected.Patterns
+}
+
+// SetPermissionTimeout sets the timeout provider, evaluated when each
+// permission request arrives so settings changes apply to the next request.
+func (m *Manager) SetPermissionTimeout(fn func() PermissionTimeout) {
+	m.permissionTimeout = fn
+}
+
+// armPermissionTimeout schedules the default answer for a permission request.
+func (p *Process) armPermissionTimeout(e agent.PermissionRequestEvent) {
+	if p.manager.permissionTimeout == nil {
+		return
+	}
+	cfg := p.manager.permissionTimeout()
+	if cfg.After <= 0 {
+		return
+	}
+
+	p.permissionTimersMu.Lock()
+	defer p.permissionTimersMu.Unlock()
+	if p.permissionTimers == nil {
+		p.permissionTimers = make(map[string]*time.Timer)
+	}
+	if prev, ok := p.permissionTimers[e.RequestID]; ok {
+		prev.Stop()
+	}
+	p.permissionTimers[e.RequestID] = time.AfterFunc(cfg.After, func() {
+		p.expirePermission(e, cfg)
+	})
+}
+
+// resolvePermission cancels the timeout of an answered request. Returns
+// ErrPermissionTimedOut if the default answer was already sent, since the
+// agent would ignore a second one.
+func (p *Process) resolvePermission(requestID string) error {
+	p.permissionTimersMu.Lock()
+	defer p.permissionTimersMu.Unlock()
+	if p.timedOutRequests[requestID] {
+		return ErrPermissionTimedOut
+	}
+	p.cancelPermissionTimeoutLocked(requestID)
+	return nil
+}
+
+// cancelPermissionTimeout drops the timeout of a request the agent cancelled.
+func (p *Process) cancelPermissionTimeout(requestID string) {
+	p.permissionTimersMu.Lock()
+	defer p.permissionTimersMu.Unlock()
+	p.cancelPermissionTimeoutLocked(requestID)
+}
+
+// cancelPermissionTimeoutLocked requires permissionTimersMu.
+func (p *Process) cancelPermissionTimeoutLocked(requestID string) {
+	if timer, ok := p.permissionTimers[requestID]; ok {
+		timer.Stop()
+		delete(p.permissionTimers, requestID)
+	}
+}
+
+// stopPermissionTimers cancels every pending timeout once the process ends.
+func (p *Process) stopPermissionTimers() {
+	p.permissionTimersMu.Lock()
+	defer p.permissionTimersMu.Unlock()
+	for id, timer := range p.permissionTimers {
+		timer.Stop()
+		delete(p.permissionTimers, id)
+	}
+}
+
+// expirePermission sends the default answer for a request nobody responded
+// to and records it in history with the timed-out marker. The emitted
+// permission_response lets clients drop the stale prompt.
+func (p *Process) expirePermission(e agent.PermissionRequestEvent, cfg PermissionTimeout) {
+	p.permissionTimersMu.Lock()
+	if _, pending := p.permissionTimers[e.RequestID]; !pending || p.closed.Load() {
+		p.permissionTimersMu.Unlock()
+		return
+	}
+	delete(p.permissionTimers, e.RequestID)
+	if p.timedOutRequests == nil {
+		p.timedOutRequests = make(map[string]bool)
+	}
+	p.timedOutRequests[e.RequestID] = true
+	p.permissionTimersMu.Unlock()
+	p.untrackPermission(e.RequestID)
+
+	choice, choiceName := agent.PermissionDeny, "deny"
+	if cfg.AllowReadOnly && agent.IsReadOnlyTool(e.ToolName) {
+		if rel, pattern, ok := p.protectedToolPath(e.ToolInput, cfg.Protected); ok {
+			p.manager.auditLog.Record(audit.Event{
+				Action:    "permission.timeout",
+				Path:      rel,
+				Pattern:   pattern,
+				SessionID: p.sessionID,
+				Detail:    "denied " + e.ToolName,
+			})
+		} else {
+			choice, choiceName = agent.PermissionAllow, "allow"
+		}
+	}
+	log := slog.With("sessionId", p.sessionID, "requestId", e.RequestID, "tool", e.ToolName, "choice", choiceName)
+
+	p.SetRunning()
+	data := agent.PermissionRequestData{
+		RequestID:             e.RequestID,
+		ToolInput:             e.ToolInput,
+		ToolUseID:             e.ToolUseID,
+		PermissionSuggestions: e.PermissionSuggestions,
+	}
+	if err := p.agentSession.SendPermissionResponse(data, choice); err != nil {
+		log.Error("failed to send timed-out permission response", "error", err)
+		return
+	}
+	log.Info("permission request timed out")
+
+	event := agent.PermissionResponseEvent{RequestID: e.RequestID, Choice: choiceName, TimedOut: true}
+	if err := p.sessionStore.AppendToHistory(p.manager.ctx, p.sessionID, agent.NewEventRecord(event)); err != nil {
+		log.Error("failed to persist timed-out permission response", "error", err)
+	}
+	p.manager.EmitMessage(p.sessionID, event)
+}
+
+// protectedToolPath reports whether the tool input targets a protected path,
+// returning it relative to the work directory. Paths outside the work
+// directory are not covered by the patterns.
+func (p *Process) protectedToolPath(input json.RawMessage, patterns protected.Patterns) (rel, pattern string, ok bool) {
+	if len(patterns) == 0 || len(input) == 0 {
+		return "", "", false
+	}
+	var target struct {
+		FilePath     string `json:"file_path"`
+		Path         string `json:"path"`
+		NotebookPath string `json:"notebook_path"`
+	}
+	if err := json.Unmarshal(input, &target); err != nil {
+		return "", "", false
+	}
+	for _, path := range []string{target.FilePath, target.Path, target.NotebookPath} {
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.manager.workDir, path)
+		}
+		r, err := filepath.Rel(p.manager.workDir, path)
+		if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			continue
+		}
+		r = filepath.ToSlash(r)
+		if pattern, ok := patterns.Match(r); ok {
+			return r, pattern, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,97 @@
+package process
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrStopTimeout is returned by CloseAndWait when the agent process does not
+// exit in time.
+var ErrStopTimeout = errors.New("agent process did not stop in time")
+
+// stopTimeout bounds how long CloseAndWait waits for the event stream to end.
+const stopTimeout = 10 * time.Second
+
+// SetStuckAfter sets the watchdog threshold provider, evaluated on every
+// reaper pass so settings changes apply without a restart.
+func (m *Manager) SetStuckAfter(fn func() time.Duration) {
+	m.stuckAfter = fn
+}
+
+// SetOnPossiblyStuck sets the callback invoked once per silence when a
+// running process has produced no output for the stuck threshold.
+func (m *Manager) SetOnPossiblyStuck(fn func(sessionID string, silentSince time.Time)) {
+	m.onPossiblyStuck = fn
+}
+
+// checkStuck reports running processes that have been silent too long. A CLI
+// waiting on an interactive prompt the adapter can't parse looks exactly like
+// this, but so does a long tool call, hence "possibly".
+func (m *Manager) checkStuck(now time.Time) {
+	if m.stuckAfter == nil || m.onPossiblyStuck == nil {
+		return
+	}
+	after := m.stuckAfter()
+	if after <= 0 {
+		return
+	}
+
+	type stuck struct {
+		sessionID   string
+		silentSince time.Time
+	}
+	var found []stuck
+	m.processesMu.Lock()
+	for _, p := range m.processes {
+		if since, ok := p.markStuck(now, after); ok {
+			found = append(found, stuck{p.sessionID, since})
+		}
+	}
+	m.processesMu.Unlock()
+
+	for _, s := range found {
+		slog.Warn("agent process possibly stuck", "sessionId", s.sessionID, "silentSince", s.silentSince)
+		m.onPossiblyStuck(s.sessionID, s.silentSince)
+	}
+}
+
+// markStuck reports whether the process is running, has been silent for at
+// least after, and was not yet reported for this silence. Returns when the
+// silence began: the last output, or the start of the turn if later.
+func (p *Process) markStuck(now time.Time, after time.Duration) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.state != ProcessStateRunning || p.stuckWarned || p.closed.Load() {
+		return time.Time{}, false
+	}
+	since := p.lastActive
+	if p.runningSince.After(since) {
+		since = p.runningSince
+	}
+	if now.Sub(since) < after {
+		return time.Time{}, false
+	}
+	p.stuckWarned = true
+	return since, true
+}
+
+// CloseAndWait terminates a session's process and waits until its event
+// stream has ended, so a replacement started afterwards can't be removed or
+// marked ended by the old one's cleanup. No-op without a process.
+func (m *Manager) CloseAndWait(sessionID string) error {
+	proc := m.remove(sessionID)
+	if proc == nil {
+		return nil
+	}
+	proc.closed.Store(true)
+	proc.agentSession.Close()
+	slog.Info("process closed", "sessionId", sessionID)
+
+	select {
+	case <-proc.done:
+		return nil
+	case <-time.After(stopTimeout):
+		return ErrStopTimeout
+	}
+}
@@ -0,0 +1,270 @@
+package process
+
+import (
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/logger"
+	"github.com/pockode/server/session"
+)
+
+// MaxWarmPoolSize caps the warm pool per worktree. Each warm process is a
+// full agent CLI, so a larger pool mostly burns memory on a busy machine.
+const MaxWarmPoolSize = 4
+
+// WarmPoolConfig describes the processes kept warm. Only sessions created
+// with the same agent type and mode can use them.
+type WarmPoolConfig struct {
+	Size      int // 0 disables the pool
+	AgentType session.AgentType
+	Mode      session.Mode
+}
+
+func (c WarmPoolConfig) normalized() WarmPoolConfig {
+	c.Size = min(c.Size, MaxWarmPoolSize)
+	c.AgentType, c.Mode = normalizeWarmKey(c.AgentType, c.Mode)
+	return c
+}
+
+// normalizeWarmKey applies the session store's defaults for an empty agent
+// type or mode, so "" and the explicit default match the same processes.
+func normalizeWarmKey(agentType session.AgentType, mode session.Mode) (session.AgentType, session.Mode) {
+	if agentType == "" {
+		agentType = session.AgentTypeClaude
+	}
+	if mode == "" {
+		mode = session.ModeDefault
+	}
+	return agentType, mode
+}
+
+// warmProcess is an agent process started for a session ID no session has
+// yet. The agent CLI and its MCP config are keyed by session ID at launch,
+// so a warm process cannot be rebound; instead a new session takes its ID
+// (claim) and the process is adopted when the session first needs it (bind).
+type warmProcess struct {
+	sessionID string
+	agentType session.AgentType
+	mode      session.Mode
+	opts      agent.StartOptions
+	sess      agent.Session
+	startedAt time.Time
+	claimedAt time.Time // zero while unclaimed
+}
+
+// SetWarmPool sets the warm pool provider, evaluated on every reaper pass so
+// settings changes apply without a restart. Without one no processes are
+// pre-started.
+func (m *Manager) SetWarmPool(fn func() WarmPoolConfig) {
+	m.warmPool = fn
+	go m.fillWarm()
+}
+
+func (m *Manager) warmConfig() WarmPoolConfig {
+	if m.warmPool == nil || m.draining.Load() || m.ctx.Err() != nil {
+		return WarmPoolConfig{}
+	}
+	return m.warmPool().normalized()
+}
+
+// NewSessionID returns the ID for a new session of the given agent type and
+// mode: the ID of an unclaimed warm process when one matches, otherwise a
+// fresh one. A claimed process that is never bound is closed by the next
+// refresh after the idle timeout.
+func (m *Manager) NewSessionID(agentType session.AgentType, mode session.Mode) string {
+	agentType, mode = normalizeWarmKey(agentType, mode)
+
+	m.warmMu.Lock()
+	var claimed string
+	for _, w := range m.warm {
+		if w.claimedAt.IsZero() && w.agentType == agentType && w.mode == mode {
+			w.claimedAt = time.Now()
+			claimed = w.sessionID
+			break
+		}
+	}
+	m.warmMu.Unlock()
+
+	if claimed == "" {
+		return uuid.Must(uuid.NewV7()).String()
+	}
+	slog.Info("warm process claimed", "sessionId", claimed)
+	go m.fillWarm()
+	return claimed
+}
+
+// WarmCount returns the number of unclaimed warm processes.
+func (m *Manager) WarmCount() int {
+	m.warmMu.Lock()
+	defer m.warmMu.Unlock()
+	n := 0
+	for _, w := range m.warm {
+		if w.claimedAt.IsZero() {
+			n++
+		}
+	}
+	return n
+}
+
+// bindWarm removes the warm process started for sessionID and returns its
+// agent session if it was launched with exactly the options the session
+// needs now; otherwise (e.g. a role adds MCP servers, or settings changed
+// since) the warm process is closed and nil is returned so the caller
+// starts a fresh one.
+func (m *Manager) bindWarm(sessionID string, agentType session.AgentType, opts agent.StartOptions) agent.Session {
+	m.warmMu.Lock()
+	var found *warmProcess
+	for i, w := range m.warm {
+		if w.sessionID == sessionID {
+			found = w
+			m.warm = append(m.warm[:i], m.warm[i+1:]...)
+			break
+		}
+	}
+	m.warmMu.Unlock()
+	if found == nil {
+		return nil
+	}
+
+	agentType, _ = normalizeWarmKey(agentType, "")
+	if found.agentType != agentType || !reflect.DeepEqual(found.opts, opts) {
+		found.sess.Close()
+		slog.Info("warm process discarded: start options changed", "sessionId", sessionID)
+		return nil
+	}
+	slog.Info("warm process bound", "sessionId", sessionID, "warmFor", time.Since(found.startedAt).Round(time.Second))
+	return found.sess
+}
+
+// fillWarm starts processes until the pool holds the configured number of
+// unclaimed ones. Only one fill runs at a time; starts happen outside every
+// lock since launching a CLI takes a while.
+func (m *Manager) fillWarm() {
+	if !m.warmFilling.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.warmFilling.Store(false)
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogPanic(r, "warm pool fill crashed")
+		}
+	}()
+
+	for {
+		cfg := m.warmConfig()
+		if m.WarmCount() >= cfg.Size {
+			return
+		}
+
+		ag, err := m.agents.Get(cfg.AgentType)
+		if err != nil {
+			slog.Warn("warm pool disabled: unknown agent type", "agentType", cfg.AgentType, "error", err)
+			return
+		}
+		sessionID := uuid.Must(uuid.NewV7()).String()
+		opts, _ := m.startOptions(sessionID, false, cfg.Mode)
+		sess, err := ag.Start(m.ctx, opts)
+		if err != nil {
+			slog.Warn("failed to start warm process", "agentType", cfg.AgentType, "error", err)
+			return
+		}
+
+		m.warmMu.Lock()
+		// A drain or shutdown may have run while the CLI was starting.
+		if m.draining.Load() || m.ctx.Err() != nil {
+			m.warmMu.Unlock()
+			sess.Close()
+			return
+		}
+		m.warm = append(m.warm, &warmProcess{
+			sessionID: sessionID,
+			agentType: cfg.AgentType,
+			mode:      cfg.Mode,
+			opts:      opts,
+			sess:      sess,
+			startedAt: time.Now(),
+		})
+		m.warmMu.Unlock()
+		slog.Info("warm process started", "sessionId", sessionID, "agentType", cfg.AgentType, "mode", cfg.Mode)
+	}
+}
+
+// refreshWarm closes warm processes that sat unused for the idle timeout,
+// no longer match the configuration, or exceed the pool size, then refills
+// the pool. Restarting long-idle processes keeps them from holding stale
+// credentials or tool state.
+func (m *Manager) refreshWarm(now time.Time) {
+	cfg := m.warmConfig()
+
+	type entry struct {
+		w         *warmProcess
+		claimedAt time.Time
+	}
+	m.warmMu.Lock()
+	snapshot := make([]entry, len(m.warm))
+	for i, w := range m.warm {
+		snapshot[i] = entry{w, w.claimedAt}
+	}
+	m.warmMu.Unlock()
+
+	// Options are rebuilt outside warmMu: the providers consult other stores.
+	stale := make(map[*warmProcess]bool)
+	unclaimed := 0
+	for _, e := range snapshot {
+		w := e.w
+		if !e.claimedAt.IsZero() {
+			if now.Sub(e.claimedAt) > m.idleTimeout {
+				stale[w] = true
+			}
+			continue
+		}
+		opts, _ := m.startOptions(w.sessionID, false, w.mode)
+		if now.Sub(w.startedAt) > m.idleTimeout || w.agentType != cfg.AgentType || w.mode != cfg.Mode ||
+			unclaimed >= cfg.Size || !reflect.DeepEqual(w.opts, opts) {
+			stale[w] = true
+			continue
+		}
+		unclaimed++
+	}
+
+	var closed []*warmProcess
+	m.warmMu.Lock()
+	kept := m.warm[:0]
+	for _, w := range m.warm {
+		// Skip processes claimed since the snapshot: a session now owns the ID.
+		if stale[w] && (w.claimedAt.IsZero() || now.Sub(w.claimedAt) > m.idleTimeout) {
+			closed = append(closed, w)
+		} else {
+			kept = append(kept, w)
+		}
+	}
+	m.warm = kept
+	m.warmMu.Unlock()
+
+	for _, w := range closed {
+		w.sess.Close()
+		slog.Info("warm process closed", "sessionId", w.sessionID)
+	}
+	if cfg.Size > 0 {
+		go m.fillWarm()
+	}
+}
+
+// closeWarm closes every warm process, claimed or not. A claimed session
+// then starts a fresh process on its first message.
+func (m *Manager) closeWarm() {
+	m.warmMu.Lock()
+	warm := m.warm
+	m.warm = nil
+	m.warmMu.Unlock()
+
+	for _, w := range warm {
+		w.sess.Close()
+	}
+	if len(warm) > 0 {
+		slog.Info("warm pool closed", "processesClosed", len(warm))
+	}
+}
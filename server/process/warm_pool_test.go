@@ -0,0 +1,154 @@
+package process
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/session"
+)
+
+func waitWarmCount(t *testing.T, m *Manager, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for m.WarmCount() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("warm count = %d, want %d", m.WarmCount(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (m *mockAgent) startCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.startCalls)
+}
+
+func (m *mockAgent) session(id string) *mockSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sessions[id]
+}
+
+func newWarmManager(t *testing.T, mock *mockAgent, size int) *Manager {
+	t.Helper()
+	store, err := session.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(mockRegistry(mock), "/tmp", "", store, 10*time.Minute)
+	t.Cleanup(m.Shutdown)
+	m.SetWarmPool(func() WarmPoolConfig { return WarmPoolConfig{Size: size} })
+	waitWarmCount(t, m, size)
+	return m
+}
+
+func TestWarmPool_ClaimAndBind(t *testing.T) {
+	mock := &mockAgent{}
+	m := newWarmManager(t, mock, 2)
+
+	id := m.NewSessionID(session.AgentTypeClaude, session.ModeDefault)
+	if mock.session(id) == nil {
+		t.Fatalf("NewSessionID = %q, want the ID of a warm process", id)
+	}
+	// The pool refills after a claim.
+	waitWarmCount(t, m, 2)
+	starts := mock.startCount()
+
+	proc, created, err := m.GetOrCreateProcess(context.Background(), id, false, session.AgentTypeClaude, session.ModeDefault)
+	if err != nil {
+		t.Fatalf("GetOrCreateProcess: %v", err)
+	}
+	if !created || proc == nil {
+		t.Fatal("expected a newly created process")
+	}
+	if got := mock.startCount(); got != starts {
+		t.Errorf("start calls = %d, want %d (warm process reused)", got, starts)
+	}
+	if mock.session(id).isClosed() {
+		t.Error("bound warm process was closed")
+	}
+}
+
+func TestWarmPool_NoMatchGeneratesID(t *testing.T) {
+	mock := &mockAgent{}
+	m := newWarmManager(t, mock, 1)
+
+	id := m.NewSessionID(session.AgentTypeClaude, session.ModePlan)
+	if mock.session(id) != nil {
+		t.Error("a plan session should not claim a default-mode warm process")
+	}
+	if m.WarmCount() != 1 {
+		t.Errorf("warm count = %d, want 1", m.WarmCount())
+	}
+}
+
+func TestWarmPool_OptionsChangedStartsFresh(t *testing.T) {
+	mock := &mockAgent{}
+	m := newWarmManager(t, mock, 1)
+
+	id := m.NewSessionID("", "")
+	warm := mock.session(id)
+	if warm == nil {
+		t.Fatal("expected a warm process to be claimed")
+	}
+	// E.g. the session's agent role adds an MCP server.
+	m.SetMCPServers(func(string) []agent.MCPServer {
+		return []agent.MCPServer{{Name: "extra", Command: "extra"}}
+	})
+	starts := mock.startCount()
+
+	if _, _, err := m.GetOrCreateProcess(context.Background(), id, false, session.AgentTypeClaude, session.ModeDefault); err != nil {
+		t.Fatalf("GetOrCreateProcess: %v", err)
+	}
+	if !warm.isClosed() {
+		t.Error("mismatched warm process should be closed")
+	}
+	if got := mock.startCount(); got <= starts {
+		t.Error("expected a fresh start for the mismatched session")
+	}
+}
+
+func TestWarmPool_Refresh(t *testing.T) {
+	mock := &mockAgent{}
+	m := newWarmManager(t, mock, 1)
+
+	var first string
+	mock.mu.Lock()
+	for id := range mock.sessions {
+		first = id
+	}
+	mock.mu.Unlock()
+	m.refreshWarm(time.Now().Add(11 * time.Minute))
+
+	if !mock.session(first).isClosed() {
+		t.Error("warm process idle past the timeout should be closed")
+	}
+	waitWarmCount(t, m, 1)
+	if mock.startCount() != 2 {
+		t.Errorf("start calls = %d, want 2 (pool refilled)", mock.startCount())
+	}
+}
+
+func TestWarmPool_ClosedOnDrain(t *testing.T) {
+	mock := &mockAgent{}
+	m := newWarmManager(t, mock, 2)
+
+	m.StartDrain()
+
+	if m.WarmCount() != 0 {
+		t.Errorf("warm count after drain = %d, want 0", m.WarmCount())
+	}
+	mock.mu.Lock()
+	for id, s := range mock.sessions {
+		if !s.isClosed() {
+			t.Errorf("warm process %s still open after drain", id)
+		}
+	}
+	mock.mu.Unlock()
+	if id := m.NewSessionID("", ""); mock.session(id) != nil {
+		t.Error("NewSessionID should not hand out a drained process")
+	}
+}
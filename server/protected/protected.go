@@ -0,0 +1,98 @@
+// Package protected matches worktree paths against the user's protected path
+// patterns (credentials, production config) that server-side writes refuse.
+package protected
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var ErrInvalidPattern = errors.New("invalid protected path pattern")
+
+// Patterns is a parsed protected path list. The zero value protects nothing.
+//
+// Syntax follows .gitignore loosely: a pattern without a slash (".env",
+// "*.pem") matches a name at any depth; one with a slash ("infra/prod",
+// "/config/keys.json") is anchored at the worktree root. "*" and "?" match
+// within a path segment and "**" matches any number of segments. A pattern
+// that matches a directory protects everything under it.
+type Patterns []string
+
+// Parse splits a comma- or newline-separated pattern list, dropping blanks.
+func Parse(s string) Patterns {
+	var out Patterns
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if p := strings.TrimSpace(field); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Validate reports the first malformed pattern, wrapping ErrInvalidPattern.
+func (ps Patterns) Validate() error {
+	for _, p := range ps {
+		for _, seg := range segments(p) {
+			if _, err := path.Match(seg, ""); err != nil {
+				return fmt.Errorf("%w: %q", ErrInvalidPattern, p)
+			}
+		}
+	}
+	return nil
+}
+
+// Match reports the first pattern protecting rel, a worktree-relative path.
+func (ps Patterns) Match(rel string) (pattern string, ok bool) {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." || rel == "" {
+		return "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(rel, "/"), "/")
+	for _, p := range ps {
+		segs := segments(p)
+		// Checking every ancestor makes a matched directory cover its contents.
+		for n := 1; n <= len(parts); n++ {
+			if matchSegments(segs, parts[:n]) {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// segments splits a pattern for matchSegments, prefixing "**" when it is
+// unanchored.
+func segments(pattern string) []string {
+	p := strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	segs := strings.Split(p, "/")
+	if !anchored {
+		segs = append([]string{"**"}, segs...)
+	}
+	return segs
+}
+
+func matchSegments(pat, parts []string) bool {
+	if len(pat) == 0 {
+		return len(parts) == 0
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pat[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pat[0], parts[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pat[1:], parts[1:])
+}
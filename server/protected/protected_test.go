@@ -0,0 +1,56 @@
+package protected
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPatterns_Match(t *testing.T) {
+	ps := Parse(".env, *.pem\nsecrets/\ninfra/prod/**\n/config/keys.json")
+
+	tests := []struct {
+		path    string
+		pattern string
+	}{
+		{".env", ".env"},
+		{"app/.env", ".env"},
+		{"certs/server.pem", "*.pem"},
+		{"secrets/api/token", "secrets/"},
+		{"nested/secrets/token", "secrets/"},
+		{"infra/prod/main.tf", "infra/prod/**"},
+		{"infra/prod", "infra/prod/**"},
+		{"config/keys.json", "/config/keys.json"},
+		{"./app/../.env", ".env"},
+		{".env.example", ""},
+		{"infra/staging/main.tf", ""},
+		{"app/config/keys.json", ""},
+		{"README.md", ""},
+		{".", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			pattern, ok := ps.Match(tt.path)
+			if ok != (tt.pattern != "") || pattern != tt.pattern {
+				t.Errorf("Match(%q) = %q, %v; want %q", tt.path, pattern, ok, tt.pattern)
+			}
+		})
+	}
+}
+
+func TestPatterns_Validate(t *testing.T) {
+	if err := Parse(".env, secrets/**").Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Parse("[abc").Validate(); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("expected ErrInvalidPattern, got %v", err)
+	}
+}
+
+func TestParse_Empty(t *testing.T) {
+	if ps := Parse(" , \n"); len(ps) != 0 {
+		t.Errorf("expected no patterns, got %v", ps)
+	}
+	if _, ok := Patterns(nil).Match(".env"); ok {
+		t.Error("nil patterns should protect nothing")
+	}
+}
@@ -107,7 +107,7 @@ func (h *HTTPHandler) Handle(ctx context.Context, req *HTTPRequest) *HTTPRespons
 }
 
 func (h *HTTPHandler) isBackendPath(path string) bool {
-	return strings.HasPrefix(path, "/api") || path == "/ws" || path == "/health"
+	return strings.HasPrefix(path, "/api") || path == "/ws" || path == "/health" || path == "/healthz" || path == "/readyz"
 }
 
 // isHopByHopHeader returns true if the header is a hop-by-hop header
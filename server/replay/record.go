@@ -0,0 +1,117 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pockode/server/agent"
+)
+
+// RecordingAgent wraps an agent and writes every event its sessions emit,
+// with arrival times, to one replay file per process in dir.
+type RecordingAgent struct {
+	inner agent.Agent
+	dir   string
+	now   func() time.Time
+}
+
+// NewRecordingAgent records inner's events into dir, created on first use.
+// The returned agent implements agent.HealthChecker and agent.CommandLister
+// exactly when inner does, so recording doesn't change what callers can do.
+func NewRecordingAgent(inner agent.Agent, dir string) agent.Agent {
+	a := &RecordingAgent{inner: inner, dir: dir, now: time.Now}
+	hc, isHC := inner.(agent.HealthChecker)
+	cl, isCL := inner.(agent.CommandLister)
+	switch {
+	case isHC && isCL:
+		return struct {
+			*RecordingAgent
+			agent.HealthChecker
+			agent.CommandLister
+		}{a, hc, cl}
+	case isHC:
+		return struct {
+			*RecordingAgent
+			agent.HealthChecker
+		}{a, hc}
+	case isCL:
+		return struct {
+			*RecordingAgent
+			agent.CommandLister
+		}{a, cl}
+	}
+	return a
+}
+
+func (a *RecordingAgent) Start(ctx context.Context, opts agent.StartOptions) (agent.Session, error) {
+	sess, err := a.inner.Start(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	name := opts.SessionID + "-" + a.now().UTC().Format("20060102T150405.000000000") + ".jsonl"
+	r := &recordingSession{
+		Session: sess,
+		events:  make(chan agent.AgentEvent),
+		path:    filepath.Join(a.dir, name),
+		now:     a.now,
+	}
+	go r.tee()
+	return r, nil
+}
+
+// recordingSession forwards the wrapped session's events unchanged. A failure
+// to write the file is logged once and ends recording, never the session.
+type recordingSession struct {
+	agent.Session
+	events chan agent.AgentEvent
+	path   string
+	now    func() time.Time
+}
+
+func (s *recordingSession) Events() <-chan agent.AgentEvent { return s.events }
+
+func (s *recordingSession) tee() {
+	defer close(s.events)
+
+	var enc *json.Encoder
+	var f *os.File
+	failed := false
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	for event := range s.Session.Events() {
+		if !failed {
+			if f == nil {
+				var err error
+				f, err = openReplayFile(s.path)
+				if err != nil {
+					slog.Warn("failed to create replay file", "path", s.path, "error", err)
+					failed = true
+				} else {
+					enc = json.NewEncoder(f)
+				}
+			}
+			if enc != nil {
+				if err := enc.Encode(newEntry(s.now(), event)); err != nil {
+					slog.Warn("failed to record event", "path", s.path, "error", err)
+					failed = true
+				}
+			}
+		}
+		s.events <- event
+	}
+}
+
+func openReplayFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+}
@@ -0,0 +1,169 @@
+// Package replay records the events agent processes emit and plays them back
+// through the process pipeline, so a reported UI or state bug can be
+// reproduced deterministically without running an agent CLI or spending API
+// tokens.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/agent"
+)
+
+// maxLineSize bounds one recorded event; tool results can be large.
+const maxLineSize = 16 << 20
+
+// Entry is one line of a replay file: an agent event and when it arrived.
+type Entry struct {
+	At    time.Time         `json:"at"`
+	Event agent.EventRecord `json:"event"`
+	// Tokens is DoneEvent.Tokens, which event records do not keep.
+	Tokens int64 `json:"tokens,omitempty"`
+}
+
+func newEntry(at time.Time, event agent.AgentEvent) Entry {
+	e := Entry{At: at, Event: event.ToRecord()}
+	if done, ok := event.(agent.DoneEvent); ok {
+		e.Tokens = done.Tokens
+	}
+	return e
+}
+
+// AgentEvent rebuilds the recorded event.
+func (e Entry) AgentEvent() (agent.AgentEvent, error) {
+	event, err := agent.EventFromRecord(e.Event)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := event.(agent.DoneEvent); ok {
+		event = agent.DoneEvent{Tokens: e.Tokens}
+	}
+	return event, nil
+}
+
+// ReadFile parses a replay file written by a recording agent.
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if _, err := e.AgentEvent(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Agent plays recorded entries back as an agent.Agent. Every Start returns a
+// session that replays all entries.
+type Agent struct {
+	entries []Entry
+	speed   float64
+}
+
+// NewAgent returns an agent replaying entries. Speed scales the recorded
+// gaps between events (2 plays twice as fast); zero or less plays them
+// back to back.
+func NewAgent(entries []Entry, speed float64) *Agent {
+	return &Agent{entries: entries, speed: speed}
+}
+
+func (a *Agent) Start(ctx context.Context, opts agent.StartOptions) (agent.Session, error) {
+	s := &playback{
+		events:  make(chan agent.AgentEvent),
+		started: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	go s.play(ctx, a.entries, a.speed)
+	return s, nil
+}
+
+// playback starts playing at the first input, as an agent CLI only answers
+// once prompted, and closes its event channel at the end of the recording.
+type playback struct {
+	events    chan agent.AgentEvent
+	started   chan struct{}
+	startOnce sync.Once
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *playback) play(ctx context.Context, entries []Entry, speed float64) {
+	defer close(s.events)
+
+	select {
+	case <-s.started:
+	case <-s.closed:
+		return
+	case <-ctx.Done():
+		return
+	}
+
+	for i, e := range entries {
+		if i > 0 && speed > 0 {
+			if gap := e.At.Sub(entries[i-1].At); gap > 0 {
+				timer := time.NewTimer(time.Duration(float64(gap) / speed))
+				select {
+				case <-timer.C:
+				case <-s.closed:
+					timer.Stop()
+					return
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+		}
+
+		event, err := e.AgentEvent()
+		if err != nil {
+			continue // ReadFile already rejected these
+		}
+		select {
+		case s.events <- event:
+		case <-s.closed:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *playback) start() error {
+	s.startOnce.Do(func() { close(s.started) })
+	return nil
+}
+
+func (s *playback) Events() <-chan agent.AgentEvent { return s.events }
+func (s *playback) SendMessage(string) error        { return s.start() }
+func (s *playback) SendPermissionResponse(agent.PermissionRequestData, agent.PermissionChoice) error {
+	return s.start()
+}
+func (s *playback) SendQuestionResponse(agent.QuestionRequestData, map[string]string) error {
+	return s.start()
+}
+func (s *playback) SendInterrupt() error { return nil }
+func (s *playback) Close()               { s.closeOnce.Do(func() { close(s.closed) }) }
@@ -0,0 +1,209 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/process"
+)
+
+// scriptedAgent emits a fixed event list, then ends the process.
+type scriptedAgent struct {
+	events []agent.AgentEvent
+}
+
+func (a *scriptedAgent) Start(ctx context.Context, opts agent.StartOptions) (agent.Session, error) {
+	ch := make(chan agent.AgentEvent, len(a.events))
+	for _, e := range a.events {
+		ch <- e
+	}
+	close(ch)
+	return &scriptedSession{events: ch}, nil
+}
+
+type scriptedSession struct {
+	events chan agent.AgentEvent
+}
+
+func (s *scriptedSession) Events() <-chan agent.AgentEvent { return s.events }
+func (s *scriptedSession) SendMessage(string) error        { return nil }
+func (s *scriptedSession) SendPermissionResponse(agent.PermissionRequestData, agent.PermissionChoice) error {
+	return nil
+}
+func (s *scriptedSession) SendQuestionResponse(agent.QuestionRequestData, map[string]string) error {
+	return nil
+}
+func (s *scriptedSession) SendInterrupt() error { return nil }
+func (s *scriptedSession) Close()               {}
+
+func recordEvents(t *testing.T, events []agent.AgentEvent) string {
+	t.Helper()
+	dir := t.TempDir()
+	rec := NewRecordingAgent(&scriptedAgent{events: events}, dir).(*RecordingAgent)
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tick := 0
+	rec.now = func() time.Time {
+		tick++
+		return base.Add(time.Duration(tick) * time.Second)
+	}
+
+	sess, err := rec.Start(context.Background(), agent.StartOptions{SessionID: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forwarded []agent.AgentEvent
+	for e := range sess.Events() {
+		forwarded = append(forwarded, e)
+	}
+	if !reflect.DeepEqual(forwarded, events) {
+		t.Fatalf("forwarded %v, want %v", forwarded, events)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "s1-*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("replay files = %v, want one", files)
+	}
+	return files[0]
+}
+
+func TestRecordAndRead(t *testing.T) {
+	exit := 2
+	events := []agent.AgentEvent{
+		agent.TextEvent{Content: "hi"},
+		agent.ToolCallEvent{ToolName: "Bash", ToolInput: json.RawMessage(`{"command":"ls"}`), ToolUseID: "t1"},
+		agent.ToolResultEvent{ToolUseID: "t1", ToolResult: "boom", IsError: true, ExitCode: &exit},
+		agent.PermissionRequestEvent{RequestID: "r1", ToolName: "Write", ToolUseID: "t2"},
+		agent.DoneEvent{Tokens: 42},
+	}
+	path := recordEvents(t, events)
+
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(events) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(events))
+	}
+	for i, e := range entries {
+		got, err := e.AgentEvent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, events[i]) {
+			t.Errorf("entry %d = %#v, want %#v", i, got, events[i])
+		}
+	}
+	if gap := entries[1].At.Sub(entries[0].At); gap != time.Second {
+		t.Errorf("gap = %v, want 1s", gap)
+	}
+}
+
+func TestReadFile_RejectsUnknownEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.jsonl")
+	os.WriteFile(path, []byte(`{"at":"2026-01-02T03:04:05Z","event":{"type":"nope"}}`+"\n"), 0600)
+	if _, err := ReadFile(path); err == nil {
+		t.Fatal("expected error for unknown event type")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	path := recordEvents(t, []agent.AgentEvent{
+		agent.TextEvent{Content: "working"},
+		agent.PermissionRequestEvent{RequestID: "r1", ToolName: "Write", ToolUseID: "t1"},
+		agent.RequestCancelledEvent{RequestID: "r1"},
+		agent.DoneEvent{},
+	})
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deterministic: two runs see the same steps.
+	first, err := Collect(context.Background(), entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Collect(context.Background(), entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("runs differ:\n%+v\n%+v", first, second)
+	}
+
+	var got []string
+	for _, s := range first {
+		if s.Event != nil {
+			got = append(got, string(s.Event.Type))
+		} else {
+			got = append(got, "state:"+string(s.State))
+		}
+	}
+	want := []string{
+		"state:idle", "state:running",
+		"text",
+		"state:idle", "permission_request",
+		"state:running", "request_cancelled",
+		"state:idle", "done",
+		"state:ended",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %v\nwant %v", got, want)
+	}
+	if s := first[3]; s.State != process.ProcessStateIdle || !s.NeedsInput {
+		t.Errorf("permission request step = %+v, want idle needing input", s)
+	}
+}
+
+// capableAgent adds health checks and command listing to scriptedAgent.
+type capableAgent struct {
+	scriptedAgent
+}
+
+func (capableAgent) HealthCheck(context.Context) agent.HealthReport {
+	return agent.HealthReport{Healthy: true, Version: "1.2.3"}
+}
+
+func (capableAgent) ListCommands(context.Context, string) ([]agent.SlashCommand, error) {
+	return []agent.SlashCommand{{Name: "review"}}, nil
+}
+
+func TestRecordingAgent_ForwardsHealthCheck(t *testing.T) {
+	rec := NewRecordingAgent(&capableAgent{}, t.TempDir())
+	hc, ok := rec.(agent.HealthChecker)
+	if !ok {
+		t.Fatal("recording agent hides the inner agent's HealthCheck")
+	}
+	if report := hc.HealthCheck(context.Background()); report.Version != "1.2.3" {
+		t.Errorf("HealthCheck = %+v, want the inner report", report)
+	}
+
+	if _, ok := NewRecordingAgent(&scriptedAgent{}, t.TempDir()).(agent.HealthChecker); ok {
+		t.Error("recording agent claims HealthCheck the inner agent lacks")
+	}
+}
+
+func TestRecordingAgent_ForwardsListCommands(t *testing.T) {
+	rec := NewRecordingAgent(&capableAgent{}, t.TempDir())
+	cl, ok := rec.(agent.CommandLister)
+	if !ok {
+		t.Fatal("recording agent hides the inner agent's ListCommands")
+	}
+	cmds, err := cl.ListCommands(context.Background(), t.TempDir())
+	if err != nil || len(cmds) != 1 || cmds[0].Name != "review" {
+		t.Errorf("ListCommands = %v, %v; want the inner commands", cmds, err)
+	}
+	if _, err := rec.Start(context.Background(), agent.StartOptions{SessionID: "s1"}); err != nil {
+		t.Errorf("Start: %v", err)
+	}
+
+	if _, ok := NewRecordingAgent(&scriptedAgent{}, t.TempDir()).(agent.CommandLister); ok {
+		t.Error("recording agent claims ListCommands the inner agent lacks")
+	}
+}
@@ -0,0 +1,90 @@
+package replay
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/process"
+	"github.com/pockode/server/session"
+)
+
+const sessionID = "replay"
+
+// Step is one effect of the pipeline clients observe: a chat event as
+// notified to subscribers, or a process state change.
+type Step struct {
+	Event       *agent.EventRecord   `json:"event,omitempty"`
+	State       process.ProcessState `json:"state,omitempty"`
+	NeedsInput  bool                 `json:"needs_input,omitempty"`
+	Interrupted bool                 `json:"interrupted,omitempty"`
+	ErrorCode   string               `json:"error_code,omitempty"`
+}
+
+// Run feeds entries through a process manager backed by a throwaway data
+// directory and calls onStep, in order, for each effect until the recording
+// ends. Speed is as for NewAgent.
+func Run(ctx context.Context, entries []Entry, speed float64, onStep func(Step)) error {
+	dataDir, err := os.MkdirTemp("", "pockode-replay-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dataDir)
+
+	store, err := session.NewFileStore(dataDir)
+	if err != nil {
+		return err
+	}
+	if _, err := store.Create(ctx, sessionID, session.AgentTypeClaude, session.ModeDefault); err != nil {
+		return err
+	}
+
+	agents := agent.NewRegistry()
+	agents.Register(session.AgentTypeClaude, NewAgent(entries, speed))
+
+	// Steps are reported synchronously from the stream goroutine, so their
+	// order matches what subscribers would see.
+	ended := make(chan struct{})
+	mgr := process.NewManager(agents, dataDir, dataDir, store, time.Hour)
+	defer mgr.Shutdown()
+	mgr.SetMessageListener(listenerFunc(func(msg process.ChatMessage) {
+		record := msg.Event.ToRecord()
+		onStep(Step{Event: &record})
+	}))
+	mgr.SetOnStateChange(func(e process.StateChangeEvent) {
+		onStep(Step{State: e.State, NeedsInput: e.NeedsInput, Interrupted: e.Interrupted, ErrorCode: e.ErrorCode})
+		if e.State == process.ProcessStateEnded {
+			close(ended)
+		}
+	})
+
+	proc, _, err := mgr.GetOrCreateProcess(ctx, sessionID, false, session.AgentTypeClaude, session.ModeDefault)
+	if err != nil {
+		return err
+	}
+	if err := proc.SendMessage(""); err != nil {
+		return err
+	}
+
+	select {
+	case <-ended:
+		return nil
+	case <-ctx.Done():
+		mgr.Close(sessionID)
+		<-ended
+		return ctx.Err()
+	}
+}
+
+// Collect replays entries back to back and returns every step. It is meant
+// for tests asserting on what clients would have seen.
+func Collect(ctx context.Context, entries []Entry) ([]Step, error) {
+	var steps []Step
+	err := Run(ctx, entries, 0, func(s Step) { steps = append(steps, s) })
+	return steps, err
+}
+
+type listenerFunc func(process.ChatMessage)
+
+func (f listenerFunc) OnChatMessage(msg process.ChatMessage) { f(msg) }
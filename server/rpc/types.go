@@ -4,15 +4,25 @@ package rpc
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/chat"
+	"github.com/pockode/server/ci"
 	"github.com/pockode/server/command"
 	"github.com/pockode/server/contents"
 	"github.com/pockode/server/git"
+	"github.com/pockode/server/i18n"
+	"github.com/pockode/server/outline"
+	"github.com/pockode/server/pairing"
 	"github.com/pockode/server/session"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/setup"
+	"github.com/pockode/server/snapshot"
+	"github.com/pockode/server/testrun"
 	"github.com/pockode/server/work"
+	"github.com/sourcegraph/jsonrpc2"
 )
 
 // Client → Server
@@ -20,6 +30,16 @@ import (
 type AuthParams struct {
 	Token    string `json:"token"`
 	Worktree string `json:"worktree,omitempty"` // empty = main worktree
+	// Encoding requests binary framing for server → client messages
+	// ("gzip"). Empty keeps plain JSON text frames.
+	Encoding string `json:"encoding,omitempty"`
+	// Locale is the client's language tag ("ja-JP") for RPC error text.
+	// Unsupported or empty falls back to the locale setting.
+	Locale string `json:"locale,omitempty"`
+	// PairingCode, instead of Token, pairs a new device named DeviceName;
+	// the result carries the device token to authenticate with from then on.
+	PairingCode string `json:"pairing_code,omitempty"`
+	DeviceName  string `json:"device_name,omitempty"`
 }
 
 type AuthResult struct {
@@ -27,6 +47,67 @@ type AuthResult struct {
 	Title        string `json:"title"`
 	WorkDir      string `json:"work_dir"`
 	WorktreeName string `json:"worktree_name"`
+	// Encoding is the framing the server switched to after this reply;
+	// empty if the request was declined or not made.
+	Encoding string `json:"encoding,omitempty"`
+	// Locale is the language matched from the requested locale, empty when
+	// none matched and the locale setting applies.
+	Locale i18n.Locale `json:"locale,omitempty"`
+	// DeviceID is set when a device token or pairing code authenticated
+	// the connection; DeviceToken only when it was a pairing code.
+	DeviceID    string `json:"device_id,omitempty"`
+	DeviceToken string `json:"device_token,omitempty"`
+}
+
+// CancelRequestParams are the params of the $/cancelRequest notification.
+type CancelRequestParams struct {
+	ID jsonrpc2.ID `json:"id"` // of the request to cancel
+}
+
+// CodeRequestCancelled is the JSON-RPC error code of a request the client
+// cancelled before it finished (LSP's RequestCancelled).
+const CodeRequestCancelled int64 = -32800
+
+// ErrorReason is the machine-readable cause of an RPC error, sent as
+// ErrorData.Reason. Clients branch on it rather than on the message, which
+// is translated and may change.
+type ErrorReason string
+
+const (
+	ErrInvalidParams     ErrorReason = "invalid_params"     // malformed or out-of-range params
+	ErrNotFound          ErrorReason = "not_found"          // the target does not exist
+	ErrInvalidTransition ErrorReason = "invalid_transition" // not allowed in the target's current state
+	ErrRefused           ErrorReason = "refused"            // a work hook refused the change
+	ErrConflict          ErrorReason = "conflict"           // clashes with existing state or a concurrent change
+	ErrUnauthorized      ErrorReason = "unauthorized"       // not authenticated
+	ErrUnavailable       ErrorReason = "unavailable"        // the feature is not enabled or supported here
+	ErrMethodNotFound    ErrorReason = "method_not_found"
+	ErrCancelled         ErrorReason = "cancelled" // the client cancelled the request
+	ErrInternal          ErrorReason = "internal"
+)
+
+// Code is the JSON-RPC error code sent with r.
+func (r ErrorReason) Code() int64 {
+	switch r {
+	case ErrInternal:
+		return jsonrpc2.CodeInternalError
+	case ErrMethodNotFound:
+		return jsonrpc2.CodeMethodNotFound
+	case ErrCancelled:
+		return CodeRequestCancelled
+	case ErrConflict, ErrUnauthorized, ErrUnavailable:
+		return jsonrpc2.CodeInvalidRequest
+	default:
+		return jsonrpc2.CodeInvalidParams
+	}
+}
+
+// ErrorData is the data of every RPC error except a file.write conflict
+// (FileConflictData). Errors names the offending params when known; each
+// message is translated like the error's own.
+type ErrorData struct {
+	Reason ErrorReason           `json:"reason"`
+	Errors []settings.FieldError `json:"errors,omitempty"`
 }
 
 type MessageParams struct {
@@ -34,6 +115,26 @@ type MessageParams struct {
 	Content   string `json:"content"`
 }
 
+// MessageResult reports whether the message is waiting for another
+// client's turn to end; session.busy tells when it is delivered.
+type MessageResult struct {
+	Queued bool `json:"queued,omitempty"`
+}
+
+// ChatQuickParams starts a quick chat: an ephemeral session outside the
+// session list. Empty AgentType/Mode fall back to the settings defaults;
+// a non-empty Content is sent as the first message.
+type ChatQuickParams struct {
+	Content   string            `json:"content,omitempty"`
+	AgentType session.AgentType `json:"agent_type,omitempty"`
+	Mode      session.Mode      `json:"mode,omitempty"`
+}
+
+type ChatQuickSaveParams struct {
+	SessionID string `json:"session_id"`
+	Title     string `json:"title,omitempty"`
+}
+
 type InterruptParams struct {
 	SessionID string `json:"session_id"`
 }
@@ -45,13 +146,43 @@ type PermissionResponseParams struct {
 	ToolInput             json.RawMessage          `json:"tool_input,omitempty"`
 	ToolUseID             string                   `json:"tool_use_id,omitempty"`
 	PermissionSuggestions []agent.PermissionUpdate `json:"permission_suggestions,omitempty"`
+	// ApplyToGroup gives the same answer to every other pending request
+	// sharing this one's group.
+	ApplyToGroup bool `json:"apply_to_group,omitempty"`
+	// QuickReplyID sends that template's text as a follow-up message.
+	QuickReplyID string `json:"quick_reply_id,omitempty"`
+}
+
+type PermissionResponseResult struct {
+	// GroupAnswered lists the other requests answered by ApplyToGroup.
+	GroupAnswered []string `json:"group_answered,omitempty"`
+	// QuickReplySent is false when the response was delivered but the
+	// follow-up message could not be sent.
+	QuickReplySent bool `json:"quick_reply_sent,omitempty"`
 }
 
 type QuestionResponseParams struct {
-	SessionID string            `json:"session_id"`
-	RequestID string            `json:"request_id"`
-	ToolUseID string            `json:"tool_use_id"`
-	Answers   map[string]string `json:"answers"` // nil = cancel
+	SessionID    string            `json:"session_id"`
+	RequestID    string            `json:"request_id"`
+	ToolUseID    string            `json:"tool_use_id"`
+	Answers      map[string]string `json:"answers"` // nil = cancel
+	QuickReplyID string            `json:"quick_reply_id,omitempty"`
+}
+
+type QuestionResponseResult struct {
+	QuickReplySent bool `json:"quick_reply_sent,omitempty"`
+}
+
+// ChatToolResultGetParams fetches the full output of a truncated tool result
+// (a tool_result event carrying tool_result_size).
+type ChatToolResultGetParams struct {
+	SessionID string `json:"session_id"`
+	ToolUseID string `json:"tool_use_id"`
+}
+
+type ChatToolResultGetResult struct {
+	ToolUseID  string `json:"tool_use_id"`
+	ToolResult string `json:"tool_result"`
 }
 
 // Session management
@@ -60,6 +191,14 @@ type SessionDeleteParams struct {
 	SessionID string `json:"session_id"`
 }
 
+type SessionTrashListResult struct {
+	Sessions []session.TrashedSession `json:"sessions"`
+}
+
+type SessionRestoreParams struct {
+	SessionID string `json:"session_id"`
+}
+
 type SessionUpdateTitleParams struct {
 	SessionID string `json:"session_id"`
 	Title     string `json:"title"`
@@ -79,6 +218,43 @@ type SessionMarkReadParams struct {
 	SessionID string `json:"session_id"`
 }
 
+// SessionToolCallsParams filters session.toolcalls (see
+// session.ToolCallFilter); omitted fields match everything.
+type SessionToolCallsParams struct {
+	SessionID string     `json:"session_id"`
+	Tool      string     `json:"tool,omitempty"`
+	Path      string     `json:"path,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+}
+
+type SessionToolCallsResult struct {
+	ToolCalls []session.ToolCall `json:"tool_calls"`
+}
+
+// Process namespace
+
+// ProcessKeepAliveParams restarts the session's idle timer. KeepAlive, when
+// set, also persists whether the idle reaper skips the session.
+type ProcessKeepAliveParams struct {
+	SessionID string `json:"session_id"`
+	KeepAlive *bool  `json:"keep_alive,omitempty"`
+}
+
+type ProcessKeepAliveResult struct {
+	KeepAlive bool `json:"keep_alive"`
+	Running   bool `json:"running"` // false when no process is alive to touch
+}
+
+// ProcessRecoverParams acts on a session reported by process.possibly_stuck.
+// Action is "interrupt" (cancel the turn) or "restart" (replace the process,
+// resuming the session); Message, if set, is sent after a restart.
+type ProcessRecoverParams struct {
+	SessionID string `json:"session_id"`
+	Action    string `json:"action"`
+	Message   string `json:"message,omitempty"`
+}
+
 // File namespace
 
 type FileGetParams struct {
@@ -91,19 +267,55 @@ type FileGetResult struct {
 	File    *contents.FileContent `json:"file,omitempty"`
 }
 
+// FileWriteParams writes Content to Path. With BaseHash (the hash file.get
+// returned) the write is refused with CodeFileConflict if the file has
+// changed since.
 type FileWriteParams struct {
-	Path    string `json:"path"`
-	Content string `json:"content"`
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	BaseHash string `json:"base_hash,omitempty"`
+}
+
+type FileWriteResult struct {
+	Hash string `json:"hash"`
+}
+
+// CodeFileConflict is the JSON-RPC error code of a refused file.write; the
+// error data is a FileConflictData.
+const CodeFileConflict int64 = -32010
+
+type FileConflictData struct {
+	Reason ErrorReason           `json:"reason"` // always ErrConflict
+	Path   string                `json:"path"`
+	File   *contents.FileContent `json:"file"` // nil if the file was deleted
 }
 
 type FileDeleteParams struct {
 	Path string `json:"path"`
 }
 
+type FileOutlineParams struct {
+	Path string `json:"path"`
+}
+
+type FileOutlineResult struct {
+	Path    string           `json:"path"`
+	Symbols []outline.Symbol `json:"symbols"`
+}
+
 // Git namespace
 
 type GitStatusResult = git.GitStatus
 
+// GitFetchResult reports whether git.fetch ran. When the previous fetch
+// was too recent it is skipped, with RetryAfterSeconds until the next one
+// is allowed; Branch is the current divergence either way.
+type GitFetchResult struct {
+	Fetched           bool              `json:"fetched"`
+	RetryAfterSeconds int               `json:"retry_after_seconds,omitempty"`
+	Branch            *git.BranchStatus `json:"branch"`
+}
+
 // Git diff watch (subscription for file-specific diff changes)
 
 type GitDiffSubscribeParams struct {
@@ -113,10 +325,12 @@ type GitDiffSubscribeParams struct {
 }
 
 type GitDiffSubscribeResult struct {
-	ID         string `json:"id"`
-	Diff       string `json:"diff"`
-	OldContent string `json:"old_content"`
-	NewContent string `json:"new_content"`
+	ID         string         `json:"id"`
+	Diff       string         `json:"diff"`
+	OldContent string         `json:"old_content"`
+	NewContent string         `json:"new_content"`
+	Hunks      []git.DiffHunk `json:"hunks,omitempty"`
+	Binary     bool           `json:"binary,omitempty"`
 }
 
 type GitDiffUnsubscribeParams struct {
@@ -128,14 +342,33 @@ type GitPathsParams struct {
 	Paths []string `json:"paths"`
 }
 
-// GitLogParams is the params for git.log request.
+// GitAddHunksParams is the params for git.add.hunks. Hunks are ranges from
+// the file's unstaged structured diff (hide_whitespace off).
+type GitAddHunksParams struct {
+	Path  string          `json:"path"`
+	Hunks []git.HunkRange `json:"hunks"`
+}
+
+// GitLogParams is the params for git.log request. Filters combine: Path
+// (a file or directory), Author (a git --author pattern), Since and Until
+// (RFC 3339), and WorkID, which keeps commits since the work item was
+// created that touch files its sessions or its descendants' changed. Cursor
+// is a previous page's NextCursor; the filters must stay the same.
 type GitLogParams struct {
-	Limit int `json:"limit,omitempty"` // default 50
+	Limit  int    `json:"limit,omitempty"` // default 50
+	Path   string `json:"path,omitempty"`
+	Author string `json:"author,omitempty"`
+	Since  string `json:"since,omitempty"`
+	Until  string `json:"until,omitempty"`
+	WorkID string `json:"work_id,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
 }
 
-// GitLogResult is the result of git.log request.
+// GitLogResult is the result of git.log request. NextCursor is empty on the
+// last page.
 type GitLogResult struct {
-	Commits []git.Commit `json:"commits"`
+	Commits    []git.Commit `json:"commits"`
+	NextCursor string       `json:"next_cursor,omitempty"`
 }
 
 // GitShowParams is the params for git.show request.
@@ -158,6 +391,10 @@ type GitShowDiffResult = git.DiffResult
 
 // Command namespace
 
+type CommandListParams struct {
+	AgentType session.AgentType `json:"agent_type,omitempty"` // empty = settings default
+}
+
 type CommandListResult struct {
 	Commands []command.Command `json:"commands"`
 }
@@ -176,6 +413,19 @@ type FSUnsubscribeParams struct {
 	ID string `json:"id"`
 }
 
+// Workspace namespace
+
+// WorkspaceSubscribeParams lists the paths to watch as fs.subscribe does;
+// the worktree's git state is always watched.
+type WorkspaceSubscribeParams struct {
+	Paths []string `json:"paths"`
+}
+
+type WorkspaceSubscribeResult struct {
+	ID  string         `json:"id"`
+	Git *git.GitStatus `json:"git"`
+}
+
 // Git namespace
 
 type GitSubscribeResult struct {
@@ -201,6 +451,11 @@ type WorktreeUnsubscribeParams struct {
 type SessionListItem struct {
 	session.SessionMeta
 	State string `json:"state"` // "idle" | "running" | "ended"
+	// WorkID is the work item whose session this is, and Worktree the
+	// worktree whose work store holds it ("" for the main store). Both are
+	// resolved server-side so clients can group sessions by story.
+	WorkID   string `json:"work_id,omitempty"`
+	Worktree string `json:"worktree,omitempty"`
 }
 
 type SessionListSubscribeResult struct {
@@ -214,45 +469,131 @@ type SessionListUnsubscribeParams struct {
 
 // Chat messages watch (subscription for chat messages)
 
+// ChatMessagesSubscribeParams subscribes to a session's events. Types, when
+// set, limits history and notifications to those event types (user
+// messages are always included); chat.messages.turn fetches the rest.
 type ChatMessagesSubscribeParams struct {
-	SessionID string `json:"session_id"`
+	SessionID string            `json:"session_id"`
+	Limit     int               `json:"limit,omitempty"` // latest records to return; 0 = full history
+	Types     []agent.EventType `json:"types,omitempty"`
 }
 
+// ChatMessagesSubscribeResult carries the latest history page. Cursor is the
+// index of its first record; older records exist when HasMore is set and are
+// loaded with chat.messages.page. Indexes, set only with Types, is the
+// history index of each record, for chat.messages.turn.
 type ChatMessagesSubscribeResult struct {
 	ID        string            `json:"id"`
 	History   []json.RawMessage `json:"history"`
+	Cursor    int               `json:"cursor"`
+	HasMore   bool              `json:"has_more"`
+	Indexes   []int             `json:"indexes,omitempty"`
 	State     string            `json:"state"` // "idle" | "running" | "ended"
 	Mode      session.Mode      `json:"mode"`
 	AgentType session.AgentType `json:"agent_type"`
 }
 
+// ChatMessagesPageParams reads the records before Before (a cursor from
+// chat.messages.subscribe or a previous page). 0 Limit =
+// session.DefaultHistoryPageLimit. Types filters the page as in
+// ChatMessagesSubscribeParams; Limit counts records before filtering.
+type ChatMessagesPageParams struct {
+	SessionID string            `json:"session_id"`
+	Before    int               `json:"before"`
+	Limit     int               `json:"limit,omitempty"`
+	Types     []agent.EventType `json:"types,omitempty"`
+}
+
+type ChatMessagesPageResult struct {
+	History []json.RawMessage `json:"history"`
+	Cursor  int               `json:"cursor"`
+	HasMore bool              `json:"has_more"`
+	Indexes []int             `json:"indexes,omitempty"`
+}
+
+// ChatMessagesTurnParams reads every record of the turn containing history
+// index At, including the event types a filtered subscription left out. A
+// negative At selects the latest turn.
+type ChatMessagesTurnParams struct {
+	SessionID string `json:"session_id"`
+	At        int    `json:"at"`
+}
+
+// ChatMessagesTurnResult is the turn's records; Cursor is the history
+// index of the first.
+type ChatMessagesTurnResult struct {
+	History []json.RawMessage `json:"history"`
+	Cursor  int               `json:"cursor"`
+}
+
 type ChatMessagesUnsubscribeParams struct {
 	ID string `json:"id"`
 }
 
+// MCP servers namespace (bound worktree)
+
+type MCPServersResult struct {
+	Servers []agent.MCPServer `json:"servers"`
+}
+
+type MCPServersSetParams struct {
+	Servers []agent.MCPServer `json:"servers"`
+}
+
+// Quick replies namespace (bound worktree)
+
+type QuickRepliesListParams struct {
+	Kind string `json:"kind,omitempty"` // "permission" | "question"; empty = all
+}
+
+type QuickRepliesResult struct {
+	QuickReplies []chat.QuickReply `json:"quick_replies"`
+}
+
+type QuickRepliesSetParams struct {
+	QuickReplies []chat.QuickReply `json:"quick_replies"`
+}
+
 // Worktree namespace
 
 type WorktreeInfo struct {
-	Name   string `json:"name"`
-	Path   string `json:"path"`
-	Branch string `json:"branch"`
-	IsMain bool   `json:"is_main"`
+	Name   string     `json:"name"`
+	Path   string     `json:"path"`
+	Branch string     `json:"branch"`
+	IsMain bool       `json:"is_main"`
+	CI     *ci.Status `json:"ci,omitempty"` // Absent until the branch is pushed and polled
+	// Absent for worktrees not created since server start.
+	Setup *setup.Status `json:"setup,omitempty"`
+	// The worktree keeps its work in its own store instead of the main one.
+	IsolatedWork bool `json:"isolated_work,omitempty"`
 }
 
 type WorktreeListResult struct {
 	Worktrees []WorktreeInfo `json:"worktrees"`
 }
 
+// WorktreeCreateParams creates a worktree. With settings.branch_template
+// set, Branch may be omitted to generate it from the template, using
+// WorkID's short ID and title; a given Branch must match the template.
 type WorktreeCreateParams struct {
 	Name       string `json:"name"`
-	Branch     string `json:"branch"`
+	Branch     string `json:"branch,omitempty"`
 	BaseBranch string `json:"base_branch,omitempty"`
+	WorkID     string `json:"work_id,omitempty"`
+	// Give the worktree its own work store, kept off the main board. Work
+	// created by its agents lands there; work.board merges every store.
+	IsolatedWork bool `json:"isolated_work,omitempty"`
 }
 
 type WorktreeCreateResult struct {
 	Worktree WorktreeInfo `json:"worktree"`
 }
 
+type WorktreeSetupStatusSubscribeResult struct {
+	ID       string         `json:"id"`
+	Statuses []setup.Status `json:"statuses"`
+}
+
 type WorktreeDeleteParams struct {
 	Name string `json:"name"`
 }
@@ -265,14 +606,93 @@ type WorktreeDeletedParams struct {
 // WorktreeSwitchParams is the params for the worktree.switch request.
 type WorktreeSwitchParams struct {
 	Name string `json:"name"` // empty = main worktree
+	// CarryOver re-establishes the old worktree's subscriptions on the new
+	// one instead of dropping them.
+	CarryOver bool `json:"carry_over,omitempty"`
 }
 
 // WorktreeSwitchResult is the result of the worktree.switch request.
 type WorktreeSwitchResult struct {
+	WorkDir       string                `json:"work_dir"`
+	WorktreeName  string                `json:"worktree_name"`
+	Subscriptions []CarriedSubscription `json:"subscriptions,omitempty"` // with carry_over
+}
+
+// CarriedSubscription is one old-worktree subscription worktree.switch
+// tried to re-establish. Result is what Method would have returned for the
+// new worktree, including the new subscription ID; when that failed, Reason
+// and Error say why and the subscription is gone.
+type CarriedSubscription struct {
+	PrevID string      `json:"prev_id"`
+	Method string      `json:"method"`
+	Result any         `json:"result,omitempty"`
+	Reason ErrorReason `json:"reason,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// WorktreeAttachParams binds an additional worktree to the connection.
+// Worktree-level methods then target it via their "worktree" param.
+type WorktreeAttachParams struct {
+	Name string `json:"name"`
+}
+
+type WorktreeAttachResult struct {
 	WorkDir      string `json:"work_dir"`
 	WorktreeName string `json:"worktree_name"`
 }
 
+type WorktreeDetachParams struct {
+	Name string `json:"name"`
+}
+
+type AgentHealthcheckParams struct {
+	AgentType session.AgentType `json:"agent_type,omitempty"` // empty = settings default
+}
+
+type AgentHealthcheckResult struct {
+	AgentType session.AgentType `json:"agent_type"`
+	agent.HealthReport
+}
+
+// EventsSinceParams asks for the change events after Seq. Without Seq only
+// the current position is returned: take it before loading full lists.
+type EventsSinceParams struct {
+	Seq   *int64 `json:"seq,omitempty"`
+	Limit int    `json:"limit,omitempty"` // 0 = eventlog.DefaultSinceLimit
+}
+
+// WorktreeRefsResult lists what keeps each loaded worktree alive.
+type WorktreeRefsResult struct {
+	Worktrees []WorktreeRefs `json:"worktrees"`
+}
+
+type WorktreeRefs struct {
+	Worktree  string              `json:"worktree"`
+	RefCount  int                 `json:"ref_count"`
+	Processes int                 `json:"processes"` // running agent processes; also block cleanup
+	Holders   []WorktreeRefHolder `json:"holders"`
+}
+
+// WorktreeRefHolder is a connection (by ID) or "internal" holding
+// references to a worktree.
+type WorktreeRefHolder struct {
+	Holder     string     `json:"holder"`
+	Count      int        `json:"count"`
+	AcquiredAt time.Time  `json:"acquired_at"`
+	Connected  bool       `json:"connected"`           // the holder is a live connection
+	LastSeen   *time.Time `json:"last_seen,omitempty"` // last request or pong of a live connection
+	Self       bool       `json:"self,omitempty"`      // the caller's own connection
+}
+
+type WorktreeForceReleaseParams struct {
+	Name   string `json:"name"`
+	Holder string `json:"holder"`
+}
+
+type WorktreeForceReleaseResult struct {
+	Released int `json:"released"`
+}
+
 // Server → Client (used in tests for notification parsing)
 
 type PermissionRequestParams struct {
@@ -298,8 +718,105 @@ type SettingsSubscribeResult struct {
 	Settings settings.Settings `json:"settings"`
 }
 
+// SettingsUpdateParams replaces all settings. Keys Settings does not know
+// are refused unless AllowUnknownFields is set, in which case they are
+// dropped. DryRun validates and reports the result without saving it.
 type SettingsUpdateParams struct {
-	Settings settings.Settings `json:"settings"`
+	Settings           settings.Settings `json:"settings"`
+	DryRun             bool              `json:"dry_run,omitempty"`
+	AllowUnknownFields bool              `json:"allow_unknown_fields,omitempty"`
+}
+
+type SettingsUpdateResult struct {
+	DryRun bool `json:"dry_run,omitempty"`
+	// Settings as stored, and with defaults filled in for empty fields.
+	Settings  settings.Settings `json:"settings"`
+	Effective settings.Settings `json:"effective"`
+	// Unknown keys dropped under AllowUnknownFields.
+	IgnoredFields []string `json:"ignored_fields,omitempty"`
+}
+
+// Client preferences namespace
+
+type ClientPrefsGetParams struct {
+	ClientID string `json:"client_id"`
+}
+
+// ClientPrefsSetParams merges Prefs into the client's preferences; a null
+// value removes its key.
+type ClientPrefsSetParams struct {
+	ClientID string               `json:"client_id"`
+	Prefs    settings.ClientPrefs `json:"prefs"`
+}
+
+// ClientPrefsResult holds the client's preferences, after the change for
+// clientprefs.set.
+type ClientPrefsResult struct {
+	Prefs settings.ClientPrefs `json:"prefs"`
+}
+
+// Devices namespace
+
+// DevicesPairParams mints a pairing code. TTLSeconds 0 uses the default
+// (10 minutes); the maximum is one hour.
+type DevicesPairParams struct {
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+// DeviceItem is a paired device; Current marks the one this connection
+// authenticated as.
+type DeviceItem struct {
+	pairing.Device
+	Current bool `json:"current,omitempty"`
+}
+
+type DevicesListResult struct {
+	Devices []DeviceItem `json:"devices"`
+}
+
+type DevicesRevokeParams struct {
+	ID string `json:"id"`
+}
+
+// Server namespace
+
+// ServerDrainParams starts a graceful shutdown. TimeoutSeconds bounds the
+// wait for running agent turns; 0 uses the server's --drain-timeout.
+type ServerDrainParams struct {
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// Fsck namespace
+
+type FsckRunParams struct {
+	// Repair applies the safe fixes after taking a snapshot.
+	Repair bool `json:"repair,omitempty"`
+}
+
+// Snapshot namespace
+
+type SnapshotCreateParams struct {
+	Label string `json:"label,omitempty"`
+}
+
+type SnapshotListResult struct {
+	Snapshots []snapshot.Info `json:"snapshots"`
+}
+
+type SnapshotRestoreParams struct {
+	Name string `json:"name"`
+}
+
+// SnapshotRestoreResult reports that the restore is scheduled; it is
+// applied when the server next starts.
+type SnapshotRestoreResult struct {
+	RestartRequired bool `json:"restart_required"`
+}
+
+// Digest namespace
+
+type DigestPreviewParams struct {
+	Hours int `json:"hours,omitempty"` // lookback window; default 24
 }
 
 // Work namespace
@@ -310,21 +827,49 @@ type WorkCreateParams struct {
 	AgentRoleID string        `json:"agent_role_id"`
 	Title       string        `json:"title"`
 	Body        string        `json:"body,omitempty"`
+	DueAt       string        `json:"due_at,omitempty"` // RFC 3339
+}
+
+// WorkBulkCreateParams creates several work items at once, all or nothing.
+// Parents must already exist.
+type WorkBulkCreateParams struct {
+	Items []WorkCreateParams `json:"items"`
+}
+
+// WorkBulkCreateResult lists the created items in request order.
+type WorkBulkCreateResult struct {
+	Items []work.Work `json:"items"`
 }
 
 type WorkUpdateParams struct {
-	ID          string  `json:"id"`
-	Title       *string `json:"title,omitempty"`
-	Body        *string `json:"body,omitempty"`
-	AgentRoleID *string `json:"agent_role_id,omitempty"`
+	ID              string  `json:"id"`
+	Title           *string `json:"title,omitempty"`
+	Body            *string `json:"body,omitempty"`
+	AgentRoleID     *string `json:"agent_role_id,omitempty"`
+	EstimateMinutes *int    `json:"estimate_minutes,omitempty"`
+	DueAt           *string `json:"due_at,omitempty"` // RFC 3339; "" clears
+	// Budget replaces the work's budget; {} removes it.
+	Budget *work.Budget `json:"budget,omitempty"`
+	// ClosePolicy sets the close policy; "" inherits the parent's again.
+	ClosePolicy *work.ClosePolicy `json:"close_policy,omitempty"`
+}
+
+// WorkApproveCloseParams closes a work item whose close policy waits for
+// the user's approval.
+type WorkApproveCloseParams struct {
+	ID string `json:"id"`
 }
 
 type WorkDeleteParams struct {
 	ID string `json:"id"`
 }
 
+// WorkStartParams optionally overrides the agent role and session mode for
+// the started session without modifying the work item's own role.
 type WorkStartParams struct {
-	ID string `json:"id"`
+	ID          string       `json:"id"`
+	AgentRoleID string       `json:"agent_role_id,omitempty"`
+	Mode        session.Mode `json:"mode,omitempty"`
 }
 
 type WorkStopParams struct {
@@ -335,11 +880,122 @@ type WorkReopenParams struct {
 	ID string `json:"id"`
 }
 
+type WorkResetParams struct {
+	ID string `json:"id"`
+}
+
+type WorkCancelParams struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// WorkWatchParams sets a work item's notification flag: "watch", "mute", or
+// "" to inherit its parent's again.
+type WorkWatchParams struct {
+	ID     string          `json:"id"`
+	Notify work.NotifyMode `json:"notify"`
+}
+
+// WorkReportParams requests the effort report (work.EffortReport) of a work
+// item and its descendants.
+type WorkReportParams struct {
+	ID string `json:"id"`
+}
+
+// WorkBulkParams applies several work operations in one request
+// (work.Operations.Bulk).
+type WorkBulkParams struct {
+	Operations []work.BulkOp `json:"operations"`
+}
+
+// WorkBulkResult reports each operation's outcome in request order.
+type WorkBulkResult struct {
+	Results []work.BulkResult `json:"results"`
+}
+
+// WorkPlanApproveParams approves a submitted plan. Mode is the act mode the
+// session switches to (default when empty; plan is rejected).
+type WorkPlanApproveParams struct {
+	ID   string       `json:"id"`
+	Mode session.Mode `json:"mode,omitempty"`
+}
+
+type WorkPlanRejectParams struct {
+	ID       string `json:"id"`
+	Feedback string `json:"feedback"`
+}
+
+// WorkListParams filters, sorts, and paginates a one-shot work list.
+type WorkListParams struct {
+	work.ListQuery
+}
+
+type WorkListResult struct {
+	Items []work.Work `json:"items"`
+	Total int         `json:"total"`
+}
+
+// WorkBoardParams filters, sorts, and paginates the work of every
+// worktree's store merged into one list.
+type WorkBoardParams struct {
+	work.ListQuery
+}
+
+// WorkBoardItem is a work on the merged board, with the worktree whose
+// store holds it ("" for the main store).
+type WorkBoardItem struct {
+	work.Work
+	Worktree string `json:"worktree,omitempty"`
+}
+
+type WorkBoardResult struct {
+	Items []WorkBoardItem `json:"items"`
+	Total int             `json:"total"`
+}
+
+// WorkSearchParams ranks works against a free-text query.
+type WorkSearchParams struct {
+	work.SearchQuery
+}
+
+type WorkSearchResult struct {
+	Hits  []work.SearchHit `json:"hits"`
+	Total int              `json:"total"`
+}
+
+// WorkNextParams asks for the work item to start next (see work.Next).
+type WorkNextParams struct {
+	work.NextQuery
+}
+
+// WorkNextResult holds the item to start next; Work is null when nothing
+// is ready.
+type WorkNextResult struct {
+	Work *work.Work `json:"work"`
+}
+
 type WorkListSubscribeResult struct {
 	ID    string      `json:"id"`
 	Items []work.Work `json:"items"`
 }
 
+// WorkStatsParams narrows work.stats; the result is work.Stats.
+type WorkStatsParams struct {
+	Weeks int           `json:"weeks,omitempty"` // 0 = work.DefaultStatsWeeks
+	Type  work.WorkType `json:"type,omitempty"`  // "" = stories and tasks
+}
+
+type WorkFilesParams struct {
+	WorkID string `json:"work_id"`
+}
+
+// WorkFilesResult lists the files changed by a work item's sessions and its
+// descendants': Files is the sorted union, ByWork the per-item lists.
+type WorkFilesResult struct {
+	Files  []string         `json:"files"`
+	ByWork []work.WorkFiles `json:"by_work"`
+}
+
 type WorkCommentListParams struct {
 	WorkID string `json:"work_id"`
 }
@@ -353,35 +1009,145 @@ type WorkCommentUpdateParams struct {
 	Body string `json:"body"`
 }
 
+// Data fields are base64 in JSON. Large files are better sent to the
+// HTTP endpoint work.AttachmentPath.
+type WorkAttachmentUploadParams struct {
+	WorkID string `json:"work_id"`
+	Name   string `json:"name"`
+	Data   []byte `json:"data"`
+}
+
+type WorkAttachmentListParams struct {
+	WorkID string `json:"work_id"`
+}
+
+type WorkAttachmentListResult struct {
+	Attachments []work.Attachment `json:"attachments"`
+}
+
+type WorkAttachmentParams struct {
+	WorkID string `json:"work_id"`
+	Name   string `json:"name"`
+}
+
+type WorkAttachmentGetResult struct {
+	Attachment work.Attachment `json:"attachment"`
+	Data       []byte          `json:"data"`
+}
+
 type WorkDetailSubscribeParams struct {
 	WorkID string `json:"work_id"`
 }
 
+// WorkSubscribeParams follows one work item, and with IncludeChildren its
+// descendants, via work.changed notifications.
+type WorkSubscribeParams struct {
+	WorkID          string `json:"work_id"`
+	IncludeChildren bool   `json:"include_children,omitempty"`
+}
+
+type WorkSubscribeResult struct {
+	ID    string      `json:"id"`
+	Items []work.Work `json:"items"` // root first
+}
+
 type WorkDetailSubscribeResult struct {
 	ID       string         `json:"id"`
 	Work     work.Work      `json:"work"`
 	Comments []work.Comment `json:"comments"`
 }
 
+// CI namespace
+
+type CIStatusSubscribeResult struct {
+	ID       string      `json:"id"`
+	Statuses []ci.Status `json:"statuses"`
+}
+
+// TestRun namespace
+
+// TestRunListParams filters runs by work item and/or session; newest first.
+type TestRunListParams struct {
+	testrun.ListQuery
+}
+
+type TestRunListResult struct {
+	Runs []testrun.Run `json:"runs"`
+}
+
+type TestRunSubscribeResult struct {
+	ID string `json:"id"`
+}
+
+// Autorun namespace
+
+// AutorunSlot is one agent role's slot occupancy: roles with a
+// max_concurrent cap, and any role with a session holding a slot.
+type AutorunSlot struct {
+	AgentRoleID string   `json:"agent_role_id"`
+	Name        string   `json:"name,omitempty"` // empty for a deleted role
+	Used        int      `json:"used"`
+	Max         int      `json:"max,omitempty"` // 0 = no cap
+	SessionIDs  []string `json:"session_ids"`
+}
+
+type AutorunSlotsResult struct {
+	Slots []AutorunSlot `json:"slots"`
+}
+
+// Sync namespace
+
+// SyncStatusParams narrows sync.status to one work item; empty lists all.
+// The result is issuesync.Status.
+type SyncStatusParams struct {
+	WorkID string `json:"work_id,omitempty"`
+}
+
 // AgentRole namespace
 
 type AgentRoleCreateParams struct {
-	Name       string   `json:"name"`
-	RolePrompt string   `json:"role_prompt"`
-	Steps      []string `json:"steps,omitempty"`
+	Name               string            `json:"name"`
+	RolePrompt         string            `json:"role_prompt"`
+	Steps              []string          `json:"steps,omitempty"`
+	IdleTimeoutMinutes int               `json:"idle_timeout_minutes,omitempty"`
+	AgentType          session.AgentType `json:"agent_type,omitempty"`
+	MCPServers         []agent.MCPServer `json:"mcp_servers,omitempty"`
+	MaxConcurrent      int               `json:"max_concurrent,omitempty"`
 }
 
 type AgentRoleUpdateParams struct {
-	ID         string    `json:"id"`
-	Name       *string   `json:"name,omitempty"`
-	RolePrompt *string   `json:"role_prompt,omitempty"`
-	Steps      *[]string `json:"steps,omitempty"`
+	ID                 string             `json:"id"`
+	Name               *string            `json:"name,omitempty"`
+	RolePrompt         *string            `json:"role_prompt,omitempty"`
+	Steps              *[]string          `json:"steps,omitempty"`
+	IdleTimeoutMinutes *int               `json:"idle_timeout_minutes,omitempty"`
+	AgentType          *session.AgentType `json:"agent_type,omitempty"`
+	MCPServers         *[]agent.MCPServer `json:"mcp_servers,omitempty"`
+	MaxConcurrent      *int               `json:"max_concurrent,omitempty"`
 }
 
 type AgentRoleDeleteParams struct {
 	ID string `json:"id"`
 }
 
+// AgentRoleExportParams selects the roles to export; empty IDs exports all.
+type AgentRoleExportParams struct {
+	IDs  []string `json:"ids,omitempty"`
+	Name string   `json:"name,omitempty"`
+}
+
+// AgentRoleImportParams imports a rolepack. Conflict defaults to skip.
+type AgentRoleImportParams struct {
+	Pack     agentrole.RolePack       `json:"pack"`
+	Conflict agentrole.ConflictPolicy `json:"conflict,omitempty"`
+	DryRun   bool                     `json:"dry_run,omitempty"`
+}
+
+type AgentRoleImportResult struct {
+	DryRun bool                   `json:"dry_run"`
+	Items  []agentrole.ImportItem `json:"items"`
+}
+
 type AgentRoleListSubscribeResult struct {
 	ID    string                `json:"id"`
 	Items []agentrole.AgentRole `json:"items"`
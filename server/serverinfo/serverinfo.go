@@ -22,11 +22,15 @@ type Info struct {
 	// server's local API. It is randomly generated at each startup, so it never
 	// outlives the process and is not the user-facing --auth-token.
 	Token string `json:"token,omitempty"`
+	// MCPSocket is the unix socket serving the local MCP API, preferred over
+	// LocalURL by the MCP subprocess. Empty when the socket could not be
+	// created.
+	MCPSocket string `json:"mcp_socket,omitempty"`
 }
 
 // Write creates the server.json file in the given data directory.
 // Creates the data directory if it doesn't exist.
-func Write(dataDir string, port int, localURL, remoteURL, token string) error {
+func Write(dataDir string, port int, localURL, remoteURL, token, mcpSocket string) error {
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		return err
 	}
@@ -38,6 +42,7 @@ func Write(dataDir string, port int, localURL, remoteURL, token string) error {
 		LocalURL:  localURL,
 		RemoteURL: remoteURL,
 		Token:     token,
+		MCPSocket: mcpSocket,
 	}
 
 	data, err := json.MarshalIndent(info, "", "  ")
@@ -2,6 +2,7 @@ package session
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -12,8 +13,77 @@ import (
 // Call at server startup before any session stores are created,
 // since no agent processes survive a restart.
 func ClearOrphanedNeedsInput(dataDir string) {
+	forEachIndex(dataDir, func(path string) {
+		rewriteIndex(path, func(idx *indexData) bool {
+			changed := false
+			for i := range idx.Sessions {
+				if idx.Sessions[i].NeedsInput {
+					idx.Sessions[i].NeedsInput = false
+					changed = true
+				}
+			}
+			return changed
+		})
+	})
+}
+
+// RemoveOrphanedEphemeral deletes quick-chat sessions left behind by a
+// restart. They would normally be deleted when their process ends, which
+// never happens for a process that did not survive the restart.
+// Call at server startup before any session stores are created.
+func RemoveOrphanedEphemeral(dataDir string) {
+	forEachIndex(dataDir, func(path string) {
+		sessionsDir := filepath.Dir(path)
+		rewriteIndex(path, func(idx *indexData) bool {
+			kept := idx.Sessions[:0]
+			for _, sess := range idx.Sessions {
+				if !sess.Ephemeral {
+					kept = append(kept, sess)
+					continue
+				}
+				if err := os.RemoveAll(filepath.Join(sessionsDir, sess.ID)); err != nil {
+					slog.Warn("failed to remove ephemeral session", "sessionId", sess.ID, "error", err)
+				}
+			}
+			changed := len(kept) != len(idx.Sessions)
+			idx.Sessions = kept
+			return changed
+		})
+	})
+}
+
+// IndexedIDs returns the IDs of the sessions in the index of the main data
+// directory and of every worktree subdirectory, read without opening a
+// store. A missing index counts as empty.
+func IndexedIDs(dataDir string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	var firstErr error
+	forEachIndex(dataDir, func(path string) {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return
+		}
+		if err == nil {
+			var idx indexData
+			if err = json.Unmarshal(data, &idx); err == nil {
+				for _, sess := range idx.Sessions {
+					ids[sess.ID] = true
+				}
+				return
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("read session index %s: %w", path, err)
+		}
+	})
+	return ids, firstErr
+}
+
+// forEachIndex calls fn with the session index path of the main data
+// directory and of every worktree subdirectory.
+func forEachIndex(dataDir string, fn func(path string)) {
 	// Main sessions
-	clearNeedsInputInIndex(filepath.Join(dataDir, "sessions", "index.json"))
+	fn(filepath.Join(dataDir, "sessions", "index.json"))
 
 	// Worktree sessions
 	worktreesDir := filepath.Join(dataDir, "worktrees")
@@ -25,11 +95,13 @@ func ClearOrphanedNeedsInput(dataDir string) {
 		if !entry.IsDir() {
 			continue
 		}
-		clearNeedsInputInIndex(filepath.Join(worktreesDir, entry.Name(), "sessions", "index.json"))
+		fn(filepath.Join(worktreesDir, entry.Name(), "sessions", "index.json"))
 	}
 }
 
-func clearNeedsInputInIndex(path string) {
+// rewriteIndex applies mutate to the index at path and writes it back when
+// mutate reports a change.
+func rewriteIndex(path string, mutate func(idx *indexData) bool) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return // File doesn't exist
@@ -41,14 +113,7 @@ func clearNeedsInputInIndex(path string) {
 		return
 	}
 
-	changed := false
-	for i := range idx.Sessions {
-		if idx.Sessions[i].NeedsInput {
-			idx.Sessions[i].NeedsInput = false
-			changed = true
-		}
-	}
-	if !changed {
+	if !mutate(&idx) {
 		return
 	}
 
@@ -62,5 +127,5 @@ func clearNeedsInputInIndex(path string) {
 		return
 	}
 
-	slog.Info("cleared orphaned NeedsInput flags", "path", path)
+	slog.Info("cleaned up session index", "path", path)
 }
@@ -44,6 +44,38 @@ func TestClearOrphanedNeedsInput(t *testing.T) {
 	}
 }
 
+func TestRemoveOrphanedEphemeral(t *testing.T) {
+	dataDir := t.TempDir()
+
+	mainIdx := indexData{Sessions: []SessionMeta{
+		{ID: "s1"},
+		{ID: "q1", Ephemeral: true},
+	}}
+	writeIndex(t, filepath.Join(dataDir, "sessions", "index.json"), mainIdx)
+	historyDir := filepath.Join(dataDir, "sessions", "q1")
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	wtIdx := indexData{Sessions: []SessionMeta{
+		{ID: "wt-q1", Ephemeral: true},
+	}}
+	writeIndex(t, filepath.Join(dataDir, "worktrees", "feature-x", "sessions", "index.json"), wtIdx)
+
+	RemoveOrphanedEphemeral(dataDir)
+
+	main := readIndex(t, filepath.Join(dataDir, "sessions", "index.json"))
+	if len(main.Sessions) != 1 || main.Sessions[0].ID != "s1" {
+		t.Errorf("main sessions = %+v, want only s1", main.Sessions)
+	}
+	if _, err := os.Stat(historyDir); !os.IsNotExist(err) {
+		t.Errorf("expected ephemeral session directory to be removed, stat err = %v", err)
+	}
+	wt := readIndex(t, filepath.Join(dataDir, "worktrees", "feature-x", "sessions", "index.json"))
+	if len(wt.Sessions) != 0 {
+		t.Errorf("worktree sessions = %+v, want none", wt.Sessions)
+	}
+}
+
 func TestClearOrphanedNeedsInput_NoChange(t *testing.T) {
 	dataDir := t.TempDir()
 
@@ -0,0 +1,162 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// HistoryVersion is the format of the history records AppendToHistory
+// writes, stored in each record as "v". Records without it predate
+// versioning and are migrated on read.
+const HistoryVersion = 1
+
+// legacyFieldNames maps the camelCase keys the pre-RPC websocket handler
+// wrote to their EventRecord names.
+var legacyFieldNames = map[string]string{
+	"toolName":              "tool_name",
+	"toolInput":             "tool_input",
+	"toolUseId":             "tool_use_id",
+	"toolResult":            "tool_result",
+	"requestId":             "request_id",
+	"permissionSuggestions": "permission_suggestions",
+}
+
+// legacyTypes maps event types the pre-RPC handler used to their current
+// names.
+var legacyTypes = map[string]string{
+	"user":     "message",
+	"tool_use": "tool_call",
+}
+
+// legacyDroppedFields were routing data on the old wire format and are not
+// part of a record.
+var legacyDroppedFields = []string{"sessionId", "session_id"}
+
+// stampVersion adds the current version to a marshalled record object.
+func stampVersion(data []byte) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+	prefix := fmt.Appendf(nil, `{"v":%d`, HistoryVersion)
+	if len(data) == 2 {
+		return append(prefix, '}')
+	}
+	return append(append(prefix, ','), data[1:]...)
+}
+
+// migrateRecord normalizes a history line to the current format. Reports
+// false for a line that is not a record at all.
+func migrateRecord(line []byte) (json.RawMessage, bool) {
+	// Probe the type and version first: current records, nearly every line,
+	// are returned as-is without decoding every field.
+	var probe struct {
+		Type string `json:"type"`
+		V    int    `json:"v"`
+	}
+	if err := json.Unmarshal(line, &probe); err != nil || probe.Type == "" {
+		return nil, false
+	}
+	if probe.V >= HistoryVersion {
+		// Current, or written by a newer server that knows better.
+		record := make(json.RawMessage, len(line))
+		copy(record, line)
+		return record, true
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return nil, false
+	}
+
+	for old, current := range legacyFieldNames {
+		if v, ok := fields[old]; ok {
+			if _, exists := fields[current]; !exists {
+				fields[current] = v
+			}
+			delete(fields, old)
+		}
+	}
+	for _, key := range legacyDroppedFields {
+		delete(fields, key)
+	}
+	if current, ok := legacyTypes[probe.Type]; ok {
+		fields["type"], _ = json.Marshal(current)
+	}
+	fields["v"], _ = json.Marshal(HistoryVersion)
+
+	record, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return record, true
+}
+
+// corruptRecord stands in for a line that could not be read, keeping the
+// record indexes paging relies on.
+func corruptRecord(index int) json.RawMessage {
+	record, _ := json.Marshal(map[string]any{
+		"v":       HistoryVersion,
+		"type":    "warning",
+		"message": fmt.Sprintf("History entry %d could not be read and was skipped", index),
+		"code":    "history_record_corrupt",
+	})
+	return record
+}
+
+func (s *FileStore) quarantinePath(sessionID string) string {
+	return filepath.Join(s.dataDir, "sessions", sessionID, "history.quarantine.jsonl")
+}
+
+// quarantinedRecord is a line of history.quarantine.jsonl: an unreadable
+// history line, kept verbatim for inspection.
+type quarantinedRecord struct {
+	Index int    `json:"index"`
+	Line  string `json:"line"`
+}
+
+// quarantine copies unreadable history lines aside so they can be
+// inspected, once per line across reads. The history file itself is left
+// alone: it is append-only and rewriting it would race with appends.
+func (s *FileStore) quarantine(sessionID string, bad map[int][]byte) {
+	s.quarantineMu.Lock()
+	defer s.quarantineMu.Unlock()
+
+	path := s.quarantinePath(sessionID)
+	seen := make(map[int]bool)
+	if file, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for scanner.Scan() {
+			var q quarantinedRecord
+			if json.Unmarshal(scanner.Bytes(), &q) == nil {
+				seen[q.Index] = true
+			}
+		}
+		file.Close()
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		slog.Error("failed to open history quarantine", "sessionId", sessionID, "error", err)
+		return
+	}
+	defer file.Close()
+	for index, line := range bad {
+		if seen[index] {
+			continue
+		}
+		data, err := json.Marshal(quarantinedRecord{Index: index, Line: string(line)})
+		if err != nil {
+			continue
+		}
+		if _, err := file.Write(append(data, '\n')); err != nil {
+			slog.Error("failed to quarantine history record", "sessionId", sessionID, "index", index, "error", err)
+			return
+		}
+		slog.Warn("quarantined corrupt history record", "sessionId", sessionID, "index", index)
+	}
+}
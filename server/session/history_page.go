@@ -0,0 +1,116 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+const (
+	// DefaultHistoryPageLimit is the page size for chat.messages.page when
+	// none is given: a few screens of a typical conversation.
+	DefaultHistoryPageLimit = 200
+	MaxHistoryPageLimit     = 1000
+)
+
+// HistoryPage is a window of a session's history. Cursor is the index of
+// the first record in the full history; pass it as the next page's before.
+// History is append-only, so indexes stay valid while the session grows.
+// Indexes is only set on a filtered page: the history index of each record.
+type HistoryPage struct {
+	Records []json.RawMessage
+	Cursor  int
+	HasMore bool
+	Indexes []int
+}
+
+// ValidateHistoryPageLimit rejects limits outside 0..MaxHistoryPageLimit.
+func ValidateHistoryPageLimit(limit int) error {
+	if limit < 0 || limit > MaxHistoryPageLimit {
+		return fmt.Errorf("limit must be between 0 and %d", MaxHistoryPageLimit)
+	}
+	return nil
+}
+
+// PageHistory returns up to limit records ending just before index before
+// (clamped to len(records)); limit 0 returns everything before it.
+//
+// A page that would start mid-turn is moved forward to the turn's user
+// message, so clients never replay tool results or text without the message
+// that prompted them. A single turn longer than limit is cut as is.
+func PageHistory(records []json.RawMessage, before, limit int) HistoryPage {
+	before = min(max(before, 0), len(records))
+	start := 0
+	if limit > 0 {
+		start = max(before-limit, 0)
+	}
+	if start > 0 {
+		for i := start; i < before; i++ {
+			if isUserMessage(records[i]) {
+				start = i
+				break
+			}
+		}
+	}
+	return HistoryPage{
+		Records: records[start:before],
+		Cursor:  start,
+		HasMore: start > 0,
+	}
+}
+
+// Filter keeps the records whose type is in types, and every user message
+// so turns can still be told apart. The kept records' history indexes let
+// a client fetch what was left out with TurnHistory. Empty types keeps all.
+func (p HistoryPage) Filter(types []string) HistoryPage {
+	if len(types) == 0 {
+		return p
+	}
+	out := HistoryPage{Records: []json.RawMessage{}, Cursor: p.Cursor, HasMore: p.HasMore, Indexes: []int{}}
+	for i, r := range p.Records {
+		if isUserMessage(r) || slices.Contains(types, recordType(r)) {
+			out.Records = append(out.Records, r)
+			out.Indexes = append(out.Indexes, p.Cursor+i)
+		}
+	}
+	return out
+}
+
+// TurnHistory returns the whole turn that contains index at: from its user
+// message up to the next one. An at that is negative or past the end
+// selects the latest turn.
+func TurnHistory(records []json.RawMessage, at int) HistoryPage {
+	if at < 0 || at >= len(records) {
+		at = len(records) - 1
+	}
+	if at < 0 {
+		return HistoryPage{Records: []json.RawMessage{}}
+	}
+	start := at
+	for start > 0 && !isUserMessage(records[start]) {
+		start--
+	}
+	end := at + 1
+	for end < len(records) && !isUserMessage(records[end]) {
+		end++
+	}
+	return HistoryPage{
+		Records: records[start:end],
+		Cursor:  start,
+		HasMore: start > 0,
+	}
+}
+
+func isUserMessage(record json.RawMessage) bool {
+	return recordType(record) == "message"
+}
+
+func recordType(record json.RawMessage) string {
+	var r struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(record, &r) != nil {
+		return ""
+	}
+	return r.Type
+}
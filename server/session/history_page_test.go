@@ -0,0 +1,91 @@
+package session
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestPageHistory(t *testing.T) {
+	var records []json.RawMessage
+	for _, typ := range []string{"message", "text", "message", "tool_call", "tool_result", "text", "message", "text"} {
+		records = append(records, json.RawMessage(`{"type":"`+typ+`"}`))
+	}
+
+	tests := []struct {
+		name        string
+		before      int
+		limit       int
+		wantCursor  int
+		wantLen     int
+		wantHasMore bool
+	}{
+		{"full history", len(records), 0, 0, 8, false},
+		{"latest page starts at a user message", len(records), 3, 6, 2, true},
+		{"page snaps forward past a mid-turn start", 6, 5, 2, 4, true},
+		{"turn longer than limit is cut", 6, 2, 4, 2, true},
+		{"first page", 2, 10, 0, 2, false},
+		{"before beyond end is clamped", 100, 2, 6, 2, true},
+		{"nothing before zero", 0, 5, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := PageHistory(records, tt.before, tt.limit)
+			if page.Cursor != tt.wantCursor || len(page.Records) != tt.wantLen || page.HasMore != tt.wantHasMore {
+				t.Errorf("got cursor=%d len=%d has_more=%v, want cursor=%d len=%d has_more=%v",
+					page.Cursor, len(page.Records), page.HasMore, tt.wantCursor, tt.wantLen, tt.wantHasMore)
+			}
+		})
+	}
+}
+
+func TestHistoryPage_Filter(t *testing.T) {
+	var records []json.RawMessage
+	for _, typ := range []string{"message", "tool_call", "tool_result", "text", "message", "text"} {
+		records = append(records, json.RawMessage(`{"type":"`+typ+`"}`))
+	}
+
+	page := PageHistory(records, len(records), 0).Filter([]string{"text"})
+	if want := []int{0, 3, 4, 5}; !slices.Equal(page.Indexes, want) {
+		t.Errorf("indexes = %v, want %v (user messages are always kept)", page.Indexes, want)
+	}
+	if len(page.Records) != 4 {
+		t.Errorf("records = %d, want 4", len(page.Records))
+	}
+
+	if all := PageHistory(records, len(records), 0).Filter(nil); all.Indexes != nil || len(all.Records) != 6 {
+		t.Errorf("unfiltered = %d records, indexes %v; want all and no indexes", len(all.Records), all.Indexes)
+	}
+}
+
+func TestTurnHistory(t *testing.T) {
+	var records []json.RawMessage
+	for _, typ := range []string{"system", "message", "tool_call", "text", "message", "text"} {
+		records = append(records, json.RawMessage(`{"type":"`+typ+`"}`))
+	}
+
+	tests := []struct {
+		name       string
+		at         int
+		wantCursor int
+		wantLen    int
+	}{
+		{"before the first message", 0, 0, 1},
+		{"turn by its message", 1, 1, 3},
+		{"turn by a record inside it", 3, 1, 3},
+		{"latest turn", -1, 4, 2},
+		{"past the end is the latest turn", 99, 4, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := TurnHistory(records, tt.at)
+			if page.Cursor != tt.wantCursor || len(page.Records) != tt.wantLen {
+				t.Errorf("got cursor=%d len=%d, want cursor=%d len=%d", page.Cursor, len(page.Records), tt.wantCursor, tt.wantLen)
+			}
+		})
+	}
+
+	if page := TurnHistory(nil, -1); len(page.Records) != 0 {
+		t.Errorf("empty history = %d records, want none", len(page.Records))
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,13 +22,37 @@ type Store interface {
 
 	// Session metadata (with I/O)
 	Create(ctx context.Context, sessionID string, agentType AgentType, mode Mode) (SessionMeta, error)
+	// CreateEphemeral creates a quick-chat session (see SessionMeta.Ephemeral).
+	CreateEphemeral(ctx context.Context, sessionID string, agentType AgentType, mode Mode) (SessionMeta, error)
+	// Keep turns an ephemeral session into a regular one, retitling it when
+	// title is non-empty. Returns ErrNotEphemeral for regular sessions.
+	Keep(ctx context.Context, sessionID string, title string) (SessionMeta, error)
+	// Delete removes a session and its history for good.
 	Delete(ctx context.Context, sessionID string) error
+	// Trash moves a session to the trash, where it is kept for
+	// TrashRetention; ephemeral sessions are deleted instead.
+	Trash(ctx context.Context, sessionID string) error
+	// Restore moves a trashed session back to the list.
+	Restore(ctx context.Context, sessionID string) (SessionMeta, error)
+	// ListTrash lists the trashed sessions, most recently deleted first.
+	ListTrash() ([]TrashedSession, error)
 	Update(ctx context.Context, sessionID string, title string) error
 	Activate(ctx context.Context, sessionID string) error
 	SetAgentType(ctx context.Context, sessionID string, agentType AgentType) error
 	SetMode(ctx context.Context, sessionID string, mode Mode) error
+	SetAgentRoleID(ctx context.Context, sessionID string, agentRoleID string) error
 	SetNeedsInput(ctx context.Context, sessionID string, needsInput bool) error
+	SetKeepAlive(ctx context.Context, sessionID string, keepAlive bool) error
+	// AddModifiedFiles merges paths into ModifiedFiles (see MergeModifiedFiles).
+	AddModifiedFiles(ctx context.Context, sessionID string, paths []string) error
+	// SetDrained sets or (with "") clears the drain marker.
+	SetDrained(ctx context.Context, sessionID string, drained DrainState) error
+	// SetUnread sets the unread flag; clearing it also resets UnreadCount.
 	SetUnread(ctx context.Context, sessionID string, unread bool) error
+	// RecordMessage updates the list preview and LastActivity for new chat
+	// messages. unread is how many of them are unread: it is added to
+	// UnreadCount, and a positive one sets Unread.
+	RecordMessage(ctx context.Context, sessionID string, preview string, unread int) error
 
 	// History persistence
 	GetHistory(ctx context.Context, sessionID string) ([]json.RawMessage, error)
@@ -34,21 +61,36 @@ type Store interface {
 	// Touch updates the session's UpdatedAt and notifies listeners.
 	Touch(ctx context.Context, sessionID string) error
 
+	// Full tool output kept aside when the streamed copy is truncated
+	SaveToolResult(ctx context.Context, sessionID, toolUseID, content string) error
+	GetToolResult(ctx context.Context, sessionID, toolUseID string) (string, error)
+
+	// ToolCalls lists the session's commands and file writes (see ToolCall).
+	ToolCalls(ctx context.Context, sessionID string, f ToolCallFilter) ([]ToolCall, error)
+
 	// Change notification
-	SetOnChangeListener(listener OnChangeListener)
+	AddOnChangeListener(listener OnChangeListener)
 }
 
 type indexData struct {
-	Sessions []SessionMeta `json:"sessions"`
+	Sessions []SessionMeta    `json:"sessions"`
+	Trash    []TrashedSession `json:"trash,omitempty"`
 }
 
 // FileStore is NOT safe for multiple instances sharing the same dataDir.
 // Use a single instance per data directory (e.g., via dependency injection).
 type FileStore struct {
-	dataDir  string
-	mu       sync.RWMutex
-	sessions []SessionMeta // in-memory cache
-	listener OnChangeListener
+	dataDir   string
+	mu        sync.RWMutex
+	sessions  []SessionMeta // in-memory cache
+	trash     []TrashedSession
+	listeners []OnChangeListener
+
+	// Guards building and appending the tool call index (toolcalls.go).
+	toolCallsMu sync.Mutex
+	// Serializes quarantining corrupt history lines (history_migrate.go),
+	// since GetHistory only holds mu for reading.
+	quarantineMu sync.Mutex
 }
 
 func NewFileStore(dataDir string) (*FileStore, error) {
@@ -64,6 +106,8 @@ func NewFileStore(dataDir string) (*FileStore, error) {
 		return nil, err
 	}
 	store.sessions = idx.Sessions
+	store.trash = idx.Trash
+	store.purgeTrash()
 
 	return store, nil
 }
@@ -100,7 +144,7 @@ func (s *FileStore) readIndexFromDisk() (indexData, error) {
 }
 
 func (s *FileStore) persistIndex() error {
-	idx := indexData{Sessions: s.sessions}
+	idx := indexData{Sessions: s.sessions, Trash: s.trash}
 	data, err := json.MarshalIndent(idx, "", "  ")
 	if err != nil {
 		return err
@@ -108,15 +152,15 @@ func (s *FileStore) persistIndex() error {
 	return os.WriteFile(s.indexPath(), data, 0644)
 }
 
-func (s *FileStore) SetOnChangeListener(listener OnChangeListener) {
+func (s *FileStore) AddOnChangeListener(listener OnChangeListener) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.listener = listener
+	s.listeners = append(s.listeners, listener)
 }
 
 func (s *FileStore) notifyChange(event SessionChangeEvent) {
-	if s.listener != nil {
-		s.listener.OnSessionChange(event)
+	for _, l := range s.listeners {
+		l.OnSessionChange(event)
 	}
 }
 
@@ -147,29 +191,31 @@ func (s *FileStore) Get(sessionID string) (SessionMeta, bool, error) {
 }
 
 func (s *FileStore) Create(ctx context.Context, sessionID string, agentType AgentType, mode Mode) (SessionMeta, error) {
+	return s.create(ctx, SessionMeta{ID: sessionID, Title: "New Chat", AgentType: agentType, Mode: mode})
+}
+
+func (s *FileStore) CreateEphemeral(ctx context.Context, sessionID string, agentType AgentType, mode Mode) (SessionMeta, error) {
+	return s.create(ctx, SessionMeta{ID: sessionID, Title: "Quick Chat", AgentType: agentType, Mode: mode, Ephemeral: true})
+}
+
+func (s *FileStore) create(ctx context.Context, session SessionMeta) (SessionMeta, error) {
 	if err := ctx.Err(); err != nil {
 		return SessionMeta{}, err
 	}
 
-	if agentType == "" {
-		agentType = AgentTypeClaude
+	if session.AgentType == "" {
+		session.AgentType = AgentTypeClaude
 	}
-	if mode == "" {
-		mode = ModeDefault
+	if session.Mode == "" {
+		session.Mode = ModeDefault
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	now := time.Now()
-	session := SessionMeta{
-		ID:        sessionID,
-		Title:     "New Chat",
-		CreatedAt: now,
-		UpdatedAt: now,
-		AgentType: agentType,
-		Mode:      mode,
-	}
+	session.CreatedAt = now
+	session.UpdatedAt = now
 
 	s.sessions = append([]SessionMeta{session}, s.sessions...)
 
@@ -304,6 +350,31 @@ func (s *FileStore) SetMode(ctx context.Context, sessionID string, mode Mode) er
 	return ErrSessionNotFound
 }
 
+func (s *FileStore) SetAgentRoleID(ctx context.Context, sessionID string, agentRoleID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		if s.sessions[i].ID == sessionID {
+			if s.sessions[i].AgentRoleID == agentRoleID {
+				return nil
+			}
+			s.sessions[i].AgentRoleID = agentRoleID
+			if err := s.persistIndex(); err != nil {
+				return err
+			}
+			s.notifyChange(SessionChangeEvent{Op: OperationUpdate, Session: s.sessions[i]})
+			return nil
+		}
+	}
+
+	return ErrSessionNotFound
+}
+
 func (s *FileStore) SetNeedsInput(ctx context.Context, sessionID string, needsInput bool) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -329,6 +400,115 @@ func (s *FileStore) SetNeedsInput(ctx context.Context, sessionID string, needsIn
 	return ErrSessionNotFound
 }
 
+func (s *FileStore) SetKeepAlive(ctx context.Context, sessionID string, keepAlive bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		if s.sessions[i].ID == sessionID {
+			if s.sessions[i].KeepAlive == keepAlive {
+				return nil
+			}
+			s.sessions[i].KeepAlive = keepAlive
+			if err := s.persistIndex(); err != nil {
+				return err
+			}
+			s.notifyChange(SessionChangeEvent{Op: OperationUpdate, Session: s.sessions[i]})
+			return nil
+		}
+	}
+
+	return ErrSessionNotFound
+}
+
+func (s *FileStore) AddModifiedFiles(ctx context.Context, sessionID string, paths []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		if s.sessions[i].ID == sessionID {
+			merged := MergeModifiedFiles(s.sessions[i].ModifiedFiles, paths)
+			if len(merged) == len(s.sessions[i].ModifiedFiles) {
+				return nil
+			}
+			s.sessions[i].ModifiedFiles = merged
+			if err := s.persistIndex(); err != nil {
+				return err
+			}
+			s.notifyChange(SessionChangeEvent{Op: OperationUpdate, Session: s.sessions[i]})
+			return nil
+		}
+	}
+
+	return ErrSessionNotFound
+}
+
+func (s *FileStore) Keep(ctx context.Context, sessionID string, title string) (SessionMeta, error) {
+	if err := ctx.Err(); err != nil {
+		return SessionMeta{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		if s.sessions[i].ID != sessionID {
+			continue
+		}
+		if !s.sessions[i].Ephemeral {
+			return SessionMeta{}, ErrNotEphemeral
+		}
+		prev := s.sessions[i]
+		s.sessions[i].Ephemeral = false
+		if title != "" {
+			s.sessions[i].Title = title
+		}
+		s.sessions[i].UpdatedAt = time.Now()
+		if err := s.persistIndex(); err != nil {
+			s.sessions[i] = prev
+			return SessionMeta{}, err
+		}
+		// List listeners never saw the session, so to them it is new.
+		s.notifyChange(SessionChangeEvent{Op: OperationCreate, Session: s.sessions[i]})
+		return s.sessions[i], nil
+	}
+
+	return SessionMeta{}, ErrSessionNotFound
+}
+
+func (s *FileStore) SetDrained(ctx context.Context, sessionID string, drained DrainState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		if s.sessions[i].ID == sessionID {
+			if s.sessions[i].Drained == drained {
+				return nil
+			}
+			s.sessions[i].Drained = drained
+			if err := s.persistIndex(); err != nil {
+				return err
+			}
+			s.notifyChange(SessionChangeEvent{Op: OperationUpdate, Session: s.sessions[i]})
+			return nil
+		}
+	}
+
+	return ErrSessionNotFound
+}
+
 func (s *FileStore) SetUnread(ctx context.Context, sessionID string, unread bool) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -339,10 +519,40 @@ func (s *FileStore) SetUnread(ctx context.Context, sessionID string, unread bool
 
 	for i := range s.sessions {
 		if s.sessions[i].ID == sessionID {
-			if s.sessions[i].Unread == unread {
+			if s.sessions[i].Unread == unread && (unread || s.sessions[i].UnreadCount == 0) {
 				return nil
 			}
 			s.sessions[i].Unread = unread
+			if !unread {
+				s.sessions[i].UnreadCount = 0
+			}
+			if err := s.persistIndex(); err != nil {
+				return err
+			}
+			s.notifyChange(SessionChangeEvent{Op: OperationUpdate, Session: s.sessions[i]})
+			return nil
+		}
+	}
+
+	return ErrSessionNotFound
+}
+
+func (s *FileStore) RecordMessage(ctx context.Context, sessionID string, preview string, unread int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.sessions {
+		if s.sessions[i].ID == sessionID {
+			s.sessions[i].LastMessagePreview = preview
+			s.sessions[i].LastActivity = time.Now()
+			if unread > 0 {
+				s.sessions[i].Unread = true
+				s.sessions[i].UnreadCount += unread
+			}
 			if err := s.persistIndex(); err != nil {
 				return err
 			}
@@ -377,6 +587,7 @@ func (s *FileStore) GetHistory(ctx context.Context, sessionID string) ([]json.Ra
 	defer file.Close()
 
 	var records []json.RawMessage
+	var bad map[int][]byte
 	scanner := bufio.NewScanner(file)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // Match CLI output buffer size
 	for scanner.Scan() {
@@ -384,11 +595,20 @@ func (s *FileStore) GetHistory(ctx context.Context, sessionID string) ([]json.Ra
 		if len(line) == 0 {
 			continue
 		}
-		// Make a copy since scanner reuses the buffer
-		record := make(json.RawMessage, len(line))
-		copy(record, line)
+		// migrateRecord copies, since scanner reuses the buffer.
+		record, ok := migrateRecord(line)
+		if !ok {
+			if bad == nil {
+				bad = make(map[int][]byte)
+			}
+			bad[len(records)] = append([]byte(nil), line...)
+			record = corruptRecord(len(records))
+		}
 		records = append(records, record)
 	}
+	if bad != nil {
+		s.quarantine(sessionID, bad)
+	}
 
 	if err := scanner.Err(); err != nil {
 		if errors.Is(err, bufio.ErrTooLong) {
@@ -429,12 +649,65 @@ func (s *FileStore) AppendToHistory(ctx context.Context, sessionID string, recor
 	if err != nil {
 		return err
 	}
+	// The index is secondary; losing an entry must not lose the record.
+	if err := s.indexToolCall(ctx, sessionID, data); err != nil {
+		slog.Error("failed to index tool call", "sessionId", sessionID, "error", err)
+	}
 
-	data = append(data, '\n')
+	data = append(stampVersion(data), '\n')
 	_, err = file.Write(data)
 	return err
 }
 
+// isPathComponent reports whether name is a single, non-hidden path element.
+func isPathComponent(name string) bool {
+	return name != "" && name == filepath.Base(name) && !strings.HasPrefix(name, ".")
+}
+
+// toolResultPath returns where a tool use's full output is stored. Tool use
+// IDs come from the agent and session IDs from clients, so anything that
+// could escape the directory is rejected.
+func (s *FileStore) toolResultPath(sessionID, toolUseID string) (string, bool) {
+	if !isPathComponent(sessionID) || !isPathComponent(toolUseID) {
+		return "", false
+	}
+	return filepath.Join(s.dataDir, "sessions", sessionID, "tool_results", toolUseID), true
+}
+
+func (s *FileStore) SaveToolResult(ctx context.Context, sessionID, toolUseID, content string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, ok := s.toolResultPath(sessionID, toolUseID)
+	if !ok {
+		return fmt.Errorf("invalid tool result path %q/%q", sessionID, toolUseID)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+func (s *FileStore) GetToolResult(ctx context.Context, sessionID, toolUseID string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	path, ok := s.toolResultPath(sessionID, toolUseID)
+	if !ok {
+		return "", ErrToolResultNotFound
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", ErrToolResultNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 func (s *FileStore) Touch(ctx context.Context, sessionID string) error {
 	if err := ctx.Err(); err != nil {
 		return err
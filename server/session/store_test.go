@@ -2,8 +2,12 @@ package session
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -249,10 +253,10 @@ func TestFileStore_History(t *testing.T) {
 	}
 
 	// Verify content (raw JSON)
-	if string(history[0]) != `{"content":"hello","type":"message"}` {
+	if string(history[0]) != `{"v":1,"content":"hello","type":"message"}` {
 		t.Errorf("unexpected record 0: %s", history[0])
 	}
-	if string(history[1]) != `{"content":"world","type":"text"}` {
+	if string(history[1]) != `{"v":1,"content":"world","type":"text"}` {
 		t.Errorf("unexpected record 1: %s", history[1])
 	}
 }
@@ -272,6 +276,108 @@ func TestFileStore_Touch_UpdatesUpdatedAt(t *testing.T) {
 	}
 }
 
+func TestFileStore_HistoryMigration(t *testing.T) {
+	dataDir := t.TempDir()
+	store, _ := NewFileStore(dataDir)
+	sess, _ := store.Create(ctx, "test-session", "", "")
+
+	lines := []string{
+		`{"type":"user","content":"hi","sessionId":"test-session"}`,
+		`{"type":"tool_use","toolName":"Bash","toolUseId":"t1","toolInput":{"command":"ls"}}`,
+		`{"type":"tool_result","tool_use_id":"t1","tool_result":"a.go"}`,
+		`{"type":"text","content":"trunc`,
+		`{"v":2,"type":"future","shape":true}`,
+		`["not","a","record"]`,
+	}
+	path := filepath.Join(dataDir, "sessions", sess.ID, "history.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := store.GetHistory(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != len(lines) {
+		t.Fatalf("got %d records, want %d (one per line)", len(history), len(lines))
+	}
+
+	want := []string{
+		`{"content":"hi","type":"message","v":1}`,
+		`{"tool_input":{"command":"ls"},"tool_name":"Bash","tool_use_id":"t1","type":"tool_call","v":1}`,
+		`{"tool_result":"a.go","tool_use_id":"t1","type":"tool_result","v":1}`,
+	}
+	for i, w := range want {
+		if string(history[i]) != w {
+			t.Errorf("record %d = %s, want %s", i, history[i], w)
+		}
+	}
+	if string(history[4]) != lines[4] {
+		t.Errorf("newer record was rewritten: %s", history[4])
+	}
+	for _, i := range []int{3, 5} {
+		if !strings.Contains(string(history[i]), `"code":"history_record_corrupt"`) {
+			t.Errorf("record %d = %s, want corrupt placeholder", i, history[i])
+		}
+	}
+
+	// Corrupt lines are quarantined once, however often history is read.
+	if _, err := store.GetHistory(ctx, sess.ID); err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, "sessions", sess.ID, "history.quarantine.jsonl"))
+	if err != nil {
+		t.Fatalf("read quarantine: %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Errorf("quarantine has %d lines, want 2:\n%s", n, data)
+	}
+	if !strings.Contains(string(data), `"index":3`) || !strings.Contains(string(data), `trunc`) {
+		t.Errorf("quarantine = %s", data)
+	}
+}
+
+func TestFileStore_HistoryQuarantineConcurrentReads(t *testing.T) {
+	dataDir := t.TempDir()
+	store, _ := NewFileStore(dataDir)
+	sess, _ := store.Create(ctx, "test-session", "", "")
+
+	path := filepath.Join(dataDir, "sessions", sess.ID, "history.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Many corrupt lines widen the window between reading and appending
+	// the quarantine.
+	var lines strings.Builder
+	for i := range 200 {
+		fmt.Fprintf(&lines, "{\"type\":\"text\",\"content\":\"trunc %d\n", i)
+	}
+	if err := os.WriteFile(path, []byte(lines.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			if _, err := store.GetHistory(ctx, sess.ID); err != nil {
+				t.Errorf("GetHistory failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(filepath.Join(dataDir, "sessions", sess.ID, "history.quarantine.jsonl"))
+	if err != nil {
+		t.Fatalf("read quarantine: %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 200 {
+		t.Errorf("quarantine has %d lines, want 200", n)
+	}
+}
+
 func TestFileStore_AppendToHistory_DoesNotUpdateTimestamp(t *testing.T) {
 	store, _ := NewFileStore(t.TempDir())
 
@@ -336,3 +442,208 @@ func TestFileStore_MigratesEmptyMode(t *testing.T) {
 		t.Errorf("expected mode to be migrated to %q, got %q", ModeDefault, sess.Mode)
 	}
 }
+
+func TestFileStore_RecordMessage(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	store.Create(ctx, "s1", "", "")
+
+	if err := store.RecordMessage(ctx, "s1", "hello", 1); err != nil {
+		t.Fatalf("RecordMessage failed: %v", err)
+	}
+	if err := store.RecordMessage(ctx, "s1", "second", 1); err != nil {
+		t.Fatalf("RecordMessage failed: %v", err)
+	}
+	if err := store.RecordMessage(ctx, "s1", "from user", 0); err != nil {
+		t.Fatalf("RecordMessage failed: %v", err)
+	}
+
+	sess, _, _ := store.Get("s1")
+	if sess.UnreadCount != 2 || !sess.Unread {
+		t.Errorf("unread = %v count = %d, want true/2", sess.Unread, sess.UnreadCount)
+	}
+	if sess.LastMessagePreview != "from user" {
+		t.Errorf("preview = %q, want %q", sess.LastMessagePreview, "from user")
+	}
+	if sess.LastActivity.IsZero() {
+		t.Error("expected non-zero LastActivity")
+	}
+
+	// Count and preview survive a restart.
+	reloaded, _ := NewFileStore(dir)
+	sess, _, _ = reloaded.Get("s1")
+	if sess.UnreadCount != 2 || sess.LastMessagePreview != "from user" {
+		t.Errorf("after reload: count = %d preview = %q", sess.UnreadCount, sess.LastMessagePreview)
+	}
+
+	if err := reloaded.SetUnread(ctx, "s1", false); err != nil {
+		t.Fatalf("SetUnread failed: %v", err)
+	}
+	sess, _, _ = reloaded.Get("s1")
+	if sess.Unread || sess.UnreadCount != 0 {
+		t.Errorf("after mark read: unread = %v count = %d, want false/0", sess.Unread, sess.UnreadCount)
+	}
+}
+
+func TestFileStore_RecordMessageNonExistent(t *testing.T) {
+	store, _ := NewFileStore(t.TempDir())
+
+	if err := store.RecordMessage(ctx, "missing", "x", 1); err != ErrSessionNotFound {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFileStore_SetKeepAlive(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	store.Create(ctx, "s1", "", "")
+
+	if err := store.SetKeepAlive(ctx, "s1", true); err != nil {
+		t.Fatalf("SetKeepAlive failed: %v", err)
+	}
+
+	reloaded, _ := NewFileStore(dir)
+	sess, _, _ := reloaded.Get("s1")
+	if !sess.KeepAlive {
+		t.Error("expected KeepAlive to persist")
+	}
+
+	if err := store.SetKeepAlive(ctx, "missing", true); err != ErrSessionNotFound {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFileStore_AddModifiedFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	store.Create(ctx, "s1", "", "")
+
+	if err := store.AddModifiedFiles(ctx, "s1", []string{"b.go", "a.go"}); err != nil {
+		t.Fatalf("AddModifiedFiles failed: %v", err)
+	}
+	if err := store.AddModifiedFiles(ctx, "s1", []string{"a.go", "c/d.go"}); err != nil {
+		t.Fatalf("AddModifiedFiles failed: %v", err)
+	}
+
+	reloaded, _ := NewFileStore(dir)
+	sess, _, _ := reloaded.Get("s1")
+	if want := []string{"a.go", "b.go", "c/d.go"}; !slices.Equal(sess.ModifiedFiles, want) {
+		t.Errorf("ModifiedFiles = %v, want %v", sess.ModifiedFiles, want)
+	}
+
+	if err := store.AddModifiedFiles(ctx, "missing", []string{"a.go"}); err != ErrSessionNotFound {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestMergeModifiedFiles_Cap(t *testing.T) {
+	var paths []string
+	for i := range MaxModifiedFiles + 10 {
+		paths = append(paths, fmt.Sprintf("f%04d", i))
+	}
+	merged := MergeModifiedFiles(nil, paths)
+	if len(merged) != MaxModifiedFiles {
+		t.Fatalf("len = %d, want %d", len(merged), MaxModifiedFiles)
+	}
+	if again := MergeModifiedFiles(merged, []string{"zzz"}); len(again) != MaxModifiedFiles {
+		t.Errorf("expected a full list to stay unchanged, got len %d", len(again))
+	}
+}
+
+func TestFileStore_SetDrained(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	store.Create(ctx, "s1", "", "")
+
+	if err := store.SetDrained(ctx, "s1", DrainedMidTurn); err != nil {
+		t.Fatalf("SetDrained failed: %v", err)
+	}
+	reloaded, _ := NewFileStore(dir)
+	if sess, _, _ := reloaded.Get("s1"); sess.Drained != DrainedMidTurn {
+		t.Errorf("Drained = %q, want %q to persist", sess.Drained, DrainedMidTurn)
+	}
+
+	if err := store.SetDrained(ctx, "s1", ""); err != nil {
+		t.Fatalf("clear failed: %v", err)
+	}
+	if sess, _, _ := store.Get("s1"); sess.Drained != "" {
+		t.Errorf("Drained = %q, want cleared", sess.Drained)
+	}
+
+	if err := store.SetDrained(ctx, "missing", DrainedIdle); err != ErrSessionNotFound {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFileStore_CreateEphemeralAndKeep(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+
+	sess, err := store.CreateEphemeral(ctx, "q1", "", "")
+	if err != nil {
+		t.Fatalf("CreateEphemeral failed: %v", err)
+	}
+	if !sess.Ephemeral || sess.Title != "Quick Chat" || sess.Mode != ModeDefault {
+		t.Errorf("session = %+v, want ephemeral quick chat in default mode", sess)
+	}
+
+	kept, err := store.Keep(ctx, "q1", "Saved question")
+	if err != nil {
+		t.Fatalf("Keep failed: %v", err)
+	}
+	if kept.Ephemeral || kept.Title != "Saved question" {
+		t.Errorf("kept = %+v, want regular session with new title", kept)
+	}
+
+	reloaded, _ := NewFileStore(dir)
+	got, _, _ := reloaded.Get("q1")
+	if got.Ephemeral {
+		t.Error("expected kept session to persist as regular")
+	}
+
+	if _, err := store.Keep(ctx, "q1", ""); err != ErrNotEphemeral {
+		t.Errorf("expected ErrNotEphemeral, got %v", err)
+	}
+	if _, err := store.Keep(ctx, "missing", ""); err != ErrSessionNotFound {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFileStore_ToolResult(t *testing.T) {
+	store, _ := NewFileStore(t.TempDir())
+	store.Create(ctx, "s1", "", "")
+
+	if err := store.SaveToolResult(ctx, "s1", "toolu_1", "full output"); err != nil {
+		t.Fatalf("SaveToolResult failed: %v", err)
+	}
+	got, err := store.GetToolResult(ctx, "s1", "toolu_1")
+	if err != nil || got != "full output" {
+		t.Errorf("GetToolResult = %q, %v", got, err)
+	}
+
+	if _, err := store.GetToolResult(ctx, "s1", "missing"); err != ErrToolResultNotFound {
+		t.Errorf("expected ErrToolResultNotFound, got %v", err)
+	}
+	for _, id := range []string{"", "../index.json", "a/b", ".."} {
+		if err := store.SaveToolResult(ctx, "s1", id, "x"); err == nil {
+			t.Errorf("SaveToolResult(%q): expected error", id)
+		}
+		if _, err := store.GetToolResult(ctx, "s1", id); err != ErrToolResultNotFound {
+			t.Errorf("GetToolResult(%q): expected ErrToolResultNotFound, got %v", id, err)
+		}
+	}
+	for _, sid := range []string{"", "..", "s1/../s1", "../sessions/s1"} {
+		if err := store.SaveToolResult(ctx, sid, "toolu_2", "x"); err == nil {
+			t.Errorf("SaveToolResult(session %q): expected error", sid)
+		}
+		if _, err := store.GetToolResult(ctx, sid, "toolu_1"); err != ErrToolResultNotFound {
+			t.Errorf("GetToolResult(session %q): expected ErrToolResultNotFound, got %v", sid, err)
+		}
+	}
+
+	// Deleting the session removes stored tool output with it.
+	store.Delete(ctx, "s1")
+	if _, err := store.GetToolResult(ctx, "s1", "toolu_1"); err != ErrToolResultNotFound {
+		t.Errorf("expected tool result to be deleted with session, got %v", err)
+	}
+}
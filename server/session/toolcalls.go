@@ -0,0 +1,278 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// indexedTools are the tool calls the index keeps: the ones that run
+// commands or write files, which is what an audit of a session looks for.
+var indexedTools = []string{"Bash", "Write", "Edit", "MultiEdit", "NotebookEdit"}
+
+type ToolCallStatus string
+
+const (
+	ToolCallRunning ToolCallStatus = "running" // no result recorded yet
+	ToolCallOK      ToolCallStatus = "ok"
+	ToolCallFailed  ToolCallStatus = "error"
+)
+
+// ToolCall is one command or file write from a session's history.
+type ToolCall struct {
+	ToolUseID string         `json:"tool_use_id"`
+	Tool      string         `json:"tool"`
+	Command   string         `json:"command,omitempty"` // Bash only
+	Paths     []string       `json:"paths,omitempty"`   // files written
+	Status    ToolCallStatus `json:"status"`
+	// ExitCode is set when the agent reports it (Codex commands).
+	ExitCode *int `json:"exit_code,omitempty"`
+	// StartedAt and FinishedAt are zero for calls indexed from history
+	// written before the index existed, which has no timestamps.
+	StartedAt  time.Time `json:"started_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// ToolCallFilter narrows ToolCalls. Zero fields match everything.
+type ToolCallFilter struct {
+	Tool string
+	// Path matches calls writing that file or a file under that directory,
+	// and commands mentioning it.
+	Path string
+	// Since (inclusive) and Until (exclusive) bound StartedAt. Calls without
+	// a timestamp are left out once either is set.
+	Since time.Time
+	Until time.Time
+}
+
+// ValidateToolCallFilter rejects a filter that could only match nothing by
+// mistake.
+func ValidateToolCallFilter(f ToolCallFilter) error {
+	if f.Tool != "" && !slices.Contains(indexedTools, f.Tool) {
+		return fmt.Errorf("tool must be one of %s", strings.Join(indexedTools, ", "))
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Until.After(f.Since) {
+		return errors.New("until must be after since")
+	}
+	return nil
+}
+
+func (f ToolCallFilter) matches(c ToolCall) bool {
+	if f.Tool != "" && c.Tool != f.Tool {
+		return false
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		if c.StartedAt.IsZero() || c.StartedAt.Before(f.Since) || (!f.Until.IsZero() && !c.StartedAt.Before(f.Until)) {
+			return false
+		}
+	}
+	if f.Path != "" {
+		dir := strings.TrimSuffix(f.Path, "/") + "/"
+		under := func(p string) bool { return p == f.Path || strings.HasPrefix(p, dir) }
+		if !slices.ContainsFunc(c.Paths, under) && !strings.Contains(c.Command, f.Path) {
+			return false
+		}
+	}
+	return true
+}
+
+// toolCallEntry is a line of toolcalls.jsonl: a call when Tool is set,
+// otherwise the result of the call with the same ID.
+type toolCallEntry struct {
+	ToolUseID string    `json:"id"`
+	Tool      string    `json:"tool,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	Paths     []string  `json:"paths,omitempty"`
+	At        time.Time `json:"at,omitzero"`
+	IsError   bool      `json:"is_error,omitempty"`
+	ExitCode  *int      `json:"exit_code,omitempty"`
+}
+
+// toolRecord is the part of a history record the index reads.
+type toolRecord struct {
+	Type      string          `json:"type"`
+	ToolName  string          `json:"tool_name"`
+	ToolInput json.RawMessage `json:"tool_input"`
+	ToolUseID string          `json:"tool_use_id"`
+	IsError   bool            `json:"is_error"`
+	ExitCode  *int            `json:"exit_code"`
+}
+
+// toolCallEntryFor returns the index entry for a history record, if it is
+// an indexed tool call or a tool result. Results are kept whatever their
+// tool, since the record does not say; reads drop those without a call.
+func toolCallEntryFor(record []byte, at time.Time) (toolCallEntry, bool) {
+	var r toolRecord
+	if err := json.Unmarshal(record, &r); err != nil || r.ToolUseID == "" {
+		return toolCallEntry{}, false
+	}
+	switch r.Type {
+	case "tool_call":
+		if !slices.Contains(indexedTools, r.ToolName) {
+			return toolCallEntry{}, false
+		}
+		command, paths := toolCallDetails(r.ToolInput)
+		return toolCallEntry{ToolUseID: r.ToolUseID, Tool: r.ToolName, Command: command, Paths: paths, At: at}, true
+	case "tool_result":
+		return toolCallEntry{ToolUseID: r.ToolUseID, At: at, IsError: r.IsError, ExitCode: r.ExitCode}, true
+	}
+	return toolCallEntry{}, false
+}
+
+// toolCallDetails reads the command and written paths from a tool input.
+// Codex edits list their files as the keys of "changes".
+func toolCallDetails(input json.RawMessage) (string, []string) {
+	var in struct {
+		Command      string                     `json:"command"`
+		FilePath     string                     `json:"file_path"`
+		NotebookPath string                     `json:"notebook_path"`
+		Changes      map[string]json.RawMessage `json:"changes"`
+	}
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", nil
+	}
+	var paths []string
+	for _, p := range []string{in.FilePath, in.NotebookPath} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	for p := range in.Changes {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	return in.Command, slices.Compact(paths)
+}
+
+func (s *FileStore) toolCallsPath(sessionID string) string {
+	return filepath.Join(s.dataDir, "sessions", sessionID, "toolcalls.jsonl")
+}
+
+// indexToolCall adds record to the tool call index before it is appended
+// to history. A session whose history predates the index is indexed from
+// its history first.
+func (s *FileStore) indexToolCall(ctx context.Context, sessionID string, record []byte) error {
+	entry, ok := toolCallEntryFor(record, time.Now())
+	if !ok {
+		return nil
+	}
+	s.toolCallsMu.Lock()
+	defer s.toolCallsMu.Unlock()
+	if err := s.ensureToolCallIndexLocked(ctx, sessionID); err != nil {
+		return err
+	}
+	return appendToolCallEntries(s.toolCallsPath(sessionID), []toolCallEntry{entry})
+}
+
+// ensureToolCallIndexLocked builds the index from history when it is
+// missing. Entries built this way have no timestamps.
+func (s *FileStore) ensureToolCallIndexLocked(ctx context.Context, sessionID string) error {
+	path := s.toolCallsPath(sessionID)
+	if _, err := os.Stat(path); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	records, err := s.GetHistory(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	var entries []toolCallEntry
+	for _, record := range records {
+		if entry, ok := toolCallEntryFor(record, time.Time{}); ok {
+			entries = append(entries, entry)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return appendToolCallEntries(path, entries)
+}
+
+func appendToolCallEntries(path string, entries []toolCallEntry) error {
+	var buf []byte
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ToolCalls returns the session's indexed tool calls matching f, oldest
+// first.
+func (s *FileStore) ToolCalls(ctx context.Context, sessionID string, f ToolCallFilter) ([]ToolCall, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.toolCallsMu.Lock()
+	defer s.toolCallsMu.Unlock()
+	if err := s.ensureToolCallIndexLocked(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(s.toolCallsPath(sessionID))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var calls []ToolCall
+	byID := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var e toolCallEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // a torn last line from a crash
+		}
+		if e.Tool != "" {
+			byID[e.ToolUseID] = len(calls)
+			calls = append(calls, ToolCall{
+				ToolUseID: e.ToolUseID,
+				Tool:      e.Tool,
+				Command:   e.Command,
+				Paths:     e.Paths,
+				Status:    ToolCallRunning,
+				StartedAt: e.At,
+			})
+			continue
+		}
+		i, ok := byID[e.ToolUseID]
+		if !ok {
+			continue
+		}
+		calls[i].Status = ToolCallOK
+		if e.IsError {
+			calls[i].Status = ToolCallFailed
+		}
+		calls[i].ExitCode = e.ExitCode
+		calls[i].FinishedAt = e.At
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	matched := make([]ToolCall, 0, len(calls))
+	for _, c := range calls {
+		if f.matches(c) {
+			matched = append(matched, c)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,165 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func toolCallRecord(id, tool string, input string) map[string]any {
+	return map[string]any{"type": "tool_call", "tool_use_id": id, "tool_name": tool, "tool_input": json.RawMessage(input)}
+}
+
+func toolResultRecord(id string, isError bool, exitCode *int) map[string]any {
+	r := map[string]any{"type": "tool_result", "tool_use_id": id, "tool_result": "out", "is_error": isError}
+	if exitCode != nil {
+		r["exit_code"] = *exitCode
+	}
+	return r
+}
+
+func toolCallIDs(calls []ToolCall) []string {
+	ids := make([]string, len(calls))
+	for i, c := range calls {
+		ids[i] = c.ToolUseID
+	}
+	return ids
+}
+
+func TestToolCalls(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const sid = "s1"
+	exit2 := 2
+
+	before := time.Now()
+	for _, r := range []any{
+		map[string]any{"type": "message", "content": "hi"},
+		toolCallRecord("b1", "Bash", `{"command":"go test ./session/"}`),
+		toolResultRecord("b1", true, &exit2),
+		toolCallRecord("r1", "Read", `{"file_path":"/repo/a.go"}`),
+		toolResultRecord("r1", false, nil),
+		toolCallRecord("w1", "Write", `{"file_path":"/repo/session/new.go","content":"x"}`),
+		toolResultRecord("w1", false, nil),
+		toolCallRecord("e1", "Edit", `{"changes":{"/repo/b.go":{},"/repo/a.go":{}}}`),
+	} {
+		if err := store.AppendToHistory(ctx, sid, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls, err := store.ToolCalls(ctx, sid, ToolCallFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toolCallIDs(calls); !slices.Equal(got, []string{"b1", "w1", "e1"}) {
+		t.Fatalf("calls = %v, want b1 w1 e1 (Read is not indexed)", got)
+	}
+	bash := calls[0]
+	if bash.Command != "go test ./session/" || bash.Status != ToolCallFailed || bash.ExitCode == nil || *bash.ExitCode != 2 {
+		t.Errorf("bash = %+v", bash)
+	}
+	if bash.StartedAt.Before(before) || bash.FinishedAt.Before(bash.StartedAt) {
+		t.Errorf("bash times = %v, %v", bash.StartedAt, bash.FinishedAt)
+	}
+	if calls[1].Status != ToolCallOK || !slices.Equal(calls[1].Paths, []string{"/repo/session/new.go"}) {
+		t.Errorf("write = %+v", calls[1])
+	}
+	if calls[2].Status != ToolCallRunning || !slices.Equal(calls[2].Paths, []string{"/repo/a.go", "/repo/b.go"}) {
+		t.Errorf("edit = %+v", calls[2])
+	}
+
+	tests := []struct {
+		name   string
+		filter ToolCallFilter
+		want   []string
+	}{
+		{"tool", ToolCallFilter{Tool: "Bash"}, []string{"b1"}},
+		{"path file", ToolCallFilter{Path: "/repo/a.go"}, []string{"e1"}},
+		{"path dir", ToolCallFilter{Path: "/repo/session"}, []string{"w1"}},
+		{"path in command", ToolCallFilter{Path: "./session/"}, []string{"b1"}},
+		{"since", ToolCallFilter{Since: before}, []string{"b1", "w1", "e1"}},
+		{"until", ToolCallFilter{Until: before}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, err := store.ToolCalls(ctx, sid, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := toolCallIDs(calls); !slices.Equal(got, tt.want) {
+				t.Errorf("calls = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// History written before the index existed is indexed on first use,
+// without timestamps, and later appends extend it.
+func TestToolCalls_BuildsFromHistory(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const sid = "s1"
+
+	historyDir := filepath.Join(dir, "sessions", sid)
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"type":"tool_use","toolUseId":"old","toolName":"Bash","toolInput":{"command":"ls"}}` + "\n" +
+		`{"type":"tool_result","toolUseId":"old","toolResult":"a.go"}` + "\n"
+	if err := os.WriteFile(filepath.Join(historyDir, "history.jsonl"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.AppendToHistory(ctx, sid, toolCallRecord("new", "Bash", `{"command":"pwd"}`)); err != nil {
+		t.Fatal(err)
+	}
+	calls, err := store.ToolCalls(ctx, sid, ToolCallFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toolCallIDs(calls); !slices.Equal(got, []string{"old", "new"}) {
+		t.Fatalf("calls = %v, want old new", got)
+	}
+	if calls[0].Command != "ls" || calls[0].Status != ToolCallOK || !calls[0].StartedAt.IsZero() {
+		t.Errorf("legacy call = %+v", calls[0])
+	}
+	if calls[1].StartedAt.IsZero() {
+		t.Error("appended call has no timestamp")
+	}
+
+	// Untimed calls never match a time range.
+	calls, err = store.ToolCalls(ctx, sid, ToolCallFilter{Since: time.Time{}.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := toolCallIDs(calls); !slices.Equal(got, []string{"new"}) {
+		t.Errorf("calls = %v, want new", got)
+	}
+}
+
+func TestValidateToolCallFilter(t *testing.T) {
+	now := time.Now()
+	for _, f := range []ToolCallFilter{
+		{Tool: "Read"},
+		{Since: now, Until: now},
+	} {
+		if err := ValidateToolCallFilter(f); err == nil {
+			t.Errorf("ValidateToolCallFilter(%+v) = nil", f)
+		}
+	}
+	if err := ValidateToolCallFilter(ToolCallFilter{Tool: "Edit", Since: now}); err != nil {
+		t.Errorf("valid filter: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// TrashRetention is how long a trashed session can be restored before its
+// history is deleted.
+const TrashRetention = 7 * 24 * time.Hour
+
+// TrashedSession is a session deleted by Store.Trash. Its history stays on
+// disk until it expires.
+type TrashedSession struct {
+	SessionMeta
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (s *FileStore) Trash(ctx context.Context, sessionID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	i := slices.IndexFunc(s.sessions, func(m SessionMeta) bool { return m.ID == sessionID })
+	if i < 0 {
+		s.mu.Unlock()
+		return ErrSessionNotFound
+	}
+	if s.sessions[i].Ephemeral {
+		s.mu.Unlock()
+		return s.Delete(ctx, sessionID)
+	}
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	prevSessions, prevTrash := s.sessions, s.trash
+	s.trash = append(slices.Clone(s.trash), TrashedSession{
+		SessionMeta: s.sessions[i],
+		DeletedAt:   now,
+		ExpiresAt:   now.Add(TrashRetention),
+	})
+	s.sessions = slices.Delete(slices.Clone(s.sessions), i, i+1)
+	if err := s.persistIndex(); err != nil {
+		s.sessions, s.trash = prevSessions, prevTrash
+		return err
+	}
+
+	s.notifyChange(SessionChangeEvent{Op: OperationDelete, Session: SessionMeta{ID: sessionID}})
+	s.purgeTrash()
+	return nil
+}
+
+func (s *FileStore) Restore(ctx context.Context, sessionID string) (SessionMeta, error) {
+	if err := ctx.Err(); err != nil {
+		return SessionMeta{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.trash, func(t TrashedSession) bool { return t.ID == sessionID })
+	if i < 0 || !time.Now().Before(s.trash[i].ExpiresAt) {
+		return SessionMeta{}, ErrSessionNotFound
+	}
+	if slices.ContainsFunc(s.sessions, func(m SessionMeta) bool { return m.ID == sessionID }) {
+		return SessionMeta{}, ErrSessionExists
+	}
+
+	restored := s.trash[i].SessionMeta
+	prevSessions, prevTrash := s.sessions, s.trash
+	s.sessions = append([]SessionMeta{restored}, s.sessions...)
+	s.trash = slices.Delete(slices.Clone(s.trash), i, i+1)
+	if err := s.persistIndex(); err != nil {
+		s.sessions, s.trash = prevSessions, prevTrash
+		return SessionMeta{}, err
+	}
+
+	s.notifyChange(SessionChangeEvent{Op: OperationCreate, Session: restored})
+	return restored, nil
+}
+
+func (s *FileStore) ListTrash() ([]TrashedSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	result := make([]TrashedSession, 0, len(s.trash))
+	for _, t := range s.trash {
+		if now.Before(t.ExpiresAt) {
+			result = append(result, t)
+		}
+	}
+	slices.SortFunc(result, func(a, b TrashedSession) int {
+		return b.DeletedAt.Compare(a.DeletedAt)
+	})
+	return result, nil
+}
+
+// purgeTrash drops expired trashed sessions and deletes their history. A
+// directory that cannot be removed is logged and left behind. Caller must
+// hold s.mu, or be the constructor.
+func (s *FileStore) purgeTrash() {
+	now := time.Now()
+	kept := make([]TrashedSession, 0, len(s.trash))
+	for _, t := range s.trash {
+		if now.Before(t.ExpiresAt) {
+			kept = append(kept, t)
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.dataDir, "sessions", t.ID)); err != nil {
+			slog.Warn("failed to remove expired trashed session", "sessionId", t.ID, "error", err)
+		}
+	}
+	if len(kept) == len(s.trash) {
+		return
+	}
+	prev := s.trash
+	s.trash = kept
+	if err := s.persistIndex(); err != nil {
+		s.trash = prev
+		slog.Warn("failed to persist session index after trash purge", "error", err)
+	}
+}
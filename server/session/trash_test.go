@@ -0,0 +1,100 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore_TrashAndRestore(t *testing.T) {
+	dataDir := t.TempDir()
+	store, _ := NewFileStore(dataDir)
+
+	sess, _ := store.Create(ctx, "s1", "", "")
+	if err := store.AppendToHistory(ctx, sess.ID, map[string]string{"type": "message"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Trash(ctx, sess.ID); err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+
+	if _, found, _ := store.Get(sess.ID); found {
+		t.Error("trashed session is still listed")
+	}
+	trash, _ := store.ListTrash()
+	if len(trash) != 1 || trash[0].ID != sess.ID {
+		t.Fatalf("trash = %+v, want s1", trash)
+	}
+	if got := trash[0].ExpiresAt.Sub(trash[0].DeletedAt); got != TrashRetention {
+		t.Errorf("retention = %v, want %v", got, TrashRetention)
+	}
+
+	// The trash survives a restart.
+	store, _ = NewFileStore(dataDir)
+	restored, err := store.Restore(ctx, sess.ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.ID != sess.ID {
+		t.Errorf("restored = %+v", restored)
+	}
+	history, _ := store.GetHistory(ctx, sess.ID)
+	if len(history) != 1 {
+		t.Errorf("history after restore = %d records, want 1", len(history))
+	}
+	if trash, _ := store.ListTrash(); len(trash) != 0 {
+		t.Errorf("trash after restore = %+v, want empty", trash)
+	}
+	if _, err := store.Restore(ctx, sess.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("second Restore: err = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestFileStore_TrashEphemeralDeletes(t *testing.T) {
+	store, _ := NewFileStore(t.TempDir())
+
+	sess, _ := store.CreateEphemeral(ctx, "quick", "", "")
+	if err := store.Trash(ctx, sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if trash, _ := store.ListTrash(); len(trash) != 0 {
+		t.Errorf("trash = %+v, want quick chats deleted outright", trash)
+	}
+}
+
+func TestNewFileStore_PurgesExpiredTrash(t *testing.T) {
+	dataDir := t.TempDir()
+	store, _ := NewFileStore(dataDir)
+	store.Create(ctx, "old", "", "")
+	store.Create(ctx, "new", "", "")
+	store.AppendToHistory(ctx, "old", map[string]string{"type": "message"})
+	store.Trash(ctx, "old")
+	store.Trash(ctx, "new")
+
+	// Age the first entry past its expiry.
+	path := filepath.Join(dataDir, "sessions", "index.json")
+	data, _ := os.ReadFile(path)
+	var idx indexData
+	if err := json.Unmarshal(data, &idx); err != nil {
+		t.Fatal(err)
+	}
+	for i := range idx.Trash {
+		if idx.Trash[i].ID == "old" {
+			idx.Trash[i].ExpiresAt = time.Now().Add(-time.Minute)
+		}
+	}
+	data, _ = json.Marshal(idx)
+	os.WriteFile(path, data, 0644)
+
+	store, _ = NewFileStore(dataDir)
+	trash, _ := store.ListTrash()
+	if len(trash) != 1 || trash[0].ID != "new" {
+		t.Fatalf("trash = %+v, want only new", trash)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "sessions", "old")); !os.IsNotExist(err) {
+		t.Errorf("expired session directory still exists: %v", err)
+	}
+}
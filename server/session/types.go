@@ -2,10 +2,16 @@ package session
 
 import (
 	"errors"
+	"slices"
 	"time"
 )
 
-var ErrSessionNotFound = errors.New("session not found")
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrToolResultNotFound = errors.New("tool result not found")
+	ErrNotEphemeral       = errors.New("session is not ephemeral")
+	ErrSessionExists      = errors.New("session already exists")
+)
 
 // AgentType identifies which AI agent backend a session uses.
 type AgentType string
@@ -31,13 +37,13 @@ type Mode string
 const (
 	ModeDefault Mode = "default" // Normal mode with permission prompts
 	ModeYolo    Mode = "yolo"    // Skip all permission prompts (--dangerously-skip-permissions)
-	// ModePlan Mode = "plan"    // Planning mode (future)
+	ModePlan    Mode = "plan"    // Read-only planning; the agent proposes a plan before acting
 )
 
 // IsValid returns true if the mode is a known valid mode.
 func (m Mode) IsValid() bool {
 	switch m {
-	case ModeDefault, ModeYolo:
+	case ModeDefault, ModeYolo, ModePlan:
 		return true
 	default:
 		return false
@@ -58,8 +64,58 @@ type SessionMeta struct {
 	Mode       Mode      `json:"mode"`        // agent mode (default, yolo, plan)
 	NeedsInput bool      `json:"needs_input"` // true when waiting for user input (permission/question)
 	Unread     bool      `json:"unread"`      // true when session has unread changes
+	// AgentRoleID records the role a work session was started under when it
+	// differs from the work item's own role (work start override).
+	AgentRoleID string `json:"agent_role_id,omitempty"`
+	// UnreadCount counts agent messages received since the user last read the
+	// session; cleared together with Unread.
+	UnreadCount        int       `json:"unread_count"`
+	LastMessagePreview string    `json:"last_message_preview,omitempty"`
+	LastActivity       time.Time `json:"last_activity,omitzero"`
+	// KeepAlive exempts the session's process from the idle reaper, for
+	// long-running build/test sessions.
+	KeepAlive bool `json:"keep_alive,omitempty"`
+	// Ephemeral marks a quick-chat session: hidden from the session list and
+	// deleted when its process ends, unless kept with Store.Keep.
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// Drained is set when a server drain closed the session's process, and
+	// cleared when the next process starts. DrainedMidTurn tells clients the
+	// agent was cut off and should be asked to continue.
+	Drained DrainState `json:"drained,omitempty"`
+	// ModifiedFiles lists the worktree-relative paths that changed while the
+	// session's agent was running (see AddModifiedFiles), sorted.
+	ModifiedFiles []string `json:"modified_files,omitempty"`
+}
+
+// MaxModifiedFiles caps the attributed paths kept per session or work item;
+// a turn that rewrites a whole tree is summarized by its first paths.
+const MaxModifiedFiles = 200
+
+// MergeModifiedFiles returns a copy of the sorted list existing with paths
+// added, skipping duplicates and anything past MaxModifiedFiles. It only
+// inserts, so an unchanged length means nothing was added.
+func MergeModifiedFiles(existing, paths []string) []string {
+	merged := slices.Clone(existing)
+	for _, p := range paths {
+		if len(merged) >= MaxModifiedFiles {
+			break
+		}
+		if i, found := slices.BinarySearch(merged, p); !found {
+			merged = slices.Insert(merged, i, p)
+		}
+	}
+	return merged
 }
 
+// DrainState records what a server drain found a session doing when it
+// stopped the session's process for a restart.
+type DrainState string
+
+const (
+	DrainedIdle    DrainState = "idle"     // the turn had finished
+	DrainedMidTurn DrainState = "mid_turn" // the drain timed out during a turn
+)
+
 // Operation represents the type of change to the session list.
 type Operation string
 
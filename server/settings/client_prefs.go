@@ -0,0 +1,135 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pockode/server/filestore"
+)
+
+// Client preference bounds. Preferences are small UI state (collapsed panels,
+// board filters, font size), not a general document store.
+const (
+	MaxClientPrefKeys      = 200
+	maxClientPrefValueSize = 16 << 10
+	maxClientPrefsSize     = 256 << 10
+)
+
+var ErrInvalidClientPrefs = errors.New("invalid client preferences")
+
+var clientPrefsName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// ClientPrefs maps preference keys to arbitrary JSON values.
+type ClientPrefs map[string]json.RawMessage
+
+// ClientPrefsStore persists UI preferences server-side, namespaced by client
+// ID (e.g. "web"), so they roam between the browsers a user opens the app
+// in. The server never interprets the values.
+type ClientPrefsStore struct {
+	file *filestore.File
+}
+
+func NewClientPrefsStore(dataDir string) (*ClientPrefsStore, error) {
+	f, err := filestore.New(filestore.Config{
+		Path:  filepath.Join(dataDir, "client-prefs.json"),
+		Label: "client-prefs",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ClientPrefsStore{file: f}, nil
+}
+
+// Get returns clientID's preferences, empty when none were set.
+func (s *ClientPrefsStore) Get(clientID string) (ClientPrefs, error) {
+	if err := validateClientPrefsName("client id", clientID); err != nil {
+		return nil, err
+	}
+	all, err := filestore.Load(s.file, map[string]ClientPrefs{})
+	if err != nil {
+		return nil, err
+	}
+	prefs := all[clientID]
+	if prefs == nil {
+		prefs = ClientPrefs{}
+	}
+	return prefs, nil
+}
+
+// Set merges changes into clientID's preferences and returns the result. A
+// JSON null value removes its key. Errors from bad input wrap
+// ErrInvalidClientPrefs.
+func (s *ClientPrefsStore) Set(clientID string, changes ClientPrefs) (ClientPrefs, error) {
+	if err := validateClientPrefsName("client id", clientID); err != nil {
+		return nil, err
+	}
+	for key, value := range changes {
+		if err := validateClientPrefsName("key", key); err != nil {
+			return nil, err
+		}
+		if len(value) > maxClientPrefValueSize {
+			return nil, fmt.Errorf("%w: value of %q exceeds %d bytes", ErrInvalidClientPrefs, key, maxClientPrefValueSize)
+		}
+		if !json.Valid(value) {
+			return nil, fmt.Errorf("%w: value of %q is not valid JSON", ErrInvalidClientPrefs, key)
+		}
+	}
+
+	var merged ClientPrefs
+	err := s.file.Update(func(current []byte) ([]byte, error) {
+		all := map[string]ClientPrefs{}
+		if current != nil {
+			if err := json.Unmarshal(current, &all); err != nil {
+				return nil, fmt.Errorf("%w: client-prefs: %v", filestore.ErrCorrupt, err)
+			}
+		}
+
+		merged = maps.Clone(all[clientID])
+		if merged == nil {
+			merged = ClientPrefs{}
+		}
+		for key, value := range changes {
+			if string(value) == "null" {
+				delete(merged, key)
+			} else {
+				merged[key] = value
+			}
+		}
+		if len(merged) > MaxClientPrefKeys {
+			return nil, fmt.Errorf("%w: at most %d keys per client", ErrInvalidClientPrefs, MaxClientPrefKeys)
+		}
+		if size := prefsSize(merged); size > maxClientPrefsSize {
+			return nil, fmt.Errorf("%w: preferences exceed %d bytes per client", ErrInvalidClientPrefs, maxClientPrefsSize)
+		}
+
+		if len(merged) == 0 {
+			delete(all, clientID)
+		} else {
+			all[clientID] = merged
+		}
+		return filestore.MarshalIndex(all)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+func validateClientPrefsName(what, name string) error {
+	if !clientPrefsName.MatchString(name) {
+		return fmt.Errorf("%w: %s %q must be 1-64 letters, digits, '.', '-' or '_'", ErrInvalidClientPrefs, what, name)
+	}
+	return nil
+}
+
+func prefsSize(prefs ClientPrefs) int {
+	size := 0
+	for key, value := range prefs {
+		size += len(key) + len(value)
+	}
+	return size
+}
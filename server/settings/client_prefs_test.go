@@ -0,0 +1,69 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClientPrefsStore_SetMergesAndRoams(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewClientPrefsStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prefs, err := store.Get("web"); err != nil || len(prefs) != 0 {
+		t.Fatalf("Get before Set = %v, %v; want empty", prefs, err)
+	}
+	if _, err := store.Set("web", ClientPrefs{"font_size": json.RawMessage(`14`), "collapsed": json.RawMessage(`["sidebar"]`)}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	prefs, err := store.Set("web", ClientPrefs{"font_size": json.RawMessage(`16`), "collapsed": json.RawMessage(`null`)})
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if len(prefs) != 1 || string(prefs["font_size"]) != "16" {
+		t.Errorf("Set = %s, want only font_size 16", prefs)
+	}
+
+	// Another browser opening the same data dir sees the change.
+	other, err := NewClientPrefsStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := other.Get("web"); string(got["font_size"]) != "16" {
+		t.Errorf("Get from another store = %s, want font_size 16", got)
+	}
+	if got, _ := other.Get("mobile"); len(got) != 0 {
+		t.Errorf("Get(mobile) = %s, want namespaces kept apart", got)
+	}
+}
+
+func TestClientPrefsStore_SetRejectsInvalid(t *testing.T) {
+	store, err := NewClientPrefsStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		clientID string
+		prefs    ClientPrefs
+	}{
+		"empty client id": {"", ClientPrefs{"a": json.RawMessage(`1`)}},
+		"bad key":         {"web", ClientPrefs{"a b": json.RawMessage(`1`)}},
+		"invalid json":    {"web", ClientPrefs{"a": json.RawMessage(`{`)}},
+		"value too large": {"web", ClientPrefs{"a": json.RawMessage(`"` + strings.Repeat("x", maxClientPrefValueSize) + `"`)}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Set(tt.clientID, tt.prefs); !errors.Is(err, ErrInvalidClientPrefs) {
+				t.Errorf("Set error = %v, want ErrInvalidClientPrefs", err)
+			}
+		})
+	}
+	if prefs, _ := store.Get("web"); len(prefs) != 0 {
+		t.Errorf("Get after rejected Sets = %s, want empty", prefs)
+	}
+}
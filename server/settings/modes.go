@@ -0,0 +1,89 @@
+package settings
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pockode/server/session"
+)
+
+// CustomMode is a user-defined session mode layered on a built-in one.
+// Sessions and work store its name in place of a built-in mode.
+type CustomMode struct {
+	Name session.Mode `json:"name"`
+	// Base is the built-in mode that decides permission prompting ("default"
+	// prompts, "plan" plans first, "yolo" skips prompts). Empty = default.
+	Base session.Mode `json:"base,omitempty"`
+	// Appended to the agent's system prompt.
+	PromptSuffix string `json:"prompt_suffix,omitempty"`
+	// When non-empty, the only tools the agent may use; permission requests
+	// for any other tool are denied by the server without asking. Entries
+	// ending in "*" match by prefix ("mcp__pockode__*").
+	AllowedTools []string `json:"allowed_tools,omitempty"`
+}
+
+var modeNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// BaseMode returns the effective built-in base.
+func (m CustomMode) BaseMode() session.Mode {
+	if m.Base == "" {
+		return session.ModeDefault
+	}
+	return m.Base
+}
+
+// ResolveMode returns the definition of a built-in or custom mode. A
+// built-in mode resolves to itself with no suffix or tool restriction; an
+// empty one to the default mode.
+func (s Settings) ResolveMode(name session.Mode) (CustomMode, bool) {
+	if name == "" {
+		name = session.ModeDefault
+	}
+	if name.IsValid() {
+		return CustomMode{Name: name, Base: name}, true
+	}
+	for _, m := range s.Modes {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return CustomMode{}, false
+}
+
+// IsValidMode reports whether name is a built-in or custom mode.
+func (s Settings) IsValidMode(name session.Mode) bool {
+	_, ok := s.ResolveMode(name)
+	return ok
+}
+
+// ValidateModes checks the custom mode definitions: names are unique,
+// well-formed and do not shadow a built-in, bases are built-in, and
+// DefaultMode refers to a known mode.
+func (s Settings) ValidateModes() error {
+	seen := make(map[session.Mode]bool, len(s.Modes))
+	for _, m := range s.Modes {
+		if !modeNameRe.MatchString(string(m.Name)) {
+			return fmt.Errorf("invalid mode name %q: use 1-32 lowercase letters, digits, '-' or '_'", m.Name)
+		}
+		if m.Name.IsValid() {
+			return fmt.Errorf("mode name %q is reserved for a built-in mode", m.Name)
+		}
+		if seen[m.Name] {
+			return fmt.Errorf("duplicate mode name %q", m.Name)
+		}
+		seen[m.Name] = true
+		if m.Base != "" && !m.Base.IsValid() {
+			return fmt.Errorf("mode %q: base must be a built-in mode, got %q", m.Name, m.Base)
+		}
+		for _, tool := range m.AllowedTools {
+			if strings.TrimSpace(tool) == "" || tool == "*" {
+				return fmt.Errorf("mode %q: invalid allowed tool %q", m.Name, tool)
+			}
+		}
+	}
+	if s.DefaultMode != "" && !s.IsValidMode(s.DefaultMode) {
+		return fmt.Errorf("invalid default mode %q", s.DefaultMode)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package settings
+
+import (
+	"testing"
+
+	"github.com/pockode/server/session"
+)
+
+func TestResolveMode(t *testing.T) {
+	s := Settings{Modes: []CustomMode{{Name: "review", PromptSuffix: "Review only.", AllowedTools: []string{"Read"}}}}
+
+	m, ok := s.ResolveMode("review")
+	if !ok || m.BaseMode() != session.ModeDefault || m.PromptSuffix != "Review only." {
+		t.Errorf("ResolveMode(review) = %+v, %v", m, ok)
+	}
+	if m, ok := s.ResolveMode(session.ModeYolo); !ok || m.BaseMode() != session.ModeYolo || len(m.AllowedTools) != 0 {
+		t.Errorf("ResolveMode(yolo) = %+v, %v; want the built-in unrestricted", m, ok)
+	}
+	if m, ok := s.ResolveMode(""); !ok || m.BaseMode() != session.ModeDefault {
+		t.Errorf("ResolveMode(\"\") = %+v, %v; want default", m, ok)
+	}
+	if _, ok := s.ResolveMode("unknown"); ok {
+		t.Error("unknown mode resolved")
+	}
+}
+
+func TestValidateModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Settings
+		wantErr bool
+	}{
+		{"none", Settings{}, false},
+		{"valid", Settings{DefaultMode: "review", Modes: []CustomMode{{Name: "review", Base: session.ModePlan, AllowedTools: []string{"Read", "mcp__pockode__*"}}}}, false},
+		{"bad name", Settings{Modes: []CustomMode{{Name: "Review Only"}}}, true},
+		{"shadows built-in", Settings{Modes: []CustomMode{{Name: "yolo"}}}, true},
+		{"duplicate", Settings{Modes: []CustomMode{{Name: "a"}, {Name: "a"}}}, true},
+		{"custom base", Settings{Modes: []CustomMode{{Name: "a"}, {Name: "b", Base: "a"}}}, true},
+		{"allow everything", Settings{Modes: []CustomMode{{Name: "a", AllowedTools: []string{"*"}}}}, true},
+		{"unknown default", Settings{DefaultMode: "review"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.s.ValidateModes(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateModes() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSettingsEqual(t *testing.T) {
+	a := Settings{Modes: []CustomMode{{Name: "review", AllowedTools: []string{"Read"}}}}
+	b := Settings{Modes: []CustomMode{{Name: "review", AllowedTools: []string{"Read"}}}}
+	if !a.Equal(b) {
+		t.Error("identical settings are not equal")
+	}
+	b.Modes[0].AllowedTools = []string{"Read", "Grep"}
+	if a.Equal(b) {
+		t.Error("differing allowed tools are equal")
+	}
+	if !(Settings{Modes: []CustomMode{}}).Equal(Settings{}) {
+		t.Error("empty and nil modes are not equal")
+	}
+}
@@ -1,12 +1,294 @@
 // Package settings provides server-side settings management.
 package settings
 
-import "github.com/pockode/server/session"
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/i18n"
+	"github.com/pockode/server/issuesync"
+	"github.com/pockode/server/protected"
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/work"
+	"github.com/pockode/server/workhook"
+)
 
 type Settings struct {
 	DefaultAgentRoleID string            `json:"default_agent_role_id,omitempty"`
 	DefaultAgentType   session.AgentType `json:"default_agent_type,omitempty"`
-	DefaultMode        session.Mode      `json:"default_mode,omitempty"`
+	DefaultMode        session.Mode      `json:"default_mode,omitempty"` // built-in or a Modes name
+
+	// Agent role that reviews stories before they close. When set, a
+	// story's final step_done starts a review task under this role instead,
+	// and the story closes once the review is done. Empty = no review.
+	ReviewAgentRoleID string `json:"review_agent_role_id,omitempty"`
+
+	// Language of server-generated text: work prompts sent to agents, and
+	// RPC errors for connections that did not ask for a locale at auth.
+	// Empty = i18n.Default.
+	Locale i18n.Locale `json:"locale,omitempty"`
+
+	// User-defined session modes, selectable wherever a mode is accepted.
+	Modes []CustomMode `json:"modes,omitempty"`
+
+	// Git identity applied to agent processes so their commits are
+	// attributed (and optionally signed) even if the host git config is unset.
+	GitUserName      string `json:"git_user_name,omitempty"`
+	GitUserEmail     string `json:"git_user_email,omitempty"`
+	GitSigningKey    string `json:"git_signing_key,omitempty"`    // SSH key path or GPG key ID
+	GitSigningFormat string `json:"git_signing_format,omitempty"` // "openpgp" | "ssh"; empty = git default
+
+	// Daily activity digest. Empty URL disables delivery.
+	DigestWebhookURL string `json:"digest_webhook_url,omitempty"`
+	DigestHour       int    `json:"digest_hour,omitempty"` // 0-23, server local time
+
+	// Tool results larger than this are truncated in the chat stream; the
+	// full output is kept for chat.toolresult.get. 0 = DefaultToolResultMaxBytes.
+	ToolResultMaxBytes int `json:"tool_result_max_bytes,omitempty"`
+
+	// When set, agent thinking blocks are dropped: neither saved to history
+	// nor sent to clients. Default is to keep them.
+	HideThinking bool `json:"hide_thinking,omitempty"`
+
+	// What startup reconciliation does with work left in_progress by a
+	// previous server run. Empty = work.OrphanPolicyStop.
+	OrphanedWorkPolicy work.OrphanPolicy `json:"orphaned_work_policy,omitempty"`
+
+	// Seconds a permission request may go unanswered before the default
+	// answer is sent: deny, or allow for read-only tools when
+	// PermissionTimeoutAllowReadOnly is set. 0 = wait forever.
+	PermissionTimeoutSeconds       int  `json:"permission_timeout_seconds,omitempty"`
+	PermissionTimeoutAllowReadOnly bool `json:"permission_timeout_allow_read_only,omitempty"`
+
+	// Minutes a running agent may produce no output before
+	// process.possibly_stuck is sent. 0 = DefaultStuckAfterMinutes;
+	// negative disables the watchdog.
+	StuckAfterMinutes int `json:"stuck_after_minutes,omitempty"`
+
+	// Agent processes each worktree keeps started ahead of need, so a new
+	// session of the default agent type and mode skips the CLI startup.
+	// 0 disables the pool; at most process.MaxWarmPoolSize.
+	WarmPoolSize int `json:"warm_pool_size,omitempty"`
+
+	// When set, a CI failure on a worktree's branch is sent to the in_progress
+	// work running in that worktree as a message asking for a fix.
+	CIFailureFeedback bool `json:"ci_failure_feedback,omitempty"`
+	// Comma- or newline-separated path patterns (see protected.Patterns)
+	// that git.add and file.write refuse and permission timeouts never
+	// auto-allow.
+	ProtectedPaths string `json:"protected_paths,omitempty"`
+
+	// Worktree setup, run in the background after worktree.create. Copies
+	// are comma- or newline-separated paths or globs relative to the main
+	// worktree, copied in when missing (".env, .env.local"); they run
+	// before the worktree-setup.sh hook. Commands are newline-separated
+	// shell commands run after it ("npm install").
+	WorktreeSetupCopy     string `json:"worktree_setup_copy,omitempty"`
+	WorktreeSetupCommands string `json:"worktree_setup_commands,omitempty"`
+	// Branch naming policy for worktree.create (see git.BranchTemplate),
+	// e.g. "feature/{work-short-id}-{slug}". When set, explicit branch names
+	// must match it and an omitted one is generated from it. Empty = any
+	// name, and one must be given.
+	BranchTemplate git.BranchTemplate `json:"branch_template,omitempty"`
+
+	// Lead times before a work item's due date at which work.due_soon is
+	// sent, as comma-separated durations ("24h,1h"). Empty =
+	// work.DefaultDueLeads; work.DueLeadsOff disables reminders.
+	WorkDueLeads string `json:"work_due_leads,omitempty"`
+	// Each reminder is also POSTed here as JSON when set.
+	WorkDueWebhookURL string `json:"work_due_webhook_url,omitempty"`
+	// When set, due reminders and the digest cover only watched work items
+	// (see work.NotifyFilter). Muted items are left out either way.
+	NotifyWatchedOnly bool `json:"notify_watched_only,omitempty"`
+
+	// Shell commands run on work lifecycle transitions, in order (see
+	// workhook.Runner). They run in the main worktree and every run is
+	// recorded in the audit log.
+	WorkHooks []workhook.Hook `json:"work_hooks,omitempty"`
+
+	// Issue tracker sync (see issuesync.Syncer). Provider is "jira" or
+	// "linear"; empty disables sync. Project is the Jira project key or
+	// Linear team key. The maps are comma- or newline-separated pairs,
+	// work_field=tracker_field and work_status=tracker_status; empty uses
+	// the provider's defaults. API tokens are passed as flags, not here.
+	SyncProvider        issuesync.ProviderName `json:"sync_provider,omitempty"`
+	SyncJiraURL         string                 `json:"sync_jira_url,omitempty"`
+	SyncProject         string                 `json:"sync_project,omitempty"`
+	SyncFieldMap        string                 `json:"sync_field_map,omitempty"`
+	SyncStatusMap       string                 `json:"sync_status_map,omitempty"`
+	SyncIntervalSeconds int                    `json:"sync_interval_seconds,omitempty"` // 0 = issuesync.DefaultInterval
+
+	// Autorun limits (see work.AutorunGate). Hours are "HH:MM-HH:MM" in
+	// server local time and may wrap midnight; days are "mon-fri" or
+	// "sat,sun". Empty means any time. The budget caps the sessions autorun
+	// drives and the tokens they use per daily or weekly period; 0 = no cap.
+	AutorunHours        string             `json:"autorun_hours,omitempty"`
+	AutorunDays         string             `json:"autorun_days,omitempty"`
+	AutorunBudgetPeriod work.AutorunPeriod `json:"autorun_budget_period,omitempty"`
+	AutorunMaxSessions  int                `json:"autorun_max_sessions,omitempty"`
+	AutorunMaxTokens    int64              `json:"autorun_max_tokens,omitempty"`
+
+	// MCP tool call guard (see mcp.CallGuard). Rate limits are
+	// comma-separated tool=calls/duration entries, "*" covering tools
+	// without their own ("work_create=10/1m,*=60/1m"); empty =
+	// mcp.DefaultRateLimits, "off" disables them. A session calling a tool
+	// with identical arguments more than MCPLoopRepeats times within
+	// MCPLoopWindowSeconds is refused: 0 = defaults, negative repeats
+	// disables loop detection.
+	MCPRateLimits        string `json:"mcp_rate_limits,omitempty"`
+	MCPLoopRepeats       int    `json:"mcp_loop_repeats,omitempty"`
+	MCPLoopWindowSeconds int    `json:"mcp_loop_window_seconds,omitempty"`
+}
+
+// DefaultToolResultMaxBytes keeps typical command output intact while
+// stopping multi-megabyte logs from flooding history and clients.
+const DefaultToolResultMaxBytes = 64 * 1024
+
+// ToolResultLimit returns the effective tool result truncation threshold.
+func (s Settings) ToolResultLimit() int {
+	if s.ToolResultMaxBytes <= 0 {
+		return DefaultToolResultMaxBytes
+	}
+	return s.ToolResultMaxBytes
+}
+
+// DefaultStuckAfterMinutes is long enough that a slow build or test run
+// rarely trips it, short enough that a hung prompt is noticed.
+const DefaultStuckAfterMinutes = 10
+
+// StuckAfter returns the effective stuck-process threshold; 0 disables.
+func (s Settings) StuckAfter() time.Duration {
+	switch {
+	case s.StuckAfterMinutes < 0:
+		return 0
+	case s.StuckAfterMinutes == 0:
+		return DefaultStuckAfterMinutes * time.Minute
+	default:
+		return time.Duration(s.StuckAfterMinutes) * time.Minute
+	}
+}
+
+// IssueSyncConfig returns the issue tracker sync configuration.
+func (s Settings) IssueSyncConfig() issuesync.Config {
+	return issuesync.Config{
+		Provider:  s.SyncProvider,
+		JiraURL:   s.SyncJiraURL,
+		Project:   s.SyncProject,
+		FieldMap:  s.SyncFieldMap,
+		StatusMap: s.SyncStatusMap,
+		Interval:  time.Duration(s.SyncIntervalSeconds) * time.Second,
+	}
+}
+
+// ProtectedPathPatterns parses ProtectedPaths.
+func (s Settings) ProtectedPathPatterns() protected.Patterns {
+	return protected.Parse(s.ProtectedPaths)
+}
+
+// WorktreeSetupCopyPaths parses WorktreeSetupCopy.
+func (s Settings) WorktreeSetupCopyPaths() []string {
+	return splitList(s.WorktreeSetupCopy, ",\n")
+}
+
+// WorktreeSetupCommandList parses WorktreeSetupCommands. Commands may
+// contain commas, so only newlines separate them.
+func (s Settings) WorktreeSetupCommandList() []string {
+	return splitList(s.WorktreeSetupCommands, "\n")
+}
+
+func splitList(s, seps string) []string {
+	var out []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(seps, r) }) {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// DueLeads parses WorkDueLeads. Invalid values (rejected by settings.update,
+// so only from a hand-edited file) fall back to the defaults.
+func (s Settings) DueLeads() []time.Duration {
+	leads, err := work.ParseDueLeads(s.WorkDueLeads)
+	if err != nil {
+		leads, _ = work.ParseDueLeads("")
+	}
+	return leads
+}
+
+// AutorunLimits parses the autorun settings. An invalid schedule (rejected
+// by settings.update, so only from a hand-edited file) is ignored rather
+// than pausing autorun for good.
+func (s Settings) AutorunLimits() work.AutorunLimits {
+	limits := work.AutorunLimits{
+		Period:      s.AutorunBudgetPeriod,
+		MaxSessions: max(s.AutorunMaxSessions, 0),
+		MaxTokens:   max(s.AutorunMaxTokens, 0),
+	}
+	if !limits.Period.IsValid() {
+		limits.Period = work.AutorunDaily
+	}
+	if start, end, err := work.ParseAutorunHours(s.AutorunHours); err == nil {
+		limits.Start, limits.End = start, end
+	}
+	if days, err := work.ParseAutorunDays(s.AutorunDays); err == nil {
+		limits.Days = days
+	}
+	return limits
+}
+
+// EffectiveLocale returns Locale, or i18n.Default when unset or invalid
+// (only from a hand-edited file, since settings.update rejects it).
+func (s Settings) EffectiveLocale() i18n.Locale {
+	if !s.Locale.IsValid() {
+		return i18n.Default
+	}
+	return s.Locale
+}
+
+// Effective returns s with the defaults its empty fields stand for filled
+// in. Defaults owned by packages that import this one (the MCP guard's)
+// are left to the caller.
+func (s Settings) Effective() Settings {
+	s.Locale = s.EffectiveLocale()
+	s.ToolResultMaxBytes = s.ToolResultLimit()
+	if s.StuckAfterMinutes == 0 {
+		s.StuckAfterMinutes = DefaultStuckAfterMinutes
+	}
+	if s.OrphanedWorkPolicy == "" {
+		s.OrphanedWorkPolicy = work.OrphanPolicyStop
+	}
+	if strings.TrimSpace(s.WorkDueLeads) == "" {
+		s.WorkDueLeads = work.DefaultDueLeads
+	}
+	if s.AutorunBudgetPeriod == "" {
+		s.AutorunBudgetPeriod = work.AutorunDaily
+	}
+	return s
+}
+
+// GitIdentity returns the git identity portion of the settings.
+func (s Settings) GitIdentity() git.Identity {
+	return git.Identity{
+		Name:          s.GitUserName,
+		Email:         s.GitUserEmail,
+		SigningKey:    s.GitSigningKey,
+		SigningFormat: s.GitSigningFormat,
+	}
+}
+
+// Equal reports whether two settings are identical. Empty Modes and
+// WorkHooks lists equal nil ones, as both encode to nothing.
+func (s Settings) Equal(o Settings) bool {
+	if len(s.Modes) == 0 && len(o.Modes) == 0 {
+		s.Modes, o.Modes = nil, nil
+	}
+	if len(s.WorkHooks) == 0 && len(o.WorkHooks) == 0 {
+		s.WorkHooks, o.WorkHooks = nil, nil
+	}
+	return reflect.DeepEqual(s, o)
 }
 
 func Default() Settings {
@@ -1,9 +1,10 @@
 package settings
 
 import (
-	"encoding/json"
+	"errors"
 	"log/slog"
 	"path/filepath"
+	"slices"
 	"sync"
 
 	"github.com/pockode/server/filestore"
@@ -15,10 +16,10 @@ type OnChangeListener interface {
 }
 
 type Store struct {
-	file     *filestore.File
-	dataMu   sync.RWMutex
-	data     Settings
-	listener OnChangeListener
+	file      *filestore.File
+	dataMu    sync.RWMutex
+	data      Settings
+	listeners []OnChangeListener
 }
 
 // NewStore loads existing settings from disk or uses defaults.
@@ -51,33 +52,28 @@ func (s *Store) Get() Settings {
 }
 
 func (s *Store) Update(settings Settings) error {
-	data, err := filestore.MarshalIndex(settings)
-	if err != nil {
-		return err
-	}
-
 	s.dataMu.Lock()
 
-	if err := s.file.Write(data); err != nil {
+	if err := s.file.Persist(settings); err != nil {
 		s.dataMu.Unlock()
 		return err
 	}
 
 	s.data = settings
-	listener := s.listener
+	listeners := slices.Clone(s.listeners)
 	s.dataMu.Unlock()
 
-	if listener != nil {
-		listener.OnSettingsChange(settings)
+	for _, l := range listeners {
+		l.OnSettingsChange(settings)
 	}
 
 	return nil
 }
 
-func (s *Store) SetOnChangeListener(listener OnChangeListener) {
+func (s *Store) AddOnChangeListener(listener OnChangeListener) {
 	s.dataMu.Lock()
 	defer s.dataMu.Unlock()
-	s.listener = listener
+	s.listeners = append(s.listeners, listener)
 }
 
 func (s *Store) StartWatching() error {
@@ -89,52 +85,39 @@ func (s *Store) StopWatching() {
 }
 
 func (s *Store) load() error {
-	data, err := s.file.Read()
-	if err != nil {
-		return err
-	}
-	if data == nil {
-		return nil
-	}
-
-	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
+	settings, err := filestore.Load(s.file, Default())
+	if errors.Is(err, filestore.ErrCorrupt) {
 		// Fall back to default for corrupted JSON
 		return nil
 	}
+	if err != nil {
+		return err
+	}
 
 	s.data = settings
 	return nil
 }
 
 func (s *Store) reloadFromDisk() {
-	genBefore := s.file.SnapshotGen()
+	var changed bool
+	var settings Settings
+	var listeners []OnChangeListener
 
-	data, err := s.file.Read()
+	err := filestore.Reload(s.file, &s.dataMu, Default(), func(loaded Settings) {
+		changed = !s.data.Equal(loaded)
+		s.data = loaded
+		settings = loaded
+		listeners = slices.Clone(s.listeners)
+	})
 	if err != nil {
-		slog.Error("settings: failed to read from disk on reload", "error", err)
-		return
-	}
-
-	var settings Settings
-	if data == nil {
-		settings = Default()
-	} else if err := json.Unmarshal(data, &settings); err != nil {
-		slog.Error("settings: failed to unmarshal on reload", "error", err)
+		slog.Error("settings: failed to reload from disk", "error", err)
 		return
 	}
 
-	s.dataMu.Lock()
-	if s.file.IsStale(genBefore) {
-		s.dataMu.Unlock()
+	if !changed {
 		return
 	}
-	old := s.data
-	s.data = settings
-	listener := s.listener
-	s.dataMu.Unlock()
-
-	if listener != nil && old != settings {
-		listener.OnSettingsChange(settings)
+	for _, l := range listeners {
+		l.OnSettingsChange(settings)
 	}
 }
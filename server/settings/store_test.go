@@ -16,7 +16,7 @@ func TestNewStore_DefaultsWhenNoFile(t *testing.T) {
 
 	got := store.Get()
 	want := Default()
-	if got != want {
+	if !got.Equal(want) {
 		t.Errorf("expected default settings %+v, got %+v", want, got)
 	}
 }
@@ -36,7 +36,7 @@ func TestNewStore_LoadsExistingFile(t *testing.T) {
 
 	got := store.Get()
 	want := Settings{}
-	if got != want {
+	if !got.Equal(want) {
 		t.Errorf("expected settings %+v, got %+v", want, got)
 	}
 }
@@ -56,7 +56,7 @@ func TestNewStore_FallsBackOnCorruptedJSON(t *testing.T) {
 
 	got := store.Get()
 	want := Default()
-	if got != want {
+	if !got.Equal(want) {
 		t.Errorf("expected default settings %+v, got %+v", want, got)
 	}
 }
@@ -73,7 +73,7 @@ func TestStore_Update(t *testing.T) {
 	}
 
 	got := store.Get()
-	if got != newSettings {
+	if !got.Equal(newSettings) {
 		t.Errorf("expected settings %+v, got %+v", newSettings, got)
 	}
 }
@@ -88,7 +88,7 @@ func TestStore_Update_PersistsToDisk(t *testing.T) {
 	store2, _ := NewStore(dir)
 	got := store2.Get()
 	want := Settings{DefaultAgentRoleID: "role-456"}
-	if got != want {
+	if !got.Equal(want) {
 		t.Errorf("expected persisted settings %+v, got %+v", want, got)
 	}
 }
@@ -103,7 +103,7 @@ func TestStore_ExternalChange_NotifiesListener(t *testing.T) {
 	var mu sync.Mutex
 	var received Settings
 	var called bool
-	store.SetOnChangeListener(listenerFunc(func(s Settings) {
+	store.AddOnChangeListener(listenerFunc(func(s Settings) {
 		mu.Lock()
 		defer mu.Unlock()
 		received = s
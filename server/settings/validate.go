@@ -0,0 +1,225 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/issuesync"
+	"github.com/pockode/server/process"
+	"github.com/pockode/server/work"
+)
+
+// FieldError reports one invalid setting by its JSON key.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type fieldRule struct {
+	field string
+	check func(Settings) error
+}
+
+// fieldRules validates each setting on its own, in struct order. Checks
+// that need other stores (the default agent role, MCP rate limits) are
+// left to the caller.
+var fieldRules = []fieldRule{
+	{"default_agent_type", func(s Settings) error {
+		if s.DefaultAgentType != "" && !s.DefaultAgentType.IsValid() {
+			return errors.New("invalid default agent type")
+		}
+		return nil
+	}},
+	{"default_mode", func(s Settings) error {
+		if s.DefaultMode != "" && !s.IsValidMode(s.DefaultMode) {
+			return fmt.Errorf("invalid default mode %q", s.DefaultMode)
+		}
+		return nil
+	}},
+	{"locale", func(s Settings) error {
+		if s.Locale != "" && !s.Locale.IsValid() {
+			return errors.New("invalid locale")
+		}
+		return nil
+	}},
+	{"modes", func(s Settings) error {
+		// default_mode has its own rule.
+		s.DefaultMode = ""
+		return s.ValidateModes()
+	}},
+	{"git_user_name", func(s Settings) error { return git.Identity{Name: s.GitUserName}.Validate() }},
+	{"git_user_email", func(s Settings) error { return git.Identity{Email: s.GitUserEmail}.Validate() }},
+	{"git_signing_key", func(s Settings) error { return git.Identity{SigningKey: s.GitSigningKey}.Validate() }},
+	{"git_signing_format", func(s Settings) error {
+		if (git.Identity{SigningKey: s.GitSigningKey}).Validate() != nil {
+			return nil // reported under git_signing_key
+		}
+		return git.Identity{SigningKey: s.GitSigningKey, SigningFormat: s.GitSigningFormat}.Validate()
+	}},
+	{"digest_webhook_url", func(s Settings) error {
+		return validateWebhookURL(s.DigestWebhookURL, "digest webhook URL must be an http(s) URL")
+	}},
+	{"digest_hour", func(s Settings) error {
+		if s.DigestHour < 0 || s.DigestHour > 23 {
+			return errors.New("digest hour must be between 0 and 23")
+		}
+		return nil
+	}},
+	{"tool_result_max_bytes", func(s Settings) error {
+		if s.ToolResultMaxBytes < 0 {
+			return errors.New("tool result max bytes must not be negative")
+		}
+		return nil
+	}},
+	{"orphaned_work_policy", func(s Settings) error {
+		if s.OrphanedWorkPolicy != "" && !s.OrphanedWorkPolicy.IsValid() {
+			return errors.New("invalid orphaned work policy")
+		}
+		return nil
+	}},
+	{"permission_timeout_seconds", func(s Settings) error {
+		if s.PermissionTimeoutSeconds < 0 {
+			return errors.New("permission timeout must not be negative")
+		}
+		return nil
+	}},
+	{"warm_pool_size", func(s Settings) error {
+		if s.WarmPoolSize < 0 || s.WarmPoolSize > process.MaxWarmPoolSize {
+			return fmt.Errorf("warm pool size must be between 0 and %d", process.MaxWarmPoolSize)
+		}
+		return nil
+	}},
+	{"protected_paths", func(s Settings) error { return s.ProtectedPathPatterns().Validate() }},
+	{"branch_template", func(s Settings) error { return s.BranchTemplate.Validate() }},
+	{"work_due_leads", func(s Settings) error {
+		_, err := work.ParseDueLeads(s.WorkDueLeads)
+		return err
+	}},
+	{"work_due_webhook_url", func(s Settings) error {
+		return validateWebhookURL(s.WorkDueWebhookURL, "work due webhook URL must be an http(s) URL")
+	}},
+	{"work_hooks", func(s Settings) error {
+		for _, h := range s.WorkHooks {
+			if err := h.Validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{"sync_provider", func(s Settings) error {
+		if !s.SyncProvider.IsValid() {
+			return errors.New("sync provider must be jira or linear")
+		}
+		return nil
+	}},
+	{"sync_jira_url", func(s Settings) error { return issuesync.ValidateJiraURL(s.SyncProvider, s.SyncJiraURL) }},
+	{"sync_project", func(s Settings) error { return issuesync.ValidateProject(s.SyncProvider, s.SyncProject) }},
+	{"sync_field_map", func(s Settings) error {
+		if !s.SyncProvider.IsValid() {
+			return nil // reported under sync_provider
+		}
+		_, err := issuesync.ParseFieldMap(s.SyncProvider, s.SyncFieldMap)
+		return err
+	}},
+	{"sync_status_map", func(s Settings) error {
+		_, err := issuesync.ParseStatusMap(s.SyncProvider, s.SyncStatusMap)
+		return err
+	}},
+	{"sync_interval_seconds", func(s Settings) error {
+		if s.SyncIntervalSeconds < 0 {
+			return errors.New("sync interval must not be negative")
+		}
+		return nil
+	}},
+	{"autorun_hours", func(s Settings) error {
+		_, _, err := work.ParseAutorunHours(s.AutorunHours)
+		return err
+	}},
+	{"autorun_days", func(s Settings) error {
+		_, err := work.ParseAutorunDays(s.AutorunDays)
+		return err
+	}},
+	{"autorun_budget_period", func(s Settings) error {
+		if !s.AutorunBudgetPeriod.IsValid() {
+			return fmt.Errorf("invalid autorun budget period %q: use daily or weekly", s.AutorunBudgetPeriod)
+		}
+		return nil
+	}},
+	{"autorun_max_sessions", func(s Settings) error {
+		if s.AutorunMaxSessions < 0 {
+			return errors.New("autorun budget must not be negative")
+		}
+		return nil
+	}},
+	{"autorun_max_tokens", func(s Settings) error {
+		if s.AutorunMaxTokens < 0 {
+			return errors.New("autorun budget must not be negative")
+		}
+		return nil
+	}},
+	{"mcp_loop_window_seconds", func(s Settings) error {
+		if s.MCPLoopWindowSeconds < 0 {
+			return errors.New("mcp loop window must not be negative")
+		}
+		return nil
+	}},
+}
+
+func validateWebhookURL(u, message string) error {
+	if u == "" {
+		return nil
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// Validate checks every field and returns all problems, so a form can
+// mark each bad input at once. Nil means valid.
+func (s Settings) Validate() []FieldError {
+	var errs []FieldError
+	for _, r := range fieldRules {
+		if err := r.check(s); err != nil {
+			errs = append(errs, FieldError{Field: r.field, Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// jsonFields lists the JSON keys of Settings.
+var jsonFields = func() []string {
+	t := reflect.TypeFor[Settings]()
+	fields := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}()
+
+// UnknownFields returns the keys of the settings object raw that Settings
+// does not have, sorted. Decoding would drop them silently, so a client
+// sending a misspelled or newer key would lose the change unnoticed.
+func UnknownFields(raw json.RawMessage) ([]string, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for k := range obj {
+		if !slices.Contains(jsonFields, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown, nil
+}
@@ -0,0 +1,69 @@
+package settings
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/pockode/server/work"
+	"github.com/pockode/server/workhook"
+)
+
+func TestValidate(t *testing.T) {
+	if errs := Default().Validate(); errs != nil {
+		t.Fatalf("Default().Validate() = %v, want nil", errs)
+	}
+
+	s := Settings{
+		DigestHour:          24,
+		DigestWebhookURL:    "ftp://example.com",
+		AutorunBudgetPeriod: "monthly",
+		AutorunMaxTokens:    -1,
+		GitSigningFormat:    "ssh",
+		WarmPoolSize:        5,
+		BranchTemplate:      "feature/{ticket}",
+		WorkHooks:           []workhook.Hook{{Event: "moved", Command: "true"}},
+		SyncProvider:        "jira",
+		SyncProject:         "PRJ",
+		SyncIntervalSeconds: -1,
+	}
+	var fields []string
+	for _, e := range s.Validate() {
+		fields = append(fields, e.Field)
+	}
+	want := []string{"git_signing_format", "digest_webhook_url", "digest_hour", "warm_pool_size", "branch_template", "work_hooks", "sync_jira_url", "sync_interval_seconds", "autorun_budget_period", "autorun_max_tokens"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("invalid fields = %v, want %v", fields, want)
+	}
+}
+
+func TestValidate_GitSigningKeyReportedOnce(t *testing.T) {
+	errs := Settings{GitSigningKey: "a\nb", GitSigningFormat: "ssh"}.Validate()
+	if len(errs) != 1 || errs[0].Field != "git_signing_key" {
+		t.Errorf("Validate() = %v, want only git_signing_key", errs)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	unknown, err := UnknownFields(json.RawMessage(`{"digest_hour": 3, "digest_houre": 4, "theme": "dark"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(unknown, []string{"digest_houre", "theme"}) {
+		t.Errorf("UnknownFields = %v", unknown)
+	}
+	if _, err := UnknownFields(json.RawMessage(`[]`)); err == nil {
+		t.Error("UnknownFields accepted a non-object")
+	}
+}
+
+func TestEffective(t *testing.T) {
+	e := Settings{StuckAfterMinutes: -1}.Effective()
+	if e.Locale == "" || e.ToolResultMaxBytes != DefaultToolResultMaxBytes || e.OrphanedWorkPolicy != work.OrphanPolicyStop ||
+		e.WorkDueLeads != work.DefaultDueLeads || e.AutorunBudgetPeriod != work.AutorunDaily {
+		t.Errorf("Effective() = %+v, want defaults filled in", e)
+	}
+	if e.StuckAfterMinutes != -1 {
+		t.Errorf("StuckAfterMinutes = %d, want -1 (disabled) kept", e.StuckAfterMinutes)
+	}
+}
@@ -0,0 +1,49 @@
+package setup
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const hookFilename = "worktree-setup.sh"
+
+const defaultHookContent = `#!/bin/bash
+set -eu
+
+# Worktree setup hook for Pockode
+# Runs in the background after a new worktree is created; progress and
+# failures are reported via worktree.setup.status.changed.
+#
+# Environment variables:
+#   $POCKODE_MAIN_DIR      - Path to main worktree
+#   $POCKODE_WORKTREE_PATH - Path to newly created worktree (= cwd)
+#   $POCKODE_WORKTREE_NAME - Name of the worktree
+
+# Symlink Claude Code local settings (share permissions across worktrees)
+# if [ -f "$POCKODE_MAIN_DIR/.claude/settings.local.json" ]; then
+#     mkdir -p .claude
+#     ln -s "$POCKODE_MAIN_DIR/.claude/settings.local.json" .claude/settings.local.json
+# fi
+
+# Install npm dependencies
+# if [ -f package.json ]; then
+#     npm install
+# fi
+`
+
+// InitHook creates the default setup hook file if it doesn't exist.
+func InitHook(dataDir string) error {
+	hookPath := filepath.Join(dataDir, hookFilename)
+
+	if _, err := os.Stat(hookPath); err == nil {
+		return nil // Already exists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(hookPath, []byte(defaultHookContent), 0644)
+}
@@ -0,0 +1,66 @@
+package setup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitHook_CreatesFile(t *testing.T) {
+	dataDir := t.TempDir()
+
+	err := InitHook(dataDir)
+	if err != nil {
+		t.Fatalf("InitHook failed: %v", err)
+	}
+
+	hookPath := filepath.Join(dataDir, "worktree-setup.sh")
+	content, err := os.ReadFile(hookPath)
+	if err != nil {
+		t.Fatalf("failed to read hook file: %v", err)
+	}
+
+	if !strings.HasPrefix(string(content), "#!/bin/bash") {
+		t.Error("hook file should start with shebang")
+	}
+}
+
+func TestInitHook_DoesNotOverwrite(t *testing.T) {
+	dataDir := t.TempDir()
+	hookPath := filepath.Join(dataDir, "worktree-setup.sh")
+
+	customContent := "#!/bin/bash\necho custom\n"
+	if err := os.WriteFile(hookPath, []byte(customContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := InitHook(dataDir)
+	if err != nil {
+		t.Fatalf("InitHook failed: %v", err)
+	}
+
+	content, err := os.ReadFile(hookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != customContent {
+		t.Error("InitHook should not overwrite existing file")
+	}
+}
+
+func TestInitHook_CreatesDataDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	dataDir := filepath.Join(tmpDir, "nested", "data")
+
+	err := InitHook(dataDir)
+	if err != nil {
+		t.Fatalf("InitHook failed: %v", err)
+	}
+
+	hookPath := filepath.Join(dataDir, "worktree-setup.sh")
+	if _, err := os.Stat(hookPath); os.IsNotExist(err) {
+		t.Error("hook file should be created")
+	}
+}
@@ -0,0 +1,410 @@
+// Package setup prepares newly created worktrees: copying untracked files
+// such as .env from the main worktree, running the worktree-setup.sh hook
+// and configured commands, in the background with per-step status.
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/internal/cmdoutput"
+	"github.com/pockode/server/internal/procgroup"
+)
+
+// ErrNotFound is returned for a worktree with no setup since server start.
+var ErrNotFound = errors.New("no setup for worktree")
+
+const (
+	// DefaultStepTimeout bounds one setup step; a dependency install
+	// on a cold cache can take minutes, but a hung step must not stay
+	// "running" forever.
+	DefaultStepTimeout = 15 * time.Minute
+)
+
+type State string
+
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateSkipped   State = "skipped" // a previous step failed
+)
+
+// Config is the user-configured part of worktree setup. The script
+// hook (worktree-setup.sh in the data directory) always runs when present.
+type Config struct {
+	// Paths or glob patterns relative to the main worktree, copied into a
+	// new worktree when they exist there and not in the worktree (".env").
+	CopyFiles []string
+	// Shell commands run in the new worktree, in order ("npm install").
+	Commands []string
+}
+
+// Step is one step of a worktree's setup.
+type Step struct {
+	Name   string `json:"name"`
+	State  State  `json:"state"`
+	Output string `json:"output,omitempty"` // tail of combined output
+	Error  string `json:"error,omitempty"`
+}
+
+// Status is the progress of a worktree's setup.
+type Status struct {
+	Worktree   string     `json:"worktree"`
+	State      State      `json:"state"`
+	Steps      []Step     `json:"steps"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Done reports whether setup has finished, successfully or not.
+func (s Status) Done() bool {
+	return s.State == StateSucceeded || s.State == StateFailed
+}
+
+func (s Status) clone() Status {
+	s.Steps = append([]Step(nil), s.Steps...)
+	return s
+}
+
+// OnChangeListener is notified on every setup step transition.
+type OnChangeListener interface {
+	OnWorktreeSetupChange(status Status)
+}
+
+type stepKind int
+
+const (
+	stepCopy stepKind = iota
+	stepHook
+	stepCommand
+)
+
+type action struct {
+	kind stepKind
+	name string // the step name; the shell command for stepCommand
+}
+
+type job struct {
+	status  Status
+	actions []action // parallel to status.Steps
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Runner prepares new worktrees in the background: it copies files
+// from the main worktree, runs the setup hook script, then the configured
+// commands. A failed step skips the rest and is reported in the status;
+// the worktree is kept so the user can fix it by hand.
+type Runner struct {
+	dataDir string
+	mainDir string
+	config  func() Config
+	timeout time.Duration
+
+	runsMu    sync.Mutex
+	runs      map[string]*job // by worktree name
+	listeners []OnChangeListener
+}
+
+func NewRunner(dataDir, mainDir string) *Runner {
+	return &Runner{
+		dataDir: dataDir,
+		mainDir: mainDir,
+		config:  func() Config { return Config{} },
+		timeout: DefaultStepTimeout,
+		runs:    make(map[string]*job),
+	}
+}
+
+// SetConfig sets where the copy and command configuration is read from.
+// It is read once per setup, so settings changes apply to the next worktree.
+func (r *Runner) SetConfig(fn func() Config) {
+	r.config = fn
+}
+
+func (r *Runner) AddOnChangeListener(listener OnChangeListener) {
+	r.runsMu.Lock()
+	defer r.runsMu.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// Start begins setting up a worktree and returns its initial status. A
+// setup already running for the same name is cancelled first.
+func (r *Runner) Start(name, path string) Status {
+	cfg := r.config()
+	steps, actions := r.plan(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &job{
+		status: Status{
+			Worktree:  name,
+			State:     StatePending,
+			Steps:     steps,
+			StartedAt: time.Now(),
+		},
+		actions: actions,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	r.runsMu.Lock()
+	if prev, ok := r.runs[name]; ok {
+		prev.cancel()
+	}
+	r.runs[name] = run
+	status := run.status.clone()
+	r.runsMu.Unlock()
+
+	r.notify(status)
+	go r.run(ctx, run, name, path, cfg.CopyFiles)
+	return status
+}
+
+// plan lists the steps a setup will run, so clients can show progress
+// from the first notification.
+func (r *Runner) plan(cfg Config) ([]Step, []action) {
+	var steps []Step
+	var actions []action
+	add := func(name string, action action) {
+		steps = append(steps, Step{Name: name, State: StatePending})
+		action.name = name
+		actions = append(actions, action)
+	}
+	if len(cfg.CopyFiles) > 0 {
+		add("copy files", action{kind: stepCopy})
+	}
+	if _, err := os.Stat(filepath.Join(r.dataDir, hookFilename)); err == nil {
+		add(hookFilename, action{kind: stepHook})
+	}
+	for _, c := range cfg.Commands {
+		add(c, action{kind: stepCommand})
+	}
+	return steps, actions
+}
+
+func (r *Runner) run(ctx context.Context, run *job, name, path string, copyFiles []string) {
+	defer close(run.done)
+
+	r.update(run, func(s *Status) { s.State = StateRunning })
+
+	env := append(os.Environ(),
+		"POCKODE_MAIN_DIR="+r.mainDir,
+		"POCKODE_WORKTREE_PATH="+path,
+		"POCKODE_WORKTREE_NAME="+name,
+	)
+
+	var failed error
+	for i, action := range run.actions {
+		if failed != nil {
+			r.update(run, func(s *Status) { s.Steps[i].State = StateSkipped })
+			continue
+		}
+		r.update(run, func(s *Status) { s.Steps[i].State = StateRunning })
+
+		var output string
+		var err error
+		switch action.kind {
+		case stepCopy:
+			output, err = copyMatching(r.mainDir, path, copyFiles)
+		case stepHook:
+			output, err = r.runStep(ctx, path, env, "bash", filepath.Join(r.dataDir, hookFilename))
+		case stepCommand:
+			output, err = r.runStep(ctx, path, env, "bash", "-c", action.name)
+		}
+
+		r.update(run, func(s *Status) {
+			s.Steps[i].Output = cmdoutput.Tail(output)
+			if err != nil {
+				s.Steps[i].State = StateFailed
+				s.Steps[i].Error = err.Error()
+				return
+			}
+			s.Steps[i].State = StateSucceeded
+		})
+		if err != nil {
+			failed = fmt.Errorf("%s: %w", action.name, err)
+			slog.Warn("worktree setup step failed", "worktree", name, "step", action.name, "error", err)
+		}
+	}
+
+	r.update(run, func(s *Status) {
+		now := time.Now()
+		s.FinishedAt = &now
+		if failed != nil {
+			s.State = StateFailed
+			s.Error = failed.Error()
+			return
+		}
+		s.State = StateSucceeded
+	})
+	if failed == nil {
+		slog.Info("worktree setup completed", "worktree", name)
+	}
+}
+
+func (r *Runner) runStep(ctx context.Context, dir string, env []string, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	procgroup.Set(cmd)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(output), fmt.Errorf("timed out after %s", r.timeout)
+	}
+	return string(output), err
+}
+
+// update applies fn to the run's status and notifies listeners, unless the
+// run has been replaced or forgotten.
+func (r *Runner) update(run *job, fn func(*Status)) {
+	r.runsMu.Lock()
+	fn(&run.status)
+	status := run.status.clone()
+	current := r.runs[status.Worktree] == run
+	r.runsMu.Unlock()
+
+	if current {
+		r.notify(status)
+	}
+}
+
+func (r *Runner) notify(status Status) {
+	r.runsMu.Lock()
+	listeners := append([]OnChangeListener(nil), r.listeners...)
+	r.runsMu.Unlock()
+	for _, l := range listeners {
+		l.OnWorktreeSetupChange(status)
+	}
+}
+
+// Get returns the setup status of a worktree created by this server.
+func (r *Runner) Get(name string) (Status, bool) {
+	r.runsMu.Lock()
+	defer r.runsMu.Unlock()
+	run, ok := r.runs[name]
+	if !ok {
+		return Status{}, false
+	}
+	return run.status.clone(), true
+}
+
+// List returns the setup status of every worktree created by this server.
+func (r *Runner) List() []Status {
+	r.runsMu.Lock()
+	defer r.runsMu.Unlock()
+	out := make([]Status, 0, len(r.runs))
+	for _, run := range r.runs {
+		out = append(out, run.status.clone())
+	}
+	return out
+}
+
+// Wait blocks until the worktree's setup finishes or ctx is done.
+func (r *Runner) Wait(ctx context.Context, name string) (Status, error) {
+	r.runsMu.Lock()
+	run, ok := r.runs[name]
+	r.runsMu.Unlock()
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	select {
+	case <-run.done:
+		status, _ := r.Get(name)
+		return status, nil
+	case <-ctx.Done():
+		return Status{}, ctx.Err()
+	}
+}
+
+// Forget cancels a worktree's setup and drops its status. Called before
+// the worktree is removed so no step keeps running in a deleted directory.
+func (r *Runner) Forget(name string) {
+	r.runsMu.Lock()
+	run, ok := r.runs[name]
+	delete(r.runs, name)
+	r.runsMu.Unlock()
+	if ok {
+		run.cancel()
+		<-run.done
+	}
+}
+
+// copyMatching copies files matching patterns from mainDir into dir,
+// skipping files the worktree already has (tracked files, or a re-run).
+// Returns one line per copied file.
+func copyMatching(mainDir, dir string, patterns []string) (string, error) {
+	var copied []string
+	for _, pattern := range patterns {
+		if filepath.IsAbs(pattern) || !filepath.IsLocal(filepath.Clean(pattern)) {
+			return strings.Join(copied, "\n"), fmt.Errorf("%q: path must be relative to the main worktree", pattern)
+		}
+		matches, err := filepath.Glob(filepath.Join(mainDir, pattern))
+		if err != nil {
+			return strings.Join(copied, "\n"), fmt.Errorf("%q: %w", pattern, err)
+		}
+		for _, src := range matches {
+			rel, err := filepath.Rel(mainDir, src)
+			if err != nil {
+				return strings.Join(copied, "\n"), err
+			}
+			ok, err := copyFile(src, filepath.Join(dir, rel))
+			if err != nil {
+				return strings.Join(copied, "\n"), fmt.Errorf("copy %s: %w", rel, err)
+			}
+			if ok {
+				copied = append(copied, rel)
+			}
+		}
+	}
+	return strings.Join(copied, "\n"), nil
+}
+
+// copyFile copies a regular file unless dst exists. Directories are
+// skipped: copying node_modules and the like is what install commands are for.
+func copyFile(src, dst string) (bool, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	if !fi.Mode().IsRegular() {
+		return false, nil
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return false, err
+	}
+	return true, out.Close()
+}
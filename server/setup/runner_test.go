@@ -0,0 +1,294 @@
+package setup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type listenerFunc func(Status)
+
+func (f listenerFunc) OnWorktreeSetupChange(s Status) { f(s) }
+
+func skipOnWindows(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts not supported on Windows")
+	}
+}
+
+func writeHook(t *testing.T, dataDir, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dataDir, hookFilename), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runSetup starts setup for a worktree at dir and waits for it to finish.
+func runSetup(t *testing.T, r *Runner, name, dir string) Status {
+	t.Helper()
+	r.Start(name, dir)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	status, err := r.Wait(ctx, name)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	return status
+}
+
+func TestRunner_NoSteps(t *testing.T) {
+	r := NewRunner(t.TempDir(), t.TempDir())
+
+	status := runSetup(t, r, "test-wt", t.TempDir())
+	if status.State != StateSucceeded {
+		t.Errorf("State = %q, want succeeded", status.State)
+	}
+	if len(status.Steps) != 0 {
+		t.Errorf("Steps = %+v, want none", status.Steps)
+	}
+	if status.FinishedAt == nil {
+		t.Error("FinishedAt should be set")
+	}
+}
+
+func TestRunner_HookEnvAndDir(t *testing.T) {
+	skipOnWindows(t)
+
+	dataDir := t.TempDir()
+	mainDir := t.TempDir()
+	worktreeDir := t.TempDir()
+	writeHook(t, dataDir, `#!/bin/bash
+echo "MAIN=$POCKODE_MAIN_DIR" > hook-ran.txt
+echo "PATH=$POCKODE_WORKTREE_PATH" >> hook-ran.txt
+echo "NAME=$POCKODE_WORKTREE_NAME" >> hook-ran.txt
+`)
+
+	status := runSetup(t, NewRunner(dataDir, mainDir), "my-feature", worktreeDir)
+	if status.State != StateSucceeded {
+		t.Fatalf("State = %q (%s), want succeeded", status.State, status.Error)
+	}
+	if len(status.Steps) != 1 || status.Steps[0].Name != hookFilename {
+		t.Fatalf("Steps = %+v, want the hook", status.Steps)
+	}
+
+	content, err := os.ReadFile(filepath.Join(worktreeDir, "hook-ran.txt"))
+	if err != nil {
+		t.Fatalf("hook did not run in worktree directory: %v", err)
+	}
+	expected := "MAIN=" + mainDir + "\nPATH=" + worktreeDir + "\nNAME=my-feature\n"
+	if string(content) != expected {
+		t.Errorf("marker file content mismatch\ngot:\n%s\nwant:\n%s", content, expected)
+	}
+}
+
+func TestRunner_FailureSkipsRemainingSteps(t *testing.T) {
+	skipOnWindows(t)
+
+	dataDir := t.TempDir()
+	worktreeDir := t.TempDir()
+	writeHook(t, dataDir, `#!/bin/bash
+echo "npm ERR! missing dependency"
+exit 1
+`)
+	r := NewRunner(dataDir, t.TempDir())
+	r.SetConfig(func() Config {
+		return Config{Commands: []string{"touch should-not-exist"}}
+	})
+
+	status := runSetup(t, r, "test-wt", worktreeDir)
+	if status.State != StateFailed {
+		t.Fatalf("State = %q, want failed", status.State)
+	}
+	if !strings.Contains(status.Error, hookFilename) {
+		t.Errorf("Error = %q, should name the failed step", status.Error)
+	}
+	hook := status.Steps[0]
+	if hook.State != StateFailed || !strings.Contains(hook.Output, "npm ERR! missing dependency") {
+		t.Errorf("hook step = %+v, want failed with script output", hook)
+	}
+	if status.Steps[1].State != StateSkipped {
+		t.Errorf("command step state = %q, want skipped", status.Steps[1].State)
+	}
+	if _, err := os.Stat(filepath.Join(worktreeDir, "should-not-exist")); !os.IsNotExist(err) {
+		t.Error("command after a failed step should not run")
+	}
+}
+
+func TestRunner_CopiesFilesAndRunsCommands(t *testing.T) {
+	skipOnWindows(t)
+
+	mainDir := t.TempDir()
+	worktreeDir := t.TempDir()
+	for name, content := range map[string]string{
+		".env":           "SECRET=main",
+		".env.local":     "LOCAL=1",
+		"config/app.env": "APP=1",
+		"tracked.env":    "from main",
+	} {
+		path := filepath.Join(mainDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Present in the worktree already, e.g. tracked by git: must be kept.
+	if err := os.WriteFile(filepath.Join(worktreeDir, "tracked.env"), []byte("from branch"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner(t.TempDir(), mainDir)
+	r.SetConfig(func() Config {
+		return Config{
+			CopyFiles: []string{".env*", "config/app.env", "tracked.env", "missing.env"},
+			Commands:  []string{"cat .env > copied.txt"},
+		}
+	})
+
+	status := runSetup(t, r, "test-wt", worktreeDir)
+	if status.State != StateSucceeded {
+		t.Fatalf("State = %q (%s), want succeeded", status.State, status.Error)
+	}
+	if got, want := status.Steps[0].Output, ".env\n.env.local\nconfig/app.env"; got != want {
+		t.Errorf("copy output = %q, want %q", got, want)
+	}
+
+	for name, want := range map[string]string{
+		".env":           "SECRET=main",
+		".env.local":     "LOCAL=1",
+		"config/app.env": "APP=1",
+		"tracked.env":    "from branch",
+		"copied.txt":     "SECRET=main",
+	} {
+		got, err := os.ReadFile(filepath.Join(worktreeDir, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	fi, err := os.Stat(filepath.Join(worktreeDir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf(".env mode = %v, want 0600", fi.Mode().Perm())
+	}
+}
+
+func TestRunner_CopyRejectsPathsOutsideMain(t *testing.T) {
+	r := NewRunner(t.TempDir(), t.TempDir())
+	r.SetConfig(func() Config {
+		return Config{CopyFiles: []string{"../outside"}}
+	})
+
+	status := runSetup(t, r, "test-wt", t.TempDir())
+	if status.State != StateFailed {
+		t.Errorf("State = %q, want failed", status.State)
+	}
+}
+
+func TestRunner_NotifiesProgress(t *testing.T) {
+	skipOnWindows(t)
+
+	r := NewRunner(t.TempDir(), t.TempDir())
+	r.SetConfig(func() Config {
+		return Config{Commands: []string{"true", "true"}}
+	})
+
+	var statesMu sync.Mutex
+	var states []string
+	r.AddOnChangeListener(listenerFunc(func(s Status) {
+		statesMu.Lock()
+		defer statesMu.Unlock()
+		line := string(s.State)
+		for _, step := range s.Steps {
+			line += " " + string(step.State)
+		}
+		states = append(states, line)
+	}))
+
+	runSetup(t, r, "test-wt", t.TempDir())
+
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	want := []string{
+		"pending pending pending",
+		"running pending pending",
+		"running running pending",
+		"running succeeded pending",
+		"running succeeded running",
+		"running succeeded succeeded",
+		"succeeded succeeded succeeded",
+	}
+	if strings.Join(states, "\n") != strings.Join(want, "\n") {
+		t.Errorf("notifications:\n%s\nwant:\n%s", strings.Join(states, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunner_ForgetCancelsRunningStep(t *testing.T) {
+	skipOnWindows(t)
+
+	r := NewRunner(t.TempDir(), t.TempDir())
+	// A compound command keeps bash around with sleep as its child, so
+	// killing only bash would leave the step running.
+	r.SetConfig(func() Config {
+		return Config{Commands: []string{"sleep 30; echo done"}}
+	})
+	r.Start("test-wt", t.TempDir())
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if status, _ := r.Get("test-wt"); len(status.Steps) > 0 && status.Steps[0].State == StateRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("step did not start")
+		}
+	}
+	time.Sleep(100 * time.Millisecond) // let bash start sleep
+
+	done := make(chan struct{})
+	go func() {
+		r.Forget("test-wt")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Forget() did not cancel the running step")
+	}
+
+	if _, ok := r.Get("test-wt"); ok {
+		t.Error("status should be dropped after Forget")
+	}
+	if len(r.List()) != 0 {
+		t.Errorf("List() = %+v, want empty", r.List())
+	}
+}
+
+func TestRunner_StepTimeout(t *testing.T) {
+	skipOnWindows(t)
+
+	r := NewRunner(t.TempDir(), t.TempDir())
+	r.timeout = 100 * time.Millisecond
+	r.SetConfig(func() Config {
+		return Config{Commands: []string{"sleep 30; echo done"}}
+	})
+
+	start := time.Now()
+	status := runSetup(t, r, "test-wt", t.TempDir())
+	if status.State != StateFailed || !strings.Contains(status.Steps[0].Error, "timed out") {
+		t.Errorf("status = %+v, want a timed out failure", status)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("setup finished after %s, want soon after the step timeout", elapsed)
+	}
+}
@@ -0,0 +1,444 @@
+// Package snapshot archives the data directory (works, roles, sessions,
+// settings, worktree session data) so risky operations can be undone.
+//
+// A snapshot is a gzipped tar under <dataDir>/snapshots. Each archive is
+// written to a temp file and renamed into place, so a listed snapshot is
+// always complete. Files are read one at a time while the server runs; every
+// store writes its index by atomic rename, so each file is consistent on its
+// own.
+//
+// Restoring replaces the data directory, which running stores would
+// immediately overwrite from memory. It is therefore applied only at
+// startup, before any store opens: either requested on the command line or
+// scheduled over RPC with MarkPendingRestore.
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("snapshot not found")
+	ErrInvalidName = errors.New("invalid snapshot name")
+)
+
+const (
+	dirName         = "snapshots"
+	pendingFilename = "restore.pending"
+	archiveExt      = ".tar.gz"
+	timeLayout      = "20060102-150405"
+)
+
+// DefaultKeep is how many snapshots are retained; the oldest are pruned
+// after each new one.
+const DefaultKeep = 20
+
+// Label values used for the automatic snapshots taken before risky
+// operations.
+const (
+	LabelManual      = "manual"
+	LabelImport      = "before-import"
+	LabelBulkDelete  = "before-bulk-delete"
+	LabelResetRoles  = "before-reset-roles"
+	LabelRestore     = "before-restore"
+	LabelFsckRepair  = "before-fsck-repair"
+	LabelCommandLine = "cli"
+)
+
+// excluded lists data-dir entries that are runtime state rather than data:
+// the snapshots themselves, the running server's discovery file and MCP
+// socket, logs, event recordings for debugging, and the per-session MCP
+// configs rewritten at every agent start. Paired devices are excluded too,
+// so a restore cannot bring back a revoked device's token.
+var excluded = []string{dirName, "server.json", "mcp.sock", "server.log", "replays", "mcp-configs", "devices.json"}
+
+var labelPattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
+type Info struct {
+	Name      string    `json:"name"`
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+type Manager struct {
+	dataDir string
+	keep    int
+	now     func() time.Time
+}
+
+func NewManager(dataDir string) *Manager {
+	return &Manager{dataDir: dataDir, keep: DefaultKeep, now: time.Now}
+}
+
+func (m *Manager) dir() string {
+	return filepath.Join(m.dataDir, dirName)
+}
+
+// Create archives the data directory and returns the new snapshot. label
+// is normalized to lowercase letters, digits, and dashes.
+func (m *Manager) Create(label string) (Info, error) {
+	return m.create(label, "")
+}
+
+// create is Create with a snapshot that pruning must not remove.
+func (m *Manager) create(label, keepName string) (Info, error) {
+	label = strings.Trim(labelPattern.ReplaceAllString(strings.ToLower(label), "-"), "-")
+	if label == "" {
+		label = LabelManual
+	}
+	if err := os.MkdirAll(m.dir(), 0755); err != nil {
+		return Info{}, fmt.Errorf("create snapshot dir: %w", err)
+	}
+
+	now := m.now()
+	name := fmt.Sprintf("%s-%s%s", now.UTC().Format(timeLayout), label, archiveExt)
+	path := filepath.Join(m.dir(), name)
+	if _, err := os.Stat(path); err == nil {
+		// Two snapshots within a second with the same label; keep both.
+		name = fmt.Sprintf("%s-%s-%d%s", now.UTC().Format(timeLayout), label, now.Nanosecond(), archiveExt)
+		path = filepath.Join(m.dir(), name)
+	}
+
+	tmp, err := os.CreateTemp(m.dir(), ".snapshot-*")
+	if err != nil {
+		return Info{}, fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	if err := m.writeArchive(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return Info{}, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return Info{}, fmt.Errorf("sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return Info{}, fmt.Errorf("close snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return Info{}, fmt.Errorf("rename snapshot: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Info{}, fmt.Errorf("stat snapshot: %w", err)
+	}
+	slog.Info("snapshot created", "name", name, "size", info.Size())
+
+	if err := m.prune(keepName); err != nil {
+		slog.Warn("failed to prune snapshots", "error", err)
+	}
+	return Info{Name: name, Label: label, CreatedAt: now.UTC().Truncate(time.Second), Size: info.Size()}, nil
+}
+
+func (m *Manager) writeArchive(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(m.dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // removed while walking
+			}
+			return err
+		}
+		rel, err := filepath.Rel(m.dataDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if isExcluded(rel, d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil // sockets, symlinks
+		}
+		return addFile(tw, path, filepath.ToSlash(rel), d)
+	})
+	if err != nil {
+		return fmt.Errorf("archive data dir: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("close gzip: %w", err)
+	}
+	return nil
+}
+
+func isExcluded(rel string, d fs.DirEntry) bool {
+	if !strings.Contains(rel, string(filepath.Separator)) && slices.Contains(excluded, rel) {
+		return true
+	}
+	name := d.Name()
+	// Lock files are recreated on demand, and temp files are half-written.
+	return strings.HasSuffix(name, ".lock") || strings.HasSuffix(name, ".tmp") || strings.HasPrefix(name, ".snapshot-")
+}
+
+func addFile(tw *tar.Writer, path, name string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if d.IsDir() {
+		hdr.Name += "/"
+		return tw.WriteHeader(hdr)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	// The file may have been replaced since Info; the header must match
+	// what is actually copied.
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr.Size = stat.Size()
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// List returns the snapshots, newest first.
+func (m *Manager) List() ([]Info, error) {
+	entries, err := os.ReadDir(m.dir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Info{}, nil
+		}
+		return nil, err
+	}
+
+	infos := []Info{}
+	for _, e := range entries {
+		info, ok := parseName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		info.Size = fi.Size()
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b Info) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(b.Name, a.Name)
+	})
+	return infos, nil
+}
+
+// parseName splits "<time>-<label>[-<n>].tar.gz".
+func parseName(name string) (Info, bool) {
+	base, ok := strings.CutSuffix(name, archiveExt)
+	if !ok || len(base) < len(timeLayout)+2 || base[len(timeLayout)] != '-' {
+		return Info{}, false
+	}
+	t, err := time.Parse(timeLayout, base[:len(timeLayout)])
+	if err != nil {
+		return Info{}, false
+	}
+	return Info{Name: name, Label: base[len(timeLayout)+1:], CreatedAt: t}, true
+}
+
+func (m *Manager) prune(keepName string) error {
+	infos, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos[min(m.keep, len(infos)):] {
+		if info.Name == keepName {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir(), info.Name)); err != nil {
+			return err
+		}
+		slog.Info("snapshot pruned", "name", info.Name)
+	}
+	return nil
+}
+
+// path validates a snapshot name and returns its archive path.
+func (m *Manager) path(name string) (string, error) {
+	if _, ok := parseName(name); !ok || filepath.Base(name) != name {
+		return "", fmt.Errorf("%w: %s", ErrInvalidName, name)
+	}
+	path := filepath.Join(m.dir(), name)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// MarkPendingRestore schedules name to be restored at the next startup.
+func (m *Manager) MarkPendingRestore(name string) error {
+	if _, err := m.path(name); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.dir(), pendingFilename), []byte(name), 0644)
+}
+
+// PendingRestore returns the snapshot scheduled by MarkPendingRestore, if any.
+func (m *Manager) PendingRestore() (string, bool, error) {
+	data, err := os.ReadFile(filepath.Join(m.dir(), pendingFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return strings.TrimSpace(string(data)), true, nil
+}
+
+// Restore replaces the data directory with the snapshot's contents. The
+// current state is snapshotted first, so a restore can itself be undone.
+// Any pending restore is cleared. Must run before any store opens.
+func (m *Manager) Restore(name string) error {
+	path, err := m.path(name)
+	if err != nil {
+		return err
+	}
+	// Validate the whole archive before touching the data directory.
+	if err := m.extract(path, true); err != nil {
+		return err
+	}
+	if _, err := m.create(LabelRestore, name); err != nil {
+		return fmt.Errorf("snapshot current state: %w", err)
+	}
+
+	entries, err := os.ReadDir(m.dataDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if isExcluded(e.Name(), e) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(m.dataDir, e.Name())); err != nil {
+			return fmt.Errorf("clear data dir: %w", err)
+		}
+	}
+	if err := m.extract(path, false); err != nil {
+		return err
+	}
+
+	if err := os.Remove(filepath.Join(m.dir(), pendingFilename)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("clear pending restore: %w", err)
+	}
+	slog.Info("snapshot restored", "name", name)
+	return nil
+}
+
+// extract unpacks the archive into the data directory, or with dryRun only
+// checks that every entry is well-formed and stays inside it.
+func (m *Manager) extract(path string, dryRun bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read snapshot: %w", err)
+		}
+		name := filepath.FromSlash(strings.TrimSuffix(hdr.Name, "/"))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("%w: entry %q escapes the data dir", ErrInvalidName, hdr.Name)
+		}
+		// Archives from before an entry was excluded may still carry it.
+		if top, _, _ := strings.Cut(name, string(filepath.Separator)); slices.Contains(excluded, top) {
+			continue
+		}
+		if dryRun {
+			if _, err := io.Copy(io.Discard, tr); err != nil {
+				return fmt.Errorf("read snapshot: %w", err)
+			}
+			continue
+		}
+
+		target := filepath.Join(m.dataDir, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeFile(path string, r io.Reader, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,230 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/pairing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+// newTestManager returns a manager whose clock advances one second per
+// snapshot, so names and ordering are deterministic.
+func newTestManager(t *testing.T) (*Manager, string) {
+	dataDir := t.TempDir()
+	m := NewManager(dataDir)
+	clock := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	return m, dataDir
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	m, dataDir := newTestManager(t)
+	writeTestFile(t, filepath.Join(dataDir, "works", "index.json"), `{"works":["a"]}`)
+	writeTestFile(t, filepath.Join(dataDir, "works", "index.json.lock"), "")
+	writeTestFile(t, filepath.Join(dataDir, "settings.json"), `{"v":1}`)
+	writeTestFile(t, filepath.Join(dataDir, "worktrees", "feat", "sessions", "index.json"), `{"sessions":[]}`)
+	writeTestFile(t, filepath.Join(dataDir, "server.json"), `{"pid":1}`)
+
+	info, err := m.Create("Before Import!")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if info.Label != "before-import" || info.Size == 0 {
+		t.Errorf("info = %+v, want normalized label and a size", info)
+	}
+
+	// Change, add, and remove data after the snapshot.
+	writeTestFile(t, filepath.Join(dataDir, "works", "index.json"), `{"works":[]}`)
+	writeTestFile(t, filepath.Join(dataDir, "agent-roles", "index.json"), `{}`)
+	if err := os.Remove(filepath.Join(dataDir, "settings.json")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dataDir, "server.json"), `{"pid":2}`)
+
+	if err := m.Restore(info.Name); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if got := readFile(t, filepath.Join(dataDir, "works", "index.json")); got != `{"works":["a"]}` {
+		t.Errorf("works = %s, want restored", got)
+	}
+	if got := readFile(t, filepath.Join(dataDir, "settings.json")); got != `{"v":1}` {
+		t.Errorf("settings = %s, want restored", got)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "agent-roles")); !os.IsNotExist(err) {
+		t.Error("expected data created after the snapshot to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "worktrees", "feat", "sessions", "index.json")); err != nil {
+		t.Errorf("expected worktree sessions to be restored: %v", err)
+	}
+	// Runtime files are neither archived nor replaced.
+	if got := readFile(t, filepath.Join(dataDir, "server.json")); got != `{"pid":2}` {
+		t.Errorf("server.json = %s, want untouched", got)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "works", "index.json.lock")); !os.IsNotExist(err) {
+		t.Error("expected lock files to be left out of the snapshot")
+	}
+
+	// The pre-restore state was snapshotted, so the restore can be undone.
+	infos, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Label != LabelRestore || infos[1].Name != info.Name {
+		t.Errorf("list = %+v, want before-restore then the original", infos)
+	}
+}
+
+func TestCreate_Prunes(t *testing.T) {
+	m, dataDir := newTestManager(t)
+	m.keep = 2
+	writeTestFile(t, filepath.Join(dataDir, "settings.json"), "{}")
+
+	var names []string
+	for range 3 {
+		info, err := m.Create("")
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		names = append(names, info.Name)
+	}
+
+	infos, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Name != names[2] || infos[1].Name != names[1] {
+		t.Errorf("list = %+v, want the two newest", infos)
+	}
+	if infos[0].Label != LabelManual {
+		t.Errorf("label = %q, want %q for an empty label", infos[0].Label, LabelManual)
+	}
+}
+
+func TestRestore_KeepsTargetWhenPruning(t *testing.T) {
+	m, dataDir := newTestManager(t)
+	m.keep = 1
+	writeTestFile(t, filepath.Join(dataDir, "settings.json"), `{"v":1}`)
+	info, err := m.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dataDir, "settings.json"), `{"v":2}`)
+
+	// The before-restore snapshot would push the target out of the
+	// retention window.
+	if err := m.Restore(info.Name); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dataDir, "settings.json")); got != `{"v":1}` {
+		t.Errorf("settings = %s, want restored", got)
+	}
+}
+
+func TestPendingRestore(t *testing.T) {
+	m, dataDir := newTestManager(t)
+	writeTestFile(t, filepath.Join(dataDir, "settings.json"), "{}")
+	info, err := m.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := m.PendingRestore(); ok || err != nil {
+		t.Fatalf("PendingRestore = %v, %v; want none", ok, err)
+	}
+	if err := m.MarkPendingRestore(info.Name); err != nil {
+		t.Fatalf("MarkPendingRestore: %v", err)
+	}
+	name, ok, err := m.PendingRestore()
+	if err != nil || !ok || name != info.Name {
+		t.Fatalf("PendingRestore = %q, %v, %v; want %q", name, ok, err, info.Name)
+	}
+
+	if err := m.Restore(name); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, ok, _ := m.PendingRestore(); ok {
+		t.Error("expected Restore to clear the pending restore")
+	}
+}
+
+func TestRestore_InvalidName(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"../settings.json", ErrInvalidName},
+		{"not-a-snapshot.tar.gz", ErrInvalidName},
+		{"20260102-030405-manual.tar.gz", ErrNotFound},
+	}
+	for _, tt := range tests {
+		if err := m.Restore(tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("Restore(%q) err = %v, want %v", tt.name, err, tt.want)
+		}
+		if err := m.MarkPendingRestore(tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("MarkPendingRestore(%q) err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRestore_KeepsRevokedDevicesRevoked(t *testing.T) {
+	m, dataDir := newTestManager(t)
+	devices, err := pairing.NewManager(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := devices.NewCode(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, token, err := devices.Pair(code.Code, "Phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := m.Create("before-revoke")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := devices.Revoke(device.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Restore(info.Name); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	reopened, err := pairing.NewManager(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Authenticate(token); ok {
+		t.Error("restore brought back a revoked device token")
+	}
+}
@@ -77,7 +77,7 @@ func PrintBanner(opts BannerOptions) {
 }
 
 // PrintQRCode prints an indented QR code with a label on the side.
-func PrintQRCode(url string) {
+func PrintQRCode(url, label string) {
 	var buf bytes.Buffer
 	qrterminal.GenerateWithConfig(url, qrterminal.Config{
 		Level:          qrterminal.L,
@@ -101,7 +101,7 @@ func PrintQRCode(url string) {
 	midLine := len(lines) / 2
 	for i, line := range lines {
 		if i == midLine {
-			fmt.Printf("%s%s  %s\n", indent, line, color(dim, "Scan to connect"))
+			fmt.Printf("%s%s  %s\n", indent, line, color(dim, label))
 		} else {
 			fmt.Printf("%s%s\n", indent, line)
 		}
@@ -0,0 +1,147 @@
+package testrun
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pockode/server/filestore"
+)
+
+const (
+	// MaxRuns bounds the stored history; the oldest runs are dropped first.
+	// Only recent runs matter for the board, and the index is rewritten on
+	// every report.
+	MaxRuns = 1000
+	// MaxFailures and MaxFailureRunes bound one run, since agents may paste
+	// an entire test log into a report.
+	MaxFailures     = 50
+	MaxFailureRunes = 2000
+)
+
+// Store records test runs and notifies listeners of new ones.
+type Store interface {
+	// Report validates and stores run, assigning ID, Status, and CreatedAt.
+	Report(ctx context.Context, run Run) (Run, error)
+	List(q ListQuery) ([]Run, error)
+
+	AddOnReportListener(listener OnReportListener)
+}
+
+type indexData struct {
+	Runs []Run `json:"runs"`
+}
+
+// FileStore persists runs to a JSON file, oldest first.
+type FileStore struct {
+	file      *filestore.File
+	runsMu    sync.RWMutex
+	runs      []Run
+	listeners []OnReportListener
+}
+
+func NewFileStore(dataDir string) (*FileStore, error) {
+	f, err := filestore.New(filestore.Config{
+		Path:  filepath.Join(dataDir, "testruns", "index.json"),
+		Label: "testrun",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := filestore.Load(f, indexData{})
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{file: f, runs: idx.Runs}, nil
+}
+
+func (s *FileStore) Report(_ context.Context, run Run) (Run, error) {
+	if run.WorkID == "" {
+		return Run{}, fmt.Errorf("%w: work_id is required", ErrInvalidRun)
+	}
+	if run.Suite == "" {
+		return Run{}, fmt.Errorf("%w: suite is required", ErrInvalidRun)
+	}
+	if run.Passed < 0 || run.Failed < 0 || run.Skipped < 0 {
+		return Run{}, fmt.Errorf("%w: counts must not be negative", ErrInvalidRun)
+	}
+
+	run.ID = uuid.Must(uuid.NewV7()).String()
+	run.CreatedAt = time.Now()
+	run.Status = StatusPassed
+	// A listed failure counts even if the agent forgot to bump Failed.
+	if run.Failed > 0 || len(run.Failures) > 0 {
+		run.Status = StatusFailed
+	}
+	run.Failures = truncateFailures(run.Failures)
+
+	s.runsMu.Lock()
+
+	prev := s.runs
+	runs := append(append([]Run(nil), s.runs...), run)
+	if len(runs) > MaxRuns {
+		runs = runs[len(runs)-MaxRuns:]
+	}
+	s.runs = runs
+
+	if err := s.file.Persist(indexData{Runs: s.runs}); err != nil {
+		s.runs = prev
+		s.runsMu.Unlock()
+		return Run{}, err
+	}
+
+	listeners := make([]OnReportListener, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.runsMu.Unlock()
+
+	for _, l := range listeners {
+		l.OnTestRunReport(run)
+	}
+	return run, nil
+}
+
+func (s *FileStore) List(q ListQuery) ([]Run, error) {
+	s.runsMu.RLock()
+	defer s.runsMu.RUnlock()
+
+	result := []Run{}
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		r := s.runs[i]
+		if q.WorkID != "" && r.WorkID != q.WorkID {
+			continue
+		}
+		if q.SessionID != "" && r.SessionID != q.SessionID {
+			continue
+		}
+		result = append(result, r)
+		if q.Limit > 0 && len(result) == q.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (s *FileStore) AddOnReportListener(listener OnReportListener) {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func truncateFailures(failures []Failure) []Failure {
+	if len(failures) > MaxFailures {
+		failures = failures[:MaxFailures]
+	}
+	out := make([]Failure, len(failures))
+	for i, f := range failures {
+		if utf8.RuneCountInString(f.Message) > MaxFailureRunes {
+			f.Message = string([]rune(f.Message)[:MaxFailureRunes-1]) + "…"
+		}
+		out[i] = f
+	}
+	return out
+}
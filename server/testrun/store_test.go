@@ -0,0 +1,148 @@
+package testrun
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func newTestStore(t *testing.T) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	return store
+}
+
+func report(t *testing.T, s *FileStore, run Run) Run {
+	t.Helper()
+	r, err := s.Report(context.Background(), run)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	return r
+}
+
+type listenerFunc func(Run)
+
+func (f listenerFunc) OnTestRunReport(run Run) { f(run) }
+
+func TestReport_Status(t *testing.T) {
+	s := newTestStore(t)
+
+	tests := []struct {
+		name string
+		run  Run
+		want Status
+	}{
+		{"all passed", Run{WorkID: "w", Suite: "unit", Passed: 3}, StatusPassed},
+		{"failed count", Run{WorkID: "w", Suite: "unit", Passed: 2, Failed: 1}, StatusFailed},
+		{"failures listed without count", Run{WorkID: "w", Suite: "unit", Failures: []Failure{{Name: "TestX"}}}, StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := report(t, s, tt.run)
+			if got.Status != tt.want {
+				t.Errorf("status = %s, want %s", got.Status, tt.want)
+			}
+			if got.ID == "" || got.CreatedAt.IsZero() {
+				t.Errorf("expected ID and CreatedAt to be set, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestReport_Rejects(t *testing.T) {
+	s := newTestStore(t)
+
+	for _, run := range []Run{
+		{Suite: "unit"},
+		{WorkID: "w"},
+		{WorkID: "w", Suite: "unit", Failed: -1},
+	} {
+		if _, err := s.Report(context.Background(), run); !errors.Is(err, ErrInvalidRun) {
+			t.Errorf("Report(%+v) err = %v, want ErrInvalidRun", run, err)
+		}
+	}
+}
+
+func TestReport_TruncatesFailures(t *testing.T) {
+	s := newTestStore(t)
+
+	failures := make([]Failure, MaxFailures+5)
+	failures[0].Message = strings.Repeat("é", MaxFailureRunes+10)
+	got := report(t, s, Run{WorkID: "w", Suite: "unit", Failures: failures})
+
+	if len(got.Failures) != MaxFailures {
+		t.Errorf("failures = %d, want %d", len(got.Failures), MaxFailures)
+	}
+	msg := got.Failures[0].Message
+	if n := utf8.RuneCountInString(msg); n != MaxFailureRunes || !utf8.ValidString(msg) {
+		t.Errorf("message has %d runes (valid=%v), want %d", n, utf8.ValidString(msg), MaxFailureRunes)
+	}
+}
+
+func TestList_FiltersNewestFirst(t *testing.T) {
+	s := newTestStore(t)
+	a := report(t, s, Run{WorkID: "w1", SessionID: "s1", Suite: "unit"})
+	b := report(t, s, Run{WorkID: "w2", SessionID: "s2", Suite: "unit"})
+	c := report(t, s, Run{WorkID: "w1", SessionID: "s3", Suite: "e2e"})
+
+	tests := []struct {
+		name  string
+		query ListQuery
+		want  []string
+	}{
+		{"all", ListQuery{}, []string{c.ID, b.ID, a.ID}},
+		{"by work", ListQuery{WorkID: "w1"}, []string{c.ID, a.ID}},
+		{"by session", ListQuery{SessionID: "s2"}, []string{b.ID}},
+		{"limit", ListQuery{WorkID: "w1", Limit: 1}, []string{c.ID}},
+		{"no match", ListQuery{WorkID: "missing"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs, err := s.List(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(runs) != len(tt.want) {
+				t.Fatalf("got %d runs, want %d", len(runs), len(tt.want))
+			}
+			for i, r := range runs {
+				if r.ID != tt.want[i] {
+					t.Errorf("runs[%d] = %s, want %s", i, r.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReport_PersistsAndNotifies(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notified []Run
+	s.AddOnReportListener(listenerFunc(func(r Run) { notified = append(notified, r) }))
+
+	run := report(t, s, Run{WorkID: "w", Suite: "unit", Passed: 1})
+	if len(notified) != 1 || notified[0].ID != run.ID {
+		t.Errorf("notified = %+v, want the reported run", notified)
+	}
+
+	reloaded, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := reloaded.List(ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != run.ID {
+		t.Errorf("reloaded runs = %+v, want the reported run", runs)
+	}
+}
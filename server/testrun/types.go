@@ -0,0 +1,55 @@
+// Package testrun stores structured test results reported by agents, so the
+// UI can show pass/fail per work item and session without parsing chat logs.
+package testrun
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidRun = errors.New("invalid test run")
+
+type Status string
+
+const (
+	StatusPassed Status = "passed"
+	StatusFailed Status = "failed"
+)
+
+// Failure is one failed test. Message is truncated to MaxFailureRunes.
+type Failure struct {
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+}
+
+// Run is one test suite execution reported by an agent. SessionID is the
+// session of the work item when the run was reported.
+type Run struct {
+	ID        string    `json:"id"`
+	WorkID    string    `json:"work_id"`
+	SessionID string    `json:"session_id,omitempty"`
+	Suite     string    `json:"suite"`
+	Status    Status    `json:"status"`
+	Passed    int       `json:"passed"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped,omitempty"`
+	Failures  []Failure `json:"failures,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListQuery filters List. Empty fields match everything; Limit 0 means no
+// limit. Results are newest first.
+type ListQuery struct {
+	WorkID    string `json:"work_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+}
+
+// OnReportListener receives each newly reported run.
+//
+// Contract: OnTestRunReport is called outside the store's mutex, but
+// listeners that call back into the store MUST do so in a separate goroutine
+// to avoid re-entrant deadlock.
+type OnReportListener interface {
+	OnTestRunReport(run Run)
+}
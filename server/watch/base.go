@@ -2,7 +2,6 @@ package watch
 
 import (
 	"context"
-	"log/slog"
 	"sync"
 )
 
@@ -10,6 +9,17 @@ type Subscription struct {
 	ID       string
 	WorkID   string // used by WorkDetailWatcher to filter by work item
 	Notifier Notifier
+
+	queue *notifyQueue // set by AddSubscription
+}
+
+// Notify queues n for the subscriber (see notifyQueue). It never blocks on
+// the subscriber.
+func (s *Subscription) Notify(ctx context.Context, n Notification) {
+	if s.queue == nil {
+		s.queue = newNotifyQueue(s.ID, s.Notifier)
+	}
+	s.queue.push(ctx, n)
 }
 
 // BaseWatcher provides common subscription management for all watcher types.
@@ -41,6 +51,7 @@ func (b *BaseWatcher) AddSubscription(sub *Subscription) {
 	b.subMu.Lock()
 	defer b.subMu.Unlock()
 
+	sub.queue = newNotifyQueue(sub.ID, sub.Notifier)
 	b.subscriptions[sub.ID] = sub
 }
 
@@ -54,6 +65,7 @@ func (b *BaseWatcher) RemoveSubscription(id string) *Subscription {
 	}
 
 	delete(b.subscriptions, id)
+	sub.queue.close()
 	return sub
 }
 
@@ -77,13 +89,7 @@ func (b *BaseWatcher) GetSubscription(id string) *Subscription {
 func (b *BaseWatcher) NotifyAll(method string, makeParams func(sub *Subscription) any) int {
 	subs := b.GetAllSubscriptions()
 	for _, sub := range subs {
-		params := makeParams(sub)
-		n := Notification{Method: method, Params: params}
-		if err := sub.Notifier.Notify(b.ctx, n); err != nil {
-			slog.Debug("failed to notify subscriber",
-				"id", sub.ID,
-				"error", err)
-		}
+		sub.Notify(b.ctx, Notification{Method: method, Params: makeParams(sub)})
 	}
 	return len(subs)
 }
@@ -2,9 +2,10 @@ package watch
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/process"
@@ -19,8 +20,9 @@ type ChatMessagesWatcher struct {
 	msgCh chan process.ChatMessage
 
 	sessionMu    sync.RWMutex
-	sessionToIDs map[string][]string // sessionID -> subscription IDs
-	idToSession  map[string]string   // subscription ID -> sessionID
+	sessionToIDs map[string][]string          // sessionID -> subscription IDs
+	idToSession  map[string]string            // subscription ID -> sessionID
+	idToTypes    map[string][]agent.EventType // subscription ID -> event types it receives; absent = all
 }
 
 var _ process.ChatMessageListener = (*ChatMessagesWatcher)(nil)
@@ -34,6 +36,7 @@ func NewChatMessagesWatcher(store session.Store) *ChatMessagesWatcher {
 		msgCh:        make(chan process.ChatMessage, 256),
 		sessionToIDs: make(map[string][]string),
 		idToSession:  make(map[string]string),
+		idToTypes:    make(map[string][]agent.EventType),
 	}
 }
 
@@ -81,36 +84,50 @@ func (w *ChatMessagesWatcher) notifyMessage(msg process.ChatMessage) {
 
 // notifyEvent broadcasts an event to session subscribers, optionally excluding one notifier.
 func (w *ChatMessagesWatcher) notifyEvent(sessionID string, record agent.EventRecord, exclude Notifier) {
+	w.notifySession(sessionID, "chat."+string(record.Type), exclude, func(sub *Subscription) any {
+		if !w.wantsEvent(sub.ID, record.Type) {
+			return nil
+		}
+		return notifyParams{
+			ID:          sub.ID,
+			EventRecord: record,
+		}
+	})
+}
+
+// notifySession sends a notification to every subscriber of a session,
+// optionally excluding one notifier. Subscribers paramsFn returns nil for
+// are skipped.
+func (w *ChatMessagesWatcher) notifySession(sessionID, method string, exclude Notifier, paramsFn func(*Subscription) any) {
 	w.sessionMu.RLock()
 	ids := make([]string, len(w.sessionToIDs[sessionID]))
 	copy(ids, w.sessionToIDs[sessionID])
 	w.sessionMu.RUnlock()
 
-	if len(ids) == 0 {
-		return
-	}
-
-	method := "chat." + string(record.Type)
-
 	for _, id := range ids {
 		sub := w.GetSubscription(id)
 		if sub == nil || sub.Notifier == exclude {
 			continue
 		}
 
-		params := notifyParams{
-			ID:          sub.ID,
-			EventRecord: record,
+		params := paramsFn(sub)
+		if params == nil {
+			continue
 		}
+		sub.Notify(context.Background(), Notification{Method: method, Params: params})
+	}
+}
 
-		n := Notification{Method: method, Params: params}
-		if err := sub.Notifier.Notify(context.Background(), n); err != nil {
-			slog.Debug("failed to notify subscriber",
-				"id", sub.ID,
-				"sessionId", sessionID,
-				"error", err)
-		}
+// wantsEvent reports whether a subscription receives events of type t.
+// User messages always go out: they mark where each turn starts.
+func (w *ChatMessagesWatcher) wantsEvent(id string, t agent.EventType) bool {
+	if t == agent.EventTypeMessage {
+		return true
 	}
+	w.sessionMu.RLock()
+	types, filtered := w.idToTypes[id]
+	w.sessionMu.RUnlock()
+	return !filtered || slices.Contains(types, t)
 }
 
 // notifyParams embeds EventRecord with subscription ID for routing.
@@ -120,11 +137,16 @@ type notifyParams struct {
 }
 
 // Subscribe registers a subscriber for a specific session.
-// Returns subscription ID and history.
+// Returns subscription ID and the latest history page of up to limit
+// records (0 = full history); older pages are read with HistoryPage.
+// Non-empty types limits both the page and later events to those event
+// types; what is left out can be fetched per turn with TurnHistory.
 func (w *ChatMessagesWatcher) Subscribe(
 	notifier Notifier,
 	sessionID string,
-) (string, []json.RawMessage, error) {
+	limit int,
+	types []agent.EventType,
+) (string, session.HistoryPage, error) {
 	id := w.GenerateID()
 	sub := &Subscription{
 		ID:       id,
@@ -134,6 +156,9 @@ func (w *ChatMessagesWatcher) Subscribe(
 	w.sessionMu.Lock()
 	w.sessionToIDs[sessionID] = append(w.sessionToIDs[sessionID], id)
 	w.idToSession[id] = sessionID
+	if len(types) > 0 {
+		w.idToTypes[id] = types
+	}
 	w.sessionMu.Unlock()
 
 	// Register subscription BEFORE getting history to avoid message loss.
@@ -143,10 +168,40 @@ func (w *ChatMessagesWatcher) Subscribe(
 	history, err := w.store.GetHistory(context.Background(), sessionID)
 	if err != nil {
 		w.Unsubscribe(id)
-		return "", nil, err
+		return "", session.HistoryPage{}, err
 	}
 
-	return id, history, nil
+	return id, session.PageHistory(history, len(history), limit).Filter(typeNames(types)), nil
+}
+
+// HistoryPage returns up to limit records before the before cursor, only
+// those of the given types when types is non-empty. The limit counts
+// records before filtering.
+func (w *ChatMessagesWatcher) HistoryPage(ctx context.Context, sessionID string, before, limit int, types []agent.EventType) (session.HistoryPage, error) {
+	history, err := w.store.GetHistory(ctx, sessionID)
+	if err != nil {
+		return session.HistoryPage{}, err
+	}
+	return session.PageHistory(history, before, limit).Filter(typeNames(types)), nil
+}
+
+// TurnHistory returns every record of the turn containing history index at
+// (negative = the latest turn), for a filtered subscriber that wants the
+// events it left out.
+func (w *ChatMessagesWatcher) TurnHistory(ctx context.Context, sessionID string, at int) (session.HistoryPage, error) {
+	history, err := w.store.GetHistory(ctx, sessionID)
+	if err != nil {
+		return session.HistoryPage{}, err
+	}
+	return session.TurnHistory(history, at), nil
+}
+
+func typeNames(types []agent.EventType) []string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return names
 }
 
 // Unsubscribe removes a subscription.
@@ -166,6 +221,7 @@ func (w *ChatMessagesWatcher) removeSessionMapping(id string) {
 	}
 
 	delete(w.idToSession, id)
+	delete(w.idToTypes, id)
 	ids := w.sessionToIDs[sessionID]
 	for i, v := range ids {
 		if v == id {
@@ -191,3 +247,72 @@ func (w *ChatMessagesWatcher) IsViewing(sessionID string) bool {
 func (w *ChatMessagesWatcher) NotifyMessage(sessionID string, event agent.MessageEvent, exclude Notifier) {
 	w.notifyEvent(sessionID, event.ToRecord(), exclude)
 }
+
+// NotifyReapPending warns session subscribers that the idle reaper will stop
+// the session's process at reapAt unless it is kept alive (process.keepalive).
+func (w *ChatMessagesWatcher) NotifyReapPending(sessionID string, reapAt time.Time) {
+	w.notifySession(sessionID, "process.reap_pending", nil, func(sub *Subscription) any {
+		return reapPendingParams{
+			ID:        sub.ID,
+			SessionID: sessionID,
+			ReapAt:    reapAt,
+		}
+	})
+}
+
+// NotifyPossiblyStuck tells session subscribers that the running agent has
+// produced no output since silentSince, listing the process.recover actions.
+func (w *ChatMessagesWatcher) NotifyPossiblyStuck(sessionID string, silentSince time.Time) {
+	w.notifySession(sessionID, "process.possibly_stuck", nil, func(sub *Subscription) any {
+		return possiblyStuckParams{
+			ID:          sub.ID,
+			SessionID:   sessionID,
+			SilentSince: silentSince,
+			Actions:     []string{"interrupt", "restart"},
+		}
+	})
+}
+
+// NotifyInputChange tells session subscribers whose input is held behind
+// the running turn (see process.Process.SubmitInput), as session.busy. Each
+// subscriber learns which of the pending messages are its own; an empty
+// pending list means everything queued has been delivered.
+func (w *ChatMessagesWatcher) NotifyInputChange(s process.InputState) {
+	w.notifySession(s.SessionID, "session.busy", nil, func(sub *Subscription) any {
+		params := sessionBusyParams{
+			ID:        sub.ID,
+			SessionID: s.SessionID,
+			TurnYours: s.Owner != nil && s.Owner == any(sub.Notifier),
+			Pending:   make([]busyPendingInput, len(s.Pending)),
+		}
+		for i, in := range s.Pending {
+			params.Pending[i] = busyPendingInput{Yours: in.From == any(sub.Notifier), QueuedAt: in.QueuedAt}
+		}
+		return params
+	})
+}
+
+type sessionBusyParams struct {
+	ID        string             `json:"id"`
+	SessionID string             `json:"session_id"`
+	TurnYours bool               `json:"turn_yours"` // the running turn is this client's
+	Pending   []busyPendingInput `json:"pending"`
+}
+
+type busyPendingInput struct {
+	Yours    bool      `json:"yours"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+type possiblyStuckParams struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	SilentSince time.Time `json:"silent_since"`
+	Actions     []string  `json:"actions"`
+}
+
+type reapPendingParams struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	ReapAt    time.Time `json:"reap_at"`
+}
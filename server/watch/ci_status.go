@@ -0,0 +1,84 @@
+package watch
+
+import (
+	"log/slog"
+
+	"github.com/pockode/server/ci"
+)
+
+// CIStatusWatcher notifies subscribers when a worktree branch's CI status
+// changes.
+type CIStatusWatcher struct {
+	*BaseWatcher
+	poller  *ci.Poller
+	eventCh chan ci.Status
+}
+
+func NewCIStatusWatcher(poller *ci.Poller) *CIStatusWatcher {
+	w := &CIStatusWatcher{
+		BaseWatcher: NewBaseWatcher("ci"),
+		poller:      poller,
+		eventCh:     make(chan ci.Status, 64),
+	}
+	poller.AddOnChangeListener(w)
+	return w
+}
+
+func (w *CIStatusWatcher) Start() error {
+	go w.eventLoop()
+	slog.Info("CIStatusWatcher started")
+	return nil
+}
+
+func (w *CIStatusWatcher) Stop() {
+	w.Cancel()
+	slog.Info("CIStatusWatcher stopped")
+}
+
+func (w *CIStatusWatcher) eventLoop() {
+	for {
+		select {
+		case <-w.Context().Done():
+			return
+		case status := <-w.eventCh:
+			w.notifyChange(status)
+		}
+	}
+}
+
+func (w *CIStatusWatcher) notifyChange(status ci.Status) {
+	if !w.HasSubscriptions() {
+		return
+	}
+
+	w.NotifyAll("ci.status.changed", func(sub *Subscription) any {
+		return ciStatusChangedParams{ID: sub.ID, Status: status}
+	})
+
+	slog.Debug("notified CI status change", "branch", status.Branch, "state", status.State)
+}
+
+// Subscribe registers a subscriber and returns the current statuses.
+func (w *CIStatusWatcher) Subscribe(notifier Notifier) (string, []ci.Status) {
+	id := w.GenerateID()
+	w.AddSubscription(&Subscription{ID: id, Notifier: notifier})
+	return id, w.poller.List()
+}
+
+type ciStatusChangedParams struct {
+	ID     string    `json:"id"`
+	Status ci.Status `json:"status"`
+}
+
+// OnCIStatusChange implements ci.OnChangeListener.
+func (w *CIStatusWatcher) OnCIStatusChange(_, status ci.Status) {
+	select {
+	case <-w.Context().Done():
+		return
+	case w.eventCh <- status:
+	default:
+		// Each event carries a branch's full status and the poller re-reports
+		// on the next change, so dropping one only delays the badge.
+		slog.Warn("CI status event dropped", "branch", status.Branch)
+	}
+}
@@ -205,13 +205,32 @@ func (w *FSWatcher) notifyPath(changedPath string) {
 		}
 		n := Notification{
 			Method: "fs.changed",
-			Params: map[string]any{"id": sub.ID},
-		}
-		if err := sub.Notifier.Notify(context.Background(), n); err != nil {
-			slog.Debug("failed to notify subscriber", "watchId", sub.ID, "error", err)
+			Params: map[string]any{"id": sub.ID, "path": changedPath},
 		}
+		sub.Notify(context.Background(), n)
 		notified++
 	}
 
 	slog.Debug("notified path change", "path", changedPath, "subscribers", notified)
 }
+
+// NotifyConflict tells the subscribers of path, except those of the writing
+// connection, that a write to it was refused because the file had changed
+// underneath; hash is the file's current hash ("" if it was deleted).
+func (w *FSWatcher) NotifyConflict(path, hash string, writer Notifier) {
+	w.pathMu.RLock()
+	ids := append([]string{}, w.pathToIDs[path]...)
+	w.pathMu.RUnlock()
+
+	for _, id := range ids {
+		sub := w.GetSubscription(id)
+		if sub == nil || sub.Notifier == writer {
+			continue
+		}
+		n := Notification{
+			Method: "file.conflict",
+			Params: map[string]any{"id": sub.ID, "path": path, "hash": hash},
+		}
+		sub.Notify(context.Background(), n)
+	}
+}
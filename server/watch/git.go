@@ -2,18 +2,22 @@ package watch
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os/exec"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/pockode/server/git"
 )
 
 const gitPollInterval = 3 * time.Second
 
-// GitWatcher polls git state (HEAD + status) and notifies subscribers on changes.
-// Detects working tree changes and HEAD changes (commit, checkout, etc).
+// GitWatcher polls git state (HEAD + status + branch divergence) and notifies
+// subscribers on changes. Detects working tree changes, HEAD changes (commit,
+// checkout, etc), and ahead/behind or fetch staleness changes.
 // For file-specific diff content changes, use GitDiffWatcher instead.
 type GitWatcher struct {
 	*BaseWatcher
@@ -21,7 +25,7 @@ type GitWatcher struct {
 	workDir string
 
 	stateMu   sync.Mutex
-	lastState string // HEAD hash + git status output
+	lastState string // HEAD hash + git status output + branch status
 }
 
 func NewGitWatcher(workDir string) *GitWatcher {
@@ -91,16 +95,17 @@ func (w *GitWatcher) checkAndNotify() {
 	}
 }
 
-// pollGitState returns git status + HEAD hash for detecting changes.
-// This detects both working tree changes and HEAD changes (commit, checkout, etc).
+// pollGitState returns git status + HEAD hash + branch status for detecting
+// changes. This detects working tree changes, HEAD changes (commit, checkout,
+// etc), and upstream divergence after a fetch or as the last fetch ages.
 func (w *GitWatcher) pollGitState() string {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Run both commands in parallel to reduce latency
-	var head, status string
+	var head, status, branch string
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(3)
 
 	go func() {
 		defer wg.Done()
@@ -112,9 +117,14 @@ func (w *GitWatcher) pollGitState() string {
 		status = w.runGitCmd(ctx, "status", "--porcelain=v1", "-uall", "--ignore-submodules=none")
 	}()
 
+	go func() {
+		defer wg.Done()
+		branch = w.branchState()
+	}()
+
 	wg.Wait()
 
-	return head + "\n" + sortLines(status)
+	return head + "\n" + branch + "\n" + sortLines(status)
 }
 
 func (w *GitWatcher) runGitCmd(ctx context.Context, args ...string) string {
@@ -129,6 +139,19 @@ func (w *GitWatcher) runGitCmd(ctx context.Context, args ...string) string {
 	return strings.TrimSpace(string(output))
 }
 
+func (w *GitWatcher) branchState() string {
+	b, err := git.Branch(w.workDir)
+	if err != nil {
+		return ""
+	}
+	var lastFetch int64
+	if b.LastFetch != nil {
+		lastFetch = b.LastFetch.UnixNano()
+	}
+	return fmt.Sprintf("%s %s %d/%d %s %d/%d %d %t",
+		b.Branch, b.Upstream, b.Ahead, b.Behind, b.Base, b.BaseAhead, b.BaseBehind, lastFetch, b.FetchStale)
+}
+
 func sortLines(text string) string {
 	if text == "" {
 		return ""
@@ -155,11 +155,11 @@ func (w *GitDiffWatcher) checkOne(sub *Subscription) {
 			"diff":        result.Diff,
 			"old_content": result.OldContent,
 			"new_content": result.NewContent,
+			"hunks":       result.Hunks,
+			"binary":      result.Binary,
 		},
 	}
-	if err := sub.Notifier.Notify(context.Background(), n); err != nil {
-		slog.Debug("failed to notify git diff change", "id", sub.ID, "error", err)
-	}
+	sub.Notify(context.Background(), n)
 }
 
 func (w *GitDiffWatcher) hashDiff(result *git.DiffResult) string {
@@ -0,0 +1,142 @@
+package watch
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+)
+
+// QueueSize bounds the notifications waiting for one subscriber. A client
+// that falls this far behind is stalled (a phone with the app suspended),
+// not slow.
+const QueueSize = 256
+
+// ResyncMethod tells a subscriber that notifications were dropped for it,
+// so the state it built from them is stale. The client subscribes again to
+// get a fresh snapshot.
+const ResyncMethod = "watch.resync_required"
+
+// ResyncParams are the params of ResyncMethod.
+type ResyncParams struct {
+	ID      string `json:"id"` // the subscription
+	Dropped int    `json:"dropped"`
+}
+
+// notifyQueue delivers a subscription's notifications in order from a
+// bounded queue, so a subscriber that does not read blocks only itself, not
+// the watcher notifying everyone. When the queue is full the oldest
+// notification is dropped and ResyncMethod is sent ahead of the rest.
+// A goroutine runs only while there is something to send.
+type notifyQueue struct {
+	subID    string
+	notifier Notifier
+
+	queueMu sync.Mutex
+	items   []queuedNotification
+	dropped int // since the last resync notice
+	sending bool
+	closed  bool
+}
+
+type queuedNotification struct {
+	ctx context.Context
+	n   Notification
+}
+
+func newNotifyQueue(subID string, notifier Notifier) *notifyQueue {
+	return &notifyQueue{subID: subID, notifier: notifier}
+}
+
+func (q *notifyQueue) push(ctx context.Context, n Notification) {
+	q.queueMu.Lock()
+	defer q.queueMu.Unlock()
+	if q.closed {
+		return
+	}
+	if len(q.items) >= QueueSize {
+		dropped := q.items[0]
+		q.items = q.items[1:]
+		if q.dropped == 0 {
+			slog.Warn("subscriber is not reading; dropping notifications", "id", q.subID, "method", dropped.n.Method)
+		}
+		q.dropped++
+		recordDrop(dropped.n.Method)
+	}
+	q.items = append(q.items, queuedNotification{ctx: ctx, n: n})
+	if !q.sending {
+		q.sending = true
+		go q.drain()
+	}
+}
+
+func (q *notifyQueue) drain() {
+	for {
+		q.queueMu.Lock()
+		if q.closed || (len(q.items) == 0 && q.dropped == 0) {
+			q.sending = false
+			q.queueMu.Unlock()
+			return
+		}
+		var next queuedNotification
+		if q.dropped > 0 {
+			next = queuedNotification{
+				ctx: context.Background(),
+				n:   Notification{Method: ResyncMethod, Params: ResyncParams{ID: q.subID, Dropped: q.dropped}},
+			}
+			q.dropped = 0
+			recordResync()
+		} else {
+			next = q.items[0]
+			q.items = q.items[1:]
+		}
+		q.queueMu.Unlock()
+
+		if err := q.notifier.Notify(next.ctx, next.n); err != nil {
+			slog.Debug("failed to notify subscriber", "id", q.subID, "error", err)
+		}
+	}
+}
+
+// close discards what is queued; the subscription is gone.
+func (q *notifyQueue) close() {
+	q.queueMu.Lock()
+	defer q.queueMu.Unlock()
+	q.closed = true
+	q.items = nil
+}
+
+// DeliveryStats counts notifications dropped for subscribers that stopped
+// reading, since the server started.
+type DeliveryStats struct {
+	Dropped  int64            `json:"dropped"`
+	Resyncs  int64            `json:"resyncs"`   // resync notices sent
+	ByMethod map[string]int64 `json:"by_method"` // dropped, per notification method
+}
+
+var (
+	statsMu sync.Mutex
+	stats   = DeliveryStats{ByMethod: make(map[string]int64)}
+)
+
+func recordDrop(method string) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats.Dropped++
+	stats.ByMethod[method]++
+}
+
+func recordResync() {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats.Resyncs++
+}
+
+// Stats returns the delivery counters.
+func Stats() DeliveryStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	s := stats
+	s.ByMethod = maps.Clone(stats.ByMethod)
+	return s
+}
@@ -0,0 +1,101 @@
+package watch
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// blockingNotifier records notifications, blocking each until released.
+type blockingNotifier struct {
+	captureNotifier
+	release chan struct{}
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, notif Notification) error {
+	<-n.release
+	return n.captureNotifier.Notify(ctx, notif)
+}
+
+func TestNotifyQueue_SlowSubscriberDropsOldest(t *testing.T) {
+	b := NewBaseWatcher("t")
+	stalled := &blockingNotifier{release: make(chan struct{})}
+	healthy := &captureNotifier{}
+	b.AddSubscription(&Subscription{ID: "stalled", Notifier: stalled})
+	b.AddSubscription(&Subscription{ID: "healthy", Notifier: healthy})
+	before := Stats()
+
+	// The first notification is taken off the queue and blocks in Notify;
+	// the queue then holds QueueSize, so 5 more are dropped.
+	const extra = 5
+	total := 1 + QueueSize + extra
+	for i := range total {
+		b.NotifyAll("test.changed", func(*Subscription) any { return i })
+		if i == 0 {
+			waitFor(t, func() bool { return stalledBusy(b, "stalled") })
+		}
+		// The stalled subscriber does not hold up the other, which keeps
+		// up and so never drops.
+		for deadline := time.Now().Add(2 * time.Second); healthy.count() <= i; {
+			if time.Now().After(deadline) {
+				t.Fatalf("healthy subscriber got %d of %d", healthy.count(), i+1)
+			}
+			runtime.Gosched()
+		}
+	}
+
+	after := Stats()
+	if got := after.Dropped - before.Dropped; got != extra {
+		t.Errorf("dropped = %d, want %d", got, extra)
+	}
+	if got := after.ByMethod["test.changed"] - before.ByMethod["test.changed"]; got != extra {
+		t.Errorf("dropped test.changed = %d, want %d", got, extra)
+	}
+
+	close(stalled.release)
+	waitFor(t, func() bool { return stalled.count() == 1+1+QueueSize })
+
+	stalled.mu.Lock()
+	defer stalled.mu.Unlock()
+	if stalled.methods[0] != "test.changed" || string(stalled.params[0]) != "0" {
+		t.Errorf("first = %s %s, want the in-flight notification", stalled.methods[0], stalled.params[0])
+	}
+	if stalled.methods[1] != ResyncMethod || string(stalled.params[1]) != `{"id":"stalled","dropped":5}` {
+		t.Errorf("second = %s %s, want the resync notice", stalled.methods[1], stalled.params[1])
+	}
+	// The newest notifications survive, in order.
+	if got := string(stalled.params[2]); got != "6" {
+		t.Errorf("oldest kept = %s, want 6", got)
+	}
+	if got := string(stalled.params[len(stalled.params)-1]); got != "261" {
+		t.Errorf("newest = %s, want 261", got)
+	}
+	if Stats().Resyncs-before.Resyncs != 1 {
+		t.Errorf("resyncs = %d, want 1", Stats().Resyncs-before.Resyncs)
+	}
+}
+
+func stalledBusy(b *BaseWatcher, id string) bool {
+	q := b.GetSubscription(id).queue
+	q.queueMu.Lock()
+	defer q.queueMu.Unlock()
+	return q.sending && len(q.items) == 0
+}
+
+func TestNotifyQueue_RemoveDiscardsQueued(t *testing.T) {
+	b := NewBaseWatcher("t")
+	n := &blockingNotifier{release: make(chan struct{})}
+	b.AddSubscription(&Subscription{ID: "s", Notifier: n})
+
+	b.NotifyAll("test.changed", func(*Subscription) any { return 1 })
+	waitFor(t, func() bool { return stalledBusy(b, "s") })
+	b.NotifyAll("test.changed", func(*Subscription) any { return 2 })
+	b.Unsubscribe("s")
+	close(n.release)
+
+	waitFor(t, func() bool { return n.count() == 1 })
+	sub := &Subscription{ID: "s", Notifier: n}
+	sub.Notify(context.Background(), Notification{Method: "direct"})
+	waitFor(t, func() bool { return n.count() == 2 })
+}
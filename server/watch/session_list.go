@@ -3,8 +3,14 @@ package watch
 import (
 	"context"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"unicode/utf8"
 
+	"github.com/pockode/server/agent"
 	"github.com/pockode/server/process"
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/session"
@@ -24,6 +30,18 @@ type WorkNeedsInputSyncer interface {
 	SyncNeedsInput(ctx context.Context, sessionID string, needsInput bool)
 }
 
+// SessionWorkLink names the work item a session belongs to and the worktree
+// whose work store holds it ("" for the main store).
+type SessionWorkLink struct {
+	WorkID   string
+	Worktree string
+}
+
+// SessionWorkResolver maps session IDs to the work items linked to them.
+type SessionWorkResolver interface {
+	SessionWorkLinks() (map[string]SessionWorkLink, error)
+}
+
 // SessionListWatcher notifies subscribers when the session list changes.
 // Uses a channel-based async notification pattern to avoid blocking the session
 // store's mutex during network I/O.
@@ -33,8 +51,20 @@ type SessionListWatcher struct {
 	processStateGetter   ProcessStateGetter
 	viewingChecker       ViewingChecker
 	workNeedsInputSyncer WorkNeedsInputSyncer
+	workResolver         SessionWorkResolver
 	eventCh              chan session.SessionChangeEvent
-	dirty                atomic.Bool // set when an event is dropped; triggers full sync
+	msgCh                chan process.ChatMessage // chat messages awaiting RecordMessage
+	dirty                atomic.Bool              // set when an event is dropped; triggers full sync
+
+	pendingMu sync.Mutex
+	pending   map[string]pendingMessage // agent text not yet recorded, by session
+}
+
+// pendingMessage is agent text held back until the end of its turn, so a
+// streaming reply rewrites the session index once rather than per message.
+type pendingMessage struct {
+	preview string
+	unread  int
 }
 
 func NewSessionListWatcher(store session.Store) *SessionListWatcher {
@@ -42,8 +72,10 @@ func NewSessionListWatcher(store session.Store) *SessionListWatcher {
 		BaseWatcher: NewBaseWatcher("sl"),
 		store:       store,
 		eventCh:     make(chan session.SessionChangeEvent, 64), // Buffer to avoid blocking
+		msgCh:       make(chan process.ChatMessage, 256),
+		pending:     make(map[string]pendingMessage),
 	}
-	store.SetOnChangeListener(w)
+	store.AddOnChangeListener(w)
 	return w
 }
 
@@ -59,6 +91,26 @@ func (w *SessionListWatcher) SetWorkNeedsInputSyncer(s WorkNeedsInputSyncer) {
 	w.workNeedsInputSyncer = s
 }
 
+// SetWorkResolver sets how list items get their work_id and worktree.
+// Without one they are left empty.
+func (w *SessionListWatcher) SetWorkResolver(r SessionWorkResolver) {
+	w.workResolver = r
+}
+
+// workLinks returns the current session → work links, or nil when there
+// is no resolver or it fails; a missing link only loses the grouping.
+func (w *SessionListWatcher) workLinks() map[string]SessionWorkLink {
+	if w.workResolver == nil {
+		return nil
+	}
+	links, err := w.workResolver.SessionWorkLinks()
+	if err != nil {
+		slog.Warn("failed to resolve session work links", "error", err)
+		return nil
+	}
+	return links
+}
+
 func (w *SessionListWatcher) Start() error {
 	go w.eventLoop()
 	slog.Info("SessionListWatcher started")
@@ -75,6 +127,7 @@ func (w *SessionListWatcher) eventLoop() {
 	for {
 		select {
 		case <-w.Context().Done():
+			w.flushAllMessages()
 			return
 		case event := <-w.eventCh:
 			if w.dirty.Swap(false) {
@@ -82,22 +135,51 @@ func (w *SessionListWatcher) eventLoop() {
 			} else {
 				w.notifyChange(event)
 			}
+		case msg := <-w.msgCh:
+			w.recordMessage(msg)
 		}
 	}
 }
 
 func (w *SessionListWatcher) buildItem(meta session.SessionMeta) rpc.SessionListItem {
+	return w.buildItemWithState(meta, w.processStateGetter.GetProcessState(meta.ID), w.workLinks())
+}
+
+func (w *SessionListWatcher) buildItemWithState(meta session.SessionMeta, state string, links map[string]SessionWorkLink) rpc.SessionListItem {
+	link := links[meta.ID]
 	return rpc.SessionListItem{
 		SessionMeta: meta,
-		State:       w.processStateGetter.GetProcessState(meta.ID),
+		State:       state,
+		WorkID:      link.WorkID,
+		Worktree:    link.Worktree,
 	}
 }
 
+// buildItems builds list items for the sessions shown in the list, leaving
+// out quick-chat sessions. Work links are resolved once for the whole list.
+func (w *SessionListWatcher) buildItems(sessions []session.SessionMeta) []rpc.SessionListItem {
+	links := w.workLinks()
+	items := make([]rpc.SessionListItem, 0, len(sessions))
+	for _, sess := range sessions {
+		if sess.Ephemeral {
+			continue
+		}
+		items = append(items, w.buildItemWithState(sess, w.processStateGetter.GetProcessState(sess.ID), links))
+	}
+	return items
+}
+
 // notifyChange sends notifications to all subscribers.
 func (w *SessionListWatcher) notifyChange(event session.SessionChangeEvent) {
 	if !w.HasSubscriptions() {
 		return
 	}
+	// Quick-chat sessions stay out of the list until kept. Deletes carry
+	// only the ID, so a discarded quick chat still sends one; clients
+	// ignore deletes of unknown sessions.
+	if event.Op != session.OperationDelete && event.Session.Ephemeral {
+		return
+	}
 
 	w.NotifyAll("session.list.changed", func(sub *Subscription) any {
 		params := sessionListChangedParams{
@@ -128,10 +210,7 @@ func (w *SessionListWatcher) notifySync() {
 		return
 	}
 
-	items := make([]rpc.SessionListItem, len(sessions))
-	for i, sess := range sessions {
-		items[i] = w.buildItem(sess)
-	}
+	items := w.buildItems(sessions)
 
 	w.NotifyAll("session.list.changed", func(sub *Subscription) any {
 		return sessionListSyncParams{
@@ -162,10 +241,7 @@ func (w *SessionListWatcher) Subscribe(notifier Notifier) (string, []rpc.Session
 		return "", nil, err
 	}
 
-	items := make([]rpc.SessionListItem, len(sessions))
-	for i, sess := range sessions {
-		items[i] = w.buildItem(sess)
-	}
+	items := w.buildItems(sessions)
 
 	return id, items, nil
 }
@@ -220,16 +296,13 @@ func (w *SessionListWatcher) HandleProcessStateChange(e process.StateChangeEvent
 	}
 
 	meta, found, err := w.store.Get(e.SessionID)
-	if err != nil || !found {
+	if err != nil || !found || meta.Ephemeral {
 		return
 	}
 
 	// Use e.State directly — the event already carries the authoritative state,
 	// so re-querying via GetProcessState would be redundant.
-	item := rpc.SessionListItem{
-		SessionMeta: meta,
-		State:       string(e.State),
-	}
+	item := w.buildItemWithState(meta, string(e.State), w.workLinks())
 	w.NotifyAll("session.list.changed", func(sub *Subscription) any {
 		return sessionListChangedParams{
 			ID:        sub.ID,
@@ -251,14 +324,136 @@ func (w *SessionListWatcher) ClearNeedsInput(sessionID string) {
 	}
 }
 
-func (w *SessionListWatcher) MarkRead(sessionID string) {
+// MarkRead clears the unread flag and count. Reports ErrSessionNotFound so
+// session.mark_read can reject unknown IDs.
+func (w *SessionListWatcher) MarkRead(sessionID string) error {
 	meta, found, err := w.store.Get(sessionID)
-	if err != nil || !found || !meta.Unread {
+	if err != nil {
+		return err
+	}
+	if !found {
+		return session.ErrSessionNotFound
+	}
+	w.pendingMu.Lock()
+	if p, ok := w.pending[sessionID]; ok {
+		p.unread = 0
+		w.pending[sessionID] = p
+	}
+	w.pendingMu.Unlock()
+	if !meta.Unread && meta.UnreadCount == 0 {
+		return nil
+	}
+	return w.store.SetUnread(context.Background(), sessionID, false)
+}
+
+// maxPreviewRunes bounds last_message_preview so the list payload stays small.
+const maxPreviewRunes = 120
+
+// OnChatMessage implements process.ChatMessageListener. Messages are queued
+// and recorded on the event loop so the agent stream and chat.message replies
+// never wait on the index write. Dropping one only makes the count/preview
+// stale until the next message.
+func (w *SessionListWatcher) OnChatMessage(msg process.ChatMessage) {
+	if w.Context().Err() != nil {
+		return
+	}
+
+	select {
+	case w.msgCh <- msg:
+	default:
+		slog.Warn("chat message dropped from session list (buffer full)", "sessionId", msg.SessionID)
+	}
+}
+
+// recordMessage updates the unread count and preview for one chat message.
+// Agent output and prompts (text, permission requests, questions) count as
+// unread unless a client is viewing the session; user messages only refresh
+// the preview. Agent text is held until the turn ends or another message is
+// recorded for the session, then written together with it.
+func (w *SessionListWatcher) recordMessage(msg process.ChatMessage) {
+	var preview string
+	unread := true
+	switch e := msg.Event.(type) {
+	case agent.TextEvent:
+		preview = e.Content
+	case agent.DoneEvent, agent.ErrorEvent, agent.InterruptedEvent, agent.ProcessEndedEvent:
+		w.flushMessage(msg.SessionID)
+		return
+	case agent.PermissionRequestEvent:
+		preview = "Permission requested: " + e.ToolName
+	case agent.AskUserQuestionEvent:
+		if len(e.Questions) > 0 {
+			preview = e.Questions[0].Question
+		}
+	case agent.MessageEvent:
+		preview = e.Content
+		unread = false
+	case agent.PermissionResponseEvent:
+		// Only server-answered responses reach listeners; user responses
+		// are recorded by chat.Client and cleared via ClearNeedsInput.
+		switch {
+		case e.TimedOut:
+			preview = "Permission request timed out: " + e.Choice
+			w.ClearNeedsInput(msg.SessionID)
+		case e.ByMode != "":
+			preview = "Permission " + e.Choice + " by mode " + string(e.ByMode)
+		default:
+			return
+		}
+	default:
 		return
 	}
-	if err := w.store.SetUnread(context.Background(), sessionID, false); err != nil {
-		slog.Warn("failed to mark read", "sessionId", sessionID, "error", err)
+	if unread && w.viewingChecker != nil && w.viewingChecker.IsViewing(msg.SessionID) {
+		unread = false
+	}
+
+	w.pendingMu.Lock()
+	p := w.pending[msg.SessionID]
+	p.preview = truncatePreview(preview)
+	if unread {
+		p.unread++
+	}
+	w.pending[msg.SessionID] = p
+	w.pendingMu.Unlock()
+
+	if _, ok := msg.Event.(agent.TextEvent); !ok {
+		w.flushMessage(msg.SessionID)
+	}
+}
+
+// flushMessage writes the session's pending preview and unread count.
+func (w *SessionListWatcher) flushMessage(sessionID string) {
+	w.pendingMu.Lock()
+	p, ok := w.pending[sessionID]
+	delete(w.pending, sessionID)
+	w.pendingMu.Unlock()
+	if !ok {
+		return
+	}
+
+	if err := w.store.RecordMessage(context.Background(), sessionID, p.preview, p.unread); err != nil {
+		slog.Warn("failed to record message", "sessionId", sessionID, "error", err)
+	}
+}
+
+// flushAllMessages writes every pending message, on shutdown.
+func (w *SessionListWatcher) flushAllMessages() {
+	w.pendingMu.Lock()
+	ids := slices.Collect(maps.Keys(w.pending))
+	w.pendingMu.Unlock()
+	for _, id := range ids {
+		w.flushMessage(id)
+	}
+}
+
+// truncatePreview collapses whitespace to single spaces and caps the length.
+func truncatePreview(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxPreviewRunes {
+		return s
 	}
+	r := []rune(s)
+	return string(r[:maxPreviewRunes-1]) + "…"
 }
 
 // OnSessionChange implements session.OnChangeListener.
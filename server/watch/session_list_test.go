@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/pockode/server/agent"
 	"github.com/pockode/server/process"
 	"github.com/pockode/server/session"
 )
@@ -13,6 +16,13 @@ import (
 type mockSessionStore struct {
 	sessions []session.SessionMeta
 	listener session.OnChangeListener
+	recorded []recordedMessage
+}
+
+type recordedMessage struct {
+	sessionID string
+	preview   string
+	unread    int
 }
 
 func (m *mockSessionStore) List() ([]session.SessionMeta, error) {
@@ -32,6 +42,14 @@ func (m *mockSessionStore) Create(ctx context.Context, sessionID string, agentTy
 	return session.SessionMeta{}, nil
 }
 
+func (m *mockSessionStore) CreateEphemeral(ctx context.Context, sessionID string, agentType session.AgentType, mode session.Mode) (session.SessionMeta, error) {
+	return session.SessionMeta{}, nil
+}
+
+func (m *mockSessionStore) Keep(ctx context.Context, sessionID string, title string) (session.SessionMeta, error) {
+	return session.SessionMeta{}, nil
+}
+
 func (m *mockSessionStore) SetAgentType(ctx context.Context, sessionID string, agentType session.AgentType) error {
 	return nil
 }
@@ -40,6 +58,18 @@ func (m *mockSessionStore) Delete(ctx context.Context, sessionID string) error {
 	return nil
 }
 
+func (m *mockSessionStore) Trash(ctx context.Context, sessionID string) error {
+	return nil
+}
+
+func (m *mockSessionStore) Restore(ctx context.Context, sessionID string) (session.SessionMeta, error) {
+	return session.SessionMeta{}, nil
+}
+
+func (m *mockSessionStore) ListTrash() ([]session.TrashedSession, error) {
+	return nil, nil
+}
+
 func (m *mockSessionStore) Update(ctx context.Context, sessionID string, title string) error {
 	return nil
 }
@@ -64,15 +94,48 @@ func (m *mockSessionStore) SetMode(ctx context.Context, sessionID string, mode s
 	return nil
 }
 
+func (m *mockSessionStore) SetAgentRoleID(ctx context.Context, sessionID string, agentRoleID string) error {
+	return nil
+}
+
+func (m *mockSessionStore) RecordMessage(ctx context.Context, sessionID string, preview string, unread int) error {
+	m.recorded = append(m.recorded, recordedMessage{sessionID: sessionID, preview: preview, unread: unread})
+	return nil
+}
+
 func (m *mockSessionStore) SetNeedsInput(ctx context.Context, sessionID string, needsInput bool) error {
 	return nil
 }
 
+func (m *mockSessionStore) SaveToolResult(ctx context.Context, sessionID, toolUseID, content string) error {
+	return nil
+}
+
+func (m *mockSessionStore) GetToolResult(ctx context.Context, sessionID, toolUseID string) (string, error) {
+	return "", session.ErrToolResultNotFound
+}
+
+func (m *mockSessionStore) ToolCalls(ctx context.Context, sessionID string, f session.ToolCallFilter) ([]session.ToolCall, error) {
+	return nil, nil
+}
+
+func (m *mockSessionStore) SetKeepAlive(ctx context.Context, sessionID string, keepAlive bool) error {
+	return nil
+}
+
+func (m *mockSessionStore) AddModifiedFiles(ctx context.Context, sessionID string, paths []string) error {
+	return nil
+}
+
+func (m *mockSessionStore) SetDrained(ctx context.Context, sessionID string, drained session.DrainState) error {
+	return nil
+}
+
 func (m *mockSessionStore) SetUnread(ctx context.Context, sessionID string, unread bool) error {
 	return nil
 }
 
-func (m *mockSessionStore) SetOnChangeListener(listener session.OnChangeListener) {
+func (m *mockSessionStore) AddOnChangeListener(listener session.OnChangeListener) {
 	m.listener = listener
 }
 
@@ -126,6 +189,32 @@ func TestSessionListWatcher_Subscribe(t *testing.T) {
 	}
 }
 
+type mockWorkResolver map[string]SessionWorkLink
+
+func (m mockWorkResolver) SessionWorkLinks() (map[string]SessionWorkLink, error) {
+	return m, nil
+}
+
+func TestSessionListWatcher_Subscribe_ResolvesWorkLinks(t *testing.T) {
+	store := &mockSessionStore{
+		sessions: []session.SessionMeta{{ID: "sess-1"}, {ID: "sess-2"}},
+	}
+	w := NewSessionListWatcher(store)
+	w.SetProcessStateGetter(&mockProcessStateGetter{})
+	w.SetWorkResolver(mockWorkResolver{"sess-2": {WorkID: "w1", Worktree: "feature"}})
+
+	_, sessions, err := w.Subscribe(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sessions[0].WorkID != "" || sessions[0].Worktree != "" {
+		t.Errorf("unlinked session got %q/%q", sessions[0].WorkID, sessions[0].Worktree)
+	}
+	if sessions[1].WorkID != "w1" || sessions[1].Worktree != "feature" {
+		t.Errorf("linked session got %q/%q, want w1/feature", sessions[1].WorkID, sessions[1].Worktree)
+	}
+}
+
 func TestSessionListWatcher_Unsubscribe(t *testing.T) {
 	store := &mockSessionStore{}
 	w := NewSessionListWatcher(store)
@@ -340,7 +429,7 @@ func TestSessionListWatcher_DirtyFlag_SyncsAfterDrop(t *testing.T) {
 		store:       store,
 		eventCh:     make(chan session.SessionChangeEvent, 1),
 	}
-	store.SetOnChangeListener(w)
+	store.AddOnChangeListener(w)
 	w.SetProcessStateGetter(&mockProcessStateGetter{})
 
 	notifier := &captureNotifier{}
@@ -411,3 +500,85 @@ func TestClearNeedsInput_NoSyncer_NoPanic(t *testing.T) {
 		t.Fatalf("expected 1 SetNeedsInput call, got %d", len(store.needsInputCalls))
 	}
 }
+
+type stubViewingChecker struct{ viewing map[string]bool }
+
+func (s stubViewingChecker) IsViewing(sessionID string) bool { return s.viewing[sessionID] }
+
+func TestSessionListWatcher_RecordMessage(t *testing.T) {
+	store := &mockSessionStore{}
+	w := NewSessionListWatcher(store)
+	w.SetViewingChecker(stubViewingChecker{viewing: map[string]bool{"viewed": true}})
+
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.TextEvent{Content: "hello\n\n  world"}})
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.PermissionRequestEvent{ToolName: "Bash"}})
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.MessageEvent{Content: "user says"}})
+	w.recordMessage(process.ChatMessage{SessionID: "viewed", Event: agent.TextEvent{Content: "seen"}})
+	w.recordMessage(process.ChatMessage{SessionID: "viewed", Event: agent.DoneEvent{}})
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.ToolCallEvent{ToolName: "Read"}})
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.PermissionResponseEvent{Choice: "allow"}})
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.PermissionResponseEvent{Choice: "deny", TimedOut: true}})
+
+	want := []recordedMessage{
+		{sessionID: "s1", preview: "Permission requested: Bash", unread: 2},
+		{sessionID: "s1", preview: "user says", unread: 0},
+		{sessionID: "viewed", preview: "seen", unread: 0},
+		{sessionID: "s1", preview: "Permission request timed out: deny", unread: 1},
+	}
+	if len(store.recorded) != len(want) {
+		t.Fatalf("recorded = %+v, want %+v", store.recorded, want)
+	}
+	for i := range want {
+		if store.recorded[i] != want[i] {
+			t.Errorf("recorded[%d] = %+v, want %+v", i, store.recorded[i], want[i])
+		}
+	}
+}
+
+func TestTruncatePreview(t *testing.T) {
+	long := strings.Repeat("あ", maxPreviewRunes+10)
+	got := truncatePreview(long)
+	if n := utf8.RuneCountInString(got); n != maxPreviewRunes {
+		t.Errorf("rune count = %d, want %d", n, maxPreviewRunes)
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Errorf("expected ellipsis suffix, got %q", got)
+	}
+}
+
+func TestSessionListWatcher_MarkRead_NotFound(t *testing.T) {
+	w := NewSessionListWatcher(&mockSessionStore{})
+
+	if err := w.MarkRead("missing"); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("err = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestSessionListWatcher_RecordMessageBatchesTextPerTurn(t *testing.T) {
+	store := &mockSessionStore{sessions: []session.SessionMeta{{ID: "s1"}}}
+	w := NewSessionListWatcher(store)
+
+	for _, text := range []string{"one", "two", "three"} {
+		w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.TextEvent{Content: text}})
+	}
+	if len(store.recorded) != 0 {
+		t.Fatalf("recorded %+v before the turn ended, want nothing", store.recorded)
+	}
+
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.DoneEvent{}})
+	want := recordedMessage{sessionID: "s1", preview: "three", unread: 3}
+	if len(store.recorded) != 1 || store.recorded[0] != want {
+		t.Fatalf("recorded = %+v, want [%+v]", store.recorded, want)
+	}
+
+	// Marking read mid-turn drops the unread count held back so far.
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.TextEvent{Content: "four"}})
+	if err := w.MarkRead("s1"); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	w.recordMessage(process.ChatMessage{SessionID: "s1", Event: agent.InterruptedEvent{}})
+	want = recordedMessage{sessionID: "s1", preview: "four", unread: 0}
+	if len(store.recorded) != 2 || store.recorded[1] != want {
+		t.Errorf("recorded = %+v, want %+v last", store.recorded, want)
+	}
+}
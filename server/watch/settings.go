@@ -23,7 +23,7 @@ func NewSettingsWatcher(store *settings.Store) *SettingsWatcher {
 		store:       store,
 		eventCh:     make(chan struct{}, 16),
 	}
-	store.SetOnChangeListener(w)
+	store.AddOnChangeListener(w)
 	return w
 }
 
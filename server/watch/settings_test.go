@@ -24,7 +24,7 @@ func TestSettingsWatcher_Subscribe(t *testing.T) {
 	if id == "" {
 		t.Error("expected non-empty subscription ID")
 	}
-	if s != store.Get() {
+	if !s.Equal(store.Get()) {
 		t.Error("expected settings to match store")
 	}
 	if !w.HasSubscriptions() {
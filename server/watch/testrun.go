@@ -0,0 +1,82 @@
+package watch
+
+import (
+	"log/slog"
+
+	"github.com/pockode/server/testrun"
+)
+
+// TestRunWatcher notifies subscribers of newly reported test runs.
+type TestRunWatcher struct {
+	*BaseWatcher
+	eventCh chan testrun.Run
+}
+
+func NewTestRunWatcher(store testrun.Store) *TestRunWatcher {
+	w := &TestRunWatcher{
+		BaseWatcher: NewBaseWatcher("tr"),
+		eventCh:     make(chan testrun.Run, 64),
+	}
+	store.AddOnReportListener(w)
+	return w
+}
+
+func (w *TestRunWatcher) Start() error {
+	go w.eventLoop()
+	slog.Info("TestRunWatcher started")
+	return nil
+}
+
+func (w *TestRunWatcher) Stop() {
+	w.Cancel()
+	slog.Info("TestRunWatcher stopped")
+}
+
+func (w *TestRunWatcher) eventLoop() {
+	for {
+		select {
+		case <-w.Context().Done():
+			return
+		case run := <-w.eventCh:
+			w.notifyReport(run)
+		}
+	}
+}
+
+func (w *TestRunWatcher) notifyReport(run testrun.Run) {
+	if !w.HasSubscriptions() {
+		return
+	}
+
+	w.NotifyAll("testrun.reported", func(sub *Subscription) any {
+		return testRunReportedParams{ID: sub.ID, Run: run}
+	})
+
+	slog.Debug("notified test run report", "workId", run.WorkID, "status", run.Status)
+}
+
+// Subscribe registers a subscriber for new runs. Clients fetch existing runs
+// with testrun.list.
+func (w *TestRunWatcher) Subscribe(notifier Notifier) string {
+	id := w.GenerateID()
+	w.AddSubscription(&Subscription{ID: id, Notifier: notifier})
+	return id
+}
+
+type testRunReportedParams struct {
+	ID  string      `json:"id"`
+	Run testrun.Run `json:"run"`
+}
+
+// OnTestRunReport implements testrun.OnReportListener.
+func (w *TestRunWatcher) OnTestRunReport(run testrun.Run) {
+	select {
+	case <-w.Context().Done():
+		return
+	case w.eventCh <- run:
+	default:
+		// Runs are append-only, so a client that misses one catches up with
+		// testrun.list; no sync message is needed.
+		slog.Warn("test run event dropped", "workId", run.WorkID)
+	}
+}
@@ -19,5 +19,6 @@ var (
 	_ Watcher = (*ChatMessagesWatcher)(nil)
 	_ Watcher = (*WorkListWatcher)(nil)
 	_ Watcher = (*WorkDetailWatcher)(nil)
+	_ Watcher = (*WorkSubtreeWatcher)(nil)
 	_ Watcher = (*AgentRoleListWatcher)(nil)
 )
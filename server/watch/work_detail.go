@@ -150,11 +150,7 @@ func (w *WorkDetailWatcher) notifySyncAll() {
 			Comments: d.Comments,
 		}
 		n := Notification{Method: "work.detail.changed", Params: params}
-		if err := sub.Notifier.Notify(w.Context(), n); err != nil {
-			slog.Debug("failed to notify detail subscriber",
-				"id", sub.ID,
-				"error", err)
-		}
+		sub.Notify(w.Context(), n)
 	}
 
 	slog.Info("sent full detail sync to subscribers after event drop")
@@ -169,11 +165,7 @@ func (w *WorkDetailWatcher) notifyFiltered(workID, method string, makeParams fun
 		}
 		params := makeParams(sub)
 		n := Notification{Method: method, Params: params}
-		if err := sub.Notifier.Notify(w.Context(), n); err != nil {
-			slog.Debug("failed to notify detail subscriber",
-				"id", sub.ID,
-				"error", err)
-		}
+		sub.Notify(w.Context(), n)
 	}
 }
 
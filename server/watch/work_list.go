@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"sync/atomic"
 
+	"github.com/pockode/server/mcp"
 	"github.com/pockode/server/work"
 )
 
@@ -129,6 +130,70 @@ type workListSyncParams struct {
 	Works     []work.Work `json:"works"`
 }
 
+type workDueSoonParams struct {
+	ID string `json:"id"`
+	work.DueSoonEvent
+}
+
+// OnWorkDueSoon implements work.OnDueSoonListener, sending the reminder to
+// every work list subscriber.
+func (w *WorkListWatcher) OnWorkDueSoon(event work.DueSoonEvent) {
+	if !w.HasSubscriptions() {
+		return
+	}
+	w.NotifyAll("work.due_soon", func(sub *Subscription) any {
+		return workDueSoonParams{ID: sub.ID, DueSoonEvent: event}
+	})
+}
+
+type workBudgetExceededParams struct {
+	ID string `json:"id"`
+	work.BudgetExceededEvent
+}
+
+// OnBudgetExceeded implements work.OnBudgetExceededListener, sending
+// work.budget_exceeded to every work list subscriber.
+func (w *WorkListWatcher) OnBudgetExceeded(event work.BudgetExceededEvent) {
+	if !w.HasSubscriptions() {
+		return
+	}
+	w.NotifyAll("work.budget_exceeded", func(sub *Subscription) any {
+		return workBudgetExceededParams{ID: sub.ID, BudgetExceededEvent: event}
+	})
+}
+
+type autorunChangeParams struct {
+	ID     string             `json:"id"`
+	Status work.AutorunStatus `json:"status"`
+}
+
+// OnAutorunChange implements work.OnAutorunChangeListener, sending
+// autorun.budget_exceeded or autorun.resumed to every work list subscriber.
+func (w *WorkListWatcher) OnAutorunChange(event work.AutorunEvent) {
+	if !w.HasSubscriptions() {
+		return
+	}
+	w.NotifyAll("autorun."+event.Type, func(sub *Subscription) any {
+		return autorunChangeParams{ID: sub.ID, Status: event.Status}
+	})
+}
+
+type mcpAlertParams struct {
+	ID string `json:"id"`
+	mcp.Alert
+}
+
+// OnMCPAlert implements mcp.OnAlertListener, sending mcp.alert to every
+// work list subscriber.
+func (w *WorkListWatcher) OnMCPAlert(alert mcp.Alert) {
+	if !w.HasSubscriptions() {
+		return
+	}
+	w.NotifyAll("mcp.alert", func(sub *Subscription) any {
+		return mcpAlertParams{ID: sub.ID, Alert: alert}
+	})
+}
+
 // OnWorkChange implements work.OnChangeListener.
 // Called outside the store's mutex, but still must not block
 // to avoid delaying other listeners.
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pockode/server/mcp"
 	"github.com/pockode/server/work"
 )
 
@@ -220,3 +221,56 @@ func waitFor(t *testing.T, cond func() bool) {
 	}
 	t.Fatal("timed out waiting for condition")
 }
+
+func TestWorkListWatcher_DueSoon(t *testing.T) {
+	w := NewWorkListWatcher(&mockWorkStore{})
+	notifier := &captureNotifier{}
+	id, _, err := w.Subscribe(notifier)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	w.OnWorkDueSoon(work.DueSoonEvent{Work: work.Work{ID: "w1"}, LeadMinutes: 60})
+	waitFor(t, func() bool { return notifier.count() >= 1 })
+
+	if notifier.count() != 1 || notifier.methods[0] != "work.due_soon" {
+		t.Fatalf("notifications = %v, want one work.due_soon", notifier.methods)
+	}
+	var params struct {
+		ID          string    `json:"id"`
+		Work        work.Work `json:"work"`
+		LeadMinutes int       `json:"lead_minutes"`
+	}
+	if err := json.Unmarshal(notifier.last(), &params); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if params.ID != id || params.Work.ID != "w1" || params.LeadMinutes != 60 {
+		t.Errorf("params = %+v", params)
+	}
+}
+
+func TestWorkListWatcher_MCPAlert(t *testing.T) {
+	w := NewWorkListWatcher(&mockWorkStore{})
+	notifier := &captureNotifier{}
+	id, _, err := w.Subscribe(notifier)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	w.OnMCPAlert(mcp.Alert{Kind: mcp.AlertLoop, SessionID: "s1", Tool: "work_create", Calls: 5, WindowSeconds: 60})
+	waitFor(t, func() bool { return notifier.count() >= 1 })
+
+	if notifier.count() != 1 || notifier.methods[0] != "mcp.alert" {
+		t.Fatalf("notifications = %v, want one mcp.alert", notifier.methods)
+	}
+	var params struct {
+		ID string `json:"id"`
+		mcp.Alert
+	}
+	if err := json.Unmarshal(notifier.last(), &params); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if params.ID != id || params.Kind != mcp.AlertLoop || params.SessionID != "s1" || params.Tool != "work_create" {
+		t.Errorf("params = %+v", params)
+	}
+}
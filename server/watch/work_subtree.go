@@ -0,0 +1,239 @@
+package watch
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pockode/server/work"
+)
+
+// WorkSubtreeWatcher notifies subscribers of changes to one work item and,
+// optionally, its descendants, so a client following a single story does
+// not have to take the whole board from WorkListWatcher.
+type WorkSubtreeWatcher struct {
+	*BaseWatcher
+	store   work.Store
+	eventCh chan work.ChangeEvent
+	dirty   atomic.Bool // set when an event is dropped; triggers full sync
+
+	subtreesMu sync.Mutex
+	subtrees   map[string]*workSubtree // subscription ID -> watched items
+}
+
+// workSubtree tracks the items a subscription currently covers. Tracking
+// them, rather than walking parents on each event, also catches deletes
+// (the parent may be gone by then) and items moved out of the subtree.
+type workSubtree struct {
+	rootID          string
+	includeChildren bool
+	ids             map[string]bool
+}
+
+func NewWorkSubtreeWatcher(store work.Store) *WorkSubtreeWatcher {
+	w := &WorkSubtreeWatcher{
+		BaseWatcher: NewBaseWatcher("wst"),
+		store:       store,
+		eventCh:     make(chan work.ChangeEvent, 64),
+		subtrees:    make(map[string]*workSubtree),
+	}
+	store.AddOnChangeListener(w)
+	return w
+}
+
+func (w *WorkSubtreeWatcher) Start() error {
+	go w.eventLoop()
+	slog.Info("WorkSubtreeWatcher started")
+	return nil
+}
+
+func (w *WorkSubtreeWatcher) Stop() {
+	w.Cancel()
+	slog.Info("WorkSubtreeWatcher stopped")
+}
+
+func (w *WorkSubtreeWatcher) eventLoop() {
+	for {
+		select {
+		case <-w.Context().Done():
+			return
+		case event := <-w.eventCh:
+			if w.dirty.Swap(false) {
+				w.notifySync()
+			} else {
+				w.notifyChange(event)
+			}
+		}
+	}
+}
+
+// collectSubtree returns the items of the subtree rooted at rootID in works,
+// root first, parents before children.
+func collectSubtree(works []work.Work, rootID string, includeChildren bool) []work.Work {
+	var items []work.Work
+	for _, item := range works {
+		if item.ID == rootID {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 || !includeChildren {
+		return items
+	}
+	ids := map[string]bool{rootID: true}
+	for i := 0; i < len(items); i++ {
+		for _, item := range works {
+			if item.ParentID == items[i].ID && !ids[item.ID] {
+				ids[item.ID] = true
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// apply updates s for event and reports what the subscriber should be
+// told: the event itself, a delete for an item that moved out of the
+// subtree, or nothing.
+func (s *workSubtree) apply(event work.ChangeEvent) (op work.Operation, notify bool) {
+	id := event.Work.ID
+	if event.Op == work.OperationDelete {
+		if !s.ids[id] {
+			return "", false
+		}
+		delete(s.ids, id)
+		return work.OperationDelete, true
+	}
+
+	inside := id == s.rootID || (s.includeChildren && event.Work.ParentID != "" && s.ids[event.Work.ParentID])
+	switch {
+	case inside:
+		s.ids[id] = true
+		return event.Op, true
+	case s.ids[id]:
+		// Re-parented elsewhere: gone from the subscriber's point of view.
+		delete(s.ids, id)
+		return work.OperationDelete, true
+	}
+	return "", false
+}
+
+func (w *WorkSubtreeWatcher) notifyChange(event work.ChangeEvent) {
+	if !w.HasSubscriptions() {
+		return
+	}
+
+	for _, sub := range w.GetAllSubscriptions() {
+		w.subtreesMu.Lock()
+		subtree, ok := w.subtrees[sub.ID]
+		var op work.Operation
+		var notify bool
+		if ok {
+			op, notify = subtree.apply(event)
+		}
+		w.subtreesMu.Unlock()
+		if !notify {
+			continue
+		}
+
+		params := workListChangedParams{ID: sub.ID, Operation: string(op)}
+		if op == work.OperationDelete {
+			params.WorkID = event.Work.ID
+		} else {
+			item := event.Work
+			params.Work = &item
+		}
+		n := Notification{Method: "work.changed", Params: params}
+		sub.Notify(w.Context(), n)
+	}
+}
+
+// notifySync rebuilds every subtree and sends it in full after dropped
+// events, when it is unknown what changed.
+func (w *WorkSubtreeWatcher) notifySync() {
+	if !w.HasSubscriptions() {
+		return
+	}
+
+	works, err := w.store.List()
+	if err != nil {
+		slog.Error("failed to list works for subtree sync", "error", err)
+		return
+	}
+
+	for _, sub := range w.GetAllSubscriptions() {
+		w.subtreesMu.Lock()
+		subtree, ok := w.subtrees[sub.ID]
+		var items []work.Work
+		if ok {
+			items = collectSubtree(works, subtree.rootID, subtree.includeChildren)
+			subtree.ids = subtreeIDs(items)
+		}
+		w.subtreesMu.Unlock()
+		if !ok {
+			continue
+		}
+
+		n := Notification{Method: "work.changed", Params: workListSyncParams{ID: sub.ID, Operation: "sync", Works: items}}
+		sub.Notify(w.Context(), n)
+	}
+
+	slog.Info("sent work subtree sync to subscribers after event drop")
+}
+
+func subtreeIDs(items []work.Work) map[string]bool {
+	ids := make(map[string]bool, len(items))
+	for _, item := range items {
+		ids[item.ID] = true
+	}
+	return ids
+}
+
+// Subscribe registers a subscriber for workID, and its descendants with
+// includeChildren, returning the current items root first.
+func (w *WorkSubtreeWatcher) Subscribe(workID string, includeChildren bool, notifier Notifier) (string, []work.Work, error) {
+	id := w.GenerateID()
+	sub := &Subscription{
+		ID:       id,
+		WorkID:   workID,
+		Notifier: notifier,
+	}
+
+	// Hold subtreesMu across the list so an event arriving meanwhile is
+	// applied to the initial set rather than lost.
+	w.subtreesMu.Lock()
+	defer w.subtreesMu.Unlock()
+
+	works, err := w.store.List()
+	if err != nil {
+		return "", nil, err
+	}
+	items := collectSubtree(works, workID, includeChildren)
+	if len(items) == 0 {
+		return "", nil, work.ErrWorkNotFound
+	}
+
+	w.subtrees[id] = &workSubtree{rootID: workID, includeChildren: includeChildren, ids: subtreeIDs(items)}
+	w.AddSubscription(sub)
+	return id, items, nil
+}
+
+// Unsubscribe overrides BaseWatcher.Unsubscribe to also drop the subtree.
+func (w *WorkSubtreeWatcher) Unsubscribe(id string) {
+	w.subtreesMu.Lock()
+	delete(w.subtrees, id)
+	w.subtreesMu.Unlock()
+
+	w.RemoveSubscription(id)
+}
+
+// OnWorkChange implements work.OnChangeListener.
+func (w *WorkSubtreeWatcher) OnWorkChange(event work.ChangeEvent) {
+	select {
+	case <-w.Context().Done():
+		return
+	case w.eventCh <- event:
+	default:
+		w.dirty.Store(true)
+		slog.Warn("work subtree change event dropped, will sync on next event", "operation", event.Op)
+	}
+}
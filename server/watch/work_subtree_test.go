@@ -0,0 +1,130 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/pockode/server/work"
+)
+
+func newSubtreeStore() *mockWorkStore {
+	return &mockWorkStore{works: []work.Work{
+		{ID: "s1", Type: work.WorkTypeStory},
+		{ID: "t1", Type: work.WorkTypeTask, ParentID: "s1"},
+		{ID: "t2", Type: work.WorkTypeTask, ParentID: "s1"},
+		{ID: "s2", Type: work.WorkTypeStory},
+		{ID: "t3", Type: work.WorkTypeTask, ParentID: "s2"},
+	}}
+}
+
+func TestWorkSubtreeWatcher_Subscribe(t *testing.T) {
+	w := NewWorkSubtreeWatcher(newSubtreeStore())
+
+	_, items, err := w.Subscribe("s1", true, &captureNotifier{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 3 || items[0].ID != "s1" {
+		t.Errorf("items = %+v, want s1 with its two tasks, root first", items)
+	}
+
+	_, items, _ = w.Subscribe("s1", false, &captureNotifier{})
+	if len(items) != 1 {
+		t.Errorf("without children got %d items, want 1", len(items))
+	}
+
+	if _, _, err := w.Subscribe("missing", true, &captureNotifier{}); !errors.Is(err, work.ErrWorkNotFound) {
+		t.Errorf("err = %v, want ErrWorkNotFound", err)
+	}
+}
+
+func TestWorkSubtreeWatcher_FiltersToSubtree(t *testing.T) {
+	store := newSubtreeStore()
+	w := NewWorkSubtreeWatcher(store)
+	w.Start()
+	defer w.Stop()
+
+	n := &captureNotifier{}
+	w.Subscribe("s1", true, n)
+
+	events := []work.ChangeEvent{
+		{Op: work.OperationUpdate, Work: work.Work{ID: "t3", ParentID: "s2"}},           // other story
+		{Op: work.OperationCreate, Work: work.Work{ID: "t4", ParentID: "s1"}},           // new child
+		{Op: work.OperationUpdate, Work: work.Work{ID: "t1", ParentID: "s1"}},           // child update
+		{Op: work.OperationUpdate, Work: work.Work{ID: "t2", ParentID: "s2"}},           // moved out
+		{Op: work.OperationDelete, Work: work.Work{ID: "t3", ParentID: "s2"}},           // other delete
+		{Op: work.OperationDelete, Work: work.Work{ID: "s1", Type: work.WorkTypeStory}}, // root delete
+	}
+	for _, e := range events {
+		w.OnWorkChange(e)
+	}
+
+	waitFor(t, func() bool { return n.count() >= 4 })
+
+	want := []struct{ op, id string }{
+		{"create", "t4"},
+		{"update", "t1"},
+		{"delete", "t2"},
+		{"delete", "s1"},
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.params) != len(want) {
+		t.Fatalf("got %d notifications, want %d", len(n.params), len(want))
+	}
+	for i, wnt := range want {
+		if n.methods[i] != "work.changed" {
+			t.Errorf("method = %q, want work.changed", n.methods[i])
+		}
+		var params workListChangedParams
+		json.Unmarshal(n.params[i], &params)
+		id := params.WorkID
+		if params.Work != nil {
+			id = params.Work.ID
+		}
+		if params.Operation != wnt.op || id != wnt.id {
+			t.Errorf("notification %d = %s %s, want %s %s", i, params.Operation, id, wnt.op, wnt.id)
+		}
+	}
+}
+
+func TestWorkSubtreeWatcher_Unsubscribe(t *testing.T) {
+	w := NewWorkSubtreeWatcher(newSubtreeStore())
+
+	id, _, _ := w.Subscribe("s1", true, &captureNotifier{})
+	w.Unsubscribe(id)
+
+	if w.HasSubscriptions() || len(w.subtrees) != 0 {
+		t.Error("expected subscription and subtree to be removed")
+	}
+}
+
+func TestWorkSubtreeWatcher_DirtyFlag_Syncs(t *testing.T) {
+	store := newSubtreeStore()
+	w := &WorkSubtreeWatcher{
+		BaseWatcher: NewBaseWatcher("wst"),
+		store:       store,
+		eventCh:     make(chan work.ChangeEvent, 1),
+		subtrees:    make(map[string]*workSubtree),
+	}
+	store.AddOnChangeListener(w)
+
+	n := &captureNotifier{}
+	w.Subscribe("s2", true, n)
+
+	// Fill the channel, then overflow to set dirty.
+	w.OnWorkChange(work.ChangeEvent{Op: work.OperationUpdate, Work: work.Work{ID: "t3", ParentID: "s2"}})
+	w.OnWorkChange(work.ChangeEvent{Op: work.OperationUpdate, Work: work.Work{ID: "t3", ParentID: "s2"}})
+
+	w.Start()
+	defer w.Stop()
+
+	waitFor(t, func() bool { return n.count() >= 1 })
+
+	var params workListSyncParams
+	json.Unmarshal(n.last(), &params)
+	if params.Operation != "sync" || len(params.Works) != 2 {
+		t.Errorf("sync = %+v, want s2 and t3", params)
+	}
+}
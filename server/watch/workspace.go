@@ -0,0 +1,235 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/git"
+)
+
+const (
+	// workspaceQuiet is how long a burst of fs and git changes must go
+	// quiet before workspace.changed is sent.
+	workspaceQuiet = 300 * time.Millisecond
+	// workspaceMaxDelay bounds the wait during a continuous stream of
+	// changes, such as an agent writing many files.
+	workspaceMaxDelay = 2 * time.Second
+)
+
+// WorkspaceWatcher combines FSWatcher and GitWatcher into one stream. It
+// subscribes to both on its subscribers' behalf, collects what changed until
+// the burst settles, then runs git status once and sends each subscriber a
+// single workspace.changed with its changed paths and, when it differs from
+// the last one sent, the new git status. Clients that would otherwise get
+// fs.changed and git.changed for every write of an agent's edit get one
+// notification instead.
+type WorkspaceWatcher struct {
+	*BaseWatcher
+	workDir string
+	fs      *FSWatcher
+	git     *GitWatcher
+	status  func(dir string) (*git.GitStatus, error)
+
+	mu         sync.Mutex
+	inner      map[string][]innerSub          // subscription ID → its fs and git subscriptions
+	pending    map[string]map[string]struct{} // subscription ID → changed paths
+	first      time.Time                      // first change of the pending burst
+	timer      *time.Timer
+	lastStatus []byte // JSON of the git status last sent
+}
+
+type innerSub struct {
+	id      string
+	watcher Watcher
+}
+
+func NewWorkspaceWatcher(workDir string, fs *FSWatcher, gw *GitWatcher) *WorkspaceWatcher {
+	return &WorkspaceWatcher{
+		BaseWatcher: NewBaseWatcher("ws"),
+		workDir:     workDir,
+		fs:          fs,
+		git:         gw,
+		status:      git.Status,
+		inner:       make(map[string][]innerSub),
+		pending:     make(map[string]map[string]struct{}),
+	}
+}
+
+// Start is a no-op: changes arrive through the FSWatcher and GitWatcher,
+// which must be started first.
+func (w *WorkspaceWatcher) Start() error {
+	return nil
+}
+
+func (w *WorkspaceWatcher) Stop() {
+	w.Cancel()
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	w.mu.Unlock()
+}
+
+// Subscribe watches paths (as fs.subscribe does) and the worktree's git
+// state, and returns the current git status as the starting snapshot. The
+// status is nil when git status fails, e.g. outside a repository.
+func (w *WorkspaceWatcher) Subscribe(paths []string, notifier Notifier) (string, *git.GitStatus, error) {
+	status, err := w.status(w.workDir)
+	if err != nil {
+		slog.Warn("workspace watcher: git status failed", "workDir", w.workDir, "error", err)
+		status = nil
+	}
+
+	id := w.GenerateID()
+	var inner []innerSub
+	for _, path := range slices.Compact(slices.Sorted(slices.Values(paths))) {
+		fsID, err := w.fs.Subscribe(path, &workspaceFSSink{w: w, id: id, path: path})
+		if err != nil {
+			w.unsubscribeInner(inner)
+			return "", nil, err
+		}
+		inner = append(inner, innerSub{id: fsID, watcher: w.fs})
+	}
+	gitID := w.git.Subscribe(workspaceGitSink{w: w})
+	inner = append(inner, innerSub{id: gitID, watcher: w.git})
+
+	w.mu.Lock()
+	w.inner[id] = inner
+	if w.lastStatus == nil && status != nil {
+		w.lastStatus, _ = json.Marshal(status)
+	}
+	w.mu.Unlock()
+
+	w.AddSubscription(&Subscription{ID: id, Notifier: notifier})
+	return id, status, nil
+}
+
+func (w *WorkspaceWatcher) Unsubscribe(id string) {
+	w.mu.Lock()
+	inner := w.inner[id]
+	delete(w.inner, id)
+	delete(w.pending, id)
+	w.mu.Unlock()
+
+	w.unsubscribeInner(inner)
+	w.RemoveSubscription(id)
+}
+
+func (w *WorkspaceWatcher) unsubscribeInner(inner []innerSub) {
+	for _, s := range inner {
+		s.watcher.Unsubscribe(s.id)
+	}
+}
+
+// workspaceFSSink receives fs.changed for one path of a subscription.
+type workspaceFSSink struct {
+	w    *WorkspaceWatcher
+	id   string
+	path string
+}
+
+func (s *workspaceFSSink) Notify(_ context.Context, n Notification) error {
+	if n.Method != "fs.changed" {
+		return nil
+	}
+	changed := s.path
+	if params, ok := n.Params.(map[string]any); ok {
+		if p, ok := params["path"].(string); ok {
+			changed = p
+		}
+	}
+	s.w.changed(s.id, changed)
+	return nil
+}
+
+// workspaceGitSink receives git.changed.
+type workspaceGitSink struct {
+	w *WorkspaceWatcher
+}
+
+func (s workspaceGitSink) Notify(_ context.Context, n Notification) error {
+	if n.Method == "git.changed" {
+		s.w.changed("", "")
+	}
+	return nil
+}
+
+// changed records a change of path for subscription id, or a git change
+// when id is empty, and (re)arms the flush timer.
+func (w *WorkspaceWatcher) changed(id, path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Context().Err() != nil {
+		return
+	}
+	if id != "" {
+		if _, ok := w.inner[id]; !ok {
+			return
+		}
+		if w.pending[id] == nil {
+			w.pending[id] = make(map[string]struct{})
+		}
+		w.pending[id][path] = struct{}{}
+	}
+
+	now := time.Now()
+	if w.timer == nil {
+		w.first = now
+		w.timer = time.AfterFunc(workspaceQuiet, w.flush)
+		return
+	}
+	if now.Sub(w.first)+workspaceQuiet < workspaceMaxDelay {
+		w.timer.Reset(workspaceQuiet)
+	}
+}
+
+// flush sends the pending burst. Any fs change may have changed git status,
+// so it is always recomputed, once for all subscribers.
+func (w *WorkspaceWatcher) flush() {
+	w.mu.Lock()
+	w.timer = nil
+	pending := w.pending
+	w.pending = make(map[string]map[string]struct{})
+	w.mu.Unlock()
+
+	if w.Context().Err() != nil {
+		return
+	}
+
+	var status *git.GitStatus
+	if s, err := w.status(w.workDir); err != nil {
+		slog.Warn("workspace watcher: git status failed", "workDir", w.workDir, "error", err)
+	} else if data, err := json.Marshal(s); err == nil {
+		w.mu.Lock()
+		if !bytes.Equal(data, w.lastStatus) {
+			w.lastStatus = data
+			status = s
+		}
+		w.mu.Unlock()
+	}
+
+	var notified int
+	for _, sub := range w.GetAllSubscriptions() {
+		paths := make([]string, 0, len(pending[sub.ID]))
+		for p := range pending[sub.ID] {
+			paths = append(paths, p)
+		}
+		if len(paths) == 0 && status == nil {
+			continue
+		}
+		slices.Sort(paths)
+		params := map[string]any{"id": sub.ID, "paths": paths}
+		if status != nil {
+			params["git"] = status
+		}
+		sub.Notify(w.Context(), Notification{Method: "workspace.changed", Params: params})
+		notified++
+	}
+	slog.Debug("notified workspace change", "subscribers", notified, "gitChanged", status != nil)
+}
@@ -0,0 +1,154 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/git"
+)
+
+type workspaceChange struct {
+	ID    string         `json:"id"`
+	Paths []string       `json:"paths"`
+	Git   *git.GitStatus `json:"git"`
+}
+
+func newTestWorkspaceWatcher(t *testing.T) (*WorkspaceWatcher, string, func(staged int)) {
+	t.Helper()
+	dir := t.TempDir()
+	fs := NewFSWatcher(dir)
+	if err := fs.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fs.Stop)
+	gw := NewGitWatcher(dir)
+	w := NewWorkspaceWatcher(dir, fs, gw)
+	t.Cleanup(w.Stop)
+
+	var mu sync.Mutex
+	staged := 0
+	w.status = func(string) (*git.GitStatus, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		s := &git.GitStatus{Staged: []git.FileStatus{}, Unstaged: []git.FileStatus{}}
+		for i := range staged {
+			s.Staged = append(s.Staged, git.FileStatus{Path: fmt.Sprintf("f%d", i), Status: "M"})
+		}
+		return s, nil
+	}
+	setStaged := func(n int) {
+		mu.Lock()
+		staged = n
+		mu.Unlock()
+	}
+	return w, dir, setStaged
+}
+
+func waitChanges(t *testing.T, n *captureNotifier, want int) []workspaceChange {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for n.count() < want && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Anything extra would arrive after another quiet period.
+	time.Sleep(workspaceQuiet + 100*time.Millisecond)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var changes []workspaceChange
+	for i, p := range n.params {
+		if n.methods[i] != "workspace.changed" {
+			t.Fatalf("method = %q, want workspace.changed", n.methods[i])
+		}
+		var c workspaceChange
+		if err := json.Unmarshal(p, &c); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+func TestWorkspaceWatcher_CoalescesBurst(t *testing.T) {
+	w, dir, setStaged := newTestWorkspaceWatcher(t)
+	n := &captureNotifier{}
+	id, status, err := w.Subscribe([]string{"", ""}, n)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if status == nil || len(status.Staged) != 0 {
+		t.Fatalf("initial status = %+v", status)
+	}
+
+	setStaged(1)
+	for i := range 5 {
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := waitChanges(t, n, 1)
+	if len(changes) != 1 {
+		t.Fatalf("got %d notifications, want 1: %+v", len(changes), changes)
+	}
+	c := changes[0]
+	if c.ID != id || fmt.Sprint(c.Paths) != "[a.txt b.txt]" {
+		t.Errorf("change = %+v, want paths [a.txt b.txt]", c)
+	}
+	if c.Git == nil || len(c.Git.Staged) != 1 {
+		t.Errorf("git = %+v, want the new status", c.Git)
+	}
+}
+
+func TestWorkspaceWatcher_GitOnlyWhenStatusChanges(t *testing.T) {
+	w, _, setStaged := newTestWorkspaceWatcher(t)
+	n := &captureNotifier{}
+	if _, _, err := w.Subscribe(nil, n); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	sink := workspaceGitSink{w: w}
+
+	// A git change the status does not reflect (already sent) is dropped.
+	sink.Notify(context.Background(), Notification{Method: "git.changed"})
+	if changes := waitChanges(t, n, 0); len(changes) != 0 {
+		t.Fatalf("unchanged status sent %+v", changes)
+	}
+
+	setStaged(2)
+	sink.Notify(context.Background(), Notification{Method: "git.changed"})
+	changes := waitChanges(t, n, 1)
+	if len(changes) != 1 || len(changes[0].Paths) != 0 || changes[0].Git == nil || len(changes[0].Git.Staged) != 2 {
+		t.Fatalf("changes = %+v, want one git-only change", changes)
+	}
+}
+
+func TestWorkspaceWatcher_UnsubscribeReleasesInner(t *testing.T) {
+	w, _, _ := newTestWorkspaceWatcher(t)
+	id, _, err := w.Subscribe([]string{""}, &captureNotifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.fs.HasSubscriptions() || !w.git.HasSubscriptions() {
+		t.Fatal("inner fs and git subscriptions missing")
+	}
+	w.Unsubscribe(id)
+	if w.fs.HasSubscriptions() || w.git.HasSubscriptions() || w.HasSubscriptions() {
+		t.Error("subscriptions left after Unsubscribe")
+	}
+
+	if _, _, err := w.Subscribe([]string{"missing"}, &captureNotifier{}); err == nil {
+		t.Error("Subscribe accepted a missing path")
+	}
+	if w.git.HasSubscriptions() {
+		t.Error("failed Subscribe left a git subscription")
+	}
+}
@@ -0,0 +1,82 @@
+package watch
+
+import (
+	"log/slog"
+
+	"github.com/pockode/server/setup"
+)
+
+// WorktreeSetupWatcher notifies subscribers of worktree setup progress:
+// every step transition of the setup that runs after worktree.create.
+type WorktreeSetupWatcher struct {
+	*BaseWatcher
+	runner  *setup.Runner
+	eventCh chan setup.Status
+}
+
+func NewWorktreeSetupWatcher(runner *setup.Runner) *WorktreeSetupWatcher {
+	w := &WorktreeSetupWatcher{
+		BaseWatcher: NewBaseWatcher("wts"),
+		runner:      runner,
+		eventCh:     make(chan setup.Status, 64),
+	}
+	runner.AddOnChangeListener(w)
+	return w
+}
+
+func (w *WorktreeSetupWatcher) Start() error {
+	go w.eventLoop()
+	slog.Info("WorktreeSetupWatcher started")
+	return nil
+}
+
+func (w *WorktreeSetupWatcher) Stop() {
+	w.Cancel()
+	slog.Info("WorktreeSetupWatcher stopped")
+}
+
+func (w *WorktreeSetupWatcher) eventLoop() {
+	for {
+		select {
+		case <-w.Context().Done():
+			return
+		case status := <-w.eventCh:
+			w.notifyChange(status)
+		}
+	}
+}
+
+func (w *WorktreeSetupWatcher) notifyChange(status setup.Status) {
+	if !w.HasSubscriptions() {
+		return
+	}
+
+	w.NotifyAll("worktree.setup.status.changed", func(sub *Subscription) any {
+		return worktreeSetupChangedParams{ID: sub.ID, Status: status}
+	})
+
+	slog.Debug("notified worktree setup change", "worktree", status.Worktree, "state", status.State)
+}
+
+// Subscribe registers a subscriber and returns the status of every setup
+// since server start, finished ones included.
+func (w *WorktreeSetupWatcher) Subscribe(notifier Notifier) (string, []setup.Status) {
+	id := w.GenerateID()
+	w.AddSubscription(&Subscription{ID: id, Notifier: notifier})
+	return id, w.runner.List()
+}
+
+type worktreeSetupChangedParams struct {
+	ID     string       `json:"id"`
+	Status setup.Status `json:"status"`
+}
+
+// OnWorktreeSetupChange implements setup.OnChangeListener.
+// It blocks rather than drops: only the reporting setup goroutine waits,
+// and a lost final status would leave clients showing "running".
+func (w *WorktreeSetupWatcher) OnWorktreeSetupChange(status setup.Status) {
+	select {
+	case <-w.Context().Done():
+	case w.eventCh <- status:
+	}
+}
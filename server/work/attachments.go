@@ -0,0 +1,244 @@
+package work
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxAttachmentSize bounds one file. Design docs and screenshots fit
+	// comfortably, and base64 over WebSocket stays under the message limit.
+	MaxAttachmentSize = 5 << 20
+	// MaxAttachments and MaxAttachmentBytes bound one work item.
+	MaxAttachments     = 20
+	MaxAttachmentBytes = 25 << 20
+
+	maxAttachmentName = 128
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+)
+
+// Attachment describes a file attached to a work item.
+type Attachment struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// AttachmentStore keeps work item attachments as plain files under
+// works/<work ID>/attachments/, so an agent can open them by path. It does
+// not check that the work item exists; callers do, with the work store.
+type AttachmentStore struct {
+	dir string
+
+	// Serializes saves so the per-work limits hold under concurrent uploads.
+	saveMu sync.Mutex
+}
+
+func NewAttachmentStore(dataDir string) *AttachmentStore {
+	return &AttachmentStore{dir: filepath.Join(dataDir, "works")}
+}
+
+func (s *AttachmentStore) workDir(workID string) (string, error) {
+	// Work IDs are UUIDs; refuse anything that could leave the works dir.
+	if workID == "" || workID != filepath.Base(workID) || workID == "." || workID == ".." {
+		return "", fmt.Errorf("%w: invalid work id %q", ErrInvalidAttachment, workID)
+	}
+	return filepath.Join(s.dir, workID, "attachments"), nil
+}
+
+// validateAttachmentName accepts a plain file name: no directories, no
+// leading dot, no control characters.
+func validateAttachmentName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidAttachment)
+	case utf8.RuneCountInString(name) > maxAttachmentName:
+		return fmt.Errorf("%w: name exceeds %d characters", ErrInvalidAttachment, maxAttachmentName)
+	case strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "."):
+		return fmt.Errorf("%w: name %q must be a plain file name", ErrInvalidAttachment, name)
+	case strings.ContainsFunc(name, unicode.IsControl):
+		return fmt.Errorf("%w: name %q contains control characters", ErrInvalidAttachment, name)
+	}
+	return nil
+}
+
+// Path returns the attachment's file path, or ErrAttachmentNotFound.
+func (s *AttachmentStore) Path(workID, name string) (string, error) {
+	dir, err := s.workDir(workID)
+	if err != nil {
+		return "", err
+	}
+	if err := validateAttachmentName(name); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrAttachmentNotFound, name)
+	} else if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// List returns the work item's attachments sorted by name; none is an empty
+// list.
+func (s *AttachmentStore) List(workID string) ([]Attachment, error) {
+	dir, err := s.workDir(workID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Attachment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Attachment, 0, len(entries))
+	for _, e := range entries {
+		// Skip in-flight temp files and anything not written by Save.
+		if !e.Type().IsRegular() || validateAttachmentName(e.Name()) != nil {
+			continue
+		}
+		info, err := e.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, attachmentFromInfo(info))
+	}
+	slices.SortFunc(list, func(a, b Attachment) int { return strings.Compare(a.Name, b.Name) })
+	return list, nil
+}
+
+// Read returns the attachment and its content.
+func (s *AttachmentStore) Read(workID, name string) (Attachment, []byte, error) {
+	path, err := s.Path(workID, name)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	return attachmentFromInfo(info), data, nil
+}
+
+// Save writes data as name, replacing an attachment of the same name. It
+// enforces MaxAttachmentSize, MaxAttachments and MaxAttachmentBytes; errors
+// from validation wrap ErrInvalidAttachment.
+func (s *AttachmentStore) Save(workID, name string, data []byte) (Attachment, error) {
+	dir, err := s.workDir(workID)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if err := validateAttachmentName(name); err != nil {
+		return Attachment{}, err
+	}
+	if len(data) > MaxAttachmentSize {
+		return Attachment{}, fmt.Errorf("%w: %s exceeds %d MiB", ErrInvalidAttachment, name, MaxAttachmentSize>>20)
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	existing, err := s.List(workID)
+	if err != nil {
+		return Attachment{}, err
+	}
+	count, total := 0, int64(len(data))
+	for _, a := range existing {
+		if a.Name != name {
+			count++
+			total += a.Size
+		}
+	}
+	if count >= MaxAttachments {
+		return Attachment{}, fmt.Errorf("%w: at most %d attachments per work item", ErrInvalidAttachment, MaxAttachments)
+	}
+	if total > MaxAttachmentBytes {
+		return Attachment{}, fmt.Errorf("%w: attachments of one work item exceed %d MiB", ErrInvalidAttachment, MaxAttachmentBytes>>20)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Attachment{}, err
+	}
+	path := filepath.Join(dir, name)
+	// The leading dot keeps List from showing a half-written file.
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return Attachment{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return Attachment{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return attachmentFromInfo(info), nil
+}
+
+// Delete removes one attachment.
+func (s *AttachmentStore) Delete(workID, name string) error {
+	path, err := s.Path(workID, name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// OnWorkChange removes a deleted work item's attachments.
+func (s *AttachmentStore) OnWorkChange(event ChangeEvent) {
+	if event.Op != OperationDelete {
+		return
+	}
+	dir, err := s.workDir(event.Work.ID)
+	if err != nil {
+		return
+	}
+	// works/<id> holds nothing else; drop it too so no empty dirs pile up.
+	if err := os.RemoveAll(filepath.Dir(dir)); err != nil {
+		slog.Warn("failed to remove work attachments", "workId", event.Work.ID, "error", err)
+	}
+}
+
+func attachmentFromInfo(info fs.FileInfo) Attachment {
+	return Attachment{
+		Name:        info.Name(),
+		Size:        info.Size(),
+		ContentType: AttachmentContentType(info.Name()),
+		UploadedAt:  info.ModTime(),
+	}
+}
+
+// AttachmentContentType guesses the MIME type from the file extension.
+func AttachmentContentType(name string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,102 @@
+package work
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// AttachmentPath is the HTTP route for uploading (PUT, raw body) and
+// downloading (GET) one attachment. It sits behind the user auth middleware.
+const AttachmentPath = "/api/works/{id}/attachments/{name}"
+
+// AttachmentHandler serves AttachmentPath. Large files go here rather than
+// through work.attachment.upload, which has to base64 them into one
+// WebSocket message.
+type AttachmentHandler struct {
+	store       Store
+	attachments *AttachmentStore
+}
+
+func NewAttachmentHandler(store Store, attachments *AttachmentStore) *AttachmentHandler {
+	return &AttachmentHandler{store: store, attachments: attachments}
+}
+
+func (h *AttachmentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wk, found, err := h.store.Get(r.PathValue("id"))
+	if err != nil {
+		slog.Error("failed to get work for attachment", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, ErrWorkNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		h.serveDownload(w, r, wk.ID, name)
+	case http.MethodPut:
+		h.serveUpload(w, r, wk.ID, name)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *AttachmentHandler) serveDownload(w http.ResponseWriter, r *http.Request, workID, name string) {
+	a, data, err := h.attachments.Read(workID, name)
+	if err != nil {
+		writeAttachmentError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	// Never render an uploaded HTML or SVG file as part of the app.
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := w.Write(data); err != nil {
+		slog.Debug("failed to write attachment", "workId", workID, "name", name, "error", err)
+	}
+}
+
+func (h *AttachmentHandler) serveUpload(w http.ResponseWriter, r *http.Request, workID, name string) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxAttachmentSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "attachment too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	a, err := h.attachments.Save(workID, name, data)
+	if err != nil {
+		writeAttachmentError(w, err)
+		return
+	}
+	slog.Info("work attachment uploaded", "workId", workID, "name", name, "size", a.Size)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a); err != nil {
+		slog.Debug("failed to write attachment response", "error", err)
+	}
+}
+
+func writeAttachmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAttachmentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidAttachment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		slog.Error("work attachment failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
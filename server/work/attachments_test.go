@@ -0,0 +1,149 @@
+package work
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttachmentStore(t *testing.T) {
+	s := NewAttachmentStore(t.TempDir())
+
+	list, err := s.List("w1")
+	if err != nil || list == nil || len(list) != 0 {
+		t.Fatalf("List before save = %#v, %v; want empty list", list, err)
+	}
+
+	a, err := s.Save("w1", "design.md", []byte("# Design"))
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if a.Name != "design.md" || a.Size != 8 || !strings.HasPrefix(a.ContentType, "text/markdown") {
+		t.Errorf("Save = %+v", a)
+	}
+	if _, err := s.Save("w1", "shot.png", []byte("\x89PNG")); err != nil {
+		t.Fatalf("Save png: %v", err)
+	}
+	// Replacing keeps one entry with the new content.
+	if _, err := s.Save("w1", "design.md", []byte("# Design v2")); err != nil {
+		t.Fatalf("Save replace: %v", err)
+	}
+
+	list, err = s.List("w1")
+	if err != nil || len(list) != 2 || list[0].Name != "design.md" || list[1].Name != "shot.png" || list[1].ContentType != "image/png" {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	_, data, err := s.Read("w1", "design.md")
+	if err != nil || string(data) != "# Design v2" {
+		t.Errorf("Read = %q, %v", data, err)
+	}
+
+	if err := s.Delete("w1", "shot.png"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := s.Read("w1", "shot.png"); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("Read deleted: err = %v, want ErrAttachmentNotFound", err)
+	}
+}
+
+func TestAttachmentStore_Invalid(t *testing.T) {
+	s := NewAttachmentStore(t.TempDir())
+
+	for _, name := range []string{"", "../escape", "dir/file", ".hidden", "a\nb", strings.Repeat("a", 129)} {
+		if _, err := s.Save("w1", name, []byte("x")); !errors.Is(err, ErrInvalidAttachment) {
+			t.Errorf("name %q: err = %v, want ErrInvalidAttachment", name, err)
+		}
+	}
+	if _, err := s.Save("..", "a.txt", []byte("x")); !errors.Is(err, ErrInvalidAttachment) {
+		t.Errorf("work id ..: err = %v, want ErrInvalidAttachment", err)
+	}
+	if _, err := s.Save("w1", "big.bin", make([]byte, MaxAttachmentSize+1)); !errors.Is(err, ErrInvalidAttachment) {
+		t.Errorf("oversized: err = %v, want ErrInvalidAttachment", err)
+	}
+}
+
+func TestAttachmentStore_Limits(t *testing.T) {
+	s := NewAttachmentStore(t.TempDir())
+
+	for i := range MaxAttachments {
+		if _, err := s.Save("w1", string(rune('a'+i))+".txt", []byte("x")); err != nil {
+			t.Fatalf("Save %d: %v", i, err)
+		}
+	}
+	if _, err := s.Save("w1", "one-more.txt", []byte("x")); !errors.Is(err, ErrInvalidAttachment) {
+		t.Errorf("over count: err = %v, want ErrInvalidAttachment", err)
+	}
+	// Replacing an existing file does not count as another attachment.
+	if _, err := s.Save("w1", "a.txt", []byte("y")); err != nil {
+		t.Errorf("replace at limit: %v", err)
+	}
+
+	big := make([]byte, MaxAttachmentSize)
+	for i := range MaxAttachmentBytes / MaxAttachmentSize {
+		if _, err := s.Save("w2", string(rune('a'+i))+".bin", big); err != nil {
+			t.Fatalf("Save big %d: %v", i, err)
+		}
+	}
+	if _, err := s.Save("w2", "z.bin", []byte("x")); !errors.Is(err, ErrInvalidAttachment) {
+		t.Errorf("over total: err = %v, want ErrInvalidAttachment", err)
+	}
+}
+
+func TestAttachmentStore_RemovedWithWork(t *testing.T) {
+	dataDir := t.TempDir()
+	s := NewAttachmentStore(dataDir)
+	if _, err := s.Save("w1", "spec.txt", []byte("spec")); err != nil {
+		t.Fatal(err)
+	}
+
+	s.OnWorkChange(ChangeEvent{Op: OperationUpdate, Work: Work{ID: "w1"}})
+	if list, _ := s.List("w1"); len(list) != 1 {
+		t.Fatal("update should keep attachments")
+	}
+	s.OnWorkChange(ChangeEvent{Op: OperationDelete, Work: Work{ID: "w1"}})
+	if _, err := os.Stat(filepath.Join(dataDir, "works", "w1")); !os.IsNotExist(err) {
+		t.Errorf("work dir still exists after delete: %v", err)
+	}
+}
+
+func TestAttachmentHandler(t *testing.T) {
+	store := newTestStore(t)
+	w := createStory(t, store, "Story")
+	h := NewAttachmentHandler(store, NewAttachmentStore(t.TempDir()))
+	mux := http.NewServeMux()
+	mux.Handle("GET "+AttachmentPath, h)
+	mux.Handle("PUT "+AttachmentPath, h)
+
+	do := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewReader(body)))
+		return rec
+	}
+
+	// Short IDs resolve like everywhere else.
+	if rec := do("PUT", "/api/works/"+w.ShortID+"/attachments/spec.md", []byte("# Spec")); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"spec.md"`) {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body)
+	}
+	rec := do("GET", "/api/works/"+w.ID+"/attachments/spec.md", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "# Spec" || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("GET = %d %q %v", rec.Code, rec.Body, rec.Header())
+	}
+
+	if rec := do("GET", "/api/works/"+w.ID+"/attachments/missing.md", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET missing = %d, want 404", rec.Code)
+	}
+	if rec := do("PUT", "/api/works/nope/attachments/a.txt", []byte("x")); rec.Code != http.StatusNotFound {
+		t.Errorf("PUT unknown work = %d, want 404", rec.Code)
+	}
+	if rec := do("PUT", "/api/works/"+w.ID+"/attachments/.env", []byte("x")); rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT dotfile = %d, want 400", rec.Code)
+	}
+	if rec := do("PUT", "/api/works/"+w.ID+"/attachments/big.bin", make([]byte, MaxAttachmentSize+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("PUT oversized = %d, want 413", rec.Code)
+	}
+}
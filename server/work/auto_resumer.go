@@ -3,15 +3,18 @@ package work
 import (
 	"context"
 	"log/slog"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/pockode/server/i18n"
 )
 
-// MessageSender sends messages to agent sessions.
+// MessageSender sends system messages to agent sessions.
 // Satisfied by *chat.Client.
 type MessageSender interface {
-	SendMessage(ctx context.Context, sessionID, content string) error
+	SendSystemMessage(ctx context.Context, sessionID, content string) error
 }
 
 // StepProvider provides step information for agent roles.
@@ -32,8 +35,16 @@ type StepProvider interface {
 // stopped) receive the message without state change. Open and closed parents
 // are skipped.
 //
+// Startup reconciliation: ReconcileOrphanedWork stops, resumes, or resets work
+// left in_progress by a previous server run.
+//
 // Step advance / reopen follow-ups: NotifyStepDone and NotifyReopen send the
 // next-step and reopen prompts after the MCP API mutates a work item in-process.
+//
+// Autorun limits: with an AutorunGate set, messages the gate refuses are held
+// back per session and sent together once it resumes. With AutorunSlots set,
+// messages to a session whose role has no free slot are held back the same
+// way until a session of that role stops running.
 type AutoResumer struct {
 	workStore    Store
 	sender       atomic.Pointer[MessageSender]
@@ -41,10 +52,21 @@ type AutoResumer struct {
 	ctx          context.Context
 	cancel       context.CancelFunc
 	retryMu      sync.Mutex
-	retries      map[string]int  // sessionID → retry count
-	continuing   map[string]bool // sessionID → auto-continuation pending
+	retries      map[string]int          // sessionID → retry count
+	continuing   map[string]bool         // sessionID → auto-continuation pending
+	agentErrors  map[string]string       // sessionID → classified error that ended the last turn
+	backoffs     map[string]int          // sessionID → consecutive rate-limited/unreachable turns
+	snapshots    map[string]TurnSnapshot // sessionID → snapshot at the last auto-continuation
+	stalled      map[string]bool         // sessionID → no-progress nudge sent
+	probe        ProgressProbe
 	maxRetries   int
 	settleDelay  time.Duration // delay before checking work status after process stop
+	backoffBase  time.Duration // first delay after a rate-limited turn, doubled per repeat
+	gate         *AutorunGate
+	slots        *AutorunSlots
+	locale       func() i18n.Locale
+	deferredMu   sync.Mutex
+	deferred     map[string][]string // sessionID → messages held back by the gate or slots
 }
 
 // defaultSettleDelay is the time to wait after a process goes idle/ends before
@@ -54,6 +76,14 @@ type AutoResumer struct {
 // the retry count, keeping the stop-after-N accounting correct. 2s is generous.
 const defaultSettleDelay = 2 * time.Second
 
+// defaultBackoffBase and maxBackoff bound the wait before continuing work
+// whose last turn hit a rate limit or could not reach the API. Such turns
+// fail fast, so continuing at once would only burn the retry budget.
+const (
+	defaultBackoffBase = 30 * time.Second
+	maxBackoff         = 15 * time.Minute
+)
+
 func NewAutoResumer(workStore Store, maxRetries int) *AutoResumer {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &AutoResumer{
@@ -62,8 +92,14 @@ func NewAutoResumer(workStore Store, maxRetries int) *AutoResumer {
 		cancel:      cancel,
 		retries:     make(map[string]int),
 		continuing:  make(map[string]bool),
+		agentErrors: make(map[string]string),
+		backoffs:    make(map[string]int),
+		snapshots:   make(map[string]TurnSnapshot),
+		stalled:     make(map[string]bool),
+		deferred:    make(map[string][]string),
 		maxRetries:  maxRetries,
 		settleDelay: defaultSettleDelay,
+		backoffBase: defaultBackoffBase,
 	}
 }
 
@@ -72,10 +108,26 @@ func (r *AutoResumer) Stop() {
 	r.cancel()
 }
 
-// StopOrphanedWork transitions all in_progress, needs_input, and waiting work items to stopped.
-// Call this at server startup before any sessions are created, so that work
-// items left running from a previous server run are properly marked.
+// StopOrphanedWork transitions all in_progress, needs_input, and waiting work
+// items to stopped. Equivalent to ReconcileOrphanedWork with OrphanPolicyStop
+// when no session has a live process.
 func (r *AutoResumer) StopOrphanedWork() {
+	r.ReconcileOrphanedWork(OrphanPolicyStop, nil, nil)
+}
+
+// ReconcileOrphanedWork handles work a previous server run left active
+// (trigger D): no process state change will ever fire for those sessions, so
+// without this pass they would stay in_progress forever. Call it once at
+// startup, after the worktree manager is running and before clients connect.
+//
+// Work whose session still has a live process (per live, which may be nil) is
+// left to the process lifecycle triggers. needs_input and waiting work is
+// stopped. in_progress work follows policy:
+//   - stop (default): transition to stopped for a manual restart.
+//   - resume: stop, then restart via restarter so the agent resumes its
+//     history. A failed restart leaves the work stopped.
+//   - reset: roll back to open and clear the session; the next start is fresh.
+func (r *AutoResumer) ReconcileOrphanedWork(policy OrphanPolicy, live ProcessChecker, restarter WorkRestarter) {
 	works, err := r.workStore.List()
 	if err != nil {
 		slog.Warn("failed to list works for orphan detection", "error", err)
@@ -86,14 +138,76 @@ func (r *AutoResumer) StopOrphanedWork() {
 		if w.Status != StatusInProgress && w.Status != StatusNeedsInput && w.Status != StatusWaiting {
 			continue
 		}
+		if live != nil && w.SessionID != "" && live.HasProcess(w.SessionID) {
+			continue
+		}
+
+		if w.Status == StatusInProgress && policy == OrphanPolicyReset {
+			if err := r.workStore.RollbackStart(r.ctx, w.ID, false); err != nil {
+				slog.Warn("failed to reset orphaned work", "workId", w.ID, "error", err)
+			} else {
+				slog.Info("reset orphaned work to open on startup", "workId", w.ID, "sessionId", w.SessionID)
+			}
+			continue
+		}
+
 		if err := r.stopWork(w.ID); err != nil {
 			slog.Warn("failed to stop orphaned work", "workId", w.ID, "error", err)
-		} else {
-			slog.Info("stopped orphaned work on startup", "workId", w.ID, "sessionId", w.SessionID)
+			continue
+		}
+		slog.Info("stopped orphaned work on startup", "workId", w.ID, "sessionId", w.SessionID)
+
+		if w.Status == StatusInProgress && policy == OrphanPolicyResume && restarter != nil {
+			if reason := r.admit(w.SessionID); reason != "" {
+				slog.Info("autorun held back, orphaned work left stopped", "workId", w.ID, "sessionId", w.SessionID, "reason", reason)
+				continue
+			}
+			if _, err := restarter.StartWork(r.ctx, w.ID, StartOptions{}); err != nil {
+				r.releaseSlot(w.SessionID)
+				slog.Warn("failed to resume orphaned work", "workId", w.ID, "error", err)
+			} else {
+				slog.Info("resumed orphaned work on startup", "workId", w.ID, "sessionId", w.SessionID)
+			}
 		}
 	}
 }
 
+// SetAutorunGate limits the messages this resumer sends on its own to the
+// gate's schedule and budget. Call before processes start.
+func (r *AutoResumer) SetAutorunGate(g *AutorunGate) {
+	r.gate = g
+	g.AddOnChangeListener(r)
+}
+
+// SetProgressProbe enables the progress check: an auto-continuation after a
+// turn that made no progress is replaced by a nudge to call step_done or
+// work_needs_input, and a second such turn in a row stops the work.
+func (r *AutoResumer) SetProgressProbe(p ProgressProbe) {
+	r.probe = p
+}
+
+// SetAutorunSlots limits the messages this resumer sends on its own to the
+// per-role concurrency caps, and feeds the slots process state changes.
+// Call before processes start.
+func (r *AutoResumer) SetAutorunSlots(s *AutorunSlots) {
+	r.slots = s
+	s.AddOnSlotFreedListener(r)
+}
+
+// SetLocale sets the language of the prompts this resumer sends, read on
+// every send so settings changes apply to the next one. Call before
+// processes start; without it prompts are English.
+func (r *AutoResumer) SetLocale(fn func() i18n.Locale) {
+	r.locale = fn
+}
+
+func (r *AutoResumer) getLocale() i18n.Locale {
+	if r.locale == nil {
+		return i18n.Default
+	}
+	return r.locale()
+}
+
 // SetSender sets the message sender. Called when the main worktree is initialized.
 func (r *AutoResumer) SetSender(sender MessageSender) {
 	r.sender.Store(&sender)
@@ -126,6 +240,10 @@ func (r *AutoResumer) getStepProvider() StepProvider {
 //
 // Parameters are extracted from process.StateChangeEvent to avoid importing the process package.
 func (r *AutoResumer) HandleProcessStateChange(sessionID, state string, needsInput, isInitial, interrupted bool) {
+	if r.slots != nil {
+		r.slots.HandleProcessState(sessionID, state, isInitial)
+	}
+
 	// Process ended: transition in_progress work to stopped,
 	// but only if auto-continuation isn't already handling this session.
 	if state == "ended" {
@@ -142,6 +260,9 @@ func (r *AutoResumer) HandleProcessStateChange(sessionID, state string, needsInp
 	// This covers the case where a user sends a message to a session
 	// whose work was stopped (e.g. after process exit), bypassing work_start.
 	if state == "running" {
+		r.retryMu.Lock()
+		delete(r.agentErrors, sessionID)
+		r.retryMu.Unlock()
 		r.handleProcessRunning(sessionID)
 		return
 	}
@@ -170,6 +291,18 @@ func (r *AutoResumer) HandleProcessStateChange(sessionID, state string, needsInp
 	go r.handleAutoContinuation(sessionID, sender)
 }
 
+// HandleAgentError records the classified error (agent.ErrorCode*) that
+// ended a session's turn. Call it before the idle HandleProcessStateChange
+// of that turn, which then reacts to it:
+//   - "rate_limit", "network" → continue only after an exponential backoff,
+//     without counting a retry.
+//   - "auth" → stop the work: retrying cannot succeed until the user logs in.
+func (r *AutoResumer) HandleAgentError(sessionID, code string) {
+	r.retryMu.Lock()
+	r.agentErrors[sessionID] = code
+	r.retryMu.Unlock()
+}
+
 // handleProcessEnded transitions in_progress/needs_input/waiting work to stopped when its process terminates.
 // This catches cases like user interrupt or unexpected process exit.
 func (r *AutoResumer) handleProcessEnded(sessionID string) {
@@ -196,6 +329,10 @@ func (r *AutoResumer) handleProcessEnded(sessionID string) {
 	// Clean up retry tracking
 	r.retryMu.Lock()
 	delete(r.retries, sessionID)
+	delete(r.agentErrors, sessionID)
+	delete(r.backoffs, sessionID)
+	delete(r.snapshots, sessionID)
+	delete(r.stalled, sessionID)
 	r.retryMu.Unlock()
 }
 
@@ -243,10 +380,40 @@ func (r *AutoResumer) handleAutoContinuation(sessionID string, sender MessageSen
 		return
 	}
 
+	r.retryMu.Lock()
+	agentErr := r.agentErrors[sessionID]
+	delete(r.agentErrors, sessionID)
+	backoffs := r.backoffs[sessionID]
+	if agentErr == "rate_limit" || agentErr == "network" {
+		r.backoffs[sessionID] = backoffs + 1
+	} else {
+		delete(r.backoffs, sessionID)
+	}
+	r.retryMu.Unlock()
+	switch agentErr {
+	case "auth":
+		slog.Warn("agent authentication failed, stopping work", "sessionId", sessionID, "workId", w.ID)
+		if err := r.stopWork(w.ID); err != nil && r.ctx.Err() == nil {
+			slog.Warn("failed to stop work after authentication failure", "workId", w.ID, "error", err)
+		}
+		return
+	case "rate_limit", "network":
+		delay := min(r.backoffBase<<min(backoffs, 10), maxBackoff)
+		slog.Info("agent turn failed, backing off before auto-continuation", "sessionId", sessionID, "workId", w.ID, "errorCode", agentErr, "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-r.ctx.Done():
+			return
+		}
+		if w = r.findWorkBySessionID(sessionID, StatusInProgress); w == nil {
+			return
+		}
+	}
+
 	r.retryMu.Lock()
 	count := r.retries[sessionID]
+	r.retryMu.Unlock()
 	if count >= r.maxRetries {
-		r.retryMu.Unlock()
 		slog.Info("auto-resume retry limit reached, stopping work", "sessionId", sessionID, "workId", w.ID)
 		if err := r.stopWork(w.ID); err != nil {
 			if r.ctx.Err() == nil {
@@ -255,21 +422,37 @@ func (r *AutoResumer) handleAutoContinuation(sessionID string, sender MessageSen
 		}
 		return
 	}
-	r.retries[sessionID] = count + 1
-	r.retryMu.Unlock()
 
-	// Build message with step context if available.
-	var msg string
-	if sp := r.getStepProvider(); sp != nil {
-		if steps, err := sp.GetSteps(w.AgentRoleID); err == nil && len(steps) > 0 {
-			msg = BuildAutoContinuationMessageWithSteps(*w, steps, w.CurrentStep)
+	// A turn that made no progress gets a nudge to finish or explain itself
+	// instead of another full continuation; a second one stops the work.
+	msg := r.continuationMessage(*w)
+	if agentErr == "" && !r.turnMadeProgress(sessionID) {
+		r.retryMu.Lock()
+		stalled := r.stalled[sessionID]
+		r.stalled[sessionID] = true
+		r.retryMu.Unlock()
+		if stalled {
+			slog.Info("work made no progress after nudge, stopping work", "sessionId", sessionID, "workId", w.ID)
+			if err := r.stopWork(w.ID); err != nil && r.ctx.Err() == nil {
+				slog.Warn("failed to stop work without progress", "workId", w.ID, "error", err)
+			}
+			return
 		}
+		msg = BuildNoProgressMessage(r.getLocale(), *w)
 	}
-	if msg == "" {
-		msg = BuildAutoContinuationMessage(*w)
+
+	// A held-back continuation is not a retry: the agent has not run. Nor
+	// is one after a rate limit: the agent could not make progress.
+	if r.holdBack(sessionID, msg) {
+		return
+	}
+	if agentErr == "" {
+		r.retryMu.Lock()
+		r.retries[sessionID] = count + 1
+		r.retryMu.Unlock()
 	}
 
-	if err := sender.SendMessage(r.ctx, sessionID, msg); err != nil {
+	if err := r.send(sender, sessionID, msg); err != nil {
 		if r.ctx.Err() != nil {
 			return // shutting down, don't log
 		}
@@ -279,6 +462,44 @@ func (r *AutoResumer) handleAutoContinuation(sessionID string, sender MessageSen
 	}
 }
 
+// continuationMessage builds the auto-continuation for w, with step context
+// if available.
+func (r *AutoResumer) continuationMessage(w Work) string {
+	if w.InPlanPhase() {
+		return BuildPlanAutoContinuationMessage(r.getLocale(), w)
+	}
+	if sp := r.getStepProvider(); sp != nil {
+		if steps, err := sp.GetSteps(w.EffectiveAgentRoleID()); err == nil && len(steps) > 0 {
+			return BuildAutoContinuationMessageWithSteps(r.getLocale(), w, steps, w.CurrentStep)
+		}
+	}
+	return BuildAutoContinuationMessage(r.getLocale(), w)
+}
+
+// turnMadeProgress snapshots the session and compares it with the snapshot
+// taken at its previous auto-continuation. Without a probe, an earlier
+// snapshot, or when the probe fails, the turn is assumed to have progressed.
+// A turn that progressed clears the session's no-progress nudge.
+func (r *AutoResumer) turnMadeProgress(sessionID string) bool {
+	if r.probe == nil {
+		return true
+	}
+	snap, err := r.probe.Snapshot(sessionID)
+	if err != nil {
+		slog.Warn("failed to snapshot work session", "sessionId", sessionID, "error", err)
+		return true
+	}
+	r.retryMu.Lock()
+	defer r.retryMu.Unlock()
+	prev, found := r.snapshots[sessionID]
+	r.snapshots[sessionID] = snap
+	if found && !madeProgress(prev, snap) {
+		return false
+	}
+	delete(r.stalled, sessionID)
+	return true
+}
+
 // OnWorkChange implements OnChangeListener.
 func (r *AutoResumer) OnWorkChange(event ChangeEvent) {
 	// Clean up tracking state on delete
@@ -287,6 +508,7 @@ func (r *AutoResumer) OnWorkChange(event ChangeEvent) {
 			r.retryMu.Lock()
 			delete(r.retries, event.Work.SessionID)
 			r.retryMu.Unlock()
+			r.dropDeferred(event.Work.SessionID)
 		}
 		return
 	}
@@ -295,21 +517,30 @@ func (r *AutoResumer) OnWorkChange(event ChangeEvent) {
 		return
 	}
 
-	// Reset retries when work completes or stops
-	if event.Work.Status == StatusClosed || event.Work.Status == StatusStopped {
+	// Reset retries when work completes, stops, runs out of budget, or is cancelled
+	if event.Work.Status == StatusClosed || event.Work.Status == StatusStopped || event.Work.Status == StatusNeedsAttention || event.Work.Status == StatusCancelled {
 		if event.Work.SessionID != "" {
 			r.retryMu.Lock()
 			delete(r.retries, event.Work.SessionID)
+			delete(r.snapshots, event.Work.SessionID)
+			delete(r.stalled, event.Work.SessionID)
 			r.retryMu.Unlock()
 		}
 	}
+	if (event.Work.Status == StatusClosed || event.Work.Status == StatusCancelled) && event.Work.SessionID != "" {
+		r.dropDeferred(event.Work.SessionID)
+	}
 
-	// Child closed → parent reactivation
+	// Child closed or cancelled → parent reactivation. Later updates to the
+	// finished child (logged time, file attribution) are not completions.
 	sender := r.getSender()
 	if sender == nil {
 		return
 	}
-	if event.Work.Status != StatusClosed || event.Work.ParentID == "" {
+	if (event.Work.Status != StatusClosed && event.Work.Status != StatusCancelled) || event.Work.ParentID == "" {
+		return
+	}
+	if event.PrevStatus == event.Work.Status {
 		return
 	}
 
@@ -344,10 +575,10 @@ func (r *AutoResumer) NotifyReopen(w Work) {
 // sendStepAdvance sends the next-step prompt to the agent session after a step
 // advance.
 func (r *AutoResumer) sendStepAdvance(w Work, sender MessageSender, sp StepProvider) {
-	steps, err := sp.GetSteps(w.AgentRoleID)
+	steps, err := sp.GetSteps(w.EffectiveAgentRoleID())
 	if err != nil {
 		if r.ctx.Err() == nil {
-			slog.Warn("failed to get steps for step advance", "agentRoleId", w.AgentRoleID, "error", err)
+			slog.Warn("failed to get steps for step advance", "agentRoleId", w.EffectiveAgentRoleID(), "error", err)
 		}
 		return
 	}
@@ -357,13 +588,18 @@ func (r *AutoResumer) sendStepAdvance(w Work, sender MessageSender, sp StepProvi
 		return
 	}
 
-	// Reset retry count (new step context)
+	// Reset retry count and progress tracking (new step context)
 	r.retryMu.Lock()
 	delete(r.retries, w.SessionID)
+	delete(r.snapshots, w.SessionID)
+	delete(r.stalled, w.SessionID)
 	r.retryMu.Unlock()
 
-	msg := BuildStepAdvanceMessage(w, steps[w.CurrentStep], w.CurrentStep+1, len(steps))
-	if err := sender.SendMessage(r.ctx, w.SessionID, msg); err != nil {
+	msg := BuildStepAdvanceMessage(r.getLocale(), w, steps[w.CurrentStep], w.CurrentStep+1, len(steps))
+	if r.holdBack(w.SessionID, msg) {
+		return
+	}
+	if err := r.send(sender, w.SessionID, msg); err != nil {
 		if r.ctx.Err() != nil {
 			return
 		}
@@ -380,8 +616,11 @@ func (r *AutoResumer) sendReopen(w Work, sender MessageSender) {
 	delete(r.retries, w.SessionID)
 	r.retryMu.Unlock()
 
-	msg := BuildReopenMessage(w)
-	if err := sender.SendMessage(r.ctx, w.SessionID, msg); err != nil {
+	msg := BuildReopenMessage(r.getLocale(), w)
+	if r.holdBack(w.SessionID, msg) {
+		return
+	}
+	if err := r.send(sender, w.SessionID, msg); err != nil {
 		if r.ctx.Err() != nil {
 			return
 		}
@@ -405,10 +644,12 @@ func (r *AutoResumer) handleParentReactivation(child Work, sender MessageSender)
 		return
 	}
 
-	// StatusOpen and StatusClosed parents don't receive child completion messages.
+	// StatusOpen, StatusClosed and StatusCancelled parents don't receive child
+	// completion messages.
 	// Open: no agent session started yet.
 	// Closed: parent was explicitly closed and should stay closed.
-	if parent.Status == StatusOpen || parent.Status == StatusClosed {
+	// Cancelled: includes the cascade from cancelling the parent itself.
+	if parent.Status == StatusOpen || parent.Status == StatusClosed || parent.Status == StatusCancelled {
 		return
 	}
 
@@ -429,8 +670,16 @@ func (r *AutoResumer) handleParentReactivation(child Work, sender MessageSender)
 	}
 
 	// Send child completion message to parent (StatusInProgress, StatusNeedsInput, StatusWaiting->InProgress, StatusStopped)
-	msg := BuildChildCompletionMessage(parent, child.Title, child.ID)
-	if err := sender.SendMessage(r.ctx, parent.SessionID, msg); err != nil {
+	msg := BuildChildCompletionMessage(r.getLocale(), parent, child.Title, child.ID)
+	if child.Status == StatusCancelled {
+		msg = BuildChildCancelledMessage(r.getLocale(), parent, child.Title, child.ID, child.CancelReason)
+	} else if child.Review {
+		msg = BuildReviewCompletionMessage(r.getLocale(), parent, child.Title, child.ID)
+	}
+	if r.holdBack(parent.SessionID, msg) {
+		return
+	}
+	if err := r.send(sender, parent.SessionID, msg); err != nil {
 		if r.ctx.Err() != nil {
 			return
 		}
@@ -440,6 +689,120 @@ func (r *AutoResumer) handleParentReactivation(child Work, sender MessageSender)
 	}
 }
 
+// holdBack queues msg instead of sending it when autorun may not drive the
+// session now.
+func (r *AutoResumer) holdBack(sessionID, msg string) bool {
+	reason := r.admit(sessionID)
+	if reason == "" {
+		return false
+	}
+	r.deferredMu.Lock()
+	r.deferred[sessionID] = append(r.deferred[sessionID], msg)
+	r.deferredMu.Unlock()
+	slog.Info("autorun message held back", "sessionId", sessionID, "reason", reason)
+	return true
+}
+
+// admit returns why autorun may not drive sessionID now, or "" when it may,
+// in which case the session holds a slot until its turn ends.
+func (r *AutoResumer) admit(sessionID string) string {
+	if r.gate != nil && !r.gate.Admit(sessionID) {
+		return "paused"
+	}
+	if r.slots != nil && !r.slots.Acquire(sessionID) {
+		return "no free slot"
+	}
+	return ""
+}
+
+// send sends an autorun message. A failed send frees the slot admit took,
+// since no turn starts to end it.
+func (r *AutoResumer) send(sender MessageSender, sessionID, msg string) error {
+	err := sender.SendSystemMessage(r.ctx, sessionID, msg)
+	if err != nil {
+		r.releaseSlot(sessionID)
+	}
+	return err
+}
+
+func (r *AutoResumer) releaseSlot(sessionID string) {
+	if r.slots != nil {
+		r.slots.Release(sessionID)
+	}
+}
+
+// ForgetSession implements SessionForgetter: it drops the retry tracking and
+// held-back messages of a session that is gone, so nothing is sent to it.
+func (r *AutoResumer) ForgetSession(sessionID string) {
+	r.retryMu.Lock()
+	delete(r.retries, sessionID)
+	delete(r.agentErrors, sessionID)
+	delete(r.backoffs, sessionID)
+	delete(r.snapshots, sessionID)
+	delete(r.stalled, sessionID)
+	r.retryMu.Unlock()
+	r.dropDeferred(sessionID)
+}
+
+func (r *AutoResumer) dropDeferred(sessionID string) {
+	r.deferredMu.Lock()
+	delete(r.deferred, sessionID)
+	r.deferredMu.Unlock()
+}
+
+// OnAutorunChange implements OnAutorunChangeListener, sending held-back
+// messages once autorun resumes.
+func (r *AutoResumer) OnAutorunChange(event AutorunEvent) {
+	if event.Type != AutorunEventResumed {
+		return
+	}
+	if sender := r.getSender(); sender != nil {
+		go r.sendDeferred(sender)
+	}
+}
+
+// OnSlotFreed implements OnSlotFreedListener, sending held-back messages
+// that may now have a slot.
+func (r *AutoResumer) OnSlotFreed(string) {
+	r.deferredMu.Lock()
+	pending := len(r.deferred) > 0
+	r.deferredMu.Unlock()
+	if sender := r.getSender(); pending && sender != nil {
+		go r.sendDeferred(sender)
+	}
+}
+
+// sendDeferred sends each session's held-back messages as one message, so
+// a session that went idle meanwhile gets a single turn. Sessions refused
+// again (the budget filled up, or the slot went to another session) stay
+// queued.
+func (r *AutoResumer) sendDeferred(sender MessageSender) {
+	r.deferredMu.Lock()
+	pending := r.deferred
+	r.deferred = make(map[string][]string)
+	r.deferredMu.Unlock()
+
+	for sessionID, msgs := range pending {
+		if r.findWorkBySessionID(sessionID, StatusInProgress, StatusNeedsInput, StatusWaiting, StatusStopped) == nil {
+			continue
+		}
+		if r.admit(sessionID) != "" {
+			r.deferredMu.Lock()
+			r.deferred[sessionID] = append(msgs, r.deferred[sessionID]...)
+			r.deferredMu.Unlock()
+			continue
+		}
+		if err := r.send(sender, sessionID, strings.Join(msgs, "\n\n---\n\n")); err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+			slog.Warn("failed to send held-back autorun messages", "sessionId", sessionID, "error", err)
+		} else {
+			slog.Info("held-back autorun messages sent", "sessionId", sessionID, "count", len(msgs))
+		}
+	}
+}
+
 func (r *AutoResumer) stopWork(workID string) error {
 	return r.workStore.Stop(r.ctx, workID)
 }
@@ -7,9 +7,11 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/pockode/server/i18n"
 )
 
-// mockSender records SendMessage calls.
+// mockSender records SendSystemMessage calls.
 type mockSender struct {
 	messagesMu sync.Mutex
 	messages   []sentMessage
@@ -20,7 +22,7 @@ type sentMessage struct {
 	Content   string
 }
 
-func (m *mockSender) SendMessage(_ context.Context, sessionID, content string) error {
+func (m *mockSender) SendSystemMessage(_ context.Context, sessionID, content string) error {
 	m.messagesMu.Lock()
 	defer m.messagesMu.Unlock()
 	m.messages = append(m.messages, sentMessage{SessionID: sessionID, Content: content})
@@ -250,6 +252,43 @@ func TestAutoResumer_RetryLimit_TransitionsToStopped(t *testing.T) {
 	}
 }
 
+func TestAutoResumer_RateLimitBacksOffWithoutCountingRetries(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+	resumer.backoffBase = 20 * time.Millisecond
+
+	story := createStory(t, store, "Story")
+	sid := "session-1"
+	startWorkWithSession(t, store, story.ID, sid)
+
+	// More rate-limited turns than maxRetries=3: each is continued after
+	// the backoff, and none counts against the limit.
+	for i := 0; i < 5; i++ {
+		resumer.HandleAgentError(sid, "rate_limit")
+		resumer.HandleProcessStateChange(sid, "idle", false, false, false)
+		waitFor(t, func() bool { return len(sender.getMessages()) >= i+1 })
+	}
+
+	if w := getWork(t, store, story.ID); w.Status != StatusInProgress {
+		t.Errorf("status = %q, want %q after rate-limited turns", w.Status, StatusInProgress)
+	}
+}
+
+func TestAutoResumer_AuthErrorStopsWork(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+
+	story := createStory(t, store, "Story")
+	sid := "session-1"
+	startWorkWithSession(t, store, story.ID, sid)
+
+	resumer.HandleAgentError(sid, "auth")
+	resumer.HandleProcessStateChange(sid, "idle", false, false, false)
+
+	waitFor(t, func() bool { return getWork(t, store, story.ID).Status == StatusStopped })
+	if msgs := sender.getMessages(); len(msgs) != 0 {
+		t.Errorf("sent %d continuations after an authentication failure, want 0", len(msgs))
+	}
+}
+
 func TestAutoResumer_RetryResetOnCompletion(t *testing.T) {
 	store, resumer, sender := setupResumerTest(t)
 
@@ -599,6 +638,74 @@ func TestAutoResumer_InProgressParentReceivesChildCompletionMessage(t *testing.T
 	}
 }
 
+func TestAutoResumer_ParentReceivesChildCancelledMessage(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+	store.AddOnChangeListener(resumer)
+
+	story := createStory(t, store, "Story")
+	task := createTask(t, store, story.ID, "Task")
+	parentSid := "parent-session"
+	startWorkWithSession(t, store, story.ID, parentSid)
+	startWork(t, store, task.ID)
+	if err := store.MarkWaiting(context.Background(), story.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Cancel(context.Background(), task.ID, "duplicate of another task"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	waitFor(t, func() bool { return len(sender.getMessages()) >= 1 })
+
+	if parent := getWork(t, store, story.ID); parent.Status != StatusInProgress {
+		t.Errorf("parent status = %q, want in_progress (woken by the cancellation)", parent.Status)
+	}
+	msgs := sender.getMessages()
+	if msgs[0].SessionID != parentSid || !strings.Contains(msgs[0].Content, "has been cancelled") ||
+		!strings.Contains(msgs[0].Content, "duplicate of another task") {
+		t.Errorf("expected child cancelled message with reason, got: %s", msgs[0].Content)
+	}
+}
+
+func TestAutoResumer_CancelledParentNoMessageFromCascade(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+	store.AddOnChangeListener(resumer)
+
+	story := createStory(t, store, "Story")
+	task := createTask(t, store, story.ID, "Task")
+	startWorkWithSession(t, store, story.ID, "parent-session")
+	startWork(t, store, task.ID)
+
+	if _, err := store.Cancel(context.Background(), story.ID, "dropped"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if len(sender.getMessages()) != 0 {
+		t.Error("cancelled parent should not be messaged about its cascaded children")
+	}
+}
+
+func TestAutoResumer_UpdateToClosedChildNoMessage(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+
+	story := createStory(t, store, "Story")
+	task := createTask(t, store, story.ID, "Task")
+	startWorkWithSession(t, store, story.ID, "parent-session")
+
+	// A closed child gaining attributed files is not a new completion.
+	resumer.OnWorkChange(ChangeEvent{
+		Op:         OperationUpdate,
+		Work:       Work{ID: task.ID, Status: StatusClosed, ParentID: story.ID, Title: "Task"},
+		PrevStatus: StatusClosed,
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	if len(sender.getMessages()) != 0 {
+		t.Error("parent should not be messaged for an update that kept the child closed")
+	}
+}
+
 func TestAutoResumer_NeedsInputParentReceivesChildCompletionMessage(t *testing.T) {
 	store, resumer, sender := setupResumerTest(t)
 
@@ -1120,7 +1227,7 @@ type errSender struct {
 	err error
 }
 
-func (s *errSender) SendMessage(_ context.Context, _, _ string) error {
+func (s *errSender) SendSystemMessage(_ context.Context, _, _ string) error {
 	return s.err
 }
 
@@ -1311,3 +1418,235 @@ func TestAutoResumer_NotifyReopen_NoSessionNoMessage(t *testing.T) {
 		t.Errorf("expected no message when no session, got %d", n)
 	}
 }
+
+// --- Trigger D: startup reconciliation ---
+
+type liveSessions map[string]bool
+
+func (l liveSessions) HasProcess(sessionID string) bool { return l[sessionID] }
+
+func TestAutoResumer_ReconcileOrphanedWork_Resume(t *testing.T) {
+	store := newTestStore(t)
+	resumer := NewAutoResumer(store, 3)
+	starter := &recordingStarter{}
+	ops := NewOperations(store, starter, nil)
+
+	story := createStory(t, store, "Story")
+	startWorkWithSession(t, store, story.ID, "s1")
+
+	resumer.ReconcileOrphanedWork(OrphanPolicyResume, nil, ops)
+
+	w := getWork(t, store, story.ID)
+	if w.Status != StatusInProgress || w.SessionID != "s1" {
+		t.Errorf("work = %q/%q, want in_progress/s1", w.Status, w.SessionID)
+	}
+	if starter.calls != 1 || starter.gotWork.SessionID != "s1" {
+		t.Errorf("starter calls = %d (session %q), want 1 restart of s1", starter.calls, starter.gotWork.SessionID)
+	}
+}
+
+func TestAutoResumer_ReconcileOrphanedWork_ResumeFailureLeavesStopped(t *testing.T) {
+	store := newTestStore(t)
+	resumer := NewAutoResumer(store, 3)
+	ops := NewOperations(store, &recordingStarter{err: fmt.Errorf("spawn failed")}, nil)
+
+	story := createStory(t, store, "Story")
+	startWorkWithSession(t, store, story.ID, "s1")
+
+	resumer.ReconcileOrphanedWork(OrphanPolicyResume, nil, ops)
+
+	w := getWork(t, store, story.ID)
+	if w.Status != StatusStopped || w.SessionID != "s1" {
+		t.Errorf("work = %q/%q, want stopped/s1", w.Status, w.SessionID)
+	}
+}
+
+func TestAutoResumer_ReconcileOrphanedWork_Reset(t *testing.T) {
+	store := newTestStore(t)
+	resumer := NewAutoResumer(store, 3)
+
+	story := createStory(t, store, "Story")
+	waiting := createStory(t, store, "Waiting")
+	startWorkWithSession(t, store, story.ID, "s1")
+	startWorkWithSession(t, store, waiting.ID, "s2")
+	if err := store.MarkWaiting(context.Background(), waiting.ID); err != nil {
+		t.Fatalf("MarkWaiting: %v", err)
+	}
+
+	resumer.ReconcileOrphanedWork(OrphanPolicyReset, nil, nil)
+
+	w := getWork(t, store, story.ID)
+	if w.Status != StatusOpen || w.SessionID != "" {
+		t.Errorf("in_progress work = %q/%q, want open with no session", w.Status, w.SessionID)
+	}
+	// The policy applies to in_progress only; paused work is stopped as before.
+	if got := getWork(t, store, waiting.ID).Status; got != StatusStopped {
+		t.Errorf("waiting work status = %q, want %q", got, StatusStopped)
+	}
+}
+
+func TestAutoResumer_ReconcileOrphanedWork_SkipsLiveSessions(t *testing.T) {
+	store := newTestStore(t)
+	resumer := NewAutoResumer(store, 3)
+	starter := &recordingStarter{}
+
+	live := createStory(t, store, "Live")
+	dead := createStory(t, store, "Dead")
+	startWorkWithSession(t, store, live.ID, "s1")
+	startWorkWithSession(t, store, dead.ID, "s2")
+
+	resumer.ReconcileOrphanedWork(OrphanPolicyStop, liveSessions{"s1": true}, NewOperations(store, starter, nil))
+
+	if got := getWork(t, store, live.ID).Status; got != StatusInProgress {
+		t.Errorf("live work status = %q, want %q", got, StatusInProgress)
+	}
+	if got := getWork(t, store, dead.ID).Status; got != StatusStopped {
+		t.Errorf("dead work status = %q, want %q", got, StatusStopped)
+	}
+	if starter.calls != 0 {
+		t.Errorf("starter calls = %d, want 0 under the stop policy", starter.calls)
+	}
+}
+
+// --- Autorun limits ---
+
+func TestAutoResumer_HoldsBackWhileAutorunPaused(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+	limits := AutorunLimits{MaxSessions: 1}
+	now := autorunBase
+	gate, _ := newTestGate(t, t.TempDir(), &limits, &now)
+	resumer.SetAutorunGate(gate)
+
+	s1 := createStory(t, store, "First")
+	startWorkWithSession(t, store, s1.ID, "session-1")
+	s2 := createStory(t, store, "Second")
+	startWorkWithSession(t, store, s2.ID, "session-2")
+
+	resumer.HandleProcessStateChange("session-1", "idle", false, false, false)
+	waitFor(t, func() bool { return len(sender.getMessages()) == 1 })
+
+	resumer.HandleProcessStateChange("session-2", "idle", false, false, false)
+	waitFor(t, func() bool { return gate.Status().Paused })
+	time.Sleep(50 * time.Millisecond)
+	if msgs := sender.getMessages(); len(msgs) != 1 {
+		t.Fatalf("expected the second session to be held back, got %d messages", len(msgs))
+	}
+
+	limits.MaxSessions = 2
+	gate.check()
+	waitFor(t, func() bool { return len(sender.getMessages()) == 2 })
+	if msg := sender.getMessages()[1]; msg.SessionID != "session-2" {
+		t.Errorf("resumed message went to %q", msg.SessionID)
+	}
+
+	// A held-back continuation did not count as a retry.
+	resumer.retryMu.Lock()
+	retries := resumer.retries["session-2"]
+	resumer.retryMu.Unlock()
+	if retries != 0 {
+		t.Errorf("retries = %d, want 0", retries)
+	}
+}
+
+func TestAutoResumer_HoldsBackUntilSlotFree(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+	slots, _ := newTestSlots(t, store, 1)
+	resumer.SetAutorunSlots(slots)
+
+	s1 := createStory(t, store, "First")
+	startWorkWithSession(t, store, s1.ID, "session-1")
+	s2 := createStory(t, store, "Second")
+	startWorkWithSession(t, store, s2.ID, "session-2")
+
+	resumer.HandleProcessStateChange("session-1", "running", false, false, false)
+	resumer.HandleProcessStateChange("session-2", "idle", false, false, false)
+	time.Sleep(50 * time.Millisecond)
+	if msgs := sender.getMessages(); len(msgs) != 0 {
+		t.Fatalf("expected the continuation to wait for a slot, got %d messages", len(msgs))
+	}
+
+	// session-1's turn ends: the slot goes to the held-back session, and
+	// session-1's own continuation waits in turn.
+	resumer.HandleProcessStateChange("session-1", "idle", false, false, false)
+	waitFor(t, func() bool { return len(sender.getMessages()) == 1 })
+	if msg := sender.getMessages()[0]; msg.SessionID != "session-2" {
+		t.Errorf("freed slot went to %q", msg.SessionID)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if msgs := sender.getMessages(); len(msgs) != 1 {
+		t.Errorf("expected session-1 to wait for the slot, got %d messages", len(msgs))
+	}
+}
+
+// --- Progress check ---
+
+type stubProgressProbe struct {
+	mu   sync.Mutex
+	snap TurnSnapshot
+}
+
+func (p *stubProgressProbe) Snapshot(string) (TurnSnapshot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snap, nil
+}
+
+func (p *stubProgressProbe) set(snap TurnSnapshot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.snap = snap
+}
+
+func TestAutoResumer_NoProgressNudgesThenStops(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+	probe := &stubProgressProbe{snap: TurnSnapshot{ToolCalls: 2, LastMessage: "All done."}}
+	resumer.SetProgressProbe(probe)
+
+	story := createStory(t, store, "Story")
+	sid := "session-1"
+	startWorkWithSession(t, store, story.ID, sid)
+
+	// First continuation: nothing to compare with yet.
+	resumer.HandleProcessStateChange(sid, "idle", false, false, false)
+	waitFor(t, func() bool { return len(sender.getMessages()) >= 1 })
+
+	// The agent repeats itself without doing anything: nudge.
+	resumer.HandleProcessStateChange(sid, "idle", false, false, false)
+	waitFor(t, func() bool { return len(sender.getMessages()) >= 2 })
+	if got, want := sender.getMessages()[1].Content, BuildNoProgressMessage(i18n.Default, getWork(t, store, story.ID)); got != want {
+		t.Errorf("second message = %q, want the no-progress nudge", got)
+	}
+
+	// Again: stop instead of continuing.
+	resumer.HandleProcessStateChange(sid, "idle", false, false, false)
+	waitFor(t, func() bool { return getWork(t, store, story.ID).Status == StatusStopped })
+	if n := len(sender.getMessages()); n != 2 {
+		t.Errorf("sent %d messages, want 2", n)
+	}
+}
+
+func TestAutoResumer_ProgressClearsNudge(t *testing.T) {
+	store, resumer, sender := setupResumerTest(t)
+	probe := &stubProgressProbe{snap: TurnSnapshot{ToolCalls: 2, LastMessage: "All done."}}
+	resumer.SetProgressProbe(probe)
+	resumer.maxRetries = 10
+
+	story := createStory(t, store, "Story")
+	sid := "session-1"
+	startWorkWithSession(t, store, story.ID, sid)
+
+	for i, snap := range []TurnSnapshot{
+		{ToolCalls: 2, LastMessage: "All done."},
+		{ToolCalls: 2, LastMessage: "All done."}, // nudged
+		{ToolCalls: 5, LastMessage: "Fixed the failing test."},
+		{ToolCalls: 5, LastMessage: "Fixed the failing test."}, // nudged again, not stopped
+	} {
+		probe.set(snap)
+		resumer.HandleProcessStateChange(sid, "idle", false, false, false)
+		waitFor(t, func() bool { return len(sender.getMessages()) >= i+1 })
+	}
+
+	if w := getWork(t, store, story.ID); w.Status != StatusInProgress {
+		t.Errorf("status = %q, want %q", w.Status, StatusInProgress)
+	}
+}
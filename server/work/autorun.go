@@ -0,0 +1,447 @@
+package work
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/filestore"
+)
+
+// ErrAutorunPaused is returned when autorun may not start work right now
+// because of its schedule or budget.
+var ErrAutorunPaused = errors.New("autorun paused")
+
+// AutorunPeriod is the window an autorun budget covers. Daily periods start
+// at local midnight, weekly ones at midnight on Monday.
+type AutorunPeriod string
+
+const (
+	AutorunDaily  AutorunPeriod = "daily"
+	AutorunWeekly AutorunPeriod = "weekly"
+)
+
+func (p AutorunPeriod) IsValid() bool {
+	return p == "" || p == AutorunDaily || p == AutorunWeekly
+}
+
+// Autorun pause reasons.
+const (
+	AutorunReasonSchedule = "schedule"
+	AutorunReasonBudget   = "budget"
+)
+
+const (
+	autorunCheckInterval = time.Minute
+	autorunUsageFile     = "autorun-usage.json"
+)
+
+// AutorunLimits bounds what autorun (the agent turns the server starts on
+// its own: auto-continuations, step advances, child completion messages,
+// orphan resume, and agent-initiated work_start) may do. The zero value
+// imposes no limit.
+type AutorunLimits struct {
+	// Allowed hours as offsets from local midnight. End before Start wraps
+	// past midnight; Start == End allows the whole day.
+	Start, End time.Duration
+	Days       []time.Weekday // days a window opens on; empty = every day
+
+	Period      AutorunPeriod // empty = daily
+	MaxSessions int           // distinct sessions autorun may drive per period; 0 = unlimited
+	MaxTokens   int64         // tokens those sessions may use per period; 0 = unlimited
+}
+
+// ParseAutorunHours parses "09:00-18:00" into offsets from midnight. Empty
+// means the whole day.
+func ParseAutorunHours(s string) (start, end time.Duration, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if ok {
+		start, err = parseClock(from)
+	}
+	if ok && err == nil {
+		end, err = parseClock(to)
+	}
+	if !ok || err != nil {
+		return 0, 0, fmt.Errorf("invalid autorun hours %q: use HH:MM-HH:MM", s)
+	}
+	return start, end, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseAutorunDays parses comma-separated weekdays and ranges ("mon-fri",
+// "sat,sun"). Empty means every day.
+func ParseAutorunDays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(strings.ToLower(s), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok1 := weekdayNames[strings.TrimSpace(from)]
+		last, ok2 := first, true
+		if isRange {
+			last, ok2 = weekdayNames[strings.TrimSpace(to)]
+		}
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid autorun day %q: use mon, tue, ... or a range like mon-fri", part)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			if !slices.Contains(days, d) {
+				days = append(days, d)
+			}
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// inSchedule reports whether t falls in an allowed window.
+func (l AutorunLimits) inSchedule(t time.Time) bool {
+	tod := t.Sub(midnight(t))
+	day := t.Weekday()
+	switch {
+	case l.Start == l.End:
+	case l.Start < l.End:
+		if tod < l.Start || tod >= l.End {
+			return false
+		}
+	case tod >= l.Start:
+	case tod < l.End:
+		// Early hours of a window that opened the day before.
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+	return len(l.Days) == 0 || slices.Contains(l.Days, day)
+}
+
+// nextWindow returns when the next allowed window after t opens.
+func (l AutorunLimits) nextWindow(t time.Time) time.Time {
+	day := midnight(t)
+	for i := 0; i <= 7; i++ {
+		open := time.Date(day.Year(), day.Month(), day.Day()+i, 0, 0, 0, 0, t.Location()).Add(l.Start)
+		if open.After(t) && l.inSchedule(open) {
+			return open
+		}
+	}
+	return t // no day allowed; never reached for a valid schedule
+}
+
+func (l AutorunLimits) periodStart(t time.Time) time.Time {
+	if l.Period == AutorunWeekly {
+		return weekStart(t)
+	}
+	return midnight(t)
+}
+
+func (l AutorunLimits) periodEnd(start time.Time) time.Time {
+	days := 1
+	if l.Period == AutorunWeekly {
+		days = 7
+	}
+	return time.Date(start.Year(), start.Month(), start.Day()+days, 0, 0, 0, 0, start.Location())
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// AutorunStatus is the gate's current state.
+type AutorunStatus struct {
+	Paused      bool       `json:"paused"`
+	Reason      string     `json:"reason,omitempty"`     // AutorunReasonSchedule or AutorunReasonBudget
+	ResumesAt   *time.Time `json:"resumes_at,omitempty"` // when paused
+	Period      string     `json:"period"`
+	PeriodStart time.Time  `json:"period_start"`
+	Sessions    int        `json:"sessions"`
+	Tokens      int64      `json:"tokens"`
+	MaxSessions int        `json:"max_sessions,omitempty"`
+	MaxTokens   int64      `json:"max_tokens,omitempty"`
+}
+
+// AutorunEvent reports autorun pausing (budget_exceeded, for either reason)
+// or resuming.
+type AutorunEvent struct {
+	Type   string        `json:"type"` // "budget_exceeded" | "resumed"
+	Status AutorunStatus `json:"status"`
+}
+
+const (
+	AutorunEventBudgetExceeded = "budget_exceeded"
+	AutorunEventResumed        = "resumed"
+)
+
+// OnAutorunChangeListener receives autorun pause and resume events. Called
+// from the gate's caller or check loop; implementations must not block.
+type OnAutorunChangeListener interface {
+	OnAutorunChange(event AutorunEvent)
+}
+
+// autorunUsage is what autorun consumed in the current period, persisted so
+// a restart does not hand out a fresh budget.
+type autorunUsage struct {
+	PeriodStart time.Time `json:"period_start"`
+	Sessions    []string  `json:"sessions"`
+	Tokens      int64     `json:"tokens"`
+}
+
+// AutorunGate decides whether autorun may drive a session now. Once a
+// request is refused it stays paused, and the check loop resumes it (and
+// notifies listeners, which replay what they held back) once the schedule
+// window opens and the budget has room, e.g. after the period resets or the
+// limits are raised.
+type AutorunGate struct {
+	limits func() AutorunLimits
+	file   *filestore.File
+	now    func() time.Time
+
+	usageMu sync.Mutex
+	usage   autorunUsage
+	paused  string // pause reason; "" when running
+
+	listenersMu sync.Mutex
+	listeners   []OnAutorunChangeListener
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAutorunGate loads usage from dataDir and reads limits on every check,
+// so settings changes apply without a restart.
+func NewAutorunGate(dataDir string, limits func() AutorunLimits) (*AutorunGate, error) {
+	file, err := filestore.New(filestore.Config{Path: filepath.Join(dataDir, autorunUsageFile), Label: "autorun-usage"})
+	if err != nil {
+		return nil, err
+	}
+	usage, err := filestore.Load(file, autorunUsage{})
+	if err != nil {
+		return nil, err
+	}
+	return &AutorunGate{
+		limits: limits,
+		file:   file,
+		now:    time.Now,
+		usage:  usage,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+func (g *AutorunGate) AddOnChangeListener(l OnAutorunChangeListener) {
+	g.listenersMu.Lock()
+	defer g.listenersMu.Unlock()
+	g.listeners = append(g.listeners, l)
+}
+
+func (g *AutorunGate) Start() {
+	go func() {
+		defer close(g.done)
+		ticker := time.NewTicker(autorunCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-g.stop:
+				return
+			case <-ticker.C:
+				g.check()
+			}
+		}
+	}()
+}
+
+func (g *AutorunGate) Stop() {
+	close(g.stop)
+	<-g.done
+}
+
+// Admit reports whether autorun may drive sessionID now and, if so, counts
+// the session against the budget. A session already counted this period
+// may continue after the session limit is reached.
+func (g *AutorunGate) Admit(sessionID string) bool {
+	return g.admit(sessionID, true)
+}
+
+// CheckStart returns a wrapped ErrAutorunPaused when autorun may not start a
+// new session now. The session is counted once autorun drives it.
+func (g *AutorunGate) CheckStart() error {
+	if g.admit("", false) {
+		return nil
+	}
+	status := g.Status()
+	if status.ResumesAt == nil {
+		return fmt.Errorf("%w (%s)", ErrAutorunPaused, status.Reason)
+	}
+	return fmt.Errorf("%w (%s); resumes at %s", ErrAutorunPaused, status.Reason, status.ResumesAt.Format(time.RFC3339))
+}
+
+func (g *AutorunGate) admit(sessionID string, record bool) bool {
+	g.usageMu.Lock()
+	limits := g.limits()
+	now := g.now()
+	g.rolloverLocked(limits, now)
+
+	reason := g.refusalLocked(limits, now, sessionID)
+	if reason == "" {
+		if record && sessionID != "" && !slices.Contains(g.usage.Sessions, sessionID) {
+			g.usage.Sessions = append(g.usage.Sessions, sessionID)
+			g.persistLocked()
+		}
+		g.usageMu.Unlock()
+		return true
+	}
+
+	first := g.paused == ""
+	g.paused = reason
+	status := g.statusLocked(limits, now)
+	g.usageMu.Unlock()
+
+	if first {
+		slog.Info("autorun paused", "reason", reason, "sessions", status.Sessions, "tokens", status.Tokens)
+		g.notify(AutorunEvent{Type: AutorunEventBudgetExceeded, Status: status})
+	}
+	return false
+}
+
+// RecordTokens adds tokens a session used, if autorun drove it this period.
+func (g *AutorunGate) RecordTokens(sessionID string, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	g.usageMu.Lock()
+	defer g.usageMu.Unlock()
+	g.rolloverLocked(g.limits(), g.now())
+	if !slices.Contains(g.usage.Sessions, sessionID) {
+		return
+	}
+	g.usage.Tokens += tokens
+	g.persistLocked()
+}
+
+// Status returns the current state.
+func (g *AutorunGate) Status() AutorunStatus {
+	g.usageMu.Lock()
+	defer g.usageMu.Unlock()
+	limits := g.limits()
+	now := g.now()
+	g.rolloverLocked(limits, now)
+	return g.statusLocked(limits, now)
+}
+
+// check resumes a paused gate whose schedule and budget allow it again.
+func (g *AutorunGate) check() {
+	g.usageMu.Lock()
+	limits := g.limits()
+	now := g.now()
+	g.rolloverLocked(limits, now)
+	if g.paused == "" || g.refusalLocked(limits, now, "") != "" {
+		g.usageMu.Unlock()
+		return
+	}
+	g.paused = ""
+	status := g.statusLocked(limits, now)
+	g.usageMu.Unlock()
+
+	slog.Info("autorun resumed", "periodStart", status.PeriodStart)
+	g.notify(AutorunEvent{Type: AutorunEventResumed, Status: status})
+}
+
+// refusalLocked returns why autorun may not drive sessionID ("" = a new
+// session) at now, or "" when it may.
+func (g *AutorunGate) refusalLocked(limits AutorunLimits, now time.Time, sessionID string) string {
+	if !limits.inSchedule(now) {
+		return AutorunReasonSchedule
+	}
+	if limits.MaxTokens > 0 && g.usage.Tokens >= limits.MaxTokens {
+		return AutorunReasonBudget
+	}
+	counted := sessionID != "" && slices.Contains(g.usage.Sessions, sessionID)
+	if limits.MaxSessions > 0 && !counted && len(g.usage.Sessions) >= limits.MaxSessions {
+		return AutorunReasonBudget
+	}
+	return ""
+}
+
+func (g *AutorunGate) rolloverLocked(limits AutorunLimits, now time.Time) {
+	start := limits.periodStart(now)
+	if g.usage.PeriodStart.Equal(start) {
+		return
+	}
+	g.usage = autorunUsage{PeriodStart: start}
+	g.persistLocked()
+}
+
+func (g *AutorunGate) statusLocked(limits AutorunLimits, now time.Time) AutorunStatus {
+	period := limits.Period
+	if period == "" {
+		period = AutorunDaily
+	}
+	status := AutorunStatus{
+		Paused:      g.paused != "",
+		Reason:      g.paused,
+		Period:      string(period),
+		PeriodStart: g.usage.PeriodStart,
+		Sessions:    len(g.usage.Sessions),
+		Tokens:      g.usage.Tokens,
+		MaxSessions: limits.MaxSessions,
+		MaxTokens:   limits.MaxTokens,
+	}
+	if status.Paused {
+		resumes := g.resumesAtLocked(limits, now)
+		status.ResumesAt = &resumes
+	}
+	return status
+}
+
+// resumesAtLocked estimates when a paused gate resumes: the budget resets at
+// the end of the period, and the schedule window must be open. The check
+// loop notices within autorunCheckInterval.
+func (g *AutorunGate) resumesAtLocked(limits AutorunLimits, now time.Time) time.Time {
+	t := now
+	overBudget := g.refusalLocked(AutorunLimits{MaxTokens: limits.MaxTokens, MaxSessions: limits.MaxSessions}, now, "") != ""
+	if overBudget {
+		t = limits.periodEnd(limits.periodStart(now))
+	}
+	if !limits.inSchedule(t) {
+		t = limits.nextWindow(t)
+	}
+	return t
+}
+
+func (g *AutorunGate) persistLocked() {
+	if err := g.file.Persist(g.usage); err != nil {
+		slog.Error("failed to persist autorun usage", "error", err)
+	}
+}
+
+func (g *AutorunGate) notify(e AutorunEvent) {
+	g.listenersMu.Lock()
+	listeners := slices.Clone(g.listeners)
+	g.listenersMu.Unlock()
+	for _, l := range listeners {
+		l.OnAutorunChange(e)
+	}
+}
@@ -0,0 +1,166 @@
+package work
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ErrNoAutorunSlot is returned when autorun may not start work because the
+// role already runs as many sessions as it allows.
+var ErrNoAutorunSlot = errors.New("no free autorun slot")
+
+// OnSlotFreedListener is told when a session of an agent role stops
+// running, so autorun can hand the slot to a session that waits for one.
+// Called from the process state callback; implementations must not block.
+type OnSlotFreedListener interface {
+	OnSlotFreed(agentRoleID string)
+}
+
+// AutorunSlots caps how many work sessions of one agent role run at once
+// (agentrole.AgentRole.MaxConcurrent). A work session holds a slot of its
+// role while its process runs a turn, whoever started the turn. Only autorun
+// waits for a free slot; a user starting work or sending a message never
+// does, but still takes one.
+//
+// Slots follow the process lifecycle: running takes one, idle and ended
+// free it. Acquire also takes one ahead of the send, so two held-back
+// messages cannot both pass before either process is running.
+type AutorunSlots struct {
+	workStore Store
+	limit     func(agentRoleID string) int // 0 = no cap
+
+	slotsMu  sync.Mutex
+	occupied map[string]string // sessionID → agent role ID
+
+	listenersMu sync.Mutex
+	listeners   []OnSlotFreedListener
+}
+
+// NewAutorunSlots reads each role's cap through limit on every check, so
+// role edits apply without a restart.
+func NewAutorunSlots(workStore Store, limit func(agentRoleID string) int) *AutorunSlots {
+	return &AutorunSlots{
+		workStore: workStore,
+		limit:     limit,
+		occupied:  make(map[string]string),
+	}
+}
+
+func (s *AutorunSlots) AddOnSlotFreedListener(l OnSlotFreedListener) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.listeners = append(s.listeners, l)
+}
+
+// HandleProcessState updates slot usage from a process state change. The
+// idle state a process reports on creation is ignored: it precedes the
+// first turn and would free a slot Acquire just took for it.
+func (s *AutorunSlots) HandleProcessState(sessionID, state string, isInitial bool) {
+	switch {
+	case state == "running":
+		roleID := s.roleOf(sessionID)
+		if roleID == "" {
+			return
+		}
+		s.slotsMu.Lock()
+		s.occupied[sessionID] = roleID
+		s.slotsMu.Unlock()
+	case state == "ended" || (state == "idle" && !isInitial):
+		s.Release(sessionID)
+	}
+}
+
+// Acquire reports whether autorun may run a turn in sessionID now and, if
+// so, holds the slot until the turn ends. A session that already holds one
+// (e.g. a step advance sent mid-turn), a session outside work, and a role
+// without a cap always may.
+func (s *AutorunSlots) Acquire(sessionID string) bool {
+	roleID := s.roleOf(sessionID)
+	if roleID == "" {
+		return true
+	}
+	maxSessions := s.limit(roleID)
+
+	s.slotsMu.Lock()
+	defer s.slotsMu.Unlock()
+	if _, held := s.occupied[sessionID]; !held && maxSessions > 0 && s.countLocked(roleID) >= maxSessions {
+		return false
+	}
+	s.occupied[sessionID] = roleID
+	return true
+}
+
+// Release frees the slot sessionID holds, if any. Autorun calls it when a
+// send fails after Acquire, since no process state change will free it.
+func (s *AutorunSlots) Release(sessionID string) {
+	s.slotsMu.Lock()
+	roleID, held := s.occupied[sessionID]
+	delete(s.occupied, sessionID)
+	s.slotsMu.Unlock()
+	if !held {
+		return
+	}
+
+	s.listenersMu.Lock()
+	listeners := slices.Clone(s.listeners)
+	s.listenersMu.Unlock()
+	for _, l := range listeners {
+		l.OnSlotFreed(roleID)
+	}
+}
+
+// CheckStart returns a wrapped ErrNoAutorunSlot when agentRoleID has no free
+// slot for a new session. The slot is taken once the session runs.
+func (s *AutorunSlots) CheckStart(agentRoleID string) error {
+	maxSessions := s.limit(agentRoleID)
+	if maxSessions <= 0 {
+		return nil
+	}
+	s.slotsMu.Lock()
+	used := s.countLocked(agentRoleID)
+	s.slotsMu.Unlock()
+	if used >= maxSessions {
+		return fmt.Errorf("%w (%d of %d sessions of this role running)", ErrNoAutorunSlot, used, maxSessions)
+	}
+	return nil
+}
+
+// Occupancy returns the sessions holding a slot, by agent role ID, each
+// list sorted.
+func (s *AutorunSlots) Occupancy() map[string][]string {
+	s.slotsMu.Lock()
+	defer s.slotsMu.Unlock()
+	byRole := make(map[string][]string)
+	for _, sessionID := range slices.Sorted(maps.Keys(s.occupied)) {
+		roleID := s.occupied[sessionID]
+		byRole[roleID] = append(byRole[roleID], sessionID)
+	}
+	return byRole
+}
+
+func (s *AutorunSlots) countLocked(roleID string) int {
+	n := 0
+	for _, r := range s.occupied {
+		if r == roleID {
+			n++
+		}
+	}
+	return n
+}
+
+// roleOf returns the agent role of sessionID's work, or "" for a session
+// that runs no work.
+func (s *AutorunSlots) roleOf(sessionID string) string {
+	w, found, err := s.workStore.FindBySessionID(sessionID)
+	if err != nil {
+		slog.Warn("failed to find work for session", "sessionId", sessionID, "error", err)
+	}
+	if !found {
+		return ""
+	}
+	return w.EffectiveAgentRoleID()
+}
@@ -0,0 +1,108 @@
+package work
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+type slotFreedRecorder struct {
+	freedMu sync.Mutex
+	freed   []string
+}
+
+func (r *slotFreedRecorder) OnSlotFreed(agentRoleID string) {
+	r.freedMu.Lock()
+	defer r.freedMu.Unlock()
+	r.freed = append(r.freed, agentRoleID)
+}
+
+func newTestSlots(t *testing.T, store *FileStore, maxSessions int) (*AutorunSlots, *slotFreedRecorder) {
+	t.Helper()
+	slots := NewAutorunSlots(store, func(agentRoleID string) int {
+		if agentRoleID == testRoleID {
+			return maxSessions
+		}
+		return 0
+	})
+	rec := &slotFreedRecorder{}
+	slots.AddOnSlotFreedListener(rec)
+	return slots, rec
+}
+
+func TestAutorunSlots_Acquire(t *testing.T) {
+	store := newTestStore(t)
+	slots, rec := newTestSlots(t, store, 1)
+
+	s1 := createStory(t, store, "First")
+	startWorkWithSession(t, store, s1.ID, "session-1")
+	s2 := createStory(t, store, "Second")
+	startWorkWithSession(t, store, s2.ID, "session-2")
+
+	if !slots.Acquire("session-1") {
+		t.Fatal("first session refused a free slot")
+	}
+	if !slots.Acquire("session-1") {
+		t.Error("a session holding the slot was refused")
+	}
+	if slots.Acquire("session-2") {
+		t.Error("second session got a slot past the cap")
+	}
+	if !slots.Acquire("no-work") {
+		t.Error("a session outside work was refused")
+	}
+	if err := slots.CheckStart(testRoleID); !errors.Is(err, ErrNoAutorunSlot) {
+		t.Errorf("CheckStart = %v, want ErrNoAutorunSlot", err)
+	}
+	if err := slots.CheckStart("other-role"); err != nil {
+		t.Errorf("CheckStart for an uncapped role = %v", err)
+	}
+
+	slots.Release("session-1")
+	if !slices.Equal(rec.freed, []string{testRoleID}) {
+		t.Errorf("freed = %v", rec.freed)
+	}
+	if !slots.Acquire("session-2") {
+		t.Error("second session refused after the slot was freed")
+	}
+}
+
+func TestAutorunSlots_FollowProcessLifecycle(t *testing.T) {
+	store := newTestStore(t)
+	slots, rec := newTestSlots(t, store, 2)
+
+	s1 := createStory(t, store, "First")
+	startWorkWithSession(t, store, s1.ID, "session-1")
+
+	// A user turn takes a slot even though nobody acquired it.
+	slots.HandleProcessState("session-1", "running", false)
+	if got := slots.Occupancy()[testRoleID]; !slices.Equal(got, []string{"session-1"}) {
+		t.Fatalf("occupancy = %v", got)
+	}
+
+	// The idle a new process reports does not free a slot.
+	slots.HandleProcessState("session-1", "idle", true)
+	if len(slots.Occupancy()[testRoleID]) != 1 {
+		t.Error("initial idle freed the slot")
+	}
+
+	slots.HandleProcessState("session-1", "idle", false)
+	if len(slots.Occupancy()) != 0 {
+		t.Errorf("occupancy after idle = %v", slots.Occupancy())
+	}
+
+	slots.HandleProcessState("session-1", "running", false)
+	slots.HandleProcessState("session-1", "ended", false)
+	if len(slots.Occupancy()) != 0 {
+		t.Errorf("occupancy after ended = %v", slots.Occupancy())
+	}
+	if len(rec.freed) != 2 {
+		t.Errorf("freed = %v, want two releases", rec.freed)
+	}
+
+	slots.HandleProcessState("no-work", "running", false)
+	if len(slots.Occupancy()) != 0 {
+		t.Error("a session outside work took a slot")
+	}
+}
@@ -0,0 +1,211 @@
+package work
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Wednesday
+var autorunBase = time.Date(2026, 3, 4, 12, 0, 0, 0, time.Local)
+
+type autorunRecorder struct {
+	eventsMu sync.Mutex
+	events   []AutorunEvent
+}
+
+func (r *autorunRecorder) OnAutorunChange(e AutorunEvent) {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *autorunRecorder) types() []string {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	var out []string
+	for _, e := range r.events {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+func newTestGate(t *testing.T, dataDir string, limits *AutorunLimits, now *time.Time) (*AutorunGate, *autorunRecorder) {
+	t.Helper()
+	g, err := NewAutorunGate(dataDir, func() AutorunLimits { return *limits })
+	if err != nil {
+		t.Fatalf("NewAutorunGate: %v", err)
+	}
+	g.now = func() time.Time { return *now }
+	rec := &autorunRecorder{}
+	g.AddOnChangeListener(rec)
+	return g, rec
+}
+
+func TestParseAutorunHours(t *testing.T) {
+	start, end, err := ParseAutorunHours("09:30-18:00")
+	if err != nil || start != 9*time.Hour+30*time.Minute || end != 18*time.Hour {
+		t.Errorf("got %v-%v, %v", start, end, err)
+	}
+	if start, end, err := ParseAutorunHours(""); err != nil || start != end {
+		t.Errorf("empty: got %v-%v, %v", start, end, err)
+	}
+	for _, bad := range []string{"9-5", "09:00", "25:00-01:00", "09:00-18:00-20:00"} {
+		if _, _, err := ParseAutorunHours(bad); err == nil {
+			t.Errorf("ParseAutorunHours(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestParseAutorunDays(t *testing.T) {
+	days, err := ParseAutorunDays("mon-wed, sat")
+	if err != nil {
+		t.Fatalf("ParseAutorunDays: %v", err)
+	}
+	want := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Saturday}
+	if !slices.Equal(days, want) {
+		t.Errorf("days = %v, want %v", days, want)
+	}
+	// Ranges wrap around the week.
+	if days, _ := ParseAutorunDays("fri-mon"); len(days) != 4 {
+		t.Errorf("fri-mon = %v", days)
+	}
+	if _, err := ParseAutorunDays("funday"); err == nil {
+		t.Error("expected error for unknown day")
+	}
+}
+
+func TestAutorunLimits_InSchedule(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 3, day, hour, 0, 0, 0, time.Local) // 2 = Monday
+	}
+	office := AutorunLimits{Start: 9 * time.Hour, End: 18 * time.Hour, Days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}}
+	night := AutorunLimits{Start: 22 * time.Hour, End: 6 * time.Hour, Days: []time.Weekday{time.Friday}}
+
+	tests := []struct {
+		name   string
+		limits AutorunLimits
+		t      time.Time
+		want   bool
+	}{
+		{"no limits", AutorunLimits{}, at(7, 3), true},
+		{"office hours", office, at(2, 10), true},
+		{"before office hours", office, at(2, 8), false},
+		{"end is exclusive", office, at(2, 18), false},
+		{"weekend", office, at(7, 10), false},
+		{"night window opens friday", night, at(6, 23), true},
+		{"night window continues saturday morning", night, at(7, 5), true},
+		{"friday morning belongs to thursday", night, at(6, 5), false},
+		{"saturday night", night, at(7, 23), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limits.inSchedule(tt.t); got != tt.want {
+				t.Errorf("inSchedule(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+
+	if got := office.nextWindow(at(6, 19)); !got.Equal(at(9, 9)) {
+		t.Errorf("nextWindow after friday evening = %v, want monday 09:00", got)
+	}
+}
+
+func TestAutorunGate_SessionBudget(t *testing.T) {
+	limits := AutorunLimits{MaxSessions: 2}
+	now := autorunBase
+	g, rec := newTestGate(t, t.TempDir(), &limits, &now)
+
+	if !g.Admit("s1") || !g.Admit("s2") {
+		t.Fatal("sessions within budget refused")
+	}
+	if g.Admit("s3") {
+		t.Error("third session admitted over budget")
+	}
+	// Sessions already counted may keep going.
+	if !g.Admit("s1") {
+		t.Error("counted session refused")
+	}
+	if err := g.CheckStart(); !errors.Is(err, ErrAutorunPaused) {
+		t.Errorf("CheckStart = %v, want ErrAutorunPaused", err)
+	}
+
+	status := g.Status()
+	if !status.Paused || status.Reason != AutorunReasonBudget || status.Sessions != 2 {
+		t.Errorf("status = %+v", status)
+	}
+	if status.ResumesAt == nil || !status.ResumesAt.Equal(time.Date(2026, 3, 5, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("ResumesAt = %v, want next midnight", status.ResumesAt)
+	}
+
+	g.check()
+	if got := rec.types(); !slices.Equal(got, []string{AutorunEventBudgetExceeded}) {
+		t.Errorf("events before reset = %v", got)
+	}
+
+	now = now.Add(13 * time.Hour) // next day
+	g.check()
+	if got := rec.types(); !slices.Equal(got, []string{AutorunEventBudgetExceeded, AutorunEventResumed}) {
+		t.Errorf("events after reset = %v", got)
+	}
+	if status := g.Status(); status.Paused || status.Sessions != 0 {
+		t.Errorf("status after reset = %+v", status)
+	}
+	if !g.Admit("s3") {
+		t.Error("session refused after reset")
+	}
+}
+
+func TestAutorunGate_TokenBudget(t *testing.T) {
+	limits := AutorunLimits{MaxTokens: 1000, Period: AutorunWeekly}
+	now := autorunBase
+	dataDir := t.TempDir()
+	g, rec := newTestGate(t, dataDir, &limits, &now)
+
+	g.RecordTokens("manual", 5000) // not driven by autorun
+	if !g.Admit("s1") {
+		t.Fatal("admit refused")
+	}
+	g.RecordTokens("s1", 1200)
+	if g.Admit("s1") {
+		t.Error("admitted over token budget")
+	}
+
+	// Usage survives a restart.
+	g2, _ := newTestGate(t, dataDir, &limits, &now)
+	if status := g2.Status(); status.Tokens != 1200 || status.Sessions != 1 {
+		t.Errorf("reloaded status = %+v", status)
+	}
+
+	// Raising the limit resumes without waiting for the weekly reset.
+	limits.MaxTokens = 5000
+	g.check()
+	if got := rec.types(); !slices.Equal(got, []string{AutorunEventBudgetExceeded, AutorunEventResumed}) {
+		t.Errorf("events = %v", got)
+	}
+}
+
+func TestAutorunGate_Schedule(t *testing.T) {
+	limits := AutorunLimits{Start: 9 * time.Hour, End: 17 * time.Hour}
+	now := autorunBase.Add(6 * time.Hour) // 18:00
+	g, rec := newTestGate(t, t.TempDir(), &limits, &now)
+
+	if g.Admit("s1") {
+		t.Fatal("admitted outside schedule")
+	}
+	status := g.Status()
+	if status.Reason != AutorunReasonSchedule || status.ResumesAt == nil || !status.ResumesAt.Equal(time.Date(2026, 3, 5, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("status = %+v", status)
+	}
+
+	now = time.Date(2026, 3, 5, 9, 1, 0, 0, time.Local)
+	g.check()
+	if got := rec.types(); !slices.Equal(got, []string{AutorunEventBudgetExceeded, AutorunEventResumed}) {
+		t.Errorf("events = %v", got)
+	}
+	if !g.Admit("s1") {
+		t.Error("refused inside schedule")
+	}
+}
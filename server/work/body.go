@@ -0,0 +1,188 @@
+package work
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A structured body opts in with a front matter block and splits the rest
+// into "## " sections, so agents can read acceptance criteria as data
+// instead of guessing at free text:
+//
+//	---
+//	priority: high
+//	component: api
+//	---
+//	## Context
+//	Why the change is needed.
+//	## Acceptance Criteria
+//	- [ ] list endpoint returns 200
+//	- [x] schema documented
+//	## Notes
+//	Anything else.
+//
+// Bodies that do not start with "---" are free text and are not parsed.
+
+const (
+	frontMatterDelim = "---"
+
+	SectionContext            = "Context"
+	SectionAcceptanceCriteria = "Acceptance Criteria"
+	SectionNotes              = "Notes"
+)
+
+var (
+	metadataKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	// Matches "- x", "* x", "1. x", each with an optional "[ ]" / "[x]".
+	criterionPattern = regexp.MustCompile(`^(?:[-*]|\d+\.)\s+(?:\[([ xX])\]\s+)?(.*)$`)
+)
+
+// StructuredBody is the parsed form of a structured body.
+type StructuredBody struct {
+	Metadata           map[string]string     `json:"metadata,omitempty"`
+	Context            string                `json:"context,omitempty"`
+	AcceptanceCriteria []AcceptanceCriterion `json:"acceptance_criteria,omitempty"`
+	Notes              string                `json:"notes,omitempty"`
+	// Sections holds any other "## " sections, in body order.
+	Sections []BodySection `json:"sections,omitempty"`
+}
+
+// AcceptanceCriterion is one list item of the Acceptance Criteria section.
+// Done is set for a checked "[x]" box.
+type AcceptanceCriterion struct {
+	Text string `json:"text"`
+	Done bool   `json:"done,omitempty"`
+}
+
+// BodySection is a section without a dedicated StructuredBody field.
+type BodySection struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// IsStructuredBody reports whether body opts into the structured format.
+func IsStructuredBody(body string) bool {
+	first, _, _ := strings.Cut(body, "\n")
+	return strings.TrimRight(first, " \t\r") == frontMatterDelim
+}
+
+// ParseBody parses a structured body. ok is false for a free-text body,
+// which is always valid. A malformed structured body returns an error
+// wrapping ErrInvalidWork.
+func ParseBody(body string) (sb StructuredBody, ok bool, err error) {
+	if !IsStructuredBody(body) {
+		return StructuredBody{}, false, nil
+	}
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], " \t") == frontMatterDelim {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return StructuredBody{}, true, fmt.Errorf("%w: body front matter is not closed with ---", ErrInvalidWork)
+	}
+	for i, line := range lines[1:end] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !found || !metadataKeyPattern.MatchString(key) {
+			return StructuredBody{}, true, fmt.Errorf("%w: body front matter line %d must be \"key: value\" with a lowercase key", ErrInvalidWork, i+2)
+		}
+		if _, dup := sb.Metadata[key]; dup {
+			return StructuredBody{}, true, fmt.Errorf("%w: body front matter repeats %q", ErrInvalidWork, key)
+		}
+		if sb.Metadata == nil {
+			sb.Metadata = make(map[string]string)
+		}
+		sb.Metadata[key] = strings.TrimSpace(value)
+	}
+
+	sections, err := splitSections(lines[end+1:])
+	if err != nil {
+		return StructuredBody{}, true, err
+	}
+	for _, s := range sections {
+		switch s.Title {
+		case SectionContext:
+			sb.Context = s.Content
+		case SectionNotes:
+			sb.Notes = s.Content
+		case SectionAcceptanceCriteria:
+			if sb.AcceptanceCriteria, err = parseCriteria(s.Content); err != nil {
+				return StructuredBody{}, true, err
+			}
+		default:
+			sb.Sections = append(sb.Sections, s)
+		}
+	}
+	return sb, true, nil
+}
+
+// ValidateBody checks that a structured body parses. Free text is valid.
+func ValidateBody(body string) error {
+	_, _, err := ParseBody(body)
+	return err
+}
+
+func splitSections(lines []string) ([]BodySection, error) {
+	var sections []BodySection
+	seen := make(map[string]bool)
+	var current *BodySection
+	var content []string
+	flush := func() {
+		if current != nil {
+			current.Content = strings.TrimSpace(strings.Join(content, "\n"))
+			sections = append(sections, *current)
+		}
+	}
+	for _, line := range lines {
+		if title, isHeading := strings.CutPrefix(line, "## "); isHeading {
+			flush()
+			title = strings.TrimSpace(title)
+			if seen[title] {
+				return nil, fmt.Errorf("%w: body repeats section %q", ErrInvalidWork, title)
+			}
+			seen[title] = true
+			current, content = &BodySection{Title: title}, nil
+			continue
+		}
+		if current == nil {
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("%w: body text after the front matter must be under a \"## \" section heading", ErrInvalidWork)
+			}
+			continue
+		}
+		content = append(content, line)
+	}
+	flush()
+	return sections, nil
+}
+
+// parseCriteria reads list items. An indented line continues the item above
+// it; blank lines are skipped.
+func parseCriteria(content string) ([]AcceptanceCriterion, error) {
+	var criteria []AcceptanceCriterion
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if m := criterionPattern.FindStringSubmatch(trimmed); m != nil && line == strings.TrimLeft(line, " \t") {
+			criteria = append(criteria, AcceptanceCriterion{Text: strings.TrimSpace(m[2]), Done: m[1] == "x" || m[1] == "X"})
+			continue
+		}
+		if len(criteria) == 0 || line == strings.TrimLeft(line, " \t") {
+			return nil, fmt.Errorf("%w: %s must be a list (\"- item\" or \"- [ ] item\")", ErrInvalidWork, SectionAcceptanceCriteria)
+		}
+		last := &criteria[len(criteria)-1]
+		last.Text += " " + trimmed
+	}
+	return criteria, nil
+}
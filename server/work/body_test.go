@@ -0,0 +1,91 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testStructuredBody = `---
+priority: high
+component: api
+---
+## Context
+The list endpoint times out.
+
+## Acceptance Criteria
+- [ ] returns 200 within 1s
+  for 10k items
+- [x] schema documented
+1. no regressions
+
+## Notes
+See #W-3.
+
+## Rollout
+Behind a flag.
+`
+
+func TestParseBody(t *testing.T) {
+	sb, ok, err := ParseBody(testStructuredBody)
+	if err != nil || !ok {
+		t.Fatalf("ParseBody = %v, %v; want structured", ok, err)
+	}
+	want := StructuredBody{
+		Metadata: map[string]string{"priority": "high", "component": "api"},
+		Context:  "The list endpoint times out.",
+		AcceptanceCriteria: []AcceptanceCriterion{
+			{Text: "returns 200 within 1s for 10k items"},
+			{Text: "schema documented", Done: true},
+			{Text: "no regressions"},
+		},
+		Notes:    "See #W-3.",
+		Sections: []BodySection{{Title: "Rollout", Content: "Behind a flag."}},
+	}
+	if !reflect.DeepEqual(sb, want) {
+		t.Errorf("ParseBody =\n%+v\nwant\n%+v", sb, want)
+	}
+
+	if _, ok, err := ParseBody("Free text\n## Context\nnot parsed"); ok || err != nil {
+		t.Errorf("free text = %v, %v; want not structured", ok, err)
+	}
+}
+
+func TestParseBody_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unclosed front matter": "---\npriority: high\n## Context\nx",
+		"bad metadata line":     "---\nnot metadata\n---\n",
+		"uppercase key":         "---\nPriority: high\n---\n",
+		"repeated key":          "---\na: 1\na: 2\n---\n",
+		"text outside section":  "---\n---\nstray text\n## Context\nx",
+		"repeated section":      "---\n---\n## Notes\na\n## Notes\nb",
+		"criteria not a list":   "---\n---\n## Acceptance Criteria\nit works",
+	}
+	for name, body := range tests {
+		if _, _, err := ParseBody(body); !errors.Is(err, ErrInvalidWork) {
+			t.Errorf("%s: err = %v, want ErrInvalidWork", name, err)
+		}
+	}
+}
+
+func TestStore_ValidatesStructuredBody(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if _, err := s.Create(ctx, Work{Type: WorkTypeStory, Title: "Bad", AgentRoleID: testRoleID, Body: "---\nunclosed"}); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("Create err = %v, want ErrInvalidWork", err)
+	}
+
+	w, err := s.Create(ctx, Work{Type: WorkTypeStory, Title: "Good", AgentRoleID: testRoleID, Body: testStructuredBody})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := "---\n---\n## Acceptance Criteria\nit works"
+	if err := s.Update(ctx, w.ID, UpdateFields{Body: &bad}); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("Update err = %v, want ErrInvalidWork", err)
+	}
+	if got, _, _ := s.Get(w.ID); got.Body != testStructuredBody {
+		t.Errorf("body changed by a rejected update: %q", got.Body)
+	}
+}
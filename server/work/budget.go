@@ -0,0 +1,192 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const budgetCheckInterval = time.Minute
+
+// Budget caps what a work item's agent sessions may use, so a stuck agent
+// cannot burn through the API budget on one task. Zero fields are
+// unlimited. Only users set it (work.update); agents cannot raise their
+// own.
+type Budget struct {
+	MaxSessions int   `json:"max_sessions,omitempty"` // fresh sessions started; restarts reuse theirs
+	MaxTokens   int64 `json:"max_tokens,omitempty"`   // tokens used by the sessions' turns
+	MaxMinutes  int   `json:"max_minutes,omitempty"`  // wall-clock since the work first started
+}
+
+func (b Budget) Validate() error {
+	if b.MaxSessions < 0 || b.MaxTokens < 0 || b.MaxMinutes < 0 {
+		return fmt.Errorf("%w: budget limits must not be negative", ErrInvalidWork)
+	}
+	return nil
+}
+
+// budgetExhausted returns which limit of its budget w has used up, or ""
+// while it may keep running. The session limit is not checked here: it
+// only refuses fresh sessions (see checkBudget).
+func (w Work) budgetExhausted(now time.Time) string {
+	b := w.Budget
+	if b == nil {
+		return ""
+	}
+	if b.MaxTokens > 0 && w.TokensUsed >= b.MaxTokens {
+		return fmt.Sprintf("used %d of %d tokens", w.TokensUsed, b.MaxTokens)
+	}
+	if b.MaxMinutes > 0 && !w.StartedAt.IsZero() {
+		if ran := int(now.Sub(w.StartedAt) / time.Minute); ran >= b.MaxMinutes {
+			return fmt.Sprintf("ran %d of %d minutes", ran, b.MaxMinutes)
+		}
+	}
+	return ""
+}
+
+// checkBudget refuses to start w when its budget is used up; fresh says the
+// start would create a new session.
+func (w Work) checkBudget(now time.Time, fresh bool) error {
+	if reason := w.budgetExhausted(now); reason != "" {
+		return transitionError{fmt.Errorf("%w: %w: %s", ErrInvalidWork, ErrBudgetExceeded, reason)}
+	}
+	if b := w.Budget; fresh && b != nil && b.MaxSessions > 0 && w.SessionsUsed >= b.MaxSessions {
+		return transitionError{fmt.Errorf("%w: %w: started %d of %d sessions", ErrInvalidWork, ErrBudgetExceeded, w.SessionsUsed, b.MaxSessions)}
+	}
+	return nil
+}
+
+// BudgetExceededEvent reports work whose budget ran out: its session was
+// closed and it moved to needs_attention. Reason matches AttentionReason.
+type BudgetExceededEvent struct {
+	Work   Work   `json:"work"`
+	Reason string `json:"reason"`
+}
+
+// OnBudgetExceededListener receives BudgetExceededEvents. Called from the
+// guard's goroutine or a session's event loop; implementations must not
+// block for long.
+type OnBudgetExceededListener interface {
+	OnBudgetExceeded(event BudgetExceededEvent)
+}
+
+// BudgetGuard enforces Work.Budget while agents run. The tokens of each
+// finished turn are added to the work the session belongs to (see
+// RecordTokens), and a check once a minute catches wall-clock limits. Active
+// work past a limit moves to needs_attention and its session is closed;
+// starting it again is refused until the budget is raised (see Store.Claim).
+type BudgetGuard struct {
+	store  Store
+	closer SessionCloser
+
+	listenersMu sync.Mutex
+	listeners   []OnBudgetExceededListener
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewBudgetGuard(store Store, closer SessionCloser) *BudgetGuard {
+	return &BudgetGuard{
+		store:  store,
+		closer: closer,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+func (g *BudgetGuard) AddOnBudgetExceededListener(l OnBudgetExceededListener) {
+	g.listenersMu.Lock()
+	defer g.listenersMu.Unlock()
+	g.listeners = append(g.listeners, l)
+}
+
+func (g *BudgetGuard) Start() {
+	go func() {
+		defer close(g.done)
+		ticker := time.NewTicker(budgetCheckInterval)
+		defer ticker.Stop()
+		g.check(context.Background(), time.Now())
+		for {
+			select {
+			case <-g.stop:
+				return
+			case now := <-ticker.C:
+				g.check(context.Background(), now)
+			}
+		}
+	}()
+}
+
+func (g *BudgetGuard) Stop() {
+	close(g.stop)
+	<-g.done
+}
+
+// RecordTokens adds tokens a session's turn used to its work item, if it
+// has one, and enforces the work's budget.
+func (g *BudgetGuard) RecordTokens(sessionID string, tokens int64) {
+	ctx := context.Background()
+	w, found, err := g.store.FindBySessionID(sessionID)
+	if err != nil {
+		slog.Warn("failed to find work for session", "sessionId", sessionID, "error", err)
+		return
+	}
+	if !found {
+		return
+	}
+	w, err = g.store.AddTokens(ctx, w.ID, tokens)
+	if err != nil {
+		slog.Warn("failed to record tokens on work", "workId", w.ID, "error", err)
+		return
+	}
+	g.enforce(ctx, w, time.Now())
+}
+
+func (g *BudgetGuard) check(ctx context.Context, now time.Time) {
+	works, err := g.store.List()
+	if err != nil {
+		slog.Error("failed to list works for budget check", "error", err)
+		return
+	}
+	for _, w := range works {
+		g.enforce(ctx, w, now)
+	}
+}
+
+func (g *BudgetGuard) enforce(ctx context.Context, w Work, now time.Time) {
+	if w.Status != StatusInProgress && w.Status != StatusNeedsInput && w.Status != StatusWaiting {
+		return
+	}
+	reason := w.budgetExhausted(now)
+	if reason == "" {
+		return
+	}
+	if err := g.store.MarkNeedsAttention(ctx, w.ID, reason); err != nil {
+		// Lost a race with another transition; the next start checks again.
+		if !errors.Is(err, ErrInvalidTransition) {
+			slog.Error("failed to mark work over budget", "workId", w.ID, "error", err)
+		}
+		return
+	}
+	slog.Info("work budget exceeded", "workId", w.ID, "sessionId", w.SessionID, "reason", reason)
+
+	// Closed asynchronously: RecordTokens runs on the session's own event
+	// loop, which closing waits for.
+	if g.closer != nil && w.SessionID != "" {
+		go g.closer.CloseSession(w.SessionID)
+	}
+
+	w.Status = StatusNeedsAttention
+	w.AttentionReason = reason
+	g.listenersMu.Lock()
+	listeners := slices.Clone(g.listeners)
+	g.listenersMu.Unlock()
+	for _, l := range listeners {
+		l.OnBudgetExceeded(BudgetExceededEvent{Work: w, Reason: reason})
+	}
+}
@@ -0,0 +1,182 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setBudget(t *testing.T, s *FileStore, id string, b Budget) {
+	t.Helper()
+	if err := s.Update(context.Background(), id, UpdateFields{Budget: &b}); err != nil {
+		t.Fatalf("set budget: %v", err)
+	}
+}
+
+func TestBudget_Validate(t *testing.T) {
+	s := newTestStore(t)
+	w := createStory(t, s, "Story")
+	err := s.Update(context.Background(), w.ID, UpdateFields{Budget: &Budget{MaxTokens: -1}})
+	if !errors.Is(err, ErrInvalidWork) {
+		t.Fatalf("negative limit err = %v, want ErrInvalidWork", err)
+	}
+
+	setBudget(t, s, w.ID, Budget{MaxTokens: 100})
+	if got := getWork(t, s, w.ID).Budget; got == nil || got.MaxTokens != 100 {
+		t.Fatalf("budget = %+v, want max_tokens 100", got)
+	}
+	setBudget(t, s, w.ID, Budget{})
+	if got := getWork(t, s, w.ID).Budget; got != nil {
+		t.Errorf("budget = %+v, want removed by an empty budget", got)
+	}
+}
+
+func TestFileStore_ClaimBudget(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	w := createStory(t, s, "Story")
+	setBudget(t, s, w.ID, Budget{MaxSessions: 1, MaxTokens: 100})
+
+	claimed, _, err := s.Claim(ctx, w.ID, StartOptions{})
+	if err != nil {
+		t.Fatalf("first claim: %v", err)
+	}
+	if claimed.SessionsUsed != 1 {
+		t.Errorf("sessions_used = %d, want 1", claimed.SessionsUsed)
+	}
+
+	// A restart reuses the session, so the used-up session limit allows it.
+	if err := s.Stop(ctx, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, restart, err := s.Claim(ctx, w.ID, StartOptions{}); err != nil || !restart {
+		t.Fatalf("restart = %v, %v; want allowed", restart, err)
+	}
+
+	// A fresh session is refused.
+	if err := s.Stop(ctx, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reset(ctx, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = s.Claim(ctx, w.ID, StartOptions{})
+	if !errors.Is(err, ErrBudgetExceeded) || !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("fresh claim err = %v, want ErrBudgetExceeded", err)
+	}
+
+	// Used-up tokens refuse restarts too.
+	setBudget(t, s, w.ID, Budget{MaxSessions: 2, MaxTokens: 100})
+	if _, _, err := s.Claim(ctx, w.ID, StartOptions{}); err != nil {
+		t.Fatalf("claim after raising sessions: %v", err)
+	}
+	if _, err := s.AddTokens(ctx, w.ID, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stop(ctx, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Claim(ctx, w.ID, StartOptions{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("restart over token budget err = %v, want ErrBudgetExceeded", err)
+	}
+}
+
+func TestFileStore_RollbackStartUncountsSession(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	w := createStory(t, s, "Story")
+	setBudget(t, s, w.ID, Budget{MaxSessions: 1})
+
+	if _, _, err := s.Claim(ctx, w.ID, StartOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RollbackStart(ctx, w.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := getWork(t, s, w.ID).SessionsUsed; got != 0 {
+		t.Fatalf("sessions_used = %d after a failed start, want 0", got)
+	}
+	if _, _, err := s.Claim(ctx, w.ID, StartOptions{}); err != nil {
+		t.Errorf("claim after rollback: %v", err)
+	}
+}
+
+type closedSessions struct {
+	ch chan string
+}
+
+func (c closedSessions) CloseSession(sessionID string) { c.ch <- sessionID }
+
+type budgetListener struct {
+	mu     sync.Mutex
+	events []BudgetExceededEvent
+}
+
+func (l *budgetListener) OnBudgetExceeded(e BudgetExceededEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func TestBudgetGuard_RecordTokens(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	w := createStory(t, s, "Story")
+	setBudget(t, s, w.ID, Budget{MaxTokens: 1000})
+	startWorkWithSession(t, s, w.ID, "sess-1")
+
+	closer := closedSessions{ch: make(chan string, 1)}
+	g := NewBudgetGuard(s, closer)
+	l := &budgetListener{}
+	g.AddOnBudgetExceededListener(l)
+
+	g.RecordTokens("sess-1", 600)
+	g.RecordTokens("other-session", 5000)
+	if got := getWork(t, s, w.ID); got.Status != StatusInProgress || got.TokensUsed != 600 {
+		t.Fatalf("after 600 tokens: status %s, tokens_used %d; want in_progress, 600", got.Status, got.TokensUsed)
+	}
+
+	g.RecordTokens("sess-1", 500)
+	got := getWork(t, s, w.ID)
+	if got.Status != StatusNeedsAttention || got.AttentionReason != "used 1100 of 1000 tokens" {
+		t.Fatalf("over budget: status %s, reason %q", got.Status, got.AttentionReason)
+	}
+	select {
+	case id := <-closer.ch:
+		if id != "sess-1" {
+			t.Errorf("closed session %s, want sess-1", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session was not closed")
+	}
+	if len(l.events) != 1 || l.events[0].Work.Status != StatusNeedsAttention || l.events[0].Reason != got.AttentionReason {
+		t.Errorf("events = %+v, want one budget_exceeded", l.events)
+	}
+
+	// Raising the budget lets the work restart in its session.
+	setBudget(t, s, w.ID, Budget{MaxTokens: 5000})
+	restarted, restart, err := s.Claim(ctx, w.ID, StartOptions{})
+	if err != nil || !restart || restarted.SessionID != "sess-1" || restarted.AttentionReason != "" {
+		t.Errorf("restart = %+v, %v, %v; want sess-1 reused and the reason cleared", restarted, restart, err)
+	}
+}
+
+func TestBudgetGuard_WallClock(t *testing.T) {
+	s := newTestStore(t)
+	w := createStory(t, s, "Story")
+	setBudget(t, s, w.ID, Budget{MaxMinutes: 60})
+	startWorkWithSession(t, s, w.ID, "sess-1")
+	started := getWork(t, s, w.ID).StartedAt
+
+	g := NewBudgetGuard(s, closedSessions{ch: make(chan string, 1)})
+	g.check(context.Background(), started.Add(59*time.Minute))
+	if got := getWork(t, s, w.ID).Status; got != StatusInProgress {
+		t.Fatalf("status = %s within the limit, want in_progress", got)
+	}
+	g.check(context.Background(), started.Add(61*time.Minute))
+	if got := getWork(t, s, w.ID); got.Status != StatusNeedsAttention || got.AttentionReason != "ran 61 of 60 minutes" {
+		t.Errorf("status %s, reason %q; want needs_attention", got.Status, got.AttentionReason)
+	}
+}
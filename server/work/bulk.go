@@ -0,0 +1,239 @@
+package work
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pockode/server/session"
+)
+
+// BulkAction is the kind of one operation in a bulk request.
+type BulkAction string
+
+const (
+	BulkUpdate BulkAction = "update"
+	BulkDelete BulkAction = "delete"
+	BulkStop   BulkAction = "stop"
+	BulkCancel BulkAction = "cancel"
+	BulkStart  BulkAction = "start"
+)
+
+// MaxBulkOps bounds one bulk request; a board selection is far smaller.
+const MaxBulkOps = 200
+
+// BulkOp is one operation of a bulk request. Fields applies to update,
+// Reason to cancel, and AgentRoleID / Mode to start.
+type BulkOp struct {
+	Action      BulkAction   `json:"action"`
+	ID          string       `json:"id"`
+	Fields      UpdateFields `json:"fields"`
+	Reason      string       `json:"reason,omitempty"`
+	AgentRoleID string       `json:"agent_role_id,omitempty"`
+	Mode        session.Mode `json:"mode,omitempty"`
+}
+
+// BulkResult reports the outcome of one BulkOp. Err keeps the typed error for
+// transports that map it; Affected lists the items the operation deleted,
+// stopped, cancelled, or started, so their sessions can be handled.
+type BulkResult struct {
+	ID       string     `json:"id"`
+	Action   BulkAction `json:"action"`
+	OK       bool       `json:"ok"`
+	Error    string     `json:"error,omitempty"`
+	Err      error      `json:"-"`
+	Affected []Work     `json:"-"`
+}
+
+func newBulkResult(op BulkOp, affected []Work, err error) BulkResult {
+	r := BulkResult{ID: op.ID, Action: op.Action, OK: err == nil, Err: err, Affected: affected}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+// ValidateBulkOps rejects a request that is empty or too large.
+func ValidateBulkOps(ops []BulkOp) error {
+	if len(ops) == 0 {
+		return fmt.Errorf("%w: no operations", ErrInvalidWork)
+	}
+	if len(ops) > MaxBulkOps {
+		return fmt.Errorf("%w: at most %d operations per request", ErrInvalidWork, MaxBulkOps)
+	}
+	return nil
+}
+
+// BulkAgentRoleIDs returns the distinct non-empty agent role IDs the
+// operations reference, for transports to validate before applying them.
+func BulkAgentRoleIDs(ops []BulkOp) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, op := range ops {
+		if op.Fields.AgentRoleID != nil {
+			add(*op.Fields.AgentRoleID)
+		}
+		add(op.AgentRoleID)
+	}
+	return ids
+}
+
+func (s *FileStore) Bulk(_ context.Context, ops []BulkOp) ([]BulkResult, error) {
+	if err := ValidateBulkOps(ops); err != nil {
+		return nil, err
+	}
+
+	s.worksMu.Lock()
+
+	prev := s.snapshotWorks()
+	now := time.Now()
+	modified := make(map[string]bool)
+	var deleted []Work
+	results := make([]BulkResult, len(ops))
+	for i, op := range ops {
+		affected, err := s.applyBulkOpLocked(op, now, modified)
+		if err == nil && op.Action == BulkDelete {
+			deleted = append(deleted, affected...)
+		}
+		results[i] = newBulkResult(op, affected, err)
+	}
+
+	if len(modified) == 0 && len(deleted) == 0 {
+		s.worksMu.Unlock()
+		return results, nil
+	}
+	if err := s.persistIndex(); err != nil {
+		s.works = prev
+		s.worksMu.Unlock()
+		return nil, err
+	}
+
+	events := s.updateEventsLocked(prev, modified)
+	for _, w := range deleted {
+		events = append(events, ChangeEvent{Op: OperationDelete, Work: w})
+	}
+	listeners := s.copyListeners()
+	s.worksMu.Unlock()
+
+	for _, e := range events {
+		notify(listeners, e)
+	}
+	return results, nil
+}
+
+// applyBulkOpLocked applies one operation to s.works. A failing operation
+// leaves s.works unchanged. Caller must hold s.worksMu.
+func (s *FileStore) applyBulkOpLocked(op BulkOp, now time.Time, modified map[string]bool) ([]Work, error) {
+	switch op.Action {
+	case BulkUpdate, BulkDelete, BulkStop, BulkCancel:
+	case BulkStart:
+		return nil, fmt.Errorf("%w: start is not a store operation", ErrInvalidWork)
+	default:
+		return nil, fmt.Errorf("%w: unknown bulk action %q", ErrInvalidWork, op.Action)
+	}
+
+	idx := s.findIndex(op.ID)
+	if idx < 0 {
+		return nil, ErrWorkNotFound
+	}
+	w := &s.works[idx]
+
+	switch op.Action {
+	case BulkUpdate:
+		if err := op.Fields.validate(); err != nil {
+			return nil, err
+		}
+		op.Fields.apply(w, now)
+		modified[w.ID] = true
+		if op.Fields.Body != nil {
+			s.linkReferencesLocked(idx, modified)
+		}
+		return nil, nil
+	case BulkDelete:
+		deleted := s.removeSubtreeLocked(op.ID)
+		for _, d := range deleted {
+			delete(modified, d.ID)
+		}
+		s.unlinkDeletedLocked(deleted, modified)
+		return deleted, nil
+	case BulkStop:
+		if !ValidateTransition(w.Status, StatusStopped) {
+			return nil, transitionErrorf("invalid transition %s → %s", w.Status, StatusStopped)
+		}
+		w.Status = StatusStopped
+		w.UpdatedAt = now
+		modified[w.ID] = true
+		return []Work{*w}, nil
+	default: // BulkCancel
+		if op.Reason == "" {
+			return nil, fmt.Errorf("%w: cancel reason is required", ErrInvalidWork)
+		}
+		if !ValidateTransition(w.Status, StatusCancelled) {
+			return nil, transitionErrorf("invalid transition %s → %s", w.Status, StatusCancelled)
+		}
+		return s.cancelLocked(idx, op.Reason, now, modified), nil
+	}
+}
+
+// Bulk applies a bulk request. Store operations run first as one atomic
+// batch (FileStore.Bulk); starts follow one by one through StartWork, since
+// launching an agent session cannot happen under the store lock. Sessions of
+// stopped and cancelled items are closed; deleted items are reported in
+// Affected for the caller to clean up. Callers validate agent roles
+// referenced by the operations.
+func (o *Operations) Bulk(ctx context.Context, ops []BulkOp) ([]BulkResult, error) {
+	if err := ValidateBulkOps(ops); err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkResult, len(ops))
+	var storeOps []BulkOp
+	var storeIdx []int
+	for i, op := range ops {
+		if op.Action != BulkStart {
+			storeOps = append(storeOps, op)
+			storeIdx = append(storeIdx, i)
+		}
+	}
+	if len(storeOps) > 0 {
+		storeResults, err := o.store.Bulk(ctx, storeOps)
+		if err != nil {
+			return nil, err
+		}
+		for j, r := range storeResults {
+			results[storeIdx[j]] = r
+		}
+	}
+
+	for i, op := range ops {
+		if op.Action != BulkStart {
+			continue
+		}
+		w, err := o.StartWork(ctx, op.ID, StartOptions{AgentRoleID: op.AgentRoleID, Mode: op.Mode})
+		var affected []Work
+		if err == nil {
+			affected = []Work{w}
+		}
+		results[i] = newBulkResult(op, affected, err)
+	}
+
+	if o.sessionCloser != nil {
+		for _, r := range results {
+			if !r.OK || (r.Action != BulkStop && r.Action != BulkCancel) {
+				continue
+			}
+			for _, w := range r.Affected {
+				if w.SessionID != "" {
+					o.sessionCloser.CloseSession(w.SessionID)
+				}
+			}
+		}
+	}
+	return results, nil
+}
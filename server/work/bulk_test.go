@@ -0,0 +1,164 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBulk_AppliesInOrderWithPartialFailure(t *testing.T) {
+	dataDir := t.TempDir()
+	store, err := NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	a := createStory(t, store, "A")
+	b := createStory(t, store, "B")
+	c := createStory(t, store, "C")
+	child := createTask(t, store, c.ID, "C child")
+	if _, err := store.Start(ctx, b.ID, "s-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []ChangeEvent
+	store.AddOnChangeListener(listenerFunc(func(e ChangeEvent) { events = append(events, e) }))
+
+	title := "A renamed"
+	negative := -1
+	results, err := store.Bulk(ctx, []BulkOp{
+		{Action: BulkUpdate, ID: a.ID, Fields: UpdateFields{Title: &title}},
+		{Action: BulkUpdate, ID: a.ID, Fields: UpdateFields{EstimateMinutes: &negative}},
+		{Action: BulkStop, ID: b.ID},
+		{Action: BulkStop, ID: a.ID}, // open → stopped is not allowed
+		{Action: BulkDelete, ID: c.ID},
+		{Action: BulkUpdate, ID: child.ID, Fields: UpdateFields{Title: &title}}, // deleted with its parent
+		{Action: BulkCancel, ID: a.ID},
+		{Action: "archive", ID: a.ID},
+	})
+	if err != nil {
+		t.Fatalf("Bulk: %v", err)
+	}
+
+	wantOK := []bool{true, false, true, false, true, false, false, false}
+	for i, r := range results {
+		if r.OK != wantOK[i] {
+			t.Errorf("results[%d] = %+v, want ok=%v", i, r, wantOK[i])
+		}
+	}
+	if !errors.Is(results[1].Err, ErrInvalidWork) || !errors.Is(results[5].Err, ErrWorkNotFound) {
+		t.Errorf("errors = %v / %v, want ErrInvalidWork / ErrWorkNotFound", results[1].Err, results[5].Err)
+	}
+	if len(results[4].Affected) != 2 {
+		t.Errorf("delete affected %d items, want story and child", len(results[4].Affected))
+	}
+
+	if got := getWork(t, store, a.ID); got.Title != "A renamed" || got.Status != StatusOpen {
+		t.Errorf("A = %q %s, want renamed and still open", got.Title, got.Status)
+	}
+	if got := getWork(t, store, b.ID); got.Status != StatusStopped {
+		t.Errorf("B status = %s, want stopped", got.Status)
+	}
+	if _, found, _ := store.Get(c.ID); found {
+		t.Error("C should be deleted")
+	}
+
+	// One update each for A and B, one delete each for C and its child.
+	ops := map[Operation]int{}
+	for _, e := range events {
+		ops[e.Op]++
+	}
+	if ops[OperationUpdate] != 2 || ops[OperationDelete] != 2 {
+		t.Errorf("events = %v, want 2 updates and 2 deletes", ops)
+	}
+
+	// Everything was persisted in the single write.
+	reloaded, err := NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := getWork(t, reloaded, b.ID); got.Status != StatusStopped {
+		t.Errorf("reloaded B status = %s, want stopped", got.Status)
+	}
+}
+
+func TestBulk_CancelCascades(t *testing.T) {
+	store := newTestStore(t)
+	story := createStory(t, store, "Story")
+	task := createTask(t, store, story.ID, "Task")
+
+	results, err := store.Bulk(context.Background(), []BulkOp{
+		{Action: BulkCancel, ID: story.ID, Reason: "dropped"},
+	})
+	if err != nil {
+		t.Fatalf("Bulk: %v", err)
+	}
+	if !results[0].OK || len(results[0].Affected) != 2 {
+		t.Fatalf("result = %+v, want story and task cancelled", results[0])
+	}
+	if got := getWork(t, store, task.ID); got.Status != StatusCancelled || got.CancelReason != "parent cancelled: dropped" {
+		t.Errorf("task = %s %q, want cancelled with parent reason", got.Status, got.CancelReason)
+	}
+}
+
+func TestBulk_RejectsEmptyAndOversized(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := store.Bulk(context.Background(), nil); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("empty: err = %v, want ErrInvalidWork", err)
+	}
+	ops := make([]BulkOp, MaxBulkOps+1)
+	if _, err := store.Bulk(context.Background(), ops); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("oversized: err = %v, want ErrInvalidWork", err)
+	}
+}
+
+func TestOperations_Bulk(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	running := createStory(t, store, "Running")
+	toStart := createStory(t, store, "To start")
+	if _, err := store.Start(ctx, running.ID, "s-running"); err != nil {
+		t.Fatal(err)
+	}
+	starter := &recordingStarter{}
+	closer := &recordingCloser{}
+	ops := NewOperations(store, starter, nil)
+	ops.SetSessionCloser(closer)
+
+	results, err := ops.Bulk(ctx, []BulkOp{
+		{Action: BulkStart, ID: toStart.ID},
+		{Action: BulkCancel, ID: running.ID, Reason: "superseded"},
+		{Action: BulkStart, ID: "missing"},
+	})
+	if err != nil {
+		t.Fatalf("Bulk: %v", err)
+	}
+	if !results[0].OK || !results[1].OK || results[2].OK {
+		t.Fatalf("results = %+v, want start ok, cancel ok, missing start failed", results)
+	}
+	if results[0].ID != toStart.ID || results[1].Action != BulkCancel {
+		t.Errorf("results out of request order: %+v", results)
+	}
+	if starter.calls != 1 {
+		t.Errorf("HandleWorkStart called %d times, want 1", starter.calls)
+	}
+	if got := getWork(t, store, toStart.ID); got.Status != StatusInProgress {
+		t.Errorf("started status = %s, want in_progress", got.Status)
+	}
+	if len(closer.closed) != 1 || closer.closed[0] != "s-running" {
+		t.Errorf("closed sessions = %v, want [s-running]", closer.closed)
+	}
+}
+
+func TestBulkAgentRoleIDs(t *testing.T) {
+	role := "r1"
+	got := BulkAgentRoleIDs([]BulkOp{
+		{Action: BulkUpdate, Fields: UpdateFields{AgentRoleID: &role}},
+		{Action: BulkStart, AgentRoleID: "r2"},
+		{Action: BulkStart, AgentRoleID: "r1"},
+		{Action: BulkStop},
+	})
+	if len(got) != 2 || got[0] != "r1" || got[1] != "r2" {
+		t.Errorf("got %v, want [r1 r2]", got)
+	}
+}
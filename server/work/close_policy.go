@@ -0,0 +1,105 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCloseApprovalRequired is returned by StepDone when the work's close
+// policy is ClosePolicyExplicit. ApproveClose closes it.
+var ErrCloseApprovalRequired = errors.New("close approval required")
+
+// ValidateClosePolicy checks a close policy. Errors wrap ErrInvalidWork.
+func ValidateClosePolicy(p ClosePolicy) error {
+	switch p {
+	case ClosePolicyInherit, ClosePolicyAuto, ClosePolicyExplicit, ClosePolicyReviewGate:
+		return nil
+	}
+	return fmt.Errorf("%w: close_policy must be auto_close, require_explicit_close, review_gate, or empty", ErrInvalidWork)
+}
+
+// closePolicyLocked returns w's close policy after inheritance, resolving
+// ClosePolicyInherit at the top to the review gate when a review role is
+// set. Caller must hold s.worksMu (read or write).
+func (s *FileStore) closePolicyLocked(w Work) ClosePolicy {
+	for seen := map[string]bool{}; w.ClosePolicy == ClosePolicyInherit && w.ParentID != "" && !seen[w.ID]; {
+		seen[w.ID] = true
+		idx := s.findIndex(w.ParentID)
+		if idx < 0 {
+			break
+		}
+		w = s.works[idx]
+	}
+	if w.ClosePolicy != ClosePolicyInherit {
+		return w.ClosePolicy
+	}
+	if s.reviewRole != nil && s.reviewRole() != "" {
+		return ClosePolicyReviewGate
+	}
+	return ClosePolicyAuto
+}
+
+// closeGateLocked decides whether w may close on its final StepDone under
+// its close policy. Caller must hold s.worksMu (read or write).
+func (s *FileStore) closeGateLocked(w Work) error {
+	switch s.closePolicyLocked(w) {
+	case ClosePolicyExplicit:
+		return ErrCloseApprovalRequired
+	case ClosePolicyReviewGate:
+		if w.Type != WorkTypeStory {
+			return nil
+		}
+		return s.reviewGateLocked(w)
+	default:
+		return nil
+	}
+}
+
+func (s *FileStore) ApproveClose(ctx context.Context, id string) (Work, error) {
+	w, found, err := s.Get(id)
+	if err != nil {
+		return Work{}, err
+	}
+	if !found {
+		return Work{}, ErrWorkNotFound
+	}
+	// Work that cannot close is left for the checks below to report.
+	if (w.Status == StatusInProgress || w.Status == StatusNeedsInput) && !w.InPlanPhase() {
+		if err := s.beforeHooks(ctx, w, LifecycleDone, LifecycleClosed); err != nil {
+			return Work{}, err
+		}
+	}
+
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return Work{}, ErrWorkNotFound
+	}
+	p := &s.works[idx]
+	if p.Status != StatusInProgress && p.Status != StatusNeedsInput {
+		s.worksMu.Unlock()
+		return Work{}, transitionErrorf("ApproveClose requires in_progress or needs_input status, got %s", p.Status)
+	}
+	if p.InPlanPhase() {
+		s.worksMu.Unlock()
+		return Work{}, transitionErrorf("plan is %s; approve the plan first", p.Plan.Status)
+	}
+
+	prev := s.snapshotWorks()
+
+	p.Status = StatusClosed
+	p.UpdatedAt = time.Now()
+	p.ClosedAt = p.UpdatedAt
+	result := *p
+
+	modified := map[string]bool{id: true}
+	if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
+		return Work{}, err
+	}
+	s.afterHooks(result, LifecycleDone, LifecycleClosed)
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func setClosePolicy(t *testing.T, s *FileStore, id string, p ClosePolicy) {
+	t.Helper()
+	if err := s.Update(context.Background(), id, UpdateFields{ClosePolicy: &p}); err != nil {
+		t.Fatalf("set close policy %q: %v", p, err)
+	}
+}
+
+func TestStepDone_ClosePolicyAuto(t *testing.T) {
+	store := newTestStore(t)
+	store.SetReviewRole(func() string { return reviewRoleID })
+
+	story := createStory(t, store, "Story")
+	setClosePolicy(t, store, story.ID, ClosePolicyAuto)
+	startWork(t, store, story.ID)
+
+	// auto_close skips the review the review role would otherwise require.
+	if _, err := store.StepDone(context.Background(), story.ID, 0); err != nil {
+		t.Fatalf("StepDone: %v", err)
+	}
+	if got := getWork(t, store, story.ID); got.Status != StatusClosed {
+		t.Errorf("status = %q, want closed", got.Status)
+	}
+}
+
+func TestStepDone_ClosePolicyExplicit(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	hooks := &hookRecorder{}
+	store.SetLifecycleHooks(hooks)
+
+	story := createStory(t, store, "Story")
+	setClosePolicy(t, store, story.ID, ClosePolicyExplicit)
+	task := createTask(t, store, story.ID, "Task")
+	startWork(t, store, story.ID)
+	startWork(t, store, task.ID)
+	hooks.take()
+
+	// Tasks inherit the story's policy.
+	if _, err := store.StepDone(ctx, task.ID, 0); !errors.Is(err, ErrCloseApprovalRequired) {
+		t.Fatalf("task StepDone: err = %v, want ErrCloseApprovalRequired", err)
+	}
+	if got := getWork(t, store, task.ID); got.Status != StatusInProgress {
+		t.Fatalf("task status = %q, want in_progress", got.Status)
+	}
+	if calls := hooks.take(); len(calls) != 0 {
+		t.Errorf("refused StepDone ran hooks %v", calls)
+	}
+
+	// Steps before the last still advance.
+	if more, err := store.StepDone(ctx, story.ID, 2); err != nil || !more {
+		t.Fatalf("StepDone(step 1 of 2) = %v, %v; want more steps", more, err)
+	}
+	hooks.take()
+
+	if err := store.MarkNeedsInput(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	closed, err := store.ApproveClose(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("ApproveClose: %v", err)
+	}
+	if closed.Status != StatusClosed || closed.ClosedAt.IsZero() {
+		t.Errorf("approved task = %+v, want closed", closed)
+	}
+	if got, want := hooks.take(), []string{"before:done", "before:closed", "after:done", "after:closed"}; !slices.Equal(got, want) {
+		t.Errorf("ApproveClose hooks = %v, want %v", got, want)
+	}
+	if _, err := store.ApproveClose(ctx, task.ID); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("ApproveClose on closed work: err = %v, want ErrInvalidWork", err)
+	}
+}
+
+func TestStepDone_ClosePolicyReviewGate(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	story := createStory(t, store, "Story")
+	setClosePolicy(t, store, story.ID, ClosePolicyReviewGate)
+	task := createTask(t, store, story.ID, "Task")
+	startWork(t, store, story.ID)
+	startWork(t, store, task.ID)
+
+	// Tasks close and are reviewed with their story.
+	doneWork(t, store, task.ID)
+
+	// The gate applies even though no review role is set, so the close is
+	// refused rather than skipping the review.
+	if _, err := store.StepDone(ctx, story.ID, 0); !errors.Is(err, ErrReviewRequired) {
+		t.Fatalf("StepDone without review: err = %v, want ErrReviewRequired", err)
+	}
+
+	// Clearing the policy falls back to the default, auto-close without a
+	// review role.
+	setClosePolicy(t, store, story.ID, ClosePolicyInherit)
+	if _, err := store.StepDone(ctx, story.ID, 0); err != nil {
+		t.Fatalf("StepDone after clearing the policy: %v", err)
+	}
+}
+
+func TestValidateClosePolicy(t *testing.T) {
+	store := newTestStore(t)
+	story := createStory(t, store, "Story")
+
+	bad := ClosePolicy("sometimes")
+	if err := store.Update(context.Background(), story.ID, UpdateFields{ClosePolicy: &bad}); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("Update with %q: err = %v, want ErrInvalidWork", bad, err)
+	}
+}
@@ -0,0 +1,276 @@
+package work
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDueLeads are the reminder lead times used when none are
+	// configured: a day ahead and an hour ahead.
+	DefaultDueLeads = "24h,1h"
+	// DueLeadsOff disables due date reminders.
+	DueLeadsOff = "off"
+
+	maxDueLeads          = 8
+	dueCheckInterval     = time.Minute
+	dueWebhookTimeout    = 10 * time.Second
+	dueWebhookMaxPending = 16
+)
+
+// ParseDueAt parses an RFC 3339 due date. An empty string is the zero time,
+// which clears the due date.
+func ParseDueAt(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: due_at must be an RFC 3339 timestamp", ErrInvalidWork)
+	}
+	return t, nil
+}
+
+// ParseDueLeads parses comma-separated reminder lead times ("24h,1h,15m")
+// into distinct durations, longest first. Empty means DefaultDueLeads and
+// DueLeadsOff returns none. A lead of 0 fires when the work falls due.
+func ParseDueLeads(s string) ([]time.Duration, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "":
+		s = DefaultDueLeads
+	case DueLeadsOff:
+		return nil, nil
+	}
+	var leads []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid due reminder lead %q: must be a non-negative duration like 24h or 30m", part)
+		}
+		if !slices.Contains(leads, d) {
+			leads = append(leads, d)
+		}
+	}
+	if len(leads) > maxDueLeads {
+		return nil, fmt.Errorf("at most %d due reminder leads", maxDueLeads)
+	}
+	slices.SortFunc(leads, func(a, b time.Duration) int { return int(b - a) })
+	return leads, nil
+}
+
+// DueSoonEvent is one reminder: the work is due within LeadMinutes, or
+// already past due when Overdue is set.
+type DueSoonEvent struct {
+	Work        Work `json:"work"`
+	LeadMinutes int  `json:"lead_minutes"`
+	Overdue     bool `json:"overdue"`
+}
+
+// OnDueSoonListener receives due date reminders. Called from the
+// reminder's goroutine; implementations must not block for long.
+type OnDueSoonListener interface {
+	OnWorkDueSoon(event DueSoonEvent)
+}
+
+// dueSent is the last reminder sent for a work item's current due date.
+type dueSent struct {
+	dueAt time.Time
+	lead  time.Duration
+}
+
+// DueReminder checks once a minute for unfinished work whose due date is
+// within a configured lead time and emits one DueSoonEvent per lead. When
+// several leads pass between checks (or while the server was down) only the
+// shortest is sent. Sent reminders are kept in memory, so a restart repeats
+// at most the latest reminder of each item; changing an item's due date
+// re-arms all of its leads.
+type DueReminder struct {
+	store       Store
+	leads       func() []time.Duration
+	webhook     func() string
+	watchedOnly func() bool
+	client      *http.Client
+
+	listenersMu sync.Mutex
+	listeners   []OnDueSoonListener
+
+	sent map[string]dueSent // owned by the check loop
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDueReminder creates a reminder reading lead times from leads on every
+// check, so settings changes apply without a restart.
+func NewDueReminder(store Store, leads func() []time.Duration) *DueReminder {
+	return &DueReminder{
+		store:  store,
+		leads:  leads,
+		client: &http.Client{Timeout: dueWebhookTimeout},
+		sent:   make(map[string]dueSent),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// SetWebhook sets a provider of the URL each reminder is POSTed to as JSON;
+// an empty URL skips delivery.
+func (r *DueReminder) SetWebhook(url func() string) {
+	r.webhook = url
+}
+
+// SetWatchedOnly sets a provider of whether only watched items get
+// reminders (see NotifyFilter). Muted items never do.
+func (r *DueReminder) SetWatchedOnly(fn func() bool) {
+	r.watchedOnly = fn
+}
+
+func (r *DueReminder) AddOnDueSoonListener(l OnDueSoonListener) {
+	r.listenersMu.Lock()
+	defer r.listenersMu.Unlock()
+	r.listeners = append(r.listeners, l)
+}
+
+func (r *DueReminder) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(dueCheckInterval)
+		defer ticker.Stop()
+		r.check(context.Background(), time.Now())
+		for {
+			select {
+			case <-r.stop:
+				return
+			case now := <-ticker.C:
+				r.check(context.Background(), now)
+			}
+		}
+	}()
+}
+
+func (r *DueReminder) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *DueReminder) check(ctx context.Context, now time.Time) {
+	leads := r.leads()
+	if len(leads) == 0 {
+		return
+	}
+	works, err := r.store.List()
+	if err != nil {
+		slog.Error("failed to list works for due reminders", "error", err)
+		return
+	}
+
+	filter := NewNotifyFilter(works, r.watchedOnly != nil && r.watchedOnly())
+
+	sent := make(map[string]dueSent, len(r.sent))
+	var events []DueSoonEvent
+	for _, w := range works {
+		if w.DueAt.IsZero() || w.Status == StatusClosed || w.Status == StatusCancelled || !filter.Allows(w) {
+			continue
+		}
+		lead, ok := dueLead(leads, w.DueAt, now)
+		if !ok {
+			continue
+		}
+		prev, found := r.sent[w.ID]
+		if found && prev.dueAt.Equal(w.DueAt) && prev.lead <= lead {
+			sent[w.ID] = prev
+			continue
+		}
+		sent[w.ID] = dueSent{dueAt: w.DueAt, lead: lead}
+		events = append(events, DueSoonEvent{
+			Work:        w,
+			LeadMinutes: int(lead / time.Minute),
+			Overdue:     now.After(w.DueAt),
+		})
+	}
+	// Rebuilt each check so finished, deleted, undated, and muted items drop out.
+	r.sent = sent
+
+	for _, e := range events {
+		slog.Info("work due soon", "workId", e.Work.ID, "dueAt", e.Work.DueAt, "leadMinutes", e.LeadMinutes, "overdue", e.Overdue)
+		r.notify(e)
+	}
+	r.deliver(ctx, events)
+}
+
+// dueLead returns the shortest lead whose reminder time has passed.
+// leads is sorted longest first.
+func dueLead(leads []time.Duration, dueAt, now time.Time) (time.Duration, bool) {
+	for i := len(leads) - 1; i >= 0; i-- {
+		if !now.Before(dueAt.Add(-leads[i])) {
+			return leads[i], true
+		}
+	}
+	return 0, false
+}
+
+func (r *DueReminder) notify(e DueSoonEvent) {
+	r.listenersMu.Lock()
+	listeners := slices.Clone(r.listeners)
+	r.listenersMu.Unlock()
+	for _, l := range listeners {
+		l.OnWorkDueSoon(e)
+	}
+}
+
+// deliver POSTs each reminder to the webhook. A burst after downtime is
+// capped so a dead endpoint cannot hold the check loop for minutes.
+func (r *DueReminder) deliver(ctx context.Context, events []DueSoonEvent) {
+	if r.webhook == nil || len(events) == 0 {
+		return
+	}
+	url := r.webhook()
+	if url == "" {
+		return
+	}
+	if len(events) > dueWebhookMaxPending {
+		slog.Warn("due reminder webhook backlog truncated", "dropped", len(events)-dueWebhookMaxPending)
+		events = events[:dueWebhookMaxPending]
+	}
+	for _, e := range events {
+		if err := postDueReminder(ctx, r.client, url, e); err != nil {
+			slog.Error("failed to deliver due reminder", "workId", e.Work.ID, "error", err)
+		}
+	}
+}
+
+func postDueReminder(ctx context.Context, client *http.Client, url string, e DueSoonEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal due reminder: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
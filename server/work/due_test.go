@@ -0,0 +1,143 @@
+package work
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseDueLeads(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []time.Duration
+		wantErr bool
+	}{
+		{in: "", want: []time.Duration{24 * time.Hour, time.Hour}},
+		{in: "off", want: nil},
+		{in: "15m, 2h,15m,0s", want: []time.Duration{2 * time.Hour, 15 * time.Minute, 0}},
+		{in: "tomorrow", wantErr: true},
+		{in: "-1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDueLeads(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDueLeads(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseDueLeads(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseDueAt(t *testing.T) {
+	if got, err := ParseDueAt(""); err != nil || !got.IsZero() {
+		t.Errorf("empty = %v, %v; want zero", got, err)
+	}
+	if got, err := ParseDueAt("2026-03-01T17:00:00Z"); err != nil || !got.Equal(time.Date(2026, 3, 1, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 = %v, %v", got, err)
+	}
+	if _, err := ParseDueAt("next week"); err == nil {
+		t.Error("expected an error for a malformed date")
+	}
+}
+
+type dueListener struct {
+	mu     sync.Mutex
+	events []DueSoonEvent
+}
+
+func (l *dueListener) OnWorkDueSoon(e DueSoonEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func (l *dueListener) take() []DueSoonEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := l.events
+	l.events = nil
+	return events
+}
+
+func TestDueReminder(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	due := now.Add(3 * time.Hour)
+
+	story := createStory(t, store, "Release")
+	if err := store.Update(ctx, story.ID, UpdateFields{DueAt: &due}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	undated := createStory(t, store, "Someday")
+
+	var posted []DueSoonEvent
+	var postedMu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e DueSoonEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		postedMu.Lock()
+		posted = append(posted, e)
+		postedMu.Unlock()
+	}))
+	defer srv.Close()
+
+	r := NewDueReminder(store, func() []time.Duration { return []time.Duration{24 * time.Hour, time.Hour, 0} })
+	r.SetWebhook(func() string { return srv.URL })
+	l := &dueListener{}
+	r.AddOnDueSoonListener(l)
+
+	// Within 24h: one reminder, not repeated on the next check.
+	r.check(ctx, now)
+	events := l.take()
+	if len(events) != 1 || events[0].Work.ID != story.ID || events[0].LeadMinutes != 24*60 || events[0].Overdue {
+		t.Fatalf("first check = %+v, want one 24h reminder for %s", events, story.ID)
+	}
+	r.check(ctx, now.Add(time.Minute))
+	if events := l.take(); len(events) != 0 {
+		t.Errorf("repeated reminder: %+v", events)
+	}
+
+	// The 1h and due-time leads both pass before the next check: only the
+	// shortest is sent.
+	r.check(ctx, now.Add(4*time.Hour))
+	events = l.take()
+	if len(events) != 1 || events[0].LeadMinutes != 0 || !events[0].Overdue {
+		t.Fatalf("after due = %+v, want one overdue reminder", events)
+	}
+
+	// A new due date re-arms the leads; closed work gets no reminders.
+	later := now.Add(30 * time.Hour)
+	if err := store.Update(ctx, story.ID, UpdateFields{DueAt: &later}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	r.check(ctx, now.Add(7*time.Hour))
+	if events := l.take(); len(events) != 1 || events[0].LeadMinutes != 24*60 {
+		t.Errorf("after reschedule = %+v, want a 24h reminder", events)
+	}
+	if err := store.Update(ctx, undated.ID, UpdateFields{DueAt: &due}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := store.Cancel(ctx, undated.ID, "dropped"); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	r.check(ctx, now.Add(8*time.Hour))
+	if events := l.take(); len(events) != 0 {
+		t.Errorf("cancelled work reminded: %+v", events)
+	}
+
+	postedMu.Lock()
+	defer postedMu.Unlock()
+	if len(posted) != 3 || posted[0].Work.ID != story.ID {
+		t.Errorf("webhook received %+v, want the 3 reminders", posted)
+	}
+}
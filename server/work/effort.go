@@ -0,0 +1,58 @@
+package work
+
+// Effort is planned vs actual effort in minutes.
+type Effort struct {
+	EstimateMinutes  int `json:"estimate_minutes"`
+	TimeSpentMinutes int `json:"time_spent_minutes"`
+}
+
+func (e Effort) add(o Effort) Effort {
+	return Effort{
+		EstimateMinutes:  e.EstimateMinutes + o.EstimateMinutes,
+		TimeSpentMinutes: e.TimeSpentMinutes + o.TimeSpentMinutes,
+	}
+}
+
+// EffortReport compares planned and actual effort for a work item. Own is
+// the item's own fields; Total adds every descendant, so a story's Total is
+// the rollup of its tasks plus any effort logged on the story itself.
+type EffortReport struct {
+	WorkID   string         `json:"work_id"`
+	Type     WorkType       `json:"type"`
+	Title    string         `json:"title"`
+	Status   WorkStatus     `json:"status"`
+	Own      Effort         `json:"own"`
+	Total    Effort         `json:"total"`
+	Children []EffortReport `json:"children,omitempty"`
+}
+
+// BuildEffortReport builds the effort report for id from a snapshot of all
+// works. Children are in the order of works. Returns false if id is not in
+// works.
+func BuildEffortReport(works []Work, id string) (EffortReport, bool) {
+	byID := make(map[string]Work, len(works))
+	children := make(map[string][]string)
+	for _, w := range works {
+		byID[w.ID] = w
+		if w.ParentID != "" {
+			children[w.ParentID] = append(children[w.ParentID], w.ID)
+		}
+	}
+	if _, ok := byID[id]; !ok {
+		return EffortReport{}, false
+	}
+
+	var build func(id string) EffortReport
+	build = func(id string) EffortReport {
+		w := byID[id]
+		own := Effort{EstimateMinutes: w.EstimateMinutes, TimeSpentMinutes: w.TimeSpentMinutes}
+		r := EffortReport{WorkID: w.ID, Type: w.Type, Title: w.Title, Status: w.Status, Own: own, Total: own}
+		for _, childID := range children[id] {
+			child := build(childID)
+			r.Total = r.Total.add(child.Total)
+			r.Children = append(r.Children, child)
+		}
+		return r
+	}
+	return build(id), true
+}
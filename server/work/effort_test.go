@@ -0,0 +1,35 @@
+package work
+
+import "testing"
+
+func TestBuildEffortReport_RollsUpTasks(t *testing.T) {
+	works := []Work{
+		{ID: "s1", Type: WorkTypeStory, Title: "Story", Status: StatusInProgress, EstimateMinutes: 60, TimeSpentMinutes: 10},
+		{ID: "t1", Type: WorkTypeTask, ParentID: "s1", Status: StatusClosed, EstimateMinutes: 30, TimeSpentMinutes: 45},
+		{ID: "s2", Type: WorkTypeStory, EstimateMinutes: 999},
+		{ID: "t2", Type: WorkTypeTask, ParentID: "s1", Status: StatusOpen, EstimateMinutes: 20},
+	}
+
+	report, ok := BuildEffortReport(works, "s1")
+	if !ok {
+		t.Fatal("expected report for s1")
+	}
+	if want := (Effort{EstimateMinutes: 60, TimeSpentMinutes: 10}); report.Own != want {
+		t.Errorf("Own = %+v, want %+v", report.Own, want)
+	}
+	if want := (Effort{EstimateMinutes: 110, TimeSpentMinutes: 55}); report.Total != want {
+		t.Errorf("Total = %+v, want %+v", report.Total, want)
+	}
+	if len(report.Children) != 2 || report.Children[0].WorkID != "t1" || report.Children[1].WorkID != "t2" {
+		t.Fatalf("Children = %+v, want t1, t2", report.Children)
+	}
+	if report.Children[0].Total != report.Children[0].Own {
+		t.Errorf("leaf Total %+v != Own %+v", report.Children[0].Total, report.Children[0].Own)
+	}
+}
+
+func TestBuildEffortReport_NotFound(t *testing.T) {
+	if _, ok := BuildEffortReport([]Work{{ID: "s1"}}, "missing"); ok {
+		t.Error("expected not found")
+	}
+}
@@ -0,0 +1,50 @@
+package work
+
+import "slices"
+
+// WorkFiles is the files one work item's sessions changed.
+type WorkFiles struct {
+	WorkID string   `json:"work_id"`
+	Title  string   `json:"title"`
+	Files  []string `json:"files"`
+}
+
+// CollectModifiedFiles gathers the files changed by the work item id and its
+// descendants: their sorted union, and the per-item lists (id first, then
+// descendants in store order) for items that changed anything. found is
+// false when id doesn't exist.
+func CollectModifiedFiles(works []Work, id string) (files []string, byWork []WorkFiles, found bool) {
+	ids := CollectDescendantIDs(works, id)
+	seen := make(map[string]bool)
+	files = []string{}
+	byWork = []WorkFiles{}
+	add := func(w Work) {
+		if len(w.ModifiedFiles) == 0 {
+			return
+		}
+		byWork = append(byWork, WorkFiles{WorkID: w.ID, Title: w.Title, Files: w.ModifiedFiles})
+		for _, f := range w.ModifiedFiles {
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+
+	for _, w := range works {
+		if w.ID == id {
+			found = true
+			add(w)
+		}
+	}
+	if !found {
+		return nil, nil, false
+	}
+	for _, w := range works {
+		if w.ID != id && ids[w.ID] {
+			add(w)
+		}
+	}
+	slices.Sort(files)
+	return files, byWork, true
+}
@@ -0,0 +1,40 @@
+package work
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCollectModifiedFiles(t *testing.T) {
+	works := []Work{
+		{ID: "task1", ParentID: "story", Title: "T1", ModifiedFiles: []string{"b.go", "c.go"}},
+		{ID: "story", Title: "S", ModifiedFiles: []string{"a.go"}},
+		{ID: "task2", ParentID: "story", Title: "T2"},
+		{ID: "other", Title: "O", ModifiedFiles: []string{"z.go"}},
+		{ID: "sub", ParentID: "task1", Title: "Sub", ModifiedFiles: []string{"a.go", "d.go"}},
+	}
+
+	files, byWork, found := CollectModifiedFiles(works, "story")
+	if !found {
+		t.Fatal("expected story to be found")
+	}
+	if want := []string{"a.go", "b.go", "c.go", "d.go"}; !slices.Equal(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+	var ids []string
+	for _, wf := range byWork {
+		ids = append(ids, wf.WorkID)
+	}
+	if want := []string{"story", "task1", "sub"}; !slices.Equal(ids, want) {
+		t.Errorf("byWork ids = %v, want %v (root first, no empty items)", ids, want)
+	}
+
+	files, byWork, found = CollectModifiedFiles(works, "task2")
+	if !found || len(files) != 0 || len(byWork) != 0 {
+		t.Errorf("task2: got %v %v %v, want found with no files", files, byWork, found)
+	}
+
+	if _, _, found := CollectModifiedFiles(works, "missing"); found {
+		t.Error("expected missing work not to be found")
+	}
+}
@@ -0,0 +1,100 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrHookRefused is returned when a lifecycle hook blocks a transition.
+var ErrHookRefused = errors.New("refused by work hook")
+
+// LifecycleEvent names a work transition that lifecycle hooks can run on.
+type LifecycleEvent string
+
+const (
+	LifecycleCreated LifecycleEvent = "created"
+	LifecycleStarted LifecycleEvent = "started" // Start or Claim, including restarts
+	LifecycleDone    LifecycleEvent = "done"    // StepDone, whether it advances a step or closes
+	LifecycleClosed  LifecycleEvent = "closed"  // StepDone on the last step
+)
+
+func (e LifecycleEvent) IsValid() bool {
+	switch e {
+	case LifecycleCreated, LifecycleStarted, LifecycleDone, LifecycleClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+// LifecycleHooks runs user-defined actions on work transitions.
+//
+// Before is called ahead of the transition, outside the store lock, with
+// the work as it is before the change (a Create passes the requested work,
+// which has no ID yet). A non-nil error refuses the transition. After is
+// called once the change is persisted, with the work as it is now; it must
+// not block.
+type LifecycleHooks interface {
+	Before(ctx context.Context, event LifecycleEvent, w Work) error
+	After(event LifecycleEvent, w Work)
+}
+
+// SetLifecycleHooks installs hooks for Create, Start, Claim, StepDone and
+// ApproveClose.
+// Call before the store is shared.
+func (s *FileStore) SetLifecycleHooks(h LifecycleHooks) {
+	s.hooks = h
+}
+
+// beforeHooks runs the Before hooks for each event in order and wraps the
+// first refusal in ErrHookRefused.
+func (s *FileStore) beforeHooks(ctx context.Context, w Work, events ...LifecycleEvent) error {
+	if s.hooks == nil {
+		return nil
+	}
+	for _, e := range events {
+		if err := s.hooks.Before(ctx, e, w); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrHookRefused, e, err)
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) afterHooks(w Work, events ...LifecycleEvent) {
+	if s.hooks == nil {
+		return
+	}
+	for _, e := range events {
+		s.hooks.After(e, w)
+	}
+}
+
+// startHooks runs the started hooks ahead of Start or Claim. Work that
+// cannot start is left for the transition to report, so no hook runs for it.
+func (s *FileStore) startHooks(ctx context.Context, id string) error {
+	if s.hooks == nil {
+		return nil
+	}
+	w, found, err := s.Get(id)
+	if err != nil || !found || !ValidateTransition(w.Status, StatusInProgress) {
+		return err
+	}
+	return s.beforeHooks(ctx, w, LifecycleStarted)
+}
+
+// stepDoneHooks runs the done hooks ahead of StepDone, and the closed hooks
+// too when the step is the last one.
+func (s *FileStore) stepDoneHooks(ctx context.Context, id string, totalSteps int) error {
+	if s.hooks == nil {
+		return nil
+	}
+	w, found, err := s.Get(id)
+	if err != nil || !found || w.Status != StatusInProgress || w.InPlanPhase() {
+		return err
+	}
+	if totalSteps > 0 && w.CurrentStep < totalSteps-1 {
+		return s.beforeHooks(ctx, w, LifecycleDone)
+	}
+	return s.beforeHooks(ctx, w, LifecycleDone, LifecycleClosed)
+}
@@ -0,0 +1,110 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// hookRecorder records hook calls as "before:<event>" / "after:<event>" and
+// refuses the events in refuse.
+type hookRecorder struct {
+	callsMu sync.Mutex
+	calls   []string
+	refuse  map[LifecycleEvent]bool
+}
+
+func (r *hookRecorder) Before(_ context.Context, event LifecycleEvent, _ Work) error {
+	r.record("before:" + string(event))
+	if r.refuse[event] {
+		return errors.New("tracker unreachable")
+	}
+	return nil
+}
+
+func (r *hookRecorder) After(event LifecycleEvent, _ Work) {
+	r.record("after:" + string(event))
+}
+
+func (r *hookRecorder) record(call string) {
+	r.callsMu.Lock()
+	defer r.callsMu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *hookRecorder) take() []string {
+	r.callsMu.Lock()
+	defer r.callsMu.Unlock()
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+func TestLifecycleHooks_Transitions(t *testing.T) {
+	s := newTestStore(t)
+	hooks := &hookRecorder{}
+	s.SetLifecycleHooks(hooks)
+
+	story := createStory(t, s, "Story")
+	if got, want := hooks.take(), []string{"before:created", "after:created"}; !slices.Equal(got, want) {
+		t.Errorf("create hooks = %v, want %v", got, want)
+	}
+
+	startWork(t, s, story.ID)
+	if got, want := hooks.take(), []string{"before:started", "after:started"}; !slices.Equal(got, want) {
+		t.Errorf("start hooks = %v, want %v", got, want)
+	}
+
+	if _, err := s.StepDone(context.Background(), story.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hooks.take(), []string{"before:done", "after:done"}; !slices.Equal(got, want) {
+		t.Errorf("step advance hooks = %v, want %v", got, want)
+	}
+
+	if _, err := s.StepDone(context.Background(), story.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"before:done", "before:closed", "after:done", "after:closed"}
+	if got := hooks.take(); !slices.Equal(got, want) {
+		t.Errorf("close hooks = %v, want %v", got, want)
+	}
+
+	// A transition that is invalid anyway runs no hook.
+	if _, err := s.Start(context.Background(), story.ID, ""); !errors.Is(err, ErrInvalidWork) {
+		t.Fatalf("Start closed work error = %v, want ErrInvalidWork", err)
+	}
+	if got := hooks.take(); len(got) != 0 {
+		t.Errorf("hooks for invalid transition = %v, want none", got)
+	}
+}
+
+func TestLifecycleHooks_Refused(t *testing.T) {
+	s := newTestStore(t)
+	hooks := &hookRecorder{refuse: map[LifecycleEvent]bool{LifecycleClosed: true}}
+	s.SetLifecycleHooks(hooks)
+
+	story := createStory(t, s, "Story")
+	startWork(t, s, story.ID)
+	hooks.take()
+
+	if _, err := s.StepDone(context.Background(), story.ID, 0); !errors.Is(err, ErrHookRefused) {
+		t.Fatalf("StepDone error = %v, want ErrHookRefused", err)
+	}
+	if got := getWork(t, s, story.ID).Status; got != StatusInProgress {
+		t.Errorf("status = %s, want in_progress", got)
+	}
+	if got, want := hooks.take(), []string{"before:done", "before:closed"}; !slices.Equal(got, want) {
+		t.Errorf("hooks = %v, want %v", got, want)
+	}
+
+	hooks.refuse = map[LifecycleEvent]bool{LifecycleCreated: true}
+	if _, err := s.Create(context.Background(), Work{Type: WorkTypeStory, Title: "Refused", AgentRoleID: testRoleID}); !errors.Is(err, ErrHookRefused) {
+		t.Fatalf("Create error = %v, want ErrHookRefused", err)
+	}
+	if works, _ := s.List(); len(works) != 1 {
+		t.Errorf("works = %d, want 1", len(works))
+	}
+}
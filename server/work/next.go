@@ -0,0 +1,108 @@
+package work
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetadataDependsOn is the structured body metadata key listing the items
+// a work item waits for, as comma- or space-separated IDs or short IDs
+// ("depends_on: PCK-3, #PCK-4").
+const MetadataDependsOn = "depends_on"
+
+// NextQuery narrows the candidates Next picks from. Zero values mean any
+// role and any parent.
+type NextQuery struct {
+	AgentRoleID string `json:"agent_role_id,omitempty"`
+	ParentID    string `json:"parent_id,omitempty"`
+}
+
+// Next returns the work item that should be started next: the open item
+// with an agent role (AgentRoleID when set) that is not blocked, earliest
+// due date first, then rank. Items without a due date follow the dated
+// ones. An item is blocked while one of its depends_on items is neither
+// closed nor cancelled, while its parent is closed or cancelled, or while
+// its budget refuses a fresh session. works is as returned by Store.List;
+// found is false when nothing is ready.
+//
+// work.next and the work_next tool (which story coordinators are pointed
+// to when a child completes) both order work through Next, so every caller
+// asking what to do next gets the same answer.
+func Next(works []Work, q NextQuery, now time.Time) (next Work, found bool) {
+	byID := make(map[string]Work, 2*len(works))
+	for _, w := range works {
+		byID[w.ID] = w
+		if w.ShortID != "" {
+			byID[w.ShortID] = w
+		}
+	}
+
+	var ready []Work
+	for _, w := range works {
+		if w.Status != StatusOpen || w.AgentRoleID == "" {
+			continue
+		}
+		if q.AgentRoleID != "" && w.AgentRoleID != q.AgentRoleID {
+			continue
+		}
+		if q.ParentID != "" && w.ParentID != q.ParentID {
+			continue
+		}
+		if isBlocked(w, byID, now) {
+			continue
+		}
+		ready = append(ready, w)
+	}
+	if len(ready) == 0 {
+		return Work{}, false
+	}
+
+	// Stable, so equal due dates (including none) keep rank order.
+	sort.SliceStable(ready, func(i, j int) bool {
+		a, b := ready[i].DueAt, ready[j].DueAt
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+	return ready[0], true
+}
+
+func isBlocked(w Work, byID map[string]Work, now time.Time) bool {
+	if parent, ok := byID[w.ParentID]; ok && isFinished(parent.Status) {
+		return true
+	}
+	if w.checkBudget(now, true) != nil {
+		return true
+	}
+	for _, id := range DependsOn(w) {
+		// Unknown IDs are deleted items and no longer block.
+		if dep, ok := byID[id]; ok && !isFinished(dep.Status) {
+			return true
+		}
+	}
+	return false
+}
+
+func isFinished(s WorkStatus) bool {
+	return s == StatusClosed || s == StatusCancelled
+}
+
+// DependsOn returns the IDs listed in w's depends_on metadata, without a
+// leading "#". It is empty for a free-text or malformed body.
+func DependsOn(w Work) []string {
+	sb, ok, err := ParseBody(w.Body)
+	if !ok || err != nil {
+		return nil
+	}
+	var ids []string
+	for _, f := range strings.FieldsFunc(sb.Metadata[MetadataDependsOn], func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		if id := strings.TrimPrefix(f, "#"); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
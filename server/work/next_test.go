@@ -0,0 +1,83 @@
+package work
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext_DueDateThenRank(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	works := []Work{
+		{ID: "a", Status: StatusOpen, AgentRoleID: "dev"},
+		{ID: "b", Status: StatusOpen, AgentRoleID: "dev", DueAt: now.Add(48 * time.Hour)},
+		{ID: "c", Status: StatusOpen, AgentRoleID: "dev", DueAt: now.Add(-time.Hour)},
+		{ID: "d", Status: StatusInProgress, AgentRoleID: "dev", DueAt: now.Add(-48 * time.Hour)},
+	}
+
+	if w, found := Next(works, NextQuery{}, now); !found || w.ID != "c" {
+		t.Fatalf("got %q, %v; want the overdue item c", w.ID, found)
+	}
+	works[2].Status = StatusClosed
+	if w, _ := Next(works, NextQuery{}, now); w.ID != "b" {
+		t.Fatalf("got %q, want the dated item b before undated a", w.ID)
+	}
+	works[1].DueAt = time.Time{}
+	if w, _ := Next(works, NextQuery{}, now); w.ID != "a" {
+		t.Fatalf("got %q, want rank order to break the tie", w.ID)
+	}
+}
+
+func TestNext_Filters(t *testing.T) {
+	works := []Work{
+		{ID: "s1", Type: WorkTypeStory, Status: StatusInProgress, AgentRoleID: "pm"},
+		{ID: "t0", Type: WorkTypeTask, Status: StatusOpen},
+		{ID: "t1", Type: WorkTypeTask, ParentID: "s1", Status: StatusOpen, AgentRoleID: "qa"},
+		{ID: "t2", Type: WorkTypeTask, ParentID: "s1", Status: StatusOpen, AgentRoleID: "dev"},
+		{ID: "t3", Type: WorkTypeTask, Status: StatusOpen, AgentRoleID: "dev"},
+	}
+	now := time.Now()
+
+	if w, _ := Next(works, NextQuery{}, now); w.ID != "t1" {
+		t.Errorf("got %q, want t1 (items without a role are skipped)", w.ID)
+	}
+	if w, _ := Next(works, NextQuery{AgentRoleID: "dev"}, now); w.ID != "t2" {
+		t.Errorf("got %q, want t2", w.ID)
+	}
+	if w, _ := Next(works, NextQuery{AgentRoleID: "dev", ParentID: "s0"}, now); w.ID != "" {
+		t.Errorf("got %q, want nothing", w.ID)
+	}
+	if _, found := Next(works, NextQuery{AgentRoleID: "ops"}, now); found {
+		t.Error("expected nothing for a role without work")
+	}
+}
+
+func TestNext_Blocked(t *testing.T) {
+	now := time.Now()
+	works := []Work{
+		{ID: "s1", ShortID: "PCK-1", Type: WorkTypeStory, Status: StatusCancelled, AgentRoleID: "pm"},
+		{ID: "t1", ParentID: "s1", Type: WorkTypeTask, Status: StatusOpen, AgentRoleID: "dev"},
+		{ID: "t2", ShortID: "PCK-3", Type: WorkTypeTask, Status: StatusInProgress, AgentRoleID: "dev"},
+		{ID: "t3", Type: WorkTypeTask, Status: StatusOpen, AgentRoleID: "dev", Body: "---\ndepends_on: #PCK-3, gone\n---\n"},
+		{ID: "t4", Type: WorkTypeTask, Status: StatusOpen, AgentRoleID: "dev", Budget: &Budget{MaxSessions: 1}, SessionsUsed: 1},
+		{ID: "t5", Type: WorkTypeTask, Status: StatusOpen, AgentRoleID: "dev"},
+	}
+
+	if w, _ := Next(works, NextQuery{}, now); w.ID != "t5" {
+		t.Fatalf("got %q, want t5 past the blocked items", w.ID)
+	}
+	works[2].Status = StatusClosed
+	if w, _ := Next(works, NextQuery{}, now); w.ID != "t3" {
+		t.Fatalf("got %q, want t3 once its dependency closed", w.ID)
+	}
+}
+
+func TestDependsOn(t *testing.T) {
+	w := Work{Body: "---\ndepends_on: #PCK-1,PCK-2  abc\n---\n## Context\nx\n"}
+	got := DependsOn(w)
+	if len(got) != 3 || got[0] != "PCK-1" || got[1] != "PCK-2" || got[2] != "abc" {
+		t.Errorf("got %v", got)
+	}
+	if got := DependsOn(Work{Body: "depends_on: PCK-1"}); got != nil {
+		t.Errorf("free-text body: got %v", got)
+	}
+}
@@ -0,0 +1,63 @@
+package work
+
+import "fmt"
+
+// ValidateNotifyMode checks a watch or mute flag. Errors wrap ErrInvalidWork.
+func ValidateNotifyMode(mode NotifyMode) error {
+	switch mode {
+	case NotifyInherit, NotifyWatch, NotifyMute:
+		return nil
+	}
+	return fmt.Errorf("%w: notify must be watch, mute, or empty", ErrInvalidWork)
+}
+
+// NotifyFilter decides which work items the notification subsystem (due
+// reminders, webhooks, the digest) alerts on. An item's flag is its own
+// Notify, or else its nearest ancestor's. Muted items never alert. With
+// watchedOnly, only watched items do; otherwise every item not muted does.
+type NotifyFilter struct {
+	byID        map[string]Work
+	watchedOnly bool
+}
+
+// NewNotifyFilter builds a filter over works, which must include the
+// ancestors of every item it is asked about.
+func NewNotifyFilter(works []Work, watchedOnly bool) *NotifyFilter {
+	byID := make(map[string]Work, len(works))
+	for _, w := range works {
+		byID[w.ID] = w
+	}
+	return &NotifyFilter{byID: byID, watchedOnly: watchedOnly}
+}
+
+// Allows reports whether w should alert.
+func (f *NotifyFilter) Allows(w Work) bool {
+	switch f.Effective(w) {
+	case NotifyMute:
+		return false
+	case NotifyWatch:
+		return true
+	default:
+		return !f.watchedOnly
+	}
+}
+
+// Effective returns w's flag after inheritance; NotifyInherit when neither
+// w nor any ancestor has one.
+func (f *NotifyFilter) Effective(w Work) NotifyMode {
+	seen := make(map[string]bool)
+	for {
+		if w.Notify != NotifyInherit {
+			return w.Notify
+		}
+		if w.ParentID == "" || seen[w.ID] {
+			return NotifyInherit
+		}
+		seen[w.ID] = true
+		parent, ok := f.byID[w.ParentID]
+		if !ok {
+			return NotifyInherit
+		}
+		w = parent
+	}
+}
@@ -0,0 +1,98 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNotifyFilter(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	story := createStory(t, store, "Story")
+	task := createTask(t, store, story.ID, "Task")
+	loud := createTask(t, store, story.ID, "Loud")
+	other := createStory(t, store, "Other")
+
+	if _, err := store.SetNotify(ctx, story.ID, NotifyWatch); err != nil {
+		t.Fatalf("SetNotify: %v", err)
+	}
+	if _, err := store.SetNotify(ctx, other.ID, NotifyMute); err != nil {
+		t.Fatalf("SetNotify: %v", err)
+	}
+	if _, err := store.SetNotify(ctx, loud.ID, "loud"); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("SetNotify(loud) error = %v, want ErrInvalidWork", err)
+	}
+	if _, err := store.SetNotify(ctx, "missing", NotifyWatch); !errors.Is(err, ErrWorkNotFound) {
+		t.Errorf("SetNotify(missing) error = %v, want ErrWorkNotFound", err)
+	}
+	// Muting a task overrides the story it inherits from.
+	if _, err := store.SetNotify(ctx, loud.ID, NotifyMute); err != nil {
+		t.Fatalf("SetNotify: %v", err)
+	}
+	unflagged := createStory(t, store, "Unflagged")
+
+	works, _ := store.List()
+	get := func(id string) Work {
+		w, _, _ := store.Get(id)
+		return w
+	}
+	tests := []struct {
+		w                Work
+		all, onlyWatched bool
+	}{
+		{get(story.ID), true, true},
+		{get(task.ID), true, true},
+		{get(loud.ID), false, false},
+		{get(other.ID), false, false},
+		{unflagged, true, false},
+	}
+	all, watched := NewNotifyFilter(works, false), NewNotifyFilter(works, true)
+	for _, tt := range tests {
+		if got := all.Allows(tt.w); got != tt.all {
+			t.Errorf("%s: Allows = %v, want %v", tt.w.Title, got, tt.all)
+		}
+		if got := watched.Allows(tt.w); got != tt.onlyWatched {
+			t.Errorf("%s: Allows (watched only) = %v, want %v", tt.w.Title, got, tt.onlyWatched)
+		}
+	}
+}
+
+func TestDueReminder_SkipsMuted(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	due := now.Add(30 * time.Minute)
+
+	story := createStory(t, store, "Muted")
+	if err := store.Update(ctx, story.ID, UpdateFields{DueAt: &due}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	task := createTask(t, store, story.ID, "Inherits")
+	if err := store.Update(ctx, task.ID, UpdateFields{DueAt: &due}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := store.SetNotify(ctx, story.ID, NotifyMute); err != nil {
+		t.Fatalf("SetNotify: %v", err)
+	}
+
+	r := NewDueReminder(store, func() []time.Duration { return []time.Duration{time.Hour} })
+	l := &dueListener{}
+	r.AddOnDueSoonListener(l)
+
+	r.check(ctx, now)
+	if events := l.take(); len(events) != 0 {
+		t.Fatalf("reminders = %d, want none for a muted story and its task", len(events))
+	}
+
+	// Unmuting re-arms the reminder.
+	if _, err := store.SetNotify(ctx, story.ID, NotifyInherit); err != nil {
+		t.Fatalf("SetNotify: %v", err)
+	}
+	r.check(ctx, now.Add(time.Minute))
+	if events := l.take(); len(events) != 2 {
+		t.Errorf("reminders after unmute = %d, want 2", len(events))
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+
+	"github.com/pockode/server/i18n"
+	"github.com/pockode/server/session"
 )
 
 // Notifier delivers the agent-facing follow-up messages that accompany a work
@@ -12,6 +15,19 @@ type Notifier interface {
 	NotifyReopen(w Work)
 }
 
+// SessionForgetter is optionally implemented by a Notifier that keeps
+// per-session state, such as messages held back for a session.
+type SessionForgetter interface {
+	ForgetSession(sessionID string)
+}
+
+// SessionIDSource is optionally implemented by a WorkStartHandler that
+// chooses the IDs of fresh work sessions, e.g. to hand out the ID of an
+// agent process that is already running.
+type SessionIDSource interface {
+	NewSessionID(w Work, opts StartOptions) string
+}
+
 // Operations performs the request-driven work lifecycle actions that combine a
 // store mutation with its agent-facing side effects (session kickoff, reopen
 // nudge). Both transports — the WebSocket handler (user actions) and the MCP
@@ -19,9 +35,12 @@ type Notifier interface {
 // an AI-triggered action have identical effects. The main server stays the
 // single writer of work data.
 type Operations struct {
-	store    Store
-	starter  WorkStartHandler
-	notifier Notifier
+	store         Store
+	starter       WorkStartHandler
+	notifier      Notifier
+	sessionCloser SessionCloser
+	modeBase      func(session.Mode) (session.Mode, bool)
+	locale        func() i18n.Locale
 }
 
 // NewOperations builds an Operations. A nil notifier is tolerated (the reopen
@@ -30,12 +49,51 @@ func NewOperations(store Store, starter WorkStartHandler, notifier Notifier) *Op
 	return &Operations{store: store, starter: starter, notifier: notifier}
 }
 
+// SetModeResolver sets how modes are checked: fn returns the built-in base
+// of a built-in or custom mode and whether the mode exists. Without one only
+// built-in modes are accepted.
+func (o *Operations) SetModeResolver(fn func(session.Mode) (session.Mode, bool)) {
+	o.modeBase = fn
+}
+
+func (o *Operations) resolveMode(mode session.Mode) (session.Mode, bool) {
+	if o.modeBase == nil {
+		return mode, mode.IsValid()
+	}
+	return o.modeBase(mode)
+}
+
+// SetLocale sets the language of the plan decision prompts. Without it they
+// are English.
+func (o *Operations) SetLocale(fn func() i18n.Locale) {
+	o.locale = fn
+}
+
+func (o *Operations) getLocale() i18n.Locale {
+	if o.locale == nil {
+		return i18n.Default
+	}
+	return o.locale()
+}
+
+// SetSessionCloser sets how CancelWork terminates the sessions of cancelled
+// work. Without one, cancelled sessions are left to the idle reaper.
+func (o *Operations) SetSessionCloser(c SessionCloser) {
+	o.sessionCloser = c
+}
+
 // StartWork claims a work item and launches its agent session. It transitions
 // the work to in_progress with a session ID, then creates the session and sends
 // the kickoff (or restart) message via the WorkStartHandler. On handler failure
 // the claim is rolled back so the work never gets stuck in_progress with a
-// dangling session. The returned Work is the claimed item.
-func (o *Operations) StartWork(ctx context.Context, id string) (Work, error) {
+// dangling session. The returned Work is the claimed item. opts may override
+// the role and mode for this session only; the caller validates that an
+// overriding role exists.
+func (o *Operations) StartWork(ctx context.Context, id string, opts StartOptions) (Work, error) {
+	if _, ok := o.resolveMode(opts.Mode); opts.Mode != "" && !ok {
+		return Work{}, fmt.Errorf("%w: invalid mode %q", ErrInvalidWork, opts.Mode)
+	}
+
 	// Precondition: a startable work must have an agent role. Checked before the
 	// claim; a stale read here is harmless (worst case a rare spurious reject),
 	// unlike the status/session decision which Claim makes under the store lock.
@@ -46,19 +104,25 @@ func (o *Operations) StartWork(ctx context.Context, id string) (Work, error) {
 	if !found {
 		return Work{}, ErrWorkNotFound
 	}
-	if current.AgentRoleID == "" {
+	if current.AgentRoleID == "" && opts.AgentRoleID == "" {
 		return Work{}, fmt.Errorf("%w: work %s has no agent_role_id", ErrInvalidWork, id)
 	}
+	// Only fresh sessions can use a chosen ID (see Claim). A stale read just
+	// leaves a chosen ID unused.
+	if src, ok := o.starter.(SessionIDSource); ok && opts.SessionID == "" &&
+		(current.SessionID == "" || (current.Status != StatusStopped && current.Status != StatusNeedsInput && current.Status != StatusNeedsAttention)) {
+		opts.SessionID = src.NewSessionID(current, opts)
+	}
 
 	// Detach from the caller's context: an HTTP request timeout or a disconnected
 	// client/AI CLI must not cancel session creation midway, which would orphan a
 	// half-created session. The claim and kickoff run to completion regardless.
 	startCtx := context.WithoutCancel(ctx)
-	w, restart, err := o.store.Claim(startCtx, id)
+	w, restart, err := o.store.Claim(startCtx, id, opts)
 	if err != nil {
 		return Work{}, err
 	}
-	if err := o.starter.HandleWorkStart(startCtx, w); err != nil {
+	if err := o.starter.HandleWorkStart(startCtx, w, opts); err != nil {
 		if rbErr := o.store.RollbackStart(startCtx, id, restart); rbErr != nil {
 			slog.Error("failed to rollback work start", "workId", id, "restart", restart, "error", rbErr)
 		}
@@ -80,3 +144,85 @@ func (o *Operations) ReopenWork(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// CancelWork cancels a work item (and its unfinished descendants) with a
+// reason and terminates their agent sessions. Returns the cancelled item.
+func (o *Operations) CancelWork(ctx context.Context, id string, reason string) (Work, error) {
+	cancelled, err := o.store.Cancel(ctx, id, reason)
+	if err != nil {
+		return Work{}, err
+	}
+	if o.sessionCloser != nil {
+		for _, w := range cancelled {
+			if w.SessionID != "" {
+				o.sessionCloser.CloseSession(w.SessionID)
+			}
+		}
+	}
+	return cancelled[0], nil
+}
+
+// SessionTrashed stops the active work linked to a session moved to the
+// trash and drops what the notifier holds for the session. The work stays
+// stopped until the session is restored or the work is Reset; starting it
+// before then fails with ErrSessionTrashed.
+func (o *Operations) SessionTrashed(ctx context.Context, sessionID string) error {
+	w, found, err := o.store.FindBySessionID(sessionID)
+	if err != nil {
+		return err
+	}
+	if found && (w.Status == StatusInProgress || w.Status == StatusNeedsInput || w.Status == StatusWaiting) {
+		if err := o.store.Stop(ctx, w.ID); err != nil {
+			return err
+		}
+	}
+	if f, ok := o.notifier.(SessionForgetter); ok {
+		f.ForgetSession(sessionID)
+	}
+	return nil
+}
+
+// ApprovePlan approves a work's submitted plan and resumes its session in the
+// given act mode (default when empty) with the execution kickoff. If the
+// resume fails the decision is reverted so the user can approve again.
+func (o *Operations) ApprovePlan(ctx context.Context, id string, mode session.Mode) (Work, error) {
+	if mode == "" {
+		mode = session.ModeDefault
+	}
+	if base, ok := o.resolveMode(mode); !ok || base == session.ModePlan {
+		return Work{}, fmt.Errorf("%w: invalid act mode %q", ErrInvalidWork, mode)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	w, err := o.store.ResolvePlan(ctx, id, true, "")
+	if err != nil {
+		return Work{}, err
+	}
+	return o.resumeAfterPlanDecision(ctx, id, StartOptions{Mode: mode, Message: BuildPlanApprovedMessage(o.getLocale(), w)})
+}
+
+// RejectPlan sends a submitted plan back to the agent with the user's
+// feedback. The session stays in plan mode and the agent resubmits.
+func (o *Operations) RejectPlan(ctx context.Context, id string, feedback string) (Work, error) {
+	if feedback == "" {
+		return Work{}, fmt.Errorf("%w: feedback is required", ErrInvalidWork)
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	w, err := o.store.ResolvePlan(ctx, id, false, feedback)
+	if err != nil {
+		return Work{}, err
+	}
+	return o.resumeAfterPlanDecision(ctx, id, StartOptions{Message: BuildPlanRevisionMessage(o.getLocale(), w)})
+}
+
+func (o *Operations) resumeAfterPlanDecision(ctx context.Context, id string, opts StartOptions) (Work, error) {
+	w, err := o.StartWork(ctx, id, opts)
+	if err != nil {
+		if rbErr := o.store.UnresolvePlan(ctx, id); rbErr != nil {
+			slog.Error("failed to revert plan decision", "workId", id, "error", rbErr)
+		}
+		return Work{}, err
+	}
+	return w, nil
+}
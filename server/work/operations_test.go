@@ -3,20 +3,27 @@ package work
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
+
+	"github.com/pockode/server/session"
 )
 
 // recordingStarter captures the context it was called with (for the detach
 // test) and can be set to fail (for the rollback test).
 type recordingStarter struct {
-	err    error
-	calls  int
-	gotCtx context.Context
+	err     error
+	calls   int
+	gotCtx  context.Context
+	gotWork Work
+	gotOpts StartOptions
 }
 
-func (r *recordingStarter) HandleWorkStart(ctx context.Context, _ Work) error {
+func (r *recordingStarter) HandleWorkStart(ctx context.Context, w Work, opts StartOptions) error {
 	r.calls++
 	r.gotCtx = ctx
+	r.gotWork = w
+	r.gotOpts = opts
 	return r.err
 }
 
@@ -32,7 +39,7 @@ func TestOperations_StartWork_ClaimsAndReturnsWork(t *testing.T) {
 	starter := &recordingStarter{}
 	ops := NewOperations(store, starter, nil)
 
-	w, err := ops.StartWork(context.Background(), story.ID)
+	w, err := ops.StartWork(context.Background(), story.ID, StartOptions{})
 	if err != nil {
 		t.Fatalf("StartWork: %v", err)
 	}
@@ -54,7 +61,7 @@ func TestOperations_StartWork_RestartReusesSession(t *testing.T) {
 	story := createStory(t, store, "Build")
 	ops := NewOperations(store, &recordingStarter{}, nil)
 
-	first, err := ops.StartWork(context.Background(), story.ID)
+	first, err := ops.StartWork(context.Background(), story.ID, StartOptions{})
 	if err != nil {
 		t.Fatalf("first StartWork: %v", err)
 	}
@@ -62,7 +69,7 @@ func TestOperations_StartWork_RestartReusesSession(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	restarted, err := ops.StartWork(context.Background(), story.ID)
+	restarted, err := ops.StartWork(context.Background(), story.ID, StartOptions{})
 	if err != nil {
 		t.Fatalf("restart StartWork: %v", err)
 	}
@@ -76,7 +83,7 @@ func TestOperations_StartWork_RollsBackOnHandlerFailure(t *testing.T) {
 	story := createStory(t, store, "Build")
 	ops := NewOperations(store, &recordingStarter{err: errors.New("kickoff failed")}, nil)
 
-	if _, err := ops.StartWork(context.Background(), story.ID); err == nil {
+	if _, err := ops.StartWork(context.Background(), story.ID, StartOptions{}); err == nil {
 		t.Fatal("expected error when handler fails")
 	}
 
@@ -98,7 +105,7 @@ func TestOperations_StartWork_MissingRole(t *testing.T) {
 	}
 	ops := NewOperations(store, &recordingStarter{}, nil)
 
-	if _, err := ops.StartWork(context.Background(), w.ID); err == nil {
+	if _, err := ops.StartWork(context.Background(), w.ID, StartOptions{}); err == nil {
 		t.Fatal("expected error for work without agent_role_id")
 	}
 	got, _, _ := store.Get(w.ID)
@@ -118,7 +125,7 @@ func TestOperations_StartWork_DetachesCallerContext(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	w, err := ops.StartWork(ctx, story.ID)
+	w, err := ops.StartWork(ctx, story.ID, StartOptions{})
 	if err != nil {
 		t.Fatalf("StartWork with cancelled ctx: %v", err)
 	}
@@ -153,3 +160,246 @@ func TestOperations_ReopenWork_NotifiesAfterReopen(t *testing.T) {
 		t.Fatalf("NotifyReopen called %d times, want 1", len(notifier.reopened))
 	}
 }
+
+func TestOperations_SessionTrashed(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	story := createStory(t, store, "Build")
+	startWorkWithSession(t, store, story.ID, "s1")
+	resumer := NewAutoResumer(store, 3)
+	defer resumer.Stop()
+	resumer.deferred["s1"] = []string{"held back"}
+	ops := NewOperations(store, &recordingStarter{}, resumer)
+
+	if err := ops.SessionTrashed(ctx, "s1"); err != nil {
+		t.Fatalf("SessionTrashed: %v", err)
+	}
+	if got := getWork(t, store, story.ID); got.Status != StatusStopped || got.SessionID != "s1" {
+		t.Errorf("work = %s/%q, want stopped keeping its session", got.Status, got.SessionID)
+	}
+	if len(resumer.deferred) != 0 {
+		t.Errorf("deferred = %v, want the session's messages dropped", resumer.deferred)
+	}
+
+	// Reset unlinks the session so the next start is fresh.
+	if err := store.Reset(ctx, story.ID); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if got := getWork(t, store, story.ID); got.Status != StatusOpen || got.SessionID != "" {
+		t.Errorf("work = %s/%q, want open without a session", got.Status, got.SessionID)
+	}
+	if err := store.Reset(ctx, story.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Reset open work: err = %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestOperations_StartWork_RoleAndModeOverride(t *testing.T) {
+	store := newTestStore(t)
+	story := createStory(t, store, "Build")
+	starter := &recordingStarter{}
+	ops := NewOperations(store, starter, nil)
+
+	w, err := ops.StartWork(context.Background(), story.ID, StartOptions{AgentRoleID: "planner", Mode: session.ModeYolo})
+	if err != nil {
+		t.Fatalf("StartWork: %v", err)
+	}
+	if w.AgentRoleID != testRoleID {
+		t.Errorf("agent_role_id = %q, want work's own role %q unchanged", w.AgentRoleID, testRoleID)
+	}
+	if w.SessionAgentRoleID != "planner" || w.EffectiveAgentRoleID() != "planner" {
+		t.Errorf("session_agent_role_id = %q, want planner", w.SessionAgentRoleID)
+	}
+	if starter.gotOpts.Mode != session.ModeYolo {
+		t.Errorf("handler mode = %q, want yolo", starter.gotOpts.Mode)
+	}
+	if starter.gotWork.SessionAgentRoleID != "planner" {
+		t.Errorf("handler saw session role %q, want planner", starter.gotWork.SessionAgentRoleID)
+	}
+}
+
+func TestOperations_StartWork_InvalidMode(t *testing.T) {
+	store := newTestStore(t)
+	story := createStory(t, store, "Build")
+	ops := NewOperations(store, &recordingStarter{}, nil)
+
+	if _, err := ops.StartWork(context.Background(), story.ID, StartOptions{Mode: "bogus"}); !errors.Is(err, ErrInvalidWork) {
+		t.Fatalf("err = %v, want ErrInvalidWork", err)
+	}
+}
+
+// Restarting without an override keeps the role the session was started with.
+func TestOperations_StartWork_RestartKeepsOverride(t *testing.T) {
+	store := newTestStore(t)
+	story := createStory(t, store, "Build")
+	ops := NewOperations(store, &recordingStarter{}, nil)
+
+	if _, err := ops.StartWork(context.Background(), story.ID, StartOptions{AgentRoleID: "planner"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkNeedsInput(context.Background(), story.ID); err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := ops.StartWork(context.Background(), story.ID, StartOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.SessionAgentRoleID != "planner" {
+		t.Errorf("session_agent_role_id = %q, want planner kept on restart", restarted.SessionAgentRoleID)
+	}
+}
+
+func submitPlannedWork(t *testing.T, store *FileStore, ops *Operations) Work {
+	t.Helper()
+	story := createStory(t, store, "Build")
+	if _, err := ops.StartWork(context.Background(), story.ID, StartOptions{Mode: session.ModePlan}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SubmitPlan(context.Background(), story.ID, "1. build"); err != nil {
+		t.Fatal(err)
+	}
+	return story
+}
+
+func TestOperations_ApprovePlan(t *testing.T) {
+	store := newTestStore(t)
+	starter := &recordingStarter{}
+	ops := NewOperations(store, starter, nil)
+	story := submitPlannedWork(t, store, ops)
+
+	w, err := ops.ApprovePlan(context.Background(), story.ID, "")
+	if err != nil {
+		t.Fatalf("ApprovePlan: %v", err)
+	}
+	if w.Status != StatusInProgress || w.Plan.Status != PlanApproved {
+		t.Errorf("status = %q plan = %+v, want in_progress / approved", w.Status, w.Plan)
+	}
+	if starter.gotOpts.Mode != session.ModeDefault {
+		t.Errorf("mode = %q, want default", starter.gotOpts.Mode)
+	}
+	if !strings.Contains(starter.gotOpts.Message, "1. build") {
+		t.Errorf("message = %q, want the approved plan", starter.gotOpts.Message)
+	}
+}
+
+func TestOperations_ApprovePlan_RejectsPlanMode(t *testing.T) {
+	store := newTestStore(t)
+	ops := NewOperations(store, &recordingStarter{}, nil)
+	story := submitPlannedWork(t, store, ops)
+
+	if _, err := ops.ApprovePlan(context.Background(), story.ID, session.ModePlan); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("err = %v, want ErrInvalidWork", err)
+	}
+}
+
+func TestOperations_CustomModes(t *testing.T) {
+	store := newTestStore(t)
+	starter := &recordingStarter{}
+	ops := NewOperations(store, starter, nil)
+	ops.SetModeResolver(func(mode session.Mode) (session.Mode, bool) {
+		switch mode {
+		case "review":
+			return session.ModePlan, true
+		case "careful":
+			return session.ModeDefault, true
+		}
+		return mode, mode.IsValid()
+	})
+
+	story := createStory(t, store, "Build")
+	if _, err := ops.StartWork(context.Background(), story.ID, StartOptions{Mode: "review"}); err != nil {
+		t.Fatalf("StartWork with custom mode: %v", err)
+	}
+	if starter.gotOpts.Mode != "review" {
+		t.Errorf("handler mode = %q, want review", starter.gotOpts.Mode)
+	}
+	if _, err := ops.StartWork(context.Background(), story.ID, StartOptions{Mode: "bogus"}); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("undefined mode err = %v, want ErrInvalidWork", err)
+	}
+
+	planned := submitPlannedWork(t, store, ops)
+	if _, err := ops.ApprovePlan(context.Background(), planned.ID, "review"); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("plan-based act mode err = %v, want ErrInvalidWork", err)
+	}
+	if _, err := ops.ApprovePlan(context.Background(), planned.ID, "careful"); err != nil {
+		t.Fatalf("ApprovePlan with custom act mode: %v", err)
+	}
+	if starter.gotOpts.Mode != "careful" {
+		t.Errorf("act mode = %q, want careful", starter.gotOpts.Mode)
+	}
+}
+
+func TestOperations_ApprovePlan_RevertsOnHandlerFailure(t *testing.T) {
+	store := newTestStore(t)
+	starter := &recordingStarter{}
+	ops := NewOperations(store, starter, nil)
+	story := submitPlannedWork(t, store, ops)
+
+	starter.err = errors.New("kickoff failed")
+	if _, err := ops.ApprovePlan(context.Background(), story.ID, ""); err == nil {
+		t.Fatal("expected error when handler fails")
+	}
+
+	got, _, _ := store.Get(story.ID)
+	if got.Plan.Status != PlanSubmitted {
+		t.Errorf("plan status = %q, want submitted after revert", got.Plan.Status)
+	}
+}
+
+func TestOperations_RejectPlan(t *testing.T) {
+	store := newTestStore(t)
+	starter := &recordingStarter{}
+	ops := NewOperations(store, starter, nil)
+	story := submitPlannedWork(t, store, ops)
+
+	if _, err := ops.RejectPlan(context.Background(), story.ID, ""); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("empty feedback: err = %v, want ErrInvalidWork", err)
+	}
+
+	w, err := ops.RejectPlan(context.Background(), story.ID, "add tests")
+	if err != nil {
+		t.Fatalf("RejectPlan: %v", err)
+	}
+	if w.Status != StatusInProgress || w.Plan.Status != PlanRejected {
+		t.Errorf("status = %q plan = %+v, want in_progress / rejected", w.Status, w.Plan)
+	}
+	if starter.gotOpts.Mode != "" {
+		t.Errorf("mode = %q, want unchanged (plan)", starter.gotOpts.Mode)
+	}
+	if !strings.Contains(starter.gotOpts.Message, "add tests") {
+		t.Errorf("message = %q, want the feedback", starter.gotOpts.Message)
+	}
+}
+
+type recordingCloser struct {
+	closed []string
+}
+
+func (c *recordingCloser) CloseSession(sessionID string) {
+	c.closed = append(c.closed, sessionID)
+}
+
+func TestOperations_CancelWork_ClosesSessions(t *testing.T) {
+	store := newTestStore(t)
+	story := createStory(t, store, "Build")
+	task := createTask(t, store, story.ID, "Task")
+	if _, err := store.Start(context.Background(), story.ID, "s-story"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Start(context.Background(), task.ID, "s-task"); err != nil {
+		t.Fatal(err)
+	}
+	closer := &recordingCloser{}
+	ops := NewOperations(store, &recordingStarter{}, nil)
+	ops.SetSessionCloser(closer)
+
+	w, err := ops.CancelWork(context.Background(), story.ID, "dropped")
+	if err != nil {
+		t.Fatalf("CancelWork: %v", err)
+	}
+	if w.ID != story.ID || w.Status != StatusCancelled {
+		t.Errorf("returned %s %s, want the cancelled story", w.ID, w.Status)
+	}
+	if len(closer.closed) != 2 || closer.closed[0] != "s-story" || closer.closed[1] != "s-task" {
+		t.Errorf("closed sessions = %v, want [s-story s-task]", closer.closed)
+	}
+}
@@ -0,0 +1,52 @@
+package work
+
+// Progress summarizes a parent's direct children. Cancelled children are left
+// out, so abandoned scope does not hold a story below 100%.
+type Progress struct {
+	Closed  int `json:"closed"`
+	Total   int `json:"total"`
+	Percent int `json:"percent"` // Closed*100/Total, rounded down
+}
+
+// ComputeProgress returns the progress of every work item with at least one
+// non-cancelled child, keyed by ID.
+func ComputeProgress(works []Work) map[string]Progress {
+	result := make(map[string]Progress)
+	for _, w := range works {
+		if w.ParentID == "" || w.Status == StatusCancelled {
+			continue
+		}
+		p := result[w.ParentID]
+		p.Total++
+		if w.Status == StatusClosed {
+			p.Closed++
+		}
+		result[w.ParentID] = p
+	}
+	for id, p := range result {
+		p.Percent = p.Closed * 100 / p.Total
+		result[id] = p
+	}
+	return result
+}
+
+// refreshProgressLocked recomputes Progress on every item and marks the ones
+// whose progress changed as modified, so a child's transition also emits an
+// update for its parent. Pointers are replaced, never mutated, so snapshots
+// stay independent. Caller must hold s.worksMu for writing.
+func (s *FileStore) refreshProgressLocked(modified map[string]bool) {
+	progress := ComputeProgress(s.works)
+	for i := range s.works {
+		w := &s.works[i]
+		p, ok := progress[w.ID]
+		switch {
+		case !ok && w.Progress == nil:
+		case !ok:
+			w.Progress = nil
+			modified[w.ID] = true
+		case w.Progress == nil || *w.Progress != p:
+			w.Progress = &p
+			modified[w.ID] = true
+		}
+	}
+}
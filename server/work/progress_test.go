@@ -0,0 +1,82 @@
+package work
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComputeProgress(t *testing.T) {
+	works := []Work{
+		{ID: "s1", Type: WorkTypeStory, Status: StatusInProgress},
+		{ID: "t1", ParentID: "s1", Status: StatusClosed},
+		{ID: "t2", ParentID: "s1", Status: StatusInProgress},
+		{ID: "t3", ParentID: "s1", Status: StatusOpen},
+		{ID: "t4", ParentID: "s1", Status: StatusCancelled},
+		{ID: "s2", Type: WorkTypeStory, Status: StatusOpen},
+		{ID: "t5", ParentID: "s2", Status: StatusCancelled},
+	}
+	got := ComputeProgress(works)
+	if got["s1"] != (Progress{Closed: 1, Total: 3, Percent: 33}) {
+		t.Errorf("s1 = %+v, want 1/3 (cancelled excluded)", got["s1"])
+	}
+	if _, ok := got["s2"]; ok {
+		t.Error("a parent with only cancelled children should have no progress")
+	}
+	if _, ok := got["t1"]; ok {
+		t.Error("a leaf should have no progress")
+	}
+}
+
+func TestStore_Progress(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	story := createStory(t, s, "S")
+	if getWork(t, s, story.ID).Progress != nil {
+		t.Error("a story without children should have no progress")
+	}
+	t1 := createTask(t, s, story.ID, "T1")
+	createTask(t, s, story.ID, "T2")
+	doneWork(t, s, t1.ID)
+
+	want := Progress{Closed: 1, Total: 2, Percent: 50}
+	if p := getWork(t, s, story.ID).Progress; p == nil || *p != want {
+		t.Errorf("Get progress = %+v, want %+v", p, want)
+	}
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range list {
+		if w.ID == story.ID && (w.Progress == nil || *w.Progress != want) {
+			t.Errorf("List progress = %+v, want %+v", w.Progress, want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "works", "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"progress"`) {
+		t.Error("progress should not be persisted")
+	}
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := getWork(t, reopened, story.ID).Progress; p == nil || *p != want {
+		t.Errorf("progress after reload = %+v, want %+v", p, want)
+	}
+
+	if err := s.Delete(context.Background(), t1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if p := getWork(t, s, story.ID).Progress; p == nil || *p != (Progress{Closed: 0, Total: 1, Percent: 0}) {
+		t.Errorf("progress after delete = %+v, want 0/1", p)
+	}
+}
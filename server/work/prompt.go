@@ -6,12 +6,16 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/pockode/server/i18n"
 	"gopkg.in/yaml.v3"
 )
 
 //go:embed prompts.yaml
 var promptsYAML []byte
 
+//go:embed prompts.ja.yaml
+var promptsJaYAML []byte
+
 // promptTemplates holds parsed templates from prompts.yaml.
 type promptTemplates struct {
 	PockodeMCPPrefix       string `yaml:"pockode_mcp_prefix"`
@@ -29,16 +33,43 @@ type promptTemplates struct {
 	TaskAutoContinueNudge  string `yaml:"task_auto_continue_nudge"`
 	StepAutoContinueNudge  string `yaml:"step_auto_continue_nudge"`
 	ChildCompletionNudge   string `yaml:"child_completion_nudge"`
+	ChildCancelledNudge    string `yaml:"child_cancelled_nudge"`
 	StepAdvanceSection     string `yaml:"step_advance_section"`
 	CurrentStepSection     string `yaml:"current_step_section"`
+	PlanModeSection        string `yaml:"plan_mode_section"`
+	PlanApprovedNudge      string `yaml:"plan_approved_nudge"`
+	PlanRevisionNudge      string `yaml:"plan_revision_nudge"`
+	PlanAutoContinueNudge  string `yaml:"plan_auto_continue_nudge"`
+	NoProgressNudge        string `yaml:"no_progress_nudge"`
+	CIFailureNudge         string `yaml:"ci_failure_nudge"`
+	ReviewTaskTitle        string `yaml:"review_task_title"`
+	ReviewTaskBody         string `yaml:"review_task_body"`
+	ReviewCompletionNudge  string `yaml:"review_completion_nudge"`
 }
 
 var prompts promptTemplates
 
+// localizedPrompts holds each translation overlaid on the English
+// templates, so a key missing from a translation stays English.
+var localizedPrompts = map[i18n.Locale]*promptTemplates{}
+
 func init() {
 	if err := yaml.Unmarshal(promptsYAML, &prompts); err != nil {
 		panic("failed to parse prompts.yaml: " + err.Error())
 	}
+	ja := prompts
+	if err := yaml.Unmarshal(promptsJaYAML, &ja); err != nil {
+		panic("failed to parse prompts.ja.yaml: " + err.Error())
+	}
+	localizedPrompts[i18n.Japanese] = &ja
+}
+
+// promptsFor returns the templates for loc, English when it has none.
+func promptsFor(loc i18n.Locale) *promptTemplates {
+	if p, ok := localizedPrompts[loc]; ok {
+		return p
+	}
+	return &prompts
 }
 
 // render executes a template string with the given data.
@@ -58,54 +89,55 @@ func render(tmplStr string, data any) string {
 // storyBehaviorRules is kept for test compatibility.
 var storyBehaviorRules = strings.TrimSuffix(prompts.StoryBehaviorRules, "\n")
 
-func roleReference(agentRoleID string) string {
-	return render(prompts.RoleReference, map[string]string{
+func roleReference(p *promptTemplates, agentRoleID string) string {
+	return render(p.RoleReference, map[string]string{
 		"AgentRoleID": agentRoleID,
 	})
 }
 
 // buildBase builds the common message shared by all prompt types.
-func buildBase(w Work) string {
-	role := roleReference(w.AgentRoleID)
+func buildBase(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	role := roleReference(p, w.EffectiveAgentRoleID())
 
-	workCtx := render(prompts.WorkContext, map[string]string{
+	workCtx := render(p.WorkContext, map[string]string{
 		"Title": w.Title,
 		"ID":    w.ID,
 	})
 
 	var rules string
 	if w.Type == WorkTypeStory {
-		rules = storyBehaviorRules + "\n" + render(prompts.StoryRulesSuffix, map[string]string{
+		rules = strings.TrimSuffix(p.StoryBehaviorRules, "\n") + "\n" + render(p.StoryRulesSuffix, map[string]string{
 			"ID": w.ID,
 		})
 	} else {
 		if w.ParentID != "" {
-			rules = render(prompts.TaskRulesWithParent, map[string]string{
+			rules = render(p.TaskRulesWithParent, map[string]string{
 				"ParentID": w.ParentID,
 				"ID":       w.ID,
 			})
 		} else {
-			rules = render(prompts.TaskRulesWithoutParent, map[string]string{
+			rules = render(p.TaskRulesWithoutParent, map[string]string{
 				"ID": w.ID,
 			})
 		}
 	}
 
-	pockodeMCPPrefix := render(prompts.PockodeMCPPrefix, nil)
+	pockodeMCPPrefix := render(p.PockodeMCPPrefix, nil)
 	return pockodeMCPPrefix + "\n\n" + role + "\n\n" + workCtx + "\n\n" + rules
 }
 
-func BuildKickoffMessage(w Work) string {
-	return buildBase(w)
+func BuildKickoffMessage(loc i18n.Locale, w Work) string {
+	return buildBase(loc, w)
 }
 
 // formatStepSection creates the step instruction section.
 // Format: "## Current Step\nStep N of M\n\n<step content>"
-func formatStepSection(workID string, steps []string, stepIndex int) string {
+func formatStepSection(p *promptTemplates, workID string, steps []string, stepIndex int) string {
 	if len(steps) == 0 || stepIndex < 0 || stepIndex >= len(steps) {
 		return ""
 	}
-	return render(prompts.CurrentStepSection, map[string]any{
+	return render(p.CurrentStepSection, map[string]any{
 		"CurrentStep": stepIndex + 1,
 		"TotalSteps":  len(steps),
 		"StepPrompt":  steps[stepIndex],
@@ -115,10 +147,11 @@ func formatStepSection(workID string, steps []string, stepIndex int) string {
 
 // BuildKickoffMessageWithSteps creates the kickoff message with step instructions.
 // If steps is non-empty and currentStep (0-indexed) is valid, the step section is appended.
-func BuildKickoffMessageWithSteps(w Work, steps []string, currentStep int) string {
-	base := buildBase(w)
+func BuildKickoffMessageWithSteps(loc i18n.Locale, w Work, steps []string, currentStep int) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, w)
 
-	stepSection := formatStepSection(w.ID, steps, currentStep)
+	stepSection := formatStepSection(p, w.ID, steps, currentStep)
 	if stepSection == "" {
 		return base
 	}
@@ -128,16 +161,17 @@ func BuildKickoffMessageWithSteps(w Work, steps []string, currentStep int) strin
 
 // BuildRestartMessage appends a restart nudge to the base message
 // when a stopped work item is restarted by the user.
-func BuildRestartMessage(w Work) string {
-	base := buildBase(w)
+func BuildRestartMessage(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, w)
 
 	var nudge string
 	if w.Type == WorkTypeStory {
-		nudge = render(prompts.StoryRestartNudge, map[string]string{
+		nudge = render(p.StoryRestartNudge, map[string]string{
 			"ID": w.ID,
 		})
 	} else {
-		nudge = render(prompts.TaskRestartNudge, map[string]string{
+		nudge = render(p.TaskRestartNudge, map[string]string{
 			"ID": w.ID,
 		})
 	}
@@ -147,16 +181,17 @@ func BuildRestartMessage(w Work) string {
 
 // BuildAutoContinuationMessage appends a nudge to the base message
 // when an agent process stops but its work item is still in_progress.
-func BuildAutoContinuationMessage(w Work) string {
-	base := buildBase(w)
+func BuildAutoContinuationMessage(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, w)
 
 	var nudge string
 	if w.Type == WorkTypeStory {
-		nudge = render(prompts.StoryAutoContinueNudge, map[string]string{
+		nudge = render(p.StoryAutoContinueNudge, map[string]string{
 			"ID": w.ID,
 		})
 	} else {
-		nudge = render(prompts.TaskAutoContinueNudge, map[string]string{
+		nudge = render(p.TaskAutoContinueNudge, map[string]string{
 			"ID": w.ID,
 		})
 	}
@@ -166,17 +201,18 @@ func BuildAutoContinuationMessage(w Work) string {
 
 // BuildAutoContinuationMessageWithSteps creates the auto-continuation message with step context.
 // When the work has steps configured, the message prompts the agent to check if the current step is complete.
-func BuildAutoContinuationMessageWithSteps(w Work, steps []string, currentStep int) string {
-	base := buildBase(w)
+func BuildAutoContinuationMessageWithSteps(loc i18n.Locale, w Work, steps []string, currentStep int) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, w)
 
 	// No steps or invalid index: fall back to standard message
 	if len(steps) == 0 || currentStep < 0 || currentStep >= len(steps) {
-		return BuildAutoContinuationMessage(w)
+		return BuildAutoContinuationMessage(loc, w)
 	}
 
-	stepSection := formatStepSection(w.ID, steps, currentStep)
+	stepSection := formatStepSection(p, w.ID, steps, currentStep)
 
-	nudge := render(prompts.StepAutoContinueNudge, map[string]any{
+	nudge := render(p.StepAutoContinueNudge, map[string]any{
 		"CurrentStep": currentStep + 1,
 		"TotalSteps":  len(steps),
 		"ID":          w.ID,
@@ -187,10 +223,27 @@ func BuildAutoContinuationMessageWithSteps(w Work, steps []string, currentStep i
 
 // BuildChildCompletionMessage appends a child completion nudge to the base message
 // when a child task completes and the parent was in waiting state.
-func BuildChildCompletionMessage(parent Work, childTitle, childID string) string {
-	base := buildBase(parent)
+func BuildChildCompletionMessage(loc i18n.Locale, parent Work, childTitle, childID string) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, parent)
+
+	nudge := render(p.ChildCompletionNudge, map[string]string{
+		"ChildTitle": childTitle,
+		"ChildID":    childID,
+		"ID":         parent.ID,
+	})
+
+	return base + "\n\n" + nudge
+}
+
+// BuildReviewCompletionMessage appends a review completion nudge to the
+// base message when a story's review task closes, telling the story to close
+// or to wait for the tasks the reviewer reopened.
+func BuildReviewCompletionMessage(loc i18n.Locale, parent Work, childTitle, childID string) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, parent)
 
-	nudge := render(prompts.ChildCompletionNudge, map[string]string{
+	nudge := render(p.ReviewCompletionNudge, map[string]string{
 		"ChildTitle": childTitle,
 		"ChildID":    childID,
 		"ID":         parent.ID,
@@ -199,12 +252,45 @@ func BuildChildCompletionMessage(parent Work, childTitle, childID string) string
 	return base + "\n\n" + nudge
 }
 
+// BuildReviewTitle names the review task of story.
+func BuildReviewTitle(loc i18n.Locale, story Work) string {
+	return render(promptsFor(loc).ReviewTaskTitle, map[string]string{
+		"Title": story.Title,
+	})
+}
+
+// BuildReviewBody tells the reviewer how to approve story or request
+// changes.
+func BuildReviewBody(loc i18n.Locale, story Work) string {
+	return render(promptsFor(loc).ReviewTaskBody, map[string]string{
+		"StoryID": story.ID,
+	})
+}
+
+// BuildChildCancelledMessage appends a child cancelled nudge to the base
+// message when a child task is cancelled, so the parent does not keep waiting
+// for a report that will never come.
+func BuildChildCancelledMessage(loc i18n.Locale, parent Work, childTitle, childID, reason string) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, parent)
+
+	nudge := render(p.ChildCancelledNudge, map[string]string{
+		"ChildTitle": childTitle,
+		"ChildID":    childID,
+		"Reason":     reason,
+		"ID":         parent.ID,
+	})
+
+	return base + "\n\n" + nudge
+}
+
 // BuildStepAdvanceMessage creates the message sent when advancing to the next step.
 // stepNum is 1-indexed (the step we are advancing TO), totalSteps is the total count.
-func BuildStepAdvanceMessage(w Work, stepPrompt string, stepNum, totalSteps int) string {
-	base := buildBase(w)
+func BuildStepAdvanceMessage(loc i18n.Locale, w Work, stepPrompt string, stepNum, totalSteps int) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, w)
 
-	stepSection := render(prompts.StepAdvanceSection, map[string]any{
+	stepSection := render(p.StepAdvanceSection, map[string]any{
 		"PrevStep":    stepNum - 1,
 		"TotalSteps":  totalSteps,
 		"CurrentStep": stepNum,
@@ -217,19 +303,91 @@ func BuildStepAdvanceMessage(w Work, stepPrompt string, stepNum, totalSteps int)
 
 // BuildReopenMessage appends a reopen nudge to the base message
 // when a closed work item is reopened by the user.
-func BuildReopenMessage(w Work) string {
-	base := buildBase(w)
+func BuildReopenMessage(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	base := buildBase(loc, w)
 
 	var nudge string
 	if w.Type == WorkTypeStory {
-		nudge = render(prompts.StoryReopenNudge, map[string]string{
+		nudge = render(p.StoryReopenNudge, map[string]string{
 			"ID": w.ID,
 		})
 	} else {
-		nudge = render(prompts.TaskReopenNudge, map[string]string{
+		nudge = render(p.TaskReopenNudge, map[string]string{
 			"ID": w.ID,
 		})
 	}
 
 	return base + "\n\n" + nudge
 }
+
+// BuildPlanModeKickoffMessage creates the kickoff for a work started in plan
+// mode. Steps are withheld until the plan is approved.
+func BuildPlanModeKickoffMessage(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	return buildBase(loc, w) + "\n\n" + render(p.PlanModeSection, map[string]string{
+		"ID": w.ID,
+	})
+}
+
+// BuildPlanApprovedMessage tells the agent its plan was approved and repeats
+// the plan so execution does not depend on the planning context.
+func BuildPlanApprovedMessage(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	var plan string
+	if w.Plan != nil {
+		plan = w.Plan.Body
+	}
+	return buildBase(loc, w) + "\n\n" + render(p.PlanApprovedNudge, map[string]string{
+		"ID":   w.ID,
+		"Plan": plan,
+	})
+}
+
+// BuildPlanRevisionMessage relays the user's rejection feedback.
+func BuildPlanRevisionMessage(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	var feedback string
+	if w.Plan != nil {
+		feedback = w.Plan.Feedback
+	}
+	return buildBase(loc, w) + "\n\n" + render(p.PlanRevisionNudge, map[string]string{
+		"ID":       w.ID,
+		"Feedback": feedback,
+	})
+}
+
+// BuildPlanAutoContinuationMessage nudges an agent that went idle while its
+// plan was still drafting.
+func BuildPlanAutoContinuationMessage(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	return buildBase(loc, w) + "\n\n" + render(p.PlanAutoContinueNudge, map[string]string{
+		"ID": w.ID,
+	})
+}
+
+// BuildNoProgressMessage replaces the auto-continuation after a turn that
+// made no progress. It is deliberately short: the agent already has the
+// work context, and needs only to finish or explain what blocks it.
+func BuildNoProgressMessage(loc i18n.Locale, w Work) string {
+	p := promptsFor(loc)
+	return render(p.NoProgressNudge, map[string]string{
+		"ID": w.ID,
+	})
+}
+
+// BuildCIFailureMessage asks the agent to fix a CI failure on its branch.
+// failedChecks are preformatted lines (check name and link).
+func BuildCIFailureMessage(loc i18n.Locale, w Work, branch, sha string, failedChecks []string) string {
+	p := promptsFor(loc)
+	lines := make([]string, len(failedChecks))
+	for i, c := range failedChecks {
+		lines[i] = "- " + c
+	}
+	return render(p.CIFailureNudge, map[string]string{
+		"ID":           w.ID,
+		"Branch":       branch,
+		"SHA":          sha,
+		"FailedChecks": strings.Join(lines, "\n"),
+	})
+}
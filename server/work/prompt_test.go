@@ -1,8 +1,12 @@
 package work
 
 import (
+	"bytes"
 	"strings"
 	"testing"
+
+	"github.com/pockode/server/i18n"
+	"gopkg.in/yaml.v3"
 )
 
 func assertContains(t *testing.T, msg, substr, label string) {
@@ -20,7 +24,7 @@ func TestBuildKickoffMessage_Task(t *testing.T) {
 		Title:       "Fix the bug",
 	}
 
-	msg := BuildKickoffMessage(w)
+	msg := BuildKickoffMessage(i18n.English, w)
 
 	assertContains(t, msg, testRoleID, "agent role ID")
 	assertContains(t, msg, "agent_role_get", "agent_role_get instruction")
@@ -43,7 +47,7 @@ func TestBuildKickoffMessage_Story(t *testing.T) {
 		Title:       "Big feature",
 	}
 
-	msg := BuildKickoffMessage(w)
+	msg := BuildKickoffMessage(i18n.English, w)
 
 	assertContains(t, msg, "Big feature", "story title")
 	assertContains(t, msg, storyBehaviorRules, "story behavior rules")
@@ -53,7 +57,7 @@ func TestBuildKickoffMessage_Story(t *testing.T) {
 }
 
 func TestBuildKickoffMessage_RoleRefComesFirst(t *testing.T) {
-	msg := BuildKickoffMessage(Work{
+	msg := BuildKickoffMessage(i18n.English, Work{
 		ID: "t1", Type: WorkTypeTask, AgentRoleID: testRoleID, Title: "T",
 	})
 
@@ -82,8 +86,8 @@ func TestBuildAutoContinuationMessage_ContainsBaseAndNudge(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			base := BuildKickoffMessage(tc.w)
-			cont := BuildAutoContinuationMessage(tc.w)
+			base := BuildKickoffMessage(i18n.English, tc.w)
+			cont := BuildAutoContinuationMessage(i18n.English, tc.w)
 
 			if !strings.Contains(cont, base) {
 				t.Error("auto-continuation should contain the full kickoff base")
@@ -102,7 +106,7 @@ func TestBuildKickoffMessage_TaskWithParent_ReportViaComment(t *testing.T) {
 		Title:       "Fix bug",
 	}
 
-	msg := BuildKickoffMessage(w)
+	msg := BuildKickoffMessage(i18n.English, w)
 
 	assertContains(t, msg, "work_comment_list", "work_comment_list instruction for parent comments")
 	assertContains(t, msg, "work_comment_add", "work_comment_add instruction")
@@ -120,7 +124,7 @@ func TestBuildKickoffMessage_TaskWithoutParent_NoCommentInstruction(t *testing.T
 		Title:       "Fix bug",
 	}
 
-	msg := BuildKickoffMessage(w)
+	msg := BuildKickoffMessage(i18n.English, w)
 
 	if strings.Contains(msg, "work_comment_add") {
 		t.Error("task without parent should not mention work_comment_add")
@@ -148,8 +152,8 @@ func TestBuildRestartMessage_ContainsBaseAndNudge(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			base := BuildKickoffMessage(tc.w)
-			restart := BuildRestartMessage(tc.w)
+			base := BuildKickoffMessage(i18n.English, tc.w)
+			restart := BuildRestartMessage(i18n.English, tc.w)
 
 			if !strings.Contains(restart, base) {
 				t.Error("restart message should contain the full kickoff base")
@@ -207,7 +211,7 @@ func TestFormatStepSection(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			result := formatStepSection("test-work-id", tc.steps, tc.index)
+			result := formatStepSection(&prompts, "test-work-id", tc.steps, tc.index)
 			if tc.wantNil {
 				if result != "" {
 					t.Errorf("expected empty string, got %q", result)
@@ -224,8 +228,8 @@ func TestFormatStepSection(t *testing.T) {
 func TestBuildKickoffMessageWithSteps_NoSteps(t *testing.T) {
 	w := Work{ID: "t1", Type: WorkTypeTask, AgentRoleID: testRoleID, Title: "T"}
 
-	msgWithoutSteps := BuildKickoffMessage(w)
-	msgWithEmptySteps := BuildKickoffMessageWithSteps(w, []string{}, 0)
+	msgWithoutSteps := BuildKickoffMessage(i18n.English, w)
+	msgWithEmptySteps := BuildKickoffMessageWithSteps(i18n.English, w, []string{}, 0)
 
 	if msgWithoutSteps != msgWithEmptySteps {
 		t.Error("empty steps should produce same message as no steps")
@@ -236,10 +240,10 @@ func TestBuildKickoffMessageWithSteps_WithSteps(t *testing.T) {
 	w := Work{ID: "t1", Type: WorkTypeTask, AgentRoleID: testRoleID, Title: "T"}
 	steps := []string{"Implement feature", "Write tests", "Update docs"}
 
-	msg := BuildKickoffMessageWithSteps(w, steps, 0)
+	msg := BuildKickoffMessageWithSteps(i18n.English, w, steps, 0)
 
 	// Should contain base message
-	base := BuildKickoffMessage(w)
+	base := BuildKickoffMessage(i18n.English, w)
 	if !strings.Contains(msg, base) {
 		t.Error("message should contain base kickoff")
 	}
@@ -253,10 +257,10 @@ func TestBuildKickoffMessageWithSteps_WithSteps(t *testing.T) {
 func TestBuildStepAdvanceMessage_Format(t *testing.T) {
 	w := Work{ID: "t1", Type: WorkTypeTask, AgentRoleID: testRoleID, Title: "T"}
 
-	msg := BuildStepAdvanceMessage(w, "Write tests", 2, 3)
+	msg := BuildStepAdvanceMessage(i18n.English, w, "Write tests", 2, 3)
 
 	// Should contain base message
-	base := BuildKickoffMessage(w)
+	base := BuildKickoffMessage(i18n.English, w)
 	if !strings.Contains(msg, base) {
 		t.Error("message should contain base kickoff")
 	}
@@ -271,8 +275,8 @@ func TestBuildStepAdvanceMessage_Format(t *testing.T) {
 func TestBuildAutoContinuationMessageWithSteps_NoSteps(t *testing.T) {
 	w := Work{ID: "t1", Type: WorkTypeTask, AgentRoleID: testRoleID, Title: "T"}
 
-	msgWithoutSteps := BuildAutoContinuationMessage(w)
-	msgWithEmptySteps := BuildAutoContinuationMessageWithSteps(w, []string{}, 0)
+	msgWithoutSteps := BuildAutoContinuationMessage(i18n.English, w)
+	msgWithEmptySteps := BuildAutoContinuationMessageWithSteps(i18n.English, w, []string{}, 0)
 
 	if msgWithoutSteps != msgWithEmptySteps {
 		t.Error("empty steps should produce same message as no steps")
@@ -283,10 +287,10 @@ func TestBuildAutoContinuationMessageWithSteps_WithSteps(t *testing.T) {
 	w := Work{ID: "t1", Type: WorkTypeTask, AgentRoleID: testRoleID, Title: "T", CurrentStep: 1}
 	steps := []string{"Implement feature", "Write tests", "Update docs"}
 
-	msg := BuildAutoContinuationMessageWithSteps(w, steps, w.CurrentStep)
+	msg := BuildAutoContinuationMessageWithSteps(i18n.English, w, steps, w.CurrentStep)
 
 	// Should contain base message
-	base := BuildKickoffMessage(w)
+	base := BuildKickoffMessage(i18n.English, w)
 	if !strings.Contains(msg, base) {
 		t.Error("message should contain base kickoff")
 	}
@@ -305,7 +309,7 @@ func TestBuildAutoContinuationMessageWithSteps_Story(t *testing.T) {
 	w := Work{ID: "s1", Type: WorkTypeStory, AgentRoleID: testRoleID, Title: "S"}
 	steps := []string{"Step 1", "Step 2"}
 
-	msg := BuildAutoContinuationMessageWithSteps(w, steps, 0)
+	msg := BuildAutoContinuationMessageWithSteps(i18n.English, w, steps, 0)
 
 	assertContains(t, msg, "## Current Step", "step header")
 	assertContains(t, msg, "Step 1 of 2", "step number")
@@ -318,8 +322,8 @@ func TestBuildAutoContinuationMessageWithSteps_InvalidIndex(t *testing.T) {
 
 	// Invalid index should fall back to standard message
 	for _, idx := range []int{-1, 2, 100} {
-		msg := BuildAutoContinuationMessageWithSteps(w, steps, idx)
-		expected := BuildAutoContinuationMessage(w)
+		msg := BuildAutoContinuationMessageWithSteps(i18n.English, w, steps, idx)
+		expected := BuildAutoContinuationMessage(i18n.English, w)
 		if msg != expected {
 			t.Errorf("index %d should fall back to standard message", idx)
 		}
@@ -344,8 +348,8 @@ func TestBuildReopenMessage_ContainsBaseAndNudge(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			base := BuildKickoffMessage(tc.w)
-			reopen := BuildReopenMessage(tc.w)
+			base := BuildKickoffMessage(i18n.English, tc.w)
+			reopen := BuildReopenMessage(i18n.English, tc.w)
 
 			if !strings.Contains(reopen, base) {
 				t.Error("reopen message should contain the full kickoff base")
@@ -354,3 +358,37 @@ func TestBuildReopenMessage_ContainsBaseAndNudge(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildCIFailureMessage(t *testing.T) {
+	w := Work{ID: "t1", Type: WorkTypeTask, AgentRoleID: testRoleID, Title: "T"}
+	msg := BuildCIFailureMessage(i18n.English, w, "feature/x", "abc123", []string{"lint: https://ci/1", "test: https://ci/2"})
+
+	assertContains(t, msg, "CI failed on branch feature/x (commit abc123)", "header")
+	assertContains(t, msg, "- lint: https://ci/1\n- test: https://ci/2", "failed checks")
+	assertContains(t, msg, "work t1", "work ID")
+}
+
+func TestPromptsJapanese(t *testing.T) {
+	t.Run("keys match the English templates", func(t *testing.T) {
+		dec := yaml.NewDecoder(bytes.NewReader(promptsJaYAML))
+		dec.KnownFields(true)
+		var ja promptTemplates
+		if err := dec.Decode(&ja); err != nil {
+			t.Fatalf("prompts.ja.yaml: %v", err)
+		}
+	})
+
+	w := Work{ID: "w-1", Type: WorkTypeTask, ParentID: "p-1", Title: "ログイン修正", AgentRoleID: "role-1"}
+	msg := BuildKickoffMessageWithSteps(i18n.Japanese, w, []string{"調査する", "直す"}, 1)
+	for _, want := range []string{"ログイン修正", "w-1", "p-1", "role-1", "work_comment_list", "直す", "ステップ 2/2", "ワークを完了してください"} {
+		assertContains(t, msg, want, want)
+	}
+	if strings.Contains(msg, "Your agent role ID") {
+		t.Errorf("Japanese kickoff contains English text: %q", msg)
+	}
+
+	// Unsupported locales fall back to English.
+	if got, want := BuildReopenMessage("fr", w), BuildReopenMessage(i18n.English, w); got != want {
+		t.Errorf("fr reopen = %q, want English", got)
+	}
+}
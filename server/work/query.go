@@ -0,0 +1,153 @@
+package work
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// SortField selects the ordering applied by Query.
+type SortField string
+
+const (
+	// SortRank keeps the store's own ordering (creation order, as shown in
+	// the UI). It is the default so unsorted queries match List.
+	SortRank      SortField = "rank"
+	SortCreatedAt SortField = "created_at"
+	SortUpdatedAt SortField = "updated_at"
+	// SortDueAt orders by due date; items without one come last in
+	// either order.
+	SortDueAt SortField = "due_at"
+)
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+// MaxQueryLimit caps a single page so one call cannot pull the whole store.
+const MaxQueryLimit = 200
+
+// ListQuery filters, sorts, and paginates a work list. Zero values mean
+// "no filter", rank order, ascending, and no limit.
+type ListQuery struct {
+	ParentID string       `json:"parent_id,omitempty"`
+	Type     WorkType     `json:"type,omitempty"`
+	Statuses []WorkStatus `json:"status,omitempty"`
+	// DueBefore keeps only items with a due date before it; pass the
+	// current time to list overdue work.
+	DueBefore time.Time `json:"due_before,omitzero"`
+	Sort      SortField `json:"sort,omitempty"`
+	Order     SortOrder `json:"order,omitempty"`
+	Limit     int       `json:"limit,omitempty"`
+	Offset    int       `json:"offset,omitempty"`
+}
+
+// ListPage is one page of a Query result. Total counts every match before
+// pagination so callers can tell whether more pages remain.
+type ListPage struct {
+	Items []Work `json:"items"`
+	Total int    `json:"total"`
+}
+
+// Validate reports malformed query parameters as ErrInvalidWork.
+func (q ListQuery) Validate() error {
+	if q.Type != "" && !ValidateType(q.Type) {
+		return fmt.Errorf("%w: invalid type %q", ErrInvalidWork, q.Type)
+	}
+	for _, s := range q.Statuses {
+		if _, ok := validTransitions[s]; !ok {
+			return fmt.Errorf("%w: invalid status %q", ErrInvalidWork, s)
+		}
+	}
+	switch q.Sort {
+	case "", SortRank, SortCreatedAt, SortUpdatedAt, SortDueAt:
+	default:
+		return fmt.Errorf("%w: invalid sort %q", ErrInvalidWork, q.Sort)
+	}
+	switch q.Order {
+	case "", SortAsc, SortDesc:
+	default:
+		return fmt.Errorf("%w: invalid order %q", ErrInvalidWork, q.Order)
+	}
+	if q.Limit < 0 || q.Limit > MaxQueryLimit {
+		return fmt.Errorf("%w: limit must be between 0 and %d", ErrInvalidWork, MaxQueryLimit)
+	}
+	if q.Offset < 0 {
+		return fmt.Errorf("%w: offset must not be negative", ErrInvalidWork)
+	}
+	return nil
+}
+
+// Query applies q to works (as returned by Store.List) and returns one page.
+// works is not modified.
+func Query(works []Work, q ListQuery) (ListPage, error) {
+	if err := q.Validate(); err != nil {
+		return ListPage{}, err
+	}
+
+	matched := make([]Work, 0, len(works))
+	for _, w := range works {
+		if q.matches(w) {
+			matched = append(matched, w)
+		}
+	}
+
+	var less func(a, b Work) bool
+	switch q.Sort {
+	case SortCreatedAt:
+		less = func(a, b Work) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	case SortUpdatedAt:
+		less = func(a, b Work) bool { return a.UpdatedAt.Before(b.UpdatedAt) }
+	case SortDueAt:
+		less = func(a, b Work) bool { return a.DueAt.Before(b.DueAt) }
+	}
+	if less != nil {
+		sort.SliceStable(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+	}
+	if q.Order == SortDesc {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+	}
+	if q.Sort == SortDueAt {
+		// Undated items have the zero time, so the sort put them first (or
+		// last when reversed); move them behind the dated ones either way.
+		dated := slices.DeleteFunc(slices.Clone(matched), func(w Work) bool { return w.DueAt.IsZero() })
+		undated := slices.DeleteFunc(matched, func(w Work) bool { return !w.DueAt.IsZero() })
+		matched = append(dated, undated...)
+	}
+
+	total := len(matched)
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+
+	return ListPage{Items: matched[start:end], Total: total}, nil
+}
+
+func (q ListQuery) matches(w Work) bool {
+	if q.ParentID != "" && w.ParentID != q.ParentID {
+		return false
+	}
+	if q.Type != "" && w.Type != q.Type {
+		return false
+	}
+	if !q.DueBefore.IsZero() && (w.DueAt.IsZero() || !w.DueAt.Before(q.DueBefore)) {
+		return false
+	}
+	if len(q.Statuses) == 0 {
+		return true
+	}
+	for _, s := range q.Statuses {
+		if w.Status == s {
+			return true
+		}
+	}
+	return false
+}
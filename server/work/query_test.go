@@ -0,0 +1,121 @@
+package work
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func queryFixture() []Work {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []Work{
+		{ID: "s1", Type: WorkTypeStory, Status: StatusOpen, CreatedAt: base, UpdatedAt: base.Add(3 * time.Hour)},
+		{ID: "t1", Type: WorkTypeTask, ParentID: "s1", Status: StatusInProgress, CreatedAt: base.Add(time.Hour), UpdatedAt: base.Add(time.Hour)},
+		{ID: "t2", Type: WorkTypeTask, ParentID: "s1", Status: StatusClosed, CreatedAt: base.Add(2 * time.Hour), UpdatedAt: base.Add(5 * time.Hour)},
+		{ID: "s2", Type: WorkTypeStory, Status: StatusClosed, CreatedAt: base.Add(30 * time.Minute), UpdatedAt: base.Add(4 * time.Hour)},
+	}
+}
+
+func pageIDs(p ListPage) []string {
+	ids := make([]string, len(p.Items))
+	for i, w := range p.Items {
+		ids[i] = w.ID
+	}
+	return ids
+}
+
+func assertIDs(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestQuery_DefaultReturnsAllInRankOrder(t *testing.T) {
+	page, err := Query(queryFixture(), ListQuery{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	assertIDs(t, pageIDs(page), "s1", "t1", "t2", "s2")
+	if page.Total != 4 {
+		t.Errorf("expected total 4, got %d", page.Total)
+	}
+}
+
+func TestQuery_Filters(t *testing.T) {
+	works := queryFixture()
+
+	page, _ := Query(works, ListQuery{ParentID: "s1"})
+	assertIDs(t, pageIDs(page), "t1", "t2")
+
+	page, _ = Query(works, ListQuery{Type: WorkTypeStory})
+	assertIDs(t, pageIDs(page), "s1", "s2")
+
+	page, _ = Query(works, ListQuery{Statuses: []WorkStatus{StatusClosed, StatusOpen}})
+	assertIDs(t, pageIDs(page), "s1", "t2", "s2")
+}
+
+func TestQuery_Sort(t *testing.T) {
+	works := queryFixture()
+
+	page, _ := Query(works, ListQuery{Sort: SortCreatedAt})
+	assertIDs(t, pageIDs(page), "s1", "s2", "t1", "t2")
+
+	page, _ = Query(works, ListQuery{Sort: SortUpdatedAt, Order: SortDesc})
+	assertIDs(t, pageIDs(page), "t2", "s2", "s1", "t1")
+}
+
+func TestQuery_PaginationReportsTotal(t *testing.T) {
+	works := queryFixture()
+
+	page, _ := Query(works, ListQuery{Limit: 2, Offset: 1})
+	assertIDs(t, pageIDs(page), "t1", "t2")
+	if page.Total != 4 {
+		t.Errorf("expected total 4, got %d", page.Total)
+	}
+
+	page, _ = Query(works, ListQuery{Limit: 2, Offset: 10})
+	if len(page.Items) != 0 || page.Total != 4 {
+		t.Errorf("expected empty page with total 4, got %d items, total %d", len(page.Items), page.Total)
+	}
+}
+
+func TestQuery_InvalidParams(t *testing.T) {
+	cases := []ListQuery{
+		{Type: "epic"},
+		{Statuses: []WorkStatus{"done"}},
+		{Sort: "title"},
+		{Order: "sideways"},
+		{Limit: -1},
+		{Limit: MaxQueryLimit + 1},
+		{Offset: -1},
+	}
+	for _, q := range cases {
+		if _, err := Query(queryFixture(), q); !errors.Is(err, ErrInvalidWork) {
+			t.Errorf("Query(%+v): expected ErrInvalidWork, got %v", q, err)
+		}
+	}
+}
+
+func TestQuery_DueAt(t *testing.T) {
+	works := queryFixture()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	works[0].DueAt = base.Add(48 * time.Hour)
+	works[1].DueAt = base.Add(24 * time.Hour)
+	works[3].DueAt = base.Add(72 * time.Hour)
+
+	page, _ := Query(works, ListQuery{Sort: SortDueAt})
+	assertIDs(t, pageIDs(page), "t1", "s1", "s2", "t2")
+
+	// Undated items stay last when the order is reversed.
+	page, _ = Query(works, ListQuery{Sort: SortDueAt, Order: SortDesc})
+	assertIDs(t, pageIDs(page), "s2", "s1", "t1", "t2")
+
+	page, _ = Query(works, ListQuery{DueBefore: base.Add(60 * time.Hour)})
+	assertIDs(t, pageIDs(page), "s1", "t1")
+}
@@ -0,0 +1,144 @@
+package work
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// minReferencePrefix is the shortest ID prefix accepted after '#'. UUIDv7
+// IDs start with a millisecond timestamp, so shorter prefixes would match
+// every item created within the same minute.
+const minReferencePrefix = 8
+
+// referencePattern matches "#<short id, id or id prefix>" at the start of
+// the text or after a non-word character, so URL fragments
+// ("page#abcdef12") and "&#x...;" entities are not taken as references.
+var referencePattern = regexp.MustCompile(`(?i)(?:^|[^\w&/#])#([a-z][a-z0-9]{0,9}-[1-9][0-9]*|[0-9a-f][0-9a-f-]{6,34}[0-9a-f])\b`)
+
+// ParseReferences returns the distinct reference tokens in text, lowercased,
+// in order of first appearance. Tokens are resolved against the store's IDs
+// on save; see resolveReference.
+func ParseReferences(text string) []string {
+	var tokens []string
+	for _, m := range referencePattern.FindAllStringSubmatch(text, -1) {
+		token := strings.ToLower(m[1])
+		if !slices.Contains(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// resolveReference maps a token to the ID of the one work it names: a short
+// ID, an exact ID, or a prefix shared by no other item. Ambiguous and
+// unknown tokens resolve to nothing rather than guessing.
+func resolveReference(works []Work, token string) (string, bool) {
+	if w, ok := findShortID(works, token); ok {
+		return w.ID, true
+	}
+	if len(token) < minReferencePrefix {
+		return "", false
+	}
+	var match string
+	for _, w := range works {
+		if w.ID == token {
+			return w.ID, true
+		}
+		if strings.HasPrefix(w.ID, token) {
+			if match != "" {
+				return "", false
+			}
+			match = w.ID
+		}
+	}
+	return match, match != ""
+}
+
+// linkReferencesLocked re-parses the references in the body and comments of
+// s.works[idx] and keeps the targets' ReferencedBy in step. Targets whose
+// backlinks change are added to modified; their UpdatedAt is left alone so a
+// mention doesn't reorder lists. Reports whether the item's own References
+// changed. Caller must hold s.worksMu.
+func (s *FileStore) linkReferencesLocked(idx int, modified map[string]bool) bool {
+	w := &s.works[idx]
+	texts := []string{w.Body}
+	for _, c := range s.comments {
+		if c.WorkID == w.ID {
+			texts = append(texts, c.Body)
+		}
+	}
+
+	var refs []string
+	for _, text := range texts {
+		for _, token := range ParseReferences(text) {
+			id, ok := resolveReference(s.works, token)
+			if ok && id != w.ID && !slices.Contains(refs, id) {
+				refs = append(refs, id)
+			}
+		}
+	}
+	slices.Sort(refs)
+
+	if slices.Equal(refs, w.References) {
+		return false
+	}
+	sourceID := w.ID
+	for _, id := range w.References {
+		if !slices.Contains(refs, id) {
+			s.updateBacklinksLocked(id, sourceID, false, modified)
+		}
+	}
+	for _, id := range refs {
+		if !slices.Contains(w.References, id) {
+			s.updateBacklinksLocked(id, sourceID, true, modified)
+		}
+	}
+	w.References = refs
+	return true
+}
+
+func (s *FileStore) updateBacklinksLocked(targetID, sourceID string, add bool, modified map[string]bool) {
+	i := s.findIndex(targetID)
+	if i < 0 {
+		return
+	}
+	t := &s.works[i]
+	has := slices.Contains(t.ReferencedBy, sourceID)
+	switch {
+	case add && !has:
+		// Cloned so the caller's rollback snapshot keeps the old slice.
+		t.ReferencedBy = append(slices.Clone(t.ReferencedBy), sourceID)
+		slices.Sort(t.ReferencedBy)
+	case !add && has:
+		t.ReferencedBy = slices.DeleteFunc(slices.Clone(t.ReferencedBy), func(id string) bool { return id == sourceID })
+	default:
+		return
+	}
+	modified[targetID] = true
+}
+
+// unlinkDeletedLocked drops references to and from deleted items from the
+// remaining ones, adding those it changes to modified. Caller must hold
+// s.worksMu.
+func (s *FileStore) unlinkDeletedLocked(deleted []Work, modified map[string]bool) {
+	if len(deleted) == 0 {
+		return
+	}
+	gone := make(map[string]bool, len(deleted))
+	for _, d := range deleted {
+		gone[d.ID] = true
+	}
+	isGone := func(id string) bool { return gone[id] }
+	for i := range s.works {
+		w := &s.works[i]
+		if slices.ContainsFunc(w.References, isGone) {
+			w.References = slices.DeleteFunc(slices.Clone(w.References), isGone)
+			modified[w.ID] = true
+		}
+		if slices.ContainsFunc(w.ReferencedBy, isGone) {
+			w.ReferencedBy = slices.DeleteFunc(slices.Clone(w.ReferencedBy), isGone)
+			modified[w.ID] = true
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package work
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestParseReferences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"see #0192ABCD-ef01", []string{"0192abcd-ef01"}},
+		{"#0192abcd and (#0192abcd), #1234567890", []string{"0192abcd", "1234567890"}},
+		{"too short #0192abc", nil},
+		{"not hex #0192abcz", nil},
+		{"url https://x.test/page#0192abcd", nil},
+		{"entity &#0192abcd;", nil},
+		{"after #PCK-12, before #pck-3", []string{"pck-12", "pck-3"}},
+		{"not a short id #PCK-0", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseReferences(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseReferences(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	works := []Work{{ID: "0192abcd-1111"}, {ID: "0192abcd-2222"}, {ID: "0193ffff-3333"}}
+	tests := []struct {
+		token  string
+		want   string
+		wantOK bool
+	}{
+		{"0192abcd-1111", "0192abcd-1111", true},
+		{"0193ffff", "0193ffff-3333", true},
+		{"0192abcd", "", false}, // ambiguous
+		{"0199aaaa", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := resolveReference(works, tt.token)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("resolveReference(%q) = %q, %v; want %q, %v", tt.token, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestStore_References(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	a := createStory(t, s, "A")
+	b := createStory(t, s, "B")
+
+	var events []ChangeEvent
+	s.AddOnChangeListener(listenerFunc(func(e ChangeEvent) { events = append(events, e) }))
+
+	c, err := s.Create(ctx, Work{Type: WorkTypeStory, Title: "C", Body: "needs #" + a.ID, AgentRoleID: testRoleID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(c.References, []string{a.ID}) {
+		t.Errorf("C.References = %v, want [%s]", c.References, a.ID)
+	}
+	if e := findEvent(events, a.ID); e == nil || e.Op != OperationUpdate || !slices.Equal(e.Work.ReferencedBy, []string{c.ID}) {
+		t.Errorf("expected update event for A with backlink, got %+v", e)
+	}
+
+	// A comment adds a reference; the body's stays.
+	events = nil
+	if _, err := s.AddComment(ctx, c.ID, "also #"+b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := s.Get(c.ID); !slices.Equal(got.References, sortedIDs(a.ID, b.ID)) {
+		t.Errorf("C.References = %v, want A and B", got.References)
+	}
+	if findEvent(events, c.ID) == nil || findEvent(events, b.ID) == nil {
+		t.Errorf("expected update events for C and B, got %d events", len(events))
+	}
+
+	// Editing the body drops A's backlink.
+	body := "no longer blocked"
+	if err := s.Update(ctx, c.ID, UpdateFields{Body: &body}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := s.Get(a.ID); len(got.ReferencedBy) != 0 {
+		t.Errorf("A.ReferencedBy = %v, want empty", got.ReferencedBy)
+	}
+	if got, _, _ := s.Get(b.ID); !slices.Equal(got.ReferencedBy, []string{c.ID}) {
+		t.Errorf("B.ReferencedBy = %v, want [%s]", got.ReferencedBy, c.ID)
+	}
+
+	// Deleting the source removes the backlink.
+	if err := s.Delete(ctx, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := s.Get(b.ID); len(got.ReferencedBy) != 0 {
+		t.Errorf("B.ReferencedBy = %v after delete, want empty", got.ReferencedBy)
+	}
+}
+
+func TestStore_ReferencesByShortID(t *testing.T) {
+	s := newTestStore(t)
+	a := createStory(t, s, "A")
+	b, err := s.Create(context.Background(), Work{Type: WorkTypeStory, Title: "B", Body: "follows #" + a.ShortID, AgentRoleID: testRoleID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(b.References, []string{a.ID}) {
+		t.Errorf("B.References = %v, want [%s]", b.References, a.ID)
+	}
+}
+
+func TestStore_ReferencesIgnoreSelf(t *testing.T) {
+	s := newTestStore(t)
+	a := createStory(t, s, "A")
+	body := "this is #" + a.ID
+	if err := s.Update(context.Background(), a.ID, UpdateFields{Body: &body}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := s.Get(a.ID); len(got.References) != 0 || len(got.ReferencedBy) != 0 {
+		t.Errorf("self reference recorded: %+v", got)
+	}
+}
+
+func sortedIDs(ids ...string) []string {
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,102 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrReviewRequired is returned by StepDone when a story's final step needs
+// a review first. Operations.RequestReview creates and starts it.
+var ErrReviewRequired = errors.New("review required")
+
+// SetReviewRole sets the provider of the agent role that reviews stories
+// before they close; "" disables the review gate. Call before the store is
+// shared.
+func (s *FileStore) SetReviewRole(fn func() string) {
+	s.reviewRole = fn
+}
+
+// closeGate runs closeGateLocked for a StepDone that would close id.
+// Work StepDone will refuse anyway is left for it to report.
+func (s *FileStore) closeGate(id string, totalSteps int) error {
+	s.worksMu.RLock()
+	defer s.worksMu.RUnlock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		return nil
+	}
+	w := s.works[idx]
+	if w.Status != StatusInProgress || w.InPlanPhase() || (totalSteps > 0 && w.CurrentStep < totalSteps-1) {
+		return nil
+	}
+	return s.closeGateLocked(w)
+}
+
+// reviewGateLocked decides whether story w may close under the review gate.
+// Its children must be finished, and its latest review must have closed
+// after every other child did, so a review that reopened tasks is followed
+// by another one. Caller must hold s.worksMu (read or write).
+func (s *FileStore) reviewGateLocked(w Work) error {
+
+	var reviewedAt, lastClosedAt time.Time
+	unfinished := 0
+	for _, c := range s.works {
+		if c.ParentID != w.ID || c.Status == StatusCancelled {
+			continue
+		}
+		switch {
+		case c.Status != StatusClosed && c.Review:
+			return transitionErrorf("review %s is not finished; call work_wait with ID %s", c.ID, w.ID)
+		case c.Status != StatusClosed:
+			unfinished++
+		case c.Review:
+			if c.ClosedAt.After(reviewedAt) {
+				reviewedAt = c.ClosedAt
+			}
+		default:
+			if c.ClosedAt.After(lastClosedAt) {
+				lastClosedAt = c.ClosedAt
+			}
+		}
+	}
+	if unfinished > 0 {
+		return transitionErrorf("%d child item(s) are not finished; call work_wait with ID %s", unfinished, w.ID)
+	}
+	if !reviewedAt.IsZero() && !lastClosedAt.After(reviewedAt) {
+		return nil
+	}
+	return ErrReviewRequired
+}
+
+// RequestReview creates a review task under story for roleID, puts the
+// story in waiting, and starts the review, whose closing resumes the story
+// (see AutoResumer). Called when StepDone returns ErrReviewRequired.
+func (o *Operations) RequestReview(ctx context.Context, storyID, roleID string) (Work, error) {
+	story, found, err := o.store.Get(storyID)
+	if err != nil {
+		return Work{}, err
+	}
+	if !found {
+		return Work{}, ErrWorkNotFound
+	}
+
+	loc := o.getLocale()
+	review, err := o.store.Create(ctx, Work{
+		Type:        WorkTypeTask,
+		ParentID:    story.ID,
+		AgentRoleID: roleID,
+		Title:       BuildReviewTitle(loc, story),
+		Body:        BuildReviewBody(loc, story),
+		Review:      true,
+	})
+	if err != nil {
+		return Work{}, err
+	}
+	// Waiting first, so a review that closes at once still resumes the story.
+	if err := o.store.MarkWaiting(ctx, story.ID); err != nil {
+		return review, err
+	}
+	return o.StartWork(ctx, review.ID, StartOptions{})
+}
@@ -0,0 +1,111 @@
+package work
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const reviewRoleID = "reviewer-role-id"
+
+func TestStepDone_ReviewGate(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	store.SetReviewRole(func() string { return reviewRoleID })
+	ops := NewOperations(store, &recordingStarter{}, nil)
+
+	story := createStory(t, store, "Story")
+	task := createTask(t, store, story.ID, "Task")
+	startWork(t, store, story.ID)
+	startWork(t, store, task.ID)
+
+	if _, err := store.StepDone(ctx, story.ID, 0); !errors.Is(err, ErrInvalidWork) {
+		t.Fatalf("StepDone with an unfinished task: err = %v, want ErrInvalidWork", err)
+	}
+	doneWork(t, store, task.ID)
+
+	// Tasks are not gated.
+	if got := getWork(t, store, task.ID); got.Status != StatusClosed {
+		t.Fatalf("task status = %q, want closed", got.Status)
+	}
+
+	if _, err := store.StepDone(ctx, story.ID, 0); !errors.Is(err, ErrReviewRequired) {
+		t.Fatalf("StepDone without review: err = %v, want ErrReviewRequired", err)
+	}
+
+	review, err := ops.RequestReview(ctx, story.ID, reviewRoleID)
+	if err != nil {
+		t.Fatalf("RequestReview: %v", err)
+	}
+	if !review.Review || review.AgentRoleID != reviewRoleID || review.ParentID != story.ID || review.Status != StatusInProgress {
+		t.Errorf("review = %+v, want a started review task under the story", review)
+	}
+	if got := getWork(t, store, story.ID); got.Status != StatusWaiting {
+		t.Fatalf("story status = %q, want waiting", got.Status)
+	}
+
+	// The reviewer requests changes: the task is reopened, then the review
+	// closes.
+	if err := store.Reopen(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	doneWork(t, store, review.ID)
+	if err := store.ResumeFromWaiting(ctx, story.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.StepDone(ctx, story.ID, 0); !errors.Is(err, ErrInvalidWork) {
+		t.Fatalf("StepDone with a reopened task: err = %v, want ErrInvalidWork", err)
+	}
+
+	// The task closed after the review, so it needs another one.
+	doneWork(t, store, task.ID)
+	if _, err := store.StepDone(ctx, story.ID, 0); !errors.Is(err, ErrReviewRequired) {
+		t.Fatalf("StepDone after changes: err = %v, want ErrReviewRequired", err)
+	}
+
+	second, err := ops.RequestReview(ctx, story.ID, reviewRoleID)
+	if err != nil {
+		t.Fatalf("second RequestReview: %v", err)
+	}
+	if err := store.ResumeFromWaiting(ctx, story.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.StepDone(ctx, story.ID, 0); !errors.Is(err, ErrInvalidWork) {
+		t.Fatalf("StepDone during review: err = %v, want ErrInvalidWork", err)
+	}
+	doneWork(t, store, second.ID)
+
+	if _, err := store.StepDone(ctx, story.ID, 0); err != nil {
+		t.Fatalf("StepDone after review: %v", err)
+	}
+	if got := getWork(t, store, story.ID); got.Status != StatusClosed {
+		t.Errorf("story status = %q, want closed", got.Status)
+	}
+}
+
+func TestStepDone_ReviewGateOff(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	store.SetReviewRole(func() string { return "" })
+
+	story := createStory(t, store, "Story")
+	startWork(t, store, story.ID)
+	if _, err := store.StepDone(ctx, story.ID, 0); err != nil {
+		t.Fatalf("StepDone: %v", err)
+	}
+}
+
+func TestStepDone_ReviewGateOnlyOnLastStep(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	store.SetReviewRole(func() string { return reviewRoleID })
+
+	story := createStory(t, store, "Story")
+	startWork(t, store, story.ID)
+	if more, err := store.StepDone(ctx, story.ID, 2); err != nil || !more {
+		t.Fatalf("StepDone on step 1 of 2 = %v, %v; want advance", more, err)
+	}
+	if _, err := store.StepDone(ctx, story.ID, 2); !errors.Is(err, ErrReviewRequired) {
+		t.Fatalf("StepDone on the last step: err = %v, want ErrReviewRequired", err)
+	}
+}
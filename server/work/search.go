@@ -0,0 +1,381 @@
+package work
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultSearchLimit is the page size when SearchQuery.Limit is zero;
+	// search is for finding a few items, not paging the whole store.
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	maxSearchQueryLen  = 200
+
+	// snippetRadius is the number of runes of body context shown on each
+	// side of the first body match.
+	snippetRadius = 60
+)
+
+// Term scores. Title matches outrank body matches at every level, and an
+// exact substring always outranks a typo-tolerant or subsequence match.
+const (
+	scoreTitleSubstring = 100
+	scoreBodySubstring  = 30
+	scoreTitleTypo      = 60
+	scoreBodyTypo       = 15
+	scoreTitleSubseq    = 40 // minus the gaps between matched runes
+	scoreMinSubseq      = 10
+	bonusTitleWordStart = 20
+	bonusBodyWordStart  = 10
+)
+
+// SearchQuery ranks works against a free-text query. Every whitespace-separated
+// term must match the title or body; Type and Statuses filter as in ListQuery.
+type SearchQuery struct {
+	Query    string       `json:"query"`
+	Type     WorkType     `json:"type,omitempty"`
+	Statuses []WorkStatus `json:"status,omitempty"`
+	Limit    int          `json:"limit,omitempty"`
+}
+
+// Highlight is one run of a title or snippet; Match marks the runs that
+// matched a query term.
+type Highlight struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+type SearchHit struct {
+	Work    Work        `json:"work"`
+	Score   int         `json:"score"`
+	Title   []Highlight `json:"title"`
+	Snippet []Highlight `json:"snippet,omitempty"` // body context around the first body match
+}
+
+// SearchResult is the top hits by score. Total counts every match before the
+// limit is applied.
+type SearchResult struct {
+	Hits  []SearchHit `json:"hits"`
+	Total int         `json:"total"`
+}
+
+// Validate reports malformed search parameters as ErrInvalidWork.
+func (q SearchQuery) Validate() error {
+	if strings.TrimSpace(q.Query) == "" {
+		return fmt.Errorf("%w: query is required", ErrInvalidWork)
+	}
+	if utf8.RuneCountInString(q.Query) > maxSearchQueryLen {
+		return fmt.Errorf("%w: query must be at most %d characters", ErrInvalidWork, maxSearchQueryLen)
+	}
+	if q.Limit < 0 || q.Limit > MaxSearchLimit {
+		return fmt.Errorf("%w: limit must be between 0 and %d", ErrInvalidWork, MaxSearchLimit)
+	}
+	return q.filter().Validate()
+}
+
+func (q SearchQuery) filter() ListQuery {
+	return ListQuery{Type: q.Type, Statuses: q.Statuses}
+}
+
+// Search ranks works (as returned by Store.List) against q. Matching is
+// case-insensitive and tolerant: a term matches as a substring, as a word
+// within one or two edits (typos), or as an in-order subsequence of the
+// title ("lgnpg" finds "login page"). Ties go to the most recently updated.
+func Search(works []Work, q SearchQuery) (SearchResult, error) {
+	if err := q.Validate(); err != nil {
+		return SearchResult{}, err
+	}
+	terms := strings.Fields(strings.ToLower(q.Query))
+	filter := q.filter()
+
+	hits := make([]SearchHit, 0)
+	for _, w := range works {
+		if !filter.matches(w) {
+			continue
+		}
+		if hit, ok := searchWork(w, terms); ok {
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Work.UpdatedAt.After(hits[j].Work.UpdatedAt)
+	})
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+	total := len(hits)
+	return SearchResult{Hits: hits[:min(limit, total)], Total: total}, nil
+}
+
+// span is a matched rune range [start, end).
+type span struct{ start, end int }
+
+func searchWork(w Work, terms []string) (SearchHit, bool) {
+	title, body := []rune(w.Title), []rune(w.Body)
+	titleLower, bodyLower := lowerRunes(title), lowerRunes(body)
+
+	var score int
+	var titleSpans, bodySpans []span
+	for _, term := range terms {
+		t := []rune(term)
+		ts, tSpans := matchTerm(t, titleLower, true)
+		bs, bSpans := matchTerm(t, bodyLower, false)
+		if ts == 0 && bs == 0 {
+			return SearchHit{}, false
+		}
+		score += max(ts, bs)
+		titleSpans = append(titleSpans, tSpans...)
+		bodySpans = append(bodySpans, bSpans...)
+	}
+
+	hit := SearchHit{
+		Work:  w,
+		Score: score,
+		Title: highlight(title, titleSpans),
+	}
+	if len(bodySpans) > 0 {
+		hit.Snippet = snippet(body, bodySpans)
+	}
+	return hit, true
+}
+
+// matchTerm scores one term against a lowered field and returns the spans to
+// highlight. A zero score means no match.
+func matchTerm(term, text []rune, isTitle bool) (int, []span) {
+	if spans := findAll(text, term); len(spans) > 0 {
+		score, bonus := scoreBodySubstring, bonusBodyWordStart
+		if isTitle {
+			score, bonus = scoreTitleSubstring, bonusTitleWordStart
+		}
+		for _, s := range spans {
+			if isWordStart(text, s.start) {
+				score += bonus
+				break
+			}
+		}
+		return score, spans
+	}
+
+	if maxEdits := typoTolerance(len(term)); maxEdits > 0 {
+		for _, word := range words(text) {
+			if editDistance(term, text[word.start:word.end], maxEdits) <= maxEdits {
+				if isTitle {
+					return scoreTitleTypo, []span{word}
+				}
+				return scoreBodyTypo, []span{word}
+			}
+		}
+	}
+
+	if isTitle {
+		if gaps, spans, ok := subsequence(term, text); ok {
+			return max(scoreTitleSubseq-gaps, scoreMinSubseq), spans
+		}
+	}
+	return 0, nil
+}
+
+// typoTolerance allows one edit from 4 runes and two from 8; shorter terms
+// would match too many unrelated words.
+func typoTolerance(n int) int {
+	switch {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func findAll(text, term []rune) []span {
+	var spans []span
+	for i := 0; i+len(term) <= len(text); {
+		if runesEqual(text[i:i+len(term)], term) {
+			spans = append(spans, span{i, i + len(term)})
+			i += len(term)
+			continue
+		}
+		i++
+	}
+	return spans
+}
+
+// subsequence matches term's runes in order within text, allowing at most
+// 2*len(term) skipped runes so scattered letters across a long title don't
+// count. gaps is the number of skipped runes between the first and last match.
+func subsequence(term, text []rune) (gaps int, spans []span, ok bool) {
+	if len(term) < 2 {
+		return 0, nil, false
+	}
+	j := 0
+	first := -1
+	for i := 0; i < len(text) && j < len(term); i++ {
+		if text[i] != term[j] {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		if n := len(spans); n > 0 && spans[n-1].end == i {
+			spans[n-1].end++
+		} else {
+			spans = append(spans, span{i, i + 1})
+		}
+		j++
+	}
+	if j < len(term) {
+		return 0, nil, false
+	}
+	gaps = spans[len(spans)-1].end - first - len(term)
+	if gaps > 2*len(term) {
+		return 0, nil, false
+	}
+	return gaps, spans, true
+}
+
+// editDistance is the Levenshtein distance between a and b, giving up early
+// (returning limit+1) once it must exceed limit.
+func editDistance(a, b []rune, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func words(text []rune) []span {
+	var out []span
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			out = append(out, span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, span{start, len(text)})
+	}
+	return out
+}
+
+func isWordStart(text []rune, i int) bool {
+	return i == 0 || !isWordRune(text[i-1])
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// lowerRunes lowers rune by rune, so indexes into the result are valid for
+// the original (strings.ToLower may change lengths).
+func lowerRunes(rs []rune) []rune {
+	out := make([]rune, len(rs))
+	for i, r := range rs {
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// highlight splits text into alternating unmatched and matched runs.
+func highlight(text []rune, spans []span) []Highlight {
+	if len(text) == 0 {
+		return []Highlight{}
+	}
+	matched := make([]bool, len(text))
+	for _, s := range spans {
+		for i := s.start; i < s.end && i < len(text); i++ {
+			matched[i] = true
+		}
+	}
+	var out []Highlight
+	start := 0
+	for i := 1; i <= len(text); i++ {
+		if i == len(text) || matched[i] != matched[start] {
+			out = append(out, Highlight{Text: string(text[start:i]), Match: matched[start]})
+			start = i
+		}
+	}
+	return out
+}
+
+// snippet returns the body around its earliest match, on one line, with an
+// ellipsis where it was cut.
+func snippet(body []rune, spans []span) []Highlight {
+	first := spans[0]
+	for _, s := range spans[1:] {
+		if s.start < first.start {
+			first = s
+		}
+	}
+	start := max(first.start-snippetRadius, 0)
+	end := min(first.end+snippetRadius, len(body))
+
+	window := make([]rune, end-start)
+	for i, r := range body[start:end] {
+		if r == '\n' || r == '\r' || r == '\t' {
+			r = ' '
+		}
+		window[i] = r
+	}
+	shifted := make([]span, 0, len(spans))
+	for _, s := range spans {
+		if s.end > start && s.start < end {
+			shifted = append(shifted, span{max(s.start, start) - start, min(s.end, end) - start})
+		}
+	}
+
+	out := highlight(window, shifted)
+	if start > 0 {
+		out = append([]Highlight{{Text: "…"}}, out...)
+	}
+	if end < len(body) {
+		out = append(out, Highlight{Text: "…"})
+	}
+	return out
+}
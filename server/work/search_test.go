@@ -0,0 +1,151 @@
+package work
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func searchFixture() []Work {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []Work{
+		{ID: "login", Type: WorkTypeStory, Status: StatusOpen, Title: "Login page", Body: "Users sign in with email.", UpdatedAt: base},
+		{ID: "auth", Type: WorkTypeTask, ParentID: "login", Status: StatusInProgress, Title: "Session tokens", Body: "Refresh the authentication token before the login expires.", UpdatedAt: base.Add(time.Hour)},
+		{ID: "billing", Type: WorkTypeStory, Status: StatusClosed, Title: "Billing export", Body: "CSV export of invoices.", UpdatedAt: base.Add(2 * time.Hour)},
+	}
+}
+
+func hitIDs(r SearchResult) []string {
+	ids := make([]string, len(r.Hits))
+	for i, h := range r.Hits {
+		ids[i] = h.Work.ID
+	}
+	return ids
+}
+
+func renderHighlights(hs []Highlight) string {
+	var b strings.Builder
+	for _, h := range hs {
+		if h.Match {
+			b.WriteString("[" + h.Text + "]")
+		} else {
+			b.WriteString(h.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestSearch_RanksTitleAboveBody(t *testing.T) {
+	result, err := Search(searchFixture(), SearchQuery{Query: "login"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	assertIDs(t, hitIDs(result), "login", "auth")
+	if result.Total != 2 {
+		t.Errorf("total = %d, want 2", result.Total)
+	}
+	if got := renderHighlights(result.Hits[0].Title); got != "[Login] page" {
+		t.Errorf("title = %q", got)
+	}
+	if got := renderHighlights(result.Hits[1].Snippet); !strings.Contains(got, "before the [login] expires") {
+		t.Errorf("snippet = %q", got)
+	}
+}
+
+func TestSearch_AllTermsMustMatch(t *testing.T) {
+	result, err := Search(searchFixture(), SearchQuery{Query: "token login"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	assertIDs(t, hitIDs(result), "auth")
+}
+
+func TestSearch_Fuzzy(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"autentication", "auth"}, // one deletion
+		{"invioces", "billing"},   // transposition = two edits, allowed from 8 runes
+		{"lgnpg", "login"},        // title subsequence
+	}
+	for _, tt := range tests {
+		result, err := Search(searchFixture(), SearchQuery{Query: tt.query})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		if len(result.Hits) == 0 || result.Hits[0].Work.ID != tt.want {
+			t.Errorf("%s: hits = %v, want %s first", tt.query, hitIDs(result), tt.want)
+		}
+	}
+
+	// Short terms get no typo tolerance.
+	result, err := Search(searchFixture(), SearchQuery{Query: "csx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 0 {
+		t.Errorf("csx: hits = %v, want none", hitIDs(result))
+	}
+}
+
+func TestSearch_FiltersAndLimit(t *testing.T) {
+	works := searchFixture()
+	result, err := Search(works, SearchQuery{Query: "e", Statuses: []WorkStatus{StatusOpen, StatusInProgress}, Limit: 1})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if result.Total != 2 || len(result.Hits) != 1 {
+		t.Errorf("total=%d hits=%d, want 2 and 1", result.Total, len(result.Hits))
+	}
+
+	result, err = Search(works, SearchQuery{Query: "export", Type: WorkTypeTask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 0 {
+		t.Errorf("type filter: hits = %v", hitIDs(result))
+	}
+}
+
+func TestSearch_TiesGoToRecentlyUpdated(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	works := []Work{
+		{ID: "old", Title: "Fix crash", UpdatedAt: base},
+		{ID: "new", Title: "Fix crash", UpdatedAt: base.Add(time.Hour)},
+	}
+	result, err := Search(works, SearchQuery{Query: "crash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertIDs(t, hitIDs(result), "new", "old")
+}
+
+func TestSearch_SnippetIsTrimmed(t *testing.T) {
+	body := strings.Repeat("lorem ", 30) + "needle\nin the haystack" + strings.Repeat(" ipsum", 30)
+	result, err := Search([]Work{{ID: "w", Title: "T", Body: body}}, SearchQuery{Query: "needle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := renderHighlights(result.Hits[0].Snippet)
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("snippet should be cut on both sides: %q", got)
+	}
+	if !strings.Contains(got, "[needle] in the haystack") {
+		t.Errorf("snippet = %q", got)
+	}
+}
+
+func TestSearch_Invalid(t *testing.T) {
+	for _, q := range []SearchQuery{
+		{Query: "  "},
+		{Query: strings.Repeat("x", maxSearchQueryLen+1)},
+		{Query: "x", Limit: MaxSearchLimit + 1},
+		{Query: "x", Statuses: []WorkStatus{"bogus"}},
+	} {
+		if _, err := Search(nil, q); !errors.Is(err, ErrInvalidWork) {
+			t.Errorf("%+v: err = %v, want ErrInvalidWork", q, err)
+		}
+	}
+}
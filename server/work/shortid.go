@@ -0,0 +1,157 @@
+package work
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// defaultShortIDPrefix is used when the project name has no ASCII letters.
+const defaultShortIDPrefix = "W"
+
+var shortIDPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]{0,9}-[1-9][0-9]*$`)
+
+// shortIDKeys are the JSON parameter keys that hold work IDs.
+var shortIDKeys = []string{"id", "ids", "work_id", "parent_id", "story_id"}
+
+// IsShortID reports whether s has the form of a short ID ("PCK-12").
+func IsShortID(s string) bool {
+	return shortIDPattern.MatchString(s)
+}
+
+func formatShortID(prefix string, seq int) string {
+	return fmt.Sprintf("%s-%d", prefix, seq)
+}
+
+// ShortIDPrefix derives a project's short ID prefix from its name: the
+// first letter followed by the next consonants, up to three letters, upper
+// case ("pockode" → "PCK"). Falls back to the remaining letters for names
+// with too few consonants.
+func ShortIDPrefix(name string) string {
+	var letters []rune
+	for _, r := range name {
+		if r <= unicode.MaxASCII && unicode.IsLetter(r) {
+			letters = append(letters, unicode.ToUpper(r))
+		}
+	}
+	if len(letters) == 0 {
+		return defaultShortIDPrefix
+	}
+	prefix := []rune{letters[0]}
+	for _, r := range letters[1:] {
+		if len(prefix) == 3 {
+			break
+		}
+		if !strings.ContainsRune("AEIOU", r) {
+			prefix = append(prefix, r)
+		}
+	}
+	for _, r := range letters[1:] {
+		if len(prefix) == 3 {
+			break
+		}
+		if strings.ContainsRune("AEIOU", r) {
+			prefix = append(prefix, r)
+		}
+	}
+	return string(prefix)
+}
+
+// projectName guesses the project from the data directory, which defaults
+// to <project>/.pockode.
+func projectName(dataDir string) string {
+	dir := filepath.Clean(dataDir)
+	if strings.HasPrefix(filepath.Base(dir), ".") {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Base(dir)
+}
+
+// findShortID returns the work with the given short ID, ignoring case.
+func findShortID(works []Work, shortID string) (Work, bool) {
+	for _, w := range works {
+		if w.ShortID != "" && strings.EqualFold(w.ShortID, shortID) {
+			return w, true
+		}
+	}
+	return Work{}, false
+}
+
+// ResolveShortIDs rewrites short IDs in request parameters to the full IDs
+// of the works they name, so every RPC and MCP tool accepts either form.
+// Only string values under work ID keys (shortIDKeys, at any depth, or in
+// arrays under them) are considered; unknown short IDs are left as they
+// are for the handler to report as not found.
+func ResolveShortIDs(params json.RawMessage, works []Work) (json.RawMessage, error) {
+	if len(params) == 0 {
+		return params, nil
+	}
+	var v any
+	if err := json.Unmarshal(params, &v); err != nil {
+		return params, nil // the handler reports malformed params
+	}
+	if !resolveShortIDsIn(v, works, false) {
+		return params, nil
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal resolved params: %w", err)
+	}
+	return out, nil
+}
+
+// resolveShortIDsIn rewrites v in place and reports whether anything changed.
+func resolveShortIDsIn(v any, works []Work, idValue bool) bool {
+	changed := false
+	switch x := v.(type) {
+	case map[string]any:
+		for k, child := range x {
+			isKey := slices.Contains(shortIDKeys, k)
+			if s, ok := child.(string); ok && isKey {
+				if w, found := resolveShortID(works, s); found {
+					x[k] = w
+					changed = true
+				}
+				continue
+			}
+			if resolveShortIDsIn(child, works, isKey) {
+				changed = true
+			}
+		}
+	case []any:
+		for i, child := range x {
+			if s, ok := child.(string); ok && idValue {
+				if w, found := resolveShortID(works, s); found {
+					x[i] = w
+					changed = true
+				}
+				continue
+			}
+			if resolveShortIDsIn(child, works, false) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// ResolveID returns the full ID of the work a short ID names, or id as it
+// is when it is not a known short ID.
+func ResolveID(works []Work, id string) string {
+	if full, ok := resolveShortID(works, id); ok {
+		return full
+	}
+	return id
+}
+
+func resolveShortID(works []Work, s string) (string, bool) {
+	if !IsShortID(s) {
+		return "", false
+	}
+	w, ok := findShortID(works, s)
+	return w.ID, ok
+}
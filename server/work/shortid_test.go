@@ -0,0 +1,124 @@
+package work
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShortIDPrefix(t *testing.T) {
+	tests := map[string]string{
+		"pockode": "PCK",
+		"my-app":  "MYP",
+		"aeo":     "AEO",
+		"Ada":     "ADA",
+		"x":       "X",
+		"2024":    "W",
+		"プロジェクト":  "W",
+	}
+	for name, want := range tests {
+		if got := ShortIDPrefix(name); got != want {
+			t.Errorf("ShortIDPrefix(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestStore_ShortIDs(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "pockode", ".pockode")
+	s, err := NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := createStory(t, s, "A")
+	b := createStory(t, s, "B")
+	if a.ShortID != "PCK-1" || b.ShortID != "PCK-2" {
+		t.Fatalf("short IDs = %q, %q; want PCK-1, PCK-2", a.ShortID, b.ShortID)
+	}
+
+	if got, found, _ := s.Get("pck-2"); !found || got.ID != b.ID {
+		t.Errorf("Get by short ID = %+v, %v; want B", got, found)
+	}
+
+	// Numbers are never reused, even after a delete and reopen.
+	if err := s.Delete(context.Background(), b.ID); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := createStory(t, reopened, "C"); c.ShortID != "PCK-3" {
+		t.Errorf("after reopen short ID = %q, want PCK-3", c.ShortID)
+	}
+}
+
+func TestStore_ShortIDSequenceFromDisk(t *testing.T) {
+	dataDir := t.TempDir()
+	s1, _ := NewFileStore(dataDir)
+	s2, _ := NewFileStore(dataDir)
+
+	// Another process sharing the data dir has advanced the sequence; s1
+	// must not hand out a number s2 already used.
+	w2 := createStory(t, s2, "from s2")
+	w1 := createStory(t, s1, "from s1")
+	if w1.ShortID == w2.ShortID {
+		t.Errorf("both stores assigned %q", w1.ShortID)
+	}
+}
+
+func TestStore_BackfillsShortIDs(t *testing.T) {
+	dataDir := t.TempDir()
+	legacy := `{"works":[
+		{"id":"b","type":"story","title":"Newer","status":"open","created_at":"2026-02-01T00:00:00Z","updated_at":"2026-02-01T00:00:00Z"},
+		{"id":"a","type":"story","title":"Older","status":"open","created_at":"2026-01-01T00:00:00Z","updated_at":"2026-01-01T00:00:00Z"}
+	]}`
+	if err := os.MkdirAll(filepath.Join(dataDir, "works"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "works", "index.json"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	older, _, _ := s.Get("a")
+	newer, _, _ := s.Get("b")
+	if older.ShortID != "W-1" || newer.ShortID != "W-2" {
+		t.Errorf("backfilled = %q, %q; want W-1 (older), W-2", older.ShortID, newer.ShortID)
+	}
+	if c := createStory(t, s, "C"); c.ShortID != "W-3" {
+		t.Errorf("next short ID = %q, want W-3", c.ShortID)
+	}
+}
+
+func TestResolveShortIDs(t *testing.T) {
+	works := []Work{{ID: "uuid-1", ShortID: "PCK-1"}, {ID: "uuid-2", ShortID: "PCK-2"}}
+	params := `{"id":"pck-1","title":"PCK-2","ops":[{"id":"PCK-2","action":"stop"}],"ids":["PCK-1","PCK-9"],"parent_id":"uuid-2"}`
+
+	got, err := ResolveShortIDs(json.RawMessage(params), works)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]any
+	if err := json.Unmarshal(got, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["id"] != "uuid-1" || v["title"] != "PCK-2" || v["parent_id"] != "uuid-2" {
+		t.Errorf("resolved = %s", got)
+	}
+	if op := v["ops"].([]any)[0].(map[string]any); op["id"] != "uuid-2" {
+		t.Errorf("nested op id = %v, want uuid-2", op["id"])
+	}
+	if ids := v["ids"].([]any); ids[0] != "uuid-1" || ids[1] != "PCK-9" {
+		t.Errorf("ids = %v, want [uuid-1 PCK-9]", ids)
+	}
+
+	unchanged := json.RawMessage(`{"id":"uuid-1"}`)
+	if got, _ := ResolveShortIDs(unchanged, works); string(got) != string(unchanged) {
+		t.Errorf("params without short IDs rewritten: %s", got)
+	}
+}
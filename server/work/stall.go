@@ -0,0 +1,62 @@
+package work
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TurnSnapshot records what a work session had done when a turn ended. The
+// AutoResumer compares the snapshots of consecutive auto-continuations to
+// catch an agent that keeps ending its turn without progressing, e.g. one
+// that says it is done but never calls step_done.
+type TurnSnapshot struct {
+	ToolCalls   int    // commands run and files written by the session so far
+	LastMessage string // the agent's text in the turn
+}
+
+// ProgressProbe takes a work session's TurnSnapshot.
+type ProgressProbe interface {
+	Snapshot(sessionID string) (TurnSnapshot, error)
+}
+
+// similarMessageThreshold is the share of words two turns' messages must
+// have in common to count as the agent repeating itself.
+const similarMessageThreshold = 0.8
+
+// madeProgress reports whether the turn ending in cur got anywhere since
+// the one ending in prev: it ran a command or wrote a file, or said
+// something substantially new.
+func madeProgress(prev, cur TurnSnapshot) bool {
+	if cur.ToolCalls != prev.ToolCalls {
+		return true
+	}
+	return !similarText(prev.LastMessage, cur.LastMessage)
+}
+
+// similarText compares the word sets of a and b (Jaccard index), ignoring
+// case and punctuation.
+func similarText(a, b string) bool {
+	wa, wb := wordSet(a), wordSet(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return len(wa) == len(wb)
+	}
+	common := 0
+	for w := range wa {
+		if wb[w] {
+			common++
+		}
+	}
+	union := len(wa) + len(wb) - common
+	return float64(common)/float64(union) >= similarMessageThreshold
+}
+
+func wordSet(s string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
@@ -0,0 +1,24 @@
+package work
+
+import "testing"
+
+func TestMadeProgress(t *testing.T) {
+	done := TurnSnapshot{ToolCalls: 3, LastMessage: "I'm done with the task."}
+	tests := []struct {
+		name string
+		cur  TurnSnapshot
+		want bool
+	}{
+		{"same reply, no tool calls", TurnSnapshot{ToolCalls: 3, LastMessage: "I'm done with the task!"}, false},
+		{"ran a command", TurnSnapshot{ToolCalls: 4, LastMessage: "I'm done with the task."}, true},
+		{"new reply", TurnSnapshot{ToolCalls: 3, LastMessage: "The build fails because the API key is missing."}, true},
+		{"empty reply", TurnSnapshot{ToolCalls: 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := madeProgress(done, tt.cur); got != tt.want {
+				t.Errorf("madeProgress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
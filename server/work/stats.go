@@ -0,0 +1,143 @@
+package work
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+const (
+	DefaultStatsWeeks = 8
+	MaxStatsWeeks     = 52
+)
+
+// Stats summarizes the work board: how much is where, how much gets done
+// per week, and how long it takes.
+type Stats struct {
+	Counts StatusCounts `json:"counts"`
+	// Throughput is the number of items closed per week, oldest first;
+	// weeks start on Monday (server local time) and the last is the
+	// current, partial week.
+	Throughput []WeekThroughput `json:"throughput"`
+	CycleTime  CycleTime        `json:"cycle_time"`
+	ByRole     []RoleStats      `json:"by_role"`
+}
+
+// StatusCounts is the number of items per status.
+type StatusCounts map[WorkStatus]int
+
+type WeekThroughput struct {
+	WeekStart time.Time `json:"week_start"`
+	Closed    int       `json:"closed"`
+}
+
+// CycleTime is the time from first start to close of the items closed in
+// the stats window. Items without a recorded start (closed before start
+// times were tracked, or never started) are left out of the sample.
+type CycleTime struct {
+	MedianMinutes float64 `json:"median_minutes"`
+	Samples       int     `json:"samples"`
+}
+
+// RoleStats is the breakdown for one assigned agent role ("" = none).
+type RoleStats struct {
+	AgentRoleID string       `json:"agent_role_id"`
+	Counts      StatusCounts `json:"counts"`
+	Closed      int          `json:"closed"` // within the stats window
+	CycleTime   CycleTime    `json:"cycle_time"`
+}
+
+// StatsOptions narrows ComputeStats.
+type StatsOptions struct {
+	Weeks int      // throughput window; 0 = DefaultStatsWeeks, capped at MaxStatsWeeks
+	Type  WorkType // "" = stories and tasks
+}
+
+// ComputeStats builds board statistics from a snapshot of all works as of
+// now. An item's close time is ClosedAt, or UpdatedAt for items closed
+// before ClosedAt was recorded.
+func ComputeStats(works []Work, now time.Time, opts StatsOptions) Stats {
+	weeks := opts.Weeks
+	if weeks <= 0 {
+		weeks = DefaultStatsWeeks
+	}
+	weeks = min(weeks, MaxStatsWeeks)
+
+	thisWeek := weekStart(now)
+	from := thisWeek.AddDate(0, 0, -7*(weeks-1))
+	stats := Stats{Counts: StatusCounts{}, Throughput: make([]WeekThroughput, weeks)}
+	for i := range stats.Throughput {
+		stats.Throughput[i].WeekStart = from.AddDate(0, 0, 7*i)
+	}
+
+	var cycles []time.Duration
+	roles := make(map[string]*RoleStats)
+	roleCycles := make(map[string][]time.Duration)
+	for _, w := range works {
+		if opts.Type != "" && w.Type != opts.Type {
+			continue
+		}
+		stats.Counts[w.Status]++
+		role := roles[w.AgentRoleID]
+		if role == nil {
+			role = &RoleStats{AgentRoleID: w.AgentRoleID, Counts: StatusCounts{}}
+			roles[w.AgentRoleID] = role
+		}
+		role.Counts[w.Status]++
+
+		if w.Status != StatusClosed {
+			continue
+		}
+		closedAt := w.ClosedAt
+		if closedAt.IsZero() {
+			closedAt = w.UpdatedAt
+		}
+		if closedAt.Before(from) || closedAt.After(now) {
+			continue
+		}
+		// Walk the week starts rather than dividing by 7*24h, which a DST
+		// change inside the window would skew.
+		week := 0
+		for week+1 < weeks && !closedAt.Before(stats.Throughput[week+1].WeekStart) {
+			week++
+		}
+		stats.Throughput[week].Closed++
+		role.Closed++
+
+		if !w.StartedAt.IsZero() && !closedAt.Before(w.StartedAt) {
+			d := closedAt.Sub(w.StartedAt)
+			cycles = append(cycles, d)
+			roleCycles[w.AgentRoleID] = append(roleCycles[w.AgentRoleID], d)
+		}
+	}
+
+	stats.CycleTime = cycleTime(cycles)
+	stats.ByRole = make([]RoleStats, 0, len(roles))
+	for id, role := range roles {
+		role.CycleTime = cycleTime(roleCycles[id])
+		stats.ByRole = append(stats.ByRole, *role)
+	}
+	slices.SortFunc(stats.ByRole, func(a, b RoleStats) int {
+		return cmp.Compare(a.AgentRoleID, b.AgentRoleID)
+	})
+	return stats
+}
+
+func cycleTime(ds []time.Duration) CycleTime {
+	if len(ds) == 0 {
+		return CycleTime{}
+	}
+	slices.Sort(ds)
+	mid := len(ds) / 2
+	median := ds[mid]
+	if len(ds)%2 == 0 {
+		median = (ds[mid-1] + ds[mid]) / 2
+	}
+	return CycleTime{MedianMinutes: median.Minutes(), Samples: len(ds)}
+}
+
+// weekStart returns local midnight on the Monday of t's week.
+func weekStart(t time.Time) time.Time {
+	back := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-back, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,109 @@
+package work
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	// Thursday; the current week starts Monday 2026-03-02.
+	now := time.Date(2026, 3, 5, 12, 0, 0, 0, time.Local)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 10, 0, 0, 0, time.Local) }
+	closed := func(role string, started, closedAt time.Time) Work {
+		return Work{Type: WorkTypeTask, AgentRoleID: role, Status: StatusClosed, StartedAt: started, ClosedAt: closedAt, UpdatedAt: closedAt}
+	}
+
+	works := []Work{
+		closed("dev", day(2), day(3)),                                                    // this week, 24h
+		closed("dev", day(3), day(3).Add(2*time.Hour)),                                   // this week, 2h
+		closed("qa", day(-1), day(1)),                                                    // last week (Feb 27 → Mar 1), 48h
+		closed("qa", time.Time{}, day(4)),                                                // no recorded start
+		{Type: WorkTypeTask, AgentRoleID: "qa", Status: StatusClosed, UpdatedAt: day(4)}, // legacy: UpdatedAt as close time
+		closed("dev", day(-60), day(-50)),                                                // outside the window
+		{Type: WorkTypeStory, AgentRoleID: "dev", Status: StatusInProgress},
+		{Type: WorkTypeTask, Status: StatusOpen},
+	}
+
+	stats := ComputeStats(works, now, StatsOptions{Weeks: 2})
+
+	if stats.Counts[StatusClosed] != 6 || stats.Counts[StatusInProgress] != 1 || stats.Counts[StatusOpen] != 1 {
+		t.Errorf("counts = %v", stats.Counts)
+	}
+	if len(stats.Throughput) != 2 {
+		t.Fatalf("throughput weeks = %d, want 2", len(stats.Throughput))
+	}
+	if want := time.Date(2026, 2, 23, 0, 0, 0, 0, time.Local); !stats.Throughput[0].WeekStart.Equal(want) {
+		t.Errorf("first week = %v, want %v", stats.Throughput[0].WeekStart, want)
+	}
+	if stats.Throughput[0].Closed != 1 || stats.Throughput[1].Closed != 4 {
+		t.Errorf("throughput = %+v", stats.Throughput)
+	}
+	// Samples 2h, 24h, 48h.
+	if stats.CycleTime.Samples != 3 || stats.CycleTime.MedianMinutes != 24*60 {
+		t.Errorf("cycle time = %+v", stats.CycleTime)
+	}
+
+	if len(stats.ByRole) != 3 || stats.ByRole[0].AgentRoleID != "" || stats.ByRole[1].AgentRoleID != "dev" {
+		t.Fatalf("by role = %+v", stats.ByRole)
+	}
+	dev := stats.ByRole[1]
+	if dev.Closed != 2 || dev.Counts[StatusClosed] != 3 || dev.CycleTime.MedianMinutes != 13*60 {
+		t.Errorf("dev = %+v", dev)
+	}
+	qa := stats.ByRole[2]
+	if qa.Closed != 3 || qa.CycleTime.Samples != 1 {
+		t.Errorf("qa = %+v", qa)
+	}
+
+	stories := ComputeStats(works, now, StatsOptions{Type: WorkTypeStory})
+	if len(stories.Throughput) != DefaultStatsWeeks || stories.Counts[StatusInProgress] != 1 || stories.Counts[StatusClosed] != 0 {
+		t.Errorf("stories = %+v", stories)
+	}
+}
+
+func TestFileStore_StartedAtAndClosedAt(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	w := createStory(t, store, "Timed")
+
+	startWork(t, store, w.ID)
+	got, _, _ := store.Get(w.ID)
+	if got.StartedAt.IsZero() {
+		t.Fatal("StartedAt not set on start")
+	}
+	started := got.StartedAt
+
+	if err := store.Stop(ctx, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Claim(ctx, w.ID, StartOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.StepDone(ctx, w.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	got, _, _ = store.Get(w.ID)
+	if !got.StartedAt.Equal(started) {
+		t.Errorf("restart moved StartedAt from %v to %v", started, got.StartedAt)
+	}
+	if got.ClosedAt.IsZero() {
+		t.Error("ClosedAt not set on close")
+	}
+
+	if err := store.Reopen(ctx, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ = store.Get(w.ID); !got.ClosedAt.IsZero() {
+		t.Error("ClosedAt kept after reopen")
+	}
+
+	fresh := createStory(t, store, "Rolled back")
+	startWork(t, store, fresh.ID)
+	if err := store.RollbackStart(ctx, fresh.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ = store.Get(fresh.ID); !got.StartedAt.IsZero() {
+		t.Error("StartedAt kept after fresh-start rollback")
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/pockode/server/filestore"
+	"github.com/pockode/server/session"
 )
 
 // Store provides CRUD operations and change notifications for Work items.
@@ -19,6 +21,11 @@ type Store interface {
 	FindBySessionID(sessionID string) (Work, bool, error)
 
 	Create(ctx context.Context, w Work) (Work, error)
+	// CreateAll creates several work items under one lock with a single
+	// persist, all or nothing: if any item is invalid or refused by a hook,
+	// or the persist fails, none is created. Parents must already exist.
+	// The items are returned in input order.
+	CreateAll(ctx context.Context, ws []Work) ([]Work, error)
 	Update(ctx context.Context, id string, fields UpdateFields) error
 	Delete(ctx context.Context, id string) error
 
@@ -27,7 +34,8 @@ type Store interface {
 	// encapsulates validation, sessionID management, and side effects.
 
 	// Start transitions a work item to in_progress and assigns an explicit
-	// sessionID. Allowed from: open, stopped, needs_input. Use Reactivate for
+	// sessionID. Allowed from: open, stopped, needs_input, needs_attention.
+	// Like Claim, it refuses work over its Budget. Use Reactivate for
 	// process-running detection. To start an agent session use Claim, which
 	// decides the sessionID atomically; Start is the lower-level primitive.
 	Start(ctx context.Context, id string, sessionID string) (Work, error)
@@ -35,10 +43,16 @@ type Store interface {
 	// Claim atomically transitions a work item to in_progress for starting an
 	// agent session. The restart decision and sessionID assignment happen under
 	// the store lock so concurrent claims cannot race: on restart (current status
-	// stopped/needs_input) the existing sessionID is reused to preserve chat
-	// history; otherwise a fresh sessionID is generated. The returned restart
+	// stopped/needs_input/needs_attention) the existing sessionID is reused to
+	// preserve chat history; otherwise a fresh sessionID is generated and counted
+	// in SessionsUsed. Work over its Budget is refused with ErrBudgetExceeded,
+	// and a used-up session limit refuses fresh sessions only. The returned restart
 	// flag tells the caller how to RollbackStart if the kickoff later fails.
-	Claim(ctx context.Context, id string) (w Work, restart bool, err error)
+	// A non-empty opts.AgentRoleID is recorded as SessionAgentRoleID; an empty
+	// one clears it on a fresh start and keeps the session's role on restart.
+	// opts.Mode == plan starts a fresh Plan in drafting; any other explicit
+	// mode drops an unapproved plan (the user skipped the gate).
+	Claim(ctx context.Context, id string, opts StartOptions) (w Work, restart bool, err error)
 
 	// Stop transitions in_progress/needs_input → stopped.
 	Stop(ctx context.Context, id string) error
@@ -46,6 +60,11 @@ type Store interface {
 	// MarkNeedsInput transitions in_progress → needs_input.
 	MarkNeedsInput(ctx context.Context, id string) error
 
+	// MarkNeedsAttention transitions in_progress/needs_input/waiting →
+	// needs_attention, recording reason as AttentionReason. Used by
+	// BudgetGuard once the work's budget ran out.
+	MarkNeedsAttention(ctx context.Context, id string, reason string) error
+
 	// MarkWaiting transitions in_progress → waiting.
 	// Used when the agent is waiting for child work to complete.
 	MarkWaiting(ctx context.Context, id string) error
@@ -64,16 +83,79 @@ type Store interface {
 	// StepDone marks current work progress as complete.
 	// Work advances CurrentStep while more steps remain; otherwise it closes.
 	// Returns hasMoreSteps=true if there are remaining steps after advancement.
+	// Closing returns ErrReviewRequired while the review gate wants a review
+	// (see FileStore.SetReviewRole), and ErrCloseApprovalRequired when the
+	// close policy wants a person to approve it (see ClosePolicy).
 	StepDone(ctx context.Context, id string, totalSteps int) (hasMoreSteps bool, err error)
 
 	// RollbackStart reverts a failed Start. Fresh starts roll back to open
-	// (clearing sessionID); restarts roll back to stopped (preserving sessionID).
+	// (clearing sessionID and uncounting the session); restarts roll back to
+	// stopped (preserving sessionID).
 	RollbackStart(ctx context.Context, id string, wasRestart bool) error
 
+	// Reset rolls stopped work back to open, clearing its session like a
+	// fresh-start rollback, so the next start gets a new session. For work
+	// whose session is gone, such as one moved to the trash.
+	Reset(ctx context.Context, id string) error
+
 	// Reopen transitions a closed work item back to in_progress.
 	// This allows users to add more child work items or continue working.
 	Reopen(ctx context.Context, id string) error
 
+	// ApproveClose closes in_progress or needs_input work whose final
+	// step_done was refused with ErrCloseApprovalRequired, running the done
+	// and closed hooks as StepDone would have.
+	ApproveClose(ctx context.Context, id string) (Work, error)
+
+	// Cancel transitions a work item to cancelled with a required reason,
+	// from any status except cancelled. Unfinished descendants (not closed or
+	// cancelled) are cancelled with it. Returns every item it cancelled,
+	// target first, so the caller can terminate their sessions.
+	Cancel(ctx context.Context, id string, reason string) ([]Work, error)
+
+	// Bulk applies update, delete, stop, and cancel operations in order under
+	// one lock with a single persist. Each operation succeeds or fails on its
+	// own and later operations see the effects of earlier ones; the results
+	// report each outcome in op order. Start is not a store operation — use
+	// Operations.Bulk. A persist failure rolls back the whole batch and is
+	// returned as the error.
+	Bulk(ctx context.Context, ops []BulkOp) ([]BulkResult, error)
+
+	// LogTime adds minutes (> 0) to the work's TimeSpentMinutes. Allowed in
+	// any status, since effort is often logged right before or after closing.
+	LogTime(ctx context.Context, id string, minutes int) (Work, error)
+
+	// SetNotify sets the work's watch or mute flag; NotifyInherit clears it.
+	// Allowed in any status; UpdatedAt is left alone, since watching is not
+	// an edit.
+	SetNotify(ctx context.Context, id string, mode NotifyMode) (Work, error)
+
+	// AddModifiedFiles records paths the work's session changed (see
+	// session.MergeModifiedFiles). Allowed in any status; UpdatedAt is left
+	// alone so attribution doesn't reorder lists.
+	AddModifiedFiles(ctx context.Context, id string, paths []string) error
+
+	// AddTokens adds tokens (> 0) a turn of the work's session used to
+	// TokensUsed and returns the updated work. Allowed in any status;
+	// UpdatedAt is left alone like AddModifiedFiles.
+	AddTokens(ctx context.Context, id string, tokens int64) (Work, error)
+
+	// --- Plan approval gate ---
+
+	// SubmitPlan records the agent's proposed plan and transitions
+	// in_progress → needs_input to await the user's decision. Allowed while
+	// the plan is drafting or rejected.
+	SubmitPlan(ctx context.Context, id string, body string) (Work, error)
+
+	// ResolvePlan records the user's decision on a submitted plan: approved,
+	// or rejected with feedback. The work must be needs_input or stopped; the
+	// caller then resumes the session via Claim.
+	ResolvePlan(ctx context.Context, id string, approved bool, feedback string) (Work, error)
+
+	// UnresolvePlan reverts a ResolvePlan whose resume failed, returning the
+	// plan to submitted so the user can decide again.
+	UnresolvePlan(ctx context.Context, id string) error
+
 	AddComment(ctx context.Context, workID, body string) (Comment, error)
 	UpdateComment(ctx context.Context, commentID, body string) (Comment, error)
 	ListComments(workID string) ([]Comment, error)
@@ -86,14 +168,26 @@ type Store interface {
 // Status and SessionID are not included — use the intent-based transition
 // methods (Start, Stop, StepDone, etc.) for status changes.
 type UpdateFields struct {
-	Title       *string `json:"title,omitempty"`
-	Body        *string `json:"body,omitempty"`
-	AgentRoleID *string `json:"agent_role_id,omitempty"`
+	Title           *string `json:"title,omitempty"`
+	Body            *string `json:"body,omitempty"`
+	AgentRoleID     *string `json:"agent_role_id,omitempty"`
+	EstimateMinutes *int    `json:"estimate_minutes,omitempty"`
+	// DueAt sets the due date; the zero time clears it.
+	DueAt *time.Time `json:"due_at,omitempty"`
+	// Budget replaces the budget; a zero Budget removes it.
+	Budget *Budget `json:"budget,omitempty"`
+	// ClosePolicy sets the close policy; ClosePolicyInherit clears it.
+	ClosePolicy *ClosePolicy `json:"close_policy,omitempty"`
 }
 
 type indexData struct {
 	Works    []Work    `json:"works"`
 	Comments []Comment `json:"comments,omitempty"`
+	// ShortIDPrefix is fixed when the index is first written so existing
+	// short IDs keep their meaning; NextShortSeq is the next number to hand
+	// out.
+	ShortIDPrefix string `json:"short_id_prefix,omitempty"`
+	NextShortSeq  int    `json:"next_short_seq,omitempty"`
 }
 
 // FileStore persists Work items to a JSON file with flock-based inter-process safety.
@@ -104,6 +198,11 @@ type FileStore struct {
 	comments         []Comment
 	listeners        []OnChangeListener
 	commentListeners []OnCommentChangeListener
+	shortIDPrefix    string
+	nextShortSeq     int
+	hooks            LifecycleHooks
+	defaultAgentRole func() string
+	reviewRole       func() string
 }
 
 func NewFileStore(dataDir string) (*FileStore, error) {
@@ -124,10 +223,44 @@ func NewFileStore(dataDir string) (*FileStore, error) {
 	}
 	store.works = idx.Works
 	store.comments = idx.Comments
+	store.shortIDPrefix = idx.ShortIDPrefix
+	if store.shortIDPrefix == "" {
+		store.shortIDPrefix = ShortIDPrefix(projectName(dataDir))
+	}
+	store.nextShortSeq = max(idx.NextShortSeq, 1)
+
+	if store.backfillShortIDs() {
+		if err := store.persistIndex(); err != nil {
+			return nil, fmt.Errorf("persist backfilled short IDs: %w", err)
+		}
+	}
+	store.refreshProgressLocked(make(map[string]bool))
 
 	return store, nil
 }
 
+// backfillShortIDs numbers works created before short IDs existed, oldest
+// first. Reports whether any were assigned.
+func (s *FileStore) backfillShortIDs() bool {
+	var missing []int
+	for i, w := range s.works {
+		if w.ShortID == "" {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return false
+	}
+	slices.SortStableFunc(missing, func(a, b int) int {
+		return s.works[a].CreatedAt.Compare(s.works[b].CreatedAt)
+	})
+	for _, i := range missing {
+		s.works[i].ShortID = formatShortID(s.shortIDPrefix, s.nextShortSeq)
+		s.nextShortSeq++
+	}
+	return true
+}
+
 // --- Read operations ---
 
 func (s *FileStore) List() ([]Work, error) {
@@ -148,6 +281,10 @@ func (s *FileStore) Get(id string) (Work, bool, error) {
 			return w, true, nil
 		}
 	}
+	if IsShortID(id) {
+		w, found := findShortID(s.works, id)
+		return w, found, nil
+	}
 	return Work{}, false, nil
 }
 
@@ -163,18 +300,120 @@ func (s *FileStore) FindBySessionID(sessionID string) (Work, bool, error) {
 	return Work{}, false, nil
 }
 
+// SetDefaultAgentRole sets the provider of the role Create assigns when
+// none is given; it returns "" when there is no usable default. Call before
+// the store is shared.
+func (s *FileStore) SetDefaultAgentRole(fn func() string) {
+	s.defaultAgentRole = fn
+}
+
 // --- Write operations ---
 
-func (s *FileStore) Create(_ context.Context, w Work) (Work, error) {
-	if !ValidateType(w.Type) {
-		return Work{}, fmt.Errorf("%w: invalid type %q", ErrInvalidWork, w.Type)
+func (s *FileStore) Create(ctx context.Context, w Work) (Work, error) {
+	created, err := s.CreateAll(ctx, []Work{w})
+	if err != nil {
+		return Work{}, err
 	}
-	if w.Title == "" {
-		return Work{}, fmt.Errorf("%w: title is required", ErrInvalidWork)
+	return created[0], nil
+}
+
+func (s *FileStore) CreateAll(ctx context.Context, ws []Work) ([]Work, error) {
+	if len(ws) == 0 {
+		return nil, fmt.Errorf("%w: no work items", ErrInvalidWork)
+	}
+	if len(ws) > MaxBulkOps {
+		return nil, fmt.Errorf("%w: at most %d work items per request", ErrInvalidWork, MaxBulkOps)
+	}
+	// Errors name the item only in a batch; a single Create reads as before.
+	itemErr := func(i int, err error) error {
+		if len(ws) == 1 {
+			return err
+		}
+		return fmt.Errorf("item %d: %w", i+1, err)
+	}
+
+	items := make([]Work, len(ws))
+	for i, w := range ws {
+		if !ValidateType(w.Type) {
+			return nil, itemErr(i, fmt.Errorf("%w: invalid type %q", ErrInvalidWork, w.Type))
+		}
+		if w.Title == "" {
+			return nil, itemErr(i, fmt.Errorf("%w: title is required", ErrInvalidWork))
+		}
+		if w.AgentRoleID == "" && s.defaultAgentRole != nil {
+			w.AgentRoleID = s.defaultAgentRole()
+		}
+		if err := s.beforeHooks(ctx, w, LifecycleCreated); err != nil {
+			return nil, itemErr(i, err)
+		}
+		items[i] = w
 	}
 
 	s.worksMu.Lock()
 
+	prev := s.snapshotWorks()
+	now := time.Now()
+	modified := make(map[string]bool)
+	for i, w := range items {
+		work, err := s.newWorkLocked(w, now)
+		if err != nil {
+			s.works = prev
+			s.worksMu.Unlock()
+			return nil, itemErr(i, err)
+		}
+		s.works = append(s.works, work)
+		s.linkReferencesLocked(len(s.works)-1, modified)
+	}
+	first := len(prev)
+
+	// The sequence is taken from the index on disk under the file lock, so
+	// another process sharing the data dir can't hand out the same number.
+	prevSeq := s.nextShortSeq
+	if err := s.file.Update(func(current []byte) ([]byte, error) {
+		var onDisk indexData
+		if len(current) > 0 {
+			if err := json.Unmarshal(current, &onDisk); err != nil {
+				return nil, fmt.Errorf("read short ID sequence: %w", err)
+			}
+		}
+		seq := max(s.nextShortSeq, onDisk.NextShortSeq)
+		for i := first; i < len(s.works); i++ {
+			s.works[i].ShortID = formatShortID(s.shortIDPrefix, seq)
+			seq++
+		}
+		s.nextShortSeq = seq
+		return filestore.MarshalIndex(s.index())
+	}); err != nil {
+		s.works = prev
+		s.nextShortSeq = prevSeq
+		s.worksMu.Unlock()
+		return nil, err
+	}
+
+	// Backlinks between the new items are part of their create events.
+	for i := first; i < len(s.works); i++ {
+		delete(modified, s.works[i].ID)
+	}
+	events := s.updateEventsLocked(prev, modified)
+	created := slices.Clone(s.works[first:])
+	listeners := s.copyListeners()
+	s.worksMu.Unlock()
+
+	for _, w := range created {
+		notify(listeners, ChangeEvent{Op: OperationCreate, Work: w})
+	}
+	for _, e := range events {
+		notify(listeners, e)
+	}
+	for _, w := range created {
+		s.afterHooks(w, LifecycleCreated)
+	}
+	return created, nil
+}
+
+// newWorkLocked validates w against its parent and builds the item to
+// append. Caller must hold s.worksMu.
+func (s *FileStore) newWorkLocked(w Work, now time.Time) (Work, error) {
 	var parent *Work
 	if w.ParentID != "" {
 		for i := range s.works {
@@ -184,53 +423,46 @@ func (s *FileStore) Create(_ context.Context, w Work) (Work, error) {
 			}
 		}
 		if parent == nil {
-			s.worksMu.Unlock()
 			return Work{}, fmt.Errorf("%w: parent %q not found", ErrInvalidWork, w.ParentID)
 		}
 	}
 	if err := ValidateParent(w.Type, parent); err != nil {
-		s.worksMu.Unlock()
 		return Work{}, err
 	}
 	if parent != nil && parent.Status == StatusClosed {
-		s.worksMu.Unlock()
-		return Work{}, fmt.Errorf("%w: parent %s is closed; reopen it first to add children", ErrInvalidWork, parent.ID)
+		return Work{}, transitionErrorf("parent %s is closed; reopen it first to add children", parent.ID)
+	}
+	if parent != nil && parent.Status == StatusCancelled {
+		return Work{}, transitionErrorf("parent %s is cancelled", parent.ID)
 	}
 
 	if w.AgentRoleID == "" {
-		s.worksMu.Unlock()
-		return Work{}, fmt.Errorf("%w: agent_role_id is required", ErrInvalidWork)
+		return Work{}, fmt.Errorf("%w: agent_role_id is required when no default agent role is set", ErrInvalidWork)
+	}
+	if err := ValidateBody(w.Body); err != nil {
+		return Work{}, err
 	}
 
-	now := time.Now()
-	work := Work{
+	return Work{
 		ID:          uuid.Must(uuid.NewV7()).String(),
 		Type:        w.Type,
 		ParentID:    w.ParentID,
 		AgentRoleID: w.AgentRoleID,
 		Title:       w.Title,
 		Body:        w.Body,
+		DueAt:       w.DueAt,
+		Review:      w.Review,
 		Status:      StatusOpen,
 		CreatedAt:   now,
 		UpdatedAt:   now,
-	}
-
-	s.works = append(s.works, work)
-
-	if err := s.persistIndex(); err != nil {
-		s.works = s.works[:len(s.works)-1]
-		s.worksMu.Unlock()
-		return Work{}, err
-	}
-
-	listeners := s.copyListeners()
-	s.worksMu.Unlock()
-
-	notify(listeners, ChangeEvent{Op: OperationCreate, Work: work})
-	return work, nil
+	}, nil
 }
 
 func (s *FileStore) Update(_ context.Context, id string, fields UpdateFields) error {
+	if err := fields.validate(); err != nil {
+		return err
+	}
+
 	s.worksMu.Lock()
 
 	idx := s.findIndex(id)
@@ -244,20 +476,62 @@ func (s *FileStore) Update(_ context.Context, id string, fields UpdateFields) er
 	// Snapshot before mutations so we can roll back on persist failure
 	prev := s.snapshotWorks()
 
-	now := time.Now()
-	if fields.Title != nil {
-		w.Title = *fields.Title
-	}
+	fields.apply(w, time.Now())
+
+	modified := map[string]bool{id: true}
 	if fields.Body != nil {
-		w.Body = *fields.Body
+		s.linkReferencesLocked(idx, modified)
+	}
+	return s.persistAndNotifyUpdates(prev, modified)
+}
+
+func (f UpdateFields) validate() error {
+	if f.EstimateMinutes != nil && *f.EstimateMinutes < 0 {
+		return fmt.Errorf("%w: estimate_minutes must not be negative", ErrInvalidWork)
 	}
-	if fields.AgentRoleID != nil {
-		w.AgentRoleID = *fields.AgentRoleID
+	if f.Budget != nil {
+		if err := f.Budget.Validate(); err != nil {
+			return err
+		}
 	}
-	w.UpdatedAt = now
+	if f.ClosePolicy != nil {
+		if err := ValidateClosePolicy(*f.ClosePolicy); err != nil {
+			return err
+		}
+	}
+	if f.Body != nil {
+		return ValidateBody(*f.Body)
+	}
+	return nil
+}
 
-	modified := map[string]bool{id: true}
-	return s.persistAndNotifyUpdates(prev, modified)
+func (f UpdateFields) apply(w *Work, now time.Time) {
+	if f.Title != nil {
+		w.Title = *f.Title
+	}
+	if f.Body != nil {
+		w.Body = *f.Body
+	}
+	if f.AgentRoleID != nil {
+		w.AgentRoleID = *f.AgentRoleID
+	}
+	if f.EstimateMinutes != nil {
+		w.EstimateMinutes = *f.EstimateMinutes
+	}
+	if f.DueAt != nil {
+		w.DueAt = *f.DueAt
+	}
+	if f.Budget != nil {
+		w.Budget = nil
+		if *f.Budget != (Budget{}) {
+			b := *f.Budget
+			w.Budget = &b
+		}
+	}
+	if f.ClosePolicy != nil {
+		w.ClosePolicy = *f.ClosePolicy
+	}
+	w.UpdatedAt = now
 }
 
 func (s *FileStore) Delete(_ context.Context, id string) error {
@@ -269,21 +543,10 @@ func (s *FileStore) Delete(_ context.Context, id string) error {
 		return ErrWorkNotFound
 	}
 
-	// Collect the target and all descendants for cascade delete.
-	deleteIDs := CollectDescendantIDs(s.works, id)
-
-	var deleted []Work
-	newWorks := make([]Work, 0, len(s.works)-len(deleteIDs))
-	for _, w := range s.works {
-		if deleteIDs[w.ID] {
-			deleted = append(deleted, w)
-		} else {
-			newWorks = append(newWorks, w)
-		}
-	}
-
-	prev := s.works
-	s.works = newWorks
+	prev := s.snapshotWorks()
+	deleted := s.removeSubtreeLocked(id)
+	modified := make(map[string]bool)
+	s.unlinkDeletedLocked(deleted, modified)
 
 	if err := s.persistIndex(); err != nil {
 		s.works = prev
@@ -291,16 +554,44 @@ func (s *FileStore) Delete(_ context.Context, id string) error {
 		return err
 	}
 
+	events := s.updateEventsLocked(prev, modified)
 	listeners := s.copyListeners()
 	s.worksMu.Unlock()
 
 	for _, w := range deleted {
 		notify(listeners, ChangeEvent{Op: OperationDelete, Work: w})
 	}
+	for _, e := range events {
+		notify(listeners, e)
+	}
 	return nil
 }
 
-func (s *FileStore) Start(_ context.Context, id string, sessionID string) (Work, error) {
+// removeSubtreeLocked removes the target and all its descendants (cascade
+// delete) and returns them. It replaces s.works rather than editing it in
+// place, so a slice taken before the call stays a valid rollback snapshot.
+// Caller must hold s.worksMu.
+func (s *FileStore) removeSubtreeLocked(id string) []Work {
+	deleteIDs := CollectDescendantIDs(s.works, id)
+
+	var deleted []Work
+	newWorks := make([]Work, 0, len(s.works)-len(deleteIDs))
+	for _, w := range s.works {
+		if deleteIDs[w.ID] {
+			deleted = append(deleted, w)
+		} else {
+			newWorks = append(newWorks, w)
+		}
+	}
+	s.works = newWorks
+	return deleted
+}
+
+func (s *FileStore) Start(ctx context.Context, id string, sessionID string) (Work, error) {
+	if err := s.startHooks(ctx, id); err != nil {
+		return Work{}, err
+	}
+
 	s.worksMu.Lock()
 
 	idx := s.findIndex(id)
@@ -312,15 +603,28 @@ func (s *FileStore) Start(_ context.Context, id string, sessionID string) (Work,
 	w := &s.works[idx]
 	if !ValidateTransition(w.Status, StatusInProgress) {
 		s.worksMu.Unlock()
-		return Work{}, fmt.Errorf("%w: invalid transition %s → %s", ErrInvalidWork, w.Status, StatusInProgress)
+		return Work{}, transitionErrorf("invalid transition %s → %s", w.Status, StatusInProgress)
+	}
+
+	now := time.Now()
+	fresh := sessionID != w.SessionID
+	if err := w.checkBudget(now, fresh); err != nil {
+		s.worksMu.Unlock()
+		return Work{}, err
 	}
 
 	prev := s.snapshotWorks()
 
-	now := time.Now()
 	w.Status = StatusInProgress
 	w.SessionID = sessionID
+	w.AttentionReason = ""
+	if fresh {
+		w.SessionsUsed++
+	}
 	w.UpdatedAt = now
+	if w.StartedAt.IsZero() {
+		w.StartedAt = now
+	}
 
 	result := *w // copy before persistAndNotifyUpdates releases the lock
 
@@ -328,11 +632,16 @@ func (s *FileStore) Start(_ context.Context, id string, sessionID string) (Work,
 	if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
 		return Work{}, err
 	}
+	s.afterHooks(result, LifecycleStarted)
 
 	return result, nil
 }
 
-func (s *FileStore) Claim(_ context.Context, id string) (Work, bool, error) {
+func (s *FileStore) Claim(ctx context.Context, id string, opts StartOptions) (Work, bool, error) {
+	if err := s.startHooks(ctx, id); err != nil {
+		return Work{}, false, err
+	}
+
 	s.worksMu.Lock()
 
 	idx := s.findIndex(id)
@@ -344,22 +653,51 @@ func (s *FileStore) Claim(_ context.Context, id string) (Work, bool, error) {
 	w := &s.works[idx]
 	if !ValidateTransition(w.Status, StatusInProgress) {
 		s.worksMu.Unlock()
-		return Work{}, false, fmt.Errorf("%w: invalid transition %s → %s", ErrInvalidWork, w.Status, StatusInProgress)
+		return Work{}, false, transitionErrorf("invalid transition %s → %s", w.Status, StatusInProgress)
 	}
 
 	// Decide restart and sessionID under the lock from the current status, so a
 	// concurrent transition cannot make us reuse a stale snapshot's decision.
-	restart := w.Status == StatusStopped || w.Status == StatusNeedsInput
+	restart := w.Status == StatusStopped || w.Status == StatusNeedsInput || w.Status == StatusNeedsAttention
 	sessionID := w.SessionID
-	if !restart || sessionID == "" {
-		sessionID = uuid.Must(uuid.NewV7()).String()
+	fresh := !restart || sessionID == ""
+	if fresh {
+		sessionID = opts.SessionID
+		if sessionID == "" {
+			sessionID = uuid.Must(uuid.NewV7()).String()
+		}
+	}
+	now := time.Now()
+	if err := w.checkBudget(now, fresh); err != nil {
+		s.worksMu.Unlock()
+		return Work{}, false, err
 	}
 
 	prev := s.snapshotWorks()
 
 	w.Status = StatusInProgress
 	w.SessionID = sessionID
-	w.UpdatedAt = time.Now()
+	w.AttentionReason = ""
+	if fresh {
+		w.SessionsUsed++
+	}
+	agentRoleOverride := opts.AgentRoleID
+	if agentRoleOverride == w.AgentRoleID {
+		agentRoleOverride = ""
+	}
+	if agentRoleOverride != "" || !restart {
+		w.SessionAgentRoleID = agentRoleOverride
+	}
+	switch {
+	case opts.Mode == session.ModePlan:
+		w.Plan = &Plan{Status: PlanDrafting}
+	case !restart, opts.Mode != "" && w.InPlanPhase():
+		w.Plan = nil
+	}
+	w.UpdatedAt = now
+	if w.StartedAt.IsZero() {
+		w.StartedAt = w.UpdatedAt
+	}
 
 	result := *w // copy before persistAndNotifyUpdates releases the lock
 
@@ -367,6 +705,7 @@ func (s *FileStore) Claim(_ context.Context, id string) (Work, bool, error) {
 	if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
 		return Work{}, false, err
 	}
+	s.afterHooks(result, LifecycleStarted)
 
 	return result, restart, nil
 }
@@ -383,7 +722,7 @@ func (s *FileStore) Stop(_ context.Context, id string) error {
 	w := &s.works[idx]
 	if !ValidateTransition(w.Status, StatusStopped) {
 		s.worksMu.Unlock()
-		return fmt.Errorf("%w: invalid transition %s → %s", ErrInvalidWork, w.Status, StatusStopped)
+		return transitionErrorf("invalid transition %s → %s", w.Status, StatusStopped)
 	}
 
 	prev := s.snapshotWorks()
@@ -407,7 +746,7 @@ func (s *FileStore) MarkNeedsInput(_ context.Context, id string) error {
 	w := &s.works[idx]
 	if !ValidateTransition(w.Status, StatusNeedsInput) {
 		s.worksMu.Unlock()
-		return fmt.Errorf("%w: invalid transition %s → %s", ErrInvalidWork, w.Status, StatusNeedsInput)
+		return transitionErrorf("invalid transition %s → %s", w.Status, StatusNeedsInput)
 	}
 
 	prev := s.snapshotWorks()
@@ -419,6 +758,31 @@ func (s *FileStore) MarkNeedsInput(_ context.Context, id string) error {
 	return s.persistAndNotifyUpdates(prev, modified)
 }
 
+func (s *FileStore) MarkNeedsAttention(_ context.Context, id string, reason string) error {
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return ErrWorkNotFound
+	}
+
+	w := &s.works[idx]
+	if !ValidateTransition(w.Status, StatusNeedsAttention) {
+		s.worksMu.Unlock()
+		return transitionErrorf("invalid transition %s → %s", w.Status, StatusNeedsAttention)
+	}
+
+	prev := s.snapshotWorks()
+
+	w.Status = StatusNeedsAttention
+	w.AttentionReason = reason
+	w.UpdatedAt = time.Now()
+
+	modified := map[string]bool{id: true}
+	return s.persistAndNotifyUpdates(prev, modified)
+}
+
 func (s *FileStore) Resume(_ context.Context, id string) error {
 	s.worksMu.Lock()
 
@@ -431,7 +795,7 @@ func (s *FileStore) Resume(_ context.Context, id string) error {
 	w := &s.works[idx]
 	if w.Status != StatusNeedsInput {
 		s.worksMu.Unlock()
-		return fmt.Errorf("%w: invalid transition %s → %s (Resume requires needs_input)", ErrInvalidWork, w.Status, StatusInProgress)
+		return transitionErrorf("invalid transition %s → %s (Resume requires needs_input)", w.Status, StatusInProgress)
 	}
 
 	prev := s.snapshotWorks()
@@ -455,7 +819,7 @@ func (s *FileStore) MarkWaiting(_ context.Context, id string) error {
 	w := &s.works[idx]
 	if !ValidateTransition(w.Status, StatusWaiting) {
 		s.worksMu.Unlock()
-		return fmt.Errorf("%w: invalid transition %s → %s", ErrInvalidWork, w.Status, StatusWaiting)
+		return transitionErrorf("invalid transition %s → %s", w.Status, StatusWaiting)
 	}
 
 	prev := s.snapshotWorks()
@@ -479,7 +843,7 @@ func (s *FileStore) ResumeFromWaiting(_ context.Context, id string) error {
 	w := &s.works[idx]
 	if w.Status != StatusWaiting {
 		s.worksMu.Unlock()
-		return fmt.Errorf("%w: invalid transition %s → %s (ResumeFromWaiting requires waiting)", ErrInvalidWork, w.Status, StatusInProgress)
+		return transitionErrorf("invalid transition %s → %s (ResumeFromWaiting requires waiting)", w.Status, StatusInProgress)
 	}
 
 	prev := s.snapshotWorks()
@@ -503,7 +867,7 @@ func (s *FileStore) Reactivate(_ context.Context, id string) error {
 	w := &s.works[idx]
 	if w.Status != StatusStopped {
 		s.worksMu.Unlock()
-		return fmt.Errorf("%w: invalid transition %s → %s (Reactivate requires stopped)", ErrInvalidWork, w.Status, StatusInProgress)
+		return transitionErrorf("invalid transition %s → %s (Reactivate requires stopped)", w.Status, StatusInProgress)
 	}
 
 	prev := s.snapshotWorks()
@@ -515,7 +879,15 @@ func (s *FileStore) Reactivate(_ context.Context, id string) error {
 	return s.persistAndNotifyUpdates(prev, modified)
 }
 
-func (s *FileStore) StepDone(_ context.Context, id string, totalSteps int) (bool, error) {
+func (s *FileStore) StepDone(ctx context.Context, id string, totalSteps int) (bool, error) {
+	// Refuse before the hooks run; re-checked under the lock below.
+	if err := s.closeGate(id, totalSteps); err != nil {
+		return false, err
+	}
+	if err := s.stepDoneHooks(ctx, id, totalSteps); err != nil {
+		return false, err
+	}
+
 	s.worksMu.Lock()
 
 	idx := s.findIndex(id)
@@ -527,7 +899,11 @@ func (s *FileStore) StepDone(_ context.Context, id string, totalSteps int) (bool
 	w := &s.works[idx]
 	if w.Status != StatusInProgress {
 		s.worksMu.Unlock()
-		return false, fmt.Errorf("%w: StepDone requires in_progress status, got %s", ErrInvalidWork, w.Status)
+		return false, transitionErrorf("StepDone requires in_progress status, got %s", w.Status)
+	}
+	if w.InPlanPhase() {
+		s.worksMu.Unlock()
+		return false, transitionErrorf("plan is %s; submit it with work_plan_submit and wait for approval", w.Plan.Status)
 	}
 
 	prev := s.snapshotWorks()
@@ -535,21 +911,31 @@ func (s *FileStore) StepDone(_ context.Context, id string, totalSteps int) (bool
 	if totalSteps > 0 && w.CurrentStep < totalSteps-1 {
 		w.CurrentStep++
 		w.UpdatedAt = time.Now()
+		result := *w
 
 		modified := map[string]bool{id: true}
 		if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
 			return false, err
 		}
+		s.afterHooks(result, LifecycleDone)
 		return true, nil
 	}
 
+	if err := s.closeGateLocked(*w); err != nil {
+		s.worksMu.Unlock()
+		return false, err
+	}
+
 	w.Status = StatusClosed
 	w.UpdatedAt = time.Now()
+	w.ClosedAt = w.UpdatedAt
+	result := *w
 
 	modified := map[string]bool{id: true}
 	if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
 		return false, err
 	}
+	s.afterHooks(result, LifecycleDone, LifecycleClosed)
 	return false, nil
 }
 
@@ -569,17 +955,21 @@ func (s *FileStore) RollbackStart(_ context.Context, id string, wasRestart bool)
 		// Restart rollback: in_progress → stopped, preserve sessionID
 		if !ValidateTransition(w.Status, StatusStopped) {
 			s.worksMu.Unlock()
-			return fmt.Errorf("%w: invalid transition %s → %s", ErrInvalidWork, w.Status, StatusStopped)
+			return transitionErrorf("invalid transition %s → %s", w.Status, StatusStopped)
 		}
 		w.Status = StatusStopped
 	} else {
 		// Fresh start rollback: in_progress → open, clear sessionID
 		if !ValidateTransition(w.Status, StatusOpen) {
 			s.worksMu.Unlock()
-			return fmt.Errorf("%w: invalid transition %s → %s", ErrInvalidWork, w.Status, StatusOpen)
+			return transitionErrorf("invalid transition %s → %s", w.Status, StatusOpen)
 		}
 		w.Status = StatusOpen
 		w.SessionID = ""
+		w.SessionAgentRoleID = ""
+		w.Plan = nil
+		w.StartedAt = time.Time{}
+		w.SessionsUsed = max(w.SessionsUsed-1, 0)
 	}
 	w.UpdatedAt = time.Now()
 
@@ -587,6 +977,33 @@ func (s *FileStore) RollbackStart(_ context.Context, id string, wasRestart bool)
 	return s.persistAndNotifyUpdates(prev, modified)
 }
 
+func (s *FileStore) Reset(_ context.Context, id string) error {
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return ErrWorkNotFound
+	}
+
+	w := &s.works[idx]
+	if w.Status != StatusStopped {
+		s.worksMu.Unlock()
+		return transitionErrorf("invalid transition %s → %s (Reset requires stopped)", w.Status, StatusOpen)
+	}
+	prev := s.snapshotWorks()
+
+	w.Status = StatusOpen
+	w.SessionID = ""
+	w.SessionAgentRoleID = ""
+	w.Plan = nil
+	w.StartedAt = time.Time{}
+	w.UpdatedAt = time.Now()
+
+	modified := map[string]bool{id: true}
+	return s.persistAndNotifyUpdates(prev, modified)
+}
+
 func (s *FileStore) Reopen(_ context.Context, id string) error {
 	s.worksMu.Lock()
 
@@ -599,13 +1016,282 @@ func (s *FileStore) Reopen(_ context.Context, id string) error {
 	w := &s.works[idx]
 	if w.Status != StatusClosed {
 		s.worksMu.Unlock()
-		return fmt.Errorf("%w: Reopen requires closed status, got %s", ErrInvalidWork, w.Status)
+		return transitionErrorf("Reopen requires closed status, got %s", w.Status)
 	}
 
 	prev := s.snapshotWorks()
 
 	w.Status = StatusInProgress
 	w.UpdatedAt = time.Now()
+	w.ClosedAt = time.Time{}
+
+	modified := map[string]bool{id: true}
+	return s.persistAndNotifyUpdates(prev, modified)
+}
+
+func (s *FileStore) Cancel(_ context.Context, id string, reason string) ([]Work, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("%w: cancel reason is required", ErrInvalidWork)
+	}
+
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return nil, ErrWorkNotFound
+	}
+
+	w := &s.works[idx]
+	if !ValidateTransition(w.Status, StatusCancelled) {
+		s.worksMu.Unlock()
+		return nil, transitionErrorf("invalid transition %s → %s", w.Status, StatusCancelled)
+	}
+
+	prev := s.snapshotWorks()
+	modified := make(map[string]bool)
+	cancelled := s.cancelLocked(idx, reason, time.Now(), modified)
+
+	if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// cancelLocked cancels s.works[idx] and its unfinished descendants, adding
+// their IDs to modified. Returns the cancelled items, target first. The
+// transition must already be validated. Caller must hold s.worksMu.
+func (s *FileStore) cancelLocked(idx int, reason string, now time.Time, modified map[string]bool) []Work {
+	w := &s.works[idx]
+	w.Status = StatusCancelled
+	w.CancelReason = reason
+	w.UpdatedAt = now
+	cancelled := []Work{*w}
+	modified[w.ID] = true
+
+	// Finished descendants keep their outcome; the rest are abandoned with
+	// the parent.
+	childReason := "parent cancelled: " + reason
+	descendants := CollectDescendantIDs(s.works, w.ID)
+	for i := range s.works {
+		d := &s.works[i]
+		if d.ID == w.ID || !descendants[d.ID] || d.Status == StatusClosed || d.Status == StatusCancelled {
+			continue
+		}
+		d.Status = StatusCancelled
+		d.CancelReason = childReason
+		d.UpdatedAt = now
+		cancelled = append(cancelled, *d)
+		modified[d.ID] = true
+	}
+	return cancelled
+}
+
+func (s *FileStore) LogTime(_ context.Context, id string, minutes int) (Work, error) {
+	if minutes <= 0 {
+		return Work{}, fmt.Errorf("%w: minutes must be positive", ErrInvalidWork)
+	}
+
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return Work{}, ErrWorkNotFound
+	}
+
+	prev := s.snapshotWorks()
+
+	w := &s.works[idx]
+	w.TimeSpentMinutes += minutes
+	w.UpdatedAt = time.Now()
+	updated := *w
+
+	modified := map[string]bool{id: true}
+	if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
+		return Work{}, err
+	}
+	return updated, nil
+}
+
+func (s *FileStore) SetNotify(_ context.Context, id string, mode NotifyMode) (Work, error) {
+	if err := ValidateNotifyMode(mode); err != nil {
+		return Work{}, err
+	}
+
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return Work{}, ErrWorkNotFound
+	}
+	if s.works[idx].Notify == mode {
+		w := s.works[idx]
+		s.worksMu.Unlock()
+		return w, nil
+	}
+
+	prev := s.snapshotWorks()
+	s.works[idx].Notify = mode
+	updated := s.works[idx]
+
+	if err := s.persistAndNotifyUpdates(prev, map[string]bool{id: true}); err != nil {
+		return Work{}, err
+	}
+	return updated, nil
+}
+
+func (s *FileStore) AddModifiedFiles(_ context.Context, id string, paths []string) error {
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return ErrWorkNotFound
+	}
+
+	merged := session.MergeModifiedFiles(s.works[idx].ModifiedFiles, paths)
+	if len(merged) == len(s.works[idx].ModifiedFiles) {
+		s.worksMu.Unlock()
+		return nil
+	}
+
+	prev := s.snapshotWorks()
+	s.works[idx].ModifiedFiles = merged
+
+	return s.persistAndNotifyUpdates(prev, map[string]bool{id: true})
+}
+
+func (s *FileStore) AddTokens(_ context.Context, id string, tokens int64) (Work, error) {
+	if tokens <= 0 {
+		return Work{}, fmt.Errorf("%w: tokens must be positive", ErrInvalidWork)
+	}
+
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return Work{}, ErrWorkNotFound
+	}
+
+	prev := s.snapshotWorks()
+
+	w := &s.works[idx]
+	w.TokensUsed += tokens
+	updated := *w
+
+	if err := s.persistAndNotifyUpdates(prev, map[string]bool{id: true}); err != nil {
+		return Work{}, err
+	}
+	return updated, nil
+}
+
+func (s *FileStore) SubmitPlan(_ context.Context, id string, body string) (Work, error) {
+	if body == "" {
+		return Work{}, fmt.Errorf("%w: plan body is required", ErrInvalidWork)
+	}
+
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return Work{}, ErrWorkNotFound
+	}
+
+	w := &s.works[idx]
+	if w.Plan == nil || (w.Plan.Status != PlanDrafting && w.Plan.Status != PlanRejected) {
+		s.worksMu.Unlock()
+		return Work{}, transitionErrorf("work %s is not awaiting a plan (start it with mode=plan)", id)
+	}
+	if w.Status != StatusInProgress {
+		s.worksMu.Unlock()
+		return Work{}, transitionErrorf("SubmitPlan requires in_progress status, got %s", w.Status)
+	}
+
+	prev := s.snapshotWorks()
+
+	now := time.Now()
+	w.Plan = &Plan{Status: PlanSubmitted, Body: body, SubmittedAt: now}
+	w.Status = StatusNeedsInput
+	w.UpdatedAt = now
+
+	result := *w
+
+	modified := map[string]bool{id: true}
+	if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
+		return Work{}, err
+	}
+	return result, nil
+}
+
+func (s *FileStore) ResolvePlan(_ context.Context, id string, approved bool, feedback string) (Work, error) {
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return Work{}, ErrWorkNotFound
+	}
+
+	w := &s.works[idx]
+	if w.Plan == nil || w.Plan.Status != PlanSubmitted {
+		s.worksMu.Unlock()
+		return Work{}, transitionErrorf("work %s has no submitted plan", id)
+	}
+	if w.Status != StatusNeedsInput && w.Status != StatusStopped {
+		s.worksMu.Unlock()
+		return Work{}, transitionErrorf("ResolvePlan requires needs_input or stopped status, got %s", w.Status)
+	}
+
+	prev := s.snapshotWorks()
+
+	now := time.Now()
+	plan := *w.Plan
+	plan.DecidedAt = now
+	if approved {
+		plan.Status = PlanApproved
+		plan.Feedback = ""
+	} else {
+		plan.Status = PlanRejected
+		plan.Feedback = feedback
+	}
+	w.Plan = &plan
+	w.UpdatedAt = now
+
+	result := *w
+
+	modified := map[string]bool{id: true}
+	if err := s.persistAndNotifyUpdates(prev, modified); err != nil {
+		return Work{}, err
+	}
+	return result, nil
+}
+
+func (s *FileStore) UnresolvePlan(_ context.Context, id string) error {
+	s.worksMu.Lock()
+
+	idx := s.findIndex(id)
+	if idx < 0 {
+		s.worksMu.Unlock()
+		return ErrWorkNotFound
+	}
+
+	w := &s.works[idx]
+	if w.Plan == nil || (w.Plan.Status != PlanApproved && w.Plan.Status != PlanRejected) {
+		s.worksMu.Unlock()
+		return transitionErrorf("work %s has no resolved plan", id)
+	}
+
+	prev := s.snapshotWorks()
+
+	plan := *w.Plan
+	plan.Status = PlanSubmitted
+	plan.DecidedAt = time.Time{}
+	w.Plan = &plan
+	w.UpdatedAt = time.Now()
 
 	modified := map[string]bool{id: true}
 	return s.persistAndNotifyUpdates(prev, modified)
@@ -621,12 +1307,7 @@ func (s *FileStore) persistAndNotifyUpdates(prev []Work, modified map[string]boo
 		return err
 	}
 
-	var events []ChangeEvent
-	for _, w := range s.works {
-		if modified[w.ID] {
-			events = append(events, ChangeEvent{Op: OperationUpdate, Work: w})
-		}
-	}
+	events := s.updateEventsLocked(prev, modified)
 	listeners := s.copyListeners()
 	s.worksMu.Unlock()
 
@@ -636,6 +1317,32 @@ func (s *FileStore) persistAndNotifyUpdates(prev []Work, modified map[string]boo
 	return nil
 }
 
+// updateEventsLocked refreshes derived progress, then builds update events
+// for the modified works (including parents whose progress changed), in
+// store order. Caller must hold s.worksMu.
+func (s *FileStore) updateEventsLocked(prev []Work, modified map[string]bool) []ChangeEvent {
+	s.refreshProgressLocked(modified)
+	if len(modified) == 0 {
+		return nil
+	}
+	prevStatus := statusByID(prev)
+	var events []ChangeEvent
+	for _, w := range s.works {
+		if modified[w.ID] {
+			events = append(events, ChangeEvent{Op: OperationUpdate, Work: w, PrevStatus: prevStatus[w.ID]})
+		}
+	}
+	return events
+}
+
+func statusByID(works []Work) map[string]WorkStatus {
+	m := make(map[string]WorkStatus, len(works))
+	for _, w := range works {
+		m[w.ID] = w.Status
+	}
+	return m
+}
+
 func (s *FileStore) snapshotWorks() []Work {
 	out := make([]Work, len(s.works))
 	copy(out, s.works)
@@ -647,7 +1354,8 @@ func (s *FileStore) snapshotWorks() []Work {
 func (s *FileStore) AddComment(_ context.Context, workID, body string) (Comment, error) {
 	s.worksMu.Lock()
 
-	if s.findIndex(workID) < 0 {
+	idx := s.findIndex(workID)
+	if idx < 0 {
 		s.worksMu.Unlock()
 		return Comment{}, ErrWorkNotFound
 	}
@@ -659,18 +1367,29 @@ func (s *FileStore) AddComment(_ context.Context, workID, body string) (Comment,
 		CreatedAt: time.Now(),
 	}
 
+	prevWorks := s.snapshotWorks()
 	s.comments = append(s.comments, comment)
+	modified := make(map[string]bool)
+	if s.linkReferencesLocked(idx, modified) {
+		modified[workID] = true
+	}
 
 	if err := s.persistIndex(); err != nil {
 		s.comments = s.comments[:len(s.comments)-1]
+		s.works = prevWorks
 		s.worksMu.Unlock()
 		return Comment{}, err
 	}
 
+	events := s.updateEventsLocked(prevWorks, modified)
+	listeners := s.copyListeners()
 	commentListeners := s.copyCommentListeners()
 	s.worksMu.Unlock()
 
 	notifyComment(commentListeners, CommentEvent{Comment: comment})
+	for _, e := range events {
+		notify(listeners, e)
+	}
 	return comment, nil
 }
 
@@ -708,19 +1427,30 @@ func (s *FileStore) UpdateComment(_ context.Context, commentID, body string) (Co
 	prev := make([]Comment, len(s.comments))
 	copy(prev, s.comments)
 
+	prevWorks := s.snapshotWorks()
 	s.comments[idx].Body = body
 	updated := s.comments[idx]
+	modified := make(map[string]bool)
+	if workIdx := s.findIndex(updated.WorkID); workIdx >= 0 && s.linkReferencesLocked(workIdx, modified) {
+		modified[updated.WorkID] = true
+	}
 
 	if err := s.persistIndex(); err != nil {
 		s.comments = prev
+		s.works = prevWorks
 		s.worksMu.Unlock()
 		return Comment{}, err
 	}
 
+	events := s.updateEventsLocked(prevWorks, modified)
+	listeners := s.copyListeners()
 	commentListeners := s.copyCommentListeners()
 	s.worksMu.Unlock()
 
 	notifyComment(commentListeners, CommentEvent{Comment: updated})
+	for _, e := range events {
+		notify(listeners, e)
+	}
 	return updated, nil
 }
 
@@ -768,19 +1498,18 @@ func notifyComment(listeners []OnCommentChangeListener, event CommentEvent) {
 
 // --- File I/O ---
 
+// Verify re-reads the index from disk under its file lock, reporting
+// whether the store could still be loaded. In-memory state is untouched.
+func (s *FileStore) Verify() error {
+	_, err := s.readIndexFromDisk()
+	return err
+}
+
 func (s *FileStore) readIndexFromDisk() (indexData, error) {
-	data, err := s.file.Read()
+	idx, err := filestore.Load(s.file, indexData{})
 	if err != nil {
 		return indexData{}, err
 	}
-	if data == nil {
-		return indexData{Works: []Work{}}, nil
-	}
-
-	var idx indexData
-	if err := json.Unmarshal(data, &idx); err != nil {
-		return indexData{}, err
-	}
 	if idx.Works == nil {
 		idx.Works = []Work{}
 	}
@@ -791,11 +1520,22 @@ func (s *FileStore) readIndexFromDisk() (indexData, error) {
 }
 
 func (s *FileStore) persistIndex() error {
-	data, err := filestore.MarshalIndex(indexData{Works: s.works, Comments: s.comments})
-	if err != nil {
-		return err
+	return s.file.Persist(s.index())
+}
+
+func (s *FileStore) index() indexData {
+	// Progress is derived from the children on load, so it is not stored.
+	works := make([]Work, len(s.works))
+	for i, w := range s.works {
+		w.Progress = nil
+		works[i] = w
+	}
+	return indexData{
+		Works:         works,
+		Comments:      s.comments,
+		ShortIDPrefix: s.shortIDPrefix,
+		NextShortSeq:  s.nextShortSeq,
 	}
-	return s.file.Write(data)
 }
 
 // --- Helpers ---
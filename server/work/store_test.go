@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/pockode/server/session"
 )
 
 func newTestStore(t *testing.T) *FileStore {
@@ -171,6 +175,77 @@ func TestCreate_AgentRoleIDRequired(t *testing.T) {
 	}
 }
 
+func TestCreate_DefaultAgentRole(t *testing.T) {
+	s := newTestStore(t)
+	def := ""
+	s.SetDefaultAgentRole(func() string { return def })
+
+	if _, err := s.Create(context.Background(), Work{Type: WorkTypeStory, Title: "No default"}); !errors.Is(err, ErrInvalidWork) {
+		t.Fatalf("err = %v, want ErrInvalidWork while there is no default", err)
+	}
+
+	def = "role-default"
+	w, err := s.Create(context.Background(), Work{Type: WorkTypeStory, Title: "Defaulted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.AgentRoleID != "role-default" {
+		t.Errorf("AgentRoleID = %q, want role-default", w.AgentRoleID)
+	}
+
+	// An explicit role wins over the default.
+	w, err = s.Create(context.Background(), Work{Type: WorkTypeStory, Title: "Explicit", AgentRoleID: testRoleID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.AgentRoleID != testRoleID {
+		t.Errorf("AgentRoleID = %q, want %q", w.AgentRoleID, testRoleID)
+	}
+}
+
+func TestCreateAll(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "Parent")
+
+	created, err := s.CreateAll(context.Background(), []Work{
+		{Type: WorkTypeTask, ParentID: story.ID, Title: "First", AgentRoleID: testRoleID},
+		{Type: WorkTypeTask, ParentID: story.ID, Title: "Second", AgentRoleID: testRoleID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 || created[0].Title != "First" || created[1].Title != "Second" {
+		t.Fatalf("created = %+v, want First and Second in order", created)
+	}
+	if created[0].ShortID == "" || created[0].ShortID == created[1].ShortID {
+		t.Errorf("short IDs = %q, %q, want distinct", created[0].ShortID, created[1].ShortID)
+	}
+	if p, _, _ := s.Get(story.ID); p.Progress == nil || p.Progress.Total != 2 {
+		t.Errorf("parent progress = %+v, want 2 children", p.Progress)
+	}
+}
+
+func TestCreateAll_AllOrNothing(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "Parent")
+	before, _ := s.List()
+
+	_, err := s.CreateAll(context.Background(), []Work{
+		{Type: WorkTypeTask, ParentID: story.ID, Title: "Fine", AgentRoleID: testRoleID},
+		{Type: WorkTypeTask, ParentID: "missing", Title: "Orphan", AgentRoleID: testRoleID},
+	})
+	if !errors.Is(err, ErrInvalidWork) || !strings.Contains(err.Error(), "item 2") {
+		t.Fatalf("err = %v, want ErrInvalidWork naming item 2", err)
+	}
+	if after, _ := s.List(); len(after) != len(before) {
+		t.Errorf("works = %d, want %d: nothing created", len(after), len(before))
+	}
+
+	if _, err := s.CreateAll(context.Background(), nil); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("empty batch err = %v, want ErrInvalidWork", err)
+	}
+}
+
 func TestCreate_InvalidType(t *testing.T) {
 	s := newTestStore(t)
 	_, err := s.Create(context.Background(), Work{Type: "epic", Title: "X", AgentRoleID: testRoleID})
@@ -239,7 +314,7 @@ func TestClaim_FreshStartGeneratesSession(t *testing.T) {
 	s := newTestStore(t)
 	story := createStory(t, s, "S")
 
-	w, restart, err := s.Claim(context.Background(), story.ID)
+	w, restart, err := s.Claim(context.Background(), story.ID, StartOptions{})
 	if err != nil {
 		t.Fatalf("Claim: %v", err)
 	}
@@ -258,7 +333,7 @@ func TestClaim_RestartReusesSession(t *testing.T) {
 	s := newTestStore(t)
 	story := createStory(t, s, "S")
 
-	first, _, err := s.Claim(context.Background(), story.ID)
+	first, _, err := s.Claim(context.Background(), story.ID, StartOptions{})
 	if err != nil {
 		t.Fatalf("first Claim: %v", err)
 	}
@@ -266,7 +341,7 @@ func TestClaim_RestartReusesSession(t *testing.T) {
 		t.Fatalf("Stop: %v", err)
 	}
 
-	again, restart, err := s.Claim(context.Background(), story.ID)
+	again, restart, err := s.Claim(context.Background(), story.ID, StartOptions{})
 	if err != nil {
 		t.Fatalf("restart Claim: %v", err)
 	}
@@ -281,18 +356,18 @@ func TestClaim_RestartReusesSession(t *testing.T) {
 func TestClaim_RejectsAlreadyInProgress(t *testing.T) {
 	s := newTestStore(t)
 	story := createStory(t, s, "S")
-	if _, _, err := s.Claim(context.Background(), story.ID); err != nil {
+	if _, _, err := s.Claim(context.Background(), story.ID, StartOptions{}); err != nil {
 		t.Fatalf("first Claim: %v", err)
 	}
 
-	if _, _, err := s.Claim(context.Background(), story.ID); !errors.Is(err, ErrInvalidWork) {
+	if _, _, err := s.Claim(context.Background(), story.ID, StartOptions{}); !errors.Is(err, ErrInvalidWork) {
 		t.Errorf("err = %v, want ErrInvalidWork", err)
 	}
 }
 
 func TestClaim_NotFound(t *testing.T) {
 	s := newTestStore(t)
-	if _, _, err := s.Claim(context.Background(), "missing"); !errors.Is(err, ErrWorkNotFound) {
+	if _, _, err := s.Claim(context.Background(), "missing", StartOptions{}); !errors.Is(err, ErrWorkNotFound) {
 		t.Errorf("err = %v, want ErrWorkNotFound", err)
 	}
 }
@@ -617,6 +692,171 @@ func TestReopen_NotFound(t *testing.T) {
 	}
 }
 
+// --- Cancel ---
+
+func TestCancel_CascadesToUnfinishedChildren(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	story := createStory(t, s, "S")
+	startWork(t, s, story.ID)
+	open := createTask(t, s, story.ID, "open task")
+	running := createTask(t, s, story.ID, "running task")
+	startWork(t, s, running.ID)
+	done := createTask(t, s, story.ID, "done task")
+	startWork(t, s, done.ID)
+	if _, err := s.StepDone(ctx, done.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled, err := s.Cancel(ctx, story.ID, "out of scope")
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if len(cancelled) != 3 || cancelled[0].ID != story.ID {
+		t.Fatalf("cancelled = %d items (first %s), want story + 2 unfinished tasks", len(cancelled), cancelled[0].ID)
+	}
+
+	if got := getWork(t, s, story.ID); got.Status != StatusCancelled || got.CancelReason != "out of scope" {
+		t.Errorf("story = %s %q, want cancelled with reason", got.Status, got.CancelReason)
+	}
+	for _, id := range []string{open.ID, running.ID} {
+		got := getWork(t, s, id)
+		if got.Status != StatusCancelled || got.CancelReason != "parent cancelled: out of scope" {
+			t.Errorf("task %s = %s %q, want cancelled with parent reason", id, got.Status, got.CancelReason)
+		}
+	}
+	if got := getWork(t, s, done.ID); got.Status != StatusClosed {
+		t.Errorf("done task status = %s, want closed preserved", got.Status)
+	}
+
+	if _, err := s.Create(ctx, Work{Type: WorkTypeTask, ParentID: story.ID, AgentRoleID: testRoleID, Title: "late"}); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("expected ErrInvalidWork creating a child of a cancelled story, got %v", err)
+	}
+}
+
+func TestCancel_FromClosed(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+	startWork(t, s, story.ID)
+	if _, err := s.StepDone(context.Background(), story.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Cancel(context.Background(), story.ID, "reverted"); err != nil {
+		t.Fatalf("closed → cancelled: %v", err)
+	}
+	if got := getWork(t, s, story.ID); got.Status != StatusCancelled {
+		t.Errorf("status = %s, want cancelled", got.Status)
+	}
+}
+
+func TestCancel_Rejects(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	story := createStory(t, s, "S")
+
+	if _, err := s.Cancel(ctx, story.ID, ""); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("empty reason: expected ErrInvalidWork, got %v", err)
+	}
+	if _, err := s.Cancel(ctx, "nonexistent", "x"); err != ErrWorkNotFound {
+		t.Errorf("expected ErrWorkNotFound, got %v", err)
+	}
+
+	if _, err := s.Cancel(ctx, story.ID, "x"); err != nil {
+		t.Fatal(err)
+	}
+	// Cancelled is terminal.
+	if _, err := s.Cancel(ctx, story.ID, "again"); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("cancel twice: expected ErrInvalidWork, got %v", err)
+	}
+	if err := s.Reopen(ctx, story.ID); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("reopen cancelled: expected ErrInvalidWork, got %v", err)
+	}
+	if _, _, err := s.Claim(ctx, story.ID, StartOptions{}); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("claim cancelled: expected ErrInvalidWork, got %v", err)
+	}
+}
+
+// --- Effort ---
+
+func TestLogTime(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	story := createStory(t, s, "S")
+
+	if _, err := s.LogTime(ctx, story.ID, 20); err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.LogTime(ctx, story.ID, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.TimeSpentMinutes != 35 {
+		t.Errorf("TimeSpentMinutes = %d, want 35", w.TimeSpentMinutes)
+	}
+	if got := getWork(t, s, story.ID); got.TimeSpentMinutes != 35 {
+		t.Errorf("persisted TimeSpentMinutes = %d, want 35", got.TimeSpentMinutes)
+	}
+
+	if _, err := s.LogTime(ctx, story.ID, 0); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("zero minutes: expected ErrInvalidWork, got %v", err)
+	}
+	if _, err := s.LogTime(ctx, "nonexistent", 5); err != ErrWorkNotFound {
+		t.Errorf("expected ErrWorkNotFound, got %v", err)
+	}
+}
+
+func TestAddModifiedFiles(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	story := createStory(t, s, "S")
+	before := getWork(t, s, story.ID)
+
+	var events []ChangeEvent
+	s.AddOnChangeListener(listenerFunc(func(e ChangeEvent) { events = append(events, e) }))
+
+	if err := s.AddModifiedFiles(ctx, story.ID, []string{"b.go", "a.go"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddModifiedFiles(ctx, story.ID, []string{"a.go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := getWork(t, s, story.ID)
+	if want := []string{"a.go", "b.go"}; !slices.Equal(got.ModifiedFiles, want) {
+		t.Errorf("ModifiedFiles = %v, want %v", got.ModifiedFiles, want)
+	}
+	if !got.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Error("attribution should not bump UpdatedAt")
+	}
+	if len(events) != 1 || events[0].PrevStatus != StatusOpen {
+		t.Errorf("expected one update event with PrevStatus open, got %+v", events)
+	}
+
+	if err := s.AddModifiedFiles(ctx, "nonexistent", []string{"a.go"}); err != ErrWorkNotFound {
+		t.Errorf("expected ErrWorkNotFound, got %v", err)
+	}
+}
+
+func TestUpdate_EstimateMinutes(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	story := createStory(t, s, "S")
+
+	estimate := 90
+	if err := s.Update(ctx, story.ID, UpdateFields{EstimateMinutes: &estimate}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getWork(t, s, story.ID); got.EstimateMinutes != 90 {
+		t.Errorf("EstimateMinutes = %d, want 90", got.EstimateMinutes)
+	}
+
+	negative := -1
+	if err := s.Update(ctx, story.ID, UpdateFields{EstimateMinutes: &negative}); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("negative estimate: expected ErrInvalidWork, got %v", err)
+	}
+}
+
 // --- needs_input transitions ---
 
 func TestTransition_InProgressToNeedsInput(t *testing.T) {
@@ -944,7 +1184,7 @@ func TestListener_Events(t *testing.T) {
 	}
 }
 
-func TestListener_ChildCloseDoesNotFireParentEvent(t *testing.T) {
+func TestListener_ChildCloseUpdatesParentProgressOnly(t *testing.T) {
 	s := newTestStore(t)
 	story := createStory(t, s, "S")
 	task := createTask(t, s, story.ID, "T")
@@ -957,17 +1197,25 @@ func TestListener_ChildCloseDoesNotFireParentEvent(t *testing.T) {
 		events = append(events, e)
 	}))
 
-	// Task done → auto-close; parent stays done (awaiting review via AutoResumer)
+	// Task done → auto-close; parent stays done (awaiting review via
+	// AutoResumer), only its progress changes.
 	doneWork(t, s, task.ID)
 
-	if len(events) != 1 {
-		t.Fatalf("expected 1 event (task closed only), got %d", len(events))
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events (task closed, parent progress), got %d", len(events))
 	}
 
 	taskEvent := findEvent(events, task.ID)
 	if taskEvent == nil || taskEvent.Work.Status != StatusClosed {
 		t.Error("expected task event with status=closed")
 	}
+	storyEvent := findEvent(events, story.ID)
+	if storyEvent == nil || storyEvent.PrevStatus != storyEvent.Work.Status {
+		t.Fatalf("expected a parent event without a status change, got %+v", storyEvent)
+	}
+	if p := storyEvent.Work.Progress; p == nil || *p != (Progress{Closed: 1, Total: 1, Percent: 100}) {
+		t.Errorf("parent progress = %+v, want 1/1 100%%", p)
+	}
 }
 
 // --- Concurrent operations ---
@@ -1190,7 +1438,7 @@ func TestConcurrent_ClaimSameWork(t *testing.T) {
 	results := make(chan outcome, n)
 	for i := 0; i < n; i++ {
 		go func() {
-			w, _, err := s.Claim(context.Background(), story.ID)
+			w, _, err := s.Claim(context.Background(), story.ID, StartOptions{})
 			results <- outcome{w: w, err: err}
 		}()
 	}
@@ -1654,3 +1902,144 @@ func TestStepDone_FiresUpdateEvent(t *testing.T) {
 		t.Errorf("event Work.CurrentStep = %d, want 1", events[0].Work.CurrentStep)
 	}
 }
+
+// --- Plan approval gate ---
+
+func claimPlan(t *testing.T, s *FileStore, id string) Work {
+	t.Helper()
+	w, _, err := s.Claim(context.Background(), id, StartOptions{Mode: session.ModePlan})
+	if err != nil {
+		t.Fatalf("Claim plan %s: %v", id, err)
+	}
+	return w
+}
+
+func TestClaim_PlanModeStartsDraft(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+
+	w := claimPlan(t, s, story.ID)
+	if w.Plan == nil || w.Plan.Status != PlanDrafting {
+		t.Fatalf("plan = %+v, want drafting", w.Plan)
+	}
+	if !w.InPlanPhase() {
+		t.Error("InPlanPhase() = false, want true")
+	}
+}
+
+func TestSubmitPlan(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+	claimPlan(t, s, story.ID)
+
+	w, err := s.SubmitPlan(context.Background(), story.ID, "1. do it")
+	if err != nil {
+		t.Fatalf("SubmitPlan: %v", err)
+	}
+	if w.Status != StatusNeedsInput {
+		t.Errorf("status = %q, want needs_input", w.Status)
+	}
+	if w.Plan.Status != PlanSubmitted || w.Plan.Body != "1. do it" || w.Plan.SubmittedAt.IsZero() {
+		t.Errorf("plan = %+v, want submitted with body", w.Plan)
+	}
+}
+
+func TestSubmitPlan_NotInPlanMode(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+	if _, _, err := s.Claim(context.Background(), story.ID, StartOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.SubmitPlan(context.Background(), story.ID, "plan"); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("err = %v, want ErrInvalidWork", err)
+	}
+}
+
+func TestSubmitPlan_EmptyBody(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+	claimPlan(t, s, story.ID)
+
+	if _, err := s.SubmitPlan(context.Background(), story.ID, ""); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("err = %v, want ErrInvalidWork", err)
+	}
+}
+
+func TestResolvePlan_ApproveAndReject(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+	claimPlan(t, s, story.ID)
+	s.SubmitPlan(context.Background(), story.ID, "v1")
+
+	w, err := s.ResolvePlan(context.Background(), story.ID, false, "more tests")
+	if err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+	if w.Plan.Status != PlanRejected || w.Plan.Feedback != "more tests" {
+		t.Errorf("plan = %+v, want rejected with feedback", w.Plan)
+	}
+
+	// A rejected plan cannot be resolved again until resubmitted.
+	if _, err := s.ResolvePlan(context.Background(), story.ID, true, ""); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("resolve rejected plan: err = %v, want ErrInvalidWork", err)
+	}
+
+	if _, _, err := s.Claim(context.Background(), story.ID, StartOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SubmitPlan(context.Background(), story.ID, "v2"); err != nil {
+		t.Fatalf("resubmit: %v", err)
+	}
+	w, err = s.ResolvePlan(context.Background(), story.ID, true, "")
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if w.Plan.Status != PlanApproved || w.Plan.Body != "v2" || w.InPlanPhase() {
+		t.Errorf("plan = %+v, want approved v2", w.Plan)
+	}
+}
+
+func TestUnresolvePlan(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+	claimPlan(t, s, story.ID)
+	s.SubmitPlan(context.Background(), story.ID, "v1")
+	s.ResolvePlan(context.Background(), story.ID, true, "")
+
+	if err := s.UnresolvePlan(context.Background(), story.ID); err != nil {
+		t.Fatalf("UnresolvePlan: %v", err)
+	}
+	w := getWork(t, s, story.ID)
+	if w.Plan.Status != PlanSubmitted || !w.Plan.DecidedAt.IsZero() {
+		t.Errorf("plan = %+v, want submitted", w.Plan)
+	}
+}
+
+func TestStepDone_BlockedInPlanPhase(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+	claimPlan(t, s, story.ID)
+
+	if _, err := s.StepDone(context.Background(), story.ID, 0); !errors.Is(err, ErrInvalidWork) {
+		t.Errorf("err = %v, want ErrInvalidWork", err)
+	}
+}
+
+// An explicit act-mode restart skips the gate and drops the unapproved plan.
+func TestClaim_ActModeRestartDropsDraft(t *testing.T) {
+	s := newTestStore(t)
+	story := createStory(t, s, "S")
+	claimPlan(t, s, story.ID)
+	if err := s.MarkNeedsInput(context.Background(), story.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	w, _, err := s.Claim(context.Background(), story.ID, StartOptions{Mode: session.ModeDefault})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Plan != nil {
+		t.Errorf("plan = %+v, want nil", w.Plan)
+	}
+}
@@ -3,7 +3,10 @@ package work
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/pockode/server/session"
 )
 
 type Comment struct {
@@ -17,8 +20,31 @@ var (
 	ErrWorkNotFound    = errors.New("work not found")
 	ErrCommentNotFound = errors.New("comment not found")
 	ErrInvalidWork     = errors.New("invalid work")
+	// ErrInvalidTransition marks the ErrInvalidWork errors that refuse a
+	// change in the work's current state, as opposed to invalid input.
+	ErrInvalidTransition = errors.New("invalid transition")
+	// ErrSessionTrashed is returned when starting work whose session is in
+	// the trash: restore the session, or Reset the work for a fresh one.
+	ErrSessionTrashed = errors.New("session is in the trash")
+	// ErrBudgetExceeded is returned when starting work whose Budget is used
+	// up; raise the budget first.
+	ErrBudgetExceeded = errors.New("budget exceeded")
 )
 
+// transitionError is an ErrInvalidWork that is also an ErrInvalidTransition;
+// its message is the wrapped error's.
+type transitionError struct{ err error }
+
+func (e transitionError) Error() string   { return e.err.Error() }
+func (e transitionError) Unwrap() []error { return []error{e.err, ErrInvalidTransition} }
+
+// transitionErrorf formats an ErrInvalidWork message like
+// fmt.Errorf("%w: "+format, ErrInvalidWork, args...), marked as a transition
+// error.
+func transitionErrorf(format string, args ...any) error {
+	return transitionError{fmt.Errorf("%w: "+format, append([]any{ErrInvalidWork}, args...)...)}
+}
+
 type WorkType string
 
 const (
@@ -29,16 +55,70 @@ const (
 type WorkStatus string
 
 const (
-	StatusOpen       WorkStatus = "open"
-	StatusInProgress WorkStatus = "in_progress"
-	StatusNeedsInput WorkStatus = "needs_input" // agent waiting for user confirmation
-	StatusWaiting    WorkStatus = "waiting"     // agent waiting for child work to complete
-	StatusStopped    WorkStatus = "stopped"     // agent session ended abnormally (retry limit, interrupt, orphan)
-	StatusClosed     WorkStatus = "closed"      // fully complete
+	StatusOpen           WorkStatus = "open"
+	StatusInProgress     WorkStatus = "in_progress"
+	StatusNeedsInput     WorkStatus = "needs_input"     // agent waiting for user confirmation
+	StatusWaiting        WorkStatus = "waiting"         // agent waiting for child work to complete
+	StatusStopped        WorkStatus = "stopped"         // agent session ended abnormally (retry limit, interrupt, orphan)
+	StatusNeedsAttention WorkStatus = "needs_attention" // Budget ran out; agent stopped until the user raises it and restarts
+	StatusClosed         WorkStatus = "closed"          // fully complete
+	StatusCancelled      WorkStatus = "cancelled"       // abandoned on purpose; CancelReason says why
+)
+
+// NotifyMode is a work item's notification flag. Items without one inherit
+// their parent's, so watching a story watches its tasks.
+type NotifyMode string
+
+const (
+	NotifyInherit NotifyMode = ""
+	NotifyWatch   NotifyMode = "watch" // alert even when only watched items alert
+	NotifyMute    NotifyMode = "mute"  // never alert
+)
+
+// ClosePolicy decides what a work item's final step_done does. Items without
+// one inherit their parent's, so a story's policy covers its tasks.
+type ClosePolicy string
+
+const (
+	// ClosePolicyInherit falls back to the parent's policy, and at the top
+	// to the review gate when a review role is set, auto-close otherwise.
+	ClosePolicyInherit ClosePolicy = ""
+	// ClosePolicyAuto closes on the final step_done, skipping the review gate.
+	ClosePolicyAuto ClosePolicy = "auto_close"
+	// ClosePolicyExplicit leaves the item open until a person approves the
+	// close (FileStore.ApproveClose).
+	ClosePolicyExplicit ClosePolicy = "require_explicit_close"
+	// ClosePolicyReviewGate requires a story's review before it closes (see
+	// FileStore.SetReviewRole), and refuses the close when no review role
+	// is set. Tasks close on step_done and are reviewed with their story.
+	ClosePolicyReviewGate ClosePolicy = "review_gate"
+)
+
+// PlanStatus tracks a work item through the plan approval gate.
+type PlanStatus string
+
+const (
+	PlanDrafting  PlanStatus = "drafting"  // started in plan mode, no plan submitted yet
+	PlanSubmitted PlanStatus = "submitted" // awaiting user approval
+	PlanApproved  PlanStatus = "approved"  // session switched to act mode
+	PlanRejected  PlanStatus = "rejected"  // user asked for changes; the agent revises and resubmits
 )
 
+// Plan is the proposal an agent submits when its work was started in plan
+// mode. The work does not proceed to execution until the user approves it.
+type Plan struct {
+	Status      PlanStatus `json:"status"`
+	Body        string     `json:"body,omitempty"`
+	Feedback    string     `json:"feedback,omitempty"` // user's reason for the last rejection
+	SubmittedAt time.Time  `json:"submitted_at,omitzero"`
+	DecidedAt   time.Time  `json:"decided_at,omitzero"`
+}
+
 type Work struct {
-	ID          string     `json:"id"`
+	ID string `json:"id"`
+	// ShortID is the human-friendly "<PREFIX>-<n>" alias assigned at
+	// creation, sequential per project. Accepted wherever ID is.
+	ShortID     string     `json:"short_id,omitempty"`
 	Type        WorkType   `json:"type"`
 	ParentID    string     `json:"parent_id,omitempty"`
 	AgentRoleID string     `json:"agent_role_id,omitempty"`
@@ -46,9 +126,88 @@ type Work struct {
 	Body        string     `json:"body,omitempty"`
 	Status      WorkStatus `json:"status"`
 	SessionID   string     `json:"session_id,omitempty"`
-	CurrentStep int        `json:"current_step,omitempty"` // 0-indexed; used only when agent role has Steps
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	// SessionAgentRoleID is the role the current session was started under
+	// when a start overrode AgentRoleID; empty means AgentRoleID.
+	SessionAgentRoleID string `json:"session_agent_role_id,omitempty"`
+	CurrentStep        int    `json:"current_step,omitempty"` // 0-indexed; used only when agent role has Steps
+	// Plan is set while the work goes through the plan approval gate. The
+	// store replaces the pointer on every change (never mutates through it)
+	// so snapshots stay independent.
+	Plan *Plan `json:"plan,omitempty"`
+	// CancelReason records why the work was cancelled (set with StatusCancelled).
+	CancelReason string `json:"cancel_reason,omitempty"`
+	// EstimateMinutes is the planned effort; TimeSpentMinutes accumulates the
+	// effort agents log via LogTime. See BuildEffortReport for the rollup.
+	EstimateMinutes  int `json:"estimate_minutes,omitempty"`
+	TimeSpentMinutes int `json:"time_spent_minutes,omitempty"`
+	// Budget caps what the work's agent sessions may use; nil is unlimited.
+	// SessionsUsed and TokensUsed count what they used so far, and
+	// AttentionReason says which limit moved it to needs_attention. See
+	// BudgetGuard.
+	Budget          *Budget `json:"budget,omitempty"`
+	SessionsUsed    int     `json:"sessions_used,omitempty"`
+	TokensUsed      int64   `json:"tokens_used,omitempty"`
+	AttentionReason string  `json:"attention_reason,omitempty"`
+	// DueAt is when the work should be finished; zero means no due date.
+	// DueReminder sends work.due_soon ahead of it.
+	DueAt time.Time `json:"due_at,omitzero"`
+	// Notify is the item's watch or mute flag (see NotifyFilter); empty
+	// inherits the parent's.
+	Notify NotifyMode `json:"notify,omitempty"`
+	// Review marks the task Operations.RequestReview creates for a story's
+	// reviewer role; the story closes only once its latest review closed
+	// after all its other children (see FileStore.SetReviewRole).
+	Review bool `json:"review,omitempty"`
+	// ClosePolicy is the item's close policy; empty inherits the parent's.
+	ClosePolicy ClosePolicy `json:"close_policy,omitempty"`
+	// ModifiedFiles lists the worktree-relative paths changed while the
+	// work's sessions were running, sorted and capped like
+	// session.SessionMeta.ModifiedFiles.
+	ModifiedFiles []string `json:"modified_files,omitempty"`
+	// References are the items this one mentions as #<id> in its body or
+	// comments; ReferencedBy are the items mentioning it. Both are sorted
+	// IDs maintained by the store on save.
+	References   []string `json:"references,omitempty"`
+	ReferencedBy []string `json:"referenced_by,omitempty"`
+	// Progress counts the direct children closed so far; nil without
+	// children. Derived by the store on every change and never persisted.
+	Progress  *Progress `json:"progress,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// StartedAt is when the work first went in_progress (kept across
+	// restarts, cleared when a fresh start is rolled back); ClosedAt is when
+	// it last closed (cleared on reopen). Both feed work.stats cycle times.
+	StartedAt time.Time `json:"started_at,omitzero"`
+	ClosedAt  time.Time `json:"closed_at,omitzero"`
+}
+
+// InPlanPhase reports whether the work is gated on an unapproved plan.
+func (w Work) InPlanPhase() bool {
+	return w.Plan != nil && w.Plan.Status != PlanApproved
+}
+
+// EffectiveAgentRoleID returns the role driving the current session: the
+// start-time override if any, else the assigned role. Use it for anything
+// the running agent sees (prompts, steps).
+func (w Work) EffectiveAgentRoleID() string {
+	if w.SessionAgentRoleID != "" {
+		return w.SessionAgentRoleID
+	}
+	return w.AgentRoleID
+}
+
+// StartOptions overrides how StartWork launches a session. Zero values keep
+// the work's assigned role and the settings default mode.
+type StartOptions struct {
+	AgentRoleID string
+	// Mode sets the session mode. ModePlan starts a fresh plan (see Plan).
+	Mode session.Mode
+	// Message replaces the kickoff/restart message sent to the session. Used
+	// by the plan gate to deliver the approval or revision request.
+	Message string
+	// SessionID is used when a fresh session is created; empty generates
+	// one. Operations fills it from a SessionIDSource.
+	SessionID string
 }
 
 type Operation string
@@ -62,6 +221,9 @@ const (
 type ChangeEvent struct {
 	Op   Operation
 	Work Work
+	// PrevStatus is the status before an update, so listeners can react to
+	// transitions only; empty when unknown.
+	PrevStatus WorkStatus
 }
 
 // OnChangeListener receives notifications when Work items change.
@@ -90,5 +252,41 @@ type OnCommentChangeListener interface {
 // existing session and send a restart message instead.
 // Satisfied by worktree integration code in the main server.
 type WorkStartHandler interface {
-	HandleWorkStart(ctx context.Context, w Work) error
+	HandleWorkStart(ctx context.Context, w Work, opts StartOptions) error
+}
+
+// SessionCloser terminates the agent process of a session. Used to stop the
+// sessions of cancelled work.
+type SessionCloser interface {
+	CloseSession(sessionID string)
+}
+
+// ProcessChecker reports whether a session has a live agent process.
+type ProcessChecker interface {
+	HasProcess(sessionID string) bool
+}
+
+// WorkRestarter restarts stopped work. Satisfied by *Operations.
+type WorkRestarter interface {
+	StartWork(ctx context.Context, id string, opts StartOptions) (Work, error)
+}
+
+// OrphanPolicy decides what startup reconciliation does with work that a
+// previous server run left in_progress.
+type OrphanPolicy string
+
+const (
+	OrphanPolicyStop   OrphanPolicy = "stop"   // Mark stopped; the user restarts it (default)
+	OrphanPolicyResume OrphanPolicy = "resume" // Restart the session, resuming its history
+	OrphanPolicyReset  OrphanPolicy = "reset"  // Roll back to open, discarding the session
+)
+
+// IsValid returns true if the policy is a known policy.
+func (p OrphanPolicy) IsValid() bool {
+	switch p {
+	case OrphanPolicyStop, OrphanPolicyResume, OrphanPolicyReset:
+		return true
+	default:
+		return false
+	}
 }
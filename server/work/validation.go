@@ -10,14 +10,17 @@ var validParents = map[WorkType][]WorkType{
 }
 
 // validTransitions defines the allowed status transitions.
-// closed → in_progress is handled exclusively by Reopen.
+// closed → in_progress is handled exclusively by Reopen, and stopped → open
+// by Reset.
 var validTransitions = map[WorkStatus][]WorkStatus{
-	StatusOpen:       {StatusInProgress},
-	StatusInProgress: {StatusOpen, StatusNeedsInput, StatusWaiting, StatusStopped, StatusClosed}, // open: rollback on failed start
-	StatusNeedsInput: {StatusInProgress, StatusStopped},                                          // user confirms → resume; stop button
-	StatusWaiting:    {StatusInProgress, StatusStopped},                                          // child completes or user input → resume; stop button
-	StatusStopped:    {StatusInProgress},                                                         // restart from stopped
-	StatusClosed:     {},                                                                         // terminal (re-activation via Reopen)
+	StatusOpen:           {StatusInProgress, StatusCancelled},
+	StatusInProgress:     {StatusOpen, StatusNeedsInput, StatusWaiting, StatusStopped, StatusNeedsAttention, StatusClosed, StatusCancelled}, // open: rollback on failed start
+	StatusNeedsInput:     {StatusInProgress, StatusStopped, StatusNeedsAttention, StatusCancelled},                                          // user confirms → resume; stop button
+	StatusWaiting:        {StatusInProgress, StatusStopped, StatusNeedsAttention, StatusCancelled},                                          // child completes or user input → resume; stop button
+	StatusStopped:        {StatusInProgress, StatusCancelled},                                                                               // restart from stopped
+	StatusNeedsAttention: {StatusInProgress, StatusCancelled},                                                                               // restart once the budget is raised
+	StatusClosed:         {StatusCancelled},                                                                                                 // terminal (re-activation via Reopen); cancel retracts a done item
+	StatusCancelled:      {},                                                                                                                // terminal
 }
 
 func ValidateType(t WorkType) bool {
@@ -25,6 +28,11 @@ func ValidateType(t WorkType) bool {
 	return ok
 }
 
+func ValidateStatus(s WorkStatus) bool {
+	_, ok := validTransitions[s]
+	return ok
+}
+
 func ValidateTransition(from, to WorkStatus) bool {
 	for _, allowed := range validTransitions[from] {
 		if allowed == to {
@@ -9,12 +9,14 @@ func Test_validNextStatuses(t *testing.T) {
 		from     WorkStatus
 		expected []WorkStatus
 	}{
-		{StatusOpen, []WorkStatus{StatusInProgress}},
-		{StatusInProgress, []WorkStatus{StatusOpen, StatusNeedsInput, StatusWaiting, StatusStopped, StatusClosed}},
-		{StatusNeedsInput, []WorkStatus{StatusInProgress, StatusStopped}},
-		{StatusWaiting, []WorkStatus{StatusInProgress, StatusStopped}},
-		{StatusStopped, []WorkStatus{StatusInProgress}},
-		{StatusClosed, []WorkStatus{}},
+		{StatusOpen, []WorkStatus{StatusInProgress, StatusCancelled}},
+		{StatusInProgress, []WorkStatus{StatusOpen, StatusNeedsInput, StatusWaiting, StatusStopped, StatusNeedsAttention, StatusClosed, StatusCancelled}},
+		{StatusNeedsInput, []WorkStatus{StatusInProgress, StatusStopped, StatusNeedsAttention, StatusCancelled}},
+		{StatusWaiting, []WorkStatus{StatusInProgress, StatusStopped, StatusNeedsAttention, StatusCancelled}},
+		{StatusStopped, []WorkStatus{StatusInProgress, StatusCancelled}},
+		{StatusNeedsAttention, []WorkStatus{StatusInProgress, StatusCancelled}},
+		{StatusClosed, []WorkStatus{StatusCancelled}},
+		{StatusCancelled, []WorkStatus{}},
 	}
 
 	for _, tt := range tests {
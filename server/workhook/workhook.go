@@ -0,0 +1,207 @@
+// Package workhook runs user-defined shell commands on work lifecycle
+// transitions (see work.LifecycleHooks), so external trackers can follow
+// work without a Go integration.
+package workhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/internal/cmdoutput"
+	"github.com/pockode/server/internal/procgroup"
+	"github.com/pockode/server/work"
+)
+
+const (
+	// DefaultTimeout bounds a hook without its own timeout. Hooks usually
+	// call a tracker API; a blocking one holds up the transition.
+	DefaultTimeout = 30 * time.Second
+	// MaxTimeoutSeconds caps a configured timeout.
+	MaxTimeoutSeconds = 600
+
+	auditAction = "work.hook"
+)
+
+// FailurePolicy decides what a failed hook does to its transition.
+type FailurePolicy string
+
+const (
+	// FailureIgnore runs the hook after the transition, in the background;
+	// a failure is only recorded.
+	FailureIgnore FailurePolicy = "ignore"
+	// FailureBlock runs the hook before the transition and refuses the
+	// transition when the hook fails or times out.
+	FailureBlock FailurePolicy = "block"
+)
+
+// Hook is one configured command.
+type Hook struct {
+	Event   work.LifecycleEvent `json:"event"`
+	Command string              `json:"command"` // run with bash -c
+	// 0 = DefaultTimeout; at most MaxTimeoutSeconds.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// Empty = FailureIgnore.
+	OnFailure FailurePolicy `json:"on_failure,omitempty"`
+}
+
+func (h Hook) Validate() error {
+	if !h.Event.IsValid() {
+		return errors.New("work hook event must be created, started, done, or closed")
+	}
+	if strings.TrimSpace(h.Command) == "" {
+		return errors.New("work hook command is required")
+	}
+	if h.TimeoutSeconds < 0 || h.TimeoutSeconds > MaxTimeoutSeconds {
+		return fmt.Errorf("work hook timeout must be between 0 and %d seconds", MaxTimeoutSeconds)
+	}
+	if h.OnFailure != "" && h.OnFailure != FailureIgnore && h.OnFailure != FailureBlock {
+		return errors.New("work hook on_failure must be ignore or block")
+	}
+	return nil
+}
+
+func (h Hook) timeout() time.Duration {
+	if h.TimeoutSeconds == 0 {
+		return DefaultTimeout
+	}
+	return time.Duration(h.TimeoutSeconds) * time.Second
+}
+
+func (h Hook) blocks() bool {
+	return h.OnFailure == FailureBlock
+}
+
+// Runner implements work.LifecycleHooks. Blocking hooks run in Before, in
+// order, and the first failure refuses the transition; the others run in
+// After, in order, on a background goroutine per transition. Every run is
+// recorded in the audit log with the tail of its output.
+type Runner struct {
+	dir      string
+	auditLog *audit.Log
+	hooks    func() []Hook
+
+	// running tracks background runs so Wait can let them finish on shutdown.
+	running sync.WaitGroup
+}
+
+var _ work.LifecycleHooks = (*Runner)(nil)
+
+// NewRunner runs hooks in dir (the main worktree).
+func NewRunner(dir string, auditLog *audit.Log) *Runner {
+	return &Runner{
+		dir:      dir,
+		auditLog: auditLog,
+		hooks:    func() []Hook { return nil },
+	}
+}
+
+// SetHooks sets where the hooks are read from. It is read on every
+// transition, so settings changes apply at once.
+func (r *Runner) SetHooks(fn func() []Hook) {
+	r.hooks = fn
+}
+
+func (r *Runner) Before(ctx context.Context, event work.LifecycleEvent, w work.Work) error {
+	for _, h := range r.matching(event, true) {
+		if err := r.run(ctx, h, w); err != nil {
+			return fmt.Errorf("%q: %w", h.Command, err)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) After(event work.LifecycleEvent, w work.Work) {
+	hooks := r.matching(event, false)
+	if len(hooks) == 0 {
+		return
+	}
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		for _, h := range hooks {
+			if err := r.run(context.Background(), h, w); err != nil {
+				slog.Warn("work hook failed", "event", event, "workId", w.ID, "command", h.Command, "error", err)
+			}
+		}
+	}()
+}
+
+// Wait blocks until hooks started by After have finished.
+func (r *Runner) Wait() {
+	r.running.Wait()
+}
+
+func (r *Runner) matching(event work.LifecycleEvent, blocking bool) []Hook {
+	var out []Hook
+	for _, h := range r.hooks() {
+		if h.Event == event && h.blocks() == blocking {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// run executes one hook with the work's fields in its environment and the
+// work as JSON on stdin, and records the outcome.
+func (r *Runner) run(ctx context.Context, h Hook, w work.Work) error {
+	input, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("marshal work: %w", err)
+	}
+
+	timeout := h.timeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", h.Command)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), env(h.Event, w)...)
+	cmd.Stdin = bytes.NewReader(input)
+	procgroup.Set(cmd)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	detail := fmt.Sprintf("%s hook %q succeeded", h.Event, h.Command)
+	if err != nil {
+		detail = fmt.Sprintf("%s hook %q failed: %v", h.Event, h.Command, err)
+	}
+	r.auditLog.Record(audit.Event{
+		Action:    auditAction,
+		SessionID: w.SessionID,
+		WorkID:    w.ID,
+		Detail:    detail,
+		Output:    cmdoutput.Tail(string(output)),
+	})
+	return err
+}
+
+// env lists the POCKODE_* variables describing the transition. Body and
+// the rest of the work are on stdin, since they can exceed what an
+// environment variable may hold.
+func env(event work.LifecycleEvent, w work.Work) []string {
+	return []string{
+		"POCKODE_HOOK_EVENT=" + string(event),
+		"POCKODE_WORK_ID=" + w.ID,
+		"POCKODE_WORK_SHORT_ID=" + w.ShortID,
+		"POCKODE_WORK_TYPE=" + string(w.Type),
+		"POCKODE_WORK_TITLE=" + w.Title,
+		"POCKODE_WORK_STATUS=" + string(w.Status),
+		"POCKODE_WORK_PARENT_ID=" + w.ParentID,
+		"POCKODE_WORK_AGENT_ROLE_ID=" + w.EffectiveAgentRoleID(),
+		"POCKODE_WORK_SESSION_ID=" + w.SessionID,
+		"POCKODE_WORK_CURRENT_STEP=" + strconv.Itoa(w.CurrentStep),
+	}
+}
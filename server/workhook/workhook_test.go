@@ -0,0 +1,152 @@
+package workhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/work"
+)
+
+func skipOnWindows(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell commands not supported on Windows")
+	}
+}
+
+func newTestRunner(t *testing.T, hooks ...Hook) (*Runner, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	dataDir := t.TempDir()
+	r := NewRunner(dir, audit.NewLog(dataDir))
+	r.SetHooks(func() []Hook { return hooks })
+	return r, dir, dataDir
+}
+
+func readAudit(t *testing.T, dataDir string) []audit.Event {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dataDir, audit.Filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []audit.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e audit.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("bad line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+var testWork = work.Work{ID: "w1", ShortID: "PK-1", Type: work.WorkTypeStory, Title: "Login", Body: "Add login", Status: work.StatusInProgress, AgentRoleID: "role", SessionID: "s1"}
+
+func TestRunner_AfterEnvAndStdin(t *testing.T) {
+	skipOnWindows(t)
+	r, dir, dataDir := newTestRunner(t, Hook{
+		Event:   work.LifecycleStarted,
+		Command: `echo "$POCKODE_HOOK_EVENT $POCKODE_WORK_SHORT_ID $POCKODE_WORK_TITLE $(pwd)" > out.txt; cat > in.json; echo hooked`,
+	})
+
+	r.After(work.LifecycleStarted, testWork)
+	r.After(work.LifecycleClosed, testWork) // no hook
+	r.Wait()
+
+	out, err := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	realDir, _ := filepath.EvalSymlinks(dir)
+	if got, want := strings.TrimSpace(string(out)), "started PK-1 Login "+realDir; got != want {
+		t.Errorf("env = %q, want %q", got, want)
+	}
+	in, err := os.ReadFile(filepath.Join(dir, "in.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w work.Work
+	if err := json.Unmarshal(in, &w); err != nil || w.Body != "Add login" {
+		t.Errorf("stdin = %s (%v), want the work as JSON", in, err)
+	}
+
+	events := readAudit(t, dataDir)
+	if len(events) != 1 {
+		t.Fatalf("audit events = %+v, want 1", events)
+	}
+	if e := events[0]; e.Action != auditAction || e.WorkID != "w1" || e.SessionID != "s1" || e.Output != "hooked" || !strings.Contains(e.Detail, "succeeded") {
+		t.Errorf("audit event = %+v", e)
+	}
+}
+
+func TestRunner_BeforeBlocks(t *testing.T) {
+	skipOnWindows(t)
+	r, _, dataDir := newTestRunner(t,
+		Hook{Event: work.LifecycleClosed, Command: "echo ok"}, // ignore: not run in Before
+		Hook{Event: work.LifecycleClosed, Command: "echo missing ticket >&2; exit 3", OnFailure: FailureBlock},
+	)
+
+	if err := r.Before(context.Background(), work.LifecycleStarted, testWork); err != nil {
+		t.Fatalf("Before(started) = %v, want nil", err)
+	}
+	err := r.Before(context.Background(), work.LifecycleClosed, testWork)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("Before(closed) = %v, want exit status 3", err)
+	}
+
+	events := readAudit(t, dataDir)
+	if len(events) != 1 || events[0].Output != "missing ticket" || !strings.Contains(events[0].Detail, "failed") {
+		t.Errorf("audit events = %+v", events)
+	}
+}
+
+func TestRunner_Timeout(t *testing.T) {
+	skipOnWindows(t)
+	// A compound command keeps bash around with sleep as its child, so
+	// killing only bash would leave Before waiting out the sleep.
+	r, _, _ := newTestRunner(t, Hook{Event: work.LifecycleCreated, Command: "sleep 5; echo x", TimeoutSeconds: 1, OnFailure: FailureBlock})
+
+	start := time.Now()
+	err := r.Before(context.Background(), work.LifecycleCreated, testWork)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Before = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Before returned after %s, want soon after the 1s timeout", elapsed)
+	}
+}
+
+func TestHook_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    Hook
+		wantErr bool
+	}{
+		{"valid", Hook{Event: work.LifecycleDone, Command: "true", TimeoutSeconds: 60, OnFailure: FailureBlock}, false},
+		{"bad event", Hook{Event: "moved", Command: "true"}, true},
+		{"no command", Hook{Event: work.LifecycleDone, Command: " "}, true},
+		{"timeout too long", Hook{Event: work.LifecycleDone, Command: "true", TimeoutSeconds: MaxTimeoutSeconds + 1}, true},
+		{"bad policy", Hook{Event: work.LifecycleDone, Command: "true", OnFailure: "retry"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hook.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
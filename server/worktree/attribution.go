@@ -0,0 +1,222 @@
+package worktree
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pockode/server/process"
+)
+
+const (
+	// attributionPollInterval is how often the working tree is re-scanned
+	// while an agent turn runs. Matches the git watcher's cadence.
+	attributionPollInterval = 3 * time.Second
+	attributionScanTimeout  = 10 * time.Second
+)
+
+// fileAttributor credits working tree changes to the sessions whose agent was
+// running when they appeared. It snapshots `git status` when the first turn
+// starts, re-scans while any turn runs and once more when a turn ends, and
+// credits every path whose status, size or mtime changed between two scans to
+// each session that ran in between. Concurrent turns in one worktree can't be
+// told apart, so they share the credit; a user's own edits during a turn are
+// credited to the agent too.
+type fileAttributor struct {
+	workDir string
+	exclude string // worktree-relative prefix to ignore (pockode's data dir), or ""
+	record  func(sessionID string, paths []string)
+
+	// State changes arrive on the process event path, which must not wait on
+	// git; they queue here for the loop goroutine.
+	queueMu sync.Mutex
+	queue   []process.StateChangeEvent
+	wake    chan struct{}
+
+	// Owned by the loop goroutine.
+	active   map[string]bool
+	credited map[string]bool      // active at any point since the last scan
+	baseline map[string]fileState // nil while no turn runs
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type fileState struct {
+	status  string
+	size    int64
+	modTime time.Time
+}
+
+func newFileAttributor(workDir, dataDir string, record func(sessionID string, paths []string)) *fileAttributor {
+	a := &fileAttributor{
+		workDir:  workDir,
+		record:   record,
+		wake:     make(chan struct{}, 1),
+		active:   make(map[string]bool),
+		credited: make(map[string]bool),
+	}
+	if rel, err := filepath.Rel(workDir, dataDir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		a.exclude = filepath.ToSlash(rel) + "/"
+	}
+	return a
+}
+
+func (a *fileAttributor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.done = make(chan struct{})
+	go a.loop(ctx)
+}
+
+// Stop ends the loop. Turns still running lose the changes since their last
+// scan, which at shutdown is at most one poll interval.
+func (a *fileAttributor) Stop() {
+	if a.cancel == nil {
+		return
+	}
+	a.cancel()
+	<-a.done
+}
+
+// HandleProcessStateChange queues a process state change without blocking.
+func (a *fileAttributor) HandleProcessStateChange(e process.StateChangeEvent) {
+	a.queueMu.Lock()
+	a.queue = append(a.queue, e)
+	a.queueMu.Unlock()
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (a *fileAttributor) loop(ctx context.Context) {
+	defer close(a.done)
+	ticker := time.NewTicker(attributionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.wake:
+			a.queueMu.Lock()
+			events := a.queue
+			a.queue = nil
+			a.queueMu.Unlock()
+			for _, e := range events {
+				a.handle(ctx, e)
+			}
+		case <-ticker.C:
+			if len(a.active) > 0 {
+				a.scan(ctx)
+			}
+		}
+	}
+}
+
+func (a *fileAttributor) handle(ctx context.Context, e process.StateChangeEvent) {
+	switch e.State {
+	case process.ProcessStateRunning:
+		if a.active[e.SessionID] {
+			return
+		}
+		if len(a.active) == 0 {
+			a.baseline = a.snapshot(ctx)
+		}
+		a.active[e.SessionID] = true
+		a.credited[e.SessionID] = true
+	case process.ProcessStateIdle, process.ProcessStateEnded:
+		if !a.active[e.SessionID] {
+			return
+		}
+		delete(a.active, e.SessionID)
+		a.scan(ctx)
+		if len(a.active) == 0 {
+			a.baseline = nil
+			clear(a.credited)
+		}
+	}
+}
+
+// scan credits the paths changed since the previous scan and makes the
+// current state the new baseline.
+func (a *fileAttributor) scan(ctx context.Context) {
+	current := a.snapshot(ctx)
+	if current == nil {
+		return // keep the old baseline; the next scan covers the gap
+	}
+	previous := a.baseline
+	a.baseline = current
+	if previous == nil {
+		return
+	}
+
+	var changed []string
+	for path, st := range current {
+		if prev, ok := previous[path]; !ok || prev != st {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path) // reverted or committed
+		}
+	}
+	if len(changed) > 0 {
+		slices.Sort(changed)
+		for sessionID := range a.credited {
+			a.record(sessionID, changed)
+		}
+	}
+	a.credited = maps.Clone(a.active)
+}
+
+// snapshot returns the dirty paths in the working tree with their status and
+// file metadata; size and mtime catch further edits to an already-dirty file.
+// Returns nil when git fails.
+func (a *fileAttributor) snapshot(ctx context.Context) map[string]fileState {
+	ctx, cancel := context.WithTimeout(ctx, attributionScanTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "--no-optional-locks", "status", "--porcelain=v1", "-z", "-uall")
+	cmd.Dir = a.workDir
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Debug("file attribution scan failed", "workDir", a.workDir, "error", err)
+		}
+		return nil
+	}
+
+	states := make(map[string]fileState)
+	entries := bytes.Split(out, []byte{0})
+	for i := 0; i < len(entries); i++ {
+		entry := string(entries[i])
+		if len(entry) < 4 {
+			continue
+		}
+		status, path := entry[:2], entry[3:]
+		if status[0] == 'R' || status[0] == 'C' {
+			i++ // the next entry is the source path; the rename shows as the new one
+		}
+		if a.exclude != "" && strings.HasPrefix(path, a.exclude) {
+			continue
+		}
+		st := fileState{status: status}
+		if info, err := os.Lstat(filepath.Join(a.workDir, path)); err == nil {
+			st.size = info.Size()
+			st.modTime = info.ModTime()
+		}
+		states[path] = st
+	}
+	return states
+}
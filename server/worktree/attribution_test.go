@@ -0,0 +1,61 @@
+package worktree
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pockode/server/process"
+)
+
+func TestFileAttributor(t *testing.T) {
+	dir := initGitRepo(t)
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorded := make(map[string][]string)
+	a := newFileAttributor(dir, filepath.Join(dir, ".pockode"), func(sessionID string, paths []string) {
+		recorded[sessionID] = append(recorded[sessionID], paths...)
+	})
+	ctx := context.Background()
+	event := func(sessionID string, state process.ProcessState) {
+		a.handle(ctx, process.StateChangeEvent{SessionID: sessionID, State: state})
+	}
+
+	// Dirty before any turn: part of the baseline, credited to nobody.
+	write("before.txt", "x")
+
+	event("s1", process.ProcessStateRunning)
+	write("a.txt", "a")
+	write("before.txt", "edited during s1")
+	write(".pockode/sessions.json", "{}")
+	a.scan(ctx)
+
+	event("s2", process.ProcessStateRunning)
+	write("dir/b.txt", "b")
+	event("s1", process.ProcessStateIdle)
+
+	write("c.txt", "c")
+	event("s2", process.ProcessStateEnded)
+
+	// No turn running: not attributed.
+	write("after.txt", "x")
+	a.scan(ctx)
+
+	if want := []string{"a.txt", "before.txt", "dir/b.txt"}; !slices.Equal(recorded["s1"], want) {
+		t.Errorf("s1 = %v, want %v", recorded["s1"], want)
+	}
+	if want := []string{"dir/b.txt", "c.txt"}; !slices.Equal(recorded["s2"], want) {
+		t.Errorf("s2 = %v, want %v", recorded["s2"], want)
+	}
+}
@@ -0,0 +1,31 @@
+package worktree
+
+import (
+	"strings"
+
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/work"
+)
+
+// BranchName resolves the branch for a new worktree under the branch
+// naming policy. An explicit branch must match the template; without one
+// the template is rendered from the worktree name and w, which may be nil
+// when the template needs no work item. A work title with nothing to
+// slugify (all kanji, say) falls back to its short ID.
+func BranchName(tmpl git.BranchTemplate, branch, name string, w *work.Work) (string, error) {
+	if branch != "" {
+		if err := tmpl.Match(branch); err != nil {
+			return "", err
+		}
+		return branch, nil
+	}
+	vars := git.BranchVars{Name: name}
+	if w != nil {
+		vars.WorkShortID = w.ShortID
+		vars.Slug = git.Slugify(w.Title)
+		if vars.Slug == "" {
+			vars.Slug = strings.ToLower(w.ShortID)
+		}
+	}
+	return tmpl.Render(vars)
+}
@@ -0,0 +1,90 @@
+package worktree
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pockode/server/ci"
+	"github.com/pockode/server/i18n"
+	"github.com/pockode/server/work"
+)
+
+// CIFailureNotifier sends CI failures on a worktree's branch to the agents of
+// the in_progress work whose sessions live in that worktree.
+type CIFailureNotifier struct {
+	manager   *Manager
+	workStore work.Store
+	enabled   func() bool
+	locale    func() i18n.Locale
+}
+
+// NewCIFailureNotifier creates a notifier; enabled is checked per failure so
+// the setting applies without a restart.
+func NewCIFailureNotifier(manager *Manager, workStore work.Store, enabled func() bool) *CIFailureNotifier {
+	return &CIFailureNotifier{manager: manager, workStore: workStore, enabled: enabled}
+}
+
+// SetLocale sets the language of the failure messages. Without it they are
+// English.
+func (n *CIFailureNotifier) SetLocale(fn func() i18n.Locale) {
+	n.locale = fn
+}
+
+// OnCIStatusChange implements ci.OnChangeListener. Only the transition into
+// failure for a commit is reported, so an agent is nudged once per failing
+// push rather than on every poll.
+func (n *CIFailureNotifier) OnCIStatusChange(prev, status ci.Status) {
+	if status.State != ci.StateFailure {
+		return
+	}
+	if prev.State == ci.StateFailure && prev.SHA == status.SHA {
+		return
+	}
+	if !n.enabled() {
+		return
+	}
+	// Sending may spawn an agent process; keep the poller loop unblocked.
+	go n.notify(status)
+}
+
+func (n *CIFailureNotifier) notify(status ci.Status) {
+	works, err := n.workStore.List()
+	if err != nil {
+		slog.Error("ci feedback: failed to list works", "error", err)
+		return
+	}
+
+	wt, err := n.manager.Get(status.Worktree)
+	if err != nil {
+		slog.Warn("ci feedback: failed to get worktree", "worktree", status.Worktree, "error", err)
+		return
+	}
+	defer n.manager.Release(wt)
+
+	var failed []string
+	for _, c := range status.Checks {
+		if c.Failed() {
+			failed = append(failed, c.Name+": "+c.URL)
+		}
+	}
+
+	loc := i18n.Default
+	if n.locale != nil {
+		loc = n.locale()
+	}
+	ctx := context.Background()
+	for _, w := range works {
+		if w.Status != work.StatusInProgress || w.SessionID == "" {
+			continue
+		}
+		if _, found, err := wt.SessionStore.Get(w.SessionID); err != nil || !found {
+			continue
+		}
+		msg := work.BuildCIFailureMessage(loc, w, status.Branch, status.SHA, failed)
+		if err := wt.ChatClient.SendSystemMessage(ctx, w.SessionID, msg); err != nil {
+			slog.Warn("ci feedback: failed to send message", "workId", w.ID, "sessionId", w.SessionID, "error", err)
+			continue
+		}
+		slog.Info("sent CI failure to work", "workId", w.ID, "branch", status.Branch, "sha", status.SHA)
+	}
+}
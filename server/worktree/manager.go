@@ -2,6 +2,7 @@ package worktree
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/pockode/server/agent"
+	"github.com/pockode/server/audit"
 	"github.com/pockode/server/chat"
 	"github.com/pockode/server/process"
 	"github.com/pockode/server/rpc"
@@ -30,9 +32,24 @@ type Manager struct {
 
 	workAutoResumer      *work.AutoResumer
 	workNeedsInputSyncer *work.NeedsInputSyncer
+	agentEnv             func() []string
+	idleTimeoutFor       func(sessionID string) time.Duration
+	toolResultLimit      func() int
+	hideThinking         func() bool
+	onUsage              func(sessionID string, tokens int64)
+	stuckAfter           func() time.Duration
+	warmPool             func() process.WarmPoolConfig
+	permissionTimeout    func() process.PermissionTimeout
+	modePolicy           func(session.Mode) process.ModePolicy
+	auditLog             *audit.Log
+	roleMCPServersFor    func(sessionID string) []agent.MCPServer
+	onFilesModified      func(sessionID string, paths []string)
+	sessionListenerFor   func(worktree string) session.OnChangeListener
+	workStores           *WorkStores
 
 	mu        sync.Mutex
 	worktrees map[string]*Worktree
+	draining  bool // set by Drain; worktrees created afterwards start draining
 }
 
 func NewManager(registry *Registry, agents *agent.Registry, dataDir string, idleTimeout time.Duration) *Manager {
@@ -50,10 +67,104 @@ func (m *Manager) Registry() *Registry {
 	return m.registry
 }
 
+// Agents returns the agent implementations processes are started with.
+func (m *Manager) Agents() *agent.Registry {
+	return m.agents
+}
+
 func (m *Manager) SetWorkAutoResumer(ar *work.AutoResumer) {
 	m.workAutoResumer = ar
 }
 
+// SetAgentEnv sets the extra-environment provider passed to every
+// worktree's process manager.
+func (m *Manager) SetAgentEnv(fn func() []string) {
+	m.agentEnv = fn
+}
+
+// SetIdleTimeoutOverride sets the per-session idle timeout provider passed
+// to every worktree's process manager.
+func (m *Manager) SetIdleTimeoutOverride(fn func(sessionID string) time.Duration) {
+	m.idleTimeoutFor = fn
+}
+
+// SetToolResultLimit sets the tool result truncation threshold provider
+// passed to every worktree's process manager.
+func (m *Manager) SetToolResultLimit(fn func() int) {
+	m.toolResultLimit = fn
+}
+
+// SetHideThinking sets the thinking suppression provider passed to every
+// worktree's process manager.
+func (m *Manager) SetHideThinking(fn func() bool) {
+	m.hideThinking = fn
+}
+
+// SetOnUsage sets the callback every worktree's process manager reports
+// per-turn token usage to.
+func (m *Manager) SetOnUsage(fn func(sessionID string, tokens int64)) {
+	m.onUsage = fn
+}
+
+// SetStuckAfter sets the stuck-process watchdog threshold provider passed
+// to every worktree's process manager.
+func (m *Manager) SetStuckAfter(fn func() time.Duration) {
+	m.stuckAfter = fn
+}
+
+// SetWarmPool sets the warm pool provider passed to every worktree's process
+// manager.
+func (m *Manager) SetWarmPool(fn func() process.WarmPoolConfig) {
+	m.warmPool = fn
+}
+
+// SetPermissionTimeout sets the permission timeout provider passed to every
+// worktree's process manager.
+func (m *Manager) SetPermissionTimeout(fn func() process.PermissionTimeout) {
+	m.permissionTimeout = fn
+}
+
+// SetModePolicy sets the custom session mode resolver passed to every
+// worktree's process manager.
+func (m *Manager) SetModePolicy(fn func(session.Mode) process.ModePolicy) {
+	m.modePolicy = fn
+}
+
+// SetAuditLog sets the audit log passed to every worktree's process manager.
+func (m *Manager) SetAuditLog(l *audit.Log) {
+	m.auditLog = l
+}
+
+// SetRoleMCPServers sets the provider of a session's agent role MCP servers,
+// merged over each worktree's own at process start.
+func (m *Manager) SetRoleMCPServers(fn func(sessionID string) []agent.MCPServer) {
+	m.roleMCPServersFor = fn
+}
+
+// SetSessionListener sets a provider of an extra session change listener
+// for each worktree's session store, given the worktree name.
+func (m *Manager) SetSessionListener(fn func(worktree string) session.OnChangeListener) {
+	m.sessionListenerFor = fn
+}
+
+// SetOnFilesModified sets the callback told which worktree paths a
+// session's agent changed, after they are recorded on the session itself.
+func (m *Manager) SetOnFilesModified(fn func(sessionID string, paths []string)) {
+	m.onFilesModified = fn
+}
+
+// SetWorkStores enables worktree-scoped work stores: the MCP server of an
+// isolated worktree's agents is pointed at its own data dir.
+func (m *Manager) SetWorkStores(s *WorkStores) {
+	m.workStores = s
+}
+
+// WorkStores returns the work store resolver, or nil when every worktree
+// shares the main store.
+func (m *Manager) WorkStores() *WorkStores {
+	return m.workStores
+}
+
 func (m *Manager) SetWorkNeedsInputSyncer(s *work.NeedsInputSyncer) {
 	m.workNeedsInputSyncer = s
 }
@@ -62,8 +173,15 @@ func (m *Manager) Start() error {
 	return m.WorktreeWatcher.Start()
 }
 
-// Get returns (or creates) the worktree for the given name and increments the reference count.
+// Get returns (or creates) the worktree for the given name and increments
+// the reference count, held by InternalHolder.
 func (m *Manager) Get(name string) (*Worktree, error) {
+	return m.GetFor(name, InternalHolder)
+}
+
+// GetFor is Get with the reference attributed to holder (a connection ID),
+// so Refs can show who keeps a worktree alive.
+func (m *Manager) GetFor(name, holder string) (*Worktree, error) {
 	workDir, err := m.registry.Resolve(name)
 	if err != nil {
 		return nil, err
@@ -71,8 +189,8 @@ func (m *Manager) Get(name string) (*Worktree, error) {
 
 	m.mu.Lock()
 	if existing, ok := m.worktrees[name]; ok {
-		existing.refCount++
-		slog.Debug("worktree ref incremented", "name", name, "refCount", existing.refCount)
+		existing.addRefLocked(holder)
+		slog.Debug("worktree ref incremented", "name", name, "holder", holder, "refCount", existing.refCount)
 		m.mu.Unlock()
 		return existing, nil
 	}
@@ -90,34 +208,62 @@ func (m *Manager) Get(name string) (*Worktree, error) {
 	// Another goroutine may have created it while we were creating
 	if existing, ok := m.worktrees[name]; ok {
 		wt.Stop()
-		existing.refCount++
-		slog.Debug("worktree ref incremented (race)", "name", name, "refCount", existing.refCount)
+		existing.addRefLocked(holder)
+		slog.Debug("worktree ref incremented (race)", "name", name, "holder", holder, "refCount", existing.refCount)
 		return existing, nil
 	}
 
 	m.worktrees[name] = wt
-	wt.refCount = 1
+	wt.addRefLocked(holder)
 	slog.Info("worktree created", "name", name, "workDir", workDir)
 
 	return wt, nil
 }
 
-// Release decrements the reference count and schedules cleanup after idleReleaseDelay.
+// WorkDirForSession returns the directory of the active worktree running
+// sessionID's agent process.
+func (m *Manager) WorkDirForSession(sessionID string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, wt := range m.worktrees {
+		if wt.ProcessManager.HasProcess(sessionID) {
+			return wt.WorkDir, true
+		}
+	}
+	return "", false
+}
+
+// Release drops a reference taken with Get and schedules cleanup after
+// idleReleaseDelay.
 func (m *Manager) Release(wt *Worktree) {
+	m.ReleaseFor(wt, InternalHolder)
+}
+
+// ReleaseFor drops one of holder's references. A holder without references
+// (already force-released) is ignored rather than driving the count negative.
+func (m *Manager) ReleaseFor(wt *Worktree, holder string) {
 	m.mu.Lock()
-	wt.refCount--
+	if !wt.dropRefLocked(holder, 1) {
+		m.mu.Unlock()
+		slog.Debug("worktree release without reference", "name", wt.Name, "holder", holder)
+		return
+	}
 	refCount := wt.refCount
-	slog.Debug("worktree ref decremented", "name", wt.Name, "refCount", refCount)
+	slog.Debug("worktree ref decremented", "name", wt.Name, "holder", holder, "refCount", refCount)
 	m.mu.Unlock()
 
 	if refCount == 0 {
-		go func() {
-			time.Sleep(idleReleaseDelay)
-			m.maybeCleanup(wt)
-		}()
+		m.scheduleCleanup(wt)
 	}
 }
 
+func (m *Manager) scheduleCleanup(wt *Worktree) {
+	go func() {
+		time.Sleep(idleReleaseDelay)
+		m.maybeCleanup(wt)
+	}()
+}
+
 // ForceShutdown immediately shuts down a worktree, notifies all subscribers,
 // and removes the worktree's data directory from .pockode.
 func (m *Manager) ForceShutdown(name string) {
@@ -138,6 +284,42 @@ func (m *Manager) ForceShutdown(name string) {
 	if err := os.RemoveAll(wtDataDir); err != nil {
 		slog.Warn("failed to remove worktree data directory", "path", wtDataDir, "error", err)
 	}
+	if m.workStores != nil {
+		m.workStores.forget(name)
+	}
+}
+
+// Drain stops every worktree's process manager from starting processes and
+// waits, up to ctx, for running agent turns to finish. See process.Manager.Drain.
+func (m *Manager) Drain(ctx context.Context) process.DrainResult {
+	m.mu.Lock()
+	m.draining = true
+	worktrees := make([]*Worktree, 0, len(m.worktrees))
+	for _, wt := range m.worktrees {
+		worktrees = append(worktrees, wt)
+	}
+	m.mu.Unlock()
+
+	var (
+		resultMu sync.Mutex
+		result   process.DrainResult
+		wg       sync.WaitGroup
+	)
+	for _, wt := range worktrees {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := wt.ProcessManager.Drain(ctx)
+			resultMu.Lock()
+			result.Idle = append(result.Idle, r.Idle...)
+			result.Running = append(result.Running, r.Running...)
+			resultMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slog.Info("worktrees drained", "idle", len(result.Idle), "running", len(result.Running))
+	return result
 }
 
 func (m *Manager) Shutdown() {
@@ -158,13 +340,35 @@ func (m *Manager) Shutdown() {
 	slog.Info("manager shutdown complete", "worktreesClosed", len(worktrees))
 }
 
-func (m *Manager) create(name, workDir string) (*Worktree, error) {
-	var wtDataDir string
+// dataDirFor returns the directory holding name's sessions and settings.
+// The main worktree keeps them at the top of the data directory.
+func (m *Manager) dataDirFor(name string) string {
 	if name == "" {
-		wtDataDir = m.dataDir
-	} else {
-		wtDataDir = filepath.Join(m.dataDir, "worktrees", name)
+		return m.dataDir
 	}
+	return filepath.Join(m.dataDir, "worktrees", name)
+}
+
+// SessionStore returns name's session store without starting the worktree:
+// the active worktree's own store, so there is a single instance per data
+// directory, or else one opened on the worktree's data directory. Meant for
+// reading sessions of worktrees nobody is using, as the digest does.
+func (m *Manager) SessionStore(name string) (session.Store, error) {
+	if _, err := m.registry.Resolve(name); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	wt, ok := m.worktrees[name]
+	m.mu.Unlock()
+	if ok {
+		return wt.SessionStore, nil
+	}
+	return session.NewFileStore(m.dataDirFor(name))
+}
+
+func (m *Manager) create(name, workDir string) (*Worktree, error) {
+	wtDataDir := m.dataDirFor(name)
 
 	sessionStore, err := session.NewFileStore(wtDataDir)
 	if err != nil {
@@ -173,19 +377,91 @@ func (m *Manager) create(name, workDir string) (*Worktree, error) {
 
 	fsWatcher := watch.NewFSWatcher(workDir)
 	gitWatcher := watch.NewGitWatcher(workDir)
+	workspaceWatcher := watch.NewWorkspaceWatcher(workDir, fsWatcher, gitWatcher)
 	gitDiffWatcher := watch.NewGitDiffWatcher(workDir)
 	sessionListWatcher := watch.NewSessionListWatcher(sessionStore)
+	if m.sessionListenerFor != nil {
+		sessionStore.AddOnChangeListener(m.sessionListenerFor(name))
+	}
 	chatMessagesWatcher := watch.NewChatMessagesWatcher(sessionStore)
 	processManager := process.NewManager(m.agents, workDir, m.dataDir, sessionStore, m.idleTimeout)
-	processManager.SetMessageListener(chatMessagesWatcher)
+	processManager.SetMessageListener(process.ChatMessageListeners{chatMessagesWatcher, sessionListWatcher})
+	if m.agentEnv != nil {
+		processManager.SetAgentEnv(m.agentEnv)
+	}
+	if m.idleTimeoutFor != nil {
+		processManager.SetIdleTimeoutOverride(m.idleTimeoutFor)
+	}
+	if m.toolResultLimit != nil {
+		processManager.SetToolResultLimit(m.toolResultLimit)
+	}
+	if m.hideThinking != nil {
+		processManager.SetHideThinking(m.hideThinking)
+	}
+	if m.onUsage != nil {
+		processManager.SetOnUsage(m.onUsage)
+	}
+	if m.permissionTimeout != nil {
+		processManager.SetPermissionTimeout(m.permissionTimeout)
+	}
+	if m.modePolicy != nil {
+		processManager.SetModePolicy(m.modePolicy)
+	}
+	if m.workStores != nil && m.workStores.IsIsolated(name) {
+		processManager.SetMCPDataDir(wtDataDir)
+	}
+	processManager.SetAuditLog(m.auditLog)
+	processManager.SetOnReapWarning(chatMessagesWatcher.NotifyReapPending)
+	if m.stuckAfter != nil {
+		processManager.SetStuckAfter(m.stuckAfter)
+	}
+	processManager.SetOnPossiblyStuck(chatMessagesWatcher.NotifyPossiblyStuck)
+	processManager.SetOnInputChange(chatMessagesWatcher.NotifyInputChange)
+	processManager.SetMCPServers(func(sessionID string) []agent.MCPServer {
+		servers, err := loadMCPServers(wtDataDir)
+		if err != nil {
+			slog.Warn("failed to load worktree MCP servers", "worktree", name, "error", err)
+		}
+		if m.roleMCPServersFor != nil {
+			servers = agent.MergeMCPServers(servers, m.roleMCPServersFor(sessionID))
+		}
+		return servers
+	})
+	m.mu.Lock()
+	if m.draining {
+		processManager.StartDrain()
+	}
+	m.mu.Unlock()
+	// Last, as it starts filling the pool with the options configured above.
+	if m.warmPool != nil {
+		processManager.SetWarmPool(m.warmPool)
+	}
 	sessionListWatcher.SetProcessStateGetter(processManager)
 	sessionListWatcher.SetViewingChecker(chatMessagesWatcher)
 	if m.workNeedsInputSyncer != nil {
 		sessionListWatcher.SetWorkNeedsInputSyncer(m.workNeedsInputSyncer)
 	}
+	if m.workStores != nil {
+		sessionListWatcher.SetWorkResolver(m.workStores)
+	}
+	attributor := newFileAttributor(workDir, m.dataDir, func(sessionID string, paths []string) {
+		if err := sessionStore.AddModifiedFiles(context.Background(), sessionID, paths); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+			slog.Warn("failed to record modified files", "sessionId", sessionID, "error", err)
+		}
+		if m.onFilesModified != nil {
+			m.onFilesModified(sessionID, paths)
+		}
+	})
 	processManager.SetOnStateChange(func(e process.StateChangeEvent) {
 		sessionListWatcher.HandleProcessStateChange(e)
+		attributor.HandleProcessStateChange(e)
+		if e.State == process.ProcessStateEnded {
+			discardEphemeral(sessionStore, e.SessionID)
+		}
 		if m.workAutoResumer != nil {
+			if e.ErrorCode != "" {
+				m.workAutoResumer.HandleAgentError(e.SessionID, e.ErrorCode)
+			}
 			m.workAutoResumer.HandleProcessStateChange(e.SessionID, string(e.State), e.NeedsInput, e.IsInitial, e.Interrupted)
 		}
 	})
@@ -197,6 +473,7 @@ func (m *Manager) create(name, workDir string) (*Worktree, error) {
 			n = exclude.(watch.Notifier)
 		}
 		chatMessagesWatcher.NotifyMessage(sessionID, event, n)
+		sessionListWatcher.OnChatMessage(process.ChatMessage{SessionID: sessionID, Event: event})
 	})
 
 	// Set sender for auto-resumer when creating the main worktree
@@ -207,15 +484,18 @@ func (m *Manager) create(name, workDir string) (*Worktree, error) {
 	wt := &Worktree{
 		Name:                name,
 		WorkDir:             workDir,
+		DataDir:             wtDataDir,
 		SessionStore:        sessionStore,
 		FSWatcher:           fsWatcher,
 		GitWatcher:          gitWatcher,
+		WorkspaceWatcher:    workspaceWatcher,
 		GitDiffWatcher:      gitDiffWatcher,
 		SessionListWatcher:  sessionListWatcher,
 		ChatMessagesWatcher: chatMessagesWatcher,
 		ProcessManager:      processManager,
 		ChatClient:          chatClient,
-		watchers:            []watch.Watcher{fsWatcher, gitWatcher, gitDiffWatcher, sessionListWatcher, chatMessagesWatcher},
+		attributor:          attributor,
+		watchers:            []watch.Watcher{fsWatcher, gitWatcher, workspaceWatcher, gitDiffWatcher, sessionListWatcher, chatMessagesWatcher},
 		subscribers:         make(map[watch.Notifier]struct{}),
 	}
 
@@ -230,6 +510,20 @@ func (m *Manager) create(name, workDir string) (*Worktree, error) {
 	return wt, nil
 }
 
+// discardEphemeral deletes a quick-chat session once its process ends, so a
+// throwaway conversation leaves nothing behind unless it was kept.
+func discardEphemeral(store session.Store, sessionID string) {
+	meta, found, err := store.Get(sessionID)
+	if err != nil || !found || !meta.Ephemeral {
+		return
+	}
+	if err := store.Delete(context.Background(), sessionID); err != nil {
+		slog.Warn("failed to discard quick chat session", "sessionId", sessionID, "error", err)
+		return
+	}
+	slog.Info("quick chat session discarded", "sessionId", sessionID)
+}
+
 // maybeCleanup cleans up the worktree if it's idle and matches the given pointer.
 func (m *Manager) maybeCleanup(target *Worktree) {
 	m.mu.Lock()
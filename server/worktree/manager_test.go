@@ -1,9 +1,14 @@
 package worktree
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/session"
 )
 
 func TestForceShutdown_RemovesDataDirectory(t *testing.T) {
@@ -39,3 +44,79 @@ func TestForceShutdown_RemovesDataDirectory(t *testing.T) {
 		t.Errorf("parent worktrees directory was unexpectedly removed")
 	}
 }
+
+func TestRefs_TracksHoldersAndForceRelease(t *testing.T) {
+	dataDir := t.TempDir()
+	m := NewManager(NewRegistry(t.TempDir(), dataDir), agent.NewRegistry(), dataDir, time.Minute)
+	t.Cleanup(m.Shutdown)
+
+	wt, err := m.GetFor("", "conn-a")
+	if err != nil {
+		t.Fatalf("GetFor: %v", err)
+	}
+	if _, err := m.GetFor("", "conn-a"); err != nil {
+		t.Fatalf("GetFor: %v", err)
+	}
+	if _, err := m.Get(""); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	refs := m.Refs()
+	if len(refs) != 1 || refs[0].RefCount != 3 || len(refs[0].Holders) != 2 {
+		t.Fatalf("refs = %+v, want 3 references from 2 holders", refs)
+	}
+	if h := refs[0].Holders[0]; h.Holder != "conn-a" || h.Count != 2 || h.AcquiredAt.IsZero() {
+		t.Errorf("first holder = %+v, want conn-a x2", h)
+	}
+
+	if n := m.ForceRelease("", "conn-a"); n != 2 {
+		t.Errorf("ForceRelease released %d, want 2", n)
+	}
+	// The crashed holder's late release must not eat the internal reference.
+	m.ReleaseFor(wt, "conn-a")
+	refs = m.Refs()
+	if refs[0].RefCount != 1 || len(refs[0].Holders) != 1 || refs[0].Holders[0].Holder != InternalHolder {
+		t.Errorf("refs after force release = %+v, want only the internal reference", refs)
+	}
+	if n := m.ForceRelease("missing", "conn-a"); n != 0 {
+		t.Errorf("ForceRelease of unloaded worktree released %d", n)
+	}
+}
+
+func TestSessionStore_DoesNotStartWorktree(t *testing.T) {
+	dataDir := t.TempDir()
+	m := NewManager(NewRegistry(t.TempDir(), dataDir), agent.NewRegistry(), dataDir, time.Minute)
+	t.Cleanup(m.Shutdown)
+
+	seeded, err := session.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if _, err := seeded.Create(context.Background(), "s1", "", ""); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	store, err := m.SessionStore("")
+	if err != nil {
+		t.Fatalf("SessionStore: %v", err)
+	}
+	if sessions, _ := store.List(); len(sessions) != 1 || sessions[0].ID != "s1" {
+		t.Errorf("sessions = %+v, want s1", sessions)
+	}
+	if refs := m.Refs(); len(refs) != 0 {
+		t.Errorf("refs = %+v, want no worktree started", refs)
+	}
+
+	// An active worktree shares its own store.
+	wt, err := m.Get("")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if store, err := m.SessionStore(""); err != nil || store != wt.SessionStore {
+		t.Errorf("SessionStore = %v, %v; want the active worktree's store", store, err)
+	}
+
+	if _, err := m.SessionStore("missing"); err == nil {
+		t.Error("SessionStore of an unknown worktree succeeded")
+	}
+}
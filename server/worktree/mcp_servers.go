@@ -0,0 +1,56 @@
+package worktree
+
+import (
+	"path/filepath"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/filestore"
+)
+
+// mcpServersFilename holds the worktree's extra MCP servers in its data dir
+// (the data dir root for the main worktree). 0600: env often holds tokens.
+const mcpServersFilename = "mcp-servers.json"
+
+type mcpServersIndex struct {
+	Servers []agent.MCPServer `json:"servers"`
+}
+
+func mcpServersFile(dataDir string) (*filestore.File, error) {
+	return filestore.New(filestore.Config{
+		Path:  filepath.Join(dataDir, mcpServersFilename),
+		Label: "mcp-servers",
+		Perm:  0600,
+	})
+}
+
+func loadMCPServers(dataDir string) ([]agent.MCPServer, error) {
+	f, err := mcpServersFile(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := filestore.Load(f, mcpServersIndex{})
+	return idx.Servers, err
+}
+
+// MCPServers returns the worktree's extra MCP servers. They are read at every
+// agent start, so a change applies to the next process.
+func (w *Worktree) MCPServers() ([]agent.MCPServer, error) {
+	return loadMCPServers(w.DataDir)
+}
+
+// SetMCPServers validates and replaces the worktree's extra MCP servers; an
+// empty list removes the file. Errors from validation wrap
+// agent.ErrInvalidMCPServer.
+func (w *Worktree) SetMCPServers(servers []agent.MCPServer) error {
+	if err := agent.ValidateMCPServers(servers); err != nil {
+		return err
+	}
+	f, err := mcpServersFile(w.DataDir)
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return f.Remove()
+	}
+	return f.Persist(mcpServersIndex{Servers: servers})
+}
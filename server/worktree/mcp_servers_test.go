@@ -0,0 +1,39 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pockode/server/agent"
+)
+
+func TestWorktree_MCPServers(t *testing.T) {
+	wt := &Worktree{DataDir: filepath.Join(t.TempDir(), "worktrees", "feature")}
+
+	servers, err := wt.MCPServers()
+	if err != nil || servers != nil {
+		t.Fatalf("MCPServers() = %v, %v; want nil before any set", servers, err)
+	}
+
+	want := []agent.MCPServer{{Name: "db", Command: "db-mcp", Args: []string{"--port", "5432"}}}
+	if err := wt.SetMCPServers(want); err != nil {
+		t.Fatalf("SetMCPServers: %v", err)
+	}
+	servers, err = wt.MCPServers()
+	if err != nil || len(servers) != 1 || !servers[0].Equal(want[0]) {
+		t.Fatalf("MCPServers() = %+v, %v; want %+v", servers, err, want)
+	}
+
+	if err := wt.SetMCPServers([]agent.MCPServer{{Name: "pockode", Command: "x"}}); !errors.Is(err, agent.ErrInvalidMCPServer) {
+		t.Errorf("reserved name: err = %v, want ErrInvalidMCPServer", err)
+	}
+
+	if err := wt.SetMCPServers(nil); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wt.DataDir, mcpServersFilename)); !os.IsNotExist(err) {
+		t.Error("clearing should remove the file")
+	}
+}
@@ -0,0 +1,74 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/pockode/server/chat"
+	"github.com/pockode/server/filestore"
+)
+
+// quickRepliesFilename holds the worktree's quick-reply templates in its
+// data dir (the data dir root for the main worktree).
+const quickRepliesFilename = "quick-replies.json"
+
+// ErrQuickReplyNotFound is returned by QuickReply for an unknown ID.
+var ErrQuickReplyNotFound = errors.New("quick reply not found")
+
+type quickRepliesIndex struct {
+	QuickReplies []chat.QuickReply `json:"quick_replies"`
+}
+
+func quickRepliesFile(dataDir string) (*filestore.File, error) {
+	return filestore.New(filestore.Config{
+		Path:  filepath.Join(dataDir, quickRepliesFilename),
+		Label: "quick-replies",
+	})
+}
+
+func loadQuickReplies(dataDir string) ([]chat.QuickReply, error) {
+	f, err := quickRepliesFile(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := filestore.Load(f, quickRepliesIndex{})
+	return idx.QuickReplies, err
+}
+
+// QuickReplies returns the worktree's quick-reply templates in their saved
+// order.
+func (w *Worktree) QuickReplies() ([]chat.QuickReply, error) {
+	return loadQuickReplies(w.DataDir)
+}
+
+// QuickReply returns the template with id, or ErrQuickReplyNotFound.
+func (w *Worktree) QuickReply(id string) (chat.QuickReply, error) {
+	replies, err := loadQuickReplies(w.DataDir)
+	if err != nil {
+		return chat.QuickReply{}, err
+	}
+	for _, q := range replies {
+		if q.ID == id {
+			return q, nil
+		}
+	}
+	return chat.QuickReply{}, fmt.Errorf("%w: %s", ErrQuickReplyNotFound, id)
+}
+
+// SetQuickReplies validates and replaces the worktree's quick-reply
+// templates; an empty list removes the file. Errors from validation wrap
+// chat.ErrInvalidQuickReply.
+func (w *Worktree) SetQuickReplies(replies []chat.QuickReply) error {
+	if err := chat.ValidateQuickReplies(replies); err != nil {
+		return err
+	}
+	f, err := quickRepliesFile(w.DataDir)
+	if err != nil {
+		return err
+	}
+	if len(replies) == 0 {
+		return f.Remove()
+	}
+	return f.Persist(quickRepliesIndex{QuickReplies: replies})
+}
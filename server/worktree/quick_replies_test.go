@@ -0,0 +1,83 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/pockode/server/chat"
+)
+
+func TestWorktree_QuickReplies(t *testing.T) {
+	wt := &Worktree{DataDir: filepath.Join(t.TempDir(), "worktrees", "feature")}
+
+	replies, err := wt.QuickReplies()
+	if err != nil || replies != nil {
+		t.Fatalf("QuickReplies() = %v, %v; want nil before any set", replies, err)
+	}
+	if _, err := wt.QuickReply("deny-why"); !errors.Is(err, ErrQuickReplyNotFound) {
+		t.Errorf("QuickReply before set: err = %v, want ErrQuickReplyNotFound", err)
+	}
+
+	want := []chat.QuickReply{
+		{ID: "deny-why", Label: "Deny and explain", Text: "Explain why first.", Kind: chat.QuickReplyPermission},
+		{ID: "prefer-x", Label: "Prefer X", Text: "Prefer library X."},
+	}
+	if err := wt.SetQuickReplies(want); err != nil {
+		t.Fatalf("SetQuickReplies: %v", err)
+	}
+	replies, err = wt.QuickReplies()
+	if err != nil || len(replies) != 2 || replies[0] != want[0] || replies[1] != want[1] {
+		t.Fatalf("QuickReplies() = %+v, %v; want %+v", replies, err, want)
+	}
+	if q, err := wt.QuickReply("prefer-x"); err != nil || q != want[1] {
+		t.Errorf("QuickReply(prefer-x) = %+v, %v", q, err)
+	}
+
+	invalid := map[string][]chat.QuickReply{
+		"bad id":    {{ID: "no spaces", Label: "L", Text: "T"}},
+		"duplicate": {{ID: "a", Label: "L", Text: "T"}, {ID: "a", Label: "L", Text: "T"}},
+		"no label":  {{ID: "a", Text: "T"}},
+		"no text":   {{ID: "a", Label: "L"}},
+		"bad kind":  {{ID: "a", Label: "L", Text: "T", Kind: "chat"}},
+		"huge text": {{ID: "a", Label: "L", Text: string(make([]byte, 4001))}},
+		"too many":  make([]chat.QuickReply, chat.MaxQuickReplies+1),
+	}
+	for name, replies := range invalid {
+		if err := wt.SetQuickReplies(replies); !errors.Is(err, chat.ErrInvalidQuickReply) {
+			t.Errorf("%s: err = %v, want ErrInvalidQuickReply", name, err)
+		}
+	}
+
+	if err := wt.SetQuickReplies(nil); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wt.DataDir, quickRepliesFilename)); !os.IsNotExist(err) {
+		t.Error("clearing should remove the file")
+	}
+}
+
+func TestWorktree_SetQuickRepliesConcurrent(t *testing.T) {
+	wt := &Worktree{DataDir: t.TempDir()}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := "r" + strconv.Itoa(i)
+			if err := wt.SetQuickReplies([]chat.QuickReply{{ID: id, Label: "L", Text: "T"}}); err != nil {
+				t.Errorf("SetQuickReplies(%s): %v", id, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	replies, err := wt.QuickReplies()
+	if err != nil || len(replies) != 1 {
+		t.Fatalf("QuickReplies() = %+v, %v; want one writer's list intact", replies, err)
+	}
+}
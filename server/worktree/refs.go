@@ -0,0 +1,120 @@
+package worktree
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// InternalHolder holds the short-lived references the server takes for
+// itself (work start/stop, digests) through Get and Release.
+const InternalHolder = "internal"
+
+type ref struct {
+	count      int
+	acquiredAt time.Time // first of the holder's current references
+}
+
+// RefHolder is one holder's references to a worktree.
+type RefHolder struct {
+	Holder     string    `json:"holder"`
+	Count      int       `json:"count"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// Refs describes what keeps a loaded worktree alive: its references and
+// running agent processes. Either one blocks idle cleanup.
+type Refs struct {
+	Worktree  string      `json:"worktree"`
+	RefCount  int         `json:"ref_count"`
+	Holders   []RefHolder `json:"holders"`
+	Processes int         `json:"processes"`
+}
+
+// addRefLocked requires Manager.mu.
+func (w *Worktree) addRefLocked(holder string) {
+	if w.refs == nil {
+		w.refs = make(map[string]*ref)
+	}
+	r, ok := w.refs[holder]
+	if !ok {
+		r = &ref{acquiredAt: time.Now()}
+		w.refs[holder] = r
+	}
+	r.count++
+	w.refCount++
+}
+
+// dropRefLocked removes up to n of holder's references (all when n <= 0)
+// and reports whether it had any. Requires Manager.mu.
+func (w *Worktree) dropRefLocked(holder string, n int) bool {
+	r, ok := w.refs[holder]
+	if !ok {
+		return false
+	}
+	if n <= 0 || n > r.count {
+		n = r.count
+	}
+	r.count -= n
+	w.refCount -= n
+	if r.count == 0 {
+		delete(w.refs, holder)
+	}
+	return true
+}
+
+// Refs returns the references of every loaded worktree, sorted by name.
+func (m *Manager) Refs() []Refs {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]Refs, 0, len(m.worktrees))
+	for name, wt := range m.worktrees {
+		r := Refs{
+			Worktree:  name,
+			RefCount:  wt.refCount,
+			Holders:   make([]RefHolder, 0, len(wt.refs)),
+			Processes: wt.ProcessManager.ProcessCount(),
+		}
+		for holder, hr := range wt.refs {
+			r.Holders = append(r.Holders, RefHolder{Holder: holder, Count: hr.count, AcquiredAt: hr.acquiredAt})
+		}
+		sort.Slice(r.Holders, func(i, j int) bool { return r.Holders[i].AcquiredAt.Before(r.Holders[j].AcquiredAt) })
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Worktree < result[j].Worktree })
+	return result
+}
+
+// ForceRelease drops every reference holder has on the named worktree, or
+// every reference at all when holder is empty, for holders that will never
+// call Release (a crashed connection). Returns how many were dropped; the
+// worktree is cleaned up as usual once idle. Later releases by a dropped
+// holder are ignored.
+func (m *Manager) ForceRelease(name, holder string) int {
+	m.mu.Lock()
+	wt, ok := m.worktrees[name]
+	if !ok {
+		m.mu.Unlock()
+		return 0
+	}
+	before := wt.refCount
+	if holder == "" {
+		for h := range wt.refs {
+			wt.dropRefLocked(h, 0)
+		}
+	} else {
+		wt.dropRefLocked(holder, 0)
+	}
+	released := before - wt.refCount
+	refCount := wt.refCount
+	m.mu.Unlock()
+
+	if released > 0 {
+		slog.Warn("worktree references force-released", "name", name, "holder", holder, "released", released)
+	}
+	if released > 0 && refCount == 0 {
+		m.scheduleCleanup(wt)
+	}
+	return released
+}
@@ -4,12 +4,13 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"log/slog"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/pockode/server/setup"
 )
 
 var (
@@ -36,6 +37,8 @@ type Registry struct {
 	isGitRepo bool
 	cacheTime time.Time
 	cacheTTL  time.Duration
+
+	setup *setup.Runner
 }
 
 func NewRegistry(mainDir, dataDir string) *Registry {
@@ -49,9 +52,15 @@ func NewRegistry(mainDir, dataDir string) *Registry {
 		dataDir:  dataDir,
 		cache:    make(map[string]Info),
 		cacheTTL: 3 * time.Second,
+		setup:    setup.NewRunner(dataDir, mainDir),
 	}
 }
 
+// Setup returns the runner that prepares worktrees after Create.
+func (r *Registry) Setup() *setup.Runner {
+	return r.setup
+}
+
 func (r *Registry) IsGitRepo() bool {
 	r.refreshIfNeeded()
 	r.cacheMu.RLock()
@@ -167,12 +176,9 @@ func (r *Registry) Create(name, branch, baseBranch string) (Info, error) {
 		return Info{}, errors.New("worktree created but not found in list")
 	}
 
-	if err := RunSetupHook(r.dataDir, r.mainDir, info.Path, name); err != nil {
-		if delErr := r.Delete(name); delErr != nil {
-			slog.Warn("failed to cleanup worktree after setup hook failure", "name", name, "error", delErr)
-		}
-		return Info{}, fmt.Errorf("setup hook failed: %w", err)
-	}
+	// Setup (dependency installs in particular) can take minutes, so it
+	// runs in the background and reports progress through the runner.
+	r.setup.Start(name, info.Path)
 
 	return info, nil
 }
@@ -196,6 +202,8 @@ func (r *Registry) Delete(name string) error {
 		return ErrWorktreeNotFound
 	}
 
+	r.setup.Forget(name)
+
 	cmd := exec.Command("git", "worktree", "remove", "--force", info.Path)
 	cmd.Dir = r.mainDir
 	if output, err := cmd.CombinedOutput(); err != nil {
@@ -1,11 +1,15 @@
 package worktree
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/pockode/server/setup"
 )
 
 func TestNewRegistry_NonGitRepo(t *testing.T) {
@@ -283,7 +287,7 @@ func TestCreate_NonGitRepo(t *testing.T) {
 	}
 }
 
-func TestCreate_SetupHookFailure_CleansUpWorktree(t *testing.T) {
+func TestCreate_SetupHookFailure_KeepsWorktree(t *testing.T) {
 	dir := initGitRepo(t)
 	dataDir := t.TempDir()
 
@@ -297,22 +301,41 @@ exit 1
 
 	r := NewRegistry(dir, dataDir)
 
-	_, err := r.Create("feature", "feature-branch", "")
-	if err == nil {
-		t.Fatal("Create() should fail when setup hook fails")
+	info, err := r.Create("feature", "feature-branch", "")
+	if err != nil {
+		t.Fatalf("Create() should not wait for the setup hook, got: %v", err)
 	}
-	if !strings.Contains(err.Error(), "setup hook failed") {
-		t.Errorf("expected setup hook error, got: %v", err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	status, err := r.Setup().Wait(ctx, "feature")
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if status.State != setup.StateFailed {
+		t.Errorf("setup State = %q, want failed", status.State)
 	}
 
-	worktreePath := filepath.Join(r.worktreesDir(), "feature")
-	if _, err := os.Stat(worktreePath); !os.IsNotExist(err) {
-		t.Error("worktree should be cleaned up after hook failure")
+	if _, err := os.Stat(info.Path); err != nil {
+		t.Errorf("worktree should be kept after hook failure: %v", err)
 	}
+}
 
-	_, err = r.Resolve("feature")
-	if err != ErrWorktreeNotFound {
-		t.Errorf("Resolve() error = %v, want ErrWorktreeNotFound", err)
+func TestDelete_ForgetsSetupStatus(t *testing.T) {
+	dir := initGitRepo(t)
+	r := NewRegistry(dir, t.TempDir())
+
+	if _, err := r.Create("feature", "feature-branch", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Setup().Get("feature"); !ok {
+		t.Fatal("Create() should start setup")
+	}
+	if err := r.Delete("feature"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Setup().Get("feature"); ok {
+		t.Error("Delete() should drop the setup status")
 	}
 }
 
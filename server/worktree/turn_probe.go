@@ -0,0 +1,51 @@
+package worktree
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/work"
+)
+
+// TurnProbe snapshots work sessions, which run in the main worktree, for
+// the AutoResumer's progress check. Implements work.ProgressProbe.
+type TurnProbe struct {
+	worktreeManager *Manager
+}
+
+func NewTurnProbe(wm *Manager) *TurnProbe {
+	return &TurnProbe{worktreeManager: wm}
+}
+
+// Snapshot counts the session's indexed tool calls and collects the text of
+// its latest turn.
+func (p *TurnProbe) Snapshot(sessionID string) (work.TurnSnapshot, error) {
+	mainWt, err := p.worktreeManager.Get("")
+	if err != nil {
+		return work.TurnSnapshot{}, fmt.Errorf("get main worktree: %w", err)
+	}
+	defer p.worktreeManager.Release(mainWt)
+
+	ctx := context.Background()
+	calls, err := mainWt.SessionStore.ToolCalls(ctx, sessionID, session.ToolCallFilter{})
+	if err != nil {
+		return work.TurnSnapshot{}, fmt.Errorf("list tool calls: %w", err)
+	}
+	history, err := mainWt.SessionStore.GetHistory(ctx, sessionID)
+	if err != nil {
+		return work.TurnSnapshot{}, fmt.Errorf("get history: %w", err)
+	}
+
+	var text strings.Builder
+	for _, raw := range session.TurnHistory(history, -1).Records {
+		var record agent.EventRecord
+		if json.Unmarshal(raw, &record) == nil && record.Type == agent.EventTypeText {
+			text.WriteString(record.Content)
+		}
+	}
+	return work.TurnSnapshot{ToolCalls: len(calls), LastMessage: text.String()}, nil
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 
 	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/session"
 	"github.com/pockode/server/settings"
 	"github.com/pockode/server/work"
 )
@@ -30,17 +32,23 @@ func NewWorkStarter(wm *Manager, ars agentrole.Store, ss *settings.Store) *WorkS
 // work item that has already been claimed (status=in_progress, sessionID set).
 // If a session with the same ID already exists (restart case), it skips
 // session creation and sends a restart message instead.
-func (s *WorkStarter) HandleWorkStart(ctx context.Context, w work.Work) error {
-	if w.AgentRoleID == "" {
+//
+// opts.Mode overrides the default session mode; the effective agent role
+// (w.SessionAgentRoleID when set) is recorded on the session, and its
+// agent type, when set, overrides the default backend for a new session. opts.Message,
+// when set, replaces the kickoff/restart message.
+func (s *WorkStarter) HandleWorkStart(ctx context.Context, w work.Work, opts work.StartOptions) error {
+	roleID := w.EffectiveAgentRoleID()
+	if roleID == "" {
 		return fmt.Errorf("work %s has no agent_role_id", w.ID)
 	}
 
-	role, found, err := s.agentRoleStore.Get(w.AgentRoleID)
+	role, found, err := s.agentRoleStore.Get(roleID)
 	if err != nil {
 		return fmt.Errorf("get agent role: %w", err)
 	}
 	if !found {
-		return fmt.Errorf("agent role %q not found", w.AgentRoleID)
+		return fmt.Errorf("agent role %q not found", roleID)
 	}
 
 	mainWt, err := s.worktreeManager.Get("")
@@ -50,38 +58,114 @@ func (s *WorkStarter) HandleWorkStart(ctx context.Context, w work.Work) error {
 	defer s.worktreeManager.Release(mainWt)
 
 	// Check if session already exists to distinguish restart from fresh start.
-	_, sessionExists, err := mainWt.SessionStore.Get(w.SessionID)
+	meta, sessionExists, err := mainWt.SessionStore.Get(w.SessionID)
 	if err != nil {
 		return fmt.Errorf("check session: %w", err)
 	}
 
 	if sessionExists {
-		return s.sendRestart(ctx, mainWt, w)
+		return s.sendRestart(ctx, mainWt, w, meta.Mode, opts)
 	}
-	return s.createAndSendKickoff(ctx, mainWt, w, role.Steps)
+	// A trashed session could still be restored; a fresh one under its ID
+	// would block that and lose the history.
+	trash, err := mainWt.SessionStore.ListTrash()
+	if err != nil {
+		return fmt.Errorf("check session trash: %w", err)
+	}
+	if slices.ContainsFunc(trash, func(t session.TrashedSession) bool { return t.ID == w.SessionID }) {
+		return fmt.Errorf("%w: restore session %s or reset work %s to start a fresh one", work.ErrSessionTrashed, w.SessionID, w.ID)
+	}
+	return s.createAndSendKickoff(ctx, mainWt, w, opts, role)
+}
+
+// NewSessionID implements work.SessionIDSource: a fresh work session takes
+// the ID of a warm process in the main worktree when one matches the agent
+// type and mode the session will be created with.
+func (s *WorkStarter) NewSessionID(w work.Work, opts work.StartOptions) string {
+	defaults := s.settingsStore.Get()
+	mode := defaults.DefaultMode
+	if opts.Mode != "" {
+		mode = opts.Mode
+	}
+	agentType := defaults.DefaultAgentType
+	roleID := opts.AgentRoleID
+	if roleID == "" {
+		roleID = w.AgentRoleID
+	}
+	role, found, err := s.agentRoleStore.Get(roleID)
+	if err != nil {
+		slog.Warn("failed to get agent role for work session", "workId", w.ID, "error", err)
+	} else if found && role.AgentType != "" {
+		agentType = role.AgentType
+	}
+
+	// Empty lets the store generate an ID; HandleWorkStart reports the error.
+	mainWt, err := s.worktreeManager.Get("")
+	if err != nil {
+		return ""
+	}
+	defer s.worktreeManager.Release(mainWt)
+	return mainWt.ProcessManager.NewSessionID(agentType, mode)
 }
 
-func (s *WorkStarter) sendRestart(ctx context.Context, wt *Worktree, w work.Work) error {
-	msg := work.BuildRestartMessage(w)
-	if err := wt.ChatClient.SendMessage(ctx, w.SessionID, msg); err != nil {
+func (s *WorkStarter) sendRestart(ctx context.Context, wt *Worktree, w work.Work, currentMode session.Mode, opts work.StartOptions) error {
+	if opts.Mode != "" && opts.Mode != currentMode {
+		// A running process keeps the mode it was launched with, so close it
+		// first; the next message respawns it (resuming history) in the new mode.
+		wt.ProcessManager.Close(w.SessionID)
+		if err := wt.SessionStore.SetMode(ctx, w.SessionID, opts.Mode); err != nil {
+			return fmt.Errorf("set session mode: %w", err)
+		}
+	}
+	if err := wt.SessionStore.SetAgentRoleID(ctx, w.SessionID, w.SessionAgentRoleID); err != nil {
+		return fmt.Errorf("set session agent role: %w", err)
+	}
+
+	msg := opts.Message
+	if msg == "" {
+		msg = work.BuildRestartMessage(s.settingsStore.Get().EffectiveLocale(), w)
+	}
+	if err := wt.ChatClient.SendSystemMessage(ctx, w.SessionID, msg); err != nil {
 		return fmt.Errorf("send restart message: %w", err)
 	}
 	return nil
 }
 
-func (s *WorkStarter) createAndSendKickoff(ctx context.Context, wt *Worktree, w work.Work, steps []string) error {
+func (s *WorkStarter) createAndSendKickoff(ctx context.Context, wt *Worktree, w work.Work, opts work.StartOptions, role agentrole.AgentRole) error {
 	defaults := s.settingsStore.Get()
-	if _, err := wt.SessionStore.Create(ctx, w.SessionID, defaults.DefaultAgentType, defaults.DefaultMode); err != nil {
+	mode := defaults.DefaultMode
+	if opts.Mode != "" {
+		mode = opts.Mode
+	}
+	agentType := defaults.DefaultAgentType
+	if role.AgentType != "" {
+		agentType = role.AgentType
+	}
+	if _, err := wt.SessionStore.Create(ctx, w.SessionID, agentType, mode); err != nil {
 		return fmt.Errorf("create session: %w", err)
 	}
 
+	if w.SessionAgentRoleID != "" {
+		if err := wt.SessionStore.SetAgentRoleID(ctx, w.SessionID, w.SessionAgentRoleID); err != nil {
+			slog.Warn("failed to record session agent role", "sessionId", w.SessionID, "error", err)
+		}
+	}
+
 	if err := wt.SessionStore.Update(ctx, w.SessionID, w.Title); err != nil {
 		slog.Warn("failed to set session title", "sessionId", w.SessionID, "error", err)
 	}
 
-	// Include first step in kickoff message if agent role has steps
-	msg := work.BuildKickoffMessageWithSteps(w, steps, w.CurrentStep)
-	if err := wt.ChatClient.SendMessage(ctx, w.SessionID, msg); err != nil {
+	// Include first step in kickoff message if agent role has steps. Plan
+	// mode withholds steps until the plan is approved.
+	msg := opts.Message
+	switch {
+	case msg != "":
+	case w.InPlanPhase():
+		msg = work.BuildPlanModeKickoffMessage(defaults.EffectiveLocale(), w)
+	default:
+		msg = work.BuildKickoffMessageWithSteps(defaults.EffectiveLocale(), w, role.Steps, w.CurrentStep)
+	}
+	if err := wt.ChatClient.SendSystemMessage(ctx, w.SessionID, msg); err != nil {
 		if delErr := wt.SessionStore.Delete(ctx, w.SessionID); delErr != nil {
 			slog.Error("failed to clean up session after kickoff failure", "sessionId", w.SessionID, "error", delErr)
 		}
@@ -37,17 +37,35 @@ func (s *WorkStopper) HandleWorkStop(ctx context.Context, id string) error {
 	}
 
 	// Terminate the agent process if running.
-	// Best-effort: the work is already stopped, so we log but don't fail
-	// if the process can't be reached (e.g. worktree already closed).
 	if w.SessionID != "" {
-		mainWt, err := s.worktreeManager.Get("")
-		if err != nil {
-			slog.Warn("could not get worktree to terminate process", "workId", id, "sessionId", w.SessionID, "error", err)
-		} else {
-			mainWt.ProcessManager.Close(w.SessionID)
-			s.worktreeManager.Release(mainWt)
-		}
+		s.CloseSession(w.SessionID)
 	}
 
 	return nil
 }
+
+// CloseSession terminates a work session's agent process if running.
+// Best-effort: the work has already transitioned, so we log but don't fail
+// if the process can't be reached (e.g. worktree already closed).
+// Implements work.SessionCloser.
+func (s *WorkStopper) CloseSession(sessionID string) {
+	mainWt, err := s.worktreeManager.Get("")
+	if err != nil {
+		slog.Warn("could not get worktree to terminate process", "sessionId", sessionID, "error", err)
+		return
+	}
+	mainWt.ProcessManager.Close(sessionID)
+	s.worktreeManager.Release(mainWt)
+}
+
+// HasProcess reports whether a work session has a live agent process.
+// Implements work.ProcessChecker.
+func (s *WorkStopper) HasProcess(sessionID string) bool {
+	mainWt, err := s.worktreeManager.Get("")
+	if err != nil {
+		slog.Warn("could not get worktree to check process", "sessionId", sessionID, "error", err)
+		return false
+	}
+	defer s.worktreeManager.Release(mainWt)
+	return mainWt.ProcessManager.HasProcess(sessionID)
+}
@@ -0,0 +1,184 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pockode/server/watch"
+	"github.com/pockode/server/work"
+)
+
+// worksDirname is the work store directory inside a data dir. Its presence
+// in a worktree's data dir marks the worktree as having an isolated store.
+const worksDirname = "works"
+
+// Board is one worktree's work store, as listed by WorkStores.All.
+type Board struct {
+	Worktree string // "" for the main worktree
+	Store    work.Store
+}
+
+// WorkStores resolves the work store each worktree uses. Worktrees share the
+// main store unless they were isolated at creation, in which case their work
+// lives in a store in their own data dir, keeping experiments off the main
+// board. The MCP server of an isolated worktree's agents is pointed at that
+// data dir, so their work tools operate on the isolated store.
+type WorkStores struct {
+	main      work.Store
+	dataDir   string
+	configure func(*work.FileStore)
+
+	mu     sync.Mutex
+	stores map[string]*work.FileStore
+}
+
+func NewWorkStores(main work.Store, dataDir string) *WorkStores {
+	return &WorkStores{main: main, dataDir: dataDir, stores: make(map[string]*work.FileStore)}
+}
+
+// SetConfigure sets a function applied to every isolated store when it is
+// opened (default agent role, lifecycle hooks, change listeners).
+func (s *WorkStores) SetConfigure(fn func(*work.FileStore)) {
+	s.configure = fn
+}
+
+// Main returns the shared store.
+func (s *WorkStores) Main() work.Store {
+	return s.main
+}
+
+// DataDir returns the data dir holding name's work store: the worktree's own
+// data dir when isolated, the main data dir otherwise.
+func (s *WorkStores) DataDir(name string) string {
+	if s.IsIsolated(name) {
+		return s.worktreeDataDir(name)
+	}
+	return s.dataDir
+}
+
+// IsIsolated reports whether name has its own work store.
+func (s *WorkStores) IsIsolated(name string) bool {
+	if name == "" {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(s.worktreeDataDir(name), worksDirname))
+	return err == nil && info.IsDir()
+}
+
+// Isolate gives name its own, initially empty, work store. Isolating an
+// already isolated worktree is a no-op.
+func (s *WorkStores) Isolate(name string) error {
+	if name == "" {
+		return errors.New("the main worktree cannot be isolated")
+	}
+	if err := os.MkdirAll(filepath.Join(s.worktreeDataDir(name), worksDirname), 0755); err != nil {
+		return fmt.Errorf("create work store directory: %w", err)
+	}
+	_, err := s.open(name)
+	return err
+}
+
+// For returns the store name's work lives in.
+func (s *WorkStores) For(name string) (work.Store, error) {
+	if !s.IsIsolated(name) {
+		return s.main, nil
+	}
+	return s.open(name)
+}
+
+// ForDataDir returns the isolated store whose data dir is dataDir, as passed
+// to the MCP server. It reports false for the main data dir or any directory
+// that is not an isolated worktree's.
+func (s *WorkStores) ForDataDir(dataDir string) (work.Store, bool, error) {
+	rel, err := filepath.Rel(filepath.Join(s.dataDir, "worktrees"), filepath.Clean(dataDir))
+	if err != nil || rel == "." || filepath.Dir(rel) != "." || rel == ".." {
+		return nil, false, nil
+	}
+	if !s.IsIsolated(rel) {
+		return nil, false, nil
+	}
+	store, err := s.open(rel)
+	if err != nil {
+		return nil, false, err
+	}
+	return store, true, nil
+}
+
+// All returns the main store followed by every isolated worktree's store,
+// ordered by worktree name.
+func (s *WorkStores) All() ([]Board, error) {
+	boards := []Board{{Store: s.main}}
+	entries, err := os.ReadDir(filepath.Join(s.dataDir, "worktrees"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && s.IsIsolated(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		store, err := s.open(name)
+		if err != nil {
+			return nil, fmt.Errorf("open work store of worktree %q: %w", name, err)
+		}
+		boards = append(boards, Board{Worktree: name, Store: store})
+	}
+	return boards, nil
+}
+
+// SessionWorkLinks implements watch.SessionWorkResolver: it maps every
+// session linked from a work item, in any store, to that item.
+func (s *WorkStores) SessionWorkLinks() (map[string]watch.SessionWorkLink, error) {
+	boards, err := s.All()
+	if err != nil {
+		return nil, err
+	}
+	links := make(map[string]watch.SessionWorkLink)
+	for _, b := range boards {
+		works, err := b.Store.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range works {
+			if w.SessionID != "" {
+				links[w.SessionID] = watch.SessionWorkLink{WorkID: w.ID, Worktree: b.Worktree}
+			}
+		}
+	}
+	return links, nil
+}
+
+// forget drops the cached store of a deleted worktree.
+func (s *WorkStores) forget(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.stores, name)
+}
+
+func (s *WorkStores) open(name string) (*work.FileStore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if store, ok := s.stores[name]; ok {
+		return store, nil
+	}
+	store, err := work.NewFileStore(s.worktreeDataDir(name))
+	if err != nil {
+		return nil, err
+	}
+	if s.configure != nil {
+		s.configure(store)
+	}
+	s.stores[name] = store
+	return store, nil
+}
+
+func (s *WorkStores) worktreeDataDir(name string) string {
+	return filepath.Join(s.dataDir, "worktrees", name)
+}
@@ -0,0 +1,147 @@
+package worktree
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/pockode/server/work"
+)
+
+func TestWorkStores_Isolate(t *testing.T) {
+	dataDir := t.TempDir()
+	main, err := work.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := NewWorkStores(main, dataDir)
+
+	if got, err := stores.For("feature"); err != nil || got != work.Store(main) {
+		t.Fatalf("For(feature) = %v, %v; want the main store before isolation", got, err)
+	}
+	if stores.DataDir("feature") != dataDir {
+		t.Errorf("DataDir(feature) = %q, want the main data dir", stores.DataDir("feature"))
+	}
+	if err := stores.Isolate(""); err == nil {
+		t.Error("isolating the main worktree succeeded")
+	}
+
+	if err := stores.Isolate("feature"); err != nil {
+		t.Fatalf("Isolate: %v", err)
+	}
+	isolated, err := stores.For("feature")
+	if err != nil || isolated == work.Store(main) {
+		t.Fatalf("For(feature) = %v, %v; want its own store", isolated, err)
+	}
+	wtDataDir := filepath.Join(dataDir, "worktrees", "feature")
+	if stores.DataDir("feature") != wtDataDir {
+		t.Errorf("DataDir(feature) = %q, want %q", stores.DataDir("feature"), wtDataDir)
+	}
+	if got, found, err := stores.ForDataDir(wtDataDir); err != nil || !found || got != isolated {
+		t.Errorf("ForDataDir(worktree) = %v, %v, %v; want the isolated store", got, found, err)
+	}
+	for _, dir := range []string{dataDir, filepath.Join(dataDir, "worktrees", "other"), filepath.Join(wtDataDir, "sub")} {
+		if _, found, _ := stores.ForDataDir(dir); found {
+			t.Errorf("ForDataDir(%q) found a store", dir)
+		}
+	}
+
+	if _, err := isolated.Create(context.Background(), work.Work{Type: work.WorkTypeStory, Title: "Experiment", AgentRoleID: "r"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if works, _ := main.List(); len(works) != 0 {
+		t.Errorf("main store has %d works, want the experiment kept off it", len(works))
+	}
+
+	boards, err := stores.All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if len(boards) != 2 || boards[0].Worktree != "" || boards[1].Worktree != "feature" {
+		t.Fatalf("All() = %+v, want main then feature", boards)
+	}
+	if works, _ := boards[1].Store.List(); len(works) != 1 {
+		t.Errorf("feature board has %d works, want 1", len(works))
+	}
+}
+
+type recordingHooks struct{ after []work.LifecycleEvent }
+
+func (h *recordingHooks) Before(context.Context, work.LifecycleEvent, work.Work) error { return nil }
+func (h *recordingHooks) After(event work.LifecycleEvent, _ work.Work) {
+	h.after = append(h.after, event)
+}
+
+func TestWorkStores_IsolatedStoresRunLifecycleHooks(t *testing.T) {
+	dataDir := t.TempDir()
+	main, err := work.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := &recordingHooks{}
+	stores := NewWorkStores(main, dataDir)
+	stores.SetConfigure(func(store *work.FileStore) { store.SetLifecycleHooks(hooks) })
+
+	if err := stores.Isolate("feature"); err != nil {
+		t.Fatalf("Isolate: %v", err)
+	}
+	isolated, err := stores.For("feature")
+	if err != nil {
+		t.Fatalf("For: %v", err)
+	}
+	if _, err := isolated.Create(context.Background(), work.Work{Type: work.WorkTypeStory, Title: "Experiment", AgentRoleID: "r"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(hooks.after) != 1 || hooks.after[0] != work.LifecycleCreated {
+		t.Errorf("hooks ran %v on the isolated store, want [created]", hooks.after)
+	}
+}
+
+func TestWorkStores_SessionWorkLinks(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	main, err := work.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := NewWorkStores(main, dataDir)
+	if err := stores.Isolate("feature"); err != nil {
+		t.Fatal(err)
+	}
+	isolated, err := stores.For("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	story, err := main.Create(ctx, work.Work{Type: work.WorkTypeStory, Title: "Main", AgentRoleID: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := main.Start(ctx, story.ID, "sess-main"); err != nil {
+		t.Fatal(err)
+	}
+	experiment, err := isolated.Create(ctx, work.Work{Type: work.WorkTypeStory, Title: "Experiment", AgentRoleID: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := isolated.Start(ctx, experiment.ID, "sess-feature"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := main.Create(ctx, work.Work{Type: work.WorkTypeStory, Title: "Unstarted", AgentRoleID: "r"}); err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := stores.SessionWorkLinks()
+	if err != nil {
+		t.Fatalf("SessionWorkLinks: %v", err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("got %d links, want 2: %+v", len(links), links)
+	}
+	if l := links["sess-main"]; l.WorkID != story.ID || l.Worktree != "" {
+		t.Errorf("sess-main = %+v, want %s in the main store", l, story.ID)
+	}
+	if l := links["sess-feature"]; l.WorkID != experiment.ID || l.Worktree != "feature" {
+		t.Errorf("sess-feature = %+v, want %s in feature", l, experiment.ID)
+	}
+}
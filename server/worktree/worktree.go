@@ -15,19 +15,23 @@ import (
 type Worktree struct {
 	Name                string
 	WorkDir             string
+	DataDir             string // per-worktree state (sessions, MCP servers)
 	SessionStore        session.Store
 	FSWatcher           *watch.FSWatcher
 	GitWatcher          *watch.GitWatcher
+	WorkspaceWatcher    *watch.WorkspaceWatcher
 	GitDiffWatcher      *watch.GitDiffWatcher
 	SessionListWatcher  *watch.SessionListWatcher
 	ChatMessagesWatcher *watch.ChatMessagesWatcher
 	ProcessManager      *process.Manager
 	ChatClient          *chat.Client
 
-	watchers []watch.Watcher // for unified lifecycle management
+	watchers   []watch.Watcher // for unified lifecycle management
+	attributor *fileAttributor
 
-	mu          sync.Mutex // protects subscribers only
-	refCount    int        // protected by Manager.mu, not Worktree.mu
+	mu          sync.Mutex      // protects subscribers only
+	refCount    int             // protected by Manager.mu, not Worktree.mu
+	refs        map[string]*ref // by holder; protected by Manager.mu
 	subscribers map[watch.Notifier]struct{}
 }
 
@@ -73,6 +77,9 @@ func (w *Worktree) Start() error {
 			return fmt.Errorf("start watcher: %w", err)
 		}
 	}
+	if w.attributor != nil {
+		w.attributor.Start()
+	}
 	return nil
 }
 
@@ -81,6 +88,9 @@ func (w *Worktree) Stop() {
 		watcher.Stop()
 	}
 	w.ProcessManager.Shutdown()
+	if w.attributor != nil {
+		w.attributor.Stop()
+	}
 }
 
 // Watchers returns all watchers managed by this worktree.
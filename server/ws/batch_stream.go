@@ -0,0 +1,231 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// batchStream adds JSON-RPC 2.0 batch support to an ObjectStream, which
+// jsonrpc2.Conn lacks. A batch array is split into single messages for the
+// read loop, and the replies to its requests are held back and written as
+// one array once every request has been answered.
+//
+// Requests within a batch are released to the handler one at a time, each
+// after the previous request's reply, so a batch like [auth, subscribe, ...]
+// observes auth having completed. Order across separate frames is unchanged.
+type batchStream struct {
+	jsonrpc2.ObjectStream
+
+	batchMu sync.Mutex        // protects the fields below
+	queue   []json.RawMessage // unread elements of the current batch
+	gateID  string            // request ID whose reply releases the next element
+	gate    chan struct{}     // closed when gateID is replied to
+	batches []*pendingBatch
+	flushed []func()      // run once no batch is pending
+	done    chan struct{} // closed by Close
+	closed  bool
+}
+
+type pendingBatch struct {
+	waiting map[string]bool // request IDs still awaiting a reply
+	replies []json.RawMessage
+}
+
+// batchMessage is the subset of a JSON-RPC message needed to route it.
+type batchMessage struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+}
+
+var errInvalidBatchElement = json.RawMessage(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid request"}}`)
+
+func newBatchStream(s jsonrpc2.ObjectStream) *batchStream {
+	return &batchStream{ObjectStream: s, done: make(chan struct{})}
+}
+
+// ReadObject returns the next message, unpacking batches into their elements.
+func (s *batchStream) ReadObject(v interface{}) error {
+	for {
+		s.batchMu.Lock()
+		gate := s.gate
+		s.batchMu.Unlock()
+		if gate != nil {
+			select {
+			case <-gate:
+			case <-s.done:
+				return io.EOF
+			}
+		}
+
+		s.batchMu.Lock()
+		if s.closed {
+			s.batchMu.Unlock()
+			return io.EOF
+		}
+		s.gate, s.gateID = nil, ""
+		if len(s.queue) > 0 {
+			next := s.queue[0]
+			s.queue = s.queue[1:]
+			var msg batchMessage
+			if err := json.Unmarshal(next, &msg); err == nil && msg.ID != nil && msg.Method != "" {
+				s.gate, s.gateID = make(chan struct{}), string(*msg.ID)
+			}
+			s.batchMu.Unlock()
+			return json.Unmarshal(next, v)
+		}
+		s.batchMu.Unlock()
+
+		var raw json.RawMessage
+		if err := s.ObjectStream.ReadObject(&raw); err != nil {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '[' {
+			return json.Unmarshal(raw, v)
+		}
+		if err := s.startBatch(raw); err != nil {
+			return err
+		}
+	}
+}
+
+// startBatch queues a batch's requests and registers the IDs whose replies
+// make up its response. Elements that are not JSON-RPC objects get an
+// invalid request reply in place, as the spec requires.
+func (s *batchStream) startBatch(raw json.RawMessage) error {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return err
+	}
+	if len(elems) == 0 {
+		return s.ObjectStream.WriteObject(errInvalidBatchElement)
+	}
+
+	batch := &pendingBatch{waiting: make(map[string]bool)}
+	var queue []json.RawMessage
+	for _, elem := range elems {
+		var msg batchMessage
+		if err := json.Unmarshal(elem, &msg); err != nil || msg.Method == "" {
+			batch.replies = append(batch.replies, errInvalidBatchElement)
+			continue
+		}
+		if msg.ID != nil {
+			batch.waiting[string(*msg.ID)] = true
+		}
+		queue = append(queue, elem)
+	}
+
+	if len(batch.waiting) == 0 {
+		// Only notifications (or invalid elements): nothing to wait for.
+		if len(batch.replies) > 0 {
+			if err := s.ObjectStream.WriteObject(batch.replies); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.batchMu.Lock()
+	s.queue = queue
+	if len(batch.waiting) > 0 {
+		s.batches = append(s.batches, batch)
+	}
+	s.batchMu.Unlock()
+	return nil
+}
+
+// WriteObject writes a message, holding back replies that belong to a
+// pending batch until the whole batch can be written.
+func (s *batchStream) WriteObject(obj interface{}) error {
+	s.batchMu.Lock()
+	if len(s.batches) == 0 && s.gate == nil {
+		s.batchMu.Unlock()
+		return s.ObjectStream.WriteObject(obj)
+	}
+	s.batchMu.Unlock()
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var msg batchMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.ID == nil || msg.Method != "" {
+		return s.ObjectStream.WriteObject(json.RawMessage(data))
+	}
+	id := string(*msg.ID)
+
+	s.batchMu.Lock()
+	if s.gate != nil && s.gateID == id {
+		close(s.gate)
+		s.gate = nil
+	}
+	for i, batch := range s.batches {
+		if !batch.waiting[id] {
+			continue
+		}
+		delete(batch.waiting, id)
+		batch.replies = append(batch.replies, data)
+		if len(batch.waiting) > 0 {
+			s.batchMu.Unlock()
+			return nil
+		}
+		s.batches = append(s.batches[:i], s.batches[i+1:]...)
+		var flushed []func()
+		if len(s.batches) == 0 {
+			flushed, s.flushed = s.flushed, nil
+		}
+		s.batchMu.Unlock()
+		err := s.ObjectStream.WriteObject(batch.replies)
+		for _, fn := range flushed {
+			fn()
+		}
+		return err
+	}
+	s.batchMu.Unlock()
+	return s.ObjectStream.WriteObject(json.RawMessage(data))
+}
+
+// Close flushes the replies collected so far for unfinished batches (e.g. an
+// auth error followed by a disconnect) and closes the underlying stream.
+func (s *batchStream) Close() error {
+	s.batchMu.Lock()
+	if s.closed {
+		s.batchMu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	batches := s.batches
+	s.batches = nil
+	s.batchMu.Unlock()
+
+	for _, batch := range batches {
+		if len(batch.replies) == 0 {
+			continue
+		}
+		// Best effort: the peer may already be gone.
+		if err := s.ObjectStream.WriteObject(batch.replies); err != nil {
+			slog.Debug("failed to flush batch replies on close", "error", err)
+		}
+	}
+	return s.ObjectStream.Close()
+}
+
+// afterFlush runs fn once every pending batch has been written, or right
+// away when none is pending. Auth uses it to switch the wire encoding only
+// after a batched auth reply is out.
+func (s *batchStream) afterFlush(fn func()) {
+	s.batchMu.Lock()
+	if len(s.batches) > 0 {
+		s.flushed = append(s.flushed, fn)
+		s.batchMu.Unlock()
+		return
+	}
+	s.batchMu.Unlock()
+	fn()
+}
+
+var _ jsonrpc2.ObjectStream = (*batchStream)(nil)
@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeObjectStream feeds queued frames to ReadObject and records writes.
+type fakeObjectStream struct {
+	framesMu sync.Mutex
+	frames   []string
+	writes   []string
+}
+
+func (f *fakeObjectStream) ReadObject(v interface{}) error {
+	f.framesMu.Lock()
+	defer f.framesMu.Unlock()
+	if len(f.frames) == 0 {
+		return io.EOF
+	}
+	frame := f.frames[0]
+	f.frames = f.frames[1:]
+	return json.Unmarshal([]byte(frame), v)
+}
+
+func (f *fakeObjectStream) WriteObject(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f.framesMu.Lock()
+	defer f.framesMu.Unlock()
+	f.writes = append(f.writes, string(data))
+	return nil
+}
+
+func (f *fakeObjectStream) Close() error { return nil }
+
+func (f *fakeObjectStream) getWrites() []string {
+	f.framesMu.Lock()
+	defer f.framesMu.Unlock()
+	return append([]string(nil), f.writes...)
+}
+
+func TestBatchStream_GatesAndCollectsReplies(t *testing.T) {
+	inner := &fakeObjectStream{frames: []string{
+		`[{"jsonrpc":"2.0","id":1,"method":"a"},{"jsonrpc":"2.0","method":"note"},{"jsonrpc":"2.0","id":2,"method":"b"}]`,
+	}}
+	s := newBatchStream(inner)
+
+	var msg batchMessage
+	if err := s.ReadObject(&msg); err != nil || msg.Method != "a" {
+		t.Fatalf("first read = %+v, %v; want method a", msg, err)
+	}
+
+	// The next element is held back until request 1 is answered.
+	next := make(chan batchMessage, 1)
+	go func() {
+		var m batchMessage
+		if err := s.ReadObject(&m); err == nil {
+			next <- m
+		}
+	}()
+	select {
+	case m := <-next:
+		t.Fatalf("read %q before request 1 was answered", m.Method)
+	default:
+	}
+
+	flushed := false
+	s.afterFlush(func() { flushed = true })
+	if flushed {
+		t.Fatal("afterFlush ran while the batch was pending")
+	}
+
+	if err := s.WriteObject(json.RawMessage(`{"jsonrpc":"2.0","id":1,"result":"one"}`)); err != nil {
+		t.Fatalf("write reply 1: %v", err)
+	}
+	if m := <-next; m.Method != "note" {
+		t.Fatalf("second read = %q, want note", m.Method)
+	}
+	// Notifications have no reply, so they do not gate the next element.
+	if err := s.ReadObject(&msg); err != nil || msg.Method != "b" {
+		t.Fatalf("third read = %+v, %v; want method b", msg, err)
+	}
+	if got := inner.getWrites(); len(got) != 0 {
+		t.Fatalf("replies written before the batch completed: %v", got)
+	}
+
+	// Unrelated outbound messages pass straight through.
+	if err := s.WriteObject(json.RawMessage(`{"jsonrpc":"2.0","method":"event"}`)); err != nil {
+		t.Fatalf("write notification: %v", err)
+	}
+	if err := s.WriteObject(json.RawMessage(`{"jsonrpc":"2.0","id":2,"result":"two"}`)); err != nil {
+		t.Fatalf("write reply 2: %v", err)
+	}
+
+	writes := inner.getWrites()
+	if len(writes) != 2 {
+		t.Fatalf("writes = %v, want notification then batch", writes)
+	}
+	var replies []json.RawMessage
+	if err := json.Unmarshal([]byte(writes[1]), &replies); err != nil || len(replies) != 2 {
+		t.Fatalf("batch reply = %s, want an array of 2", writes[1])
+	}
+	if !flushed {
+		t.Error("afterFlush did not run once the batch was written")
+	}
+}
+
+func TestBatchStream_CloseFlushesPartialBatch(t *testing.T) {
+	inner := &fakeObjectStream{frames: []string{
+		`[{"jsonrpc":"2.0","id":1,"method":"auth"},{"jsonrpc":"2.0","id":2,"method":"b"}]`,
+	}}
+	s := newBatchStream(inner)
+
+	var msg batchMessage
+	if err := s.ReadObject(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := s.WriteObject(json.RawMessage(`{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"invalid token"}}`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	writes := inner.getWrites()
+	if len(writes) != 1 {
+		t.Fatalf("writes = %v, want the partial batch", writes)
+	}
+	var replies []json.RawMessage
+	if err := json.Unmarshal([]byte(writes[0]), &replies); err != nil || len(replies) != 1 {
+		t.Errorf("partial batch = %s, want an array of 1", writes[0])
+	}
+	// Reads after close do not block on the unanswered request.
+	if err := s.ReadObject(&msg); err != io.EOF {
+		t.Errorf("read after close = %v, want EOF", err)
+	}
+}
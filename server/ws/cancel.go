@@ -0,0 +1,91 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// cancelMethod is the notification a client sends to cancel one of its
+// running requests, named as in LSP.
+const cancelMethod = "$/cancelRequest"
+
+var (
+	errRequestCancelled = errors.New("request cancelled")
+	errConnClosed       = errors.New("connection closed")
+)
+
+// inflightRequest is a running request; a pointer so a finished request
+// does not remove a newer one that reused its ID.
+type inflightRequest struct {
+	cancel context.CancelCauseFunc
+}
+
+// trackRequest derives the context a request runs under, cancelled by
+// $/cancelRequest for its ID. done must be called when the request has
+// been replied to.
+func (s *rpcConnState) trackRequest(ctx context.Context, id jsonrpc2.ID) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	r := &inflightRequest{cancel: cancel}
+
+	s.inflightMu.Lock()
+	if s.inflight == nil {
+		s.inflight = make(map[jsonrpc2.ID]*inflightRequest)
+	}
+	s.inflight[id] = r
+	s.inflightMu.Unlock()
+
+	return ctx, func() {
+		s.inflightMu.Lock()
+		if s.inflight[id] == r {
+			delete(s.inflight, id)
+		}
+		s.inflightMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelRequest cancels the running request with the given ID and reports
+// whether there was one. A request that already finished is not an error:
+// the cancel and the reply crossed on the wire.
+func (s *rpcConnState) cancelRequest(id jsonrpc2.ID) bool {
+	s.inflightMu.Lock()
+	r, ok := s.inflight[id]
+	s.inflightMu.Unlock()
+	if ok {
+		r.cancel(errRequestCancelled)
+	}
+	return ok
+}
+
+// cancelAllRequests stops the requests still running when the connection
+// closes; nobody is left to read their replies.
+func (s *rpcConnState) cancelAllRequests() {
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+	for _, r := range s.inflight {
+		r.cancel(errConnClosed)
+	}
+}
+
+// requestCancelled reports whether the client cancelled the request ctx
+// belongs to.
+func requestCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRequestCancelled)
+}
+
+// handleCancelRequest handles $/cancelRequest. It is a notification, so
+// bad params are only logged; the cancelled request replies with
+// rpc.CodeRequestCancelled unless it finishes first.
+func (h *rpcMethodHandler) handleCancelRequest(req *jsonrpc2.Request) {
+	var params rpc.CancelRequestParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.log.Debug("invalid cancel request", "error", err)
+		return
+	}
+	if !h.state.cancelRequest(params.ID) {
+		h.log.Debug("cancel for a request not running", "id", params.ID)
+	}
+}
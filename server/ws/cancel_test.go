@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// fakeSlowGitLog puts a git on PATH whose log never finishes, touching
+// started first; other subcommands run the real git.
+func fakeSlowGitLog(t *testing.T) (started string) {
+	t.Helper()
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not installed")
+	}
+	bin := t.TempDir()
+	started = filepath.Join(bin, "started")
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = log ]; then touch '" + started + "'; exec sleep 30; fi\n" +
+		"exec '" + realGit + "' \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "git"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return started
+}
+
+func (e *testEnv) send(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if err := e.conn.Write(e.ctx, websocket.MessageText, data); err != nil {
+		e.t.Fatalf("failed to send: %v", err)
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	started := fakeSlowGitLog(t)
+	env := newTestEnv(t, &mockAgent{})
+
+	id := env.nextID()
+	env.send(rpcRequest{JSONRPC: "2.0", ID: id, Method: "git.log", Params: rpc.GitLogParams{}})
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("git log did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A cancel for an unknown request is ignored.
+	env.send(map[string]any{"jsonrpc": "2.0", "method": cancelMethod, "params": rpc.CancelRequestParams{ID: jsonrpc2.ID{Num: 999}}})
+	env.send(map[string]any{"jsonrpc": "2.0", "method": cancelMethod, "params": rpc.CancelRequestParams{ID: jsonrpc2.ID{Num: uint64(id)}}})
+
+	for {
+		_, data, err := env.conn.Read(env.ctx)
+		if err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		var resp rpcResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.ID != id {
+			continue
+		}
+		if resp.Error == nil || resp.Error.Code != rpc.CodeRequestCancelled {
+			t.Fatalf("response = %+v, want request cancelled", resp)
+		}
+		break
+	}
+
+	// The connection keeps working.
+	if resp := env.call("command.list", nil); resp.Error != nil {
+		t.Errorf("command.list after cancel: %s", resp.Error.Message)
+	}
+}
@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/worktree"
+)
+
+const (
+	connPingInterval = 30 * time.Second
+	connPingTimeout  = 10 * time.Second
+
+	// staleConnAfter is how long a pingable connection may go without a
+	// request or pong before it is closed and its worktree references
+	// released. A few missed pings, so one slow network blip is not enough.
+	staleConnAfter = 2 * time.Minute
+
+	refReapInterval = time.Minute
+)
+
+func (s *rpcConnState) markSeen(now time.Time) {
+	s.lastSeen.Store(now.UnixNano())
+}
+
+func (s *rpcConnState) seenAt() time.Time {
+	return time.Unix(0, s.lastSeen.Load())
+}
+
+// registerConn tracks a connection so worktree references can be traced
+// back to it.
+func (h *RPCHandler) registerConn(s *rpcConnState) {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+	h.conns[s.connID] = s
+}
+
+func (h *RPCHandler) unregisterConn(connID string) {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+	delete(h.conns, connID)
+}
+
+func (h *RPCHandler) lookupConn(connID string) (*rpcConnState, bool) {
+	h.connsMu.Lock()
+	defer h.connsMu.Unlock()
+	s, ok := h.conns[connID]
+	return s, ok
+}
+
+// pingLoop keeps lastSeen fresh for a client that is connected but idle.
+func (s *rpcConnState) pingLoop(pinger Pinger, done <-chan struct{}) {
+	ticker := time.NewTicker(connPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), connPingTimeout)
+			err := pinger.Ping(ctx)
+			cancel()
+			if err == nil {
+				s.markSeen(time.Now())
+			}
+		}
+	}
+}
+
+// runRefReaper periodically releases worktree references whose holder will
+// never release them.
+func (h *RPCHandler) runRefReaper(ctx context.Context) {
+	ticker := time.NewTicker(refReapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.reapStaleRefs(now)
+		}
+	}
+}
+
+// reapStaleRefs force-releases references held by connections that are
+// gone (their cleanup never ran) or silent for staleConnAfter. A silent
+// connection is closed first so it cannot keep using the worktree.
+func (h *RPCHandler) reapStaleRefs(now time.Time) {
+	for _, refs := range h.worktreeManager.Refs() {
+		for _, holder := range refs.Holders {
+			if holder.Holder == worktree.InternalHolder {
+				continue
+			}
+			state, live := h.lookupConn(holder.Holder)
+			if live && (!state.pingable || now.Sub(state.seenAt()) < staleConnAfter) {
+				continue
+			}
+			if live {
+				state.log.Warn("closing stale connection", "lastSeen", state.seenAt())
+				state.close()
+			}
+			h.worktreeManager.ForceRelease(refs.Worktree, holder.Holder)
+		}
+	}
+}
+
+// worktreeRefs converts the manager's references, adding what is known
+// about each holder's connection.
+func (h *rpcMethodHandler) worktreeRefs() []rpc.WorktreeRefs {
+	all := h.worktreeManager.Refs()
+	result := make([]rpc.WorktreeRefs, 0, len(all))
+	for _, refs := range all {
+		r := rpc.WorktreeRefs{
+			Worktree:  refs.Worktree,
+			RefCount:  refs.RefCount,
+			Processes: refs.Processes,
+			Holders:   make([]rpc.WorktreeRefHolder, 0, len(refs.Holders)),
+		}
+		for _, holder := range refs.Holders {
+			rh := rpc.WorktreeRefHolder{
+				Holder:     holder.Holder,
+				Count:      holder.Count,
+				AcquiredAt: holder.AcquiredAt,
+				Self:       holder.Holder == h.state.connID,
+			}
+			if state, ok := h.lookupConn(holder.Holder); ok {
+				seen := state.seenAt()
+				rh.Connected = true
+				rh.LastSeen = &seen
+			}
+			r.Holders = append(r.Holders, rh)
+		}
+		result = append(result, r)
+	}
+	return result
+}
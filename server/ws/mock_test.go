@@ -6,6 +6,7 @@ package ws
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/session"
@@ -77,6 +78,22 @@ type mockAgent struct {
 	startCalls        []startCall
 }
 
+// waitMessages returns the prompts received so far once at least n have
+// arrived. Prompts are recorded by the session goroutine, so they can lag
+// behind the RPC reply that triggered them.
+func (m *mockAgent) waitMessages(n int) []string {
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.Lock()
+		msgs := append([]string(nil), m.messages...)
+		m.mu.Unlock()
+		if len(msgs) >= n || time.Now().After(deadline) {
+			return msgs
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
 func (m *mockAgent) Start(ctx context.Context, opts agent.StartOptions) (agent.Session, error) {
 	m.mu.Lock()
 	m.startCalls = append(m.startCalls, startCall{sessionID: opts.SessionID, resume: opts.Resume, mode: opts.Mode})
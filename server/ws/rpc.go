@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"cmp"
 	"context"
 	"crypto/subtle"
 	"encoding/json"
@@ -8,40 +9,114 @@ import (
 	"log/slog"
 	"net/http"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/coder/websocket"
 	"github.com/google/uuid"
 	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/ci"
 	"github.com/pockode/server/command"
+	"github.com/pockode/server/digest"
+	"github.com/pockode/server/eventlog"
+	"github.com/pockode/server/fsck"
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/i18n"
+	"github.com/pockode/server/issuesync"
 	"github.com/pockode/server/logger"
+	"github.com/pockode/server/mcp"
+	"github.com/pockode/server/outline"
+	"github.com/pockode/server/pairing"
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/snapshot"
+	"github.com/pockode/server/testrun"
 	"github.com/pockode/server/watch"
 	"github.com/pockode/server/work"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// outlineCacheSize bounds file.outline's cache; each entry is one file's
+// symbol list, so a few hundred covers the files a user browses in a session.
+const outlineCacheSize = 256
+
+// commandCatalogTTL bounds how stale command.list's agent listing may get:
+// a custom command added to .claude/commands shows up within this time.
+const commandCatalogTTL = 5 * time.Minute
+
 // RPCHandler handles JSON-RPC 2.0 over WebSocket.
 type RPCHandler struct {
 	token                string
 	version              string
 	devMode              bool
 	commandStore         *command.Store
+	commandCatalog       *command.Catalog
 	worktreeManager      *worktree.Manager
 	settingsStore        *settings.Store
 	settingsWatcher      *watch.SettingsWatcher
 	workStore            work.Store
 	workListWatcher      *watch.WorkListWatcher
 	workDetailWatcher    *watch.WorkDetailWatcher
+	workSubtreeWatcher   *watch.WorkSubtreeWatcher
 	workOps              *work.Operations
 	workStopper          *worktree.WorkStopper
 	agentRoleStore       agentrole.Store
 	agentRoleListWatcher *watch.AgentRoleListWatcher
+	testRunStore         testrun.Store
+	testRunWatcher       *watch.TestRunWatcher
+	ciPoller             *ci.Poller
+	ciStatusWatcher      *watch.CIStatusWatcher
+	gitFetcher           *git.FetchLimiter
+	worktreeSetupWatcher *watch.WorktreeSetupWatcher
+	digestGenerator      *digest.Generator
+	outlineCache         *outline.Cache
+
+	// Starts a drain-then-shutdown; false when one is already under way.
+	drainer func(timeout time.Duration) bool
+
+	// Takes snapshot.* requests and the automatic snapshots before risky
+	// operations; nil disables both.
+	snapshots *snapshot.Manager
+
+	// Records refused writes to protected paths; nil discards.
+	auditLog *audit.Log
+
+	// Serves events.since; nil disables it.
+	eventLog *eventlog.Log
+
+	// Serves fsck.run; nil disables it.
+	fsck *fsck.Checker
+
+	// Serves clientprefs.*; nil disables them.
+	clientPrefs *settings.ClientPrefsStore
+
+	// Serves devices.* and pairing/device-token auth; nil disables them.
+	pairing *pairing.Manager
+
+	// Serves autorun.status; nil disables it.
+	autorunGate *work.AutorunGate
+
+	// Serves autorun.slots; nil disables it.
+	autorunSlots *work.AutorunSlots
+
+	// Serves sync.status; nil disables it.
+	issueSync *issuesync.Syncer
+
+	// Serves work.attachment.*; nil disables them.
+	attachments *work.AttachmentStore
+
+	// Live connections by ID, for tracing worktree references to holders.
+	connsMu       sync.Mutex
+	conns         map[string]*rpcConnState
+	stopRefReaper context.CancelFunc
 }
 
-func NewRPCHandler(token, version string, devMode bool, commandStore *command.Store, worktreeManager *worktree.Manager, settingsStore *settings.Store, workStore work.Store, workOps *work.Operations, workStopper *worktree.WorkStopper, agentRoleStore agentrole.Store) *RPCHandler {
+func NewRPCHandler(token, version string, devMode bool, commandStore *command.Store, worktreeManager *worktree.Manager, settingsStore *settings.Store, workStore work.Store, workOps *work.Operations, workStopper *worktree.WorkStopper, agentRoleStore agentrole.Store, testRunStore testrun.Store, ciPoller *ci.Poller) *RPCHandler {
 	settingsWatcher := watch.NewSettingsWatcher(settingsStore)
 	settingsWatcher.Start()
 
@@ -51,25 +126,143 @@ func NewRPCHandler(token, version string, devMode bool, commandStore *command.St
 	workDetailWatcher := watch.NewWorkDetailWatcher(workStore)
 	workDetailWatcher.Start()
 
+	workSubtreeWatcher := watch.NewWorkSubtreeWatcher(workStore)
+	workSubtreeWatcher.Start()
+
 	agentRoleListWatcher := watch.NewAgentRoleListWatcher(agentRoleStore)
 	agentRoleListWatcher.Start()
 
-	return &RPCHandler{
+	testRunWatcher := watch.NewTestRunWatcher(testRunStore)
+	testRunWatcher.Start()
+
+	ciStatusWatcher := watch.NewCIStatusWatcher(ciPoller)
+	ciStatusWatcher.Start()
+
+	worktreeSetupWatcher := watch.NewWorktreeSetupWatcher(worktreeManager.Registry().Setup())
+	worktreeSetupWatcher.Start()
+
+	reaperCtx, stopRefReaper := context.WithCancel(context.Background())
+	h := &RPCHandler{
 		token:                token,
 		version:              version,
 		devMode:              devMode,
 		commandStore:         commandStore,
+		commandCatalog:       command.NewCatalog(commandCatalogTTL),
 		worktreeManager:      worktreeManager,
 		settingsStore:        settingsStore,
 		settingsWatcher:      settingsWatcher,
 		workStore:            workStore,
 		workListWatcher:      workListWatcher,
 		workDetailWatcher:    workDetailWatcher,
+		workSubtreeWatcher:   workSubtreeWatcher,
 		workOps:              workOps,
 		workStopper:          workStopper,
 		agentRoleStore:       agentRoleStore,
 		agentRoleListWatcher: agentRoleListWatcher,
+		testRunStore:         testRunStore,
+		testRunWatcher:       testRunWatcher,
+		ciPoller:             ciPoller,
+		ciStatusWatcher:      ciStatusWatcher,
+		gitFetcher:           git.NewFetchLimiter(git.DefaultFetchInterval),
+		worktreeSetupWatcher: worktreeSetupWatcher,
+		digestGenerator:      digest.NewGenerator(digest.NewWorktreeSource(worktreeManager), workStore),
+		outlineCache:         outline.NewCache(outlineCacheSize),
+		conns:                make(map[string]*rpcConnState),
+		stopRefReaper:        stopRefReaper,
+	}
+	h.digestGenerator.SetWatchedOnly(func() bool { return settingsStore.Get().NotifyWatchedOnly })
+	go h.runRefReaper(reaperCtx)
+	return h
+}
+
+// SetDrainer sets the function server.drain uses to start a graceful
+// shutdown. A zero timeout selects the server default.
+func (h *RPCHandler) SetDrainer(fn func(timeout time.Duration) bool) {
+	h.drainer = fn
+}
+
+// SetSnapshots enables snapshot.* methods and automatic snapshots.
+func (h *RPCHandler) SetSnapshots(m *snapshot.Manager) {
+	h.snapshots = m
+}
+
+// SetDueReminder forwards work due date reminders to work list subscribers
+// as work.due_soon notifications.
+func (h *RPCHandler) SetDueReminder(r *work.DueReminder) {
+	r.AddOnDueSoonListener(h.workListWatcher)
+}
+
+// SetBudgetGuard forwards work budget stops to work list subscribers as
+// work.budget_exceeded notifications.
+func (h *RPCHandler) SetBudgetGuard(g *work.BudgetGuard) {
+	g.AddOnBudgetExceededListener(h.workListWatcher)
+}
+
+// SetAutorunGate enables autorun.status and forwards autorun pause and
+// resume events to work list subscribers.
+func (h *RPCHandler) SetAutorunGate(g *work.AutorunGate) {
+	h.autorunGate = g
+	g.AddOnChangeListener(h.workListWatcher)
+}
+
+// SetAutorunSlots enables autorun.slots.
+func (h *RPCHandler) SetAutorunSlots(s *work.AutorunSlots) {
+	h.autorunSlots = s
+}
+
+// SetIssueSync enables sync.status.
+func (h *RPCHandler) SetIssueSync(s *issuesync.Syncer) {
+	h.issueSync = s
+}
+
+// SetMCPCallGuard forwards refused MCP tool calls to work list subscribers
+// as mcp.alert notifications.
+func (h *RPCHandler) SetMCPCallGuard(g *mcp.CallGuard) {
+	g.AddOnAlertListener(h.workListWatcher)
+}
+
+// SetAttachmentStore enables work.attachment.* methods.
+func (h *RPCHandler) SetAttachmentStore(s *work.AttachmentStore) {
+	h.attachments = s
+}
+
+// SetAuditLog sets where refused protected path writes are recorded.
+func (h *RPCHandler) SetAuditLog(l *audit.Log) {
+	h.auditLog = l
+}
+
+// SetEventLog enables events.since replay from l.
+func (h *RPCHandler) SetEventLog(l *eventlog.Log) {
+	h.eventLog = l
+}
+
+// SetFsck enables fsck.run with c.
+func (h *RPCHandler) SetFsck(c *fsck.Checker) {
+	h.fsck = c
+}
+
+// SetClientPrefs enables clientprefs.get and clientprefs.set with s.
+func (h *RPCHandler) SetClientPrefs(s *settings.ClientPrefsStore) {
+	h.clientPrefs = s
+}
+
+// SetPairing enables device pairing with m: auth accepts pairing codes and
+// device tokens, and devices.* manage the paired devices.
+func (h *RPCHandler) SetPairing(m *pairing.Manager) {
+	h.pairing = m
+}
+
+// ValidToken reports whether token is the auth token or a paired device's
+// token. HTTP routes authenticate with it.
+func (h *RPCHandler) ValidToken(token string) bool {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1 {
+		return true
+	}
+	if h.pairing == nil {
+		return false
 	}
+	_, ok := h.pairing.Authenticate(token)
+	return ok
 }
 
 // Stop stops the RPC handler and releases resources.
@@ -77,12 +270,20 @@ func (h *RPCHandler) Stop() {
 	h.settingsWatcher.Stop()
 	h.workListWatcher.Stop()
 	h.workDetailWatcher.Stop()
+	h.workSubtreeWatcher.Stop()
 	h.agentRoleListWatcher.Stop()
+	h.testRunWatcher.Stop()
+	h.ciStatusWatcher.Stop()
+	h.worktreeSetupWatcher.Stop()
+	h.stopRefReaper()
 }
 
 func (h *RPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		InsecureSkipVerify: h.devMode,
+		// No context takeover keeps per-connection memory flat; the win we
+		// need is on large diffs and file contents, which compress well alone.
+		CompressionMode: websocket.CompressionNoContextTakeover,
 	})
 	if err != nil {
 		slog.Error("failed to accept websocket", "error", err)
@@ -93,6 +294,9 @@ func (h *RPCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *RPCHandler) handleConnection(ctx context.Context, wsConn *websocket.Conn) {
+	// The library default (32 KiB) is too small for file.write and
+	// work.attachment.upload; match the bound on inflated gzip frames.
+	wsConn.SetReadLimit(maxInflatedSize)
 	stream := NewWebSocketStream(wsConn)
 	connID := uuid.Must(uuid.NewV7()).String()
 	h.HandleStream(ctx, stream, connID)
@@ -108,9 +312,12 @@ func (h *RPCHandler) HandleStream(ctx context.Context, stream jsonrpc2.ObjectStr
 	log := slog.With("connId", connID)
 	log.Info("new connection")
 
+	batched := newBatchStream(stream)
 	state := &rpcConnState{
 		connID: connID,
 		log:    log,
+		stream: stream,
+		batch:  batched,
 		// worktree is set after auth
 	}
 
@@ -121,11 +328,19 @@ func (h *RPCHandler) HandleStream(ctx context.Context, stream jsonrpc2.ObjectStr
 		authenticated: false,
 	}
 
-	rpcConn := jsonrpc2.NewConn(ctx, stream, jsonrpc2.AsyncHandler(handler))
+	rpcConn := jsonrpc2.NewConn(ctx, batched, jsonrpc2.AsyncHandler(handler))
 	state.setConn(rpcConn)
+	state.markSeen(time.Now())
+	if pinger, ok := stream.(Pinger); ok {
+		state.pingable = true
+		go state.pingLoop(pinger, rpcConn.DisconnectNotify())
+	}
+	h.registerConn(state)
 
 	<-rpcConn.DisconnectNotify()
 
+	state.cancelAllRequests()
+	h.unregisterConn(connID)
 	state.cleanup(h.worktreeManager)
 	log.Info("connection closed")
 }
@@ -134,28 +349,76 @@ func (h *RPCHandler) HandleStream(ctx context.Context, stream jsonrpc2.ObjectStr
 type rpcConnState struct {
 	mu            sync.Mutex
 	connID        string
+	stream        jsonrpc2.ObjectStream
+	batch         *batchStream // wraps stream; owns batch request handling
 	conn          *jsonrpc2.Conn
 	notifier      *JSONRPCNotifier
 	log           *slog.Logger
-	worktree      *worktree.Worktree       // set after auth
-	subscriptions map[string]watch.Watcher // subID → watcher for cleanup
+	worktree      *worktree.Worktree            // set after auth
+	locale        i18n.Locale                   // requested at auth; empty = settings locale
+	deviceID      string                        // paired device authenticated as; empty = auth token
+	attached      map[string]*worktree.Worktree // worktree.attach'ed, by name; excludes worktree
+	subscriptions map[string]watch.Watcher      // subID → watcher for cleanup
+	resubscribers map[string]resubscriber       // subID → how to redo it; worktree watchers only
+	pingable      bool                          // stream is a Pinger; set before registration
+	lastSeen      atomic.Int64                  // unix nanos of the last request or pong
+
+	inflightMu sync.Mutex
+	inflight   map[jsonrpc2.ID]*inflightRequest // running requests, for $/cancelRequest
+}
+
+// close disconnects the client; cleanup then runs as for any disconnect.
+// It does not wait: the close handshake waits on a peer that may never
+// answer, or that is blocked on the caller.
+func (s *rpcConnState) close() {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	go func() {
+		if err := conn.Close(); err != nil {
+			s.log.Debug("failed to close connection", "error", err)
+		}
+	}()
 }
 
 func (s *rpcConnState) getConnID() string {
 	return s.connID
 }
 
+func (s *rpcConnState) getLocale() i18n.Locale {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.locale
+}
+
 func (s *rpcConnState) getWorktree() *worktree.Worktree {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	return s.worktree
 }
 
+// targetWorktree resolves a worktree-level request's "worktree" param: the
+// bound worktree when name is nil or names it, otherwise an attached one.
+func (s *rpcConnState) targetWorktree(name *string) (*worktree.Worktree, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.worktree != nil && (name == nil || *name == s.worktree.Name) {
+		return s.worktree, true
+	}
+	if name == nil {
+		return nil, false
+	}
+	wt, ok := s.attached[*name]
+	return wt, ok
+}
+
 func (s *rpcConnState) setConn(conn *jsonrpc2.Conn) {
 	s.mu.Lock()
 	s.conn = conn
 	s.notifier = NewJSONRPCNotifier(conn)
 	s.subscriptions = make(map[string]watch.Watcher)
+	s.resubscribers = make(map[string]resubscriber)
+	s.attached = make(map[string]*worktree.Worktree)
 	s.mu.Unlock()
 }
 
@@ -171,17 +434,35 @@ func (s *rpcConnState) trackSubscription(id string, watcher watch.Watcher) {
 	s.subscriptions[id] = watcher
 }
 
+// trackWorktreeSubscription is trackSubscription for a worktree watcher,
+// recording how worktree.switch can re-establish it on another worktree.
+func (s *rpcConnState) trackWorktreeSubscription(id string, watcher watch.Watcher, method string, subscribe func(*worktree.Worktree) (any, *rpcError)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[id] = watcher
+	s.resubscribers[id] = resubscriber{prevID: id, method: method, subscribe: subscribe}
+}
+
 func (s *rpcConnState) untrackSubscription(id string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.subscriptions, id)
+	delete(s.resubscribers, id)
+}
+
+// resubscriber re-establishes a worktree subscription on another worktree.
+type resubscriber struct {
+	prevID    string
+	method    string // the subscribe method, e.g. "fs.subscribe"
+	subscribe func(*worktree.Worktree) (any, *rpcError)
 }
 
 // unsubscribeWorktreeWatchers removes and unsubscribes all subscriptions
-// belonging to watchers of the given worktree.
-func (s *rpcConnState) unsubscribeWorktreeWatchers(wt *worktree.Worktree) {
+// belonging to watchers of the given worktree. It returns their
+// resubscribers, ordered by method and then ID.
+func (s *rpcConnState) unsubscribeWorktreeWatchers(wt *worktree.Worktree) []resubscriber {
 	if wt == nil {
-		return
+		return nil
 	}
 
 	wtWatchers := make(map[watch.Watcher]struct{}, len(wt.Watchers()))
@@ -192,12 +473,21 @@ func (s *rpcConnState) unsubscribeWorktreeWatchers(wt *worktree.Worktree) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	var removed []resubscriber
 	for id, watcher := range s.subscriptions {
 		if _, belongs := wtWatchers[watcher]; belongs {
 			watcher.Unsubscribe(id)
 			delete(s.subscriptions, id)
+			if r, ok := s.resubscribers[id]; ok {
+				removed = append(removed, r)
+				delete(s.resubscribers, id)
+			}
 		}
 	}
+	slices.SortFunc(removed, func(a, b resubscriber) int {
+		return cmp.Or(strings.Compare(a.method, b.method), strings.Compare(a.prevID, b.prevID))
+	})
+	return removed
 }
 
 func (s *rpcConnState) cleanup(worktreeManager *worktree.Manager) {
@@ -209,13 +499,20 @@ func (s *rpcConnState) cleanup(worktreeManager *worktree.Manager) {
 		watcher.Unsubscribe(id)
 	}
 	s.subscriptions = nil
+	s.resubscribers = nil
+
+	for name, wt := range s.attached {
+		wt.Unsubscribe(s.notifier)
+		worktreeManager.ReleaseFor(wt, s.connID)
+		delete(s.attached, name)
+	}
 
 	if s.worktree == nil {
 		return // Not authenticated yet (e.g., connection closed before auth)
 	}
 
 	s.worktree.Unsubscribe(s.notifier)
-	worktreeManager.Release(s.worktree)
+	worktreeManager.ReleaseFor(s.worktree, s.connID)
 
 	// Reset state (safe even for connection close - no harm in resetting)
 	s.worktree = nil
@@ -233,15 +530,21 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	defer func() {
 		if r := recover(); r != nil {
 			logger.LogPanic(r, "rpc handler panic", "method", req.Method, "connId", h.state.connID)
+			// Reply so the client (and a batch waiting on this request) is not
+			// left hanging.
+			if !req.Notif {
+				h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "internal error")
+			}
 		}
 	}()
 
 	h.log.Debug("received request", "method", req.Method, "id", req.ID)
+	h.state.markSeen(time.Now())
 
 	// Auth must be the first request
 	if !h.isAuthenticated() {
 		if req.Method != "auth" {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "first request must be auth")
+			h.replyError(ctx, conn, req.ID, rpc.ErrUnauthorized, "first request must be auth")
 			conn.Close()
 			return
 		}
@@ -249,6 +552,20 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 		return
 	}
 
+	if req.Method == cancelMethod {
+		h.handleCancelRequest(req)
+		return
+	}
+	if !req.Notif {
+		var done func()
+		ctx, done = h.state.trackRequest(ctx, req.ID)
+		defer done()
+	}
+
+	if (strings.HasPrefix(req.Method, "work.") || req.Method == "worktree.create" || req.Method == "git.log") && h.workStore != nil {
+		h.resolveWorkShortIDs(req)
+	}
+
 	// Methods that don't require worktree (manager-level operations)
 	switch req.Method {
 	case "worktree.list":
@@ -260,9 +577,27 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	case "worktree.delete":
 		h.handleWorktreeDelete(ctx, conn, req)
 		return
+	case "worktree.setup.status.subscribe":
+		h.handleWorktreeSetupSubscribe(ctx, conn, req)
+		return
+	case "worktree.setup.status.unsubscribe":
+		h.handleWatcherUnsubscribe(ctx, conn, req, h.worktreeSetupWatcher, "worktree setup")
+		return
 	case "worktree.switch":
 		h.handleWorktreeSwitch(ctx, conn, req)
 		return
+	case "worktree.attach":
+		h.handleWorktreeAttach(ctx, conn, req)
+		return
+	case "worktree.detach":
+		h.handleWorktreeDetach(ctx, conn, req)
+		return
+	case "worktree.refs":
+		h.handleWorktreeRefs(ctx, conn, req)
+		return
+	case "worktree.force_release":
+		h.handleWorktreeForceRelease(ctx, conn, req)
+		return
 	case "worktree.subscribe":
 		h.handleWorktreeSubscribe(ctx, conn, req)
 		return
@@ -281,10 +616,69 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	case "settings.update":
 		h.handleSettingsUpdate(ctx, conn, req)
 		return
+	case "clientprefs.get":
+		h.handleClientPrefsGet(ctx, conn, req)
+		return
+	case "devices.pair":
+		h.handleDevicesPair(ctx, conn, req)
+		return
+	case "devices.list":
+		h.handleDevicesList(ctx, conn, req)
+		return
+	case "devices.revoke":
+		h.handleDevicesRevoke(ctx, conn, req)
+		return
+	case "clientprefs.set":
+		h.handleClientPrefsSet(ctx, conn, req)
+		return
+	case "events.since":
+		h.handleEventsSince(ctx, conn, req)
+		return
+	case "autorun.status":
+		h.handleAutorunStatus(ctx, conn, req)
+		return
+	case "autorun.slots":
+		h.handleAutorunSlots(ctx, conn, req)
+		return
+	case "sync.status":
+		h.handleSyncStatus(ctx, conn, req)
+		return
+	// agent namespace (app-level)
+	case "agent.healthcheck":
+		h.handleAgentHealthcheck(ctx, conn, req)
+		return
+	// server namespace (app-level)
+	case "server.drain":
+		h.handleServerDrain(ctx, conn, req)
+		return
+	case "server.stats":
+		h.handleServerStats(ctx, conn, req)
+		return
+	// snapshot namespace (app-level)
+	case "snapshot.create":
+		h.handleSnapshotCreate(ctx, conn, req)
+		return
+	case "snapshot.list":
+		h.handleSnapshotList(ctx, conn, req)
+		return
+	case "snapshot.restore":
+		h.handleSnapshotRestore(ctx, conn, req)
+		return
+	// fsck namespace (app-level)
+	case "fsck.run":
+		h.handleFsckRun(ctx, conn, req)
+		return
+	// digest namespace (app-level)
+	case "digest.preview":
+		h.handleDigestPreview(ctx, conn, req)
+		return
 	// work namespace (app-level)
 	case "work.create":
 		h.handleWorkCreate(ctx, conn, req)
 		return
+	case "work.bulk_create":
+		h.handleWorkBulkCreate(ctx, conn, req)
+		return
 	case "work.update":
 		h.handleWorkUpdate(ctx, conn, req)
 		return
@@ -300,9 +694,62 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	case "work.reopen":
 		h.handleWorkReopen(ctx, conn, req)
 		return
+	case "work.reset":
+		h.handleWorkReset(ctx, conn, req)
+		return
+	case "work.cancel":
+		h.handleWorkCancel(ctx, conn, req)
+		return
+	case "work.bulk":
+		h.handleWorkBulk(ctx, conn, req)
+		return
+	case "work.watch":
+		h.handleWorkWatch(ctx, conn, req)
+	case "work.approve_close":
+		h.handleWorkApproveClose(ctx, conn, req)
+		return
+	case "work.report":
+		h.handleWorkReport(ctx, conn, req)
+		return
+	case "work.plan.approve":
+		h.handleWorkPlanApprove(ctx, conn, req)
+		return
+	case "work.plan.reject":
+		h.handleWorkPlanReject(ctx, conn, req)
+		return
+	case "work.list":
+		h.handleWorkList(ctx, conn, req)
+		return
+	case "work.board":
+		h.handleWorkBoard(ctx, conn, req)
+		return
+	case "work.search":
+		h.handleWorkSearch(ctx, conn, req)
+		return
+	case "work.next":
+		h.handleWorkNext(ctx, conn, req)
+		return
+	case "work.files":
+		h.handleWorkFiles(ctx, conn, req)
+		return
+	case "work.stats":
+		h.handleWorkStats(ctx, conn, req)
+		return
 	case "work.comment.list":
 		h.handleWorkCommentList(ctx, conn, req)
 		return
+	case "work.attachment.upload":
+		h.handleWorkAttachmentUpload(ctx, conn, req)
+		return
+	case "work.attachment.list":
+		h.handleWorkAttachmentList(ctx, conn, req)
+		return
+	case "work.attachment.get":
+		h.handleWorkAttachmentGet(ctx, conn, req)
+		return
+	case "work.attachment.delete":
+		h.handleWorkAttachmentDelete(ctx, conn, req)
+		return
 	case "work.comment.update":
 		h.handleWorkCommentUpdate(ctx, conn, req)
 		return
@@ -312,6 +759,12 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	case "work.detail.unsubscribe":
 		h.handleWatcherUnsubscribe(ctx, conn, req, h.workDetailWatcher, "work detail")
 		return
+	case "work.subscribe":
+		h.handleWorkSubscribe(ctx, conn, req)
+		return
+	case "work.unsubscribe":
+		h.handleWatcherUnsubscribe(ctx, conn, req, h.workSubtreeWatcher, "work subtree")
+		return
 	case "work.list.subscribe":
 		h.handleWorkListSubscribe(ctx, conn, req)
 		return
@@ -328,6 +781,12 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	case "agent_role.delete":
 		h.handleAgentRoleDelete(ctx, conn, req)
 		return
+	case "agent_role.export":
+		h.handleAgentRoleExport(ctx, conn, req)
+		return
+	case "agent_role.import":
+		h.handleAgentRoleImport(ctx, conn, req)
+		return
 	case "agent_role.reset_defaults":
 		h.handleAgentRoleResetDefaults(ctx, conn, req)
 		return
@@ -337,12 +796,32 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	case "agent_role.list.unsubscribe":
 		h.handleWatcherUnsubscribe(ctx, conn, req, h.agentRoleListWatcher, "agent role list")
 		return
+	case "ci.status.subscribe":
+		h.handleCIStatusSubscribe(ctx, conn, req)
+		return
+	case "ci.status.unsubscribe":
+		h.handleWatcherUnsubscribe(ctx, conn, req, h.ciStatusWatcher, "ci status")
+		return
+	case "testrun.list":
+		h.handleTestRunList(ctx, conn, req)
+		return
+	case "testrun.subscribe":
+		h.handleTestRunSubscribe(ctx, conn, req)
+		return
+	case "testrun.unsubscribe":
+		h.handleWatcherUnsubscribe(ctx, conn, req, h.testRunWatcher, "test run")
+		return
 	}
 
-	// All other methods require a valid worktree
-	wt := h.state.getWorktree()
-	if wt == nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "no worktree bound")
+	// All other methods require a valid worktree: the bound one, or an
+	// attached one selected by the "worktree" param.
+	if h.state.getWorktree() == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "no worktree bound")
+		return
+	}
+	wt, ok := h.state.targetWorktree(requestWorktree(req))
+	if !ok {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidTransition, "worktree not attached")
 		return
 	}
 
@@ -351,6 +830,10 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 	// chat namespace
 	case "chat.messages.subscribe":
 		h.handleChatMessagesSubscribe(ctx, conn, req, wt)
+	case "chat.messages.page":
+		h.handleChatMessagesPage(ctx, conn, req, wt)
+	case "chat.messages.turn":
+		h.handleChatMessagesTurn(ctx, conn, req, wt)
 	case "chat.messages.unsubscribe":
 		h.handleWatcherUnsubscribe(ctx, conn, req, wt.ChatMessagesWatcher, "chat-messages")
 	case "chat.message":
@@ -361,11 +844,21 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 		h.handlePermissionResponse(ctx, conn, req, wt)
 	case "chat.question_response":
 		h.handleQuestionResponse(ctx, conn, req, wt)
+	case "chat.toolresult.get":
+		h.handleToolResultGet(ctx, conn, req, wt)
+	case "chat.quick":
+		h.handleChatQuick(ctx, conn, req, wt)
+	case "chat.quick.save":
+		h.handleChatQuickSave(ctx, conn, req, wt)
 	// session namespace
 	case "session.create":
 		h.handleSessionCreate(ctx, conn, req, wt)
 	case "session.delete":
 		h.handleSessionDelete(ctx, conn, req, wt)
+	case "session.trash.list":
+		h.handleSessionTrashList(ctx, conn, req, wt)
+	case "session.restore":
+		h.handleSessionRestore(ctx, conn, req, wt)
 	case "session.update_title":
 		h.handleSessionUpdateTitle(ctx, conn, req, wt)
 	case "session.set_agent_type":
@@ -374,10 +867,27 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 		h.handleSessionSetMode(ctx, conn, req, wt)
 	case "session.mark_read":
 		h.handleSessionMarkRead(ctx, conn, req, wt)
+	case "session.toolcalls":
+		h.handleSessionToolCalls(ctx, conn, req, wt)
 	case "session.list.subscribe":
 		h.handleSessionListSubscribe(ctx, conn, req, wt)
 	case "session.list.unsubscribe":
 		h.handleWatcherUnsubscribe(ctx, conn, req, wt.SessionListWatcher, "session list")
+	// process namespace
+	case "process.keepalive":
+		h.handleProcessKeepAlive(ctx, conn, req, wt)
+	case "process.recover":
+		h.handleProcessRecover(ctx, conn, req, wt)
+	// mcp_servers namespace
+	case "mcp_servers.get":
+		h.handleMCPServersGet(ctx, conn, req, wt)
+	case "mcp_servers.set":
+		h.handleMCPServersSet(ctx, conn, req, wt)
+	// quick_replies namespace
+	case "quick_replies.list":
+		h.handleQuickRepliesList(ctx, conn, req, wt)
+	case "quick_replies.set":
+		h.handleQuickRepliesSet(ctx, conn, req, wt)
 	// file namespace
 	case "file.get":
 		h.handleFileGet(ctx, conn, req, wt)
@@ -385,6 +895,8 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 		h.handleFileWrite(ctx, conn, req, wt)
 	case "file.delete":
 		h.handleFileDelete(ctx, conn, req, wt)
+	case "file.outline":
+		h.handleFileOutline(ctx, conn, req, wt)
 	// git namespace
 	case "git.status":
 		h.handleGitStatus(ctx, conn, req, wt)
@@ -398,6 +910,8 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 		h.handleWatcherUnsubscribe(ctx, conn, req, wt.GitDiffWatcher, "git-diff")
 	case "git.add":
 		h.handleGitAdd(ctx, conn, req, wt)
+	case "git.add.hunks":
+		h.handleGitAddHunks(ctx, conn, req, wt)
 	case "git.reset":
 		h.handleGitReset(ctx, conn, req, wt)
 	case "git.log":
@@ -406,13 +920,20 @@ func (h *rpcMethodHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req
 		h.handleGitShow(ctx, conn, req, wt)
 	case "git.show.diff":
 		h.handleGitShowDiff(ctx, conn, req, wt)
+	case "git.fetch":
+		h.handleGitFetch(ctx, conn, req, wt)
 	// fs namespace
 	case "fs.subscribe":
 		h.handleFSSubscribe(ctx, conn, req, wt)
 	case "fs.unsubscribe":
 		h.handleWatcherUnsubscribe(ctx, conn, req, wt.FSWatcher, "fs")
+	// workspace namespace
+	case "workspace.subscribe":
+		h.handleWorkspaceSubscribe(ctx, conn, req, wt)
+	case "workspace.unsubscribe":
+		h.handleWatcherUnsubscribe(ctx, conn, req, wt.WorkspaceWatcher, "workspace")
 	default:
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeMethodNotFound, "method not found: "+req.Method)
+		h.replyError(ctx, conn, req.ID, rpc.ErrMethodNotFound, "method not found: "+req.Method)
 	}
 }
 
@@ -431,34 +952,37 @@ func (h *rpcMethodHandler) setAuthenticated() {
 func (h *rpcMethodHandler) handleAuth(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.AuthParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		conn.Close()
 		return
 	}
 
-	if subtle.ConstantTimeCompare([]byte(params.Token), []byte(h.token)) != 1 {
-		h.log.Warn("invalid auth token")
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "invalid token")
+	device, deviceToken, ok := h.authenticate(params)
+	if !ok {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnauthorized, "invalid token")
 		conn.Close()
 		return
 	}
 
-	wt, err := h.worktreeManager.Get(params.Worktree)
+	wt, err := h.worktreeManager.GetFor(params.Worktree, h.state.connID)
 	if err != nil {
 		h.log.Warn("worktree not found", "worktree", params.Worktree, "error", err)
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "worktree not found")
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "worktree not found")
 		conn.Close()
 		return
 	}
 
+	locale := i18n.Match(params.Locale)
 	h.state.mu.Lock()
 	h.state.worktree = wt
+	h.state.locale = locale
+	h.state.deviceID = device.ID
 	h.state.mu.Unlock()
 
 	wt.Subscribe(h.state.getNotifier())
 
 	h.setAuthenticated()
-	h.log.Info("authenticated", "worktree", wt.Name, "workDir", wt.WorkDir)
+	h.log.Info("authenticated", "worktree", wt.Name, "workDir", wt.WorkDir, "deviceId", device.ID)
 
 	title := filepath.Base(h.worktreeManager.Registry().MainDir())
 	result := rpc.AuthResult{
@@ -466,22 +990,132 @@ func (h *rpcMethodHandler) handleAuth(ctx context.Context, conn *jsonrpc2.Conn,
 		Title:        title,
 		WorkDir:      wt.WorkDir,
 		WorktreeName: wt.Name,
+		Locale:       locale,
+		DeviceID:     device.ID,
+		DeviceToken:  deviceToken,
+	}
+	switcher, canSwitch := h.state.stream.(EncodingSwitcher)
+	if params.Encoding == EncodingGzip && canSwitch {
+		result.Encoding = params.Encoding
 	}
 	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		h.log.Error("failed to send auth response", "error", err)
+		return
+	}
+	// Switch only after the reply is out so the client reads it as plain
+	// JSON before it knows which encoding was accepted. A batched reply is
+	// held back until its batch completes, so wait for that too.
+	if result.Encoding != "" {
+		h.state.batch.afterFlush(func() { switcher.SetEncoding(result.Encoding) })
+	}
+}
+
+// authenticate checks the auth params: the auth token, a paired device's
+// token, or a pairing code, which pairs a new device and returns its token.
+// device is zero for the auth token.
+func (h *rpcMethodHandler) authenticate(params rpc.AuthParams) (device pairing.Device, deviceToken string, ok bool) {
+	if params.PairingCode != "" {
+		if h.pairing == nil {
+			h.log.Warn("pairing code used with pairing disabled")
+			return pairing.Device{}, "", false
+		}
+		device, token, err := h.pairing.Pair(params.PairingCode, params.DeviceName)
+		if err != nil {
+			h.log.Warn("pairing failed", "error", err)
+			return pairing.Device{}, "", false
+		}
+		h.log.Info("device paired", "deviceId", device.ID, "name", device.Name)
+		return device, token, true
+	}
+	if subtle.ConstantTimeCompare([]byte(params.Token), []byte(h.token)) == 1 {
+		return pairing.Device{}, "", true
+	}
+	if h.pairing != nil {
+		if device, found := h.pairing.Authenticate(params.Token); found {
+			return device, "", true
+		}
 	}
+	h.log.Warn("invalid auth token")
+	return pairing.Device{}, "", false
 }
 
-func (h *rpcMethodHandler) replyError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, code int64, message string) {
+// replyError sends message translated into the connection's locale, with
+// reason as the error data.
+func (h *rpcMethodHandler) replyError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, reason rpc.ErrorReason, message string) {
+	h.replyErrorData(ctx, conn, id, reason, message, nil)
+}
+
+// replyFieldError is replyError for a problem with one param, which the
+// error data names so clients can point at the offending input.
+func (h *rpcMethodHandler) replyFieldError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, reason rpc.ErrorReason, field, message string) {
+	h.replyErrorData(ctx, conn, id, reason, message, []settings.FieldError{{Field: field, Message: message}})
+}
+
+// replyErrorData sends an error whose message is the first field error's
+// when fieldErrs is non-empty; all messages are translated.
+func (h *rpcMethodHandler) replyErrorData(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, reason rpc.ErrorReason, message string, fieldErrs []settings.FieldError) {
+	// A cancelled request fails however the handler saw it fail (a killed
+	// git, a context error); report the cancellation itself.
+	if requestCancelled(ctx) {
+		reason, message, fieldErrs = rpc.ErrCancelled, "request cancelled", nil
+	}
+	locale := h.locale()
+	for i := range fieldErrs {
+		fieldErrs[i].Message = i18n.T(locale, fieldErrs[i].Message)
+	}
 	err := &jsonrpc2.Error{
-		Code:    code,
-		Message: message,
+		Code:    reason.Code(),
+		Message: i18n.T(locale, message),
+	}
+	if len(fieldErrs) > 0 {
+		err.Message = fieldErrs[0].Message
 	}
+	err.SetError(rpc.ErrorData{Reason: reason, Errors: fieldErrs})
 	if replyErr := conn.ReplyWithError(ctx, id, err); replyErr != nil {
 		h.log.Error("failed to send error response", "error", replyErr)
 	}
 }
 
+// rpcError is a reply error held as a value, for handler logic that
+// worktree.switch also runs outside of the request it was written for.
+type rpcError struct {
+	reason  rpc.ErrorReason
+	field   string // the offending param, if the error is about one
+	message string
+}
+
+func (h *rpcMethodHandler) replyRPCError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, e *rpcError) {
+	if e.field != "" {
+		h.replyFieldError(ctx, conn, id, e.reason, e.field, e.message)
+		return
+	}
+	h.replyError(ctx, conn, id, e.reason, e.message)
+}
+
+// locale is the language requested at auth, else the locale setting.
+func (h *rpcMethodHandler) locale() i18n.Locale {
+	return h.state.getLocale().Or(h.settingsStore.Get().EffectiveLocale())
+}
+
+// resolveWorkShortIDs rewrites short IDs ("PCK-12") in a work.* request to
+// full work IDs, so handlers only deal with one form.
+func (h *rpcMethodHandler) resolveWorkShortIDs(req *jsonrpc2.Request) {
+	if req.Params == nil {
+		return
+	}
+	works, err := h.workStore.List()
+	if err != nil {
+		h.log.Warn("failed to list works for short ID resolution", "error", err)
+		return
+	}
+	params, err := work.ResolveShortIDs(*req.Params, works)
+	if err != nil {
+		h.log.Warn("failed to resolve short IDs", "method", req.Method, "error", err)
+		return
+	}
+	req.Params = &params
+}
+
 func unmarshalParams(req *jsonrpc2.Request, v interface{}) error {
 	if req.Params == nil {
 		return errors.New("params required")
@@ -502,11 +1136,11 @@ func (h *rpcMethodHandler) handleWatcherUnsubscribe(
 ) {
 	var params unsubscribeParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 	if params.ID == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "id is required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "id", "id is required")
 		return
 	}
 
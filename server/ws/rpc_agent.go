@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/session"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleAgentHealthcheck diagnoses an agent's setup up front, so a missing
+// CLI or login shows up before the first work.start fails mid-flow.
+func (h *rpcMethodHandler) handleAgentHealthcheck(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.AgentHealthcheckParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+	agentType := h.resolveAgentType(params.AgentType)
+	if !agentType.IsValid() {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "agent_type", "invalid agent_type")
+		return
+	}
+
+	a, err := h.worktreeManager.Agents().Get(agentType)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+		return
+	}
+	checker, ok := a.(agent.HealthChecker)
+	if !ok {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "health check not supported for agent "+string(agentType))
+		return
+	}
+
+	report := checker.HealthCheck(ctx)
+	if !report.Healthy {
+		h.log.Warn("agent health check failed", "agent", agentType, "checks", report.Checks)
+	}
+	if err := conn.Reply(ctx, req.ID, rpc.AgentHealthcheckResult{AgentType: agentType, HealthReport: report}); err != nil {
+		h.log.Error("failed to send agent healthcheck response", "error", err)
+	}
+}
+
+// resolveAgentType fills an omitted agent type from the settings default.
+func (h *rpcMethodHandler) resolveAgentType(agentType session.AgentType) session.AgentType {
+	if agentType == "" {
+		agentType = h.settingsStore.Get().DefaultAgentType
+	}
+	if agentType == "" {
+		agentType = session.AgentTypeClaude
+	}
+	return agentType
+}
@@ -7,30 +7,35 @@ import (
 
 	"github.com/pockode/server/agentrole"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/snapshot"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 func (h *rpcMethodHandler) replyAgentRoleError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error, fallbackMsg string) {
 	if errors.Is(err, agentrole.ErrNotFound) {
-		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, "agent role not found")
+		h.replyError(ctx, conn, id, rpc.ErrNotFound, "agent role not found")
 	} else if errors.Is(err, agentrole.ErrInvalidRole) {
-		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, err.Error())
+		h.replyError(ctx, conn, id, rpc.ErrInvalidParams, err.Error())
 	} else {
-		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, fallbackMsg)
+		h.replyError(ctx, conn, id, rpc.ErrInternal, fallbackMsg)
 	}
 }
 
 func (h *rpcMethodHandler) handleAgentRoleCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.AgentRoleCreateParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	role, err := h.agentRoleStore.Create(ctx, agentrole.AgentRole{
-		Name:       params.Name,
-		RolePrompt: params.RolePrompt,
-		Steps:      params.Steps,
+		Name:               params.Name,
+		RolePrompt:         params.RolePrompt,
+		Steps:              params.Steps,
+		IdleTimeoutMinutes: params.IdleTimeoutMinutes,
+		AgentType:          params.AgentType,
+		MCPServers:         params.MCPServers,
+		MaxConcurrent:      params.MaxConcurrent,
 	})
 	if err != nil {
 		h.replyAgentRoleError(ctx, conn, req.ID, err, "failed to create agent role")
@@ -47,14 +52,18 @@ func (h *rpcMethodHandler) handleAgentRoleCreate(ctx context.Context, conn *json
 func (h *rpcMethodHandler) handleAgentRoleUpdate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.AgentRoleUpdateParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	fields := agentrole.UpdateFields{
-		Name:       params.Name,
-		RolePrompt: params.RolePrompt,
-		Steps:      params.Steps,
+		Name:               params.Name,
+		RolePrompt:         params.RolePrompt,
+		Steps:              params.Steps,
+		IdleTimeoutMinutes: params.IdleTimeoutMinutes,
+		AgentType:          params.AgentType,
+		MCPServers:         params.MCPServers,
+		MaxConcurrent:      params.MaxConcurrent,
 	}
 	if err := h.agentRoleStore.Update(ctx, params.ID, fields); err != nil {
 		h.replyAgentRoleError(ctx, conn, req.ID, err, "failed to update agent role")
@@ -71,14 +80,14 @@ func (h *rpcMethodHandler) handleAgentRoleUpdate(ctx context.Context, conn *json
 func (h *rpcMethodHandler) handleAgentRoleDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.AgentRoleDeleteParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	// Referential integrity: check if any work items reference this role
 	works, err := h.workStore.List()
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to check role references")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to check role references")
 		return
 	}
 	var refCount int
@@ -88,7 +97,7 @@ func (h *rpcMethodHandler) handleAgentRoleDelete(ctx context.Context, conn *json
 		}
 	}
 	if refCount > 0 {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams,
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidTransition,
 			fmt.Sprintf("cannot delete: role is referenced by %d work item(s)", refCount))
 		return
 	}
@@ -114,9 +123,11 @@ func (h *rpcMethodHandler) handleAgentRoleDelete(ctx context.Context, conn *json
 }
 
 func (h *rpcMethodHandler) handleAgentRoleResetDefaults(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	h.snapshotBefore(snapshot.LabelResetRoles)
+
 	pmRoleID, err := h.agentRoleStore.ResetDefaults(ctx)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to reset agent roles")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to reset agent roles")
 		return
 	}
 
@@ -134,11 +145,62 @@ func (h *rpcMethodHandler) handleAgentRoleResetDefaults(ctx context.Context, con
 	}
 }
 
+func (h *rpcMethodHandler) handleAgentRoleExport(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.AgentRoleExportParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	roles, err := h.agentRoleStore.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list agent roles")
+		return
+	}
+	pack, err := agentrole.BuildRolePack(roles, params.IDs, params.Name)
+	if err != nil {
+		h.replyAgentRoleError(ctx, conn, req.ID, err, "failed to export agent roles")
+		return
+	}
+
+	h.log.Info("agent roles exported", "count", len(pack.Roles))
+
+	if err := conn.Reply(ctx, req.ID, pack); err != nil {
+		h.log.Error("failed to send agent role export response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleAgentRoleImport(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.AgentRoleImportParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if params.Conflict == "" {
+		params.Conflict = agentrole.ConflictSkip
+	}
+	if !params.DryRun {
+		h.snapshotBefore(snapshot.LabelImport)
+	}
+
+	items, err := h.agentRoleStore.Import(ctx, params.Pack, params.Conflict, params.DryRun)
+	if err != nil {
+		h.replyAgentRoleError(ctx, conn, req.ID, err, "failed to import agent roles")
+		return
+	}
+
+	h.log.Info("agent roles imported", "count", len(items), "conflict", params.Conflict, "dryRun", params.DryRun)
+
+	if err := conn.Reply(ctx, req.ID, rpc.AgentRoleImportResult{DryRun: params.DryRun, Items: items}); err != nil {
+		h.log.Error("failed to send agent role import response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleAgentRoleListSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	notifier := h.state.getNotifier()
 	id, items, err := h.agentRoleListWatcher.Subscribe(notifier)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to subscribe")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to subscribe")
 		return
 	}
 	h.state.trackSubscription(id, h.agentRoleListWatcher)
@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestHandler_AgentRoleExportImport(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	exportResp := env.call("agent_role.export", rpc.AgentRoleExportParams{IDs: []string{env.testRoleID}, Name: "team"})
+	if exportResp.Error != nil {
+		t.Fatalf("export: %s", exportResp.Error.Message)
+	}
+	var pack agentrole.RolePack
+	if err := json.Unmarshal(exportResp.Result, &pack); err != nil {
+		t.Fatalf("unmarshal pack: %v", err)
+	}
+	if pack.Version != agentrole.RolePackVersion || len(pack.Roles) != 1 {
+		t.Fatalf("pack = %+v, want one role", pack)
+	}
+
+	// Re-importing into the same server collides with the exported role.
+	dryResp := env.call("agent_role.import", rpc.AgentRoleImportParams{Pack: pack, Conflict: agentrole.ConflictDuplicate, DryRun: true})
+	if dryResp.Error != nil {
+		t.Fatalf("dry run: %s", dryResp.Error.Message)
+	}
+	var dry rpc.AgentRoleImportResult
+	if err := json.Unmarshal(dryResp.Result, &dry); err != nil {
+		t.Fatalf("unmarshal dry run: %v", err)
+	}
+	if !dry.DryRun || len(dry.Items) != 1 || dry.Items[0].Action != agentrole.ImportDuplicate {
+		t.Fatalf("dry run = %+v, want one duplicate", dry)
+	}
+	before := len(exportAllRoles(t, env).Roles)
+
+	importResp := env.call("agent_role.import", rpc.AgentRoleImportParams{Pack: pack, Conflict: agentrole.ConflictDuplicate})
+	if importResp.Error != nil {
+		t.Fatalf("import: %s", importResp.Error.Message)
+	}
+	if got := len(exportAllRoles(t, env).Roles); got != before+1 {
+		t.Errorf("role count = %d, want %d", got, before+1)
+	}
+
+	// The default policy is skip, which leaves the store untouched.
+	skipResp := env.call("agent_role.import", rpc.AgentRoleImportParams{Pack: pack})
+	var skip rpc.AgentRoleImportResult
+	if err := json.Unmarshal(skipResp.Result, &skip); err != nil || skip.Items[0].Action != agentrole.ImportSkip {
+		t.Errorf("default policy = %+v, %v; want skip", skip, err)
+	}
+}
+
+func TestHandler_AgentRoleImport_Invalid(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	pack := agentrole.RolePack{Version: agentrole.RolePackVersion, Roles: []agentrole.PackRole{{Name: "A"}}}
+
+	tests := []struct {
+		name   string
+		params rpc.AgentRoleImportParams
+	}{
+		{"unknown policy", rpc.AgentRoleImportParams{Pack: pack, Conflict: "merge"}},
+		{"empty pack", rpc.AgentRoleImportParams{Pack: agentrole.RolePack{Version: agentrole.RolePackVersion}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := env.call("agent_role.import", tt.params)
+			if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+				t.Errorf("error = %+v, want InvalidParams", resp.Error)
+			}
+		})
+	}
+
+	resp := env.call("agent_role.export", rpc.AgentRoleExportParams{IDs: []string{"missing"}})
+	if resp.Error == nil {
+		t.Error("export of unknown role succeeded")
+	}
+}
+
+// exportAllRoles exports every role, which doubles as a role listing.
+func exportAllRoles(t *testing.T, env *testEnv) agentrole.RolePack {
+	t.Helper()
+	resp := env.call("agent_role.export", rpc.AgentRoleExportParams{})
+	if resp.Error != nil {
+		t.Fatalf("export: %s", resp.Error.Message)
+	}
+	var pack agentrole.RolePack
+	if err := json.Unmarshal(resp.Result, &pack); err != nil {
+		t.Fatalf("unmarshal pack: %v", err)
+	}
+	return pack
+}
@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/session"
+)
+
+type healthCheckedAgent struct {
+	*mockAgent
+	report agent.HealthReport
+}
+
+func (a healthCheckedAgent) HealthCheck(context.Context) agent.HealthReport {
+	return a.report
+}
+
+func TestHandler_AgentHealthcheck(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	if resp := env.call("agent.healthcheck", nil); resp.Error == nil {
+		t.Error("expected error for agent without health check")
+	}
+	if resp := env.call("agent.healthcheck", rpc.AgentHealthcheckParams{AgentType: "gpt"}); resp.Error == nil {
+		t.Error("expected error for invalid agent type")
+	}
+
+	var report agent.HealthReport
+	report.Add("cli", agent.HealthOK, "/usr/bin/codex")
+	report.Add("auth", agent.HealthFail, "not logged in")
+	env.worktreeManager.Agents().Register(session.AgentTypeCodex, healthCheckedAgent{mockAgent: &mockAgent{}, report: report})
+
+	resp := env.call("agent.healthcheck", rpc.AgentHealthcheckParams{AgentType: session.AgentTypeCodex})
+	if resp.Error != nil {
+		t.Fatalf("agent.healthcheck: %s", resp.Error.Message)
+	}
+	var result rpc.AgentHealthcheckResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if result.AgentType != session.AgentTypeCodex || result.Healthy || len(result.Checks) != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.Checks[1].Name != "auth" || result.Checks[1].Status != agent.HealthFail {
+		t.Errorf("auth check = %+v", result.Checks[1])
+	}
+}
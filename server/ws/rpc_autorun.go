@@ -0,0 +1,62 @@
+package ws
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleAutorunStatus(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.autorunGate == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "autorun limits not enabled")
+		return
+	}
+	if err := conn.Reply(ctx, req.ID, h.autorunGate.Status()); err != nil {
+		h.log.Error("failed to send autorun status response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleAutorunSlots(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.autorunSlots == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "autorun slots not enabled")
+		return
+	}
+	roles, err := h.agentRoleStore.List()
+	if err != nil {
+		h.log.Error("failed to list agent roles", "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list agent roles")
+		return
+	}
+
+	occupancy := h.autorunSlots.Occupancy()
+	slots := []rpc.AutorunSlot{}
+	for _, role := range roles {
+		sessions := occupancy[role.ID]
+		delete(occupancy, role.ID)
+		if role.MaxConcurrent == 0 && len(sessions) == 0 {
+			continue
+		}
+		slots = append(slots, rpc.AutorunSlot{
+			AgentRoleID: role.ID,
+			Name:        role.Name,
+			Used:        len(sessions),
+			Max:         role.MaxConcurrent,
+			SessionIDs:  append([]string{}, sessions...),
+		})
+	}
+	// Sessions still running under a role deleted since they started.
+	for _, roleID := range slices.Sorted(maps.Keys(occupancy)) {
+		slots = append(slots, rpc.AutorunSlot{
+			AgentRoleID: roleID,
+			Used:        len(occupancy[roleID]),
+			SessionIDs:  occupancy[roleID],
+		})
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.AutorunSlotsResult{Slots: slots}); err != nil {
+		h.log.Error("failed to send autorun slots response", "error", err)
+	}
+}
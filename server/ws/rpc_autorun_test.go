@@ -0,0 +1,118 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/settings"
+	"github.com/pockode/server/work"
+)
+
+func TestHandler_AutorunStatus(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	if resp := env.call("autorun.status", nil); resp.Error == nil {
+		t.Error("autorun.status succeeded without a gate")
+	}
+
+	gate, err := work.NewAutorunGate(t.TempDir(), func() work.AutorunLimits {
+		return work.AutorunLimits{MaxSessions: 1}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.handler.SetAutorunGate(gate)
+	gate.Admit("sess-1")
+	gate.Admit("sess-2")
+
+	resp := env.call("autorun.status", nil)
+	if resp.Error != nil {
+		t.Fatalf("autorun.status: %s", resp.Error.Message)
+	}
+	var status work.AutorunStatus
+	if err := json.Unmarshal(resp.Result, &status); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !status.Paused || status.Reason != work.AutorunReasonBudget || status.Sessions != 1 || status.ResumesAt == nil {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestHandler_SettingsUpdateValidatesAutorun(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	for _, s := range []settings.Settings{
+		{AutorunHours: "9-5"},
+		{AutorunDays: "someday"},
+		{AutorunBudgetPeriod: "monthly"},
+		{AutorunMaxTokens: -1},
+	} {
+		if resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: s}); resp.Error == nil {
+			t.Errorf("settings.update accepted %+v", s)
+		}
+	}
+
+	valid := settings.Settings{AutorunHours: "22:00-06:00", AutorunDays: "mon-fri", AutorunBudgetPeriod: work.AutorunWeekly, AutorunMaxSessions: 5}
+	if resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: valid}); resp.Error != nil {
+		t.Errorf("settings.update: %s", resp.Error.Message)
+	}
+}
+
+func TestHandler_AutorunSlots(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	if resp := env.call("autorun.slots", nil); resp.Error == nil {
+		t.Error("autorun.slots succeeded without slots")
+	}
+
+	maxConcurrent := 2
+	if resp := env.call("agent_role.update", rpc.AgentRoleUpdateParams{ID: env.testRoleID, MaxConcurrent: &maxConcurrent}); resp.Error != nil {
+		t.Fatalf("agent_role.update: %s", resp.Error.Message)
+	}
+	slots := work.NewAutorunSlots(env.workStore, func(string) int { return maxConcurrent })
+	env.handler.SetAutorunSlots(slots)
+
+	w, err := env.workStore.Create(context.Background(), work.Work{Type: work.WorkTypeStory, Title: "Story", AgentRoleID: env.testRoleID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.workStore.Start(context.Background(), w.ID, "sess-1"); err != nil {
+		t.Fatal(err)
+	}
+	slots.HandleProcessState("sess-1", "running", false)
+
+	resp := env.call("autorun.slots", nil)
+	if resp.Error != nil {
+		t.Fatalf("autorun.slots: %s", resp.Error.Message)
+	}
+	var result rpc.AutorunSlotsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := rpc.AutorunSlot{AgentRoleID: env.testRoleID, Name: "Test Engineer", Used: 1, Max: 2, SessionIDs: []string{"sess-1"}}
+	if len(result.Slots) != 1 || !reflect.DeepEqual(result.Slots[0], want) {
+		t.Errorf("slots = %+v, want [%+v]", result.Slots, want)
+	}
+}
+
+func TestHandler_AutorunStatusRepliesOnce(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	resp := env.call("autorun.status", nil)
+
+	// A second reply would follow right behind the first, e.g. a "method not
+	// found" from the worktree dispatch if the case fell through.
+	ctx, cancel := context.WithTimeout(env.ctx, 300*time.Millisecond)
+	defer cancel()
+	for {
+		_, data, err := env.conn.Read(ctx)
+		if err != nil {
+			break
+		}
+		var extra rpcResponse
+		if err := json.Unmarshal(data, &extra); err == nil && extra.ID == resp.ID {
+			t.Errorf("autorun.status replied twice; second reply: %s", data)
+		}
+	}
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/chat"
+	"github.com/pockode/server/process"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/session"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -15,52 +17,139 @@ import (
 func (h *rpcMethodHandler) handleChatMessagesSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.ChatMessagesSubscribeParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
+	result, rerr := h.subscribeChatMessages(wt, params)
+	if rerr != nil {
+		h.replyRPCError(ctx, conn, req.ID, rerr)
+		return
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send subscribe response", "sessionId", params.SessionID, "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) subscribeChatMessages(wt *worktree.Worktree, params rpc.ChatMessagesSubscribeParams) (rpc.ChatMessagesSubscribeResult, *rpcError) {
+	if err := session.ValidateHistoryPageLimit(params.Limit); err != nil {
+		return rpc.ChatMessagesSubscribeResult{}, &rpcError{reason: rpc.ErrInvalidParams, message: err.Error()}
+	}
+
 	log := h.log.With("sessionId", params.SessionID)
 
 	// Verify session exists and get mode
 	meta, found, err := wt.SessionStore.Get(params.SessionID)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to get session")
-		return
+		return rpc.ChatMessagesSubscribeResult{}, &rpcError{reason: rpc.ErrInternal, message: "failed to get session"}
 	}
 	if !found {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "session not found")
-		return
+		return rpc.ChatMessagesSubscribeResult{}, &rpcError{reason: rpc.ErrNotFound, message: "session not found"}
 	}
 
 	notifier := h.state.getNotifier()
-	id, history, err := wt.ChatMessagesWatcher.Subscribe(notifier, params.SessionID)
+	id, page, err := wt.ChatMessagesWatcher.Subscribe(notifier, params.SessionID, params.Limit, params.Types)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
-		return
+		return rpc.ChatMessagesSubscribeResult{}, &rpcError{reason: rpc.ErrInternal, message: err.Error()}
 	}
-	h.state.trackSubscription(id, wt.ChatMessagesWatcher)
+	h.state.trackWorktreeSubscription(id, wt.ChatMessagesWatcher, "chat.messages.subscribe", func(wt *worktree.Worktree) (any, *rpcError) {
+		return h.subscribeChatMessages(wt, params)
+	})
 
-	wt.SessionListWatcher.MarkRead(params.SessionID)
+	if err := wt.SessionListWatcher.MarkRead(params.SessionID); err != nil {
+		log.Warn("failed to mark session read on subscribe", "error", err)
+	}
 
 	result := rpc.ChatMessagesSubscribeResult{
 		ID:        id,
-		History:   history,
+		History:   page.Records,
+		Cursor:    page.Cursor,
+		HasMore:   page.HasMore,
+		Indexes:   page.Indexes,
 		State:     wt.ProcessManager.GetProcessState(params.SessionID),
 		Mode:      meta.Mode,
 		AgentType: meta.AgentType,
 	}
+	log.Info("subscribed to chat messages", "subscriptionId", id, "state", result.State, "mode", meta.Mode)
+	return result, nil
+}
+
+// handleChatMessagesPage returns older history for a client that subscribed
+// with a limit and scrolled past the start of what it has.
+func (h *rpcMethodHandler) handleChatMessagesPage(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.ChatMessagesPageParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if params.Before < 0 {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "before", "before must not be negative")
+		return
+	}
+	if err := session.ValidateHistoryPageLimit(params.Limit); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+		return
+	}
+	limit := params.Limit
+	if limit == 0 {
+		limit = session.DefaultHistoryPageLimit
+	}
+
+	if _, found, err := wt.SessionStore.Get(params.SessionID); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to get session")
+		return
+	} else if !found {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
+		return
+	}
+
+	page, err := wt.ChatMessagesWatcher.HistoryPage(ctx, params.SessionID, params.Before, limit, params.Types)
+	if err != nil {
+		h.log.Error("failed to read history page", "sessionId", params.SessionID, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to read history")
+		return
+	}
+
+	result := rpc.ChatMessagesPageResult{History: page.Records, Cursor: page.Cursor, HasMore: page.HasMore, Indexes: page.Indexes}
 	if err := conn.Reply(ctx, req.ID, result); err != nil {
-		log.Error("failed to send subscribe response", "error", err)
+		h.log.Error("failed to send history page response", "error", err)
+	}
+}
+
+// handleChatMessagesTurn returns a whole turn for a client that subscribed
+// with an event type filter and wants to see what it left out.
+func (h *rpcMethodHandler) handleChatMessagesTurn(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.ChatMessagesTurnParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	log.Info("subscribed to chat messages", "subscriptionId", id, "state", result.State, "mode", meta.Mode)
+	if _, found, err := wt.SessionStore.Get(params.SessionID); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to get session")
+		return
+	} else if !found {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
+		return
+	}
+
+	page, err := wt.ChatMessagesWatcher.TurnHistory(ctx, params.SessionID, params.At)
+	if err != nil {
+		h.log.Error("failed to read turn history", "sessionId", params.SessionID, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to read history")
+		return
+	}
+
+	result := rpc.ChatMessagesTurnResult{History: page.Records, Cursor: page.Cursor}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send turn history response", "error", err)
+	}
 }
 
 func (h *rpcMethodHandler) handleMessage(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.MessageParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
@@ -72,12 +161,16 @@ func (h *rpcMethodHandler) handleMessage(ctx context.Context, conn *jsonrpc2.Con
 
 	wt.SessionListWatcher.ClearNeedsInput(params.SessionID)
 
-	if err := wt.ChatClient.SendMessageExcluding(ctx, params.SessionID, params.Content, h.state.getNotifier()); err != nil {
+	queued, err := wt.ChatClient.SendMessageExcluding(ctx, params.SessionID, params.Content, h.state.getNotifier())
+	if err != nil {
 		h.replyErrorForChat(ctx, conn, req.ID, err)
 		return
 	}
+	if queued {
+		log.Info("prompt queued behind another client's turn")
+	}
 
-	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+	if err := conn.Reply(ctx, req.ID, rpc.MessageResult{Queued: queued}); err != nil {
 		log.Error("failed to send response", "error", err)
 	}
 }
@@ -85,7 +178,7 @@ func (h *rpcMethodHandler) handleMessage(ctx context.Context, conn *jsonrpc2.Con
 func (h *rpcMethodHandler) handleInterrupt(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.InterruptParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
@@ -106,7 +199,7 @@ func (h *rpcMethodHandler) handleInterrupt(ctx context.Context, conn *jsonrpc2.C
 func (h *rpcMethodHandler) handlePermissionResponse(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.PermissionResponseParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
@@ -120,16 +213,36 @@ func (h *rpcMethodHandler) handlePermissionResponse(ctx context.Context, conn *j
 	}
 	choice := parsePermissionChoice(params.Choice)
 
+	var quickReply chat.QuickReply
+	if params.QuickReplyID != "" {
+		var ok bool
+		if quickReply, ok = h.lookupQuickReply(ctx, conn, req, wt, params.QuickReplyID, chat.QuickReplyPermission); !ok {
+			return
+		}
+	}
+
 	wt.SessionListWatcher.ClearNeedsInput(params.SessionID)
 
-	if err := wt.ChatClient.SendPermissionResponse(ctx, params.SessionID, data, choice); err != nil {
+	var result rpc.PermissionResponseResult
+	if params.ApplyToGroup {
+		answered, err := wt.ChatClient.SendPermissionGroupResponse(ctx, params.SessionID, data, choice)
+		if err != nil {
+			h.replyErrorForChat(ctx, conn, req.ID, err)
+			return
+		}
+		result.GroupAnswered = answered
+	} else if err := wt.ChatClient.SendPermissionResponse(ctx, params.SessionID, data, choice); err != nil {
 		h.replyErrorForChat(ctx, conn, req.ID, err)
 		return
 	}
 
-	log.Info("sent permission response", "choice", params.Choice)
+	if params.QuickReplyID != "" {
+		result.QuickReplySent = h.sendQuickReply(ctx, wt, params.SessionID, quickReply)
+	}
 
-	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+	log.Info("sent permission response", "choice", params.Choice, "groupAnswered", len(result.GroupAnswered), "quickReply", params.QuickReplyID)
+
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		log.Error("failed to send response", "error", err)
 	}
 }
@@ -137,7 +250,7 @@ func (h *rpcMethodHandler) handlePermissionResponse(ctx context.Context, conn *j
 func (h *rpcMethodHandler) handleQuestionResponse(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.QuestionResponseParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
@@ -148,6 +261,14 @@ func (h *rpcMethodHandler) handleQuestionResponse(ctx context.Context, conn *jso
 		ToolUseID: params.ToolUseID,
 	}
 
+	var quickReply chat.QuickReply
+	if params.QuickReplyID != "" {
+		var ok bool
+		if quickReply, ok = h.lookupQuickReply(ctx, conn, req, wt, params.QuickReplyID, chat.QuickReplyQuestion); !ok {
+			return
+		}
+	}
+
 	wt.SessionListWatcher.ClearNeedsInput(params.SessionID)
 
 	if err := wt.ChatClient.SendQuestionResponse(ctx, params.SessionID, data, params.Answers); err != nil {
@@ -155,19 +276,142 @@ func (h *rpcMethodHandler) handleQuestionResponse(ctx context.Context, conn *jso
 		return
 	}
 
-	log.Info("sent question response", "cancelled", params.Answers == nil)
+	var result rpc.QuestionResponseResult
+	if params.QuickReplyID != "" {
+		result.QuickReplySent = h.sendQuickReply(ctx, wt, params.SessionID, quickReply)
+	}
 
-	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+	log.Info("sent question response", "cancelled", params.Answers == nil, "quickReply", params.QuickReplyID)
+
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		log.Error("failed to send response", "error", err)
 	}
 }
 
-// replyErrorForChat handles chat-specific errors with appropriate RPC codes.
+func (h *rpcMethodHandler) handleToolResultGet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.ChatToolResultGetParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	content, err := wt.SessionStore.GetToolResult(ctx, params.SessionID, params.ToolUseID)
+	if err != nil {
+		if errors.Is(err, session.ErrToolResultNotFound) {
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "tool result not found")
+			return
+		}
+		h.log.Error("failed to read tool result", "sessionId", params.SessionID, "toolUseId", params.ToolUseID, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to read tool result")
+		return
+	}
+
+	result := rpc.ChatToolResultGetResult{ToolUseID: params.ToolUseID, ToolResult: content}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send tool result response", "error", err)
+	}
+}
+
+// handleChatQuick creates an ephemeral session for a throwaway question. It
+// is a regular session for every chat.* method, but stays out of the session
+// list and is deleted when its process ends unless saved with chat.quick.save.
+func (h *rpcMethodHandler) handleChatQuick(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.ChatQuickParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	s := h.settingsStore.Get()
+	if params.AgentType == "" {
+		params.AgentType = s.DefaultAgentType
+	}
+	if params.Mode == "" {
+		params.Mode = s.DefaultMode
+	}
+	if params.AgentType != "" && !params.AgentType.IsValid() {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "agent_type", "invalid agent type")
+		return
+	}
+	if params.Mode != "" && !s.IsValidMode(params.Mode) {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "mode", "invalid mode")
+		return
+	}
+
+	sessionID := wt.ProcessManager.NewSessionID(params.AgentType, params.Mode)
+	sess, err := wt.SessionStore.CreateEphemeral(ctx, sessionID, params.AgentType, params.Mode)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to create session")
+		return
+	}
+
+	log := h.log.With("sessionId", sessionID)
+
+	if params.Content != "" {
+		// A new session has no other client, so nothing is queued.
+		if _, err := wt.ChatClient.SendMessageExcluding(ctx, sessionID, params.Content, h.state.getNotifier()); err != nil {
+			wt.ProcessManager.Close(sessionID)
+			if delErr := wt.SessionStore.Delete(ctx, sessionID); delErr != nil {
+				log.Warn("failed to delete quick chat session after send failure", "error", delErr)
+			}
+			h.replyErrorForChat(ctx, conn, req.ID, err)
+			return
+		}
+	}
+
+	log.Info("quick chat started", "length", len(params.Content))
+
+	result := rpc.SessionListItem{
+		SessionMeta: sess,
+		State:       wt.ProcessManager.GetProcessState(sessionID),
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		log.Error("failed to send quick chat response", "error", err)
+	}
+}
+
+// handleChatQuickSave keeps a quick chat as a regular session.
+func (h *rpcMethodHandler) handleChatQuickSave(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.ChatQuickSaveParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	sess, err := wt.SessionStore.Keep(ctx, params.SessionID, params.Title)
+	if err != nil {
+		switch {
+		case errors.Is(err, session.ErrSessionNotFound):
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
+		case errors.Is(err, session.ErrNotEphemeral):
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidTransition, "session is not a quick chat")
+		default:
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to save session")
+		}
+		return
+	}
+
+	h.log.Info("quick chat saved", "sessionId", params.SessionID)
+
+	result := rpc.SessionListItem{
+		SessionMeta: sess,
+		State:       wt.ProcessManager.GetProcessState(params.SessionID),
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send quick chat save response", "error", err)
+	}
+}
+
+// replyErrorForChat handles chat-specific errors with appropriate reasons.
 func (h *rpcMethodHandler) replyErrorForChat(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error) {
 	if errors.Is(err, chat.ErrSessionNotFound) {
-		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, "session not found")
+		h.replyError(ctx, conn, id, rpc.ErrNotFound, "session not found")
+	} else if errors.Is(err, process.ErrPermissionTimedOut) {
+		h.replyError(ctx, conn, id, rpc.ErrInvalidTransition, err.Error())
+	} else if errors.Is(err, process.ErrDraining) {
+		h.replyError(ctx, conn, id, rpc.ErrUnavailable, err.Error())
 	} else {
-		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, err.Error())
+		h.replyError(ctx, conn, id, rpc.ErrInternal, err.Error())
 	}
 }
 
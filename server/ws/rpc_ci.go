@@ -0,0 +1,19 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleCIStatusSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	notifier := h.state.getNotifier()
+	id, statuses := h.ciStatusWatcher.Subscribe(notifier)
+	h.state.trackSubscription(id, h.ciStatusWatcher)
+	h.log.Debug("subscribed", "watcher", "ci status", "watchId", id)
+
+	if err := conn.Reply(ctx, req.ID, rpc.CIStatusSubscribeResult{ID: id, Statuses: statuses}); err != nil {
+		h.log.Error("failed to send ci status subscribe response", "error", err)
+	}
+}
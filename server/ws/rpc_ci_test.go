@@ -0,0 +1,29 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pockode/server/rpc"
+)
+
+func TestHandler_CIStatusSubscribe(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("ci.status.subscribe", nil)
+	if resp.Error != nil {
+		t.Fatalf("subscribe: %s", resp.Error.Message)
+	}
+	var result rpc.CIStatusSubscribeResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.ID == "" || result.Statuses == nil || len(result.Statuses) != 0 {
+		t.Errorf("result = %+v, want an ID and an empty status list", result)
+	}
+
+	resp = env.call("ci.status.unsubscribe", map[string]string{"id": result.ID})
+	if resp.Error != nil {
+		t.Errorf("unsubscribe: %s", resp.Error.Message)
+	}
+}
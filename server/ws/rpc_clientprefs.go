@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/settings"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleClientPrefsGet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.clientPrefs == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "client preferences not enabled")
+		return
+	}
+	var params rpc.ClientPrefsGetParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	prefs, err := h.clientPrefs.Get(params.ClientID)
+	if err != nil {
+		h.replyClientPrefsError(ctx, conn, req, err, "failed to load client preferences")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.ClientPrefsResult{Prefs: prefs}); err != nil {
+		h.log.Error("failed to send client preferences response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleClientPrefsSet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.clientPrefs == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "client preferences not enabled")
+		return
+	}
+	var params rpc.ClientPrefsSetParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	prefs, err := h.clientPrefs.Set(params.ClientID, params.Prefs)
+	if err != nil {
+		h.replyClientPrefsError(ctx, conn, req, err, "failed to save client preferences")
+		return
+	}
+	h.log.Debug("client preferences updated", "clientId", params.ClientID, "keys", len(params.Prefs))
+
+	if err := conn.Reply(ctx, req.ID, rpc.ClientPrefsResult{Prefs: prefs}); err != nil {
+		h.log.Error("failed to send client preferences set response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) replyClientPrefsError(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, err error, message string) {
+	if errors.Is(err, settings.ErrInvalidClientPrefs) {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+		return
+	}
+	h.log.Error(message, "error", err)
+	h.replyError(ctx, conn, req.ID, rpc.ErrInternal, message)
+}
@@ -0,0 +1,47 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/settings"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestHandler_ClientPrefs(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("clientprefs.get", rpc.ClientPrefsGetParams{ClientID: "web"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidRequest {
+		t.Fatalf("without store: error = %+v, want InvalidRequest", resp.Error)
+	}
+
+	store, err := settings.NewClientPrefsStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.handler.SetClientPrefs(store)
+
+	resp = env.call("clientprefs.set", rpc.ClientPrefsSetParams{ClientID: "web", Prefs: settings.ClientPrefs{"font_size": json.RawMessage(`15`)}})
+	if resp.Error != nil {
+		t.Fatalf("set: %s", resp.Error.Message)
+	}
+
+	resp = env.call("clientprefs.get", rpc.ClientPrefsGetParams{ClientID: "web"})
+	if resp.Error != nil {
+		t.Fatalf("get: %s", resp.Error.Message)
+	}
+	var result rpc.ClientPrefsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if string(result.Prefs["font_size"]) != "15" {
+		t.Errorf("prefs = %s, want font_size 15", result.Prefs)
+	}
+
+	resp = env.call("clientprefs.set", rpc.ClientPrefsSetParams{ClientID: "no spaces"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("bad client id: error = %+v, want InvalidParams", resp.Error)
+	}
+}
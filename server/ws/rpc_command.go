@@ -3,12 +3,32 @@ package ws
 import (
 	"context"
 
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/command"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/session"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// handleCommandList returns the palette's commands: the ones used before,
+// ranked by frecency, then the rest the agent offers in the bound worktree.
+// Descriptions and argument hints come from the agent's CLI when it can
+// list its commands; otherwise only the builtins are offered.
 func (h *rpcMethodHandler) handleCommandList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	commands := h.commandStore.List()
+	var params rpc.CommandListParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+	agentType := h.resolveAgentType(params.AgentType)
+	if !agentType.IsValid() {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "agent_type", "invalid agent_type")
+		return
+	}
+
+	commands := h.commandStore.List(h.agentCommands(ctx, agentType)...)
 
 	result := rpc.CommandListResult{Commands: commands}
 
@@ -16,3 +36,37 @@ func (h *rpcMethodHandler) handleCommandList(ctx context.Context, conn *jsonrpc2
 		h.log.Error("failed to send command list response", "error", err)
 	}
 }
+
+// agentCommands lists the agent's commands in the bound worktree (or the
+// main one), or nil when the agent cannot list them.
+func (h *rpcMethodHandler) agentCommands(ctx context.Context, agentType session.AgentType) []command.Spec {
+	a, err := h.worktreeManager.Agents().Get(agentType)
+	if err != nil {
+		return nil
+	}
+	lister, ok := a.(agent.CommandLister)
+	if !ok {
+		return nil
+	}
+	workDir := h.worktreeManager.Registry().MainDir()
+	if wt := h.state.getWorktree(); wt != nil {
+		workDir = wt.WorkDir
+	}
+
+	specs, err := h.commandCatalog.Get(string(agentType)+"\x00"+workDir, func() ([]command.Spec, error) {
+		listed, err := lister.ListCommands(ctx, workDir)
+		if err != nil {
+			return nil, err
+		}
+		specs := make([]command.Spec, 0, len(listed))
+		for _, c := range listed {
+			specs = append(specs, command.Spec{Name: c.Name, Description: c.Description, ArgumentHint: c.ArgumentHint})
+		}
+		return specs, nil
+	})
+	if err != nil {
+		h.log.Warn("failed to list agent commands", "agent", agentType, "workDir", workDir, "error", err)
+		return nil
+	}
+	return specs
+}
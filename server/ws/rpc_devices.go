@@ -0,0 +1,114 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pockode/server/pairing"
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleDevicesPair(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.pairing == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "device pairing not enabled")
+		return
+	}
+	var params rpc.DevicesPairParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+	ttl := time.Duration(params.TTLSeconds) * time.Second
+	if ttl < 0 || ttl > pairing.MaxCodeTTL {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "ttl_seconds", "ttl_seconds must be between 0 and 3600")
+		return
+	}
+
+	code, err := h.pairing.NewCode(ttl)
+	if err != nil {
+		h.log.Error("failed to mint pairing code", "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to create pairing code")
+		return
+	}
+	h.log.Info("pairing code minted", "expiresAt", code.ExpiresAt)
+
+	if err := conn.Reply(ctx, req.ID, code); err != nil {
+		h.log.Error("failed to send devices pair response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleDevicesList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.pairing == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "device pairing not enabled")
+		return
+	}
+
+	current := h.state.getDeviceID()
+	devices := h.pairing.List()
+	result := rpc.DevicesListResult{Devices: make([]rpc.DeviceItem, len(devices))}
+	for i, d := range devices {
+		result.Devices[i] = rpc.DeviceItem{Device: d, Current: current != "" && d.ID == current}
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send devices list response", "error", err)
+	}
+}
+
+// handleDevicesRevoke removes a device and closes its live connections, so
+// a lost phone is cut off at once rather than on its next reconnect.
+func (h *rpcMethodHandler) handleDevicesRevoke(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.pairing == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "device pairing not enabled")
+		return
+	}
+	var params rpc.DevicesRevokeParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if params.ID == "" {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "id", "id is required")
+		return
+	}
+
+	if err := h.pairing.Revoke(params.ID); err != nil {
+		if errors.Is(err, pairing.ErrDeviceNotFound) {
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "device not found")
+			return
+		}
+		h.log.Error("failed to revoke device", "deviceId", params.ID, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to revoke device")
+		return
+	}
+	h.log.Info("device revoked", "deviceId", params.ID)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send devices revoke response", "error", err)
+	}
+	h.closeDeviceConns(params.ID)
+}
+
+func (h *RPCHandler) closeDeviceConns(deviceID string) {
+	h.connsMu.Lock()
+	var states []*rpcConnState
+	for _, s := range h.conns {
+		if s.getDeviceID() == deviceID {
+			states = append(states, s)
+		}
+	}
+	h.connsMu.Unlock()
+	for _, s := range states {
+		s.log.Info("closing connection of revoked device", "deviceId", deviceID)
+		s.close()
+	}
+}
+
+func (s *rpcConnState) getDeviceID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deviceID
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/pockode/server/pairing"
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// authOnNewConn opens another connection to env's server and sends auth.
+func authOnNewConn(t *testing.T, env *testEnv, params rpc.AuthParams) (*websocket.Conn, rpcResponse) {
+	t.Helper()
+	conn, _, err := websocket.Dial(env.ctx, "ws"+strings.TrimPrefix(env.server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close(websocket.StatusNormalClosure, "") })
+
+	data, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: "auth", Params: params})
+	if err := conn.Write(env.ctx, websocket.MessageText, data); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	_, respData, err := conn.Read(env.ctx)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	return conn, resp
+}
+
+func TestHandler_Devices(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("devices.pair", nil)
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidRequest {
+		t.Fatalf("without pairing: error = %+v, want InvalidRequest", resp.Error)
+	}
+
+	m, err := pairing.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.handler.SetPairing(m)
+
+	if resp := env.call("devices.pair", rpc.DevicesPairParams{TTLSeconds: 7200}); resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("ttl over the maximum: error = %+v, want InvalidParams", resp.Error)
+	}
+	resp = env.call("devices.pair", nil)
+	if resp.Error != nil {
+		t.Fatalf("pair: %s", resp.Error.Message)
+	}
+	var code pairing.Code
+	if err := json.Unmarshal(resp.Result, &code); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	phone, resp := authOnNewConn(t, env, rpc.AuthParams{PairingCode: code.Code, DeviceName: "Phone"})
+	if resp.Error != nil {
+		t.Fatalf("auth with pairing code: %s", resp.Error.Message)
+	}
+	var auth rpc.AuthResult
+	if err := json.Unmarshal(resp.Result, &auth); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if auth.DeviceID == "" || auth.DeviceToken == "" {
+		t.Fatalf("auth result = %+v, want a device ID and token", auth)
+	}
+	if _, resp := authOnNewConn(t, env, rpc.AuthParams{PairingCode: code.Code}); resp.Error == nil {
+		t.Error("a pairing code was redeemed twice")
+	}
+	if _, resp := authOnNewConn(t, env, rpc.AuthParams{Token: auth.DeviceToken}); resp.Error != nil {
+		t.Errorf("auth with device token: %s", resp.Error.Message)
+	}
+	if !env.handler.ValidToken(auth.DeviceToken) || env.handler.ValidToken("wrong") {
+		t.Error("ValidToken does not match the paired devices")
+	}
+
+	resp = env.call("devices.list", nil)
+	var list rpc.DevicesListResult
+	if err := json.Unmarshal(resp.Result, &list); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(list.Devices) != 1 || list.Devices[0].Name != "Phone" || list.Devices[0].Current {
+		t.Errorf("devices = %+v, want Phone, not current", list.Devices)
+	}
+
+	if resp := env.call("devices.revoke", rpc.DevicesRevokeParams{ID: "nope"}); resp.Error == nil || errorData(t, resp).Reason != rpc.ErrNotFound {
+		t.Errorf("unknown device: error = %+v, want not_found", resp.Error)
+	}
+	if resp := env.call("devices.revoke", rpc.DevicesRevokeParams{ID: auth.DeviceID}); resp.Error != nil {
+		t.Fatalf("revoke: %s", resp.Error.Message)
+	}
+	if _, _, err := phone.Read(env.ctx); err == nil {
+		t.Error("the revoked device's connection stayed open")
+	}
+	if _, resp := authOnNewConn(t, env, rpc.AuthParams{Token: auth.DeviceToken}); resp.Error == nil {
+		t.Error("a revoked device token still authenticates")
+	}
+}
@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const maxDigestPreviewHours = 24 * 7
+
+func (h *rpcMethodHandler) handleDigestPreview(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.DigestPreviewParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+	if params.Hours < 0 || params.Hours > maxDigestPreviewHours {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "hours", "hours must be between 1 and 168")
+		return
+	}
+	if params.Hours == 0 {
+		params.Hours = 24
+	}
+
+	until := time.Now()
+	d, err := h.digestGenerator.Generate(ctx, until.Add(-time.Duration(params.Hours)*time.Hour), until)
+	if err != nil {
+		h.log.Error("failed to generate digest preview", "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to generate digest")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, d); err != nil {
+		h.log.Error("failed to send digest preview response", "error", err)
+	}
+}
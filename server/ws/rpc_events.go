@@ -0,0 +1,33 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/pockode/server/eventlog"
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleEventsSince(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.eventLog == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "event log not enabled")
+		return
+	}
+	var params rpc.EventsSinceParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil || params.Limit < 0 {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	var result eventlog.SinceResult
+	if params.Seq == nil {
+		result = eventlog.SinceResult{Events: []eventlog.Event{}, LatestSeq: h.eventLog.LatestSeq()}
+	} else {
+		result = h.eventLog.Since(*params.Seq, params.Limit)
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send events since response", "error", err)
+	}
+}
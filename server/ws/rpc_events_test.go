@@ -0,0 +1,61 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pockode/server/eventlog"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/work"
+)
+
+func (e *testEnv) eventsSince(params any) eventlog.SinceResult {
+	e.t.Helper()
+	resp := e.call("events.since", params)
+	if resp.Error != nil {
+		e.t.Fatalf("events.since: %s", resp.Error.Message)
+	}
+	var result eventlog.SinceResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		e.t.Fatalf("unmarshal: %v", err)
+	}
+	return result
+}
+
+func TestHandler_EventsSince(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	if resp := env.call("events.since", nil); resp.Error == nil {
+		t.Error("events.since succeeded without an event log")
+	}
+
+	log, err := eventlog.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+	env.workStore.AddOnChangeListener(log)
+	env.handler.SetEventLog(log)
+
+	cursor := env.eventsSince(nil)
+	if len(cursor.Events) != 0 {
+		t.Errorf("cursor query returned events: %+v", cursor.Events)
+	}
+
+	resp := env.call("work.create", rpc.WorkCreateParams{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Offline change"})
+	if resp.Error != nil {
+		t.Fatalf("work.create: %s", resp.Error.Message)
+	}
+
+	got := env.eventsSince(rpc.EventsSinceParams{Seq: &cursor.LatestSeq})
+	if got.Reset || len(got.Events) != 1 {
+		t.Fatalf("events.since = %+v, want the create", got)
+	}
+	if e := got.Events[0]; e.Source != eventlog.SourceWork || e.Op != "create" || e.Seq != cursor.LatestSeq+1 {
+		t.Errorf("event = %+v", e)
+	}
+
+	ahead := got.LatestSeq + 10
+	if got := env.eventsSince(rpc.EventsSinceParams{Seq: &ahead}); !got.Reset {
+		t.Errorf("events.since past the log = %+v, want reset", got)
+	}
+}
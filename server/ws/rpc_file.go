@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"github.com/pockode/server/contents"
+	"github.com/pockode/server/outline"
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
@@ -13,21 +14,21 @@ import (
 func (h *rpcMethodHandler) handleFileGet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.FileGetParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	result, err := contents.GetContents(wt.WorkDir, params.Path)
+	result, err := contents.GetContents(ctx, wt.WorkDir, params.Path)
 	if err != nil {
 		if errors.Is(err, contents.ErrNotFound) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, err.Error())
 			return
 		}
 		if errors.Is(err, contents.ErrInvalidPath) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path")
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "path", "invalid path")
 			return
 		}
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		return
 	}
 
@@ -52,41 +53,69 @@ func (h *rpcMethodHandler) handleFileGet(ctx context.Context, conn *jsonrpc2.Con
 func (h *rpcMethodHandler) handleFileWrite(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.FileWriteParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	if err := contents.WriteFile(wt.WorkDir, params.Path, params.Content); err != nil {
+	if h.refuseProtected(ctx, conn, req, wt, params.Path) {
+		return
+	}
+
+	err := contents.WriteFileIfUnchanged(wt.WorkDir, params.Path, params.Content, params.BaseHash)
+	var conflict *contents.ConflictError
+	if errors.As(err, &conflict) {
+		h.replyFileConflict(ctx, conn, req, wt, conflict)
+		return
+	}
+	if err != nil {
 		if errors.Is(err, contents.ErrInvalidPath) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path")
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "path", "invalid path")
 			return
 		}
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		return
 	}
 
-	if err := conn.Reply(ctx, req.ID, nil); err != nil {
+	result := rpc.FileWriteResult{Hash: contents.HashContent([]byte(params.Content))}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		h.log.Error("failed to send file write response", "error", err)
 	}
 }
 
+// replyFileConflict refuses a write made against stale content, handing
+// back the latest content so the client can merge, and warns the path's
+// other subscribers that their copy may be stale too.
+func (h *rpcMethodHandler) replyFileConflict(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree, conflict *contents.ConflictError) {
+	var hash string
+	if conflict.Current != nil {
+		hash = conflict.Current.Hash
+	}
+	wt.FSWatcher.NotifyConflict(conflict.Path, hash, h.state.getNotifier())
+
+	rpcErr := &jsonrpc2.Error{Code: rpc.CodeFileConflict, Message: conflict.Error()}
+	rpcErr.SetError(rpc.FileConflictData{Reason: rpc.ErrConflict, Path: conflict.Path, File: conflict.Current})
+	if err := conn.ReplyWithError(ctx, req.ID, rpcErr); err != nil {
+		h.log.Error("failed to send file conflict response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleFileDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.FileDeleteParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if err := contents.DeleteFile(wt.WorkDir, params.Path); err != nil {
 		if errors.Is(err, contents.ErrInvalidPath) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path")
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "path", "invalid path")
 			return
 		}
 		if errors.Is(err, contents.ErrNotFound) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, err.Error())
 			return
 		}
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		return
 	}
 
@@ -94,3 +123,30 @@ func (h *rpcMethodHandler) handleFileDelete(ctx context.Context, conn *jsonrpc2.
 		h.log.Error("failed to send file delete response", "error", err)
 	}
 }
+
+func (h *rpcMethodHandler) handleFileOutline(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.FileOutlineParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	symbols, err := h.outlineCache.Get(wt.WorkDir, params.Path)
+	if err != nil {
+		switch {
+		case errors.Is(err, contents.ErrInvalidPath):
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "path", "invalid path")
+		case errors.Is(err, contents.ErrNotFound):
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, err.Error())
+		case errors.Is(err, outline.ErrUnsupported), errors.Is(err, outline.ErrTooLarge):
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+		default:
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
+		}
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.FileOutlineResult{Path: params.Path, Symbols: symbols}); err != nil {
+		h.log.Error("failed to send file outline response", "error", err)
+	}
+}
@@ -3,6 +3,8 @@ package ws
 import (
 	"context"
 
+	"github.com/pockode/server/contents"
+
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
@@ -11,20 +13,64 @@ import (
 func (h *rpcMethodHandler) handleFSSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.FSSubscribeParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
+	result, rerr := h.subscribeFS(wt, params)
+	if rerr != nil {
+		h.replyRPCError(ctx, conn, req.ID, rerr)
+		return
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send fs subscribe response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) subscribeFS(wt *worktree.Worktree, params rpc.FSSubscribeParams) (rpc.FSSubscribeResult, *rpcError) {
 	notifier := h.state.getNotifier()
 	id, err := wt.FSWatcher.Subscribe(params.Path, notifier)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
-		return
+		return rpc.FSSubscribeResult{}, &rpcError{reason: rpc.ErrInvalidParams, message: err.Error()}
 	}
-	h.state.trackSubscription(id, wt.FSWatcher)
+	h.state.trackWorktreeSubscription(id, wt.FSWatcher, "fs.subscribe", func(wt *worktree.Worktree) (any, *rpcError) {
+		return h.subscribeFS(wt, params)
+	})
 	h.log.Debug("subscribed", "watcher", "fs", "watchId", id, "path", params.Path)
+	return rpc.FSSubscribeResult{ID: id}, nil
+}
 
-	if err := conn.Reply(ctx, req.ID, rpc.FSSubscribeResult{ID: id}); err != nil {
-		h.log.Error("failed to send fs subscribe response", "error", err)
+func (h *rpcMethodHandler) handleWorkspaceSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.WorkspaceSubscribeParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	result, rerr := h.subscribeWorkspace(wt, params)
+	if rerr != nil {
+		h.replyRPCError(ctx, conn, req.ID, rerr)
+		return
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send workspace subscribe response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) subscribeWorkspace(wt *worktree.Worktree, params rpc.WorkspaceSubscribeParams) (rpc.WorkspaceSubscribeResult, *rpcError) {
+	for _, path := range params.Paths {
+		if err := contents.ValidatePath(wt.WorkDir, path); err != nil {
+			return rpc.WorkspaceSubscribeResult{}, &rpcError{reason: rpc.ErrInvalidParams, message: "invalid path: " + path}
+		}
+	}
+	notifier := h.state.getNotifier()
+	id, status, err := wt.WorkspaceWatcher.Subscribe(params.Paths, notifier)
+	if err != nil {
+		return rpc.WorkspaceSubscribeResult{}, &rpcError{reason: rpc.ErrInvalidParams, message: err.Error()}
 	}
+	h.state.trackWorktreeSubscription(id, wt.WorkspaceWatcher, "workspace.subscribe", func(wt *worktree.Worktree) (any, *rpcError) {
+		return h.subscribeWorkspace(wt, params)
+	})
+	h.log.Debug("subscribed", "watcher", "workspace", "watchId", id, "paths", params.Paths)
+	return rpc.WorkspaceSubscribeResult{ID: id, Git: status}, nil
 }
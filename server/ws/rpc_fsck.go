@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/snapshot"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleFsckRun(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.fsck == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "fsck not enabled")
+		return
+	}
+	var params rpc.FsckRunParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	if params.Repair {
+		h.snapshotBefore(snapshot.LabelFsckRepair)
+	}
+	report, err := h.fsck.Run(ctx, params.Repair)
+	if err != nil {
+		h.log.Error("failed to run fsck", "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to run fsck")
+		return
+	}
+	if report.Repaired > 0 {
+		h.log.Info("fsck repaired data directory", "issues", len(report.Issues), "repaired", report.Repaired)
+	}
+
+	if err := conn.Reply(ctx, req.ID, report); err != nil {
+		h.log.Error("failed to send fsck run response", "error", err)
+	}
+}
@@ -0,0 +1,49 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/fsck"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/work"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestHandler_FsckRun(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("fsck.run", nil)
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidRequest {
+		t.Fatalf("without checker: error = %+v, want InvalidRequest", resp.Error)
+	}
+
+	dataDir := t.TempDir()
+	roles, err := agentrole.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	story, err := env.workStore.Create(context.Background(), work.Work{Type: work.WorkTypeStory, Title: "Story", AgentRoleID: "deleted-role"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.handler.SetFsck(fsck.NewChecker(dataDir, env.workStore, roles, nil))
+
+	resp = env.call("fsck.run", rpc.FsckRunParams{Repair: true})
+	if resp.Error != nil {
+		t.Fatalf("run: %s", resp.Error.Message)
+	}
+	var report fsck.Report
+	if err := json.Unmarshal(resp.Result, &report); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	// No default role, so the missing role has no fix to apply.
+	if len(report.Issues) != 1 || report.Issues[0].Kind != fsck.KindMissingRole || report.Issues[0].Repaired {
+		t.Errorf("issues = %+v, want one unrepaired missing_role", report.Issues)
+	}
+	if w, _, _ := env.workStore.Get(story.ID); w.AgentRoleID != "deleted-role" {
+		t.Errorf("agent role = %q, want it unchanged", w.AgentRoleID)
+	}
+}
@@ -3,11 +3,15 @@ package ws
 import (
 	"context"
 	"errors"
+	"math"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/pockode/server/contents"
 	"github.com/pockode/server/git"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/work"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -15,7 +19,7 @@ import (
 func (h *rpcMethodHandler) handleGitStatus(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	status, err := git.Status(wt.WorkDir)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		return
 	}
 
@@ -24,72 +28,107 @@ func (h *rpcMethodHandler) handleGitStatus(ctx context.Context, conn *jsonrpc2.C
 	}
 }
 
+func (h *rpcMethodHandler) handleGitFetch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	fetched, retryAfter, err := h.gitFetcher.Fetch(ctx, wt.WorkDir)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
+		return
+	}
+
+	branch, err := git.Branch(wt.WorkDir)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
+		return
+	}
+
+	result := rpc.GitFetchResult{
+		Fetched:           fetched,
+		RetryAfterSeconds: int(math.Ceil(retryAfter.Seconds())),
+		Branch:            branch,
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send git fetch response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleGitDiffSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.GitDiffSubscribeParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	if params.Path == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "path required")
+	response, rerr := h.subscribeGitDiff(wt, params)
+	if rerr != nil {
+		h.replyRPCError(ctx, conn, req.ID, rerr)
 		return
 	}
+	if err := conn.Reply(ctx, req.ID, response); err != nil {
+		h.log.Error("failed to send git diff subscribe response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) subscribeGitDiff(wt *worktree.Worktree, params rpc.GitDiffSubscribeParams) (rpc.GitDiffSubscribeResult, *rpcError) {
+	if params.Path == "" {
+		return rpc.GitDiffSubscribeResult{}, &rpcError{reason: rpc.ErrInvalidParams, field: "path", message: "path required"}
+	}
 
 	if err := contents.ValidatePath(wt.WorkDir, params.Path); err != nil {
 		if errors.Is(err, contents.ErrInvalidPath) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path")
-			return
+			return rpc.GitDiffSubscribeResult{}, &rpcError{reason: rpc.ErrInvalidParams, field: "path", message: "invalid path"}
 		}
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
-		return
+		return rpc.GitDiffSubscribeResult{}, &rpcError{reason: rpc.ErrInternal, message: err.Error()}
 	}
 
 	notifier := h.state.getNotifier()
 	id, result, err := wt.GitDiffWatcher.Subscribe(params.Path, params.Staged, params.HideWhitespace, notifier)
 	if err != nil {
 		if strings.Contains(err.Error(), "file not found") {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, err.Error())
-			return
+			return rpc.GitDiffSubscribeResult{}, &rpcError{reason: rpc.ErrNotFound, message: err.Error()}
 		}
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
-		return
+		return rpc.GitDiffSubscribeResult{}, &rpcError{reason: rpc.ErrInternal, message: err.Error()}
 	}
-	h.state.trackSubscription(id, wt.GitDiffWatcher)
+	h.state.trackWorktreeSubscription(id, wt.GitDiffWatcher, "git.diff.subscribe", func(wt *worktree.Worktree) (any, *rpcError) {
+		return h.subscribeGitDiff(wt, params)
+	})
 
 	h.log.Debug("subscribed", "watcher", "git-diff", "watchId", id, "path", params.Path, "staged", params.Staged)
 
-	response := rpc.GitDiffSubscribeResult{
+	return rpc.GitDiffSubscribeResult{
 		ID:         id,
 		Diff:       result.Diff,
 		OldContent: result.OldContent,
 		NewContent: result.NewContent,
-	}
-	if err := conn.Reply(ctx, req.ID, response); err != nil {
-		h.log.Error("failed to send git diff subscribe response", "error", err)
-	}
+		Hunks:      result.Hunks,
+		Binary:     result.Binary,
+	}, nil
 }
 
 func (h *rpcMethodHandler) handleGitSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	if err := conn.Reply(ctx, req.ID, h.subscribeGit(wt)); err != nil {
+		h.log.Error("failed to send git subscribe response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) subscribeGit(wt *worktree.Worktree) rpc.GitSubscribeResult {
 	notifier := h.state.getNotifier()
 	id := wt.GitWatcher.Subscribe(notifier)
-	h.state.trackSubscription(id, wt.GitWatcher)
+	h.state.trackWorktreeSubscription(id, wt.GitWatcher, "git.subscribe", func(wt *worktree.Worktree) (any, *rpcError) {
+		return h.subscribeGit(wt), nil
+	})
 	h.log.Debug("subscribed", "watcher", "git", "watchId", id)
-
-	if err := conn.Reply(ctx, req.ID, rpc.GitSubscribeResult{ID: id}); err != nil {
-		h.log.Error("failed to send git subscribe response", "error", err)
-	}
+	return rpc.GitSubscribeResult{ID: id}
 }
 
 func (h *rpcMethodHandler) handleGitAdd(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.GitPathsParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if len(params.Paths) == 0 {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "paths required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "paths", "paths required")
 		return
 	}
 
@@ -97,15 +136,32 @@ func (h *rpcMethodHandler) handleGitAdd(ctx context.Context, conn *jsonrpc2.Conn
 	for _, path := range params.Paths {
 		if err := contents.ValidatePath(workDir, path); err != nil {
 			if errors.Is(err, contents.ErrInvalidPath) {
-				h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path: "+path)
+				h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "paths", "invalid path: "+path)
 				return
 			}
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 			return
 		}
+	}
+
+	// Vet everything a directory add would stage before staging anything,
+	// so a refused request leaves the index untouched.
+	if len(h.settingsStore.Get().ProtectedPathPatterns()) > 0 {
+		for _, path := range params.Paths {
+			candidates, err := git.AddCandidates(workDir, path)
+			if err != nil {
+				h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
+				return
+			}
+			if h.refuseProtected(ctx, conn, req, wt, append([]string{path}, candidates...)...) {
+				return
+			}
+		}
+	}
 
+	for _, path := range params.Paths {
 		if err := git.Add(workDir, path); err != nil {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 			return
 		}
 	}
@@ -115,15 +171,53 @@ func (h *rpcMethodHandler) handleGitAdd(ctx context.Context, conn *jsonrpc2.Conn
 	}
 }
 
+func (h *rpcMethodHandler) handleGitAddHunks(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.GitAddHunksParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	if len(params.Hunks) == 0 {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "hunks", "hunks required")
+		return
+	}
+
+	if err := contents.ValidatePath(wt.WorkDir, params.Path); err != nil {
+		if errors.Is(err, contents.ErrInvalidPath) {
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "path", "invalid path: "+params.Path)
+			return
+		}
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
+		return
+	}
+	if h.refuseProtected(ctx, conn, req, wt, params.Path) {
+		return
+	}
+
+	if err := git.AddHunks(wt.WorkDir, params.Path, params.Hunks); err != nil {
+		if errors.Is(err, git.ErrHunkNotFound) {
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "hunk not found in current diff")
+			return
+		}
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, nil); err != nil {
+		h.log.Error("failed to send git add hunks response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleGitReset(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.GitPathsParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if len(params.Paths) == 0 {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "paths required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "paths", "paths required")
 		return
 	}
 
@@ -131,15 +225,15 @@ func (h *rpcMethodHandler) handleGitReset(ctx context.Context, conn *jsonrpc2.Co
 	for _, path := range params.Paths {
 		if err := contents.ValidatePath(workDir, path); err != nil {
 			if errors.Is(err, contents.ErrInvalidPath) {
-				h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid path: "+path)
+				h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "paths", "invalid path: "+path)
 				return
 			}
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 			return
 		}
 
 		if err := git.Reset(workDir, path); err != nil {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 			return
 		}
 	}
@@ -152,38 +246,121 @@ func (h *rpcMethodHandler) handleGitReset(ctx context.Context, conn *jsonrpc2.Co
 func (h *rpcMethodHandler) handleGitLog(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.GitLogParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	commits, err := git.Log(wt.WorkDir, params.Limit)
+	opts := git.LogOptions{Limit: params.Limit, Author: params.Author, Cursor: params.Cursor}
+	for _, bound := range []struct {
+		value string
+		dst   *time.Time
+	}{{params.Since, &opts.Since}, {params.Until, &opts.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "since and until must be RFC 3339 timestamps")
+			return
+		}
+		*bound.dst = t
+	}
+	if params.Path != "" {
+		if err := contents.ValidatePath(wt.WorkDir, params.Path); err != nil {
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "path", "invalid path")
+			return
+		}
+		opts.Paths = []string{params.Path}
+	}
+	if params.WorkID != "" {
+		ok, empty := h.applyWorkLogFilter(ctx, conn, req, wt, params.WorkID, &opts)
+		if !ok {
+			return
+		}
+		if empty {
+			if err := conn.Reply(ctx, req.ID, rpc.GitLogResult{Commits: []git.Commit{}}); err != nil {
+				h.log.Error("failed to send git log response", "error", err)
+			}
+			return
+		}
+	}
+
+	page, err := git.Log(ctx, wt.WorkDir, opts)
+	if errors.Is(err, git.ErrInvalidCursor) {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+		return
+	}
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		return
 	}
 
-	result := rpc.GitLogResult{Commits: commits}
+	result := rpc.GitLogResult{Commits: page.Commits, NextCursor: page.NextCursor}
 
 	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		h.log.Error("failed to send git log response", "error", err)
 	}
 }
 
+// applyWorkLogFilter narrows opts to the files workID and its descendants
+// changed, within opts.Paths if set, and to commits since the work was
+// created. The work is looked up in the store of wt. It reports false after
+// replying with an error, and empty when no changed file passes the filter,
+// so no commit can match.
+func (h *rpcMethodHandler) applyWorkLogFilter(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree, workID string, opts *git.LogOptions) (ok, empty bool) {
+	store := h.workStore
+	if stores := h.worktreeManager.WorkStores(); stores != nil {
+		var err error
+		if store, err = stores.For(wt.Name); err != nil {
+			h.log.Error("failed to open worktree work store", "worktree", wt.Name, "error", err)
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+			return false, false
+		}
+	}
+	works, err := store.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+		return false, false
+	}
+	files, _, found := work.CollectModifiedFiles(works, workID)
+	if !found {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "work not found")
+		return false, false
+	}
+	for _, w := range works {
+		if w.ID == workID && w.CreatedAt.After(opts.Since) {
+			opts.Since = w.CreatedAt
+		}
+	}
+
+	if len(opts.Paths) > 0 {
+		within := strings.TrimSuffix(opts.Paths[0], "/")
+		files = slices.DeleteFunc(files, func(f string) bool {
+			return f != within && !strings.HasPrefix(f, within+"/")
+		})
+	}
+	if len(files) == 0 {
+		return true, true
+	}
+	opts.Paths = files
+	return true, false
+}
+
 func (h *rpcMethodHandler) handleGitShow(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.GitShowParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if params.Hash == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "hash required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "hash", "hash required")
 		return
 	}
 
 	result, err := git.Show(wt.WorkDir, params.Hash)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		return
 	}
 
@@ -195,18 +372,18 @@ func (h *rpcMethodHandler) handleGitShow(ctx context.Context, conn *jsonrpc2.Con
 func (h *rpcMethodHandler) handleGitShowDiff(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.GitShowDiffParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if params.Hash == "" || params.Path == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "hash and path required")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "hash and path required")
 		return
 	}
 
 	result, err := git.ShowFileDiff(wt.WorkDir, params.Hash, params.Path, params.HideWhitespace)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		return
 	}
 
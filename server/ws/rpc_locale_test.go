@@ -0,0 +1,70 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+	"github.com/pockode/server/i18n"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/settings"
+)
+
+func TestHandler_Locale(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	deleteMissing := func() string {
+		t.Helper()
+		resp := env.call("work.delete", rpc.WorkDeleteParams{ID: "missing"})
+		if resp.Error == nil {
+			t.Fatal("work.delete of a missing work succeeded")
+		}
+		return resp.Error.Message
+	}
+
+	if msg := deleteMissing(); msg != "work not found" {
+		t.Errorf("default message = %q, want English", msg)
+	}
+
+	if resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: settings.Settings{Locale: "fr"}}); resp.Error == nil {
+		t.Error("settings.update accepted an unsupported locale")
+	}
+	if resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: settings.Settings{Locale: i18n.Japanese}}); resp.Error != nil {
+		t.Fatalf("settings.update: %s", resp.Error.Message)
+	}
+	if msg := deleteMissing(); msg != "ワークが見つかりません" {
+		t.Errorf("message with ja setting = %q, want Japanese", msg)
+	}
+
+	// A locale requested at auth wins over the setting.
+	conn, _, err := websocket.Dial(env.ctx, "ws"+strings.TrimPrefix(env.server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "")
+	send := func(id int, method string, params any) rpcResponse {
+		t.Helper()
+		data, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+		if err := conn.Write(env.ctx, websocket.MessageText, data); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+		for {
+			_, data, err := conn.Read(env.ctx)
+			if err != nil {
+				t.Fatalf("failed to read: %v", err)
+			}
+			var resp rpcResponse
+			if err := json.Unmarshal(data, &resp); err == nil && resp.ID == id {
+				return resp
+			}
+		}
+	}
+	resp := send(1, "auth", rpc.AuthParams{Token: "test-token", Locale: "en-US"})
+	var auth rpc.AuthResult
+	if resp.Error != nil || json.Unmarshal(resp.Result, &auth) != nil || auth.Locale != i18n.English {
+		t.Fatalf("auth = %+v, want locale en", resp)
+	}
+	if resp := send(2, "work.delete", rpc.WorkDeleteParams{ID: "missing"}); resp.Error == nil || resp.Error.Message != "work not found" {
+		t.Errorf("work.delete error = %+v, want English", resp.Error)
+	}
+}
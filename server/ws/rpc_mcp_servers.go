@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/worktree"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleMCPServersGet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	servers, err := wt.MCPServers()
+	if err != nil {
+		h.log.Error("failed to load MCP servers", "worktree", wt.Name, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to load mcp servers")
+		return
+	}
+	if servers == nil {
+		servers = []agent.MCPServer{}
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.MCPServersResult{Servers: servers}); err != nil {
+		h.log.Error("failed to send mcp servers response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleMCPServersSet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.MCPServersSetParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	if err := wt.SetMCPServers(params.Servers); err != nil {
+		if errors.Is(err, agent.ErrInvalidMCPServer) {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+			return
+		}
+		h.log.Error("failed to save MCP servers", "worktree", wt.Name, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to save mcp servers")
+		return
+	}
+
+	h.log.Info("worktree MCP servers updated", "worktree", wt.Name, "count", len(params.Servers))
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send mcp servers set response", "error", err)
+	}
+}
@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/rpc"
+)
+
+func TestHandler_MCPServers(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("mcp_servers.get", nil)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.MCPServersResult
+	json.Unmarshal(resp.Result, &result)
+	if result.Servers == nil || len(result.Servers) != 0 {
+		t.Fatalf("servers = %#v, want empty list", result.Servers)
+	}
+
+	servers := []agent.MCPServer{{Name: "db", Command: "db-mcp", Env: map[string]string{"DB_URL": "postgres://"}}}
+	resp = env.call("mcp_servers.set", rpc.MCPServersSetParams{Servers: servers})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+
+	resp = env.call("mcp_servers.get", nil)
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Servers) != 1 || !result.Servers[0].Equal(servers[0]) {
+		t.Errorf("servers = %+v, want %+v", result.Servers, servers)
+	}
+}
+
+func TestHandler_MCPServersSet_Invalid(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("mcp_servers.set", rpc.MCPServersSetParams{Servers: []agent.MCPServer{{Name: "pockode", Command: "x"}}})
+	if resp.Error == nil {
+		t.Fatal("expected error for reserved name")
+	}
+	if !strings.Contains(resp.Error.Message, "reserved") {
+		t.Errorf("error = %q, want reserved-name message", resp.Error.Message)
+	}
+}
@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/session"
+	"github.com/pockode/server/worktree"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleProcessKeepAlive(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.ProcessKeepAliveParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	meta, found, err := wt.SessionStore.Get(params.SessionID)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to get session")
+		return
+	}
+	if !found {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
+		return
+	}
+
+	keepAlive := meta.KeepAlive
+	if params.KeepAlive != nil {
+		if err := wt.SessionStore.SetKeepAlive(ctx, params.SessionID, *params.KeepAlive); err != nil {
+			if errors.Is(err, session.ErrSessionNotFound) {
+				h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
+				return
+			}
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to set keep alive")
+			return
+		}
+		keepAlive = *params.KeepAlive
+		h.log.Info("session keep alive set", "sessionId", params.SessionID, "keepAlive", keepAlive)
+	}
+
+	result := rpc.ProcessKeepAliveResult{
+		KeepAlive: keepAlive,
+		Running:   wt.ProcessManager.Touch(params.SessionID),
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send process keepalive response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleProcessRecover(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.ProcessRecoverParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	log := h.log.With("sessionId", params.SessionID, "action", params.Action)
+
+	switch params.Action {
+	case "interrupt":
+		if !wt.ProcessManager.HasProcess(params.SessionID) {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidTransition, "no running process")
+			return
+		}
+		if err := wt.ChatClient.Interrupt(ctx, params.SessionID); err != nil {
+			h.replyErrorForChat(ctx, conn, req.ID, err)
+			return
+		}
+	case "restart":
+		if err := wt.ChatClient.Restart(ctx, params.SessionID); err != nil {
+			h.replyErrorForChat(ctx, conn, req.ID, err)
+			return
+		}
+		if params.Message != "" {
+			if err := wt.ChatClient.SendUserMessage(ctx, params.SessionID, params.Message); err != nil {
+				h.replyErrorForChat(ctx, conn, req.ID, err)
+				return
+			}
+		}
+	default:
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "action", "action must be interrupt or restart")
+		return
+	}
+
+	log.Info("process recovered")
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		log.Error("failed to send process recover response", "error", err)
+	}
+}
@@ -0,0 +1,37 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pockode/server/audit"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/worktree"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// refuseProtected rejects a write when any of paths (worktree-relative)
+// matches a protected path pattern, replying with the first match and
+// recording it in the audit log. Reports whether the request was refused.
+func (h *rpcMethodHandler) refuseProtected(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree, paths ...string) bool {
+	patterns := h.settingsStore.Get().ProtectedPathPatterns()
+	if len(patterns) == 0 {
+		return false
+	}
+	for _, path := range paths {
+		pattern, ok := patterns.Match(path)
+		if !ok {
+			continue
+		}
+		h.auditLog.Record(audit.Event{
+			Action:   req.Method,
+			Path:     path,
+			Pattern:  pattern,
+			Worktree: wt.Name,
+			Detail:   "connId " + h.state.getConnID(),
+		})
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, fmt.Sprintf("protected path: %s (matches %q)", path, pattern))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/chat"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/worktree"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleQuickRepliesList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.QuickRepliesListParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+	switch params.Kind {
+	case "", chat.QuickReplyPermission, chat.QuickReplyQuestion:
+	default:
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "kind", "invalid kind")
+		return
+	}
+
+	replies, err := wt.QuickReplies()
+	if err != nil {
+		h.log.Error("failed to load quick replies", "worktree", wt.Name, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to load quick replies")
+		return
+	}
+	result := rpc.QuickRepliesResult{QuickReplies: []chat.QuickReply{}}
+	for _, q := range replies {
+		if params.Kind == "" || q.AppliesTo(params.Kind) {
+			result.QuickReplies = append(result.QuickReplies, q)
+		}
+	}
+
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send quick replies response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleQuickRepliesSet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.QuickRepliesSetParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	if err := wt.SetQuickReplies(params.QuickReplies); err != nil {
+		if errors.Is(err, chat.ErrInvalidQuickReply) {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+			return
+		}
+		h.log.Error("failed to save quick replies", "worktree", wt.Name, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to save quick replies")
+		return
+	}
+
+	h.log.Info("worktree quick replies updated", "worktree", wt.Name, "count", len(params.QuickReplies))
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send quick replies set response", "error", err)
+	}
+}
+
+// lookupQuickReply resolves a response's quick_reply_id before the response
+// is sent, so a bad ID fails without answering the agent. It replies with an
+// error and returns false when the ID is unknown or offered for another kind.
+func (h *rpcMethodHandler) lookupQuickReply(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree, id, kind string) (chat.QuickReply, bool) {
+	q, err := wt.QuickReply(id)
+	if errors.Is(err, worktree.ErrQuickReplyNotFound) || (err == nil && !q.AppliesTo(kind)) {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "quick reply not found")
+		return chat.QuickReply{}, false
+	}
+	if err != nil {
+		h.log.Error("failed to load quick replies", "worktree", wt.Name, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to load quick replies")
+		return chat.QuickReply{}, false
+	}
+	return q, true
+}
+
+// sendQuickReply sends the template as a follow-up user message. The
+// response it follows was already delivered, so a failure is logged and
+// reported as not sent rather than failing the request.
+func (h *rpcMethodHandler) sendQuickReply(ctx context.Context, wt *worktree.Worktree, sessionID string, q chat.QuickReply) bool {
+	if err := wt.ChatClient.SendUserMessage(ctx, sessionID, q.Text); err != nil {
+		h.log.Warn("failed to send quick reply", "sessionId", sessionID, "quickReply", q.ID, "error", err)
+		return false
+	}
+	return true
+}
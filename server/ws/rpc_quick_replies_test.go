@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pockode/server/agent"
+	"github.com/pockode/server/chat"
+	"github.com/pockode/server/rpc"
+)
+
+func TestHandler_QuickReplies(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("quick_replies.list", nil)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.QuickRepliesResult
+	json.Unmarshal(resp.Result, &result)
+	if result.QuickReplies == nil || len(result.QuickReplies) != 0 {
+		t.Fatalf("quick_replies = %#v, want empty list", result.QuickReplies)
+	}
+
+	replies := []chat.QuickReply{
+		{ID: "deny-why", Label: "Deny and explain", Text: "Explain why you need this first.", Kind: chat.QuickReplyPermission},
+		{ID: "prefer-x", Label: "Prefer X", Text: "Prefer library X."},
+	}
+	resp = env.call("quick_replies.set", rpc.QuickRepliesSetParams{QuickReplies: replies})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+
+	resp = env.call("quick_replies.list", rpc.QuickRepliesListParams{Kind: chat.QuickReplyQuestion})
+	result = rpc.QuickRepliesResult{}
+	json.Unmarshal(resp.Result, &result)
+	if len(result.QuickReplies) != 1 || result.QuickReplies[0] != replies[1] {
+		t.Errorf("question quick_replies = %+v, want only %+v", result.QuickReplies, replies[1])
+	}
+
+	resp = env.call("quick_replies.set", rpc.QuickRepliesSetParams{QuickReplies: []chat.QuickReply{{ID: "x", Label: "X"}}})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "text is required") {
+		t.Errorf("missing text: error = %+v, want text is required", resp.Error)
+	}
+}
+
+func TestHandler_PermissionResponse_QuickReply(t *testing.T) {
+	mock := &mockAgent{
+		events: []agent.AgentEvent{
+			agent.PermissionRequestEvent{RequestID: "req-1", ToolName: "Bash", ToolInput: []byte(`{"command":"rm -rf build"}`), ToolUseID: "toolu_1"},
+			agent.DoneEvent{},
+		},
+	}
+	env := newTestEnv(t, mock)
+	wt := env.getMainWorktree()
+	defer env.worktreeManager.Release(wt)
+	wt.SessionStore.Create(bgCtx, "sess", "", "")
+	if err := wt.SetQuickReplies([]chat.QuickReply{
+		{ID: "deny-why", Label: "Deny and explain", Text: "Explain why you need to delete build first.", Kind: chat.QuickReplyPermission},
+		{ID: "answer-only", Label: "Answer", Text: "x", Kind: chat.QuickReplyQuestion},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	env.subscribeChatMessages("sess")
+	env.sendMessage("sess", "clean up")
+	if notif := env.readNotification(); notif.Method != "chat.permission_request" {
+		t.Fatalf("expected chat.permission_request, got %q", notif.Method)
+	}
+
+	for _, id := range []string{"missing", "answer-only"} {
+		resp := env.call("chat.permission_response", rpc.PermissionResponseParams{SessionID: "sess", RequestID: "req-1", ToolUseID: "toolu_1", Choice: "deny", QuickReplyID: id})
+		if resp.Error == nil || resp.Error.Message != "quick reply not found" {
+			t.Errorf("quick_reply_id %q: error = %+v, want quick reply not found", id, resp.Error)
+		}
+	}
+
+	resp := env.call("chat.permission_response", rpc.PermissionResponseParams{SessionID: "sess", RequestID: "req-1", ToolUseID: "toolu_1", Choice: "deny", QuickReplyID: "deny-why"})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.PermissionResponseResult
+	json.Unmarshal(resp.Result, &result)
+	if !result.QuickReplySent {
+		t.Error("quick_reply_sent = false, want true")
+	}
+
+	msgs := mock.waitMessages(2)
+	if len(msgs) != 2 || msgs[1] != "Explain why you need to delete build first." {
+		t.Errorf("agent messages = %q, want the quick reply as a follow-up", msgs)
+	}
+}
@@ -0,0 +1,53 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/watch"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// maxDrainTimeout bounds server.drain; an agent turn that has not finished
+// within an hour is not going to.
+const maxDrainTimeout = time.Hour
+
+// handleServerDrain only starts the drain; the server shuts down once it
+// finishes, so clients observe completion as the connection closing.
+func (h *rpcMethodHandler) handleServerDrain(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.ServerDrainParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+	timeout := time.Duration(params.TimeoutSeconds) * time.Second
+	if timeout < 0 || timeout > maxDrainTimeout {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "timeout_seconds", "timeout_seconds must be between 0 and 3600")
+		return
+	}
+	if h.drainer == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "drain not supported")
+		return
+	}
+
+	if !h.drainer(timeout) {
+		h.replyError(ctx, conn, req.ID, rpc.ErrConflict, "drain already in progress")
+		return
+	}
+	h.log.Info("server drain requested", "timeout", timeout)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send server drain response", "error", err)
+	}
+}
+
+// handleServerStats reports notification delivery counters, so stalled
+// clients show up as dropped notifications rather than as guesswork.
+func (h *rpcMethodHandler) handleServerStats(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if err := conn.Reply(ctx, req.ID, watch.Stats()); err != nil {
+		h.log.Error("failed to send server stats response", "error", err)
+	}
+}
@@ -0,0 +1,47 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestHandler_ServerDrain(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("server.drain", nil)
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidRequest {
+		t.Fatalf("without drainer: error = %+v, want InvalidRequest", resp.Error)
+	}
+
+	drains := make(chan time.Duration, 1)
+	env.handler.SetDrainer(func(timeout time.Duration) bool {
+		select {
+		case drains <- timeout:
+			return true
+		default:
+			return false
+		}
+	})
+
+	resp = env.call("server.drain", rpc.ServerDrainParams{TimeoutSeconds: 30})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	if got := <-drains; got != 30*time.Second {
+		t.Errorf("timeout = %v, want 30s", got)
+	}
+
+	drains <- 0 // a drain is under way
+	resp = env.call("server.drain", nil)
+	if resp.Error == nil {
+		t.Error("second drain succeeded, want already in progress")
+	}
+
+	resp = env.call("server.drain", rpc.ServerDrainParams{TimeoutSeconds: -1})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("negative timeout: error = %+v, want InvalidParams", resp.Error)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 
-	"github.com/google/uuid"
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/session"
 	"github.com/pockode/server/worktree"
@@ -12,12 +11,11 @@ import (
 )
 
 func (h *rpcMethodHandler) handleSessionCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
-	sessionID := uuid.Must(uuid.NewV7()).String()
-
 	s := h.settingsStore.Get()
+	sessionID := wt.ProcessManager.NewSessionID(s.DefaultAgentType, s.DefaultMode)
 	sess, err := wt.SessionStore.Create(ctx, sessionID, s.DefaultAgentType, s.DefaultMode)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to create session")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to create session")
 		return
 	}
 
@@ -36,41 +34,91 @@ func (h *rpcMethodHandler) handleSessionCreate(ctx context.Context, conn *jsonrp
 func (h *rpcMethodHandler) handleSessionDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.SessionDeleteParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	wt.ProcessManager.Close(params.SessionID)
-	if err := wt.SessionStore.Delete(ctx, params.SessionID); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to delete session")
+	// Deleting a session that is already gone succeeds, as it always has.
+	if err := wt.SessionStore.Trash(ctx, params.SessionID); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to delete session")
 		return
 	}
+	// Work sessions live in the main worktree.
+	if wt.Name == "" {
+		if err := h.workOps.SessionTrashed(ctx, params.SessionID); err != nil {
+			h.log.Warn("failed to stop work of trashed session", "sessionId", params.SessionID, "error", err)
+		}
+	}
 
-	h.log.Info("session deleted", "sessionId", params.SessionID)
+	h.log.Info("session moved to trash", "sessionId", params.SessionID)
 
 	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
 		h.log.Error("failed to send session delete response", "error", err)
 	}
 }
 
+func (h *rpcMethodHandler) handleSessionTrashList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	sessions, err := wt.SessionStore.ListTrash()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list trash")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.SessionTrashListResult{Sessions: sessions}); err != nil {
+		h.log.Error("failed to send session trash list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleSessionRestore(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.SessionRestoreParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	sess, err := wt.SessionStore.Restore(ctx, params.SessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, session.ErrSessionNotFound):
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
+		case errors.Is(err, session.ErrSessionExists):
+			h.replyError(ctx, conn, req.ID, rpc.ErrConflict, "session already exists")
+		default:
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to restore session")
+		}
+		return
+	}
+
+	h.log.Info("session restored", "sessionId", params.SessionID)
+
+	result := rpc.SessionListItem{
+		SessionMeta: sess,
+		State:       wt.ProcessManager.GetProcessState(sess.ID),
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send session restore response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleSessionUpdateTitle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.SessionUpdateTitleParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if params.Title == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "title required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "title", "title required")
 		return
 	}
 
 	if err := wt.SessionStore.Update(ctx, params.SessionID, params.Title); err != nil {
 		if errors.Is(err, session.ErrSessionNotFound) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "session not found")
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
 			return
 		}
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to update session")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to update session")
 		return
 	}
 
@@ -84,31 +132,31 @@ func (h *rpcMethodHandler) handleSessionUpdateTitle(ctx context.Context, conn *j
 func (h *rpcMethodHandler) handleSessionSetAgentType(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.SessionSetAgentTypeParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if !params.AgentType.IsValid() {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid agent type")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "agent_type", "invalid agent type")
 		return
 	}
 
 	meta, found, err := wt.SessionStore.Get(params.SessionID)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to get session")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to get session")
 		return
 	}
 	if !found {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "session not found")
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
 		return
 	}
 	if meta.Activated {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "cannot change agent type after session has started")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidTransition, "cannot change agent type after session has started")
 		return
 	}
 
 	if err := wt.SessionStore.SetAgentType(ctx, params.SessionID, params.AgentType); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to set agent type")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to set agent type")
 		return
 	}
 
@@ -122,12 +170,12 @@ func (h *rpcMethodHandler) handleSessionSetAgentType(ctx context.Context, conn *
 func (h *rpcMethodHandler) handleSessionSetMode(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.SessionSetModeParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	if !params.Mode.IsValid() {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid mode")
+	if params.Mode == "" || !h.settingsStore.Get().IsValidMode(params.Mode) {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "mode", "invalid mode")
 		return
 	}
 
@@ -136,10 +184,10 @@ func (h *rpcMethodHandler) handleSessionSetMode(ctx context.Context, conn *jsonr
 
 	if err := wt.SessionStore.SetMode(ctx, params.SessionID, params.Mode); err != nil {
 		if errors.Is(err, session.ErrSessionNotFound) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "session not found")
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
 			return
 		}
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to set mode")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to set mode")
 		return
 	}
 
@@ -151,35 +199,90 @@ func (h *rpcMethodHandler) handleSessionSetMode(ctx context.Context, conn *jsonr
 }
 
 func (h *rpcMethodHandler) handleSessionListSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	result, rerr := h.subscribeSessionList(wt)
+	if rerr != nil {
+		h.replyRPCError(ctx, conn, req.ID, rerr)
+		return
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send session list subscribe response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) subscribeSessionList(wt *worktree.Worktree) (rpc.SessionListSubscribeResult, *rpcError) {
 	notifier := h.state.getNotifier()
 	id, sessions, err := wt.SessionListWatcher.Subscribe(notifier)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to subscribe")
-		return
+		return rpc.SessionListSubscribeResult{}, &rpcError{reason: rpc.ErrInternal, message: "failed to subscribe"}
 	}
-	h.state.trackSubscription(id, wt.SessionListWatcher)
+	h.state.trackWorktreeSubscription(id, wt.SessionListWatcher, "session.list.subscribe", func(wt *worktree.Worktree) (any, *rpcError) {
+		return h.subscribeSessionList(wt)
+	})
 	h.log.Debug("subscribed", "watcher", "session list", "watchId", id)
 
-	result := rpc.SessionListSubscribeResult{
+	return rpc.SessionListSubscribeResult{
 		ID:       id,
 		Sessions: sessions,
-	}
-
-	if err := conn.Reply(ctx, req.ID, result); err != nil {
-		h.log.Error("failed to send session list subscribe response", "error", err)
-	}
+	}, nil
 }
 
 func (h *rpcMethodHandler) handleSessionMarkRead(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
 	var params rpc.SessionMarkReadParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	wt.SessionListWatcher.MarkRead(params.SessionID)
+	if err := wt.SessionListWatcher.MarkRead(params.SessionID); err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
+			return
+		}
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to mark read")
+		return
+	}
 
 	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
 		h.log.Error("failed to send session mark read response", "error", err)
 	}
 }
+
+// handleSessionToolCalls lists the commands and file writes of a session,
+// so they can be audited without scrolling its chat.
+func (h *rpcMethodHandler) handleSessionToolCalls(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	var params rpc.SessionToolCallsParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	filter := session.ToolCallFilter{Tool: params.Tool, Path: params.Path}
+	if params.Since != nil {
+		filter.Since = *params.Since
+	}
+	if params.Until != nil {
+		filter.Until = *params.Until
+	}
+	if err := session.ValidateToolCallFilter(filter); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+		return
+	}
+
+	if _, found, err := wt.SessionStore.Get(params.SessionID); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to get session")
+		return
+	} else if !found {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "session not found")
+		return
+	}
+
+	calls, err := wt.SessionStore.ToolCalls(ctx, params.SessionID, filter)
+	if err != nil {
+		h.log.Error("failed to list tool calls", "sessionId", params.SessionID, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list tool calls")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.SessionToolCallsResult{ToolCalls: calls}); err != nil {
+		h.log.Error("failed to send session tool calls response", "error", err)
+	}
+}
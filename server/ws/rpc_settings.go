@@ -2,8 +2,14 @@ package ws
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
 
+	"github.com/pockode/server/mcp"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/settings"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
@@ -25,41 +31,119 @@ func (h *rpcMethodHandler) handleSettingsSubscribe(ctx context.Context, conn *js
 func (h *rpcMethodHandler) handleSettingsUpdate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.SettingsUpdateParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && strings.HasPrefix(typeErr.Field, "settings.") {
+			h.replySettingsInvalid(ctx, conn, req.ID, []settings.FieldError{{
+				Field:   strings.TrimPrefix(typeErr.Field, "settings."),
+				Message: jsonTypeMessage(typeErr.Type),
+			}})
+			return
+		}
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	// Validate that the referenced agent role exists
-	if params.Settings.DefaultAgentRoleID != "" {
-		_, found, err := h.agentRoleStore.Get(params.Settings.DefaultAgentRoleID)
+	var raw struct {
+		Settings json.RawMessage `json:"settings"`
+	}
+	var unknown []string
+	if err := json.Unmarshal(*req.Params, &raw); err == nil && len(raw.Settings) > 0 {
+		if unknown, err = settings.UnknownFields(raw.Settings); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	var fieldErrs []settings.FieldError
+	if !params.AllowUnknownFields {
+		for _, k := range unknown {
+			fieldErrs = append(fieldErrs, settings.FieldError{Field: k, Message: "unknown setting"})
+		}
+	}
+	fieldErrs = append(fieldErrs, params.Settings.Validate()...)
+
+	// Checks against other stores and packages settings cannot import.
+	for _, ref := range []struct{ field, id string }{
+		{"default_agent_role_id", params.Settings.DefaultAgentRoleID},
+		{"review_agent_role_id", params.Settings.ReviewAgentRoleID},
+	} {
+		if ref.id == "" {
+			continue
+		}
+		_, found, err := h.agentRoleStore.Get(ref.id)
 		if err != nil {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to validate agent role")
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to validate agent role")
 			return
 		}
 		if !found {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "agent role not found")
-			return
+			fieldErrs = append(fieldErrs, settings.FieldError{Field: ref.field, Message: "agent role not found"})
 		}
 	}
-
-	// Validate default agent type if set
-	if params.Settings.DefaultAgentType != "" && !params.Settings.DefaultAgentType.IsValid() {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid default agent type")
-		return
+	if _, err := mcp.ParseRateLimits(params.Settings.MCPRateLimits); err != nil {
+		fieldErrs = append(fieldErrs, settings.FieldError{Field: "mcp_rate_limits", Message: err.Error()})
 	}
 
-	// Validate default mode if set
-	if params.Settings.DefaultMode != "" && !params.Settings.DefaultMode.IsValid() {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid default mode")
+	if len(fieldErrs) > 0 {
+		h.replySettingsInvalid(ctx, conn, req.ID, fieldErrs)
 		return
 	}
 
-	if err := h.settingsStore.Update(params.Settings); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to update settings")
-		return
+	if !params.DryRun {
+		if len(unknown) > 0 {
+			h.log.Warn("settings update dropped unknown fields", "fields", unknown)
+		}
+		if err := h.settingsStore.Update(params.Settings); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to update settings")
+			return
+		}
 	}
 
-	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+	result := rpc.SettingsUpdateResult{
+		DryRun:        params.DryRun,
+		Settings:      params.Settings,
+		Effective:     effectiveSettings(params.Settings),
+		IgnoredFields: unknown,
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		h.log.Error("failed to send settings update response", "error", err)
 	}
 }
+
+// replySettingsInvalid refuses a settings.update, listing every invalid
+// field in the error data so a form can mark them all at once.
+func (h *rpcMethodHandler) replySettingsInvalid(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, fieldErrs []settings.FieldError) {
+	h.replyErrorData(ctx, conn, id, rpc.ErrInvalidParams, fieldErrs[0].Message, fieldErrs)
+}
+
+// effectiveSettings adds the MCP guard defaults to settings.Effective.
+func effectiveSettings(s settings.Settings) settings.Settings {
+	s = s.Effective()
+	if strings.TrimSpace(s.MCPRateLimits) == "" {
+		s.MCPRateLimits = mcp.DefaultRateLimits
+	}
+	if s.MCPLoopRepeats == 0 {
+		s.MCPLoopRepeats = mcp.DefaultLoopRepeats
+	}
+	if s.MCPLoopWindowSeconds == 0 {
+		s.MCPLoopWindowSeconds = int(mcp.DefaultLoopWindow.Seconds())
+	}
+	return s
+}
+
+// jsonTypeMessage names the JSON type a Go type decodes from.
+func jsonTypeMessage(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "must be a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "must be a number"
+	case reflect.String:
+		return "must be a string"
+	case reflect.Slice, reflect.Array:
+		return "must be an array"
+	default:
+		return "must be an object"
+	}
+}
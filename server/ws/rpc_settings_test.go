@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/pockode/server/mcp"
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/settings"
+)
+
+func settingsFieldErrors(t *testing.T, resp rpcResponse) []string {
+	t.Helper()
+	data := errorData(t, resp)
+	if data.Reason != rpc.ErrInvalidParams {
+		t.Fatalf("reason = %q, want %q", data.Reason, rpc.ErrInvalidParams)
+	}
+	var fields []string
+	for _, e := range data.Errors {
+		fields = append(fields, e.Field)
+	}
+	return fields
+}
+
+func TestHandler_SettingsUpdate_FieldErrors(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: settings.Settings{
+		DigestHour:               30,
+		DefaultAgentRoleID:       "missing-role",
+		MCPRateLimits:            "work_create=ten/1m",
+		PermissionTimeoutSeconds: -5,
+	}})
+	fields := settingsFieldErrors(t, resp)
+	want := []string{"digest_hour", "permission_timeout_seconds", "default_agent_role_id", "mcp_rate_limits"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+	if resp.Error.Message != "digest hour must be between 0 and 23" {
+		t.Errorf("message = %q, want the first field's", resp.Error.Message)
+	}
+
+	resp = env.call("settings.update", map[string]any{"settings": map[string]any{"digest_hour": "noon"}})
+	if fields := settingsFieldErrors(t, resp); !slices.Equal(fields, []string{"digest_hour"}) || resp.Error.Message != "must be a number" {
+		t.Errorf("type error = %v %q", fields, resp.Error.Message)
+	}
+}
+
+func TestHandler_SettingsUpdate_UnknownFields(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	params := map[string]any{"settings": map[string]any{"digest_hour": 7, "digest_houre": 8}}
+
+	resp := env.call("settings.update", params)
+	if fields := settingsFieldErrors(t, resp); !slices.Equal(fields, []string{"digest_houre"}) {
+		t.Errorf("fields = %v, want the unknown key", fields)
+	}
+	if got := env.handler.settingsStore.Get().DigestHour; got != 0 {
+		t.Fatalf("refused update was saved: digest_hour = %d", got)
+	}
+
+	params["allow_unknown_fields"] = true
+	resp = env.call("settings.update", params)
+	if resp.Error != nil {
+		t.Fatalf("allow_unknown_fields: %s", resp.Error.Message)
+	}
+	var result rpc.SettingsUpdateResult
+	json.Unmarshal(resp.Result, &result)
+	if !slices.Equal(result.IgnoredFields, []string{"digest_houre"}) {
+		t.Errorf("ignored_fields = %v", result.IgnoredFields)
+	}
+	if got := env.handler.settingsStore.Get().DigestHour; got != 7 {
+		t.Errorf("digest_hour = %d, want 7 saved", got)
+	}
+}
+
+func TestHandler_SettingsUpdate_DryRun(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: settings.Settings{DigestHour: 9}, DryRun: true})
+	if resp.Error != nil {
+		t.Fatalf("dry run: %s", resp.Error.Message)
+	}
+	var result rpc.SettingsUpdateResult
+	json.Unmarshal(resp.Result, &result)
+	if !result.DryRun || result.Settings.DigestHour != 9 {
+		t.Errorf("result = %+v", result)
+	}
+	if result.Effective.ToolResultMaxBytes != settings.DefaultToolResultMaxBytes || result.Effective.MCPRateLimits != mcp.DefaultRateLimits {
+		t.Errorf("effective = %+v, want defaults filled in", result.Effective)
+	}
+	if got := env.handler.settingsStore.Get().DigestHour; got != 0 {
+		t.Errorf("dry run saved digest_hour = %d", got)
+	}
+
+	resp = env.call("settings.update", rpc.SettingsUpdateParams{Settings: settings.Settings{DigestHour: 24}, DryRun: true})
+	if fields := settingsFieldErrors(t, resp); !slices.Equal(fields, []string{"digest_hour"}) {
+		t.Errorf("dry run of invalid settings: fields = %v", fields)
+	}
+}
@@ -0,0 +1,104 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/snapshot"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// snapshotBefore takes an automatic snapshot ahead of a risky operation. A
+// failed snapshot is logged but does not block the operation: the user
+// asked for the change, and the snapshot is only a safety net.
+func (h *rpcMethodHandler) snapshotBefore(label string) {
+	if h.snapshots == nil {
+		return
+	}
+	if _, err := h.snapshots.Create(label); err != nil {
+		h.log.Warn("failed to take automatic snapshot", "label", label, "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) requireSnapshots(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) bool {
+	if h.snapshots == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "snapshots not enabled")
+		return false
+	}
+	return true
+}
+
+func (h *rpcMethodHandler) handleSnapshotCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if !h.requireSnapshots(ctx, conn, req) {
+		return
+	}
+	var params rpc.SnapshotCreateParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	info, err := h.snapshots.Create(params.Label)
+	if err != nil {
+		h.log.Error("failed to create snapshot", "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to create snapshot")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, info); err != nil {
+		h.log.Error("failed to send snapshot create response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleSnapshotList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if !h.requireSnapshots(ctx, conn, req) {
+		return
+	}
+
+	infos, err := h.snapshots.List()
+	if err != nil {
+		h.log.Error("failed to list snapshots", "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list snapshots")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.SnapshotListResult{Snapshots: infos}); err != nil {
+		h.log.Error("failed to send snapshot list response", "error", err)
+	}
+}
+
+// handleSnapshotRestore schedules the restore for the next startup: the
+// running stores hold the current state in memory and would write it back.
+func (h *rpcMethodHandler) handleSnapshotRestore(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if !h.requireSnapshots(ctx, conn, req) {
+		return
+	}
+	var params rpc.SnapshotRestoreParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	if err := h.snapshots.MarkPendingRestore(params.Name); err != nil {
+		if errors.Is(err, snapshot.ErrNotFound) {
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, snapshot.ErrInvalidName) {
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "name", err.Error())
+			return
+		}
+		h.log.Error("failed to schedule snapshot restore", "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to schedule restore")
+		return
+	}
+
+	h.log.Info("snapshot restore scheduled", "name", params.Name)
+
+	if err := conn.Reply(ctx, req.ID, rpc.SnapshotRestoreResult{RestartRequired: true}); err != nil {
+		h.log.Error("failed to send snapshot restore response", "error", err)
+	}
+}
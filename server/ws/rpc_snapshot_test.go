@@ -0,0 +1,86 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/snapshot"
+	"github.com/pockode/server/work"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestHandler_Snapshot(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("snapshot.list", nil)
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidRequest {
+		t.Fatalf("without manager: error = %+v, want InvalidRequest", resp.Error)
+	}
+
+	snapshots := snapshot.NewManager(t.TempDir())
+	env.handler.SetSnapshots(snapshots)
+
+	resp = env.call("snapshot.create", rpc.SnapshotCreateParams{Label: "before-migration"})
+	if resp.Error != nil {
+		t.Fatalf("create: %s", resp.Error.Message)
+	}
+	var created snapshot.Info
+	if err := json.Unmarshal(resp.Result, &created); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if created.Label != "before-migration" {
+		t.Errorf("label = %q, want before-migration", created.Label)
+	}
+
+	resp = env.call("snapshot.list", nil)
+	var list rpc.SnapshotListResult
+	if err := json.Unmarshal(resp.Result, &list); err != nil {
+		t.Fatalf("unmarshal list: %v", err)
+	}
+	if len(list.Snapshots) != 1 || list.Snapshots[0].Name != created.Name {
+		t.Errorf("list = %+v, want the created snapshot", list.Snapshots)
+	}
+
+	resp = env.call("snapshot.restore", rpc.SnapshotRestoreParams{Name: created.Name})
+	if resp.Error != nil {
+		t.Fatalf("restore: %s", resp.Error.Message)
+	}
+	var restore rpc.SnapshotRestoreResult
+	if err := json.Unmarshal(resp.Result, &restore); err != nil || !restore.RestartRequired {
+		t.Errorf("restore = %+v, %v; want restart_required", restore, err)
+	}
+	if name, ok, _ := snapshots.PendingRestore(); !ok || name != created.Name {
+		t.Errorf("pending = %q, %v; want %q", name, ok, created.Name)
+	}
+
+	resp = env.call("snapshot.restore", rpc.SnapshotRestoreParams{Name: "../settings.json"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("invalid name: error = %+v, want InvalidParams", resp.Error)
+	}
+}
+
+func TestHandler_WorkBulk_SnapshotsBeforeDelete(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	snapshots := snapshot.NewManager(t.TempDir())
+	env.handler.SetSnapshots(snapshots)
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Story"})
+	var story work.Work
+	if err := json.Unmarshal(storyResp.Result, &story); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := env.call("work.bulk", rpc.WorkBulkParams{Operations: []work.BulkOp{{Action: work.BulkDelete, ID: story.ID}}})
+	if resp.Error != nil {
+		t.Fatalf("bulk: %s", resp.Error.Message)
+	}
+
+	infos, err := snapshots.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Label != snapshot.LabelBulkDelete {
+		t.Errorf("snapshots = %+v, want one %s", infos, snapshot.LabelBulkDelete)
+	}
+}
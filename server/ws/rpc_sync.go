@@ -0,0 +1,32 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleSyncStatus(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	if h.issueSync == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "issue sync not enabled")
+		return
+	}
+	var params rpc.SyncStatusParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	status, err := h.issueSync.Status(params.WorkID)
+	if err != nil {
+		h.log.Error("failed to get issue sync status", "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to get sync status")
+		return
+	}
+	if err := conn.Reply(ctx, req.ID, status); err != nil {
+		h.log.Error("failed to send sync status response", "error", err)
+	}
+}
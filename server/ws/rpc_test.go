@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -15,10 +16,14 @@ import (
 	"github.com/coder/websocket"
 	"github.com/pockode/server/agent"
 	"github.com/pockode/server/agentrole"
+	"github.com/pockode/server/ci"
 	"github.com/pockode/server/command"
+	"github.com/pockode/server/contents"
+	"github.com/pockode/server/git"
 	"github.com/pockode/server/rpc"
 	"github.com/pockode/server/session"
 	"github.com/pockode/server/settings"
+	"github.com/pockode/server/testrun"
 	"github.com/pockode/server/work"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
@@ -29,14 +34,17 @@ var bgCtx = context.Background()
 func mockRegistry(mock *mockAgent) *agent.Registry {
 	r := agent.NewRegistry()
 	r.Register(session.AgentTypeClaude, mock)
+	r.Register(session.AgentTypeCodex, mock)
 	return r
 }
 
 type testEnv struct {
 	t               *testing.T
 	mock            *mockAgent
+	handler         *RPCHandler
 	worktreeManager *worktree.Manager
 	workStore       work.Store
+	testRunStore    testrun.Store
 	testRoleID      string // pre-created agent role ID for tests
 	server          *httptest.Server
 	conn            *websocket.Conn
@@ -70,6 +78,11 @@ func newTestEnvWithWorkDir(t *testing.T, mock *mockAgent, workDir string) *testE
 		t.Fatalf("failed to create agent role store: %v", err)
 	}
 
+	testRunStore, err := testrun.NewFileStore(dataDir)
+	if err != nil {
+		t.Fatalf("failed to create test run store: %v", err)
+	}
+
 	// Create a default role for tests
 	testRole, err := agentRoleStore.Create(context.Background(), agentrole.AgentRole{
 		Name:       "Test Engineer",
@@ -84,8 +97,9 @@ func newTestEnvWithWorkDir(t *testing.T, mock *mockAgent, workDir string) *testE
 	workStarter := worktree.NewWorkStarter(worktreeManager, agentRoleStore, settingsStore)
 	workStopper := worktree.NewWorkStopper(worktreeManager, workStore)
 	workOps := work.NewOperations(workStore, workStarter, nil)
+	workOps.SetSessionCloser(workStopper)
 
-	h := NewRPCHandler("test-token", "test", true, cmdStore, worktreeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore)
+	h := NewRPCHandler("test-token", "test", true, cmdStore, worktreeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore, testRunStore, ci.NewPoller(nil, workDir, nil))
 	server := httptest.NewServer(h)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -101,8 +115,10 @@ func newTestEnvWithWorkDir(t *testing.T, mock *mockAgent, workDir string) *testE
 	env := &testEnv{
 		t:               t,
 		mock:            mock,
+		handler:         h,
 		worktreeManager: worktreeManager,
 		workStore:       workStore,
+		testRunStore:    testRunStore,
 		testRoleID:      testRole.ID,
 		server:          server,
 		conn:            conn,
@@ -150,6 +166,19 @@ type rpcResponse struct {
 	Error   *jsonrpc2.Error `json:"error,omitempty"`
 }
 
+// errorData decodes the data every error response carries.
+func errorData(t *testing.T, resp rpcResponse) rpc.ErrorData {
+	t.Helper()
+	if resp.Error == nil || resp.Error.Data == nil {
+		t.Fatalf("error = %+v, want one with data", resp.Error)
+	}
+	var data rpc.ErrorData
+	if err := json.Unmarshal(*resp.Error.Data, &data); err != nil {
+		t.Fatalf("unmarshal error data: %v", err)
+	}
+	return data
+}
+
 type rpcNotification struct {
 	JSONRPC string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
@@ -245,10 +274,12 @@ func TestHandler_Auth_InvalidToken(t *testing.T) {
 	defer worktreeManager.Shutdown()
 
 	agentRoleStore, _ := agentrole.NewFileStore(dataDir)
+	testRunStore, _ := testrun.NewFileStore(dataDir)
 	workStarter := worktree.NewWorkStarter(worktreeManager, agentRoleStore, settingsStore)
 	workStopper := worktree.NewWorkStopper(worktreeManager, workStore)
 	workOps := work.NewOperations(workStore, workStarter, nil)
-	h := NewRPCHandler("secret-token", "test", true, cmdStore, worktreeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore)
+	workOps.SetSessionCloser(workStopper)
+	h := NewRPCHandler("secret-token", "test", true, cmdStore, worktreeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore, testRunStore, ci.NewPoller(nil, workDir, nil))
 	server := httptest.NewServer(h)
 	defer server.Close()
 
@@ -284,6 +315,9 @@ func TestHandler_Auth_InvalidToken(t *testing.T) {
 	if !strings.Contains(resp.Error.Message, "invalid token") {
 		t.Errorf("expected 'invalid token' error, got %q", resp.Error.Message)
 	}
+	if data := errorData(t, resp); data.Reason != rpc.ErrUnauthorized {
+		t.Errorf("reason = %q, want %q", data.Reason, rpc.ErrUnauthorized)
+	}
 }
 
 func TestHandler_Auth_FirstMessageMustBeAuth(t *testing.T) {
@@ -296,11 +330,13 @@ func TestHandler_Auth_FirstMessageMustBeAuth(t *testing.T) {
 	worktreeManager := worktree.NewManager(registry, mockRegistry(&mockAgent{}), dataDir, 10*time.Minute)
 	defer worktreeManager.Shutdown()
 	agentRoleStore, _ := agentrole.NewFileStore(dataDir)
+	testRunStore, _ := testrun.NewFileStore(dataDir)
 
 	workStarter := worktree.NewWorkStarter(worktreeManager, agentRoleStore, settingsStore)
 	workStopper := worktree.NewWorkStopper(worktreeManager, workStore)
 	workOps := work.NewOperations(workStore, workStarter, nil)
-	h := NewRPCHandler("test-token", "test", true, cmdStore, worktreeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore)
+	workOps.SetSessionCloser(workStopper)
+	h := NewRPCHandler("test-token", "test", true, cmdStore, worktreeManager, settingsStore, workStore, workOps, workStopper, agentRoleStore, testRunStore, ci.NewPoller(nil, workDir, nil))
 	server := httptest.NewServer(h)
 	defer server.Close()
 
@@ -338,6 +374,106 @@ func TestHandler_Auth_FirstMessageMustBeAuth(t *testing.T) {
 	}
 }
 
+func TestHandler_Batch_AuthThenRequests(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	// A fresh, unauthenticated connection sends auth and follow-ups in one frame.
+	conn, _, err := websocket.Dial(env.ctx, "ws"+strings.TrimPrefix(env.server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "")
+
+	batch := []any{
+		rpcRequest{JSONRPC: "2.0", ID: 1, Method: "auth", Params: rpc.AuthParams{Token: "test-token"}},
+		rpcRequest{JSONRPC: "2.0", ID: 2, Method: "settings.subscribe"},
+		rpcRequest{JSONRPC: "2.0", ID: 3, Method: "worktree.list"},
+		42,
+	}
+	data, _ := json.Marshal(batch)
+	if err := conn.Write(env.ctx, websocket.MessageText, data); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	_, respData, err := conn.Read(env.ctx)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	var resps []rpcResponse
+	if err := json.Unmarshal(respData, &resps); err != nil {
+		t.Fatalf("expected a batch response array, got %s: %v", respData, err)
+	}
+	if len(resps) != 4 {
+		t.Fatalf("got %d responses, want 4: %s", len(resps), respData)
+	}
+
+	byID := make(map[int]rpcResponse)
+	invalid := 0
+	for _, r := range resps {
+		if r.ID == 0 {
+			invalid++
+			continue
+		}
+		byID[r.ID] = r
+	}
+	for id := 1; id <= 3; id++ {
+		r, ok := byID[id]
+		if !ok {
+			t.Fatalf("missing response for id %d", id)
+		}
+		if r.Error != nil {
+			t.Errorf("id %d: unexpected error %q (requests must run after auth)", id, r.Error.Message)
+		}
+	}
+	if invalid != 1 {
+		t.Errorf("got %d invalid-request replies, want 1", invalid)
+	}
+
+	// Single requests keep working after the batch.
+	req := rpcRequest{JSONRPC: "2.0", ID: 4, Method: "worktree.list"}
+	data, _ = json.Marshal(req)
+	if err := conn.Write(env.ctx, websocket.MessageText, data); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	for {
+		_, respData, err = conn.Read(env.ctx)
+		if err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		var resp rpcResponse
+		if err := json.Unmarshal(respData, &resp); err == nil && resp.ID == 4 {
+			if resp.Error != nil {
+				t.Errorf("unexpected error: %s", resp.Error.Message)
+			}
+			break
+		}
+	}
+}
+
+func TestHandler_Batch_Empty(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	if err := env.conn.Write(env.ctx, websocket.MessageText, []byte("[]")); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	_, respData, err := env.conn.Read(env.ctx)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidRequest {
+		t.Errorf("expected invalid request error, got %s", respData)
+	}
+
+	// The connection survives an empty batch.
+	if resp := env.call("worktree.list", nil); resp.Error != nil {
+		t.Errorf("worktree.list after empty batch: %s", resp.Error.Message)
+	}
+}
+
 func TestHandler_ChatMessagesSubscribe(t *testing.T) {
 	env := newTestEnv(t, &mockAgent{})
 	env.getMainWorktree().SessionStore.Create(bgCtx, "sess", "", "")
@@ -633,7 +769,10 @@ func TestHandler_UnknownMethod(t *testing.T) {
 	resp := env.call("unknown_method", nil)
 
 	if resp.Error == nil || !strings.Contains(resp.Error.Message, "method not found") {
-		t.Errorf("expected method not found error, got %+v", resp)
+		t.Fatalf("expected method not found error, got %+v", resp)
+	}
+	if data := errorData(t, resp); data.Reason != rpc.ErrMethodNotFound {
+		t.Errorf("reason = %q, want %q", data.Reason, rpc.ErrMethodNotFound)
 	}
 }
 
@@ -722,6 +861,184 @@ func TestHandler_SessionDelete(t *testing.T) {
 	}
 }
 
+func TestHandler_SessionMarkRead(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	store := env.getMainWorktree().SessionStore
+	sess, _ := store.Create(bgCtx, "unread", "", "")
+	store.RecordMessage(bgCtx, sess.ID, "one", 1)
+	store.RecordMessage(bgCtx, sess.ID, "two", 1)
+
+	resp := env.call("session.mark_read", rpc.SessionMarkReadParams{SessionID: sess.ID})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+
+	meta, _, _ := store.Get(sess.ID)
+	if meta.Unread || meta.UnreadCount != 0 {
+		t.Errorf("unread = %v count = %d, want false/0", meta.Unread, meta.UnreadCount)
+	}
+	if meta.LastMessagePreview != "two" {
+		t.Errorf("preview = %q, want preserved %q", meta.LastMessagePreview, "two")
+	}
+}
+
+func TestHandler_SessionMarkRead_NotFound(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("session.mark_read", rpc.SessionMarkReadParams{SessionID: "missing"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params error, got %+v", resp)
+	}
+}
+
+func TestHandler_ProcessKeepAlive(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	wt := env.getMainWorktree()
+	sess, _ := wt.SessionStore.Create(bgCtx, "keepalive-sess", "", "")
+
+	// Without a process the flag is still persisted for the next start.
+	keep := true
+	resp := env.call("process.keepalive", rpc.ProcessKeepAliveParams{SessionID: sess.ID, KeepAlive: &keep})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.ProcessKeepAliveResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !result.KeepAlive || result.Running {
+		t.Errorf("result = %+v, want keep_alive without a running process", result)
+	}
+	if meta, _, _ := wt.SessionStore.Get(sess.ID); !meta.KeepAlive {
+		t.Error("expected keep_alive to be persisted")
+	}
+
+	env.sendMessage(sess.ID, "hello")
+	resp = env.call("process.keepalive", rpc.ProcessKeepAliveParams{SessionID: sess.ID})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !result.KeepAlive || !result.Running {
+		t.Errorf("result = %+v, want keep_alive on a running process", result)
+	}
+}
+
+func TestHandler_ProcessKeepAlive_NotFound(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("process.keepalive", rpc.ProcessKeepAliveParams{SessionID: "missing"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params error, got %+v", resp)
+	}
+}
+
+func TestHandler_ProcessRecover(t *testing.T) {
+	mock := &mockAgent{}
+	env := newTestEnv(t, mock)
+	wt := env.getMainWorktree()
+	sess, _ := wt.SessionStore.Create(bgCtx, "stuck-sess", "", "")
+
+	resp := env.call("process.recover", rpc.ProcessRecoverParams{SessionID: sess.ID, Action: "interrupt"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("interrupt without a process: got %+v, want invalid params", resp.Error)
+	}
+
+	env.sendMessage(sess.ID, "hello")
+	resp = env.call("process.recover", rpc.ProcessRecoverParams{SessionID: sess.ID, Action: "restart", Message: "continue"})
+	if resp.Error != nil {
+		t.Fatalf("restart: %s", resp.Error.Message)
+	}
+	if !wt.ProcessManager.HasProcess(sess.ID) {
+		t.Fatal("no process after restart")
+	}
+	mock.mu.Lock()
+	calls := append([]startCall(nil), mock.startCalls...)
+	mock.mu.Unlock()
+	if len(calls) != 2 || !calls[1].resume {
+		t.Errorf("start calls = %+v, want a resumed second start", calls)
+	}
+	if msgs := mock.waitMessages(2); len(msgs) != 2 || msgs[1] != "continue" {
+		t.Errorf("messages = %v, want the follow-up after restart", msgs)
+	}
+
+	resp = env.call("process.recover", rpc.ProcessRecoverParams{SessionID: sess.ID, Action: "kill"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("unknown action: got %+v, want invalid params", resp.Error)
+	}
+}
+
+func TestHandler_ChatToolResultGet(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	store := env.getMainWorktree().SessionStore
+	sess, _ := store.Create(bgCtx, "toolresult-sess", "", "")
+	if err := store.SaveToolResult(bgCtx, sess.ID, "toolu_1", "the full log"); err != nil {
+		t.Fatalf("SaveToolResult: %v", err)
+	}
+
+	resp := env.call("chat.toolresult.get", rpc.ChatToolResultGetParams{SessionID: sess.ID, ToolUseID: "toolu_1"})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.ChatToolResultGetResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if result.ToolResult != "the full log" || result.ToolUseID != "toolu_1" {
+		t.Errorf("result = %+v", result)
+	}
+
+	resp = env.call("chat.toolresult.get", rpc.ChatToolResultGetParams{SessionID: sess.ID, ToolUseID: "missing"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params error, got %+v", resp)
+	}
+}
+
+func TestHandler_ChatMessagesPaging(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	store := env.getMainWorktree().SessionStore
+	sess, _ := store.Create(bgCtx, "paging-sess", "", "")
+	for i := range 5 {
+		_ = store.AppendToHistory(bgCtx, sess.ID, map[string]any{"type": "message", "content": fmt.Sprint(i)})
+		_ = store.AppendToHistory(bgCtx, sess.ID, map[string]any{"type": "text", "content": fmt.Sprint(i)})
+	}
+
+	resp := env.call("chat.messages.subscribe", rpc.ChatMessagesSubscribeParams{SessionID: sess.ID, Limit: 4})
+	if resp.Error != nil {
+		t.Fatalf("subscribe: %s", resp.Error.Message)
+	}
+	var sub rpc.ChatMessagesSubscribeResult
+	if err := json.Unmarshal(resp.Result, &sub); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(sub.History) != 4 || sub.Cursor != 6 || !sub.HasMore {
+		t.Fatalf("subscribe page = %d records, cursor %d, has_more %v", len(sub.History), sub.Cursor, sub.HasMore)
+	}
+
+	resp = env.call("chat.messages.page", rpc.ChatMessagesPageParams{SessionID: sess.ID, Before: sub.Cursor, Limit: 10})
+	if resp.Error != nil {
+		t.Fatalf("page: %s", resp.Error.Message)
+	}
+	var page rpc.ChatMessagesPageResult
+	if err := json.Unmarshal(resp.Result, &page); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(page.History) != 6 || page.Cursor != 0 || page.HasMore {
+		t.Errorf("older page = %d records, cursor %d, has_more %v", len(page.History), page.Cursor, page.HasMore)
+	}
+
+	resp = env.call("chat.messages.page", rpc.ChatMessagesPageParams{SessionID: sess.ID, Before: 4, Limit: session.MaxHistoryPageLimit + 1})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("oversized limit: got %+v, want invalid params", resp.Error)
+	}
+	resp = env.call("chat.messages.page", rpc.ChatMessagesPageParams{SessionID: "missing", Before: 4})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("missing session: got %+v, want invalid params", resp.Error)
+	}
+}
+
 func TestHandler_SessionDelete_ClosesProcess(t *testing.T) {
 	env := newTestEnv(t, &mockAgent{})
 	wt := env.getMainWorktree()
@@ -851,6 +1168,57 @@ func TestHandler_ChatMessagesSubscribe_History(t *testing.T) {
 	}
 }
 
+func TestHandler_ChatMessagesSubscribe_TypeFilter(t *testing.T) {
+	mock := &mockAgent{
+		events: []agent.AgentEvent{
+			agent.ToolCallEvent{ToolName: "Bash", ToolInput: json.RawMessage(`{}`), ToolUseID: "t1"},
+			agent.TextEvent{Content: "Response"},
+			agent.DoneEvent{},
+		},
+	}
+	env := newTestEnv(t, mock)
+	env.getMainWorktree().SessionStore.Create(bgCtx, "sess", "", "")
+
+	resp := env.call("chat.messages.subscribe", rpc.ChatMessagesSubscribeParams{
+		SessionID: "sess",
+		Types:     []agent.EventType{agent.EventTypeText, agent.EventTypeDone},
+	})
+	if resp.Error != nil {
+		t.Fatalf("subscribe failed: %s", resp.Error.Message)
+	}
+	env.sendMessage("sess", "hello")
+
+	// The tool call is not sent.
+	if n := env.readNotification(); n.Method != "chat.text" {
+		t.Errorf("first notification = %q, want chat.text", n.Method)
+	}
+	if n := env.readNotification(); n.Method != "chat.done" {
+		t.Errorf("second notification = %q, want chat.done", n.Method)
+	}
+
+	resp = env.call("chat.messages.page", rpc.ChatMessagesPageParams{
+		SessionID: "sess",
+		Before:    100,
+		Types:     []agent.EventType{agent.EventTypeText},
+	})
+	var page rpc.ChatMessagesPageResult
+	json.Unmarshal(resp.Result, &page)
+	if want := []int{0, 2}; !slices.Equal(page.Indexes, want) {
+		t.Errorf("filtered page indexes = %v, want %v (message and text)", page.Indexes, want)
+	}
+
+	// The whole turn, tool call included, is available on demand.
+	resp = env.call("chat.messages.turn", rpc.ChatMessagesTurnParams{SessionID: "sess", At: page.Indexes[1]})
+	if resp.Error != nil {
+		t.Fatalf("turn failed: %s", resp.Error.Message)
+	}
+	var turn rpc.ChatMessagesTurnResult
+	json.Unmarshal(resp.Result, &turn)
+	if turn.Cursor != 0 || len(turn.History) != 4 {
+		t.Errorf("turn = cursor %d, %d records; want the message, tool call, text and done", turn.Cursor, len(turn.History))
+	}
+}
+
 // File/Git RPC tests
 
 // newWorkDirTestEnv is a convenience wrapper for tests that need a specific workDir.
@@ -981,6 +1349,66 @@ func TestHandler_FileWrite(t *testing.T) {
 	}
 }
 
+func TestHandler_FileWrite_Conflict(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+	testFile := filepath.Join(workDir, "test.txt")
+	os.WriteFile(testFile, []byte("original"), 0644)
+
+	var got rpc.FileGetResult
+	json.Unmarshal(env.call("file.get", rpc.FileGetParams{Path: "test.txt"}).Result, &got)
+	base := got.File.Hash
+
+	// The agent edits the file while the user has it open.
+	os.WriteFile(testFile, []byte("agent edit"), 0644)
+
+	// Another client watching the file.
+	other := dialAuthed(t, env)
+	data, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 2, Method: "fs.subscribe", Params: rpc.FSSubscribeParams{Path: "test.txt"}})
+	if err := other.Write(env.ctx, websocket.MessageText, data); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if _, _, err := other.Read(env.ctx); err != nil {
+		t.Fatalf("failed to read subscribe response: %v", err)
+	}
+
+	resp := env.call("file.write", rpc.FileWriteParams{Path: "test.txt", Content: "user edit", BaseHash: base})
+	if resp.Error == nil || resp.Error.Code != rpc.CodeFileConflict {
+		t.Fatalf("expected conflict error, got %+v", resp.Error)
+	}
+	var conflict rpc.FileConflictData
+	if err := json.Unmarshal(*resp.Error.Data, &conflict); err != nil {
+		t.Fatalf("unmarshal conflict data: %v", err)
+	}
+	if conflict.File == nil || conflict.File.Content != "agent edit" {
+		t.Errorf("conflict data = %+v, want latest content", conflict)
+	}
+	if content, _ := os.ReadFile(testFile); string(content) != "agent edit" {
+		t.Errorf("file overwritten on conflict: %q", content)
+	}
+
+	_, msg, err := other.Read(env.ctx)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	var notif rpcNotification
+	json.Unmarshal(msg, &notif)
+	if notif.Method != "file.conflict" {
+		t.Errorf("method = %q, want file.conflict", notif.Method)
+	}
+
+	// Writing against the latest hash succeeds and returns the new hash.
+	resp = env.call("file.write", rpc.FileWriteParams{Path: "test.txt", Content: "merged", BaseHash: conflict.File.Hash})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.FileWriteResult
+	json.Unmarshal(resp.Result, &result)
+	if result.Hash != contents.HashContent([]byte("merged")) {
+		t.Errorf("hash = %q", result.Hash)
+	}
+}
+
 func TestHandler_FileWrite_CreatesNewFile(t *testing.T) {
 	workDir := t.TempDir()
 	env := newWorkDirTestEnv(t, workDir)
@@ -1013,6 +1441,77 @@ func TestHandler_FileWrite_InvalidPath(t *testing.T) {
 	}
 }
 
+func TestHandler_FileWrite_ProtectedPath(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+
+	resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: settings.Settings{ProtectedPaths: ".env, secrets/**"}})
+	if resp.Error != nil {
+		t.Fatalf("settings.update: %s", resp.Error.Message)
+	}
+
+	for _, path := range []string{".env", "app/.env", "secrets/prod/key.pem"} {
+		resp := env.call("file.write", rpc.FileWriteParams{Path: path, Content: "x"})
+		if resp.Error == nil || !strings.Contains(resp.Error.Message, "protected path") {
+			t.Errorf("write %s: error = %v, want protected path", path, resp.Error)
+		}
+		if _, err := os.Stat(filepath.Join(workDir, path)); !os.IsNotExist(err) {
+			t.Errorf("write %s reached the disk", path)
+		}
+	}
+
+	resp = env.call("file.write", rpc.FileWriteParams{Path: ".env.example", Content: "x"})
+	if resp.Error != nil {
+		t.Errorf("unprotected write failed: %s", resp.Error.Message)
+	}
+}
+
+func TestHandler_SettingsUpdate_InvalidProtectedPaths(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: settings.Settings{ProtectedPaths: "secrets/[a"}})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Fatalf("expected invalid params, got %+v", resp.Error)
+	}
+}
+
+func TestHandler_CustomModes(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	store := env.getMainWorktree().SessionStore
+	sess, _ := store.Create(bgCtx, "sess-1", "", "")
+
+	if resp := env.call("session.set_mode", rpc.SessionSetModeParams{SessionID: sess.ID, Mode: "review"}); resp.Error == nil {
+		t.Fatal("undefined mode was accepted")
+	}
+
+	for _, invalid := range []settings.Settings{
+		{Modes: []settings.CustomMode{{Name: "plan"}}},
+		{Modes: []settings.CustomMode{{Name: "review", Base: "review"}}},
+		{Modes: []settings.CustomMode{{Name: "review"}, {Name: "review"}}},
+		{DefaultMode: "review"},
+	} {
+		resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: invalid})
+		if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+			t.Errorf("settings %+v: expected invalid params, got %+v", invalid, resp.Error)
+		}
+	}
+
+	modes := settings.Settings{
+		DefaultMode: "review",
+		Modes:       []settings.CustomMode{{Name: "review", Base: session.ModePlan, AllowedTools: []string{"Read", "Grep"}}},
+	}
+	if resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: modes}); resp.Error != nil {
+		t.Fatalf("settings.update: %s", resp.Error.Message)
+	}
+	if resp := env.call("session.set_mode", rpc.SessionSetModeParams{SessionID: sess.ID, Mode: "review"}); resp.Error != nil {
+		t.Fatalf("session.set_mode: %s", resp.Error.Message)
+	}
+	got, _, _ := store.Get(sess.ID)
+	if got.Mode != "review" {
+		t.Errorf("mode = %q, want review", got.Mode)
+	}
+}
+
 func TestHandler_FileDelete(t *testing.T) {
 	workDir := t.TempDir()
 	env := newWorkDirTestEnv(t, workDir)
@@ -1073,6 +1572,48 @@ func TestHandler_FileDelete_Directory(t *testing.T) {
 	}
 }
 
+func TestHandler_FileOutline(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+	os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+
+	resp := env.call("file.outline", rpc.FileOutlineParams{Path: "main.go"})
+
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.FileOutlineResult
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Symbols) != 1 || result.Symbols[0].Name != "main" || result.Symbols[0].Line != 3 {
+		t.Errorf("unexpected symbols: %+v", result.Symbols)
+	}
+}
+
+func TestHandler_FileOutline_Errors(t *testing.T) {
+	workDir := t.TempDir()
+	env := newWorkDirTestEnv(t, workDir)
+	os.WriteFile(filepath.Join(workDir, "notes.txt"), []byte("text"), 0644)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"../etc/passwd.go", "invalid path"},
+		{"missing.go", "not found"},
+		{"notes.txt", "not supported"},
+	}
+	for _, tt := range tests {
+		resp := env.call("file.outline", rpc.FileOutlineParams{Path: tt.path})
+		if resp.Error == nil {
+			t.Errorf("%s: expected error", tt.path)
+			continue
+		}
+		if !strings.Contains(resp.Error.Message, tt.want) {
+			t.Errorf("%s: expected %q error, got %q", tt.path, tt.want, resp.Error.Message)
+		}
+	}
+}
+
 // Git RPC tests
 
 func setupGitRepo(t *testing.T) string {
@@ -1094,6 +1635,138 @@ func runGitIn(t *testing.T, dir string, args ...string) {
 	}
 }
 
+func TestHandler_GitLog_WorkFilter(t *testing.T) {
+	dir := setupGitRepo(t)
+	env := newWorkDirTestEnv(t, dir)
+	ctx := context.Background()
+
+	commit := func(name string) {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0644)
+		runGitIn(t, dir, "add", name)
+		runGitIn(t, dir, "commit", "-m", "add "+name)
+	}
+	story, err := env.workStore.Create(ctx, work.Work{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Story"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := env.workStore.Create(ctx, work.Work{Type: work.WorkTypeTask, ParentID: story.ID, AgentRoleID: env.testRoleID, Title: "Task"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.workStore.AddModifiedFiles(ctx, task.ID, []string{"src/a.go", "README.md"}); err != nil {
+		t.Fatal(err)
+	}
+	commit("src/a.go")
+	commit("other.go")
+	commit("README.md")
+
+	log := func(params rpc.GitLogParams) []string {
+		t.Helper()
+		resp := env.call("git.log", params)
+		if resp.Error != nil {
+			t.Fatalf("git.log %+v: %s", params, resp.Error.Message)
+		}
+		var result rpc.GitLogResult
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		var subjects []string
+		for _, c := range result.Commits {
+			subjects = append(subjects, c.Subject)
+		}
+		return subjects
+	}
+
+	if got := log(rpc.GitLogParams{WorkID: story.ID}); !slices.Equal(got, []string{"add README.md", "add src/a.go"}) {
+		t.Errorf("story commits = %v, want the two files its task changed", got)
+	}
+	if got := log(rpc.GitLogParams{WorkID: story.ID, Path: "src"}); !slices.Equal(got, []string{"add src/a.go"}) {
+		t.Errorf("story commits under src = %v", got)
+	}
+	if got := log(rpc.GitLogParams{WorkID: story.ID, Path: "other.go"}); len(got) != 0 {
+		t.Errorf("story commits to other.go = %v, want none", got)
+	}
+
+	for name, params := range map[string]rpc.GitLogParams{
+		"missing work":  {WorkID: "missing"},
+		"bad since":     {Since: "yesterday"},
+		"bad cursor":    {Cursor: "nope"},
+		"escaping path": {Path: "../x"},
+	} {
+		if resp := env.call("git.log", params); resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+			t.Errorf("%s: error = %+v, want InvalidParams", name, resp.Error)
+		}
+	}
+}
+
+func TestHandler_GitAdd_ProtectedPath(t *testing.T) {
+	dir := setupGitRepo(t)
+	env := newWorkDirTestEnv(t, dir)
+	os.MkdirAll(filepath.Join(dir, "config"), 0755)
+	os.WriteFile(filepath.Join(dir, "config", "app.yaml"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "config", ".env"), []byte("SECRET=1"), 0644)
+
+	resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: settings.Settings{ProtectedPaths: ".env"}})
+	if resp.Error != nil {
+		t.Fatalf("settings.update: %s", resp.Error.Message)
+	}
+
+	// A directory add that would sweep up the protected file is refused
+	// as a whole.
+	resp = env.call("git.add", rpc.GitPathsParams{Paths: []string{"config"}})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "config/.env") {
+		t.Fatalf("expected refusal naming config/.env, got %+v", resp.Error)
+	}
+	cmd := exec.Command("git", "diff", "--cached", "--name-only")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("refused add staged %q", out)
+	}
+
+	resp = env.call("git.add", rpc.GitPathsParams{Paths: []string{"config/app.yaml"}})
+	if resp.Error != nil {
+		t.Errorf("unprotected add failed: %s", resp.Error.Message)
+	}
+}
+
+func TestHandler_GitAddHunks(t *testing.T) {
+	dir := setupGitRepo(t)
+	env := newWorkDirTestEnv(t, dir)
+	file := filepath.Join(dir, "f.txt")
+	os.WriteFile(file, []byte("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"), 0644)
+	runGitIn(t, dir, "add", "f.txt")
+	runGitIn(t, dir, "commit", "-m", "initial")
+	os.WriteFile(file, []byte("A\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n"), 0644)
+
+	if resp := env.call("git.add.hunks", rpc.GitAddHunksParams{Path: "f.txt"}); resp.Error == nil {
+		t.Error("expected an error without hunks")
+	}
+	stale := git.HunkRange{OldStart: 3, OldLines: 1, NewStart: 3, NewLines: 1}
+	if resp := env.call("git.add.hunks", rpc.GitAddHunksParams{Path: "f.txt", Hunks: []git.HunkRange{stale}}); resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for a stale hunk, got %+v", resp.Error)
+	}
+
+	last := git.HunkRange{OldStart: 7, OldLines: 4, NewStart: 7, NewLines: 4}
+	if resp := env.call("git.add.hunks", rpc.GitAddHunksParams{Path: "f.txt", Hunks: []git.HunkRange{last}}); resp.Error != nil {
+		t.Fatalf("git.add.hunks: %s", resp.Error.Message)
+	}
+	cmd := exec.Command("git", "diff", "--cached", "-U0")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "+J") || strings.Contains(string(out), "+A") {
+		t.Errorf("staged diff should hold only the last hunk:\n%s", out)
+	}
+}
+
 func TestHandler_GitStatus_Empty(t *testing.T) {
 	dir := setupGitRepo(t)
 	env := newWorkDirTestEnv(t, dir)
@@ -1136,6 +1809,35 @@ func TestHandler_GitStatus_UntrackedFile(t *testing.T) {
 	}
 }
 
+func TestHandler_GitFetch_RateLimited(t *testing.T) {
+	dir := setupGitRepo(t)
+	env := newWorkDirTestEnv(t, dir)
+
+	resp := env.call("git.fetch", nil)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.GitFetchResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if !result.Fetched || result.Branch == nil {
+		t.Errorf("first fetch = %+v, want fetched with branch status", result)
+	}
+
+	resp = env.call("git.fetch", nil)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	result = rpc.GitFetchResult{}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if result.Fetched || result.RetryAfterSeconds <= 0 || result.Branch == nil {
+		t.Errorf("second fetch = %+v, want skipped with retry_after_seconds", result)
+	}
+}
+
 func TestHandler_GitDiffSubscribe_Unstaged(t *testing.T) {
 	dir := setupGitRepo(t)
 
@@ -1292,6 +1994,46 @@ func TestHandler_WorktreeCreate_Validation(t *testing.T) {
 	}
 }
 
+func TestHandler_WorktreeCreate_BranchTemplate(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+	env := newWorkDirTestEnv(t, dir)
+
+	s := settings.Default()
+	s.BranchTemplate = "feature/{work-short-id}-{slug}"
+	if resp := env.call("settings.update", rpc.SettingsUpdateParams{Settings: s}); resp.Error != nil {
+		t.Fatalf("settings.update: %s", resp.Error.Message)
+	}
+	resp := env.call("work.create", rpc.WorkCreateParams{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "ログイン修正 fix"})
+	if resp.Error != nil {
+		t.Fatalf("work.create: %s", resp.Error.Message)
+	}
+	var w work.Work
+	json.Unmarshal(resp.Result, &w)
+
+	resp = env.call("worktree.create", rpc.WorktreeCreateParams{Name: "bad", Branch: "my-branch"})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "does not match") {
+		t.Errorf("non-conforming branch: got %+v", resp)
+	}
+	resp = env.call("worktree.create", rpc.WorktreeCreateParams{Name: "nowork"})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "needs a work item") {
+		t.Errorf("no branch and no work: got %+v", resp)
+	}
+
+	// The short ID is accepted as work_id, as for work.* methods.
+	resp = env.call("worktree.create", rpc.WorktreeCreateParams{Name: "login", WorkID: w.ShortID})
+	if resp.Error != nil {
+		t.Fatalf("create from template: %s", resp.Error.Message)
+	}
+	var result rpc.WorktreeCreateResult
+	json.Unmarshal(resp.Result, &result)
+	if want := "feature/" + w.ShortID + "-roguin-fix"; result.Worktree.Branch != want {
+		t.Errorf("branch = %q, want %q", result.Worktree.Branch, want)
+	}
+}
+
 func TestHandler_WorktreeCreateAndDelete_E2E(t *testing.T) {
 	dir := setupGitRepo(t)
 	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
@@ -1392,6 +2134,70 @@ func TestHandler_WorktreeSwitch(t *testing.T) {
 	}
 }
 
+func TestHandler_WorktreeSwitch_CarryOver(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+	if resp := env.call("worktree.create", rpc.WorktreeCreateParams{Name: "feature", Branch: "feature-branch"}); resp.Error != nil {
+		t.Fatalf("create failed: %s", resp.Error.Message)
+	}
+
+	var sess session.SessionMeta
+	json.Unmarshal(env.call("session.create", nil).Result, &sess)
+	var gitSub rpc.GitSubscribeResult
+	json.Unmarshal(env.call("git.subscribe", nil).Result, &gitSub)
+	var listSub rpc.SessionListSubscribeResult
+	json.Unmarshal(env.call("session.list.subscribe", nil).Result, &listSub)
+	var chatSub rpc.ChatMessagesSubscribeResult
+	json.Unmarshal(env.call("chat.messages.subscribe", rpc.ChatMessagesSubscribeParams{SessionID: sess.ID}).Result, &chatSub)
+
+	resp := env.call("worktree.switch", rpc.WorktreeSwitchParams{Name: "feature", CarryOver: true})
+	if resp.Error != nil {
+		t.Fatalf("switch failed: %s", resp.Error.Message)
+	}
+	var result struct {
+		Subscriptions []struct {
+			PrevID string          `json:"prev_id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Reason rpc.ErrorReason `json:"reason"`
+		} `json:"subscriptions"`
+	}
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Subscriptions) != 3 {
+		t.Fatalf("expected 3 carried subscriptions, got %+v", result.Subscriptions)
+	}
+
+	// Ordered by method: the chat session only exists on main.
+	chat, gitC, list := result.Subscriptions[0], result.Subscriptions[1], result.Subscriptions[2]
+	if chat.Method != "chat.messages.subscribe" || chat.PrevID != chatSub.ID || chat.Reason != rpc.ErrNotFound || chat.Result != nil {
+		t.Errorf("chat = %+v, want not_found for %s", chat, chatSub.ID)
+	}
+	var newGit rpc.GitSubscribeResult
+	json.Unmarshal(gitC.Result, &newGit)
+	if gitC.Method != "git.subscribe" || gitC.PrevID != gitSub.ID || newGit.ID == "" || newGit.ID == gitSub.ID {
+		t.Errorf("git = %+v, want a new subscription replacing %s", gitC, gitSub.ID)
+	}
+	var newList rpc.SessionListSubscribeResult
+	json.Unmarshal(list.Result, &newList)
+	if list.Method != "session.list.subscribe" || list.PrevID != listSub.ID || newList.ID == "" {
+		t.Errorf("session list = %+v, want a new subscription replacing %s", list, listSub.ID)
+	}
+	if len(newList.Sessions) != 0 {
+		t.Errorf("expected the feature worktree's empty session list, got %d sessions", len(newList.Sessions))
+	}
+
+	// Carried subscriptions carry over again, and the failed one is gone.
+	resp = env.call("worktree.switch", rpc.WorktreeSwitchParams{Name: "", CarryOver: true})
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Subscriptions) != 2 || result.Subscriptions[0].PrevID != newGit.ID || result.Subscriptions[1].PrevID != newList.ID {
+		t.Errorf("expected git and session list to carry back, got %+v", result.Subscriptions)
+	}
+}
+
 func TestHandler_WorktreeSwitch_SameWorktree(t *testing.T) {
 	env := newTestEnv(t, &mockAgent{})
 
@@ -1423,6 +2229,108 @@ func TestHandler_WorktreeSwitch_NotFound(t *testing.T) {
 	}
 }
 
+func TestHandler_WorktreeAttach(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+	if resp := env.call("worktree.create", rpc.WorktreeCreateParams{Name: "feature", Branch: "feature-branch"}); resp.Error != nil {
+		t.Fatalf("create failed: %s", resp.Error.Message)
+	}
+
+	// Not attached yet
+	resp := env.call("session.create", map[string]string{"worktree": "feature"})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "worktree not attached") {
+		t.Fatalf("expected 'worktree not attached' error, got %+v", resp.Error)
+	}
+
+	resp = env.call("worktree.attach", rpc.WorktreeAttachParams{Name: "feature"})
+	if resp.Error != nil {
+		t.Fatalf("attach failed: %s", resp.Error.Message)
+	}
+	var attached rpc.WorktreeAttachResult
+	json.Unmarshal(resp.Result, &attached)
+	if attached.WorktreeName != "feature" || !strings.Contains(attached.WorkDir, "feature") {
+		t.Errorf("unexpected attach result %+v", attached)
+	}
+
+	// The "worktree" param routes to the attached worktree; without it the
+	// bound worktree is used.
+	if resp := env.call("session.create", map[string]string{"worktree": "feature"}); resp.Error != nil {
+		t.Fatalf("session.create on attached failed: %s", resp.Error.Message)
+	}
+	if resp := env.call("session.create", map[string]string{}); resp.Error != nil {
+		t.Fatalf("session.create on bound failed: %s", resp.Error.Message)
+	}
+
+	feature, err := env.worktreeManager.Get("feature")
+	if err != nil {
+		t.Fatalf("get feature: %v", err)
+	}
+	defer env.worktreeManager.Release(feature)
+	if sessions, _ := feature.SessionStore.List(); len(sessions) != 1 {
+		t.Errorf("expected 1 session in feature, got %d", len(sessions))
+	}
+	if sessions, _ := env.getMainWorktree().SessionStore.List(); len(sessions) != 1 {
+		t.Errorf("expected 1 session in main, got %d", len(sessions))
+	}
+
+	if resp := env.call("worktree.detach", rpc.WorktreeDetachParams{Name: ""}); resp.Error == nil {
+		t.Error("expected error detaching the bound worktree")
+	}
+	if resp := env.call("worktree.detach", rpc.WorktreeDetachParams{Name: "feature"}); resp.Error != nil {
+		t.Fatalf("detach failed: %s", resp.Error.Message)
+	}
+	if resp := env.call("session.create", map[string]string{"worktree": "feature"}); resp.Error == nil {
+		t.Error("expected error after detach")
+	}
+	if resp := env.call("worktree.detach", rpc.WorktreeDetachParams{Name: "feature"}); resp.Error == nil {
+		t.Error("expected error detaching twice")
+	}
+}
+
+func TestHandler_WorktreeAttach_SwitchPromotes(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+
+	env := newWorkDirTestEnv(t, dir)
+	if resp := env.call("worktree.create", rpc.WorktreeCreateParams{Name: "feature", Branch: "feature-branch"}); resp.Error != nil {
+		t.Fatalf("create failed: %s", resp.Error.Message)
+	}
+	if resp := env.call("worktree.attach", rpc.WorktreeAttachParams{Name: "feature"}); resp.Error != nil {
+		t.Fatalf("attach failed: %s", resp.Error.Message)
+	}
+	if resp := env.call("worktree.switch", rpc.WorktreeSwitchParams{Name: "feature"}); resp.Error != nil {
+		t.Fatalf("switch failed: %s", resp.Error.Message)
+	}
+
+	// feature is now bound, not attached; main is neither.
+	if resp := env.call("worktree.detach", rpc.WorktreeDetachParams{Name: "feature"}); resp.Error == nil {
+		t.Error("expected error detaching the newly bound worktree")
+	}
+	if resp := env.call("session.create", map[string]string{"worktree": "feature"}); resp.Error != nil {
+		t.Fatalf("session.create naming the bound worktree failed: %s", resp.Error.Message)
+	}
+
+	// Main ("") can be attached back alongside it.
+	if resp := env.call("session.create", map[string]string{"worktree": ""}); resp.Error == nil {
+		t.Error("expected main to be unreachable before attach")
+	}
+	if resp := env.call("worktree.attach", rpc.WorktreeAttachParams{Name: ""}); resp.Error != nil {
+		t.Fatalf("attach main failed: %s", resp.Error.Message)
+	}
+	if resp := env.call("session.create", map[string]string{"worktree": ""}); resp.Error != nil {
+		t.Fatalf("session.create on attached main failed: %s", resp.Error.Message)
+	}
+	if sessions, _ := env.getMainWorktree().SessionStore.List(); len(sessions) != 1 {
+		t.Errorf("expected 1 session in main, got %d", len(sessions))
+	}
+}
+
 func TestHandler_MissingParams(t *testing.T) {
 	env := newTestEnv(t, &mockAgent{})
 
@@ -1447,3 +2355,80 @@ func TestHandler_MissingParams(t *testing.T) {
 		t.Errorf("expected 'invalid params' error, got %q", resp.Error.Message)
 	}
 }
+
+func TestHandler_ChatQuick(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	wt := env.getMainWorktree()
+
+	resp := env.call("chat.quick", rpc.ChatQuickParams{Content: "what does main.go do?"})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var quick rpc.SessionListItem
+	if err := json.Unmarshal(resp.Result, &quick); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if !quick.Ephemeral {
+		t.Errorf("expected ephemeral session, got %+v", quick.SessionMeta)
+	}
+	if !wt.ProcessManager.HasProcess(quick.ID) {
+		t.Error("expected process to be started by the first message")
+	}
+
+	// Quick chats stay out of the session list until saved.
+	listResp := env.call("session.list.subscribe", nil)
+	var list rpc.SessionListSubscribeResult
+	if err := json.Unmarshal(listResp.Result, &list); err != nil {
+		t.Fatalf("failed to unmarshal list: %v", err)
+	}
+	if len(list.Sessions) != 0 {
+		t.Errorf("expected quick chat to be hidden from the list, got %d sessions", len(list.Sessions))
+	}
+
+	saveResp := env.call("chat.quick.save", rpc.ChatQuickSaveParams{SessionID: quick.ID, Title: "main.go overview"})
+	if saveResp.Error != nil {
+		t.Fatalf("save: %s", saveResp.Error.Message)
+	}
+	meta, _, _ := wt.SessionStore.Get(quick.ID)
+	if meta.Ephemeral || meta.Title != "main.go overview" {
+		t.Errorf("saved session = %+v, want regular session with new title", meta)
+	}
+
+	saveResp = env.call("chat.quick.save", rpc.ChatQuickSaveParams{SessionID: quick.ID})
+	if saveResp.Error == nil || saveResp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("second save error = %+v, want InvalidParams", saveResp.Error)
+	}
+}
+
+func TestHandler_ChatQuick_DiscardedOnProcessEnd(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	wt := env.getMainWorktree()
+
+	resp := env.call("chat.quick", rpc.ChatQuickParams{Content: "hi"})
+	var quick rpc.SessionListItem
+	if err := json.Unmarshal(resp.Result, &quick); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+
+	wt.ProcessManager.Close(quick.ID)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, found, _ := wt.SessionStore.Get(quick.ID); !found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected quick chat session to be deleted after its process ended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandler_ChatQuick_InvalidMode(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("chat.quick", rpc.ChatQuickParams{Mode: "turbo"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("error = %+v, want InvalidParams", resp.Error)
+	}
+}
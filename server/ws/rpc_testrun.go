@@ -0,0 +1,43 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/pockode/server/rpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (h *rpcMethodHandler) handleTestRunList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.TestRunListParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+	if params.Limit < 0 {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "limit", "limit must not be negative")
+		return
+	}
+
+	runs, err := h.testRunStore.List(params.ListQuery)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list test runs")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.TestRunListResult{Runs: runs}); err != nil {
+		h.log.Error("failed to send test run list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleTestRunSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	notifier := h.state.getNotifier()
+	id := h.testRunWatcher.Subscribe(notifier)
+	h.state.trackSubscription(id, h.testRunWatcher)
+	h.log.Debug("subscribed", "watcher", "test run", "watchId", id)
+
+	if err := conn.Reply(ctx, req.ID, rpc.TestRunSubscribeResult{ID: id}); err != nil {
+		h.log.Error("failed to send test run subscribe response", "error", err)
+	}
+}
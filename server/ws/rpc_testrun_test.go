@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/testrun"
+)
+
+func TestHandler_TestRunSubscribeAndList(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("testrun.subscribe", nil)
+	if resp.Error != nil {
+		t.Fatalf("subscribe: %s", resp.Error.Message)
+	}
+	var sub rpc.TestRunSubscribeResult
+	json.Unmarshal(resp.Result, &sub)
+
+	run, err := env.testRunStore.Report(env.ctx, testrun.Run{WorkID: "w1", SessionID: "s1", Suite: "unit", Failed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notif := env.readNotification()
+	if notif.Method != "testrun.reported" {
+		t.Fatalf("method = %q, want testrun.reported", notif.Method)
+	}
+	var params struct {
+		ID  string      `json:"id"`
+		Run testrun.Run `json:"run"`
+	}
+	json.Unmarshal(notif.Params, &params)
+	if params.ID != sub.ID || params.Run.ID != run.ID || params.Run.Status != testrun.StatusFailed {
+		t.Errorf("notification = %+v, want failed run %s on %s", params, run.ID, sub.ID)
+	}
+
+	if _, err := env.testRunStore.Report(env.ctx, testrun.Run{WorkID: "w2", Suite: "unit", Passed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	env.readNotification()
+
+	resp = env.call("testrun.list", rpc.TestRunListParams{ListQuery: testrun.ListQuery{WorkID: "w1"}})
+	if resp.Error != nil {
+		t.Fatalf("list: %s", resp.Error.Message)
+	}
+	var list rpc.TestRunListResult
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Runs) != 1 || list.Runs[0].ID != run.ID {
+		t.Errorf("runs = %+v, want only the w1 run", list.Runs)
+	}
+
+	resp = env.call("testrun.list", nil)
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Runs) != 2 {
+		t.Errorf("unfiltered runs = %d, want 2", len(list.Runs))
+	}
+
+	resp = env.call("testrun.list", rpc.TestRunListParams{ListQuery: testrun.ListQuery{Limit: -1}})
+	if resp.Error == nil {
+		t.Error("expected error for negative limit")
+	}
+}
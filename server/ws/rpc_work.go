@@ -3,40 +3,53 @@ package ws
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/snapshot"
 	"github.com/pockode/server/work"
+	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-// replyWorkError classifies work store errors into JSON-RPC error codes.
+// replyWorkError classifies work store errors into error reasons.
 func (h *rpcMethodHandler) replyWorkError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error, fallbackMsg string) {
 	if errors.Is(err, work.ErrWorkNotFound) {
-		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, "work not found")
+		h.replyError(ctx, conn, id, rpc.ErrNotFound, "work not found")
+	} else if errors.Is(err, work.ErrHookRefused) {
+		h.replyError(ctx, conn, id, rpc.ErrRefused, err.Error())
+	} else if errors.Is(err, work.ErrInvalidTransition) {
+		h.replyError(ctx, conn, id, rpc.ErrInvalidTransition, err.Error())
 	} else if errors.Is(err, work.ErrInvalidWork) {
-		h.replyError(ctx, conn, id, jsonrpc2.CodeInvalidParams, err.Error())
+		h.replyError(ctx, conn, id, rpc.ErrInvalidParams, err.Error())
 	} else {
-		h.replyError(ctx, conn, id, jsonrpc2.CodeInternalError, fallbackMsg)
+		h.replyError(ctx, conn, id, rpc.ErrInternal, fallbackMsg)
 	}
 }
 
 func (h *rpcMethodHandler) handleWorkCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkCreateParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	// Validate agent_role_id is provided and exists
-	if params.AgentRoleID == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "agent_role_id is required")
-		return
+	// Validate agent_role_id exists if given; the store falls back to the
+	// default agent role otherwise.
+	if params.AgentRoleID != "" {
+		if _, found, err := h.agentRoleStore.Get(params.AgentRoleID); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to validate agent role")
+			return
+		} else if !found {
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrNotFound, "agent_role_id", "agent role not found: "+params.AgentRoleID)
+			return
+		}
 	}
-	if _, found, err := h.agentRoleStore.Get(params.AgentRoleID); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to validate agent role")
-		return
-	} else if !found {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "agent role not found: "+params.AgentRoleID)
+
+	dueAt, err := work.ParseDueAt(params.DueAt)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
 		return
 	}
 
@@ -46,6 +59,7 @@ func (h *rpcMethodHandler) handleWorkCreate(ctx context.Context, conn *jsonrpc2.
 		AgentRoleID: params.AgentRoleID,
 		Title:       params.Title,
 		Body:        params.Body,
+		DueAt:       dueAt,
 	})
 	if err != nil {
 		h.replyWorkError(ctx, conn, req.ID, err, "failed to create work")
@@ -59,28 +73,85 @@ func (h *rpcMethodHandler) handleWorkCreate(ctx context.Context, conn *jsonrpc2.
 	}
 }
 
+func (h *rpcMethodHandler) handleWorkBulkCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkBulkCreateParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	items := make([]work.Work, len(params.Items))
+	for i, p := range params.Items {
+		if p.AgentRoleID != "" {
+			if _, found, err := h.agentRoleStore.Get(p.AgentRoleID); err != nil {
+				h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to validate agent role")
+				return
+			} else if !found {
+				h.replyFieldError(ctx, conn, req.ID, rpc.ErrNotFound, "agent_role_id", "agent role not found: "+p.AgentRoleID)
+				return
+			}
+		}
+		dueAt, err := work.ParseDueAt(p.DueAt)
+		if err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+			return
+		}
+		items[i] = work.Work{
+			Type:        p.Type,
+			ParentID:    p.ParentID,
+			AgentRoleID: p.AgentRoleID,
+			Title:       p.Title,
+			Body:        p.Body,
+			DueAt:       dueAt,
+		}
+	}
+
+	created, err := h.workStore.CreateAll(ctx, items)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to create work")
+		return
+	}
+
+	h.log.Info("works created", "count", len(created))
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorkBulkCreateResult{Items: created}); err != nil {
+		h.log.Error("failed to send work bulk create response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleWorkUpdate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkUpdateParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	// Validate agent_role_id exists if specified
 	if params.AgentRoleID != nil && *params.AgentRoleID != "" {
 		if _, found, err := h.agentRoleStore.Get(*params.AgentRoleID); err != nil {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to validate agent role")
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to validate agent role")
 			return
 		} else if !found {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "agent role not found: "+*params.AgentRoleID)
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrNotFound, "agent_role_id", "agent role not found: "+*params.AgentRoleID)
 			return
 		}
 	}
 
 	fields := work.UpdateFields{
-		Title:       params.Title,
-		Body:        params.Body,
-		AgentRoleID: params.AgentRoleID,
+		Title:           params.Title,
+		Body:            params.Body,
+		AgentRoleID:     params.AgentRoleID,
+		EstimateMinutes: params.EstimateMinutes,
+		Budget:          params.Budget,
+		ClosePolicy:     params.ClosePolicy,
+	}
+	if params.DueAt != nil {
+		dueAt, err := work.ParseDueAt(*params.DueAt)
+		if err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, err.Error())
+			return
+		}
+		fields.DueAt = &dueAt
 	}
 	if err := h.workStore.Update(ctx, params.ID, fields); err != nil {
 		h.replyWorkError(ctx, conn, req.ID, err, "failed to update work")
@@ -97,7 +168,7 @@ func (h *rpcMethodHandler) handleWorkUpdate(ctx context.Context, conn *jsonrpc2.
 func (h *rpcMethodHandler) handleWorkDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkDeleteParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
@@ -162,20 +233,26 @@ func (h *rpcMethodHandler) deleteWorkSessions(ctx context.Context, sessionIDs []
 func (h *rpcMethodHandler) handleWorkStart(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkStartParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
-	w, err := h.workOps.StartWork(ctx, params.ID)
-	if err != nil {
-		// ErrWorkNotFound / ErrInvalidWork map to client errors; a kickoff failure
-		// (e.g. "send kickoff message: ...") is surfaced verbatim so the user sees
-		// why the agent did not start.
-		if errors.Is(err, work.ErrWorkNotFound) || errors.Is(err, work.ErrInvalidWork) {
-			h.replyWorkError(ctx, conn, req.ID, err, "failed to start work")
-		} else {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+	if params.AgentRoleID != "" {
+		if _, found, err := h.agentRoleStore.Get(params.AgentRoleID); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to validate agent role")
+			return
+		} else if !found {
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrNotFound, "agent_role_id", "agent role not found: "+params.AgentRoleID)
+			return
 		}
+	}
+
+	w, err := h.workOps.StartWork(ctx, params.ID, work.StartOptions{
+		AgentRoleID: params.AgentRoleID,
+		Mode:        params.Mode,
+	})
+	if err != nil {
+		h.replyStartError(ctx, conn, req.ID, err, "failed to start work")
 		return
 	}
 
@@ -189,7 +266,7 @@ func (h *rpcMethodHandler) handleWorkStart(ctx context.Context, conn *jsonrpc2.C
 func (h *rpcMethodHandler) handleWorkStop(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkStopParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
@@ -205,10 +282,64 @@ func (h *rpcMethodHandler) handleWorkStop(ctx context.Context, conn *jsonrpc2.Co
 	}
 }
 
+// replyStartError reports a failure from an operation that launches or resumes
+// a work session. ErrWorkNotFound / ErrInvalidWork / ErrHookRefused map to
+// client errors; a kickoff failure (e.g. "send kickoff message: ...") is
+// surfaced verbatim so the user sees why the agent did not start.
+func (h *rpcMethodHandler) replyStartError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error, fallback string) {
+	if errors.Is(err, work.ErrWorkNotFound) || errors.Is(err, work.ErrInvalidWork) || errors.Is(err, work.ErrHookRefused) {
+		h.replyWorkError(ctx, conn, id, err, fallback)
+	} else if errors.Is(err, work.ErrSessionTrashed) {
+		h.replyError(ctx, conn, id, rpc.ErrInvalidTransition, err.Error())
+	} else {
+		h.replyError(ctx, conn, id, rpc.ErrInternal, err.Error())
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkPlanApprove(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkPlanApproveParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	w, err := h.workOps.ApprovePlan(ctx, params.ID, params.Mode)
+	if err != nil {
+		h.replyStartError(ctx, conn, req.ID, err, "failed to approve plan")
+		return
+	}
+
+	h.log.Info("work plan approved", "workId", w.ID, "sessionId", w.SessionID)
+
+	if err := conn.Reply(ctx, req.ID, w); err != nil {
+		h.log.Error("failed to send work plan approve response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkPlanReject(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkPlanRejectParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	w, err := h.workOps.RejectPlan(ctx, params.ID, params.Feedback)
+	if err != nil {
+		h.replyStartError(ctx, conn, req.ID, err, "failed to reject plan")
+		return
+	}
+
+	h.log.Info("work plan rejected", "workId", w.ID, "sessionId", w.SessionID)
+
+	if err := conn.Reply(ctx, req.ID, w); err != nil {
+		h.log.Error("failed to send work plan reject response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleWorkReopen(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkReopenParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
@@ -224,20 +355,299 @@ func (h *rpcMethodHandler) handleWorkReopen(ctx context.Context, conn *jsonrpc2.
 	}
 }
 
+func (h *rpcMethodHandler) handleWorkReset(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkResetParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	if err := h.workStore.Reset(ctx, params.ID); err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to reset work")
+		return
+	}
+
+	h.log.Info("work reset", "workId", params.ID)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send work reset response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkCancel(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkCancelParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	w, err := h.workOps.CancelWork(ctx, params.ID, params.Reason)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to cancel work")
+		return
+	}
+
+	h.log.Info("work cancelled", "workId", params.ID)
+
+	if err := conn.Reply(ctx, req.ID, w); err != nil {
+		h.log.Error("failed to send work cancel response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkWatch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkWatchParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	w, err := h.workStore.SetNotify(ctx, params.ID, params.Notify)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to update work")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, w); err != nil {
+		h.log.Error("failed to send work watch response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkApproveClose(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkApproveCloseParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	w, err := h.workStore.ApproveClose(ctx, params.ID)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to close work")
+		return
+	}
+
+	h.log.Info("work close approved", "workId", params.ID)
+
+	if err := conn.Reply(ctx, req.ID, w); err != nil {
+		h.log.Error("failed to send work approve close response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkBulk(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkBulkParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	for _, id := range work.BulkAgentRoleIDs(params.Operations) {
+		if _, found, err := h.agentRoleStore.Get(id); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to validate agent role")
+			return
+		} else if !found {
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrNotFound, "agent_role_id", "agent role not found: "+id)
+			return
+		}
+	}
+
+	for _, op := range params.Operations {
+		if op.Action == work.BulkDelete {
+			h.snapshotBefore(snapshot.LabelBulkDelete)
+			break
+		}
+	}
+
+	results, err := h.workOps.Bulk(ctx, params.Operations)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to apply bulk operations")
+		return
+	}
+
+	// Deleted items take their sessions with them, as in work.delete.
+	var sessionIDs []string
+	failed := 0
+	for _, r := range results {
+		if !r.OK {
+			failed++
+			continue
+		}
+		if r.Action == work.BulkDelete {
+			for _, w := range r.Affected {
+				if w.SessionID != "" {
+					sessionIDs = append(sessionIDs, w.SessionID)
+				}
+			}
+		}
+	}
+	h.deleteWorkSessions(ctx, sessionIDs)
+
+	h.log.Info("work bulk applied", "operations", len(results), "failed", failed)
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorkBulkResult{Results: results}); err != nil {
+		h.log.Error("failed to send work bulk response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkReport(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkReportParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	works, err := h.workStore.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+		return
+	}
+
+	report, ok := work.BuildEffortReport(works, params.ID)
+	if !ok {
+		h.replyWorkError(ctx, conn, req.ID, work.ErrWorkNotFound, "failed to build work report")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, report); err != nil {
+		h.log.Error("failed to send work report response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkListParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	works, err := h.workStore.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+		return
+	}
+
+	page, err := work.Query(works, params.ListQuery)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to list works")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorkListResult{Items: page.Items, Total: page.Total}); err != nil {
+		h.log.Error("failed to send work list response", "error", err)
+	}
+}
+
+// handleWorkBoard lists the work of the main store and of every worktree
+// with its own store as one board, so the dashboard sees isolated
+// experiments alongside the main work.
+func (h *rpcMethodHandler) handleWorkBoard(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkBoardParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+
+	boards := []worktree.Board{{Store: h.workStore}}
+	if stores := h.worktreeManager.WorkStores(); stores != nil {
+		var err error
+		if boards, err = stores.All(); err != nil {
+			h.log.Error("failed to list worktree work stores", "error", err)
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+			return
+		}
+	}
+
+	var works []work.Work
+	worktreeOf := make(map[string]string)
+	for _, b := range boards {
+		items, err := b.Store.List()
+		if err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+			return
+		}
+		for _, w := range items {
+			worktreeOf[w.ID] = b.Worktree
+		}
+		works = append(works, items...)
+	}
+
+	page, err := work.Query(works, params.ListQuery)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to list works")
+		return
+	}
+
+	result := rpc.WorkBoardResult{Items: make([]rpc.WorkBoardItem, len(page.Items)), Total: page.Total}
+	for i, w := range page.Items {
+		result.Items[i] = rpc.WorkBoardItem{Work: w, Worktree: worktreeOf[w.ID]}
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send work board response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkSearch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkSearchParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	works, err := h.workStore.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+		return
+	}
+
+	result, err := work.Search(works, params.SearchQuery)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to search works")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorkSearchResult{Hits: result.Hits, Total: result.Total}); err != nil {
+		h.log.Error("failed to send work search response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkNext(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkNextParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	works, err := h.workStore.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+		return
+	}
+
+	var result rpc.WorkNextResult
+	if w, found := work.Next(works, params.NextQuery, time.Now()); found {
+		result.Work = &w
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send work next response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleWorkCommentList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkCommentListParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 	if params.WorkID == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "work_id is required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "work_id", "work_id is required")
 		return
 	}
 
 	comments, err := h.workStore.ListComments(params.WorkID)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to list comments")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list comments")
 		return
 	}
 
@@ -246,28 +656,84 @@ func (h *rpcMethodHandler) handleWorkCommentList(ctx context.Context, conn *json
 	}
 }
 
+func (h *rpcMethodHandler) handleWorkFiles(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkFilesParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if params.WorkID == "" {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "work_id", "work_id is required")
+		return
+	}
+
+	works, err := h.workStore.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+		return
+	}
+	files, byWork, found := work.CollectModifiedFiles(works, params.WorkID)
+	if !found {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "work not found")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorkFilesResult{Files: files, ByWork: byWork}); err != nil {
+		h.log.Error("failed to send work files response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkStats(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkStatsParams
+	if req.Params != nil {
+		if err := unmarshalParams(req, &params); err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+			return
+		}
+	}
+	if params.Weeks < 0 || params.Weeks > work.MaxStatsWeeks {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "weeks", fmt.Sprintf("weeks must be between 0 and %d", work.MaxStatsWeeks))
+		return
+	}
+	if params.Type != "" && params.Type != work.WorkTypeStory && params.Type != work.WorkTypeTask {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "type", "invalid type")
+		return
+	}
+
+	works, err := h.workStore.List()
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to list works")
+		return
+	}
+	stats := work.ComputeStats(works, time.Now(), work.StatsOptions{Weeks: params.Weeks, Type: params.Type})
+
+	if err := conn.Reply(ctx, req.ID, stats); err != nil {
+		h.log.Error("failed to send work stats response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleWorkCommentUpdate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkCommentUpdateParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 	if params.ID == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "id is required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "id", "id is required")
 		return
 	}
 	if params.Body == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "body is required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "body", "body is required")
 		return
 	}
 
 	comment, err := h.workStore.UpdateComment(ctx, params.ID, params.Body)
 	if err != nil {
 		if errors.Is(err, work.ErrCommentNotFound) {
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "comment not found")
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "comment not found")
 			return
 		}
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to update comment")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to update comment")
 		return
 	}
 
@@ -281,11 +747,11 @@ func (h *rpcMethodHandler) handleWorkCommentUpdate(ctx context.Context, conn *js
 func (h *rpcMethodHandler) handleWorkDetailSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorkDetailSubscribeParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 	if params.WorkID == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "work_id is required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "work_id", "work_id is required")
 		return
 	}
 
@@ -309,11 +775,36 @@ func (h *rpcMethodHandler) handleWorkDetailSubscribe(ctx context.Context, conn *
 	}
 }
 
+func (h *rpcMethodHandler) handleWorkSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkSubscribeParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if params.WorkID == "" {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "work_id", "work_id is required")
+		return
+	}
+
+	notifier := h.state.getNotifier()
+	id, items, err := h.workSubtreeWatcher.Subscribe(params.WorkID, params.IncludeChildren, notifier)
+	if err != nil {
+		h.replyWorkError(ctx, conn, req.ID, err, "failed to subscribe")
+		return
+	}
+	h.state.trackSubscription(id, h.workSubtreeWatcher)
+	h.log.Debug("subscribed", "watcher", "work subtree", "watchId", id, "workId", params.WorkID, "includeChildren", params.IncludeChildren)
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorkSubscribeResult{ID: id, Items: items}); err != nil {
+		h.log.Error("failed to send work subscribe response", "error", err)
+	}
+}
+
 func (h *rpcMethodHandler) handleWorkListSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	notifier := h.state.getNotifier()
 	id, items, err := h.workListWatcher.Subscribe(notifier)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, "failed to subscribe")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to subscribe")
 		return
 	}
 	h.state.trackSubscription(id, h.workListWatcher)
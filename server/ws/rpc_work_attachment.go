@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/work"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// attachmentWork checks attachments are enabled and the work item exists,
+// replying with an error and returning false otherwise.
+func (h *rpcMethodHandler) attachmentWork(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, workID string) bool {
+	if h.attachments == nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "attachments are not available")
+		return false
+	}
+	if workID == "" {
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "work_id", "work_id is required")
+		return false
+	}
+	_, found, err := h.workStore.Get(workID)
+	if err != nil {
+		h.log.Error("failed to get work", "workId", workID, "error", err)
+		h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to get work")
+		return false
+	}
+	if !found {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "work not found")
+		return false
+	}
+	return true
+}
+
+func (h *rpcMethodHandler) replyAttachmentError(ctx context.Context, conn *jsonrpc2.Conn, id jsonrpc2.ID, err error, message string) {
+	switch {
+	case errors.Is(err, work.ErrAttachmentNotFound):
+		h.replyError(ctx, conn, id, rpc.ErrNotFound, "attachment not found")
+	case errors.Is(err, work.ErrInvalidAttachment):
+		h.replyError(ctx, conn, id, rpc.ErrInvalidParams, err.Error())
+	default:
+		h.log.Error(message, "error", err)
+		h.replyError(ctx, conn, id, rpc.ErrInternal, message)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkAttachmentUpload(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkAttachmentUploadParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if !h.attachmentWork(ctx, conn, req, params.WorkID) {
+		return
+	}
+
+	a, err := h.attachments.Save(params.WorkID, params.Name, params.Data)
+	if err != nil {
+		h.replyAttachmentError(ctx, conn, req.ID, err, "failed to save attachment")
+		return
+	}
+	h.log.Info("work attachment uploaded", "workId", params.WorkID, "name", a.Name, "size", a.Size)
+
+	if err := conn.Reply(ctx, req.ID, a); err != nil {
+		h.log.Error("failed to send work attachment upload response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkAttachmentList(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkAttachmentListParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if !h.attachmentWork(ctx, conn, req, params.WorkID) {
+		return
+	}
+
+	list, err := h.attachments.List(params.WorkID)
+	if err != nil {
+		h.replyAttachmentError(ctx, conn, req.ID, err, "failed to list attachments")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorkAttachmentListResult{Attachments: list}); err != nil {
+		h.log.Error("failed to send work attachment list response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkAttachmentGet(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkAttachmentParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if !h.attachmentWork(ctx, conn, req, params.WorkID) {
+		return
+	}
+
+	a, data, err := h.attachments.Read(params.WorkID, params.Name)
+	if err != nil {
+		h.replyAttachmentError(ctx, conn, req.ID, err, "failed to read attachment")
+		return
+	}
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorkAttachmentGetResult{Attachment: a, Data: data}); err != nil {
+		h.log.Error("failed to send work attachment get response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorkAttachmentDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorkAttachmentParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if !h.attachmentWork(ctx, conn, req, params.WorkID) {
+		return
+	}
+
+	if err := h.attachments.Delete(params.WorkID, params.Name); err != nil {
+		h.replyAttachmentError(ctx, conn, req.ID, err, "failed to delete attachment")
+		return
+	}
+	h.log.Info("work attachment deleted", "workId", params.WorkID, "name", params.Name)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send work attachment delete response", "error", err)
+	}
+}
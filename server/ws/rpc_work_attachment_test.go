@@ -0,0 +1,77 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/work"
+)
+
+func TestHandler_WorkAttachments(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	env.handler.SetAttachmentStore(work.NewAttachmentStore(t.TempDir()))
+
+	createResp := env.call("work.create", rpc.WorkCreateParams{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Story"})
+	var created work.Work
+	if err := json.Unmarshal(createResp.Result, &created); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	resp := env.call("work.attachment.upload", rpc.WorkAttachmentUploadParams{WorkID: created.ShortID, Name: "spec.md", Data: []byte("# Spec")})
+	if resp.Error != nil {
+		t.Fatalf("upload: %s", resp.Error.Message)
+	}
+	var uploaded work.Attachment
+	json.Unmarshal(resp.Result, &uploaded)
+	if uploaded.Name != "spec.md" || uploaded.Size != 6 {
+		t.Errorf("uploaded = %+v", uploaded)
+	}
+
+	resp = env.call("work.attachment.list", rpc.WorkAttachmentListParams{WorkID: created.ID})
+	var list rpc.WorkAttachmentListResult
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Attachments) != 1 || list.Attachments[0].Name != "spec.md" {
+		t.Errorf("list = %+v", list)
+	}
+
+	resp = env.call("work.attachment.get", rpc.WorkAttachmentParams{WorkID: created.ID, Name: "spec.md"})
+	var got rpc.WorkAttachmentGetResult
+	json.Unmarshal(resp.Result, &got)
+	if string(got.Data) != "# Spec" {
+		t.Errorf("get data = %q", got.Data)
+	}
+
+	// Larger than the WebSocket library's default 32 KiB message limit.
+	resp = env.call("work.attachment.upload", rpc.WorkAttachmentUploadParams{WorkID: created.ID, Name: "mock.png", Data: make([]byte, 100<<10)})
+	if resp.Error != nil {
+		t.Fatalf("upload 100 KiB: %s", resp.Error.Message)
+	}
+
+	resp = env.call("work.attachment.upload", rpc.WorkAttachmentUploadParams{WorkID: created.ID, Name: "../x", Data: []byte("x")})
+	if resp.Error == nil || !strings.Contains(resp.Error.Message, "plain file name") {
+		t.Errorf("bad name: error = %+v", resp.Error)
+	}
+	resp = env.call("work.attachment.upload", rpc.WorkAttachmentUploadParams{WorkID: "missing", Name: "a.txt", Data: []byte("x")})
+	if resp.Error == nil || resp.Error.Message != "work not found" {
+		t.Errorf("unknown work: error = %+v", resp.Error)
+	}
+
+	if resp := env.call("work.attachment.delete", rpc.WorkAttachmentParams{WorkID: created.ID, Name: "spec.md"}); resp.Error != nil {
+		t.Fatalf("delete: %s", resp.Error.Message)
+	}
+	resp = env.call("work.attachment.get", rpc.WorkAttachmentParams{WorkID: created.ID, Name: "spec.md"})
+	if resp.Error == nil || resp.Error.Message != "attachment not found" {
+		t.Errorf("get deleted: error = %+v", resp.Error)
+	}
+}
+
+func TestHandler_WorkAttachments_Unavailable(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("work.attachment.list", rpc.WorkAttachmentListParams{WorkID: "w"})
+	if resp.Error == nil || resp.Error.Message != "attachments are not available" {
+		t.Errorf("error = %+v, want attachments are not available", resp.Error)
+	}
+}
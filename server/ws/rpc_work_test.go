@@ -1,13 +1,19 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/pockode/server/agentrole"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/session"
 	"github.com/pockode/server/work"
+	"github.com/sourcegraph/jsonrpc2"
 )
 
 // --- work.create ---
@@ -186,6 +192,32 @@ func TestHandler_WorkUpdate_Title(t *testing.T) {
 	}
 }
 
+func TestHandler_WorkUpdate_ByShortID(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	createResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Original title",
+	})
+	var created work.Work
+	if err := json.Unmarshal(createResp.Result, &created); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if created.ShortID == "" {
+		t.Fatal("expected a short ID")
+	}
+
+	newTitle := "Updated title"
+	resp := env.call("work.update", rpc.WorkUpdateParams{ID: strings.ToLower(created.ShortID), Title: &newTitle})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	if w, _, _ := env.workStore.Get(created.ID); w.Title != newTitle {
+		t.Errorf("title = %q, want %q", w.Title, newTitle)
+	}
+}
+
 func TestHandler_WorkUpdate_NotFound(t *testing.T) {
 	env := newTestEnv(t, &mockAgent{})
 
@@ -200,6 +232,41 @@ func TestHandler_WorkUpdate_NotFound(t *testing.T) {
 	}
 }
 
+func TestHandler_WorkDueAt(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	createResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Ship it",
+		DueAt:       "2026-03-01T17:00:00Z",
+	})
+	if createResp.Error != nil {
+		t.Fatalf("create: %s", createResp.Error.Message)
+	}
+	var created work.Work
+	json.Unmarshal(createResp.Result, &created)
+	if want := time.Date(2026, 3, 1, 17, 0, 0, 0, time.UTC); !created.DueAt.Equal(want) {
+		t.Errorf("due_at = %v, want %v", created.DueAt, want)
+	}
+
+	bad := "tomorrow"
+	resp := env.call("work.update", rpc.WorkUpdateParams{ID: created.ID, DueAt: &bad})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("malformed due_at: got %+v, want invalid params", resp.Error)
+	}
+
+	empty := ""
+	resp = env.call("work.update", rpc.WorkUpdateParams{ID: created.ID, DueAt: &empty})
+	if resp.Error != nil {
+		t.Fatalf("clear: %s", resp.Error.Message)
+	}
+	w, _, _ := env.workStore.Get(created.ID)
+	if !w.DueAt.IsZero() {
+		t.Errorf("due_at = %v after clearing", w.DueAt)
+	}
+}
+
 // --- work.delete ---
 
 func TestHandler_WorkDelete(t *testing.T) {
@@ -359,9 +426,7 @@ func TestHandler_WorkStart(t *testing.T) {
 	}
 
 	// Verify kickoff message references agent role ID (not inline prompt)
-	mock.mu.Lock()
-	msgs := mock.messages
-	mock.mu.Unlock()
+	msgs := mock.waitMessages(1)
 	if len(msgs) == 0 {
 		t.Fatal("expected at least one message sent to agent")
 	}
@@ -371,6 +436,295 @@ func TestHandler_WorkStart(t *testing.T) {
 	}
 }
 
+func TestHandler_WorkStart_Overrides(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	roleResp := env.call("agent_role.create", rpc.AgentRoleCreateParams{Name: "Planner", RolePrompt: "Plan."})
+	if roleResp.Error != nil {
+		t.Fatalf("create role: %s", roleResp.Error.Message)
+	}
+	var planner agentrole.AgentRole
+	json.Unmarshal(roleResp.Result, &planner)
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Story",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+
+	resp := env.call("work.start", rpc.WorkStartParams{ID: story.ID, AgentRoleID: planner.ID, Mode: session.ModeYolo})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result work.Work
+	json.Unmarshal(resp.Result, &result)
+
+	if result.AgentRoleID != env.testRoleID {
+		t.Errorf("agent_role_id = %q, want %q unchanged", result.AgentRoleID, env.testRoleID)
+	}
+	if result.SessionAgentRoleID != planner.ID {
+		t.Errorf("session_agent_role_id = %q, want %q", result.SessionAgentRoleID, planner.ID)
+	}
+
+	mainWt, err := env.worktreeManager.Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.worktreeManager.Release(mainWt)
+	meta, found, err := mainWt.SessionStore.Get(result.SessionID)
+	if err != nil || !found {
+		t.Fatalf("session not found: %v", err)
+	}
+	if meta.Mode != session.ModeYolo {
+		t.Errorf("session mode = %q, want yolo", meta.Mode)
+	}
+	if meta.AgentRoleID != planner.ID {
+		t.Errorf("session agent_role_id = %q, want %q", meta.AgentRoleID, planner.ID)
+	}
+}
+
+func TestHandler_WorkStart_RoleAgentType(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	if resp := env.call("agent_role.create", rpc.AgentRoleCreateParams{Name: "Bad", AgentType: "gpt"}); resp.Error == nil {
+		t.Error("agent_role.create accepted an unknown agent type")
+	}
+	roleResp := env.call("agent_role.create", rpc.AgentRoleCreateParams{Name: "Codex Dev", RolePrompt: "Build.", AgentType: session.AgentTypeCodex})
+	if roleResp.Error != nil {
+		t.Fatalf("create role: %s", roleResp.Error.Message)
+	}
+	var codexRole agentrole.AgentRole
+	json.Unmarshal(roleResp.Result, &codexRole)
+
+	agentTypeOf := func(roleID string) session.AgentType {
+		t.Helper()
+		createResp := env.call("work.create", rpc.WorkCreateParams{Type: work.WorkTypeStory, AgentRoleID: roleID, Title: "Story"})
+		var w work.Work
+		json.Unmarshal(createResp.Result, &w)
+		resp := env.call("work.start", rpc.WorkStartParams{ID: w.ID})
+		if resp.Error != nil {
+			t.Fatalf("work.start: %s", resp.Error.Message)
+		}
+		json.Unmarshal(resp.Result, &w)
+		wt := env.getMainWorktree()
+		defer env.worktreeManager.Release(wt)
+		meta, found, err := wt.SessionStore.Get(w.SessionID)
+		if err != nil || !found {
+			t.Fatalf("session not found: %v", err)
+		}
+		return meta.AgentType
+	}
+
+	if got := agentTypeOf(codexRole.ID); got != session.AgentTypeCodex {
+		t.Errorf("codex role session agent_type = %q, want codex", got)
+	}
+	if got := agentTypeOf(env.testRoleID); got != session.AgentTypeClaude {
+		t.Errorf("default role session agent_type = %q, want claude", got)
+	}
+}
+
+func TestHandler_WorkStart_UnknownRoleOverride(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Story",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+
+	resp := env.call("work.start", rpc.WorkStartParams{ID: story.ID, AgentRoleID: "missing"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params error, got %+v", resp)
+	}
+}
+
+func TestHandler_WorkSessionTrashed(t *testing.T) {
+	mock := &mockAgent{}
+	env := newTestEnv(t, mock)
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Story",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+	startResp := env.call("work.start", rpc.WorkStartParams{ID: story.ID})
+	var started work.Work
+	json.Unmarshal(startResp.Result, &started)
+	mock.waitMessages(1)
+
+	if resp := env.call("session.delete", rpc.SessionDeleteParams{SessionID: started.SessionID}); resp.Error != nil {
+		t.Fatalf("session.delete: %s", resp.Error.Message)
+	}
+	got, _, _ := env.workStore.Get(story.ID)
+	if got.Status != work.StatusStopped {
+		t.Errorf("work status = %s, want stopped once its session is trashed", got.Status)
+	}
+
+	trashResp := env.call("session.trash.list", nil)
+	var trash rpc.SessionTrashListResult
+	json.Unmarshal(trashResp.Result, &trash)
+	if len(trash.Sessions) != 1 || trash.Sessions[0].ID != started.SessionID {
+		t.Fatalf("trash = %+v, want the work session", trash)
+	}
+
+	// Starting again must not replace the trashed session.
+	resp := env.call("work.start", rpc.WorkStartParams{ID: story.ID})
+	if data := errorData(t, resp); data.Reason != rpc.ErrInvalidTransition {
+		t.Errorf("work.start reason = %q, want %q", data.Reason, rpc.ErrInvalidTransition)
+	}
+
+	if resp := env.call("session.restore", rpc.SessionRestoreParams{SessionID: started.SessionID}); resp.Error != nil {
+		t.Fatalf("session.restore: %s", resp.Error.Message)
+	}
+	if resp := env.call("work.start", rpc.WorkStartParams{ID: story.ID}); resp.Error != nil {
+		t.Fatalf("work.start after restore: %s", resp.Error.Message)
+	}
+}
+
+func TestHandler_WorkReset(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Story",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+	env.workStore.Start(bgCtx, story.ID, "gone")
+	env.workStore.Stop(bgCtx, story.ID)
+
+	if resp := env.call("work.reset", rpc.WorkResetParams{ID: story.ID}); resp.Error != nil {
+		t.Fatalf("work.reset: %s", resp.Error.Message)
+	}
+	got, _, _ := env.workStore.Get(story.ID)
+	if got.Status != work.StatusOpen || got.SessionID != "" {
+		t.Errorf("work = %s/%q, want open without a session", got.Status, got.SessionID)
+	}
+}
+
+func TestHandler_WorkErrorReasons(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Story",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+
+	tests := []struct {
+		name   string
+		method string
+		params any
+		code   int64
+		reason rpc.ErrorReason
+		field  string
+	}{
+		{"missing work", "work.reopen", rpc.WorkReopenParams{ID: "missing"}, jsonrpc2.CodeInvalidParams, rpc.ErrNotFound, ""},
+		{"reopen open work", "work.reopen", rpc.WorkReopenParams{ID: story.ID}, jsonrpc2.CodeInvalidParams, rpc.ErrInvalidTransition, ""},
+		{"missing role", "work.start", rpc.WorkStartParams{ID: story.ID, AgentRoleID: "missing"}, jsonrpc2.CodeInvalidParams, rpc.ErrNotFound, "agent_role_id"},
+		{"empty title", "work.create", rpc.WorkCreateParams{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID}, jsonrpc2.CodeInvalidParams, rpc.ErrInvalidParams, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := env.call(tt.method, tt.params)
+			if resp.Error == nil || resp.Error.Code != tt.code {
+				t.Fatalf("error = %+v, want code %d", resp.Error, tt.code)
+			}
+			data := errorData(t, resp)
+			if data.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", data.Reason, tt.reason)
+			}
+			var field string
+			if len(data.Errors) > 0 {
+				field = data.Errors[0].Field
+			}
+			if field != tt.field {
+				t.Errorf("field = %q, want %q", field, tt.field)
+			}
+		})
+	}
+}
+
+func TestHandler_WorkPlanApproval(t *testing.T) {
+	mock := &mockAgent{}
+	env := newTestEnv(t, mock)
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Story",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+
+	resp := env.call("work.start", rpc.WorkStartParams{ID: story.ID, Mode: session.ModePlan})
+	if resp.Error != nil {
+		t.Fatalf("start: %s", resp.Error.Message)
+	}
+	var started work.Work
+	json.Unmarshal(resp.Result, &started)
+
+	sessionMode := func() session.Mode {
+		t.Helper()
+		mainWt, err := env.worktreeManager.Get("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer env.worktreeManager.Release(mainWt)
+		meta, _, _ := mainWt.SessionStore.Get(started.SessionID)
+		return meta.Mode
+	}
+	if got := sessionMode(); got != session.ModePlan {
+		t.Errorf("session mode = %q, want plan", got)
+	}
+
+	// Approving before a plan is submitted is rejected.
+	resp = env.call("work.plan.approve", rpc.WorkPlanApproveParams{ID: story.ID})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("approve without plan: expected invalid params, got %+v", resp)
+	}
+
+	if _, err := env.workStore.SubmitPlan(context.Background(), story.ID, "1. ship it"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = env.call("work.plan.approve", rpc.WorkPlanApproveParams{ID: story.ID})
+	if resp.Error != nil {
+		t.Fatalf("approve: %s", resp.Error.Message)
+	}
+	var approved work.Work
+	json.Unmarshal(resp.Result, &approved)
+	if approved.Status != work.StatusInProgress || approved.Plan == nil || approved.Plan.Status != work.PlanApproved {
+		t.Errorf("approved = %+v, want in_progress with approved plan", approved)
+	}
+	if got := sessionMode(); got != session.ModeDefault {
+		t.Errorf("session mode = %q, want default after approval", got)
+	}
+
+	msgs := mock.waitMessages(2)
+	if len(msgs) < 2 || !strings.Contains(msgs[len(msgs)-1], "1. ship it") {
+		t.Errorf("expected execution kickoff with the approved plan, got %q", msgs)
+	}
+}
+
+func TestHandler_WorkPlanReject_RequiresFeedback(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("work.plan.reject", rpc.WorkPlanRejectParams{ID: "any"})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params error, got %+v", resp)
+	}
+}
+
 func TestHandler_WorkStart_NotFound(t *testing.T) {
 	env := newTestEnv(t, &mockAgent{})
 
@@ -428,7 +782,7 @@ func TestHandler_WorkStart_RollbackOnKickoffFailure(t *testing.T) {
 	var task work.Work
 	json.Unmarshal(taskResp.Result, &task)
 
-	// Start should fail (agent start error propagates through ChatClient.SendMessage)
+	// Start should fail (agent start error propagates through ChatClient.SendSystemMessage)
 	resp := env.call("work.start", rpc.WorkStartParams{ID: task.ID})
 	if resp.Error == nil {
 		t.Fatal("expected error when agent start fails")
@@ -482,6 +836,275 @@ func TestHandler_WorkStart_RollbackAllowsRetry(t *testing.T) {
 	}
 }
 
+// --- work.list ---
+
+func TestHandler_WorkCancel(t *testing.T) {
+	mock := &mockAgent{}
+	env := newTestEnv(t, mock)
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Feature X",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+
+	startResp := env.call("work.start", rpc.WorkStartParams{ID: story.ID})
+	if startResp.Error != nil {
+		t.Fatalf("start: %s", startResp.Error.Message)
+	}
+	var started work.Work
+	json.Unmarshal(startResp.Result, &started)
+
+	resp := env.call("work.cancel", rpc.WorkCancelParams{ID: story.ID})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Fatalf("expected invalid params without reason, got %+v", resp)
+	}
+
+	resp = env.call("work.cancel", rpc.WorkCancelParams{ID: story.ID, Reason: "no longer needed"})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var cancelled work.Work
+	json.Unmarshal(resp.Result, &cancelled)
+	if cancelled.Status != work.StatusCancelled || cancelled.CancelReason != "no longer needed" {
+		t.Errorf("work = %s %q, want cancelled with reason", cancelled.Status, cancelled.CancelReason)
+	}
+	if env.getMainWorktree().ProcessManager.HasProcess(started.SessionID) {
+		t.Error("expected the cancelled work's process to be closed")
+	}
+}
+
+func TestHandler_WorkBulk(t *testing.T) {
+	mock := &mockAgent{}
+	env := newTestEnv(t, mock)
+
+	create := func(title string) work.Work {
+		resp := env.call("work.create", rpc.WorkCreateParams{
+			Type:        work.WorkTypeStory,
+			AgentRoleID: env.testRoleID,
+			Title:       title,
+		})
+		var w work.Work
+		json.Unmarshal(resp.Result, &w)
+		return w
+	}
+	toStart, toRename, toDelete := create("Start"), create("Rename"), create("Delete")
+
+	startResp := env.call("work.start", rpc.WorkStartParams{ID: toDelete.ID})
+	var started work.Work
+	json.Unmarshal(startResp.Result, &started)
+	if started.SessionID == "" {
+		t.Fatal("expected a session for the work to delete")
+	}
+
+	title := "Renamed"
+	resp := env.call("work.bulk", rpc.WorkBulkParams{Operations: []work.BulkOp{
+		{Action: work.BulkStart, ID: toStart.ID},
+		{Action: work.BulkUpdate, ID: toRename.ID, Fields: work.UpdateFields{Title: &title}},
+		{Action: work.BulkDelete, ID: toDelete.ID},
+		{Action: work.BulkStop, ID: "missing"},
+	}})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.WorkBulkResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Results) != 4 {
+		t.Fatalf("results = %+v, want 4", result.Results)
+	}
+	for i, wantOK := range []bool{true, true, true, false} {
+		if result.Results[i].OK != wantOK {
+			t.Errorf("results[%d] = %+v, want ok=%v", i, result.Results[i], wantOK)
+		}
+	}
+	if result.Results[3].Error != work.ErrWorkNotFound.Error() {
+		t.Errorf("missing error = %q, want %q", result.Results[3].Error, work.ErrWorkNotFound.Error())
+	}
+
+	if w, _, _ := env.workStore.Get(toStart.ID); w.Status != work.StatusInProgress {
+		t.Errorf("started status = %s, want in_progress", w.Status)
+	}
+	if w, _, _ := env.workStore.Get(toRename.ID); w.Title != "Renamed" {
+		t.Errorf("title = %q, want Renamed", w.Title)
+	}
+	wt := env.getMainWorktree()
+	defer env.worktreeManager.Release(wt)
+	if _, found, _ := wt.SessionStore.Get(started.SessionID); found {
+		t.Error("expected the deleted work's session to be deleted")
+	}
+}
+
+func TestHandler_WorkBulk_UnknownRole(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("work.bulk", rpc.WorkBulkParams{Operations: []work.BulkOp{
+		{Action: work.BulkStart, ID: "any", AgentRoleID: "no-such-role"},
+	}})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Fatalf("expected invalid params for unknown role, got %+v", resp)
+	}
+
+	resp = env.call("work.bulk", rpc.WorkBulkParams{})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected invalid params for an empty request, got %+v", resp)
+	}
+}
+
+func TestHandler_WorkBulkCreate(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Parent story",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+
+	task := func(title string) rpc.WorkCreateParams {
+		return rpc.WorkCreateParams{Type: work.WorkTypeTask, ParentID: story.ShortID, AgentRoleID: env.testRoleID, Title: title}
+	}
+	resp := env.call("work.bulk_create", rpc.WorkBulkCreateParams{Items: []rpc.WorkCreateParams{task("One"), task("Two")}})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.WorkBulkCreateResult
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Items) != 2 || result.Items[0].Title != "One" || result.Items[1].ParentID != story.ID {
+		t.Fatalf("items = %+v, want One and Two under the story", result.Items)
+	}
+
+	// One bad item fails the whole batch.
+	bad := task("")
+	resp = env.call("work.bulk_create", rpc.WorkBulkCreateParams{Items: []rpc.WorkCreateParams{task("Three"), bad}})
+	if resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Fatalf("expected invalid params, got %+v", resp)
+	}
+	works, _ := env.workStore.List()
+	if len(works) != 3 {
+		t.Errorf("works = %d, want 3: the failed batch created nothing", len(works))
+	}
+}
+
+func TestHandler_WorkReport(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	storyResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeStory,
+		AgentRoleID: env.testRoleID,
+		Title:       "Feature X",
+	})
+	var story work.Work
+	json.Unmarshal(storyResp.Result, &story)
+	taskResp := env.call("work.create", rpc.WorkCreateParams{
+		Type:        work.WorkTypeTask,
+		ParentID:    story.ID,
+		AgentRoleID: env.testRoleID,
+		Title:       "Task",
+	})
+	var task work.Work
+	json.Unmarshal(taskResp.Result, &task)
+
+	estimate := 45
+	if resp := env.call("work.update", rpc.WorkUpdateParams{ID: task.ID, EstimateMinutes: &estimate}); resp.Error != nil {
+		t.Fatalf("update: %s", resp.Error.Message)
+	}
+	if _, err := env.workStore.LogTime(env.ctx, task.ID, 50); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := env.call("work.report", rpc.WorkReportParams{ID: story.ID})
+	if resp.Error != nil {
+		t.Fatalf("report: %s", resp.Error.Message)
+	}
+	var report work.EffortReport
+	json.Unmarshal(resp.Result, &report)
+	if want := (work.Effort{EstimateMinutes: 45, TimeSpentMinutes: 50}); report.Total != want {
+		t.Errorf("Total = %+v, want %+v", report.Total, want)
+	}
+	if len(report.Children) != 1 || report.Children[0].WorkID != task.ID {
+		t.Errorf("Children = %+v, want the task", report.Children)
+	}
+
+	resp = env.call("work.report", rpc.WorkReportParams{ID: "missing"})
+	if resp.Error == nil {
+		t.Error("expected error for missing work")
+	}
+}
+
+func TestHandler_WorkList_Paginates(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	for _, title := range []string{"Story A", "Story B", "Story C"} {
+		env.call("work.create", rpc.WorkCreateParams{
+			Type:        work.WorkTypeStory,
+			AgentRoleID: env.testRoleID,
+			Title:       title,
+		})
+	}
+
+	resp := env.call("work.list", rpc.WorkListParams{ListQuery: work.ListQuery{Limit: 2, Offset: 1}})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+
+	var result rpc.WorkListResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if result.Total != 3 {
+		t.Errorf("expected total 3, got %d", result.Total)
+	}
+	if len(result.Items) != 2 || result.Items[0].Title != "Story B" {
+		t.Errorf("expected [Story B, Story C], got %+v", result.Items)
+	}
+}
+
+func TestHandler_WorkList_InvalidSort(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	resp := env.call("work.list", rpc.WorkListParams{ListQuery: work.ListQuery{Sort: "title"}})
+	if resp.Error == nil {
+		t.Fatal("expected error for invalid sort")
+	}
+}
+
+func TestHandler_WorkSearch(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+
+	for _, title := range []string{"Login page", "Billing export"} {
+		env.call("work.create", rpc.WorkCreateParams{
+			Type:        work.WorkTypeStory,
+			AgentRoleID: env.testRoleID,
+			Title:       title,
+		})
+	}
+
+	resp := env.call("work.search", rpc.WorkSearchParams{SearchQuery: work.SearchQuery{Query: "logn"}})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.WorkSearchResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if result.Total != 1 || result.Hits[0].Work.Title != "Login page" {
+		t.Fatalf("expected Login page, got %+v", result)
+	}
+	if len(result.Hits[0].Title) == 0 || !result.Hits[0].Title[0].Match {
+		t.Errorf("expected highlighted title, got %+v", result.Hits[0].Title)
+	}
+
+	resp = env.call("work.search", rpc.WorkSearchParams{})
+	if resp.Error == nil {
+		t.Error("expected error for empty query")
+	}
+}
+
 // --- work.list.subscribe ---
 
 func TestHandler_WorkListSubscribe_Empty(t *testing.T) {
@@ -534,3 +1157,189 @@ func TestHandler_WorkListSubscribe_WithItems(t *testing.T) {
 		t.Errorf("expected 2 items, got %d", len(result.Items))
 	}
 }
+
+// --- work.subscribe ---
+
+func TestHandler_WorkSubscribe(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	ctx := context.Background()
+
+	story, _ := env.workStore.Create(ctx, work.Work{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Story"})
+	env.workStore.Create(ctx, work.Work{Type: work.WorkTypeTask, ParentID: story.ID, AgentRoleID: env.testRoleID, Title: "Task"})
+	other, _ := env.workStore.Create(ctx, work.Work{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Other"})
+
+	resp := env.call("work.subscribe", rpc.WorkSubscribeParams{WorkID: story.ID, IncludeChildren: true})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.WorkSubscribeResult
+	json.Unmarshal(resp.Result, &result)
+	if result.ID == "" || len(result.Items) != 2 || result.Items[0].ID != story.ID {
+		t.Fatalf("result = %+v, want the story and its task", result)
+	}
+
+	// Changes elsewhere on the board are filtered out. Updated on the
+	// store directly so no reply can race past the notification.
+	title := "Other renamed"
+	if err := env.workStore.Update(ctx, other.ID, work.UpdateFields{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	title = "Story renamed"
+	if err := env.workStore.Update(ctx, story.ID, work.UpdateFields{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+
+	notif := env.readNotification()
+	if notif.Method != "work.changed" {
+		t.Fatalf("method = %q, want work.changed", notif.Method)
+	}
+	var params struct {
+		ID   string    `json:"id"`
+		Work work.Work `json:"work"`
+	}
+	json.Unmarshal(notif.Params, &params)
+	if params.ID != result.ID || params.Work.ID != story.ID {
+		t.Errorf("notification = %+v, want the story's update", params)
+	}
+
+	resp = env.call("work.subscribe", rpc.WorkSubscribeParams{WorkID: "missing"})
+	if resp.Error == nil {
+		t.Error("expected error for unknown work")
+	}
+}
+
+func TestHandler_WorkFiles(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	ctx := context.Background()
+
+	story, err := env.workStore.Create(ctx, work.Work{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Story"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := env.workStore.Create(ctx, work.Work{Type: work.WorkTypeTask, ParentID: story.ID, AgentRoleID: env.testRoleID, Title: "Task"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.workStore.AddModifiedFiles(ctx, task.ID, []string{"b.go", "a.go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := env.call("work.files", rpc.WorkFilesParams{WorkID: story.ID})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var result rpc.WorkFilesResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if len(result.Files) != 2 || result.Files[0] != "a.go" {
+		t.Errorf("files = %v, want [a.go b.go]", result.Files)
+	}
+	if len(result.ByWork) != 1 || result.ByWork[0].WorkID != task.ID {
+		t.Errorf("by_work = %+v, want only the task", result.ByWork)
+	}
+
+	if resp := env.call("work.files", rpc.WorkFilesParams{WorkID: "missing"}); resp.Error == nil {
+		t.Error("expected error for missing work")
+	}
+}
+
+func TestHandler_WorkStats(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	ctx := context.Background()
+
+	story, err := env.workStore.Create(ctx, work.Work{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Story"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.workStore.Start(ctx, story.ID, "sess-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.workStore.StepDone(ctx, story.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := env.call("work.stats", rpc.WorkStatsParams{Weeks: 4})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+	var stats work.Stats
+	if err := json.Unmarshal(resp.Result, &stats); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if stats.Counts[work.StatusClosed] != 1 || len(stats.Throughput) != 4 || stats.Throughput[3].Closed != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.CycleTime.Samples != 1 || len(stats.ByRole) != 1 || stats.ByRole[0].AgentRoleID != env.testRoleID {
+		t.Errorf("cycle time / roles = %+v / %+v", stats.CycleTime, stats.ByRole)
+	}
+
+	if resp := env.call("work.stats", rpc.WorkStatsParams{Weeks: 100}); resp.Error == nil {
+		t.Error("expected error for too many weeks")
+	}
+	if resp := env.call("work.stats", rpc.WorkStatsParams{Type: "epic"}); resp.Error == nil {
+		t.Error("expected error for invalid type")
+	}
+}
+
+func TestHandler_WorkApproveClose(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	ctx := context.Background()
+	policy := work.ClosePolicyExplicit
+	story, err := env.workStore.Create(ctx, work.Work{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Story"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := env.call("work.update", rpc.WorkUpdateParams{ID: story.ID, ClosePolicy: &policy}); resp.Error != nil {
+		t.Fatalf("work.update: %s", resp.Error.Message)
+	}
+	if _, err := env.workStore.Start(ctx, story.ID, "sess-approve"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.workStore.StepDone(ctx, story.ID, 0); !errors.Is(err, work.ErrCloseApprovalRequired) {
+		t.Fatalf("StepDone: err = %v, want ErrCloseApprovalRequired", err)
+	}
+
+	resp := env.call("work.approve_close", rpc.WorkApproveCloseParams{ID: story.ID})
+	if resp.Error != nil {
+		t.Fatalf("work.approve_close: %s", resp.Error.Message)
+	}
+	var w work.Work
+	if err := json.Unmarshal(resp.Result, &w); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if w.Status != work.StatusClosed || w.ClosePolicy != work.ClosePolicyExplicit {
+		t.Errorf("work = %+v, want closed with its policy kept", w)
+	}
+
+	if resp := env.call("work.approve_close", rpc.WorkApproveCloseParams{ID: story.ID}); resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("closed work: error = %+v, want InvalidParams", resp.Error)
+	}
+}
+
+func TestHandler_WorkWatch(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	story, err := env.workStore.Create(context.Background(), work.Work{Type: work.WorkTypeStory, AgentRoleID: env.testRoleID, Title: "Story"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := env.call("work.watch", rpc.WorkWatchParams{ID: story.ID, Notify: work.NotifyWatch})
+	if resp.Error != nil {
+		t.Fatalf("work.watch: %s", resp.Error.Message)
+	}
+	var w work.Work
+	if err := json.Unmarshal(resp.Result, &w); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if w.Notify != work.NotifyWatch {
+		t.Errorf("notify = %q, want watch", w.Notify)
+	}
+
+	if resp := env.call("work.watch", rpc.WorkWatchParams{ID: story.ID, Notify: "loud"}); resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("bad flag: error = %+v, want InvalidParams", resp.Error)
+	}
+	if resp := env.call("work.watch", rpc.WorkWatchParams{ID: "missing", Notify: work.NotifyMute}); resp.Error == nil || resp.Error.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("missing work: error = %+v, want InvalidParams", resp.Error)
+	}
+}
@@ -2,9 +2,13 @@ package ws
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 
+	"github.com/pockode/server/git"
+	"github.com/pockode/server/i18n"
 	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/work"
 	"github.com/pockode/server/worktree"
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -23,6 +27,15 @@ func (h *rpcMethodHandler) handleWorktreeList(ctx context.Context, conn *jsonrpc
 			Branch: wt.Branch,
 			IsMain: wt.IsMain,
 		}
+		if status, ok := h.ciPoller.Get(wt.Branch); ok {
+			result.Worktrees[i].CI = &status
+		}
+		if status, ok := registry.Setup().Get(wt.Name); ok {
+			result.Worktrees[i].Setup = &status
+		}
+		if stores := h.worktreeManager.WorkStores(); stores != nil {
+			result.Worktrees[i].IsolatedWork = stores.IsIsolated(wt.Name)
+		}
 	}
 
 	if err := conn.Reply(ctx, req.ID, result); err != nil {
@@ -33,57 +46,114 @@ func (h *rpcMethodHandler) handleWorktreeList(ctx context.Context, conn *jsonrpc
 func (h *rpcMethodHandler) handleWorktreeCreate(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorktreeCreateParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if params.Name == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "name required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "name", "name required")
 		return
 	}
-	if params.Branch == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "branch required")
+	branch, ok := h.worktreeBranch(ctx, conn, req, params)
+	if !ok {
 		return
 	}
 
 	registry := h.worktreeManager.Registry()
-	info, err := registry.Create(params.Name, params.Branch, params.BaseBranch)
+	info, err := registry.Create(params.Name, branch, params.BaseBranch)
 	if err != nil {
 		switch {
 		case errors.Is(err, worktree.ErrNotGitRepo):
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "not a git repository")
+			h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "not a git repository")
 		case errors.Is(err, worktree.ErrWorktreeAlreadyExist):
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "worktree already exists")
+			h.replyError(ctx, conn, req.ID, rpc.ErrConflict, "worktree already exists")
 		default:
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		}
 		return
 	}
 
 	h.log.Info("worktree created", "name", info.Name, "branch", info.Branch)
 
+	if params.IsolatedWork {
+		stores := h.worktreeManager.WorkStores()
+		if stores == nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "worktree-scoped work stores are unavailable")
+			return
+		}
+		if err := stores.Isolate(info.Name); err != nil {
+			h.log.Error("failed to create worktree work store", "name", info.Name, "error", err)
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, "failed to create worktree work store")
+			return
+		}
+	}
+
 	result := rpc.WorktreeCreateResult{
 		Worktree: rpc.WorktreeInfo{
-			Name:   info.Name,
-			Path:   info.Path,
-			Branch: info.Branch,
-			IsMain: info.IsMain,
+			Name:         info.Name,
+			Path:         info.Path,
+			Branch:       info.Branch,
+			IsMain:       info.IsMain,
+			IsolatedWork: params.IsolatedWork,
 		},
 	}
+	if status, ok := registry.Setup().Get(info.Name); ok {
+		result.Worktree.Setup = &status
+	}
 	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		h.log.Error("failed to send worktree create response", "error", err)
 	}
 }
 
+// worktreeBranch applies the branch naming policy to a worktree.create
+// request, replying with the error when it fails.
+func (h *rpcMethodHandler) worktreeBranch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, params rpc.WorktreeCreateParams) (string, bool) {
+	tmpl := h.settingsStore.Get().BranchTemplate
+	if tmpl == "" {
+		if params.Branch == "" {
+			h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "branch", "branch required")
+			return "", false
+		}
+		return params.Branch, true
+	}
+
+	var w *work.Work
+	if params.Branch == "" && params.WorkID != "" {
+		found, ok, err := h.workStore.Get(params.WorkID)
+		if err != nil {
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
+			return "", false
+		}
+		if !ok {
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "work not found")
+			return "", false
+		}
+		w = &found
+	}
+
+	branch, err := worktree.BranchName(tmpl, params.Branch, params.Name, w)
+	switch {
+	case errors.Is(err, git.ErrBranchTemplateMatch):
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "branch", "branch does not match the branch template")
+	case errors.Is(err, git.ErrBranchTemplateVar):
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "work_id", "branch template needs a work item")
+	case err != nil:
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "branch", "invalid branch name")
+	default:
+		return branch, true
+	}
+	return "", false
+}
+
 func (h *rpcMethodHandler) handleWorktreeDelete(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorktreeDeleteParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	if params.Name == "" {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "name required")
+		h.replyFieldError(ctx, conn, req.ID, rpc.ErrInvalidParams, "name", "name required")
 		return
 	}
 
@@ -91,11 +161,11 @@ func (h *rpcMethodHandler) handleWorktreeDelete(ctx context.Context, conn *jsonr
 	if err := registry.Delete(params.Name); err != nil {
 		switch {
 		case errors.Is(err, worktree.ErrNotGitRepo):
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidRequest, "not a git repository")
+			h.replyError(ctx, conn, req.ID, rpc.ErrUnavailable, "not a git repository")
 		case errors.Is(err, worktree.ErrWorktreeNotFound):
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "worktree not found")
+			h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "worktree not found")
 		default:
-			h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInternalError, err.Error())
+			h.replyError(ctx, conn, req.ID, rpc.ErrInternal, err.Error())
 		}
 		return
 	}
@@ -113,14 +183,14 @@ func (h *rpcMethodHandler) handleWorktreeDelete(ctx context.Context, conn *jsonr
 func (h *rpcMethodHandler) handleWorktreeSwitch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	var params rpc.WorktreeSwitchParams
 	if err := unmarshalParams(req, &params); err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "invalid params")
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
 		return
 	}
 
 	// Get new worktree first (outside lock) to ensure it exists before modifying state
-	newWorktree, err := h.worktreeManager.Get(params.Name)
+	newWorktree, err := h.worktreeManager.GetFor(params.Name, h.state.connID)
 	if err != nil {
-		h.replyError(ctx, conn, req.ID, jsonrpc2.CodeInvalidParams, "worktree not found")
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "worktree not found")
 		return
 	}
 
@@ -133,7 +203,7 @@ func (h *rpcMethodHandler) handleWorktreeSwitch(ctx context.Context, conn *jsonr
 	if currentWorktree != nil && currentWorktree.Name == params.Name {
 		h.state.mu.Unlock()
 		// Release the extra ref we acquired above
-		h.worktreeManager.Release(newWorktree)
+		h.worktreeManager.ReleaseFor(newWorktree, h.state.connID)
 		result := rpc.WorktreeSwitchResult{
 			WorkDir:      currentWorktree.WorkDir,
 			WorktreeName: currentWorktree.Name,
@@ -144,16 +214,24 @@ func (h *rpcMethodHandler) handleWorktreeSwitch(ctx context.Context, conn *jsonr
 		return
 	}
 
-	// Update state atomically first, then cleanup old worktree outside lock
+	// Update state atomically first, then cleanup old worktree outside lock.
+	// An attached target is promoted: it already holds a ref and the
+	// notifier, and its subscriptions carry over.
 	h.state.worktree = newWorktree
+	_, wasAttached := h.state.attached[params.Name]
+	delete(h.state.attached, params.Name)
 	newWorktree.Subscribe(notifier)
 	h.state.mu.Unlock()
+	if wasAttached {
+		h.worktreeManager.ReleaseFor(newWorktree, h.state.connID)
+	}
 
 	// Cleanup old worktree (outside lock to avoid deadlock)
+	var dropped []resubscriber
 	if currentWorktree != nil {
-		h.state.unsubscribeWorktreeWatchers(currentWorktree)
+		dropped = h.state.unsubscribeWorktreeWatchers(currentWorktree)
 		currentWorktree.Unsubscribe(notifier)
-		h.worktreeManager.Release(currentWorktree)
+		h.worktreeManager.ReleaseFor(currentWorktree, h.state.connID)
 	}
 
 	h.log.Info("worktree switched", "to", newWorktree.Name)
@@ -162,11 +240,35 @@ func (h *rpcMethodHandler) handleWorktreeSwitch(ctx context.Context, conn *jsonr
 		WorkDir:      newWorktree.WorkDir,
 		WorktreeName: newWorktree.Name,
 	}
+	if params.CarryOver {
+		result.Subscriptions = h.carrySubscriptions(dropped, newWorktree)
+	}
 	if err := conn.Reply(ctx, req.ID, result); err != nil {
 		h.log.Error("failed to send worktree switch response", "error", err)
 	}
 }
 
+// carrySubscriptions re-establishes subscriptions dropped by a switch on
+// the new worktree. One that no longer applies there, such as a chat
+// subscription to a session the worktree does not have, is reported with
+// its error rather than failing the switch.
+func (h *rpcMethodHandler) carrySubscriptions(dropped []resubscriber, wt *worktree.Worktree) []rpc.CarriedSubscription {
+	carried := make([]rpc.CarriedSubscription, 0, len(dropped))
+	for _, r := range dropped {
+		c := rpc.CarriedSubscription{PrevID: r.prevID, Method: r.method}
+		result, rerr := r.subscribe(wt)
+		if rerr != nil {
+			c.Reason = rerr.reason
+			c.Error = i18n.T(h.locale(), rerr.message)
+			h.log.Debug("subscription not carried over", "method", r.method, "watchId", r.prevID, "error", rerr.message)
+		} else {
+			c.Result = result
+		}
+		carried = append(carried, c)
+	}
+	return carried
+}
+
 func (h *rpcMethodHandler) handleWorktreeSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
 	notifier := h.state.getNotifier()
 	id := h.worktreeManager.WorktreeWatcher.Subscribe(notifier)
@@ -177,3 +279,147 @@ func (h *rpcMethodHandler) handleWorktreeSubscribe(ctx context.Context, conn *js
 		h.log.Error("failed to send worktree subscribe response", "error", err)
 	}
 }
+
+// worktreeSelector is the optional "worktree" param every worktree-level
+// method accepts, targeting an attached worktree instead of the bound one.
+// A pointer, since "" names the main worktree.
+type worktreeSelector struct {
+	Worktree *string `json:"worktree"`
+}
+
+// requestWorktree returns the request's "worktree" param, or nil when absent.
+// Params the selector can't decode fall through to the bound worktree, where
+// the method's own validation reports them.
+func requestWorktree(req *jsonrpc2.Request) *string {
+	if req.Params == nil {
+		return nil
+	}
+	var sel worktreeSelector
+	if err := json.Unmarshal(*req.Params, &sel); err != nil {
+		return nil
+	}
+	return sel.Worktree
+}
+
+func (h *rpcMethodHandler) handleWorktreeAttach(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorktreeAttachParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	if wt, ok := h.state.targetWorktree(&params.Name); ok {
+		h.replyWorktreeAttach(ctx, conn, req, wt)
+		return
+	}
+
+	wt, err := h.worktreeManager.GetFor(params.Name, h.state.connID)
+	if err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrNotFound, "worktree not found")
+		return
+	}
+
+	h.state.mu.Lock()
+	bound := h.state.worktree
+	_, attached := h.state.attached[params.Name]
+	if attached || (bound != nil && bound.Name == params.Name) {
+		// Lost a race with a concurrent attach or switch to the same name.
+		h.state.mu.Unlock()
+		h.worktreeManager.ReleaseFor(wt, h.state.connID)
+		h.replyWorktreeAttach(ctx, conn, req, wt)
+		return
+	}
+	h.state.attached[params.Name] = wt
+	wt.Subscribe(h.state.notifier)
+	h.state.mu.Unlock()
+
+	h.log.Info("worktree attached", "name", wt.Name)
+	h.replyWorktreeAttach(ctx, conn, req, wt)
+}
+
+func (h *rpcMethodHandler) replyWorktreeAttach(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, wt *worktree.Worktree) {
+	result := rpc.WorktreeAttachResult{
+		WorkDir:      wt.WorkDir,
+		WorktreeName: wt.Name,
+	}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send worktree attach response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorktreeDetach(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorktreeDetachParams
+	if err := unmarshalParams(req, &params); err != nil {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+
+	h.state.mu.Lock()
+	if bound := h.state.worktree; bound != nil && bound.Name == params.Name {
+		h.state.mu.Unlock()
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidTransition, "cannot detach the bound worktree")
+		return
+	}
+	wt, ok := h.state.attached[params.Name]
+	delete(h.state.attached, params.Name)
+	notifier := h.state.notifier
+	h.state.mu.Unlock()
+
+	if !ok {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidTransition, "worktree not attached")
+		return
+	}
+
+	h.state.unsubscribeWorktreeWatchers(wt)
+	wt.Unsubscribe(notifier)
+	h.worktreeManager.ReleaseFor(wt, h.state.connID)
+
+	h.log.Info("worktree detached", "name", wt.Name)
+
+	if err := conn.Reply(ctx, req.ID, struct{}{}); err != nil {
+		h.log.Error("failed to send worktree detach response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorktreeSetupSubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	notifier := h.state.getNotifier()
+	id, statuses := h.worktreeSetupWatcher.Subscribe(notifier)
+	h.state.trackSubscription(id, h.worktreeSetupWatcher)
+	h.log.Debug("subscribed", "watcher", "worktree setup", "watchId", id)
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorktreeSetupStatusSubscribeResult{ID: id, Statuses: statuses}); err != nil {
+		h.log.Error("failed to send worktree setup subscribe response", "error", err)
+	}
+}
+
+func (h *rpcMethodHandler) handleWorktreeRefs(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	result := rpc.WorktreeRefsResult{Worktrees: h.worktreeRefs()}
+	if err := conn.Reply(ctx, req.ID, result); err != nil {
+		h.log.Error("failed to send worktree refs response", "error", err)
+	}
+}
+
+// handleWorktreeForceRelease drops a holder's references to a worktree. A
+// live connection is closed first: dropping its references while it keeps
+// using the worktree would let idle cleanup stop it underneath the client.
+func (h *rpcMethodHandler) handleWorktreeForceRelease(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var params rpc.WorktreeForceReleaseParams
+	if err := unmarshalParams(req, &params); err != nil || params.Holder == "" {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidParams, "invalid params")
+		return
+	}
+	if params.Holder == h.state.connID {
+		h.replyError(ctx, conn, req.ID, rpc.ErrInvalidTransition, "cannot force-release the calling connection; use worktree.detach")
+		return
+	}
+
+	if state, live := h.lookupConn(params.Holder); live {
+		state.close()
+	}
+	released := h.worktreeManager.ForceRelease(params.Name, params.Holder)
+	h.log.Info("worktree references force-released", "name", params.Name, "holder", params.Holder, "released", released)
+
+	if err := conn.Reply(ctx, req.ID, rpc.WorktreeForceReleaseResult{Released: released}); err != nil {
+		h.log.Error("failed to send worktree force release response", "error", err)
+	}
+}
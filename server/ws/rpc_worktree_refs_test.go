@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/pockode/server/rpc"
+)
+
+// dialAuthed opens another authenticated connection to env's server.
+func dialAuthed(t *testing.T, env *testEnv) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.Dial(env.ctx, "ws"+strings.TrimPrefix(env.server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close(websocket.StatusNormalClosure, "") })
+	data, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: "auth", Params: rpc.AuthParams{Token: "test-token"}})
+	if err := conn.Write(env.ctx, websocket.MessageText, data); err != nil {
+		t.Fatalf("failed to send auth: %v", err)
+	}
+	if _, _, err := conn.Read(env.ctx); err != nil {
+		t.Fatalf("failed to read auth response: %v", err)
+	}
+	return conn
+}
+
+func (e *testEnv) mainRefs() rpc.WorktreeRefs {
+	e.t.Helper()
+	resp := e.call("worktree.refs", nil)
+	if resp.Error != nil {
+		e.t.Fatalf("worktree.refs: %s", resp.Error.Message)
+	}
+	var result rpc.WorktreeRefsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		e.t.Fatalf("unmarshal: %v", err)
+	}
+	for _, r := range result.Worktrees {
+		if r.Worktree == "" {
+			return r
+		}
+	}
+	e.t.Fatalf("main worktree missing from %+v", result.Worktrees)
+	return rpc.WorktreeRefs{}
+}
+
+func otherHolders(refs rpc.WorktreeRefs) []rpc.WorktreeRefHolder {
+	var others []rpc.WorktreeRefHolder
+	for _, h := range refs.Holders {
+		if !h.Self {
+			others = append(others, h)
+		}
+	}
+	return others
+}
+
+func TestHandler_WorktreeRefsAndForceRelease(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	other := dialAuthed(t, env)
+
+	refs := env.mainRefs()
+	if refs.RefCount != 2 || len(refs.Holders) != 2 {
+		t.Fatalf("refs = %+v, want this and the other connection", refs)
+	}
+	holders := otherHolders(refs)
+	if len(holders) != 1 || !holders[0].Connected || holders[0].LastSeen == nil {
+		t.Fatalf("other holders = %+v, want one live connection", holders)
+	}
+
+	resp := env.call("worktree.force_release", rpc.WorktreeForceReleaseParams{Name: "", Holder: holders[0].Holder})
+	if resp.Error != nil {
+		t.Fatalf("worktree.force_release: %s", resp.Error.Message)
+	}
+	var result rpc.WorktreeForceReleaseResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if _, _, err := other.Read(env.ctx); err == nil {
+		t.Error("force-released connection is still open")
+	}
+	if refs := env.mainRefs(); refs.RefCount != 1 || len(otherHolders(refs)) != 0 {
+		t.Errorf("refs after force release = %+v, want only this connection", refs)
+	}
+
+	self := refs.Holders[0].Holder
+	if !refs.Holders[0].Self {
+		self = refs.Holders[1].Holder
+	}
+	if resp := env.call("worktree.force_release", rpc.WorktreeForceReleaseParams{Holder: self}); resp.Error == nil {
+		t.Error("force-releasing the calling connection was accepted")
+	}
+}
+
+func TestHandler_ReapStaleRefs(t *testing.T) {
+	env := newTestEnv(t, &mockAgent{})
+	stale := dialAuthed(t, env)
+
+	// A holder that disconnected without releasing.
+	if _, err := env.worktreeManager.GetFor("", "gone"); err != nil {
+		t.Fatalf("GetFor: %v", err)
+	}
+	// A connection that stopped answering pings.
+	for _, h := range otherHolders(env.mainRefs()) {
+		if state, ok := env.handler.lookupConn(h.Holder); ok {
+			state.markSeen(time.Now().Add(-2 * staleConnAfter))
+		}
+	}
+
+	env.handler.reapStaleRefs(time.Now())
+
+	if _, _, err := stale.Read(env.ctx); err == nil {
+		t.Error("stale connection is still open")
+	}
+	if refs := env.mainRefs(); refs.RefCount != 1 || len(otherHolders(refs)) != 0 {
+		t.Errorf("refs after reaping = %+v, want only this connection", refs)
+	}
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pockode/server/rpc"
+	"github.com/pockode/server/setup"
+)
+
+func TestHandler_WorktreeSetupStatus(t *testing.T) {
+	dir := setupGitRepo(t)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Test"), 0644)
+	runGitIn(t, dir, "add", ".")
+	runGitIn(t, dir, "commit", "-m", "initial")
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("TOKEN=x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := newWorkDirTestEnv(t, dir)
+	env.worktreeManager.Registry().Setup().SetConfig(func() setup.Config {
+		return setup.Config{CopyFiles: []string{".env"}, Commands: []string{"test -f .env"}}
+	})
+
+	resp := env.call("worktree.setup.status.subscribe", nil)
+	if resp.Error != nil {
+		t.Fatalf("subscribe: %s", resp.Error.Message)
+	}
+	var sub rpc.WorktreeSetupStatusSubscribeResult
+	if err := json.Unmarshal(resp.Result, &sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.ID == "" || len(sub.Statuses) != 0 {
+		t.Fatalf("result = %+v, want an ID and no statuses", sub)
+	}
+
+	resp = env.call("worktree.create", rpc.WorktreeCreateParams{Name: "feature", Branch: "feature-branch"})
+	if resp.Error != nil {
+		t.Fatalf("create: %s", resp.Error.Message)
+	}
+	var created rpc.WorktreeCreateResult
+	if err := json.Unmarshal(resp.Result, &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Worktree.Setup == nil || len(created.Worktree.Setup.Steps) != 2 {
+		t.Fatalf("create result setup = %+v, want two planned steps", created.Worktree.Setup)
+	}
+
+	// Notifications may have been skipped while waiting for the create
+	// response, so poll the list for the final state.
+	var status *setup.Status
+	deadline := time.Now().Add(10 * time.Second)
+	for status == nil || !status.Done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("setup did not finish: %+v", status)
+		}
+		time.Sleep(20 * time.Millisecond)
+		var list rpc.WorktreeListResult
+		if err := json.Unmarshal(env.call("worktree.list", nil).Result, &list); err != nil {
+			t.Fatal(err)
+		}
+		for _, wt := range list.Worktrees {
+			if wt.Name == "feature" {
+				status = wt.Setup
+			}
+		}
+	}
+	if status.State != setup.StateSucceeded {
+		t.Fatalf("setup = %+v, want succeeded", status)
+	}
+	if _, err := os.Stat(filepath.Join(created.Worktree.Path, ".env")); err != nil {
+		t.Errorf(".env not copied: %v", err)
+	}
+
+	resp = env.call("worktree.setup.status.unsubscribe", map[string]string{"id": sub.ID})
+	if resp.Error != nil {
+		t.Errorf("unsubscribe: %s", resp.Error.Message)
+	}
+}
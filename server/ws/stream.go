@@ -1,8 +1,11 @@
 package ws
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"sync"
 
@@ -10,11 +13,40 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
+// EncodingGzip is the optional binary framing negotiated at auth: a binary
+// frame carries one gzip-compressed JSON-RPC message. It exists for clients
+// behind proxies that strip permessage-deflate; text frames stay plain JSON.
+const EncodingGzip = "gzip"
+
+// gzipMinSize is the smallest payload worth gzipping once EncodingGzip is
+// on. Smaller messages (acks, short notifications) grow under gzip's
+// ~20-byte header and go out as plain text frames instead.
+const gzipMinSize = 1024
+
+// maxInflatedSize bounds a decompressed inbound frame so a small binary
+// frame cannot expand into an arbitrarily large allocation.
+const maxInflatedSize = 10 * 1024 * 1024
+
+// EncodingSwitcher is implemented by streams that can change their outbound
+// wire encoding after auth. Streams without it (e.g. relay) only speak
+// plain JSON text.
+type EncodingSwitcher interface {
+	SetEncoding(encoding string) bool
+}
+
+// Pinger is implemented by streams that can check the peer is alive
+// without a request. Streams without it (e.g. relay) are never treated as
+// stale, since an idle client looks the same as a dead one.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 // WebSocketStream adapts coder/websocket to jsonrpc2.ObjectStream.
 // It is safe for concurrent use.
 type WebSocketStream struct {
-	conn *websocket.Conn
-	mu   sync.Mutex // protects writes
+	conn     *websocket.Conn
+	mu       sync.Mutex // protects writes and encoding
+	encoding string
 }
 
 // NewWebSocketStream creates a new WebSocketStream from a websocket connection.
@@ -22,9 +54,11 @@ func NewWebSocketStream(conn *websocket.Conn) *WebSocketStream {
 	return &WebSocketStream{conn: conn}
 }
 
-// ReadObject reads a JSON object from the websocket connection.
+// ReadObject reads a JSON object from the websocket connection. Binary
+// frames are gzip-compressed JSON, accepted whether or not the encoding
+// was negotiated, since each frame type is self-describing.
 func (s *WebSocketStream) ReadObject(v interface{}) error {
-	_, data, err := s.conn.Read(context.Background())
+	typ, data, err := s.conn.Read(context.Background())
 	if err != nil {
 		// Treat normal close frames as EOF so jsonrpc2 shuts down gracefully
 		switch websocket.CloseStatus(err) {
@@ -33,6 +67,11 @@ func (s *WebSocketStream) ReadObject(v interface{}) error {
 		}
 		return err
 	}
+	if typ == websocket.MessageBinary {
+		if data, err = gunzip(data); err != nil {
+			return err
+		}
+	}
 	return json.Unmarshal(data, v)
 }
 
@@ -44,13 +83,71 @@ func (s *WebSocketStream) WriteObject(v interface{}) error {
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+
+	if s.encoding == EncodingGzip && len(data) >= gzipMinSize {
+		compressed, err := gzipBytes(data)
+		if err != nil {
+			return err
+		}
+		return s.conn.Write(context.Background(), websocket.MessageBinary, compressed)
+	}
 	return s.conn.Write(context.Background(), websocket.MessageText, data)
 }
 
+// Ping sends a WebSocket ping and waits for the pong. The pong is read by
+// ReadObject's loop, so it only arrives while the connection is being read.
+func (s *WebSocketStream) Ping(ctx context.Context) error {
+	return s.conn.Ping(ctx)
+}
+
+// SetEncoding switches outbound framing. Returns false for unsupported
+// encodings, leaving the stream on plain JSON text.
+func (s *WebSocketStream) SetEncoding(encoding string) bool {
+	if encoding != EncodingGzip {
+		return false
+	}
+	s.mu.Lock()
+	s.encoding = encoding
+	s.mu.Unlock()
+	return true
+}
+
 // Close closes the websocket connection with a normal closure status.
 func (s *WebSocketStream) Close() error {
 	return s.conn.Close(websocket.StatusNormalClosure, "")
 }
 
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("gzip frame: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("gzip frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gunzip frame: %w", err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(io.LimitReader(zr, maxInflatedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("gunzip frame: %w", err)
+	}
+	if len(out) > maxInflatedSize {
+		return nil, fmt.Errorf("gunzip frame: exceeds %d bytes", maxInflatedSize)
+	}
+	return out, nil
+}
+
 // Ensure WebSocketStream implements ObjectStream
-var _ jsonrpc2.ObjectStream = (*WebSocketStream)(nil)
+var (
+	_ jsonrpc2.ObjectStream = (*WebSocketStream)(nil)
+	_ EncodingSwitcher      = (*WebSocketStream)(nil)
+	_ Pinger                = (*WebSocketStream)(nil)
+)
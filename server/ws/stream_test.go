@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// newStreamPair returns a server-side WebSocketStream and the client conn
+// talking to it.
+func newStreamPair(t *testing.T) (*WebSocketStream, *websocket.Conn, context.Context) {
+	t.Helper()
+	streamCh := make(chan *WebSocketStream, 1)
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			return
+		}
+		streamCh <- NewWebSocketStream(conn)
+		<-done
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	client, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		client.CloseNow()
+		close(done)
+		cancel()
+		server.Close()
+	})
+	return <-streamCh, client, ctx
+}
+
+func TestWebSocketStream_GzipEncodingCompressesLargeFrames(t *testing.T) {
+	stream, client, ctx := newStreamPair(t)
+
+	if stream.SetEncoding("msgpack") {
+		t.Fatal("expected unsupported encoding to be rejected")
+	}
+	if !stream.SetEncoding(EncodingGzip) {
+		t.Fatal("expected gzip encoding to be accepted")
+	}
+
+	large := map[string]string{"diff": strings.Repeat("+line\n", gzipMinSize)}
+	if err := stream.WriteObject(large); err != nil {
+		t.Fatalf("WriteObject large: %v", err)
+	}
+	if err := stream.WriteObject(map[string]string{"ok": "1"}); err != nil {
+		t.Fatalf("WriteObject small: %v", err)
+	}
+
+	typ, data, err := client.Read(ctx)
+	if err != nil {
+		t.Fatalf("read large: %v", err)
+	}
+	if typ != websocket.MessageBinary {
+		t.Fatalf("expected binary frame for large payload, got %v", typ)
+	}
+	inflated, err := gunzip(data)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	if !strings.Contains(string(inflated), "+line") {
+		t.Errorf("unexpected inflated payload %q", inflated[:32])
+	}
+
+	typ, _, err = client.Read(ctx)
+	if err != nil {
+		t.Fatalf("read small: %v", err)
+	}
+	if typ != websocket.MessageText {
+		t.Errorf("expected text frame for small payload, got %v", typ)
+	}
+}
+
+func TestWebSocketStream_ReadsGzipBinaryFrames(t *testing.T) {
+	stream, client, ctx := newStreamPair(t)
+
+	compressed, err := gzipBytes([]byte(`{"method":"ping"}`))
+	if err != nil {
+		t.Fatalf("gzipBytes: %v", err)
+	}
+	if err := client.Write(ctx, websocket.MessageBinary, compressed); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var got struct {
+		Method string `json:"method"`
+	}
+	if err := stream.ReadObject(&got); err != nil {
+		t.Fatalf("ReadObject: %v", err)
+	}
+	if got.Method != "ping" {
+		t.Errorf("expected method ping, got %q", got.Method)
+	}
+}